@@ -21,24 +21,30 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/cache/memory"
+	"github.com/tricksterproxy/trickster/pkg/cache/readonly"
 	"github.com/tricksterproxy/trickster/pkg/cache/registration"
 	"github.com/tricksterproxy/trickster/pkg/cache/types"
 	"github.com/tricksterproxy/trickster/pkg/config"
 	ro "github.com/tricksterproxy/trickster/pkg/config/reload/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/handlers"
-	th "github.com/tricksterproxy/trickster/pkg/proxy/handlers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight"
 	"github.com/tricksterproxy/trickster/pkg/routing"
 	"github.com/tricksterproxy/trickster/pkg/runtime"
+	"github.com/tricksterproxy/trickster/pkg/tracing"
 	tr "github.com/tricksterproxy/trickster/pkg/tracing/registration"
+	"github.com/tricksterproxy/trickster/pkg/trickster"
 	"github.com/tricksterproxy/trickster/pkg/util/log"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+	"github.com/tricksterproxy/trickster/pkg/util/middleware"
 )
 
 var cfgLock = &sync.Mutex{}
@@ -56,6 +62,12 @@ func runConfig(oldConf *config.Config, wg *sync.WaitGroup, log *log.Logger,
 	// load the config
 	conf, flags, err := config.Load(runtime.ApplicationName, runtime.ApplicationVersion, args)
 	if err != nil {
+		// on a reload, the running config is kept, and the failure is logged
+		if log != nil {
+			handleStartupIssue("could not load configuration",
+				tl.Pairs{"detail": err.Error()}, log, errorsFatal)
+			return err
+		}
 		fmt.Println("\nERROR: Could not load configuration:", err.Error())
 		if flags != nil && !flags.ValidateConfig {
 			PrintUsage()
@@ -70,15 +82,41 @@ func runConfig(oldConf *config.Config, wg *sync.WaitGroup, log *log.Logger,
 		os.Exit(0)
 	}
 
-	err = validateConfig(conf)
-	if err != nil {
-		handleStartupIssue("ERROR: Could not load configuration: "+err.Error(),
-			nil, nil, errorsFatal)
+	// if it's a -print-default-config command, print the default config and exit
+	if flags.PrintDefaultConfig {
+		d, err := config.DefaultDocument()
+		if err != nil {
+			fmt.Println("ERROR: Could not generate the default configuration:", err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(d)
+		os.Exit(0)
 	}
+
+	err = validateConfig(conf)
+	// in validation mode, the config fails on any of its findings, which otherwise are only
+	// logged as warnings
 	if flags.ValidateConfig {
+		if err != nil {
+			fmt.Println("ERROR: Could not load configuration: " + err.Error())
+		}
+		if err != nil || len(conf.LoaderWarnings) > 0 {
+			fmt.Println("Trickster configuration validation failed.")
+			os.Exit(1)
+		}
 		fmt.Println("Trickster configuration validation succeeded.")
 		os.Exit(0)
 	}
+	if err != nil {
+		if log != nil {
+			handleStartupIssue("could not load configuration",
+				tl.Pairs{"detail": err.Error()}, log, errorsFatal)
+			return err
+		}
+		handleStartupIssue("ERROR: Could not load configuration: "+err.Error(),
+			nil, nil, errorsFatal)
+		return err
+	}
 
 	return applyConfig(conf, oldConf, wg, log, oldCaches, args, errorsFatal)
 
@@ -94,44 +132,63 @@ func applyConfig(conf, oldConf *config.Config, wg *sync.WaitGroup, log *log.Logg
 	if conf.Main.ServerName == "" {
 		conf.Main.ServerName, _ = os.Hostname()
 	}
-	runtime.Server = conf.Main.ServerName
 
 	if conf.ReloadConfig == nil {
 		conf.ReloadConfig = ro.NewOptions()
 	}
 
-	log = applyLoggingConfig(conf, oldConf, log)
+	// the logger, caches, router and services of the new config are built without altering those
+	// of the running config, which are only replaced once the new config passes its pre-flight
+	// checks, so that a rejected reload leaves the running config intact
+	newLog, commitLog := applyLoggingConfig(conf, oldConf, log)
 
 	for _, w := range conf.LoaderWarnings {
-		log.Warn(w, tl.Pairs{})
-	}
-
-	//Register Tracing Configurations
-	tracers, err := tr.RegisterAll(conf, log, false)
-	if err != nil {
-		handleStartupIssue("tracing registration failed", tl.Pairs{"detail": err.Error()},
-			log, errorsFatal)
-		return err
+		newLog.Warn(w, tl.Pairs{})
 	}
 
-	// every config (re)load is a new router
-	router := mux.NewRouter()
-	router.HandleFunc(conf.Main.PingHandlerPath, th.PingHandleFunc(conf)).Methods(http.MethodGet)
+	newLog.Info("startup diagnostics", tl.Pairs(conf.StartupDiagnostics().Pairs()))
 
-	var caches = applyCachingConfig(conf, oldConf, log, oldCaches)
-	rh := handlers.ReloadHandleFunc(runConfig, conf, wg, log, caches, args)
+	caches, pending, commitCaches := applyCachingConfig(conf, oldConf, newLog, oldCaches)
+	changes := applyOriginClients(conf, oldConf)
 
-	_, err = routing.RegisterProxyRoutes(conf, router, caches, tracers, log, false)
+	// every config (re)load is a new router, which is only used once the newly-created
+	// caches and the origins pass the pre-flight checks of their required components
+	router, tracers, services, err := trickster.NewCheckedRouter(conf, instance.Default, caches, pending, newLog)
 	if err != nil {
-		handleStartupIssue("route registration failed", tl.Pairs{"detail": err.Error()},
-			log, errorsFatal)
+		rejectConfig(err, log, newLog, pending, errorsFatal)
 		return err
 	}
 
+	runtime.Server = conf.Main.ServerName
+	log = newLog
+	commitLog()
+	applyAccessLogConfig(conf, oldConf)
+	metrics.SetLogOnceEntriesFunc(log.OnceEntries)
+	metrics.SetLogCountsFunc(log.Counts)
+	commitCaches()
+	rh := handlers.ReloadHandleFunc(runConfig, conf, wg, log, caches, args)
+
+	instance.Default.Configure(conf, log)
 	applyListenerConfigs(conf, oldConf, router, http.HandlerFunc(rh), log, tracers)
+	setRunning(caches, tracers)
+	startServices(services)
 
 	metrics.LastReloadSuccessfulTimestamp.Set(float64(time.Now().Unix()))
 	metrics.LastReloadSuccessful.Set(1)
+	// the summary of what was loaded is logged once the config is running, and again after
+	// each successful reload
+	summary := tl.Pairs(conf.StartupSummary())
+	summary["reload"] = oldConf != nil
+	log.Info("startup complete", summary)
+	if oldConf != nil {
+		log.Info("origins reloaded", tl.Pairs{
+			"added":     changes.Added,
+			"removed":   changes.Removed,
+			"changed":   changes.Changed,
+			"unchanged": changes.Unchanged,
+		})
+		drainOriginClients(conf, oldConf, changes)
+	}
 	// add Config Reload HUP Signal Monitor
 	if oldConf != nil && oldConf.Resources != nil {
 		oldConf.Resources.QuitChan <- true // this signals the old hup monitor goroutine to exit
@@ -141,10 +198,38 @@ func applyConfig(conf, oldConf *config.Config, wg *sync.WaitGroup, log *log.Logg
 	return nil
 }
 
-func applyLoggingConfig(c, oc *config.Config, oldLog *log.Logger) *log.Logger {
+// rejectConfig releases the newly-created logger and caches of a config that failed its route
+// registration or pre-flight checks, and logs the failure to the running logger, if any
+func rejectConfig(err error, oldLog, newLog *log.Logger, pending map[string]cache.Cache,
+	errorsFatal bool) {
+
+	for _, c := range pending {
+		c.Close()
+	}
+
+	logger := oldLog
+	if logger == nil {
+		logger = newLog
+	} else if newLog != oldLog {
+		defer newLog.Close()
+	}
+
+	if pe, ok := err.(*preflight.Error); ok {
+		handleStartupIssue("pre-flight check failed",
+			tl.Pairs{"component": pe.Component, "detail": pe.Err.Error()}, logger, errorsFatal)
+		return
+	}
+	handleStartupIssue("route registration failed", tl.Pairs{"detail": err.Error()},
+		logger, errorsFatal)
+}
+
+// applyLoggingConfig returns the logger for the new config, which is the old logger when its log
+// writer is unchanged, along with a function that puts the logger in use once the new config is
+// accepted, by applying its options to the old logger, or by closing the replaced old logger
+func applyLoggingConfig(c, oc *config.Config, oldLog *log.Logger) (*log.Logger, func()) {
 
 	if c == nil || c.Logging == nil {
-		return oldLog
+		return oldLog, func() {}
 	}
 
 	if c.ReloadConfig == nil {
@@ -152,47 +237,103 @@ func applyLoggingConfig(c, oc *config.Config, oldLog *log.Logger) *log.Logger {
 	}
 
 	if oc != nil && oc.Logging != nil {
-		if c.Logging.LogFile == oc.Logging.LogFile &&
-			c.Logging.LogLevel == oc.Logging.LogLevel {
-			// no changes in logging config,
-			// so we keep the old logger intact
-			return oldLog
-		}
-		if c.Logging.LogFile != oc.Logging.LogFile {
-			if oc.Logging.LogFile != "" {
-				// if we're changing from file1 -> console or file1 -> file2, close file1 handle
-				// the extra 1s allows HTTP listeners to close first and finish their log writes
-				go delayedLogCloser(oldLog,
-					time.Duration(c.ReloadConfig.DrainTimeoutSecs+1)*time.Second)
+		// all options other than the log level and its overrides, once limits, dedup window and
+		// access log are options of the log writer
+		wc := *c.Logging
+		wc.LogLevel = oc.Logging.LogLevel
+		wc.LogLevelOverrides = oc.Logging.LogLevelOverrides
+		wc.LogOnceMaxEntries = oc.Logging.LogOnceMaxEntries
+		wc.LogOnceTTLSecs = oc.Logging.LogOnceTTLSecs
+		wc.LogDedupWindowSecs = oc.Logging.LogDedupWindowSecs
+		wc.AccessLogFile = oc.Logging.AccessLogFile
+		wc.AccessLogFormat = oc.Logging.AccessLogFormat
+		wc.AccessLogFlushIntervalMS = oc.Logging.AccessLogFlushIntervalMS
+		// the redact keys are applied by the log writer, so a change to them is a change to it
+		if !reflect.DeepEqual(wc, *oc.Logging) {
+			return initLogger(c), func() {
+				if oc.Logging.LogFile != "" || oc.Logging.LogTarget != "" ||
+					oc.Logging.LogFormat == "gelf" {
+					// if we're changing from file1 -> console or file1 -> file2, or changing
+					// the format or rotation of file1, close file1 handle. the connections to
+					// syslog, the network log target and the GELF endpoint are likewise closed
+					// the extra 1s allows HTTP listeners to close first and finish their log
+					// writes
+					go delayedLogCloser(oldLog,
+						time.Duration(c.ReloadConfig.DrainTimeoutSecs+1)*time.Second)
+				} else if oc.Logging.LogAsyncBufferSize > 0 {
+					// Console remains open, but the events buffered for it are written
+					go delayedLogFlusher(oldLog,
+						time.Duration(c.ReloadConfig.DrainTimeoutSecs+1)*time.Second)
+				}
 			}
-			return initLogger(c)
 		}
-		if c.Logging.LogLevel != oc.Logging.LogLevel {
-			// the only change is the log level, so update it and return the original logger
+		// the log writer is unchanged, so we keep the old logger intact, restoring the
+		// configured level in case it was changed at runtime, and forgetting its once
+		// entries so that their events are logged again for the reloaded config
+		return oldLog, func() {
 			oldLog.SetLogLevel(c.Logging.LogLevel)
-			return oldLog
+			oldLog.SetLevelOverrides(c.Logging.LogLevelOverrides)
+			oldLog.SetOnceLimits(c.Logging.LogOnceMaxEntries,
+				time.Duration(c.Logging.LogOnceTTLSecs)*time.Second)
+			oldLog.SetDedupWindow(time.Duration(c.Logging.LogDedupWindowSecs) * time.Second)
+			oldLog.ResetAllOnce()
 		}
 	}
 
-	return initLogger(c)
+	return initLogger(c), func() {}
+}
+
+// applyAccessLogConfig enables the access log of the new config, keeping that of the old config
+// when its options are unchanged
+func applyAccessLogConfig(c, oc *config.Config) {
+
+	if c == nil || c.Logging == nil {
+		return
+	}
+
+	if oc != nil && oc.Logging != nil &&
+		c.Logging.AccessLogFile == oc.Logging.AccessLogFile &&
+		c.Logging.AccessLogFormat == oc.Logging.AccessLogFormat &&
+		c.Logging.AccessLogFlushIntervalMS == oc.Logging.AccessLogFlushIntervalMS &&
+		c.Logging.LogIncludeHostname == oc.Logging.LogIncludeHostname &&
+		reflect.DeepEqual(c.Logging.RedactKeys, oc.Logging.RedactKeys) {
+		return
+	}
+
+	if prev := middleware.SetAccessLogger(log.NewAccessLogger(c)); prev != nil {
+		// the old access log is closed once outstanding requests have drained
+		go func() {
+			time.Sleep(time.Duration(c.ReloadConfig.DrainTimeoutSecs+1) * time.Second)
+			prev.Close()
+		}()
+	}
 }
 
+// applyCachingConfig returns the caches for the new config, reusing the unchanged caches of the old
+// config, along with the subset of newly-created caches, which are connected by the pre-flight
+// checks, and a function that applies the new options to the reused caches and closes the unused
+// old caches once the new config is accepted
 func applyCachingConfig(c, oc *config.Config, logger *log.Logger,
-	oldCaches map[string]cache.Cache) map[string]cache.Cache {
+	oldCaches map[string]cache.Cache) (map[string]cache.Cache, map[string]cache.Cache, func()) {
 
 	if c == nil {
-		return nil
+		return nil, nil, func() {}
 	}
 
 	caches := make(map[string]cache.Cache)
+	pending := make(map[string]cache.Cache)
 
 	if oc == nil || oldCaches == nil {
 		for k, v := range c.Caches {
-			caches[k] = registration.NewCache(k, v, logger)
+			caches[k] = registration.New(k, v, logger)
+			pending[k] = caches[k]
 		}
-		return caches
+		return caches, pending, func() {}
 	}
 
+	var updates []func()
+	var unused []cache.Cache
+
 	for k, v := range c.Caches {
 
 		if w, ok := oldCaches[k]; ok {
@@ -202,6 +343,11 @@ func applyCachingConfig(c, oc *config.Config, logger *log.Logger,
 			// if a cache is in both the old and new config, and unchanged, pass the
 			// pre-existing object instead of making a new one
 			if v.Equal(ocfg) {
+				// the read-only options are applied to the reused cache
+				if rc, ok := w.(*readonly.Cache); ok {
+					v := v
+					updates = append(updates, func() { rc.UpdateOptions(v) })
+				}
 				caches[k] = w
 				continue
 			}
@@ -213,24 +359,78 @@ func applyCachingConfig(c, oc *config.Config, logger *log.Logger,
 			if ocfg.CacheTypeID == v.CacheTypeID &&
 				ocfg.CacheTypeID == types.CacheTypeMemory {
 				if v.Index != nil {
-					mc := w.(*memory.Cache)
-					mc.Index.UpdateOptions(v.Index)
+					mc, idx := w.(*memory.Cache), v.Index
+					updates = append(updates, func() { mc.Index.UpdateOptions(idx) })
 				}
 				caches[k] = w
 				continue
 			}
 
 			// if we got to this point, the cache won't be used, so lets close it
-			go func() {
-				time.Sleep(time.Second * time.Duration(c.ReloadConfig.DrainTimeoutSecs))
-				w.Close()
-			}()
+			unused = append(unused, w)
 		}
 
 		// the newly-named cache is not in the old config or couldn't be reused, so make it anew
-		caches[k] = registration.NewCache(k, v, logger)
+		caches[k] = registration.New(k, v, logger)
+		pending[k] = caches[k]
+	}
+
+	// the caches that were removed from the config are likewise closed
+	for k, w := range oldCaches {
+		if _, ok := c.Caches[k]; !ok {
+			unused = append(unused, w)
+		}
+	}
+
+	return caches, pending, func() {
+		for _, f := range updates {
+			f()
+		}
+		if len(unused) == 0 {
+			return
+		}
+		go func() {
+			time.Sleep(time.Second * time.Duration(c.ReloadConfig.DrainTimeoutSecs))
+			for _, w := range unused {
+				w.Close()
+			}
+		}()
+	}
+}
+
+// applyOriginClients passes the upstream clients of the origins that are unchanged from the old
+// config to the new config, so that their pooled connections are kept across a reload
+func applyOriginClients(c, oc *config.Config) *config.OriginChanges {
+	changes := c.OriginChanges(oc)
+	if oc == nil {
+		return changes
+	}
+	for _, k := range changes.Unchanged {
+		c.Origins[k].HTTPClient = oc.Origins[k].HTTPClient
+	}
+	return changes
+}
+
+// drainOriginClients closes the idle connections of the upstream clients of the origins that were
+// removed or changed by the new config, once their outstanding requests have drained
+func drainOriginClients(c, oc *config.Config, changes *config.OriginChanges) {
+	var clients []*http.Client
+	for _, l := range [][]string{changes.Removed, changes.Changed} {
+		for _, k := range l {
+			if hc := oc.Origins[k].HTTPClient; hc != nil {
+				clients = append(clients, hc)
+			}
+		}
+	}
+	if len(clients) == 0 {
+		return
 	}
-	return caches
+	go func() {
+		time.Sleep(time.Second * time.Duration(c.ReloadConfig.DrainTimeoutSecs))
+		for _, hc := range clients {
+			hc.CloseIdleConnections()
+		}
+	}()
 }
 
 func initLogger(c *config.Config) *log.Logger {
@@ -251,6 +451,58 @@ func initLogger(c *config.Config) *log.Logger {
 	return log
 }
 
+// running holds the caches and tracers of the running config, which are closed and flushed
+// before Trickster exits on a fatal error, so that caches such as bbolt are not left corrupted,
+// and the spans of the tracers and the buffered access log entries are not lost
+var running struct {
+	sync.Mutex
+	caches   map[string]cache.Cache
+	tracers  tracing.Tracers
+	services *routing.Services
+}
+
+var registerRunningHook sync.Once
+
+// setRunning sets the caches and tracers of the running config
+func setRunning(caches map[string]cache.Cache, tracers tracing.Tracers) {
+	running.Lock()
+	running.caches = caches
+	running.tracers = tracers
+	running.Unlock()
+	registerRunningHook.Do(func() { tl.RegisterFatalHook(closeRunning) })
+}
+
+// startServices starts the health check probers and refresh jobs of the new config, once those of
+// the previous config are stopped, and serves its refresh jobs in the Refresh Jobs API
+func startServices(s *routing.Services) {
+	running.Lock()
+	prev := running.services
+	running.services = s
+	running.Unlock()
+	prev.Stop()
+	s.Start()
+	instance.Default.SetScheduler(s.Scheduler())
+}
+
+// closeRunning flushes the tracers and the access log, and closes the caches of the running config
+func closeRunning() {
+	running.Lock()
+	defer running.Unlock()
+	if al := middleware.SetAccessLogger(nil); al != nil {
+		al.Close()
+	}
+	for _, t := range running.tracers {
+		if t != nil && t.Flusher != nil {
+			t.Flusher()
+		}
+	}
+	for _, c := range running.caches {
+		if c != nil {
+			c.Close()
+		}
+	}
+}
+
 func delayedLogCloser(log *log.Logger, delay time.Duration) {
 	// we can't immediately close the log, because some outstanding
 	// http requests might still be on the old reference, so this will
@@ -262,6 +514,15 @@ func delayedLogCloser(log *log.Logger, delay time.Duration) {
 	log.Close()
 }
 
+func delayedLogFlusher(log *log.Logger, delay time.Duration) {
+	// like delayedLogCloser, but for a logger whose writer remains open
+	if log == nil {
+		return
+	}
+	time.Sleep(delay)
+	log.Flush()
+}
+
 func handleStartupIssue(event string, detail log.Pairs, logger *log.Logger, exitFatal bool) {
 	metrics.LastReloadSuccessful.Set(0)
 	if event != "" {
@@ -299,7 +560,7 @@ func validateConfig(conf *config.Config) error {
 		return err
 	}
 
-	_, err = routing.RegisterProxyRoutes(conf, router, caches, tracers, log, true)
+	_, _, err = routing.RegisterProxyRoutes(conf, instance.Default, router, caches, tracers, log, true)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/readonly"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const testReloadConfig = `
+[frontend]
+  listen_port = 0
+[metrics]
+  listen_port = 0
+[reloading]
+  listen_port = 0
+  drain_timeout_secs = 0
+[logging]
+  log_file = '%s'
+  log_level = '%s'
+[caches]
+  [caches.reload]
+%s
+[origins]
+  [origins.reload]
+  origin_type = 'reverseproxycache'
+  origin_url = '%s'
+  cache_name = 'reload'
+  health_check_interval_ms = 60000
+%s`
+
+func TestApplyConfigRejected(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "trickster-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer origin.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	logFile := filepath.Join(dir, "trickster.log")
+	conf, err := config.LoadDocument(fmt.Sprintf(testReloadConfig, logFile, "info", `
+  cache_type = 'bbolt'
+    [caches.reload.bbolt]
+    filename = '`+filepath.Join(dir, "trickster.db")+`'
+`, origin.URL, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg := &sync.WaitGroup{}
+	if err := applyConfig(conf, nil, wg, nil, nil, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	running.Lock()
+	caches, services := running.caches, running.services
+	running.Unlock()
+	defer func() {
+		services.Stop()
+		caches["reload"].Close()
+	}()
+	log := tl.New(conf)
+
+	// the new config changes the log level and the cache, and requires an origin that is down
+	newConf, err := config.LoadDocument(fmt.Sprintf(testReloadConfig, logFile, "debug", `
+  cache_type = 'filesystem'
+    [caches.reload.filesystem]
+    cache_path = '`+filepath.Join(dir, "cache")+`'
+`, origin.URL, `
+  [origins.down]
+  origin_type = 'reverseproxycache'
+  origin_url = '`+down.URL+`'
+  preflight_policy = 'required'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(newConf, conf, wg, log, caches, nil, false); err == nil {
+		t.Fatal("expected pre-flight error")
+	}
+
+	// the old logger, cache and health check prober are unchanged once the drain has elapsed
+	time.Sleep(500 * time.Millisecond)
+
+	if log.Level() != "info" {
+		t.Errorf("expected %s got %s", "info", log.Level())
+	}
+
+	c := caches["reload"]
+	if err := c.Store("key", []byte("value"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if _, s, _ := c.Retrieve("key", false); s != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, s)
+	}
+	if rc, ok := readonly.Lookup("reload"); !ok || rc != c {
+		t.Error("expected the old cache to remain registered")
+	}
+
+	running.Lock()
+	if running.services != services {
+		t.Error("expected the old services to remain running")
+	}
+	running.Unlock()
+	if _, ok := instance.Default.Health.OriginStatus("reload"); !ok {
+		t.Error("expected the old health check prober to remain running")
+	}
+	if _, ok := instance.Default.Health.OriginStatus("down"); ok {
+		t.Error("expected no health check prober for the rejected config")
+	}
+}
@@ -18,12 +18,21 @@ package main
 
 import (
 	"crypto/tls"
+	"errors"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/adminauth"
+	"github.com/tricksterproxy/trickster/pkg/proxy/configdiff"
+	"github.com/tricksterproxy/trickster/pkg/proxy/grpchealth"
 	ph "github.com/tricksterproxy/trickster/pkg/proxy/handlers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/proxy/invalidation"
 	"github.com/tricksterproxy/trickster/pkg/proxy/listener"
+	"github.com/tricksterproxy/trickster/pkg/proxy/resolve"
 	ttls "github.com/tricksterproxy/trickster/pkg/proxy/tls"
 	"github.com/tricksterproxy/trickster/pkg/routing"
 	"github.com/tricksterproxy/trickster/pkg/tracing"
@@ -47,11 +56,49 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 
 	adminRouter := http.NewServeMux()
 	adminRouter.Handle(conf.ReloadConfig.HandlerPath, reloadHandler)
+	if conf.ReloadConfig.RefreshJobsHandlerPath != "" {
+		adminRouter.HandleFunc(conf.ReloadConfig.RefreshJobsHandlerPath, ph.RefreshJobsHandleFunc(instance.Default))
+	}
+	if conf.ReloadConfig.TopQueriesHandlerPath != "" {
+		adminRouter.HandleFunc(conf.ReloadConfig.TopQueriesHandlerPath, ph.TopQueriesHandleFunc(instance.Default))
+	}
+	if conf.ReloadConfig.ConfigDiffHandlerPath != "" {
+		adminRouter.HandleFunc(conf.ReloadConfig.ConfigDiffHandlerPath, configdiff.HandleFunc(conf, instance.Default))
+	}
+	if conf.ReloadConfig.ResolveHandlerPath != "" {
+		adminRouter.HandleFunc(conf.ReloadConfig.ResolveHandlerPath, resolve.HandleFunc(conf))
+	}
+	if conf.ReloadConfig.BackgroundTasksHandlerPath != "" {
+		adminRouter.HandleFunc(conf.ReloadConfig.BackgroundTasksHandlerPath, ph.BackgroundTasksHandleFunc)
+	}
+	if p := conf.ReloadConfig.OriginsHandlerPath; p != "" {
+		// the origin is named by the path element following the handler path
+		h := ph.OriginsHandleFunc(conf, instance.Default, p)
+		adminRouter.HandleFunc(strings.TrimSuffix(p, "/")+"/", h)
+		if !strings.HasSuffix(p, "/") {
+			adminRouter.HandleFunc(p, h)
+		}
+	}
+	if conf.ReloadConfig.InvalidateHandlerPath != "" {
+		adminRouter.HandleFunc(conf.ReloadConfig.InvalidateHandlerPath, invalidation.HandleFunc(conf, instance.Default))
+	}
+	if conf.ReloadConfig.LogLevelHandlerPath != "" {
+		adminRouter.HandleFunc(conf.ReloadConfig.LogLevelHandlerPath, ph.LogLevelHandleFunc(log))
+	}
+	if conf.ReloadConfig.CacheEventsHandlerPath != "" {
+		adminRouter.HandleFunc(conf.ReloadConfig.CacheEventsHandlerPath, ph.CacheEventsHandleFunc(instance.Default))
+	}
+	if conf.ReloadConfig.CacheReadOnlyHandlerPath != "" {
+		adminRouter.HandleFunc(conf.ReloadConfig.CacheReadOnlyHandlerPath, ph.CacheReadOnlyHandleFunc(log))
+	}
+
+	// the admin handlers are authorized by the admin tokens, when any are configured
+	adminHandler := adminauth.Handler(conf.ReloadConfig.AdminTokens, log, adminRouter)
 
 	// No changes in frontend config
 	if oldConf != nil && oldConf.Frontend != nil &&
 		oldConf.Frontend.Equal(conf.Frontend) {
-		lg.UpdateFrontendRouters(router, adminRouter)
+		lg.UpdateFrontendRouters(router, adminHandler)
 		if ttls.OptionsChanged(conf, oldConf) {
 			tlsConfig, _ = conf.TLSCertConfig()
 			l := lg.Get("tlsListener")
@@ -165,11 +212,55 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 		}
 		go lg.StartListener("reloadListener",
 			conf.ReloadConfig.ListenAddress, conf.ReloadConfig.ListenPort,
-			conf.Frontend.ConnectionsLimit, nil, mr, wg, nil, true, 0, log)
+			conf.Frontend.ConnectionsLimit, nil,
+			adminauth.Handler(conf.ReloadConfig.AdminTokens, log, mr), wg, nil, true, 0, log)
 	} else {
 		mr := http.NewServeMux()
 		mr.HandleFunc(conf.Main.ConfigHandlerPath, ph.ConfigHandleFunc(conf))
 		mr.Handle(conf.ReloadConfig.HandlerPath, reloadHandler)
-		lg.UpdateRouter("reloadListener", mr)
+		lg.UpdateRouter("reloadListener", adminauth.Handler(conf.ReloadConfig.AdminTokens, log, mr))
+	}
+
+	applyGRPCHealthConfig(conf, oldConf, log)
+}
+
+// applyGRPCHealthConfig starts, restarts or stops the gRPC Health listener as its config requires,
+// or updates the origins it reports when the listener is unchanged
+func applyGRPCHealthConfig(conf, oldConf *config.Config, log *tl.Logger) {
+
+	if conf.GRPCHealth == nil || conf.GRPCHealth.ListenPort < 1 {
+		grpchealth.Stop()
+		return
+	}
+
+	originNames := make([]string, 0, len(conf.Origins))
+	for k := range conf.Origins {
+		originNames = append(originNames, k)
+	}
+	sort.Strings(originNames)
+
+	if oldConf != nil && conf.GRPCHealth.Equal(oldConf.GRPCHealth) &&
+		(!conf.GRPCHealth.ServeTLS || !ttls.OptionsChanged(conf, oldConf)) {
+		grpchealth.SetOrigins(originNames)
+		return
+	}
+
+	var tlsConfig *tls.Config
+	if conf.GRPCHealth.ServeTLS {
+		var err error
+		tlsConfig, err = conf.TLSCertConfig()
+		if err == nil && tlsConfig == nil {
+			err = errors.New("no frontend tls certificates are configured")
+		}
+		if err != nil {
+			log.Error("unable to start grpc health listener due to certificate error",
+				tl.Pairs{"detail": err})
+			return
+		}
+	}
+	err := grpchealth.Serve(conf.GRPCHealth.ListenAddress, conf.GRPCHealth.ListenPort,
+		tlsConfig, instance.Default.Readiness, originNames, log)
+	if err != nil {
+		log.Error("unable to start grpc health listener", tl.Pairs{"detail": err})
 	}
 }
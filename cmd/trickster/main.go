@@ -18,10 +18,14 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"sync"
 
 	"github.com/tricksterproxy/trickster/pkg/runtime"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
@@ -42,6 +46,13 @@ var wg = &sync.WaitGroup{}
 func main() {
 	runtime.ApplicationName = applicationName
 	runtime.ApplicationVersion = applicationVersion
+	runtime.ApplicationCommitID = applicationGitCommitID
+	runtime.ApplicationBuildTime = applicationBuildTime
+	// the metrics listener serves the default registry of the Prometheus client
+	if err := metrics.Register(prometheus.DefaultRegisterer); err != nil {
+		fmt.Println("ERROR: Could not register metrics:", err.Error())
+		os.Exit(1)
+	}
 	runConfig(nil, wg, nil, nil, os.Args[1:], fatalStartupErrors)
 	wg.Wait()
 }
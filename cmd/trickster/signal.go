@@ -29,6 +29,10 @@ import (
 
 var hups = make(chan os.Signal, 1)
 
+// reopens receives the signals to reopen the log file, which are only notified on platforms
+// that support SIGUSR1
+var reopens = make(chan os.Signal, 1)
+
 func init() {
 	signal.Notify(hups, syscall.SIGHUP)
 }
@@ -52,8 +56,22 @@ func startHupMonitor(conf *config.Config, wg *sync.WaitGroup, log *tl.Logger,
 						return // runConfig will start a new HupMonitor in place of this one
 					}
 				}
+				// a log level changed at runtime is restored to the configured level
+				if previous := log.SetLogLevel(conf.Logging.LogLevel); previous != log.Level() {
+					log.Info("log level changed", tl.Pairs{"previous": previous,
+						"level": log.Level(), "source": "sighup"})
+				}
 				conf.Main.ReloaderLock.Unlock()
 				log.Warn("configuration NOT reloaded", tl.Pairs{})
+			case <-reopens:
+				// the log file is reopened after it is rotated externally, such as by logrotate
+				if err := log.Reopen(); err != nil {
+					log.Error("unable to reopen log file", tl.Pairs{"logFile": conf.Logging.LogFile,
+						"detail": err.Error(), "source": "sigusr1"})
+				} else if conf.Logging.LogRotation == "external" {
+					log.Info("log file reopened", tl.Pairs{"logFile": conf.Logging.LogFile,
+						"source": "sigusr1"})
+				}
 			case <-conf.Resources.QuitChan:
 				return
 			}
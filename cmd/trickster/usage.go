@@ -31,7 +31,10 @@ Trickster Usage:
  trickster -version
 
  Validating a configuration file:
-  trickster -validate-config -config /path/to/file.conf
+  trickster -validate -config /path/to/file.conf
+
+ Printing the default configuration, with an example of each origin and cache type:
+  trickster -print-default-config
 
  Using a configuration file:
   trickster -config /path/to/file.conf [-log-level DEBUG|INFO|WARN|ERROR] [-proxy-port 8480] [-metrics-port 8481]
@@ -85,5 +88,5 @@ func PrintVersion() {
 func PrintUsage() {
 	fmt.Println()
 	fmt.Println(version())
-	fmt.Println(usageText)
+	fmt.Print(usageText + "\n")
 }
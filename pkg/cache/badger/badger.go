@@ -40,6 +40,19 @@ type Cache struct {
 	dbh *badger.DB
 }
 
+func init() {
+	cache.MustRegisterProvider("badger", newProviderCache)
+}
+
+func newProviderCache(name string, cfg *options.Options, logger *log.Logger) cache.Cache {
+	return &Cache{Name: name, Config: cfg, Logger: logger}
+}
+
+// ReportsMetrics returns true, since the Cache records its own operation metrics
+func (c *Cache) ReportsMetrics() bool {
+	return true
+}
+
 // Locker returns the cache's locker
 func (c *Cache) Locker() locks.NamedLocker {
 	return c.locker
@@ -73,7 +86,7 @@ func (c *Cache) Connect() error {
 
 // Store places the the data into the Badger Cache using the provided Key and TTL
 func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
-	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+	metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
 	c.Logger.Debug("badger cache store", log.Pairs{"key": cacheKey, "ttl": ttl})
 	return c.dbh.Update(func(txn *badger.Txn) error {
 		return txn.SetEntry(&badger.Entry{Key: []byte(cacheKey), Value: data, ExpiresAt: uint64(time.Now().Add(ttl).Unix())})
@@ -96,19 +109,19 @@ func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.Loo
 
 	if err == nil {
 		c.Logger.Debug("badger cache retrieve", log.Pairs{"key": cacheKey})
-		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+		metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
 		return data, status.LookupStatusHit, nil
 	}
 
 	if err == badger.ErrKeyNotFound {
 		err = cache.ErrKNF
 		c.Logger.Debug("badger cache miss", log.Pairs{"key": cacheKey})
-		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		metrics.ObserveCacheMiss(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType)
 		return nil, status.LookupStatusKeyMiss, err
 	}
 
 	c.Logger.Debug("badger cache retrieve failed", log.Pairs{"key": cacheKey, "reason": err.Error()})
-	metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+	metrics.ObserveCacheMiss(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType)
 	return data, status.LookupStatusError, err
 }
 
@@ -118,7 +131,7 @@ func (c *Cache) Remove(cacheKey string) {
 	c.dbh.Update(func(txn *badger.Txn) error {
 		return txn.Delete([]byte(cacheKey))
 	})
-	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+	metrics.ObserveCacheDel(c.Config.Metrics, c.Name, c.Config.CacheType, 0)
 }
 
 // BulkRemove removes a list of objects from the cache. noLock is not used for Badger
@@ -130,7 +143,7 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 			if err := txn.Delete([]byte(key)); err != nil {
 				return err
 			}
-			metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+			metrics.ObserveCacheDel(c.Config.Metrics, c.Name, c.Config.CacheType, 0)
 		}
 		return nil
 	})
@@ -138,6 +151,9 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 
 // Close closes the Badger Cache
 func (c *Cache) Close() error {
+	if c.dbh == nil {
+		return nil
+	}
 	return c.dbh.Close()
 }
 
@@ -154,7 +170,7 @@ func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
 	})
 	c.Logger.Debug("badger cache update-ttl", log.Pairs{"key": cacheKey, "ttl": ttl, "success": err == nil})
 	if err == nil {
-		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "update-ttl", "none", 0)
+		metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "update-ttl", "none", 0)
 	}
 }
 
@@ -265,6 +265,11 @@ func TestBadgerCache_Close(t *testing.T) {
 	cacheConfig := &co.Options{CacheType: cacheType, Badger: &bo.Options{Directory: dir, ValueDirectory: dir}}
 	bc := Cache{Config: cacheConfig, Logger: tl.ConsoleLogger("error")}
 
+	// an unconnected cache should close
+	if err := bc.Close(); err != nil {
+		t.Error(err)
+	}
+
 	if err := bc.Connect(); err != nil {
 		t.Error(err)
 	}
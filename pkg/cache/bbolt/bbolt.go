@@ -45,6 +45,19 @@ type Cache struct {
 	dbh *bbolt.DB
 }
 
+func init() {
+	cache.MustRegisterProvider("bbolt", newProviderCache)
+}
+
+func newProviderCache(name string, cfg *options.Options, logger *log.Logger) cache.Cache {
+	return &Cache{Name: name, Config: cfg, Logger: logger}
+}
+
+// ReportsMetrics returns true, since the Cache records its own operation metrics
+func (c *Cache) ReportsMetrics() bool {
+	return true
+}
+
 // Locker returns the cache's locker
 func (c *Cache) Locker() locks.NamedLocker {
 	return c.locker
@@ -85,8 +98,14 @@ func (c *Cache) Connect() error {
 
 	// Load Index here and pass bytes as param2
 	indexData, _, _ := c.retrieve(index.IndexKey, false, false)
-	c.Index = index.NewIndex(c.Name, c.Config.CacheType, indexData,
-		c.Config.Index, c.BulkRemove, c.storeNoIndex, c.Logger)
+	deletions, _, _ := c.retrieve(index.DeletionsKey, false, false)
+	c.Index = index.NewIndex(c.Name, c.Config, indexData, c.BulkRemove, c.storeNoIndex, c.Logger)
+
+	// recover deletions that were pending, and keys that were orphaned, when the cache was last closed
+	if n := c.Index.Reconcile(deletions, c.storedKeys()); n > 0 {
+		c.Logger.Info("bbolt cache deletions recovered", log.Pairs{"name": c.Name, "pending": n})
+	}
+	c.Index.StartDeleter(c.deletePending, c.Logger)
 	return nil
 }
 
@@ -106,19 +125,22 @@ func (c *Cache) storeNoIndex(cacheKey string, data []byte) {
 
 func (c *Cache) store(cacheKey string, data []byte, ttl time.Duration, updateIndex bool) error {
 
-	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+	metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
 
 	o := &index.Object{Key: cacheKey, Value: data, Expiration: time.Now().Add(ttl)}
 	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
 	err := writeToBBolt(c.dbh, c.Config.BBolt.Bucket, cacheKey, o.ToBytes())
-	nl.Release()
 	if err != nil {
+		nl.Release()
 		return err
 	}
 	c.Logger.Debug("bbolt cache store", log.Pairs{"key": cacheKey, "ttl": ttl, "indexed": updateIndex})
+	// the index is updated under the key's lock, so the deleter can't delete the object once
+	// it has been written again
 	if updateIndex {
 		c.Index.UpdateObject(o)
 	}
+	nl.Release()
 	return nil
 }
 
@@ -140,13 +162,19 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool,
 	atime bool) ([]byte, status.LookupStatus, error) {
 
 	nl, _ := c.locker.RAcquire(c.lockPrefix + cacheKey)
+	if c.Index != nil && c.Index.IsDeleted(cacheKey) {
+		nl.RRelease()
+		c.Logger.Debug("bbolt cache miss", log.Pairs{"key": cacheKey})
+		metrics.ObserveCacheMiss(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
 	var data []byte
 	err := c.dbh.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(c.Config.BBolt.Bucket))
 		data = b.Get([]byte(cacheKey))
 		if data == nil {
 			c.Logger.Debug("bbolt cache miss", log.Pairs{"key": cacheKey})
-			metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+			metrics.ObserveCacheMiss(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType)
 			return cache.ErrKNF
 		}
 		return nil
@@ -158,7 +186,7 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool,
 
 	o, err := index.ObjectFromBytes(data)
 	if err != nil {
-		_, err = metrics.CacheError(cacheKey, c.Name, c.Config.CacheType,
+		_, err = metrics.CacheError(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType,
 			"value for key [%s] could not be deserialized from cache")
 		return nil, status.LookupStatusError, err
 	}
@@ -176,12 +204,12 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool,
 		if atime {
 			go c.Index.UpdateObjectAccessTime(cacheKey)
 		}
-		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+		metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
 		return o.Value, status.LookupStatusHit, nil
 	}
 	// Cache Object has been expired but not reaped, go ahead and delete it
 	go c.remove(cacheKey, false)
-	metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+	metrics.ObserveCacheMiss(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType)
 	return nil, status.LookupStatusKeyMiss, cache.ErrKNF
 }
 
@@ -210,13 +238,20 @@ func (c *Cache) remove(cacheKey string, isBulk bool) error {
 	if !isBulk {
 		go c.Index.RemoveObject(cacheKey)
 	}
-	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+	metrics.ObserveCacheDel(c.Config.Metrics, c.Name, c.Config.CacheType, 0)
 	c.Logger.Debug("bbolt cache key delete", log.Pairs{"key": cacheKey})
 	return nil
 }
 
-// BulkRemove removes a list of objects from the cache
+// BulkRemove removes a list of objects from the cache. Unless the index is configured with
+// sync_bulk_remove, the objects are marked deleted in the index, so that they are no longer
+// retrievable, and are deleted from the bucket in the background by the index's deleter
 func (c *Cache) BulkRemove(cacheKeys []string) {
+	if !c.Config.Index.SyncBulkRemove {
+		c.Index.MarkDeleted(cacheKeys)
+		return
+	}
+
 	wg := &sync.WaitGroup{}
 	for _, cacheKey := range cacheKeys {
 		wg.Add(1)
@@ -228,6 +263,56 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 	wg.Wait()
 }
 
+// deletePending deletes a batch of objects that are marked deleted in the index in a single
+// transaction, skipping any that have been written again since. The locks for all of the keys
+// are acquired before the transaction begins, so that none of the objects are written during it.
+func (c *Cache) deletePending(cacheKeys []string) {
+	held := make(map[string]locks.NamedLock, len(cacheKeys))
+	deletions := make([]string, 0, len(cacheKeys))
+	for _, cacheKey := range cacheKeys {
+		// a key may be queued more than once, but its lock can only be acquired once
+		if _, ok := held[cacheKey]; ok {
+			continue
+		}
+		held[cacheKey], _ = c.locker.Acquire(c.lockPrefix + cacheKey)
+		if c.Index.ClearDeleted(cacheKey) {
+			deletions = append(deletions, cacheKey)
+		}
+	}
+	if len(deletions) > 0 {
+		err := c.dbh.Update(func(tx *bbolt.Tx) error {
+			b := tx.Bucket([]byte(c.Config.BBolt.Bucket))
+			for _, cacheKey := range deletions {
+				if err := b.Delete([]byte(cacheKey)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			c.Logger.Error("bbolt cache batch delete failure",
+				log.Pairs{"keyCount": len(deletions), "reason": err.Error()})
+		} else {
+			metrics.ObserveCacheDel(c.Config.Metrics, c.Name, c.Config.CacheType, float64(len(deletions)))
+		}
+	}
+	for _, nl := range held {
+		nl.Release()
+	}
+}
+
+// storedKeys returns the keys of the objects stored in the bucket
+func (c *Cache) storedKeys() []string {
+	keys := make([]string, 0)
+	c.dbh.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(c.Config.BBolt.Bucket)).ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys
+}
+
 // Close closes the Cache
 func (c *Cache) Close() error {
 	if c.Index != nil {
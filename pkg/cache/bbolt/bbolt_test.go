@@ -25,11 +25,14 @@ import (
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	bo "github.com/tricksterproxy/trickster/pkg/cache/bbolt/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/index"
 	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
 	co "github.com/tricksterproxy/trickster/pkg/cache/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
 	"github.com/tricksterproxy/trickster/pkg/locks"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"github.com/coreos/bbolt"
 )
 
 const cacheType = "bbolt"
@@ -434,6 +437,124 @@ func TestBboltCache_BulkRemove(t *testing.T) {
 
 }
 
+func isStored(bc *Cache, cacheKey string) bool {
+	var stored bool
+	bc.dbh.View(func(tx *bbolt.Tx) error {
+		stored = tx.Bucket([]byte(bc.Config.BBolt.Bucket)).Get([]byte(cacheKey)) != nil
+		return nil
+	})
+	return stored
+}
+
+func TestBboltCache_BulkRemoveDeferred(t *testing.T) {
+
+	cacheConfig := newCacheConfig()
+	bc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	defer os.RemoveAll(cacheConfig.BBolt.Filename)
+
+	err := bc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	for _, key := range []string{"key1", "key2"} {
+		err = bc.Store(key, []byte("data"), time.Duration(60)*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	bc.BulkRemove([]string{"key1", "key2", "key1"})
+
+	// key2 is written again before the deleter runs, and so it should not be deleted
+	err = bc.Store("key2", []byte("data2"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for i := 0; i < 100 && bc.Index.PendingDeletions() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if isStored(&bc, "key1") {
+		t.Errorf("expected key1 to be deleted")
+	}
+	data, ls, err := bc.Retrieve("key2", false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data2" || ls != status.LookupStatusHit {
+		t.Errorf("unexpected retrieval %s %s", data, ls)
+	}
+}
+
+func TestBboltCache_BulkRemoveSync(t *testing.T) {
+
+	cacheConfig := newCacheConfig()
+	cacheConfig.Index.SyncBulkRemove = true
+	bc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	defer os.RemoveAll(cacheConfig.BBolt.Filename)
+
+	err := bc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	err = bc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the key should be deleted before BulkRemove returns
+	bc.BulkRemove([]string{cacheKey})
+	if isStored(&bc, cacheKey) {
+		t.Errorf("expected key to be deleted")
+	}
+	if n := bc.Index.PendingDeletions(); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+}
+
+func TestBboltCache_Reconcile(t *testing.T) {
+
+	cacheConfig := newCacheConfig()
+	bc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	defer os.RemoveAll(cacheConfig.BBolt.Filename)
+
+	err := bc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an orphaned key, left by a deletion that was pending when the cache was closed,
+	// should be deleted when the cache is connected again
+	orphan := &index.Object{Key: "orphan", Value: []byte("data"), Expiration: time.Now().Add(time.Minute)}
+	err = writeToBBolt(bc.dbh, cacheConfig.BBolt.Bucket, "orphan", orphan.ToBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.Close()
+
+	bc = Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	err = bc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	_, ls, _ := bc.Retrieve("orphan", false)
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+	for i := 0; i < 100 && bc.Index.PendingDeletions() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if isStored(&bc, "orphan") {
+		t.Errorf("expected orphaned key to be deleted")
+	}
+}
+
 func BenchmarkCache_BulkRemove(b *testing.B) {
 	bc := storeBenchmark(b)
 	defer bc.Close()
@@ -30,6 +30,10 @@ import (
 // ErrKNF represents the error "key not found in cache"
 var ErrKNF = errors.New("key not found in cache")
 
+// ErrReadOnly represents the error "cache is in read-only mode", which is returned when an object
+// is not stored because its cache is in read-only mode
+var ErrReadOnly = errors.New("cache is in read-only mode")
+
 // Cache is the interface for the supported caching fabrics
 // When making new cache types, Retrieve() must return an error on cache miss
 type Cache interface {
@@ -0,0 +1,345 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package events records the mutations of Trickster's caches as a stream of structured events,
+// which are retained in a bounded ring buffer for external observers such as cache warmers and
+// auditors, and optionally written to a datagram sink or a rotating file
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+)
+
+// The types of cache mutations
+const (
+	// TypeStore is the write of a new cache object
+	TypeStore = "store"
+	// TypeMerge is the write of a timeseries cache object that was merged with newly-fetched data
+	TypeMerge = "merge"
+	// TypeEvict is the removal of a cache object by the cache's index, such as when it expires
+	// or the cache exceeds its size limits
+	TypeEvict = "evict"
+	// TypePurge is the removal of a cache object on behalf of a request or an invalidation
+	TypePurge = "purge"
+	// TypeNegativeStore is the write of a Negative Cache entry
+	TypeNegativeStore = "negative-store"
+)
+
+// bufferSize is the number of events that may await the stream before further events are dropped
+const bufferSize = 4096
+
+// ErrNotEnabled is returned when the cache event stream is not enabled
+var ErrNotEnabled = errors.New("cache events are not enabled")
+
+// Event is the record of a mutation of a cache object
+type Event struct {
+	// Seq is the event's sequence number in the stream, which is its cursor
+	Seq uint64 `json:"seq"`
+	// Time is the time of the mutation
+	Time time.Time `json:"time"`
+	// Type is the type of the mutation
+	Type string `json:"type"`
+	// Cache is the name of the mutated cache
+	Cache string `json:"cache,omitempty"`
+	// KeyHash is the checksum of the mutated object's cache key
+	KeyHash string `json:"key_hash"`
+	// Origin is the name of the origin whose object was mutated, when known
+	Origin string `json:"origin,omitempty"`
+	// Bytes is the size of the mutated object, when known
+	Bytes int64 `json:"bytes,omitempty"`
+	// ExtentStart is the epoch start of the time range held by a timeseries object
+	ExtentStart int64 `json:"extent_start,omitempty"`
+	// ExtentEnd is the epoch end of the time range held by a timeseries object
+	ExtentEnd int64 `json:"extent_end,omitempty"`
+	// Reason is the reason for the mutation, for evictions and purges
+	Reason string `json:"reason,omitempty"`
+
+	// key is the object's cache key, which is checksummed by the stream rather than the emitter
+	key string
+}
+
+// New returns a new Event of the type for the mutation of the key in the named cache
+func New(eventType, cacheName, key string) *Event {
+	return &Event{Time: time.Now(), Type: eventType, Cache: cacheName, key: key}
+}
+
+// Options configures a Stream
+type Options struct {
+	// Size is the number of events retained in the ring buffer
+	Size int
+	// Sink is the URL of a datagram sink to which each event is written, of the form
+	// udp://host:port or unixgram:///path/to/socket
+	Sink string
+	// LogFile is the path of a file to which each event is written as a line of JSON
+	LogFile string
+	// LogMaxSizeMB is the size in megabytes at which the LogFile is rotated
+	LogMaxSizeMB int
+	// LogMaxBackups is the number of rotated LogFiles to retain
+	LogMaxBackups int
+	// LogMaxAgeDays is the number of days to retain rotated LogFiles
+	LogMaxAgeDays int
+	// LogCompress indicates whether rotated LogFiles are compressed
+	LogCompress bool
+}
+
+// Stream retains the most recent events in a ring buffer, and writes them to its sinks. Events are
+// emitted without blocking, and are dropped when the Stream cannot keep up with them
+type Stream struct {
+	opts    Options
+	ch      chan *Event
+	done    chan struct{}
+	wg      sync.WaitGroup
+	sinks   []io.WriteCloser
+	dropped uint64
+	metrics *metrics.Metrics
+
+	mtx    sync.RWMutex
+	events []*Event
+	next   int
+	count  int
+	seq    uint64
+}
+
+// NewStream returns a new, running Stream with the provided options
+func NewStream(o *Options) (*Stream, error) {
+	size := o.Size
+	if size < 1 {
+		size = 1
+	}
+	s := &Stream{
+		opts:   *o,
+		ch:     make(chan *Event, bufferSize),
+		done:   make(chan struct{}),
+		events: make([]*Event, size),
+	}
+	if o.Sink != "" {
+		w, err := dialSink(o.Sink)
+		if err != nil {
+			return nil, err
+		}
+		s.sinks = append(s.sinks, w)
+	}
+	if o.LogFile != "" {
+		s.sinks = append(s.sinks, &lumberjack.Logger{
+			Filename:   o.LogFile,
+			MaxSize:    o.LogMaxSizeMB,
+			MaxBackups: o.LogMaxBackups,
+			MaxAge:     o.LogMaxAgeDays,
+			Compress:   o.LogCompress,
+		})
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// Emit passes the event to the Stream, or drops it if the Stream's buffer is full
+func (s *Stream) Emit(e *Event) {
+	select {
+	case s.ch <- e:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+		metrics.OrDefault(s.metrics).CacheEventsDropped.Inc()
+	}
+}
+
+// Dropped returns the number of events dropped by the Stream
+func (s *Stream) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close stops the Stream once its buffered events are recorded, and closes its sinks
+func (s *Stream) Close() {
+	close(s.done)
+	s.wg.Wait()
+	for _, w := range s.sinks {
+		w.Close()
+	}
+}
+
+func (s *Stream) run() {
+	defer s.wg.Done()
+	for {
+		select {
+		case e := <-s.ch:
+			s.record(e)
+		case <-s.done:
+			for {
+				select {
+				case e := <-s.ch:
+					s.record(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// record sequences the event, adds it to the ring buffer and writes it to the sinks
+func (s *Stream) record(e *Event) {
+	e.KeyHash = md5.Checksum(e.key)
+	s.mtx.Lock()
+	s.seq++
+	e.Seq = s.seq
+	s.events[s.next] = e
+	s.next = (s.next + 1) % len(s.events)
+	if s.count < len(s.events) {
+		s.count++
+	}
+	s.mtx.Unlock()
+	if len(s.sinks) == 0 {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+	for _, w := range s.sinks {
+		// sinks are best effort, so that an unavailable observer cannot stall the stream
+		w.Write(b)
+	}
+}
+
+// Events returns up to limit of the retained events whose sequence numbers follow the after
+// cursor, from the oldest to the newest, and the cursor that follows the returned events. When
+// the after cursor is ahead of the Stream, such as after the Stream is reconfigured, the events
+// are returned from the oldest retained event
+func (s *Stream) Events(after uint64, limit int) ([]*Event, uint64) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if s.count == 0 {
+		return []*Event{}, s.seq
+	}
+	oldest := s.seq - uint64(s.count) + 1
+	start := after + 1
+	if start < oldest || after > s.seq {
+		start = oldest
+	}
+	if start > s.seq {
+		return []*Event{}, s.seq
+	}
+	n := int(s.seq - start + 1)
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	out := make([]*Event, n)
+	first := (s.next - s.count + len(s.events) + int(start-oldest)) % len(s.events)
+	for i := 0; i < n; i++ {
+		out[i] = s.events[(first+i)%len(s.events)]
+	}
+	return out, out[n-1].Seq
+}
+
+// Publisher is a cache event stream that is enabled, disabled and reconfigured with the config
+// of the router whose caches emit the events. A nil Publisher is disabled
+type Publisher struct {
+	metrics *metrics.Metrics
+	stream  *Stream
+	mtx     sync.RWMutex
+}
+
+// NewPublisher returns a new, disabled Publisher that reports its dropped events to the Metrics
+func NewPublisher(m *metrics.Metrics) *Publisher {
+	return &Publisher{metrics: m}
+}
+
+// Default is the Publisher of the routers of the Trickster binary
+var Default = NewPublisher(metrics.Default)
+
+// OrDefault returns the Publisher, or the Default Publisher when it is nil
+func OrDefault(p *Publisher) *Publisher {
+	if p == nil {
+		return Default
+	}
+	return p
+}
+
+// Configure enables or disables the Publisher's event stream. Events that were already
+// retained are kept when the options are unchanged. When the options are invalid, the running
+// stream is unchanged and the error is returned
+func (p *Publisher) Configure(enabled bool, o *Options) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if !enabled || o == nil {
+		if p.stream != nil {
+			p.stream.Close()
+			p.stream = nil
+		}
+		return nil
+	}
+	if p.stream != nil && p.stream.opts == *o {
+		return nil
+	}
+	s, err := NewStream(o)
+	if err != nil {
+		return err
+	}
+	s.metrics = p.metrics
+	if p.stream != nil {
+		p.stream.Close()
+	}
+	p.stream = s
+	return nil
+}
+
+// Close disables the Publisher's event stream
+func (p *Publisher) Close() {
+	p.Configure(false, nil)
+}
+
+func (p *Publisher) current() *Stream {
+	if p == nil {
+		return nil
+	}
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+	return p.stream
+}
+
+// Enabled returns true if the Publisher's event stream is enabled
+func (p *Publisher) Enabled() bool {
+	return p.current() != nil
+}
+
+// Emit passes the event to the Publisher's event stream, if it is enabled
+func (p *Publisher) Emit(e *Event) {
+	if s := p.current(); s != nil {
+		s.Emit(e)
+	}
+}
+
+// Events returns up to limit of the Publisher's retained events that follow the after cursor, the
+// cursor that follows them, and the number of events that were dropped
+func (p *Publisher) Events(after uint64, limit int) ([]*Event, uint64, uint64, error) {
+	s := p.current()
+	if s == nil {
+		return nil, 0, 0, ErrNotEnabled
+	}
+	events, next := s.Events(after, limit)
+	return events, next, s.Dropped(), nil
+}
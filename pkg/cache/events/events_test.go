@@ -0,0 +1,240 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+// testRecord emits count events to the stream and waits for them to be recorded
+func testRecord(t *testing.T, s *Stream, count int) {
+	_, start := s.Events(0, 0)
+	for i := 0; i < count; i++ {
+		s.Emit(New(TypeStore, "default", "key"))
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, next := s.Events(0, 0); next == start+uint64(count) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for events to be recorded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+
+	s, err := NewStream(&Options{Size: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	events, next := s.Events(0, 0)
+	if len(events) != 0 || next != 0 {
+		t.Errorf("expected no events got %d next %d", len(events), next)
+	}
+
+	testRecord(t, s, 3)
+	events, next = s.Events(0, 0)
+	if len(events) != 3 || next != 3 {
+		t.Fatalf("expected %d events got %d next %d", 3, len(events), next)
+	}
+	if events[0].Seq != 1 || events[0].KeyHash != md5.Checksum("key") ||
+		events[0].Type != TypeStore || events[0].Cache != "default" {
+		t.Errorf("unexpected event %+v", events[0])
+	}
+
+	// the oldest events are replaced once the ring is full
+	testRecord(t, s, 4)
+	tests := []struct {
+		after, limit int
+		seqs         []uint64
+		next         uint64
+	}{
+		{0, 0, []uint64{3, 4, 5, 6, 7}, 7},
+		{0, 2, []uint64{3, 4}, 4},
+		{4, 2, []uint64{5, 6}, 6},
+		{6, 0, []uint64{7}, 7},
+		{7, 0, []uint64{}, 7},
+		// a cursor that is ahead of the stream starts from the oldest event
+		{100, 1, []uint64{3}, 3},
+	}
+	for i, test := range tests {
+		events, next := s.Events(uint64(test.after), test.limit)
+		if next != test.next {
+			t.Errorf("(%d) expected next %d got %d", i, test.next, next)
+		}
+		if len(events) != len(test.seqs) {
+			t.Errorf("(%d) expected %d events got %d", i, len(test.seqs), len(events))
+			continue
+		}
+		for j, e := range events {
+			if e.Seq != test.seqs[j] {
+				t.Errorf("(%d) expected seq %d got %d", i, test.seqs[j], e.Seq)
+			}
+		}
+	}
+}
+
+func TestStreamDropped(t *testing.T) {
+	// the stream is not running, so that its buffer fills
+	s := &Stream{ch: make(chan *Event, 1)}
+	s.Emit(New(TypeEvict, "default", "key1"))
+	s.Emit(New(TypeEvict, "default", "key2"))
+	if s.Dropped() != 1 {
+		t.Errorf("expected %d got %d", 1, s.Dropped())
+	}
+}
+
+func TestStreamSinks(t *testing.T) {
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	td, err := ioutil.TempDir("", "trickster-cache-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	logFile := filepath.Join(td, "events.log")
+
+	s, err := NewStream(&Options{Size: 10, Sink: "udp://" + pc.LocalAddr().String(),
+		LogFile: logFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(TypeMerge, "default", "key")
+	e.Origin = "prom1"
+	e.ExtentStart = 1600000000
+	e.ExtentEnd = 1600003600
+	s.Emit(e)
+	s.Close()
+
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &Event{}
+	if err := json.Unmarshal(b[:n], got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Seq != 1 || got.Type != TypeMerge || got.Origin != "prom1" ||
+		got.ExtentEnd != 1600003600 {
+		t.Errorf("unexpected event %+v", got)
+	}
+
+	f, err := os.Open(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	if !sc.Scan() {
+		t.Fatal("expected an event in the log file")
+	}
+	got = &Event{}
+	if err := json.Unmarshal(sc.Bytes(), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.KeyHash != md5.Checksum("key") {
+		t.Errorf("unexpected event %+v", got)
+	}
+}
+
+func TestDialSink(t *testing.T) {
+	for _, sink := range []string{"tcp://127.0.0.1:8125", "udp://", "unixgram://", "%"} {
+		if _, err := dialSink(sink); err == nil {
+			t.Errorf("expected error for sink %s", sink)
+		}
+	}
+}
+
+func TestPublisherConfigure(t *testing.T) {
+
+	p := NewPublisher(nil)
+	defer p.Close()
+
+	if p.Enabled() {
+		t.Error("expected stream to be disabled")
+	}
+	if _, _, _, err := p.Events(0, 0); err != ErrNotEnabled {
+		t.Errorf("expected %v got %v", ErrNotEnabled, err)
+	}
+	// events are discarded when the stream is disabled
+	p.Emit(New(TypeStore, "default", "key"))
+
+	if err := p.Configure(true, &Options{Size: 10}); err != nil {
+		t.Fatal(err)
+	}
+	s := p.current()
+	testRecord(t, s, 2)
+
+	// the stream is retained when its options are unchanged
+	p.Configure(true, &Options{Size: 10})
+	if p.current() != s {
+		t.Error("expected the stream to be retained")
+	}
+
+	// the stream is unchanged when the new options are invalid
+	if err := p.Configure(true, &Options{Size: 20, Sink: "tcp://127.0.0.1:8125"}); err == nil {
+		t.Error("expected error for invalid sink")
+	}
+	if p.current() != s {
+		t.Error("expected the stream to be retained")
+	}
+
+	events, next, dropped, err := p.Events(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || next != 2 || dropped != 0 {
+		t.Errorf("unexpected events %d next %d dropped %d", len(events), next, dropped)
+	}
+
+	p.Configure(true, &Options{Size: 20})
+	if p.current() == s {
+		t.Error("expected a new stream")
+	}
+}
+
+func TestNilPublisher(t *testing.T) {
+	var p *Publisher
+	if p.Enabled() {
+		t.Error("expected a nil publisher to be disabled")
+	}
+	p.Emit(New(TypeStore, "default", "key"))
+	if _, _, _, err := p.Events(0, 0); err != ErrNotEnabled {
+		t.Errorf("expected %v got %v", ErrNotEnabled, err)
+	}
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+)
+
+// dialSink returns a writer for the datagram sink URL, which is of the form udp://host:port or
+// unixgram:///path/to/socket, to which each event is written as a datagram
+func dialSink(sink string) (io.WriteCloser, error) {
+	u, err := url.Parse(sink)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache event sink %s: %v", sink, err)
+	}
+	switch u.Scheme {
+	case "udp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid cache event sink %s: no host", sink)
+		}
+		return net.Dial("udp", u.Host)
+	case "unixgram":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid cache event sink %s: no socket path", sink)
+		}
+		return net.Dial("unixgram", u.Path)
+	}
+	return nil, fmt.Errorf("invalid cache event sink %s: unsupported scheme %s", sink, u.Scheme)
+}
@@ -45,6 +45,19 @@ type Cache struct {
 	lockPrefix string
 }
 
+func init() {
+	cache.MustRegisterProvider("filesystem", newProviderCache)
+}
+
+func newProviderCache(name string, cfg *options.Options, logger *log.Logger) cache.Cache {
+	return &Cache{Name: name, Config: cfg, Logger: logger}
+}
+
+// ReportsMetrics returns true, since the Cache records its own operation metrics
+func (c *Cache) ReportsMetrics() bool {
+	return true
+}
+
 // Locker returns the cache's locker
 func (c *Cache) Locker() locks.NamedLocker {
 	return c.locker
@@ -71,8 +84,14 @@ func (c *Cache) Connect() error {
 
 	// Load Index here and pass bytes as param2
 	indexData, _, _ := c.retrieve(index.IndexKey, false, false)
-	c.Index = index.NewIndex(c.Name, c.Config.CacheType, indexData,
-		c.Config.Index, c.BulkRemove, c.storeNoIndex, c.Logger)
+	deletions, _, _ := c.retrieve(index.DeletionsKey, false, false)
+	c.Index = index.NewIndex(c.Name, c.Config, indexData, c.BulkRemove, c.storeNoIndex, c.Logger)
+
+	// recover deletions that were pending, and files that were orphaned, when the cache was last closed
+	if n := c.Index.Reconcile(deletions, c.storedKeys()); n > 0 {
+		c.Logger.Info("filesystem cache deletions recovered", log.Pairs{"name": c.Name, "pending": n})
+	}
+	c.Index.StartDeleter(c.deletePending, c.Logger)
 	return nil
 }
 
@@ -99,7 +118,7 @@ func (c *Cache) store(cacheKey string, data []byte, ttl time.Duration, updateInd
 		return fmt.Errorf("cacheKey required")
 	}
 
-	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+	metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
 
 	dataFile := c.getFileName(cacheKey)
 
@@ -130,19 +149,25 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) ([]byte
 	dataFile := c.getFileName(cacheKey)
 
 	nl, _ := c.locker.RAcquire(c.lockPrefix + cacheKey)
+	if c.Index != nil && c.Index.IsDeleted(cacheKey) {
+		nl.RRelease()
+		c.Logger.Debug("filesystem cache miss", log.Pairs{"key": cacheKey, "dataFile": dataFile})
+		metrics.ObserveCacheMiss(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
 	data, err := ioutil.ReadFile(dataFile)
 	nl.RRelease()
 
 	if err != nil {
 		c.Logger.Debug("filesystem cache miss", log.Pairs{"key": cacheKey, "dataFile": dataFile})
-		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		metrics.ObserveCacheMiss(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType)
 		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
 	}
 
 	o, err := index.ObjectFromBytes(data)
 	if err != nil {
 
-		_, err2 := metrics.CacheError(cacheKey, c.Name, c.Config.CacheType,
+		_, err2 := metrics.CacheError(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType,
 			"value for key [%s] could not be deserialized from cache")
 		return nil, status.LookupStatusError, err2
 	}
@@ -159,12 +184,12 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) ([]byte
 		if atime {
 			go c.Index.UpdateObjectAccessTime(cacheKey)
 		}
-		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+		metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
 		return o.Value, status.LookupStatusHit, nil
 	}
 	// Cache Object has been expired but not reaped, go ahead and delete it
 	go c.remove(cacheKey, false)
-	metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+	metrics.ObserveCacheMiss(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType)
 	return nil, status.LookupStatusKeyMiss, cache.ErrKNF
 }
 
@@ -185,11 +210,18 @@ func (c *Cache) remove(cacheKey string, isBulk bool) {
 	if err == nil && !isBulk {
 		go c.Index.RemoveObject(cacheKey)
 	}
-	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+	metrics.ObserveCacheDel(c.Config.Metrics, c.Name, c.Config.CacheType, 0)
 }
 
-// BulkRemove removes a list of objects from the cache
+// BulkRemove removes a list of objects from the cache. Unless the index is configured with
+// sync_bulk_remove, the objects are marked deleted in the index, so that they are no longer
+// retrievable, and their files are deleted in the background by the index's deleter
 func (c *Cache) BulkRemove(cacheKeys []string) {
+	if !c.Config.Index.SyncBulkRemove {
+		c.Index.MarkDeleted(cacheKeys)
+		return
+	}
+
 	wg := &sync.WaitGroup{}
 
 	for _, cacheKey := range cacheKeys {
@@ -202,6 +234,34 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 	wg.Wait()
 }
 
+// deletePending deletes the files of a batch of objects that are marked deleted in the index,
+// skipping any that have been written again since
+func (c *Cache) deletePending(cacheKeys []string) {
+	for _, cacheKey := range cacheKeys {
+		nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
+		if c.Index.ClearDeleted(cacheKey) {
+			os.Remove(c.getFileName(cacheKey))
+			metrics.ObserveCacheDel(c.Config.Metrics, c.Name, c.Config.CacheType, 0)
+		}
+		nl.Release()
+	}
+}
+
+// storedKeys returns the keys of the objects with files in the cache path
+func (c *Cache) storedKeys() []string {
+	files, err := ioutil.ReadDir(c.Config.Filesystem.CachePath)
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(files))
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".data") {
+			keys = append(keys, strings.TrimSuffix(f.Name(), ".data"))
+		}
+	}
+	return keys
+}
+
 // Close is not used for Cache
 func (c *Cache) Close() error {
 	if c.Index != nil {
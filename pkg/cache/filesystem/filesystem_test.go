@@ -26,6 +26,7 @@ import (
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	flo "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/index"
 	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
 	co "github.com/tricksterproxy/trickster/pkg/cache/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
@@ -590,6 +591,108 @@ func TestFilesystemCache_BulkRemove(t *testing.T) {
 	}
 }
 
+func TestFilesystemCache_BulkRemoveDeferred(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fc.Close()
+
+	for _, key := range []string{"key1", "key2"} {
+		err = fc.Store(key, []byte("data"), time.Duration(60)*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	fc.BulkRemove([]string{"key1", "key2"})
+
+	// key2 is written again before the deleter runs, and so it should not be deleted
+	err = fc.Store("key2", []byte("data2"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for i := 0; i < 100 && fc.Index.PendingDeletions() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(fc.getFileName("key1")); !os.IsNotExist(err) {
+		t.Errorf("expected file for key1 to be deleted")
+	}
+	data, ls, err := fc.Retrieve("key2", false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data2" || ls != status.LookupStatusHit {
+		t.Errorf("unexpected retrieval %s %s", data, ls)
+	}
+}
+
+func TestFilesystemCache_BulkRemoveSync(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	cacheConfig.Index.SyncBulkRemove = true
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fc.Close()
+
+	err = fc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the file should be deleted before BulkRemove returns
+	fc.BulkRemove([]string{cacheKey})
+	if _, err := os.Stat(fc.getFileName(cacheKey)); !os.IsNotExist(err) {
+		t.Errorf("expected file to be deleted")
+	}
+	if n := fc.Index.PendingDeletions(); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+}
+
+func TestFilesystemCache_Reconcile(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	// an orphaned file, left by a deletion that was pending when the cache was closed,
+	// should be deleted when the cache is connected
+	orphan := &index.Object{Key: "orphan", Value: []byte("data"), Expiration: time.Now().Add(time.Minute)}
+	err := ioutil.WriteFile(fc.getFileName("orphan"), orphan.ToBytes(), os.FileMode(0777))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fc.Close()
+
+	_, ls, _ := fc.Retrieve("orphan", false)
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+	for i := 0; i < 100 && fc.Index.PendingDeletions() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(fc.getFileName("orphan")); !os.IsNotExist(err) {
+		t.Errorf("expected orphaned file to be deleted")
+	}
+}
+
 func BenchmarkCache_BulkRemove(b *testing.B) {
 	fc := storeBenchmark(b)
 	defer fc.Close()
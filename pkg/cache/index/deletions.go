@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// DeletionsKey is the key under which the index writes the journal of its pending deletions
+// to its associated cache
+const DeletionsKey = "cache.index.deletions"
+
+// MarkDeleted removes the Objects with the provided keys from the Index and queues them for
+// physical deletion by the deleter. Once it returns, IsDeleted is true for each of the keys
+// until they are deleted or written again, so that the cache can treat them as misses.
+func (idx *Index) MarkDeleted(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	idx.journalMtx.Lock()
+	idx.mtx.Lock()
+	idx.RemoveObjects(keys, true)
+	for _, key := range keys {
+		if _, ok := idx.pending[key]; ok || key == IndexKey || key == DeletionsKey {
+			continue
+		}
+		idx.pending[key] = struct{}{}
+		idx.pendingQueue = append(idx.pendingQueue, key)
+	}
+	n := len(idx.pending)
+	idx.mtx.Unlock()
+	idx.writeJournal()
+	idx.journalMtx.Unlock()
+
+	metrics.ObserveCachePendingDeletions(idx.metrics, idx.name, idx.cacheType, n)
+	select {
+	case idx.pendingSignal <- struct{}{}:
+	default:
+	}
+}
+
+// IsDeleted returns true if the object of the provided key is awaiting physical deletion
+func (idx *Index) IsDeleted(key string) bool {
+	idx.mtx.Lock()
+	_, ok := idx.pending[key]
+	idx.mtx.Unlock()
+	return ok
+}
+
+// ClearDeleted removes the provided key from the pending deletions, and returns true if it was
+// pending. The cache's delete func calls ClearDeleted while holding the lock for the key, and
+// physically deletes the object only when it returns true, since an object that is written
+// again after being marked deleted is no longer pending.
+func (idx *Index) ClearDeleted(key string) bool {
+	idx.mtx.Lock()
+	_, ok := idx.pending[key]
+	delete(idx.pending, key)
+	idx.mtx.Unlock()
+	if ok {
+		metrics.ObserveCacheDeferredDeletions(idx.metrics, idx.name, idx.cacheType, 1)
+	}
+	return ok
+}
+
+// PendingDeletions returns the number of objects awaiting physical deletion
+func (idx *Index) PendingDeletions() int {
+	idx.mtx.Lock()
+	n := len(idx.pending)
+	idx.mtx.Unlock()
+	return n
+}
+
+// Reconcile recovers the deletions that were pending when the cache was last closed, and is
+// called once when the cache is connected, before the deleter is started. It marks deleted
+// the keys in the provided deletions journal, along with any of the provided stored keys that
+// are not in the Index, and returns the number of objects awaiting deletion.
+func (idx *Index) Reconcile(journal []byte, storedKeys []string) int {
+	keys := readJournal(journal)
+	idx.mtx.Lock()
+	for _, key := range storedKeys {
+		if _, ok := idx.Objects[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	idx.mtx.Unlock()
+	idx.MarkDeleted(keys)
+	return idx.PendingDeletions()
+}
+
+// StartDeleter starts the deleter, which physically deletes the objects of pending deletions
+// by calling deleteFunc with each batch of their keys
+func (idx *Index) StartDeleter(deleteFunc func(cacheKeys []string), log *tl.Logger) {
+	idx.deleteFunc = deleteFunc
+	go idx.deleter(log)
+}
+
+// deleter continually deletes batches of pending deletions, pausing between each batch
+func (idx *Index) deleter(log *tl.Logger) {
+	for !idx.isClosing {
+		n := idx.deleteBatch()
+		if n == 0 {
+			select {
+			case <-idx.pendingSignal:
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		log.Debug("cache deleter batch completed", tl.Pairs{"cacheName": idx.name,
+			"deleted": n, "pending": idx.PendingDeletions()})
+		if idx.options.BulkRemoveInterval > 0 {
+			time.Sleep(idx.options.BulkRemoveInterval)
+		}
+	}
+	idx.deleterExited = true
+}
+
+// deleteBatch deletes the next batch of pending deletions, and returns the number of keys
+// in the batch. When no deletions remain pending, the emptied journal is written.
+func (idx *Index) deleteBatch() int {
+	idx.mtx.Lock()
+	n := idx.options.BulkRemoveBatchSize
+	if n < 1 || n > len(idx.pendingQueue) {
+		n = len(idx.pendingQueue)
+	}
+	batch := idx.pendingQueue[:n:n]
+	idx.pendingQueue = idx.pendingQueue[n:]
+	idx.mtx.Unlock()
+	if n == 0 {
+		return 0
+	}
+
+	idx.deleteFunc(batch)
+
+	idx.journalMtx.Lock()
+	idx.mtx.Lock()
+	remaining := len(idx.pending)
+	drained := len(idx.pendingQueue) == 0
+	idx.mtx.Unlock()
+	if drained {
+		idx.writeJournal()
+	}
+	idx.journalMtx.Unlock()
+
+	metrics.ObserveCachePendingDeletions(idx.metrics, idx.name, idx.cacheType, remaining)
+	return n
+}
+
+// writeJournal writes the keys of the pending deletions to the cache, so they can be recovered
+// by Reconcile if the cache is closed before they are deleted. The caller must hold journalMtx.
+func (idx *Index) writeJournal() {
+	if idx.flushFunc == nil {
+		return
+	}
+	idx.mtx.Lock()
+	b := msgp.AppendArrayHeader(make([]byte, 0, 16*len(idx.pending)), uint32(len(idx.pending)))
+	for key := range idx.pending {
+		b = msgp.AppendString(b, key)
+	}
+	idx.mtx.Unlock()
+	idx.flushFunc(DeletionsKey, b)
+}
+
+// readJournal returns the keys in a deletions journal written by writeJournal
+func readJournal(b []byte) []string {
+	n, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, n)
+	for i := uint32(0); i < n; i++ {
+		var key string
+		key, b, err = msgp.ReadStringBytes(b)
+		if err != nil {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
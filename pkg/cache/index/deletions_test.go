@@ -0,0 +1,184 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+)
+
+type testJournal struct {
+	data []byte
+	mtx  sync.Mutex
+}
+
+func (j *testJournal) flush(cacheKey string, data []byte) {
+	if cacheKey != DeletionsKey {
+		return
+	}
+	j.mtx.Lock()
+	j.data = data
+	j.mtx.Unlock()
+}
+
+func (j *testJournal) keys() []string {
+	j.mtx.Lock()
+	keys := readJournal(j.data)
+	j.mtx.Unlock()
+	sort.Strings(keys)
+	return keys
+}
+
+func TestMarkDeleted(t *testing.T) {
+
+	j := &testJournal{}
+	idx := NewIndex("test", &co.Options{CacheType: "test", Index: &io.Options{}}, nil,
+		testBulkRemoveFunc, j.flush, testLogger)
+
+	idx.UpdateObject(&Object{Key: "test.1", Value: []byte("test_value")})
+	idx.UpdateObject(&Object{Key: "test.2", Value: []byte("test_value")})
+	idx.UpdateObject(&Object{Key: "test.3", Value: []byte("test_value")})
+
+	idx.MarkDeleted(nil)
+	if n := idx.PendingDeletions(); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+
+	idx.MarkDeleted([]string{"test.1", "test.2", "test.2", IndexKey})
+	if n := idx.PendingDeletions(); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	if idx.ObjectCount != 1 {
+		t.Errorf("expected %d got %d", 1, idx.ObjectCount)
+	}
+	if !idx.IsDeleted("test.1") || !idx.IsDeleted("test.2") || idx.IsDeleted("test.3") {
+		t.Error("unexpected pending deletions")
+	}
+	if keys := j.keys(); !reflect.DeepEqual(keys, []string{"test.1", "test.2"}) {
+		t.Errorf("unexpected journal %v", keys)
+	}
+
+	// an object that is written again is no longer deleted
+	idx.UpdateObject(&Object{Key: "test.1", Value: []byte("test_value")})
+	if idx.IsDeleted("test.1") {
+		t.Error("expected test.1 to no longer be deleted")
+	}
+	if idx.ClearDeleted("test.1") {
+		t.Error("expected false")
+	}
+	if !idx.ClearDeleted("test.2") {
+		t.Error("expected true")
+	}
+	if n := idx.PendingDeletions(); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+}
+
+func TestDeleter(t *testing.T) {
+
+	j := &testJournal{}
+	idx := NewIndex("test", &co.Options{CacheType: "test", Index: &io.Options{BulkRemoveBatchSize: 2,
+		BulkRemoveInterval: time.Millisecond}}, nil, testBulkRemoveFunc, j.flush, testLogger)
+
+	var mtx sync.Mutex
+	var batches [][]string
+	var deleted []string
+	idx.StartDeleter(func(cacheKeys []string) {
+		mtx.Lock()
+		batches = append(batches, cacheKeys)
+		for _, key := range cacheKeys {
+			if idx.ClearDeleted(key) {
+				deleted = append(deleted, key)
+			}
+		}
+		mtx.Unlock()
+	}, testLogger)
+
+	idx.MarkDeleted([]string{"test.1", "test.2", "test.3", "test.4", "test.5"})
+
+	for i := 0; i < 100 && idx.PendingDeletions() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := idx.PendingDeletions(); n != 0 {
+		t.Fatalf("expected %d got %d", 0, n)
+	}
+
+	mtx.Lock()
+	if len(batches) != 3 {
+		t.Errorf("expected %d got %d", 3, len(batches))
+	}
+	if len(deleted) != 5 {
+		t.Errorf("expected %d got %d", 5, len(deleted))
+	}
+	mtx.Unlock()
+
+	for i := 0; i < 100 && len(j.keys()) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if keys := j.keys(); len(keys) != 0 {
+		t.Errorf("expected empty journal got %v", keys)
+	}
+
+	idx.Close()
+	time.Sleep(1500 * time.Millisecond)
+	if !idx.deleterExited {
+		t.Error("expected true")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+
+	j := &testJournal{}
+	idx := NewIndex("test", &co.Options{CacheType: "test", Index: &io.Options{}}, nil,
+		testBulkRemoveFunc, j.flush, testLogger)
+	idx.UpdateObject(&Object{Key: "test.1", Value: []byte("test_value")})
+	idx.UpdateObject(&Object{Key: "test.2", Value: []byte("test_value")})
+	idx.MarkDeleted([]string{"test.1"})
+
+	// a new index loaded from the flushed index and journal recovers the pending deletion,
+	// and the stored object that is not in the index
+	idx2 := NewIndex("test", &co.Options{CacheType: "test", Index: &io.Options{}}, idx.ToBytes(), testBulkRemoveFunc,
+		nil, testLogger)
+	n := idx2.Reconcile(j.data, []string{"test.1", "test.2", "test.3", IndexKey, DeletionsKey})
+	if n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	if !idx2.IsDeleted("test.1") || idx2.IsDeleted("test.2") || !idx2.IsDeleted("test.3") {
+		t.Error("unexpected pending deletions")
+	}
+
+	// an index flushed before the deletion was marked still recovers it from the journal
+	idx3 := NewIndex("test", &co.Options{CacheType: "test", Index: &io.Options{}}, nil,
+		testBulkRemoveFunc, nil, testLogger)
+	idx3.UpdateObject(&Object{Key: "test.1", Value: []byte("test_value")})
+	if n := idx3.Reconcile(j.data, nil); n != 1 {
+		t.Errorf("expected %d got %d", 1, n)
+	}
+	if _, ok := idx3.Objects["test.1"]; ok {
+		t.Error("expected test.1 to be removed from the index")
+	}
+
+	if keys := readJournal([]byte("invalid")); len(keys) != 0 {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
@@ -24,8 +24,10 @@ import (
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
 	"github.com/tricksterproxy/trickster/pkg/cache/index/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 	gm "github.com/tricksterproxy/trickster/pkg/util/metrics"
 )
@@ -48,13 +50,28 @@ type Index struct {
 	name           string                             `msg:"-"`
 	cacheType      string                             `msg:"-"`
 	options        *options.Options                   `msg:"-"`
+	metrics        *gm.Metrics                        `msg:"-"`
+	events         *events.Publisher                  `msg:"-"`
 	bulkRemoveFunc func([]string)                     `msg:"-"`
 	flushFunc      func(cacheKey string, data []byte) `msg:"-"`
 	lastWrite      time.Time                          `msg:"-"`
+	deleteFunc     func(cacheKeys []string)           `msg:"-"`
+
+	// pending is the set of keys removed from the Index whose objects await physical deletion,
+	// in the order they are processed by the deleter
+	pending       map[string]struct{} `msg:"-"`
+	pendingQueue  []string            `msg:"-"`
+	pendingSignal chan struct{}       `msg:"-"`
+	journalMtx    sync.Mutex          `msg:"-"`
+
+	// unusedEvictions are the keys of objects evicted as unused, and when they would otherwise
+	// have expired, so that objects written again before then can be counted
+	unusedEvictions map[string]time.Time `msg:"-"`
 
 	isClosing     bool
 	flusherExited bool
 	reaperExited  bool
+	deleterExited bool
 
 	mtx sync.Mutex
 }
@@ -81,6 +98,9 @@ type Object struct {
 	LastWrite time.Time `msg:"lastwrite"`
 	// LastAccess is the time the object was last Accessed
 	LastAccess time.Time `msg:"lastaccess"`
+	// FirstAccess is the time the object was first Accessed after it was written, or zero when
+	// it has not been Accessed
+	FirstAccess time.Time `msg:"firstaccess"`
 	// Size the size of the Object in bytes
 	Size int64 `msg:"size"`
 	// Value is the value of the Object stored in the Cache
@@ -91,6 +111,12 @@ type Object struct {
 	ReferenceValue cache.ReferenceObject `msg:"-"`
 }
 
+// unused returns true if the object has not been Accessed since it was written. Objects from an
+// index that predates FirstAccess were Accessed if their LastAccess is after their LastWrite
+func (o *Object) unused() bool {
+	return o.FirstAccess.IsZero() && !o.LastAccess.After(o.LastWrite)
+}
+
 // ToBytes returns a serialized byte slice representing the Object
 func (o *Object) ToBytes() []byte {
 	bytes, _ := o.MarshalMsg(nil)
@@ -104,11 +130,13 @@ func ObjectFromBytes(data []byte) (*Object, error) {
 	return o, err
 }
 
-// NewIndex returns a new Index based on the provided inputs
-func NewIndex(cacheName, cacheType string, indexData []byte, o *options.Options,
+// NewIndex returns a new Index for the named cache, using the cache's Index Options, and
+// reporting to its Metrics and cache event Publisher
+func NewIndex(cacheName string, cc *co.Options, indexData []byte,
 	bulkRemoveFunc func([]string), flushFunc func(cacheKey string, data []byte),
 	log *tl.Logger) *Index {
 	i := &Index{}
+	o := cc.Index
 
 	if len(indexData) > 0 {
 		i.UnmarshalMsg(indexData)
@@ -117,10 +145,15 @@ func NewIndex(cacheName, cacheType string, indexData []byte, o *options.Options,
 	}
 
 	i.name = cacheName
-	i.cacheType = cacheType
+	i.cacheType = cc.CacheType
+	i.metrics = gm.OrDefault(cc.Metrics)
+	i.events = events.OrDefault(cc.Events)
 	i.flushFunc = flushFunc
 	i.bulkRemoveFunc = bulkRemoveFunc
 	i.options = o
+	i.pending = make(map[string]struct{})
+	i.pendingSignal = make(chan struct{}, 1)
+	i.unusedEvictions = make(map[string]time.Time)
 
 	if flushFunc != nil {
 		if o.FlushInterval > 0 {
@@ -138,8 +171,8 @@ func NewIndex(cacheName, cacheType string, indexData []byte, o *options.Options,
 			tl.Pairs{"cacheName": i.name, "reapInterval": o.ReapInterval})
 	}
 
-	gm.CacheMaxObjects.WithLabelValues(cacheName, cacheType).Set(float64(o.MaxSizeObjects))
-	gm.CacheMaxBytes.WithLabelValues(cacheName, cacheType).Set(float64(o.MaxSizeBytes))
+	i.metrics.CacheMaxObjects.WithLabelValues(cacheName, i.cacheType).Set(float64(o.MaxSizeObjects))
+	i.metrics.CacheMaxBytes.WithLabelValues(cacheName, i.cacheType).Set(float64(o.MaxSizeBytes))
 
 	return i
 }
@@ -154,8 +187,11 @@ func (idx *Index) UpdateOptions(o *options.Options) {
 // UpdateObjectAccessTime updates the LastAccess for the object with the provided key
 func (idx *Index) UpdateObjectAccessTime(key string) {
 	idx.mtx.Lock()
-	if _, ok := idx.Objects[key]; ok {
-		idx.Objects[key].LastAccess = time.Now()
+	if o, ok := idx.Objects[key]; ok {
+		o.LastAccess = time.Now()
+		if o.FirstAccess.IsZero() {
+			o.FirstAccess = o.LastAccess
+		}
 	}
 	idx.mtx.Unlock()
 
@@ -193,14 +229,27 @@ func (idx *Index) UpdateObject(obj *Object) {
 
 	if o, ok := idx.Objects[key]; ok {
 		atomic.AddInt64(&idx.CacheSize, obj.Size-o.Size)
+		// an object that was read before it was rewritten has been reused
+		if !o.unused() {
+			obj.FirstAccess = o.FirstAccess
+			if obj.FirstAccess.IsZero() {
+				obj.FirstAccess = o.LastAccess
+			}
+		}
 	} else {
 		atomic.AddInt64(&idx.CacheSize, obj.Size)
 		atomic.AddInt64(&idx.ObjectCount, 1)
 	}
 
-	metrics.ObserveCacheSizeChange(idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
+	metrics.ObserveCacheSizeChange(idx.metrics, idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
 
 	idx.Objects[key] = obj
+	// a key that is written while awaiting deletion is no longer deleted
+	delete(idx.pending, key)
+	if _, ok := idx.unusedEvictions[key]; ok {
+		delete(idx.unusedEvictions, key)
+		metrics.ObserveCacheUnusedEvictionRewrite(idx.metrics, idx.name, idx.cacheType)
+	}
 	idx.mtx.Unlock()
 }
 
@@ -212,10 +261,10 @@ func (idx *Index) RemoveObject(key string) {
 		atomic.AddInt64(&idx.CacheSize, -o.Size)
 		atomic.AddInt64(&idx.ObjectCount, -1)
 
-		metrics.ObserveCacheOperation(idx.name, idx.cacheType, "del", "none", float64(o.Size))
+		metrics.ObserveCacheOperation(idx.metrics, idx.name, idx.cacheType, "del", "none", float64(o.Size))
 
 		delete(idx.Objects, key)
-		metrics.ObserveCacheSizeChange(idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
+		metrics.ObserveCacheSizeChange(idx.metrics, idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
 	}
 	idx.mtx.Unlock()
 }
@@ -229,9 +278,9 @@ func (idx *Index) RemoveObjects(keys []string, noLock bool) {
 		if o, ok := idx.Objects[key]; ok {
 			atomic.AddInt64(&idx.CacheSize, -o.Size)
 			atomic.AddInt64(&idx.ObjectCount, -1)
-			metrics.ObserveCacheOperation(idx.name, idx.cacheType, "del", "none", float64(o.Size))
+			metrics.ObserveCacheOperation(idx.metrics, idx.name, idx.cacheType, "del", "none", float64(o.Size))
 			delete(idx.Objects, key)
-			metrics.ObserveCacheSizeChange(idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
+			metrics.ObserveCacheSizeChange(idx.metrics, idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
 		}
 	}
 	idx.lastWrite = time.Now()
@@ -240,6 +289,22 @@ func (idx *Index) RemoveObjects(keys []string, noLock bool) {
 	}
 }
 
+// emitEvictions emits a cache event for the eviction of each of the keys' Objects for the reason.
+// The caller must hold the Index's lock
+func (idx *Index) emitEvictions(keys []string, reason string) {
+	if !idx.events.Enabled() {
+		return
+	}
+	for _, key := range keys {
+		e := events.New(events.TypeEvict, idx.name, key)
+		if o, ok := idx.Objects[key]; ok {
+			e.Bytes = o.Size
+		}
+		e.Reason = reason
+		idx.events.Emit(e)
+	}
+}
+
 // GetExpiration returns the cache index's expiration for the object of the given key
 func (idx *Index) GetExpiration(cacheKey string) time.Time {
 	idx.mtx.Lock()
@@ -266,6 +331,10 @@ func (idx *Index) flusher(log *tl.Logger) {
 }
 
 func (idx *Index) flushOnce(log *tl.Logger) {
+	// the index is not flushed while the deletions journal is being written, so that the
+	// removal of deleted objects is never persisted before the journal of their deletion
+	idx.journalMtx.Lock()
+	defer idx.journalMtx.Unlock()
 	idx.mtx.Lock()
 	bytes, err := idx.MarshalMsg(nil)
 	idx.mtx.Unlock()
@@ -288,7 +357,8 @@ func (idx *Index) reaper(log *tl.Logger) {
 
 type objectsAtime []*Object
 
-// reap makes a single iteration through the cache index to to find and remove expired elements
+// reap makes a single iteration through the cache index to to find and remove expired elements,
+// evict elements that were not accessed within the unused eviction window after they were written,
 // and evict least-recently-accessed elements to maintain the Maximum allowed Cache Size
 func (idx *Index) reap(log *tl.Logger) {
 
@@ -296,30 +366,68 @@ func (idx *Index) reap(log *tl.Logger) {
 	defer idx.mtx.Unlock()
 
 	removals := make([]string, 0)
+	unused := make([]string, 0)
 	remainders := make(objectsAtime, 0, idx.ObjectCount)
 
 	var cacheChanged bool
 
 	now := time.Now()
 
+	var unusedCutoff time.Time
+	if idx.options.UnusedEvictionWindow > 0 {
+		unusedCutoff = now.Add(-idx.options.UnusedEvictionWindow)
+	}
+
 	for _, o := range idx.Objects {
 		if o.Key == IndexKey {
 			continue
 		}
 		if o.Expiration.Before(now) && !o.Expiration.IsZero() {
 			removals = append(removals, o.Key)
+		} else if !unusedCutoff.IsZero() && o.LastWrite.Before(unusedCutoff) && o.unused() {
+			unused = append(unused, o.Key)
 		} else {
 			remainders = append(remainders, o)
 		}
 	}
 
 	if len(removals) > 0 {
-		metrics.ObserveCacheEvent(idx.name, idx.cacheType, "eviction", "ttl")
+		metrics.ObserveCacheEvent(idx.metrics, idx.name, idx.cacheType, "eviction", "ttl")
+		idx.emitEvictions(removals, "ttl")
 		go idx.bulkRemoveFunc(removals)
 		idx.RemoveObjects(removals, true)
 		cacheChanged = true
 	}
 
+	if idx.unusedEvictions == nil {
+		idx.unusedEvictions = make(map[string]time.Time)
+	}
+	for key, exp := range idx.unusedEvictions {
+		if !exp.After(now) {
+			delete(idx.unusedEvictions, key)
+		}
+	}
+
+	if len(unused) > 0 {
+		for _, key := range unused {
+			// objects without a TTL are counted if they are rewritten within the window
+			exp := idx.Objects[key].Expiration
+			if exp.IsZero() {
+				exp = now.Add(idx.options.UnusedEvictionWindow)
+			}
+			idx.unusedEvictions[key] = exp
+		}
+		log.Debug("evicting objects not accessed within the unused eviction window",
+			tl.Pairs{"cacheName": idx.name, "count": len(unused),
+				"unusedEvictionWindow": idx.options.UnusedEvictionWindow})
+		metrics.ObserveCacheEvent(idx.metrics, idx.name, idx.cacheType, "eviction", "unused")
+		metrics.ObserveCacheUnusedEvictions(idx.metrics, idx.name, idx.cacheType, len(unused))
+		idx.emitEvictions(unused, "unused")
+		go idx.bulkRemoveFunc(unused)
+		idx.RemoveObjects(unused, true)
+		cacheChanged = true
+	}
+
 	if ((idx.options.MaxSizeBytes > 0 && idx.CacheSize > idx.options.MaxSizeBytes) ||
 		(idx.options.MaxSizeObjects > 0 && idx.ObjectCount > idx.options.MaxSizeObjects)) &&
 		len(remainders) > 0 {
@@ -373,7 +481,8 @@ func (idx *Index) reap(log *tl.Logger) {
 		}
 
 		if len(removals) > 0 {
-			metrics.ObserveCacheEvent(idx.name, idx.cacheType, "eviction", evictionType)
+			metrics.ObserveCacheEvent(idx.metrics, idx.name, idx.cacheType, "eviction", evictionType)
+			idx.emitEvictions(removals, evictionType)
 			go idx.bulkRemoveFunc(removals)
 			idx.RemoveObjects(removals, true)
 			cacheChanged = true
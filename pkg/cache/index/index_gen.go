@@ -301,6 +301,11 @@ func (z *Object) DecodeMsg(dc *msgp.Reader) (err error) {
 			if err != nil {
 				return
 			}
+		case "firstaccess":
+			z.FirstAccess, err = dc.ReadTime()
+			if err != nil {
+				return
+			}
 		case "size":
 			z.Size, err = dc.ReadInt64()
 			if err != nil {
@@ -323,9 +328,9 @@ func (z *Object) DecodeMsg(dc *msgp.Reader) (err error) {
 
 // EncodeMsg implements msgp.Encodable
 func (z *Object) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 6
+	// map header, size 7
 	// write "key"
-	err = en.Append(0x86, 0xa3, 0x6b, 0x65, 0x79)
+	err = en.Append(0x87, 0xa3, 0x6b, 0x65, 0x79)
 	if err != nil {
 		return
 	}
@@ -360,6 +365,15 @@ func (z *Object) EncodeMsg(en *msgp.Writer) (err error) {
 	if err != nil {
 		return
 	}
+	// write "firstaccess"
+	err = en.Append(0xab, 0x66, 0x69, 0x72, 0x73, 0x74, 0x61, 0x63, 0x63, 0x65, 0x73, 0x73)
+	if err != nil {
+		return
+	}
+	err = en.WriteTime(z.FirstAccess)
+	if err != nil {
+		return
+	}
 	// write "size"
 	err = en.Append(0xa4, 0x73, 0x69, 0x7a, 0x65)
 	if err != nil {
@@ -384,9 +398,9 @@ func (z *Object) EncodeMsg(en *msgp.Writer) (err error) {
 // MarshalMsg implements msgp.Marshaler
 func (z *Object) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 6
+	// map header, size 7
 	// string "key"
-	o = append(o, 0x86, 0xa3, 0x6b, 0x65, 0x79)
+	o = append(o, 0x87, 0xa3, 0x6b, 0x65, 0x79)
 	o = msgp.AppendString(o, z.Key)
 	// string "expiration"
 	o = append(o, 0xaa, 0x65, 0x78, 0x70, 0x69, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e)
@@ -397,6 +411,9 @@ func (z *Object) MarshalMsg(b []byte) (o []byte, err error) {
 	// string "lastaccess"
 	o = append(o, 0xaa, 0x6c, 0x61, 0x73, 0x74, 0x61, 0x63, 0x63, 0x65, 0x73, 0x73)
 	o = msgp.AppendTime(o, z.LastAccess)
+	// string "firstaccess"
+	o = append(o, 0xab, 0x66, 0x69, 0x72, 0x73, 0x74, 0x61, 0x63, 0x63, 0x65, 0x73, 0x73)
+	o = msgp.AppendTime(o, z.FirstAccess)
 	// string "size"
 	o = append(o, 0xa4, 0x73, 0x69, 0x7a, 0x65)
 	o = msgp.AppendInt64(o, z.Size)
@@ -442,6 +459,11 @@ func (z *Object) UnmarshalMsg(bts []byte) (o []byte, err error) {
 			if err != nil {
 				return
 			}
+		case "firstaccess":
+			z.FirstAccess, bts, err = msgp.ReadTimeBytes(bts)
+			if err != nil {
+				return
+			}
 		case "size":
 			z.Size, bts, err = msgp.ReadInt64Bytes(bts)
 			if err != nil {
@@ -465,6 +487,6 @@ func (z *Object) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *Object) Msgsize() (s int) {
-	s = 1 + 4 + msgp.StringPrefixSize + len(z.Key) + 11 + msgp.TimeSize + 10 + msgp.TimeSize + 11 + msgp.TimeSize + 5 + msgp.Int64Size + 6 + msgp.BytesPrefixSize + len(z.Value)
+	s = 1 + 4 + msgp.StringPrefixSize + len(z.Key) + 11 + msgp.TimeSize + 10 + msgp.TimeSize + 11 + msgp.TimeSize + 12 + msgp.TimeSize + 5 + msgp.Int64Size + 6 + msgp.BytesPrefixSize + len(z.Value)
 	return
 }
@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
 	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
 	co "github.com/tricksterproxy/trickster/pkg/cache/options"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
@@ -45,7 +46,7 @@ func TestNewIndex(t *testing.T) {
 	cacheConfig := &co.Options{CacheType: "test",
 		Index: &io.Options{ReapInterval: time.Millisecond * 100,
 			FlushInterval: time.Millisecond * 100}}
-	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	idx := NewIndex("test", cacheConfig, nil, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 
 	// this gives a chance for the reaper to run through for test coverage
 	time.Sleep(1 * time.Second)
@@ -65,14 +66,14 @@ func TestNewIndex(t *testing.T) {
 		t.Error("expected true")
 	}
 
-	idx2 := NewIndex("test", "test", idx.ToBytes(), cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	idx2 := NewIndex("test", cacheConfig, idx.ToBytes(), testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 	if idx2 == nil {
 		t.Errorf("nil cache index")
 	}
 
 	cacheConfig.Index.FlushInterval = 0
 	cacheConfig.Index.ReapInterval = 0
-	idx3 := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	idx3 := NewIndex("test", cacheConfig, nil, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 	if idx3 == nil {
 		t.Errorf("nil cache index")
 	}
@@ -89,7 +90,7 @@ func TestReap(t *testing.T) {
 	cacheConfig.Index.MaxSizeBytes = 100
 	cacheConfig.Index.MaxSizeBackoffBytes = 30
 
-	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	idx := NewIndex("test", cacheConfig, nil, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 	if idx.name != "test" {
 		t.Errorf("expected test got %s", idx.name)
 	}
@@ -167,6 +168,95 @@ func TestReap(t *testing.T) {
 
 }
 
+func TestReapCacheEvents(t *testing.T) {
+
+	p := events.NewPublisher(nil)
+	if err := p.Configure(true, &events.Options{Size: 10}); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	idx := NewIndex("test", &co.Options{CacheType: "test", Events: p, Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
+		FlushInterval: time.Second * time.Duration(10)}}, nil, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	idx.UpdateObject(&Object{Key: "test.1", Value: []byte("test_value"), Expiration: time.Now().Add(-time.Minute)})
+	idx.UpdateObject(&Object{Key: "test.2", Value: []byte("test_value"), Expiration: time.Now().Add(time.Minute)})
+	idx.reap(testLogger)
+
+	var evs []*events.Event
+	for deadline := time.Now().Add(time.Second); len(evs) == 0 && time.Now().Before(deadline); {
+		evs, _, _, _ = p.Events(0, 0)
+		time.Sleep(time.Millisecond)
+	}
+	if len(evs) != 1 {
+		t.Fatalf("expected %d events got %d", 1, len(evs))
+	}
+	if evs[0].Type != events.TypeEvict || evs[0].Reason != "ttl" || evs[0].Cache != "test" ||
+		evs[0].Bytes != 10 {
+		t.Errorf("unexpected event %+v", evs[0])
+	}
+}
+
+func TestReapUnused(t *testing.T) {
+
+	cacheConfig := &co.Options{CacheType: "test",
+		Index: &io.Options{UnusedEvictionWindow: time.Minute}}
+
+	idx := NewIndex("test", cacheConfig, nil, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+
+	// written within the window, and not read
+	idx.UpdateObject(&Object{Key: "test.1", Value: []byte("test_value")})
+
+	// written before the window, and not read
+	idx.UpdateObject(&Object{Key: "test.2", Value: []byte("test_value"), Expiration: time.Now().Add(time.Hour)})
+	idx.Objects["test.2"].LastWrite = time.Now().Add(-2 * time.Minute)
+	idx.Objects["test.2"].LastAccess = idx.Objects["test.2"].LastWrite
+
+	// written before the window, and read
+	idx.UpdateObject(&Object{Key: "test.3", Value: []byte("test_value")})
+	idx.Objects["test.3"].LastWrite = time.Now().Add(-2 * time.Minute)
+	idx.UpdateObjectAccessTime("test.3")
+	if idx.Objects["test.3"].FirstAccess.IsZero() {
+		t.Error("expected non-zero first access")
+	}
+
+	// written before the window by an index that predates FirstAccess, and read
+	idx.UpdateObject(&Object{Key: "test.4", Value: []byte("test_value")})
+	idx.Objects["test.4"].LastWrite = time.Now().Add(-2 * time.Minute)
+
+	idx.reap(testLogger)
+
+	for _, key := range []string{"test.1", "test.3", "test.4"} {
+		if _, ok := idx.Objects[key]; !ok {
+			t.Errorf("expected key %s to be present", key)
+		}
+	}
+	if _, ok := idx.Objects["test.2"]; ok {
+		t.Errorf("expected key %s to be missing", "test.2")
+	}
+	if _, ok := idx.unusedEvictions["test.2"]; !ok {
+		t.Errorf("expected key %s to be recorded as evicted", "test.2")
+	}
+
+	// the rewrite of an object evicted as unused is counted once
+	idx.UpdateObject(&Object{Key: "test.2", Value: []byte("test_value")})
+	if _, ok := idx.unusedEvictions["test.2"]; ok {
+		t.Errorf("expected key %s to be no longer recorded as evicted", "test.2")
+	}
+
+	// a rewritten object that was read has been reused
+	idx.UpdateObject(&Object{Key: "test.3", Value: []byte("test_value")})
+	if idx.Objects["test.3"].FirstAccess.IsZero() {
+		t.Error("expected non-zero first access")
+	}
+
+	// evicted keys are forgotten once they would have expired
+	idx.unusedEvictions["test.5"] = time.Now().Add(-time.Second)
+	idx.reap(testLogger)
+	if _, ok := idx.unusedEvictions["test.5"]; ok {
+		t.Errorf("expected key %s to be forgotten", "test.5")
+	}
+}
+
 func TestObjectFromBytes(t *testing.T) {
 
 	obj := &Object{}
@@ -188,7 +278,7 @@ func TestUpdateObject(t *testing.T) {
 	cacheConfig := &co.Options{CacheType: "test",
 		Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
 			FlushInterval: time.Second * time.Duration(10)}}
-	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	idx := NewIndex("test", cacheConfig, nil, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 
 	idx.UpdateObject(&obj)
 	if _, ok := idx.Objects["test"]; ok {
@@ -230,7 +320,7 @@ func TestRemoveObject(t *testing.T) {
 	cacheConfig := &co.Options{CacheType: "test",
 		Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
 			FlushInterval: time.Second * time.Duration(10)}}
-	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	idx := NewIndex("test", cacheConfig, nil, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 
 	idx.UpdateObject(&obj)
 	if _, ok := idx.Objects["test"]; !ok {
@@ -283,7 +373,7 @@ func TestUpdateObjectTTL(t *testing.T) {
 	cacheConfig := &co.Options{CacheType: "test",
 		Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
 			FlushInterval: time.Second * time.Duration(10)}}
-	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	idx := NewIndex("test", cacheConfig, nil, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 
 	exp := idx.GetExpiration(cacheKey)
 	if !exp.IsZero() {
@@ -310,7 +400,7 @@ func TestUpdateOptions(t *testing.T) {
 	cacheConfig := &co.Options{CacheType: "test",
 		Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
 			FlushInterval: time.Second * time.Duration(10)}}
-	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	idx := NewIndex("test", cacheConfig, nil, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 
 	options := io.NewOptions()
 	options.MaxSizeBytes = 5
@@ -325,7 +415,7 @@ func TestRemoveObjects(t *testing.T) {
 	cacheConfig := &co.Options{CacheType: "test",
 		Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
 			FlushInterval: time.Second * time.Duration(10)}}
-	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	idx := NewIndex("test", cacheConfig, nil, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 	obj := &Object{Key: "test", Value: []byte("test_value")}
 	idx.UpdateObject(obj)
 	idx.RemoveObjects([]string{"test"}, false)
@@ -40,9 +40,21 @@ type Options struct {
 	// MaxSizeBackoffObjects indicates how far under max_size_objects the cache size must
 	// be to complete object-size-based eviction exercise.
 	MaxSizeBackoffObjects int64 `toml:"max_size_backoff_objects"`
+	// SyncBulkRemove indicates that objects removed in bulk are physically deleted before the
+	// removal returns, rather than in the background by the cache's deletion worker
+	SyncBulkRemove bool `toml:"sync_bulk_remove"`
+	// BulkRemoveBatchSize sets how many objects the deletion worker physically deletes in each batch
+	BulkRemoveBatchSize int `toml:"bulk_remove_batch_size"`
+	// BulkRemoveIntervalMS sets how long the deletion worker pauses between batches
+	BulkRemoveIntervalMS int `toml:"bulk_remove_interval_ms"`
+	// UnusedEvictionWindowSecs, when greater than 0, is how long after an object is written that
+	// the reaper evicts it ahead of its TTL if it has not been read
+	UnusedEvictionWindowSecs int `toml:"unused_eviction_window_secs"`
 
-	ReapInterval  time.Duration `toml:"-"`
-	FlushInterval time.Duration `toml:"-"`
+	ReapInterval         time.Duration `toml:"-"`
+	FlushInterval        time.Duration `toml:"-"`
+	BulkRemoveInterval   time.Duration `toml:"-"`
+	UnusedEvictionWindow time.Duration `toml:"-"`
 }
 
 // NewOptions returns a new Cache Index Options Reference with default values set
@@ -54,6 +66,8 @@ func NewOptions() *Options {
 		MaxSizeBackoffBytes:   d.DefaultMaxSizeBackoffBytes,
 		MaxSizeObjects:        d.DefaultMaxSizeObjects,
 		MaxSizeBackoffObjects: d.DefaultMaxSizeBackoffObjects,
+		BulkRemoveBatchSize:   d.DefaultBulkRemoveBatchSize,
+		BulkRemoveIntervalMS:  d.DefaultBulkRemoveIntervalMS,
 	}
 }
 
@@ -70,5 +84,9 @@ func (o *Options) Equal(o2 *Options) bool {
 		o.MaxSizeBytes == o2.MaxSizeBytes &&
 		o.MaxSizeBackoffBytes == o2.MaxSizeBackoffBytes &&
 		o.MaxSizeObjects == o2.MaxSizeObjects &&
-		o.MaxSizeBackoffObjects == o2.MaxSizeBackoffObjects
+		o.MaxSizeBackoffObjects == o2.MaxSizeBackoffObjects &&
+		o.SyncBulkRemove == o2.SyncBulkRemove &&
+		o.BulkRemoveBatchSize == o2.BulkRemoveBatchSize &&
+		o.BulkRemoveIntervalMS == o2.BulkRemoveIntervalMS &&
+		o.UnusedEvictionWindowSecs == o2.UnusedEvictionWindowSecs
 }
@@ -14,14 +14,124 @@
  * limitations under the License.
  */
 
+// Package key provides the inputs from which cache keys are derived
 package key
 
 import (
-	"io"
+	"encoding/json"
 	"net/http"
-	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
 )
 
-// HasherFunc is a custom function that returns a hashed key value string for cache objects
-type HasherFunc func(path string, params url.Values,
-	headers http.Header, body io.ReadCloser, extra string) (string, io.ReadCloser)
+// ProviderFunc is a custom function that provides the origin-specific Extras of a request's
+// key Inputs, from the request's URL path and body
+type ProviderFunc func(path string, body []byte, inputs *Inputs)
+
+// Component is a named value of a request that is included in its cache key
+type Component struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Inputs are the exact inputs from which a request's cache key is derived. Inputs having a Method
+// are keyed by all of their components. Others, such as those of requests to paths with no
+// config, or whose Extras are provided by the origin, are keyed by their URL path, Extras, Extra
+// and Identity only
+type Inputs struct {
+	// Origin is the name of the origin config that services the request
+	Origin string `json:"origin,omitempty"`
+	// Path is the path config that services the request
+	Path string `json:"path,omitempty"`
+	// URLPath is the path of the requested URL
+	URLPath string `json:"url_path"`
+	// Method is the request's HTTP method
+	Method string `json:"method,omitempty"`
+	// Identity is the checksum of the requestor's identity, under the per-identity auth
+	// cache policy
+	Identity string `json:"identity,omitempty"`
+	// Authorization is the request's Authorization header, which keys authenticated requests
+	// when they have no Identity. It is only ever rendered as its checksum
+	Authorization string `json:"-"`
+	// Params are the request's query or form parameters that are included in the key
+	Params []Component `json:"params,omitempty"`
+	// Headers are the request's headers that are included in the key
+	Headers []Component `json:"headers,omitempty"`
+	// FormFields are the request's form fields that are included in the key
+	FormFields []Component `json:"form_fields,omitempty"`
+	// BodyHash is the checksum of the request's body, for cacheable POST requests
+	BodyHash string `json:"body_hash,omitempty"`
+	// Extras are the origin-specific components of the key, set by the path's ProviderFunc
+	Extras []string `json:"extras,omitempty"`
+	// Extra is the component of the key that is added by the caching engine, such as the step
+	// of a timeseries query
+	Extra string `json:"extra,omitempty"`
+}
+
+// credentialHeaders are the headers whose values are only ever rendered as their checksums
+var credentialHeaders = map[string]bool{
+	headers.NameAuthorization:      true,
+	headers.NameProxyAuthorization: true,
+	headers.NameCookie:             true,
+}
+
+// Derive returns the cache key derived from the Inputs
+func (ki *Inputs) Derive() string {
+	if ki.Method == "" {
+		k := ki.URLPath + strings.Join(ki.Extras, "") + ki.Extra
+		if ki.Identity != "" {
+			k += ".identity." + ki.Identity
+		}
+		return md5.Checksum(k)
+	}
+	vals := make([]string, 0, len(ki.Params)+len(ki.Headers)+len(ki.FormFields)+3)
+	if ki.Identity != "" {
+		vals = append(vals, "identity."+ki.Identity+".")
+	} else if ki.Authorization != "" {
+		vals = append(vals, headers.NameAuthorization+"."+ki.Authorization+".")
+	}
+	vals = append(vals, "method."+ki.Method+".")
+	for _, l := range [][]Component{ki.Params, ki.Headers, ki.FormFields} {
+		for _, c := range l {
+			vals = append(vals, c.Name+"."+c.Value+".")
+		}
+	}
+	if ki.BodyHash != "" {
+		vals = append(vals, "body."+ki.BodyHash+".")
+	}
+	sort.Strings(vals)
+	return md5.Checksum(ki.URLPath + "." + strings.Join(vals, "") +
+		strings.Join(ki.Extras, "") + ki.Extra)
+}
+
+// MarshalJSON encodes the Inputs, with the values of credentials replaced by their checksums
+func (ki *Inputs) MarshalJSON() ([]byte, error) {
+	type inputs Inputs
+	c := inputs(*ki)
+	if len(ki.Headers) > 0 {
+		c.Headers = make([]Component, len(ki.Headers))
+		for i, h := range ki.Headers {
+			if credentialHeaders[http.CanonicalHeaderKey(h.Name)] {
+				h.Value = md5.Checksum(h.Value)
+			}
+			c.Headers[i] = h
+		}
+	}
+	var a string
+	if ki.Authorization != "" {
+		a = md5.Checksum(ki.Authorization)
+	}
+	return json.Marshal(struct {
+		*inputs
+		Authorization string `json:"authorization,omitempty"`
+	}{&c, a})
+}
+
+// String returns the JSON encoding of the Inputs
+func (ki *Inputs) String() string {
+	b, _ := json.Marshal(ki)
+	return string(b)
+}
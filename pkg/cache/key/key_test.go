@@ -0,0 +1,78 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package key
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+func TestDerive(t *testing.T) {
+
+	tests := []struct {
+		ki       *Inputs
+		expected string
+	}{
+		{ // keyed by the path only
+			&Inputs{URLPath: "/", Extra: "extra"},
+			md5.Checksum("/extra"),
+		},
+		{ // keyed by provided extras
+			&Inputs{URLPath: "/", Extras: []string{"/a", "/b"}, Extra: "extra", Identity: "id"},
+			md5.Checksum("//a/bextra.identity.id"),
+		},
+		{ // keyed by all components, which are sorted
+			&Inputs{URLPath: "/", Method: "GET", Authorization: "test", Extra: "extra",
+				Params:  []Component{{"query", "up"}, {"end", "2"}},
+				Headers: []Component{{"X-Test", "test"}},
+			},
+			md5.Checksum("/.Authorization.test.X-Test.test.end.2.method.GET.query.up.extra"),
+		},
+		{ // the identity replaces the authorization
+			&Inputs{URLPath: "/", Method: "POST", Identity: "id", Authorization: "test",
+				FormFields: []Component{{"field1", "value1"}}, BodyHash: "hash",
+			},
+			md5.Checksum("/.body.hash.field1.value1.identity.id.method.POST."),
+		},
+	}
+
+	for i, test := range tests {
+		if k := test.ki.Derive(); k != test.expected {
+			t.Errorf("(%d) expected %s got %s", i, test.expected, k)
+		}
+	}
+}
+
+func TestInputsString(t *testing.T) {
+	ki := &Inputs{URLPath: "/", Method: "GET", Authorization: "secret",
+		Headers: []Component{{"Cookie", "session=secret"}, {"X-Test", "test"}},
+	}
+	s := ki.String()
+	if strings.Contains(s, "secret") {
+		t.Errorf("unexpected credentials in %s", s)
+	}
+	if !strings.Contains(s, `"authorization":"`+md5.Checksum("secret")+`"`) ||
+		!strings.Contains(s, `{"name":"X-Test","value":"test"}`) {
+		t.Errorf("unexpected inputs %s", s)
+	}
+	// rendering does not modify the inputs
+	if ki.Headers[0].Value != "session=secret" {
+		t.Errorf("expected %s got %s", "session=secret", ki.Headers[0].Value)
+	}
+}
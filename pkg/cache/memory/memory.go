@@ -42,6 +42,19 @@ type Cache struct {
 	lockPrefix string
 }
 
+func init() {
+	cache.MustRegisterProvider("memory", newProviderCache)
+}
+
+func newProviderCache(name string, cfg *options.Options, logger *tl.Logger) cache.Cache {
+	return &Cache{Name: name, Config: cfg, Logger: logger}
+}
+
+// ReportsMetrics returns true, since the Cache records its own operation metrics
+func (c *Cache) ReportsMetrics() bool {
+	return true
+}
+
 // Locker returns the cache's locker
 func (c *Cache) Locker() locks.NamedLocker {
 	return c.locker
@@ -63,7 +76,7 @@ func (c *Cache) Connect() error {
 		"maxSizeBytes": c.Config.Index.MaxSizeBytes, "maxSizeObjects": c.Config.Index.MaxSizeObjects})
 	c.lockPrefix = c.Name + ".memory."
 	c.client = sync.Map{}
-	c.Index = index.NewIndex(c.Name, c.Config.CacheType, nil, c.Config.Index, c.BulkRemove, nil, c.Logger)
+	c.Index = index.NewIndex(c.Name, c.Config, nil, c.BulkRemove, nil, c.Logger)
 	return nil
 }
 
@@ -85,11 +98,11 @@ func (c *Cache) store(cacheKey string, byteData []byte, refData cache.ReferenceO
 	isDirect := byteData == nil && refData != nil
 	if byteData != nil {
 		l = len(byteData)
-		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(l))
+		metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "set", "none", float64(l))
 		o1 = &index.Object{Key: cacheKey, Value: byteData, Expiration: time.Now().Add(ttl)}
 		o2 = &index.Object{Key: cacheKey, Value: byteData, Expiration: time.Now().Add(ttl)}
 	} else if refData != nil {
-		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "setDirect", "none", 0)
+		metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "setDirect", "none", 0)
 		o1 = &index.Object{Key: cacheKey, ReferenceValue: refData, Expiration: time.Now().Add(ttl)}
 		o2 = &index.Object{Key: cacheKey, ReferenceValue: refData, Expiration: time.Now().Add(ttl)}
 	}
@@ -142,20 +155,21 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) (*index
 
 	if ok {
 		o := record.(*index.Object)
-		o.Expiration = c.Index.GetExpiration(cacheKey)
+		// the stored object is shared by concurrent retrievals, so it is not modified here
+		exp := c.Index.GetExpiration(cacheKey)
 
-		if allowExpired || o.Expiration.IsZero() || o.Expiration.After(time.Now()) {
+		if allowExpired || exp.IsZero() || exp.After(time.Now()) {
 			c.Logger.Debug("memory cache retrieve", tl.Pairs{"cacheKey": cacheKey})
 			if atime {
 				go c.Index.UpdateObjectAccessTime(cacheKey)
 			}
-			metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(o.Value)))
+			metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "get", "hit", float64(len(o.Value)))
 			return o, status.LookupStatusHit, nil
 		}
 		// Cache Object has been expired but not reaped, go ahead and delete it
 		go c.remove(cacheKey, false)
 	}
-	metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+	metrics.ObserveCacheMiss(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType)
 	return nil, status.LookupStatusKeyMiss, cache.ErrKNF
 }
 
@@ -176,7 +190,7 @@ func (c *Cache) remove(cacheKey string, isBulk bool) {
 	if !isBulk {
 		go c.Index.RemoveObject(cacheKey)
 	}
-	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+	metrics.ObserveCacheDel(c.Config.Metrics, c.Name, c.Config.CacheType, 0)
 }
 
 // BulkRemove removes a list of objects from the cache
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// InstrumentedCache wraps a Cache that does not record its own operation metrics,
+// and records them on its behalf, under the same names and labels as the built-in Caches
+type InstrumentedCache struct {
+	cache.Cache
+	name string
+}
+
+// Instrument returns the Cache wrapped with operation metrics for the named cache
+func Instrument(name string, c cache.Cache) cache.Cache {
+	return &InstrumentedCache{Cache: c, name: name}
+}
+
+// Unwrap returns the underlying Cache
+func (c *InstrumentedCache) Unwrap() cache.Cache {
+	return c.Cache
+}
+
+func (c *InstrumentedCache) cacheType() string {
+	if cfg := c.Cache.Configuration(); cfg != nil {
+		return cfg.CacheType
+	}
+	return ""
+}
+
+// metrics returns the Metrics of the underlying Cache's configuration
+func (c *InstrumentedCache) metrics() *metrics.Metrics {
+	if cfg := c.Cache.Configuration(); cfg != nil {
+		return cfg.Metrics
+	}
+	return nil
+}
+
+// Store places an object in the underlying Cache and records the operation
+func (c *InstrumentedCache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	err := c.Cache.Store(cacheKey, data, ttl)
+	if err != nil {
+		ObserveCacheEvent(c.metrics(), c.name, c.cacheType(), "error", "failed to store object")
+		return err
+	}
+	ObserveCacheOperation(c.metrics(), c.name, c.cacheType(), "set", "none", float64(len(data)))
+	return nil
+}
+
+// Retrieve gets an object from the underlying Cache and records the operation
+func (c *InstrumentedCache) Retrieve(cacheKey string,
+	allowExpired bool) ([]byte, status.LookupStatus, error) {
+	b, s, err := c.Cache.Retrieve(cacheKey, allowExpired)
+	switch {
+	case err == nil && s == status.LookupStatusHit:
+		ObserveCacheOperation(c.metrics(), c.name, c.cacheType(), "get", "hit", float64(len(b)))
+	case err == nil || err == cache.ErrKNF:
+		ObserveCacheMiss(c.metrics(), cacheKey, c.name, c.cacheType())
+	default:
+		ObserveCacheEvent(c.metrics(), c.name, c.cacheType(), "error", "failed to retrieve object")
+	}
+	return b, s, err
+}
+
+// Remove removes an object from the underlying Cache and records the operation
+func (c *InstrumentedCache) Remove(cacheKey string) {
+	c.Cache.Remove(cacheKey)
+	ObserveCacheDel(c.metrics(), c.name, c.cacheType(), 0)
+}
+
+// BulkRemove removes a list of objects from the underlying Cache and records the operation
+func (c *InstrumentedCache) BulkRemove(cacheKeys []string) {
+	c.Cache.BulkRemove(cacheKeys)
+	for range cacheKeys {
+		ObserveCacheDel(c.metrics(), c.name, c.cacheType(), 0)
+	}
+}
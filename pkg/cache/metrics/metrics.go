@@ -18,41 +18,96 @@ package metrics
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/util/metrics"
 )
 
 // ObserveCacheMiss records a Cache Miss event
-func ObserveCacheMiss(cacheKey, cacheName, cacheType string) {
-	ObserveCacheOperation(cacheName, cacheType, "get", "miss", 0)
+func ObserveCacheMiss(m *metrics.Metrics, cacheKey, cacheName, cacheType string) {
+	ObserveCacheOperation(m, cacheName, cacheType, "get", "miss", 0)
 }
 
 // ObserveCacheDel records a cache deletion event
-func ObserveCacheDel(cache, cacheType string, count float64) {
-	ObserveCacheOperation(cache, cacheType, "del", "none", count)
+func ObserveCacheDel(m *metrics.Metrics, cache, cacheType string, count float64) {
+	ObserveCacheOperation(m, cache, cacheType, "del", "none", count)
 }
 
 // CacheError returns an empty cache object and the formatted error
-func CacheError(cacheKey, cacheName, cacheType string, msg string) ([]byte, error) {
-	ObserveCacheEvent(cacheName, cacheType, "error", msg)
+func CacheError(m *metrics.Metrics, cacheKey, cacheName, cacheType string, msg string) ([]byte, error) {
+	ObserveCacheEvent(m, cacheName, cacheType, "error", msg)
 	return nil, fmt.Errorf(msg, cacheKey)
 }
 
 // ObserveCacheOperation increments counters as cache operations occur
-func ObserveCacheOperation(cache, cacheType, operation, status string, bytes float64) {
-	metrics.CacheObjectOperations.WithLabelValues(cache, cacheType, operation, status).Inc()
+func ObserveCacheOperation(m *metrics.Metrics, cache, cacheType, operation, status string, bytes float64) {
+	m = metrics.OrDefault(m)
+	m.CacheObjectOperations.WithLabelValues(cache, cacheType, operation, status).Inc()
 	if bytes > 0 {
-		metrics.CacheByteOperations.WithLabelValues(cache, cacheType, operation, status).Add(bytes)
+		m.CacheByteOperations.WithLabelValues(cache, cacheType, operation, status).Add(bytes)
 	}
 }
 
 // ObserveCacheEvent increments counters as cache events occur
-func ObserveCacheEvent(cache, cacheType, event, reason string) {
-	metrics.CacheEvents.WithLabelValues(cache, cacheType, event, reason).Inc()
+func ObserveCacheEvent(m *metrics.Metrics, cache, cacheType, event, reason string) {
+	metrics.OrDefault(m).CacheEvents.WithLabelValues(cache, cacheType, event, reason).Inc()
+}
+
+// ObserveCacheCorruption records a cache object that failed integrity verification
+func ObserveCacheCorruption(m *metrics.Metrics, cache, cacheType string) {
+	metrics.OrDefault(m).CacheCorruptions.WithLabelValues(cache, cacheType).Inc()
+}
+
+// ObserveCacheDecodeFailure records a cache object that could not be retrieved or decoded, by failure class
+func ObserveCacheDecodeFailure(m *metrics.Metrics, cache, cacheType, class string) {
+	metrics.OrDefault(m).CacheDecodeFailures.WithLabelValues(cache, cacheType, class).Inc()
+}
+
+// ObserveCacheLockWait records the time a caller waited to acquire a cache key lock in the provided mode
+func ObserveCacheLockWait(m *metrics.Metrics, cache, cacheType, mode string, wait time.Duration) {
+	metrics.OrDefault(m).CacheLockWaitDuration.WithLabelValues(cache, cacheType, mode).Observe(wait.Seconds())
 }
 
 // ObserveCacheSizeChange adjust counters and gauges as the cache size changes due to object operations
-func ObserveCacheSizeChange(cache, cacheType string, byteCount, objectCount int64) {
-	metrics.CacheObjects.WithLabelValues(cache, cacheType).Set(float64(objectCount))
-	metrics.CacheBytes.WithLabelValues(cache, cacheType).Set(float64(byteCount))
+func ObserveCacheSizeChange(m *metrics.Metrics, cache, cacheType string, byteCount, objectCount int64) {
+	m = metrics.OrDefault(m)
+	m.CacheObjects.WithLabelValues(cache, cacheType).Set(float64(objectCount))
+	m.CacheBytes.WithLabelValues(cache, cacheType).Set(float64(byteCount))
+}
+
+// ObserveCachePendingDeletions sets the count of objects awaiting physical deletion from the cache
+func ObserveCachePendingDeletions(m *metrics.Metrics, cache, cacheType string, count int) {
+	metrics.OrDefault(m).CachePendingDeletions.WithLabelValues(cache, cacheType).Set(float64(count))
+}
+
+// ObserveCacheDeferredDeletions records objects physically deleted by the cache's background deletion worker
+func ObserveCacheDeferredDeletions(m *metrics.Metrics, cache, cacheType string, count int) {
+	metrics.OrDefault(m).CacheDeferredDeletions.WithLabelValues(cache, cacheType).Add(float64(count))
+}
+
+// ObserveCacheUnusedEvictions records objects evicted from the cache because they were not read
+// within the unused eviction window
+func ObserveCacheUnusedEvictions(m *metrics.Metrics, cache, cacheType string, count int) {
+	metrics.OrDefault(m).CacheUnusedEvictions.WithLabelValues(cache, cacheType).Add(float64(count))
+}
+
+// ObserveCacheUnusedEvictionRewrite records an object written to the cache again after it was
+// evicted as unused
+func ObserveCacheUnusedEvictionRewrite(m *metrics.Metrics, cache, cacheType string) {
+	metrics.OrDefault(m).CacheUnusedEvictionRewrites.WithLabelValues(cache, cacheType).Inc()
+}
+
+// ObserveCacheReadOnly records whether a cache is in read-only mode
+func ObserveCacheReadOnly(m *metrics.Metrics, cache, cacheType string, readOnly bool) {
+	var v float64
+	if readOnly {
+		v = 1
+	}
+	metrics.OrDefault(m).CacheReadOnly.WithLabelValues(cache, cacheType).Set(v)
+}
+
+// ObserveCacheReadOnlySkippedStore records an object that was not stored because its cache was
+// in read-only mode
+func ObserveCacheReadOnlySkippedStore(m *metrics.Metrics, cache, cacheType string) {
+	metrics.OrDefault(m).CacheReadOnlySkippedStores.WithLabelValues(cache, cacheType).Inc()
 }
@@ -18,6 +18,7 @@ package metrics
 
 import (
 	"testing"
+	"time"
 )
 
 var testCacheKey, testCacheName, testCacheType string
@@ -29,30 +30,34 @@ func init() {
 }
 
 func TestObserveCacheMiss(t *testing.T) {
-	ObserveCacheMiss(testCacheKey, testCacheName, testCacheType)
+	ObserveCacheMiss(nil, testCacheKey, testCacheName, testCacheType)
 }
 
 // ObserveCacheDel records a cache deletion event
 func TestObserveCacheDel(t *testing.T) {
-	ObserveCacheDel(testCacheName, testCacheType, 0)
+	ObserveCacheDel(nil, testCacheName, testCacheType, 0)
 }
 
 func TestCacheError(t *testing.T) {
-	_, err := CacheError(testCacheKey, testCacheName, testCacheType, "%s")
+	_, err := CacheError(nil, testCacheKey, testCacheName, testCacheType, "%s")
 	if err.Error() != testCacheKey {
 		t.Errorf("expected %s got %s", testCacheKey, err.Error())
 	}
 }
 
 func TestObserveCacheOperation(t *testing.T) {
-	ObserveCacheOperation(testCacheName, testCacheType, "set", "ok", 0)
-	ObserveCacheOperation(testCacheName, testCacheType, "set", "ok", 1)
+	ObserveCacheOperation(nil, testCacheName, testCacheType, "set", "ok", 0)
+	ObserveCacheOperation(nil, testCacheName, testCacheType, "set", "ok", 1)
 }
 
 func TestObserveCacheEvent(t *testing.T) {
-	ObserveCacheEvent(testCacheName, testCacheType, "test", "test")
+	ObserveCacheEvent(nil, testCacheName, testCacheType, "test", "test")
 }
 
 func TestObserveCacheSizeChange(t *testing.T) {
-	ObserveCacheSizeChange(testCacheName, testCacheType, 0, 0)
+	ObserveCacheSizeChange(nil, testCacheName, testCacheType, 0, 0)
+}
+
+func TestObserveCacheLockWait(t *testing.T) {
+	ObserveCacheLockWait(nil, testCacheName, testCacheType, "write", time.Millisecond)
 }
@@ -17,13 +17,18 @@
 package options
 
 import (
+	"time"
+
 	badger "github.com/tricksterproxy/trickster/pkg/cache/badger/options"
 	bbolt "github.com/tricksterproxy/trickster/pkg/cache/bbolt/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
 	filesystem "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
 	index "github.com/tricksterproxy/trickster/pkg/cache/index/options"
 	redis "github.com/tricksterproxy/trickster/pkg/cache/redis/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/types"
 	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight/policy"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
 )
 
 // Options is a collection of defining the Trickster Caching Behavior
@@ -42,12 +47,42 @@ type Options struct {
 	BBolt *bbolt.Options `toml:"bbolt"`
 	// Badger provides options for BadgerDB caching
 	Badger *badger.Options `toml:"badger"`
+	// VerifyChecksums, when true, stores a checksum with each serialized cache object, which is
+	// verified on retrieval. Objects failing verification are treated as a cache miss and removed
+	VerifyChecksums bool `toml:"verify_checksums"`
+	// PreflightPolicyName specifies how a failed startup connection to the cache is handled
+	// ("required", "warn", "ignore")
+	PreflightPolicyName string `toml:"preflight_policy"`
+	// ReadOnly, when true, starts the cache in read-only mode, during which objects are retrieved
+	// but not stored, until the mode is changed with the Cache Read-Only Handler
+	ReadOnly bool `toml:"read_only"`
+	// ReadOnlyErrorThreshold is the ratio of failed stores to attempted stores in the window at
+	// which the cache automatically enters read-only mode. 0 disables automatic read-only mode
+	ReadOnlyErrorThreshold float64 `toml:"read_only_error_threshold"`
+	// ReadOnlyWindowSecs is the window over which the cache's store error rate is evaluated
+	ReadOnlyWindowSecs int `toml:"read_only_window_secs"`
+	// ReadOnlyMinStores is the number of stores the cache must attempt in the window before its
+	// store error rate is evaluated
+	ReadOnlyMinStores int `toml:"read_only_min_stores"`
+	// ReadOnlyProbeIntervalSecs is the interval at which a cache in automatic read-only mode lets a
+	// store through as a probe, which exits read-only mode when it succeeds
+	ReadOnlyProbeIntervalSecs int `toml:"read_only_probe_interval_secs"`
 
 	//  Synthetic Values
 
 	// CacheTypeID represents the internal constant for the provided CacheType string
 	// and is automatically populated at startup
 	CacheTypeID types.CacheType `toml:"-"`
+	// PreflightPolicy is the parsed value of PreflightPolicyName
+	PreflightPolicy policy.Policy `toml:"-"`
+	// ReadOnlyWindow is the time.Duration representation of ReadOnlyWindowSecs
+	ReadOnlyWindow time.Duration `toml:"-"`
+	// ReadOnlyProbeInterval is the time.Duration representation of ReadOnlyProbeIntervalSecs
+	ReadOnlyProbeInterval time.Duration `toml:"-"`
+	// Metrics are the Metrics of the router that uses the cache. When nil, the Default Metrics are used
+	Metrics *metrics.Metrics `toml:"-"`
+	// Events is the Publisher of the cache's events. When nil, the Default Publisher is used
+	Events *events.Publisher `toml:"-"`
 }
 
 // NewOptions will return a pointer to an OriginConfig with the default configuration settings
@@ -61,6 +96,15 @@ func NewOptions() *Options {
 		BBolt:       bbolt.NewOptions(),
 		Badger:      badger.NewOptions(),
 		Index:       index.NewOptions(),
+
+		PreflightPolicy:     d.DefaultPreflightPolicy,
+		PreflightPolicyName: d.DefaultPreflightPolicyName,
+
+		ReadOnlyWindowSecs:        d.DefaultCacheReadOnlyWindowSecs,
+		ReadOnlyWindow:            time.Duration(d.DefaultCacheReadOnlyWindowSecs) * time.Second,
+		ReadOnlyMinStores:         d.DefaultCacheReadOnlyMinStores,
+		ReadOnlyProbeIntervalSecs: d.DefaultCacheReadOnlyProbeIntervalSecs,
+		ReadOnlyProbeInterval:     time.Duration(d.DefaultCacheReadOnlyProbeIntervalSecs) * time.Second,
 	}
 }
 
@@ -71,6 +115,18 @@ func (cc *Options) Clone() *Options {
 	c.Name = cc.Name
 	c.CacheType = cc.CacheType
 	c.CacheTypeID = cc.CacheTypeID
+	c.VerifyChecksums = cc.VerifyChecksums
+	c.PreflightPolicyName = cc.PreflightPolicyName
+	c.PreflightPolicy = cc.PreflightPolicy
+	c.ReadOnly = cc.ReadOnly
+	c.ReadOnlyErrorThreshold = cc.ReadOnlyErrorThreshold
+	c.ReadOnlyWindowSecs = cc.ReadOnlyWindowSecs
+	c.ReadOnlyWindow = cc.ReadOnlyWindow
+	c.ReadOnlyMinStores = cc.ReadOnlyMinStores
+	c.ReadOnlyProbeIntervalSecs = cc.ReadOnlyProbeIntervalSecs
+	c.ReadOnlyProbeInterval = cc.ReadOnlyProbeInterval
+	c.Metrics = cc.Metrics
+	c.Events = cc.Events
 
 	c.Index.FlushInterval = cc.Index.FlushInterval
 	c.Index.FlushIntervalSecs = cc.Index.FlushIntervalSecs
@@ -80,6 +136,12 @@ func (cc *Options) Clone() *Options {
 	c.Index.MaxSizeObjects = cc.Index.MaxSizeObjects
 	c.Index.ReapInterval = cc.Index.ReapInterval
 	c.Index.ReapIntervalSecs = cc.Index.ReapIntervalSecs
+	c.Index.SyncBulkRemove = cc.Index.SyncBulkRemove
+	c.Index.BulkRemoveBatchSize = cc.Index.BulkRemoveBatchSize
+	c.Index.BulkRemoveIntervalMS = cc.Index.BulkRemoveIntervalMS
+	c.Index.BulkRemoveInterval = cc.Index.BulkRemoveInterval
+	c.Index.UnusedEvictionWindowSecs = cc.Index.UnusedEvictionWindowSecs
+	c.Index.UnusedEvictionWindow = cc.Index.UnusedEvictionWindow
 
 	c.Badger.Directory = cc.Badger.Directory
 	c.Badger.ValueDirectory = cc.Badger.ValueDirectory
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/types"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// ProviderFunc returns a new, unconnected Cache for the named cache configuration
+type ProviderFunc func(name string, cfg *options.Options, logger *tl.Logger) Cache
+
+// MetricsReporter is optionally implemented by Caches that record their own operation metrics.
+// Caches that do not report their own metrics are instrumented when they are created
+type MetricsReporter interface {
+	ReportsMetrics() bool
+}
+
+// ErrInvalidProvider is returned when a provider is registered without a name or function
+var ErrInvalidProvider = errors.New("cache provider registrations require a name and function")
+
+var providers = make(map[string]ProviderFunc)
+var providersLock sync.RWMutex
+
+// RegisterProvider registers the ProviderFunc as the provider of Caches having the provided
+// cache type. Providers, including those maintained outside of Trickster, must be registered
+// before the configuration is loaded, such as in the init() function of the provider's package,
+// since configurations referencing unknown cache types fail validation. Cache type names are
+// not case-sensitive
+func RegisterProvider(cacheType string, f ProviderFunc) error {
+	cacheType = strings.ToLower(cacheType)
+	if cacheType == "" || f == nil {
+		return ErrInvalidProvider
+	}
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	if _, ok := providers[cacheType]; ok {
+		return fmt.Errorf("cache provider already registered for cache type %s", cacheType)
+	}
+	providers[cacheType] = f
+	types.Register(cacheType)
+	return nil
+}
+
+// MustRegisterProvider registers the ProviderFunc as the provider of Caches for the cache
+// type, and panics if the registration fails
+func MustRegisterProvider(cacheType string, f ProviderFunc) {
+	if err := RegisterProvider(cacheType, f); err != nil {
+		panic(err)
+	}
+}
+
+// LookupProvider returns the provider registered for the cache type
+func LookupProvider(cacheType string) (ProviderFunc, bool) {
+	providersLock.RLock()
+	f, ok := providers[strings.ToLower(cacheType)]
+	providersLock.RUnlock()
+	return f, ok
+}
+
+// ProviderNames returns the sorted list of cache types having registered providers
+func ProviderNames() []string {
+	providersLock.RLock()
+	names := make([]string, 0, len(providers))
+	for k := range providers {
+		names = append(names, k)
+	}
+	providersLock.RUnlock()
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/types"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestRegisterProvider(t *testing.T) {
+
+	f := func(name string, cfg *options.Options, logger *tl.Logger) Cache {
+		return nil
+	}
+
+	if err := RegisterProvider("", f); err != ErrInvalidProvider {
+		t.Errorf("expected %v got %v", ErrInvalidProvider, err)
+	}
+	if err := RegisterProvider("test-provider", nil); err != ErrInvalidProvider {
+		t.Errorf("expected %v got %v", ErrInvalidProvider, err)
+	}
+	if err := RegisterProvider("Test-Provider", f); err != nil {
+		t.Error(err)
+	}
+	if err := RegisterProvider("test-provider", f); err == nil {
+		t.Error("expected error for duplicate registration")
+	}
+
+	if _, ok := LookupProvider("TEST-PROVIDER"); !ok {
+		t.Error("expected provider lookups to be case-insensitive")
+	}
+	found := false
+	for _, n := range ProviderNames() {
+		if n == "test-provider" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected test-provider in %v", ProviderNames())
+	}
+
+	if _, ok := types.Lookup("test-provider"); !ok {
+		t.Error("expected test-provider to be a known cache type")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for duplicate registration")
+			}
+		}()
+		MustRegisterProvider("test-provider", f)
+	}()
+}
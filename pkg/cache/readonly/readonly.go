@@ -0,0 +1,330 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package readonly places Caches in read-only mode, during which objects are retrieved but not
+// stored, either on request or automatically while the cache's backend is failing its stores
+package readonly
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	gm "github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// The read-only modes of a Cache
+const (
+	// ModeAuto enters read-only mode when the cache's store error rate exceeds its threshold,
+	// and exits it when a probing store succeeds
+	ModeAuto = "auto"
+	// ModeReadOnly forces read-only mode
+	ModeReadOnly = "read-only"
+	// ModeReadWrite forces the cache to remain writable
+	ModeReadWrite = "read-write"
+)
+
+// ErrInvalidMode is returned when a read-only mode is not supported
+var ErrInvalidMode = errors.New("invalid read-only mode; must be one of auto, read-only or read-write")
+
+// Status is the read-only status of a Cache
+type Status struct {
+	// Cache is the name of the cache
+	Cache string `json:"cache"`
+	// Mode is the cache's read-only mode
+	Mode string `json:"mode"`
+	// ReadOnly is true if the cache is in read-only mode
+	ReadOnly bool `json:"read_only"`
+	// Since is the time the cache entered read-only mode
+	Since *time.Time `json:"since,omitempty"`
+	// SkippedStores is the number of objects not stored while the cache was in read-only mode
+	SkippedStores uint64 `json:"skipped_stores"`
+}
+
+// Cache wraps a Cache, skipping its stores while it is in read-only mode
+type Cache struct {
+	cache.Cache
+	name      string
+	cacheType string
+	metrics   *gm.Metrics
+	logger    *tl.Logger
+
+	// readOnly is 1 while the cache is in read-only mode, and is read without the lock by stores
+	readOnly int32
+	skipped  uint64
+
+	mtx            sync.Mutex
+	mode           string
+	configReadOnly bool
+	threshold      float64
+	window         time.Duration
+	minStores      int
+	probeInterval  time.Duration
+	since          time.Time
+	windowStart    time.Time
+	stores         int
+	failures       int
+	nextProbe      time.Time
+}
+
+// Wrap returns the Cache wrapped with read-only mode per its configuration, and registers it
+// under its name for the Cache Read-Only Handler until it is closed
+func Wrap(name string, c cache.Cache, logger *tl.Logger) *Cache {
+	cfg := c.Configuration()
+	rc := &Cache{Cache: c, name: name, cacheType: cfg.CacheType, metrics: cfg.Metrics,
+		logger: logger, mode: ModeAuto}
+	rc.updateOptions(cfg)
+	if cfg.ReadOnly {
+		rc.setMode(ModeReadOnly, time.Now())
+	}
+	rc.configReadOnly = cfg.ReadOnly
+	metrics.ObserveCacheReadOnly(rc.metrics, name, rc.cacheType, cfg.ReadOnly)
+	register(rc)
+	return rc
+}
+
+// Unwrap returns the underlying Cache
+func (c *Cache) Unwrap() cache.Cache {
+	return c.Cache
+}
+
+// ReadOnly returns true if the cache is in read-only mode
+func (c *Cache) ReadOnly() bool {
+	return atomic.LoadInt32(&c.readOnly) == 1
+}
+
+// Store places an object in the underlying Cache, unless the cache is in read-only mode, in which
+// case cache.ErrReadOnly is returned. In automatic read-only mode, a store is periodically let
+// through as a probe, which exits read-only mode when it succeeds
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	if c.ReadOnly() && !c.claimProbe() {
+		atomic.AddUint64(&c.skipped, 1)
+		metrics.ObserveCacheReadOnlySkippedStore(c.metrics, c.name, c.cacheType)
+		return cache.ErrReadOnly
+	}
+	err := c.Cache.Store(cacheKey, data, ttl)
+	c.observeStore(err)
+	return err
+}
+
+// SetTTL updates the TTL of an object in the underlying Cache, unless the cache is in read-only
+// mode, in which case the object retains its TTL
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	if c.ReadOnly() {
+		return
+	}
+	c.Cache.SetTTL(cacheKey, ttl)
+}
+
+// Close unregisters the cache and closes the underlying Cache
+func (c *Cache) Close() error {
+	unregister(c)
+	return c.Cache.Close()
+}
+
+// claimProbe returns true if the store may be attempted as a probe of a cache in automatic
+// read-only mode, which is permitted once per probe interval
+func (c *Cache) claimProbe() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	now := time.Now()
+	if c.mode != ModeAuto || now.Before(c.nextProbe) {
+		return false
+	}
+	c.nextProbe = now.Add(c.probeInterval)
+	return true
+}
+
+// observeStore records the result of an attempted store, entering automatic read-only mode when
+// the store error rate in the window reaches the threshold, and exiting it when a probe succeeds
+func (c *Cache) observeStore(err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.mode != ModeAuto {
+		return
+	}
+	now := time.Now()
+	if c.ReadOnly() {
+		if err == nil {
+			c.resetWindow(now)
+			c.setReadOnly(false, now)
+			c.logger.Info("cache exited read-only mode", tl.Pairs{"cacheName": c.name,
+				"reason": "probe succeeded", "readOnlyDuration": now.Sub(c.since).String()})
+		} else {
+			c.logger.Debug("cache read-only mode probe failed",
+				tl.Pairs{"cacheName": c.name, "detail": err.Error()})
+		}
+		return
+	}
+	if c.threshold <= 0 {
+		return
+	}
+	if now.Sub(c.windowStart) > c.window {
+		c.resetWindow(now)
+	}
+	c.stores++
+	if err != nil {
+		c.failures++
+	}
+	if c.stores >= c.minStores && float64(c.failures)/float64(c.stores) >= c.threshold {
+		c.logger.Warn("cache entered read-only mode", tl.Pairs{"cacheName": c.name,
+			"reason": "store errors", "failedStores": c.failures, "attemptedStores": c.stores,
+			"window": c.window.String()})
+		c.resetWindow(now)
+		c.nextProbe = now.Add(c.probeInterval)
+		c.setReadOnly(true, now)
+	}
+}
+
+func (c *Cache) resetWindow(now time.Time) {
+	c.windowStart = now
+	c.stores = 0
+	c.failures = 0
+}
+
+// setReadOnly enters or exits read-only mode. The caller must hold the lock
+func (c *Cache) setReadOnly(readOnly bool, now time.Time) {
+	var v int32
+	if readOnly {
+		v = 1
+		if !c.ReadOnly() {
+			c.since = now
+		}
+	}
+	atomic.StoreInt32(&c.readOnly, v)
+	metrics.ObserveCacheReadOnly(c.metrics, c.name, c.cacheType, readOnly)
+}
+
+// setMode sets the read-only mode. The caller must hold the lock, or have exclusive access
+func (c *Cache) setMode(mode string, now time.Time) {
+	c.mode = mode
+	c.resetWindow(now)
+	c.setReadOnly(mode == ModeReadOnly, now)
+}
+
+// SetMode sets the cache's read-only mode. Setting ModeAuto exits read-only mode, which is
+// entered again if the cache continues to fail its stores
+func (c *Cache) SetMode(mode string) error {
+	if mode != ModeAuto && mode != ModeReadOnly && mode != ModeReadWrite {
+		return ErrInvalidMode
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.setMode(mode, time.Now())
+	return nil
+}
+
+// UpdateOptions applies the read-only options of a reloaded config, which are compared to the
+// options the cache was configured with, so that a mode set with SetMode is only replaced when
+// the configured mode changes
+func (c *Cache) UpdateOptions(o *options.Options) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.updateOptions(o)
+	if o.ReadOnly != c.configReadOnly {
+		c.configReadOnly = o.ReadOnly
+		mode := ModeAuto
+		if o.ReadOnly {
+			mode = ModeReadOnly
+		}
+		c.setMode(mode, time.Now())
+	}
+}
+
+func (c *Cache) updateOptions(o *options.Options) {
+	c.threshold = o.ReadOnlyErrorThreshold
+	c.window = o.ReadOnlyWindow
+	c.minStores = o.ReadOnlyMinStores
+	c.probeInterval = o.ReadOnlyProbeInterval
+}
+
+// Status returns the cache's read-only status
+func (c *Cache) Status() *Status {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	s := &Status{Cache: c.name, Mode: c.mode, ReadOnly: c.ReadOnly(),
+		SkippedStores: atomic.LoadUint64(&c.skipped)}
+	if s.ReadOnly {
+		since := c.since
+		s.Since = &since
+	}
+	return s
+}
+
+// caches holds the registered caches of each name, in the order they were registered, so that
+// closing a cache that was never put in use, such as one of a rejected config, restores the
+// cache that it replaced
+var caches = make(map[string][]*Cache)
+var cachesLock sync.RWMutex
+
+func register(c *Cache) {
+	cachesLock.Lock()
+	caches[c.name] = append(caches[c.name], c)
+	cachesLock.Unlock()
+}
+
+// unregister removes the cache from the registry. When it is the latest cache of its name, the
+// cache that it replaced, if still open, is registered under the name again
+func unregister(c *Cache) {
+	cachesLock.Lock()
+	defer cachesLock.Unlock()
+	l := caches[c.name]
+	for i, v := range l {
+		if v != c {
+			continue
+		}
+		l = append(l[:i:i], l[i+1:]...)
+		if len(l) == 0 {
+			delete(caches, c.name)
+			return
+		}
+		caches[c.name] = l
+		if i == len(l) {
+			prev := l[i-1]
+			metrics.ObserveCacheReadOnly(prev.metrics, prev.name, prev.cacheType, prev.ReadOnly())
+		}
+		return
+	}
+}
+
+// Lookup returns the registered cache of the name
+func Lookup(name string) (*Cache, bool) {
+	cachesLock.RLock()
+	defer cachesLock.RUnlock()
+	l, ok := caches[name]
+	if !ok {
+		return nil, false
+	}
+	return l[len(l)-1], true
+}
+
+// Statuses returns the read-only status of each registered cache, sorted by name
+func Statuses() []*Status {
+	cachesLock.RLock()
+	out := make([]*Status, 0, len(caches))
+	for _, l := range caches {
+		out = append(out, l[len(l)-1].Status())
+	}
+	cachesLock.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Cache < out[j].Cache })
+	return out
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package readonly
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+var errTestStore = errors.New("test store error")
+
+// testCache is a Cache whose stores fail while err is set
+type testCache struct {
+	cache.Cache
+	cfg    *options.Options
+	err    error
+	stores int
+	ttls   int
+}
+
+func (c *testCache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	c.stores++
+	return c.err
+}
+
+func (c *testCache) SetTTL(cacheKey string, ttl time.Duration) { c.ttls++ }
+func (c *testCache) Configuration() *options.Options           { return c.cfg }
+func (c *testCache) Close() error                              { return nil }
+
+func newTestCache(name string) *testCache {
+	cfg := options.NewOptions()
+	cfg.Name = name
+	cfg.CacheType = "redis"
+	cfg.ReadOnlyErrorThreshold = 0.5
+	cfg.ReadOnlyMinStores = 4
+	return &testCache{cfg: cfg}
+}
+
+func TestAutoReadOnly(t *testing.T) {
+
+	tc := newTestCache("test-auto")
+	c := Wrap("test-auto", tc, tl.ConsoleLogger("error"))
+	defer c.Close()
+
+	store := func() error { return c.Store("key", []byte("value"), time.Minute) }
+
+	// the error rate is not evaluated until the minimum number of stores are attempted
+	tc.err = errTestStore
+	for i := 0; i < 3; i++ {
+		if err := store(); err != errTestStore {
+			t.Errorf("expected %v got %v", errTestStore, err)
+		}
+	}
+	if c.ReadOnly() {
+		t.Fatal("expected cache to be writable")
+	}
+	store()
+	if !c.ReadOnly() {
+		t.Fatal("expected cache to be read-only")
+	}
+
+	// stores are skipped until the probe interval elapses
+	if err := store(); err != cache.ErrReadOnly {
+		t.Errorf("expected %v got %v", cache.ErrReadOnly, err)
+	}
+	c.SetTTL("key", time.Minute)
+	if tc.stores != 4 || tc.ttls != 0 {
+		t.Errorf("expected %d stores and %d ttls got %d and %d", 4, 0, tc.stores, tc.ttls)
+	}
+	if s := c.Status(); s.SkippedStores != 1 || s.Since == nil || s.Mode != ModeAuto {
+		t.Errorf("unexpected status %+v", s)
+	}
+
+	// a failed probe remains read-only
+	c.mtx.Lock()
+	c.nextProbe = time.Now()
+	c.mtx.Unlock()
+	if err := store(); err != errTestStore {
+		t.Errorf("expected %v got %v", errTestStore, err)
+	}
+	if !c.ReadOnly() {
+		t.Fatal("expected cache to be read-only")
+	}
+	if err := store(); err != cache.ErrReadOnly {
+		t.Errorf("expected %v got %v", cache.ErrReadOnly, err)
+	}
+
+	// a successful probe exits read-only mode
+	tc.err = nil
+	c.mtx.Lock()
+	c.nextProbe = time.Now()
+	c.mtx.Unlock()
+	if err := store(); err != nil {
+		t.Error(err)
+	}
+	if c.ReadOnly() {
+		t.Fatal("expected cache to be writable")
+	}
+	if s := c.Status(); s.Since != nil {
+		t.Errorf("unexpected status %+v", s)
+	}
+}
+
+func TestSetMode(t *testing.T) {
+
+	tc := newTestCache("test-mode")
+	tc.cfg.ReadOnly = true
+	c := Wrap("test-mode", tc, tl.ConsoleLogger("error"))
+	defer c.Close()
+
+	if !c.ReadOnly() || c.Status().Mode != ModeReadOnly {
+		t.Fatal("expected cache to be read-only")
+	}
+	// a forced read-only cache is not probed
+	c.mtx.Lock()
+	c.nextProbe = time.Now()
+	c.mtx.Unlock()
+	if err := c.Store("key", []byte("value"), time.Minute); err != cache.ErrReadOnly {
+		t.Errorf("expected %v got %v", cache.ErrReadOnly, err)
+	}
+
+	if err := c.SetMode("invalid"); err != ErrInvalidMode {
+		t.Errorf("expected %v got %v", ErrInvalidMode, err)
+	}
+
+	// a forced writable cache does not enter read-only mode
+	if err := c.SetMode(ModeReadWrite); err != nil {
+		t.Fatal(err)
+	}
+	tc.err = errTestStore
+	for i := 0; i < 10; i++ {
+		c.Store("key", []byte("value"), time.Minute)
+	}
+	if c.ReadOnly() {
+		t.Error("expected cache to be writable")
+	}
+
+	c.SetMode(ModeAuto)
+	for i := 0; i < 4; i++ {
+		c.Store("key", []byte("value"), time.Minute)
+	}
+	if !c.ReadOnly() {
+		t.Error("expected cache to be read-only")
+	}
+
+	// a reloaded config replaces the mode only when the configured mode changes
+	cfg := tc.cfg.Clone()
+	c.UpdateOptions(cfg)
+	if c.Status().Mode != ModeAuto {
+		t.Errorf("expected %s got %s", ModeAuto, c.Status().Mode)
+	}
+	cfg.ReadOnly = false
+	c.UpdateOptions(cfg)
+	if c.ReadOnly() || c.Status().Mode != ModeAuto {
+		t.Errorf("unexpected status %+v", c.Status())
+	}
+}
+
+func TestRegistry(t *testing.T) {
+
+	c1 := Wrap("test-registry", newTestCache("test-registry"), tl.ConsoleLogger("error"))
+	c2 := Wrap("test-registry", newTestCache("test-registry"), tl.ConsoleLogger("error"))
+
+	// a closed cache does not unregister the cache that replaced it
+	c1.Close()
+	if c, ok := Lookup("test-registry"); !ok || c != c2 {
+		t.Error("expected the replacing cache to be registered")
+	}
+	found := false
+	for _, s := range Statuses() {
+		if s.Cache == "test-registry" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected status for test-registry")
+	}
+
+	// a closed cache that replaced another restores the cache it replaced
+	c3 := Wrap("test-registry", newTestCache("test-registry"), tl.ConsoleLogger("error"))
+	c3.Close()
+	if c, ok := Lookup("test-registry"); !ok || c != c2 {
+		t.Error("expected the replaced cache to be registered")
+	}
+
+	c2.Close()
+	if _, ok := Lookup("test-registry"); ok {
+		t.Error("expected cache to be unregistered")
+	}
+}
@@ -45,6 +45,19 @@ type Cache struct {
 	closer func() error
 }
 
+func init() {
+	cache.MustRegisterProvider("redis", newProviderCache)
+}
+
+func newProviderCache(name string, cfg *options.Options, logger *tl.Logger) cache.Cache {
+	return &Cache{Name: name, Config: cfg, Logger: logger}
+}
+
+// ReportsMetrics returns true, since the Cache records its own operation metrics
+func (c *Cache) ReportsMetrics() bool {
+	return true
+}
+
 // Locker returns the cache's locker
 func (c *Cache) Locker() locks.NamedLocker {
 	return c.locker
@@ -96,7 +109,7 @@ func (c *Cache) Connect() error {
 
 // Store places the the data into the Redis Cache using the provided Key and TTL
 func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
-	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+	metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
 	c.Logger.Debug("redis cache store", tl.Pairs{"key": cacheKey})
 	return c.client.Set(cacheKey, data, ttl).Err()
 }
@@ -109,18 +122,18 @@ func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.Loo
 	if err == nil {
 		data := []byte(res)
 		c.Logger.Debug("redis cache retrieve", tl.Pairs{"key": cacheKey})
-		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+		metrics.ObserveCacheOperation(c.Config.Metrics, c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
 		return data, status.LookupStatusHit, nil
 	}
 
 	if err == redis.Nil {
 		c.Logger.Debug("redis cache miss", tl.Pairs{"key": cacheKey})
-		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		metrics.ObserveCacheMiss(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType)
 		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
 	}
 
 	c.Logger.Debug("redis cache retrieve failed", tl.Pairs{"key": cacheKey, "reason": err.Error()})
-	metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+	metrics.ObserveCacheMiss(c.Config.Metrics, cacheKey, c.Name, c.Config.CacheType)
 	return nil, status.LookupStatusError, err
 }
 
@@ -128,7 +141,7 @@ func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.Loo
 func (c *Cache) Remove(cacheKey string) {
 	c.Logger.Debug("redis cache remove", tl.Pairs{"key": cacheKey})
 	c.client.Del(cacheKey)
-	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+	metrics.ObserveCacheDel(c.Config.Metrics, c.Name, c.Config.CacheType, 0)
 }
 
 // SetTTL updates the TTL for the provided cache object
@@ -140,11 +153,14 @@ func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
 func (c *Cache) BulkRemove(cacheKeys []string) {
 	c.Logger.Debug("redis cache bulk remove", tl.Pairs{})
 	c.client.Del(cacheKeys...)
-	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, float64(len(cacheKeys)))
+	metrics.ObserveCacheDel(c.Config.Metrics, c.Name, c.Config.CacheType, float64(len(cacheKeys)))
 }
 
 // Close disconnects from the Redis Cache
 func (c *Cache) Close() error {
+	if c.closer == nil {
+		return nil
+	}
 	c.Logger.Info("closing redis connection", tl.Pairs{})
 	return c.closer()
 }
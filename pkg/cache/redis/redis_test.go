@@ -371,7 +371,13 @@ func TestRedisCache_Close(t *testing.T) {
 	rc, close := setupRedisCache(clientTypeStandard)
 	defer close()
 
-	err := rc.Connect()
+	// an unconnected cache should close
+	err := rc.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = rc.Connect()
 	if err != nil {
 		t.Error(err)
 	}
@@ -19,24 +19,22 @@
 package registration
 
 import (
+	"time"
+
 	"github.com/tricksterproxy/trickster/pkg/cache"
-	"github.com/tricksterproxy/trickster/pkg/cache/badger"
-	"github.com/tricksterproxy/trickster/pkg/cache/bbolt"
-	"github.com/tricksterproxy/trickster/pkg/cache/filesystem"
 	"github.com/tricksterproxy/trickster/pkg/cache/memory"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
 	"github.com/tricksterproxy/trickster/pkg/cache/options"
-	"github.com/tricksterproxy/trickster/pkg/cache/redis"
+	"github.com/tricksterproxy/trickster/pkg/cache/readonly"
 	"github.com/tricksterproxy/trickster/pkg/config"
 	"github.com/tricksterproxy/trickster/pkg/locks"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
-)
 
-// Cache Interface Types
-const (
-	ctFilesystem = "filesystem"
-	ctRedis      = "redis"
-	ctBBolt      = "bbolt"
-	ctBadger     = "badger"
+	// the built-in cache providers register themselves with the cache package
+	_ "github.com/tricksterproxy/trickster/pkg/cache/badger"
+	_ "github.com/tricksterproxy/trickster/pkg/cache/bbolt"
+	_ "github.com/tricksterproxy/trickster/pkg/cache/filesystem"
+	_ "github.com/tricksterproxy/trickster/pkg/cache/redis"
 )
 
 // Caches maintains a list of active caches
@@ -70,26 +68,53 @@ func CloseCaches(caches map[string]cache.Cache) error {
 	return nil
 }
 
-// NewCache returns a Cache object based on the provided config.CachingConfig
+// NewCache returns a connected Cache object based on the provided config.CachingConfig, from the
+// provider registered for its cache type
 func NewCache(cacheName string, cfg *options.Options, logger *tl.Logger) cache.Cache {
+	c := New(cacheName, cfg, logger)
+	Connect(c, logger)
+	return c
+}
+
+// New returns a Cache object based on the provided config.CachingConfig, from the provider registered
+// for its cache type, which must be connected with Connect before use
+func New(cacheName string, cfg *options.Options, logger *tl.Logger) cache.Cache {
 
 	var c cache.Cache
 
-	switch cfg.CacheType {
-	case ctFilesystem:
-		c = &filesystem.Cache{Name: cacheName, Config: cfg, Logger: logger}
-	case ctRedis:
-		c = &redis.Cache{Name: cacheName, Config: cfg, Logger: logger}
-	case ctBBolt:
-		c = &bbolt.Cache{Name: cacheName, Config: cfg, Logger: logger}
-	case ctBadger:
-		c = &badger.Cache{Name: cacheName, Config: cfg, Logger: logger}
-	default:
+	if f, ok := cache.LookupProvider(cfg.CacheType); ok {
+		c = f(cacheName, cfg, logger)
+	} else {
 		// Default to MemoryCache
 		c = &memory.Cache{Name: cacheName, Config: cfg, Logger: logger}
 	}
 
-	c.SetLocker(locks.NewNamedLocker())
-	c.Connect()
+	// providers that do not record their own metrics are instrumented here, so that
+	// their operations are reported identically to those of the built-in caches
+	if mr, ok := c.(cache.MetricsReporter); !ok || !mr.ReportsMetrics() {
+		c = metrics.Instrument(cacheName, c)
+	}
+
+	// the memory cache cannot fail its stores, and is retrieved from by reference, so
+	// only the other caches can be placed in read-only mode
+	if cfg.CacheType != "memory" {
+		c = readonly.Wrap(cacheName, c, logger)
+	}
+
+	c.SetLocker(locks.NewObservedNamedLocker(func(mode string, wait time.Duration) {
+		metrics.ObserveCacheLockWait(cfg.Metrics, cacheName, cfg.CacheType, mode, wait)
+	}))
 	return c
 }
+
+// Connect connects the Cache object, logging and returning any connection error
+func Connect(c cache.Cache, logger *tl.Logger) error {
+	cfg := c.Configuration()
+	if err := c.Connect(); err != nil {
+		logger.Error("cache connection failed",
+			tl.Pairs{"cacheName": cfg.Name, "cacheType": cfg.CacheType, "detail": err.Error()})
+		return err
+	}
+	logger.Info("cache ready", tl.Pairs{"cacheName": cfg.Name, "cacheType": cfg.CacheType})
+	return nil
+}
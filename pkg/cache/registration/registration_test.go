@@ -19,14 +19,20 @@ package registration
 import (
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	bao "github.com/tricksterproxy/trickster/pkg/cache/badger/options"
 	bbo "github.com/tricksterproxy/trickster/pkg/cache/bbolt/options"
 	flo "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
 	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/memory"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
 	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/readonly"
 	ro "github.com/tricksterproxy/trickster/pkg/cache/redis/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/testdata/examplecache"
 	"github.com/tricksterproxy/trickster/pkg/cache/types"
 	"github.com/tricksterproxy/trickster/pkg/config"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
@@ -74,6 +80,86 @@ func TestLoadCachesFromConfig(t *testing.T) {
 
 }
 
+const testExternalProviderConf = `
+[caches]
+    [caches.test]
+    cache_type = 'example'
+
+[origins]
+    [origins.test]
+    origin_type = 'rpc'
+    origin_url = 'http://1'
+    cache_name = 'test'
+`
+
+func TestLoadCachesFromConfigExternalProvider(t *testing.T) {
+
+	f, err := ioutil.TempFile("", "trickster-test-*.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString(testExternalProviderConf); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	args := []string{"-config", f.Name()}
+
+	// configurations loaded before the provider is registered can't reference its cache type
+	_, _, err = config.Load("trickster", "test", args)
+	if err == nil || !strings.Contains(err.Error(), "invalid cache type [example]") {
+		t.Fatalf("expected invalid cache type error got %v", err)
+	}
+
+	if err = examplecache.Register(); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, _, err := config.Load("trickster", "test", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caches := LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer CloseCaches(caches)
+
+	c, ok := caches["test"]
+	if !ok {
+		t.Fatal("could not find the configuration for test")
+	}
+	rc, ok := c.(*readonly.Cache)
+	if !ok {
+		t.Fatalf("expected read-only cache got %T", c)
+	}
+	ic, ok := rc.Unwrap().(*metrics.InstrumentedCache)
+	if !ok {
+		t.Fatalf("expected instrumented cache got %T", rc.Unwrap())
+	}
+	if _, ok := ic.Unwrap().(*examplecache.Cache); !ok {
+		t.Errorf("expected example cache got %T", ic.Unwrap())
+	}
+	if c.Locker() == nil {
+		t.Error("expected cache locker")
+	}
+
+	if err = c.Store("test-key", []byte("test-value"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	b, _, err := c.Retrieve("test-key", false)
+	if err != nil {
+		t.Error(err)
+	} else if string(b) != "test-value" {
+		t.Errorf("expected %s got %s", "test-value", string(b))
+	}
+
+	// the built-in caches report their own metrics and are not wrapped
+	mc := NewCache("test-memory", newCacheConfig(t, "memory"), tl.ConsoleLogger("error"))
+	defer mc.Close()
+	if _, ok := mc.(*memory.Cache); !ok {
+		t.Errorf("expected memory cache got %T", mc)
+	}
+}
+
 func newCacheConfig(t *testing.T, cacheType string) *co.Options {
 
 	bd := "."
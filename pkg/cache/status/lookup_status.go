@@ -52,17 +52,18 @@ const (
 )
 
 var cacheLookupStatusNames = map[string]LookupStatus{
-	"hit":         LookupStatusHit,
-	"phit":        LookupStatusPartialHit,
-	"rhit":        LookupStatusRevalidated,
-	"rmiss":       LookupStatusRangeMiss,
-	"kmiss":       LookupStatusKeyMiss,
-	"purge":       LookupStatusPurge,
-	"proxy-error": LookupStatusProxyError,
-	"proxy-only":  LookupStatusProxyOnly,
-	"nchit":       LookupStatusNegativeCacheHit,
-	"proxy-hit":   LookupStatusProxyHit,
-	"error":       LookupStatusError,
+	"hit":          LookupStatusHit,
+	"phit":         LookupStatusPartialHit,
+	"rhit":         LookupStatusRevalidated,
+	"rmiss":        LookupStatusRangeMiss,
+	"kmiss":        LookupStatusKeyMiss,
+	"purge":        LookupStatusPurge,
+	"proxy-error":  LookupStatusProxyError,
+	"proxy-only":   LookupStatusProxyOnly,
+	"negative-hit": LookupStatusNegativeCacheHit,
+	"nchit":        LookupStatusNegativeCacheHit, // deprecated name, retained for parsing
+	"proxy-hit":    LookupStatusProxyHit,
+	"error":        LookupStatusError,
 }
 
 var cacheLookupStatusValues = map[LookupStatus]string{
@@ -74,7 +75,7 @@ var cacheLookupStatusValues = map[LookupStatus]string{
 	LookupStatusPurge:            "purge",
 	LookupStatusProxyError:       "proxy-error",
 	LookupStatusProxyOnly:        "proxy-only",
-	LookupStatusNegativeCacheHit: "nchit",
+	LookupStatusNegativeCacheHit: "negative-hit",
 	LookupStatusProxyHit:         "proxy-hit",
 	LookupStatusError:            "error",
 }
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package examplecache is an example of a cache provider maintained outside of Trickster,
+// which stores objects in a map. It does not record its own metrics, so Trickster
+// instruments it when it is created
+package examplecache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// CacheType is the cache type under which the example provider registers
+const CacheType = "example"
+
+// ensure the example provider conforms to the Cache interface at compile time
+var _ cache.Cache = (*Cache)(nil)
+var _ cache.ProviderFunc = NewCache
+
+// Register registers the example provider with Trickster. Providers would typically
+// register in their package's init() function, so that they are registered as soon as
+// they are imported
+func Register() error {
+	return cache.RegisterProvider(CacheType, NewCache)
+}
+
+type object struct {
+	value   []byte
+	expires time.Time
+}
+
+// Cache is the example cache
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Logger *tl.Logger
+
+	objects map[string]object
+	mtx     sync.Mutex
+	locker  locks.NamedLocker
+}
+
+// NewCache returns a new example cache for the named cache configuration
+func NewCache(name string, cfg *options.Options, logger *tl.Logger) cache.Cache {
+	return &Cache{Name: name, Config: cfg, Logger: logger}
+}
+
+// Connect initializes the Cache
+func (c *Cache) Connect() error {
+	c.objects = make(map[string]object)
+	return nil
+}
+
+// Store places an object in the cache using the specified key and ttl
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	c.mtx.Lock()
+	c.objects[cacheKey] = object{value: data, expires: time.Now().Add(ttl)}
+	c.mtx.Unlock()
+	return nil
+}
+
+// Retrieve gets an object from the cache using the specified key
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	c.mtx.Lock()
+	o, ok := c.objects[cacheKey]
+	c.mtx.Unlock()
+	if !ok || (!allowExpired && time.Now().After(o.expires)) {
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+	return o.value, status.LookupStatusHit, nil
+}
+
+// SetTTL updates the TTL for the provided cache object
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	c.mtx.Lock()
+	if o, ok := c.objects[cacheKey]; ok {
+		o.expires = time.Now().Add(ttl)
+		c.objects[cacheKey] = o
+	}
+	c.mtx.Unlock()
+}
+
+// Remove removes an object from the cache
+func (c *Cache) Remove(cacheKey string) {
+	c.mtx.Lock()
+	delete(c.objects, cacheKey)
+	c.mtx.Unlock()
+}
+
+// BulkRemove removes a list of objects from the cache
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.mtx.Lock()
+	for _, k := range cacheKeys {
+		delete(c.objects, k)
+	}
+	c.mtx.Unlock()
+}
+
+// Close closes the Cache
+func (c *Cache) Close() error {
+	return nil
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
@@ -16,7 +16,11 @@
 
 package types
 
-import "strconv"
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
 
 // CacheType enumerates the methodologies for maintaining time series cache data
 type CacheType int
@@ -34,6 +38,8 @@ const (
 	CacheTypeBadgerDB
 )
 
+var mtx sync.RWMutex
+
 // Names is a map of cache types keyed by name
 var Names = map[string]CacheType{
 	"memory":     CacheTypeMemory,
@@ -53,8 +59,51 @@ func init() {
 }
 
 func (t CacheType) String() string {
-	if v, ok := Values[t]; ok {
+	mtx.RLock()
+	v, ok := Values[t]
+	mtx.RUnlock()
+	if ok {
 		return v
 	}
 	return strconv.Itoa(int(t))
 }
+
+// Register adds name to the list of known cache types and returns its id. If the name
+// is already known, its existing id is returned
+func Register(name string) CacheType {
+	mtx.Lock()
+	defer mtx.Unlock()
+	if t, ok := Names[name]; ok {
+		return t
+	}
+	t := CacheType(len(Names))
+	for {
+		if _, ok := Values[t]; !ok {
+			break
+		}
+		t++
+	}
+	Names[name] = t
+	Values[t] = name
+	return t
+}
+
+// Lookup returns the id of the cache type with the provided name, and true if it is known
+func Lookup(name string) (CacheType, bool) {
+	mtx.RLock()
+	t, ok := Names[name]
+	mtx.RUnlock()
+	return t, ok
+}
+
+// NamesList returns the sorted list of known cache type names
+func NamesList() []string {
+	mtx.RLock()
+	l := make([]string, 0, len(Names))
+	for k := range Names {
+		l = append(l, k)
+	}
+	mtx.RUnlock()
+	sort.Strings(l)
+	return l
+}
@@ -39,3 +39,26 @@ func TestCacheTypeString(t *testing.T) {
 	}
 
 }
+
+func TestRegister(t *testing.T) {
+
+	if v := Register("filesystem"); v != CacheTypeFilesystem {
+		t.Errorf("expected %d got %d", CacheTypeFilesystem, v)
+	}
+
+	v := Register("test-register")
+	if v <= CacheTypeBadgerDB {
+		t.Errorf("expected new cache type id, got %d", v)
+	}
+	if v.String() != "test-register" {
+		t.Errorf("expected %s got %s", "test-register", v.String())
+	}
+	if n, ok := Lookup("test-register"); !ok || n != v {
+		t.Errorf("expected %d got %d", v, n)
+	}
+
+	l := NamesList()
+	if len(l) != 6 || l[0] != "badger" || l[5] != "test-register" {
+		t.Errorf("unexpected names list %v", l)
+	}
+}
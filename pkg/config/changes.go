@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// OriginChanges are the names of the origins of a config, by how they changed from those of the
+// config it replaces
+type OriginChanges struct {
+	Added     []string
+	Removed   []string
+	Changed   []string
+	Unchanged []string
+}
+
+// OriginChanges returns the changes of the origins of the subject config from those of the old
+// config. An origin is unchanged when all of its configured options are identical, and so its
+// upstream client can be kept when the subject config replaces the old one. An origin whose
+// client loads TLS certificate files is always changed, since the files may have been replaced
+// at the same paths, such as when certificates are rotated
+func (c *Config) OriginChanges(old *Config) *OriginChanges {
+	oc := &OriginChanges{}
+	for k, o := range c.Origins {
+		var p interface{}
+		if old != nil {
+			if po, ok := old.Origins[k]; ok {
+				p = po
+			}
+		}
+		switch {
+		case p == nil:
+			oc.Added = append(oc.Added, k)
+		case o.TLS != nil && (o.TLS.ClientCertPath != "" || o.TLS.ClientKeyPath != "" ||
+			len(o.TLS.CertificateAuthorityPaths) > 0):
+			oc.Changed = append(oc.Changed, k)
+		case configuredEqual(reflect.ValueOf(o), reflect.ValueOf(p)):
+			oc.Unchanged = append(oc.Unchanged, k)
+		default:
+			oc.Changed = append(oc.Changed, k)
+		}
+	}
+	if old != nil {
+		for k := range old.Origins {
+			if _, ok := c.Origins[k]; !ok {
+				oc.Removed = append(oc.Removed, k)
+			}
+		}
+	}
+	for _, l := range [][]string{oc.Added, oc.Removed, oc.Changed, oc.Unchanged} {
+		sort.Strings(l)
+	}
+	return oc
+}
+
+// configuredEqual returns true if the values are equal in their configured options, which are
+// the fields with TOML keys, so that the runtime state of the options, such as their handlers
+// and clients, is not compared
+func configuredEqual(a, b reflect.Value) bool {
+	if a.Kind() != b.Kind() || a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Ptr:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return configuredEqual(a.Elem(), b.Elem())
+	case reflect.Struct:
+		if a.Type() == durationType {
+			return a.Int() == b.Int()
+		}
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := strings.Split(f.Tag.Get("toml"), ",")[0]
+			if key == "" || key == "-" || f.PkgPath != "" {
+				continue
+			}
+			if !configuredEqual(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for _, k := range a.MapKeys() {
+			v := b.MapIndex(k)
+			if !v.IsValid() || !configuredEqual(a.MapIndex(k), v) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !configuredEqual(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return configuredEqual(a.Elem(), b.Elem())
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return true
+	}
+	return a.Interface() == b.Interface()
+}
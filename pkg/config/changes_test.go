@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestOriginChanges(t *testing.T) {
+
+	a := []string{"-config", "../../testdata/test.multiple_origins.conf"}
+	old, _, err := Load("trickster-test", "0", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, _, err := Load("trickster-test", "0", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oc := conf.OriginChanges(nil)
+	if !reflect.DeepEqual(oc.Added, []string{"test", "test2"}) || len(oc.Removed) != 0 ||
+		len(oc.Changed) != 0 || len(oc.Unchanged) != 0 {
+		t.Errorf("unexpected changes from no config: %+v", oc)
+	}
+
+	// the runtime state of the origins is not compared
+	old.Origins["test"].HTTPClient = &http.Client{}
+	oc = conf.OriginChanges(old)
+	if !reflect.DeepEqual(oc.Unchanged, []string{"test", "test2"}) || len(oc.Added) != 0 ||
+		len(oc.Removed) != 0 || len(oc.Changed) != 0 {
+		t.Errorf("unexpected changes from the same config: %+v", oc)
+	}
+
+	// an origin whose client loads TLS certificate files is changed, so that its client
+	// loads them again
+	conf.Origins["test2"].TLS.CertificateAuthorityPaths = []string{"/etc/trickster/ca.pem"}
+	old.Origins["test2"].TLS.CertificateAuthorityPaths = []string{"/etc/trickster/ca.pem"}
+	oc = conf.OriginChanges(old)
+	if !reflect.DeepEqual(oc.Changed, []string{"test2"}) ||
+		!reflect.DeepEqual(oc.Unchanged, []string{"test"}) {
+		t.Errorf("unexpected changes of a TLS origin: %+v", oc)
+	}
+	conf.Origins["test2"].TLS.CertificateAuthorityPaths = nil
+
+	for _, p := range conf.Origins["test2"].Paths {
+		p.NoMetrics = !p.NoMetrics
+	}
+	conf.Origins["test3"] = conf.Origins["test"].Clone()
+	delete(conf.Origins, "test")
+	oc = conf.OriginChanges(old)
+	expected := &OriginChanges{
+		Added:   []string{"test3"},
+		Removed: []string{"test"},
+		Changed: []string{"test2"},
+	}
+	if !reflect.DeepEqual(oc, expected) {
+		t.Errorf("expected %+v got %+v", expected, oc)
+	}
+}
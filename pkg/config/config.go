@@ -21,11 +21,13 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -35,15 +37,25 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/cache/types"
 	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
 	reload "github.com/tricksterproxy/trickster/pkg/config/reload/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
+	"github.com/tricksterproxy/trickster/pkg/proxy/estimate"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
-	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	healthcheck "github.com/tricksterproxy/trickster/pkg/proxy/healthcheck/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/lookback"
 	origins "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	rule "github.com/tricksterproxy/trickster/pkg/proxy/origins/rule/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/partial"
 	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight/policy"
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
+	"github.com/tricksterproxy/trickster/pkg/proxy/ratelimit"
+	refresh "github.com/tricksterproxy/trickster/pkg/proxy/refresh/options"
 	rewriter "github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
 	rwopts "github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter/options"
 	to "github.com/tricksterproxy/trickster/pkg/proxy/tls/options"
 	tracing "github.com/tricksterproxy/trickster/pkg/tracing/options"
+	mwopts "github.com/tricksterproxy/trickster/pkg/util/middleware/options"
+	"github.com/tricksterproxy/trickster/pkg/util/sha1"
 
 	"github.com/BurntSushi/toml"
 )
@@ -62,6 +74,8 @@ type Config struct {
 	Logging *LoggingConfig `toml:"logging"`
 	// Metrics provides configurations for collecting Metrics about the application
 	Metrics *MetricsConfig `toml:"metrics"`
+	// GRPCHealth provides configurations for the gRPC Health listener
+	GRPCHealth *GRPCHealthConfig `toml:"grpc_health"`
 	// TracingConfigs provides the distributed tracing configuration
 	TracingConfigs map[string]*tracing.Options `toml:"tracing"`
 	// NegativeCacheConfigs is a map of NegativeCacheConfigs
@@ -70,6 +84,10 @@ type Config struct {
 	Rules map[string]*rule.Options `toml:"rules"`
 	// RequestRewriters is a map of the Rewriters
 	RequestRewriters map[string]*rwopts.Options `toml:"request_rewriters"`
+	// RefreshJobs is a map of the Refresh Jobs, which keep designated queries fresh in the cache
+	RefreshJobs map[string]*refresh.Options `toml:"refresh_jobs"`
+	// Middlewares is a map of the named Middleware instances that Paths can reference
+	Middlewares map[string]*mwopts.Options `toml:"middlewares"`
 	// ReloadConfig provides configurations for in-process config reloading
 	ReloadConfig *reload.Options `toml:"reloading"`
 
@@ -92,21 +110,59 @@ type MainConfig struct {
 	ConfigHandlerPath string `toml:"config_handler_path"`
 	// PingHandlerPath provides the path to register the Ping Handler for checking that Trickster is running
 	PingHandlerPath string `toml:"ping_handler_path"`
+	// ReadyHandlerPath provides the path to register the Readiness Handler, which reports the
+	// results of the startup pre-flight checks
+	ReadyHandlerPath string `toml:"ready_handler_path"`
+	// PreflightTimeoutMS is the maximum time each startup pre-flight check waits for its component
+	PreflightTimeoutMS int `toml:"preflight_timeout_ms"`
 	// ReloadHandlerPath provides the path to register the Config Reload Handler
 	ReloadHandlerPath string `toml:"reload_handler_path"`
 	// HeatlHandlerPath provides the base Health Check Handler path
 	HealthHandlerPath string `toml:"health_handler_path"`
+	// VersionHandlerPath provides the path to register the Version Handler for outputting
+	// the startup diagnostics of the running instance
+	VersionHandlerPath string `toml:"version_handler_path"`
 	// PprofServer provides the name of the http listener that will host the pprof debugging routes
 	// Options are: "metrics", "reload", "both", or "off"; default is both
 	PprofServer string `toml:"pprof_server"`
 	// ServerName represents the server name that is conveyed in Via headers to upstream origins
 	// defaults to os.Hostname
 	ServerName string `toml:"server_name"`
+	// TopQueriesSize is the number of query fingerprints whose costs are tracked for the Top Queries Handler
+	TopQueriesSize int `toml:"top_queries_size"`
+	// MaxInflightProcessingBytes is the estimated memory, in bytes, that requests may hold at once while
+	// processing upstream responses and cached documents. 0 is unlimited
+	MaxInflightProcessingBytes int64 `toml:"max_inflight_processing_bytes"`
+	// InflightProcessingTimeoutMS is the time a request waits for in-flight processing capacity
+	// before it is rejected
+	InflightProcessingTimeoutMS int `toml:"inflight_processing_timeout_ms"`
+	// RequestSamplingEnabled retains the metadata of recently-seen requests, which the Config Diff
+	// Handler evaluates against candidate configs
+	RequestSamplingEnabled bool `toml:"request_sampling_enabled"`
+	// RequestSamplingSize is the number of recently-seen requests retained when sampling is enabled
+	RequestSamplingSize int `toml:"request_sampling_size"`
+	// CacheEventsEnabled retains the most recent cache mutation events for the Cache Events Handler
+	CacheEventsEnabled bool `toml:"cache_events_enabled"`
+	// CacheEventsSize is the number of cache mutation events retained when they are enabled
+	CacheEventsSize int `toml:"cache_events_size"`
+	// CacheEventSink is the URL of a datagram sink to which each cache mutation event is written,
+	// of the form udp://host:port or unixgram:///path/to/socket
+	CacheEventSink string `toml:"cache_event_sink"`
+	// CacheEventLog is the path of a file to which each cache mutation event is written as a line
+	// of JSON. It is rotated according to the logging config's rotation options
+	CacheEventLog string `toml:"cache_event_log"`
 
 	// ReloaderLock is used to lock the config for reloading
 	ReloaderLock sync.Mutex `toml:"-"`
 
-	configFilePath      string
+	configFilePath string
+	configFileHash string
+	// envOverrides are the TOML key paths of the options set by environment variables, by the
+	// names of their variables
+	envOverrides map[string]string
+	// sources are the sources of the options that were not set by their defaults, by their
+	// TOML key paths
+	sources             map[string]string
 	configLastModified  time.Time
 	configRateLimitTime time.Time
 	stalenessCheckLock  sync.Mutex
@@ -124,6 +180,10 @@ type FrontendConfig struct {
 	TLSListenPort int `toml:"tls_listen_port"`
 	// ConnectionsLimit indicates how many concurrent front end connections trickster will handle at any time
 	ConnectionsLimit int `toml:"connections_limit"`
+	// MaxRequestURLBytes is the maximum length of a request URL, above which requests are rejected
+	MaxRequestURLBytes int `toml:"max_request_url_bytes"`
+	// MaxRequestBodyBytes is the maximum size of a request body, above which requests are rejected
+	MaxRequestBodyBytes int `toml:"max_request_body_bytes"`
 
 	// ServeTLS indicates whether to listen and serve on the TLS port, meaning
 	// at least one origin configuration has a valid certificate and key file configured.
@@ -132,10 +192,112 @@ type FrontendConfig struct {
 
 // LoggingConfig is a collection of Logging configurations
 type LoggingConfig struct {
-	// LogFile provides the filepath to the instances's logfile. Set as empty string to Log to Console
+	// LogFile provides the filepath to the instances's logfile. Set as empty string to Log to Console,
+	// or as stdout, stderr, /dev/stdout or /dev/stderr to Log to that stream
 	LogFile string `toml:"log_file"`
+	// LogAlsoStdout indicates whether events are also written to Console when a LogFile is provided
+	LogAlsoStdout bool `toml:"log_also_stdout"`
+	// SplitStreams indicates whether warn, error and fatal events written to Console are written
+	// to STDERR, and events of other levels to STDOUT, rather than all events to STDOUT
+	SplitStreams bool `toml:"split_streams"`
 	// LogLevel provides the most granular level (e.g., DEBUG, INFO, ERROR) to log
 	LogLevel string `toml:"log_level"`
+	// LogLevelOverrides provides the log levels of subsystems, which override the LogLevel for
+	// the events sent from them. Each subsystem is a package path relative to pkg/, with its
+	// elements separated by dots, such as 'proxy.engines', and includes its subpackages
+	LogLevelOverrides map[string]string `toml:"log_level_overrides"`
+	// LogFormat provides the encoding of log events, either 'logfmt', 'json' or 'pretty', which is
+	// colorized for reading on Console, or 'gelf', in which case events are sent to the GELF
+	// endpoint at the GelfAddress
+	LogFormat string `toml:"log_format"`
+	// LogTimestampFormat provides the format of the time of log events, either a Go time layout,
+	// or one of 'rfc3339', 'rfc3339nano', 'epoch' or 'epoch_ms'. The default is 'rfc3339nano'
+	LogTimestampFormat string `toml:"log_timestamp_format"`
+	// LogIncludeHostname indicates whether the hostname is attached to each log event, and to
+	// each logfmt access log entry, along with the instance_id, when the InstanceID is set
+	LogIncludeHostname bool `toml:"log_include_hostname"`
+	// LogTimestampLocal indicates whether the time of log events is in the local time zone,
+	// rather than in UTC
+	LogTimestampLocal bool `toml:"log_timestamp_local"`
+	// LogMaxSizeMB provides the size in megabytes at which the logfile is rotated
+	LogMaxSizeMB int `toml:"log_max_size_mb"`
+	// LogMaxBackups provides the number of rotated logfiles to retain
+	LogMaxBackups int `toml:"log_max_backups"`
+	// LogMaxAgeDays provides the number of days to retain rotated logfiles
+	LogMaxAgeDays int `toml:"log_max_age_days"`
+	// LogCompress indicates whether rotated logfiles are compressed
+	LogCompress bool `toml:"log_compress"`
+	// LogRotation provides the rotator of the logfile, either 'internal', in which case Trickster
+	// rotates it, or 'external', in which case it is reopened on SIGUSR1 after an external rotation
+	LogRotation string `toml:"log_rotation"`
+	// LogOnceMaxEntries provides the maximum number of keys of once-per-key events that are
+	// remembered, after which the oldest are forgotten. 0 is unbounded
+	LogOnceMaxEntries int `toml:"log_once_max_entries"`
+	// LogOnceTTLSecs provides the number of seconds after which a once-per-key event may be
+	// logged again. 0 is never
+	LogOnceTTLSecs int `toml:"log_once_ttl_secs"`
+	// LogDedupWindowSecs provides the number of seconds for which repeats of an identical event
+	// are suppressed after it is logged, and then summarized in a single event. 0 is disabled
+	LogDedupWindowSecs int `toml:"log_dedup_window_secs"`
+	// LogAsyncBufferSize provides the number of encoded events that are buffered to be written to
+	// the LogFile or Console by a dedicated goroutine, rather than by the caller. When the buffer
+	// is full, the oldest buffered event is dropped. 0 writes events synchronously
+	LogAsyncBufferSize int `toml:"log_async_buffer_size"`
+	// LogTarget provides the destination of log events, either 'syslog', 'network', in which case
+	// they are written to the endpoint at the LogTargetAddress, or empty to write them to the
+	// LogFile, or to Console when no LogFile is provided
+	LogTarget string `toml:"log_target"`
+	// LogTargetNetwork provides the network of the endpoint of the network LogTarget, either
+	// 'tcp' or 'udp'
+	LogTargetNetwork string `toml:"log_target_network"`
+	// LogTargetAddress provides the address of the endpoint of the network LogTarget, such as
+	// the 'localhost:5000' of a Logstash TCP input
+	LogTargetAddress string `toml:"log_target_address"`
+	// LogTargetBufferSize provides the number of events that are buffered for the network
+	// LogTarget while it is reconnected, beyond which events are dropped
+	LogTargetBufferSize int `toml:"log_target_buffer_size"`
+	// SyslogNetwork provides the network of a remote syslog endpoint, such as 'udp' or 'tcp'.
+	// When empty, events are written to the local syslog server
+	SyslogNetwork string `toml:"syslog_network"`
+	// SyslogAddress provides the address of a remote syslog endpoint, such as 'localhost:514'
+	SyslogAddress string `toml:"syslog_address"`
+	// SyslogFacility provides the syslog facility of log events, such as 'daemon' or 'local0'
+	SyslogFacility string `toml:"syslog_facility"`
+	// SyslogTag provides the tag of log events written to syslog
+	SyslogTag string `toml:"syslog_tag"`
+	// GelfNetwork provides the network of the GELF endpoint of log events, either 'udp' or 'tcp'
+	GelfNetwork string `toml:"gelf_network"`
+	// GelfAddress provides the address of the GELF endpoint of log events, such as 'localhost:12201'
+	GelfAddress string `toml:"gelf_address"`
+	// RedactKeys provides the names of the log event keys, headers and URL query parameters whose
+	// values are redacted from log events and the access log
+	RedactKeys []string `toml:"redact_keys"`
+	// AccessLogFile provides the filepath of the access log, to which an entry is written for each
+	// proxied request. The access log is disabled when empty
+	AccessLogFile string `toml:"access_log_file"`
+	// AccessLogFormat provides the format of access log entries, either 'logfmt' or 'combined'
+	AccessLogFormat string `toml:"access_log_format"`
+	// AccessLogFlushIntervalMS provides the interval in milliseconds at which buffered access log
+	// entries are written to the access log file
+	AccessLogFlushIntervalMS int `toml:"access_log_flush_interval_ms"`
+	// LogLocale provides the human-readable fields that are added to log events
+	LogLocale LogLocaleConfig `toml:"log_locale"`
+}
+
+// LogLocaleConfig is a collection of configurations for the human-readable fields that are added
+// to log events, alongside their canonical fields, which are unchanged
+type LogLocaleConfig struct {
+	// Timezone provides the IANA name of the time zone of the local timestamp field added to each
+	// event. When empty, no local timestamp is added
+	Timezone string `toml:"timezone"`
+	// TimeField provides the name of the local timestamp field
+	TimeField string `toml:"time_field"`
+	// TranslationsFile provides the path to a TOML or JSON file that maps field names to tables of
+	// value translations. Each translated field is added to events as a display field. The file is
+	// reloaded when it changes
+	TranslationsFile string `toml:"translations_file"`
+	// DisplaySuffix provides the suffix of the names of display fields
+	DisplaySuffix string `toml:"display_suffix"`
 }
 
 // MetricsConfig is a collection of Metrics Collection configurations
@@ -146,6 +308,27 @@ type MetricsConfig struct {
 	ListenPort int `toml:"listen_port"`
 }
 
+// GRPCHealthConfig is a collection of configurations for the gRPC Health listener, which serves
+// the standard gRPC Health service and gRPC Server Reflection
+type GRPCHealthConfig struct {
+	// ListenAddress is IP address on which the gRPC Health listener is bound
+	ListenAddress string `toml:"listen_address"`
+	// ListenPort is TCP Port on which the gRPC Health listener is bound. The listener is
+	// disabled when ListenPort is 0, which is the default
+	ListenPort int `toml:"listen_port"`
+	// ServeTLS indicates that the gRPC Health listener serves TLS, using the certificates
+	// of the frontend TLS listener
+	ServeTLS bool `toml:"serve_tls"`
+}
+
+// Equal returns true if the GRPCHealthConfigs are identical
+func (gc *GRPCHealthConfig) Equal(gc2 *GRPCHealthConfig) bool {
+	if gc == nil || gc2 == nil {
+		return gc == gc2
+	}
+	return *gc == *gc2
+}
+
 // Resources is a collection of values used by configs at runtime that are not part of the config itself
 type Resources struct {
 	QuitChan chan bool `toml:"-"`
@@ -172,28 +355,58 @@ func NewConfig() *Config {
 			"default": cache.NewOptions(),
 		},
 		Logging: &LoggingConfig{
-			LogFile:  d.DefaultLogFile,
-			LogLevel: d.DefaultLogLevel,
+			LogFile:                  d.DefaultLogFile,
+			LogLevel:                 d.DefaultLogLevel,
+			LogFormat:                d.DefaultLogFormat,
+			LogMaxSizeMB:             d.DefaultLogMaxSizeMB,
+			LogRotation:              d.DefaultLogRotation,
+			LogMaxBackups:            d.DefaultLogMaxBackups,
+			LogMaxAgeDays:            d.DefaultLogMaxAgeDays,
+			LogCompress:              d.DefaultLogCompress,
+			SyslogFacility:           d.DefaultSyslogFacility,
+			RedactKeys:               d.DefaultLogRedactKeys(),
+			AccessLogFormat:          d.DefaultAccessLogFormat,
+			AccessLogFlushIntervalMS: d.DefaultAccessLogFlushIntervalMS,
+			SyslogTag:                d.DefaultSyslogTag,
+			GelfNetwork:              d.DefaultGelfNetwork,
+			GelfAddress:              d.DefaultGelfAddress,
+			LogTargetNetwork:         d.DefaultLogTargetNetwork,
+			LogTargetBufferSize:      d.DefaultLogTargetBufferSize,
+			LogLocale: LogLocaleConfig{
+				TimeField:     d.DefaultLogLocaleTimeField,
+				DisplaySuffix: d.DefaultLogLocaleDisplaySuffix,
+			},
 		},
 		Main: &MainConfig{
-			ConfigHandlerPath: d.DefaultConfigHandlerPath,
-			PingHandlerPath:   d.DefaultPingHandlerPath,
-			ReloadHandlerPath: d.DefaultReloadHandlerPath,
-			HealthHandlerPath: d.DefaultHealthHandlerPath,
-			PprofServer:       d.DefaultPprofServerName,
-			ServerName:        hn,
+			ConfigHandlerPath:  d.DefaultConfigHandlerPath,
+			PingHandlerPath:    d.DefaultPingHandlerPath,
+			ReadyHandlerPath:   d.DefaultReadyHandlerPath,
+			ReloadHandlerPath:  d.DefaultReloadHandlerPath,
+			HealthHandlerPath:  d.DefaultHealthHandlerPath,
+			VersionHandlerPath: d.DefaultVersionHandlerPath,
+			PprofServer:        d.DefaultPprofServerName,
+			ServerName:         hn,
+			TopQueriesSize:     d.DefaultTopQueriesSize,
+
+			InflightProcessingTimeoutMS: d.DefaultInflightProcessingTimeoutMS,
+			RequestSamplingSize:         d.DefaultRequestSamplingSize,
+			CacheEventsSize:             d.DefaultCacheEventsSize,
+			PreflightTimeoutMS:          d.DefaultPreflightTimeoutMS,
 		},
 		Metrics: &MetricsConfig{
 			ListenPort: d.DefaultMetricsListenPort,
 		},
+		GRPCHealth: &GRPCHealthConfig{},
 		Origins: map[string]*origins.Options{
 			"default": origins.NewOptions(),
 		},
 		Frontend: &FrontendConfig{
-			ListenPort:       d.DefaultProxyListenPort,
-			ListenAddress:    d.DefaultProxyListenAddress,
-			TLSListenPort:    d.DefaultTLSProxyListenPort,
-			TLSListenAddress: d.DefaultTLSProxyListenAddress,
+			ListenPort:          d.DefaultProxyListenPort,
+			ListenAddress:       d.DefaultProxyListenAddress,
+			TLSListenPort:       d.DefaultTLSProxyListenPort,
+			TLSListenAddress:    d.DefaultTLSProxyListenAddress,
+			MaxRequestURLBytes:  d.DefaultMaxRequestURLBytes,
+			MaxRequestBodyBytes: d.DefaultMaxRequestBodyBytes,
 		},
 		NegativeCacheConfigs: map[string]NegativeCacheConfig{
 			"default": NewNegativeCacheConfig(),
@@ -209,32 +422,100 @@ func NewConfig() *Config {
 	}
 }
 
+// Durations returns a map of the NegativeCacheConfig's status codes to their TTLs as time.Durations.
+// It assumes the config has already been validated
+func (nc NegativeCacheConfig) Durations() map[int]time.Duration {
+	m := make(map[int]time.Duration, len(nc))
+	for c, s := range nc {
+		ci, _ := strconv.Atoi(c)
+		m[ci] = time.Duration(s) * time.Second
+	}
+	return m
+}
+
 // NewNegativeCacheConfig returns an empty NegativeCacheConfig
 func NewNegativeCacheConfig() NegativeCacheConfig {
 	return NegativeCacheConfig{}
 }
 
-// loadFile loads application configuration from a TOML-formatted file.
+// loadFile loads application configuration from a TOML- or YAML-formatted file, with the options
+// named by environment variables overridden. When the default file does not exist, the
+// environment variables override the defaults
 func (c *Config) loadFile(flags *Flags) error {
 	b, err := ioutil.ReadFile(flags.ConfigPath)
 	if err != nil {
+		if !flags.customPath {
+			return c.loadEnvDocument("", &Flags{}, false)
+		}
 		c.setDefaults(&toml.MetaData{})
 		return err
 	}
-	return c.loadTOMLConfig(string(b), flags)
+	document := string(b)
+	yamlFile := isYAML(flags.ConfigPath, b)
+	if yamlFile {
+		if document, err = yamlToTOML(b); err != nil {
+			c.setDefaults(&toml.MetaData{})
+			return err
+		}
+	}
+	// the lines of the findings of a YAML file are unknown, since its document is converted
+	err = c.loadEnvDocument(document, flags, !yamlFile)
+	if err == nil {
+		c.Main.configFileHash = sha1.Checksum(string(b))
+	}
+	return err
 }
 
 // loadTOMLConfig loads application configuration from a TOML-formatted byte slice.
 func (c *Config) loadTOMLConfig(tml string, flags *Flags) error {
+	return c.loadDocument(tml, flags, true)
+}
+
+// loadEnvDocument loads application configuration from a TOML-formatted document, with the
+// options named by environment variables overridden in the document before its defaults are
+// set and its options are validated. The lines of its Findings are those of the document as
+// provided
+func (c *Config) loadEnvDocument(tml string, flags *Flags, withLines bool) error {
+	etml, overrides, err := envDocument(tml)
+	if err != nil {
+		c.setDefaults(&toml.MetaData{})
+		return err
+	}
+	if err = c.decodeDocument(etml, tml, flags, withLines); err != nil {
+		return err
+	}
+	if len(overrides) > 0 {
+		c.Main.envOverrides = overrides
+		for _, path := range overrides {
+			c.setSource(path, SourceEnv)
+		}
+	}
+	return nil
+}
+
+// loadDocument loads application configuration from a TOML-formatted byte slice, and adds its
+// Findings to the LoaderWarnings, with their lines in the document when withLines is true
+func (c *Config) loadDocument(tml string, flags *Flags, withLines bool) error {
+	return c.decodeDocument(tml, tml, flags, withLines)
+}
+
+// decodeDocument loads application configuration from the TOML-formatted document, and adds its
+// Findings to the LoaderWarnings, with their lines in the written document when withLines is true
+func (c *Config) decodeDocument(tml, written string, flags *Flags, withLines bool) error {
 	md, err := toml.Decode(tml, c)
 	if err != nil {
 		c.setDefaults(&toml.MetaData{})
 		return err
 	}
+	pathKeys := c.pathKeys()
 	err = c.setDefaults(&md)
 	if err == nil {
+		c.setFileSources(&md, pathKeys)
 		c.Main.configFilePath = flags.ConfigPath
 		c.Main.configLastModified = c.CheckFileLastModified()
+		for _, f := range c.findings(&md, written, withLines) {
+			c.LoaderWarnings = append(c.LoaderWarnings, f.String())
+		}
 	}
 	return err
 }
@@ -261,22 +542,48 @@ func (c *Config) setDefaults(metadata *toml.MetaData) error {
 		return err
 	}
 
+	if err = c.processLoggingConfig(); err != nil {
+		return err
+	}
+
 	if c.RequestRewriters != nil {
 		if c.CompiledRewriters, err = rewriter.ProcessConfigs(c.RequestRewriters); err != nil {
 			return err
 		}
 	}
 
+	if len(c.Middlewares) > 0 {
+		if err = mwopts.ProcessMiddlewareOptions(c.Middlewares, c.CompiledRewriters); err != nil {
+			return err
+		}
+	}
+
 	if err = c.processOriginConfigs(metadata); err != nil {
 		return err
 	}
 
 	tracing.ProcessTracingOptions(c.TracingConfigs, metadata)
 
+	if len(c.RefreshJobs) > 0 {
+		originNames := make(map[string]bool, len(c.Origins))
+		for k := range c.Origins {
+			originNames[k] = true
+		}
+		if err = refresh.ProcessRefreshJobOptions(c.RefreshJobs, metadata, originNames); err != nil {
+			return err
+		}
+	}
+
 	if err = c.processCachingConfigs(metadata); err != nil {
 		return err
 	}
 
+	if c.ReloadConfig != nil && len(c.ReloadConfig.AdminTokens) > 0 {
+		if err = reload.ProcessAdminTokens(c.ReloadConfig.AdminTokens); err != nil {
+			return err
+		}
+	}
+
 	if err = c.validateConfigMappings(); err != nil {
 		return err
 	}
@@ -302,6 +609,179 @@ func (c *Config) processPprofConfig() error {
 	return ErrInvalidPprofServerName
 }
 
+// ErrInvalidLogFormat returns an error for invalid log format
+var ErrInvalidLogFormat = errors.New("invalid log format")
+
+// ErrInvalidLogTimezone returns an error for an invalid log locale timezone
+var ErrInvalidLogTimezone = errors.New("invalid log locale timezone")
+
+// ErrInvalidLogTarget returns an error for an invalid log target
+var ErrInvalidLogTarget = errors.New("invalid log target")
+
+// ErrInvalidLogTargetNetwork returns an error for an invalid log target network
+var ErrInvalidLogTargetNetwork = errors.New("invalid log target network")
+
+// ErrMissingLogTargetAddress returns an error for a network log target without an address
+var ErrMissingLogTargetAddress = errors.New("missing log target address")
+
+// ErrInvalidLogLevelOverride returns an error for an invalid log level override
+var ErrInvalidLogLevelOverride = errors.New("invalid log level override")
+
+// ErrInvalidLogRotation returns an error for an invalid log rotation
+var ErrInvalidLogRotation = errors.New("invalid log rotation")
+
+// ErrInvalidAccessLogFormat returns an error for an invalid access log format
+var ErrInvalidAccessLogFormat = errors.New("invalid access log format")
+
+// ErrInvalidGelfNetwork returns an error for an invalid GELF network
+var ErrInvalidGelfNetwork = errors.New("invalid gelf network")
+
+// ErrInvalidSyslogFacility returns an error for an invalid syslog facility
+var ErrInvalidSyslogFacility = errors.New("invalid syslog facility")
+
+// SyslogFacilities are the codes of the supported syslog facilities, by name
+var SyslogFacilities = map[string]int{"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4,
+	"syslog": 5, "lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21,
+	"local6": 22, "local7": 23}
+
+func (c *Config) processLoggingConfig() error {
+	lc := &c.Logging.LogLocale
+	if lc.Timezone != "" {
+		if _, err := time.LoadLocation(lc.Timezone); err != nil {
+			return ErrInvalidLogTimezone
+		}
+	}
+	if lc.TimeField == "" {
+		lc.TimeField = d.DefaultLogLocaleTimeField
+	}
+	if lc.DisplaySuffix == "" {
+		lc.DisplaySuffix = d.DefaultLogLocaleDisplaySuffix
+	}
+	c.Logging.LogRotation = strings.ToLower(c.Logging.LogRotation)
+	switch c.Logging.LogRotation {
+	case "internal", "external":
+	case "":
+		c.Logging.LogRotation = d.DefaultLogRotation
+	default:
+		return ErrInvalidLogRotation
+	}
+	if err := c.processLogLevelOverrides(); err != nil {
+		return err
+	}
+	if err := c.processSyslogConfig(); err != nil {
+		return err
+	}
+	if err := c.processGelfConfig(); err != nil {
+		return err
+	}
+	if err := c.processNetworkTargetConfig(); err != nil {
+		return err
+	}
+	if err := c.processAccessLogConfig(); err != nil {
+		return err
+	}
+	c.Logging.LogFormat = strings.ToLower(c.Logging.LogFormat)
+	switch c.Logging.LogFormat {
+	case "logfmt", "json", "gelf", "pretty":
+		return nil
+	case "":
+		c.Logging.LogFormat = d.DefaultLogFormat
+		return nil
+	}
+	return ErrInvalidLogFormat
+}
+
+// logLevels are the log levels that a subsystem's level may be overridden to
+var logLevels = map[string]bool{"trace": true, "debug": true, "info": true, "warn": true,
+	"error": true, "none": true}
+
+func (c *Config) processLogLevelOverrides() error {
+	if len(c.Logging.LogLevelOverrides) == 0 {
+		return nil
+	}
+	lo := make(map[string]string, len(c.Logging.LogLevelOverrides))
+	for k, v := range c.Logging.LogLevelOverrides {
+		// subsystems may also be provided as package paths, such as 'proxy/engines'
+		k = strings.Trim(strings.Replace(strings.ToLower(k), "/", ".", -1), ".")
+		v = strings.ToLower(v)
+		if k == "" || !logLevels[v] {
+			return ErrInvalidLogLevelOverride
+		}
+		lo[k] = v
+	}
+	c.Logging.LogLevelOverrides = lo
+	return nil
+}
+
+func (c *Config) processAccessLogConfig() error {
+	if c.Logging.AccessLogFlushIntervalMS <= 0 {
+		c.Logging.AccessLogFlushIntervalMS = d.DefaultAccessLogFlushIntervalMS
+	}
+	c.Logging.AccessLogFormat = strings.ToLower(c.Logging.AccessLogFormat)
+	switch c.Logging.AccessLogFormat {
+	case "logfmt", "combined":
+		return nil
+	case "":
+		c.Logging.AccessLogFormat = d.DefaultAccessLogFormat
+		return nil
+	}
+	return ErrInvalidAccessLogFormat
+}
+
+func (c *Config) processGelfConfig() error {
+	if c.Logging.GelfAddress == "" {
+		c.Logging.GelfAddress = d.DefaultGelfAddress
+	}
+	c.Logging.GelfNetwork = strings.ToLower(c.Logging.GelfNetwork)
+	switch c.Logging.GelfNetwork {
+	case "udp", "tcp":
+	case "":
+		c.Logging.GelfNetwork = d.DefaultGelfNetwork
+	default:
+		return ErrInvalidGelfNetwork
+	}
+	return nil
+}
+
+func (c *Config) processNetworkTargetConfig() error {
+	if c.Logging.LogTargetBufferSize <= 0 {
+		c.Logging.LogTargetBufferSize = d.DefaultLogTargetBufferSize
+	}
+	c.Logging.LogTargetNetwork = strings.ToLower(c.Logging.LogTargetNetwork)
+	switch c.Logging.LogTargetNetwork {
+	case "tcp", "udp":
+	case "":
+		c.Logging.LogTargetNetwork = d.DefaultLogTargetNetwork
+	default:
+		return ErrInvalidLogTargetNetwork
+	}
+	if c.Logging.LogTarget == "network" && c.Logging.LogTargetAddress == "" {
+		return ErrMissingLogTargetAddress
+	}
+	return nil
+}
+
+func (c *Config) processSyslogConfig() error {
+	c.Logging.LogTarget = strings.ToLower(c.Logging.LogTarget)
+	switch c.Logging.LogTarget {
+	case "", "syslog", "network":
+	default:
+		return ErrInvalidLogTarget
+	}
+	if c.Logging.SyslogTag == "" {
+		c.Logging.SyslogTag = d.DefaultSyslogTag
+	}
+	c.Logging.SyslogFacility = strings.ToLower(c.Logging.SyslogFacility)
+	if c.Logging.SyslogFacility == "" {
+		c.Logging.SyslogFacility = d.DefaultSyslogFacility
+	}
+	if _, ok := SyslogFacilities[c.Logging.SyslogFacility]; !ok {
+		return ErrInvalidSyslogFacility
+	}
+	return nil
+}
+
 func (c *Config) validateTLSConfigs() error {
 	for _, oc := range c.Origins {
 		if oc.TLS != nil {
@@ -318,9 +798,16 @@ func (c *Config) validateTLSConfigs() error {
 }
 
 var pathMembers = []string{"path", "match_type", "handler", "methods", "cache_key_params",
-	"cache_key_headers", "default_ttl_secs", "request_headers", "response_headers",
-	"response_headers", "response_code", "response_body", "no_metrics", "collapsed_forwarding",
-	"req_rewriter_name",
+	"cache_key_headers", "cache_key_exclude_params", "default_ttl_secs", "request_headers", "response_headers",
+	"request_params", "response_code", "response_body", "no_metrics", "collapsed_forwarding",
+	"req_rewriter_name", "negative_cache_name", "cache_post_requests", "cache_post_max_body_bytes",
+	"cache_post_allow_authorization", "head_upgrade_to_get", "assume_single_use",
+	"assume_single_use_window_secs", "cache_allow_headers", "cache_deny_headers",
+	"cache_authenticated_requests", "cache_identity_header", "max_request_url_bytes",
+	"max_request_body_bytes", "middleware", "split_queries", "split_queries_limit", "partial_response", "canonical_format",
+	"max_lookback_secs", "max_lookback_action", "max_lookback_tenant_header", "max_lookback_tenant_claim",
+	"max_lookback_tenants", "priority", "priority_header", "max_estimated_points", "max_estimated_points_action",
+	"max_estimated_points_origin", "max_estimated_points_bypass_token", "max_estimated_points_ttl_secs",
 }
 
 func (c *Config) validateConfigMappings() error {
@@ -416,6 +903,22 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 			oc.OriginURL = v.OriginURL
 		}
 
+		if metadata.IsDefined("origins", k, "bootstrap_peer_url") {
+			oc.BootstrapPeerURL = v.BootstrapPeerURL
+		}
+
+		if metadata.IsDefined("origins", k, "bootstrap_window_secs") {
+			oc.BootstrapWindowSecs = v.BootstrapWindowSecs
+		}
+
+		if metadata.IsDefined("origins", k, "clock_skew_warn_secs") {
+			oc.ClockSkewWarnSecs = v.ClockSkewWarnSecs
+		}
+
+		if metadata.IsDefined("origins", k, "clock_skew_correction") {
+			oc.ClockSkewCorrection = v.ClockSkewCorrection
+		}
+
 		if metadata.IsDefined("origins", k, "compressable_types") {
 			oc.CompressableTypeList = v.CompressableTypeList
 		}
@@ -424,6 +927,18 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 			oc.TimeoutSecs = v.TimeoutSecs
 		}
 
+		if metadata.IsDefined("origins", k, "timeout_header") {
+			oc.TimeoutHeader = v.TimeoutHeader
+		}
+
+		if metadata.IsDefined("origins", k, "client_timeout_ms") {
+			oc.ClientTimeoutMS = v.ClientTimeoutMS
+		}
+
+		if metadata.IsDefined("origins", k, "timeout_margin_ms") {
+			oc.TimeoutMarginMS = v.TimeoutMarginMS
+		}
+
 		if metadata.IsDefined("origins", k, "max_idle_conns") {
 			oc.MaxIdleConns = v.MaxIdleConns
 		}
@@ -459,6 +974,14 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 			oc.FastForwardDisable = v.FastForwardDisable
 		}
 
+		if metadata.IsDefined("origins", k, "reuse_coarser_step") {
+			oc.ReuseCoarserStep = v.ReuseCoarserStep
+		}
+
+		if metadata.IsDefined("origins", k, "local_buildinfo") {
+			oc.LocalBuildInfo = v.LocalBuildInfo
+		}
+
 		if metadata.IsDefined("origins", k, "backfill_tolerance_secs") {
 			oc.BackfillToleranceSecs = v.BackfillToleranceSecs
 		}
@@ -475,6 +998,14 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 					}
 					p.ReqRewriter = ri
 				}
+				if metadata.IsDefined("origins", k, "paths", l, "middleware") {
+					stack, err := mwopts.ResolveStack(c.Middlewares, p.Middleware)
+					if err != nil {
+						return fmt.Errorf("invalid middleware in path %s of origin config %s: %s",
+							l, k, err.Error())
+					}
+					p.MiddlewareStack = stack
+				}
 				if len(p.Methods) == 0 {
 					p.Methods = []string{http.MethodGet, http.MethodHead}
 				}
@@ -484,6 +1015,9 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 						p.Custom = append(p.Custom, pm)
 					}
 				}
+				if !metadata.IsDefined("origins", k, "paths", l, "cache_post_max_body_bytes") {
+					p.CachePostMaxBodyBytes = d.DefaultCachePostMaxBodyBytes
+				}
 				if metadata.IsDefined("origins", k, "paths", l, "response_body") {
 					p.ResponseBodyBytes = []byte(p.ResponseBody)
 					p.HasCustomResponseBody = true
@@ -497,6 +1031,42 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 				} else {
 					p.CollapsedForwardingType = forwarding.CFTypeBasic
 				}
+				if metadata.IsDefined("origins", k, "paths", l, "partial_response") {
+					if _, ok := partial.Names[p.PartialResponseName]; !ok {
+						return fmt.Errorf("invalid partial_response mode: %s", p.PartialResponseName)
+					}
+					p.PartialResponse = partial.Names[p.PartialResponseName]
+				}
+				if metadata.IsDefined("origins", k, "paths", l, "max_lookback_action") {
+					if _, ok := lookback.Names[p.MaxLookbackActionName]; !ok {
+						return fmt.Errorf("invalid max_lookback_action: %s", p.MaxLookbackActionName)
+					}
+					p.MaxLookbackAction = lookback.Names[p.MaxLookbackActionName]
+				}
+				if metadata.IsDefined("origins", k, "paths", l, "max_estimated_points_action") {
+					if _, ok := estimate.Names[p.MaxEstimatedPointsActionName]; !ok {
+						return fmt.Errorf("invalid max_estimated_points_action: %s", p.MaxEstimatedPointsActionName)
+					}
+					p.MaxEstimatedPointsAction = estimate.Names[p.MaxEstimatedPointsActionName]
+				}
+				if p.MaxEstimatedPoints > 0 && p.MaxEstimatedPointsAction == estimate.ActionRoute {
+					if _, ok := c.Origins[p.MaxEstimatedPointsOrigin]; !ok || p.MaxEstimatedPointsOrigin == k {
+						return fmt.Errorf("invalid max_estimated_points_origin [%s] provided in origin config [%s]",
+							p.MaxEstimatedPointsOrigin, k)
+					}
+				}
+				if metadata.IsDefined("origins", k, "paths", l, "priority") {
+					if _, ok := priority.Names[p.PriorityName]; !ok {
+						return fmt.Errorf("invalid priority: %s", p.PriorityName)
+					}
+					p.Priority = priority.Names[p.PriorityName]
+				}
+				if metadata.IsDefined("origins", k, "paths", l, "cache_authenticated_requests") {
+					if _, ok := authcache.PolicyNames[p.AuthCachePolicyName]; !ok {
+						return fmt.Errorf("invalid cache_authenticated_requests policy: %s", p.AuthCachePolicyName)
+					}
+					p.AuthCachePolicy = authcache.GetPolicy(p.AuthCachePolicyName)
+				}
 				if mt, ok := matching.Names[strings.ToLower(p.MatchTypeName)]; ok {
 					p.MatchType = mt
 					p.MatchTypeName = p.MatchType.String()
@@ -513,6 +1083,18 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 			oc.NegativeCacheName = v.NegativeCacheName
 		}
 
+		if metadata.IsDefined("origins", k, "negative_cache_allow_revalidate") {
+			oc.NegativeCacheAllowRevalidate = v.NegativeCacheAllowRevalidate
+		}
+
+		if metadata.IsDefined("origins", k, "negative_cache_backoff_multiplier") {
+			oc.NegativeCacheBackoffMultiplier = v.NegativeCacheBackoffMultiplier
+		}
+
+		if metadata.IsDefined("origins", k, "negative_cache_backoff_max_ttl_secs") {
+			oc.NegativeCacheBackoffMaxTTLSecs = v.NegativeCacheBackoffMaxTTLSecs
+		}
+
 		if metadata.IsDefined("origins", k, "tracing_name") {
 			oc.TracingConfigName = v.TracingConfigName
 		}
@@ -533,6 +1115,47 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 			oc.HealthCheckHeaders = v.HealthCheckHeaders
 		}
 
+		if metadata.IsDefined("origins", k, "health_check_body") {
+			oc.HealthCheckBody = v.HealthCheckBody
+		}
+
+		if metadata.IsDefined("origins", k, "health_check_expected_codes") {
+			oc.HealthCheckExpectedCodes = v.HealthCheckExpectedCodes
+		}
+
+		if metadata.IsDefined("origins", k, "health_check_expected_body") {
+			oc.HealthCheckExpectedBody = v.HealthCheckExpectedBody
+		}
+
+		if metadata.IsDefined("origins", k, "health_check_expected_body_regex") {
+			oc.HealthCheckExpectedBodyRegex = v.HealthCheckExpectedBodyRegex
+		}
+
+		if metadata.IsDefined("origins", k, "health_check_timeout_ms") {
+			oc.HealthCheckTimeoutMS = v.HealthCheckTimeoutMS
+		}
+
+		if metadata.IsDefined("origins", k, "health_check_interval_ms") {
+			oc.HealthCheckIntervalMS = v.HealthCheckIntervalMS
+		}
+
+		if metadata.IsDefined("origins", k, "health_check_allow_get_body") {
+			oc.HealthCheckAllowGetBody = v.HealthCheckAllowGetBody
+		}
+
+		// validate the health check options set by the user; provider-specific
+		// defaults are validated as each origin's routes are registered
+		if _, err := healthcheck.New(oc, nil); err != nil {
+			return fmt.Errorf("invalid health check config for origin [%s]: %s", k, err.Error())
+		}
+
+		if metadata.IsDefined("origins", k, "preflight_policy") {
+			oc.PreflightPolicyName = strings.ToLower(v.PreflightPolicyName)
+			if p, ok := policy.Names[oc.PreflightPolicyName]; ok {
+				oc.PreflightPolicy = p
+			}
+		}
+
 		if metadata.IsDefined("origins", k, "max_object_size_bytes") {
 			oc.MaxObjectSizeBytes = v.MaxObjectSizeBytes
 		}
@@ -549,6 +1172,73 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 			oc.DearticulateUpstreamRanges = v.DearticulateUpstreamRanges
 		}
 
+		if metadata.IsDefined("origins", k, "reject_duplicate_params") {
+			oc.RejectDuplicateParams = v.RejectDuplicateParams
+		}
+
+		if metadata.IsDefined("origins", k, "fingerprint_strip_values") {
+			oc.FingerprintStripValues = v.FingerprintStripValues
+		}
+
+		if metadata.IsDefined("origins", k, "slow_query_threshold_ms") {
+			oc.SlowQueryThresholdMS = v.SlowQueryThresholdMS
+		}
+
+		if metadata.IsDefined("origins", k, "lookback_delta_ms") {
+			oc.LookbackDeltaMS = v.LookbackDeltaMS
+		}
+
+		if metadata.IsDefined("origins", k, "rate_limit_remaining_header") {
+			oc.RateLimitRemainingHeader = v.RateLimitRemainingHeader
+		}
+
+		if metadata.IsDefined("origins", k, "rate_limit_reset_header") {
+			oc.RateLimitResetHeader = v.RateLimitResetHeader
+		}
+
+		if metadata.IsDefined("origins", k, "rate_limit_min_remaining") {
+			oc.RateLimitMinRemaining = v.RateLimitMinRemaining
+		}
+
+		if metadata.IsDefined("origins", k, "rate_limit_mode") {
+			oc.RateLimitModeName = strings.ToLower(v.RateLimitModeName)
+			if m, ok := ratelimit.Names[oc.RateLimitModeName]; ok {
+				oc.RateLimitMode = m
+			}
+		}
+
+		if metadata.IsDefined("origins", k, "rate_limit_max_wait_ms") {
+			oc.RateLimitMaxWaitMS = v.RateLimitMaxWaitMS
+		}
+
+		if metadata.IsDefined("origins", k, "rate_limit_serve_stale") {
+			oc.RateLimitServeStale = v.RateLimitServeStale
+		}
+
+		if metadata.IsDefined("origins", k, "max_concurrent_requests") {
+			oc.MaxConcurrentRequests = v.MaxConcurrentRequests
+		}
+
+		if metadata.IsDefined("origins", k, "concurrency_reserved_share") {
+			oc.ConcurrencyReservedShare = v.ConcurrencyReservedShare
+		}
+
+		if metadata.IsDefined("origins", k, "concurrency_max_wait_ms") {
+			oc.ConcurrencyMaxWaitMS = v.ConcurrencyMaxWaitMS
+		}
+
+		if metadata.IsDefined("origins", k, "timezone") {
+			oc.TimeZoneName = v.TimeZoneName
+		}
+
+		if metadata.IsDefined("origins", k, "record_dir") {
+			oc.RecordDir = v.RecordDir
+		}
+
+		if metadata.IsDefined("origins", k, "replay_dir") {
+			oc.ReplayDir = v.ReplayDir
+		}
+
 		if metadata.IsDefined("origins", k, "tls") {
 			oc.TLS = &to.Options{
 				InsecureSkipVerify:        v.TLS.InsecureSkipVerify,
@@ -582,9 +1272,56 @@ func (c *Config) processCachingConfigs(metadata *toml.MetaData) error {
 
 		if metadata.IsDefined("caches", k, "cache_type") {
 			cc.CacheType = strings.ToLower(v.CacheType)
-			if n, ok := types.Names[cc.CacheType]; ok {
-				cc.CacheTypeID = n
+			n, ok := types.Lookup(cc.CacheType)
+			if !ok {
+				return fmt.Errorf("invalid cache type [%s] provided in cache config [%s]. knownTypes: %s",
+					v.CacheType, k, strings.Join(types.NamesList(), ", "))
 			}
+			cc.CacheTypeID = n
+		}
+
+		if metadata.IsDefined("caches", k, "verify_checksums") {
+			cc.VerifyChecksums = v.VerifyChecksums
+		}
+
+		if metadata.IsDefined("caches", k, "preflight_policy") {
+			cc.PreflightPolicyName = strings.ToLower(v.PreflightPolicyName)
+			p, ok := policy.Names[cc.PreflightPolicyName]
+			if !ok {
+				return fmt.Errorf("invalid preflight_policy [%s] provided in cache config [%s]",
+					v.PreflightPolicyName, k)
+			}
+			cc.PreflightPolicy = p
+		}
+
+		if metadata.IsDefined("caches", k, "read_only") {
+			cc.ReadOnly = v.ReadOnly
+		}
+
+		if metadata.IsDefined("caches", k, "read_only_error_threshold") {
+			cc.ReadOnlyErrorThreshold = v.ReadOnlyErrorThreshold
+		}
+
+		if cc.ReadOnlyErrorThreshold < 0 || cc.ReadOnlyErrorThreshold > 1 {
+			return fmt.Errorf("invalid read_only_error_threshold %g in cache config [%s]",
+				cc.ReadOnlyErrorThreshold, k)
+		}
+
+		if metadata.IsDefined("caches", k, "read_only_window_secs") {
+			cc.ReadOnlyWindowSecs = v.ReadOnlyWindowSecs
+		}
+
+		if metadata.IsDefined("caches", k, "read_only_min_stores") {
+			cc.ReadOnlyMinStores = v.ReadOnlyMinStores
+		}
+
+		if metadata.IsDefined("caches", k, "read_only_probe_interval_secs") {
+			cc.ReadOnlyProbeIntervalSecs = v.ReadOnlyProbeIntervalSecs
+		}
+
+		if cc.ReadOnlyWindowSecs < 1 || cc.ReadOnlyMinStores < 1 || cc.ReadOnlyProbeIntervalSecs < 1 {
+			return fmt.Errorf("invalid read-only mode options in cache config [%s]; "+
+				"read_only_window_secs, read_only_min_stores and read_only_probe_interval_secs must be positive", k)
 		}
 
 		if metadata.IsDefined("caches", k, "index", "reap_interval_secs") {
@@ -619,13 +1356,44 @@ func (c *Config) processCachingConfigs(metadata *toml.MetaData) error {
 			return errors.New("MaxSizeBackoffObjects can't be larger than MaxSizeObjects")
 		}
 
+		if metadata.IsDefined("caches", k, "index", "sync_bulk_remove") {
+			cc.Index.SyncBulkRemove = v.Index.SyncBulkRemove
+		}
+
+		if metadata.IsDefined("caches", k, "index", "bulk_remove_batch_size") {
+			cc.Index.BulkRemoveBatchSize = v.Index.BulkRemoveBatchSize
+		}
+
+		if metadata.IsDefined("caches", k, "index", "bulk_remove_interval_ms") {
+			cc.Index.BulkRemoveIntervalMS = v.Index.BulkRemoveIntervalMS
+		}
+
+		if cc.Index.BulkRemoveBatchSize < 1 {
+			return fmt.Errorf("invalid bulk_remove_batch_size %d in cache config [%s]",
+				cc.Index.BulkRemoveBatchSize, k)
+		}
+
+		if cc.Index.BulkRemoveIntervalMS < 0 {
+			return fmt.Errorf("invalid bulk_remove_interval_ms %d in cache config [%s]",
+				cc.Index.BulkRemoveIntervalMS, k)
+		}
+
+		if metadata.IsDefined("caches", k, "index", "unused_eviction_window_secs") {
+			cc.Index.UnusedEvictionWindowSecs = v.Index.UnusedEvictionWindowSecs
+		}
+
+		if cc.Index.UnusedEvictionWindowSecs < 0 {
+			return fmt.Errorf("invalid unused_eviction_window_secs %d in cache config [%s]",
+				cc.Index.UnusedEvictionWindowSecs, k)
+		}
+
 		if cc.CacheTypeID == types.CacheTypeRedis {
 
 			var hasEndpoint, hasEndpoints bool
 
-			ct := strings.ToLower(v.Redis.ClientType)
+			// the redis section of a cache whose type is redis may not be configured
 			if metadata.IsDefined("caches", k, "redis", "client_type") {
-				cc.Redis.ClientType = ct
+				cc.Redis.ClientType = strings.ToLower(v.Redis.ClientType)
 			}
 
 			if metadata.IsDefined("caches", k, "redis", "protocol") {
@@ -751,26 +1519,109 @@ func (c *Config) Clone() *Config {
 	nc.Main.ConfigHandlerPath = c.Main.ConfigHandlerPath
 	nc.Main.InstanceID = c.Main.InstanceID
 	nc.Main.PingHandlerPath = c.Main.PingHandlerPath
+	nc.Main.ReadyHandlerPath = c.Main.ReadyHandlerPath
+	nc.Main.PreflightTimeoutMS = c.Main.PreflightTimeoutMS
 	nc.Main.ReloadHandlerPath = c.Main.ReloadHandlerPath
 	nc.Main.HealthHandlerPath = c.Main.HealthHandlerPath
+	nc.Main.VersionHandlerPath = c.Main.VersionHandlerPath
 	nc.Main.PprofServer = c.Main.PprofServer
 	nc.Main.ServerName = c.Main.ServerName
+	nc.Main.TopQueriesSize = c.Main.TopQueriesSize
+	nc.Main.MaxInflightProcessingBytes = c.Main.MaxInflightProcessingBytes
+	nc.Main.InflightProcessingTimeoutMS = c.Main.InflightProcessingTimeoutMS
+	nc.Main.RequestSamplingEnabled = c.Main.RequestSamplingEnabled
+	nc.Main.RequestSamplingSize = c.Main.RequestSamplingSize
+	nc.Main.CacheEventsEnabled = c.Main.CacheEventsEnabled
+	nc.Main.CacheEventsSize = c.Main.CacheEventsSize
+	nc.Main.CacheEventSink = c.Main.CacheEventSink
+	nc.Main.CacheEventLog = c.Main.CacheEventLog
 
 	nc.Main.configFilePath = c.Main.configFilePath
+	nc.Main.configFileHash = c.Main.configFileHash
+	if c.Main.envOverrides != nil {
+		nc.Main.envOverrides = make(map[string]string, len(c.Main.envOverrides))
+		for k, v := range c.Main.envOverrides {
+			nc.Main.envOverrides[k] = v
+		}
+	}
+	if c.Main.sources != nil {
+		nc.Main.sources = make(map[string]string, len(c.Main.sources))
+		for k, v := range c.Main.sources {
+			nc.Main.sources[k] = v
+		}
+	}
 	nc.Main.configLastModified = c.Main.configLastModified
 	nc.Main.configRateLimitTime = c.Main.configRateLimitTime
 
 	nc.Logging.LogFile = c.Logging.LogFile
+	nc.Logging.LogIncludeHostname = c.Logging.LogIncludeHostname
+	nc.Logging.LogAlsoStdout = c.Logging.LogAlsoStdout
+	nc.Logging.SplitStreams = c.Logging.SplitStreams
 	nc.Logging.LogLevel = c.Logging.LogLevel
+	if c.Logging.LogLevelOverrides != nil {
+		nc.Logging.LogLevelOverrides = make(map[string]string, len(c.Logging.LogLevelOverrides))
+		for k, v := range c.Logging.LogLevelOverrides {
+			nc.Logging.LogLevelOverrides[k] = v
+		}
+	}
+	nc.Logging.LogFormat = c.Logging.LogFormat
+	nc.Logging.LogTimestampFormat = c.Logging.LogTimestampFormat
+	nc.Logging.LogTimestampLocal = c.Logging.LogTimestampLocal
+	nc.Logging.LogMaxSizeMB = c.Logging.LogMaxSizeMB
+	nc.Logging.LogMaxBackups = c.Logging.LogMaxBackups
+	nc.Logging.LogMaxAgeDays = c.Logging.LogMaxAgeDays
+	nc.Logging.LogCompress = c.Logging.LogCompress
+	nc.Logging.LogRotation = c.Logging.LogRotation
+	nc.Logging.LogOnceMaxEntries = c.Logging.LogOnceMaxEntries
+	nc.Logging.LogOnceTTLSecs = c.Logging.LogOnceTTLSecs
+	nc.Logging.LogDedupWindowSecs = c.Logging.LogDedupWindowSecs
+	nc.Logging.LogAsyncBufferSize = c.Logging.LogAsyncBufferSize
+	nc.Logging.LogTarget = c.Logging.LogTarget
+	nc.Logging.SyslogNetwork = c.Logging.SyslogNetwork
+	nc.Logging.SyslogAddress = c.Logging.SyslogAddress
+	nc.Logging.SyslogFacility = c.Logging.SyslogFacility
+	nc.Logging.SyslogTag = c.Logging.SyslogTag
+	nc.Logging.GelfNetwork = c.Logging.GelfNetwork
+	nc.Logging.GelfAddress = c.Logging.GelfAddress
+	nc.Logging.LogTargetNetwork = c.Logging.LogTargetNetwork
+	nc.Logging.LogTargetAddress = c.Logging.LogTargetAddress
+	nc.Logging.LogTargetBufferSize = c.Logging.LogTargetBufferSize
+	if c.Logging.RedactKeys != nil {
+		nc.Logging.RedactKeys = make([]string, len(c.Logging.RedactKeys))
+		copy(nc.Logging.RedactKeys, c.Logging.RedactKeys)
+	}
+	nc.Logging.AccessLogFile = c.Logging.AccessLogFile
+	nc.Logging.AccessLogFormat = c.Logging.AccessLogFormat
+	nc.Logging.AccessLogFlushIntervalMS = c.Logging.AccessLogFlushIntervalMS
+	nc.Logging.LogLocale = c.Logging.LogLocale
 
 	nc.Metrics.ListenAddress = c.Metrics.ListenAddress
 	nc.Metrics.ListenPort = c.Metrics.ListenPort
 
+	if c.GRPCHealth != nil {
+		*nc.GRPCHealth = *c.GRPCHealth
+	}
+
+	if c.ReloadConfig != nil {
+		rc := *c.ReloadConfig
+		if c.ReloadConfig.AdminTokens != nil {
+			rc.AdminTokens = make(map[string]*reload.AdminTokenOptions,
+				len(c.ReloadConfig.AdminTokens))
+			for k, v := range c.ReloadConfig.AdminTokens {
+				at := *v
+				rc.AdminTokens[k] = &at
+			}
+		}
+		nc.ReloadConfig = &rc
+	}
+
 	nc.Frontend.ListenAddress = c.Frontend.ListenAddress
 	nc.Frontend.ListenPort = c.Frontend.ListenPort
 	nc.Frontend.TLSListenAddress = c.Frontend.TLSListenAddress
 	nc.Frontend.TLSListenPort = c.Frontend.TLSListenPort
 	nc.Frontend.ConnectionsLimit = c.Frontend.ConnectionsLimit
+	nc.Frontend.MaxRequestURLBytes = c.Frontend.MaxRequestURLBytes
+	nc.Frontend.MaxRequestBodyBytes = c.Frontend.MaxRequestBodyBytes
 	nc.Frontend.ServeTLS = c.Frontend.ServeTLS
 
 	nc.Resources = &Resources{
@@ -807,6 +1658,23 @@ func (c *Config) Clone() *Config {
 		}
 	}
 
+	// compiled rewriters are not modified once compiled, so they are shared with the clone
+	nc.CompiledRewriters = c.CompiledRewriters
+
+	if len(c.RefreshJobs) > 0 {
+		nc.RefreshJobs = make(map[string]*refresh.Options, len(c.RefreshJobs))
+		for k, v := range c.RefreshJobs {
+			nc.RefreshJobs[k] = v.Clone()
+		}
+	}
+
+	if len(c.Middlewares) > 0 {
+		nc.Middlewares = make(map[string]*mwopts.Options, len(c.Middlewares))
+		for k, v := range c.Middlewares {
+			nc.Middlewares[k] = v.Clone()
+		}
+	}
+
 	return nc
 }
 
@@ -834,7 +1702,14 @@ func (c *Config) IsStale() bool {
 	return t != c.Main.configLastModified
 }
 
+// String returns the running configuration as a TOML document, with its secrets masked and
+// the source of each of its options noted in a comment
 func (c *Config) String() string {
+	return c.annotate(c.document())
+}
+
+// document returns the running configuration as a TOML document, with its secrets masked
+func (c *Config) document() string {
 	cp := c.Clone()
 
 	// the toml library will panic if the Handler is assigned,
@@ -843,29 +1718,19 @@ func (c *Config) String() string {
 	if cp.Origins != nil {
 		for _, v := range cp.Origins {
 			if v != nil {
+				v.ReqRewriter = nil
 				for _, w := range v.Paths {
 					w.Handler = nil
-					w.KeyHasher = nil
-				}
-			}
-			// also strip out potentially sensitive headers
-			hideAuthorizationCredentials(v.HealthCheckHeaders)
-
-			if v.Paths != nil {
-				for _, p := range v.Paths {
-					hideAuthorizationCredentials(p.RequestHeaders)
-					hideAuthorizationCredentials(p.ResponseHeaders)
+					w.MaxEstimatedPointsRouter = nil
+					w.KeyProvider = nil
+					w.ReqRewriter = nil
 				}
 			}
 		}
 	}
 
-	// strip Redis password
-	for k, v := range cp.Caches {
-		if v != nil && cp.Caches[k].Redis.Password != "" {
-			cp.Caches[k].Redis.Password = "*****"
-		}
-	}
+	// strip secrets, such as Redis passwords and Authorization headers
+	cp.maskSecrets()
 
 	var buf bytes.Buffer
 	e := toml.NewEncoder(&buf)
@@ -873,6 +1738,49 @@ func (c *Config) String() string {
 	return buf.String()
 }
 
+// annotate returns the TOML document of the running configuration with the source of each of
+// its options noted in a comment, along with the environment variable of each option set by one
+func (c *Config) annotate(document string) string {
+	vars := make(map[string]string, len(c.EnvOverrides()))
+	for k, v := range c.EnvOverrides() {
+		vars[v] = k
+	}
+	lines := strings.Split(document, "\n")
+	scanKeys(document, func(i int, k []string, table bool) {
+		if table {
+			return
+		}
+		path := keyPath(k)
+		source := c.Source(path)
+		if v, ok := vars[path]; ok && source == SourceEnv {
+			source += " " + v
+		}
+		lines[i] += " # " + source
+	})
+	return strings.Join(lines, "\n")
+}
+
+// JSON returns the running configuration as JSON, with its secrets masked, as the "config"
+// member, and the sources of its options by their TOML key paths as the "sources" member
+func (c *Config) JSON() ([]byte, error) {
+	document := c.document()
+	options := make(map[string]interface{})
+	if _, err := toml.Decode(document, &options); err != nil {
+		return nil, err
+	}
+	sources := make(map[string]string)
+	scanKeys(document, func(i int, k []string, table bool) {
+		if !table {
+			path := keyPath(k)
+			sources[path] = c.Source(path)
+		}
+	})
+	return json.MarshalIndent(map[string]interface{}{
+		"config":  options,
+		"sources": sources,
+	}, "", "  ")
+}
+
 // ConfigFilePath returns the file path from which this configuration is based
 func (c *Config) ConfigFilePath() string {
 	if c.Main != nil {
@@ -881,18 +1789,15 @@ func (c *Config) ConfigFilePath() string {
 	return ""
 }
 
+// ConfigFileHash returns the SHA1 checksum of the file from which this configuration is based
+func (c *Config) ConfigFileHash() string {
+	if c.Main != nil {
+		return c.Main.configFileHash
+	}
+	return ""
+}
+
 // Equal returns true if the FrontendConfigs are identical in value.
 func (fc *FrontendConfig) Equal(fc2 *FrontendConfig) bool {
 	return *fc == *fc2
 }
-
-var sensitiveCredentials = map[string]bool{headers.NameAuthorization: true}
-
-func hideAuthorizationCredentials(headers map[string]string) {
-	// strip Authorization Headers
-	for k := range headers {
-		if _, ok := sensitiveCredentials[k]; ok {
-			headers[k] = "*****"
-		}
-	}
-}
@@ -154,6 +154,212 @@ func TestProcessPprofConfig(t *testing.T) {
 
 }
 
+func TestProcessLoggingConfig(t *testing.T) {
+
+	c := NewConfig()
+	c.Logging.LogFormat = ""
+
+	err := c.processLoggingConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Logging.LogFormat != d.DefaultLogFormat {
+		t.Errorf("expected %s got %s", d.DefaultLogFormat, c.Logging.LogFormat)
+	}
+
+	c.Logging.LogFormat = "JSON"
+
+	err = c.processLoggingConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Logging.LogFormat != "json" {
+		t.Errorf("expected %s got %s", "json", c.Logging.LogFormat)
+	}
+
+	c.Logging.LogFormat = "x"
+
+	err = c.processLoggingConfig()
+	if err != ErrInvalidLogFormat {
+		t.Error("expected error for invalid log format")
+	}
+
+	c.Logging.LogFormat = "json"
+	c.Logging.LogLocale.TimeField = ""
+	c.Logging.LogLocale.DisplaySuffix = ""
+	c.Logging.LogLocale.Timezone = "Asia/Shanghai"
+
+	err = c.processLoggingConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Logging.LogLocale.TimeField != d.DefaultLogLocaleTimeField ||
+		c.Logging.LogLocale.DisplaySuffix != d.DefaultLogLocaleDisplaySuffix {
+		t.Errorf("unexpected log locale %v", c.Logging.LogLocale)
+	}
+
+	c.Logging.LogLocale.Timezone = "Nowhere/Special"
+
+	err = c.processLoggingConfig()
+	if err != ErrInvalidLogTimezone {
+		t.Error("expected error for invalid log timezone")
+	}
+
+	c.Logging.LogLocale.Timezone = ""
+	c.Logging.LogTarget = "SYSLOG"
+	c.Logging.SyslogFacility = ""
+	c.Logging.SyslogTag = ""
+
+	err = c.processLoggingConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Logging.LogTarget != "syslog" || c.Logging.SyslogFacility != d.DefaultSyslogFacility ||
+		c.Logging.SyslogTag != d.DefaultSyslogTag {
+		t.Errorf("unexpected syslog config %s %s %s", c.Logging.LogTarget,
+			c.Logging.SyslogFacility, c.Logging.SyslogTag)
+	}
+
+	c.Logging.SyslogFacility = "local9"
+
+	err = c.processLoggingConfig()
+	if err != ErrInvalidSyslogFacility {
+		t.Error("expected error for invalid syslog facility")
+	}
+
+	c.Logging.SyslogFacility = "Local0"
+	c.Logging.LogTarget = "journald"
+
+	err = c.processLoggingConfig()
+	if err != ErrInvalidLogTarget {
+		t.Error("expected error for invalid log target")
+	}
+
+	c.Logging.LogTarget = ""
+	c.Logging.AccessLogFormat = ""
+	c.Logging.AccessLogFlushIntervalMS = 0
+
+	err = c.processLoggingConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Logging.AccessLogFormat != d.DefaultAccessLogFormat ||
+		c.Logging.AccessLogFlushIntervalMS != d.DefaultAccessLogFlushIntervalMS {
+		t.Errorf("unexpected access log config %s %d", c.Logging.AccessLogFormat,
+			c.Logging.AccessLogFlushIntervalMS)
+	}
+
+	c.Logging.AccessLogFormat = "common"
+
+	err = c.processLoggingConfig()
+	if err != ErrInvalidAccessLogFormat {
+		t.Error("expected error for invalid access log format")
+	}
+
+	c.Logging.AccessLogFormat = ""
+	c.Logging.LogRotation = ""
+
+	err = c.processLoggingConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Logging.LogRotation != d.DefaultLogRotation {
+		t.Errorf("expected %s got %s", d.DefaultLogRotation, c.Logging.LogRotation)
+	}
+
+	c.Logging.LogRotation = "logrotate"
+
+	err = c.processLoggingConfig()
+	if err != ErrInvalidLogRotation {
+		t.Error("expected error for invalid log rotation")
+	}
+
+	c.Logging.LogRotation = ""
+	c.Logging.LogFormat = "GELF"
+	c.Logging.GelfNetwork = ""
+	c.Logging.GelfAddress = ""
+
+	err = c.processLoggingConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Logging.LogFormat != "gelf" || c.Logging.GelfNetwork != d.DefaultGelfNetwork ||
+		c.Logging.GelfAddress != d.DefaultGelfAddress {
+		t.Errorf("unexpected gelf config %s %s %s", c.Logging.LogFormat,
+			c.Logging.GelfNetwork, c.Logging.GelfAddress)
+	}
+
+	c.Logging.GelfNetwork = "http"
+
+	err = c.processLoggingConfig()
+	if err != ErrInvalidGelfNetwork {
+		t.Error("expected error for invalid gelf network")
+	}
+
+	c.Logging.GelfNetwork = "tcp"
+	c.Logging.LogFormat = ""
+	c.Logging.LogTarget = "Network"
+	c.Logging.LogTargetNetwork = ""
+	c.Logging.LogTargetBufferSize = 0
+
+	err = c.processLoggingConfig()
+	if err != ErrMissingLogTargetAddress {
+		t.Error("expected error for missing log target address")
+	}
+
+	c.Logging.LogTargetAddress = "logstash:5000"
+
+	err = c.processLoggingConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Logging.LogTarget != "network" || c.Logging.LogTargetNetwork != d.DefaultLogTargetNetwork ||
+		c.Logging.LogTargetBufferSize != d.DefaultLogTargetBufferSize {
+		t.Errorf("unexpected log target config %s %s %d", c.Logging.LogTarget,
+			c.Logging.LogTargetNetwork, c.Logging.LogTargetBufferSize)
+	}
+
+	c.Logging.LogTargetNetwork = "unix"
+
+	err = c.processLoggingConfig()
+	if err != ErrInvalidLogTargetNetwork {
+		t.Error("expected error for invalid log target network")
+	}
+
+	c.Logging.LogTargetNetwork = "UDP"
+	c.Logging.LogTarget = ""
+	c.Logging.LogLevelOverrides = map[string]string{"proxy/engines": "DEBUG", "Cache.Redis": "warn"}
+
+	err = c.processLoggingConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if v := c.Logging.LogLevelOverrides["proxy.engines"]; v != "debug" {
+		t.Errorf("expected %s got %s", "debug", v)
+	}
+
+	if v := c.Logging.LogLevelOverrides["cache.redis"]; v != "warn" {
+		t.Errorf("expected %s got %s", "warn", v)
+	}
+
+	c.Logging.LogLevelOverrides = map[string]string{"proxy.engines": "verbose"}
+
+	err = c.processLoggingConfig()
+	if err != ErrInvalidLogLevelOverride {
+		t.Error("expected error for invalid log level override")
+	}
+
+}
+
 func TestSetDefaults(t *testing.T) {
 
 	c, _ := emptyTestConfig()
@@ -19,6 +19,8 @@ package defaults
 import (
 	"github.com/tricksterproxy/trickster/pkg/cache/evictionmethods"
 	"github.com/tricksterproxy/trickster/pkg/cache/types"
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight/policy"
+	"github.com/tricksterproxy/trickster/pkg/proxy/ratelimit"
 )
 
 const (
@@ -27,6 +29,42 @@ const (
 	DefaultLogFile = ""
 	// DefaultLogLevel is the default level for logging
 	DefaultLogLevel = "INFO"
+	// DefaultLogFormat is the default encoding of log events
+	DefaultLogFormat = "logfmt"
+	// DefaultLogMaxSizeMB is the default size in megabytes at which a log file is rotated
+	DefaultLogMaxSizeMB = 256
+	// DefaultLogMaxBackups is the default number of rotated log files to retain.
+	// 256 megs @ 80 backups is 20GB of logs
+	DefaultLogMaxBackups = 80
+	// DefaultLogMaxAgeDays is the default number of days to retain rotated log files
+	DefaultLogMaxAgeDays = 7
+	// DefaultLogCompress is the default for whether rotated log files are compressed
+	DefaultLogCompress = true
+	// DefaultLogRotation is the default rotator of the log file, which is Trickster itself
+	DefaultLogRotation = "internal"
+	// DefaultSyslogFacility is the default syslog facility of log events written to syslog
+	DefaultSyslogFacility = "daemon"
+	// DefaultSyslogTag is the default tag of log events written to syslog
+	DefaultSyslogTag = "trickster"
+	// DefaultGelfNetwork is the default network of the GELF endpoint of log events
+	DefaultGelfNetwork = "udp"
+	// DefaultGelfAddress is the default address of the GELF endpoint of log events
+	DefaultGelfAddress = "localhost:12201"
+	// DefaultLogTargetNetwork is the default network of the endpoint of the network log target
+	DefaultLogTargetNetwork = "tcp"
+	// DefaultLogTargetBufferSize is the default number of events buffered for the network log
+	// target while it is reconnected
+	DefaultLogTargetBufferSize = 10000
+	// DefaultAccessLogFormat is the default format of access log entries
+	DefaultAccessLogFormat = "logfmt"
+	// DefaultAccessLogFlushIntervalMS is the default interval in milliseconds at which buffered
+	// access log entries are written to the access log file
+	DefaultAccessLogFlushIntervalMS = 1000
+	// DefaultLogLocaleTimeField is the default name of the local timestamp field of log events
+	DefaultLogLocaleTimeField = "time_local"
+	// DefaultLogLocaleDisplaySuffix is the default suffix of the names of the translated display
+	// fields of log events
+	DefaultLogLocaleDisplaySuffix = "_display"
 
 	// DefaultProxyListenPort is the default port that the HTTP frontend will listen on
 	DefaultProxyListenPort = 8480
@@ -44,6 +82,10 @@ const (
 	DefaultTLSProxyListenPort = 8483
 	// DefaultTLSProxyListenAddress is the default address that the TLS frontend endpoint will listen on
 	DefaultTLSProxyListenAddress = ""
+	// DefaultMaxRequestURLBytes is the default maximum length of a frontend request URL
+	DefaultMaxRequestURLBytes = 65536
+	// DefaultMaxRequestBodyBytes is the default maximum size of a frontend request body
+	DefaultMaxRequestBodyBytes = 10485760
 
 	// DefaultReloadPort is the default port that the Reload endpoint will listen on
 	DefaultReloadPort = 8484
@@ -66,6 +108,15 @@ const (
 	// DefaultCacheTypeID is the default cache type ID for any defined cache
 	// and should align with DefaultCacheType
 	DefaultCacheTypeID = types.CacheTypeMemory
+	// DefaultCacheReadOnlyWindowSecs is the default window over which a cache's store error rate
+	// is evaluated for automatic read-only mode
+	DefaultCacheReadOnlyWindowSecs = 30
+	// DefaultCacheReadOnlyMinStores is the default number of stores a cache must attempt in the
+	// window before its store error rate is evaluated
+	DefaultCacheReadOnlyMinStores = 10
+	// DefaultCacheReadOnlyProbeIntervalSecs is the default interval at which a cache in automatic
+	// read-only mode probes a write to exit it
+	DefaultCacheReadOnlyProbeIntervalSecs = 10
 
 	// DefaultTimeseriesTTLSecs is the default Cache TTL for Time Series Objects
 	DefaultTimeseriesTTLSecs = 21600
@@ -97,8 +148,24 @@ const (
 	DefaultMaxSizeObjects = 0
 	// DefaultMaxSizeBackoffObjects is the default Max Cache Backoff Object Count
 	DefaultMaxSizeBackoffObjects = 100
+	// DefaultBulkRemoveBatchSize is the default number of objects physically deleted in each batch
+	// by a cache's background deletion worker
+	DefaultBulkRemoveBatchSize = 500
+	// DefaultBulkRemoveIntervalMS is the default pause (in milliseconds) between batches of a cache's
+	// background deletion worker
+	DefaultBulkRemoveIntervalMS = 100
 	// DefaultMaxObjectSizeBytes is the default Max Size of any Cache Object
 	DefaultMaxObjectSizeBytes = 524288
+	// DefaultCachePostMaxBodyBytes is the default maximum request body size of a cacheable POST request
+	DefaultCachePostMaxBodyBytes = 65536
+	// DefaultSplitQueriesLimit is the default maximum number of sub-queries into which a query is split
+	DefaultSplitQueriesLimit = 50
+	// DefaultMaxEstimatedPointsTTLSecs is the default age after which the series count of a query is no
+	// longer used to estimate the size of its results
+	DefaultMaxEstimatedPointsTTLSecs = 3600
+	// DefaultAssumeSingleUseWindowSecs is the default window in which a second request for the cache
+	// key of a path that assumes single use is cached
+	DefaultAssumeSingleUseWindowSecs = 300
 	// DefaultOriginTRF is the default Timeseries Retention Factor for Time Series-based Origins
 	DefaultOriginTRF = 1024
 	// DefaultOriginTEM is the default Timeseries Eviction Method for Time Series-based Origins
@@ -107,10 +174,17 @@ const (
 	DefaultOriginTEMName = "oldest"
 	// DefaultOriginTimeoutSecs is the default Upstream Request Timeout for Origins
 	DefaultOriginTimeoutSecs = 180
+	// DefaultTimeoutHeader is the default request header in which clients may provide a timeout hint
+	DefaultTimeoutHeader = "X-Request-Timeout"
+	// DefaultTimeoutMarginMS is the default margin by which timeouts forwarded upstream are reduced
+	DefaultTimeoutMarginMS = 100
 	// DefaultOriginCacheName is the default Cache Name for Origins
 	DefaultOriginCacheName = "default"
 	// DefaultOriginNegativeCacheName is the default Negative Cache Name for Origins
 	DefaultOriginNegativeCacheName = "default"
+	// DefaultNegativeCacheBackoffMaxTTLSecs is the default cap of the TTL of Negative Cache entries
+	// extended while an origin keeps failing
+	DefaultNegativeCacheBackoffMaxTTLSecs = 300
 	// DefaultTracingConfigName is the default Tracing Config Name for Origins
 	DefaultTracingConfigName = "default"
 	// DefaultBackfillToleranceSecs is the default Backfill Tolerance setting for Origins
@@ -119,20 +193,90 @@ const (
 	DefaultKeepAliveTimeoutSecs = 300
 	// DefaultMaxIdleConns is the default number of Idle Connections in Origins' upstream client pools
 	DefaultMaxIdleConns = 20
+	// DefaultBootstrapWindowSecs is the default duration after startup that Origins fill cache misses from a bootstrap peer
+	DefaultBootstrapWindowSecs = 300
+	// DefaultClockSkewWarnSecs is the default estimated clock skew above which Origins log a warning
+	DefaultClockSkewWarnSecs = 60
+	// DefaultLookbackDeltaMS is the default duration that Prometheus looks back for a sample when evaluating
+	// an instant vector selector, matching the default of Prometheus' query.lookback-delta flag
+	DefaultLookbackDeltaMS = 300000
+	// DefaultRateLimitMinRemaining is the default estimate of an origin's remaining rate limit capacity
+	// at or below which upstream requests are paced
+	DefaultRateLimitMinRemaining = 1
+	// DefaultRateLimitMode is the default handling of upstream requests while an origin is throttled
+	DefaultRateLimitMode = ratelimit.ModeQueue
+	// DefaultRateLimitModeName is the default name of the handling of upstream requests while an origin is throttled
+	DefaultRateLimitModeName = "queue"
+	// DefaultRateLimitMaxWaitMS is the default maximum duration an upstream request is queued for a rate limit reset
+	DefaultRateLimitMaxWaitMS = 10000
+	// DefaultConcurrencyReservedShare is the default share of an origin's max concurrent requests that
+	// is reserved for the high priority class
+	DefaultConcurrencyReservedShare = 0.2
+	// DefaultConcurrencyMaxWaitMS is the default maximum duration an upstream request waits to be admitted
+	// under an origin's max concurrent requests
+	DefaultConcurrencyMaxWaitMS = 10000
 	// DefaultHealthCheckPath is the default value (noop) for Origins' Health Check Path
 	DefaultHealthCheckPath = "-"
 	// DefaultHealthCheckQuery is the default value (noop) for Origins' Health Check Query Parameters
 	DefaultHealthCheckQuery = "-"
 	// DefaultHealthCheckVerb is the default value (noop) for Origins' Health Check Verb
 	DefaultHealthCheckVerb = "-"
+	// DefaultHealthCheckBody is the default value (noop) for Origins' Health Check Request Body
+	DefaultHealthCheckBody = "-"
+	// DefaultHealthCheckTimeoutMS is the default timeout for Origins' Health Check requests
+	DefaultHealthCheckTimeoutMS = 3000
 	// DefaultConfigHandlerPath is the default value for the Trickster Config Printout Handler path
 	DefaultConfigHandlerPath = "/trickster/config"
 	// DefaultPingHandlerPath is the default value for the Trickster Config Ping Handler path
 	DefaultPingHandlerPath = "/trickster/ping"
+	// DefaultReadyHandlerPath is the default value for the Trickster Readiness Handler path
+	DefaultReadyHandlerPath = "/trickster/readyz"
+	// DefaultPreflightTimeoutMS is the default timeout for each startup pre-flight check
+	DefaultPreflightTimeoutMS = 5000
+	// DefaultPreflightPolicy is the default handling of a component's failed pre-flight check
+	DefaultPreflightPolicy = policy.Warn
+	// DefaultPreflightPolicyName is the default name of the handling of a component's failed pre-flight check
+	DefaultPreflightPolicyName = "warn"
 	// DefaultReloadHandlerPath defines the default path for the Reload Handler
 	DefaultReloadHandlerPath = "/trickster/config/reload"
 	// DefaultHealthHandlerPath defines the default path for the Health Handler
 	DefaultHealthHandlerPath = "/trickster/health"
+	// DefaultVersionHandlerPath defines the default path for the Version Handler
+	DefaultVersionHandlerPath = "/trickster/version"
+	// DefaultRefreshJobsHandlerPath defines the default path for the Refresh Jobs Handler on the reload listener
+	DefaultRefreshJobsHandlerPath = "/trickster/refresh_jobs"
+	// DefaultTopQueriesHandlerPath defines the default path for the Top Queries Handler on the reload listener
+	DefaultTopQueriesHandlerPath = "/trickster/debug/top-queries"
+	// DefaultTopQueriesSize is the default number of query fingerprints tracked for the Top Queries Handler
+	DefaultTopQueriesSize = 100
+	// DefaultInflightProcessingTimeoutMS is the default time a request waits for in-flight processing capacity
+	DefaultInflightProcessingTimeoutMS = 5000
+	// DefaultConfigDiffHandlerPath defines the default path for the Config Diff Handler on the reload listener
+	DefaultConfigDiffHandlerPath = "/trickster/debug/config-diff"
+	// DefaultResolveHandlerPath defines the default path for the Resolve Handler on the reload listener
+	DefaultResolveHandlerPath = "/trickster/debug/resolve"
+	// DefaultBackgroundTasksHandlerPath defines the default path for the Background Tasks Handler on the reload listener
+	DefaultBackgroundTasksHandlerPath = "/trickster/debug/background-tasks"
+	// DefaultOriginsHandlerPath defines the default path for the Origins Handler on the reload listener
+	DefaultOriginsHandlerPath = "/trickster/origins"
+	// DefaultInvalidateHandlerPath defines the default path for the Invalidate Handler on the reload listener
+	DefaultInvalidateHandlerPath = "/trickster/invalidate"
+	// DefaultLogLevelHandlerPath defines the default path for the Log Level Handler on the reload listener
+	DefaultLogLevelHandlerPath = "/trickster/log/level"
+	// DefaultCacheEventsHandlerPath defines the default path for the Cache Events Handler on the reload listener
+	DefaultCacheEventsHandlerPath = "/trickster/cache/events"
+	// DefaultCacheReadOnlyHandlerPath defines the default path for the Cache Read-Only Handler on the reload listener
+	DefaultCacheReadOnlyHandlerPath = "/trickster/cache/read-only"
+	// DefaultCacheEventsSize is the default number of cache mutation events retained for the Cache Events Handler
+	DefaultCacheEventsSize = 10000
+	// DefaultRequestSamplingSize is the default number of recently-seen requests retained for the Config Diff Handler
+	DefaultRequestSamplingSize = 1000
+	// DefaultRefreshJobIntervalSecs is the default interval at which Refresh Jobs are executed
+	DefaultRefreshJobIntervalSecs = 300
+	// DefaultRefreshJobJitterMS is the default maximum random delay added to each Refresh Job execution
+	DefaultRefreshJobJitterMS = 5000
+	// DefaultRefreshJobMaxBackoffSecs is the default maximum delay between retries of a failing Refresh Job
+	DefaultRefreshJobMaxBackoffSecs = 3600
 	// DefaultMaxRuleExecutions is the default value for the number of allowed Rule executions per Request
 	DefaultMaxRuleExecutions = 16
 	// DefaultPprofServerName defines the default Pprof Server Name
@@ -141,6 +285,18 @@ const (
 	DefaultForwardedHeaders = "standard"
 )
 
+// DefaultCacheKeyExcludeParams returns a list of query parameters that are excluded from cache keys
+// when a path does not configure its own list, such as cache-busting parameters added by clients
+func DefaultCacheKeyExcludeParams() []string {
+	return []string{"_"}
+}
+
+// DefaultLogRedactKeys returns the names of the log event keys, headers and URL query parameters
+// whose values are redacted from log events
+func DefaultLogRedactKeys() []string {
+	return []string{"authorization", "password", "token", "api_key"}
+}
+
 // DefaultCompressableTypes returns a list of types that Trickster should compress before caching
 func DefaultCompressableTypes() []string {
 	return []string{
@@ -0,0 +1,263 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"net"
+	goruntime "runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/runtime"
+)
+
+// StartupDiagnostics describes what a running Trickster instance is made of.
+// It is safe for external exposure, as credentials are never included.
+type StartupDiagnostics struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	CommitID   string `json:"commitID"`
+	BuildTime  string `json:"buildTime"`
+	GoVersion  string `json:"goVersion"`
+	GoArch     string `json:"goArch"`
+	ConfigFile string `json:"configFile"`
+	ConfigHash string `json:"configHash"`
+	InstanceID int    `json:"instanceID"`
+	// Listeners maps a listener name (e.g., "http") to its listen address
+	Listeners map[string]string `json:"listeners"`
+	// Origins maps an origin name to its provider and cache binding
+	Origins map[string]*OriginDiagnostics `json:"origins"`
+	// Caches maps a cache name to its provider
+	Caches map[string]string `json:"caches"`
+	// Tracers maps a tracing config name to its exporter type and collector
+	Tracers map[string]*TracerDiagnostics `json:"tracers"`
+	// MetricsEndpoint is the address from which the /metrics path is served
+	MetricsEndpoint string `json:"metricsEndpoint"`
+}
+
+// OriginDiagnostics describes a configured Origin for StartupDiagnostics
+type OriginDiagnostics struct {
+	OriginType string `json:"originType"`
+	Host       string `json:"host"`
+	CacheName  string `json:"cacheName"`
+	IsDefault  bool   `json:"isDefault"`
+}
+
+// TracerDiagnostics describes a configured Tracer for StartupDiagnostics
+type TracerDiagnostics struct {
+	TracerType   string `json:"tracerType"`
+	CollectorURL string `json:"collectorURL"`
+}
+
+// StartupDiagnostics returns the StartupDiagnostics for the subject config
+func (c *Config) StartupDiagnostics() *StartupDiagnostics {
+
+	sd := &StartupDiagnostics{
+		Name:       runtime.ApplicationName,
+		Version:    runtime.ApplicationVersion,
+		CommitID:   runtime.ApplicationCommitID,
+		BuildTime:  runtime.ApplicationBuildTime,
+		GoVersion:  goruntime.Version(),
+		GoArch:     goruntime.GOARCH,
+		ConfigFile: c.ConfigFilePath(),
+		ConfigHash: c.ConfigFileHash(),
+		Listeners:  make(map[string]string),
+		Origins:    make(map[string]*OriginDiagnostics),
+		Caches:     make(map[string]string),
+		Tracers:    make(map[string]*TracerDiagnostics),
+	}
+
+	if c.Main != nil {
+		sd.InstanceID = c.Main.InstanceID
+	}
+
+	if c.Frontend != nil {
+		if c.Frontend.ListenPort > 0 {
+			sd.Listeners["http"] = listenAddress(c.Frontend.ListenAddress, c.Frontend.ListenPort)
+		}
+		if c.Frontend.ServeTLS && c.Frontend.TLSListenPort > 0 {
+			sd.Listeners["tls"] = listenAddress(c.Frontend.TLSListenAddress, c.Frontend.TLSListenPort)
+		}
+	}
+
+	if c.Metrics != nil && c.Metrics.ListenPort > 0 {
+		sd.MetricsEndpoint = listenAddress(c.Metrics.ListenAddress, c.Metrics.ListenPort)
+		sd.Listeners["metrics"] = sd.MetricsEndpoint
+	}
+
+	if c.ReloadConfig != nil && c.ReloadConfig.ListenPort > 0 {
+		sd.Listeners["reload"] = listenAddress(c.ReloadConfig.ListenAddress, c.ReloadConfig.ListenPort)
+	}
+
+	for k, o := range c.Origins {
+		if o == nil {
+			continue
+		}
+		od := &OriginDiagnostics{OriginType: o.OriginType, Host: o.Host, IsDefault: o.IsDefault}
+		if o.OriginType != "rule" {
+			od.CacheName = o.CacheName
+		}
+		sd.Origins[k] = od
+	}
+
+	for k, cc := range c.Caches {
+		if cc != nil {
+			sd.Caches[k] = cc.CacheType
+		}
+	}
+
+	for k, tc := range c.TracingConfigs {
+		if tc != nil {
+			sd.Tracers[k] = &TracerDiagnostics{TracerType: tc.TracerType,
+				CollectorURL: stripUserInfo(tc.CollectorURL)}
+		}
+	}
+
+	return sd
+}
+
+// Pairs returns the StartupDiagnostics as a flat map suitable for structured logging
+func (sd *StartupDiagnostics) Pairs() map[string]interface{} {
+
+	origins := make([]string, 0, len(sd.Origins))
+	for k, o := range sd.Origins {
+		s := fmt.Sprintf("%s:%s", k, o.OriginType)
+		if o.CacheName != "" {
+			s += ":" + o.CacheName
+		}
+		origins = append(origins, s)
+	}
+
+	caches := make([]string, 0, len(sd.Caches))
+	for k, v := range sd.Caches {
+		caches = append(caches, k+":"+v)
+	}
+
+	tracers := make([]string, 0, len(sd.Tracers))
+	for k, t := range sd.Tracers {
+		s := k + ":" + t.TracerType
+		if t.CollectorURL != "" {
+			s += ":" + t.CollectorURL
+		}
+		tracers = append(tracers, s)
+	}
+
+	listeners := make([]string, 0, len(sd.Listeners))
+	for k, v := range sd.Listeners {
+		listeners = append(listeners, k+"="+v)
+	}
+
+	return map[string]interface{}{
+		"name":            sd.Name,
+		"version":         sd.Version,
+		"commitID":        sd.CommitID,
+		"buildTime":       sd.BuildTime,
+		"goVersion":       sd.GoVersion,
+		"goArch":          sd.GoArch,
+		"config":          sd.ConfigFile,
+		"configHash":      sd.ConfigHash,
+		"instanceID":      sd.InstanceID,
+		"listeners":       sortedJoin(listeners),
+		"origins":         sortedJoin(origins),
+		"caches":          sortedJoin(caches),
+		"tracers":         sortedJoin(tracers),
+		"metricsEndpoint": sd.MetricsEndpoint,
+	}
+}
+
+// StartupSummary returns a summary of what the subject config loaded, as a flat map suitable
+// for structured logging once startup completes: the counts of origins by type and of the path
+// configs of each origin, the cache backends in use by the origins, the listeners, whether TLS
+// is served, the log level and the config file. Like StartupDiagnostics, it includes no secrets
+func (c *Config) StartupSummary() map[string]interface{} {
+
+	sd := c.StartupDiagnostics()
+
+	types := make(map[string]int)
+	paths := make([]string, 0, len(c.Origins))
+	backends := make(map[string]bool)
+	for k, o := range c.Origins {
+		if o == nil {
+			continue
+		}
+		types[o.OriginType]++
+		paths = append(paths, k+"="+strconv.Itoa(len(o.Paths)))
+		if o.OriginType == "rule" {
+			continue
+		}
+		if cc, ok := c.Caches[o.CacheName]; ok && cc != nil {
+			backends[cc.CacheType] = true
+		}
+	}
+
+	originTypes := make([]string, 0, len(types))
+	for k, n := range types {
+		originTypes = append(originTypes, k+"="+strconv.Itoa(n))
+	}
+
+	cacheBackends := make([]string, 0, len(backends))
+	for k := range backends {
+		cacheBackends = append(cacheBackends, k)
+	}
+
+	listeners := make([]string, 0, len(sd.Listeners))
+	for k, v := range sd.Listeners {
+		listeners = append(listeners, k+"="+v)
+	}
+
+	var logLevel string
+	if c.Logging != nil {
+		logLevel = c.Logging.LogLevel
+	}
+
+	return map[string]interface{}{
+		"origins":       len(sd.Origins),
+		"originTypes":   sortedJoin(originTypes),
+		"originPaths":   sortedJoin(paths),
+		"cacheBackends": sortedJoin(cacheBackends),
+		"listeners":     sortedJoin(listeners),
+		"tls":           c.Frontend != nil && c.Frontend.ServeTLS,
+		"logLevel":      logLevel,
+		"config":        sd.ConfigFile,
+	}
+}
+
+func sortedJoin(s []string) string {
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func listenAddress(address string, port int) string {
+	return net.JoinHostPort(address, strconv.Itoa(port))
+}
+
+// stripUserInfo removes any user:password@ portion from the provided URL string
+func stripUserInfo(u string) string {
+	i := strings.Index(u, "://")
+	if i < 0 {
+		return u
+	}
+	rest := u[i+3:]
+	if j := strings.Index(rest, "@"); j >= 0 {
+		if k := strings.Index(rest, "/"); k < 0 || j < k {
+			return u[:i+3] + rest[j+1:]
+		}
+	}
+	return u
+}
@@ -17,8 +17,16 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
 )
 
 const (
@@ -28,22 +36,28 @@ const (
 	evProxyPort   = "TRK_PROXY_PORT"
 	evMetricsPort = "TRK_METRICS_PORT"
 	evLogLevel    = "TRK_LOG_LEVEL"
+
+	// evPrefix is the prefix of the environment variables that override config options
+	evPrefix = "TRK_"
 )
 
 func (c *Config) loadEnvVars() {
 	// Origin
 	if x := os.Getenv(evOriginURL); x != "" {
 		c.providedOriginURL = x
+		c.setSource("origins.default.origin_url", SourceEnv)
 	}
 
 	if x := os.Getenv(evOriginType); x != "" {
 		c.providedOriginType = x
+		c.setSource("origins.default.origin_type", SourceEnv)
 	}
 
 	// Proxy Port
 	if x := os.Getenv(evProxyPort); x != "" {
 		if y, err := strconv.ParseInt(x, 10, 64); err == nil {
 			c.Frontend.ListenPort = int(y)
+			c.setSource("frontend.listen_port", SourceEnv)
 		}
 	}
 
@@ -51,12 +65,246 @@ func (c *Config) loadEnvVars() {
 	if x := os.Getenv(evMetricsPort); x != "" {
 		if y, err := strconv.ParseInt(x, 10, 64); err == nil {
 			c.Metrics.ListenPort = int(y)
+			c.setSource("metrics.listen_port", SourceEnv)
 		}
 	}
 
 	// LogLevel
 	if x := os.Getenv(evLogLevel); x != "" {
 		c.Logging.LogLevel = x
+		c.setSource("logging.log_level", SourceEnv)
+	}
+
+}
+
+// durationType is the type of the config options that are parsed as durations, such as "30s"
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// envValue is the value of a config option that is set by an environment variable, along with
+// the TOML keys of the option
+type envValue struct {
+	keys  []string
+	value interface{}
+}
+
+// envDocument returns the TOML document with the config options that are named by environment
+// variables set to their values, such as TRK_LOGGING_LOG_LEVEL for log_level in the [logging]
+// section, or TRK_ORIGINS_<NAME>_ORIGIN_URL for origin_url in the [origins.<name>] section,
+// along with the TOML key paths of the options, by the names of their variables. A variable's
+// name is TRK_ followed by the path of the option's TOML keys, uppercased and joined by
+// underscores, with any other characters of the names of origins, caches and other named
+// sections replaced by underscores. Only the named sections that are configured by the
+// document or the defaults can be overridden. The options are overridden in the document, rather
+// than in a loaded config, so that they are processed and validated like those of the document.
+// It returns an error that names the variable whose value can't be converted to the type of its
+// option
+func envDocument(document string) (string, map[string]string, error) {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 && strings.HasPrefix(kv, evPrefix) {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	if len(env) == 0 {
+		return document, nil, nil
+	}
+
+	// the named sections and the types of the options are those of the document's config. A
+	// document that can't be decoded is returned as is, for its error to be reported when loaded
+	c := NewConfig()
+	if _, err := toml.Decode(document, c); err != nil {
+		return document, nil, nil
+	}
+	overrides := make(map[string]string)
+	values := make(map[string]envValue)
+	if err := overrideFromEnv(reflect.ValueOf(c).Elem(), nil, strings.TrimSuffix(evPrefix, "_"),
+		env, overrides, values); err != nil {
+		return document, nil, err
+	}
+	if len(values) == 0 {
+		return document, nil, nil
+	}
+
+	doc := make(map[string]interface{})
+	if _, err := toml.Decode(document, &doc); err != nil {
+		return document, nil, nil
+	}
+	for _, v := range values {
+		t := doc
+		for _, k := range v.keys[:len(v.keys)-1] {
+			st, ok := t[k].(map[string]interface{})
+			if !ok {
+				st = make(map[string]interface{})
+				t[k] = st
+			}
+			t = st
+		}
+		t[v.keys[len(v.keys)-1]] = v.value
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+		return document, nil, err
 	}
+	return buf.String(), overrides, nil
+}
 
+// overrideFromEnv sets the options of the struct v whose environment variables are set, and
+// records the key path of each in overrides, by the name of its variable, and its value in values,
+// by its key path
+func overrideFromEnv(v reflect.Value, keys []string, name string, env, overrides map[string]string,
+	values map[string]envValue) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := strings.Split(f.Tag.Get("toml"), ",")[0]
+		if key == "" || key == "-" || f.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		fkeys, fname := append(keys[:len(keys):len(keys)], key), name+"_"+envName(key)
+		switch {
+		case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct:
+			if fv.IsNil() {
+				continue
+			}
+			if err := overrideFromEnv(fv.Elem(), fkeys, fname, env, overrides, values); err != nil {
+				return err
+			}
+		case fv.Kind() == reflect.Struct && fv.Type() != durationType:
+			if err := overrideFromEnv(fv, fkeys, fname, env, overrides, values); err != nil {
+				return err
+			}
+		case fv.Kind() == reflect.Map && fv.Type().Key().Kind() == reflect.String &&
+			fv.Type().Elem().Kind() == reflect.Ptr &&
+			fv.Type().Elem().Elem().Kind() == reflect.Struct:
+			// the named sections are overridden in the order of their names, so that the
+			// first error is always that of the same variable
+			names := make([]string, 0, fv.Len())
+			for _, k := range fv.MapKeys() {
+				names = append(names, k.String())
+			}
+			sort.Strings(names)
+			for _, k := range names {
+				e := fv.MapIndex(reflect.ValueOf(k))
+				if e.IsNil() {
+					continue
+				}
+				if err := overrideFromEnv(e.Elem(), append(fkeys[:len(fkeys):len(fkeys)], k),
+					fname+"_"+envName(k), env, overrides, values); err != nil {
+					return err
+				}
+			}
+		default:
+			x, ok := env[fname]
+			if !ok {
+				continue
+			}
+			if ok, err := setEnvValue(fv, x); err != nil {
+				return fmt.Errorf("invalid value for environment variable %s: %s", fname, err)
+			} else if ok {
+				path := keyPath(fkeys)
+				overrides[fname] = path
+				values[path] = envValue{keys: fkeys, value: fv.Interface()}
+			}
+		}
+	}
+	return nil
+}
+
+// setEnvValue sets the option to the value of its environment variable, converted to the type
+// of the option, and returns false when the type of the option can't be set from a variable
+func setEnvValue(v reflect.Value, x string) (bool, error) {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(x)
+		if err != nil {
+			return false, err
+		}
+		v.SetInt(int64(d))
+		return true, nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(x)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(x)
+		if err != nil {
+			return false, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(x, 10, v.Type().Bits())
+		if err != nil {
+			return false, err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(x, 10, v.Type().Bits())
+		if err != nil {
+			return false, err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(x, v.Type().Bits())
+		if err != nil {
+			return false, err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		// lists of strings are comma-separated
+		if v.Type().Elem().Kind() != reflect.String {
+			return false, nil
+		}
+		var l []string
+		for _, s := range strings.Split(x, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				l = append(l, s)
+			}
+		}
+		sv := reflect.MakeSlice(v.Type(), len(l), len(l))
+		for i, s := range l {
+			sv.Index(i).SetString(s)
+		}
+		v.Set(sv)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// envName returns the TOML key as it appears in environment variable names, uppercased with
+// its characters other than letters and digits replaced by underscores
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// joinKey appends the key to the path of TOML keys, quoting it when it is not a bare key
+func joinKey(path, key string) string {
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			r == '_' || r == '-') {
+			key = strconv.Quote(key)
+			break
+		}
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// EnvOverrides returns the TOML key paths of the config options that were set by environment
+// variables, by the names of their variables
+func (c *Config) EnvOverrides() map[string]string {
+	if c.Main == nil {
+		return nil
+	}
+	return c.Main.envOverrides
 }
@@ -18,8 +18,12 @@ package config
 
 import (
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/types"
 )
 
 func TestLoadEnvVars(t *testing.T) {
@@ -72,3 +76,179 @@ func TestLoadEnvVars(t *testing.T) {
 	os.Unsetenv(evLogLevel)
 
 }
+
+func TestLoadEnvOverrides(t *testing.T) {
+
+	env := map[string]string{
+		"TRK_ORIGINS_TEST_ORIGIN_URL":      "http://2:9090/api",
+		"TRK_CACHES_TEST_REDIS_ENDPOINT":   "redis:6379",
+		"TRK_CACHES_TEST_REDIS_ENDPOINTS":  "redis-1:6379, redis-2:6379",
+		"TRK_CACHES_TEST_REDIS_DB":         "3",
+		"TRK_CACHES_TEST_REDIS_PASSWORD":   "env_password",
+		"TRK_LOGGING_LOG_ALSO_STDOUT":      "true",
+		"TRK_FRONTEND_LISTEN_PORT":         "9999",
+		"TRK_LOGGING_LOG_LEVEL":            "debug",
+		"TRK_ORIGINS_UNCONFIGURED_TIMEOUT": "5",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	// flags take precedence over env vars, which take precedence over the file
+	a := []string{"-config", "../../testdata/test.redis-standard.conf", "-log-level", "warn"}
+	conf, _, err := Load("trickster-test", "0", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := conf.Origins["test"]
+	if o.OriginURL != "http://2:9090/api" || o.Host != "2:9090" || o.PathPrefix != "/api" {
+		t.Errorf("unexpected origin url %s", o.OriginURL)
+	}
+	// options that are not overridden keep the values of the file
+	if o.MaxObjectSizeBytes != 999 {
+		t.Errorf("expected %d got %d", 999, o.MaxObjectSizeBytes)
+	}
+
+	c := conf.Caches["test"]
+	if c.Redis.Endpoint != "redis:6379" {
+		t.Errorf("expected %s got %s", "redis:6379", c.Redis.Endpoint)
+	}
+	if !reflect.DeepEqual(c.Redis.Endpoints, []string{"redis-1:6379", "redis-2:6379"}) {
+		t.Errorf("unexpected endpoints %v", c.Redis.Endpoints)
+	}
+	if c.Redis.DB != 3 {
+		t.Errorf("expected %d got %d", 3, c.Redis.DB)
+	}
+	if !conf.Logging.LogAlsoStdout {
+		t.Errorf("expected %t got %t", true, conf.Logging.LogAlsoStdout)
+	}
+	if conf.Frontend.ListenPort != 9999 {
+		t.Errorf("expected %d got %d", 9999, conf.Frontend.ListenPort)
+	}
+	if conf.Logging.LogLevel != "warn" {
+		t.Errorf("expected %s got %s", "warn", conf.Logging.LogLevel)
+	}
+
+	ov := conf.EnvOverrides()
+	if len(ov) != 8 {
+		t.Errorf("expected %d got %d", 8, len(ov))
+	}
+	if v := ov["TRK_CACHES_TEST_REDIS_ENDPOINT"]; v != "caches.test.redis.endpoint" {
+		t.Errorf("expected %s got %s", "caches.test.redis.endpoint", v)
+	}
+
+	// the config dump notes the options set by env vars, without the values of secrets
+	s := conf.String()
+	if !strings.Contains(s,
+		`endpoint = "redis:6379" # env TRK_CACHES_TEST_REDIS_ENDPOINT`+"\n") ||
+		!strings.Contains(s, `log_level = "warn" # flag`+"\n") {
+		t.Errorf("expected env overrides in %s", s)
+	}
+	if strings.Contains(s, "env_password") {
+		t.Errorf("unexpected password in %s", s)
+	}
+
+	// the error of a value that can't be converted names its variable
+	os.Setenv("TRK_CACHES_TEST_REDIS_DB", "x")
+	_, _, err = Load("trickster-test", "0", a)
+	if err == nil || !strings.Contains(err.Error(), "TRK_CACHES_TEST_REDIS_DB") {
+		t.Errorf("expected error for %s got %v", "TRK_CACHES_TEST_REDIS_DB", err)
+	}
+}
+
+func TestSetEnvValue(t *testing.T) {
+
+	var d time.Duration
+	if ok, err := setEnvValue(reflect.ValueOf(&d).Elem(), "1m30s"); !ok || err != nil {
+		t.Errorf("unexpected result %t %v", ok, err)
+	} else if d != 90*time.Second {
+		t.Errorf("expected %s got %s", 90*time.Second, d)
+	}
+	if _, err := setEnvValue(reflect.ValueOf(&d).Elem(), "90"); err == nil {
+		t.Error("expected error for invalid duration")
+	}
+
+	var f float64
+	if ok, err := setEnvValue(reflect.ValueOf(&f).Elem(), "0.25"); !ok || err != nil || f != 0.25 {
+		t.Errorf("unexpected result %t %v %g", ok, err, f)
+	}
+
+	var b bool
+	if _, err := setEnvValue(reflect.ValueOf(&b).Elem(), "maybe"); err == nil {
+		t.Error("expected error for invalid bool")
+	}
+
+	var i8 int8
+	if _, err := setEnvValue(reflect.ValueOf(&i8).Elem(), "1000"); err == nil {
+		t.Error("expected error for out of range int")
+	}
+
+	// maps of values can't be set by env vars
+	var m map[string]string
+	if ok, err := setEnvValue(reflect.ValueOf(&m).Elem(), "a"); ok || err != nil {
+		t.Errorf("unexpected result %t %v", ok, err)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		key, expected string
+	}{
+		{"origin_url", "ORIGIN_URL"},
+		{"my-origin.1", "MY_ORIGIN_1"},
+		{"/api/v1", "_API_V1"},
+	}
+	for _, test := range tests {
+		if v := envName(test.key); v != test.expected {
+			t.Errorf("expected %s got %s", test.expected, v)
+		}
+	}
+}
+
+func TestLoadEnvOverridesProcessed(t *testing.T) {
+
+	env := map[string]string{
+		"TRK_ORIGINS_DEFAULT_ORIGIN_URL":  "http://1:9090/",
+		"TRK_ORIGINS_DEFAULT_ORIGIN_TYPE": "prometheus",
+		"TRK_CACHES_DEFAULT_CACHE_TYPE":   "redis",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	// options that are derived from overridden options, such as the type id of a cache, are
+	// derived from their overridden values, including those of the defaults
+	a := []string{}
+	conf, _, err := Load("trickster-test", "0", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := conf.Caches["default"]
+	if c.CacheType != "redis" || c.CacheTypeID != types.CacheTypeRedis {
+		t.Errorf("expected %s got %s %d", "redis", c.CacheType, c.CacheTypeID)
+	}
+	if o := conf.Origins["default"]; o.Host != "1:9090" {
+		t.Errorf("expected %s got %s", "1:9090", o.Host)
+	}
+	if v := conf.EnvOverrides()["TRK_CACHES_DEFAULT_CACHE_TYPE"]; v != "caches.default.cache_type" {
+		t.Errorf("expected %s got %s", "caches.default.cache_type", v)
+	}
+
+	// overridden options are validated like those of the file
+	os.Setenv("TRK_CACHES_DEFAULT_CACHE_TYPE", "nonsense")
+	_, _, err = Load("trickster-test", "0", a)
+	if err == nil || !strings.Contains(err.Error(), "invalid cache type [nonsense]") {
+		t.Errorf("expected error for cache type %s got %v", "nonsense", err)
+	}
+}
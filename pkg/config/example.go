@@ -0,0 +1,187 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	cache "github.com/tricksterproxy/trickster/pkg/cache/options"
+	ct "github.com/tricksterproxy/trickster/pkg/cache/types"
+	origins "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	rule "github.com/tricksterproxy/trickster/pkg/proxy/origins/rule/options"
+	ot "github.com/tricksterproxy/trickster/pkg/proxy/origins/types"
+)
+
+// exampleOriginURLs are the upstream URLs of the example origins, by origin type. The example
+// origin of the rule origin type has no upstream URL
+var exampleOriginURLs = map[string]string{
+	ot.OriginTypeRPC:        "http://www.example.com",
+	ot.OriginTypeRule:       "",
+	ot.OriginTypePrometheus: "http://prometheus:9090",
+	ot.OriginTypeInfluxDB:   "http://influxdb:8086",
+	ot.OriginTypeIronDB:     "http://irondb:8112",
+	ot.OriginTypeClickHouse: "http://clickhouse:8123",
+}
+
+// omitted marks the lines of the options that are omitted from the default document
+const omitted = "\x00"
+
+// exampleRuleName is the name of the rule of the example origin of the rule origin type
+const exampleRuleName = "example"
+
+// exampleConfig returns the default config, without its default origin, and with an example
+// origin of each built-in origin type and an example cache of each cache type other than that
+// of the default cache, each named by its type. Caches that no origin uses are not loaded, so
+// each example cache is used by an example origin, in the order of their names, and the
+// remaining example origins use the default cache
+func exampleConfig() *Config {
+	c := NewConfig()
+	delete(c.Origins, "default")
+	// the server name defaults to the hostname of the running instance
+	c.Main.ServerName = ""
+
+	for name, u := range exampleOriginURLs {
+		o := origins.NewOptions()
+		o.OriginType = name
+		if name == ot.OriginTypeRule {
+			o.RuleName = exampleRuleName
+			c.Rules = map[string]*rule.Options{
+				exampleRuleName: {
+					NextRoute:   ot.OriginTypeRPC,
+					InputSource: "header",
+					InputKey:    "Host",
+					InputType:   "string",
+					Operation:   "prefix",
+				},
+			}
+		} else {
+			o.OriginURL = u
+		}
+		c.Origins[name] = o
+	}
+
+	var cacheNames []string
+	for t, name := range ct.Values {
+		if name == c.Caches["default"].CacheType {
+			continue
+		}
+		cc := cache.NewOptions()
+		cc.CacheType = name
+		cc.CacheTypeID = t
+		c.Caches[name] = cc
+		cacheNames = append(cacheNames, name)
+	}
+	sort.Strings(cacheNames)
+
+	originNames := make([]string, 0, len(c.Origins))
+	for k := range c.Origins {
+		originNames = append(originNames, k)
+	}
+	sort.Strings(originNames)
+	for i, k := range originNames {
+		if i < len(cacheNames) {
+			c.Origins[k].CacheName = cacheNames[i]
+		}
+	}
+
+	return c
+}
+
+// DefaultDocument returns a TOML document of the default config, with an example origin of each
+// built-in origin type and an example cache of each cache type. It is generated from the options
+// and their default values, and each of its options notes whether it is a default or example
+// value. The options that do not apply to the origin type of an example origin are omitted
+func DefaultDocument() (string, error) {
+	c := exampleConfig()
+	document := c.document()
+	var err error
+
+	examples := map[string]bool{}
+	for k, o := range c.Origins {
+		examples[joinKey(joinKey("origins", k), "origin_type")] = true
+		if o.OriginURL != "" {
+			examples[joinKey(joinKey("origins", k), "origin_url")] = true
+		}
+		if o.CacheName != "default" {
+			examples[joinKey(joinKey("origins", k), "cache_name")] = true
+		}
+		if o.RuleName != "" {
+			examples[joinKey(joinKey("origins", k), "rule_name")] = true
+		}
+	}
+	for k := range c.Caches {
+		examples[joinKey(joinKey("caches", k), "cache_type")] = true
+	}
+	for _, k := range []string{"next_route", "input_source", "input_key", "input_type",
+		"operation"} {
+		examples[joinKey(joinKey("rules", exampleRuleName), k)] = true
+	}
+
+	// the options that don't apply to the origin types of the example origins are omitted, so
+	// that the document passes validation
+	omit := make(map[string]bool)
+	var md toml.MetaData
+	if md, err = toml.Decode(document, NewConfig()); err != nil {
+		return "", err
+	}
+	for _, f := range c.findings(&md, document, false) {
+		omit[f.Path] = true
+	}
+
+	lines := strings.Split(document, "\n")
+	headers := make(map[int]string)
+	scanKeys(document, func(i int, k []string, table bool) {
+		path := keyPath(k)
+		switch {
+		case omit[path]:
+			lines[i] = omitted
+		case table && len(k) == 2 && k[0] == "origins":
+			headers[i] = "an example origin of origin_type " + c.Origins[k[1]].OriginType
+		case table && len(k) == 2 && k[0] == "caches" && k[1] == "default":
+			headers[i] = "the default cache, of cache_type " + c.Caches[k[1]].CacheType
+		case table && len(k) == 2 && k[0] == "caches":
+			headers[i] = "an example cache of cache_type " + k[1]
+		case table && len(k) == 2 && k[0] == "rules":
+			headers[i] = "an example rule, which the example origin of origin_type rule uses"
+		case table:
+		case examples[path]:
+			lines[i] += " # example"
+		default:
+			lines[i] += " # default"
+		}
+	})
+
+	var b strings.Builder
+	b.WriteString("# Trickster's default configuration, including an example origin of each\n" +
+		"# origin_type and an example cache of each cache_type. Options noted as\n" +
+		"# example are not defaults, and are only set by the examples.\n\n")
+	for i, line := range lines {
+		if line == omitted {
+			continue
+		}
+		if h, ok := headers[i]; ok {
+			b.WriteString(line[:len(line)-len(strings.TrimLeft(line, " "))] + "# " + h + "\n")
+		}
+		b.WriteString(line)
+		if i < len(lines)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), nil
+}
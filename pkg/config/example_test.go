@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	ct "github.com/tricksterproxy/trickster/pkg/cache/types"
+	ot "github.com/tricksterproxy/trickster/pkg/proxy/origins/types"
+)
+
+func TestDefaultDocument(t *testing.T) {
+
+	document, err := DefaultDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the document loads without findings, to a config identical to the defaults
+	conf, err := LoadDocument(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.LoaderWarnings) > 0 {
+		t.Errorf("unexpected warnings %v", conf.LoaderWarnings)
+	}
+	expected := exampleConfig()
+	// an empty cache_key_prefix is the origin's host once loaded
+	for k, o := range expected.Origins {
+		o.CacheKeyPrefix = conf.Origins[k].Host
+	}
+	if !configuredEqual(reflect.ValueOf(conf).Elem(), reflect.ValueOf(expected).Elem()) {
+		t.Errorf("expected %s got %s", expected.document(), conf.document())
+	}
+
+	for _, name := range []string{ot.OriginTypeRPC, ot.OriginTypeRule, ot.OriginTypePrometheus,
+		ot.OriginTypeInfluxDB, ot.OriginTypeIronDB, ot.OriginTypeClickHouse} {
+		if _, ok := conf.Origins[name]; !ok {
+			t.Errorf("missing example origin for origin_type %s", name)
+		}
+	}
+	cacheTypes := make(map[string]bool)
+	for _, c := range conf.Caches {
+		cacheTypes[c.CacheType] = true
+	}
+	for _, name := range ct.Values {
+		if !cacheTypes[name] {
+			t.Errorf("missing example cache for cache_type %s", name)
+		}
+	}
+
+	for _, line := range []string{
+		"  [origins.prometheus]\n",
+		`    origin_url = "http://prometheus:9090" # example` + "\n",
+		`  listen_port = 8480 # default` + "\n",
+		"  # an example cache of cache_type redis\n",
+	} {
+		if !strings.Contains(document, line) {
+			t.Errorf("missing %s in %s", line, document)
+		}
+	}
+	// the timeseries options don't apply to the rpc origin type
+	rpc := document[strings.Index(document, "[origins.rpc]"):]
+	rpc = rpc[:strings.Index(rpc, "[origins.rule]")]
+	if strings.Contains(rpc, "timeseries_ttl_secs") {
+		t.Errorf("unexpected timeseries option for origin_type rpc in %s", rpc)
+	}
+}
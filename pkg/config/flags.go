@@ -27,6 +27,8 @@ const (
 	cfConfig      = "config"
 	cfVersion     = "version"
 	cfValidate    = "validate-config"
+	cfCheck       = "validate"
+	cfDefaults    = "print-default-config"
 	cfLogLevel    = "log-level"
 	cfInstanceID  = "instance-id"
 	cfOrigin      = "origin-url"
@@ -37,16 +39,17 @@ const (
 
 // Flags holds the values for whitelisted flags
 type Flags struct {
-	PrintVersion      bool
-	ValidateConfig    bool
-	customPath        bool
-	ProxyListenPort   int
-	MetricsListenPort int
-	InstanceID        int
-	ConfigPath        string
-	Origin            string
-	OriginType        string
-	LogLevel          string
+	PrintVersion       bool
+	PrintDefaultConfig bool
+	ValidateConfig     bool
+	customPath         bool
+	ProxyListenPort    int
+	MetricsListenPort  int
+	InstanceID         int
+	ConfigPath         string
+	Origin             string
+	OriginType         string
+	LogLevel           string
 }
 
 func parseFlags(applicationName string, arguments []string) (*Flags, error) {
@@ -56,8 +59,12 @@ func parseFlags(applicationName string, arguments []string) (*Flags, error) {
 
 	flagSet.BoolVar(&flags.PrintVersion, cfVersion, false,
 		"Prints the Trickster version")
+	flagSet.BoolVar(&flags.PrintDefaultConfig, cfDefaults, false,
+		"Prints the default Trickster config, with an example of each origin and cache type")
 	flagSet.BoolVar(&flags.ValidateConfig, cfValidate, false,
 		"Validates a Trickster config and exits without running the server")
+	flagSet.BoolVar(&flags.ValidateConfig, cfCheck, false,
+		"Same as -"+cfValidate)
 	flagSet.StringVar(&flags.ConfigPath, cfConfig, "",
 		"Path to Trickster Config File")
 	flagSet.StringVar(&flags.LogLevel, cfLogLevel, "",
@@ -90,23 +97,29 @@ func parseFlags(applicationName string, arguments []string) (*Flags, error) {
 func (c *Config) loadFlags(flags *Flags) {
 	if len(flags.Origin) > 0 {
 		c.providedOriginURL = flags.Origin
+		c.setSource("origins.default.origin_url", SourceFlag)
 	}
 	if len(flags.OriginType) > 0 {
 		c.providedOriginType = flags.OriginType
+		c.setSource("origins.default.origin_type", SourceFlag)
 	}
 	if flags.ProxyListenPort > 0 {
 		c.Frontend.ListenPort = flags.ProxyListenPort
+		c.setSource("frontend.listen_port", SourceFlag)
 	}
 	if flags.MetricsListenPort > 0 {
 		c.Metrics.ListenPort = flags.MetricsListenPort
+		c.setSource("metrics.listen_port", SourceFlag)
 	}
 	// if flags.ReloadListenPort > 0 {
 	// 	c.Main.Reload.ListenPort = flags.ReloadListenPort
 	// }
 	if flags.LogLevel != "" {
 		c.Logging.LogLevel = flags.LogLevel
+		c.setSource("logging.log_level", SourceFlag)
 	}
 	if flags.InstanceID > 0 {
 		c.Main.InstanceID = flags.InstanceID
+		c.setSource("main.instance_id", SourceFlag)
 	}
 }
@@ -57,3 +57,30 @@ func TestLoadFlags(t *testing.T) {
 		t.Errorf("wanted \"%d\". got \"%d\".", 9092, c.Metrics.ListenPort)
 	}
 }
+
+func TestParseFlagsValidate(t *testing.T) {
+	for _, f := range []string{"-validate", "-validate-config"} {
+		flags, err := parseFlags("trickster-test", []string{f})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !flags.ValidateConfig {
+			t.Errorf("%s: expected %t got %t", f, true, flags.ValidateConfig)
+		}
+	}
+}
+
+func TestParseFlagsPrintDefaultConfig(t *testing.T) {
+	flags, err := parseFlags("trickster-test", []string{"-print-default-config"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !flags.PrintDefaultConfig {
+		t.Errorf("expected %t got %t", true, flags.PrintDefaultConfig)
+	}
+	// the default config is printed without loading any config
+	c, _, err := Load("trickster-test", "0", []string{"-print-default-config"})
+	if c != nil || err != nil {
+		t.Errorf("expected nil config and error, got %v %v", c, err)
+	}
+}
@@ -20,13 +20,18 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight/policy"
+	"github.com/tricksterproxy/trickster/pkg/proxy/ratelimit"
 )
 
 // Load returns the Application Configuration, starting with a default config,
-// then overriding with any provided config file, then env vars, and finally flags
+// then overriding with any provided config file, then env vars, and finally flags,
+// so that the precedence of an option is flags > env vars > file > defaults
 func Load(applicationName string, applicationVersion string, arguments []string) (*Config, *Flags, error) {
 
 	c := NewConfig()
@@ -34,23 +39,47 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 	if err != nil {
 		return nil, flags, err
 	}
-	if flags.PrintVersion {
+	if flags.PrintVersion || flags.PrintDefaultConfig {
 		return nil, flags, nil
 	}
-	if err := c.loadFile(flags); err != nil && flags.customPath {
-		// a user-provided path couldn't be loaded. return the error for the application to handle
+	// env vars that name config options override the file, and are overridden by flags
+	if err := c.loadFile(flags); err != nil {
+		// the config couldn't be loaded. return the error for the application to handle
 		return nil, flags, err
 	}
 
 	c.loadEnvVars()
 	c.loadFlags(flags) // load parsed flags to override file and envs
+	if err := c.process(); err != nil {
+		return nil, flags, err
+	}
+	return c, flags, nil
+}
+
+// LoadDocument returns the Application Configuration described by the provided TOML document,
+// starting with a default config. Unlike Load, it does not read any files, environment variables
+// or command line flags, so that it can evaluate a candidate config alongside the running one
+func LoadDocument(document string) (*Config, error) {
+	c := NewConfig()
+	if err := c.loadTOMLConfig(document, &Flags{}); err != nil {
+		return nil, err
+	}
+	if err := c.process(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// process validates the loaded configuration, and derives the values that are
+// not directly configured, such as durations from their configured seconds
+func (c *Config) process() error {
 
 	// set the default origin url from the flags
 	if d, ok := c.Origins["default"]; ok {
 		if c.providedOriginURL != "" {
 			url, err := url.Parse(c.providedOriginURL)
 			if err != nil {
-				return nil, flags, err
+				return err
 			}
 			if c.providedOriginType != "" {
 				d.OriginType = c.providedOriginType
@@ -64,6 +93,11 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 		// then Trickster will not use the auto-created default origin
 		if d.OriginURL == "" {
 			delete(c.Origins, "default")
+			for path := range c.Sources() {
+				if strings.HasPrefix(path, "origins.default.") {
+					delete(c.Main.sources, path)
+				}
+			}
 		}
 
 		if c.providedOriginType != "" {
@@ -72,17 +106,17 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 	}
 
 	if len(c.Origins) == 0 {
-		return nil, flags, errors.New("no valid origins configured")
+		return errors.New("no valid origins configured")
 	}
 
 	for k, n := range c.NegativeCacheConfigs {
 		for c := range n {
 			ci, err := strconv.Atoi(c)
 			if err != nil {
-				return nil, flags, fmt.Errorf(`invalid negative cache config in %s: %s is not a valid status code`, k, c)
+				return fmt.Errorf(`invalid negative cache config in %s: %s is not a valid status code`, k, c)
 			}
 			if ci < 400 || ci >= 600 {
-				return nil, flags, fmt.Errorf(`invalid negative cache config in %s: %s is not a valid status code`, k, c)
+				return fmt.Errorf(`invalid negative cache config in %s: %s is not a valid status code`, k, c)
 			}
 		}
 	}
@@ -90,16 +124,72 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 	for k, o := range c.Origins {
 
 		if o.OriginType == "" {
-			return nil, flags, fmt.Errorf(`missing origin-type for origin "%s"`, k)
+			return fmt.Errorf(`missing origin-type for origin "%s"`, k)
 		}
 
 		if o.OriginType != "rule" && o.OriginURL == "" {
-			return nil, flags, fmt.Errorf(`missing origin-url for origin "%s"`, k)
+			return fmt.Errorf(`missing origin-url for origin "%s"`, k)
+		}
+
+		if o.BootstrapPeerURL != "" {
+			bu, err := url.Parse(o.BootstrapPeerURL)
+			if err != nil {
+				return err
+			}
+			if bu.Scheme == "" || bu.Host == "" {
+				return fmt.Errorf(`invalid bootstrap_peer_url for origin "%s": %s`,
+					k, o.BootstrapPeerURL)
+			}
+			bu.Path = strings.TrimSuffix(bu.Path, "/")
+			o.BootstrapPeer = bu
+		}
+
+		if _, ok := ratelimit.Names[o.RateLimitModeName]; !ok {
+			return fmt.Errorf(`invalid rate_limit_mode for origin "%s": %s`,
+				k, o.RateLimitModeName)
+		}
+
+		if _, ok := policy.Names[o.PreflightPolicyName]; !ok {
+			return fmt.Errorf(`invalid preflight_policy for origin "%s": %s`,
+				k, o.PreflightPolicyName)
+		}
+
+		if o.MaxConcurrentRequests < 0 {
+			return fmt.Errorf(`invalid max_concurrent_requests for origin "%s": %d`,
+				k, o.MaxConcurrentRequests)
+		}
+
+		if o.ConcurrencyReservedShare < 0 || o.ConcurrencyReservedShare > 1 {
+			return fmt.Errorf(`invalid concurrency_reserved_share for origin "%s": %g`,
+				k, o.ConcurrencyReservedShare)
+		}
+
+		if (o.RateLimitRemainingHeader == "") != (o.RateLimitResetHeader == "") {
+			return fmt.Errorf(`rate_limit_remaining_header and rate_limit_reset_header `+
+				`must be set together for origin "%s"`, k)
+		}
+
+		if o.TimeZoneName != "" {
+			loc, err := time.LoadLocation(o.TimeZoneName)
+			if err != nil {
+				return fmt.Errorf(`invalid timezone for origin "%s": %s`, k, o.TimeZoneName)
+			}
+			o.TimeZone = loc
+		}
+
+		if o.RecordDir != "" && o.ReplayDir != "" {
+			return fmt.Errorf(`record_dir and replay_dir cannot both be set for origin "%s"`, k)
+		}
+
+		if o.ReplayDir != "" {
+			if fi, err := os.Stat(o.ReplayDir); err != nil || !fi.IsDir() {
+				return fmt.Errorf(`invalid replay_dir for origin "%s": %s`, k, o.ReplayDir)
+			}
 		}
 
 		url, err := url.Parse(o.OriginURL)
 		if err != nil {
-			return nil, flags, err
+			return err
 		}
 
 		if strings.HasSuffix(url.Path, "/") {
@@ -111,11 +201,20 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 		o.Host = url.Host
 		o.PathPrefix = url.Path
 		o.Timeout = time.Duration(o.TimeoutSecs) * time.Second
+		o.ClientTimeout = time.Duration(o.ClientTimeoutMS) * time.Millisecond
+		o.TimeoutMargin = time.Duration(o.TimeoutMarginMS) * time.Millisecond
+		o.SlowQueryThreshold = time.Duration(o.SlowQueryThresholdMS) * time.Millisecond
+		o.LookbackDelta = time.Duration(o.LookbackDeltaMS) * time.Millisecond
+		o.RateLimitMaxWait = time.Duration(o.RateLimitMaxWaitMS) * time.Millisecond
+		o.ConcurrencyMaxWait = time.Duration(o.ConcurrencyMaxWaitMS) * time.Millisecond
 		o.BackfillTolerance = time.Duration(o.BackfillToleranceSecs) * time.Second
 		o.TimeseriesRetention = time.Duration(o.TimeseriesRetentionFactor)
 		o.TimeseriesTTL = time.Duration(o.TimeseriesTTLSecs) * time.Second
 		o.FastForwardTTL = time.Duration(o.FastForwardTTLSecs) * time.Second
 		o.MaxTTL = time.Duration(o.MaxTTLSecs) * time.Second
+		o.BootstrapWindow = time.Duration(o.BootstrapWindowSecs) * time.Second
+		o.NegativeCacheBackoffMaxTTL = time.Duration(o.NegativeCacheBackoffMaxTTLSecs) * time.Second
+		o.ClockSkewWarnThreshold = time.Duration(o.ClockSkewWarnSecs) * time.Second
 
 		if o.CompressableTypeList != nil {
 			o.CompressableTypes = make(map[string]bool)
@@ -130,15 +229,20 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 
 		nc, ok := c.NegativeCacheConfigs[o.NegativeCacheName]
 		if !ok {
-			return nil, flags, fmt.Errorf(`invalid negative cache name: %s`, o.NegativeCacheName)
+			return fmt.Errorf(`invalid negative cache name: %s`, o.NegativeCacheName)
 		}
+		o.NegativeCache = nc.Durations()
 
-		nc2 := map[int]time.Duration{}
-		for c, s := range nc {
-			ci, _ := strconv.Atoi(c)
-			nc2[ci] = time.Duration(s) * time.Second
+		for _, p := range o.Paths {
+			if p.NegativeCacheName == "" {
+				continue
+			}
+			nc, ok := c.NegativeCacheConfigs[p.NegativeCacheName]
+			if !ok {
+				return fmt.Errorf(`invalid negative cache name: %s`, p.NegativeCacheName)
+			}
+			p.NegativeCache = nc.Durations()
 		}
-		o.NegativeCache = nc2
 
 		// enforce MaxTTL
 		if o.TimeseriesTTLSecs > o.MaxTTLSecs {
@@ -156,7 +260,11 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 	for _, c := range c.Caches {
 		c.Index.FlushInterval = time.Duration(c.Index.FlushIntervalSecs) * time.Second
 		c.Index.ReapInterval = time.Duration(c.Index.ReapIntervalSecs) * time.Second
+		c.Index.BulkRemoveInterval = time.Duration(c.Index.BulkRemoveIntervalMS) * time.Millisecond
+		c.Index.UnusedEvictionWindow = time.Duration(c.Index.UnusedEvictionWindowSecs) * time.Second
+		c.ReadOnlyWindow = time.Duration(c.ReadOnlyWindowSecs) * time.Second
+		c.ReadOnlyProbeInterval = time.Duration(c.ReadOnlyProbeIntervalSecs) * time.Second
 	}
 
-	return c, flags, nil
+	return nil
 }
@@ -19,6 +19,7 @@ package config
 import (
 	"io/ioutil"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
@@ -89,6 +90,92 @@ func TestLoadConfigurationFileFailures(t *testing.T) {
 			"../../testdata/test.invalid-pcf-name.conf",
 			`invalid collapsed_forwarding name: INVALID`,
 		},
+		{ // Case 8
+			"../../testdata/test.bad-bootstrap-peer-url.conf",
+			`invalid bootstrap_peer_url for origin "test": trickster-peer/test`,
+		},
+		{ // Case 9
+			"../../testdata/test.invalid-negative-cache-4.conf",
+			`invalid negative cache name: foo`,
+		},
+		{ // Case 10
+			"../../testdata/test.invalid-auth-cache-policy.conf",
+			`invalid cache_authenticated_requests policy: INVALID`,
+		},
+		{ // Case 11
+			"../../testdata/test.unknown-cache-type.conf",
+			`invalid cache type [test_cache_type] provided in cache config [test]. knownTypes: ` +
+				`badger, bbolt, filesystem, memory, redis`,
+		},
+		{ // Case 12
+			"../../testdata/test.unknown-middleware-name.conf",
+			`invalid middleware in path query of origin config test: unknown middleware name [auth-internal]`,
+		},
+		{ // Case 13
+			"../../testdata/test.unknown-middleware-type.conf",
+			`invalid type [test_middleware_type] provided in middleware [cors-dashboards]. knownTypes: ` +
+				`limits, metrics, rewriter`,
+		},
+		{ // Case 14
+			"../../testdata/test.invalid-rate-limit-mode.conf",
+			`invalid rate_limit_mode for origin "test": drop`,
+		},
+		{ // Case 15
+			"../../testdata/test.missing-rate-limit-reset-header.conf",
+			`rate_limit_remaining_header and rate_limit_reset_header must be set together for origin "test"`,
+		},
+		{ // Case 16
+			"../../testdata/test.invalid-timezone.conf",
+			`invalid timezone for origin "test": America/Nowhere`,
+		},
+		{ // Case 17
+			"../../testdata/test.record-and-replay.conf",
+			`record_dir and replay_dir cannot both be set for origin "test"`,
+		},
+		{ // Case 18
+			"../../testdata/test.missing-replay-dir.conf",
+			`invalid replay_dir for origin "test": /nonexistent/trickster/fixtures`,
+		},
+		{ // Case 19
+			"../../testdata/test.invalid-partial-response.conf",
+			`invalid partial_response mode: INVALID`,
+		},
+		{ // Case 20
+			"../../testdata/test.invalid-preflight-policy.conf",
+			`invalid preflight_policy for origin "test": strict`,
+		},
+		{ // Case 21
+			"../../testdata/test.invalid-cache-preflight-policy.conf",
+			`invalid preflight_policy [strict] provided in cache config [test]`,
+		},
+		{ // Case 22
+			"../../testdata/test.invalid-max-lookback-action.conf",
+			`invalid max_lookback_action: INVALID`,
+		},
+		{ // Case 23
+			"../../testdata/test.invalid-concurrency-reserved-share.conf",
+			`invalid concurrency_reserved_share for origin "test": 1.5`,
+		},
+		{ // Case 24
+			"../../testdata/test.invalid-priority.conf",
+			`invalid priority: INVALID`,
+		},
+		{ // Case 25
+			"../../testdata/test.invalid-max-estimated-points-action.conf",
+			`invalid max_estimated_points_action: INVALID`,
+		},
+		{ // Case 26
+			"../../testdata/test.invalid-max-estimated-points-origin.conf",
+			`invalid max_estimated_points_origin [heavy] provided in origin config [test]`,
+		},
+		{ // Case 27
+			"../../testdata/test.invalid-read-only-error-threshold.conf",
+			`invalid read_only_error_threshold 1.5 in cache config [default]`,
+		},
+		{ // Case 28
+			"../../testdata/test.invalid-admin-token-permission.conf",
+			`invalid method in permission [FETCH /trickster/invalidate] for admin token [automation]`,
+		},
 	}
 
 	for i, test := range tests {
@@ -156,6 +243,23 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected test, got %s", conf.Metrics.ListenAddress)
 	}
 
+	// Test gRPC Health Server
+	if conf.GRPCHealth.ListenPort != 57825 {
+		t.Errorf("expected 57825, got %d", conf.GRPCHealth.ListenPort)
+	}
+
+	if conf.GRPCHealth.ListenAddress != "grpc_health_test" {
+		t.Errorf("expected grpc_health_test, got %s", conf.GRPCHealth.ListenAddress)
+	}
+
+	if !conf.GRPCHealth.ServeTLS {
+		t.Errorf("expected true, got %t", conf.GRPCHealth.ServeTLS)
+	}
+
+	if c := conf.Clone(); !c.GRPCHealth.Equal(conf.GRPCHealth) {
+		t.Errorf("expected %v, got %v", conf.GRPCHealth, c.GRPCHealth)
+	}
+
 	// Test Logging
 	if conf.Logging.LogLevel != "test_log_level" {
 		t.Errorf("expected test_log_level, got %s", conf.Logging.LogLevel)
@@ -165,6 +269,146 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected test_file, got %s", conf.Logging.LogFile)
 	}
 
+	if conf.Logging.LogFormat != "json" {
+		t.Errorf("expected json, got %s", conf.Logging.LogFormat)
+	}
+
+	if conf.Logging.LogTimestampFormat != "epoch_ms" {
+		t.Errorf("expected %s, got %s", "epoch_ms", conf.Logging.LogTimestampFormat)
+	}
+
+	if !conf.Logging.LogIncludeHostname {
+		t.Errorf("expected %t, got %t", true, conf.Logging.LogIncludeHostname)
+	}
+
+	if !conf.Logging.LogTimestampLocal {
+		t.Errorf("expected %t, got %t", true, conf.Logging.LogTimestampLocal)
+	}
+
+	if conf.Logging.LogMaxSizeMB != 64 {
+		t.Errorf("expected %d, got %d", 64, conf.Logging.LogMaxSizeMB)
+	}
+
+	if conf.Logging.LogMaxBackups != 10 {
+		t.Errorf("expected %d, got %d", 10, conf.Logging.LogMaxBackups)
+	}
+
+	if conf.Logging.LogMaxAgeDays != 3 {
+		t.Errorf("expected %d, got %d", 3, conf.Logging.LogMaxAgeDays)
+	}
+
+	if conf.Logging.LogCompress {
+		t.Errorf("expected %t, got %t", false, conf.Logging.LogCompress)
+	}
+
+	if !conf.Logging.LogAlsoStdout {
+		t.Errorf("expected %t, got %t", true, conf.Logging.LogAlsoStdout)
+	}
+
+	if v := conf.Logging.LogLevelOverrides["cache.redis"]; v != "warn" {
+		t.Errorf("expected %s, got %s", "warn", v)
+	}
+
+	if conf.Logging.LogAsyncBufferSize != 4096 {
+		t.Errorf("expected %d, got %d", 4096, conf.Logging.LogAsyncBufferSize)
+	}
+
+	if conf.Logging.LogDedupWindowSecs != 30 {
+		t.Errorf("expected %d, got %d", 30, conf.Logging.LogDedupWindowSecs)
+	}
+
+	if conf.Logging.LogRotation != "external" {
+		t.Errorf("expected %s, got %s", "external", conf.Logging.LogRotation)
+	}
+
+	if !conf.Logging.SplitStreams {
+		t.Errorf("expected %t, got %t", true, conf.Logging.SplitStreams)
+	}
+
+	if conf.Logging.LogOnceMaxEntries != 5000 {
+		t.Errorf("expected %d, got %d", 5000, conf.Logging.LogOnceMaxEntries)
+	}
+
+	if conf.Logging.LogOnceTTLSecs != 3600 {
+		t.Errorf("expected %d, got %d", 3600, conf.Logging.LogOnceTTLSecs)
+	}
+
+	if conf.Logging.LogTarget != "syslog" {
+		t.Errorf("expected %s, got %s", "syslog", conf.Logging.LogTarget)
+	}
+
+	if conf.Logging.SyslogNetwork != "udp" {
+		t.Errorf("expected %s, got %s", "udp", conf.Logging.SyslogNetwork)
+	}
+
+	if conf.Logging.SyslogAddress != "localhost:514" {
+		t.Errorf("expected %s, got %s", "localhost:514", conf.Logging.SyslogAddress)
+	}
+
+	if conf.Logging.SyslogFacility != "local3" {
+		t.Errorf("expected %s, got %s", "local3", conf.Logging.SyslogFacility)
+	}
+
+	if conf.Logging.GelfNetwork != "tcp" || conf.Logging.GelfAddress != "graylog:12201" {
+		t.Errorf("unexpected gelf config %s %s", conf.Logging.GelfNetwork, conf.Logging.GelfAddress)
+	}
+
+	if conf.Logging.LogTargetNetwork != "udp" || conf.Logging.LogTargetAddress != "logstash:5000" ||
+		conf.Logging.LogTargetBufferSize != 500 {
+		t.Errorf("unexpected log target config %s %s %d", conf.Logging.LogTargetNetwork,
+			conf.Logging.LogTargetAddress, conf.Logging.LogTargetBufferSize)
+	}
+
+	if conf.Logging.SyslogTag != "trickster-test" {
+		t.Errorf("expected %s, got %s", "trickster-test", conf.Logging.SyslogTag)
+	}
+
+	if conf.Logging.AccessLogFile != "test_access_file" {
+		t.Errorf("expected %s, got %s", "test_access_file", conf.Logging.AccessLogFile)
+	}
+
+	if conf.Logging.AccessLogFormat != "combined" {
+		t.Errorf("expected %s, got %s", "combined", conf.Logging.AccessLogFormat)
+	}
+
+	if conf.Logging.AccessLogFlushIntervalMS != 250 {
+		t.Errorf("expected %d, got %d", 250, conf.Logging.AccessLogFlushIntervalMS)
+	}
+
+	if len(conf.Logging.RedactKeys) != 2 || conf.Logging.RedactKeys[1] != "x_secret" {
+		t.Errorf("expected %s, got %v", "x_secret", conf.Logging.RedactKeys)
+	}
+
+	// Test Admin Tokens
+	at, ok := conf.ReloadConfig.AdminTokens["oncall"]
+	if !ok {
+		t.Fatal("expected admin token oncall")
+	}
+
+	if at.Token != "test-admin-token" {
+		t.Errorf("expected %s, got %s", "test-admin-token", at.Token)
+	}
+
+	if len(at.ParsedPermissions) != 2 || at.ParsedPermissions[1].String() != "* /trickster/purge" {
+		t.Errorf("unexpected permissions %v", at.ParsedPermissions)
+	}
+
+	if conf.Logging.LogLocale.Timezone != "Asia/Shanghai" {
+		t.Errorf("expected %s, got %s", "Asia/Shanghai", conf.Logging.LogLocale.Timezone)
+	}
+
+	if conf.Logging.LogLocale.TimeField != "test_time_field" {
+		t.Errorf("expected %s, got %s", "test_time_field", conf.Logging.LogLocale.TimeField)
+	}
+
+	if conf.Logging.LogLocale.TranslationsFile != "test_translations_file" {
+		t.Errorf("expected %s, got %s", "test_translations_file", conf.Logging.LogLocale.TranslationsFile)
+	}
+
+	if conf.Logging.LogLocale.DisplaySuffix != "_test" {
+		t.Errorf("expected %s, got %s", "_test", conf.Logging.LogLocale.DisplaySuffix)
+	}
+
 	// Test Origins
 
 	o, ok := conf.Origins["test"]
@@ -213,6 +457,14 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected 37, got %d", o.TimeoutSecs)
 	}
 
+	if o.NegativeCacheBackoffMultiplier != 2 {
+		t.Errorf("expected 2, got %f", o.NegativeCacheBackoffMultiplier)
+	}
+
+	if o.NegativeCacheBackoffMaxTTL != 120*time.Second {
+		t.Errorf("expected %s, got %s", 120*time.Second, o.NegativeCacheBackoffMaxTTL)
+	}
+
 	if o.IsDefault != true {
 		t.Errorf("expected true got %t", o.IsDefault)
 	}
@@ -271,6 +523,26 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected redis, got %s", c.CacheType)
 	}
 
+	if !c.ReadOnly {
+		t.Errorf("expected %t got %t", true, c.ReadOnly)
+	}
+
+	if c.ReadOnlyErrorThreshold != 0.5 {
+		t.Errorf("expected 0.5, got %f", c.ReadOnlyErrorThreshold)
+	}
+
+	if c.ReadOnlyWindow != time.Minute {
+		t.Errorf("expected 1m0s, got %s", c.ReadOnlyWindow)
+	}
+
+	if c.ReadOnlyMinStores != 25 {
+		t.Errorf("expected 25, got %d", c.ReadOnlyMinStores)
+	}
+
+	if c.ReadOnlyProbeInterval != 5*time.Second {
+		t.Errorf("expected 5s, got %s", c.ReadOnlyProbeInterval)
+	}
+
 	if c.Index.ReapIntervalSecs != 4 {
 		t.Errorf("expected 4, got %d", c.Index.ReapIntervalSecs)
 	}
@@ -295,6 +567,22 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected 20, got %d", c.Index.MaxSizeBackoffObjects)
 	}
 
+	if !c.Index.SyncBulkRemove {
+		t.Errorf("expected true, got %t", c.Index.SyncBulkRemove)
+	}
+
+	if c.Index.BulkRemoveBatchSize != 250 {
+		t.Errorf("expected 250, got %d", c.Index.BulkRemoveBatchSize)
+	}
+
+	if c.Index.BulkRemoveInterval != 50*time.Millisecond {
+		t.Errorf("expected 50ms, got %s", c.Index.BulkRemoveInterval)
+	}
+
+	if c.Index.UnusedEvictionWindow != 15*time.Minute {
+		t.Errorf("expected 15m0s, got %s", c.Index.UnusedEvictionWindow)
+	}
+
 	if c.Index.ReapIntervalSecs != 4 {
 		t.Errorf("expected 4, got %d", c.Index.ReapIntervalSecs)
 	}
@@ -424,6 +712,11 @@ func TestEmptyLoadConfiguration(t *testing.T) {
 		t.Errorf("expected '%s', got '%s'", d.DefaultMetricsListenAddress, conf.Metrics.ListenAddress)
 	}
 
+	// the gRPC Health Server is disabled by default
+	if conf.GRPCHealth.ListenPort != 0 {
+		t.Errorf("expected 0, got %d", conf.GRPCHealth.ListenPort)
+	}
+
 	// Test Logging
 	if conf.Logging.LogLevel != d.DefaultLogLevel {
 		t.Errorf("expected %s, got %s", d.DefaultLogLevel, conf.Logging.LogLevel)
@@ -433,6 +726,17 @@ func TestEmptyLoadConfiguration(t *testing.T) {
 		t.Errorf("expected '%s', got '%s'", d.DefaultLogFile, conf.Logging.LogFile)
 	}
 
+	if conf.Logging.LogFormat != d.DefaultLogFormat {
+		t.Errorf("expected %s, got %s", d.DefaultLogFormat, conf.Logging.LogFormat)
+	}
+
+	if conf.Logging.LogMaxSizeMB != d.DefaultLogMaxSizeMB ||
+		conf.Logging.LogMaxBackups != d.DefaultLogMaxBackups ||
+		conf.Logging.LogMaxAgeDays != d.DefaultLogMaxAgeDays ||
+		conf.Logging.LogCompress != d.DefaultLogCompress {
+		t.Errorf("unexpected log rotation options %+v", conf.Logging)
+	}
+
 	// Test Origins
 
 	o, ok := conf.Origins["test"]
@@ -701,3 +1005,77 @@ func TestLoadEmptyArgs(t *testing.T) {
 		t.Error("expected error: no valid origins configured")
 	}
 }
+
+func TestLoadDocument(t *testing.T) {
+
+	conf, err := LoadDocument(`
+[origins]
+  [origins.test]
+  origin_type = 'prometheus'
+  origin_url = 'http://127.0.0.1:9090'
+  timeout_secs = 30
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oc, ok := conf.Origins["test"]
+	if !ok {
+		t.Fatal("expected origin test")
+	}
+	if oc.Host != "127.0.0.1:9090" {
+		t.Errorf("expected %s got %s", "127.0.0.1:9090", oc.Host)
+	}
+	if oc.Timeout != 30*time.Second {
+		t.Errorf("expected %s got %s", 30*time.Second, oc.Timeout)
+	}
+
+	if _, err = LoadDocument("[origins]\n  [origins.test]\n  origin_type = 'prometheus'\n"); err == nil {
+		t.Error("expected error for origin without an origin_url")
+	}
+
+	if _, err = LoadDocument("[[origins"); err == nil {
+		t.Error("expected error for invalid toml")
+	}
+}
+
+func TestLoadConfigurationFindings(t *testing.T) {
+	a := []string{"-config", "../../testdata/test.unknown-options.conf"}
+	conf, _, err := Load("trickster-test", "0", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"origins.test.timeseries_retention_factr (line 23): unknown config option",
+		"unknown_section (line 40): unknown config option",
+		"origins.test.paths.series.canonical_format (line 28): not applicable to origin_type prometheus",
+		"origins.test-rpc.timeseries_retention_factor (line 33): not applicable to origin_type rpc",
+		`origins.test-rpc.paths."/api".split_queries (line 38): not applicable to origin_type rpc`,
+	}
+	if !reflect.DeepEqual(conf.LoaderWarnings, expected) {
+		t.Errorf("expected %v got %v", expected, conf.LoaderWarnings)
+	}
+}
+
+func TestLoadDocumentFindingsRegisteredOriginType(t *testing.T) {
+	// origin types of providers registered outside of Trickster are timeseries origin types
+	conf, err := LoadDocument(`
+[origins]
+  [origins.test]
+  origin_type = 'exampletsdb'
+  origin_url = 'http://127.0.0.1:9090'
+  timeseries_retention_factor = 2048
+    [origins.test.paths]
+      [origins.test.paths.series]
+      path = '/series'
+      canonical_format = true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"origins.test.paths.series.canonical_format (line 10): not applicable to origin_type exampletsdb",
+	}
+	if !reflect.DeepEqual(conf.LoaderWarnings, expected) {
+		t.Errorf("expected %v got %v", expected, conf.LoaderWarnings)
+	}
+}
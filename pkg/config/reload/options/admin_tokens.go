@@ -0,0 +1,138 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// AdminTokenOptions is a collection of configurations for a named token that is permitted to
+// call the admin handlers
+type AdminTokenOptions struct {
+	// TokenFile provides the path to the file holding the token's value
+	TokenFile string `toml:"token_file"`
+	// Permissions provides the admin handlers the token is permitted to call, each of the form
+	// "METHOD /path", where the method may be "*" for any method, and the path may include
+	// path.Match globs. A permission that omits the method permits any method
+	Permissions []string `toml:"permissions"`
+
+	// Synthesized AdminTokenOptions
+
+	// Name is the name of the token
+	Name string `toml:"-"`
+	// Token is the value of the token, read from the TokenFile
+	Token string `toml:"-"`
+	// ParsedPermissions are the token's parsed Permissions
+	ParsedPermissions []*Permission `toml:"-"`
+}
+
+// Permission permits a call to the admin handlers having a method and path
+type Permission struct {
+	// Method is the permitted method, or "*" for any method
+	Method string
+	// Pattern is the path.Match pattern of the permitted paths
+	Pattern string
+}
+
+var permissionMethods = map[string]bool{"*": true, http.MethodGet: true, http.MethodHead: true,
+	http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true, http.MethodDelete: true,
+	http.MethodOptions: true}
+
+// ParsePermission parses a permission of the form "METHOD /path", or "/path" for any method
+func ParsePermission(s string) (*Permission, error) {
+	parts := strings.Fields(s)
+	p := &Permission{Method: "*"}
+	switch len(parts) {
+	case 1:
+		p.Pattern = parts[0]
+	case 2:
+		p.Method = strings.ToUpper(parts[0])
+		p.Pattern = parts[1]
+	default:
+		return nil, fmt.Errorf("invalid permission [%s]", s)
+	}
+	if !permissionMethods[p.Method] {
+		return nil, fmt.Errorf("invalid method in permission [%s]", s)
+	}
+	if !strings.HasPrefix(p.Pattern, "/") {
+		return nil, fmt.Errorf("invalid path in permission [%s]", s)
+	}
+	if _, err := path.Match(p.Pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid path in permission [%s]: %v", s, err)
+	}
+	return p, nil
+}
+
+// Allows returns true if the Permission permits a call having the method and path
+func (p *Permission) Allows(method, urlPath string) bool {
+	if p.Method != "*" && p.Method != method &&
+		!(p.Method == http.MethodGet && method == http.MethodHead) {
+		return false
+	}
+	ok, _ := path.Match(p.Pattern, urlPath)
+	return ok
+}
+
+// String returns the Permission in the form "METHOD /path"
+func (p *Permission) String() string {
+	return p.Method + " " + p.Pattern
+}
+
+// ProcessAdminTokens reads the value of each of the provided Admin Token Options from its
+// token file, and parses its permissions
+func ProcessAdminTokens(tokens map[string]*AdminTokenOptions) error {
+	keys := make([]string, 0, len(tokens))
+	for k := range tokens {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	names := make(map[string]string, len(tokens))
+	for _, k := range keys {
+		v := tokens[k]
+		v.Name = k
+		if v.TokenFile == "" {
+			return fmt.Errorf("no token_file provided for admin token [%s]", k)
+		}
+		b, err := ioutil.ReadFile(v.TokenFile)
+		if err != nil {
+			return fmt.Errorf("unable to read token_file for admin token [%s]: %v", k, err)
+		}
+		v.Token = strings.TrimSpace(string(b))
+		if v.Token == "" {
+			return fmt.Errorf("empty token_file provided for admin token [%s]", k)
+		}
+		// each token value must identify a single name, which is logged in its place
+		if n, ok := names[v.Token]; ok {
+			return fmt.Errorf("admin tokens [%s] and [%s] have the same value", n, k)
+		}
+		names[v.Token] = k
+		v.ParsedPermissions = make([]*Permission, 0, len(v.Permissions))
+		for _, s := range v.Permissions {
+			p, err := ParsePermission(s)
+			if err != nil {
+				return fmt.Errorf("%v for admin token [%s]", err, k)
+			}
+			v.ParsedPermissions = append(v.ParsedPermissions, p)
+		}
+	}
+	return nil
+}
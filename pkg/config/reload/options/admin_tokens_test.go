@@ -0,0 +1,111 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePermission(t *testing.T) {
+
+	tests := []struct {
+		s, method, path string
+		valid, allows   bool
+	}{
+		{"PUT /trickster/cache/*", "PUT", "/trickster/cache/read-only", true, true},
+		{"put /trickster/cache/*", "PUT", "/trickster/cache/read-only", true, true},
+		{"PUT /trickster/cache/*", "GET", "/trickster/cache/read-only", true, false},
+		{"PUT /trickster/cache/*", "PUT", "/trickster/cache/events/x", true, false},
+		{"GET /trickster/origins", "HEAD", "/trickster/origins", true, true},
+		{"/trickster/invalidate", "POST", "/trickster/invalidate", true, true},
+		{"* /trickster/*", "DELETE", "/trickster/purge", true, true},
+		{"FETCH /trickster/cache", "", "", false, false},
+		{"PUT trickster/cache", "", "", false, false},
+		{"PUT /trickster/[", "", "", false, false},
+		{"PUT /a /b", "", "", false, false},
+	}
+
+	for i, test := range tests {
+		p, err := ParsePermission(test.s)
+		if (err == nil) != test.valid {
+			t.Errorf("(%d) expected valid %t got error %v", i, test.valid, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if p.Allows(test.method, test.path) != test.allows {
+			t.Errorf("(%d) expected %t got %t", i, test.allows, !test.allows)
+		}
+	}
+}
+
+func TestProcessAdminTokens(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "admin-tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := func(name, value string) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(value), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	oncall := file("oncall", "oncall-token\n")
+	automation := file("automation", "automation-token")
+	empty := file("empty", " \n")
+
+	tokens := map[string]*AdminTokenOptions{
+		"oncall":     {TokenFile: oncall, Permissions: []string{"PUT /trickster/cache/*"}},
+		"automation": {TokenFile: automation, Permissions: []string{"POST /trickster/invalidate"}},
+	}
+	if err := ProcessAdminTokens(tokens); err != nil {
+		t.Fatal(err)
+	}
+	if o := tokens["oncall"]; o.Name != "oncall" || o.Token != "oncall-token" ||
+		len(o.ParsedPermissions) != 1 {
+		t.Errorf("unexpected token %+v", o)
+	}
+
+	tests := []struct {
+		tokens   map[string]*AdminTokenOptions
+		expected string
+	}{
+		{map[string]*AdminTokenOptions{"a": {}}, "no token_file provided for admin token [a]"},
+		{map[string]*AdminTokenOptions{"a": {TokenFile: filepath.Join(dir, "missing")}},
+			"unable to read token_file for admin token [a]"},
+		{map[string]*AdminTokenOptions{"a": {TokenFile: empty}}, "empty token_file provided for admin token [a]"},
+		{map[string]*AdminTokenOptions{"a": {TokenFile: oncall}, "b": {TokenFile: oncall}},
+			"admin tokens [a] and [b] have the same value"},
+		{map[string]*AdminTokenOptions{"a": {TokenFile: oncall, Permissions: []string{"x"}}},
+			"invalid path in permission [x] for admin token [a]"},
+	}
+
+	for i, test := range tests {
+		err := ProcessAdminTokens(test.tokens)
+		if err == nil || !strings.HasPrefix(err.Error(), test.expected) {
+			t.Errorf("(%d) expected error `%s` got `%v`", i, test.expected, err)
+		}
+	}
+}
@@ -27,6 +27,42 @@ type Options struct {
 	ListenPort int `toml:"listen_port"`
 	// ReloadHandlerPath provides the path to register the Config Reload Handler
 	HandlerPath string `toml:"handler_path"`
+	// RefreshJobsHandlerPath provides the path to register the Refresh Jobs status and trigger Handler
+	RefreshJobsHandlerPath string `toml:"refresh_jobs_handler_path"`
+	// TopQueriesHandlerPath provides the path to register the Top Queries Handler, which reports the
+	// costs of the most requested query fingerprints
+	TopQueriesHandlerPath string `toml:"top_queries_handler_path"`
+	// ConfigDiffHandlerPath provides the path to register the Config Diff Handler, which reports how
+	// requests would be routed and cached differently under a candidate config
+	ConfigDiffHandlerPath string `toml:"config_diff_handler_path"`
+	// ResolveHandlerPath provides the path to register the Resolve Handler, which reports how a
+	// request would be routed, and the exact inputs from which its cache key would be derived
+	ResolveHandlerPath string `toml:"resolve_handler_path"`
+	// BackgroundTasksHandlerPath provides the path to register the Background Tasks Handler, which
+	// reports the goroutines running on behalf of proxied requests
+	BackgroundTasksHandlerPath string `toml:"background_tasks_handler_path"`
+	// OriginsHandlerPath provides the path to register the Origins Handler, which reports the effective
+	// configuration and runtime state of each origin
+	OriginsHandlerPath string `toml:"origins_handler_path"`
+	// InvalidateHandlerPath provides the path to register the Invalidate Handler, which invalidates
+	// time ranges of cached timeseries documents on request
+	InvalidateHandlerPath string `toml:"invalidate_handler_path"`
+	// LogLevelHandlerPath provides the path to register the Log Level Handler, which reports and
+	// changes the log level of the running logger
+	LogLevelHandlerPath string `toml:"log_level_handler_path"`
+	// CacheEventsHandlerPath provides the path to register the Cache Events Handler, which reports
+	// the most recent cache mutation events
+	CacheEventsHandlerPath string `toml:"cache_events_handler_path"`
+	// CacheReadOnlyHandlerPath provides the path to register the Cache Read-Only Handler, which
+	// reports and sets the read-only mode of each cache
+	CacheReadOnlyHandlerPath string `toml:"cache_read_only_handler_path"`
+	// HMACSecret is the shared secret with which requests to the admin handlers requiring
+	// authentication are signed. Those handlers reject all requests when it is not set
+	HMACSecret string `toml:"hmac_secret"`
+	// AdminTokens provides the named tokens permitted to call the admin handlers. When any are
+	// configured, every request to the admin handlers must present a token that is permitted
+	// to call the requested path and method
+	AdminTokens map[string]*AdminTokenOptions `toml:"admin_tokens"`
 	// DrainTimeoutSecs provides the duration to wait for all sessions to drain before closing
 	// old resources following a reload
 	DrainTimeoutSecs int `toml:"drain_timeout_secs"`
@@ -40,10 +76,20 @@ type Options struct {
 // NewOptions returns a new Options references with Default Values set
 func NewOptions() *Options {
 	return &Options{
-		ListenAddress:    defaults.DefaultReloadAddress,
-		ListenPort:       defaults.DefaultReloadPort,
-		HandlerPath:      defaults.DefaultReloadHandlerPath,
-		DrainTimeoutSecs: defaults.DefaultDrainTimeoutSecs,
-		RateLimitSecs:    defaults.DefaultRateLimitSecs,
+		ListenAddress:              defaults.DefaultReloadAddress,
+		ListenPort:                 defaults.DefaultReloadPort,
+		HandlerPath:                defaults.DefaultReloadHandlerPath,
+		RefreshJobsHandlerPath:     defaults.DefaultRefreshJobsHandlerPath,
+		TopQueriesHandlerPath:      defaults.DefaultTopQueriesHandlerPath,
+		ConfigDiffHandlerPath:      defaults.DefaultConfigDiffHandlerPath,
+		ResolveHandlerPath:         defaults.DefaultResolveHandlerPath,
+		BackgroundTasksHandlerPath: defaults.DefaultBackgroundTasksHandlerPath,
+		OriginsHandlerPath:         defaults.DefaultOriginsHandlerPath,
+		InvalidateHandlerPath:      defaults.DefaultInvalidateHandlerPath,
+		LogLevelHandlerPath:        defaults.DefaultLogLevelHandlerPath,
+		CacheEventsHandlerPath:     defaults.DefaultCacheEventsHandlerPath,
+		CacheReadOnlyHandlerPath:   defaults.DefaultCacheReadOnlyHandlerPath,
+		DrainTimeoutSecs:           defaults.DefaultDrainTimeoutSecs,
+		RateLimitSecs:              defaults.DefaultRateLimitSecs,
 	}
 }
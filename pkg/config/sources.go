@@ -0,0 +1,229 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	rwopts "github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter/options"
+)
+
+// The sources from which the value of a config option can be set, from the lowest precedence
+// to the highest
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// masked is the value with which the secrets of the config are rendered
+const masked = "*****"
+
+// secretOptions are the TOML keys of the config options whose values are secrets
+var secretOptions = map[string]bool{
+	"password":                          true,
+	"hmac_secret":                       true,
+	"collector_pass":                    true,
+	"private_key_path":                  true,
+	"client_key_path":                   true,
+	"max_estimated_points_bypass_token": true,
+}
+
+// headerOptions are the TOML keys of the config options that are maps of HTTP header names
+// to values, whose values are secrets when their names are sensitiveCredentials
+var headerOptions = map[string]bool{
+	"request_headers":      true,
+	"response_headers":     true,
+	"health_check_headers": true,
+	"headers":              true,
+}
+
+// urlOptionSuffix is the suffix of the TOML keys of the config options that are URLs, whose
+// userinfo is a secret
+const urlOptionSuffix = "_url"
+
+// sensitiveCredentials are the canonical names of the HTTP headers that carry credentials
+var sensitiveCredentials = map[string]bool{
+	headers.NameAuthorization:      true,
+	headers.NameProxyAuthorization: true,
+	headers.NameCookie:             true,
+	headers.NameSetCookie:          true,
+	"X-Api-Key":                    true,
+	"X-Auth-Token":                 true,
+}
+
+// Sources returns the sources of the config options that were not set by their defaults, by
+// their TOML key paths. The paths of the path configs of origins are keyed by their
+// path and methods, as they are in the running config
+func (c *Config) Sources() map[string]string {
+	if c.Main == nil {
+		return nil
+	}
+	return c.Main.sources
+}
+
+// Source returns the source of the config option at the TOML key path
+func (c *Config) Source(path string) string {
+	if s, ok := c.Sources()[path]; ok {
+		return s
+	}
+	return SourceDefault
+}
+
+// setSource records the source of the config option at the TOML key path, replacing that of
+// any source of lower precedence
+func (c *Config) setSource(path, source string) {
+	if c.Main.sources == nil {
+		c.Main.sources = make(map[string]string)
+	}
+	c.Main.sources[path] = source
+}
+
+// pathKeys returns the TOML keys of the path configs of each origin, which setDefaults re-keys
+// by their path and methods
+func (c *Config) pathKeys() map[*po.Options][2]string {
+	keys := make(map[*po.Options][2]string)
+	for k, o := range c.Origins {
+		if o == nil {
+			continue
+		}
+		for l, p := range o.Paths {
+			keys[p] = [2]string{k, l}
+		}
+	}
+	return keys
+}
+
+// setFileSources records the config options that are defined in the decoded document as set
+// by the file. pathKeys are the keys of the path configs of the origins as they were decoded
+func (c *Config) setFileSources(md *toml.MetaData, pathKeys map[*po.Options][2]string) {
+	renamed := make(map[[2]string]string)
+	for k, o := range c.Origins {
+		if o == nil {
+			continue
+		}
+		for l, p := range o.Paths {
+			if dk, ok := pathKeys[p]; ok && dk[0] == k {
+				renamed[dk] = l
+			}
+		}
+	}
+	for _, k := range md.Keys() {
+		if md.Type(k...) == "Hash" {
+			continue
+		}
+		if len(k) > 3 && k[0] == "origins" && k[2] == "paths" {
+			if l, ok := renamed[[2]string{k[1], k[3]}]; ok {
+				k = append(append(toml.Key{}, k[:3]...), append([]string{l}, k[4:]...)...)
+			}
+		}
+		c.setSource(keyPath(k), SourceFile)
+	}
+}
+
+// maskSecrets replaces the values of the secret options of the config, and of the
+// credentials in its configured headers, URLs and request rewriter header instructions,
+// by walking the options by their TOML keys
+func (c *Config) maskSecrets() {
+	maskValue(reflect.ValueOf(c).Elem())
+}
+
+func maskValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			maskValue(v.Elem())
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() == reflect.Ptr {
+			for _, k := range v.MapKeys() {
+				maskValue(v.MapIndex(k))
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := strings.Split(f.Tag.Get("toml"), ",")[0]
+			if key == "" || key == "-" || f.PkgPath != "" {
+				continue
+			}
+			fv := v.Field(i)
+			switch {
+			case secretOptions[key] && fv.Kind() == reflect.String:
+				if fv.String() != "" {
+					fv.SetString(masked)
+				}
+			case headerOptions[key] && fv.Type() == reflect.TypeOf(map[string]string(nil)):
+				hideAuthorizationCredentials(fv.Interface().(map[string]string))
+			case strings.HasSuffix(key, urlOptionSuffix) && fv.Kind() == reflect.String:
+				fv.SetString(hideURLCredentials(fv.String()))
+			case fv.Type() == reflect.TypeOf(rwopts.RewriteList(nil)):
+				hideRewriterCredentials(fv.Interface().(rwopts.RewriteList))
+			default:
+				maskValue(fv)
+			}
+		}
+	}
+}
+
+// hideAuthorizationCredentials masks the values of the headers that carry credentials. The
+// names of configured headers may be prefixed by the + and - header operators
+func hideAuthorizationCredentials(hdrs map[string]string) {
+	for k := range hdrs {
+		if sensitiveCredentials[http.CanonicalHeaderKey(strings.TrimLeft(k, "+-"))] {
+			hdrs[k] = masked
+		}
+	}
+}
+
+// hideURLCredentials returns the URL with the password of its userinfo masked, or its username
+// when it has no password, such as a token used as the username
+func hideURLCredentials(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	// the mask is added once the URL is rendered, since its characters would otherwise be escaped
+	info := masked
+	if _, ok := u.User.Password(); ok {
+		info = url.User(u.User.Username()).String() + ":" + masked
+	}
+	u.User = nil
+	return strings.Replace(u.String(), "//", "//"+info+"@", 1)
+}
+
+// hideRewriterCredentials masks the values of the header instructions of the rewrite list that
+// act on the headers that carry credentials, which are the elements following the header name
+func hideRewriterCredentials(rl rwopts.RewriteList) {
+	for _, ins := range rl {
+		if len(ins) < 4 || ins[0] != "header" ||
+			!sensitiveCredentials[http.CanonicalHeaderKey(ins[2])] {
+			continue
+		}
+		for i := 3; i < len(ins); i++ {
+			ins[i] = masked
+		}
+	}
+}
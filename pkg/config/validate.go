@@ -0,0 +1,197 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	ot "github.com/tricksterproxy/trickster/pkg/proxy/origins/types"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Finding is a problem in a config document that does not prevent it from loading, such as an
+// unknown option, which is ignored
+type Finding struct {
+	// Path is the TOML key path of the option
+	Path string
+	// Line is the line of the option in the document, or 0 when it is unknown
+	Line int
+	// Message describes the problem
+	Message string
+}
+
+func (f Finding) String() string {
+	if f.Line > 0 {
+		return fmt.Sprintf("%s (line %d): %s", f.Path, f.Line, f.Message)
+	}
+	return fmt.Sprintf("%s: %s", f.Path, f.Message)
+}
+
+// timeseriesOriginOptions are the origin options that only apply to timeseries origin types
+var timeseriesOriginOptions = map[string]bool{
+	"timeseries_retention_factor": true,
+	"timeseries_eviction_method":  true,
+	"timeseries_ttl_secs":         true,
+	"backfill_tolerance_secs":     true,
+	"fast_forward_disable":        true,
+	"reuse_coarser_step":          true,
+}
+
+// timeseriesPathOptions are the path options that only apply to the paths of timeseries origin
+// types
+var timeseriesPathOptions = map[string]bool{
+	"split_queries":                     true,
+	"split_queries_limit":               true,
+	"partial_response":                  true,
+	"max_lookback_secs":                 true,
+	"max_lookback_action":               true,
+	"max_lookback_tenant_header":        true,
+	"max_lookback_tenant_claim":         true,
+	"max_lookback_tenants":              true,
+	"max_estimated_points":              true,
+	"max_estimated_points_action":       true,
+	"max_estimated_points_origin":       true,
+	"max_estimated_points_bypass_token": true,
+	"max_estimated_points_ttl_secs":     true,
+}
+
+// originTypePathOptions are the path options that only apply to the paths of one origin type
+var originTypePathOptions = map[string]string{
+	"canonical_format": ot.OriginTypeClickHouse,
+}
+
+// findings returns the Findings of the decoded document: the options that are unknown, and so
+// are ignored, and those that do not apply to the origin types of their origins. Their lines
+// are included when withLines is true, which is when the document is that of the config file
+func (c *Config) findings(md *toml.MetaData, document string, withLines bool) []Finding {
+	var lines map[string]int
+	if withLines {
+		lines = keyLines(document)
+	}
+	var findings []Finding
+	add := func(k toml.Key, message string) {
+		path := keyPath(k)
+		findings = append(findings, Finding{Path: path, Line: lines[path], Message: message})
+	}
+
+	// the keys of an unknown table are also undecoded, and are reported by the table
+	unknown := make(map[string]bool)
+	for _, k := range md.Undecoded() {
+		reported := false
+		for i := 1; i < len(k) && !reported; i++ {
+			reported = unknown[keyPath(k[:i])]
+		}
+		if reported {
+			continue
+		}
+		unknown[keyPath(k)] = true
+		add(k, "unknown config option")
+	}
+
+	for _, k := range md.Keys() {
+		if len(k) < 3 || k[0] != "origins" {
+			continue
+		}
+		o, ok := c.Origins[k[1]]
+		if !ok || o == nil {
+			continue
+		}
+		timeseries := ot.IsTimeseries(o.OriginType)
+		switch {
+		case len(k) == 3 && timeseriesOriginOptions[k[2]] && !timeseries,
+			len(k) == 5 && k[2] == "paths" && timeseriesPathOptions[k[4]] && !timeseries:
+			add(k, "not applicable to origin_type "+o.OriginType)
+		case len(k) == 5 && k[2] == "paths":
+			if pt, ok := originTypePathOptions[k[4]]; ok && !strings.EqualFold(pt, o.OriginType) {
+				add(k, "not applicable to origin_type "+o.OriginType)
+			}
+		}
+	}
+
+	return findings
+}
+
+// keyPath returns the key as a TOML key path, with its keys quoted when they are not bare keys
+func keyPath(k toml.Key) string {
+	var path string
+	for _, key := range k {
+		path = joinKey(path, key)
+	}
+	return path
+}
+
+// keyLines returns the lines of the tables and keys of the TOML document, by their key paths
+func keyLines(document string) map[string]int {
+	lines := make(map[string]int)
+	scanKeys(document, func(i int, k []string, table bool) {
+		if path := keyPath(k); table || lines[path] == 0 {
+			lines[path] = i + 1
+		}
+	})
+	return lines
+}
+
+// scanKeys calls fn with the full keys of each table header and key/value line of the TOML
+// document, by the index of the line
+func scanKeys(document string, fn func(i int, k []string, table bool)) {
+	var table []string
+	for i, line := range strings.Split(document, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			header := strings.Trim(line, "[] \t")
+			if j := strings.LastIndexByte(line, ']'); j > 0 {
+				header = strings.Trim(line[:j], "[] \t")
+			}
+			table = splitKeys(header)
+			fn(i, table, true)
+			continue
+		}
+		j := strings.IndexByte(line, '=')
+		if j <= 0 {
+			continue
+		}
+		fn(i, append(append([]string{}, table...), splitKeys(line[:j])...), false)
+	}
+}
+
+// splitKeys returns the keys of the dotted TOML key, without the quotes of its quoted keys
+func splitKeys(s string) []string {
+	var keys []string
+	var key strings.Builder
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			key.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '.':
+			keys = append(keys, strings.TrimSpace(key.String()))
+			key.Reset()
+		default:
+			key.WriteRune(r)
+		}
+	}
+	return append(keys, strings.TrimSpace(key.String()))
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitKeys(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected []string
+	}{
+		{"origins", []string{"origins"}},
+		{"origins.test.paths", []string{"origins", "test", "paths"}},
+		{` origins . "a.b" . 'c' `, []string{"origins", "a.b", "c"}},
+	}
+	for _, test := range tests {
+		if v := splitKeys(test.key); !reflect.DeepEqual(v, test.expected) {
+			t.Errorf("expected %v got %v", test.expected, v)
+		}
+	}
+}
+
+func TestKeyLines(t *testing.T) {
+	doc := `# comment
+[main]
+instance_id = 1
+
+[origins.'a.b']
+  origin_url = 'http://1' # comment
+  paths.root.path = '/'
+`
+	expected := map[string]int{
+		"main":                          2,
+		"main.instance_id":              3,
+		`origins."a.b"`:                 5,
+		`origins."a.b".origin_url`:      6,
+		`origins."a.b".paths.root.path`: 7,
+	}
+	if v := keyLines(doc); !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected %v got %v", expected, v)
+	}
+}
+
+func TestFindingString(t *testing.T) {
+	f := Finding{Path: "main.test", Message: "unknown config option"}
+	if s := f.String(); s != "main.test: unknown config option" {
+		t.Errorf("unexpected finding %s", s)
+	}
+	f.Line = 3
+	if s := f.String(); s != "main.test (line 3): unknown config option" {
+		t.Errorf("unexpected finding %s", s)
+	}
+}
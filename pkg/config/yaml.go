@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// isYAML returns true if the config file is YAML, by the extension of its path, or when its
+// extension is neither that of YAML nor TOML, when its document is not valid TOML
+func isYAML(path string, document []byte) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	case ".toml", ".conf":
+		return false
+	}
+	var v map[string]interface{}
+	_, err := toml.Decode(string(document), &v)
+	return err != nil
+}
+
+// yamlToTOML converts a YAML document to the TOML document that describes the same tree. A YAML
+// config is decoded from its TOML document, so that every option is decoded exactly as it is
+// from a TOML config, and the TOML metadata that records which options are set, which the
+// defaults and loader warnings rely on, applies equally to both formats
+func yamlToTOML(document []byte) (string, error) {
+	var v map[interface{}]interface{}
+	if err := yaml.Unmarshal(document, &v); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(yamlValue(v)); err != nil {
+		return "", fmt.Errorf("unable to convert yaml config: %s", err)
+	}
+	return buf.String(), nil
+}
+
+// yamlValue returns the decoded YAML value with its mappings keyed by strings, as TOML tables
+// are, and without its null values, which TOML can't represent and are treated as unset
+func yamlValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			if e != nil {
+				m[fmt.Sprint(k)] = yamlValue(e)
+			}
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, 0, len(x))
+		for _, e := range x {
+			if e != nil {
+				l = append(l, yamlValue(e))
+			}
+		}
+		return l
+	}
+	return v
+}
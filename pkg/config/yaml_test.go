@@ -0,0 +1,156 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// tomlFileToYAML writes the TOML config file as a YAML file of the name in the directory
+func tomlFileToYAML(t *testing.T, tomlFile, dir, name string) string {
+	var v map[string]interface{}
+	if _, err := toml.DecodeFile(tomlFile, &v); err != nil {
+		t.Fatal(err)
+	}
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yamlFile := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(yamlFile, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return yamlFile
+}
+
+func TestLoadYAMLConfig(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "trickster-yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// each TOML config, converted to YAML, resolves to an identical Config
+	tests := []string{
+		"../../cmd/trickster/conf/example.conf",
+		"../../testdata/test.redis-standard.conf",
+		"../../testdata/test.multiple_origins.conf",
+		"../../testdata/test.routing.rules.conf",
+		"../../testdata/test.warning1.conf",
+	}
+
+	for _, tomlFile := range tests {
+		t.Run(filepath.Base(tomlFile), func(t *testing.T) {
+			expected, _, err := Load("trickster-test", "0", []string{"-config", tomlFile})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"test.yaml", "test.yml", "test"} {
+				yamlFile := tomlFileToYAML(t, tomlFile, dir, name)
+				conf, _, err := Load("trickster-test", "0", []string{"-config", yamlFile})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if conf.String() != expected.String() {
+					t.Errorf("%s: expected %s got %s", name, expected.String(), conf.String())
+				}
+				if len(conf.LoaderWarnings) != len(expected.LoaderWarnings) {
+					t.Errorf("%s: expected %v got %v", name, expected.LoaderWarnings,
+						conf.LoaderWarnings)
+				}
+			}
+		})
+	}
+}
+
+func TestYAMLToTOML(t *testing.T) {
+
+	doc := `
+frontend:
+  listen_port: 57821
+origins:
+  test:
+    origin_type: prometheus
+    origin_url: http://1
+    timeout_secs:
+    paths:
+      root:
+        path: /
+        methods: ["GET", "POST"]
+negative_caches:
+  default:
+    404: 3
+`
+	conf := NewConfig()
+	s, err := yamlToTOML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.loadTOMLConfig(s, &Flags{}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Frontend.ListenPort != 57821 {
+		t.Errorf("expected %d got %d", 57821, conf.Frontend.ListenPort)
+	}
+	o := conf.Origins["test"]
+	// a null value is treated as unset, so the option keeps its default
+	if o.TimeoutSecs != NewConfig().Origins["default"].TimeoutSecs {
+		t.Errorf("expected default timeout got %d", o.TimeoutSecs)
+	}
+	if len(o.Paths) != 1 {
+		t.Errorf("expected %d got %d", 1, len(o.Paths))
+	}
+	for _, p := range o.Paths {
+		if p.Path != "/" || len(p.Methods) != 2 {
+			t.Errorf("unexpected path %s %v", p.Path, p.Methods)
+		}
+	}
+	if v := conf.NegativeCacheConfigs["default"]["404"]; v != 3 {
+		t.Errorf("expected %d got %d", 3, v)
+	}
+
+	if _, err := yamlToTOML([]byte("origins: [")); err == nil {
+		t.Error("expected error for invalid yaml")
+	}
+}
+
+func TestIsYAML(t *testing.T) {
+	tests := []struct {
+		path     string
+		document string
+		expected bool
+	}{
+		{"trickster.yaml", "", true},
+		{"trickster.YML", "", true},
+		{"trickster.conf", "a: 1", false},
+		{"trickster.toml", "a: 1", false},
+		{"trickster", "[main]\ninstance_id = 1\n", false},
+		{"trickster", "main:\n  instance_id: 1\n", true},
+	}
+	for _, test := range tests {
+		if v := isYAML(test.path, []byte(test.document)); v != test.expected {
+			t.Errorf("%s: expected %t got %t", test.path, test.expected, v)
+		}
+	}
+}
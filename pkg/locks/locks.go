@@ -19,24 +19,64 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// Lock Modes reported to a WaitObserver
+const (
+	// ModeRead indicates the caller waited for a read lock
+	ModeRead = "read"
+	// ModeWrite indicates the caller waited for a write lock
+	ModeWrite = "write"
+	// ModeUpgrade indicates the caller waited to upgrade a read lock to a write lock
+	ModeUpgrade = "upgrade"
+)
+
+// WaitObserver is called with the lock mode and the duration for which a caller
+// waited to acquire a Named Lock
+type WaitObserver func(mode string, wait time.Duration)
+
 // NamedLocker provides a locker for handling Named Locks
 type NamedLocker interface {
 	Acquire(string) (NamedLock, error)
 	RAcquire(string) (NamedLock, error)
+	Len() int
 }
 
 type namedLocker struct {
-	locks   map[string]*namedLock
-	mapLock *sync.Mutex
+	locks    map[string]*namedLock
+	mapLock  *sync.Mutex
+	observer WaitObserver
 }
 
 // NewNamedLocker returns a new Named Locker
 func NewNamedLocker() NamedLocker {
+	return NewObservedNamedLocker(nil)
+}
+
+// NewObservedNamedLocker returns a new Named Locker that reports the time callers
+// spend waiting to acquire each lock to the provided observer
+func NewObservedNamedLocker(observer WaitObserver) NamedLocker {
 	return &namedLocker{
-		locks:   make(map[string]*namedLock),
-		mapLock: &sync.Mutex{},
+		locks:    make(map[string]*namedLock),
+		mapLock:  &sync.Mutex{},
+		observer: observer,
+	}
+}
+
+// Len returns the number of Named Locks currently held or awaited. Locks are
+// removed from the registry once their last holder releases them, so the registry
+// is bounded by the number of keys with in-flight lock holders
+func (lk *namedLocker) Len() int {
+	lk.mapLock.Lock()
+	l := len(lk.locks)
+	lk.mapLock.Unlock()
+	return l
+}
+
+func (lk *namedLocker) observe(mode string, start time.Time) {
+	if lk.observer != nil {
+		lk.observer(mode, time.Since(start))
 	}
 }
 
@@ -73,12 +113,7 @@ func (nl *namedLock) Release() error {
 		return errInvalidLockName(nl.name)
 	}
 
-	qs := atomic.AddInt32(&nl.queueSize, -1)
-	if qs == 0 {
-		nl.locker.mapLock.Lock()
-		delete(nl.locker.locks, nl.name)
-		nl.locker.mapLock.Unlock()
-	}
+	nl.dequeue()
 
 	atomic.AddInt32(&nl.writeLockMode, -1)
 	nl.Unlock()
@@ -92,17 +127,24 @@ func (nl *namedLock) RRelease() error {
 		return errInvalidLockName(nl.name)
 	}
 
-	qs := atomic.AddInt32(&nl.queueSize, -1)
-	if qs == 0 {
-		nl.locker.mapLock.Lock()
-		delete(nl.locker.locks, nl.name)
-		nl.locker.mapLock.Unlock()
-	}
+	nl.dequeue()
 
 	nl.RUnlock()
 	return nil
 }
 
+// dequeue decrements the lock's queue size and removes the lock from the registry
+// once it is no longer held or awaited. This is done under the registry mutex so that
+// a concurrent Acquire cannot enqueue on a lock that is being removed, which would
+// otherwise allow a subsequent caller to create a second lock for the same name
+func (nl *namedLock) dequeue() {
+	nl.locker.mapLock.Lock()
+	if atomic.AddInt32(&nl.queueSize, -1) == 0 {
+		delete(nl.locker.locks, nl.name)
+	}
+	nl.locker.mapLock.Unlock()
+}
+
 // WriteLockCounter returns the number of write locks acquired by the namedLock
 // This function should only be called by a goroutine actively holding a write lock,
 // as it is otherwise not atomic
@@ -122,6 +164,7 @@ func (nl *namedLock) WriteLockMode() bool {
 // state checks are required (e.g., re-querying a cache that might have changed) before proceeding.
 func (nl *namedLock) Upgrade() (NamedLock, error) {
 
+	start := time.Now()
 	ch := make(chan bool, 1)
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
@@ -141,6 +184,7 @@ func (nl *namedLock) Upgrade() (NamedLock, error) {
 
 	// wait until write mode is set, read lock is released, and write lock is acquired
 	wg.Wait()
+	nl.locker.observe(ModeUpgrade, start)
 
 	return nl, nil
 }
@@ -161,7 +205,9 @@ func (lk *namedLocker) Acquire(lockName string) (NamedLock, error) {
 	lk.mapLock.Unlock()
 	atomic.AddInt32(&nl.writeLockMode, 1)
 
+	start := time.Now()
 	nl.Lock()
+	lk.observe(ModeWrite, start)
 
 	nl.writeLockCount++
 	return nl, nil
@@ -184,7 +230,9 @@ func (lk *namedLocker) RAcquire(lockName string) (NamedLock, error) {
 	lk.mapLock.Unlock()
 	atomic.StoreInt32(&nl.writeLockMode, 0)
 
+	start := time.Now()
 	nl.RLock()
+	lk.observe(ModeRead, start)
 	return nl, nil
 }
 
@@ -17,6 +17,7 @@ import (
 	"math/rand"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -188,3 +189,64 @@ func TestUpgrade(t *testing.T) {
 		t.Errorf("expected 1 got %d", nl.WriteLockCounter())
 	}
 }
+
+func TestLocksExclusiveUnderContention(t *testing.T) {
+
+	lk := NewNamedLocker()
+	keys := []string{"key1", "key2", "key3"}
+	holders := make([]int32, len(keys))
+	var violations int32
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 3000; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			k := i % len(keys)
+			var nl NamedLock
+			if i%2 == 0 {
+				nl, _ = lk.Acquire(keys[k])
+			} else {
+				nl, _ = lk.RAcquire(keys[k])
+				nl, _ = nl.Upgrade()
+			}
+			if atomic.AddInt32(&holders[k], 1) != 1 {
+				atomic.AddInt32(&violations, 1)
+			}
+			atomic.AddInt32(&holders[k], -1)
+			nl.Release()
+		}(i)
+	}
+	wg.Wait()
+
+	if violations != 0 {
+		t.Errorf("expected 0 got %d concurrent write lock holders", violations)
+	}
+
+	if l := lk.Len(); l != 0 {
+		t.Errorf("expected empty lock registry, got %d", l)
+	}
+}
+
+func TestObservedNamedLocker(t *testing.T) {
+
+	var mtx sync.Mutex
+	observed := make(map[string]int)
+	lk := NewObservedNamedLocker(func(mode string, wait time.Duration) {
+		mtx.Lock()
+		observed[mode]++
+		mtx.Unlock()
+	})
+
+	nl, _ := lk.RAcquire("test")
+	nl, _ = nl.Upgrade()
+	nl.Release()
+	nl, _ = lk.Acquire("test")
+	nl.Release()
+
+	for _, mode := range []string{ModeRead, ModeWrite, ModeUpgrade} {
+		if observed[mode] != 1 {
+			t.Errorf("expected 1 observation for mode %s got %d", mode, observed[mode])
+		}
+	}
+}
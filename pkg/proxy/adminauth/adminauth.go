@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package adminauth authorizes requests to the admin handlers with named tokens, each of which
+// is permitted to call a list of admin paths and methods
+package adminauth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	reload "github.com/tricksterproxy/trickster/pkg/config/reload/options"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const bearerPrefix = "Bearer "
+
+// Handler returns the handler wrapped with the authorization of the provided admin tokens,
+// or the handler itself when no tokens are configured. Each request must present a token in
+// its Authorization header as a Bearer token, and is rejected with 401 when it does not
+// present a configured token, or 403 when the token is not permitted to call its path and
+// method. The token's name is logged with each request, and the outcome of each request
+// that may change state is logged as an audit event
+func Handler(tokens map[string]*reload.AdminTokenOptions, log *tl.Logger,
+	next http.Handler) http.Handler {
+	if len(tokens) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		t := lookup(tokens, r)
+		name := ""
+		if t != nil {
+			name = t.Name
+		}
+		log.Debug("admin request", tl.Pairs{"uri": r.RequestURI, "method": r.Method,
+			"clientIP": r.RemoteAddr, "tokenName": name})
+
+		if t == nil {
+			audit(log, r, name, http.StatusUnauthorized)
+			txe.NewResponseError(http.StatusUnauthorized, txe.CodeUnauthorized,
+				"a valid admin token is required").Respond(w, r)
+			return
+		}
+		if !allowed(t, r.Method, r.URL.Path) {
+			audit(log, r, name, http.StatusForbidden)
+			txe.NewResponseError(http.StatusForbidden, txe.CodeForbidden,
+				"admin token ["+name+"] is missing permission "+r.Method+" "+r.URL.Path).Respond(w, r)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		audit(log, r, name, sw.status)
+	})
+}
+
+// lookup returns the token presented by the request, or nil if it does not present one of
+// the configured tokens. Every token is compared in constant time
+func lookup(tokens map[string]*reload.AdminTokenOptions, r *http.Request) *reload.AdminTokenOptions {
+	v := r.Header.Get(headers.NameAuthorization)
+	if !strings.HasPrefix(v, bearerPrefix) {
+		return nil
+	}
+	v = strings.TrimSpace(v[len(bearerPrefix):])
+	var found *reload.AdminTokenOptions
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(v), []byte(t.Token)) == 1 {
+			found = t
+		}
+	}
+	return found
+}
+
+// allowed returns true if the token is permitted to call the path with the method
+func allowed(t *reload.AdminTokenOptions, method, path string) bool {
+	for _, p := range t.ParsedPermissions {
+		if p.Allows(method, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// audit logs the outcome of a request that may change state
+func audit(log *tl.Logger, r *http.Request, tokenName string, status int) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+	log.Info("admin audit", tl.Pairs{"uri": r.RequestURI, "method": r.Method,
+		"clientIP": r.RemoteAddr, "tokenName": tokenName, "code": status})
+}
+
+// statusWriter records the status code written to the ResponseWriter
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	sw.status = code
+	sw.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adminauth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	reload "github.com/tricksterproxy/trickster/pkg/config/reload/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func testTokens(t *testing.T) map[string]*reload.AdminTokenOptions {
+	tokens := map[string]*reload.AdminTokenOptions{
+		"oncall": {Token: "oncall-token",
+			Permissions: []string{"PUT /trickster/cache/*", "GET /trickster/*"}},
+		"automation": {Token: "automation-token",
+			Permissions: []string{"POST /trickster/invalidate"}},
+	}
+	for name, o := range tokens {
+		o.Name = name
+		for _, s := range o.Permissions {
+			p, err := reload.ParsePermission(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o.ParsedPermissions = append(o.ParsedPermissions, p)
+		}
+	}
+	return tokens
+}
+
+func TestHandler(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "adminauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "out.log")
+	conf := config.NewConfig()
+	conf.Main = &config.MainConfig{InstanceID: 0}
+	conf.Logging = &config.LoggingConfig{LogFile: fileName, LogLevel: "info"}
+	log := tl.New(conf)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	// without tokens, requests are not authorized
+	if h := Handler(nil, log, next); h == nil {
+		t.Fatal("expected handler")
+	} else {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "http://0/trickster/cache/read-only", nil))
+		if w.Code != http.StatusAccepted {
+			t.Errorf("expected %d got %d", http.StatusAccepted, w.Code)
+		}
+	}
+
+	h := Handler(testTokens(t), log, next)
+
+	tests := []struct {
+		method, path, authorization string
+		code                        int
+		body                        string
+	}{
+		{http.MethodPut, "/trickster/cache/read-only", "Bearer oncall-token", http.StatusAccepted, ""},
+		{http.MethodGet, "/trickster/origins", "Bearer oncall-token", http.StatusAccepted, ""},
+		{http.MethodPut, "/trickster/maintenance", "Bearer oncall-token", http.StatusForbidden,
+			"admin token [oncall] is missing permission PUT /trickster/maintenance"},
+		{http.MethodPost, "/trickster/invalidate", "Bearer automation-token", http.StatusAccepted, ""},
+		{http.MethodPost, "/trickster/invalidate", "Bearer oncall-token", http.StatusForbidden,
+			"missing permission POST /trickster/invalidate"},
+		{http.MethodPost, "/trickster/invalidate", "Bearer invalid-token", http.StatusUnauthorized, ""},
+		{http.MethodPost, "/trickster/invalidate", "automation-token", http.StatusUnauthorized, ""},
+		{http.MethodPost, "/trickster/invalidate", "", http.StatusUnauthorized, ""},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, "http://0"+test.path, nil)
+		if test.authorization != "" {
+			r.Header.Set("Authorization", test.authorization)
+		}
+		h.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("(%d) expected %d got %d", i, test.code, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), test.body) {
+			t.Errorf("(%d) expected body to contain `%s` got `%s`", i, test.body, w.Body.String())
+		}
+	}
+
+	log.Close()
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	// state-changing calls are audited with the name of the token, and never its value
+	if strings.Count(s, "admin audit") != 7 || !strings.Contains(s, "tokenName=automation") ||
+		strings.Contains(s, "automation-token") {
+		t.Errorf("unexpected audit events: %s", s)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package authcache provides the policies for caching responses to authenticated requests
+package authcache
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+// Policy enumerates the ways that responses to authenticated requests can be cached
+type Policy int
+
+const (
+	// PolicyDefault indicates that no policy was configured, and the origin's default is used
+	PolicyDefault = Policy(iota)
+	// PolicyNever indicates that authenticated requests are proxied without caching
+	PolicyNever
+	// PolicyShared indicates that authenticated requests use the shared cache
+	PolicyShared
+	// PolicyPerIdentity indicates that authenticated requests are cached separately for each identity
+	PolicyPerIdentity
+)
+
+// PolicyNames is a map of policies keyed by name
+var PolicyNames = map[string]Policy{
+	"never":        PolicyNever,
+	"shared":       PolicyShared,
+	"per-identity": PolicyPerIdentity,
+}
+
+// PolicyValues is a map of policy names keyed by internal id
+var PolicyValues = make(map[Policy]string)
+
+func init() {
+	for k, v := range PolicyNames {
+		PolicyValues[v] = k
+	}
+}
+
+func (p Policy) String() string {
+	if v, ok := PolicyValues[p]; ok {
+		return v
+	}
+	if p == PolicyDefault {
+		return "default"
+	}
+	return strconv.Itoa(int(p))
+}
+
+// GetPolicy returns the Policy for the provided name, or PolicyDefault if the name is invalid
+func GetPolicy(name string) Policy {
+	if v, ok := PolicyNames[name]; ok {
+		return v
+	}
+	return PolicyDefault
+}
+
+// IsAuthenticated returns true if the request carries credentials in its Authorization or Cookie
+// headers, or in the provided identity header, when not empty
+func IsAuthenticated(r *http.Request, identityHeader string) bool {
+	if r.Header.Get(headers.NameAuthorization) != "" || r.Header.Get(headers.NameCookie) != "" {
+		return true
+	}
+	return identityHeader != "" && r.Header.Get(identityHeader) != ""
+}
+
+// Identity returns a hash identifying the requestor, for inclusion in cache keys. When an identity
+// header is provided and present in the request, only its value is hashed; otherwise the request's
+// Authorization and Cookie headers are hashed. An empty string is returned for unauthenticated requests
+func Identity(r *http.Request, identityHeader string) string {
+	if identityHeader != "" {
+		if v := r.Header.Get(identityHeader); v != "" {
+			return md5.Checksum(identityHeader + ":" + v)
+		}
+	}
+	a := r.Header.Get(headers.NameAuthorization)
+	c := r.Header.Get(headers.NameCookie)
+	if a == "" && c == "" {
+		return ""
+	}
+	return md5.Checksum(headers.NameAuthorization + ":" + a + "\n" + headers.NameCookie + ":" + c)
+}
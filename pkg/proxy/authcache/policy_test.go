@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package authcache
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPolicyString(t *testing.T) {
+
+	tests := []struct {
+		p        Policy
+		expected string
+	}{
+		{PolicyDefault, "default"},
+		{PolicyNever, "never"},
+		{PolicyShared, "shared"},
+		{PolicyPerIdentity, "per-identity"},
+		{Policy(13), "13"},
+	}
+
+	for _, test := range tests {
+		if test.p.String() != test.expected {
+			t.Errorf("expected %s got %s", test.expected, test.p.String())
+		}
+	}
+
+	if p := GetPolicy("per-identity"); p != PolicyPerIdentity {
+		t.Errorf("expected %s got %s", "per-identity", p.String())
+	}
+
+	if p := GetPolicy("13"); p != PolicyDefault {
+		t.Errorf("expected %s got %s", "default", p.String())
+	}
+
+}
+
+func TestIsAuthenticated(t *testing.T) {
+
+	r, _ := http.NewRequest(http.MethodGet, "http://0/", nil)
+	if IsAuthenticated(r, "X-Tenant") {
+		t.Error("expected false")
+	}
+
+	r.Header.Set("X-Tenant", "a")
+	if !IsAuthenticated(r, "X-Tenant") {
+		t.Error("expected true")
+	}
+	if IsAuthenticated(r, "") {
+		t.Error("expected false")
+	}
+
+	r.Header.Set("Cookie", "session=1")
+	if !IsAuthenticated(r, "") {
+		t.Error("expected true")
+	}
+
+}
+
+func TestIdentity(t *testing.T) {
+
+	r, _ := http.NewRequest(http.MethodGet, "http://0/", nil)
+	if id := Identity(r, ""); id != "" {
+		t.Errorf("expected empty identity got %s", id)
+	}
+
+	r.Header.Set("Authorization", "Basic dXNlcjE6cGFzcw==")
+	id1 := Identity(r, "")
+	r.Header.Set("Authorization", "Basic dXNlcjI6cGFzcw==")
+	id2 := Identity(r, "")
+	if id1 == "" || id1 == id2 {
+		t.Errorf("expected distinct identities, got %s and %s", id1, id2)
+	}
+
+	// the identity header takes precedence over the credentials when present
+	r.Header.Set("X-Tenant", "a")
+	id3 := Identity(r, "X-Tenant")
+	r.Header.Set("Authorization", "Basic dXNlcjE6cGFzcw==")
+	if id4 := Identity(r, "X-Tenant"); id3 != id4 {
+		t.Errorf("expected %s got %s", id3, id4)
+	}
+	if id3 == id1 {
+		t.Error("expected the identity header to be used")
+	}
+
+}
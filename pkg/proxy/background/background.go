@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package background tracks the goroutines that the proxy engines launch on behalf of requests,
+// such as fast forward fetches and cache writes, some of which outlive the requests, so that
+// their counts can be monitored and awaited
+package background
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// The kinds of background tasks
+const (
+	KindFastForward      = "fast_forward"
+	KindRangeFetch       = "range_fetch"
+	KindUpstreamFetch    = "upstream_fetch"
+	KindRevalidation     = "revalidation"
+	KindCacheWrite       = "cache_write"
+	KindCollapsedForward = "collapsed_forward"
+	KindQuerySplit       = "query_split"
+)
+
+var (
+	mtx    sync.Mutex
+	total  int
+	counts = make(map[string]int)
+)
+
+// Go runs f in a new goroutine that is tracked under the provided kind until f returns
+func Go(kind string, f func()) {
+	update(kind, 1)
+	go func() {
+		defer update(kind, -1)
+		f()
+	}()
+}
+
+func update(kind string, delta int) {
+	mtx.Lock()
+	total += delta
+	counts[kind] += delta
+	n := counts[kind]
+	mtx.Unlock()
+	metrics.ProxyBackgroundTasks.WithLabelValues(kind).Set(float64(n))
+}
+
+// Counts returns the number of running tasks of each kind that has been run
+func Counts() map[string]int {
+	mtx.Lock()
+	defer mtx.Unlock()
+	m := make(map[string]int, len(counts))
+	for k, v := range counts {
+		m[k] = v
+	}
+	return m
+}
+
+// Running returns the number of running tasks
+func Running() int {
+	mtx.Lock()
+	defer mtx.Unlock()
+	return total
+}
+
+// waitInterval is the interval at which Wait checks for running tasks
+const waitInterval = 10 * time.Millisecond
+
+// Wait blocks until no tasks are running, or the timeout elapses, and returns true if no
+// tasks are running
+func Wait(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for Running() > 0 {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(waitInterval)
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package background
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGo(t *testing.T) {
+
+	release := make(chan struct{})
+	Go(KindCacheWrite, func() { <-release })
+	Go(KindCacheWrite, func() { <-release })
+	Go(KindFastForward, func() { <-release })
+
+	if n := Counts()[KindCacheWrite]; n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	if n := Running(); n != 3 {
+		t.Errorf("expected %d got %d", 3, n)
+	}
+	if Wait(time.Millisecond) {
+		t.Error("expected tasks to be running")
+	}
+
+	close(release)
+	if !Wait(time.Second) {
+		t.Fatal("expected tasks to complete")
+	}
+	c := Counts()
+	if c[KindCacheWrite] != 0 || c[KindFastForward] != 0 {
+		t.Errorf("unexpected counts %v", c)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package chain attributes the handling of requests across tiers of chained Trickster
+// instances, such as an edge tier whose origin is a regional tier. Each instance appends its
+// server name to the X-Trickster-Chain header of the requests it forwards upstream, so that an
+// instance knows its depth in the chain, and appends its handling of the request to the
+// X-Trickster-Chain header of the response, so that the client knows which tiers served it
+package chain
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/runtime"
+)
+
+// Hop is the handling of a request by a Trickster instance in the chain
+type Hop struct {
+	// ID is the server name of the instance
+	ID string
+	// Status is the cache status of the instance's handling of the request
+	Status string
+	// ServeTime is the time the instance took to serve the response
+	ServeTime time.Duration
+}
+
+// String returns the Hop in its X-Trickster-Chain response header form, id;status=s;ms=n
+func (h Hop) String() string {
+	return h.ID + ";status=" + h.Status + ";ms=" +
+		strconv.FormatInt(int64(h.ServeTime/time.Millisecond), 10)
+}
+
+// ParseHop parses a Hop from its X-Trickster-Chain response header form. ok is false when the
+// value does not name an instance
+func ParseHop(s string) (Hop, bool) {
+	parts := strings.Split(strings.TrimSpace(s), ";")
+	h := Hop{ID: strings.TrimSpace(parts[0])}
+	if h.ID == "" {
+		return h, false
+	}
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "status":
+			h.Status = kv[1]
+		case "ms":
+			if ms, err := strconv.ParseInt(kv[1], 10, 64); err == nil {
+				h.ServeTime = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return h, true
+}
+
+// Parse returns the Hops of an X-Trickster-Chain response header value, ordered from the
+// instance nearest the origin to that nearest the client
+func Parse(v string) []Hop {
+	if v == "" {
+		return nil
+	}
+	parts := strings.Split(v, ",")
+	hops := make([]Hop, 0, len(parts))
+	for _, p := range parts {
+		if h, ok := ParseHop(p); ok {
+			hops = append(hops, h)
+		}
+	}
+	return hops
+}
+
+// Format returns the X-Trickster-Chain response header value of the Hops
+func Format(hops []Hop) string {
+	parts := make([]string, len(hops))
+	for i, h := range hops {
+		parts[i] = h.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Depth returns the depth in the chain of the instance receiving a request having the header,
+// which is the number of instances the request passed through before it. The instance that
+// receives requests directly from clients is at depth 0
+func Depth(h http.Header) int {
+	if h == nil {
+		return 0
+	}
+	n := 0
+	for _, id := range strings.Split(h.Get(headers.NameTricksterChain), ",") {
+		if strings.TrimSpace(id) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// SetRequestHeader appends this instance's server name to the X-Trickster-Chain header of a
+// request that is forwarded upstream
+func SetRequestHeader(h http.Header) {
+	if h == nil {
+		return
+	}
+	if v := h.Get(headers.NameTricksterChain); v != "" {
+		h.Set(headers.NameTricksterChain, v+", "+runtime.Server)
+		return
+	}
+	h.Set(headers.NameTricksterChain, runtime.Server)
+}
+
+// SetResponseHeader sets the X-Trickster-Chain header of a response to the upstream Hops,
+// followed by this instance's handling of the request
+func SetResponseHeader(h http.Header, upstream []Hop, status string, serveTime time.Duration) {
+	if h == nil {
+		return
+	}
+	hops := append(append(make([]Hop, 0, len(upstream)+1), upstream...),
+		Hop{ID: runtime.Server, Status: status, ServeTime: serveTime})
+	h.Set(headers.NameTricksterChain, Format(hops))
+}
+
+// Upstream collects the chain reported by the upstream responses of a client request. When a
+// request requires several upstream requests, such as a timeseries request for several missing
+// ranges, the chain of the first upstream response that reports one is retained
+type Upstream struct {
+	mtx  sync.Mutex
+	hops []Hop
+	set  bool
+}
+
+// Observe removes the X-Trickster-Chain header from an upstream response header, so that it is
+// not cached, and retains its Hops if none have been retained. Observe is safe to call on a nil
+// Upstream, in which case the header is only removed
+func (u *Upstream) Observe(h http.Header) {
+	if h == nil {
+		return
+	}
+	v := h.Get(headers.NameTricksterChain)
+	if v == "" {
+		return
+	}
+	h.Del(headers.NameTricksterChain)
+	if u == nil {
+		return
+	}
+	u.mtx.Lock()
+	if !u.set {
+		u.hops = Parse(v)
+		u.set = true
+	}
+	u.mtx.Unlock()
+}
+
+// Hops returns the retained Hops, or nil when none were observed
+func (u *Upstream) Hops() []Hop {
+	if u == nil {
+		return nil
+	}
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	return u.hops
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chain
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/runtime"
+)
+
+func TestParse(t *testing.T) {
+
+	hops := Parse("regional-1;status=phit;ms=12, edge-1;status=hit;ms=3,;status=hit")
+	if len(hops) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(hops))
+	}
+	if hops[0].ID != "regional-1" || hops[0].Status != "phit" ||
+		hops[0].ServeTime != 12*time.Millisecond {
+		t.Errorf("unexpected hop %+v", hops[0])
+	}
+	if v := Format(hops); v != "regional-1;status=phit;ms=12, edge-1;status=hit;ms=3" {
+		t.Errorf("unexpected format %s", v)
+	}
+	if hops = Parse(""); hops != nil {
+		t.Errorf("expected nil got %v", hops)
+	}
+	if h, ok := ParseHop("edge-1;ms=x;status"); !ok || h.ServeTime != 0 || h.Status != "" {
+		t.Errorf("unexpected hop %+v", h)
+	}
+}
+
+func TestDepth(t *testing.T) {
+
+	if d := Depth(nil); d != 0 {
+		t.Errorf("expected %d got %d", 0, d)
+	}
+	h := http.Header{}
+	if d := Depth(h); d != 0 {
+		t.Errorf("expected %d got %d", 0, d)
+	}
+	SetRequestHeader(h)
+	if v := h.Get(headers.NameTricksterChain); v != runtime.Server {
+		t.Errorf("expected %s got %s", runtime.Server, v)
+	}
+	SetRequestHeader(h)
+	if d := Depth(h); d != 2 {
+		t.Errorf("expected %d got %d", 2, d)
+	}
+}
+
+func TestUpstream(t *testing.T) {
+
+	var u *Upstream
+	h := http.Header{headers.NameTricksterChain: []string{"regional-1;status=hit;ms=2"}}
+	u.Observe(h)
+	if v := h.Get(headers.NameTricksterChain); v != "" || u.Hops() != nil {
+		t.Errorf("expected header to be removed got %s", v)
+	}
+
+	u = &Upstream{}
+	u.Observe(http.Header{headers.NameTricksterChain: []string{"regional-1;status=kmiss;ms=20"}})
+	u.Observe(http.Header{headers.NameTricksterChain: []string{"regional-2;status=hit;ms=2"}})
+	hops := u.Hops()
+	if len(hops) != 1 || hops[0].ID != "regional-1" {
+		t.Errorf("unexpected hops %v", hops)
+	}
+
+	h = http.Header{}
+	SetResponseHeader(h, hops, "hit", 1500*time.Microsecond)
+	expected := "regional-1;status=kmiss;ms=20, " + runtime.Server + ";status=hit;ms=1"
+	if v := h.Get(headers.NameTricksterChain); v != expected {
+		t.Errorf("expected %s got %s", expected, v)
+	}
+}
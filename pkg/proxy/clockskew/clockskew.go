@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package clockskew maintains smoothed estimates of the clock skew between Trickster
+// and its upstream origins, as observed from timestamps in origin responses
+package clockskew
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// SmoothingFactor is the weight given to each new sample in the skew estimate's moving average
+const SmoothingFactor = 0.2
+
+// Tracker maintains a smoothed estimate of the clock skew for a single origin. A positive
+// estimate means the origin's clock is ahead of Trickster's; negative means it is behind
+type Tracker struct {
+	originName string
+	originType string
+	metrics    *metrics.Metrics
+	estimate   time.Duration
+	samples    int
+	mtx        sync.Mutex
+}
+
+// Registry holds the Trackers of a router's origins
+type Registry struct {
+	metrics  *metrics.Metrics
+	trackers sync.Map
+}
+
+// NewRegistry returns a new Registry whose Trackers report to the Metrics
+func NewRegistry(m *metrics.Metrics) *Registry {
+	return &Registry{metrics: m}
+}
+
+// Get returns the Tracker for the named origin, creating it if necessary
+func (r *Registry) Get(originName, originType string) *Tracker {
+	if t, ok := r.trackers.Load(originName); ok {
+		return t.(*Tracker)
+	}
+	t, _ := r.trackers.LoadOrStore(originName, &Tracker{originName: originName,
+		originType: originType, metrics: r.metrics})
+	return t.(*Tracker)
+}
+
+// Estimate returns the current skew estimate for the named origin, or 0 if no samples have been observed
+func (r *Registry) Estimate(originName string) time.Duration {
+	if t, ok := r.trackers.Load(originName); ok {
+		return t.(*Tracker).Estimate()
+	}
+	return 0
+}
+
+// Observe records a skew sample for the named origin, computed from a timestamp generated
+// by the origin and the local time of its receipt, and returns the updated estimate
+func (r *Registry) Observe(originName, originType string, originTime, localTime time.Time) time.Duration {
+	return r.Get(originName, originType).Observe(originTime.Sub(localTime))
+}
+
+// Observe records a skew sample and returns the updated estimate
+func (t *Tracker) Observe(sample time.Duration) time.Duration {
+	t.mtx.Lock()
+	if t.samples == 0 {
+		t.estimate = sample
+	} else {
+		t.estimate += time.Duration(SmoothingFactor * float64(sample-t.estimate))
+	}
+	t.samples++
+	e := t.estimate
+	t.mtx.Unlock()
+	metrics.OrDefault(t.metrics).ProxyOriginClockSkew.WithLabelValues(t.originName, t.originType).Set(e.Seconds())
+	return e
+}
+
+// Estimate returns the current skew estimate
+func (t *Tracker) Estimate() time.Duration {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.estimate
+}
+
+// Samples returns the number of samples the estimate is based on
+func (t *Tracker) Samples() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.samples
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clockskew
+
+import (
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+
+	now := time.Now()
+	r := NewRegistry(nil)
+
+	if e := r.Estimate("test-observe"); e != 0 {
+		t.Errorf("expected %d got %d", 0, e)
+	}
+
+	// the first sample sets the estimate outright
+	e := r.Observe("test-observe", "test", now.Add(-10*time.Second), now)
+	if e != -10*time.Second {
+		t.Errorf("expected %s got %s", -10*time.Second, e)
+	}
+
+	// subsequent samples are smoothed
+	e = r.Observe("test-observe", "test", now, now)
+	if e != -8*time.Second {
+		t.Errorf("expected %s got %s", -8*time.Second, e)
+	}
+
+	if e := r.Estimate("test-observe"); e != -8*time.Second {
+		t.Errorf("expected %s got %s", -8*time.Second, e)
+	}
+
+	tr := r.Get("test-observe", "test")
+	if tr.Samples() != 2 {
+		t.Errorf("expected %d got %d", 2, tr.Samples())
+	}
+}
+
+func TestRegistryGet(t *testing.T) {
+	r := NewRegistry(nil)
+	t1 := r.Get("test-get", "test")
+	t2 := r.Get("test-get", "test")
+	if t1 != t2 {
+		t.Error("expected the same tracker")
+	}
+	if NewRegistry(nil).Get("test-get", "test") == t1 {
+		t.Error("expected registries not to share trackers")
+	}
+	if t1.Estimate() != 0 {
+		t.Errorf("expected %d got %d", 0, t1.Estimate())
+	}
+}
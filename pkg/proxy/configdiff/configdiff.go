@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package configdiff evaluates requests against the running config and a candidate config, and
+// reports the requests whose cache key, matched path or selected origin would change under the
+// candidate, without touching any cache or origin
+package configdiff
+
+import (
+	"sort"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/sampling"
+	"github.com/tricksterproxy/trickster/pkg/routing"
+)
+
+// DefaultMaxExamples is the default number of examples reported for each path
+const DefaultMaxExamples = 5
+
+// Report summarizes the differences between the routes of the evaluated requests under the
+// current and candidate configs
+type Report struct {
+	// Requests is the number of requests evaluated
+	Requests int `json:"requests"`
+	// Changed is the number of requests with any difference
+	Changed int `json:"changed"`
+	// Paths reports the differences for each path, as matched under the current config
+	Paths []*PathReport `json:"paths"`
+}
+
+// PathReport counts the differences for the requests matched by a path under the current config
+type PathReport struct {
+	// Origin is the name of the origin that serves the path under the current config,
+	// or empty for requests that match no route
+	Origin string `json:"origin"`
+	// Path is the path config that matches the requests under the current config
+	Path string `json:"path"`
+	// Requests is the number of requests evaluated for the path
+	Requests int `json:"requests"`
+	// CacheKeyChanged is the number of requests whose cache key would change
+	CacheKeyChanged int `json:"cache_key_changed"`
+	// PathChanged is the number of requests that would be matched by a different path
+	PathChanged int `json:"path_changed"`
+	// OriginChanged is the number of requests that would be served by a different origin
+	OriginChanged int `json:"origin_changed"`
+	// Examples are the first of the requests with any difference, up to the maximum
+	Examples []*Example `json:"examples,omitempty"`
+}
+
+// Example is a request whose route would change under the candidate config
+type Example struct {
+	// Request is the request line of the request
+	Request string `json:"request"`
+	// Current is the request's route under the current config
+	Current routing.Route `json:"current"`
+	// Candidate is the request's route under the candidate config
+	Candidate routing.Route `json:"candidate"`
+}
+
+// Diff evaluates the samples against the current and candidate configs, reporting up to
+// maxExamples examples for each path. Both configs are modified as they are by
+// routing.RegisterProxyRoutes, so neither may be the running config
+func Diff(current, candidate *config.Config, samples []*sampling.Sample,
+	maxExamples int) (*Report, error) {
+
+	ci, err := routing.NewInspector(current)
+	if err != nil {
+		return nil, err
+	}
+	ni, err := routing.NewInspector(candidate)
+	if err != nil {
+		return nil, err
+	}
+
+	type pathKey struct{ origin, path string }
+	paths := make(map[pathKey]*PathReport)
+	report := &Report{Paths: make([]*PathReport, 0)}
+
+	for _, s := range samples {
+		// each config inspects its own request, since rewriters modify the request
+		r1, err := s.Request()
+		if err != nil {
+			continue
+		}
+		r2, _ := s.Request()
+		cr, nr := ci.Route(r1), ni.Route(r2)
+
+		report.Requests++
+		pk := pathKey{cr.Origin, cr.Path}
+		pr, ok := paths[pk]
+		if !ok {
+			pr = &PathReport{Origin: cr.Origin, Path: cr.Path}
+			paths[pk] = pr
+			report.Paths = append(report.Paths, pr)
+		}
+		pr.Requests++
+		if cr == nr {
+			continue
+		}
+		report.Changed++
+		if cr.CacheKey != nr.CacheKey {
+			pr.CacheKeyChanged++
+		}
+		if cr.Path != nr.Path {
+			pr.PathChanged++
+		}
+		if cr.Origin != nr.Origin {
+			pr.OriginChanged++
+		}
+		if len(pr.Examples) < maxExamples {
+			pr.Examples = append(pr.Examples,
+				&Example{Request: s.String(), Current: cr, Candidate: nr})
+		}
+	}
+
+	sort.Slice(report.Paths, func(i, j int) bool {
+		if report.Paths[i].Origin != report.Paths[j].Origin {
+			return report.Paths[i].Origin < report.Paths[j].Origin
+		}
+		return report.Paths[i].Path < report.Paths[j].Path
+	})
+
+	return report, nil
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package configdiff
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/proxy/sampling"
+)
+
+const testCurrentConfig = `
+[origins]
+  [origins.prom1]
+  origin_type = 'prometheus'
+  origin_url = 'http://127.0.0.1:9090'
+  is_default = true
+
+  [origins.web]
+  origin_type = 'reverseproxycache'
+  origin_url = 'http://127.0.0.1:8080'
+`
+
+// the candidate makes web the default origin, and proxies its static content without caching
+const testCandidateConfig = `
+[origins]
+  [origins.prom1]
+  origin_type = 'prometheus'
+  origin_url = 'http://127.0.0.1:9090'
+
+  [origins.web]
+  origin_type = 'reverseproxycache'
+  origin_url = 'http://127.0.0.1:8080'
+  is_default = true
+    [origins.web.paths]
+      [origins.web.paths.static]
+      path = '/static/'
+      match_type = 'prefix'
+      handler = 'proxy'
+`
+
+var testRequests = []string{
+	"GET /api/v1/query_range?query=up&start=1600000000&end=1600003600&step=15",
+	"GET /web/static/app.js",
+	"GET /web/static/app.css",
+	"GET /prom1/api/v1/query?query=up",
+	"GET /web/index.html",
+	"GET /graph",
+}
+
+func testSamples(t *testing.T) []*sampling.Sample {
+	samples := make([]*sampling.Sample, len(testRequests))
+	for i, line := range testRequests {
+		s, err := sampling.ParseRequestLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples[i] = s
+	}
+	return samples
+}
+
+func testConfig(t *testing.T, document string) *config.Config {
+	conf, err := config.LoadDocument(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func findPath(report *Report, origin, path string) *PathReport {
+	for _, p := range report.Paths {
+		if p.Origin == origin && p.Path == path {
+			return p
+		}
+	}
+	return nil
+}
+
+func TestDiff(t *testing.T) {
+
+	report, err := Diff(testConfig(t, testCurrentConfig), testConfig(t, testCandidateConfig),
+		testSamples(t), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Requests != len(testRequests) {
+		t.Errorf("expected %d got %d", len(testRequests), report.Requests)
+	}
+	if report.Changed != 4 {
+		t.Errorf("expected %d got %d", 4, report.Changed)
+	}
+
+	p := findPath(report, "prom1", "/api/v1/query_range")
+	if p == nil {
+		t.Fatal("expected a report for prom1 /api/v1/query_range")
+	}
+	if p.Requests != 1 || p.OriginChanged != 1 || p.PathChanged != 1 || p.CacheKeyChanged != 1 {
+		t.Errorf("unexpected counts %+v", p)
+	}
+	if len(p.Examples) != 1 || p.Examples[0].Candidate.Origin != "web" ||
+		!strings.Contains(p.Examples[0].Current.CacheKey, ".dpc.") {
+		t.Errorf("unexpected examples %+v", p.Examples)
+	}
+
+	p = findPath(report, "web", "/")
+	if p == nil {
+		t.Fatal("expected a report for web /")
+	}
+	if p.Requests != 3 || p.OriginChanged != 0 || p.PathChanged != 2 || p.CacheKeyChanged != 2 {
+		t.Errorf("unexpected counts %+v", p)
+	}
+	// examples are capped
+	if len(p.Examples) != 1 || p.Examples[0].Candidate.Path != "/static/" ||
+		p.Examples[0].Candidate.CacheKey != "" || p.Examples[0].Current.CacheKey == "" {
+		t.Errorf("unexpected examples %+v", p.Examples)
+	}
+
+	p = findPath(report, "prom1", "/api/v1/query")
+	if p == nil || p.Requests != 1 || len(p.Examples) != 0 {
+		t.Errorf("unexpected report %+v", p)
+	}
+
+	// requests for other paths are served by the default origin
+	p = findPath(report, "prom1", "/")
+	if p == nil || p.Requests != 1 || p.OriginChanged != 1 || p.PathChanged != 0 {
+		t.Errorf("unexpected report %+v", p)
+	}
+}
+
+func TestHandleFunc(t *testing.T) {
+
+	inst := instance.New()
+	h := HandleFunc(testConfig(t, testCurrentConfig), inst)
+
+	body := func(v interface{}) *strings.Reader {
+		b, _ := json.Marshal(v)
+		return strings.NewReader(string(b))
+	}
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/trickster/debug/config-diff", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	tests := []struct {
+		req  *Request
+		code int
+	}{
+		{&Request{Config: testCandidateConfig, Requests: testRequests}, http.StatusOK},
+		{&Request{Requests: testRequests}, http.StatusBadRequest},
+		{&Request{Config: testCandidateConfig, Requests: []string{"GET"}}, http.StatusBadRequest},
+		{&Request{Config: "[[origins", Requests: testRequests}, http.StatusBadRequest},
+		// requests are required when sampling is disabled
+		{&Request{Config: testCandidateConfig}, http.StatusBadRequest},
+	}
+
+	for i, test := range tests {
+		w = httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodPost, "/trickster/debug/config-diff", body(test.req)))
+		if w.Code != test.code {
+			b, _ := ioutil.ReadAll(w.Body)
+			t.Errorf("(%d) expected %d got %d: %s", i, test.code, w.Code, string(b))
+		}
+	}
+
+	// when sampling is enabled, the sampled requests are evaluated
+	inst.Sampler.Configure(true, 10)
+	for _, s := range testSamples(t) {
+		r, _ := s.Request()
+		inst.Sampler.Record(r)
+	}
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/trickster/debug/config-diff",
+		body(&Request{Config: testCandidateConfig})))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	report := &Report{}
+	if err := json.Unmarshal(w.Body.Bytes(), report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests != len(testRequests) || report.Changed != 4 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
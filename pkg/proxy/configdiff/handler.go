@@ -0,0 +1,114 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package configdiff
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/handlers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/proxy/sampling"
+)
+
+// Request is the body of a request to the Config Diff Handler
+type Request struct {
+	// Config is the candidate config document, in TOML
+	Config string `json:"config"`
+	// Requests are the request lines to evaluate, of the form "METHOD URL". When empty,
+	// the sampled requests are evaluated
+	Requests []string `json:"requests,omitempty"`
+	// MaxExamples is the maximum number of examples reported for each path
+	MaxExamples *int `json:"max_examples,omitempty"`
+}
+
+// HandleFunc returns a handler that evaluates the requests in a POSTed Request against the
+// running config and the Request's candidate config, and responds with the Report. When the
+// Request has no requests, those sampled by the Instance, or by the Default Instance when it is
+// nil, are evaluated
+func HandleFunc(conf *config.Config, inst *instance.Instance) func(http.ResponseWriter, *http.Request) {
+	inst = instance.OrDefault(inst)
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost {
+			handlers.HandleMethodNotAllowedResponse(w, r)
+			return
+		}
+
+		badRequest := func(msg string) {
+			txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest, msg).Respond(w, r)
+		}
+
+		req := &Request{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			badRequest("invalid request body: " + err.Error())
+			return
+		}
+		if req.Config == "" {
+			badRequest("no candidate config provided")
+			return
+		}
+		maxExamples := DefaultMaxExamples
+		if req.MaxExamples != nil {
+			if *req.MaxExamples < 0 {
+				badRequest("invalid max_examples")
+				return
+			}
+			maxExamples = *req.MaxExamples
+		}
+
+		var samples []*sampling.Sample
+		if len(req.Requests) > 0 {
+			samples = make([]*sampling.Sample, 0, len(req.Requests))
+			for _, line := range req.Requests {
+				s, err := sampling.ParseRequestLine(line)
+				if err != nil {
+					badRequest(err.Error() + ": " + line)
+					return
+				}
+				samples = append(samples, s)
+			}
+		} else if inst.Sampler.Enabled() {
+			samples = inst.Sampler.Samples()
+		} else {
+			badRequest("no requests provided, and request sampling is not enabled")
+			return
+		}
+
+		candidate, err := config.LoadDocument(req.Config)
+		if err != nil {
+			badRequest("invalid candidate config: " + err.Error())
+			return
+		}
+
+		// the running config is cloned, since inspecting its routes modifies it
+		report, err := Diff(conf.Clone(), candidate, samples, maxExamples)
+		if err != nil {
+			badRequest("invalid candidate config: " + err.Error())
+			return
+		}
+
+		b, _ := json.Marshal(report)
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import "context"
+
+// WithEstimateRouted returns a copy of the provided context that also indicates the request was
+// routed to its path's designated origin for exceeding the path's max estimated points, so that
+// it is not estimated, and routed, again
+func WithEstimateRouted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, estimateRoutedKey, true)
+}
+
+// EstimateRouted returns true if the request was routed for exceeding its max estimated points
+func EstimateRouted(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	v, _ := ctx.Value(estimateRoutedKey).(bool)
+	return v
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/inflight"
+)
+
+// WithInflightReservation returns a copy of the provided context that also includes the
+// in-flight processing reservation of the request that originated it, so that requests made
+// on its behalf are accounted to its reservation rather than waiting for their own
+func WithInflightReservation(ctx context.Context, r *inflight.Reservation) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, inflightKey, r)
+}
+
+// InflightReservation returns the in-flight processing reservation included in the context, if any
+func InflightReservation(ctx context.Context) *inflight.Reservation {
+	if ctx == nil {
+		return nil
+	}
+	if r, ok := ctx.Value(inflightKey).(*inflight.Reservation); ok {
+		return r
+	}
+	return nil
+}
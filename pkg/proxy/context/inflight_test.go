@@ -0,0 +1,42 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/inflight"
+)
+
+func TestInflightReservation(t *testing.T) {
+
+	if r := InflightReservation(nil); r != nil {
+		t.Error("expected nil reservation")
+	}
+
+	ctx := context.Background()
+	if WithInflightReservation(ctx, nil) != ctx {
+		t.Error("expected the same context")
+	}
+
+	r, _ := inflight.NewBudget(0, 0, nil).Acquire(ctx, 1)
+	ctx = WithInflightReservation(ctx, r)
+	if InflightReservation(ctx) != r {
+		t.Error("expected the same reservation")
+	}
+}
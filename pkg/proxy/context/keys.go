@@ -22,4 +22,9 @@ const (
 	resourcesKey contextKey = iota
 	hopsKey
 	healthCheckKey
+	inflightKey
+	maxLookbackKey
+	priorityKey
+	estimateRoutedKey
+	requestIDKey
 )
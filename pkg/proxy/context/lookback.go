@@ -0,0 +1,37 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+	"time"
+)
+
+// WithMaxLookback returns a copy of the provided context that also includes the max lookback
+// assigned to the request by a rule, which overrides that of the request's path
+func WithMaxLookback(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, maxLookbackKey, d)
+}
+
+// MaxLookback returns the max lookback assigned to the request by a rule, and whether one was assigned
+func MaxLookback(ctx context.Context) (time.Duration, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	d, ok := ctx.Value(maxLookbackKey).(time.Duration)
+	return d, ok
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
+)
+
+// WithPriority returns a copy of the provided context that also includes the priority class
+// assigned to the request by a rule, which overrides that of the request's path
+func WithPriority(ctx context.Context, c priority.Class) context.Context {
+	return context.WithValue(ctx, priorityKey, c)
+}
+
+// Priority returns the priority class assigned to the request by a rule, and whether one was assigned
+func Priority(ctx context.Context) (priority.Class, bool) {
+	if ctx == nil {
+		return priority.ClassNormal, false
+	}
+	c, ok := ctx.Value(priorityKey).(priority.Class)
+	return c, ok
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
+)
+
+func TestPriority(t *testing.T) {
+
+	if _, ok := Priority(nil); ok {
+		t.Error("expected false")
+	}
+
+	ctx := context.Background()
+	if _, ok := Priority(ctx); ok {
+		t.Error("expected false")
+	}
+
+	ctx = WithPriority(ctx, priority.ClassLow)
+	if c, ok := Priority(ctx); !ok || c != priority.ClassLow {
+		t.Errorf("expected %s got %s", priority.ClassLow, c)
+	}
+}
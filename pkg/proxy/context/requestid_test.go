@@ -0,0 +1,39 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+
+	if id := RequestID(nil); id != "" {
+		t.Errorf("expected empty id got %s", id)
+	}
+
+	ctx := context.Background()
+	if id := RequestID(ctx); id != "" {
+		t.Errorf("expected empty id got %s", id)
+	}
+
+	ctx = WithRequestID(ctx, "abc")
+	if id := RequestID(ctx); id != "abc" {
+		t.Errorf("expected %s got %s", "abc", id)
+	}
+}
@@ -23,7 +23,7 @@ import (
 )
 
 func logUpstreamRequest(log *tl.Logger, originName, originType, handlerName, method,
-	path, userAgent string, responseCode, size int, requestDuration float64) {
+	path, userAgent, priority string, responseCode, size int, requestDuration float64) {
 	log.Debug("upstream request",
 		tl.Pairs{
 			"originName":  originName,
@@ -32,6 +32,7 @@ func logUpstreamRequest(log *tl.Logger, originName, originType, handlerName, met
 			"method":      method,
 			"uri":         path,
 			"userAgent":   userAgent,
+			"priority":    priority,
 			"code":        responseCode,
 			"size":        size,
 			"durationMS":  int(requestDuration * 1000),
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+)
+
+// authCachePolicy returns the policy for caching the response to the request, should it be
+// authenticated, resolving the origin's default when the path does not configure one
+func authCachePolicy(r *http.Request) authcache.Policy {
+	rsc := request.GetResources(r)
+	if rsc == nil || rsc.PathConfig == nil {
+		return authcache.PolicyShared
+	}
+	if p := rsc.PathConfig.AuthCachePolicy; p != authcache.PolicyDefault {
+		return p
+	}
+	return origins.DefaultAuthCachePolicy(rsc.OriginClient)
+}
+
+// authPermitsCaching returns false when the request is authenticated and its path's policy
+// requires authenticated requests to be proxied without caching
+func authPermitsCaching(r *http.Request) bool {
+	if authCachePolicy(r) != authcache.PolicyNever {
+		return true
+	}
+	rsc := request.GetResources(r)
+	return !authcache.IsAuthenticated(r, rsc.PathConfig.CacheIdentityHeader)
+}
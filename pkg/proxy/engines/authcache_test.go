@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+func TestAuthCachePolicy(t *testing.T) {
+
+	r, _ := http.NewRequest(http.MethodGet, "http://0/", nil)
+	if p := authCachePolicy(r); p != authcache.PolicyShared {
+		t.Errorf("expected %s got %s", authcache.PolicyShared, p)
+	}
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	// the test client does not report a default, so the safe default is used
+	if p := authCachePolicy(r); p != authcache.PolicyNever {
+		t.Errorf("expected %s got %s", authcache.PolicyNever, p)
+	}
+
+	rsc.PathConfig.AuthCachePolicy = authcache.PolicyPerIdentity
+	if p := authCachePolicy(r); p != authcache.PolicyPerIdentity {
+		t.Errorf("expected %s got %s", authcache.PolicyPerIdentity, p)
+	}
+}
+
+func TestObjectProxyCacheRequestAuthenticated(t *testing.T) {
+
+	type authRequest struct {
+		hdrs   map[string]string
+		status string
+	}
+
+	userA := map[string]string{headers.NameAuthorization: "Basic dXNlcmE6cGFzcw=="}
+	userB := map[string]string{headers.NameAuthorization: "Basic dXNlcmI6cGFzcw=="}
+
+	tests := []struct {
+		policy   authcache.Policy
+		header   string
+		requests []authRequest
+	}{
+		{
+			policy: authcache.PolicyNever,
+			requests: []authRequest{
+				{userA, "proxy-only"},
+				{userA, "proxy-only"},
+				{map[string]string{headers.NameCookie: "session=a"}, "proxy-only"},
+				{nil, "kmiss"},
+				{nil, "hit"},
+			},
+		},
+		{
+			policy: authcache.PolicyShared,
+			requests: []authRequest{
+				{userA, "kmiss"},
+				{userA, "hit"},
+			},
+		},
+		{
+			policy: authcache.PolicyPerIdentity,
+			requests: []authRequest{
+				{userA, "kmiss"},
+				{userA, "hit"},
+				{userB, "kmiss"},
+				{nil, "kmiss"},
+				{nil, "hit"},
+			},
+		},
+		{
+			policy: authcache.PolicyPerIdentity,
+			header: "X-Tenant",
+			requests: []authRequest{
+				{map[string]string{"X-Tenant": "a", headers.NameAuthorization: "a"}, "kmiss"},
+				{map[string]string{"X-Tenant": "a", headers.NameAuthorization: "b"}, "hit"},
+				{map[string]string{"X-Tenant": "b", headers.NameAuthorization: "a"}, "kmiss"},
+			},
+		},
+	}
+
+	for i, test := range tests {
+
+		hdrs := map[string]string{headers.NameCacheControl: "max-age=60"}
+		ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+		if err != nil {
+			t.Error(err)
+		}
+
+		rsc.PathConfig.AuthCachePolicy = test.policy
+		rsc.PathConfig.CacheIdentityHeader = test.header
+		// ensure each test case starts with an empty cache
+		rsc.OriginConfig.CacheKeyPrefix += ".auth." + strconv.Itoa(i)
+
+		for j, ar := range test.requests {
+			r2 := r.Clone(r.Context())
+			for k, v := range ar.hdrs {
+				r2.Header.Set(k, v)
+			}
+			_, e := testFetchOPC(r2, http.StatusOK, "test", map[string]string{"status": ar.status})
+			for _, err = range e {
+				t.Errorf("test %d request %d: %s", i, j, err)
+			}
+		}
+		ts.Close()
+	}
+}
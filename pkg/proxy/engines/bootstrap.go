@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/runtime"
+	"github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const (
+	fillSourceOrigin = "origin"
+	fillSourcePeer   = "bootstrap_peer"
+)
+
+// isBootstrapping returns true if the origin is configured with a bootstrap peer
+// and the process is still within the origin's bootstrap window
+func isBootstrapping(oc *oo.Options) bool {
+	return oc != nil && oc.BootstrapPeer != nil &&
+		time.Since(runtime.StartTime) < oc.BootstrapWindow
+}
+
+// isPeerFillStatus returns true if a bootstrap peer's response status satisfies a fill.
+// Any other status, such as a 404 from a peer that does not serve the origin's path or a
+// 429 from a rate limited peer, is not the origin's response and is not cached
+func isPeerFillStatus(code int) bool {
+	return (code >= http.StatusOK && code < http.StatusMultipleChoices) ||
+		code == http.StatusNotModified
+}
+
+// prepareFillReader is PrepareFetchReader for cache miss fills. While the origin is
+// bootstrapping, cacheable requests are filled from the bootstrap peer, falling back
+// to the origin if the peer cannot satisfy the request. Requests that are themselves
+// bootstrap fills from another peer are always sent to the origin, to prevent loops.
+func prepareFillReader(r *http.Request) (io.ReadCloser, *http.Response, int64) {
+
+	rsc := request.GetResources(r)
+	oc := rsc.OriginConfig
+
+	isPeerFill := r.Header.Get(headers.NameTricksterBootstrap) != ""
+	r.Header.Del(headers.NameTricksterBootstrap)
+
+	if oc == nil {
+		return PrepareFetchReader(r)
+	}
+
+	if !isPeerFill && methods.IsCacheable(r.Method) {
+		if isBootstrapping(oc) {
+			pr := r.Clone(r.Context())
+			pr.URL.Scheme = oc.BootstrapPeer.Scheme
+			pr.URL.Host = oc.BootstrapPeer.Host
+			pr.URL.Path = oc.BootstrapPeer.Path + strings.TrimPrefix(r.URL.Path, oc.PathPrefix)
+			pr.Header.Set(headers.NameTricksterBootstrap, "1")
+			reader, resp, contentLength := PrepareFetchReader(pr)
+			if reader != nil && isPeerFillStatus(resp.StatusCode) {
+				rsc.State().Metrics.ProxyCacheFills.WithLabelValues(oc.Name, oc.OriginType, fillSourcePeer).Inc()
+				return reader, resp, contentLength
+			}
+			if reader != nil {
+				reader.Close()
+			}
+			rsc.Logger.Warn("bootstrap peer fill failed, falling back to origin",
+				log.Pairs{"originName": oc.Name, "peerURL": pr.URL.String(),
+					"peerStatus": resp.StatusCode})
+		} else if oc.BootstrapPeer != nil {
+			rsc.Logger.InfoOnce("bootstrap.complete."+oc.Name, "bootstrap window complete",
+				log.Pairs{"originName": oc.Name, "bootstrapWindow": oc.BootstrapWindow.String()})
+		}
+	}
+
+	reader, resp, contentLength := PrepareFetchReader(r)
+	rsc.State().Metrics.ProxyCacheFills.WithLabelValues(oc.Name, oc.OriginType, fillSourceOrigin).Inc()
+	return reader, resp, contentLength
+}
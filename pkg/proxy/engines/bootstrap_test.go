@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+func TestIsPeerFillStatus(t *testing.T) {
+	for code, expected := range map[int]bool{
+		http.StatusOK:                  true,
+		http.StatusPartialContent:      true,
+		http.StatusNotModified:         true,
+		http.StatusFound:               false,
+		http.StatusBadRequest:          false,
+		http.StatusNotFound:            false,
+		http.StatusTooManyRequests:     false,
+		http.StatusInternalServerError: false,
+	} {
+		if v := isPeerFillStatus(code); v != expected {
+			t.Errorf("expected %t got %t for %d", expected, v, code)
+		}
+	}
+}
+
+func TestPrepareFillReader(t *testing.T) {
+
+	var originMarker, peerMarker, peerPath string
+	peerCode := http.StatusOK
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originMarker = r.Header.Get(headers.NameTricksterBootstrap)
+		w.Write([]byte("origin"))
+	}))
+	defer origin.Close()
+
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peerMarker = r.Header.Get(headers.NameTricksterBootstrap)
+		peerPath = r.URL.Path
+		w.WriteHeader(peerCode)
+		w.Write([]byte("peer"))
+	}))
+	defer peer.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", origin.URL + "/prefix", "-origin-type", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	oc := conf.Origins["default"]
+	oc.HTTPClient = http.DefaultClient
+	oc.BootstrapPeer, _ = url.Parse(peer.URL + "/default")
+	oc.BootstrapWindow = time.Hour
+
+	newRequest := func(method string) *http.Request {
+		r := httptest.NewRequest(method, origin.URL+"/prefix/api/v1/query?query=up", nil)
+		return r.WithContext(tc.WithResources(r.Context(),
+			request.NewResources(oc, nil, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+	}
+
+	fill := func(r *http.Request) string {
+		reader, _, _ := prepareFillReader(r)
+		if reader == nil {
+			t.Fatal("expected non-nil reader")
+		}
+		defer reader.Close()
+		b, _ := ioutil.ReadAll(reader)
+		return string(b)
+	}
+
+	// within the bootstrap window, the fill should come from the peer
+	if v := fill(newRequest(http.MethodGet)); v != "peer" {
+		t.Errorf("expected %s got %s", "peer", v)
+	}
+	if peerMarker == "" {
+		t.Error("expected bootstrap header on peer request")
+	}
+	if peerPath != "/default/api/v1/query" {
+		t.Errorf("expected %s got %s", "/default/api/v1/query", peerPath)
+	}
+
+	// bootstrap fill requests from another peer must go to the origin, without the marker
+	r := newRequest(http.MethodGet)
+	r.Header.Set(headers.NameTricksterBootstrap, "1")
+	if v := fill(r); v != "origin" {
+		t.Errorf("expected %s got %s", "origin", v)
+	}
+	if originMarker != "" {
+		t.Error("expected no bootstrap header on origin request")
+	}
+
+	// uncacheable methods always go to the origin
+	if v := fill(newRequest(http.MethodPost)); v != "origin" {
+		t.Errorf("expected %s got %s", "origin", v)
+	}
+
+	// a failing peer, or one responding with any status other than 2xx or 304,
+	// falls back to the origin
+	for _, code := range []int{http.StatusBadGateway, http.StatusNotFound,
+		http.StatusTooManyRequests, http.StatusFound} {
+		peerCode = code
+		if v := fill(newRequest(http.MethodGet)); v != "origin" {
+			t.Errorf("expected %s got %s for peer status %d", "origin", v, code)
+		}
+	}
+	peerCode = http.StatusOK
+
+	// after the bootstrap window, fills go to the origin
+	oc.BootstrapWindow = 0
+	if v := fill(newRequest(http.MethodGet)); v != "origin" {
+		t.Errorf("expected %s got %s", "origin", v)
+	}
+}
@@ -18,12 +18,18 @@ package engines
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
 	"mime"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
 	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
@@ -33,9 +39,147 @@ import (
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 
 	"github.com/golang/snappy"
+	"github.com/tinylib/msgp/msgp"
 	"go.opentelemetry.io/otel/api/kv"
 )
 
+// serialized cache objects are prefixed with an envelope flags byte. When the checksum
+// flag is set, the flags byte is followed by a 4-byte CRC-32C of the payload. Objects
+// written before checksums were supported never have the flag set, and are not verified.
+// The high 4 bits of the flags byte are the schema version of the payload
+const (
+	envelopeCompressed = byte(1 << iota)
+	envelopeChecksum
+)
+
+const (
+	envelopeChecksumLen = 4
+	envelopeFlagsMask   = envelopeCompressed | envelopeChecksum
+	envelopeSchemaShift = 4
+	// envelopeSchemaVersion is the schema version of the objects written by this release
+	envelopeSchemaVersion = 0
+)
+
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// The classes of failures to retrieve or decode a cache object, which label the
+// trickster_cache_decode_failures_total metric
+const (
+	decodeFailureRetrieve  = "retrieve"
+	decodeFailureShortRead = "short_read"
+	decodeFailureCodec     = "codec"
+	decodeFailureChecksum  = "checksum"
+	decodeFailureSchema    = "schema"
+	decodeFailureUnmarshal = "unmarshal"
+)
+
+// cacheDecodeError is a failure to retrieve or decode a cache object
+type cacheDecodeError struct {
+	class string
+	err   error
+}
+
+func (e *cacheDecodeError) Error() string {
+	return e.err.Error()
+}
+
+var (
+	errEnvelopeShortRead = errors.New("cache object is shorter than its envelope")
+	errEnvelopeCodec     = errors.New("cache object envelope has unknown flags")
+	errEnvelopeChecksum  = errors.New("cache object failed checksum verification")
+	errEnvelopeSchema    = errors.New("cache object has an unknown schema version")
+)
+
+// sealEnvelope prefixes the payload with its envelope
+func sealEnvelope(payload []byte, compressed, checksum bool) []byte {
+	flags := byte(envelopeSchemaVersion << envelopeSchemaShift)
+	if compressed {
+		flags |= envelopeCompressed
+	}
+	if !checksum {
+		return append([]byte{flags}, payload...)
+	}
+	b := make([]byte, 1+envelopeChecksumLen, 1+envelopeChecksumLen+len(payload))
+	b[0] = flags | envelopeChecksum
+	binary.BigEndian.PutUint32(b[1:], crc32.Checksum(payload, checksumTable))
+	return append(b, payload...)
+}
+
+// openEnvelope returns the payload from a serialized cache object, and whether the payload
+// is compressed. The error is a *cacheDecodeError when the envelope can't be opened
+func openEnvelope(b []byte) (payload []byte, compressed bool, err error) {
+	if len(b) == 0 {
+		return b, false, nil
+	}
+	flags := b[0]
+	if flags>>envelopeSchemaShift > envelopeSchemaVersion {
+		return nil, false, &cacheDecodeError{class: decodeFailureSchema, err: errEnvelopeSchema}
+	}
+	if flags&^(envelopeFlagsMask|0xf0) != 0 {
+		return nil, false, &cacheDecodeError{class: decodeFailureCodec, err: errEnvelopeCodec}
+	}
+	payload = b[1:]
+	compressed = flags&envelopeCompressed != 0
+	if flags&envelopeChecksum == 0 {
+		return payload, compressed, nil
+	}
+	if len(payload) < envelopeChecksumLen {
+		return nil, compressed, &cacheDecodeError{class: decodeFailureShortRead, err: errEnvelopeShortRead}
+	}
+	sum := binary.BigEndian.Uint32(payload)
+	payload = payload[envelopeChecksumLen:]
+	if crc32.Checksum(payload, checksumTable) != sum {
+		return nil, compressed, &cacheDecodeError{class: decodeFailureChecksum, err: errEnvelopeChecksum}
+	}
+	return payload, compressed, nil
+}
+
+// decodeDocument decodes a serialized cache object into an HTTPDocument. The error is a
+// *cacheDecodeError when the object can't be decoded
+func decodeDocument(b []byte) (*HTTPDocument, error) {
+	payload, compressed, err := openEnvelope(b)
+	if err != nil {
+		return nil, err
+	}
+	if compressed {
+		if payload, err = snappy.Decode(nil, payload); err != nil {
+			return nil, &cacheDecodeError{class: decodeFailureCodec, err: err}
+		}
+	}
+	d := &HTTPDocument{}
+	if _, err = d.UnmarshalMsg(payload); err != nil {
+		return nil, &cacheDecodeError{class: unmarshalFailureClass(err), err: err}
+	}
+	return d, nil
+}
+
+// unmarshalFailureClass returns the failure class of an error unmarshaling a cache object
+func unmarshalFailureClass(err error) string {
+	if c := msgp.Cause(err); c == msgp.ErrShortBytes || c == io.ErrUnexpectedEOF {
+		return decodeFailureShortRead
+	}
+	return decodeFailureUnmarshal
+}
+
+// discardCacheObject handles a cache object that could not be retrieved or decoded by logging
+// the failure, removing the object and counting the failure by its class, so that the request
+// proceeds as a cache miss and the object is rewritten by its response
+func discardCacheObject(logger *tl.Logger, c cache.Cache, key string, err error) {
+	class := decodeFailureUnmarshal
+	if de, ok := err.(*cacheDecodeError); ok {
+		class = de.class
+	}
+	cc := c.Configuration()
+	logger.Warn("discarding cache object that could not be decoded", tl.Pairs{
+		"cacheKey": key, "class": class, "detail": err.Error()})
+	c.Remove(key)
+	emitCacheEvent(nil, c, events.TypePurge, key, "undecodable", 0, nil)
+	metrics.ObserveCacheDecodeFailure(cc.Metrics, cc.Name, cc.CacheType, class)
+	if class == decodeFailureChecksum {
+		metrics.ObserveCacheCorruption(cc.Metrics, cc.Name, cc.CacheType)
+	}
+}
+
 // QueryCache queries the cache for an HTTPDocument and returns it
 func QueryCache(ctx context.Context, c cache.Cache, key string,
 	ranges byterange.Ranges) (*HTTPDocument, status.LookupStatus, byterange.Ranges, error) {
@@ -79,6 +223,13 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 
 		bytes, lookupStatus, err = c.Retrieve(key, true)
 
+		if err != nil && err != cache.ErrKNF {
+			discardCacheObject(rsc.Logger, c, key,
+				&cacheDecodeError{class: decodeFailureRetrieve, err: err})
+			tspan.SetAttributes(rsc.Tracer, span, kv.String("cache.status", status.LookupStatusKeyMiss.String()))
+			return &HTTPDocument{}, status.LookupStatusKeyMiss, ranges, cache.ErrKNF
+		}
+
 		if err != nil || (lookupStatus != status.LookupStatusHit) {
 			var nr byterange.Ranges
 			if lookupStatus == status.LookupStatusKeyMiss && ranges != nil && len(ranges) > 0 {
@@ -89,31 +240,14 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 			return d, lookupStatus, nr, err
 		}
 
-		var inflate bool
-		// check and remove compression bit
-		if len(bytes) > 0 {
-			if bytes[0] == 1 {
-				inflate = true
-			}
-			bytes = bytes[1:]
-		}
-
-		if inflate {
-			rsc.Logger.Debug("decompressing cached data", tl.Pairs{"cacheKey": key})
-			b, err := snappy.Decode(nil, bytes)
-			if err == nil {
-				bytes = b
-			}
-		}
-		_, err = d.UnmarshalMsg(bytes)
+		d, err = decodeDocument(bytes)
 		if err != nil {
-			rsc.Logger.Error("error unmarshaling cache document", tl.Pairs{
-				"cacheKey": key,
-				"detail":   err.Error(),
-			})
+			discardCacheObject(rsc.Logger, c, key, err)
 			tspan.SetAttributes(rsc.Tracer, span, kv.String("cache.status", status.LookupStatusKeyMiss.String()))
-			return d, status.LookupStatusKeyMiss, ranges, err
+			return &HTTPDocument{}, status.LookupStatusKeyMiss, ranges, cache.ErrKNF
 		}
+		// objects written by earlier versions may include headers that must not be replayed
+		headers.StripCacheDeniedHeaders(http.Header(d.Headers))
 
 	}
 
@@ -121,7 +255,12 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 
 	// Fulfillment is when we have a range stored, but a subsequent user wants the whole body, so
 	// we must inflate the requested range to be the entire object in order to get the correct delta.
-	d.isFulfillment = (d.Ranges != nil && len(d.Ranges) > 0) && (ranges == nil || len(ranges) == 0)
+	// A document retrieved by reference from a memory cache is shared with concurrent readers,
+	// so the flag is set on a copy of the document
+	if f := (d.Ranges != nil && len(d.Ranges) > 0) && (ranges == nil || len(ranges) == 0); f != d.isFulfillment {
+		d = d.clone()
+		d.isFulfillment = f
+	}
 
 	if d.isFulfillment {
 		if span != nil {
@@ -166,12 +305,27 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 		defer span.End()
 	}
 
+	rsc.Logger.Trace("writing object to cache", tl.Pairs{"cacheKey": key, "ttl": ttl.String()})
+
 	d.headerLock.Lock()
-	h := http.Header(d.Headers)
+	// the stored headers are a filtered copy, so any headers that must not be cached
+	// are still delivered in the response to the client that filled the cache
+	var allow, deny []string
+	if pc := rsc.PathConfig; pc != nil {
+		allow, deny = pc.CacheAllowHeaders, pc.CacheDenyHeaders
+	}
+	h := headers.FilterCacheHeaders(http.Header(d.Headers), allow, deny)
+	if h == nil {
+		h = make(http.Header)
+	}
+	d.Headers = h
 	h.Del(headers.NameDate)
 	h.Del(headers.NameTransferEncoding)
 	h.Del(headers.NameContentRange)
 	h.Del(headers.NameTricksterResult)
+	h.Del(headers.NameTricksterChain)
+	h.Del(headers.NameTricksterPartial)
+	h.Del(headers.NameRequestID)
 	ce := h.Get(headers.NameContentEncoding)
 	d.headerLock.Unlock()
 
@@ -218,10 +372,9 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 
 	if compress {
 		rsc.Logger.Debug("compressing cache data", tl.Pairs{"cacheKey": key})
-		bytes = append([]byte{1}, snappy.Encode(nil, bytes)...)
-	} else {
-		bytes = append([]byte{0}, bytes...)
+		bytes = snappy.Encode(nil, bytes)
 	}
+	bytes = sealEnvelope(bytes, compress, c.Configuration().VerifyChecksums)
 
 	err = c.Store(key, bytes, ttl)
 	if err != nil {
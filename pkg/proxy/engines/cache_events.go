@@ -0,0 +1,51 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// emitCacheEvent emits a cache event for the mutation of the key's object in the cache, on behalf
+// of the request resources' origin when they are provided. The event holds the time range of the
+// extents of a timeseries object, when they are provided. The event is published to the cache's
+// Publisher, or to the Default Publisher when the cache has none
+func emitCacheEvent(rsc *request.Resources, c cache.Cache, eventType, key, reason string,
+	size int, el timeseries.ExtentList) {
+	cc := c.Configuration()
+	if cc == nil {
+		return
+	}
+	p := events.OrDefault(cc.Events)
+	if !p.Enabled() {
+		return
+	}
+	e := events.New(eventType, cc.Name, key)
+	if rsc != nil && rsc.OriginConfig != nil {
+		e.Origin = rsc.OriginConfig.Name
+	}
+	e.Bytes = int64(size)
+	if len(el) > 0 {
+		e.ExtentStart = el[0].Start.Unix()
+		e.ExtentEnd = el[len(el)-1].End.Unix()
+	}
+	e.Reason = reason
+	p.Emit(e)
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// waitCacheEvents returns the recorded cache events once there are at least count of them
+func waitCacheEvents(t *testing.T, count int) []*events.Event {
+	deadline := time.Now().Add(time.Second)
+	for {
+		evs, _, _, err := events.Default.Events(0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(evs) >= count {
+			return evs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d cache events got %d", count, len(evs))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestObjectProxyCacheRequestCacheEvents(t *testing.T) {
+
+	if err := events.Default.Configure(true, &events.Options{Size: 10}); err != nil {
+		t.Fatal(err)
+	}
+	defer events.Default.Configure(false, nil)
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	evs := waitCacheEvents(t, 1)
+	if evs[0].Type != events.TypeStore || evs[0].Origin != rsc.OriginConfig.Name ||
+		evs[0].Cache != rsc.CacheConfig.Name || evs[0].Bytes != 4 || evs[0].KeyHash == "" {
+		t.Errorf("unexpected event %+v", evs[0])
+	}
+
+	// a client's no-cache request purges the object
+	r.Header.Set(headers.NameCacheControl, headers.ValueNoCache)
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "proxy-only"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	evs = waitCacheEvents(t, 2)
+	if evs[1].Type != events.TypePurge || evs[1].Reason != "no-cache" ||
+		evs[1].KeyHash != evs[0].KeyHash {
+		t.Errorf("unexpected event %+v", evs[1])
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"testing"
 	"time"
 
+	tcache "github.com/tricksterproxy/trickster/pkg/cache"
 	co "github.com/tricksterproxy/trickster/pkg/cache/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/registration"
 	cr "github.com/tricksterproxy/trickster/pkg/cache/registration"
@@ -36,6 +37,7 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/locks"
 	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/ranges/byterange"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
@@ -439,6 +441,193 @@ func TestQueryCache(t *testing.T) {
 
 }
 
+func TestEnvelope(t *testing.T) {
+
+	payload := []byte("trickster")
+
+	tests := []struct {
+		compressed, checksum bool
+		length               int
+	}{
+		{false, false, len(payload) + 1},
+		{true, false, len(payload) + 1},
+		{false, true, len(payload) + 5},
+		{true, true, len(payload) + 5},
+	}
+
+	for i, test := range tests {
+		b := sealEnvelope(payload, test.compressed, test.checksum)
+		if len(b) != test.length {
+			t.Errorf("test %d: expected %d got %d", i, test.length, len(b))
+		}
+		p, compressed, err := openEnvelope(b)
+		if err != nil {
+			t.Errorf("test %d: unexpected error %v", i, err)
+		}
+		if compressed != test.compressed {
+			t.Errorf("test %d: expected %t got %t", i, test.compressed, compressed)
+		}
+		if string(p) != string(payload) {
+			t.Errorf("test %d: expected %s got %s", i, string(payload), string(p))
+		}
+	}
+
+	// simulate a truncated value
+	b := sealEnvelope(payload, false, true)
+	if _, _, err := openEnvelope(b[:len(b)-2]); failureClass(err) != decodeFailureChecksum {
+		t.Errorf("expected checksum mismatch got %v", err)
+	}
+	if _, _, err := openEnvelope(b[:3]); failureClass(err) != decodeFailureShortRead {
+		t.Errorf("expected short read got %v", err)
+	}
+
+	// objects written before checksums were supported are not verified
+	p, compressed, err := openEnvelope(append([]byte{1}, payload...))
+	if err != nil || !compressed || string(p) != string(payload) {
+		t.Error("expected legacy envelope to be opened")
+	}
+
+	// objects with unknown flags or schema versions are not opened
+	if _, _, err := openEnvelope(append([]byte{envelopeChecksum << 2}, payload...)); failureClass(err) != decodeFailureCodec {
+		t.Errorf("expected codec failure got %v", err)
+	}
+	if _, _, err := openEnvelope(append([]byte{1 << envelopeSchemaShift}, payload...)); failureClass(err) != decodeFailureSchema {
+		t.Errorf("expected schema failure got %v", err)
+	}
+}
+
+// failureClass returns the failure class of a cacheDecodeError, or an empty string
+func failureClass(err error) string {
+	if de, ok := err.(*cacheDecodeError); ok {
+		return de.class
+	}
+	return ""
+}
+
+func TestQueryCacheChecksumMismatch(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-origin-type", "test"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := registration.LoadCachesFromConfig(conf, testLogger)
+	defer registration.CloseCaches(caches)
+	cache, ok := caches["default"]
+	if !ok {
+		t.Errorf("Could not find default configuration")
+	}
+	// use the marshaling route by making our cache not appear to be a memory cache
+	cache.Configuration().CacheType = "test"
+	cache.Configuration().VerifyChecksums = true
+
+	resp := &http.Response{}
+	resp.Header = make(http.Header)
+	resp.StatusCode = 200
+	d := DocumentFromHTTPResponse(resp, []byte("1234"), nil, testLogger)
+
+	ctx := context.Background()
+	ctx = tc.WithResources(ctx, &request.Resources{OriginConfig: conf.Origins["default"],
+		Tracer: tu.NewTestTracer(), Logger: testLogger})
+
+	err = WriteCache(ctx, cache, "testKey", d, time.Duration(60)*time.Second, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, lookupStatus, _, err := QueryCache(ctx, cache, "testKey", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if lookupStatus != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, lookupStatus)
+	}
+
+	// truncate the stored object
+	b, _, _ := cache.Retrieve("testKey", true)
+	cache.Store("testKey", b[:len(b)-3], time.Duration(60)*time.Second)
+
+	_, lookupStatus, _, err = QueryCache(ctx, cache, "testKey", nil)
+	if err != tcache.ErrKNF {
+		t.Errorf("expected %v got %v", tcache.ErrKNF, err)
+	}
+	if lookupStatus != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, lookupStatus)
+	}
+
+	// the corrupted object should have been removed
+	if _, _, err = cache.Retrieve("testKey", true); err != tcache.ErrKNF {
+		t.Errorf("expected %v got %v", tcache.ErrKNF, err)
+	}
+}
+
+func TestQueryCacheDecodeFailures(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-origin-type", "test"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := registration.LoadCachesFromConfig(conf, testLogger)
+	defer registration.CloseCaches(caches)
+	cache, ok := caches["default"]
+	if !ok {
+		t.Errorf("Could not find default configuration")
+	}
+	// use the marshaling route by making our cache not appear to be a memory cache
+	cache.Configuration().CacheType = "test"
+
+	ctx := context.Background()
+	ctx = tc.WithResources(ctx, &request.Resources{OriginConfig: conf.Origins["default"],
+		Tracer: tu.NewTestTracer(), Logger: testLogger})
+
+	resp := &http.Response{StatusCode: 200, Header: make(http.Header)}
+	d := DocumentFromHTTPResponse(resp, []byte("1234"), nil, testLogger)
+	payload, _ := d.MarshalMsg(nil)
+
+	checksummed := sealEnvelope(payload, false, true)
+	checksummed[len(checksummed)-1]++
+
+	tests := []struct {
+		class  string
+		object []byte
+	}{
+		{decodeFailureShortRead, sealEnvelope(payload[:len(payload)/2], false, false)},
+		{decodeFailureShortRead, sealEnvelope(payload, false, true)[:3]},
+		{decodeFailureCodec, append([]byte{1 << 3}, payload...)},
+		{decodeFailureCodec, sealEnvelope([]byte("not snappy"), true, false)},
+		{decodeFailureChecksum, checksummed},
+		{decodeFailureSchema, append([]byte{(envelopeSchemaVersion + 1) << envelopeSchemaShift}, payload...)},
+		{decodeFailureUnmarshal, sealEnvelope([]byte{0xc1}, false, false)},
+	}
+
+	for i, test := range tests {
+		if _, err := decodeDocument(test.object); failureClass(err) != test.class {
+			t.Errorf("test %d: expected %s failure got %v", i, test.class, err)
+		}
+
+		cache.Store("testKey", test.object, time.Duration(60)*time.Second)
+		_, lookupStatus, _, err := QueryCache(ctx, cache, "testKey", nil)
+		if err != tcache.ErrKNF {
+			t.Errorf("test %d: expected %v got %v", i, tcache.ErrKNF, err)
+		}
+		if lookupStatus != status.LookupStatusKeyMiss {
+			t.Errorf("test %d: expected %s got %s", i, status.LookupStatusKeyMiss, lookupStatus)
+		}
+		// the undecodable object should have been removed
+		if _, _, err = cache.Retrieve("testKey", true); err != tcache.ErrKNF {
+			t.Errorf("test %d: expected %v got %v", i, tcache.ErrKNF, err)
+		}
+	}
+
+	// retrieval errors are also handled as cache misses
+	ec := &testCache{configuration: cache.Configuration(), locker: locks.NewNamedLocker()}
+	_, lookupStatus, _, err := QueryCache(ctx, ec, "testKey", nil)
+	if err != tcache.ErrKNF || lookupStatus != status.LookupStatusKeyMiss {
+		t.Errorf("expected %v %s got %v %s", tcache.ErrKNF, status.LookupStatusKeyMiss, err, lookupStatus)
+	}
+}
+
 // Mock Cache for testing error conditions
 type testCache struct {
 	configuration *co.Options
@@ -466,3 +655,107 @@ func (tc *testCache) Close() error                              { return errTest
 func (tc *testCache) Configuration() *co.Options                { return tc.configuration }
 func (tc *testCache) Locker() locks.NamedLocker                 { return tc.locker }
 func (tc *testCache) SetLocker(l locks.NamedLocker)             { tc.locker = l }
+
+func TestWriteCacheFilterHeaders(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-origin-type", "test"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := registration.LoadCachesFromConfig(conf, testLogger)
+	defer registration.CloseCaches(caches)
+	cache, ok := caches["default"]
+	if !ok {
+		t.Errorf("Could not find default configuration")
+	}
+	// use the marshaling route by making our cache not appear to be a memory cache
+	cache.Configuration().CacheType = "test"
+
+	pc := po.NewOptions()
+	pc.CacheDenyHeaders = []string{"x-example"}
+
+	resp := &http.Response{StatusCode: 200, Header: http.Header{
+		headers.NameSetCookie: []string{"session=trickster"},
+		"X-Example":           []string{"test"},
+		"X-Correlation-Id":    []string{"12345"},
+		headers.NameRequestID: []string{"abc"},
+	}}
+	d := DocumentFromHTTPResponse(resp, []byte("1234"), nil, testLogger)
+
+	ctx := context.Background()
+	ctx = tc.WithResources(ctx, &request.Resources{OriginConfig: conf.Origins["default"],
+		PathConfig: pc, Tracer: tu.NewTestTracer(), Logger: testLogger})
+
+	err = WriteCache(ctx, cache, "testKey", d, time.Duration(60)*time.Second, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	// the response headers are not modified
+	if resp.Header.Get(headers.NameSetCookie) == "" {
+		t.Error("expected Set-Cookie header")
+	}
+
+	d, _, _, err = QueryCache(ctx, cache, "testKey", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	h := http.Header(d.Headers)
+	// the request ID of the response that filled the cache is not replayed
+	if h.Get(headers.NameSetCookie) != "" || h.Get("X-Example") != "" ||
+		h.Get("X-Correlation-Id") != "12345" || h.Get(headers.NameRequestID) != "" {
+		t.Errorf("unexpected headers: %s", headers.LogString(h))
+	}
+
+	// objects that were stored with denied headers do not replay them
+	d.Headers[headers.NameSetCookie] = []string{"session=trickster"}
+	b, _ := d.MarshalMsg(nil)
+	cache.Store("testKey", sealEnvelope(b, false, false), time.Duration(60)*time.Second)
+	d, _, _, err = QueryCache(ctx, cache, "testKey", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if v := http.Header(d.Headers).Get(headers.NameSetCookie); v != "" {
+		t.Errorf("expected empty Set-Cookie header, got %s", v)
+	}
+}
+
+func TestQueryCacheFulfillmentCopy(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-origin-type", "test"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := registration.LoadCachesFromConfig(conf, testLogger)
+	defer registration.CloseCaches(caches)
+	cache := caches["default"]
+
+	resp := &http.Response{}
+	resp.Header = make(http.Header)
+	resp.StatusCode = http.StatusPartialContent
+	resp.Header.Add(headers.NameContentRange, "bytes 0-3/10")
+	d := DocumentFromHTTPResponse(resp, []byte("1234"), nil, testLogger)
+
+	ctx := context.Background()
+	ctx = tc.WithResources(ctx, &request.Resources{OriginConfig: conf.Origins["default"],
+		Tracer: tu.NewTestTracer(), Logger: testLogger})
+
+	err = WriteCache(ctx, cache, "testKey", d, time.Duration(60)*time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a request for the whole body of a stored range is a fulfillment, which is flagged on
+	// a copy of the document, rather than the one shared by reference from the memory cache
+	d2, _, _, err := QueryCache(ctx, cache, "testKey", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d2.isFulfillment {
+		t.Error("expected fulfillment")
+	}
+	if d2 == d || d.isFulfillment {
+		t.Error("expected the cached document to be unmodified")
+	}
+}
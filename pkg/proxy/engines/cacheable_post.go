@@ -0,0 +1,123 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+)
+
+// replayableBody is a request body that can be replayed to the origin, via the
+// request's GetBody func, on cache misses, revalidations and retries
+type replayableBody struct {
+	*bytes.Reader
+}
+
+func (rb *replayableBody) Close() error {
+	return nil
+}
+
+// setReplayableBody sets the request's body to the provided bytes, such that it can be replayed
+func setReplayableBody(r *http.Request, b []byte) {
+	r.GetBody = func() (io.ReadCloser, error) {
+		return &replayableBody{bytes.NewReader(b)}, nil
+	}
+	r.Body, _ = r.GetBody()
+	r.ContentLength = int64(len(b))
+}
+
+// rewindBody resets a replayable request body, which may have been consumed by an earlier upstream request
+func rewindBody(r *http.Request) {
+	if r.GetBody == nil {
+		return
+	}
+	if _, ok := r.Body.(*replayableBody); !ok {
+		return
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		return
+	}
+	r.Body = body
+	if rb, ok := body.(*replayableBody); ok {
+		r.ContentLength = rb.Size()
+	}
+}
+
+// prepareCacheablePost buffers the body of a POST request to a path that is configured to cache POST
+// requests, so that it can be hashed into the cache key and replayed to the origin. It returns false
+// when the request must instead be proxied without caching
+func prepareCacheablePost(pr *proxyRequest) bool {
+
+	rsc := request.GetResources(pr.Request)
+	pc := rsc.PathConfig
+
+	if pr.Method != http.MethodPost || pc == nil || !pc.CachePostRequests {
+		return true
+	}
+
+	if pr.Header.Get(headers.NameAuthorization) != "" && !pc.CachePostAllowAuthorization {
+		return false
+	}
+
+	max := int64(pc.CachePostMaxBodyBytes)
+	if max <= 0 {
+		max = d.DefaultCachePostMaxBodyBytes
+	}
+	if pr.ContentLength > max {
+		return false
+	}
+
+	var b []byte
+	if pr.Body != nil {
+		body := pr.Body
+		var err error
+		b, err = ioutil.ReadAll(io.LimitReader(body, max+1))
+		if err != nil || int64(len(b)) > max {
+			// restore the portion of the body that was read, so the request can still be proxied
+			pr.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(b), body), body}
+			pr.upstreamRequest.Body = pr.Request.Body
+			return false
+		}
+		body.Close()
+	}
+
+	pr.isCacheablePost = true
+	pr.postBody = b
+	pr.resetPostBody()
+
+	return true
+}
+
+// resetPostBody resets the request bodies of a cacheable POST request to the buffered body,
+// e.g., after the body has been read to derive the cache key
+func (pr *proxyRequest) resetPostBody() {
+	if !pr.isCacheablePost {
+		return
+	}
+	setReplayableBody(pr.Request, pr.postBody)
+	setReplayableBody(pr.upstreamRequest, pr.postBody)
+}
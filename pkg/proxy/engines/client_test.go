@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"net/url"
@@ -427,6 +428,26 @@ type MatrixData struct {
 	Result     model.Matrix `json:"result"`
 }
 
+// StreamTimeseries writes a Timeseries as newline-delimited JSON frames
+func (c *TestClient) StreamTimeseries(ts timeseries.Timeseries, w io.Writer, flush func()) error {
+	me, ok := ts.(*MatrixEnvelope)
+	if !ok {
+		return fmt.Errorf("cannot stream %T as matrix frames", ts)
+	}
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(map[string]string{"status": me.Status, "resultType": me.Data.ResultType}); err != nil {
+		return err
+	}
+	flush()
+	for _, s := range me.Data.Result {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+		flush()
+	}
+	return nil
+}
+
 // MarshalTimeseries converts a Timeseries into a JSON blob
 func (c *TestClient) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
 	// Marshal the Envelope back to a json object for Cache Storage
@@ -565,7 +586,10 @@ func (me *MatrixEnvelope) Clone() timeseries.Timeseries {
 
 	for _, ss := range me.Data.Result {
 		newSS := &model.SampleStream{Metric: ss.Metric}
-		newSS.Values = ss.Values[:]
+		// the values are copied, since a later Merge may append to a cropped clone
+		// and overwrite the original's values beyond the cropped length
+		newSS.Values = make([]model.SamplePair, len(ss.Values))
+		copy(newSS.Values, ss.Values)
 		resMe.Data.Result = append(resMe.Data.Result, newSS)
 	}
 	return resMe
@@ -651,6 +675,22 @@ func (me *MatrixEnvelope) CropToSize(sz int, t time.Time, lur timeseries.Extent)
 	me.Sort()
 }
 
+// Downsample reduces the Timeseries to only those values whose timestamps are aligned to the provided step
+func (me *MatrixEnvelope) Downsample(step time.Duration) {
+	ms := int64(step / time.Millisecond)
+	me.isCounted = false
+	for i, s := range me.Data.Result {
+		vals := make([]model.SamplePair, 0, len(s.Values))
+		for _, v := range s.Values {
+			if int64(v.Timestamp)%ms == 0 {
+				vals = append(vals, v)
+			}
+		}
+		me.Data.Result[i].Values = vals
+	}
+	me.StepDuration = step
+}
+
 // CropToRange reduces the Timeseries down to timestamps contained within the provided Extents (inclusive).
 // CropToRange assumes the base Timeseries is already sorted, and will corrupt an unsorted Timeseries
 func (me *MatrixEnvelope) CropToRange(e timeseries.Extent) {
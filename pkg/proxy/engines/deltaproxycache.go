@@ -18,23 +18,32 @@ package engines
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	tc "github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
 	"github.com/tricksterproxy/trickster/pkg/cache/evictionmethods"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
 	"github.com/tricksterproxy/trickster/pkg/locks"
+	"github.com/tricksterproxy/trickster/pkg/proxy/background"
 	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	tpe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/partial"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
 	tspan "github.com/tricksterproxy/trickster/pkg/tracing/span"
+	terr "github.com/tricksterproxy/trickster/pkg/util/errors"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
-	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
 
 	"go.opentelemetry.io/otel/api/kv"
 	"go.opentelemetry.io/otel/api/trace"
@@ -46,6 +55,24 @@ import (
 // requests the gaps from the origin server and returns the reconstituted dataset to the downstream
 // request while caching the results for subsequent requests of the same data
 func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
+	if !enforceMaxLookback(w, r) || !enforceMaxEstimatedPoints(w, r) {
+		return
+	}
+	if rsc := request.GetResources(r); rsc != nil && rsc.PathConfig != nil && rsc.PathConfig.SplitQueries {
+		if splitQueryRequest(w, r) {
+			return
+		}
+		w.Header().Set(headers.NameTricksterQuerySplit, "whole")
+	}
+	deltaProxyCacheRequest(w, r, false)
+}
+
+// deltaProxyCacheRequest services a DeltaProxyCacheRequest. When writeLocked is true, the
+// cache key is write-locked for the entire request, rather than read-locked and upgraded
+// on a cache miss. This is used to retry a request that lost the race to upgrade its read
+// lock, since under heavy contention for a key, a retry that takes another read lock can
+// lose every subsequent race as well.
+func deltaProxyCacheRequest(w http.ResponseWriter, r *http.Request, writeLocked bool) {
 
 	rsc := request.GetResources(r)
 	oc := rsc.OriginConfig
@@ -70,10 +97,36 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var cacheStatus status.LookupStatus
+	if !authPermitsCaching(r) {
+		DoProxy(w, r, true)
+		return
+	}
+
+	// the query's costs are recorded under its fingerprint once the response is complete. A retry
+	// continues with the recorder of the request it retries, which then leaves the recording to it
+	qr, ok := w.(*queryRecorder)
+	if !ok {
+		qr = newQueryRecorder(w, client, oc, trq)
+		if pc != nil {
+			qr.seriesTTL = time.Duration(pc.MaxEstimatedPointsTTLSecs) * time.Second
+		}
+		w = qr
+	}
+	tspan.SetAttributes(rsc.Tracer, span, kv.String("query.fingerprint", qr.fingerprint))
+
+	// requests that are proxied without caching retain the LookupStatusProxyOnly status
+	cacheStatus := status.LookupStatusProxyOnly
+	var elapsed time.Duration
+	var retried bool
+	defer func() {
+		if !retried {
+			qr.record(rsc, cacheStatus, elapsed)
+		}
+	}()
 
 	pr := newProxyRequest(r, w)
-	trq.FastForwardDisable = oc.FastForwardDisable || trq.FastForwardDisable
+	// fast forward data is optional, so it is not fetched while the origin's rate limit is low
+	trq.FastForwardDisable = oc.FastForwardDisable || trq.FastForwardDisable || servesStale(r, rsc)
 	trq.NormalizeExtent()
 
 	// this is used to ensure the head of the cache respects the BackFill Tolerance
@@ -86,9 +139,19 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 
 	now := time.Now()
 
+	// when the origin's clock is behind, its most recent data trails our "now", so the
+	// leading edge is measured against the origin's clock to avoid caching incomplete data
+	if oc.ClockSkewCorrection {
+		if skew := rsc.State().ClockSkews.Estimate(oc.Name); skew < 0 {
+			if le := now.Add(skew).Add(-bt); bf.End.After(le) {
+				bf.End = le
+			}
+		}
+	}
+
 	OldestRetainedTimestamp := time.Time{}
 	if oc.TimeseriesEvictionMethod == evictionmethods.EvictionMethodOldest {
-		OldestRetainedTimestamp = now.Truncate(trq.Step).Add(-(trq.Step * oc.TimeseriesRetention))
+		OldestRetainedTimestamp = trq.AlignTime(now).Add(-(trq.Step * oc.TimeseriesRetention))
 		if trq.Extent.End.Before(OldestRetainedTimestamp) {
 			pr.Logger.Debug("timerange end is too early to consider caching",
 				tl.Pairs{"oldestRetainedTimestamp": OldestRetainedTimestamp,
@@ -105,22 +168,64 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	client.SetExtent(pr.upstreamRequest, trq, &trq.Extent)
-	key := oc.CacheKeyPrefix + ".dpc." + pr.DeriveCacheKey(trq.TemplateURL, "")
-	pr.cacheLock, _ = locker.RAcquire(key)
+	// upstream requests begin earlier by the query's lookback, so that the points at the start of
+	// the fetched extent are computed from complete windows. the result is cropped back to the extent
+	le := trq.LookbackExtent(trq.Extent)
+	client.SetExtent(pr.upstreamRequest, trq, &le)
+	key := oc.CacheKeyPrefix + ".dpc." + pr.DeriveCacheKey(trq.TemplateURL, stepKeyExtra(trq.Step))
+	// the step index tracks the steps cached for the query, independent of the step
+	var stepIndexKey string
+	// downsampled finer steps are aligned to UTC, so they cannot serve queries aligned to a time zone
+	if oc.ReuseCoarserStep && !trq.IsLocal() {
+		stepIndexKey = oc.CacheKeyPrefix + ".dpc.steps." + pr.DeriveCacheKey(trq.TemplateURL, "")
+	}
+	var releaseLock func() error
+	if writeLocked {
+		pr.cacheLock, _ = locker.Acquire(key)
+		releaseLock = pr.cacheLock.Release
+	} else {
+		pr.cacheLock, _ = locker.RAcquire(key)
+		releaseLock = pr.cacheLock.RRelease
+	}
 
 	// this is used to determine if Fast Forward should be activated for this request
 	normalizedNow := &timeseries.TimeRangeQuery{
-		Extent: timeseries.Extent{Start: time.Unix(0, 0), End: now},
-		Step:   trq.Step,
+		Extent:     timeseries.Extent{Start: time.Unix(0, 0), End: now},
+		Step:       trq.Step,
+		Location:   trq.Location,
+		StepOffset: trq.StepOffset,
 	}
 	normalizedNow.NormalizeExtent()
 
 	var cts timeseries.Timeseries
 	var doc *HTTPDocument
-	var elapsed time.Duration
+	// upstreamMissing are the ranges that an upstream Trickster reported missing from the partial
+	// responses it returned. They are not cached, and are reported missing by this response,
+	// but do not fail the request, since the upstream permitted its partial response
+	var upstreamMissing timeseries.ExtentList
+	// isShared indicates the document is referenced directly by a memory cache, and
+	// therefore visible to concurrent readers, so it must not be modified in place
+	var isShared bool
 
 	coReq := GetRequestCachingPolicy(r.Header)
+	if !coReq.NoCache {
+		doc, cacheStatus, _, err = QueryCache(ctx, cache, key, nil)
+		pr.Logger.Trace("delta proxy cache lookup",
+			tl.Pairs{"cacheKey": key, "cacheStatus": cacheStatus.String()})
+	}
+
+	// capacity is reserved for processing the cached document, or a document of unknown size when
+	// there is none, before it is unmarshaled or fetched from the origin
+	if resp := pr.reserveInflight(inflightDocumentSize(doc)); resp != nil {
+		releaseLock()
+		cacheStatus = status.LookupStatusProxyError
+		recordDPCResult(r, cacheStatus, resp.StatusCode, r.URL.Path, "", 0, nil, resp.Header)
+		body, _ := ioutil.ReadAll(resp.Body)
+		Respond(w, resp.StatusCode, resp.Header, body)
+		return
+	}
+	defer pr.releaseInflight()
+
 	if coReq.NoCache {
 		if span != nil {
 			span.AddEvent(
@@ -129,10 +234,11 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 			)
 		}
 		cacheStatus = status.LookupStatusPurge
-		go cache.Remove(key)
-		cts, doc, elapsed, err = fetchTimeseries(pr, trq, client)
+		background.Go(background.KindCacheWrite, func() { cache.Remove(key) })
+		emitCacheEvent(rsc, cache, events.TypePurge, key, "no-cache", 0, nil)
+		cts, doc, elapsed, upstreamMissing, err = fetchTimeseries(pr, trq, client)
 		if err != nil {
-			pr.cacheLock.RRelease()
+			releaseLock()
 			h := doc.SafeHeaderClone()
 			recordDPCResult(r, status.LookupStatusProxyError, doc.StatusCode,
 				r.URL.Path, "", elapsed.Seconds(), nil, h)
@@ -140,11 +246,19 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 			return // fetchTimeseries logs the error
 		}
 	} else {
-		doc, cacheStatus, _, err = QueryCache(ctx, cache, key, nil)
 		if cacheStatus == status.LookupStatusKeyMiss && err == tc.ErrKNF {
-			cts, doc, elapsed, err = fetchTimeseries(pr, trq, client)
+			// a path that assumes single use only caches the second request for a key within its
+			// window. a rerun request was already admitted
+			if !writeLocked && !admitSingleUse(pc, key) {
+				releaseLock()
+				pr.Logger.Debug("not caching the first request for a path that assumes single use",
+					tl.Pairs{"cacheKey": key})
+				DoProxy(w, r, true)
+				return
+			}
+			cts, doc, elapsed, upstreamMissing, err = fetchTimeseries(pr, trq, client)
 			if err != nil {
-				pr.cacheLock.RRelease()
+				releaseLock()
 				h := doc.SafeHeaderClone()
 				recordDPCResult(r, status.LookupStatusProxyError, doc.StatusCode,
 					r.URL.Path, "", elapsed.Seconds(), nil, h)
@@ -158,17 +272,20 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 			} else {
 				if cc.CacheType == "memory" {
 					cts = doc.timeseries
+					isShared = true
 				} else {
 					cts, err = client.UnmarshalTimeseries(doc.Body)
 				}
 			}
 			if err != nil {
-				pr.Logger.Error("cache object unmarshaling failed",
-					tl.Pairs{"key": key, "originName": client.Name(), "detail": err.Error()})
-				go cache.Remove(key)
-				cts, doc, elapsed, err = fetchTimeseries(pr, trq, client)
+				// the object is discarded, and the request proceeds as a cache miss
+				discardCacheObject(pr.Logger, cache, key,
+					&cacheDecodeError{class: decodeFailureUnmarshal, err: err})
+				isShared = false
+				cacheStatus = status.LookupStatusKeyMiss
+				cts, doc, elapsed, upstreamMissing, err = fetchTimeseries(pr, trq, client)
 				if err != nil {
-					pr.cacheLock.RRelease()
+					releaseLock()
 					h := doc.SafeHeaderClone()
 					recordDPCResult(r, status.LookupStatusProxyError, doc.StatusCode,
 						r.URL.Path, "", elapsed.Seconds(), nil, h)
@@ -182,14 +299,16 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 					if tsc > 0 &&
 						tsc >= oc.TimeseriesRetentionFactor {
 						if trq.Extent.End.Before(el[0].Start) {
-							pr.cacheLock.RRelease()
+							releaseLock()
+							cacheStatus = status.LookupStatusProxyOnly
 							go pr.Logger.Debug("timerange end is too early to consider caching",
 								tl.Pairs{"step": trq.Step, "retention": oc.TimeseriesRetention})
 							DoProxy(w, r, true)
 							return
 						}
 						if trq.Extent.Start.After(el[len(el)-1].End) {
-							pr.cacheLock.RRelease()
+							releaseLock()
+							cacheStatus = status.LookupStatusProxyOnly
 							go pr.Logger.Debug("timerange not cached due to backfill tolerance",
 								tl.Pairs{
 									"backFillToleranceSecs":   bt,
@@ -207,6 +326,11 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// a timeseries that is not shared with a memory cache is held by the request until it responds
+	if cts != nil && !isShared {
+		pr.inflight.Observe(int64(cts.Size()))
+	}
+
 	// Find the ranges that we want, but which are not currently cached
 	var missRanges timeseries.ExtentList
 	if cacheStatus == status.LookupStatusPartialHit {
@@ -223,13 +347,63 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 		cacheStatus = status.LookupStatusRangeMiss
 	}
 
+	// when the request is not a full cache hit, it may be fulfilled from a finer step's cached document
+	if stepIndexKey != "" && !coReq.NoCache && cacheStatus != status.LookupStatusHit {
+		if rts, fdoc, fstep, ok := reuseFinerStep(ctx, pr, trq, client, stepIndexKey); ok {
+			releaseLock()
+			elapsed = time.Since(now)
+			cacheStatus = status.LookupStatusHit
+			tspan.SetAttributes(rsc.Tracer, span, kv.String("cache.status", status.LookupStatusHit.String()))
+			if vc := rts.ValueCount(); vc > 0 {
+				rsc.State().Metrics.ProxyRequestElements.WithLabelValues(oc.Name,
+					oc.OriginType, "cached", r.URL.Path).Add(float64(vc))
+			}
+			qr.series = rts.SeriesCount()
+			rts.SetExtents(nil)
+			rts.SetStep(0)
+			rh := fdoc.SafeHeaderClone()
+			rh.Set(headers.NameTricksterStepReuse, fmt.Sprintf("step=%s; cachedStep=%s", trq.Step, fstep))
+			// the downsampled response must not share a validator with the finer step's responses
+			transform := "downsample." + fstep.String()
+			fs := frameStreamer(r, client, fdoc.StatusCode, rh)
+			if fs != nil {
+				transform += ".ndjson"
+			} else if f := renderFormat(r, client); f != "" {
+				transform += "." + f
+			}
+			etag := timeseriesETag(fdoc.ContentHash, trq, transform)
+			if setTimeseriesValidator(r, fdoc.StatusCode, rh, etag) {
+				recordDPCResult(r, status.LookupStatusHit, http.StatusNotModified, r.URL.Path, "off",
+					elapsed.Seconds(), nil, rh)
+				Respond(w, http.StatusNotModified, rh, nil)
+				return
+			}
+			if fs != nil {
+				recordDPCResult(r, status.LookupStatusHit, fdoc.StatusCode, r.URL.Path, "off",
+					elapsed.Seconds(), nil, rh)
+				if err := respondFrames(w, fdoc.StatusCode, rh, rts, fs); err != nil {
+					pr.Logger.Debug("timeseries frame stream interrupted", tl.Pairs{"detail": err.Error()})
+				}
+				return
+			}
+			rdata, _ := renderTimeseries(r, client, rts, rh)
+			recordDPCResult(r, status.LookupStatusHit, fdoc.StatusCode, r.URL.Path, "off",
+				elapsed.Seconds(), nil, rh)
+			Respond(w, fdoc.StatusCode, rh, rdata)
+			return
+		}
+	}
+
 	tspan.SetAttributes(rsc.Tracer, span, kv.String("cache.status", cacheStatus.String()))
 
 	var writeLock locks.NamedLock
 
 	if cacheStatus == status.LookupStatusHit {
-		// In a cache hit, nothing changes so we just release the reader lock
-		pr.cacheLock.RRelease()
+		// In a cache hit, nothing changes so we just release the lock
+		releaseLock()
+	} else if writeLocked {
+		// the cache was queried under the write lock, so there is no need to re-check it
+		writeLock = pr.cacheLock
 	} else {
 		// in this case, it's not a cache hit, so something is _likely_ going to be cached now.
 		// we write lock here, so as to prevent other concurrent client requests for the same url,
@@ -253,14 +427,24 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 
 		// now check if we were the first request for this url to upgrade from a reader to writer
 		if pr.cacheLock.WriteLockCounter()-cwc != 1 {
-			// we weren't first, so quickly drop our write lock, and re-run the request
+			// we weren't first, so drop our write lock, and re-run the request while
+			// holding the write lock throughout
 			pr.cacheLock.Release()
-			DeltaProxyCacheRequest(w, r)
+			pr.releaseInflight()
+			retried = true
+			deltaProxyCacheRequest(w, r, true)
 			return
 		}
 		writeLock = pr.cacheLock
 	}
 
+	// the merge is performed against a private copy of a shared document, which
+	// replaces the cached reference when it is written back
+	if writeLock != nil && isShared {
+		doc = doc.clone()
+		cts = doc.timeseries
+	}
+
 	ffStatus := "off"
 	var ffReq *http.Request
 	// if the step resolution <= Fast Forward TTL, then no need to even try Fast Forward
@@ -273,7 +457,11 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 			} else {
 				rs := request.NewResources(oc, oc.FastForwardPath, cc, cache, client, rsc.Tracer, pr.Logger)
 				rs.AlternateCacheTTL = oc.FastForwardTTL
-				ffReq = ffReq.WithContext(tctx.WithResources(ffReq.Context(), rs))
+				rs.Instance = rsc.Instance
+				rs.TimeoutDeadline = rsc.TimeoutDeadline
+				rs.UpstreamChain = rsc.UpstreamChain
+				ffReq = ffReq.WithContext(tctx.WithInflightReservation(
+					tctx.WithResources(ffReq.Context(), rs), pr.inflight))
 			}
 		} else {
 			trq.FastForwardDisable = true
@@ -296,16 +484,38 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 	appendLock := sync.Mutex{}
 	uncachedValueCount := 0
 
+	// failures are the ranges whose upstream requests did not return a timeseries, and failedDoc
+	// is the first of their responses, which is returned unless the path permits partial responses
+	var failures timeseries.ExtentList
+	var failedDoc *HTTPDocument
+	fail := func(e *timeseries.Extent, resp *http.Response, body []byte) {
+		appendLock.Lock()
+		failures = append(failures, *e)
+		if failedDoc == nil {
+			failedDoc = &HTTPDocument{StatusCode: resp.StatusCode, Status: resp.Status,
+				Headers: resp.Header, Body: body}
+		}
+		appendLock.Unlock()
+	}
+
 	// iterate each time range that the client needs and fetch from the upstream origin
 	for i := range missRanges {
 		wg.Add(1)
-		// This fetches the gaps from the origin and adds their datasets to the merge list
-		go func(e *timeseries.Extent, rq *proxyRequest) {
+		e, rq := &missRanges[i], pr.Clone()
+		// This fetches the gaps from the origin and adds their datasets to the merge list. The fetch
+		// is detached from the client request, so that the range is cached if the client disconnects
+		background.Go(background.KindRangeFetch, func() {
 			defer wg.Done()
-			rq.upstreamRequest = rq.WithContext(tctx.WithResources(
-				trace.ContextWithSpan(context.Background(), span),
-				request.NewResources(oc, pc, cc, cache, client, rsc.Tracer, pr.Logger)))
-			client.SetExtent(rq.upstreamRequest, trq, e)
+			rs := request.NewResources(oc, pc, cc, cache, client, rsc.Tracer, pr.Logger)
+			rs.Instance = rsc.Instance
+			rs.TimeoutDeadline = rsc.TimeoutDeadline
+			rs.UpstreamChain = rsc.UpstreamChain
+			fctx, cancel := withOriginTimeout(tctx.WithResources(
+				trace.ContextWithSpan(context.Background(), span), rs), oc)
+			defer cancel()
+			rq.upstreamRequest = rq.WithContext(fctx)
+			le := trq.LookbackExtent(*e)
+			client.SetExtent(rq.upstreamRequest, trq, &le)
 
 			ctxMR, spanMR := tspan.NewChildSpan(rq.upstreamRequest.Context(), rsc.Tracer, "FetchRange")
 			if spanMR != nil {
@@ -314,70 +524,139 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 			}
 
 			body, resp, _ := rq.Fetch()
-			if resp.StatusCode == http.StatusOK && len(body) > 0 {
-				nts, err := client.UnmarshalTimeseries(body)
-				if err != nil {
-					pr.Logger.Error("proxy object unmarshaling failed",
-						tl.Pairs{"body": string(body)})
-					return
-				}
-				doc.headerLock.Lock()
-				headers.Merge(doc.Headers, resp.Header)
-				doc.headerLock.Unlock()
-				uncachedValueCount += nts.ValueCount()
-				nts.SetStep(trq.Step)
-				nts.SetExtents([]timeseries.Extent{*e})
+			if resp.StatusCode != http.StatusOK || len(body) == 0 {
+				pr.Logger.ErrorRateLimited(upstreamErrorLogKey(rsc, resp.StatusCode), upstreamErrorLogWindow,
+					"unexpected upstream response for range",
+					tl.Pairs{"statusCode": resp.StatusCode, "extent": e.String()})
+				fail(e, resp, body)
+				return
+			}
+			nts, err := client.UnmarshalTimeseries(body)
+			if err != nil {
+				pr.Logger.LogError(terr.Wrap(err, "proxy object unmarshaling failed",
+					tl.Pairs{"originName": oc.Name, "cacheKey": key, "extent": e.String(),
+						"upstreamRequestURL": rq.upstreamRequest.URL.String(), "body": string(body)}))
+				fail(e, resp, body)
+				return
+			}
+			filled, missing := partialExtents(resp.Header, *e, trq.Step)
+			if len(filled) == 0 {
 				appendLock.Lock()
-				mts = append(mts, nts)
+				upstreamMissing = append(upstreamMissing, missing...)
 				appendLock.Unlock()
+				return
+			}
+			doc.headerLock.Lock()
+			headers.Merge(doc.Headers, resp.Header)
+			doc.headerLock.Unlock()
+			rq.inflight.Observe(int64(nts.Size()))
+			nts.SetStep(trq.Step)
+			if trq.Lookback > 0 {
+				nts.SetExtents([]timeseries.Extent{le})
+				nts.CropToRange(*e)
 			}
-		}(&missRanges[i], pr.Clone())
+			nts.SetExtents(filled)
+			appendLock.Lock()
+			uncachedValueCount += nts.ValueCount()
+			mts = append(mts, nts)
+			upstreamMissing = append(upstreamMissing, missing...)
+			appendLock.Unlock()
+		})
 	}
 
 	var hasFastForwardData bool
 	var ffts timeseries.Timeseries
+	var ffHash string
 
 	// Only fast forward if configured and the user request is for the absolute latest datapoint
 	if (!trq.FastForwardDisable) &&
 		(trq.Extent.End.Equal(normalizedNow.Extent.End)) {
 		wg.Add(1)
-		go func() {
+		background.Go(background.KindFastForward, func() {
 			defer wg.Done()
+			fctx, cancel := withOriginTimeout(ffReq.Context(), oc)
+			defer cancel()
+			ffReq = ffReq.WithContext(fctx)
 			_, span := tspan.NewChildSpan(ctx, rsc.Tracer, "FetchFastForward")
 			if span != nil {
 				ffReq = ffReq.WithContext(trace.ContextWithSpan(ffReq.Context(), span))
 				defer span.End()
 			}
 			body, resp, isHit := FetchViaObjectProxyCache(ffReq)
+			received := time.Now()
 			if resp != nil && resp.StatusCode == http.StatusOK && len(body) > 0 {
+				var err error
 				ffts, err = client.UnmarshalInstantaneous(body)
 				if err != nil {
 					ffStatus = "err"
-					pr.Logger.Error("proxy object unmarshaling failed",
-						tl.Pairs{"body": string(body)})
+					pr.Logger.LogError(terr.Wrap(err, "proxy object unmarshaling failed",
+						tl.Pairs{"originName": oc.Name, "fastForward": true,
+							"upstreamRequestURL": ffReq.URL.String(), "body": string(body)}))
 					return
 				}
 				ffts.SetStep(trq.Step)
+				ffHash = md5.Checksum(string(body))
 				x := ffts.Extents()
 				if isHit {
 					ffStatus = "hit"
 				} else {
 					ffStatus = "miss"
+					if etr, ok := client.(origins.EvaluationTimeReporter); ok {
+						if et, ok := etr.EvaluationTime(ffts); ok {
+							rsc.State().ClockSkews.Observe(oc.Name, oc.OriginType, et, received)
+						}
+					}
 				}
 				hasFastForwardData = len(x) > 0 && x[0].End.After(trq.Extent.End)
 			} else {
 				ffStatus = "err"
 			}
-		}()
+		})
 	}
 
 	wg.Wait()
 
+	// unless the path permits partial responses, a failed range fails the request with its
+	// upstream response. A partial response must include some of the requested data, so
+	// the request also fails when the fetch of every range of a range miss has failed
+	if len(failures) > 0 {
+		sort.Sort(failures)
+		if pc == nil || pc.PartialResponse != partial.ModeBestEffort ||
+			(cacheStatus == status.LookupStatusRangeMiss && len(failures) == len(missRanges)) {
+			writeLock.Release()
+			cacheStatus = status.LookupStatusProxyError
+			h := failedDoc.SafeHeaderClone()
+			recordDPCResult(r, cacheStatus, failedDoc.StatusCode, r.URL.Path, ffStatus,
+				time.Since(now).Seconds(), missRanges, h)
+			Respond(w, failedDoc.StatusCode, h, failedDoc.Body)
+			return
+		}
+		pr.Logger.Warn("returning partial response", tl.Pairs{"cacheKey": key,
+			"extentsMissing": failures.String()})
+	}
+	missing := append(failures.Clone(), upstreamMissing...)
+	if len(missing) > 0 {
+		sort.Sort(missing)
+		dpStatus["extentsMissing"] = missing.String()
+	}
+
 	// Merge the new delta timeseries into the cached timeseries
 	if len(mts) > 0 {
 		// on phit, elapsed records the time spent waiting for all upstream requests to complete
 		elapsed = time.Since(now)
 		cts.Merge(true, mts...)
+		// the timeseries compresses its extents by adding its step, which does not join extents on
+		// either side of a daylight saving time transition when the query's steps are calendar days
+		if trq.Location != nil {
+			cts.SetExtents(trq.CompressExtents(cts.Extents()))
+		}
+	}
+
+	// the content hash is updated whenever the cached document changes. on a cache hit, the
+	// document is unchanged, so its hash is reused when it has one
+	contentHash := doc.ContentHash
+	if cacheStatus != status.LookupStatusHit || contentHash == "" {
+		contentHash = timeseriesContentHash(client, cts)
 	}
 
 	// cts is the cacheable time series, rts is the user's response timeseries
@@ -385,7 +664,7 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 
 	if writeLock != nil {
 		// if the mutex is still locked, it means we need to write the time series to cache
-		go func() {
+		background.Go(background.KindCacheWrite, func() {
 			defer writeLock.Release()
 			// Crop the Cache Object down to the Sample Size or Age Retention Policy and the
 			// Backfill Tolerance before storing to cache
@@ -398,31 +677,45 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 			// Don't cache datasets with empty extents
 			// (everything was cropped so there is nothing to cache)
 			if len(cts.Extents()) > 0 {
+				doc.ContentHash = contentHash
 				if cc.CacheType == "memory" {
 					doc.timeseries = cts
 				} else {
 					cdata, err := client.MarshalTimeseries(cts)
 					if err != nil {
-						pr.Logger.Error("error marshaling timeseries", tl.Pairs{
-							"cacheKey": key,
-							"detail":   err.Error(),
-						})
+						pr.Logger.LogError(terr.Wrap(err, "error marshaling timeseries",
+							tl.Pairs{"originName": oc.Name, "cacheKey": key}))
 						return
 					}
 					doc.Body = cdata
 				}
-				if err := WriteCache(ctx, cache, key, doc, oc.TimeseriesTTL, oc.CompressableTypes); err != nil {
-					pr.Logger.Error("error writing object to cache",
+				err := WriteCache(ctx, cache, key, doc, oc.TimeseriesTTL, oc.CompressableTypes)
+				if err == tc.ErrReadOnly {
+					// the cached document, and its extents, are unchanged, so the ranges that
+					// were merged are fetched again by the next request
+					pr.Logger.Debug("cache is read-only, merged timeseries not stored",
+						tl.Pairs{"cacheName": cache.Configuration().Name, "cacheKey": key})
+				} else if err != nil {
+					pr.Logger.LogError(terr.Wrap(err, "error writing object to cache",
 						tl.Pairs{
 							"originName": oc.Name,
 							"cacheName":  cache.Configuration().Name,
 							"cacheKey":   key,
-							"detail":     err.Error(),
 						},
-					)
+					))
+				} else {
+					eventType := events.TypeMerge
+					if cacheStatus == status.LookupStatusKeyMiss {
+						eventType = events.TypeStore
+					}
+					emitCacheEvent(rsc, cache, eventType, key, "", cts.Size(), cts.Extents())
+					recordInvalidationTarget(rsc, key, trq.Statement)
+					if stepIndexKey != "" {
+						updateStepIndex(cache, stepIndexKey, trq.Step, oc.TimeseriesTTL)
+					}
 				}
 			}
-		}()
+		})
 	}
 
 	// if it was a cache key miss, there is no need to undergo Crop since the extents are identical
@@ -430,14 +723,15 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 		rts.CropToRange(trq.Extent)
 	}
 	cachedValueCount := rts.ValueCount() - uncachedValueCount
+	qr.series = rts.SeriesCount()
 
 	if uncachedValueCount > 0 {
-		metrics.ProxyRequestElements.WithLabelValues(oc.Name,
+		rsc.State().Metrics.ProxyRequestElements.WithLabelValues(oc.Name,
 			oc.OriginType, "uncached", r.URL.Path).Add(float64(uncachedValueCount))
 	}
 
 	if cachedValueCount > 0 {
-		metrics.ProxyRequestElements.WithLabelValues(oc.Name,
+		rsc.State().Metrics.ProxyRequestElements.WithLabelValues(oc.Name,
 			oc.OriginType, "cached", r.URL.Path).Add(float64(cachedValueCount))
 	}
 
@@ -445,27 +739,81 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 	// the cropped extent was normalized to stepboundaries and would remove fast forward data
 	// If the fast forward data point is older (e.g. cached) than the last datapoint in the
 	// returned time series, it will not be merged
+	var transform string
 	if hasFastForwardData && len(ffts.Extents()) == 1 &&
 		ffts.Extents()[0].Start.Truncate(time.Second).After(normalizedNow.Extent.End) {
 		rts.Merge(false, ffts)
+		transform = "ff." + ffHash
 	}
 	rts.SetExtents(nil) // so they are not included in the client response json
 	rts.SetStep(0)
-	rdata, err := client.MarshalTimeseries(rts)
 	rh := doc.SafeHeaderClone()
 	sc := doc.StatusCode
 
+	// a partial response reports the ranges that it is missing, and must not share
+	// a validator with the complete response
+	if len(missing) > 0 {
+		rh.Set(headers.NameTricksterPartial, "missing="+missing.String())
+		if tw, ok := client.(origins.TimeseriesWarner); ok {
+			tw.AddWarning(rts, "partial response: upstream requests failed for ranges "+
+				missing.String())
+		}
+		rsc.State().Metrics.ProxyPartialResponses.WithLabelValues(oc.Name, oc.OriginType, r.URL.Path).Inc()
+		transform += ".partial"
+	}
+
+	logDeltaRoutine(pr.Logger, dpStatus)
+
+	// clients that negotiate a streamed response receive the timeseries as newline-delimited frames
+	fs := frameStreamer(r, client, sc, rh)
+	if fs != nil {
+		// the streamed representation must not share a validator with the JSON document
+		transform += ".ndjson"
+	} else if f := renderFormat(r, client); f != "" {
+		// nor must any other format rendered from the canonical form
+		transform += "." + f
+	}
+
+	if setTimeseriesValidator(r, sc, rh, timeseriesETag(contentHash, trq, transform)) {
+		recordDPCResult(r, cacheStatus, http.StatusNotModified, r.URL.Path, ffStatus,
+			elapsed.Seconds(), missRanges, rh)
+		Respond(w, http.StatusNotModified, rh, nil)
+		return
+	}
+
+	if fs != nil {
+		recordDPCResult(r, cacheStatus, sc, r.URL.Path, ffStatus, elapsed.Seconds(), missRanges, rh)
+		if err := respondFrames(w, sc, rh, rts, fs); err != nil {
+			pr.Logger.Debug("timeseries frame stream interrupted", tl.Pairs{"detail": err.Error()})
+		}
+		return
+	}
+
+	rdata, err := renderTimeseries(r, client, rts, rh)
+	pr.inflight.Observe(int64(len(rdata)))
+
 	// Respond to the user. Using the response headers from a Delta Response,
 	// so as to not map conflict with cacheData on WriteCache
-	logDeltaRoutine(pr.Logger, dpStatus)
 	recordDPCResult(r, cacheStatus, sc, r.URL.Path, ffStatus, elapsed.Seconds(), missRanges, rh)
 	Respond(w, sc, rh, rdata)
 }
 
 func logDeltaRoutine(log *tl.Logger, p tl.Pairs) { log.Debug("delta routine completed", p) }
 
+// upstreamErrorLogWindow is the interval at which unexpected upstream responses of each origin
+// and status code are logged, so that an origin outage does not flood the log
+const upstreamErrorLogWindow = time.Second
+
+// upstreamErrorLogKey returns the rate-limited log key of an unexpected upstream response
+func upstreamErrorLogKey(rsc *request.Resources, statusCode int) string {
+	return "dpc.upstream." + rsc.OriginConfig.Name + "." + strconv.Itoa(statusCode)
+}
+
+// fetchTimeseries fetches the entire range of the query from the origin, and returns any ranges
+// that an upstream Trickster reported missing from its partial response
 func fetchTimeseries(pr *proxyRequest, trq *timeseries.TimeRangeQuery,
-	client origins.TimeseriesClient) (timeseries.Timeseries, *HTTPDocument, time.Duration, error) {
+	client origins.TimeseriesClient) (timeseries.Timeseries, *HTTPDocument, time.Duration,
+	timeseries.ExtentList, error) {
 
 	rsc := request.GetResources(pr.Request)
 
@@ -485,7 +833,8 @@ func fetchTimeseries(pr *proxyRequest, trq *timeseries.TimeRangeQuery,
 	}
 
 	if resp.StatusCode != 200 {
-		pr.Logger.Error("unexpected upstream response",
+		pr.Logger.ErrorRateLimited(upstreamErrorLogKey(rsc, resp.StatusCode), upstreamErrorLogWindow,
+			"unexpected upstream response",
 			tl.Pairs{
 				"statusCode":              resp.StatusCode,
 				"clientRequestURL":        pr.Request.URL.String(),
@@ -498,19 +847,26 @@ func fetchTimeseries(pr *proxyRequest, trq *timeseries.TimeRangeQuery,
 				"upstreamResponseBody":    string(body),
 			},
 		)
-		return nil, d, time.Duration(0), tpe.ErrUnexpectedUpstreamResponse
+		return nil, d, time.Duration(0), nil, tpe.ErrUnexpectedUpstreamResponse
 	}
 
 	ts, err := client.UnmarshalTimeseries(body)
 	if err != nil {
-		pr.Logger.Error("proxy object unmarshaling failed", tl.Pairs{"body": string(body)})
-		return nil, d, time.Duration(0), err
+		pr.Logger.LogError(terr.Wrap(err, "proxy object unmarshaling failed",
+			tl.Pairs{"originName": rsc.OriginConfig.Name,
+				"upstreamRequestURL": pr.upstreamRequest.URL.String(), "body": string(body)}))
+		return nil, d, time.Duration(0), nil, err
 	}
 
-	ts.SetExtents([]timeseries.Extent{trq.Extent})
+	filled, missing := partialExtents(resp.Header, trq.Extent, trq.Step)
 	ts.SetStep(trq.Step)
+	if trq.Lookback > 0 {
+		ts.SetExtents([]timeseries.Extent{trq.LookbackExtent(trq.Extent)})
+		ts.CropToRange(trq.Extent)
+	}
+	ts.SetExtents(filled)
 
-	return ts, d, elapsed, nil
+	return ts, d, elapsed, missing, nil
 }
 
 func recordDPCResult(r *http.Request, cacheStatus status.LookupStatus, httpStatus int, path,
@@ -518,3 +874,23 @@ func recordDPCResult(r *http.Request, cacheStatus status.LookupStatus, httpStatu
 	recordResults(r, "DeltaProxyCache", cacheStatus, httpStatus, path, ffStatus, elapsed,
 		timeseries.ExtentList(needed), header)
 }
+
+// partialExtents returns the portions of a range that an upstream response filled, and those
+// it is missing. When the upstream is a Trickster whose response is partial, its
+// X-Trickster-Partial header reports the missing ranges, and is removed from the header so that
+// it is not merged into the response. Otherwise, the upstream response filled the entire range
+func partialExtents(h http.Header, e timeseries.Extent,
+	step time.Duration) (timeseries.ExtentList, timeseries.ExtentList) {
+	v := h.Get(headers.NameTricksterPartial)
+	if v == "" {
+		return timeseries.ExtentList{e}, nil
+	}
+	h.Del(headers.NameTricksterPartial)
+	missing, err := timeseries.ParseExtentList(strings.TrimPrefix(v, "missing="))
+	if err != nil || len(missing) == 0 {
+		return timeseries.ExtentList{e}, nil
+	}
+	sort.Sort(missing)
+	missing = missing.Crop(e)
+	return missing.Subtract(e, step), missing
+}
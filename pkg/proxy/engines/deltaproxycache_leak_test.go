@@ -0,0 +1,109 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/background"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	"github.com/tricksterproxy/trickster/pkg/util/testing/leaks"
+)
+
+const queryReturnsOKSlowly = "some_query_here{latency_ms=0,range_latency_ms=1}"
+
+// testDPCLeaks makes two delta proxy cache requests for the query, with fast forward enabled,
+// and checks that no goroutines outlive them, once their background tasks have completed
+func testDPCLeaks(t *testing.T, query string,
+	prepare func(*http.Request) (*http.Request, context.CancelFunc)) []int {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	oc.FastForwardDisable = false
+
+	step := 300 * time.Second
+	now := time.Now()
+	client.fftime = now.Truncate(oc.FastForwardTTL)
+	extr := timeseries.Extent{Start: now.Add(-12 * time.Hour), End: now}
+
+	r.URL.Path = "/prometheus/api/v1/query_range"
+	r.URL.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), query)
+
+	s := leaks.Take()
+
+	codes := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		rq, cancel := prepare(r)
+		client.QueryRangeHandler(w, rq)
+		cancel()
+		codes = append(codes, w.Code)
+		if !background.Wait(5 * time.Second) {
+			t.Fatalf("background tasks did not complete: %v", background.Counts())
+		}
+	}
+
+	oc.HTTPClient.CloseIdleConnections()
+	s.Check(t, 5*time.Second)
+	return codes
+}
+
+func TestDeltaProxyCacheRequestLeaks(t *testing.T) {
+
+	t.Run("ok", func(t *testing.T) {
+		codes := testDPCLeaks(t, queryReturnsOKNoLatency,
+			func(r *http.Request) (*http.Request, context.CancelFunc) {
+				return r, func() {}
+			})
+		if codes[0] != http.StatusOK || codes[1] != http.StatusOK {
+			t.Errorf("unexpected status codes %v", codes)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		codes := testDPCLeaks(t, queryReturnsOKSlowly,
+			func(r *http.Request) (*http.Request, context.CancelFunc) {
+				request.GetResources(r).TimeoutDeadline = time.Now().Add(20 * time.Millisecond)
+				return r, func() {}
+			})
+		if codes[0] == http.StatusOK {
+			t.Errorf("unexpected status codes %v", codes)
+		}
+	})
+
+	t.Run("client disconnect", func(t *testing.T) {
+		testDPCLeaks(t, queryReturnsOKSlowly,
+			func(r *http.Request) (*http.Request, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(r.Context())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return r.WithContext(ctx), cancel
+			})
+	})
+}
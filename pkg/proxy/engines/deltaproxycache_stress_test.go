@@ -0,0 +1,184 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+
+	"github.com/prometheus/common/model"
+	mockprom "github.com/tricksterproxy/mockster/pkg/mocks/prometheus"
+	"github.com/tricksterproxy/mockster/pkg/routes"
+)
+
+// newSerializedOrigin returns a mock Prometheus origin that services one request at a time.
+// mockster seeds the shared math/rand source for each value it generates, so its output
+// is only deterministic when requests to it are serialized
+func newSerializedOrigin() *httptest.Server {
+	mtx := &sync.Mutex{}
+	h := routes.GetRouter()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		h.ServeHTTP(w, r)
+	}))
+}
+
+// TestDeltaProxyCacheRequestConcurrentMerge fires many overlapping requests for the same
+// cache key against a deterministic origin, and verifies that the resulting cached document
+// is exactly the merge of every requested extent. Run it with -race to detect unsafe
+// concurrent access to cached documents during the retrieve-merge-store sequence.
+func TestDeltaProxyCacheRequestConcurrentMerge(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	oc.FastForwardDisable = true
+
+	origin := newSerializedOrigin()
+	defer origin.Close()
+	u, err := url.Parse(origin.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oc.Host = u.Host
+
+	const requestCount = 300
+	step := time.Duration(60) * time.Second
+	base := time.Now().Add(-time.Duration(8) * time.Hour).Truncate(time.Hour)
+
+	// the cached document is cropped to the end of the request that last wrote it, so the
+	// requests share an end time, as with a dashboard, to make the merged result independent
+	// of the order in which the requests are serviced
+	end := base.Add(time.Duration(5) * time.Hour)
+	extents := make([]timeseries.Extent, requestCount)
+	for i := range extents {
+		extents[i] = timeseries.Extent{Start: base.Add(time.Duration(i*7%240) * time.Minute), End: end}
+	}
+
+	newRequest := func(e timeseries.Extent) *http.Request {
+		r2 := r.Clone(tc.WithResources(r.Context(), rsc.Clone()))
+		r2.URL.Path = "/prometheus/api/v1/query_range"
+		r2.URL.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+			int(step.Seconds()), e.Start.Unix(), e.End.Unix(), queryReturnsOKNoLatency)
+		return r2
+	}
+
+	// the expected document is the brute-force merge of each request's expected results
+	var expected timeseries.Timeseries
+	union := extents[0]
+	bodies := make([]string, requestCount)
+	for i, e := range extents {
+		body, _, _ := mockprom.GetTimeSeriesData(queryReturnsOKNoLatency, e.Start, e.End, step)
+		bodies[i] = body
+		ets, err := client.UnmarshalTimeseries([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected == nil {
+			expected = ets
+		} else {
+			expected.Merge(true, ets)
+		}
+		if e.Start.Before(union.Start) {
+			union.Start = e.Start
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	errs := make(chan error, requestCount)
+	for i, e := range extents {
+		wg.Add(1)
+		go func(e timeseries.Extent, body string) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			client.QueryRangeHandler(w, newRequest(e))
+			resp := w.Result()
+			if resp.StatusCode != http.StatusOK {
+				errs <- fmt.Errorf("expected %d got %d for extent %s",
+					http.StatusOK, resp.StatusCode, e.String())
+				return
+			}
+			if err := testStringMatch(w.Body.String(), body); err != nil {
+				errs <- fmt.Errorf("extent %s: %s", e.String(), err.Error())
+			}
+		}(e, bodies[i])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// a request for the union of all extents acquires the key's read lock only after
+	// every pending cache write has completed, so it must be a full cache hit
+	fr := newRequest(union)
+	w := httptest.NewRecorder()
+	client.QueryRangeHandler(w, fr)
+	err = testResultHeaderPartMatch(w.Result().Header, map[string]string{"status": "hit"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	trq, err := client.ParseTimeRangeQuery(fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr := newProxyRequest(fr, nil)
+	key := oc.CacheKeyPrefix + ".dpc." + pr.DeriveCacheKey(trq.TemplateURL, stepKeyExtra(trq.Step))
+	doc, _, _, err := QueryCache(fr.Context(), rsc.CacheClient, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.timeseries == nil {
+		t.Fatal("expected cached timeseries")
+	}
+
+	if el := doc.timeseries.Extents(); !reflect.DeepEqual(el, timeseries.ExtentList{union}) {
+		t.Errorf("expected extents %s got %s", union.String(), el.String())
+	}
+	if !reflect.DeepEqual(matrixValues(doc.timeseries), matrixValues(expected)) {
+		t.Error("cached document does not match the merge of all requested extents")
+	}
+
+	if l := rsc.CacheClient.Locker().Len(); l != 0 {
+		t.Errorf("expected empty lock registry, got %d", l)
+	}
+}
+
+func matrixValues(ts timeseries.Timeseries) map[string][]model.SamplePair {
+	me := ts.(*MatrixEnvelope)
+	out := make(map[string][]model.SamplePair, len(me.Data.Result))
+	for _, s := range me.Data.Result {
+		out[s.Metric.String()] = s.Values
+	}
+	return out
+}
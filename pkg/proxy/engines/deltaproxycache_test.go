@@ -21,11 +21,15 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	mockprom "github.com/tricksterproxy/mockster/pkg/mocks/prometheus"
+	"github.com/tricksterproxy/trickster/pkg/proxy/chain"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/partial"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
@@ -1173,7 +1177,7 @@ func TestDeltaProxyCacheRequestWithUnmarshalAndUpstreamErrors(t *testing.T) {
 	// Give time for the object to be written to cache in a separate goroutine from response
 	time.Sleep(time.Millisecond * 10)
 
-	key := oc.Host + ".dpc.61a603af5b94ea305dc3fa35af4eed98"
+	key := oc.Host + ".dpc.7bc9f0382bef3633669a458e56348f6e"
 
 	_, _, err = client.cache.Retrieve(key, false)
 	if err != nil {
@@ -1201,6 +1205,22 @@ func TestDeltaProxyCacheRequestWithUnmarshalAndUpstreamErrors(t *testing.T) {
 		t.Error(err)
 	}
 
+	// the undecodable object is handled as a cache miss
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "kmiss"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// and is rewritten by the response
+	time.Sleep(time.Millisecond * 10)
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "hit"})
+	if err != nil {
+		t.Error(err)
+	}
+
 	// Give time for the object to be written to cache in a separate goroutine from response
 	time.Sleep(time.Millisecond * 10)
 
@@ -1583,3 +1603,276 @@ func TestDeltaProxyCacheRequestFFTTLBiggerThanStep(t *testing.T) {
 	}
 
 }
+
+// failingTransport fails the upstream requests for ranges that start before the cutoff
+type failingTransport struct {
+	cutoff int64
+	next   http.RoundTripper
+}
+
+func (ft *failingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64); start < ft.cutoff {
+		return &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway",
+			Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader("bad gateway")),
+			Request: r}, nil
+	}
+	return ft.next.RoundTrip(r)
+}
+
+func TestDeltaProxyCacheRequestPartialResponse(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	pc := rsc.PathConfig
+
+	oc.FastForwardDisable = true
+	step := time.Duration(300) * time.Second
+
+	end := time.Now().Add(-time.Duration(12) * time.Hour).Truncate(step)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(6) * time.Hour), End: end}
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "kmiss"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// extend the range in both directions, and fail the fetch of the lower range
+	hc := &http.Client{Transport: &failingTransport{cutoff: extr.Start.Unix(),
+		next: http.DefaultTransport}}
+	oc.HTTPClient = hc
+	client.webClient = hc
+	wide := timeseries.Extent{Start: extr.Start.Add(-time.Hour), End: extr.End.Add(time.Hour)}
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), wide.Start.Unix(), wide.End.Unix(), queryReturnsOKNoLatency)
+	r.URL = u
+
+	// the default mode fails the request with the upstream response
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	err = testStatusCodeMatch(resp.StatusCode, http.StatusBadGateway)
+	if err != nil {
+		t.Error(err)
+	}
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "proxy-error"})
+	if err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameTricksterPartial); v != "" {
+		t.Errorf("expected empty %s header got %s", headers.NameTricksterPartial, v)
+	}
+
+	// the best-effort mode returns the data that was obtained
+	pc.PartialResponse = partial.ModeBestEffort
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	err = testStatusCodeMatch(resp.StatusCode, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "phit"})
+	if err != nil {
+		t.Error(err)
+	}
+	v := resp.Header.Get(headers.NameTricksterPartial)
+	if !strings.HasPrefix(v, "missing=") || !strings.Contains(v, strconv.FormatInt(wide.Start.Unix(), 10)) {
+		t.Errorf("expected missing range starting at %d got %s", wide.Start.Unix(), v)
+	}
+
+	// only the extents that were obtained are cached, so the lower range is fetched again
+	oc.HTTPClient = http.DefaultClient
+	client.webClient = http.DefaultClient
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "phit"})
+	if err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameTricksterPartial); v != "" {
+		t.Errorf("expected empty %s header got %s", headers.NameTricksterPartial, v)
+	}
+
+	// a range miss whose only fetch fails is not a partial response
+	oc.HTTPClient = hc
+	client.webClient = hc
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), wide.Start.Add(-48*time.Hour).Unix(), wide.Start.Add(-47*time.Hour).Unix(),
+		queryReturnsOKNoLatency)
+	r.URL = u
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	err = testStatusCodeMatch(resp.StatusCode, http.StatusBadGateway)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// chainedTierTransport simulates an upstream Trickster tier, whose responses report the tier in
+// the X-Trickster-Chain header, and report the missing ranges of partial responses
+type chainedTierTransport struct {
+	missing timeseries.ExtentList
+	next    http.RoundTripper
+	fetched []string
+}
+
+func (ct *chainedTierTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ct.fetched = append(ct.fetched, r.URL.Query().Get("start")+"-"+r.URL.Query().Get("end"))
+	resp, err := ct.next.RoundTrip(r)
+	if err != nil {
+		return resp, err
+	}
+	resp.Header.Set(headers.NameTricksterChain, "regional-1;status=phit;ms=12")
+	if len(ct.missing) > 0 {
+		resp.Header.Set(headers.NameTricksterPartial, "missing="+ct.missing.String())
+	}
+	return resp, nil
+}
+
+func TestDeltaProxyCacheRequestChainedTier(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	oc.FastForwardDisable = true
+	rsc.UpstreamChain = &chain.Upstream{}
+
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour).Truncate(step)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(6) * time.Hour), End: end}
+	gap := timeseries.Extent{Start: extr.Start, End: extr.Start.Add(time.Hour)}
+
+	// the regional tier's response is missing the first hour of the range
+	ct := &chainedTierTransport{missing: timeseries.ExtentList{gap}, next: http.DefaultTransport}
+	hc := &http.Client{Transport: ct}
+	oc.HTTPClient = hc
+	client.webClient = hc
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	err = testStatusCodeMatch(resp.StatusCode, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameTricksterPartial); v != "missing="+gap.String() {
+		t.Errorf("expected missing=%s got %s", gap.String(), v)
+	}
+	hops := chain.Parse(resp.Header.Get(headers.NameTricksterChain))
+	if len(hops) != 2 || hops[0].ID != "regional-1" || hops[0].Status != "phit" ||
+		hops[1].Status != "kmiss" {
+		t.Errorf("unexpected chain %s", resp.Header.Get(headers.NameTricksterChain))
+	}
+
+	// give time for the object to be written to cache in a separate goroutine from response
+	time.Sleep(time.Millisecond * 10)
+
+	// only the range that the regional tier filled was cached, so only the gap is fetched
+	ct.missing = nil
+	ct.fetched = nil
+	rsc.UpstreamChain = &chain.Upstream{}
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "phit"})
+	if err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameTricksterPartial); v != "" {
+		t.Errorf("expected empty %s header got %s", headers.NameTricksterPartial, v)
+	}
+	if len(ct.fetched) != 1 || ct.fetched[0] != gap.String() {
+		t.Errorf("expected fetch of %s got %v", gap.String(), ct.fetched)
+	}
+
+	// the regional tier's partial response for a missing range is reported by the response
+	gap = timeseries.Extent{Start: extr.End.Add(30 * time.Minute), End: extr.End.Add(time.Hour)}
+	ct.missing = timeseries.ExtentList{gap}
+	ct.fetched = nil
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Unix(), gap.End.Unix(), queryReturnsOKNoLatency)
+	r.URL = u
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "phit"})
+	if err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameTricksterPartial); v != "missing="+gap.String() {
+		t.Errorf("expected missing=%s got %s", gap.String(), v)
+	}
+	time.Sleep(time.Millisecond * 10)
+
+	// the ranges that the regional tier filled are not fetched again
+	ct.missing = nil
+	ct.fetched = nil
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	if len(ct.fetched) != 1 || ct.fetched[0] != gap.String() {
+		t.Errorf("expected fetch of %s got %v", gap.String(), ct.fetched)
+	}
+}
+
+func TestDeltaProxyCacheRequestAssumeSingleUse(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	rsc.OriginConfig.FastForwardDisable = true
+	rsc.PathConfig.AssumeSingleUse = true
+
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour).Truncate(step)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(6) * time.Hour), End: end}
+	expected, _, _ := mockprom.GetTimeSeriesData(queryReturnsOKNoLatency, extr.Start, extr.End, step)
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&single_use=dpc",
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	// the first request for the key is proxied, and the second request is cached
+	for _, cacheStatus := range []string{"proxy-only", "kmiss", "hit"} {
+		w := httptest.NewRecorder()
+		client.QueryRangeHandler(w, r)
+		resp := w.Result()
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		if err = testStringMatch(string(bodyBytes), expected); err != nil {
+			t.Error(err)
+		}
+		if err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": cacheStatus}); err != nil {
+			t.Error(err)
+		}
+		// give time for the object to be written to cache in a separate goroutine from response
+		time.Sleep(time.Millisecond * 10)
+	}
+}
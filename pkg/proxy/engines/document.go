@@ -42,7 +42,13 @@ type HTTPDocument struct {
 	Body          []byte              `msg:"body"`
 	ContentLength int64               `msg:"content_length"`
 	ContentType   string              `msg:"content_type"`
-	CachingPolicy *CachingPolicy      `msg:"caching_policy"`
+	// ResultLimit is the client-requested result limit under which the origin produced
+	// the document. 0 indicates the document is not limited
+	ResultLimit int `msg:"result_limit"`
+	// ContentHash is the hash of the document's timeseries content as of its most recent merge,
+	// from which the validators of responses rendered from the document are derived
+	ContentHash   string         `msg:"content_hash"`
+	CachingPolicy *CachingPolicy `msg:"caching_policy"`
 	// Ranges is the list of Byte Ranges contained in the body of this document
 	Ranges     byterange.Ranges              `msg:"ranges"`
 	RangeParts byterange.MultipartByteRanges `msg:"-"`
@@ -56,6 +62,12 @@ type HTTPDocument struct {
 	headerLock       sync.Mutex
 }
 
+// CoversResultLimit returns true if the document can satisfy a request for up to limit results,
+// where a limit of 0 requests all results
+func (d *HTTPDocument) CoversResultLimit(limit int) bool {
+	return d.ResultLimit == 0 || (limit > 0 && limit <= d.ResultLimit)
+}
+
 // SafeHeaderClone returns a threadsafe copy of the Document Header
 func (d *HTTPDocument) SafeHeaderClone() http.Header {
 	d.headerLock.Lock()
@@ -64,6 +76,39 @@ func (d *HTTPDocument) SafeHeaderClone() http.Header {
 	return h
 }
 
+// clone returns a copy of the document that can be modified without affecting the
+// original, which may be shared by reference with concurrent readers of a memory cache.
+// The Body and Range Parts are not deep copied, since the document's methods replace,
+// rather than modify, them
+func (d *HTTPDocument) clone() *HTTPDocument {
+	d.headerLock.Lock()
+	h := http.Header(d.Headers).Clone()
+	d.headerLock.Unlock()
+	d2 := &HTTPDocument{
+		StatusCode:       d.StatusCode,
+		Status:           d.Status,
+		Headers:          h,
+		Body:             d.Body,
+		ContentLength:    d.ContentLength,
+		ContentType:      d.ContentType,
+		ResultLimit:      d.ResultLimit,
+		ContentHash:      d.ContentHash,
+		Ranges:           d.Ranges,
+		RangeParts:       d.RangeParts,
+		StoredRangeParts: d.StoredRangeParts,
+		rangePartsLoaded: d.rangePartsLoaded,
+		isFulfillment:    d.isFulfillment,
+		isLoaded:         d.isLoaded,
+	}
+	if d.CachingPolicy != nil {
+		d2.CachingPolicy = d.CachingPolicy.Clone()
+	}
+	if d.timeseries != nil {
+		d2.timeseries = d.timeseries.Clone()
+	}
+	return d2
+}
+
 // Size returns the size of the HTTPDocument's headers, CachingPolicy, RangeParts, Body and timeseries data
 func (d *HTTPDocument) Size() int {
 	var i int
@@ -104,6 +104,16 @@ func (z *HTTPDocument) DecodeMsg(dc *msgp.Reader) (err error) {
 			if err != nil {
 				return
 			}
+		case "result_limit":
+			z.ResultLimit, err = dc.ReadInt()
+			if err != nil {
+				return
+			}
+		case "content_hash":
+			z.ContentHash, err = dc.ReadString()
+			if err != nil {
+				return
+			}
 		case "caching_policy":
 			if dc.IsNil() {
 				err = dc.ReadNil()
@@ -177,7 +187,7 @@ func (z *HTTPDocument) DecodeMsg(dc *msgp.Reader) (err error) {
 func (z *HTTPDocument) EncodeMsg(en *msgp.Writer) (err error) {
 	// map header, size 9
 	// write "status_code"
-	err = en.Append(0x89, 0xab, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x5f, 0x63, 0x6f, 0x64, 0x65)
+	err = en.Append(0x8b, 0xab, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x5f, 0x63, 0x6f, 0x64, 0x65)
 	if err != nil {
 		return
 	}
@@ -246,6 +256,24 @@ func (z *HTTPDocument) EncodeMsg(en *msgp.Writer) (err error) {
 	if err != nil {
 		return
 	}
+	// write "result_limit"
+	err = en.Append(0xac, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt(z.ResultLimit)
+	if err != nil {
+		return
+	}
+	// write "content_hash"
+	err = en.Append(0xac, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x68, 0x61, 0x73, 0x68)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.ContentHash)
+	if err != nil {
+		return
+	}
 	// write "caching_policy"
 	err = en.Append(0xae, 0x63, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x67, 0x5f, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79)
 	if err != nil {
@@ -305,7 +333,7 @@ func (z *HTTPDocument) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
 	// map header, size 9
 	// string "status_code"
-	o = append(o, 0x89, 0xab, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x5f, 0x63, 0x6f, 0x64, 0x65)
+	o = append(o, 0x8b, 0xab, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x5f, 0x63, 0x6f, 0x64, 0x65)
 	o = msgp.AppendInt(o, z.StatusCode)
 	// string "status"
 	o = append(o, 0xa6, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73)
@@ -329,6 +357,12 @@ func (z *HTTPDocument) MarshalMsg(b []byte) (o []byte, err error) {
 	// string "content_type"
 	o = append(o, 0xac, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65)
 	o = msgp.AppendString(o, z.ContentType)
+	// string "result_limit"
+	o = append(o, 0xac, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74)
+	o = msgp.AppendInt(o, z.ResultLimit)
+	// string "content_hash"
+	o = append(o, 0xac, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x68, 0x61, 0x73, 0x68)
+	o = msgp.AppendString(o, z.ContentHash)
 	// string "caching_policy"
 	o = append(o, 0xae, 0x63, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x67, 0x5f, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79)
 	if z.CachingPolicy == nil {
@@ -442,6 +476,16 @@ func (z *HTTPDocument) UnmarshalMsg(bts []byte) (o []byte, err error) {
 			if err != nil {
 				return
 			}
+		case "result_limit":
+			z.ResultLimit, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				return
+			}
+		case "content_hash":
+			z.ContentHash, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
 		case "caching_policy":
 			if msgp.IsNil(bts) {
 				bts, err = msgp.ReadNilBytes(bts)
@@ -524,7 +568,7 @@ func (z *HTTPDocument) Msgsize() (s int) {
 			}
 		}
 	}
-	s += 5 + msgp.BytesPrefixSize + len(z.Body) + 15 + msgp.Int64Size + 13 + msgp.StringPrefixSize + len(z.ContentType) + 15
+	s += 5 + msgp.BytesPrefixSize + len(z.Body) + 15 + msgp.Int64Size + 13 + msgp.StringPrefixSize + len(z.ContentType) + 13 + msgp.IntSize + 13 + msgp.StringPrefixSize + len(z.ContentHash) + 15
 	if z.CachingPolicy == nil {
 		s += msgp.NilSize
 	} else {
@@ -230,3 +230,25 @@ func TestLoadRangeParts(t *testing.T) {
 	}
 
 }
+
+func TestCoversResultLimit(t *testing.T) {
+
+	tests := []struct {
+		docLimit, limit int
+		expected        bool
+	}{
+		{0, 0, true},
+		{0, 10, true},
+		{10, 10, true},
+		{10, 5, true},
+		{10, 11, false},
+		{10, 0, false},
+	}
+
+	for i, test := range tests {
+		d := &HTTPDocument{ResultLimit: test.docLimit}
+		if v := d.CoversResultLimit(test.limit); v != test.expected {
+			t.Errorf("test %d: expected %t got %t", i, test.expected, v)
+		}
+	}
+}
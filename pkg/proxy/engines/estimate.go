@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/estimate"
+	"github.com/tricksterproxy/trickster/pkg/proxy/fingerprint"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// enforceMaxEstimatedPoints enforces the max estimated points of a timeseries request before it is
+// serviced. The number of points in the request's results is estimated from the series counts of
+// previous requests for the same query fingerprint, and a request whose estimate exceeds the max is
+// either rejected, or routed to its path's designated origin with the estimate in its
+// X-Trickster-Estimated-Points header. It returns false when the request was rejected or routed,
+// in which case the request must not be serviced
+func enforceMaxEstimatedPoints(w http.ResponseWriter, r *http.Request) bool {
+	rsc := request.GetResources(r)
+	if rsc == nil || rsc.PathConfig == nil || rsc.PathConfig.MaxEstimatedPoints <= 0 ||
+		tctx.EstimateRouted(r.Context()) {
+		return true
+	}
+	pc := rsc.PathConfig
+	oc := rsc.OriginConfig
+	// the bypass token is never forwarded to the origin
+	bypassed := estimate.Bypassed(r, pc.MaxEstimatedPointsBypassToken)
+	r.Header.Del(headers.NameTricksterEstimateBypass)
+	if bypassed {
+		return true
+	}
+	client, ok := rsc.OriginClient.(origins.TimeseriesClient)
+	if !ok {
+		return true
+	}
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		return true
+	}
+	fp := fingerprint.Sum(normalizeQuery(client, oc, trq.Statement))
+	series, ok := rsc.State().TopQueries.Series(oc.Name, fp,
+		time.Duration(pc.MaxEstimatedPointsTTLSecs)*time.Second)
+	if !ok {
+		return true
+	}
+	points := estimate.Points(series, trq.Extent, trq.Step)
+	if points <= pc.MaxEstimatedPoints {
+		return true
+	}
+	rsc.Logger.Debug("timeseries request exceeds max estimated points",
+		tl.Pairs{"originName": oc.Name, "fingerprint": fp, "estimatedPoints": points,
+			"maxEstimatedPoints": pc.MaxEstimatedPoints, "action": pc.MaxEstimatedPointsAction.String()})
+	if pc.MaxEstimatedPointsAction == estimate.ActionRoute && pc.MaxEstimatedPointsRouter != nil {
+		r.Header.Set(headers.NameTricksterEstimatedPoints, strconv.FormatInt(points, 10))
+		pc.MaxEstimatedPointsRouter.ServeHTTP(w, r.WithContext(tctx.WithEstimateRouted(r.Context())))
+		return false
+	}
+	e := txe.NewResponseError(http.StatusRequestEntityTooLarge, txe.CodeMaxEstimatedPoints,
+		fmt.Sprintf("the results of the request are estimated to have %d points, exceeding the max of %d",
+			points, pc.MaxEstimatedPoints))
+	e.Details = map[string]interface{}{
+		"fingerprint":          fp,
+		"estimated_points":     points,
+		"estimated_series":     series,
+		"max_estimated_points": pc.MaxEstimatedPoints,
+	}
+	e.Respond(w, r)
+	return false
+}
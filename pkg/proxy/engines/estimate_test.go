@@ -0,0 +1,163 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/estimate"
+	"github.com/tricksterproxy/trickster/pkg/proxy/fingerprint"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+)
+
+func TestEnforceMaxEstimatedPoints(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	pc := rsc.PathConfig
+	oc := rsc.OriginConfig
+	now := time.Now()
+	query := "estimated_query_here"
+
+	newRequest := func(d time.Duration) *http.Request {
+		req := r.Clone(r.Context())
+		req.URL.Path = "/prometheus/api/v1/query_range"
+		req.URL.RawQuery = url.Values{"query": {query}, "step": {"60"},
+			"start": {fmt.Sprint(now.Add(-d).Unix())}, "end": {fmt.Sprint(now.Unix())}}.Encode()
+		return req
+	}
+
+	pc.MaxEstimatedPoints = 1000
+
+	// queries whose results were not yet recorded are not estimated
+	if !enforceMaxEstimatedPoints(httptest.NewRecorder(), newRequest(time.Duration(24)*time.Hour)) {
+		t.Error("expected request to pass")
+	}
+
+	fp := fingerprint.Sum(normalizeQuery(rsc.OriginClient.(*TestClient), oc, query))
+	instance.Default.TopQueries.Record(oc.Name, fp, query, 0, 0)
+	instance.Default.TopQueries.RecordSeries(oc.Name, fp, 10, time.Hour)
+
+	// 10 series over an hour at a 1m step is estimated at 610 points
+	if !enforceMaxEstimatedPoints(httptest.NewRecorder(), newRequest(time.Hour)) {
+		t.Error("expected request to pass")
+	}
+
+	// while over 2 hours, it is estimated at 1210 points
+	w := httptest.NewRecorder()
+	if enforceMaxEstimatedPoints(w, newRequest(time.Duration(2)*time.Hour)) {
+		t.Error("expected request to be rejected")
+	}
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected %d got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+	// the prometheus error envelope reports the estimate in its message
+	if b := w.Body.String(); !strings.Contains(b, `"`+txe.CodeMaxEstimatedPoints+`"`) ||
+		!strings.Contains(b, "estimated to have 1210 points") {
+		t.Errorf("unexpected body %s", b)
+	}
+
+	// the bypass header exempts the request when it holds the token, and is never forwarded
+	pc.MaxEstimatedPointsBypassToken = "secret"
+	req := newRequest(time.Duration(2) * time.Hour)
+	req.Header.Set(headers.NameTricksterEstimateBypass, "wrong")
+	if enforceMaxEstimatedPoints(httptest.NewRecorder(), req) {
+		t.Error("expected request to be rejected")
+	}
+	req.Header.Set(headers.NameTricksterEstimateBypass, "secret")
+	if !enforceMaxEstimatedPoints(httptest.NewRecorder(), req) {
+		t.Error("expected request to pass")
+	}
+	if req.Header.Get(headers.NameTricksterEstimateBypass) != "" {
+		t.Error("expected the bypass header to be removed")
+	}
+
+	// the route action routes the request to the path's designated origin, with its estimate
+	var routed *http.Request
+	pc.MaxEstimatedPointsAction = estimate.ActionRoute
+	pc.MaxEstimatedPointsRouter = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routed = r
+		w.WriteHeader(http.StatusNoContent)
+	})
+	w = httptest.NewRecorder()
+	if enforceMaxEstimatedPoints(w, newRequest(time.Duration(2)*time.Hour)) {
+		t.Error("expected request to be routed")
+	}
+	if routed == nil || w.Code != http.StatusNoContent {
+		t.Fatal("expected request to be routed")
+	}
+	if h := routed.Header.Get(headers.NameTricksterEstimatedPoints); h != "1210" {
+		t.Errorf("expected %s got %s", "1210", h)
+	}
+
+	// and a routed request is not estimated again
+	if !enforceMaxEstimatedPoints(httptest.NewRecorder(), routed) ||
+		!tctx.EstimateRouted(routed.Context()) {
+		t.Error("expected routed request to pass")
+	}
+}
+
+func TestDeltaProxyCacheRequestMaxEstimatedPoints(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	rsc.PathConfig.CacheKeyParams = []string{upQuery, upStep}
+	rsc.PathConfig.MaxEstimatedPoints = 200
+
+	step := time.Duration(60) * time.Second
+	end := time.Now().Truncate(step)
+	newRequest := func(d time.Duration) *http.Request {
+		req := r.Clone(r.Context())
+		req.URL.Path = "/prometheus/api/v1/query_range"
+		req.URL.RawQuery = url.Values{"query": {"some_query_here{latency_ms=0,range_latency_ms=0,series_id=1}"},
+			"step": {fmt.Sprint(int(step.Seconds()))}, "start": {fmt.Sprint(end.Add(-d).Unix())},
+			"end": {fmt.Sprint(end.Unix())}}.Encode()
+		return req
+	}
+
+	// the first request is serviced, and records the series count of the query's results
+	w := httptest.NewRecorder()
+	client.QueryRangeHandler(w, newRequest(time.Hour))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+
+	// which estimates that a request over a longer range exceeds the max
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, newRequest(time.Duration(6)*time.Hour))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected %d got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
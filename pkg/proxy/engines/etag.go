@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+// timeseriesContentHash returns the hash of the timeseries' serialized content
+func timeseriesContentHash(client origins.TimeseriesClient, ts timeseries.Timeseries) string {
+	b, err := client.MarshalTimeseries(ts)
+	if err != nil {
+		return ""
+	}
+	return md5.Checksum(string(b))
+}
+
+// timeseriesETag returns a strong validator for a response rendered from a document with the
+// provided content hash, for the requested extent and step. transform describes any render-time
+// transformations applied to the response (e.g., downsampling or fast forward data), so that
+// responses rendered differently from the same document never share a validator
+func timeseriesETag(contentHash string, trq *timeseries.TimeRangeQuery, transform string) string {
+	if contentHash == "" {
+		return ""
+	}
+	return `"` + md5.Checksum(strings.Join([]string{contentHash,
+		strconv.FormatInt(trq.Extent.Start.UnixNano(), 10),
+		strconv.FormatInt(trq.Extent.End.UnixNano(), 10),
+		trq.Step.String(), transform}, ".")) + `"`
+}
+
+// transformedETag returns the validator for a response rendered from a document having the
+// provided origin validator, after the transform was applied to the document's body. Weak
+// origin validators remain weak
+func transformedETag(etag, transform string) string {
+	if etag == "" || transform == "" {
+		return etag
+	}
+	var prefix string
+	if strings.HasPrefix(etag, "W/") {
+		prefix = "W/"
+	}
+	return prefix + `"` + md5.Checksum(etag+"."+transform) + `"`
+}
+
+// clientHasCurrentETag returns true if the client request is a GET or HEAD request having
+// an If-None-Match header that is satisfied by the validator
+func clientHasCurrentETag(r *http.Request, etag string) bool {
+	if etag == "" || !methods.IsCacheable(r.Method) {
+		return false
+	}
+	inm := r.Header.Get(headers.NameIfNoneMatch)
+	if inm == "" {
+		return false
+	}
+	// CheckIfNoneMatch returns false when the client's validator matches
+	return !CheckIfNoneMatch(strings.Trim(etag, `"`), inm, status.LookupStatusHit)
+}
+
+// setTimeseriesValidator replaces any origin validator in the header, which does not apply to
+// responses rendered from merged timeseries, with the provided validator. It returns true if
+// the client's conditional request is satisfied by the validator, and should receive a 304
+func setTimeseriesValidator(r *http.Request, code int, h http.Header, etag string) bool {
+	h.Del(headers.NameETag)
+	if etag == "" || code != http.StatusOK {
+		return false
+	}
+	h.Set(headers.NameETag, etag)
+	return clientHasCurrentETag(r, etag)
+}
+
+// limitedETag returns the validator for a response that was trimmed to the result limit
+func limitedETag(etag string, limit int) string {
+	return transformedETag(etag, "limit."+strconv.Itoa(limit))
+}
@@ -0,0 +1,216 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestTimeseriesETag(t *testing.T) {
+
+	end := time.Unix(3600, 0)
+	trq := &timeseries.TimeRangeQuery{Extent: timeseries.Extent{Start: time.Unix(0, 0), End: end},
+		Step: time.Minute}
+
+	if v := timeseriesETag("", trq, ""); v != "" {
+		t.Errorf("expected empty validator got %s", v)
+	}
+
+	etag := timeseriesETag("abc", trq, "")
+	if len(etag) != 34 || etag[0] != '"' || etag[33] != '"' {
+		t.Errorf("expected quoted strong validator got %s", etag)
+	}
+	if v := timeseriesETag("abc", trq, ""); v != etag {
+		t.Errorf("expected %s got %s", etag, v)
+	}
+
+	trq2 := trq.Clone()
+	trq2.Extent.End = end.Add(time.Minute)
+	trq3 := trq.Clone()
+	trq3.Step = time.Second * 30
+
+	tests := []struct {
+		hash      string
+		trq       *timeseries.TimeRangeQuery
+		transform string
+	}{
+		{"abd", trq, ""},
+		{"abc", trq2, ""},
+		{"abc", trq3, ""},
+		{"abc", trq, "downsample.30s"},
+		{"abc", trq, "ff.123"},
+	}
+
+	for i, test := range tests {
+		if v := timeseriesETag(test.hash, test.trq, test.transform); v == etag {
+			t.Errorf("test %d: expected validator other than %s", i, etag)
+		}
+	}
+}
+
+func TestTransformedETag(t *testing.T) {
+
+	if v := transformedETag("", "limit.1"); v != "" {
+		t.Errorf("expected empty validator got %s", v)
+	}
+	if v := transformedETag(`"abc"`, ""); v != `"abc"` {
+		t.Errorf("expected %s got %s", `"abc"`, v)
+	}
+
+	v1 := limitedETag(`"abc"`, 1)
+	if v1 == `"abc"` || v1[0] != '"' {
+		t.Errorf("expected strong transformed validator got %s", v1)
+	}
+	if v2 := limitedETag(`"abc"`, 2); v2 == v1 {
+		t.Errorf("expected validators to differ for different limits: %s", v2)
+	}
+	if v := limitedETag(`W/"abc"`, 1); v[:3] != `W/"` {
+		t.Errorf("expected weak transformed validator got %s", v)
+	}
+}
+
+func TestClientHasCurrentETag(t *testing.T) {
+
+	tests := []struct {
+		method   string
+		inm      string
+		etag     string
+		expected bool
+	}{
+		{http.MethodGet, "", `"abc"`, false},
+		{http.MethodGet, `"abc"`, "", false},
+		{http.MethodGet, `"abc"`, `"abc"`, true},
+		{http.MethodHead, `"abc"`, `"abc"`, true},
+		{http.MethodGet, `"xyz", "abc"`, `"abc"`, true},
+		{http.MethodGet, `"xyz"`, `"abc"`, false},
+		{http.MethodGet, "*", `"abc"`, true},
+		{http.MethodPost, `"abc"`, `"abc"`, false},
+	}
+
+	for i, test := range tests {
+		r := httptest.NewRequest(test.method, "http://0/", nil)
+		if test.inm != "" {
+			r.Header.Set(headers.NameIfNoneMatch, test.inm)
+		}
+		if v := clientHasCurrentETag(r, test.etag); v != test.expected {
+			t.Errorf("test %d: expected %t got %t", i, test.expected, v)
+		}
+	}
+}
+
+func TestDeltaProxyCacheRequestETag(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	oc.FastForwardDisable = true
+	oc.ReuseCoarserStep = true
+
+	end := time.Now().Add(-time.Duration(4) * time.Hour).Truncate(time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(6) * time.Hour), End: end}
+
+	resp, _, err := testDPCStepRequest(r, client, extr, time.Minute, map[string]string{"status": "kmiss"})
+	if err != nil {
+		t.Error(err)
+	}
+	etag := resp.Header.Get(headers.NameETag)
+	if etag == "" {
+		t.Fatal("expected validator on response")
+	}
+
+	resp, _, err = testDPCStepRequest(r, client, extr, time.Minute, map[string]string{"status": "hit"})
+	if err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameETag); v != etag {
+		t.Errorf("expected %s got %s", etag, v)
+	}
+
+	// a conditional request with a current validator receives a 304 with no body
+	r.Header.Set(headers.NameIfNoneMatch, etag)
+	w := httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("expected %d got %d", http.StatusNotModified, resp.StatusCode)
+	}
+	if len(b) != 0 {
+		t.Errorf("expected empty body got %s", string(b))
+	}
+	if v := resp.Header.Get(headers.NameETag); v != etag {
+		t.Errorf("expected %s got %s", etag, v)
+	}
+
+	// a subset of the cached range is rendered differently, so the validator doesn't match
+	extr2 := timeseries.Extent{Start: extr.Start.Add(time.Hour), End: extr.End}
+	resp, _, err = testDPCStepRequest(r, client, extr2, time.Minute, map[string]string{"status": "hit"})
+	if err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameETag); v == etag || v == "" {
+		t.Errorf("expected new validator got %s", v)
+	}
+
+	// a response downsampled from the 1m document doesn't share its validator
+	resp, _, err = testDPCStepRequest(r, client, extr, 5*time.Minute, map[string]string{"status": "hit"})
+	if err != nil {
+		t.Error(err)
+	}
+	if resp.Header.Get(headers.NameTricksterStepReuse) == "" {
+		t.Error("expected step reuse")
+	}
+	etag5m := resp.Header.Get(headers.NameETag)
+	if etag5m == etag || etag5m == "" {
+		t.Errorf("expected new validator got %s", etag5m)
+	}
+
+	r.Header.Set(headers.NameIfNoneMatch, etag5m)
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected %d got %d", http.StatusNotModified, w.Code)
+	}
+
+	// extending the range merges new data into the document, which updates its validator
+	r.Header.Del(headers.NameIfNoneMatch)
+	extr3 := timeseries.Extent{Start: extr.Start.Add(-time.Hour), End: extr.End}
+	if _, _, err = testDPCStepRequest(r, client, extr3, time.Minute,
+		map[string]string{"status": "phit"}); err != nil {
+		t.Error(err)
+	}
+	r.Header.Set(headers.NameIfNoneMatch, etag)
+	resp, _, err = testDPCStepRequest(r, client, extr, time.Minute, map[string]string{"status": "hit"})
+	if err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameETag); v == etag || v == "" {
+		t.Errorf("expected new validator got %s", v)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/proxy/fingerprint"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// queryRecorder is an http.ResponseWriter that counts the bytes of the response to a timeseries
+// query, so that the costs of the query can be recorded under its fingerprint once it completes
+type queryRecorder struct {
+	http.ResponseWriter
+	fingerprint string
+	query       string
+	start       time.Time
+	bytes       int64
+	// series is the number of series in the response, or -1 when it is not known
+	series int
+	// seriesTTL is the age after which the recorded series count of the query is replaced
+	seriesTTL time.Duration
+}
+
+// normalizeQuery returns the normalized form of the query, using the client's QueryNormalizer
+// when it has one, or otherwise by collapsing the query's whitespace
+func normalizeQuery(client origins.TimeseriesClient, oc *oo.Options, query string) string {
+	if qn, ok := client.(origins.QueryNormalizer); ok {
+		return qn.NormalizeQuery(query, oc.FingerprintStripValues)
+	}
+	return strings.Join(strings.Fields(query), " ")
+}
+
+func newQueryRecorder(w http.ResponseWriter, client origins.TimeseriesClient,
+	oc *oo.Options, trq *timeseries.TimeRangeQuery) *queryRecorder {
+	query := normalizeQuery(client, oc, trq.Statement)
+	return &queryRecorder{
+		ResponseWriter: w,
+		fingerprint:    fingerprint.Sum(query),
+		query:          query,
+		start:          time.Now(),
+		series:         -1,
+	}
+}
+
+func (qr *queryRecorder) Write(b []byte) (int, error) {
+	n, err := qr.ResponseWriter.Write(b)
+	qr.bytes += int64(n)
+	return n, err
+}
+
+// Flush flushes the underlying ResponseWriter, if it is an http.Flusher
+func (qr *queryRecorder) Flush() {
+	if f, ok := qr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// record records the costs of the query, and logs it as a slow query when its duration exceeds
+// the origin's threshold. elapsed is the time the request spent fetching from the origin, except
+// on a cache hit, and the entire duration of a request that is proxied without caching
+func (qr *queryRecorder) record(rsc *request.Resources,
+	cacheStatus status.LookupStatus, elapsed time.Duration) {
+	oc, logger := rsc.OriginConfig, rsc.Logger
+	duration := time.Since(qr.start)
+	var fetchTime time.Duration
+	switch cacheStatus {
+	case status.LookupStatusHit:
+	case status.LookupStatusProxyOnly:
+		fetchTime = duration
+	default:
+		fetchTime = elapsed
+	}
+	tracker := rsc.State().TopQueries
+	tracker.Record(oc.Name, qr.fingerprint, qr.query, fetchTime, qr.bytes)
+	if qr.series >= 0 {
+		tracker.RecordSeries(oc.Name, qr.fingerprint, qr.series, qr.seriesTTL)
+	}
+	if oc.SlowQueryThreshold > 0 && duration > oc.SlowQueryThreshold && logger != nil {
+		logger.Warn("slow query", tl.Pairs{"originName": oc.Name, "fingerprint": qr.fingerprint,
+			"query": qr.query, "cacheStatus": cacheStatus.String(), "durationMS": duration.Milliseconds(),
+			"originFetchMS": fetchTime.Milliseconds(), "bytes": qr.bytes})
+	}
+}
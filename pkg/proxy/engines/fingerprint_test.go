@@ -0,0 +1,110 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/proxy/fingerprint"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+type testNormalizerClient struct {
+	*TestClient
+}
+
+func (c *testNormalizerClient) NormalizeQuery(query string, stripValues bool) string {
+	if stripValues {
+		return "stripped"
+	}
+	return "normalized"
+}
+
+func TestNormalizeQuery(t *testing.T) {
+
+	oc := oo.NewOptions()
+	// clients without a QueryNormalizer only have their whitespace collapsed
+	if v := normalizeQuery(&TestClient{}, oc, " sum( up{job=\"a\"} )\n "); v != `sum( up{job="a"} )` {
+		t.Errorf("expected %s got %s", `sum( up{job="a"} )`, v)
+	}
+	c := &testNormalizerClient{TestClient: &TestClient{}}
+	if v := normalizeQuery(c, oc, "up"); v != "normalized" {
+		t.Errorf("expected %s got %s", "normalized", v)
+	}
+	oc.FingerprintStripValues = true
+	if v := normalizeQuery(c, oc, "up"); v != "stripped" {
+		t.Errorf("expected %s got %s", "stripped", v)
+	}
+}
+
+func TestDeltaProxyCacheRequestFingerprint(t *testing.T) {
+
+	instance.Default.TopQueries.Resize(7)
+	defer instance.Default.TopQueries.Resize(d.DefaultTopQueriesSize)
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	oc.FastForwardDisable = true
+	oc.SlowQueryThreshold = time.Nanosecond
+
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(18) * time.Hour), End: end}
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	client.QueryRangeHandler(w, r)
+	bytes := w.Body.Len()
+	time.Sleep(time.Millisecond * 10)
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	bytes += w.Body.Len()
+
+	entries, _ := instance.Default.TopQueries.Top(0, fingerprint.OrderRequests)
+	if len(entries) != 1 {
+		t.Fatalf("expected %d entries got %d", 1, len(entries))
+	}
+	e := entries[0]
+	if e.OriginName != oc.Name || e.Query != queryReturnsOKNoLatency ||
+		e.Fingerprint != fingerprint.Sum(queryReturnsOKNoLatency) {
+		t.Errorf("unexpected entry %v", e)
+	}
+	if e.Requests != 2 {
+		t.Errorf("expected %d requests got %d", 2, e.Requests)
+	}
+	if e.Bytes != int64(bytes) {
+		t.Errorf("expected %d bytes got %d", bytes, e.Bytes)
+	}
+	if e.OriginFetchSecs <= 0 {
+		t.Errorf("expected origin fetch time, got %f", e.OriginFetchSecs)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/healthcheck"
+	ho "github.com/tricksterproxy/trickster/pkg/proxy/healthcheck/options"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+)
+
+// HealthCheck makes the upstream health check described by the Origin Options and provider defaults.
+// The upstream response is relayed when it meets the health check expectations; otherwise the
+// client receives a 503 describing the failed expectation
+func HealthCheck(w http.ResponseWriter, r *http.Request, oc *oo.Options,
+	base *url.URL, defaults *ho.Options) {
+
+	t, err := healthcheck.NewTarget(oc, base, defaults)
+	if err != nil {
+		healthCheckError(http.StatusInternalServerError, txe.CodeHealthCheckInvalid,
+			"invalid health check configuration: "+err.Error(), oc).Respond(w, r)
+		return
+	}
+
+	if t == nil {
+		healthCheckError(http.StatusBadRequest, txe.CodeHealthCheckNotConfigured,
+			"health check url not configured", oc).Respond(w, r)
+		return
+	}
+
+	rsc := request.GetResources(r)
+	req, cancel := t.NewRequest(tctx.WithHealthCheckFlag(tctx.WithResources(context.Background(), rsc), true))
+	defer cancel()
+
+	buf := &bytes.Buffer{}
+	resp := DoProxy(buf, req, true)
+
+	if err := t.Check(resp.StatusCode, buf.Bytes()); err != nil {
+		healthCheckError(http.StatusServiceUnavailable, txe.CodeOriginUnhealthy,
+			err.Error(), oc).Respond(w, r)
+		return
+	}
+
+	Respond(w, resp.StatusCode, resp.Header, buf.Bytes())
+}
+
+func healthCheckError(code int, errCode, message string, oc *oo.Options) *txe.ResponseError {
+	e := txe.NewResponseError(code, errCode, message)
+	e.Origin = oc.Name
+	e.OriginType = oc.OriginType
+	return e
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	ho "github.com/tricksterproxy/trickster/pkg/proxy/healthcheck/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+func TestHealthCheck(t *testing.T) {
+
+	es := tu.NewTestServer(http.StatusOK, "test", nil)
+	defer es.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", es.URL, "-origin-type", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	oc := conf.Origins["default"]
+	oc.HTTPClient = http.DefaultClient
+	base, _ := url.Parse(es.URL)
+	defaults := &ho.Options{Verb: http.MethodGet, Path: "/"}
+
+	r := httptest.NewRequest("GET", "http://0/trickster/health/default", nil)
+	r = r.WithContext(tc.WithResources(r.Context(),
+		request.NewResources(oc, nil, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+
+	w := httptest.NewRecorder()
+	HealthCheck(w, r, oc, base, defaults)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	if string(b) != "test" {
+		t.Errorf("expected %s got %s", "test", string(b))
+	}
+
+	oc.HealthCheckExpectedBody = "healthy"
+	w = httptest.NewRecorder()
+	HealthCheck(w, r, oc, base, defaults)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected %d got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	checkResponseErrorCode(t, resp, txe.CodeOriginUnhealthy)
+
+	oc.HealthCheckExpectedBody = ""
+	oc.HealthCheckBody = "test"
+	w = httptest.NewRecorder()
+	HealthCheck(w, r, oc, base, defaults)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected %d got %d", http.StatusInternalServerError, resp.StatusCode)
+	}
+	checkResponseErrorCode(t, resp, txe.CodeHealthCheckInvalid)
+
+	oc.HealthCheckBody = ""
+	oc.HealthCheckVerb = ""
+	w = httptest.NewRecorder()
+	HealthCheck(w, r, oc, base, defaults)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	checkResponseErrorCode(t, resp, txe.CodeHealthCheckNotConfigured)
+}
+
+func checkResponseErrorCode(t *testing.T, resp *http.Response, code string) {
+	t.Helper()
+	e := &txe.ResponseError{}
+	if err := json.NewDecoder(resp.Body).Decode(e); err != nil {
+		t.Error(err)
+		return
+	}
+	if e.Code != code {
+		t.Errorf("expected %s got %s", code, e.Code)
+	}
+	if e.StatusCode != resp.StatusCode {
+		t.Errorf("expected %d got %d", resp.StatusCode, e.StatusCode)
+	}
+	if e.Origin != "default" {
+		t.Errorf("expected %s got %s", "default", e.Origin)
+	}
+}
@@ -18,6 +18,7 @@ package engines
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"math"
@@ -28,19 +29,25 @@ import (
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/proxy/background"
+	"github.com/tricksterproxy/trickster/pkg/proxy/chain"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/proxy/timeconv"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
 	"github.com/tricksterproxy/trickster/pkg/tracing"
 	tspan "github.com/tricksterproxy/trickster/pkg/tracing/span"
 	"github.com/tricksterproxy/trickster/pkg/util/log"
-	"github.com/tricksterproxy/trickster/pkg/util/metrics"
 
 	"go.opentelemetry.io/otel/api/kv"
-	othttptrace "go.opentelemetry.io/otel/plugin/httptrace"
+	"go.opentelemetry.io/otel/api/propagation"
 )
 
 // Reqs is for Progressive Collapsed Forwarding
@@ -64,6 +71,9 @@ func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
 
 	pc := rsc.PathConfig
 
+	// bootstrap markers only apply to cache fills, and are never forwarded upstream
+	r.Header.Del(headers.NameTricksterBootstrap)
+
 	var elapsed time.Duration
 	var cacheStatusCode status.LookupStatus
 	var resp *http.Response
@@ -71,11 +81,20 @@ func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
 
 	if pc == nil || pc.CollapsedForwardingType != forwarding.CFTypeProgressive ||
 		!methods.HasBody(r.Method) {
-		reader, resp, _ = PrepareFetchReader(r)
+		var contentLength int64
+		reader, resp, contentLength = PrepareFetchReader(r)
 		cacheStatusCode = setStatusHeader(resp.StatusCode, resp.Header)
-		writer := PrepareResponseWriter(w, resp.StatusCode, resp.Header)
+		chain.SetResponseHeader(resp.Header, rsc.UpstreamChain.Hops(), cacheStatusCode.String(),
+			time.Since(start))
+		writer := prepareResponseWriter(w, resp.StatusCode, resp.Header, contentLength)
 		if writer != nil && reader != nil {
-			io.Copy(writer, reader)
+			if contentLength < 0 {
+				// the upstream response length is unknown, so the downstream response is chunked,
+				// and each read from the upstream is flushed to the client as it is received
+				copyWithFlush(writer, reader)
+			} else {
+				io.Copy(writer, reader)
+			}
 		}
 	} else {
 		pr := newProxyRequest(r, w)
@@ -85,22 +104,25 @@ func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
 			var contentLength int64
 			reader, resp, contentLength = PrepareFetchReader(r)
 			cacheStatusCode = setStatusHeader(resp.StatusCode, resp.Header)
+			chain.SetResponseHeader(resp.Header, rsc.UpstreamChain.Hops(), cacheStatusCode.String(),
+				time.Since(start))
 			writer := PrepareResponseWriter(w, resp.StatusCode, resp.Header)
 			// Check if we know the content length and if it is less than our max object size.
 			if contentLength != 0 && contentLength < int64(oc.MaxObjectSizeBytes) {
 				pcf := NewPCF(resp, contentLength)
 				reqs.Store(key, pcf)
-				// Blocks until server completes
-				grClose := reader != nil && closeResponse
+				// Blocks until server completes. The upstream body is read to completion for the
+				// collapsed clients, so it is always closed once read
+				grClose := reader != nil
 				closeResponse = false
-				go func() {
+				background.Go(background.KindCollapsedForward, func() {
 					io.Copy(pcf, reader)
 					pcf.Close()
 					reqs.Delete(key)
 					if grClose {
 						reader.Close()
 					}
-				}()
+				})
 				pcf.AddClient(writer)
 			}
 		} else {
@@ -124,10 +146,19 @@ func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
 // PrepareResponseWriter prepares a response and returns an io.Writer for the data to be written to.
 // Used in Respond.
 func PrepareResponseWriter(w io.Writer, code int, header http.Header) io.Writer {
+	return prepareResponseWriter(w, code, header, -1)
+}
+
+// prepareResponseWriter prepares a response having the provided Content-Length. When the length is
+// less than zero, the response has no Content-Length and is chunked
+func prepareResponseWriter(w io.Writer, code int, header http.Header, contentLength int64) io.Writer {
 	if rw, ok := w.(http.ResponseWriter); ok {
 		h := rw.Header()
 		headers.Merge(h, header)
 		headers.AddResponseHeaders(h)
+		if contentLength >= 0 && bodyAllowedForStatus(code) {
+			h.Set(headers.NameContentLength, strconv.FormatInt(contentLength, 10))
+		}
 		rw.WriteHeader(code)
 		return rw
 	}
@@ -152,22 +183,58 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 	var rc io.ReadCloser
 
 	headers.AddForwardingHeaders(r, oc.ForwardedHeaders)
+	chain.SetRequestHeader(r.Header)
+	r.Header.Del(headers.NameTricksterRevalidate)
+
+	rewindBody(r)
 
 	if pc != nil {
 		headers.UpdateHeaders(r.Header, pc.RequestHeaders)
-		qp, _, _ := params.GetRequestValues(r)
-		params.UpdateParams(qp, pc.RequestParams)
-		params.SetRequestValues(r, qp)
+		// requests are only rewritten when there are param updates, so that they are otherwise
+		// forwarded in the client's original form (e.g., parameter ordering and JSON bodies)
+		if len(pc.RequestParams) > 0 {
+			qp, _, _ := params.GetRequestValues(r)
+			params.UpdateParams(qp, pc.RequestParams)
+			params.SetRequestValues(r, qp)
+		}
+	}
+
+	// pacing precedes the timeout budget, since time spent queued is deducted from it
+	if paced(r, oc) {
+		if resp := paceUpstream(r, rsc); resp != nil {
+			if pc != nil {
+				headers.UpdateHeaders(resp.Header, pc.ResponseHeaders)
+			}
+			return resp.Body, resp, resp.ContentLength
+		}
 	}
 
+	class := requestPriority(r, pc)
+	tspan.SetAttributes(rsc.Tracer, span, kv.String("request.priority", class.String()))
+
+	// admission follows pacing, so that requests queued for the rate limit to reset do not hold
+	// the origin's concurrency capacity, and precedes the timeout budget, like pacing
+	var slot *priority.Slot
+	if concurrencyLimited(r, oc) {
+		var resp *http.Response
+		if slot, resp = admitUpstream(r, rsc, class); resp != nil {
+			if pc != nil {
+				headers.UpdateHeaders(resp.Header, pc.ResponseHeaders)
+			}
+			return resp.Body, resp, resp.ContentLength
+		}
+	}
+
+	forwardTimeoutBudget(r, rsc)
+
 	r.Close = false
 	r.RequestURI = ""
 
 	if rsc.Tracer != nil {
 		// Processing traces for proxies
 		// https://www.w3.org/TR/trace-context-1/#alternative-processing
-		ctx, r = othttptrace.W3C(ctx, r)
-		othttptrace.Inject(ctx, r)
+		r = r.WithContext(ctx)
+		propagation.InjectHTTP(ctx, tracing.Propagators, r.Header)
 	}
 
 	ctx, doSpan := tspan.NewChildSpan(r.Context(), rsc.Tracer, "ProxyRequest")
@@ -178,13 +245,52 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 	// clear the Host header before proxying or it will be forwarded upstream
 	r.Host = ""
 
+	var cancel context.CancelFunc
+	if !rsc.TimeoutDeadline.IsZero() {
+		var dctx context.Context
+		dctx, cancel = context.WithDeadline(r.Context(), rsc.TimeoutDeadline)
+		r = r.WithContext(dctx)
+	}
+
+	ut := rsc.State().Upstreams.Get(oc.Name, oc.OriginType)
+	done := ut.Begin()
 	resp, err := oc.HTTPClient.Do(r)
+	if err != nil {
+		done()
+		ut.ObserveError(err, time.Now())
+	} else {
+		ut.ObserveResponse(resp, time.Now())
+		rsc.Logger.Trace("upstream request complete", log.Pairs{"statusCode": resp.StatusCode,
+			"url": func() interface{} { return r.URL.String() }})
+		// the request is in flight until its body is read
+		resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: done}
+	}
+	if cancel != nil {
+		if err != nil {
+			cancel()
+		} else {
+			// the deadline must outlive the Do call, since it also bounds reading the body
+			resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+		}
+	}
+	if slot != nil {
+		if err != nil {
+			slot.Release()
+		} else {
+			// the admission is held until the body is read, since the origin is serving it until then
+			resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: slot.Release}
+		}
+	}
 	if err != nil {
 		rsc.Logger.Error("error downloading url", log.Pairs{"url": r.URL.String(), "detail": err.Error()})
 		// if there is an err and the response is nil, the server could not be reached
 		// so make a 502 for the downstream response
 		if resp == nil {
-			resp = &http.Response{StatusCode: http.StatusBadGateway, Request: r, Header: make(http.Header)}
+			re := txe.UpstreamError(err).WithRequest(r)
+			b := re.Body()
+			resp = &http.Response{StatusCode: re.StatusCode, Request: r, Header: re.Header(),
+				Body: ioutil.NopCloser(bytes.NewReader(b)), ContentLength: int64(len(b))}
+			rc = resp.Body
 		}
 
 		if pc != nil {
@@ -200,9 +306,13 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 			)
 			doSpan.SetStatus(tracing.HTTPToCode(resp.StatusCode), "")
 		}
-		return nil, resp, 0
+		return rc, resp, resp.ContentLength
 	}
 
+	// the chain reported by an upstream Trickster is included in this Trickster's chain, rather
+	// than being cached or forwarded as-is
+	rsc.UpstreamChain.Observe(resp.Header)
+
 	originalLen := int64(-1)
 	if v, ok := resp.Header[headers.NameContentLength]; ok {
 		originalLen, err = strconv.ParseInt(strings.Join(v, ""), 10, 64)
@@ -212,23 +322,33 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 		resp.ContentLength = originalLen
 	}
 
-	// warn if the clock between trickster and the origin is off by more than 1 minute
-	if date := resp.Header.Get(headers.NameDate); date != "" {
+	// track the clock skew between trickster and the origin, and warn if it is too high.
+	// bootstrap peer fills are skipped, since their Date reflects the peer's clock
+	if date := resp.Header.Get(headers.NameDate); date != "" &&
+		r.Header.Get(headers.NameTricksterBootstrap) == "" {
 		d, err := http.ParseTime(date)
 		if err == nil {
-			if offset := time.Since(d); time.Duration(math.Abs(float64(offset))) > time.Minute {
+			// Date headers are truncated to the second, so on average they
+			// trail the origin's clock by half a second
+			skew := rsc.State().ClockSkews.Observe(oc.Name, oc.OriginType, d.Add(500*time.Millisecond), time.Now())
+			if oc.ClockSkewWarnThreshold > 0 &&
+				time.Duration(math.Abs(float64(skew))) > oc.ClockSkewWarnThreshold {
 				rsc.Logger.WarnOnce("clockoffset."+oc.Name,
 					"clock offset between trickster host and origin is high and may cause data anomalies",
 					log.Pairs{
 						"originName":    oc.Name,
-						"tricksterTime": strconv.FormatInt(d.Add(offset).Unix(), 10),
+						"tricksterTime": strconv.FormatInt(time.Now().Unix(), 10),
 						"originTime":    strconv.FormatInt(d.Unix(), 10),
-						"offset":        strconv.FormatInt(int64(offset.Seconds()), 10) + "s",
+						"offset":        strconv.FormatInt(int64(-skew.Seconds()), 10) + "s",
 					})
 			}
 		}
 	}
 
+	if tracksRateLimit(r, oc) {
+		observeRateLimit(resp, rsc)
+	}
+
 	hasCustomResponseBody := false
 	resp.Header.Del(headers.NameContentLength)
 
@@ -241,6 +361,7 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 		// Since we are not responding with the actual upstream response body, close it here
 		resp.Body.Close()
 		rc = ioutil.NopCloser(bytes.NewReader(pc.ResponseBodyBytes))
+		originalLen = int64(len(pc.ResponseBodyBytes))
 	} else {
 		rc = resp.Body
 	}
@@ -248,9 +369,56 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 	return rc, resp, originalLen
 }
 
-// Respond sends an HTTP Response down to the requesting client
+// forwardTimeoutBudget sets the origin's timeout parameter to the request's remaining timeout budget,
+// less the origin's timeout margin, so that the origin abandons the query before Trickster does
+func forwardTimeoutBudget(r *http.Request, rsc *request.Resources) {
+	if rsc.TimeoutDeadline.IsZero() ||
+		r.Header.Get(headers.NameContentType) == headers.ValueApplicationJSON {
+		return
+	}
+	tp, ok := rsc.OriginClient.(origins.TimeoutParamer)
+	if !ok || tp.TimeoutParam() == "" {
+		return
+	}
+	remaining := time.Until(rsc.TimeoutDeadline) - rsc.OriginConfig.TimeoutMargin
+	if remaining < time.Millisecond {
+		remaining = time.Millisecond
+	}
+	qp, _, _ := params.GetRequestValues(r)
+	qp.Set(tp.TimeoutParam(), timeconv.FormatTimeout(remaining))
+	params.SetRequestValues(r, qp)
+}
+
+// cancelReadCloser releases a response's request context once its body is closed
+// withOriginTimeout returns a copy of the context that is canceled when the origin's timeout
+// elapses, which bounds the upstream requests made with contexts that are detached from their
+// client requests, such as fast forward and range fetches
+func withOriginTimeout(ctx context.Context, oc *oo.Options) (context.Context, context.CancelFunc) {
+	if oc == nil || oc.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, oc.Timeout)
+}
+
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelReadCloser) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// Respond sends an HTTP Response down to the requesting client. Since the entire body is
+// known in advance, it is sent with a Content-Length, rather than chunked
 func Respond(w io.Writer, code int, header http.Header, body []byte) {
-	PrepareResponseWriter(w, code, header)
+	contentLength := int64(-1)
+	if body != nil {
+		contentLength = int64(len(body))
+	}
+	prepareResponseWriter(w, code, header, contentLength)
 	w.Write(body)
 }
 
@@ -274,11 +442,16 @@ func recordResults(r *http.Request, engine string, cacheStatus status.LookupStat
 
 	if pc != nil && !pc.NoMetrics {
 		httpStatus := strconv.Itoa(statusCode)
-		metrics.ProxyRequestStatus.WithLabelValues(oc.Name, oc.OriginType, r.Method, status, httpStatus, path).Inc()
+		m := rsc.State().Metrics
+		m.ProxyRequestStatus.WithLabelValues(oc.Name, oc.OriginType, r.Method, status, httpStatus, path).Inc()
+		m.ProxyChainRequests.WithLabelValues(oc.Name, oc.OriginType,
+			strconv.Itoa(rsc.ChainDepth), status).Inc()
 		if elapsed > 0 {
-			metrics.ProxyRequestDuration.WithLabelValues(oc.Name, oc.OriginType,
+			m.ProxyRequestDuration.WithLabelValues(oc.Name, oc.OriginType,
 				r.Method, status, httpStatus, path).Observe(elapsed)
 		}
 	}
 	headers.SetResultsHeader(header, engine, status, ffStatus, extents)
+	chain.SetResponseHeader(header, rsc.UpstreamChain.Hops(), status,
+		time.Duration(elapsed*float64(time.Second)))
 }
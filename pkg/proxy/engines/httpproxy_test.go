@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,8 +29,11 @@ import (
 	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/proxy/timeconv"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
 )
@@ -88,6 +92,47 @@ func TestDoProxy(t *testing.T) {
 	}
 }
 
+func TestDoProxyOriginalQuery(t *testing.T) {
+
+	// the origin echoes the raw query string it received
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	defer es.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", es.URL, "-origin-type", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	oc := conf.Origins["default"]
+	oc.HTTPClient = http.DefaultClient
+	pc := po.NewOptions()
+
+	const query = "query=up%20time&end=2&start=1&_=12345"
+
+	tests := []struct {
+		requestParams map[string]string
+		expected      string
+	}{
+		{nil, query},
+		{map[string]string{"+step": "15"}, "_=12345&end=2&query=up+time&start=1&step=15"},
+	}
+
+	for i, test := range tests {
+		pc.RequestParams = test.requestParams
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", es.URL+"/?"+query, nil)
+		r = r.WithContext(tc.WithResources(r.Context(),
+			request.NewResources(oc, pc, nil, nil, nil, nil, testLogger)))
+		DoProxy(w, r, true)
+		if w.Body.String() != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, w.Body.String())
+		}
+	}
+}
+
 func TestProxyRequestBadGateway(t *testing.T) {
 
 	const badUpstream = "http://127.0.0.1:64389"
@@ -152,20 +197,35 @@ func TestClockOffsetWarning(t *testing.T) {
 	oc.HTTPClient = http.DefaultClient
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("GET", s.URL, nil)
+	logger, rec := tl.NewTestLogger("warn")
 	r = r.WithContext(tc.WithResources(r.Context(),
-		request.NewResources(oc, pc, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+		request.NewResources(oc, pc, nil, nil, nil, tu.NewTestTracer(), logger)))
 
-	if testLogger.HasWarnedOnce("clockoffset.default") {
+	if logger.HasWarnedOnce("clockoffset.default") {
 		t.Errorf("expected %t got %t", false, true)
 	}
 
 	DoProxy(w, r, true)
+	DoProxy(httptest.NewRecorder(), r, true)
 	resp := w.Result()
 
-	if !testLogger.HasWarnedOnce("clockoffset.default") {
+	if !logger.HasWarnedOnce("clockoffset.default") {
 		t.Errorf("expected %t got %t", true, false)
 	}
 
+	// the offset is warned once, although it was observed by both requests
+	warns := rec.Find("warn",
+		"clock offset between trickster host and origin is high and may cause data anomalies")
+	if len(warns) != 1 {
+		t.Errorf("expected %d got %d", 1, len(warns))
+	} else if warns[0].Pairs["originName"] != "default" {
+		t.Errorf("expected %s got %v", "default", warns[0].Pairs["originName"])
+	}
+
+	if skew := instance.Default.ClockSkews.Estimate("default"); skew >= -time.Minute {
+		t.Errorf("expected skew estimate below %s got %s", -time.Minute, skew)
+	}
+
 	err = testResultHeaderPartMatch(resp.Header, map[string]string{"engine": "HTTPProxy"})
 	if err != nil {
 		t.Error(err)
@@ -298,8 +358,78 @@ func TestPrepareFetchReaderErr(t *testing.T) {
 	r = r.WithContext(tc.WithResources(r.Context(),
 		request.NewResources(oc, nil, nil, nil, nil, tu.NewTestTracer(), testLogger)))
 	r.Method = "\t"
-	_, _, i := PrepareFetchReader(r)
-	if i != 0 {
-		t.Errorf("expected 0 got %d", i)
+	reader, resp, i := PrepareFetchReader(r)
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected %d got %d", http.StatusBadGateway, resp.StatusCode)
+	}
+	if reader == nil {
+		t.Fatal("expected error body reader")
+	}
+	b, _ := ioutil.ReadAll(reader)
+	if i != int64(len(b)) {
+		t.Errorf("expected %d got %d", len(b), i)
+	}
+	expected := `{"status":502,"code":"origin_unreachable",` +
+		`"message":"unable to get a response from the origin","origin":"default","traceId":"`
+	if !strings.HasPrefix(string(b), expected) {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+}
+
+type timeoutParamClient struct {
+	origins.Client
+}
+
+func (c *timeoutParamClient) TimeoutParam() string {
+	return "timeout"
+}
+
+func TestPrepareFetchReaderTimeoutBudget(t *testing.T) {
+
+	var upstreamTimeout string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamTimeout = r.URL.Query().Get("timeout")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer es.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", es.URL, "-origin-type", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	oc := conf.Origins["default"]
+	oc.HTTPClient = http.DefaultClient
+	oc.TimeoutMargin = time.Second
+
+	rsc := request.NewResources(oc, nil, nil, nil, &timeoutParamClient{}, nil, testLogger)
+	rsc.TimeoutDeadline = time.Now().Add(10 * time.Second)
+	r := httptest.NewRequest("GET", es.URL+"/?query=up&timeout=30", nil)
+	r = r.WithContext(tc.WithResources(r.Context(), rsc))
+
+	reader, _, _ := PrepareFetchReader(r)
+	if reader != nil {
+		reader.Close()
+	}
+
+	d, err := timeconv.ParseTimeout(upstreamTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d <= 8*time.Second || d > 9*time.Second {
+		t.Errorf("expected forwarded timeout between 8s and 9s got %s", upstreamTimeout)
+	}
+
+	// without a budget, the client's timeout param is forwarded as-is
+	r = httptest.NewRequest("GET", es.URL+"/?query=up&timeout=30", nil)
+	r = r.WithContext(tc.WithResources(r.Context(),
+		request.NewResources(oc, nil, nil, nil, &timeoutParamClient{}, nil, testLogger)))
+	reader, _, _ = PrepareFetchReader(r)
+	if reader != nil {
+		reader.Close()
+	}
+	if upstreamTimeout != "30" {
+		t.Errorf("expected %s got %s", "30", upstreamTimeout)
 	}
 }
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// reserveInflight reserves in-flight processing capacity for a document of size bytes, or of
+// unknown size when size is negative, before the request unmarshals or buffers it. A request made
+// on behalf of another request, such as a Fast Forward request, is accounted to the originating
+// request's reservation, since waiting for its own could deadlock with it. When the capacity
+// cannot be reserved, the returned response should be used in place of the upstream response
+func (pr *proxyRequest) reserveInflight(size int64) *http.Response {
+	if res := tctx.InflightReservation(pr.Request.Context()); res != nil {
+		pr.inflight = res
+		res.Add(size)
+		return nil
+	}
+	res, err := request.GetResources(pr.Request).State().Inflight.Acquire(pr.Request.Context(), size)
+	if err != nil {
+		pr.Logger.Warn("in-flight processing capacity is unavailable",
+			tl.Pairs{"url": pr.URL.String(), "detail": err.Error()})
+		return inflightLimitedResponse(pr.Request)
+	}
+	pr.inflight = res
+	pr.ownsInflight = true
+	return nil
+}
+
+// releaseInflight returns the request's in-flight processing capacity, once its buffers are freed.
+// A reservation shared from an originating request is released by that request
+func (pr *proxyRequest) releaseInflight() {
+	if pr.ownsInflight {
+		pr.inflight.Release()
+	}
+}
+
+// inflightDocumentSize returns the size of a cached document for reserving in-flight processing
+// capacity, which is the size of its timeseries when a memory cache holds it unmarshaled, or -1
+// when there is no document
+func inflightDocumentSize(d *HTTPDocument) int64 {
+	if d == nil {
+		return -1
+	}
+	if d.timeseries != nil {
+		return int64(d.timeseries.Size())
+	}
+	return int64(len(d.Body))
+}
+
+// inflightLimitedResponse returns a 503 response for a request that could not reserve in-flight
+// processing capacity before the timeout elapsed
+func inflightLimitedResponse(r *http.Request) *http.Response {
+	re := txe.NewResponseError(http.StatusServiceUnavailable, txe.CodeInflightLimit,
+		"in-flight processing capacity is exhausted").WithRequest(r)
+	b := re.Body()
+	return &http.Response{StatusCode: re.StatusCode, Request: r, Header: re.Header(),
+		Body: ioutil.NopCloser(bytes.NewReader(b)), ContentLength: int64(len(b))}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestInflightLimit(t *testing.T) {
+
+	instance.Default.Inflight.Configure(1000, 10*time.Millisecond)
+	defer instance.Default.Inflight.Configure(0, 0)
+
+	// a reservation holding the whole capacity causes requests to be rejected once they time out
+	held, err := instance.Default.Inflight.Acquire(context.Background(), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts, _, r, _, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	w := httptest.NewRecorder()
+	ObjectProxyCacheRequest(w, r)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected %d got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	if !strings.Contains(string(b), "inflight_processing_limit") {
+		t.Errorf("expected inflight_processing_limit error got %s", string(b))
+	}
+
+	ts2, w, r2, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts2.Close()
+	client := rsc.OriginClient.(*TestClient)
+	rsc.OriginConfig.FastForwardDisable = true
+	step := 300 * time.Second
+	end := time.Now().Add(-12 * time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-6 * time.Hour), End: end}
+	r2.URL.Path = "/prometheus/api/v1/query_range"
+	r2.URL.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	client.QueryRangeHandler(w, r2)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected %d got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	b, _ = ioutil.ReadAll(resp.Body)
+	if !strings.Contains(string(b), "inflight_processing_limit") {
+		t.Errorf("expected inflight_processing_limit error got %s", string(b))
+	}
+
+	// once the capacity is released, requests proceed, and release their own reservations
+	held.Release()
+
+	w = httptest.NewRecorder()
+	ObjectProxyCacheRequest(w, r)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Result().StatusCode)
+	}
+
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r2)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Result().StatusCode)
+	}
+
+	if n := instance.Default.Inflight.Used(); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+	if n := instance.Default.Inflight.Peak(); n < 3000 {
+		t.Errorf("expected at least %d got %d", 3000, n)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/key"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+)
+
+// uncachedHandlers are the names of the path handlers that never cache their responses
+var uncachedHandlers = map[string]bool{
+	"proxy":         true,
+	"health":        true,
+	"localresponse": true,
+}
+
+// InspectCacheKey returns the cache key under which the request's response would be cached,
+// without reading or writing the cache or contacting the origin. The request must have been
+// rewritten for its upstream, with its Resources in the context. Requests that the origin
+// parses as timeseries range queries are keyed as the DeltaProxyCache keys them, and others as
+// the ObjectProxyCache keys them. The key is empty for requests that would not be cached
+func InspectCacheKey(r *http.Request) string {
+	k, _ := InspectKeyInputs(r)
+	return k
+}
+
+// InspectKeyInputs returns the cache key of the request as InspectCacheKey does, and the Inputs
+// from which it is derived. The Inputs are nil for requests that would not be cached
+func InspectKeyInputs(r *http.Request) (string, *key.Inputs) {
+	rsc := request.GetResources(r)
+	if rsc == nil || rsc.OriginConfig == nil || rsc.PathConfig == nil ||
+		uncachedHandlers[rsc.PathConfig.HandlerName] || !authPermitsCaching(r) {
+		return "", nil
+	}
+	oc := rsc.OriginConfig
+	pr := newProxyRequest(r, nil)
+	if client, ok := rsc.OriginClient.(origins.TimeseriesClient); ok {
+		if trq, err := client.ParseTimeRangeQuery(r); err == nil {
+			ki := pr.keyInputs(trq.TemplateURL, stepKeyExtra(trq.Step))
+			return oc.CacheKeyPrefix + ".dpc." + ki.Derive(), ki
+		}
+	}
+	ki := pr.keyInputs(nil, "")
+	return oc.CacheKeyPrefix + ".opc." + ki.Derive(), ki
+}
@@ -0,0 +1,274 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+
+	tcache "github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// maxInvalidationTargets is the maximum number of cached timeseries documents whose queries are
+// tracked for each origin. When it is exceeded, the least recently written document is no longer
+// tracked, and can only expire by its TTL
+const maxInvalidationTargets = 100000
+
+// InvalidationResult counts the cached timeseries documents affected by an invalidation
+type InvalidationResult struct {
+	// Matched is the number of tracked documents whose query matched the invalidation
+	Matched int `json:"matched"`
+	// Removed is the number of documents that were removed from the cache, because they held
+	// no data outside of the invalidated extent
+	Removed int `json:"removed"`
+	// Truncated is the number of documents from which the invalidated extent was cropped
+	Truncated int `json:"truncated"`
+	// Unaffected is the number of matching documents holding no data in the invalidated extent,
+	// or which were no longer cached
+	Unaffected int `json:"unaffected"`
+}
+
+// invalidationTarget is a cached timeseries document, with the resources of the request that
+// wrote it, from which it can be read and rewritten outside of a request
+type invalidationTarget struct {
+	key   string
+	query string
+	rsc   *request.Resources
+}
+
+// invalidationIndex tracks the cached timeseries documents of an origin, by cache key, in the
+// order in which they were most recently written
+type invalidationIndex struct {
+	mtx     sync.Mutex
+	order   *list.List
+	targets map[string]*list.Element
+}
+
+// invalidationIndexKey identifies an origin by its name within the Instance that serves it
+type invalidationIndexKey struct {
+	instance   *instance.Instance
+	originName string
+}
+
+// invalidationIndexes are the invalidationIndex of each origin, by invalidationIndexKey
+var invalidationIndexes sync.Map
+
+func getInvalidationIndex(inst *instance.Instance, originName string) *invalidationIndex {
+	k := invalidationIndexKey{instance: instance.OrDefault(inst), originName: originName}
+	if v, ok := invalidationIndexes.Load(k); ok {
+		return v.(*invalidationIndex)
+	}
+	v, _ := invalidationIndexes.LoadOrStore(k,
+		&invalidationIndex{order: list.New(), targets: make(map[string]*list.Element)})
+	return v.(*invalidationIndex)
+}
+
+// recordInvalidationTarget tracks the document written to the cache key for the query, so that
+// it can be found and invalidated by the query's metric names
+func recordInvalidationTarget(rsc *request.Resources, key, query string) {
+	t := &invalidationTarget{key: key, query: query,
+		rsc: request.NewResources(rsc.OriginConfig, rsc.PathConfig, rsc.CacheConfig,
+			rsc.CacheClient, rsc.OriginClient, rsc.Tracer, rsc.Logger)}
+	t.rsc.Instance = rsc.Instance
+	idx := getInvalidationIndex(rsc.Instance, rsc.OriginConfig.Name)
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	if e, ok := idx.targets[key]; ok {
+		e.Value = t
+		idx.order.MoveToBack(e)
+		return
+	}
+	idx.targets[key] = idx.order.PushBack(t)
+	if idx.order.Len() > maxInvalidationTargets {
+		e := idx.order.Front()
+		idx.order.Remove(e)
+		delete(idx.targets, e.Value.(*invalidationTarget).key)
+	}
+}
+
+// match returns the tracked documents whose query is matched by the function
+func (idx *invalidationIndex) match(match func(query string) bool) []*invalidationTarget {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	targets := make([]*invalidationTarget, 0)
+	for e := idx.order.Front(); e != nil; e = e.Next() {
+		t := e.Value.(*invalidationTarget)
+		if match == nil || match(t.query) {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// forget stops tracking the document, unless it was rewritten since it was matched
+func (idx *invalidationIndex) forget(t *invalidationTarget) {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	if e, ok := idx.targets[t.key]; ok && e.Value == t {
+		idx.order.Remove(e)
+		delete(idx.targets, t.key)
+	}
+}
+
+// InvalidateTimeseries invalidates the extent of the cached timeseries documents of the origin
+// whose query is matched by the function, or of all of its documents when match is nil. Each
+// document holding data in the extent is removed from the cache when it holds no other data, or
+// is otherwise rewritten with the extent cropped from it, so that its next request fetches the
+// extent from the origin. The origin is that of the Instance, or of the Default Instance when it is nil
+func InvalidateTimeseries(inst *instance.Instance, originName string, match func(query string) bool,
+	e timeseries.Extent) (*InvalidationResult, error) {
+	if e.End.Before(e.Start) {
+		return nil, errors.New("invalid extent")
+	}
+	idx := getInvalidationIndex(inst, originName)
+	ir := &InvalidationResult{}
+	for _, t := range idx.match(match) {
+		ir.Matched++
+		removed, truncated, err := t.invalidate(e)
+		if err != nil {
+			t.rsc.Logger.Error("error invalidating cached timeseries",
+				tl.Pairs{"originName": originName, "cacheKey": t.key, "detail": err.Error()})
+			return ir, err
+		}
+		switch {
+		case removed:
+			ir.Removed++
+			idx.forget(t)
+		case truncated:
+			ir.Truncated++
+		default:
+			ir.Unaffected++
+		}
+	}
+	return ir, nil
+}
+
+// invalidate crops the extent from the target's document, under the lock of its cache key.
+// removed is true if the document was removed, or was no longer cached
+func (t *invalidationTarget) invalidate(e timeseries.Extent) (removed, truncated bool, err error) {
+
+	cache := t.rsc.CacheClient
+	client := t.rsc.OriginClient.(origins.TimeseriesClient)
+	oc := t.rsc.OriginConfig
+
+	lock, _ := cache.Locker().Acquire(t.key)
+	defer lock.Release()
+
+	ctx := tc.WithResources(context.Background(), t.rsc)
+	doc, lookupStatus, _, err := QueryCache(ctx, cache, t.key, nil)
+	if err != nil || lookupStatus != status.LookupStatusHit || doc == nil {
+		return true, false, nil
+	}
+
+	var ts timeseries.Timeseries
+	if t.rsc.CacheConfig.CacheType == "memory" {
+		ts = doc.timeseries
+	} else {
+		ts, err = client.UnmarshalTimeseries(doc.Body)
+	}
+	if err != nil || ts == nil {
+		// a document that cannot be decoded would be discarded by its next request
+		cache.Remove(t.key)
+		emitCacheEvent(t.rsc, cache, events.TypePurge, t.key, "invalidation", 0, nil)
+		return true, false, nil
+	}
+
+	kept, ok := cropExtent(ts, e)
+	if !ok {
+		return false, false, nil
+	}
+	if kept == nil {
+		cache.Remove(t.key)
+		emitCacheEvent(t.rsc, cache, events.TypePurge, t.key, "invalidation", 0, nil)
+		return true, false, nil
+	}
+
+	// the document may be shared by reference with concurrent readers of a memory cache,
+	// so a modified copy of it is written in its place
+	doc = doc.clone()
+	doc.ContentHash = timeseriesContentHash(client, kept)
+	if t.rsc.CacheConfig.CacheType == "memory" {
+		doc.timeseries = kept
+	} else {
+		if doc.Body, err = client.MarshalTimeseries(kept); err != nil {
+			return false, false, err
+		}
+	}
+	err = WriteCache(ctx, cache, t.key, doc, oc.TimeseriesTTL, oc.CompressableTypes)
+	if err == tcache.ErrReadOnly {
+		// the truncated document cannot be written, so it is removed rather than left to serve
+		// the invalidated range
+		cache.Remove(t.key)
+		emitCacheEvent(t.rsc, cache, events.TypePurge, t.key, "invalidation", 0, nil)
+		return true, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	// the event of a partial invalidation holds the invalidated time range
+	emitCacheEvent(t.rsc, cache, events.TypePurge, t.key, "invalidation", kept.Size(),
+		timeseries.ExtentList{e})
+	return false, true, nil
+}
+
+// cropExtent returns a copy of the timeseries without its data in the extent. ok is false when
+// the timeseries holds no data in the extent, and the returned timeseries is nil when it holds no
+// data outside of it
+func cropExtent(ts timeseries.Timeseries, e timeseries.Extent) (timeseries.Timeseries, bool) {
+	el := ts.Extents()
+	var overlaps bool
+	for _, x := range el {
+		if !x.End.Before(e.Start) && !x.Start.After(e.End) {
+			overlaps = true
+			break
+		}
+	}
+	if !overlaps {
+		return nil, false
+	}
+
+	// the data before and after the extent are cropped from separate copies, and then merged,
+	// leaving a gap in the extents of the result that its next request fetches from the origin
+	var kept timeseries.Timeseries
+	if first := el[0].Start; first.Before(e.Start) {
+		kept = ts.Clone()
+		kept.CropToRange(timeseries.Extent{Start: first, End: e.Start.Add(-1)})
+	}
+	if last := el[len(el)-1].End; last.After(e.End) {
+		after := ts.Clone()
+		after.CropToRange(timeseries.Extent{Start: e.End.Add(1), End: last})
+		if kept == nil {
+			kept = after
+		} else {
+			kept.Merge(true, after)
+		}
+	}
+	if kept == nil || len(kept.Extents()) == 0 {
+		return nil, true
+	}
+	return kept, true
+}
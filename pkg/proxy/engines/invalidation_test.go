@@ -0,0 +1,172 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockprom "github.com/tricksterproxy/mockster/pkg/mocks/prometheus"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+const queryInvalidated = "invalidated_query{latency_ms=0,range_latency_ms=0}"
+
+func TestInvalidateTimeseries(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	oc.FastForwardDisable = true
+	invalidationIndexes.Delete(oc.Name)
+
+	step := 300 * time.Second
+	end := time.Now().Add(-12 * time.Hour).Truncate(step)
+	extr := timeseries.Extent{Start: end.Add(-6 * time.Hour), End: end}
+
+	r.URL.Path = "/prometheus/api/v1/query_range"
+	r.URL.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryInvalidated)
+
+	request := func(expected string) {
+		t.Helper()
+		w = httptest.NewRecorder()
+		client.QueryRangeHandler(w, r)
+		if err := testResultHeaderPartMatch(w.Result().Header,
+			map[string]string{"status": expected}); err != nil {
+			t.Error(err)
+		}
+		// the object is written to cache in a separate goroutine from the response
+		time.Sleep(time.Millisecond * 10)
+	}
+	matches := func(name string) func(string) bool {
+		return func(query string) bool { return strings.Contains(query, name) }
+	}
+
+	request("kmiss")
+	request("hit")
+
+	// a query for another metric is not matched
+	ir, err := InvalidateTimeseries(nil, oc.Name, matches("other_query"), extr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ir.Matched != 0 {
+		t.Errorf("unexpected result %+v", ir)
+	}
+
+	// an extent outside of the document does not affect it
+	ir, err = InvalidateTimeseries(nil, oc.Name, matches("invalidated_query"),
+		timeseries.Extent{Start: end.Add(time.Hour), End: end.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ir.Matched != 1 || ir.Unaffected != 1 {
+		t.Errorf("unexpected result %+v", ir)
+	}
+	request("hit")
+
+	// an extent in the middle of the document is cropped from it, and fetched again
+	ir, err = InvalidateTimeseries(nil, oc.Name, matches("invalidated_query"),
+		timeseries.Extent{Start: extr.Start.Add(time.Hour), End: extr.Start.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ir.Matched != 1 || ir.Truncated != 1 {
+		t.Errorf("unexpected result %+v", ir)
+	}
+	request("phit")
+	request("hit")
+
+	// an extent covering the document removes it
+	ir, err = InvalidateTimeseries(nil, oc.Name, matches("invalidated_query"),
+		timeseries.Extent{Start: extr.Start.Add(-time.Hour), End: extr.End.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ir.Matched != 1 || ir.Removed != 1 {
+		t.Errorf("unexpected result %+v", ir)
+	}
+	request("kmiss")
+
+	if _, err = InvalidateTimeseries(nil, oc.Name, nil,
+		timeseries.Extent{Start: extr.End, End: extr.Start}); err == nil {
+		t.Error("expected error for invalid extent")
+	}
+}
+
+func TestCropExtent(t *testing.T) {
+
+	step := time.Minute
+	t0 := time.Unix(1600000000, 0).Truncate(step)
+	body, _, _ := mockprom.GetTimeSeriesData(queryInvalidated, t0, t0.Add(10*step), step)
+	newTimeseries := func() timeseries.Timeseries {
+		ts, err := (&TestClient{}).UnmarshalTimeseries([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts.SetStep(step)
+		ts.SetExtents(timeseries.ExtentList{{Start: t0, End: t0.Add(10 * step)}})
+		return ts
+	}
+
+	tests := []struct {
+		e        timeseries.Extent
+		ok       bool
+		expected timeseries.ExtentList
+	}{
+		{timeseries.Extent{Start: t0.Add(20 * step), End: t0.Add(30 * step)}, false, nil},
+		{timeseries.Extent{Start: t0.Add(-step), End: t0.Add(20 * step)}, true, nil},
+		{timeseries.Extent{Start: t0.Add(8 * step), End: t0.Add(20 * step)}, true,
+			timeseries.ExtentList{{Start: t0, End: t0.Add(8*step - 1)}}},
+		{timeseries.Extent{Start: t0.Add(-step), End: t0.Add(2 * step)}, true,
+			timeseries.ExtentList{{Start: t0.Add(2*step + 1), End: t0.Add(10 * step)}}},
+		{timeseries.Extent{Start: t0.Add(4 * step), End: t0.Add(5 * step)}, true,
+			timeseries.ExtentList{{Start: t0, End: t0.Add(4*step - 1)},
+				{Start: t0.Add(5*step + 1), End: t0.Add(10 * step)}}},
+	}
+
+	for i, test := range tests {
+		ts := newTimeseries()
+		kept, ok := cropExtent(ts, test.e)
+		if ok != test.ok {
+			t.Errorf("(%d) expected %t got %t", i, test.ok, ok)
+			continue
+		}
+		if test.expected == nil {
+			if kept != nil {
+				t.Errorf("(%d) expected nil got %v", i, kept.Extents())
+			}
+			continue
+		}
+		if kept.Extents().String() != test.expected.String() {
+			t.Errorf("(%d) expected %v got %v", i, test.expected, kept.Extents())
+		}
+		// the original timeseries is not modified
+		if el := ts.Extents(); len(el) != 1 || !el[0].End.Equal(t0.Add(10*step)) {
+			t.Errorf("(%d) unexpected modification %v", i, el)
+		}
+	}
+}
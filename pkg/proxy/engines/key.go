@@ -21,28 +21,52 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/key"
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
 	"github.com/tricksterproxy/trickster/pkg/proxy/params"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 	"github.com/tricksterproxy/trickster/pkg/util/md5"
 )
 
 // DeriveCacheKey calculates a query-specific keyname based on the prometheus query in the user request
 func (pr *proxyRequest) DeriveCacheKey(templateURL *url.URL, extra string) string {
+	ki := pr.keyInputs(templateURL, extra)
+	k := ki.Derive()
+	if pr.Logger != nil {
+		pr.Logger.Trace("derived cache key", tl.Pairs{"cacheKey": k, "keyInputs": ki.String()})
+	}
+	return k
+}
+
+// keyInputs returns the Inputs from which the request's cache key is derived. Hop-by-hop
+// headers, and any headers or params that are set for the upstream request by the path's
+// request_headers and request_params, are never included, so that credentials injected for
+// the origin cannot affect, or be exposed by, the keys of the requests that carry them
+func (pr *proxyRequest) keyInputs(templateURL *url.URL, extra string) *key.Inputs {
+
+	ki := &key.Inputs{URLPath: pr.URL.Path, Extra: extra}
 
 	rsc := request.GetResources(pr.Request)
 	pc := rsc.PathConfig
+	if rsc.OriginConfig != nil {
+		ki.Origin = rsc.OriginConfig.Name
+	}
 
 	if pc == nil {
-		return md5.Checksum(pr.URL.Path + extra)
+		return ki
 	}
+	ki.Path = pc.Path
 
 	var qp url.Values
 	r := pr.Request
@@ -64,36 +88,74 @@ func (pr *proxyRequest) DeriveCacheKey(templateURL *url.URL, extra string) strin
 		b = []byte(s)
 	}
 
-	if pc.KeyHasher != nil && len(pc.KeyHasher) == 1 {
-		var k string
-		k, r.Body = pc.KeyHasher[0](r.URL.Path, qp, r.Header, r.Body, extra)
-		return k
+	injectedHeaders := injectedNames(pc.RequestHeaders, http.CanonicalHeaderKey)
+	h := make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		if !headers.IsHopByHop(k) && !injectedHeaders[http.CanonicalHeaderKey(k)] {
+			h[k] = v
+		}
+	}
+
+	// under the per-identity policy, authenticated requests are only keyed to their own requestor
+	if authCachePolicy(pr.Request) == authcache.PolicyPerIdentity {
+		kr := *r
+		kr.Header = h
+		ki.Identity = authcache.Identity(&kr, pc.CacheIdentityHeader)
+	}
+
+	if pc.KeyProvider != nil && len(pc.KeyProvider) == 1 {
+		ki.URLPath = r.URL.Path
+		if r.Body != nil {
+			b, _ = ioutil.ReadAll(r.Body)
+			r.Body = ioutil.NopCloser(bytes.NewReader(b))
+		}
+		pc.KeyProvider[0](r.URL.Path, b, ki)
+		return ki
 	}
 
-	vals := make([]string, 0, (len(pc.CacheKeyParams) + len(pc.CacheKeyHeaders) + len(pc.CacheKeyFormFields)*2))
+	if ki.Identity == "" {
+		ki.Authorization = h.Get(headers.NameAuthorization)
+	}
 
-	if v := r.Header.Get(headers.NameAuthorization); v != "" {
-		vals = append(vals, fmt.Sprintf("%s.%s.", headers.NameAuthorization, v))
+	// HEAD requests share the cache object of the equivalent GET request, from which their
+	// headers are served
+	ki.Method = r.Method
+	if ki.Method == http.MethodHead {
+		ki.Method = http.MethodGet
 	}
 
-	// Append the http method to the slice for creating the derived cache key
-	vals = append(vals, fmt.Sprintf("%s.%s.", "method", r.Method))
+	// parameters are already decoded, so the key is independent of their encoding, and
+	// of their ordering, since the key components are sorted when the key is derived
+	excludes := pc.CacheKeyExcludeParams
+	if excludes == nil {
+		excludes = d.DefaultCacheKeyExcludeParams()
+	}
+	excluded := injectedNames(pc.RequestParams, nil)
+	for _, p := range excludes {
+		excluded[p] = true
+	}
 
 	if len(pc.CacheKeyParams) == 1 && pc.CacheKeyParams[0] == "*" {
 		for p := range qp {
-			vals = append(vals, fmt.Sprintf("%s.%s.", p, qp.Get(p)))
+			if !excluded[p] {
+				ki.Params = append(ki.Params, key.Component{Name: p, Value: params.CacheKeyValue(qp, p)})
+			}
 		}
+		sort.Slice(ki.Params, func(i, j int) bool { return ki.Params[i].Name < ki.Params[j].Name })
 	} else {
 		for _, p := range pc.CacheKeyParams {
-			if v := qp.Get(p); v != "" {
-				vals = append(vals, fmt.Sprintf("%s.%s.", p, v))
+			if excluded[p] {
+				continue
+			}
+			if v := params.CacheKeyValue(qp, p); v != "" {
+				ki.Params = append(ki.Params, key.Component{Name: p, Value: v})
 			}
 		}
 	}
 
 	for _, p := range pc.CacheKeyHeaders {
-		if v := r.Header.Get(p); v != "" {
-			vals = append(vals, fmt.Sprintf("%s.%s.", p, v))
+		if v := h.Get(p); v != "" {
+			ki.Headers = append(ki.Headers, key.Component{Name: p, Value: v})
 		}
 	}
 
@@ -124,14 +186,31 @@ func (pr *proxyRequest) DeriveCacheKey(templateURL *url.URL, extra string) strin
 		for _, f := range pc.CacheKeyFormFields {
 			if _, ok := pr.Form[f]; ok {
 				if v := pr.FormValue(f); v != "" {
-					vals = append(vals, fmt.Sprintf("%s.%s.", f, v))
+					ki.FormFields = append(ki.FormFields, key.Component{Name: f, Value: v})
 				}
 			}
 		}
 	}
 
-	sort.Strings(vals)
-	return md5.Checksum(pr.URL.Path + "." + strings.Join(vals, "") + extra)
+	if pr.isCacheablePost {
+		ki.BodyHash = md5.Checksum(string(pr.postBody))
+	}
+
+	return ki
+}
+
+// injectedNames returns the set of names that are set, added or removed by the updates, which
+// are request_headers or request_params, optionally normalized by the provided function
+func injectedNames(updates map[string]string, normalize func(string) string) map[string]bool {
+	names := make(map[string]bool, len(updates))
+	for k := range updates {
+		k = strings.TrimLeft(k, "+-")
+		if normalize != nil {
+			k = normalize(k)
+		}
+		names[k] = true
+	}
+	return names
 }
 
 func deepSearch(document map[string]interface{}, key string) (string, error) {
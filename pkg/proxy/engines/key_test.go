@@ -20,21 +20,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"net/url"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/tricksterproxy/trickster/pkg/cache/key"
 	ct "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
 )
 
@@ -186,11 +187,12 @@ func TestDeriveCacheKey(t *testing.T) {
 		t.Errorf("expected %s got %s", "82c1d86126a02b96b8d0fcb94a9f486a", ck)
 	}
 
-	// Test Custom KeyHasher Integration
-	rpath.KeyHasher = []key.HasherFunc{exampleKeyHasher}
+	// Test Custom KeyProvider Integration
+	rpath.KeyProvider = []key.ProviderFunc{exampleKeyProvider}
+	providedKey := md5.Checksum(pr.upstreamRequest.URL.Path + "test-keyextra")
 	ck = pr.DeriveCacheKey(nil, "extra")
-	if ck != "test-key" {
-		t.Errorf("expected %s got %s", "test-key", ck)
+	if ck != providedKey {
+		t.Errorf("expected %s got %s", providedKey, ck)
 	}
 
 	tr = httptest.NewRequest(http.MethodPost, "http://127.0.0.1/", nil)
@@ -200,15 +202,15 @@ func TestDeriveCacheKey(t *testing.T) {
 	tr.Header.Set(headers.NameContentLength, strconv.Itoa(len(testJSONDocument)))
 	pr = newProxyRequest(tr, nil)
 	pr.upstreamRequest.URL = nil
+	providedKey = md5.Checksum("/test-keyextra")
 	ck = pr.DeriveCacheKey(nil, "extra")
-	if ck != "test-key" {
-		t.Errorf("expected %s got %s", expected, ck)
+	if ck != providedKey {
+		t.Errorf("expected %s got %s", providedKey, ck)
 	}
 }
 
-func exampleKeyHasher(path string, params url.Values, headers http.Header,
-	body io.ReadCloser, extra string) (string, io.ReadCloser) {
-	return "test-key", nil
+func exampleKeyProvider(path string, body []byte, ki *key.Inputs) {
+	ki.Extras = append(ki.Extras, "test-key")
 }
 
 func TestDeriveCacheKeyAuthHeader(t *testing.T) {
@@ -243,6 +245,72 @@ func TestDeriveCacheKeyAuthHeader(t *testing.T) {
 
 }
 
+func TestDeriveCacheKeyNormalization(t *testing.T) {
+
+	variants := []string{
+		"query=up%20time&start=1&end=2&match[]=a&match[]=b",
+		"end=2&start=1&query=up+time&match[]=a&match[]=b",
+		"match%5B%5D=a&query=up%20time&match%5b%5d=b&end=2&start=1",
+		"query=%75p+time&start=1&end=2&match[]=a&match[]=b&_=1594848638250",
+	}
+
+	tests := []struct {
+		name           string
+		cacheKeyParams []string
+		excludes       []string
+		distinct       []string
+	}{
+		{
+			name:           "explicit",
+			cacheKeyParams: []string{"query", "start", "end", "match[]"},
+			distinct: []string{"query=up&start=1&end=2&match[]=a&match[]=b",
+				"query=up+time&start=1&end=2&match[]=b&match[]=a"},
+		},
+		{
+			name:           "wildcard",
+			cacheKeyParams: []string{"*"},
+			distinct: []string{"query=up+time&start=1&end=2&match[]=a",
+				"query=up+time&start=1&end=2&match[]=a&match[]=b&x=1"},
+		},
+		{
+			name:           "custom excludes",
+			cacheKeyParams: []string{"*"},
+			excludes:       []string{"_", "x"},
+			distinct:       []string{"query=up+time&start=1&end=3&match[]=a&match[]=b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pc := &po.Options{Path: "/", CacheKeyParams: test.cacheKeyParams,
+				CacheKeyExcludeParams: test.excludes}
+			oc := &oo.Options{Paths: map[string]*po.Options{"root": pc}}
+			deriveKey := func(query string) string {
+				r := httptest.NewRequest("GET", "http://127.0.0.1/?"+query, nil)
+				r = r.WithContext(ct.WithResources(context.Background(),
+					request.NewResources(oc, pc, nil, nil, nil, nil, tl.ConsoleLogger("error"))))
+				return newProxyRequest(r, nil).DeriveCacheKey(nil, "")
+			}
+			expected := deriveKey(variants[0])
+			for _, v := range variants[1:] {
+				if k := deriveKey(v); k != expected {
+					t.Errorf("expected %s got %s for %s", expected, k, v)
+				}
+			}
+			if test.excludes != nil {
+				if k := deriveKey(variants[1] + "&x=1"); k != expected {
+					t.Errorf("expected %s got %s", expected, k)
+				}
+			}
+			for _, v := range test.distinct {
+				if k := deriveKey(v); k == expected {
+					t.Errorf("expected a distinct key for %s", v)
+				}
+			}
+		})
+	}
+}
+
 func TestDeriveCacheKeyNoPathConfig(t *testing.T) {
 
 	client := &TestClient{
@@ -282,3 +350,51 @@ func TestDeriveCacheKeyNilURL(t *testing.T) {
 		t.Errorf("unexpected cache key: %s", k)
 	}
 }
+
+func TestKeyInputsExcludeInjectedAndHopByHop(t *testing.T) {
+
+	pc := &po.Options{
+		Path:            "/",
+		CacheKeyParams:  []string{"*"},
+		CacheKeyHeaders: []string{"X-Test-Header", "X-Api-Key", headers.NameConnection, headers.NameKeepAlive},
+		RequestHeaders: map[string]string{
+			headers.NameAuthorization: "Bearer injected-secret",
+			"+X-Api-Key":              "injected-secret",
+		},
+		RequestParams: map[string]string{"api_key": "injected-secret"},
+	}
+	oc := &oo.Options{Name: "test", Paths: map[string]*po.Options{"root": pc}}
+
+	tr := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/?query=up", nil)
+	tr = tr.WithContext(ct.WithResources(context.Background(),
+		request.NewResources(oc, pc, nil, nil, nil, nil, tl.ConsoleLogger("error"))))
+	tr.Header.Set("X-Test-Header", "test")
+	tr.Header.Set(headers.NameConnection, "keep-alive")
+	tr.Header.Set(headers.NameKeepAlive, "timeout=5")
+
+	pr := newProxyRequest(tr, nil)
+	expected := pr.DeriveCacheKey(nil, "")
+
+	// the key is unchanged once the path's credentials are injected into the upstream request
+	headers.UpdateHeaders(pr.upstreamRequest.Header, pc.RequestHeaders)
+	qp := pr.upstreamRequest.URL.Query()
+	params.UpdateParams(qp, pc.RequestParams)
+	pr.upstreamRequest.URL.RawQuery = qp.Encode()
+
+	ki := pr.keyInputs(nil, "")
+	if k := ki.Derive(); k != expected {
+		t.Errorf("expected %s got %s", expected, k)
+	}
+	if strings.Contains(ki.String(), "injected-secret") || ki.Authorization != "" {
+		t.Errorf("unexpected injected credentials in key inputs %s", ki.String())
+	}
+	if ki.Origin != "test" || ki.Path != "/" || ki.Method != http.MethodGet {
+		t.Errorf("unexpected key inputs %s", ki.String())
+	}
+	if len(ki.Params) != 1 || ki.Params[0].Name != "query" {
+		t.Errorf("unexpected params %v", ki.Params)
+	}
+	if len(ki.Headers) != 1 || ki.Headers[0].Name != "X-Test-Header" {
+		t.Errorf("unexpected headers %v", ki.Headers)
+	}
+}
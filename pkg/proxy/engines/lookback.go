@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/lookback"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// maxLookback returns the max lookback of the request, which is that assigned by a rule when
+// present, then that of the request's tenant, and otherwise that of the request's path
+func maxLookback(r *http.Request) time.Duration {
+	if d, ok := tctx.MaxLookback(r.Context()); ok {
+		return d
+	}
+	rsc := request.GetResources(r)
+	if rsc == nil || rsc.PathConfig == nil {
+		return 0
+	}
+	pc := rsc.PathConfig
+	if len(pc.MaxLookbackTenants) > 0 {
+		if tenant := lookback.Tenant(r, pc.MaxLookbackTenantHeader,
+			pc.MaxLookbackTenantClaim); tenant != "" {
+			if secs, ok := pc.MaxLookbackTenants[tenant]; ok {
+				return time.Duration(secs) * time.Second
+			}
+		}
+	}
+	return time.Duration(pc.MaxLookbackSecs) * time.Second
+}
+
+// enforceMaxLookback enforces the max lookback of a timeseries request before it is serviced.
+// A request whose time range starts before the oldest permitted time is either clamped, by
+// rewriting its start to the oldest permitted time so that its cache key and upstream requests
+// are derived from the clamped range, or rejected. It returns false when the request was
+// rejected and a response was written, in which case the request must not be serviced
+func enforceMaxLookback(w http.ResponseWriter, r *http.Request) bool {
+	limit := maxLookback(r)
+	if limit <= 0 {
+		return true
+	}
+	rsc := request.GetResources(r)
+	client, ok := rsc.OriginClient.(origins.TimeseriesClient)
+	if !ok {
+		return true
+	}
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		return true
+	}
+	oldest := time.Now().Add(-limit)
+	if !trq.Extent.Start.Before(oldest) {
+		return true
+	}
+	if rsc.PathConfig.MaxLookbackAction == lookback.ActionReject || trq.Extent.End.Before(oldest) {
+		txe.NewResponseError(http.StatusForbidden, txe.CodeMaxLookbackExceeded,
+			fmt.Sprintf("the requested time range exceeds the max lookback of %s", limit)).Respond(w, r)
+		return false
+	}
+	start := trq.AlignTime(oldest)
+	if start.Before(oldest) {
+		start = trq.NextStep(start)
+	}
+	if start.After(trq.Extent.End) {
+		start = trq.Extent.End
+	}
+	client.SetExtent(r, trq, &timeseries.Extent{Start: start, End: trq.Extent.End})
+	w.Header().Set(headers.NameTricksterLookback, fmt.Sprintf("clamped; start=%d", start.Unix()))
+	return true
+}
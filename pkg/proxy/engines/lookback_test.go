@@ -0,0 +1,173 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/lookback"
+)
+
+func TestEnforceMaxLookback(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	pc := rsc.PathConfig
+	step := time.Duration(60) * time.Second
+	now := time.Now()
+
+	newRequest := func(start, end time.Time) *http.Request {
+		req := r.Clone(r.Context())
+		req.URL.Path = "/prometheus/api/v1/query_range"
+		req.URL.RawQuery = url.Values{"query": {"some_query_here"}, "step": {fmt.Sprint(int(step.Seconds()))},
+			"start": {fmt.Sprint(start.Unix())}, "end": {fmt.Sprint(end.Unix())}}.Encode()
+		return req
+	}
+
+	// no max lookback is unlimited
+	req := newRequest(now.Add(-time.Duration(48)*time.Hour), now)
+	w := httptest.NewRecorder()
+	if !enforceMaxLookback(w, req) || w.Header().Get(headers.NameTricksterLookback) != "" {
+		t.Error("expected request to pass unchanged")
+	}
+
+	pc.MaxLookbackSecs = 3600
+
+	// requests within the max lookback are unchanged
+	req = newRequest(now.Add(-time.Duration(30)*time.Minute), now)
+	rawQuery := req.URL.RawQuery
+	w = httptest.NewRecorder()
+	if !enforceMaxLookback(w, req) || req.URL.RawQuery != rawQuery {
+		t.Error("expected request to pass unchanged")
+	}
+
+	// requests beyond the max lookback are clamped to the first step within it
+	req = newRequest(now.Add(-time.Duration(2)*time.Hour), now)
+	w = httptest.NewRecorder()
+	if !enforceMaxLookback(w, req) {
+		t.Fatal("expected request to be clamped")
+	}
+	start := req.URL.Query().Get("start")
+	expected := fmt.Sprintf("clamped; start=%s", start)
+	if h := w.Header().Get(headers.NameTricksterLookback); h != expected {
+		t.Errorf("expected %s got %s", expected, h)
+	}
+	var secs int64
+	fmt.Sscan(start, &secs)
+	if oldest := now.Add(-time.Hour).Unix(); secs < oldest || secs >= oldest+int64(step.Seconds()) ||
+		secs%int64(step.Seconds()) != 0 {
+		t.Errorf("unexpected clamped start %d", secs)
+	}
+
+	// requests that end beyond the max lookback are rejected
+	req = newRequest(now.Add(-time.Duration(3)*time.Hour), now.Add(-time.Duration(2)*time.Hour))
+	w = httptest.NewRecorder()
+	if enforceMaxLookback(w, req) {
+		t.Error("expected request to be rejected")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected %d got %d", http.StatusForbidden, w.Code)
+	}
+
+	// as are all requests beyond the max lookback with the reject action
+	pc.MaxLookbackAction = lookback.ActionReject
+	req = newRequest(now.Add(-time.Duration(2)*time.Hour), now)
+	w = httptest.NewRecorder()
+	if enforceMaxLookback(w, req) {
+		t.Error("expected request to be rejected")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected %d got %d", http.StatusForbidden, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"max_lookback_exceeded"`) {
+		t.Errorf("unexpected body %s", w.Body.String())
+	}
+
+	// the tenant's max lookback overrides the path's
+	pc.MaxLookbackTenantHeader = "X-Tenant"
+	pc.MaxLookbackTenants = map[string]int{"unlimited": 0, "limited": 3600}
+	req = newRequest(now.Add(-time.Duration(2)*time.Hour), now)
+	req.Header.Set("X-Tenant", "unlimited")
+	w = httptest.NewRecorder()
+	if !enforceMaxLookback(w, req) {
+		t.Error("expected request to pass")
+	}
+	req.Header.Set("X-Tenant", "limited")
+	if enforceMaxLookback(httptest.NewRecorder(), req) {
+		t.Error("expected request to be rejected")
+	}
+
+	// and a rule's max lookback overrides the tenant's
+	req = req.WithContext(tctx.WithMaxLookback(req.Context(), time.Duration(3)*time.Hour))
+	if !enforceMaxLookback(httptest.NewRecorder(), req) {
+		t.Error("expected request to pass")
+	}
+}
+
+func TestDeltaProxyCacheRequestMaxLookback(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	rsc.PathConfig.CacheKeyParams = []string{upQuery, upStep}
+	rsc.PathConfig.MaxLookbackSecs = 3600
+
+	step := time.Duration(60) * time.Second
+	end := time.Now().Truncate(step)
+	req := r.Clone(r.Context())
+	req.URL.Path = "/prometheus/api/v1/query_range"
+	req.URL.RawQuery = url.Values{"query": {"some_query_here{latency_ms=0,range_latency_ms=0,series_id=1}"},
+		"step": {fmt.Sprint(int(step.Seconds()))}, "start": {fmt.Sprint(end.Add(-time.Duration(6) * time.Hour).Unix())},
+		"end": {fmt.Sprint(end.Unix())}}.Encode()
+
+	w := httptest.NewRecorder()
+	client.QueryRangeHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if h := w.Header().Get(headers.NameTricksterLookback); !strings.HasPrefix(h, "clamped; start=") {
+		t.Errorf("expected clamped got %s", h)
+	}
+
+	// the response holds only the clamped range
+	me, err := client.UnmarshalTimeseries(w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldest := end.Add(-time.Hour)
+	for _, ss := range me.(*MatrixEnvelope).Data.Result {
+		if len(ss.Values) == 0 || ss.Values[0].Timestamp.Time().Before(oldest) {
+			t.Errorf("unexpected values %v", ss.Values)
+		}
+	}
+}
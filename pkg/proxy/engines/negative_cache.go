@@ -0,0 +1,138 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+)
+
+// negativeStaleFactor is the multiple of a Negative Cache entry's TTL for which it is retained in
+// the cache, so that it can be served to other requests while a single request refreshes it
+const negativeStaleFactor = 2
+
+// negativeRefreshes holds the cache keys of the expired Negative Cache entries being refreshed
+var negativeRefreshes sync.Map
+
+// negativeRefresh describes the expired Negative Cache entry that a request is refreshing
+type negativeRefresh struct {
+	// ttl is the TTL of the expired entry
+	ttl time.Duration
+	// statusCode is the status code of the expired entry
+	statusCode int
+	// stored indicates that the refreshed response was written to the cache
+	stored bool
+}
+
+// negativeCache returns the Negative Cache map that applies to the request,
+// which is the Path's when configured, and otherwise the Origin's
+func negativeCache(rsc *request.Resources) map[int]time.Duration {
+	if rsc == nil {
+		return nil
+	}
+	if rsc.PathConfig != nil && rsc.PathConfig.NegativeCache != nil {
+		return rsc.PathConfig.NegativeCache
+	}
+	if rsc.OriginConfig != nil {
+		return rsc.OriginConfig.NegativeCache
+	}
+	return nil
+}
+
+// bypassesNegativeCache returns true if the request includes the X-Trickster-Revalidate
+// header and the origin permits it to skip reads from the Negative Cache
+func bypassesNegativeCache(r *http.Request) bool {
+	rsc := request.GetResources(r)
+	return rsc != nil && rsc.OriginConfig != nil && rsc.OriginConfig.NegativeCacheAllowRevalidate &&
+		r.Header.Get(headers.NameTricksterRevalidate) != ""
+}
+
+// claimNegativeRefresh returns true if the request is to refresh the expired Negative Cache entry
+// that it found, which is the case unless another request is already refreshing it
+func (pr *proxyRequest) claimNegativeRefresh() bool {
+	if _, loaded := negativeRefreshes.LoadOrStore(pr.key, struct{}{}); loaded {
+		return false
+	}
+	pr.negativeRefresh = &negativeRefresh{statusCode: pr.cacheDocument.StatusCode,
+		ttl: time.Duration(pr.cachingPolicy.FreshnessLifetime) * time.Second}
+	return true
+}
+
+// finishNegativeRefresh ends the request's refresh of an expired Negative Cache entry. When the
+// origin no longer responds negatively, the backoff is reset, and an expired entry that was not
+// replaced by the response is removed, so that the next negative response starts from its base TTL
+func (pr *proxyRequest) finishNegativeRefresh() {
+	nr := pr.negativeRefresh
+	if nr == nil {
+		return
+	}
+	defer negativeRefreshes.Delete(pr.key)
+	if pr.cachingPolicy.IsNegativeCache {
+		return
+	}
+	rsc := request.GetResources(pr.Request)
+	if !nr.stored {
+		rsc.CacheClient.Remove(pr.key)
+		emitCacheEvent(rsc, rsc.CacheClient, events.TypePurge, pr.key, "negative-refresh", 0, nil)
+	}
+	if ttl, ok := negativeCache(rsc)[nr.statusCode]; ok {
+		recordNegativeCacheTTL(rsc, nr.statusCode, ttl)
+	}
+}
+
+// negativeCacheTTL returns the cache TTL of a Negative Cache entry with the status code, which
+// retains it beyond its freshness lifetime so that it can be served while it is refreshed. When
+// the entry replaces an expired one, its freshness lifetime is first extended to that of the
+// expired entry times the origin's backoff multiplier, up to the backoff's cap
+func (pr *proxyRequest) negativeCacheTTL(rsc *request.Resources, code int) time.Duration {
+	cp := pr.cachingPolicy
+	oc := rsc.OriginConfig
+	ttl := time.Duration(cp.FreshnessLifetime) * time.Second
+	if nr := pr.negativeRefresh; nr != nil && oc.NegativeCacheBackoffMultiplier > 1 {
+		bt := time.Duration(float64(nr.ttl) * oc.NegativeCacheBackoffMultiplier).Truncate(time.Second)
+		if bt > oc.NegativeCacheBackoffMaxTTL {
+			bt = oc.NegativeCacheBackoffMaxTTL
+		}
+		if bt > ttl {
+			ttl = bt
+			cp.FreshnessLifetime = int(ttl.Seconds())
+			cp.Expires = cp.LocalDate.Add(ttl)
+		}
+	}
+	recordNegativeCacheTTL(rsc, code, ttl)
+	if ttl *= negativeStaleFactor; ttl > oc.MaxTTL {
+		ttl = oc.MaxTTL
+	}
+	return ttl
+}
+
+// recordNegativeCacheTTL records the effective TTL of the Negative Cache entries of the request's
+// path for the status code
+func recordNegativeCacheTTL(rsc *request.Resources, code int, ttl time.Duration) {
+	var path string
+	if rsc.PathConfig != nil {
+		path = rsc.PathConfig.Path
+	}
+	rsc.State().Metrics.ProxyNegativeCacheTTL.WithLabelValues(rsc.OriginConfig.Name,
+		rsc.OriginConfig.OriginType, path, strconv.Itoa(code)).Set(ttl.Seconds())
+}
@@ -24,11 +24,14 @@ import (
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/proxy/background"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tspan "github.com/tricksterproxy/trickster/pkg/tracing/span"
 	"github.com/tricksterproxy/trickster/pkg/util/log"
@@ -128,6 +131,46 @@ func confirmTrueCacheHit(pr *proxyRequest) (bool, error) {
 
 	pr.cachingPolicy.Merge(pr.cacheDocument.CachingPolicy)
 
+	// a client-requested revalidation treats a Negative Cache entry as a miss,
+	// so the fresh upstream response replaces it in the cache
+	if pr.cachingPolicy.IsNegativeCache && pr.bypassNegativeCache {
+		pr.cachingPolicy.IsNegativeCache = false
+		pr.cacheDocument = nil
+		pr.cacheStatus = status.LookupStatusKeyMiss
+		return false, handleCacheKeyMiss(pr)
+	}
+
+	// an expired Negative Cache entry is refreshed by a single request, which fetches without holding
+	// the key's lock, while other requests are served the expired entry until it is replaced
+	if pr.cachingPolicy.IsNegativeCache && !pr.checkCacheFreshness() {
+		if !pr.claimNegativeRefresh() {
+			pr.servedStale = true
+			return true, nil
+		}
+		if pr.hasReadLock {
+			pr.cacheLock.RRelease()
+			pr.hasReadLock = false
+		}
+		pr.cachingPolicy.IsNegativeCache = false
+		pr.cacheDocument = nil
+		pr.cacheStatus = status.LookupStatusKeyMiss
+		return false, handleCacheKeyMiss(pr)
+	}
+
+	// a document produced under a result limit can't satisfy a request for more results
+	if !pr.cacheDocument.CoversResultLimit(pr.resultLimit) {
+		pr.cacheDocument = nil
+		pr.cacheStatus = status.LookupStatusKeyMiss
+		return false, handleCacheKeyMiss(pr)
+	}
+
+	// while the origin's rate limit is low, an expired object is served rather than revalidated
+	if !pr.checkCacheFreshness() && pr.cacheStatus == status.LookupStatusHit &&
+		!pr.cachingPolicy.IsNegativeCache && servesStale(pr.Request, request.GetResources(pr.Request)) {
+		pr.servedStale = true
+		return true, nil
+	}
+
 	if (!pr.checkCacheFreshness()) && (pr.cachingPolicy.CanRevalidate) {
 		return false, handleCacheRevalidation(pr)
 	}
@@ -232,6 +275,9 @@ func handleTrueCacheHit(pr *proxyRequest) error {
 
 	pr.upstreamResponse = &http.Response{StatusCode: d.StatusCode, Request: pr.Request,
 		Header: d.SafeHeaderClone()}
+	if pr.servedStale {
+		pr.upstreamResponse.Header.Set(headers.NameWarning, valueWarningStale)
+	}
 	if pr.wantsRanges {
 		h, b := d.RangeParts.ExtractResponseRange(pr.wantedRanges, d.ContentLength, d.ContentType, d.Body)
 		headers.Merge(pr.upstreamResponse.Header, h)
@@ -255,9 +301,12 @@ func handleCacheKeyMiss(pr *proxyRequest) error {
 	rsc := request.GetResources(pr.Request)
 	pc := rsc.PathConfig
 
+	// a path that assumes single use only caches the second request for a key within its window
+	pr.singleUse = !admitSingleUse(pc, pr.key)
+
 	// if a we're using PCF, handle that separately
-	if !methods.HasBody(pr.Method) && !pr.wantsRanges && pc != nil &&
-		pc.CollapsedForwardingType == forwarding.CFTypeProgressive {
+	if !methods.HasBody(pr.Method) && !pr.isHeadRequest() && !pr.wantsRanges && !pr.singleUse &&
+		pc != nil && pc.CollapsedForwardingType == forwarding.CFTypeProgressive {
 		if err := handlePCF(pr); err != errors.ErrPCFContentLength {
 			// if err is nil, or something else, we'll proceed.
 			return err
@@ -271,6 +320,13 @@ func handleCacheKeyMiss(pr *proxyRequest) error {
 
 func handleUpstreamTransactions(pr *proxyRequest) error {
 	pr.makeUpstreamRequests()
+	// the upstream bodies are accounted to the request's in-flight reservation by their Content-Lengths,
+	// which replace the estimate made for a document of unknown size on a cache miss
+	for _, resp := range append(pr.originResponses, pr.revalidationResponse) {
+		if resp != nil {
+			pr.inflight.Add(resp.ContentLength)
+		}
+	}
 	pr.reconstituteResponses()
 	pr.determineCacheability()
 	return nil
@@ -302,8 +358,9 @@ func handlePCF(pr *proxyRequest) error {
 	}
 	pr.upstreamRequest = pr.upstreamRequest.WithContext(ctx)
 
-	reader, resp, contentLength := PrepareFetchReader(pr.upstreamRequest)
+	reader, resp, contentLength := prepareFillReader(pr.upstreamRequest)
 	pr.upstreamResponse = resp
+	pr.inflight.Add(contentLength)
 
 	pr.writeResponseHeader()
 	pr.responseWriter = PrepareResponseWriter(pr.responseWriter, resp.StatusCode, resp.Header)
@@ -314,10 +371,10 @@ func handlePCF(pr *proxyRequest) error {
 		// Blocks until server completes
 
 		pr.cachingPolicy.Merge(GetResponseCachingPolicy(pr.upstreamResponse.StatusCode,
-			rsc.OriginConfig.NegativeCache, pr.upstreamResponse.Header))
+			responseNegativeCache(rsc, pr.upstreamResponse), pr.upstreamResponse.Header))
 		pr.determineCacheability()
 
-		go func() {
+		background.Go(background.KindCollapsedForward, func() {
 			var dest io.Writer = pcf
 			if pr.writeToCache {
 				pr.cacheBuffer = &bytes.Buffer{}
@@ -326,12 +383,20 @@ func handlePCF(pr *proxyRequest) error {
 			io.Copy(dest, reader)
 			pcf.Close()
 			reqs.Delete(pr.key)
-		}()
+			if reader != nil {
+				reader.Close()
+			}
+		})
 
 		pcf.AddClient(pr.responseWriter)
 
 		return handleAllWrites(pr)
 	}
+	// the response is not forwarded progressively, so its body is closed to end the upstream
+	// request and release its connection and concurrency admission
+	if reader != nil {
+		reader.Close()
+	}
 	return errors.ErrPCFContentLength
 }
 
@@ -346,6 +411,7 @@ func handleAllWrites(pr *proxyRequest) error {
 			} else {
 				d.Body = pr.cacheBuffer.Bytes()
 			}
+			pr.inflight.Observe(int64(pr.cacheBuffer.Len()))
 		}
 		pr.store()
 	}
@@ -354,6 +420,12 @@ func handleAllWrites(pr *proxyRequest) error {
 
 func handleResponse(pr *proxyRequest) error {
 	pr.prepareResponse()
+	// the body of an object fetched for a HEAD request is buffered, so that its length is reported
+	if pr.isHeadRequest() && pr.responseBody == nil && pr.upstreamReader != nil &&
+		pr.upstreamRequest.Method == http.MethodGet && pr.upstreamResponse.StatusCode < 300 {
+		pr.responseBody, _ = ioutil.ReadAll(pr.upstreamReader)
+		pr.updateContentLength()
+	}
 	if !pr.isPCF {
 		pr.writeResponseHeader()
 	}
@@ -381,6 +453,14 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 	cc := rsc.CacheClient
 
 	pr := newProxyRequest(r, w)
+	// the upstream bodies are fully read by the time the response is written
+	defer pr.closeUpstreamBodies()
+
+	// the object is fetched with a GET request when a HEAD request misses the cache, so that
+	// it is cached for subsequent requests
+	if pr.isHeadRequest() && rsc.PathConfig != nil && rsc.PathConfig.HeadUpgradeToGet {
+		pr.upstreamRequest.Method = http.MethodGet
+	}
 
 	_, span := tspan.NewChildSpan(r.Context(), rsc.Tracer, "ObjectProxyCacheRequest")
 	if span != nil {
@@ -391,16 +471,27 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 	pr.parseRequestRanges()
 
 	pr.cachingPolicy = GetRequestCachingPolicy(pr.Header)
+	pr.bypassNegativeCache = bypassesNegativeCache(r)
+	if rl, ok := rsc.OriginClient.(origins.ResultLimiter); ok {
+		pr.resultLimiter = rl
+		pr.resultLimit = rl.ResultLimit(r)
+	}
+
+	if !authPermitsCaching(r) || !prepareCacheablePost(pr) {
+		return nil, status.LookupStatusProxyOnly
+	}
 
 	pr.key = oc.CacheKeyPrefix + ".opc." + pr.DeriveCacheKey(nil, "")
+	pr.resetPostBody()
 
 	// if a PCF entry exists, or the client requested no-cache for this object, proxy out to it
 	pcfResult, pcfExists := reqs.Load(pr.key)
-	pr.isPCF = !methods.HasBody(pr.Method) && pcfExists && !pr.wantsRanges
+	pr.isPCF = !methods.HasBody(pr.Method) && !pr.isHeadRequest() && pcfExists && !pr.wantsRanges
 
 	if pr.isPCF || pr.cachingPolicy.NoCache {
 		if pr.cachingPolicy.NoCache {
 			cc.Remove(pr.key)
+			emitCacheEvent(rsc, cc, events.TypePurge, pr.key, "no-cache", 0, nil)
 			return nil, status.LookupStatusProxyOnly
 		}
 		pcf := pcfResult.(ProgressiveCollapseForwarder)
@@ -420,6 +511,20 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 	var err error
 	pr.cacheDocument, pr.cacheStatus, pr.neededRanges, err =
 		QueryCache(pr.upstreamRequest.Context(), cc, pr.key, pr.wantedRanges)
+
+	// capacity is reserved for buffering the cached document, or a document of unknown size on a
+	// cache miss, before the document is processed or fetched from the origin
+	if resp := pr.reserveInflight(inflightDocumentSize(pr.cacheDocument)); resp != nil {
+		if pr.hasReadLock {
+			pr.cacheLock.RRelease()
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		Respond(w, resp.StatusCode, resp.Header, body)
+		recordOPCResult(pr, status.LookupStatusProxyError, resp.StatusCode, r.URL.Path, 0, resp.Header)
+		return resp, status.LookupStatusProxyError
+	}
+	defer pr.releaseInflight()
+
 	if err == nil || err == cache.ErrKNF {
 		if f, ok := cacheResponseHandlers[pr.cacheStatus]; ok {
 			f(pr)
@@ -433,6 +538,7 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 		pr.cacheStatus = status.LookupStatusKeyMiss
 		handleCacheKeyMiss(pr)
 	}
+	pr.finishNegativeRefresh()
 
 	if pr.hasWriteLock {
 		pr.cacheLock.Release()
@@ -504,6 +610,7 @@ func rerunRequest(pr *proxyRequest) {
 			pr.cacheLock.Release()
 			pr.hasWriteLock = false
 		}
+		pr.releaseInflight()
 		ObjectProxyCacheRequest(w, pr.Request)
 	}
 }
@@ -29,10 +29,12 @@ import (
 	"github.com/tricksterproxy/mockster/pkg/mocks/byterange"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
 	"github.com/tricksterproxy/trickster/pkg/locks"
+	"github.com/tricksterproxy/trickster/pkg/proxy/background"
 	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
@@ -158,6 +160,43 @@ func TestObjectProxyCacheRequest(t *testing.T) {
 
 }
 
+func TestObjectProxyCacheRequestUndecodableObject(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+	rsc.CacheConfig.CacheType = "test" // disable direct-memory and force marshaling
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	pr := newProxyRequest(r, httptest.NewRecorder())
+	key := rsc.OriginConfig.CacheKeyPrefix + ".opc." + pr.DeriveCacheKey(nil, "")
+	b, _, err := rsc.CacheClient.Retrieve(key, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an object from a newer schema version is treated as a cache miss, and rewritten
+	b[0] = (envelopeSchemaVersion + 1) << envelopeSchemaShift
+	rsc.CacheClient.Store(key, b, time.Duration(30)*time.Second)
+
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
 func TestObjectProxyCachePartialHit(t *testing.T) {
 
 	ts, _, r, rsc, err := setupTestHarnessOPCRange(nil)
@@ -585,6 +624,73 @@ func TestObjectProxyCacheRequestWithPCF(t *testing.T) {
 
 }
 
+func TestObjectProxyCacheRequestClosesUpstreamBodies(t *testing.T) {
+
+	// the upstream request is in flight until its body is closed, including the body of a
+	// response of unknown length, which is not forwarded progressively
+	tests := []struct {
+		pcf, chunked bool
+	}{
+		{false, false},
+		{true, false},
+		{true, true},
+	}
+
+	for _, test := range tests {
+		hdrs := map[string]string{"Cache-Control": "max-age=60"}
+		if test.chunked {
+			hdrs[headers.NameTransferEncoding] = "chunked"
+		}
+		setup := setupTestHarnessOPC
+		if test.pcf {
+			setup = setupTestHarnessOPCWithPCF
+		}
+		ts, _, r, rsc, err := setup("", "test", http.StatusOK, hdrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		oc := rsc.OriginConfig
+		ut := instance.Default.Upstreams.Get(oc.Name, oc.OriginType)
+		n := ut.InFlight()
+
+		_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+		for _, err = range e {
+			t.Error(err)
+		}
+		background.Wait(time.Second)
+		ts.Close()
+
+		if ut.InFlight() != n {
+			t.Errorf("pcf=%t chunked=%t: expected %d got %d", test.pcf, test.chunked, n, ut.InFlight())
+		}
+	}
+}
+
+func TestObjectProxyCacheRequestPCFUnknownLengthReleasesSlot(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60",
+		headers.NameTransferEncoding: "chunked"}
+	ts, _, r, rsc, err := setupTestHarnessOPCWithPCF("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	oc := rsc.OriginConfig
+	oc.MaxConcurrentRequests = 4
+	oc.Name = "pcf-unknown-length"
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	background.Wait(time.Second)
+
+	if n := instance.Default.Limiters.Get(oc.Name, oc.OriginType).Active(); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+}
+
 func TestObjectProxyCacheTrueHitNoDocumentErr(t *testing.T) {
 
 	pr := &proxyRequest{}
@@ -818,10 +924,306 @@ func TestObjectProxyCacheRequestNegativeCache(t *testing.T) {
 	}
 
 	// request again, this time it should be a cache hit.
-	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "nchit"})
+	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "negative-hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
+func TestObjectProxyCacheRequestSetCookieNotReplayed(t *testing.T) {
+
+	hdrs := map[string]string{headers.NameSetCookie: "session=client1", "X-Correlation-Id": "12345"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusNotFound, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	pc := po.NewOptions()
+	cfg := rsc.OriginConfig
+	cfg.Paths = map[string]*po.Options{
+		"/": pc,
+	}
+	cfg.NegativeCache[404] = time.Second * 30
+	r = r.WithContext(tc.WithResources(r.Context(), request.NewResources(cfg, pc, rsc.CacheConfig,
+		rsc.CacheClient, rsc.OriginClient, nil, rsc.Logger)))
+
+	// the client that fills the cache receives its cookie
+	w, e := testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := w.Header().Get(headers.NameSetCookie); v != "session=client1" {
+		t.Errorf("expected %s got %s", "session=client1", v)
+	}
+
+	// a different client served from cache does not
+	w, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "negative-hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := w.Header().Get(headers.NameSetCookie); v != "" {
+		t.Errorf("expected empty Set-Cookie header, got %s", v)
+	}
+	if v := w.Header().Get("X-Correlation-Id"); v != "12345" {
+		t.Errorf("expected %s got %s", "12345", v)
+	}
+}
+
+func TestObjectProxyCacheHeadAfterGet(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, _, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the HEAD request is served the headers of the cached GET document, without its body
+	hr := r.Clone(r.Context())
+	hr.Method = http.MethodHead
+	w, e := testFetchOPC(hr, http.StatusOK, "", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := w.Header().Get(headers.NameContentLength); v != "4" {
+		t.Errorf("expected %s got %s", "4", v)
+	}
+
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
+func TestObjectProxyCacheGetAfterHead(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, _, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	hr := r.Clone(r.Context())
+	hr.Method = http.MethodHead
+	_, e := testFetchOPC(hr, http.StatusOK, "", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the bodiless response to the HEAD request was not cached, so the GET request misses
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	w, e := testFetchOPC(hr, http.StatusOK, "", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := w.Header().Get(headers.NameContentLength); v != "4" {
+		t.Errorf("expected %s got %s", "4", v)
+	}
+}
+
+func TestObjectProxyCacheHeadUpgradeToGet(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+	rsc.PathConfig.HeadUpgradeToGet = true
+
+	hr := r.Clone(r.Context())
+	hr.Method = http.MethodHead
+	w, e := testFetchOPC(hr, http.StatusOK, "", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := w.Header().Get(headers.NameContentLength); v != "4" {
+		t.Errorf("expected %s got %s", "4", v)
+	}
+
+	// the object fetched for the HEAD request satisfies the GET request
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
+func TestObjectProxyCacheRequestNegativeCacheRevalidate(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusNotFound, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	pc := po.NewOptions()
+	cfg := rsc.OriginConfig
+	cfg.Paths = map[string]*po.Options{
+		"/": pc,
+	}
+	cfg.NegativeCache[404] = time.Second * 30
+	r = r.WithContext(tc.WithResources(r.Context(), request.NewResources(cfg, pc, rsc.CacheConfig,
+		rsc.CacheClient, rsc.OriginClient, nil, rsc.Logger)))
+
+	_, e := testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the revalidate header is ignored unless the origin allows it
+	r.Header.Set(headers.NameTricksterRevalidate, "1")
+	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "negative-hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	cfg.NegativeCacheAllowRevalidate = true
+	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the revalidated response should have been written back to the negative cache
+	r.Header.Del(headers.NameTricksterRevalidate)
+	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "negative-hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// a no-cache request purges the negative cache entry
+	r.Header.Set(headers.NameCacheControl, headers.ValueNoCache)
+	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "proxy-error"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	r.Header.Del(headers.NameCacheControl)
+	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
+func TestObjectProxyCacheRequestNegativeCacheRefresh(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusNotFound, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	pc := po.NewOptions()
+	cfg := rsc.OriginConfig
+	cfg.Paths = map[string]*po.Options{
+		"/": pc,
+	}
+	cfg.NegativeCache[404] = time.Second
+	cfg.NegativeCacheBackoffMultiplier = 3
+	cfg.NegativeCacheBackoffMaxTTL = 5 * time.Second
+	r = r.WithContext(tc.WithResources(r.Context(), request.NewResources(cfg, pc, rsc.CacheConfig,
+		rsc.CacheClient, rsc.OriginClient, nil, rsc.Logger)))
+
+	key := cfg.CacheKeyPrefix + ".opc." + newProxyRequest(r, nil).DeriveCacheKey(nil, "")
+
+	// expire moves the cached entry's freshness into the past, and returns its freshness lifetime
+	expire := func() int {
+		d, _, _, err := QueryCache(r.Context(), rsc.CacheClient, key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lifetime := d.CachingPolicy.FreshnessLifetime
+		d.CachingPolicy.LocalDate = time.Now().Add(-time.Duration(lifetime+1) * time.Second)
+		WriteCache(r.Context(), rsc.CacheClient, key, d, time.Minute, nil)
+		return lifetime
+	}
+
+	_, e := testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if l := expire(); l != 1 {
+		t.Errorf("expected %d got %d", 1, l)
+	}
+
+	// while another request refreshes the expired entry, it is served as it is
+	negativeRefreshes.Store(key, struct{}{})
+	w, e := testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "negative-hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if w.Header().Get(headers.NameWarning) != valueWarningStale {
+		t.Errorf("expected stale warning, got %s", w.Header().Get(headers.NameWarning))
+	}
+	negativeRefreshes.Delete(key)
+
+	// each refresh that fails again extends the entry's TTL, up to the cap
+	for _, expected := range []int{3, 5} {
+		_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
+		for _, err = range e {
+			t.Error(err)
+		}
+		if l := expire(); l != expected {
+			t.Errorf("expected %d got %d", expected, l)
+		}
+	}
+	if _, ok := negativeRefreshes.Load(key); ok {
+		t.Error("expected refresh to be released")
+	}
+
+	// a refresh that is not a negative response removes the expired entry, resetting the backoff
+	delete(cfg.NegativeCache, 404)
+	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
 	for _, err = range e {
 		t.Error(err)
 	}
+	if _, _, _, err = QueryCache(r.Context(), rsc.CacheClient, key, nil); err == nil {
+		t.Error("expected expired entry to be removed")
+	}
+	cfg.NegativeCache[404] = time.Second
+	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if l := expire(); l != 1 {
+		t.Errorf("expected %d got %d", 1, l)
+	}
+}
+
+func TestObjectProxyCacheRequestPathNegativeCache(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusNotFound, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	// the path's empty negative cache overrides the origin's
+	pc := po.NewOptions()
+	pc.NegativeCache = map[int]time.Duration{}
+	cfg := rsc.OriginConfig
+	cfg.Paths = map[string]*po.Options{
+		"/": pc,
+	}
+	cfg.NegativeCache[404] = time.Second * 30
+	r = r.WithContext(tc.WithResources(r.Context(), request.NewResources(cfg, pc, rsc.CacheConfig,
+		rsc.CacheClient, rsc.OriginClient, nil, rsc.Logger)))
+
+	for i := 0; i < 2; i++ {
+		_, e := testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
+		for _, err = range e {
+			t.Error(err)
+		}
+	}
 }
 
 func TestHandleCacheRevalidation(t *testing.T) {
@@ -1191,3 +1593,23 @@ func TestRerunRequest(t *testing.T) {
 		t.Error("expected true")
 	}
 }
+
+func TestObjectProxyCacheAssumeSingleUse(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+	rsc.PathConfig.AssumeSingleUse = true
+	r.URL.RawQuery = "single_use=opc"
+
+	// the response is only cached on the second request for its key
+	for _, cacheStatus := range []string{"kmiss", "kmiss", "hit"} {
+		_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": cacheStatus})
+		for _, err = range e {
+			t.Error(err)
+		}
+	}
+}
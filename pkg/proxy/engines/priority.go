@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"strings"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// requestPriority returns the priority class of the request, which is that assigned by a rule,
+// or else that named by the path's priority header, or else the path's priority
+func requestPriority(r *http.Request, pc *po.Options) priority.Class {
+	if c, ok := tctx.Priority(r.Context()); ok {
+		return c
+	}
+	if pc == nil {
+		return priority.ClassNormal
+	}
+	if pc.PriorityHeader != "" {
+		v := strings.ToLower(strings.TrimSpace(r.Header.Get(pc.PriorityHeader)))
+		if c, ok := priority.Names[v]; ok {
+			return c
+		}
+	}
+	return pc.Priority
+}
+
+// concurrencyLimited returns true if the request's upstream request is admitted under the origin's
+// concurrency limit. Health checks are never limited, so that they reflect the origin's recovery as
+// soon as it occurs, and nor are fills from a bootstrap peer, which are not made to the origin
+func concurrencyLimited(r *http.Request, oc *oo.Options) bool {
+	return oc != nil && oc.MaxConcurrentRequests > 0 && !tctx.HealthCheckFlag(r.Context()) &&
+		r.Header.Get(headers.NameTricksterBootstrap) == ""
+}
+
+// admitUpstream admits the upstream request under the origin's concurrency limit, waiting behind
+// those of its own and higher priority classes. The returned Slot must be released once the
+// upstream response is complete. When the request is not admitted, the returned response should
+// be used in place of the upstream response
+func admitUpstream(r *http.Request, rsc *request.Resources, c priority.Class) (*priority.Slot, *http.Response) {
+	oc := rsc.OriginConfig
+	l := rsc.State().Limiters.Get(oc.Name, oc.OriginType)
+	l.Configure(oc.MaxConcurrentRequests, oc.ConcurrencyReservedShare, oc.ConcurrencyMaxWait)
+	s, err := l.Acquire(r.Context(), c)
+	if err != nil {
+		rsc.Logger.Warn("origin concurrency capacity is unavailable",
+			tl.Pairs{"originName": oc.Name, "priority": c.String(), "detail": err.Error()})
+		return nil, concurrencyLimitedResponse(r, c)
+	}
+	return s, nil
+}
+
+// concurrencyLimitedResponse returns a 503 response for an upstream request of the priority class
+// that was not admitted under the origin's concurrency limit before the timeout elapsed
+func concurrencyLimitedResponse(r *http.Request, c priority.Class) *http.Response {
+	return rejectionResponse(r, txe.NewResponseError(http.StatusServiceUnavailable,
+		txe.CodeOriginConcurrencyLimit,
+		"the origin's concurrency limit is saturated for priority class "+c.String()))
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
+)
+
+func TestRequestPriority(t *testing.T) {
+
+	pc := po.NewOptions()
+	r := httptest.NewRequest("GET", "http://127.0.0.1/", nil)
+	r.Header.Set("X-Priority", "LOW")
+
+	if c := requestPriority(r, nil); c != priority.ClassNormal {
+		t.Errorf("expected %s got %s", priority.ClassNormal, c)
+	}
+	if c := requestPriority(r, pc); c != priority.ClassNormal {
+		t.Errorf("expected %s got %s", priority.ClassNormal, c)
+	}
+
+	pc.Priority = priority.ClassHigh
+	if c := requestPriority(r, pc); c != priority.ClassHigh {
+		t.Errorf("expected %s got %s", priority.ClassHigh, c)
+	}
+
+	// the header overrides the path, when it names a class
+	pc.PriorityHeader = "X-Priority"
+	if c := requestPriority(r, pc); c != priority.ClassLow {
+		t.Errorf("expected %s got %s", priority.ClassLow, c)
+	}
+	r.Header.Set("X-Priority", "urgent")
+	if c := requestPriority(r, pc); c != priority.ClassHigh {
+		t.Errorf("expected %s got %s", priority.ClassHigh, c)
+	}
+
+	// a rule overrides the header and the path
+	r = r.WithContext(tc.WithPriority(r.Context(), priority.ClassLow))
+	if c := requestPriority(r, pc); c != priority.ClassLow {
+		t.Errorf("expected %s got %s", priority.ClassLow, c)
+	}
+}
+
+func TestAdmitUpstream(t *testing.T) {
+
+	var requests int32
+	hold := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			<-hold
+		}
+		w.Write([]byte("test"))
+	}))
+	defer s.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", s.URL, "-origin-type", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	oc := conf.Origins["default"]
+	oc.Name = "test-admit-upstream"
+	oc.HTTPClient = http.DefaultClient
+	oc.MaxConcurrentRequests = 1
+	oc.ConcurrencyMaxWait = 20 * time.Millisecond
+
+	// the first request holds the origin's only admission until it is released
+	done := make(chan *http.Response)
+	go func() {
+		done <- doRateLimitedProxy(oc, s.URL, false)
+	}()
+	l := instance.Default.Limiters.Get(oc.Name, oc.OriginType)
+	for i := 0; i < 100 && atomic.LoadInt32(&requests) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	resp := doRateLimitedProxy(oc, s.URL, false)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected %d got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	if !strings.Contains(string(b), "origin_concurrency_limit") ||
+		!strings.Contains(string(b), "priority class normal") {
+		t.Errorf("expected origin_concurrency_limit error got %s", string(b))
+	}
+
+	// health checks are not limited
+	resp = doRateLimitedProxy(oc, s.URL, true)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	close(hold)
+	resp = <-done
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if l.Active() != 0 {
+		t.Errorf("expected %d got %d", 0, l.Active())
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+}
+
+func TestConcurrencyLimitedResponseNotCached(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	oc := rsc.OriginConfig
+	oc.Name = "test-concurrency-not-cached"
+	oc.MaxConcurrentRequests = 1
+	oc.ConcurrencyMaxWait = 10 * time.Millisecond
+	oc.NegativeCache = map[int]time.Duration{http.StatusServiceUnavailable: time.Minute}
+
+	// the origin's only admission is held, so the request is rejected
+	l := instance.Default.Limiters.Get(oc.Name, oc.OriginType)
+	l.Configure(oc.MaxConcurrentRequests, oc.ConcurrencyReservedShare, oc.ConcurrencyMaxWait)
+	s, err := l.Acquire(context.Background(), priority.ClassNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	ObjectProxyCacheRequest(w, r)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected %d got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	// once capacity is available, the rejection is not served from the Negative Cache
+	s.Release()
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
@@ -22,14 +22,21 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
 	"github.com/tricksterproxy/trickster/pkg/locks"
+	"github.com/tricksterproxy/trickster/pkg/proxy/background"
+	"github.com/tricksterproxy/trickster/pkg/proxy/chain"
 	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/inflight"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	"github.com/tricksterproxy/trickster/pkg/proxy/ranges/byterange"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tspan "github.com/tricksterproxy/trickster/pkg/tracing/span"
@@ -88,6 +95,30 @@ type proxyRequest struct {
 	wantsRanges       bool
 	isPartialResponse bool
 	wasReconstituted  bool
+	// bypassNegativeCache indicates the client requested that Negative Cache entries be ignored
+	bypassNegativeCache bool
+	// negativeRefresh describes the expired Negative Cache entry that the request is refreshing
+	negativeRefresh *negativeRefresh
+	// servedStale indicates an expired cache object is served without revalidation, since the
+	// origin's rate limit is low
+	servedStale bool
+	// singleUse indicates the request missed the cache for a path that assumes single use, and
+	// its response is not cached since its cache key was not recently requested
+	singleUse bool
+
+	// resultLimiter applies resultLimit, the client-requested result limit, to the rendered response
+	resultLimiter     origins.ResultLimiter
+	resultLimit       int
+	resultLimitWriter *resultLimitWriter
+
+	// postBody is the buffered request body of a cacheable POST request
+	isCacheablePost bool
+	postBody        []byte
+
+	// inflight is the request's in-flight processing reservation, which it releases only when it
+	// owns it, rather than sharing it from the request that it was made on behalf of
+	inflight     *inflight.Reservation
+	ownsInflight bool
 }
 
 // newProxyRequest accepts the original inbound HTTP Request and Response
@@ -125,21 +156,27 @@ func (pr *proxyRequest) Clone() *proxyRequest {
 				trace.ContextWithSpan(context.Background(),
 					trace.SpanFromContext(pr.upstreamRequest.Context())),
 				rsc)),
-		Logger:             pr.Logger,
-		cacheDocument:      pr.cacheDocument,
-		key:                pr.key,
-		cacheStatus:        pr.cacheStatus,
-		writeToCache:       pr.writeToCache,
-		wantsRanges:        pr.wantsRanges,
-		wantedRanges:       pr.wantedRanges,
-		neededRanges:       pr.neededRanges,
-		rangeParts:         pr.rangeParts,
-		collapsedForwarder: pr.collapsedForwarder,
-		cachingPolicy:      pr.cachingPolicy,
-		revalidation:       pr.revalidation,
-		isPartialResponse:  pr.isPartialResponse,
-		started:            time.Now(),
-		mapLock:            &sync.Mutex{},
+		Logger:              pr.Logger,
+		cacheDocument:       pr.cacheDocument,
+		key:                 pr.key,
+		cacheStatus:         pr.cacheStatus,
+		writeToCache:        pr.writeToCache,
+		wantsRanges:         pr.wantsRanges,
+		wantedRanges:        pr.wantedRanges,
+		neededRanges:        pr.neededRanges,
+		rangeParts:          pr.rangeParts,
+		collapsedForwarder:  pr.collapsedForwarder,
+		cachingPolicy:       pr.cachingPolicy,
+		revalidation:        pr.revalidation,
+		isPartialResponse:   pr.isPartialResponse,
+		started:             time.Now(),
+		bypassNegativeCache: pr.bypassNegativeCache,
+		resultLimiter:       pr.resultLimiter,
+		resultLimit:         pr.resultLimit,
+		isCacheablePost:     pr.isCacheablePost,
+		postBody:            pr.postBody,
+		inflight:            pr.inflight,
+		mapLock:             &sync.Mutex{},
 	}
 }
 
@@ -157,7 +194,13 @@ func (pr *proxyRequest) Fetch() ([]byte, *http.Response, time.Duration) {
 	}
 
 	start := time.Now()
-	reader, resp, _ := PrepareFetchReader(pr.upstreamRequest)
+	reader, resp, _ := prepareFillReader(pr.upstreamRequest)
+
+	// the body is accounted to the request's in-flight reservation as it is buffered, by its
+	// Content-Length when known, or by its size once read
+	if resp.ContentLength >= 0 {
+		pr.inflight.Add(resp.ContentLength)
+	}
 
 	var body []byte
 	var err error
@@ -165,6 +208,10 @@ func (pr *proxyRequest) Fetch() ([]byte, *http.Response, time.Duration) {
 		body, err = ioutil.ReadAll(reader)
 		resp.Body.Close()
 	}
+	if resp.ContentLength < 0 {
+		pr.inflight.Add(int64(len(body)))
+	}
+	pr.inflight.Observe(int64(len(body)))
 	if err != nil {
 		pr.Logger.Error("error reading body from http response",
 			tl.Pairs{"url": pr.URL.String(), "detail": err.Error()})
@@ -174,7 +221,8 @@ func (pr *proxyRequest) Fetch() ([]byte, *http.Response, time.Duration) {
 	elapsed := time.Since(start) // includes any time required to decompress the document for deserialization
 
 	go logUpstreamRequest(pr.Logger, oc.Name, oc.OriginType, handlerName,
-		pr.Method, pr.URL.String(), pr.UserAgent(), resp.StatusCode, len(body), elapsed.Seconds())
+		pr.Method, pr.URL.String(), pr.UserAgent(), requestPriority(pr.upstreamRequest, pc).String(),
+		resp.StatusCode, len(body), elapsed.Seconds())
 
 	return body, resp, elapsed
 }
@@ -279,7 +327,7 @@ func (pr *proxyRequest) makeUpstreamRequests() error {
 
 	if pr.revalidationRequest != nil {
 		wg.Add(1)
-		go func() {
+		background.Go(background.KindRevalidation, func() {
 			req := pr.revalidationRequest
 			_, span := tspan.NewChildSpan(req.Context(), rsc.Tracer, "FetchRevalidation")
 			if span != nil {
@@ -291,9 +339,9 @@ func (pr *proxyRequest) makeUpstreamRequests() error {
 				pr.revalidationRequest = req.WithContext(trace.ContextWithSpan(req.Context(), span))
 				defer span.End()
 			}
-			pr.revalidationReader, pr.revalidationResponse, _ = PrepareFetchReader(pr.revalidationRequest)
+			pr.revalidationReader, pr.revalidationResponse, _ = prepareFillReader(pr.revalidationRequest)
 			wg.Done()
-		}()
+		})
 	}
 
 	if pr.originRequests != nil && len(pr.originRequests) > 0 {
@@ -301,7 +349,8 @@ func (pr *proxyRequest) makeUpstreamRequests() error {
 		pr.originReaders = make([]io.ReadCloser, len(pr.originRequests))
 		for i := range pr.originRequests {
 			wg.Add(1)
-			go func(j int) {
+			j := i
+			background.Go(background.KindUpstreamFetch, func() {
 				req := pr.originRequests[j]
 				_, span := tspan.NewChildSpan(req.Context(), rsc.Tracer, "Fetch")
 				if span != nil {
@@ -313,9 +362,9 @@ func (pr *proxyRequest) makeUpstreamRequests() error {
 					req = req.WithContext(trace.ContextWithSpan(req.Context(), span))
 					defer span.End()
 				}
-				pr.originReaders[j], pr.originResponses[j], _ = PrepareFetchReader(req)
+				pr.originReaders[j], pr.originResponses[j], _ = prepareFillReader(req)
 				wg.Done()
-			}(i)
+			})
 		}
 	}
 
@@ -324,6 +373,19 @@ func (pr *proxyRequest) makeUpstreamRequests() error {
 	return nil
 }
 
+// closeUpstreamBodies closes the bodies of the request's upstream and revalidation responses, so that
+// their connections, and any resources held until they are read, such as concurrency slots, are released
+func (pr *proxyRequest) closeUpstreamBodies() {
+	for _, rc := range pr.originReaders {
+		if rc != nil {
+			rc.Close()
+		}
+	}
+	if pr.revalidationReader != nil {
+		pr.revalidationReader.Close()
+	}
+}
+
 func (pr *proxyRequest) checkCacheFreshness() bool {
 	cp := pr.cachingPolicy
 	if pr.cachingPolicy == nil {
@@ -362,16 +424,44 @@ func (pr *proxyRequest) stripConditionalHeaders() {
 
 func (pr *proxyRequest) writeResponseHeader() {
 	headers.SetResultsHeader(pr.upstreamResponse.Header, "ObjectProxyCache", pr.cacheStatus.String(), "", nil)
+	if rsc := request.GetResources(pr.Request); rsc != nil {
+		chain.SetResponseHeader(pr.upstreamResponse.Header, rsc.UpstreamChain.Hops(),
+			pr.cacheStatus.String(), time.Since(pr.started))
+	}
 }
 
 func (pr *proxyRequest) setBodyWriter() {
 
 	if !pr.isPCF {
 		pr.mapLock.Lock()
-		PrepareResponseWriter(pr.responseWriter, pr.upstreamResponse.StatusCode, pr.upstreamResponse.Header)
+		h := pr.upstreamResponse.Header
+		if pr.limitsResults() && h.Get(headers.NameETag) != "" {
+			// the origin's validator describes the unlimited document, so the client
+			// receives one that is specific to the limited response
+			h = h.Clone()
+			h.Set(headers.NameETag, limitedETag(h.Get(headers.NameETag), pr.resultLimit))
+		}
+		if pr.isHeadRequest() && pr.responseBody != nil && pr.upstreamResponse.StatusCode < 300 {
+			// the body is not written, so the client is told the length of the object it would receive
+			h.Set(headers.NameContentLength, strconv.Itoa(len(pr.responseBody)))
+		}
+		PrepareResponseWriter(pr.responseWriter, pr.upstreamResponse.StatusCode, h)
 		pr.mapLock.Unlock()
 	}
 
+	// a HEAD response has no body, though an object fetched with an upgraded GET is still
+	// written to the cache buffer
+	if pr.isHeadRequest() && pr.responseWriter != nil {
+		pr.responseWriter = ioutil.Discard
+	}
+
+	// the result limit only applies to what is written to the client, never to the cache buffer
+	if pr.limitsResults() && pr.responseWriter != nil &&
+		pr.upstreamResponse.StatusCode == http.StatusOK && !pr.cachingPolicy.IsClientFresh {
+		pr.resultLimitWriter = newResultLimitWriter(pr.responseWriter, pr.resultLimiter, pr.resultLimit)
+		pr.responseWriter = pr.resultLimitWriter
+	}
+
 	if pr.writeToCache && pr.cacheBuffer == nil {
 		pr.cacheBuffer = &bytes.Buffer{}
 
@@ -395,6 +485,10 @@ func (pr *proxyRequest) writeResponseBody() {
 		return
 	}
 	io.Copy(pr.responseWriter, pr.upstreamReader)
+	if pr.resultLimitWriter != nil {
+		pr.resultLimitWriter.flush()
+		pr.resultLimitWriter = nil
+	}
 }
 
 func (pr *proxyRequest) determineCacheability() {
@@ -402,8 +496,12 @@ func (pr *proxyRequest) determineCacheability() {
 	rsc := request.GetResources(pr.Request)
 	resp := pr.upstreamResponse
 
+	// a response to a HEAD request has no body, so it is never cached, since the
+	// cache object would otherwise satisfy GET requests
+	fetchedHead := pr.upstreamRequest != nil && pr.upstreamRequest.Method == http.MethodHead
+
 	if resp != nil && resp.StatusCode >= 400 {
-		pr.writeToCache = pr.cachingPolicy.IsNegativeCache
+		pr.writeToCache = pr.cachingPolicy.IsNegativeCache && !fetchedHead
 		resp.Header.Del(headers.NameCacheControl)
 		resp.Header.Del(headers.NameExpires)
 		resp.Header.Del(headers.NameLastModified)
@@ -412,6 +510,11 @@ func (pr *proxyRequest) determineCacheability() {
 		return
 	}
 
+	if fetchedHead {
+		pr.writeToCache = false
+		return
+	}
+
 	if pr.revalidation == RevalStatusLocal {
 
 		tpc := pr.cachingPolicy.Clone()
@@ -444,7 +547,7 @@ func (pr *proxyRequest) determineCacheability() {
 
 func (pr *proxyRequest) store() error {
 
-	if !pr.writeToCache || pr.cacheDocument == nil {
+	if !pr.writeToCache || pr.cacheDocument == nil || pr.singleUse {
 		return nil
 	}
 
@@ -470,12 +573,26 @@ func (pr *proxyRequest) store() error {
 		rf = 1
 	}
 
+	ttl := pr.cachingPolicy.TTL(rf, oc.MaxTTL)
+	if pr.cachingPolicy.IsNegativeCache {
+		ttl = pr.negativeCacheTTL(rsc, d.StatusCode)
+	}
+
 	d.CachingPolicy = pr.cachingPolicy
+	d.ResultLimit = pr.resultLimit
 	err := WriteCache(pr.upstreamRequest.Context(), rsc.CacheClient, pr.key, d,
-		pr.cachingPolicy.TTL(rf, oc.MaxTTL), oc.CompressableTypes)
+		ttl, oc.CompressableTypes)
 	if err != nil {
 		return err
 	}
+	eventType := events.TypeStore
+	if pr.cachingPolicy.IsNegativeCache {
+		eventType = events.TypeNegativeStore
+	}
+	emitCacheEvent(rsc, rsc.CacheClient, eventType, pr.key, "", len(d.Body), nil)
+	if pr.negativeRefresh != nil {
+		pr.negativeRefresh.stored = true
+	}
 	return nil
 }
 
@@ -493,9 +610,29 @@ func (pr *proxyRequest) updateContentLength() {
 	pr.upstreamReader = bytes.NewReader(pr.responseBody)
 }
 
+// isHeadRequest returns true if the client requested only the headers of the object
+func (pr *proxyRequest) isHeadRequest() bool {
+	return pr.Request != nil && pr.Method == http.MethodHead
+}
+
+// limitsResults returns true if the client-requested result limit is applied to the response
+func (pr *proxyRequest) limitsResults() bool {
+	return pr.resultLimit > 0 && pr.resultLimiter != nil && !pr.wantsRanges
+}
+
 func (pr *proxyRequest) prepareResponse() {
 
-	pr.cachingPolicy.ResolveClientConditionals(pr.cacheStatus)
+	if pr.limitsResults() && pr.cachingPolicy.ETag != "" {
+		// the client's validator is compared to that of the limited response, without altering
+		// the caching policy that is stored with the document
+		cp := pr.cachingPolicy.Clone()
+		cp.ETag = strings.Trim(strings.TrimPrefix(limitedETag(cp.ETag, pr.resultLimit), "W/"), `"`)
+		cp.ResolveClientConditionals(pr.cacheStatus)
+		pr.cachingPolicy.IsClientFresh = cp.IsClientFresh
+		pr.cachingPolicy.IfNoneMatchResult = cp.IfNoneMatchResult
+	} else {
+		pr.cachingPolicy.ResolveClientConditionals(pr.cacheStatus)
+	}
 
 	d := pr.cacheDocument
 	resp := pr.upstreamResponse
@@ -726,7 +863,7 @@ func (pr *proxyRequest) reconstituteResponses() {
 	if pr.upstreamResponse.StatusCode != http.StatusNotModified {
 		rsc := request.GetResources(pr.Request)
 		pr.cachingPolicy.Merge(GetResponseCachingPolicy(pr.upstreamResponse.StatusCode,
-			rsc.OriginConfig.NegativeCache, pr.upstreamResponse.Header))
+			responseNegativeCache(rsc, pr.upstreamResponse), pr.upstreamResponse.Header))
 
 	}
 
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/proxy/background"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tspan "github.com/tricksterproxy/trickster/pkg/tracing/span"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// splitWriter is an http.ResponseWriter that captures the response to a sub-query
+type splitWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (sw *splitWriter) Header() http.Header {
+	return sw.header
+}
+
+func (sw *splitWriter) Write(b []byte) (int, error) {
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+	return sw.body.Write(b)
+}
+
+func (sw *splitWriter) WriteHeader(code int) {
+	if sw.code == 0 {
+		sw.code = code
+	}
+}
+
+// splitQueryRequest services a timeseries request by splitting its query into sub-queries, which
+// are each serviced by the Delta Proxy Cache, and combining their results. It returns false, having
+// written nothing, when the query cannot be split or any sub-query does not succeed, so that the
+// request can instead be serviced with its query whole
+func splitQueryRequest(w http.ResponseWriter, r *http.Request) bool {
+
+	rsc := request.GetResources(r)
+	client, ok := rsc.OriginClient.(origins.TimeseriesClient)
+	if !ok {
+		return false
+	}
+	qs, ok := client.(origins.QuerySplitter)
+	if !ok {
+		return false
+	}
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil || !authPermitsCaching(r) {
+		return false
+	}
+	reqs, combine, ok := qs.SplitQuery(r, trq)
+	if !ok || len(reqs) < 2 {
+		return false
+	}
+	limit := rsc.PathConfig.SplitQueriesLimit
+	if limit <= 0 {
+		limit = d.DefaultSplitQueriesLimit
+	}
+	if len(reqs) > limit {
+		rsc.Logger.Debug("split query exceeds the limit, falling back to whole query",
+			tl.Pairs{"subQueries": len(reqs), "limit": limit})
+		return false
+	}
+
+	ctx, span := tspan.NewChildSpan(r.Context(), rsc.Tracer, "SplitQuery")
+	if span != nil {
+		defer span.End()
+	}
+
+	sws := make([]*splitWriter, len(reqs))
+	wg := sync.WaitGroup{}
+	for i, sr := range reqs {
+		// sub-queries are always answered in full, in the origin's default format, to be combined
+		sr.Header.Del(headers.NameAccept)
+		sr.Header.Del(headers.NameIfNoneMatch)
+		sr.Header.Del(headers.NameIfModifiedSince)
+		sr = request.SetResources(sr.WithContext(ctx), rsc.Clone())
+		sws[i] = &splitWriter{header: make(http.Header)}
+		wg.Add(1)
+		sw, sr := sws[i], sr
+		background.Go(background.KindQuerySplit, func() {
+			defer wg.Done()
+			deltaProxyCacheRequest(sw, sr, false)
+		})
+	}
+	wg.Wait()
+
+	results := make([]timeseries.Timeseries, len(sws))
+	for i, sw := range sws {
+		if sw.code != http.StatusOK {
+			rsc.Logger.Debug("split query falling back to whole query",
+				tl.Pairs{"subQuery": i, "statusCode": sw.code})
+			return false
+		}
+		if results[i], err = client.UnmarshalTimeseries(sw.body.Bytes()); err != nil {
+			rsc.Logger.Debug("split query falling back to whole query",
+				tl.Pairs{"subQuery": i, "detail": err.Error()})
+			return false
+		}
+	}
+	ts, err := combine(results)
+	if err != nil {
+		rsc.Logger.Debug("split query falling back to whole query", tl.Pairs{"detail": err.Error()})
+		return false
+	}
+
+	// the combined response does not share a validator with any of the sub-queries' responses
+	rh := sws[0].header.Clone()
+	rh.Del(headers.NameContentLength)
+	rh.Del(headers.NameETag)
+	rh.Del(headers.NameLastModified)
+	rh.Set(headers.NameTricksterQuerySplit, "split; subqueries="+strconv.Itoa(len(reqs)))
+
+	if fs := frameStreamer(r, client, http.StatusOK, rh); fs != nil {
+		if err := respondFrames(w, http.StatusOK, rh, ts, fs); err != nil {
+			rsc.Logger.Debug("timeseries frame stream interrupted", tl.Pairs{"detail": err.Error()})
+		}
+		return true
+	}
+	body, err := renderTimeseries(r, client, ts, rh)
+	if err != nil {
+		return false
+	}
+	Respond(w, http.StatusOK, rh, body)
+	return true
+}
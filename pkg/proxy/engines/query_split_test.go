@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// splitTestClient is a TestClient that splits queries on ' or ', and combines
+// their results by concatenating their series
+type splitTestClient struct {
+	*TestClient
+	combineErr error
+}
+
+func (c *splitTestClient) SplitQuery(r *http.Request,
+	trq *timeseries.TimeRangeQuery) ([]*http.Request, origins.TimeseriesCombiner, bool) {
+	parts := strings.Split(trq.Statement, " or ")
+	if len(parts) < 2 {
+		return nil, nil, false
+	}
+	v, _, _ := params.GetRequestValues(r)
+	reqs := make([]*http.Request, len(parts))
+	for i, q := range parts {
+		sr := r.Clone(r.Context())
+		sv := url.Values{}
+		for k := range v {
+			sv.Set(k, v.Get(k))
+		}
+		sv.Set(upQuery, q)
+		params.SetRequestValues(sr, sv)
+		reqs[i] = sr
+	}
+	return reqs, func(results []timeseries.Timeseries) (timeseries.Timeseries, error) {
+		if c.combineErr != nil {
+			return nil, c.combineErr
+		}
+		me := results[0].(*MatrixEnvelope)
+		for _, ts := range results[1:] {
+			me.Data.Result = append(me.Data.Result, ts.(*MatrixEnvelope).Data.Result...)
+		}
+		return me, nil
+	}, true
+}
+
+func TestSplitQueryRequest(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	client := &splitTestClient{TestClient: rsc.OriginClient.(*TestClient)}
+	rsc.OriginClient = client
+	rsc.PathConfig.SplitQueries = true
+	rsc.PathConfig.CacheKeyParams = []string{upQuery, upStep}
+	rsc.OriginConfig.FastForwardDisable = true
+
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour).Truncate(step)
+	start := end.Add(-time.Duration(6) * time.Hour)
+
+	const q1 = "some_query_here{latency_ms=0,range_latency_ms=0,series_id=1}"
+	const q2 = "some_query_here{latency_ms=0,range_latency_ms=0,series_id=2}"
+
+	queryRange := func(query string) *httptest.ResponseRecorder {
+		req := r.Clone(r.Context())
+		req.URL.Path = "/prometheus/api/v1/query_range"
+		req.URL.RawQuery = url.Values{"query": {query}, "step": {fmt.Sprint(int(step.Seconds()))},
+			"start": {fmt.Sprint(start.Unix())}, "end": {fmt.Sprint(end.Unix())}}.Encode()
+		w := httptest.NewRecorder()
+		client.QueryRangeHandler(w, req)
+		return w
+	}
+
+	w := queryRange(q1 + " or " + q2)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if h := w.Header().Get(headers.NameTricksterQuerySplit); h != "split; subqueries=2" {
+		t.Errorf("expected %s got %s", "split; subqueries=2", h)
+	}
+	me, err := client.UnmarshalTimeseries(w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(me.(*MatrixEnvelope).Data.Result); n < 2 {
+		t.Errorf("expected the series of both sub-queries, got %d", n)
+	}
+
+	// each sub-query is cached independently of the query it was split from
+	w = queryRange(q2)
+	if h := w.Header().Get(headers.NameTricksterResult); !strings.Contains(h, "status=hit") {
+		t.Errorf("expected hit got %s", h)
+	}
+	if h := w.Header().Get(headers.NameTricksterQuerySplit); h != "whole" {
+		t.Errorf("expected %s got %s", "whole", h)
+	}
+
+	// the query is serviced whole when any sub-query fails
+	w = queryRange(queryReturnsBadRequest + " or " + q1)
+	if h := w.Header().Get(headers.NameTricksterQuerySplit); h != "whole" {
+		t.Errorf("expected %s got %s", "whole", h)
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// or when the results cannot be combined
+	client.combineErr = fmt.Errorf("test error")
+	w = queryRange(q1 + " or " + q2)
+	if h := w.Header().Get(headers.NameTricksterQuerySplit); h != "whole" {
+		t.Errorf("expected %s got %s", "whole", h)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+
+	// queries are not split on paths without the option
+	rsc.PathConfig.SplitQueries = false
+	w = queryRange(q1 + " or " + q2)
+	if h := w.Header().Get(headers.NameTricksterQuerySplit); h != "" {
+		t.Errorf("expected empty header got %s", h)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/ratelimit"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// valueWarningStale is the Warning header value of responses served from an expired cache object
+const valueWarningStale = `110 - "Response is Stale"`
+
+// tracksRateLimit returns true if the origin's rate limit is tracked from the request's upstream
+// response. Fills from a bootstrap peer are not tracked, since they do not count against the limit
+func tracksRateLimit(r *http.Request, oc *oo.Options) bool {
+	return oc != nil && oc.RateLimitRemainingHeader != "" &&
+		r.Header.Get(headers.NameTricksterBootstrap) == ""
+}
+
+// paced returns true if the request's upstream request is paced to the origin's rate limit.
+// Health checks are never paced, so that they reflect the origin's recovery as soon as it occurs
+func paced(r *http.Request, oc *oo.Options) bool {
+	return tracksRateLimit(r, oc) && !tctx.HealthCheckFlag(r.Context())
+}
+
+// servesStale returns true if an expired cache object should be served for the request without
+// revalidation, because upstream requests to the origin are currently throttled
+func servesStale(r *http.Request, rsc *request.Resources) bool {
+	oc := rsc.OriginConfig
+	return paced(r, oc) && oc.RateLimitServeStale && rsc.State().RateLimits.Throttled(oc.Name)
+}
+
+// paceUpstream reserves capacity in the origin's rate limit for the upstream request. While the
+// origin is throttled, the request is queued until the rate limit resets, or is rejected when
+// so configured, or when the reset is further away than the maximum wait. When the request is
+// rejected, the returned response should be used in place of the upstream response
+func paceUpstream(r *http.Request, rsc *request.Resources) *http.Response {
+	oc := rsc.OriginConfig
+	t := rsc.State().RateLimits.Get(oc.Name, oc.OriginType)
+	start := time.Now()
+	for {
+		now := time.Now()
+		wait, changed := t.Reserve(oc.RateLimitMinRemaining, now)
+		if changed {
+			logRateLimitTransition(rsc, t)
+		}
+		if wait <= 0 {
+			return nil
+		}
+		if oc.RateLimitMode == ratelimit.ModeReject || now.Add(wait).Sub(start) > oc.RateLimitMaxWait {
+			return rateLimitedResponse(r, wait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return rateLimitedResponse(r, wait)
+		case <-timer.C:
+		}
+	}
+}
+
+// rateLimitedResponse returns a 429 response for an upstream request that was rejected
+// because the origin is throttled, which may be retried after the provided wait
+func rateLimitedResponse(r *http.Request, wait time.Duration) *http.Response {
+	resp := rejectionResponse(r, txe.NewResponseError(http.StatusTooManyRequests,
+		txe.CodeOriginRateLimited, "the origin's rate limit is exhausted"))
+	resp.Header.Set(headers.NameRetryAfter, strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+	return resp
+}
+
+// observeRateLimit updates the origin's rate limit estimate from the upstream response's rate
+// limit headers, or from its Retry-After header when the origin responded with a 429
+func observeRateLimit(resp *http.Response, rsc *request.Resources) {
+	oc := rsc.OriginConfig
+	now := time.Now()
+	remaining, reset, ok := ratelimit.ParseHeaders(resp.Header,
+		oc.RateLimitRemainingHeader, oc.RateLimitResetHeader, now)
+	if !ok && resp.StatusCode == http.StatusTooManyRequests {
+		reset, ok = ratelimit.ParseRetryAfter(resp.Header, now)
+		remaining = 0
+	}
+	if !ok {
+		return
+	}
+	t := rsc.State().RateLimits.Get(oc.Name, oc.OriginType)
+	if t.Observe(remaining, reset, oc.RateLimitMinRemaining, now) {
+		logRateLimitTransition(rsc, t)
+	}
+}
+
+// logRateLimitTransition logs the origin's transition into or out of the throttled state
+func logRateLimitTransition(rsc *request.Resources, t *ratelimit.Tracker) {
+	oc := rsc.OriginConfig
+	remaining, reset, _ := t.Remaining()
+	pairs := log.Pairs{
+		"originName": oc.Name,
+		"remaining":  remaining,
+		"reset":      reset.Format(time.RFC3339),
+	}
+	if t.Throttled() {
+		rsc.Logger.Warn("origin rate limit is low, pacing upstream requests", pairs)
+		return
+	}
+	rsc.Logger.Info("origin rate limit has recovered, no longer pacing upstream requests", pairs)
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/ratelimit"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+// rateLimitedOrigin returns a test server that reports the rate limit headers returned by
+// the provided func for each request, and the origin options for it, with pacing enabled
+func rateLimitedOrigin(t *testing.T, name string,
+	limit func(n int32) (string, string)) (*httptest.Server, *oo.Options, *int32) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining, reset := limit(atomic.AddInt32(&requests, 1))
+		w.Header().Set("X-RateLimit-Remaining", remaining)
+		w.Header().Set("X-RateLimit-Reset", reset)
+		w.Write([]byte("test"))
+	}))
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", s.URL, "-origin-type", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	oc := conf.Origins["default"]
+	oc.Name = name
+	oc.HTTPClient = http.DefaultClient
+	oc.RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	oc.RateLimitResetHeader = "X-RateLimit-Reset"
+	return s, oc, &requests
+}
+
+func doRateLimitedProxy(oc *oo.Options, url string, healthCheck bool) *http.Response {
+	pc := &po.Options{Path: "/"}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", url, nil)
+	ctx := tc.WithResources(r.Context(),
+		request.NewResources(oc, pc, nil, nil, nil, tu.NewTestTracer(), testLogger))
+	if healthCheck {
+		ctx = tc.WithHealthCheckFlag(ctx, true)
+	}
+	DoProxy(w, r.WithContext(ctx), true)
+	return w.Result()
+}
+
+func TestPaceUpstreamReject(t *testing.T) {
+
+	s, oc, requests := rateLimitedOrigin(t, "test-pace-reject", func(int32) (string, string) {
+		return "1", "60"
+	})
+	defer s.Close()
+	oc.RateLimitMode = ratelimit.ModeReject
+
+	resp := doRateLimitedProxy(oc, s.URL, false)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if !instance.Default.RateLimits.Throttled(oc.Name) {
+		t.Errorf("expected %t got %t", true, false)
+	}
+
+	// the remaining capacity is at the minimum, so the next request is rejected without an upstream request
+	resp = doRateLimitedProxy(oc, s.URL, false)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected %d got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+	if ra, _ := strconv.Atoi(resp.Header.Get(headers.NameRetryAfter)); ra < 59 || ra > 60 {
+		t.Errorf("expected Retry-After of %d got %d", 60, ra)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	if !strings.Contains(string(b), "origin_rate_limited") {
+		t.Errorf("expected origin_rate_limited error got %s", string(b))
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Errorf("expected %d got %d", 1, n)
+	}
+
+	// health checks are not paced
+	resp = doRateLimitedProxy(oc, s.URL, true)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+}
+
+func TestPaceUpstreamQueue(t *testing.T) {
+
+	s, oc, requests := rateLimitedOrigin(t, "test-pace-queue", func(n int32) (string, string) {
+		if n == 1 {
+			return "0", "0.2"
+		}
+		return "10", "60"
+	})
+	defer s.Close()
+
+	resp := doRateLimitedProxy(oc, s.URL, false)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	// the next request is queued until the window resets
+	start := time.Now()
+	resp = doRateLimitedProxy(oc, s.URL, false)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected the request to be queued, but it completed in %s", elapsed)
+	}
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	if instance.Default.RateLimits.Throttled(oc.Name) {
+		t.Errorf("expected %t got %t", false, true)
+	}
+
+	// requests are rejected when the reset is further away than the maximum wait
+	oc.RateLimitMaxWait = 10 * time.Millisecond
+	instance.Default.RateLimits.Get(oc.Name, oc.OriginType).Observe(0, time.Now().Add(2*time.Minute), 1, time.Now())
+	resp = doRateLimitedProxy(oc, s.URL, false)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected %d got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+}
+
+func TestServeStaleWhileThrottled(t *testing.T) {
+
+	hdrs := map[string]string{
+		headers.NameETag:        "test",
+		headers.NameExpires:     time.Now().Add(-time.Hour).UTC().Format(time.RFC1123),
+		"X-RateLimit-Remaining": "1",
+		"X-RateLimit-Reset":     "60",
+	}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	oc := rsc.OriginConfig
+	oc.Name = "test-serve-stale"
+	oc.RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	oc.RateLimitResetHeader = "X-RateLimit-Reset"
+	oc.NegativeCache = map[int]time.Duration{http.StatusTooManyRequests: time.Minute}
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the cached object has expired, but is served rather than revalidated while the origin is throttled
+	w, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := w.Result().Header.Get(headers.NameWarning); v != valueWarningStale {
+		t.Errorf("expected %s got %s", valueWarningStale, v)
+	}
+
+	// when stale objects are not served, the revalidation is paced
+	oc.RateLimitServeStale = false
+	w = httptest.NewRecorder()
+	ObjectProxyCacheRequest(w, r)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected %d got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+	if v := resp.Header.Get(headers.NameWarning); v != "" {
+		t.Errorf("expected empty Warning header got %s", v)
+	}
+
+	// the rejection is not negative cached in place of the expired object, which is still served
+	oc.RateLimitServeStale = true
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+)
+
+// rejectionBody is the body of a response that Trickster generates in place of the upstream
+// response when it rejects an upstream request, and marks the response as Trickster's own
+type rejectionBody struct {
+	io.ReadCloser
+}
+
+// rejectionResponse returns the response to use in place of the upstream response when the
+// upstream request is rejected with the provided error. The rejection describes Trickster's state
+// rather than the origin's, so it is never cached, including by the Negative Cache
+func rejectionResponse(r *http.Request, re *txe.ResponseError) *http.Response {
+	re.WithRequest(r)
+	b := re.Body()
+	h := re.Header()
+	h.Set(headers.NameCacheControl, headers.ValueNoStore)
+	return &http.Response{StatusCode: re.StatusCode, Request: r, Header: h,
+		Body:          rejectionBody{ReadCloser: ioutil.NopCloser(bytes.NewReader(b))},
+		ContentLength: int64(len(b))}
+}
+
+// isRejection returns true if the response was generated by rejectionResponse
+func isRejection(resp *http.Response) bool {
+	if resp == nil {
+		return false
+	}
+	_, ok := resp.Body.(rejectionBody)
+	return ok
+}
+
+// responseNegativeCache returns the Negative Cache map that applies to the upstream response,
+// which is none for a rejection of the upstream request
+func responseNegativeCache(rsc *request.Resources, resp *http.Response) map[int]time.Duration {
+	if isRejection(resp) {
+		return nil
+	}
+	return negativeCache(rsc)
+}
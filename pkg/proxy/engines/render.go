@@ -0,0 +1,50 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// renderFormat returns the name of the format in which the timeseries response to the request is
+// rendered, or an empty string when the response is the client's canonical form
+func renderFormat(r *http.Request, client origins.TimeseriesClient) string {
+	if tr, ok := client.(origins.TimeseriesRenderer); ok {
+		return tr.TimeseriesFormat(r)
+	}
+	return ""
+}
+
+// renderTimeseries returns the body of the response to the request for the timeseries, which
+// is rendered in the requested format when the client is a TimeseriesRenderer, setting the
+// response's Content-Type, or is otherwise the client's canonical form
+func renderTimeseries(r *http.Request, client origins.TimeseriesClient,
+	ts timeseries.Timeseries, header http.Header) ([]byte, error) {
+	if tr, ok := client.(origins.TimeseriesRenderer); ok && tr.TimeseriesFormat(r) != "" {
+		b, contentType, err := tr.RenderTimeseries(ts, r)
+		if err != nil {
+			return nil, err
+		}
+		header.Set(headers.NameContentType, contentType)
+		return b, nil
+	}
+	return client.MarshalTimeseries(ts)
+}
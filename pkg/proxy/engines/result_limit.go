@@ -0,0 +1,48 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+)
+
+// resultLimitWriter buffers a response body so that the client-requested
+// result limit can be applied before it is written to the client
+type resultLimitWriter struct {
+	w       io.Writer
+	buf     *bytes.Buffer
+	limiter origins.ResultLimiter
+	limit   int
+}
+
+func newResultLimitWriter(w io.Writer, limiter origins.ResultLimiter, limit int) *resultLimitWriter {
+	return &resultLimitWriter{w: w, buf: &bytes.Buffer{}, limiter: limiter, limit: limit}
+}
+
+func (lw *resultLimitWriter) Write(b []byte) (int, error) {
+	return lw.buf.Write(b)
+}
+
+// flush writes the limited body to the underlying writer
+func (lw *resultLimitWriter) flush() error {
+	b, _ := lw.limiter.LimitResults(lw.buf.Bytes(), lw.limit)
+	_, err := lw.w.Write(b)
+	return err
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"sync"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+)
+
+// singleUsePruneInterval is how often the expired requests are pruned from the single use log
+const singleUsePruneInterval = time.Minute
+
+// singleUseLog records the uncached requests for the cache keys of paths that assume single use,
+// and when a second request for each key must arrive to be cached
+type singleUseLog struct {
+	mtx       sync.Mutex
+	deadlines map[string]time.Time
+	lastPrune time.Time
+}
+
+var singleUseRequests = &singleUseLog{deadlines: make(map[string]time.Time)}
+
+// admitSingleUse returns true if the response for the cache key may be cached. When the path
+// assumes single use, the response is only cached when the key was requested within the path's
+// window of an earlier request that was not cached
+func admitSingleUse(pc *po.Options, key string) bool {
+	if pc == nil || !pc.AssumeSingleUse {
+		return true
+	}
+	window := time.Duration(pc.AssumeSingleUseWindowSecs) * time.Second
+	if window <= 0 {
+		window = time.Duration(d.DefaultAssumeSingleUseWindowSecs) * time.Second
+	}
+	return singleUseRequests.admit(key, window, time.Now())
+}
+
+// admit records a request for the key, and returns true if it is the second request for the key
+// within the window
+func (l *singleUseLog) admit(key string, window time.Duration, now time.Time) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if now.Sub(l.lastPrune) >= singleUsePruneInterval {
+		for k, deadline := range l.deadlines {
+			if !deadline.After(now) {
+				delete(l.deadlines, k)
+			}
+		}
+		l.lastPrune = now
+	}
+	if deadline, ok := l.deadlines[key]; ok && deadline.After(now) {
+		delete(l.deadlines, key)
+		return true
+	}
+	l.deadlines[key] = now.Add(window)
+	return false
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"testing"
+	"time"
+
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+)
+
+func TestAdmitSingleUse(t *testing.T) {
+
+	if !admitSingleUse(nil, "test") {
+		t.Error("expected true")
+	}
+
+	pc := po.NewOptions()
+	if !admitSingleUse(pc, "test") {
+		t.Error("expected true")
+	}
+
+	// the first request for a key is not cached, and the second request within the window is
+	pc.AssumeSingleUse = true
+	if admitSingleUse(pc, "test.single.use") {
+		t.Error("expected false")
+	}
+	if !admitSingleUse(pc, "test.single.use") {
+		t.Error("expected true")
+	}
+
+	l := &singleUseLog{deadlines: make(map[string]time.Time)}
+	now := time.Now()
+	if l.admit("test", time.Minute, now) {
+		t.Error("expected false")
+	}
+	// a second request after the window is again the first
+	if l.admit("test", time.Minute, now.Add(2*time.Minute)) {
+		t.Error("expected false")
+	}
+	if !l.admit("test", time.Minute, now.Add(150*time.Second)) {
+		t.Error("expected true")
+	}
+	if _, ok := l.deadlines["test"]; ok {
+		t.Error("expected admitted key to be removed")
+	}
+
+	// expired requests are pruned
+	l.admit("test.2", time.Minute, now)
+	l.admit("test.3", time.Minute, now.Add(3*time.Minute))
+	if _, ok := l.deadlines["test.2"]; ok {
+		t.Error("expected expired key to be pruned")
+	}
+}
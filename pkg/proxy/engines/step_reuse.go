@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// maxStepIndexSize is the maximum number of steps tracked in a step index
+const maxStepIndexSize = 16
+
+// stepKeyExtra returns the cache key component of a timeseries request's step, which is always
+// included in Delta Proxy Cache keys, so that documents at different steps never share a key,
+// regardless of whether the origin's path config includes the step in the cache key params
+func stepKeyExtra(step time.Duration) string {
+	return ".step." + step.String()
+}
+
+// parseStepIndex returns the list of steps stored in a step index
+func parseStepIndex(b []byte) []time.Duration {
+	if len(b) == 0 {
+		return nil
+	}
+	parts := strings.Split(string(b), ",")
+	steps := make([]time.Duration, 0, len(parts))
+	for _, p := range parts {
+		if d, err := time.ParseDuration(p); err == nil && d > 0 {
+			steps = append(steps, d)
+		}
+	}
+	return steps
+}
+
+// formatStepIndex returns the serialized form of a step index
+func formatStepIndex(steps []time.Duration) []byte {
+	parts := make([]string, len(steps))
+	for i, s := range steps {
+		parts[i] = s.String()
+	}
+	return []byte(strings.Join(parts, ","))
+}
+
+// updateStepIndex adds the step to the step index, which tracks the steps at which a query has cached
+// documents, so that requests for the same query at a coarser step can find them
+func updateStepIndex(c cache.Cache, indexKey string, step time.Duration, ttl time.Duration) {
+	lk, _ := c.Locker().Acquire(indexKey)
+	defer lk.Release()
+	b, _, _ := c.Retrieve(indexKey, false)
+	steps := parseStepIndex(b)
+	for _, s := range steps {
+		if s == step {
+			c.SetTTL(indexKey, ttl)
+			return
+		}
+	}
+	steps = append(steps, step)
+	sort.Slice(steps, func(i, j int) bool { return steps[i] < steps[j] })
+	if len(steps) > maxStepIndexSize {
+		steps = steps[len(steps)-maxStepIndexSize:]
+	}
+	c.Store(indexKey, formatStepIndex(steps), ttl)
+}
+
+// finerSteps returns the steps from the index that evenly divide the provided step, coarsest first
+func finerSteps(steps []time.Duration, step time.Duration) []time.Duration {
+	out := make([]time.Duration, 0, len(steps))
+	for _, s := range steps {
+		if s > 0 && s < step && step%s == 0 {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] > out[j] })
+	return out
+}
+
+// reuseFinerStep attempts to fulfill the time range query from a cached document at a finer step that
+// evenly divides the requested step and fully covers the requested extent. On success, it returns the
+// downsampled timeseries, the cached document it was sampled from, and the step of that document
+func reuseFinerStep(ctx context.Context, pr *proxyRequest, trq *timeseries.TimeRangeQuery,
+	client origins.TimeseriesClient, indexKey string) (timeseries.Timeseries, *HTTPDocument, time.Duration, bool) {
+
+	rsc := request.GetResources(pr.Request)
+	oc := rsc.OriginConfig
+	c := rsc.CacheClient
+
+	b, _, err := c.Retrieve(indexKey, false)
+	if err != nil {
+		return nil, nil, 0, false
+	}
+
+	for _, step := range finerSteps(parseStepIndex(b), trq.Step) {
+		key := oc.CacheKeyPrefix + ".dpc." + pr.DeriveCacheKey(trq.TemplateURL, stepKeyExtra(step))
+		lk, _ := c.Locker().RAcquire(key)
+		doc, lookupStatus, _, err := QueryCache(ctx, c, key, nil)
+		if err != nil || lookupStatus != status.LookupStatusHit || doc == nil {
+			lk.RRelease()
+			continue
+		}
+		var cts timeseries.Timeseries
+		if c.Configuration().CacheType == "memory" {
+			cts = doc.timeseries
+		} else {
+			cts, err = client.UnmarshalTimeseries(doc.Body)
+		}
+		if err != nil {
+			discardCacheObject(pr.Logger, c, key,
+				&cacheDecodeError{class: decodeFailureUnmarshal, err: err})
+		}
+		if err != nil || cts == nil {
+			lk.RRelease()
+			continue
+		}
+		if _, ok := cts.(timeseries.Downsampler); !ok {
+			// the origin's timeseries can't be sampled, so no step can be reused
+			lk.RRelease()
+			return nil, nil, 0, false
+		}
+		if len(trq.CalculateDeltas(cts.Extents())) > 0 {
+			lk.RRelease()
+			continue
+		}
+		rts := cts.Clone()
+		lk.RRelease()
+		rts.CropToRange(trq.Extent)
+		rts.(timeseries.Downsampler).Downsample(trq.Step)
+		pr.Logger.Debug("fulfilled timeseries request from cached document at a finer step",
+			tl.Pairs{"cacheKey": key, "step": trq.Step, "cachedStep": step})
+		return rts, doc, step, true
+	}
+	return nil, nil, 0, false
+}
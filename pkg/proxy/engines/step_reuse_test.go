@@ -0,0 +1,173 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+
+	mockprom "github.com/tricksterproxy/mockster/pkg/mocks/prometheus"
+)
+
+func TestStepIndex(t *testing.T) {
+
+	steps := []time.Duration{15 * time.Second, time.Minute}
+	b := formatStepIndex(steps)
+	if string(b) != "15s,1m0s" {
+		t.Errorf("expected %s got %s", "15s,1m0s", string(b))
+	}
+	if s := parseStepIndex(b); !reflect.DeepEqual(s, steps) {
+		t.Errorf("expected %v got %v", steps, s)
+	}
+	if s := parseStepIndex(nil); s != nil {
+		t.Errorf("expected nil got %v", s)
+	}
+	if s := parseStepIndex([]byte("15s,x")); len(s) != 1 {
+		t.Errorf("expected %d got %d", 1, len(s))
+	}
+}
+
+func TestFinerSteps(t *testing.T) {
+
+	steps := []time.Duration{10 * time.Second, 15 * time.Second, 20 * time.Second,
+		time.Minute, 5 * time.Minute}
+
+	tests := []struct {
+		step     time.Duration
+		expected []time.Duration
+	}{
+		{time.Minute, []time.Duration{20 * time.Second, 15 * time.Second, 10 * time.Second}},
+		{90 * time.Second, []time.Duration{15 * time.Second, 10 * time.Second}},
+		{45 * time.Second, []time.Duration{15 * time.Second}},
+		{10 * time.Second, []time.Duration{}},
+		{7 * time.Second, []time.Duration{}},
+	}
+
+	for _, test := range tests {
+		if s := finerSteps(steps, test.step); !reflect.DeepEqual(s, test.expected) {
+			t.Errorf("%s: expected %v got %v", test.step, test.expected, s)
+		}
+	}
+}
+
+func testDPCStepRequest(r *http.Request, client *TestClient, extr timeseries.Extent,
+	step time.Duration, match map[string]string) (*http.Response, string, error) {
+
+	r.URL.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	w := httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if err := testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+		return resp, string(b), err
+	}
+	err := testResultHeaderPartMatch(resp.Header, match)
+	// Give time for the object to be written to cache in a separate goroutine from response
+	time.Sleep(time.Millisecond * 10)
+	return resp, string(b), err
+}
+
+func TestDeltaProxyCacheRequestStepInCacheKey(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	rsc.OriginConfig.FastForwardDisable = true
+
+	end := time.Now().Add(-time.Duration(12) * time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(6) * time.Hour), End: end}
+
+	// the harness's cache key params exclude the step, but requests at different steps
+	// must still use separate documents
+	for _, step := range []time.Duration{time.Minute, 5 * time.Minute} {
+		if _, _, err := testDPCStepRequest(r, client, extr, step,
+			map[string]string{"status": "kmiss"}); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDeltaProxyCacheRequestReuseCoarserStep(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	oc.FastForwardDisable = true
+	oc.ReuseCoarserStep = true
+
+	// align the range to the coarsest step, so the 1m document covers each coarser request
+	end := time.Now().Add(-time.Duration(4) * time.Hour).Truncate(time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(6) * time.Hour), End: end}
+
+	if _, _, err := testDPCStepRequest(r, client, extr, time.Minute,
+		map[string]string{"status": "kmiss"}); err != nil {
+		t.Error(err)
+	}
+
+	// 5m is evenly divisible by 1m, so it is sampled from the 1m document
+	step := 5 * time.Minute
+	resp, body, err := testDPCStepRequest(r, client, extr, step, map[string]string{"status": "hit"})
+	if err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameTricksterStepReuse); v != "step=5m0s; cachedStep=1m0s" {
+		t.Errorf("expected %s got %s", "step=5m0s; cachedStep=1m0s", v)
+	}
+	expected, _, _ := mockprom.GetTimeSeriesData(queryReturnsOKNoLatency,
+		extr.Start.Truncate(step), extr.End.Truncate(step), step)
+	if err = testStringMatch(body, expected); err != nil {
+		t.Error(err)
+	}
+
+	// 90s is not evenly divisible by 1m, so it falls back to its own document
+	resp, _, err = testDPCStepRequest(r, client, extr, 90*time.Second, map[string]string{"status": "kmiss"})
+	if err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameTricksterStepReuse); v != "" {
+		t.Errorf("expected empty header got %s", v)
+	}
+
+	// a range not fully covered by the 1m document falls back to its own document
+	extr2 := timeseries.Extent{Start: extr.Start.Add(-time.Hour), End: extr.End}
+	resp, _, err = testDPCStepRequest(r, client, extr2, 2*time.Minute, map[string]string{"status": "kmiss"})
+	if err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameTricksterStepReuse); v != "" {
+		t.Errorf("expected empty header got %s", v)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// acceptsFrames returns true if the request's Accept header lists the newline-delimited
+// JSON media type, indicating the client can render a timeseries response as it streams
+func acceptsFrames(r *http.Request) bool {
+	for _, v := range r.Header[headers.NameAccept] {
+		for _, part := range strings.Split(v, ",") {
+			mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err == nil && mt == headers.ValueApplicationNDJSON {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addVary adds the header name to the Vary header, which is collapsed to a single value,
+// since the headers are merged into the response by their first value
+func addVary(h http.Header, name string) {
+	if v := h.Get(headers.NameVary); v != "" {
+		h.Set(headers.NameVary, v+", "+name)
+		return
+	}
+	h.Set(headers.NameVary, name)
+}
+
+// respondFrames sends an HTTP Response to the requesting client, with the timeseries rendered as
+// newline-delimited JSON frames that are each flushed as they are written. Since the length of
+// the body is not known in advance, the response is chunked
+func respondFrames(w io.Writer, code int, header http.Header,
+	ts timeseries.Timeseries, fs origins.FrameStreamer) error {
+	header.Set(headers.NameContentType, headers.ValueApplicationNDJSON)
+	header.Del(headers.NameContentLength)
+	writer := PrepareResponseWriter(w, code, header)
+	flush := func() {}
+	if f, ok := w.(http.Flusher); ok {
+		flush = f.Flush
+	}
+	return fs.StreamTimeseries(ts, writer, flush)
+}
+
+// copyWithFlush copies from src to dst until EOF, flushing dst after each read from src,
+// so that the downstream response preserves the flush boundaries of the upstream response
+func copyWithFlush(dst io.Writer, src io.Reader) (int64, error) {
+	f, ok := dst.(http.Flusher)
+	if !ok {
+		return io.Copy(dst, src)
+	}
+	var written int64
+	buf := make([]byte, HTTPBlockSize)
+	for {
+		n, rerr := src.Read(buf)
+		if n > 0 {
+			wn, werr := dst.Write(buf[:n])
+			written += int64(wn)
+			if werr != nil {
+				return written, werr
+			}
+			f.Flush()
+		}
+		if rerr == io.EOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, rerr
+		}
+	}
+}
+
+// bodyAllowedForStatus returns true if a response with the provided status code may include a body
+func bodyAllowedForStatus(code int) bool {
+	switch {
+	case code >= 100 && code <= 199:
+		return false
+	case code == http.StatusNoContent, code == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// frameStreamer returns the client as a FrameStreamer when the request negotiated a streamed
+// response of the provided status code, or nil otherwise. Since a FrameStreamer's responses
+// vary by the request's Accept header, Accept is added to the response's Vary header
+func frameStreamer(r *http.Request, client origins.TimeseriesClient, code int,
+	header http.Header) origins.FrameStreamer {
+	fs, ok := client.(origins.FrameStreamer)
+	if !ok {
+		return nil
+	}
+	addVary(header, headers.NameAccept)
+	if code != http.StatusOK || !acceptsFrames(r) {
+		return nil
+	}
+	return fs
+}
@@ -0,0 +1,385 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+// rawGet sends a GET request for the path over a new connection to the server, and returns the
+// connection's reader positioned at the start of the response body, along with the response's
+// status line and headers exactly as they were received
+func rawGet(t *testing.T, ts *httptest.Server, path string,
+	h map[string]string) (net.Conn, *bufio.Reader, string, map[string]string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	req := "GET " + path + " HTTP/1.1\r\nHost: " + ts.Listener.Addr().String() + "\r\n"
+	for k, v := range h {
+		req += k + ": " + v + "\r\n"
+	}
+	if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	statusLine, err := br.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	rh := make(map[string]string)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) == 2 {
+			rh[parts[0]] = parts[1]
+		}
+	}
+	return conn, br, strings.TrimRight(statusLine, "\r\n"), rh
+}
+
+// readRawChunk reads one chunk of a chunked response body from the reader
+func readRawChunk(t *testing.T, br *bufio.Reader) string {
+	t.Helper()
+	line, err := br.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.ParseInt(strings.TrimRight(line, "\r\n"), 16, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, n+2)
+	if _, err := io.ReadFull(br, b); err != nil {
+		t.Fatal(err)
+	}
+	return string(b[:n])
+}
+
+func newTestProxyServer(t *testing.T, originURL string, pc *po.Options) *httptest.Server {
+	t.Helper()
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", originURL, "-origin-type", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	oc := conf.Origins["default"]
+	oc.HTTPClient = http.DefaultClient
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Scheme = oc.Scheme
+		r.URL.Host = oc.Host
+		r = r.WithContext(tc.WithResources(r.Context(),
+			request.NewResources(oc, pc, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+		DoProxy(w, r, true)
+	}))
+}
+
+func TestDoProxyChunkedFlush(t *testing.T) {
+
+	release := make(chan bool)
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("part1"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("part2"))
+	}))
+	defer es.Close()
+
+	ps := newTestProxyServer(t, es.URL, po.NewOptions())
+	defer ps.Close()
+
+	conn, br, statusLine, rh := rawGet(t, ps, "/", nil)
+	defer conn.Close()
+
+	if statusLine != "HTTP/1.1 200 OK" {
+		t.Errorf("expected %s got %s", "HTTP/1.1 200 OK", statusLine)
+	}
+	if rh[headers.NameTransferEncoding] != "chunked" {
+		t.Errorf("expected chunked response, got headers %v", rh)
+	}
+	if v, ok := rh[headers.NameContentLength]; ok {
+		t.Errorf("expected no Content-Length, got %s", v)
+	}
+
+	// the first part must arrive before the origin completes its response
+	if s := readRawChunk(t, br); s != "part1" {
+		t.Errorf("expected %s got %s", "part1", s)
+	}
+	close(release)
+	if s := readRawChunk(t, br); s != "part2" {
+		t.Errorf("expected %s got %s", "part2", s)
+	}
+	if s := readRawChunk(t, br); s != "" {
+		t.Errorf("expected terminating chunk, got %s", s)
+	}
+}
+
+func TestDoProxyContentLength(t *testing.T) {
+
+	// the body is larger than the server's buffer, so would be chunked without a Content-Length
+	body := strings.Repeat("x", 3*HTTPBlockSize)
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.NameContentLength, strconv.Itoa(len(body)))
+		w.Write([]byte(body))
+	}))
+	defer es.Close()
+
+	ps := newTestProxyServer(t, es.URL, po.NewOptions())
+	defer ps.Close()
+
+	conn, br, _, rh := rawGet(t, ps, "/", nil)
+	defer conn.Close()
+
+	if v, ok := rh[headers.NameTransferEncoding]; ok {
+		t.Errorf("expected no Transfer-Encoding, got %s", v)
+	}
+	if rh[headers.NameContentLength] != strconv.Itoa(len(body)) {
+		t.Errorf("expected %d got %s", len(body), rh[headers.NameContentLength])
+	}
+	b := make([]byte, len(body))
+	if _, err := io.ReadFull(br, b); err != nil {
+		t.Error(err)
+	}
+	if string(b) != body {
+		t.Error("body mismatch")
+	}
+
+	// a custom response body is sent with its own length
+	pc := po.NewOptions()
+	pc.ResponseBodyBytes = []byte("custom")
+	pc.HasCustomResponseBody = true
+	ps2 := newTestProxyServer(t, es.URL, pc)
+	defer ps2.Close()
+
+	conn2, _, _, rh := rawGet(t, ps2, "/", nil)
+	defer conn2.Close()
+	if rh[headers.NameContentLength] != "6" {
+		t.Errorf("expected %d got %s", 6, rh[headers.NameContentLength])
+	}
+}
+
+func TestRespondContentLength(t *testing.T) {
+
+	body := []byte(strings.Repeat("x", 3*HTTPBlockSize))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/304" {
+			Respond(w, http.StatusNotModified, nil, nil)
+			return
+		}
+		Respond(w, http.StatusOK, http.Header{headers.NameContentLength: []string{"1"}}, body)
+	}))
+	defer ts.Close()
+
+	conn, _, _, rh := rawGet(t, ts, "/", nil)
+	defer conn.Close()
+	if v, ok := rh[headers.NameTransferEncoding]; ok {
+		t.Errorf("expected no Transfer-Encoding, got %s", v)
+	}
+	if rh[headers.NameContentLength] != strconv.Itoa(len(body)) {
+		t.Errorf("expected %d got %s", len(body), rh[headers.NameContentLength])
+	}
+
+	conn2, _, statusLine, rh := rawGet(t, ts, "/304", nil)
+	defer conn2.Close()
+	if statusLine != "HTTP/1.1 304 Not Modified" {
+		t.Errorf("expected %s got %s", "HTTP/1.1 304 Not Modified", statusLine)
+	}
+	if v, ok := rh[headers.NameContentLength]; ok {
+		t.Errorf("expected no Content-Length, got %s", v)
+	}
+}
+
+func TestAcceptsFrames(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/x-ndjson", true},
+		{"application/json, application/x-ndjson;q=0.9", true},
+		{"text/plain,application/x-ndjson ; charset=utf-8", true},
+	}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+			if test.accept != "" {
+				r.Header.Set(headers.NameAccept, test.accept)
+			}
+			if v := acceptsFrames(r); v != test.expected {
+				t.Errorf("expected %t got %t", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{}
+	addVary(h, headers.NameAccept)
+	if v := h.Get(headers.NameVary); v != "Accept" {
+		t.Errorf("expected %s got %s", "Accept", v)
+	}
+	h.Set(headers.NameVary, "Accept-Encoding")
+	addVary(h, headers.NameAccept)
+	if v := h.Get(headers.NameVary); v != "Accept-Encoding, Accept" {
+		t.Errorf("expected %s got %s", "Accept-Encoding, Accept", v)
+	}
+}
+
+func TestDeltaProxyCacheRequestFrames(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	rsc.OriginConfig.FastForwardDisable = true
+
+	fs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r2 *http.Request) {
+		r2 = r2.WithContext(tc.WithResources(r2.Context(), rsc.Clone()))
+		client.QueryRangeHandler(w, r2)
+	}))
+	defer fs.Close()
+
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour).Truncate(step)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(6) * time.Hour), End: end}
+	path := fmt.Sprintf("%s?step=%d&start=%d&end=%d&query=%s", r.URL.Path,
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	// first request is a miss, and fills the cache. it is a JSON document with a Content-Length
+	conn, br, _, rh := rawGet(t, fs, path, nil)
+	defer conn.Close()
+	if v, ok := rh[headers.NameTransferEncoding]; ok {
+		t.Errorf("expected no Transfer-Encoding, got %s", v)
+	}
+	cl, err := strconv.Atoi(rh[headers.NameContentLength])
+	if err != nil {
+		t.Fatalf("expected Content-Length, got headers %v", rh)
+	}
+	b := make([]byte, cl)
+	if _, err := io.ReadFull(br, b); err != nil {
+		t.Fatal(err)
+	}
+	me := &MatrixEnvelope{}
+	if err := json.Unmarshal(b, me); err != nil {
+		t.Fatal(err)
+	}
+	if rh[headers.NameVary] != headers.NameAccept {
+		t.Errorf("expected %s got %s", headers.NameAccept, rh[headers.NameVary])
+	}
+	etag := rh[headers.NameETag]
+
+	// second request negotiates frames, and is served from cache as a chunked stream
+	conn2, br2, statusLine, rh := rawGet(t, fs, path,
+		map[string]string{headers.NameAccept: headers.ValueApplicationNDJSON})
+	defer conn2.Close()
+	if statusLine != "HTTP/1.1 200 OK" {
+		t.Errorf("expected %s got %s", "HTTP/1.1 200 OK", statusLine)
+	}
+	if rh[headers.NameTransferEncoding] != "chunked" {
+		t.Errorf("expected chunked response, got headers %v", rh)
+	}
+	if v, ok := rh[headers.NameContentLength]; ok {
+		t.Errorf("expected no Content-Length, got %s", v)
+	}
+	if rh[headers.NameContentType] != headers.ValueApplicationNDJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationNDJSON, rh[headers.NameContentType])
+	}
+	if !strings.Contains(rh[headers.NameTricksterResult], "status=hit") {
+		t.Errorf("expected cache hit, got %s", rh[headers.NameTricksterResult])
+	}
+	if rh[headers.NameETag] == "" || rh[headers.NameETag] == etag {
+		t.Errorf("expected a distinct validator for the streamed response, got %s", rh[headers.NameETag])
+	}
+
+	// each frame is flushed in its own chunk
+	frames := make([]string, 0, len(me.Data.Result)+1)
+	for {
+		s := readRawChunk(t, br2)
+		if s == "" {
+			break
+		}
+		frames = append(frames, s)
+	}
+	if len(frames) != len(me.Data.Result)+1 {
+		t.Fatalf("expected %d frames got %d", len(me.Data.Result)+1, len(frames))
+	}
+	if frames[0] != `{"resultType":"matrix","status":"success"}`+"\n" {
+		t.Errorf("unexpected header frame %s", frames[0])
+	}
+	for i, f := range frames[1:] {
+		if !strings.HasSuffix(f, "\n") {
+			t.Errorf("expected newline-terminated frame, got %s", f)
+		}
+		expected, _ := json.Marshal(me.Data.Result[i])
+		if strings.TrimSuffix(f, "\n") != string(expected) {
+			t.Errorf("expected %s got %s", string(expected), f)
+		}
+	}
+}
+
+func TestCopyWithFlush(t *testing.T) {
+	// a writer that cannot flush is copied to directly
+	w := &strings.Builder{}
+	n, err := copyWithFlush(w, strings.NewReader("test"))
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 4 || w.String() != "test" {
+		t.Errorf("expected %s got %s", "test", w.String())
+	}
+
+	rec := httptest.NewRecorder()
+	n, err = copyWithFlush(rec, ioutil.NopCloser(strings.NewReader("test")))
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 4 || !rec.Flushed || rec.Body.String() != "test" {
+		t.Errorf("expected flushed %s got %s", "test", rec.Body.String())
+	}
+}
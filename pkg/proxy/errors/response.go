@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package errors
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+// Codes are stable, machine-readable identifiers for the errors that Trickster generates,
+// which are included in the body of the error responses
+const (
+	CodeBadRequest               = "bad_request"
+	CodeDuplicateParams          = "duplicate_params"
+	CodeForbidden                = "forbidden"
+	CodeHealthCheckInvalid       = "health_check_invalid"
+	CodeHealthCheckNotConfigured = "health_check_not_configured"
+	CodeIdempotencyKeyReused     = "idempotency_key_reused"
+	CodeInflightLimit            = "inflight_processing_limit"
+	CodeInternal                 = "internal_error"
+	CodeMaxEstimatedPoints       = "max_estimated_points_exceeded"
+	CodeMaxLookbackExceeded      = "max_lookback_exceeded"
+	CodeMethodNotAllowed         = "method_not_allowed"
+	CodeNotFound                 = "not_found"
+	CodeOriginConcurrencyLimit   = "origin_concurrency_limit"
+	CodeOriginRateLimited        = "origin_rate_limited"
+	CodeOriginTimeout            = "origin_timeout"
+	CodeOriginUnhealthy          = "origin_unhealthy"
+	CodeOriginUnreachable        = "origin_unreachable"
+	CodeRequestTooLarge          = "request_too_large"
+	CodeRequestURITooLong        = "request_uri_too_long"
+	CodeUnauthorized             = "unauthorized"
+)
+
+// ResponseError is an error generated by Trickster, rather than by an upstream origin,
+// that is rendered to the client as a JSON document
+type ResponseError struct {
+	StatusCode int    `json:"status"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Origin     string `json:"origin,omitempty"`
+	Path       string `json:"path,omitempty"`
+	TraceID    string `json:"traceId,omitempty"`
+	// Details provides additional machine-readable information about the error
+	Details map[string]interface{} `json:"details,omitempty"`
+	// OriginType determines the shape of the document, so that datasources of origin types
+	// having their own error envelope can render the error natively
+	OriginType string `json:"-"`
+}
+
+// prometheusError is the error envelope of the Prometheus HTTP API
+type prometheusError struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+}
+
+// NewResponseError returns a new ResponseError
+func NewResponseError(statusCode int, code, message string) *ResponseError {
+	return &ResponseError{StatusCode: statusCode, Code: code, Message: message}
+}
+
+// UpstreamError returns a 502 Bad Gateway ResponseError for an upstream request that
+// failed with err, which distinguishes timeouts from other connection failures
+func UpstreamError(err error) *ResponseError {
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return NewResponseError(http.StatusBadGateway, CodeOriginTimeout,
+			"timed out waiting for a response from the origin")
+	}
+	return NewResponseError(http.StatusBadGateway, CodeOriginUnreachable,
+		"unable to get a response from the origin")
+}
+
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// WithRequest populates the origin and path names, and the trace ID, of the ResponseError
+// from the request, for each that is not already set
+func (e *ResponseError) WithRequest(r *http.Request) *ResponseError {
+	if r == nil {
+		return e
+	}
+	if rsc := request.GetResources(r); rsc != nil {
+		if rsc.OriginConfig != nil && e.Origin == "" {
+			e.Origin = rsc.OriginConfig.Name
+			e.OriginType = rsc.OriginConfig.OriginType
+		}
+		if rsc.PathConfig != nil && e.Path == "" {
+			e.Path = rsc.PathConfig.Path
+		}
+	}
+	if e.TraceID == "" {
+		if sc := trace.SpanFromContext(r.Context()).SpanContext(); sc.HasTraceID() {
+			e.TraceID = sc.TraceID.String()
+		}
+	}
+	return e
+}
+
+// Body returns the JSON document describing the error. For Prometheus origins, this is
+// the Prometheus HTTP API error envelope
+func (e *ResponseError) Body() []byte {
+	var b []byte
+	if e.OriginType == "prometheus" {
+		b, _ = json.Marshal(prometheusError{Status: "error", ErrorType: e.Code, Error: e.Message})
+	} else {
+		b, _ = json.Marshal(e)
+	}
+	return b
+}
+
+// Header returns the headers of the error response
+func (e *ResponseError) Header() http.Header {
+	return http.Header{
+		headers.NameContentType: []string{headers.ValueApplicationJSON},
+	}
+}
+
+// Respond writes the ResponseError to the client, populated from the request
+func (e *ResponseError) Respond(w http.ResponseWriter, r *http.Request) {
+	e.WithRequest(r)
+	h := w.Header()
+	for k, v := range e.Header() {
+		h[k] = v
+	}
+	w.WriteHeader(e.StatusCode)
+	w.Write(e.Body())
+}
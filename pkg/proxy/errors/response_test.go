@@ -0,0 +1,108 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package errors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+)
+
+type testTimeoutError struct{}
+
+func (e testTimeoutError) Error() string   { return "timeout" }
+func (e testTimeoutError) Timeout() bool   { return true }
+func (e testTimeoutError) Temporary() bool { return true }
+
+func TestResponseError(t *testing.T) {
+
+	oc := &oo.Options{Name: "test", OriginType: "rpc"}
+	pc := &po.Options{Path: "/api"}
+	r := httptest.NewRequest(http.MethodGet, "http://0/api", nil)
+	r = r.WithContext(tc.WithResources(r.Context(),
+		request.NewResources(oc, pc, nil, nil, nil, nil, nil)))
+
+	w := httptest.NewRecorder()
+	NewResponseError(http.StatusBadRequest, CodeBadRequest, "test message").Respond(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+	if ct := w.Header().Get(headers.NameContentType); ct != headers.ValueApplicationJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationJSON, ct)
+	}
+	expected := `{"status":400,"code":"bad_request","message":"test message","origin":"test","path":"/api"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+
+	// prometheus origins receive the Prometheus error envelope
+	oc.OriginType = "prometheus"
+	w = httptest.NewRecorder()
+	NewResponseError(http.StatusBadRequest, CodeBadRequest, "test message").Respond(w, r)
+	expected = `{"status":"error","errorType":"bad_request","error":"test message"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+
+	// values already set on the error are not replaced by the request's resources
+	e := NewResponseError(http.StatusBadRequest, CodeBadRequest, "test message")
+	e.Origin = "other"
+	e.WithRequest(r)
+	if e.Origin != "other" || e.Path != "/api" {
+		t.Errorf("unexpected origin %s and path %s", e.Origin, e.Path)
+	}
+
+	// requests without resources are rendered without the origin and path names
+	e = NewResponseError(http.StatusNotFound, CodeNotFound, "test message").
+		WithRequest(httptest.NewRequest(http.MethodGet, "http://0/", nil))
+	expected = `{"status":404,"code":"not_found","message":"test message"}`
+	if string(e.Body()) != expected {
+		t.Errorf("expected %s got %s", expected, string(e.Body()))
+	}
+	if e.Error() != "test message" {
+		t.Errorf("expected %s got %s", "test message", e.Error())
+	}
+
+	// details are rendered when present
+	e.Details = map[string]interface{}{"limit": 10}
+	expected = `{"status":404,"code":"not_found","message":"test message","details":{"limit":10}}`
+	if string(e.Body()) != expected {
+		t.Errorf("expected %s got %s", expected, string(e.Body()))
+	}
+}
+
+func TestUpstreamError(t *testing.T) {
+
+	e := UpstreamError(testTimeoutError{})
+	if e.StatusCode != http.StatusBadGateway || e.Code != CodeOriginTimeout {
+		t.Errorf("expected %d %s got %d %s", http.StatusBadGateway, CodeOriginTimeout,
+			e.StatusCode, e.Code)
+	}
+
+	e = UpstreamError(context.Canceled)
+	if e.StatusCode != http.StatusBadGateway || e.Code != CodeOriginUnreachable {
+		t.Errorf("expected %d %s got %d %s", http.StatusBadGateway, CodeOriginUnreachable,
+			e.StatusCode, e.Code)
+	}
+}
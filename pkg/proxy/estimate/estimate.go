@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package estimate enumerates how a timeseries request whose results are estimated to exceed its
+// path's max estimated points is handled, and estimates the size of a request's results
+package estimate
+
+import (
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// Action enumerates the ways that a timeseries request whose results are estimated to exceed its
+// path's max estimated points is handled
+type Action int
+
+const (
+	// ActionReject indicates that the request is rejected with a 413 Request Entity Too Large
+	ActionReject = Action(iota)
+	// ActionRoute indicates that the request is routed to the path's designated origin, which is
+	// typically a rule that routes the request to an origin suited to large queries
+	ActionRoute
+)
+
+// Names is a map of Actions keyed by string name
+var Names = map[string]Action{
+	"reject": ActionReject,
+	"route":  ActionRoute,
+}
+
+// Values is a map of Actions valued by string name
+var Values = make(map[Action]string)
+
+func init() {
+	for k, v := range Names {
+		Values[v] = k
+	}
+}
+
+func (a Action) String() string {
+	if v, ok := Values[a]; ok {
+		return v
+	}
+	return strconv.Itoa(int(a))
+}
+
+// Points returns the estimated number of points in the results of a query for the extent at the
+// step, having the provided number of series, each with a point at every step of the extent
+func Points(series float64, e timeseries.Extent, step time.Duration) int64 {
+	if series <= 0 || step <= 0 || e.End.Before(e.Start) {
+		return 0
+	}
+	return int64(math.Ceil(series * float64(e.End.Sub(e.Start)/step+1)))
+}
+
+// Bypassed returns true if the request's X-Trickster-Estimate-Bypass header holds the token, which
+// exempts the request from its max estimated points. No request is exempt when token is empty
+func Bypassed(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	v := r.Header.Get(headers.NameTricksterEstimateBypass)
+	return v != "" && subtle.ConstantTimeCompare([]byte(v), []byte(token)) == 1
+}
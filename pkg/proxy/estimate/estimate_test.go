@@ -0,0 +1,86 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package estimate
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestActionString(t *testing.T) {
+	if ActionReject.String() != "reject" {
+		t.Errorf("expected %s got %s", "reject", ActionReject.String())
+	}
+	if ActionRoute.String() != "route" {
+		t.Errorf("expected %s got %s", "route", ActionRoute.String())
+	}
+	if Action(5).String() != "5" {
+		t.Errorf("expected %s got %s", "5", Action(5).String())
+	}
+}
+
+func TestPoints(t *testing.T) {
+
+	e := timeseries.Extent{Start: time.Unix(0, 0), End: time.Unix(3600, 0)}
+
+	tests := []struct {
+		series   float64
+		extent   timeseries.Extent
+		step     time.Duration
+		expected int64
+	}{
+		{10, e, time.Minute, 610},
+		{2.5, e, time.Hour, 5},
+		{0, e, time.Minute, 0},
+		{10, e, 0, 0},
+		{10, timeseries.Extent{Start: e.End, End: e.Start}, time.Minute, 0},
+	}
+
+	for i, test := range tests {
+		if v := Points(test.series, test.extent, test.step); v != test.expected {
+			t.Errorf("(%d) expected %d got %d", i, test.expected, v)
+		}
+	}
+}
+
+func TestBypassed(t *testing.T) {
+
+	tests := []struct {
+		header, token string
+		expected      bool
+	}{
+		{"secret", "secret", true},
+		{"secret", "other", false},
+		{"", "secret", false},
+		{"", "", false},
+		{"secret", "", false},
+	}
+
+	for i, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+		if test.header != "" {
+			r.Header.Set(headers.NameTricksterEstimateBypass, test.header)
+		}
+		if v := Bypassed(r, test.token); v != test.expected {
+			t.Errorf("(%d) expected %t got %t", i, test.expected, v)
+		}
+	}
+}
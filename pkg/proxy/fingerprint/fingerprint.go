@@ -0,0 +1,263 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fingerprint identifies timeseries queries by a hash of their normalized text,
+// so that the costs of similar queries can be tracked together without retaining the
+// unbounded and potentially sensitive query text itself
+package fingerprint
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// Placeholder replaces the literal values that are stripped from normalized queries
+const Placeholder = "?"
+
+// TokenType enumerates the types of Tokens in a query
+type TokenType int
+
+const (
+	// TokenSymbol is an operator or punctuation character
+	TokenSymbol = TokenType(iota)
+	// TokenWord is an identifier or keyword
+	TokenWord
+	// TokenNumber is a numeric literal, including durations like 5m
+	TokenNumber
+	// TokenString is a quoted literal, including its quotes
+	TokenString
+	// TokenRegex is a regular expression literal delimited by slashes, including the slashes
+	TokenRegex
+)
+
+// Token is a lexical element of a query
+type Token struct {
+	Type  TokenType
+	Value string
+	// Spaced is true when the Token was preceded by whitespace or a comment
+	Spaced bool
+}
+
+// Syntax describes the lexical features of a query language
+type Syntax struct {
+	// Quotes lists the characters that delimit quoted tokens
+	Quotes string
+	// LineComment is the sequence that begins a comment extending to the end of the line
+	LineComment string
+	// BlockComments, when true, indicates that /* */ delimits comments
+	BlockComments bool
+	// RegexOperators lists the operators that may be followed by a slash-delimited regular expression
+	RegexOperators []string
+}
+
+// operatorChars are the symbol characters that combine into a single operator Token (e.g., !=)
+const operatorChars = "=!<>~:|&"
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isWordChar(c byte) bool {
+	// bytes of multi-byte characters, like the µ duration unit, are treated as word characters
+	return isWordStart(c) || isDigit(c) || c == ':' || c >= 0x80
+}
+
+// Tokenize splits the query into Tokens according to the Syntax. Whitespace and comments
+// are discarded, and are only reflected in the Spaced field of the Token that follows them
+func Tokenize(query string, s Syntax) []Token {
+	tokens := make([]Token, 0, len(query)/2)
+	spaced := false
+	emit := func(t TokenType, v string) {
+		tokens = append(tokens, Token{Type: t, Value: v, Spaced: spaced})
+		spaced = false
+	}
+	// regexAllowed is true when the preceding token is one of the RegexOperators
+	regexAllowed := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		last := tokens[len(tokens)-1]
+		if last.Type != TokenSymbol {
+			return false
+		}
+		for _, op := range s.RegexOperators {
+			if last.Value == op {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case isSpace(c):
+			spaced = true
+			i++
+		case s.LineComment != "" && strings.HasPrefix(query[i:], s.LineComment):
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				j = len(query) - i
+			}
+			spaced = true
+			i += j
+		case s.BlockComments && strings.HasPrefix(query[i:], "/*"):
+			j := strings.Index(query[i+2:], "*/")
+			if j < 0 {
+				j = len(query) - i - 4
+			}
+			spaced = true
+			i += j + 4
+		case strings.IndexByte(s.Quotes, c) >= 0:
+			j := scanQuoted(query, i, c)
+			emit(TokenString, query[i:j])
+			i = j
+		case c == '/' && regexAllowed():
+			j := scanQuoted(query, i, c)
+			emit(TokenRegex, query[i:j])
+			i = j
+		case isDigit(c) || (c == '.' && i+1 < len(query) && isDigit(query[i+1])):
+			j := i + 1
+			for j < len(query) && (isWordChar(query[j]) || query[j] == '.') && query[j] != ':' {
+				j++
+			}
+			emit(TokenNumber, query[i:j])
+			i = j
+		case isWordStart(c):
+			j := i + 1
+			for j < len(query) && isWordChar(query[j]) {
+				j++
+			}
+			emit(TokenWord, query[i:j])
+			i = j
+		case strings.IndexByte(operatorChars, c) >= 0:
+			j := i + 1
+			for j < len(query) && strings.IndexByte(operatorChars, query[j]) >= 0 {
+				j++
+			}
+			emit(TokenSymbol, query[i:j])
+			i = j
+		default:
+			emit(TokenSymbol, query[i:i+1])
+			i++
+		}
+	}
+	return tokens
+}
+
+// scanQuoted returns the index following the closing quote of the quoted token that begins
+// at i, or the length of the query when the token is unterminated. A backslash escapes the
+// character that follows it
+func scanQuoted(query string, i int, quote byte) int {
+	for j := i + 1; j < len(query); j++ {
+		switch query[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(query)
+}
+
+// isWordLike returns true if the Token is not a symbol, or is a closing bracket,
+// which is spaced from the Tokens that follow it like the end of a word
+func isWordLike(t Token) bool {
+	return t.Type != TokenSymbol || t.Value == ")" || t.Value == "]" || t.Value == "}"
+}
+
+// Join reassembles the Tokens into a query with canonical whitespace. A single space separates
+// two Tokens only when whitespace separated them in the original query, and they are both
+// symbols or both not symbols, so that, for example, 'a + b' and 'a+b' are joined identically,
+// while '- -' remains distinct from '--'. No space is retained inside of brackets, before
+// opening brackets, or around separators, so that 'f (a, b)' is joined as 'f(a,b)'
+func Join(tokens []Token) string {
+	var sb strings.Builder
+	for i, t := range tokens {
+		if i > 0 && t.Spaced {
+			prev := tokens[i-1]
+			if !(prev.Type == TokenSymbol && strings.Contains("([{,;", prev.Value)) &&
+				!(t.Type == TokenSymbol && strings.Contains("([{}]),;", t.Value)) &&
+				isWordLike(prev) == isWordLike(t) {
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString(t.Value)
+	}
+	return sb.String()
+}
+
+// Normalize returns the query with its whitespace and comments canonicalized, and its
+// numeric literals replaced with the Placeholder, for languages without a specific
+// normalization
+func Normalize(query string, s Syntax) string {
+	tokens := Tokenize(query, s)
+	for i, t := range tokens {
+		if t.Type == TokenNumber {
+			tokens[i].Value = Placeholder
+		}
+	}
+	return Join(tokens)
+}
+
+// UpperKeywords uppercases the Word Tokens that are in the set of lowercase keywords, for
+// languages whose keywords are case-insensitive
+func UpperKeywords(tokens []Token, keywords map[string]bool) {
+	for i, t := range tokens {
+		if t.Type == TokenWord && keywords[strings.ToLower(t.Value)] {
+			tokens[i].Value = strings.ToUpper(t.Value)
+		}
+	}
+}
+
+// timeLayouts are the layouts of the literal time values that are recognized in quoted Tokens
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// IsTimeLiteral returns true if the quoted Token's value is a literal time value
+func IsTimeLiteral(quoted string) bool {
+	if len(quoted) < 2 {
+		return false
+	}
+	v := quoted[1 : len(quoted)-1]
+	for _, l := range timeLayouts {
+		if _, err := time.Parse(l, v); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Sum returns the fingerprint of the normalized query text
+func Sum(normalized string) string {
+	h := fnv.New64a()
+	h.Write([]byte(normalized))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
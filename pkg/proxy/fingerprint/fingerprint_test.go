@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fingerprint
+
+import (
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+
+	s := Syntax{Quotes: `'"`, LineComment: "--", BlockComments: true, RegexOperators: []string{"=~"}}
+	tokens := Tokenize(`a >= 'it\'s' -- comment
+	/* block */ AND b =~ /x y/ 5m`, s)
+
+	expected := []Token{
+		{TokenWord, "a", false},
+		{TokenSymbol, ">=", true},
+		{TokenString, `'it\'s'`, true},
+		{TokenWord, "AND", true},
+		{TokenWord, "b", true},
+		{TokenSymbol, "=~", true},
+		{TokenRegex, "/x y/", true},
+		{TokenNumber, "5m", true},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens got %d: %v", len(expected), len(tokens), tokens)
+	}
+	for i := range expected {
+		if tokens[i] != expected[i] {
+			t.Errorf("token %d: expected %v got %v", i, expected[i], tokens[i])
+		}
+	}
+
+	// a slash that does not follow a regex operator is division
+	tokens = Tokenize("a / b / 2", s)
+	if len(tokens) != 5 || tokens[1].Type != TokenSymbol {
+		t.Errorf("expected division symbols, got %v", tokens)
+	}
+
+	// unterminated quotes and comments extend to the end of the query
+	tokens = Tokenize("a 'b /* c", s)
+	if len(tokens) != 2 || tokens[1].Value != "'b /* c" {
+		t.Errorf("expected unterminated string, got %v", tokens)
+	}
+	if tokens = Tokenize("a /* b", s); len(tokens) != 1 {
+		t.Errorf("expected unterminated comment, got %v", tokens)
+	}
+}
+
+func TestJoin(t *testing.T) {
+
+	tests := []struct {
+		query, expected string
+	}{
+		{"  sum  by (job) ( rate( x[5m] ) )  ", "sum by(job)(rate(x[5m]))"},
+		{"a + b", "a+b"},
+		{"a+b", "a+b"},
+		{"a - -1", "a- -1"},
+		{"select\n\ta,\tb from t", "select a,b from t"},
+	}
+
+	for i, test := range tests {
+		if v := Join(Tokenize(test.query, Syntax{})); v != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, v)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if v := Normalize("/read/1577836800/1577840400/60", Syntax{}); v != "/read/?/?/?" {
+		t.Errorf("expected %s got %s", "/read/?/?/?", v)
+	}
+}
+
+func TestUpperKeywords(t *testing.T) {
+	tokens := Tokenize("select Where from", Syntax{})
+	UpperKeywords(tokens, map[string]bool{"select": true, "where": true})
+	if v := Join(tokens); v != "SELECT WHERE from" {
+		t.Errorf("expected %s got %s", "SELECT WHERE from", v)
+	}
+}
+
+func TestIsTimeLiteral(t *testing.T) {
+
+	tests := []struct {
+		quoted   string
+		expected bool
+	}{
+		{"'2020-01-01T00:00:00Z'", true},
+		{"'2020-01-01T00:00:00.123+01:00'", true},
+		{"'2020-01-01 00:00:00'", true},
+		{"'2020-01-01'", true},
+		{"'server01'", false},
+		{"'", false},
+	}
+
+	for i, test := range tests {
+		if v := IsTimeLiteral(test.quoted); v != test.expected {
+			t.Errorf("test %d: expected %t got %t", i, test.expected, v)
+		}
+	}
+}
+
+func TestSum(t *testing.T) {
+	a, b := Sum("up"), Sum("down")
+	if len(a) != 16 {
+		t.Errorf("expected 16 characters got %d", len(a))
+	}
+	if a == b {
+		t.Error("expected distinct fingerprints")
+	}
+	if a != Sum("up") {
+		t.Error("expected stable fingerprint")
+	}
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fingerprint
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// The orders in which Top can sort the tracked Entries
+const (
+	// OrderRequests sorts Entries by their request count
+	OrderRequests = "requests"
+	// OrderFetchTime sorts Entries by their total origin fetch time
+	OrderFetchTime = "fetch_time"
+	// OrderBytes sorts Entries by their total response bytes
+	OrderBytes = "bytes"
+)
+
+// SeriesWeight is the weight of the most recently recorded series count in the decaying average
+// of a query's series counts, so that the average follows changes in the query's results
+const SeriesWeight = 0.5
+
+// ErrInvalidOrder is returned when Entries are requested in an unknown order
+var ErrInvalidOrder = errors.New("invalid order; must be one of requests, fetch_time or bytes")
+
+// Entry reports the costs recorded for a query fingerprint
+type Entry struct {
+	// OriginName is the name of the origin that served the query
+	OriginName string `json:"origin"`
+	// Fingerprint is the hash of the normalized query
+	Fingerprint string `json:"fingerprint"`
+	// Query is the normalized query
+	Query string `json:"query"`
+	// Requests is the number of requests for the query
+	Requests uint64 `json:"requests"`
+	// RequestsError is the maximum amount by which Requests may overstate the actual count,
+	// since an Entry that replaces an evicted Entry inherits its count
+	RequestsError uint64 `json:"requests_error"`
+	// OriginFetchSecs is the total time spent fetching the query's data from the origin
+	OriginFetchSecs float64 `json:"origin_fetch_secs"`
+	// Bytes is the total size of the responses to the query
+	Bytes int64 `json:"bytes"`
+	// LastSeen is the time of the most recent request for the query
+	LastSeen time.Time `json:"last_seen"`
+	// Series is the decaying average of the number of series in the query's results, which is
+	// used to estimate the size of the results of future requests for the query
+	Series float64 `json:"series"`
+	// SeriesUpdated is the time at which Series was last updated, which is zero until the
+	// results of the query are recorded
+	SeriesUpdated time.Time `json:"series_updated"`
+
+	index int
+}
+
+type entryKey struct {
+	originName  string
+	fingerprint string
+}
+
+// entryHeap is a min-heap of Entries by their request count
+type entryHeap []*Entry
+
+func (h entryHeap) Len() int           { return len(h) }
+func (h entryHeap) Less(i, j int) bool { return h[i].Requests < h[j].Requests }
+func (h entryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *entryHeap) Push(x interface{}) {
+	e := x.(*Entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// Tracker retains the costs of the most requested query fingerprints in a bounded space, using
+// the Space-Saving algorithm: once the Tracker is at capacity, a new fingerprint replaces the
+// least requested one and inherits its request count, so that a frequent fingerprint is never
+// displaced by a stream of infrequent ones
+type Tracker struct {
+	capacity int
+	entries  map[entryKey]*Entry
+	heap     entryHeap
+	mtx      sync.Mutex
+}
+
+// NewTracker returns a new Tracker that retains up to capacity fingerprints
+func NewTracker(capacity int) *Tracker {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Tracker{
+		capacity: capacity,
+		entries:  make(map[entryKey]*Entry, capacity),
+		heap:     make(entryHeap, 0, capacity),
+	}
+}
+
+// Capacity returns the maximum number of fingerprints retained by the Tracker
+func (t *Tracker) Capacity() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.capacity
+}
+
+// Len returns the number of fingerprints currently retained by the Tracker
+func (t *Tracker) Len() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return len(t.heap)
+}
+
+// Record records a request for the query fingerprint served by the named origin
+func (t *Tracker) Record(originName, fingerprint, query string, fetchTime time.Duration, bytes int64) {
+	k := entryKey{originName: originName, fingerprint: fingerprint}
+	now := time.Now()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if e, ok := t.entries[k]; ok {
+		e.Requests++
+		e.OriginFetchSecs += fetchTime.Seconds()
+		e.Bytes += bytes
+		e.LastSeen = now
+		heap.Fix(&t.heap, e.index)
+		return
+	}
+	if len(t.heap) < t.capacity {
+		e := &Entry{OriginName: originName, Fingerprint: fingerprint, Query: query, Requests: 1,
+			OriginFetchSecs: fetchTime.Seconds(), Bytes: bytes, LastSeen: now}
+		t.entries[k] = e
+		heap.Push(&t.heap, e)
+		return
+	}
+	// replace the least requested entry, which remains at the root of the heap
+	e := t.heap[0]
+	delete(t.entries, entryKey{originName: e.OriginName, fingerprint: e.Fingerprint})
+	e.OriginName, e.Fingerprint, e.Query = originName, fingerprint, query
+	e.RequestsError = e.Requests
+	e.Requests++
+	e.OriginFetchSecs = fetchTime.Seconds()
+	e.Bytes = bytes
+	e.LastSeen = now
+	e.Series = 0
+	e.SeriesUpdated = time.Time{}
+	t.entries[k] = e
+	heap.Fix(&t.heap, 0)
+}
+
+// RecordSeries records the number of series in the results of a request for the query fingerprint
+// served by the named origin, which must already be tracked. A series count recorded more than
+// maxAge after the previous one replaces the average, rather than being averaged into it
+func (t *Tracker) RecordSeries(originName, fingerprint string, series int, maxAge time.Duration) {
+	k := entryKey{originName: originName, fingerprint: fingerprint}
+	now := time.Now()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	e, ok := t.entries[k]
+	if !ok {
+		return
+	}
+	if e.SeriesUpdated.IsZero() || (maxAge > 0 && now.Sub(e.SeriesUpdated) > maxAge) {
+		e.Series = float64(series)
+	} else {
+		e.Series += (float64(series) - e.Series) * SeriesWeight
+	}
+	e.SeriesUpdated = now
+}
+
+// Series returns the average number of series in the results of the query fingerprint served by
+// the named origin, and whether it is known. It is not known when the fingerprint is not tracked,
+// or its series count was not recorded within maxAge
+func (t *Tracker) Series(originName, fingerprint string, maxAge time.Duration) (float64, bool) {
+	k := entryKey{originName: originName, fingerprint: fingerprint}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	e, ok := t.entries[k]
+	if !ok || e.SeriesUpdated.IsZero() ||
+		(maxAge > 0 && time.Since(e.SeriesUpdated) > maxAge) {
+		return 0, false
+	}
+	return e.Series, true
+}
+
+// Top returns copies of up to n of the retained Entries, sorted in descending order by the
+// provided order. When n is less than 1, all Entries are returned
+func (t *Tracker) Top(n int, order string) ([]Entry, error) {
+	var less func(a, b *Entry) bool
+	switch order {
+	case OrderRequests, "":
+		less = func(a, b *Entry) bool { return a.Requests > b.Requests }
+	case OrderFetchTime:
+		less = func(a, b *Entry) bool { return a.OriginFetchSecs > b.OriginFetchSecs }
+	case OrderBytes:
+		less = func(a, b *Entry) bool { return a.Bytes > b.Bytes }
+	default:
+		return nil, ErrInvalidOrder
+	}
+	t.mtx.Lock()
+	entries := make([]Entry, len(t.heap))
+	for i, e := range t.heap {
+		entries[i] = *e
+	}
+	t.mtx.Unlock()
+	sort.SliceStable(entries, func(i, j int) bool {
+		if less(&entries[i], &entries[j]) {
+			return true
+		}
+		if less(&entries[j], &entries[i]) {
+			return false
+		}
+		return entries[i].Fingerprint < entries[j].Fingerprint
+	})
+	if n > 0 && n < len(entries) {
+		entries = entries[:n]
+	}
+	return entries, nil
+}
+
+// Resize sets the capacity of the Tracker, or restores the default capacity when capacity is
+// less than 1. The Tracker's Entries are discarded only when the capacity changes
+func (t *Tracker) Resize(capacity int) {
+	if capacity < 1 {
+		capacity = d.DefaultTopQueriesSize
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.capacity == capacity {
+		return
+	}
+	t.capacity = capacity
+	t.entries = make(map[entryKey]*Entry, capacity)
+	t.heap = make(entryHeap, 0, capacity)
+}
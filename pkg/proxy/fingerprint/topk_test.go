@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fingerprint
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+func TestTrackerRecord(t *testing.T) {
+
+	tr := NewTracker(2)
+	tr.Record("prom", "a", "up", time.Second, 10)
+	tr.Record("prom", "a", "up", time.Second, 10)
+	tr.Record("prom", "a", "up", time.Second, 10)
+	tr.Record("prom", "b", "down", 2*time.Second, 100)
+	// the same fingerprint is tracked separately for each origin
+	tr.Record("influx", "b", "down", 0, 1)
+
+	if tr.Len() != 2 {
+		t.Fatalf("expected %d entries got %d", 2, tr.Len())
+	}
+
+	entries, err := tr.Top(0, OrderRequests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Fingerprint != "a" || entries[0].Requests != 3 || entries[0].Bytes != 30 ||
+		entries[0].OriginFetchSecs != 3 || entries[0].RequestsError != 0 {
+		t.Errorf("unexpected entry %v", entries[0])
+	}
+	// the influx entry replaced the least requested entry, inheriting its count
+	if entries[1].OriginName != "influx" || entries[1].Requests != 2 ||
+		entries[1].RequestsError != 1 || entries[1].Bytes != 1 {
+		t.Errorf("unexpected entry %v", entries[1])
+	}
+
+	// a frequent fingerprint is not displaced by a stream of infrequent ones
+	tr = NewTracker(2)
+	for i := 0; i < 10; i++ {
+		tr.Record("prom", "a", "up", 0, 0)
+	}
+	for i := 0; i < 9; i++ {
+		tr.Record("prom", "c"+strconv.Itoa(i), "q", 0, 0)
+	}
+	entries, _ = tr.Top(1, "")
+	if len(entries) != 1 || entries[0].Fingerprint != "a" || entries[0].Requests != 10 {
+		t.Errorf("expected fingerprint a to be retained, got %v", entries)
+	}
+}
+
+func TestTrackerTopOrder(t *testing.T) {
+
+	tr := NewTracker(10)
+	tr.Record("prom", "a", "", 3*time.Second, 1)
+	tr.Record("prom", "b", "", time.Second, 300)
+	tr.Record("prom", "b", "", time.Second, 300)
+	tr.Record("prom", "c", "", 2500*time.Millisecond, 20)
+
+	tests := []struct {
+		order    string
+		expected string
+	}{
+		{OrderRequests, "bac"},
+		{OrderFetchTime, "acb"},
+		{OrderBytes, "bca"},
+	}
+
+	for _, test := range tests {
+		entries, err := tr.Top(0, test.order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v string
+		for _, e := range entries {
+			v += e.Fingerprint
+		}
+		if v != test.expected {
+			t.Errorf("%s: expected %s got %s", test.order, test.expected, v)
+		}
+	}
+
+	if _, err := tr.Top(0, "cost"); err != ErrInvalidOrder {
+		t.Errorf("expected %v got %v", ErrInvalidOrder, err)
+	}
+}
+
+func TestResize(t *testing.T) {
+
+	tr := NewTracker(5)
+	tr.Record("prom", "a", "up", 0, 0)
+
+	// the entries are retained when the capacity is unchanged
+	tr.Resize(5)
+	if tr.Len() != 1 {
+		t.Errorf("expected %d got %d", 1, tr.Len())
+	}
+
+	tr.Resize(0)
+	if tr.Capacity() != d.DefaultTopQueriesSize {
+		t.Errorf("expected %d got %d", d.DefaultTopQueriesSize, tr.Capacity())
+	}
+	if tr.Len() != 0 {
+		t.Errorf("expected %d got %d", 0, tr.Len())
+	}
+}
+
+func TestTrackerSeries(t *testing.T) {
+
+	tr := NewTracker(1)
+	// series counts are not recorded for fingerprints that are not tracked
+	tr.RecordSeries("prom", "a", 10, time.Hour)
+	if _, ok := tr.Series("prom", "a", time.Hour); ok {
+		t.Error("expected no series count for an untracked fingerprint")
+	}
+
+	tr.Record("prom", "a", "up", 0, 0)
+	if _, ok := tr.Series("prom", "a", time.Hour); ok {
+		t.Error("expected no series count before one is recorded")
+	}
+
+	tr.RecordSeries("prom", "a", 10, time.Hour)
+	if v, ok := tr.Series("prom", "a", time.Hour); !ok || v != 10 {
+		t.Errorf("expected %d got %f", 10, v)
+	}
+
+	// subsequent counts are averaged, with the recent counts weighted more
+	tr.RecordSeries("prom", "a", 30, time.Hour)
+	if v, _ := tr.Series("prom", "a", time.Hour); v != 20 {
+		t.Errorf("expected %d got %f", 20, v)
+	}
+	tr.RecordSeries("prom", "a", 40, time.Hour)
+	if v, _ := tr.Series("prom", "a", time.Hour); v != 30 {
+		t.Errorf("expected %d got %f", 30, v)
+	}
+
+	// stale counts are not reported, and are replaced rather than averaged
+	time.Sleep(10 * time.Millisecond)
+	if _, ok := tr.Series("prom", "a", time.Millisecond); ok {
+		t.Error("expected no series count once it is stale")
+	}
+	tr.RecordSeries("prom", "a", 2, time.Millisecond)
+	if v, ok := tr.Series("prom", "a", time.Hour); !ok || v != 2 {
+		t.Errorf("expected %d got %f", 2, v)
+	}
+
+	// an entry that replaces an evicted entry does not inherit its series count
+	tr.Record("prom", "b", "down", 0, 0)
+	if _, ok := tr.Series("prom", "b", time.Hour); ok {
+		t.Error("expected no series count for the replacing fingerprint")
+	}
+}
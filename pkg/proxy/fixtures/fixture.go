@@ -0,0 +1,222 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fixtures records upstream requests and responses to fixture files, and replays them
+// in place of the origin, so that origin interactions can be tested offline
+package fixtures
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+// redacted replaces the values of credentials in fixtures
+const redacted = "REDACTED"
+
+// Fixture is a recorded upstream request and its response
+type Fixture struct {
+	// Method is the request method
+	Method string `json:"method"`
+	// URL is the normalized request path and query, which excludes the origin's host
+	URL string `json:"url"`
+	// BodyHash is the MD5 checksum of the request body
+	BodyHash string `json:"body_hash,omitempty"`
+	// RequestHeader is the request's header, without credentials
+	RequestHeader http.Header `json:"request_header,omitempty"`
+	// StatusCode is the response's status code
+	StatusCode int `json:"status_code"`
+	// Header is the response's header, without cookies
+	Header http.Header `json:"header,omitempty"`
+	// Body is the response body, which is base64-encoded when it is not valid UTF-8
+	Body string `json:"body"`
+	// BodyEncoding is "base64" when the Body is base64-encoded
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// Key returns the key by which a request is matched to its fixture
+func (f *Fixture) Key() string {
+	return md5.Checksum(f.Method + " " + f.URL + " " + f.BodyHash)
+}
+
+// Filename returns the name of the fixture's file, which is prefixed with its method and path
+// so that the fixtures in a directory can be told apart
+func (f *Fixture) Filename() string {
+	p := strings.Trim(reUnsafe.ReplaceAllString(f.URL[:strings.IndexByte(f.URL+"?", '?')], "_"), "_")
+	if len(p) > 64 {
+		p = p[:64]
+	}
+	return strings.ToLower(f.Method) + "_" + p + "_" + f.Key()[:12] + ".json"
+}
+
+var reUnsafe = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// newFixture returns a fixture for the request and its body, without a response
+func newFixture(r *http.Request, body []byte) *Fixture {
+	f := &Fixture{Method: r.Method, URL: normalizeURL(r.URL), RequestHeader: sanitizeHeader(r.Header)}
+	if len(body) > 0 {
+		f.BodyHash = md5.Checksum(string(body))
+	}
+	return f
+}
+
+// setResponse sets the fixture's response from the provided response and its body
+func (f *Fixture) setResponse(resp *http.Response, body []byte) {
+	f.StatusCode = resp.StatusCode
+	f.Header = sanitizeHeader(resp.Header)
+	if utf8.Valid(body) {
+		f.Body = string(body)
+		return
+	}
+	f.Body = base64.StdEncoding.EncodeToString(body)
+	f.BodyEncoding = "base64"
+}
+
+// response returns the fixture's response to the provided request
+func (f *Fixture) response(r *http.Request) (*http.Response, error) {
+	body := []byte(f.Body)
+	if f.BodyEncoding == "base64" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(f.Body); err != nil {
+			return nil, err
+		}
+	}
+	h := f.Header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.StatusCode, http.StatusText(f.StatusCode)),
+		StatusCode:    f.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          ioutil.NopCloser(strings.NewReader(string(body))),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}, nil
+}
+
+// normalizeURL returns the path and query of the URL, with the query parameters sorted and
+// the values of credentials redacted, so that it matches regardless of the origin's host
+func normalizeURL(u *url.URL) string {
+	if u == nil {
+		return "/"
+	}
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	q := u.Query()
+	if len(q) == 0 {
+		return p
+	}
+	for k, v := range q {
+		if isCredential(k) {
+			for i := range v {
+				v[i] = redacted
+			}
+		} else {
+			sort.Strings(v)
+		}
+	}
+	// url.Values.Encode sorts the parameters by key
+	return p + "?" + q.Encode()
+}
+
+// sanitizeHeader returns a copy of the header without credentials or cookies
+func sanitizeHeader(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	h2 := http.Header{}
+	for k, v := range h {
+		if isCredential(k) {
+			continue
+		}
+		h2[k] = append([]string(nil), v...)
+	}
+	return h2
+}
+
+// isCredential returns true if the named header or query parameter may carry a credential
+func isCredential(name string) bool {
+	n := strings.ToLower(name)
+	switch n {
+	case "u", "p", "user", "username":
+		return true
+	}
+	for _, s := range []string{"auth", "cookie", "password", "passwd", "secret", "token", "key"} {
+		if strings.Contains(n, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load returns the fixture stored in the named file
+func Load(filename string) (*Fixture, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fixture{}
+	if err = json.Unmarshal(b, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Save writes the fixture to its file in the provided directory, replacing any previous
+// recording of the same request
+func (f *Fixture) Save(dir string) error {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// the fixture is written to a temporary file and renamed, so that concurrent recordings
+	// of the same request, or a concurrent replay, never observe a partial file
+	tmp, err := ioutil.TempFile(dir, ".fixture-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	tmp.Close()
+	return os.Rename(tmp.Name(), filepath.Join(dir, f.Filename()))
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fixtures
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// ErrNoFixture is returned by a Replayer for a request that has no recorded fixture
+var ErrNoFixture = errors.New("no fixture recorded for upstream request")
+
+// Recorder is an http.RoundTripper that sends requests to the next RoundTripper and writes
+// each request and response to a fixture file in its directory
+type Recorder struct {
+	dir  string
+	next http.RoundTripper
+}
+
+// NewRecorder returns a Recorder that writes fixtures to dir for the requests sent through next
+func NewRecorder(dir string, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{dir: dir, next: next}
+}
+
+// RoundTrip implements http.RoundTripper. The response body is buffered so that it can be
+// recorded, and is returned to the caller unchanged
+func (rec *Recorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, r, err := readRequestBody(r)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rec.next.RoundTrip(r)
+	if err != nil {
+		return resp, err
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return resp, err
+	}
+	f := newFixture(r, body)
+	f.setResponse(resp, b)
+	if err = f.Save(rec.dir); err != nil {
+		return nil, fmt.Errorf("could not record fixture for %s %s: %s", f.Method, f.URL, err.Error())
+	}
+	return resp, nil
+}
+
+// Replayer is an http.RoundTripper that serves requests from the fixture files in its
+// directory, and never contacts the origin
+type Replayer struct {
+	dir string
+}
+
+// NewReplayer returns a Replayer that serves requests from the fixtures in dir
+func NewReplayer(dir string) *Replayer {
+	return &Replayer{dir: dir}
+}
+
+// RoundTrip implements http.RoundTripper. A request without a matching fixture fails with an
+// error naming the request, so that missing fixtures are apparent
+func (rep *Replayer) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, r, err := readRequestBody(r)
+	if err != nil {
+		return nil, err
+	}
+	f := newFixture(r, body)
+	rf, err := Load(filepath.Join(rep.dir, f.Filename()))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s %s (body hash %q) in %s",
+				ErrNoFixture, f.Method, f.URL, f.BodyHash, rep.dir)
+		}
+		return nil, err
+	}
+	return rf.response(r)
+}
+
+// readRequestBody returns the request's body, and a copy of the request from which it
+// can be read again
+func readRequestBody(r *http.Request) ([]byte, *http.Request, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, r, nil
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, r, err
+	}
+	r2 := r.Clone(r.Context())
+	r2.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, r2, nil
+}
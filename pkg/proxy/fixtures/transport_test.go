@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fixtures
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "trickster-fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Test", "test")
+		if r.URL.Path == "/binary" {
+			w.Write([]byte{0xff, 0xfe, 0x00})
+			return
+		}
+		w.Write([]byte(r.Method + " " + r.URL.Query().Get("query") + " " + string(b)))
+	}))
+
+	rc := &http.Client{Transport: NewRecorder(dir, nil)}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/api/v1/query?query=up&time=0&password=hunter2", nil)
+	req.Header.Set("Authorization", "Basic dGVzdDp0ZXN0")
+	resp, err := rc.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != "GET up " {
+		t.Errorf("expected %s got %s", "GET up ", string(b))
+	}
+
+	if _, err = rc.Post(s.URL+"/api/v1/query", "application/x-www-form-urlencoded",
+		strings.NewReader("query=up")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rc.Get(s.URL + "/binary"); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// credentials are not written to the fixtures
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 3 {
+		t.Fatalf("expected %d fixtures got %d", 3, len(files))
+	}
+	for _, fn := range files {
+		b, _ := ioutil.ReadFile(fn)
+		for _, v := range []string{"hunter2", "dGVzdDp0ZXN0", "session=secret"} {
+			if strings.Contains(string(b), v) {
+				t.Errorf("expected %s to be removed from %s", v, fn)
+			}
+		}
+	}
+
+	// the origin is closed, so requests are served from the fixtures, matched regardless of the
+	// host, the order of the query parameters, or the credentials
+	pc := &http.Client{Transport: NewReplayer(dir)}
+	resp, err = pc.Get("http://origin.example.com/api/v1/query?time=0&password=other&query=up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != "GET up " {
+		t.Errorf("expected %s got %s", "GET up ", string(b))
+	}
+	if resp.Header.Get("X-Test") != "test" || resp.Header.Get("Set-Cookie") != "" {
+		t.Errorf("unexpected response header %v", resp.Header)
+	}
+
+	resp, err = pc.Post("http://origin.example.com/api/v1/query", "application/x-www-form-urlencoded",
+		strings.NewReader("query=up"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != "POST  query=up" {
+		t.Errorf("expected %s got %s", "POST  query=up", string(b))
+	}
+
+	resp, err = pc.Get("http://origin.example.com/binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != string([]byte{0xff, 0xfe, 0x00}) {
+		t.Errorf("expected %v got %v", []byte{0xff, 0xfe, 0x00}, b)
+	}
+
+	// requests without a fixture fail, including those whose body differs
+	_, err = pc.Post("http://origin.example.com/api/v1/query", "application/x-www-form-urlencoded",
+		strings.NewReader("query=down"))
+	if err == nil || !errors.Is(err, ErrNoFixture) {
+		t.Errorf("expected %v got %v", ErrNoFixture, err)
+	}
+	_, err = pc.Get("http://origin.example.com/api/v1/query?query=down&time=0")
+	if err == nil || !errors.Is(err, ErrNoFixture) {
+		t.Errorf("expected %v got %v", ErrNoFixture, err)
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+
+	tests := []struct {
+		in, expected string
+	}{
+		{"http://a/", "/"},
+		{"http://a", "/"},
+		{"http://a/q?b=2&a=1", "/q?a=1&b=2"},
+		{"http://a/q?a=2&a=1", "/q?a=1&a=2"},
+		{"http://a/q?u=user&p=pass&api_key=k", "/q?api_key=REDACTED&p=REDACTED&u=REDACTED"},
+	}
+
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.in, nil)
+		if v := normalizeURL(r.URL); v != test.expected {
+			t.Errorf("expected %s got %s", test.expected, v)
+		}
+	}
+
+	if v := normalizeURL(nil); v != "/" {
+		t.Errorf("expected %s got %s", "/", v)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package grpchealth serves the standard gRPC Health service, reporting the readiness of
+// Trickster and of each of its origins, along with gRPC Server Reflection, on a dedicated
+// listener for service meshes and load balancers that can only health check gRPC services
+package grpchealth
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// OverallService is the service name under which the readiness of Trickster as a whole is reported.
+// The readiness of each origin is reported under the origin's name
+const OverallService = ""
+
+// refreshInterval is how often the serving statuses are refreshed from the recorded readiness
+const refreshInterval = time.Second
+
+// Server is a gRPC server of the Health and Server Reflection services
+type Server struct {
+	server    *grpc.Server
+	health    *health.Server
+	listener  net.Listener
+	readiness *preflight.Readiness
+	origins   []string
+	quit      chan struct{}
+	mtx       sync.Mutex
+}
+
+var current *Server
+var currentMtx sync.Mutex
+
+// Serve starts a Server listening on the provided address and port, and stops any Server
+// previously started by Serve. The Server serves TLS when tlsConfig is not nil. The Health
+// service reports the readiness recorded in rd of each of the named origins, in addition to
+// OverallService
+func Serve(address string, port int, tlsConfig *tls.Config, rd *preflight.Readiness,
+	origins []string, log *tl.Logger) error {
+	s, err := NewServer(address, port, tlsConfig, rd, origins)
+	if err != nil {
+		return err
+	}
+	currentMtx.Lock()
+	if current != nil {
+		current.Stop()
+	}
+	current = s
+	currentMtx.Unlock()
+	log.Info("grpc health listener starting",
+		tl.Pairs{"address": s.listener.Addr().String(), "tls": tlsConfig != nil})
+	go s.Serve(log)
+	return nil
+}
+
+// SetOrigins updates the origins whose readiness is reported by the Server started by Serve
+func SetOrigins(origins []string) {
+	currentMtx.Lock()
+	if current != nil {
+		current.SetOrigins(origins)
+	}
+	currentMtx.Unlock()
+}
+
+// Stop stops the Server started by Serve, if any
+func Stop() {
+	currentMtx.Lock()
+	if current != nil {
+		current.Stop()
+		current = nil
+	}
+	currentMtx.Unlock()
+}
+
+// NewServer returns a new Server bound to the provided address and port, which reports the
+// readiness recorded in rd
+func NewServer(address string, port int, tlsConfig *tls.Config, rd *preflight.Readiness,
+	origins []string) (*Server, error) {
+	l, err := net.Listen("tcp", fmt.Sprintf("%s:%d", address, port))
+	if err != nil {
+		return nil, err
+	}
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s := &Server{
+		server:    grpc.NewServer(opts...),
+		health:    health.NewServer(),
+		listener:  l,
+		readiness: rd,
+		quit:      make(chan struct{}),
+	}
+	healthpb.RegisterHealthServer(s.server, s.health)
+	reflection.Register(s.server)
+	s.SetOrigins(origins)
+	return s, nil
+}
+
+// Addr returns the Server's listening address
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve serves gRPC requests and refreshes the serving statuses until the Server is stopped
+func (s *Server) Serve(log *tl.Logger) {
+	go func() {
+		t := time.NewTicker(refreshInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				s.refresh()
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+	if err := s.server.Serve(s.listener); err != nil && err != grpc.ErrServerStopped {
+		log.Error("grpc health listener stopped", tl.Pairs{"detail": err.Error()})
+	}
+}
+
+// SetOrigins updates the origins whose readiness is reported. Origins that are no longer
+// reported have their serving status set to SERVICE_UNKNOWN
+func (s *Server) SetOrigins(origins []string) {
+	s.mtx.Lock()
+	keep := make(map[string]bool, len(origins))
+	for _, name := range origins {
+		keep[name] = true
+	}
+	for _, name := range s.origins {
+		if !keep[name] {
+			s.health.SetServingStatus(name, healthpb.HealthCheckResponse_SERVICE_UNKNOWN)
+		}
+	}
+	s.origins = origins
+	s.mtx.Unlock()
+	s.refresh()
+}
+
+// refresh sets the serving status of each service from the readiness recorded by the
+// pre-flight checks, mirroring the Readiness Handler
+func (s *Server) refresh() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.health.SetServingStatus(OverallService, servingStatus(s.readiness.Ready()))
+	for _, name := range s.origins {
+		s.health.SetServingStatus(name, servingStatus(s.readiness.ComponentReady("origin:"+name)))
+	}
+}
+
+// Stop stops the Server, closing its listener and any open connections
+func (s *Server) Stop() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	select {
+	case <-s.quit:
+		return
+	default:
+	}
+	close(s.quit)
+	s.health.Shutdown()
+	s.server.Stop()
+}
+
+func servingStatus(ready bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ready {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpchealth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight"
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight/policy"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func testCheck(component string, err error) *preflight.Check {
+	return &preflight.Check{Component: component, Policy: policy.Warn,
+		Run: func(context.Context) error { return err }}
+}
+
+func TestServe(t *testing.T) {
+
+	logger := tl.ConsoleLogger("error")
+	rd := preflight.NewReadiness()
+	rd.Record(preflight.Run([]*preflight.Check{
+		testCheck("origin:prom1", nil),
+		testCheck("origin:prom2", errors.New("connection refused")),
+	}, time.Second, logger))
+
+	err := Serve("127.0.0.1", 0, nil, rd, []string{"prom1", "prom2"}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Stop()
+
+	if _, ok := current.server.GetServiceInfo()["grpc.reflection.v1alpha.ServerReflection"]; !ok {
+		t.Error("expected the server reflection service to be registered")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, current.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	check := func(service string) (healthpb.HealthCheckResponse_ServingStatus, codes.Code) {
+		rsp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN, status.Code(err)
+		}
+		return rsp.Status, codes.OK
+	}
+
+	tests := []struct {
+		service string
+		status  healthpb.HealthCheckResponse_ServingStatus
+		code    codes.Code
+	}{
+		{OverallService, healthpb.HealthCheckResponse_SERVING, codes.OK},
+		{"prom1", healthpb.HealthCheckResponse_SERVING, codes.OK},
+		{"prom2", healthpb.HealthCheckResponse_NOT_SERVING, codes.OK},
+		{"prom3", healthpb.HealthCheckResponse_UNKNOWN, codes.NotFound},
+	}
+	for _, test := range tests {
+		s, c := check(test.service)
+		if s != test.status || c != test.code {
+			t.Errorf("%s: expected %s %s got %s %s", test.service, test.status, test.code, s, c)
+		}
+	}
+
+	// origins that are no longer configured are reported as unknown
+	SetOrigins([]string{"prom1"})
+	if s, _ := check("prom2"); s != healthpb.HealthCheckResponse_SERVICE_UNKNOWN {
+		t.Errorf("expected %s got %s", healthpb.HealthCheckResponse_SERVICE_UNKNOWN, s)
+	}
+}
+
+func TestServeInvalidAddress(t *testing.T) {
+	if err := Serve("127.0.0.1", -1, nil, preflight.NewReadiness(), nil, tl.ConsoleLogger("error")); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/background"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// BackgroundTasks is the response of the Background Tasks Handler
+type BackgroundTasks struct {
+	// Running is the number of goroutines running on behalf of proxied requests
+	Running int `json:"running"`
+	// Tasks is the number of those goroutines of each kind
+	Tasks map[string]int `json:"tasks"`
+	// Goroutines is the number of goroutines in the process
+	Goroutines int `json:"goroutines"`
+}
+
+// BackgroundTasksHandleFunc responds with the number of goroutines running on behalf of proxied
+// requests, such as fast forward fetches and cache writes, which can outlive the requests
+func BackgroundTasksHandleFunc(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		HandleMethodNotAllowedResponse(w, r)
+		return
+	}
+
+	bt := &BackgroundTasks{Tasks: background.Counts(), Goroutines: runtime.NumGoroutine()}
+	for _, n := range bt.Tasks {
+		bt.Running += n
+	}
+
+	b, _ := json.Marshal(bt)
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
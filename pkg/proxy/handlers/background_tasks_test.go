@@ -0,0 +1,57 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/background"
+)
+
+func TestBackgroundTasksHandleFunc(t *testing.T) {
+
+	release := make(chan struct{})
+	background.Go(background.KindCacheWrite, func() { <-release })
+	defer background.Wait(time.Second)
+	defer close(release)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://0/trickster/debug/background-tasks", nil)
+	BackgroundTasksHandleFunc(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+
+	bt := &BackgroundTasks{}
+	if err := json.Unmarshal(w.Body.Bytes(), bt); err != nil {
+		t.Fatal(err)
+	}
+	if bt.Running != 1 || bt.Tasks[background.KindCacheWrite] != 1 || bt.Goroutines < 1 {
+		t.Errorf("unexpected response %+v", bt)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "http://0/trickster/debug/background-tasks", nil)
+	BackgroundTasksHandleFunc(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+)
+
+// DefaultCacheEventsLimit is the number of events returned by the Cache Events Handler when the
+// request does not provide a limit
+const DefaultCacheEventsLimit = 100
+
+// MaxCacheEventsLimit is the maximum number of events returned by the Cache Events Handler
+const MaxCacheEventsLimit = 1000
+
+// CacheEvents is the body of responses from the Cache Events Handler
+type CacheEvents struct {
+	// Events are the cache mutation events that follow the request's cursor
+	Events []*events.Event `json:"events"`
+	// Next is the cursor to request the events that follow those returned
+	Next uint64 `json:"next"`
+	// Dropped is the number of events dropped because the stream could not keep up with them
+	Dropped uint64 `json:"dropped"`
+}
+
+// CacheEventsHandleFunc responds with the cache mutation events that follow the request's after
+// cursor, up to the request's limit. The events are those published by the Instance, or by the
+// Default Instance when it is nil
+func CacheEventsHandleFunc(inst *instance.Instance) func(http.ResponseWriter, *http.Request) {
+	inst = instance.OrDefault(inst)
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodGet {
+			HandleMethodNotAllowedResponse(w, r)
+			return
+		}
+
+		qp := r.URL.Query()
+		var after uint64
+		if s := qp.Get("after"); s != "" {
+			var err error
+			if after, err = strconv.ParseUint(s, 10, 64); err != nil {
+				txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest,
+					"invalid after cursor: "+s).Respond(w, r)
+				return
+			}
+		}
+		limit := DefaultCacheEventsLimit
+		if s := qp.Get("limit"); s != "" {
+			var err error
+			if limit, err = strconv.Atoi(s); err != nil || limit < 1 {
+				txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest,
+					"invalid limit: "+s).Respond(w, r)
+				return
+			}
+			if limit > MaxCacheEventsLimit {
+				limit = MaxCacheEventsLimit
+			}
+		}
+
+		evs, next, dropped, err := inst.Events.Events(after, limit)
+		if err != nil {
+			txe.NewResponseError(http.StatusNotFound, txe.CodeNotFound, err.Error()).Respond(w, r)
+			return
+		}
+
+		b, _ := json.Marshal(&CacheEvents{Events: evs, Next: next, Dropped: dropped})
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
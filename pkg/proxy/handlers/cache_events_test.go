@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+)
+
+func TestCacheEventsHandleFunc(t *testing.T) {
+
+	inst := instance.New()
+	h := CacheEventsHandleFunc(inst)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "http://0/trickster/cache/events", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected %d got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := inst.Events.Configure(true, &events.Options{Size: 10}); err != nil {
+		t.Fatal(err)
+	}
+	defer inst.Close()
+	for _, key := range []string{"key1", "key2", "key3"} {
+		inst.Events.Emit(events.New(events.TypeStore, "default", key))
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, next, _, _ := inst.Events.Events(0, 0); next == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for events to be recorded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	tests := []struct {
+		method, query string
+		code          int
+		count         int
+		next          uint64
+	}{
+		{http.MethodGet, "", http.StatusOK, 3, 3},
+		{http.MethodGet, "?after=1", http.StatusOK, 2, 3},
+		{http.MethodGet, "?after=1&limit=1", http.StatusOK, 1, 2},
+		{http.MethodGet, "?after=3", http.StatusOK, 0, 3},
+		{http.MethodGet, "?after=x", http.StatusBadRequest, 0, 0},
+		{http.MethodGet, "?limit=0", http.StatusBadRequest, 0, 0},
+		{http.MethodPost, "", http.StatusMethodNotAllowed, 0, 0},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(test.method,
+			"http://0/trickster/cache/events"+test.query, nil))
+		if w.Code != test.code {
+			t.Errorf("(%d) expected %d got %d", i, test.code, w.Code)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		ce := &CacheEvents{}
+		if err := json.Unmarshal(w.Body.Bytes(), ce); err != nil {
+			t.Fatal(err)
+		}
+		if len(ce.Events) != test.count || ce.Next != test.next {
+			t.Errorf("(%d) expected %d events and next %d got %d and %d", i,
+				test.count, test.next, len(ce.Events), ce.Next)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/readonly"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// CacheReadOnlyMode is the body of requests to the Cache Read-Only Handler
+type CacheReadOnlyMode struct {
+	// Cache is the name of the cache
+	Cache string `json:"cache"`
+	// Mode is the cache's read-only mode: auto, read-only or read-write
+	Mode string `json:"mode"`
+}
+
+// CacheReadOnlyHandleFunc returns a handler that responds with the read-only status of each
+// cache, or of the cache named by the cache parameter, and sets the read-only mode of a cache to
+// that PUT in a CacheReadOnlyMode body
+func CacheReadOnlyHandleFunc(log *tl.Logger) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var v interface{}
+		switch r.Method {
+		case http.MethodGet:
+			name := r.URL.Query().Get("cache")
+			if name == "" {
+				v = readonly.Statuses()
+				break
+			}
+			c, ok := readonly.Lookup(name)
+			if !ok {
+				txe.NewResponseError(http.StatusNotFound, txe.CodeNotFound,
+					"unknown cache: "+name).Respond(w, r)
+				return
+			}
+			v = c.Status()
+		case http.MethodPut:
+			req := &CacheReadOnlyMode{}
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+				txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest,
+					"invalid request body: "+err.Error()).Respond(w, r)
+				return
+			}
+			c, ok := readonly.Lookup(req.Cache)
+			if !ok {
+				txe.NewResponseError(http.StatusNotFound, txe.CodeNotFound,
+					"unknown cache: "+req.Cache).Respond(w, r)
+				return
+			}
+			if err := c.SetMode(req.Mode); err != nil {
+				txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest,
+					err.Error()).Respond(w, r)
+				return
+			}
+			log.Info("cache read-only mode changed", tl.Pairs{"cacheName": req.Cache,
+				"mode": req.Mode, "source": "cacheReadOnlyEndpoint"})
+			v = c.Status()
+		default:
+			HandleMethodNotAllowedResponse(w, r)
+			return
+		}
+
+		b, _ := json.Marshal(v)
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
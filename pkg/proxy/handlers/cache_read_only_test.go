@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/memory"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/readonly"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestCacheReadOnlyHandleFunc(t *testing.T) {
+
+	logger := tl.ConsoleLogger("error")
+	cfg := options.NewOptions()
+	cfg.Name = "test-read-only"
+	mc := &memory.Cache{Name: cfg.Name, Config: cfg, Logger: logger}
+	mc.Connect()
+	c := readonly.Wrap(cfg.Name, mc, logger)
+	defer c.Close()
+
+	h := CacheReadOnlyHandleFunc(logger)
+	const u = "http://0/trickster/cache/read-only"
+
+	tests := []struct {
+		method, query, body string
+		code                int
+		readOnly            bool
+	}{
+		{http.MethodGet, "?cache=test-read-only", "", http.StatusOK, false},
+		{http.MethodGet, "?cache=unknown", "", http.StatusNotFound, false},
+		{http.MethodPut, "", `{"cache":"test-read-only","mode":"read-only"}`, http.StatusOK, true},
+		{http.MethodGet, "?cache=test-read-only", "", http.StatusOK, true},
+		{http.MethodPut, "", `{"cache":"test-read-only","mode":"invalid"}`, http.StatusBadRequest, false},
+		{http.MethodPut, "", `{"cache":"unknown","mode":"auto"}`, http.StatusNotFound, false},
+		{http.MethodPut, "", `{"cache":`, http.StatusBadRequest, false},
+		{http.MethodPut, "", `{"cache":"test-read-only","mode":"auto"}`, http.StatusOK, false},
+		{http.MethodPost, "", "", http.StatusMethodNotAllowed, false},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(test.method, u+test.query, strings.NewReader(test.body)))
+		if w.Code != test.code {
+			t.Errorf("(%d) expected %d got %d", i, test.code, w.Code)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		s := &readonly.Status{}
+		if err := json.Unmarshal(w.Body.Bytes(), s); err != nil {
+			t.Fatal(err)
+		}
+		if s.Cache != cfg.Name || s.ReadOnly != test.readOnly {
+			t.Errorf("(%d) unexpected status %+v", i, s)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, u, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	var statuses []*readonly.Status
+	if err := json.Unmarshal(w.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Cache != cfg.Name {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+}
@@ -18,16 +18,32 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/tricksterproxy/trickster/pkg/config"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 )
 
-// ConfigHandleFunc responds to the HTTP request with the running configuration
+// ConfigHandleFunc responds to the HTTP request with the running configuration, with its
+// secrets masked. It is TOML, with the source of each option noted in a comment, unless the
+// request accepts JSON, in which case the sources are a member alongside the configuration
 func ConfigHandleFunc(conf *config.Config) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set(headers.NameContentType, headers.ValueTextPlain)
 		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		if strings.Contains(r.Header.Get(headers.NameAccept), headers.ValueApplicationJSON) {
+			b, err := conf.JSON()
+			if err != nil {
+				txe.NewResponseError(http.StatusInternalServerError, txe.CodeInternal,
+					err.Error()).Respond(w, r)
+				return
+			}
+			w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+			w.WriteHeader(http.StatusOK)
+			w.Write(b)
+			return
+		}
+		w.Header().Set(headers.NameContentType, headers.ValueTextPlain)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(conf.String()))
 	}
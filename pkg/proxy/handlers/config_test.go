@@ -17,11 +17,13 @@
 package handlers
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 )
 
 func TestConfigHandler(t *testing.T) {
@@ -58,3 +60,50 @@ func TestConfigHandler(t *testing.T) {
 	}
 
 }
+
+func TestConfigHandlerJSON(t *testing.T) {
+
+	conf, _, err := config.Load("trickster-test", "test",
+		[]string{"-origin-url", "http://1.2.3.4", "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Caches["default"].Redis.Password = "plaintext-password"
+	configHandler := ConfigHandleFunc(conf)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://0/trickster/config", nil)
+	r.Header.Set(headers.NameAccept, headers.ValueApplicationJSON)
+
+	configHandler(w, r)
+	resp := w.Result()
+
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+	if ct := resp.Header.Get(headers.NameContentType); ct != headers.ValueApplicationJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationJSON, ct)
+	}
+
+	var doc struct {
+		Config  map[string]interface{} `json:"config"`
+		Sources map[string]string      `json:"sources"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := doc.Config["origins"]; !ok {
+		t.Errorf("missing origins in %v", doc.Config)
+	}
+	if s := doc.Sources["origins.default.origin_url"]; s != config.SourceFlag {
+		t.Errorf("expected %s got %s", config.SourceFlag, s)
+	}
+	if s := doc.Sources["frontend.listen_port"]; s != config.SourceDefault {
+		t.Errorf("expected %s got %s", config.SourceDefault, s)
+	}
+	c := doc.Config["caches"].(map[string]interface{})["default"].(map[string]interface{})
+	if p := c["redis"].(map[string]interface{})["password"]; p != "*****" {
+		t.Errorf("expected %s got %v", "*****", p)
+	}
+}
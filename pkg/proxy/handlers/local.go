@@ -19,6 +19,7 @@ package handlers
 import (
 	"net/http"
 
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 )
@@ -43,6 +44,19 @@ func HandleLocalResponse(w http.ResponseWriter, r *http.Request) {
 
 // HandleBadRequestResponse responds to an HTTP Request with 400 Bad Request
 func HandleBadRequestResponse(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusBadRequest)
-	w.Write(nil)
+	txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest,
+		"the request is not supported").Respond(w, r)
+}
+
+// HandleNotFoundResponse responds to an HTTP Request that matches no route with 404 Not Found
+func HandleNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	txe.NewResponseError(http.StatusNotFound, txe.CodeNotFound,
+		"no route matches the request path").Respond(w, r)
+}
+
+// HandleMethodNotAllowedResponse responds to an HTTP Request whose path matches a route,
+// but whose method does not, with 405 Method Not Allowed
+func HandleMethodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	txe.NewResponseError(http.StatusMethodNotAllowed, txe.CodeMethodNotAllowed,
+		"the request method is not allowed for the request path").Respond(w, r)
 }
@@ -19,6 +19,7 @@ package handlers
 import (
 	"io/ioutil"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/tricksterproxy/trickster/pkg/config"
@@ -165,4 +166,35 @@ func TestHandleBadRequestResponse(t *testing.T) {
 	if w.Result().StatusCode != 400 {
 		t.Errorf("expected %d got %d", 400, w.Result().StatusCode)
 	}
+	expected := `{"status":400,"code":"bad_request","message":"the request is not supported"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+	if ct := w.Header().Get(headers.NameContentType); ct != headers.ValueApplicationJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationJSON, ct)
+	}
+}
+
+func TestHandleNotFoundResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://0/trickster/", nil)
+	HandleNotFoundResponse(w, r)
+	if w.Result().StatusCode != 404 {
+		t.Errorf("expected %d got %d", 404, w.Result().StatusCode)
+	}
+	if !strings.Contains(w.Body.String(), `"code":"not_found"`) {
+		t.Errorf("expected not_found code got %s", w.Body.String())
+	}
+}
+
+func TestHandleMethodNotAllowedResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://0/trickster/", nil)
+	HandleMethodNotAllowedResponse(w, r)
+	if w.Result().StatusCode != 405 {
+		t.Errorf("expected %d got %d", 405, w.Result().StatusCode)
+	}
+	if !strings.Contains(w.Body.String(), `"code":"method_not_allowed"`) {
+		t.Errorf("expected method_not_allowed code got %s", w.Body.String())
+	}
 }
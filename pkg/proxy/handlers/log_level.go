@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// LogLevel is the body of requests to, and responses from, the Log Level Handler
+type LogLevel struct {
+	// Level is the log level
+	Level string `json:"level"`
+	// Previous is the log level before it was changed by the request
+	Previous string `json:"previous,omitempty"`
+}
+
+// LogLevelHandleFunc returns a handler that responds with the log level of the logger, and
+// changes it to the level PUT in a LogLevel body, without reloading the configuration
+func LogLevelHandleFunc(log *tl.Logger) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var ll *LogLevel
+		switch r.Method {
+		case http.MethodGet:
+			ll = &LogLevel{Level: log.Level()}
+		case http.MethodPut:
+			req := &LogLevel{}
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+				txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest,
+					"invalid request body: "+err.Error()).Respond(w, r)
+				return
+			}
+			if !tl.IsLevel(req.Level) {
+				txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest,
+					"invalid log level: "+req.Level).Respond(w, r)
+				return
+			}
+			ll = &LogLevel{Previous: log.SetLogLevel(req.Level), Level: strings.ToLower(req.Level)}
+			log.Info("log level changed", tl.Pairs{"previous": ll.Previous, "level": ll.Level,
+				"source": "logLevelEndpoint"})
+		default:
+			HandleMethodNotAllowedResponse(w, r)
+			return
+		}
+
+		b, _ := json.Marshal(ll)
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestLogLevelHandleFunc(t *testing.T) {
+
+	log := tl.ConsoleLogger("info")
+	h := LogLevelHandleFunc(log)
+
+	tests := []struct {
+		method, body string
+		code         int
+		expected     LogLevel
+	}{
+		{http.MethodGet, "", http.StatusOK, LogLevel{Level: "info"}},
+		{http.MethodPut, `{"level":"DEBUG"}`, http.StatusOK, LogLevel{Level: "debug", Previous: "info"}},
+		{http.MethodGet, "", http.StatusOK, LogLevel{Level: "debug"}},
+		{http.MethodPut, `{"level":"verbose"}`, http.StatusBadRequest, LogLevel{}},
+		{http.MethodPut, `{"level"`, http.StatusBadRequest, LogLevel{}},
+		{http.MethodPost, `{"level":"info"}`, http.StatusMethodNotAllowed, LogLevel{}},
+		{http.MethodPut, `{"level":"info"}`, http.StatusOK, LogLevel{Level: "info", Previous: "debug"}},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(test.method, "http://0/trickster/log/level", strings.NewReader(test.body)))
+		if w.Code != test.code {
+			t.Errorf("(%d) expected %d got %d", i, test.code, w.Code)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		ll := LogLevel{}
+		if err := json.Unmarshal(w.Body.Bytes(), &ll); err != nil {
+			t.Fatal(err)
+		}
+		if ll != test.expected {
+			t.Errorf("(%d) expected %+v got %+v", i, test.expected, ll)
+		}
+	}
+
+	if log.Level() != "info" {
+		t.Errorf("expected %s got %s", "info", log.Level())
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/healthcheck"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
+	"github.com/tricksterproxy/trickster/pkg/proxy/upstream"
+)
+
+// OriginSummary summarizes the runtime state of an Origin
+type OriginSummary struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	Healthy     bool              `json:"healthy"`
+	InFlight    int64             `json:"inFlight"`
+	LastFailure *upstream.Failure `json:"lastFailure,omitempty"`
+}
+
+// OriginState describes the effective configuration and the runtime state of an Origin
+type OriginState struct {
+	OriginSummary
+	URL            string               `json:"url"`
+	IsDefault      bool                 `json:"isDefault"`
+	Cache          OriginCache          `json:"cache"`
+	Timeouts       OriginTimeouts       `json:"timeouts"`
+	TLS            *OriginTLS           `json:"tls,omitempty"`
+	Health         *healthcheck.Status  `json:"health,omitempty"`
+	Concurrency    *OriginConcurrency   `json:"concurrency,omitempty"`
+	RateLimit      *OriginRateLimit     `json:"rateLimit,omitempty"`
+	ClockSkew      string               `json:"clockSkew,omitempty"`
+	LastConnection *upstream.Connection `json:"lastConnection,omitempty"`
+}
+
+// OriginCache describes the caches to which an Origin is bound
+type OriginCache struct {
+	Name          string `json:"name"`
+	Type          string `json:"type,omitempty"`
+	NegativeCache string `json:"negativeCache,omitempty"`
+}
+
+// OriginTimeouts are the effective timeouts of an Origin, after all defaults and overrides
+type OriginTimeouts struct {
+	Timeout            string `json:"timeout"`
+	KeepAliveTimeout   string `json:"keepAliveTimeout"`
+	ClientTimeout      string `json:"clientTimeout,omitempty"`
+	TimeoutMargin      string `json:"timeoutMargin,omitempty"`
+	ConcurrencyMaxWait string `json:"concurrencyMaxWait,omitempty"`
+}
+
+// OriginTLS describes the upstream TLS configuration of an Origin. Only the presence of a
+// client certificate is reported
+type OriginTLS struct {
+	InsecureSkipVerify        bool     `json:"insecureSkipVerify"`
+	CertificateAuthorityPaths []string `json:"certificateAuthorityPaths,omitempty"`
+	ClientCertificate         bool     `json:"clientCertificate"`
+}
+
+// OriginConcurrency describes the state of an Origin's concurrency limit
+type OriginConcurrency struct {
+	Limit   int            `json:"limit"`
+	Active  int            `json:"active"`
+	Waiting map[string]int `json:"waiting"`
+}
+
+// OriginRateLimit describes the current estimate of an Origin's rate limit window
+type OriginRateLimit struct {
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+	Throttled bool      `json:"throttled"`
+}
+
+// OriginsHandleFunc returns a handler that responds with the OriginSummary of each configured
+// Origin, or with the OriginState of the Origin named by the final element of the request path.
+// The Origins' states are those of the Instance, or of the Default Instance when it is nil
+func OriginsHandleFunc(conf *config.Config, inst *instance.Instance,
+	handlerPath string) func(http.ResponseWriter, *http.Request) {
+	inst = instance.OrDefault(inst)
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodGet {
+			HandleMethodNotAllowedResponse(w, r)
+			return
+		}
+
+		var v interface{}
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, handlerPath), "/")
+		if name == "" {
+			names := make([]string, 0, len(conf.Origins))
+			for k := range conf.Origins {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			summaries := make([]OriginSummary, len(names))
+			for i, k := range names {
+				summaries[i] = originSummary(inst, conf.Origins[k])
+			}
+			v = summaries
+		} else {
+			oc, ok := conf.Origins[name]
+			if !ok {
+				txe.NewResponseError(http.StatusNotFound, txe.CodeNotFound,
+					"no origin is named "+name).Respond(w, r)
+				return
+			}
+			v = originState(conf, inst, oc)
+		}
+
+		b, _ := json.Marshal(v)
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
+
+func originSummary(inst *instance.Instance, oc *oo.Options) OriginSummary {
+	s := OriginSummary{Name: oc.Name, Type: oc.OriginType, Healthy: inst.Health.IsHealthy(oc.Name)}
+	if t, ok := inst.Upstreams.Lookup(oc.Name); ok {
+		s.InFlight = t.InFlight()
+		s.LastFailure = t.LastFailure()
+	}
+	return s
+}
+
+func originState(conf *config.Config, inst *instance.Instance, oc *oo.Options) *OriginState {
+	s := &OriginState{
+		OriginSummary: originSummary(inst, oc),
+		URL:           upstream.RedactURL(oc.OriginURL),
+		IsDefault:     oc.IsDefault,
+		Cache:         OriginCache{Name: oc.CacheName, NegativeCache: oc.NegativeCacheName},
+		Timeouts: OriginTimeouts{
+			Timeout:          oc.Timeout.String(),
+			KeepAliveTimeout: (time.Duration(oc.KeepAliveTimeoutSecs) * time.Second).String(),
+		},
+	}
+	if cc, ok := conf.Caches[oc.CacheName]; ok && cc != nil {
+		s.Cache.Type = cc.CacheType
+	}
+	if oc.ClientTimeout > 0 {
+		s.Timeouts.ClientTimeout = oc.ClientTimeout.String()
+	}
+	if oc.TimeoutMargin > 0 {
+		s.Timeouts.TimeoutMargin = oc.TimeoutMargin.String()
+	}
+	if oc.TLS != nil {
+		s.TLS = &OriginTLS{
+			InsecureSkipVerify:        oc.TLS.InsecureSkipVerify,
+			CertificateAuthorityPaths: oc.TLS.CertificateAuthorityPaths,
+			ClientCertificate:         oc.TLS.ClientCertPath != "",
+		}
+	}
+	if hs, ok := inst.Health.OriginStatus(oc.Name); ok {
+		s.Health = &hs
+	}
+	if oc.MaxConcurrentRequests > 0 {
+		s.Timeouts.ConcurrencyMaxWait = oc.ConcurrencyMaxWait.String()
+		l := inst.Limiters.Get(oc.Name, oc.OriginType)
+		s.Concurrency = &OriginConcurrency{Limit: oc.MaxConcurrentRequests, Active: l.Active(),
+			Waiting: make(map[string]int, len(priority.Names))}
+		for n, c := range priority.Names {
+			s.Concurrency.Waiting[n] = l.Waiting(c)
+		}
+	}
+	if remaining, reset, ok := inst.RateLimits.Get(oc.Name, oc.OriginType).Remaining(); ok {
+		s.RateLimit = &OriginRateLimit{Remaining: remaining, Reset: reset,
+			Throttled: inst.RateLimits.Throttled(oc.Name)}
+	}
+	if t := inst.ClockSkews.Get(oc.Name, oc.OriginType); t.Samples() > 0 {
+		s.ClockSkew = t.Estimate().String()
+	}
+	if t, ok := inst.Upstreams.Lookup(oc.Name); ok {
+		s.LastConnection = t.LastConnection()
+	}
+	return s
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"net/http"
 
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 )
 
@@ -41,8 +42,8 @@ func HandleRedirectResponse(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	w.WriteHeader(http.StatusBadRequest)
-	w.Write(nil)
+	txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest,
+		"redirect location not configured").Respond(w, r)
 }
 
 // WithRedirects will attach the configured redirect code and location information to
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/proxy/refresh"
+)
+
+// RefreshJobsHandleFunc responds with the status of the running Refresh Jobs, or of the job named
+// by the 'job' query parameter. A POST with a job name triggers the job to run immediately. The
+// jobs are those of the Instance, or of the Default Instance when it is nil
+func RefreshJobsHandleFunc(inst *instance.Instance) func(http.ResponseWriter, *http.Request) {
+	inst = instance.OrDefault(inst)
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		name := r.URL.Query().Get("job")
+		if r.Method != http.MethodGet && (r.Method != http.MethodPost || name == "") {
+			HandleMethodNotAllowedResponse(w, r)
+			return
+		}
+
+		var v interface{}
+		s := inst.Scheduler()
+		if name == "" {
+			statuses := []refresh.Status{}
+			if s != nil {
+				statuses = s.Statuses()
+			}
+			v = statuses
+		} else {
+			var j *refresh.Job
+			if s != nil {
+				j = s.Job(name)
+			}
+			if j == nil {
+				txe.NewResponseError(http.StatusNotFound, txe.CodeNotFound,
+					"no refresh job is named "+name).Respond(w, r)
+				return
+			}
+			if r.Method == http.MethodPost {
+				j.Trigger()
+			}
+			v = j.Status()
+		}
+
+		b, _ := json.Marshal(v)
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusAccepted)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		w.Write(b)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/refresh"
+	ro "github.com/tricksterproxy/trickster/pkg/proxy/refresh/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRefreshJobsHandleFunc(t *testing.T) {
+
+	o := ro.NewOptions()
+	o.Name = "test"
+	o.OriginName = "default"
+	o.Path = "/"
+	o.Enabled = false
+
+	s := refresh.NewScheduler(map[string]*ro.Options{"test": o},
+		map[string]*oo.Options{"default": {Router: mux.NewRouter()}}, nil, tl.ConsoleLogger("error"))
+	s.Start()
+	defer s.Stop()
+	inst := instance.New()
+	inst.SetScheduler(s)
+	h := RefreshJobsHandleFunc(inst)
+
+	tests := []struct {
+		method, query string
+		expected      int
+	}{
+		{http.MethodGet, "", http.StatusOK},
+		{http.MethodGet, "?job=test", http.StatusOK},
+		{http.MethodPost, "?job=test", http.StatusAccepted},
+		{http.MethodGet, "?job=missing", http.StatusNotFound},
+		{http.MethodPost, "", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "?job=test", http.StatusMethodNotAllowed},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, "http://0/trickster/refresh_jobs"+test.query, nil)
+		h(w, r)
+		if w.Code != test.expected {
+			t.Errorf("test %d: expected %d got %d", i, test.expected, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "http://0/trickster/refresh_jobs", nil))
+	var statuses []refresh.Status
+	if err := json.Unmarshal(w.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Name != "test" || statuses[0].Enabled {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// SignaturePrefix prefixes the hex-encoded HMAC-SHA256 digest in the X-Trickster-Signature header
+const SignaturePrefix = "sha256="
+
+// MaxSignatureAge is the maximum difference between the time at which a request was signed
+// and the time at which it is verified, which limits the replay of captured requests
+const MaxSignatureAge = 5 * time.Minute
+
+// Errors returned when a signed request cannot be verified
+var (
+	ErrSigningNotConfigured = errors.New("request signing is not configured")
+	ErrMissingSignature     = errors.New("missing signature")
+	ErrInvalidSignature     = errors.New("invalid signature")
+	ErrExpiredSignature     = errors.New("signature timestamp is outside of the permitted window")
+)
+
+// Sign returns the X-Trickster-Signature header value of a request body signed with the
+// secret at the epoch timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature verifies that the request, having the provided body, was signed with the
+// secret. The signature is the HMAC-SHA256 of the X-Trickster-Timestamp header value, a
+// period and the body, and the timestamp must be within MaxSignatureAge of now
+func VerifySignature(secret string, r *http.Request, body []byte, now time.Time) error {
+	if secret == "" {
+		return ErrSigningNotConfigured
+	}
+	sig := r.Header.Get(headers.NameTricksterSignature)
+	ts := r.Header.Get(headers.NameTricksterTimestamp)
+	if sig == "" || ts == "" {
+		return ErrMissingSignature
+	}
+	t, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(t, 0)); d > MaxSignatureAge || d < -MaxSignatureAge {
+		return ErrExpiredSignature
+	}
+	if !strings.HasPrefix(sig, SignaturePrefix) ||
+		!hmac.Equal([]byte(sig), []byte(Sign(secret, t, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+func TestVerifySignature(t *testing.T) {
+
+	now := time.Unix(1600000000, 0)
+	body := []byte(`{"origin":"prom1"}`)
+
+	tests := []struct {
+		secret, signWith string
+		signedAt         time.Time
+		body             []byte
+		expected         error
+	}{
+		{"secret", "secret", now, body, nil},
+		{"secret", "secret", now.Add(-MaxSignatureAge), body, nil},
+		{"", "secret", now, body, ErrSigningNotConfigured},
+		{"secret", "", now, body, ErrMissingSignature},
+		{"secret", "other", now, body, ErrInvalidSignature},
+		{"secret", "secret", now, []byte(`{"origin":"prom2"}`), ErrInvalidSignature},
+		{"secret", "secret", now.Add(-MaxSignatureAge - time.Second), body, ErrExpiredSignature},
+		{"secret", "secret", now.Add(MaxSignatureAge + time.Second), body, ErrExpiredSignature},
+	}
+
+	for i, test := range tests {
+		r := httptest.NewRequest("POST", "http://0/trickster/invalidate", nil)
+		if test.signWith != "" {
+			r.Header.Set(headers.NameTricksterTimestamp, strconv.FormatInt(test.signedAt.Unix(), 10))
+			r.Header.Set(headers.NameTricksterSignature,
+				Sign(test.signWith, test.signedAt.Unix(), test.body))
+		}
+		if err := VerifySignature(test.secret, r, body, now); err != test.expected {
+			t.Errorf("(%d) expected %v got %v", i, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+)
+
+// TopQueriesHandleFunc responds with the costs of the most requested query fingerprints. The 'sort'
+// query parameter orders them by 'requests' (the default), 'fetch_time' or 'bytes', and the 'limit'
+// query parameter limits the number of fingerprints in the response. The queries are those tracked
+// by the Instance, or by the Default Instance when it is nil
+func TopQueriesHandleFunc(inst *instance.Instance) func(http.ResponseWriter, *http.Request) {
+	inst = instance.OrDefault(inst)
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodGet {
+			HandleMethodNotAllowedResponse(w, r)
+			return
+		}
+
+		qp := r.URL.Query()
+		var limit int
+		if v := qp.Get("limit"); v != "" {
+			var err error
+			if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+				txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest,
+					"invalid limit: "+v).Respond(w, r)
+				return
+			}
+		}
+
+		entries, err := inst.TopQueries.Top(limit, qp.Get("sort"))
+		if err != nil {
+			txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest, err.Error()).Respond(w, r)
+			return
+		}
+
+		b, _ := json.Marshal(entries)
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
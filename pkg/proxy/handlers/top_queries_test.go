@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/fingerprint"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+)
+
+func TestTopQueriesHandleFunc(t *testing.T) {
+
+	inst := instance.New()
+	tr := inst.TopQueries
+	tr.Resize(3)
+	tr.Record("default", "a", "up", time.Second, 100)
+	tr.Record("default", "a", "up", time.Second, 100)
+	tr.Record("default", "b", "down", 5*time.Second, 10)
+
+	tests := []struct {
+		method, query string
+		expected      int
+		first         string
+		count         int
+	}{
+		{http.MethodGet, "", http.StatusOK, "a", 2},
+		{http.MethodGet, "?sort=fetch_time", http.StatusOK, "b", 2},
+		{http.MethodGet, "?sort=bytes&limit=1", http.StatusOK, "a", 1},
+		{http.MethodGet, "?sort=cost", http.StatusBadRequest, "", 0},
+		{http.MethodGet, "?limit=x", http.StatusBadRequest, "", 0},
+		{http.MethodPost, "", http.StatusMethodNotAllowed, "", 0},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, "http://0/trickster/debug/top-queries"+test.query, nil)
+		TopQueriesHandleFunc(inst)(w, r)
+		if w.Code != test.expected {
+			t.Errorf("test %d: expected %d got %d", i, test.expected, w.Code)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var entries []fingerprint.Entry
+		if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != test.count || entries[0].Fingerprint != test.first {
+			t.Errorf("test %d: unexpected entries %v", i, entries)
+		}
+	}
+}
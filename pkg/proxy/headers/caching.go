@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headers
+
+import (
+	"net/http"
+)
+
+// CacheDeniedHeaders defines a list of response headers that are never stored with cached
+// objects, since they are specific to the client and would leak to other clients on cache hits
+var CacheDeniedHeaders = []string{
+	NameSetCookie,
+	NameAuthorization,
+}
+
+// cacheRequiredHeaders defines a list of response headers that are always stored with cached
+// objects when present, since they are needed to correctly represent and revalidate the object
+var cacheRequiredHeaders = []string{
+	NameContentType,
+	NameContentEncoding,
+	NameContentLength,
+	NameCacheControl,
+	NameETag,
+	NameLastModified,
+	NameExpires,
+}
+
+// IsCacheDenied returns true if the provided header name must never be stored with cached objects.
+// This includes the CacheDeniedHeaders, the HopHeaders, and any header prefixed with "Proxy-"
+func IsCacheDenied(name string) bool {
+	if IsHopByHop(name) {
+		return true
+	}
+	name = http.CanonicalHeaderKey(name)
+	for _, k := range CacheDeniedHeaders {
+		if name == k {
+			return true
+		}
+	}
+	return false
+}
+
+// StripCacheDeniedHeaders removes any headers that must never be stored with cached objects
+func StripCacheDeniedHeaders(h http.Header) {
+	for k := range h {
+		if IsCacheDenied(k) {
+			delete(h, k)
+		}
+	}
+}
+
+// FilterCacheHeaders returns a copy of the provided headers that is suitable for storing with a
+// cached object. Headers that are denied for caching by IsCacheDenied are always removed, as are
+// headers in the deny list. When the allow list is not empty, only the allowed headers, and those
+// required to represent and revalidate the object, are retained
+func FilterCacheHeaders(h http.Header, allow, deny []string) http.Header {
+	if h == nil {
+		return nil
+	}
+	var allowed map[string]bool
+	if len(allow) > 0 {
+		allowed = make(map[string]bool, len(allow)+len(cacheRequiredHeaders))
+		for _, k := range allow {
+			allowed[http.CanonicalHeaderKey(k)] = true
+		}
+		for _, k := range cacheRequiredHeaders {
+			allowed[k] = true
+		}
+	}
+	denied := make(map[string]bool, len(deny))
+	for _, k := range deny {
+		denied[http.CanonicalHeaderKey(k)] = true
+	}
+	h2 := make(http.Header, len(h))
+	for k, v := range h {
+		ck := http.CanonicalHeaderKey(k)
+		if IsCacheDenied(ck) || denied[ck] || (allowed != nil && !allowed[ck]) {
+			continue
+		}
+		h2[k] = append([]string(nil), v...)
+	}
+	return h2
+}
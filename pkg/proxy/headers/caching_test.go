@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func testCacheHeaders() http.Header {
+	return http.Header{
+		NameSetCookie:         []string{"session=trickster"},
+		NameAuthorization:     []string{"Basic dHJpY2tzdGVyOnRyaWNrc3Rlcg=="},
+		NameProxyAuthenticate: []string{"Basic"},
+		"Proxy-Example":       []string{"test"},
+		NameConnection:        []string{"close"},
+		NameTransferEncoding:  []string{"chunked"},
+		NameContentType:       []string{"application/json"},
+		NameCacheControl:      []string{"max-age=60"},
+		"X-Request-Id":        []string{"12345"},
+		"X-Example":           []string{"test"},
+	}
+}
+
+func TestIsCacheDenied(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"set-cookie", true},
+		{NameAuthorization, true},
+		{"Proxy-Anything", true},
+		{NameUpgrade, true},
+		{NameContentType, false},
+		{"X-Request-Id", false},
+	}
+	for _, test := range tests {
+		if v := IsCacheDenied(test.name); v != test.expected {
+			t.Errorf("%s: expected %t got %t", test.name, test.expected, v)
+		}
+	}
+}
+
+func TestStripCacheDeniedHeaders(t *testing.T) {
+	h := testCacheHeaders()
+	StripCacheDeniedHeaders(h)
+	if len(h) != 4 {
+		t.Errorf("expected %d got %d", 4, len(h))
+	}
+	if h.Get(NameSetCookie) != "" {
+		t.Error("expected empty Set-Cookie header")
+	}
+}
+
+func TestFilterCacheHeaders(t *testing.T) {
+
+	if FilterCacheHeaders(nil, nil, nil) != nil {
+		t.Error("expected nil header")
+	}
+
+	h := testCacheHeaders()
+	h2 := FilterCacheHeaders(h, nil, []string{"x-example"})
+	if len(h2) != 3 {
+		t.Errorf("expected %d got %d", 3, len(h2))
+	}
+	if h2.Get("X-Request-Id") != "12345" {
+		t.Errorf("expected %s got %s", "12345", h2.Get("X-Request-Id"))
+	}
+	// the source headers are not modified
+	if h.Get(NameSetCookie) == "" {
+		t.Error("expected Set-Cookie header")
+	}
+
+	// the allow list cannot override the unconditional deny list
+	h2 = FilterCacheHeaders(h, []string{NameSetCookie, "X-Example"}, nil)
+	if len(h2) != 3 {
+		t.Errorf("expected %d got %d", 3, len(h2))
+	}
+	if h2.Get("X-Example") != "test" || h2.Get(NameContentType) == "" || h2.Get(NameSetCookie) != "" {
+		t.Errorf("unexpected headers: %s", LogString(h2))
+	}
+}
@@ -78,6 +78,21 @@ var HopHeaders = []string{
 	NameAcceptEncoding,
 }
 
+// IsHopByHop returns true if the provided header name is only meaningful for a single hop.
+// This includes the HopHeaders, and any header prefixed with "Proxy-"
+func IsHopByHop(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if strings.HasPrefix(name, "Proxy-") {
+		return true
+	}
+	for _, k := range HopHeaders {
+		if name == k {
+			return true
+		}
+	}
+	return false
+}
+
 // ForwardingHeaders defines a list of headers that Proxies use to identify themselves in a request
 var ForwardingHeaders = []string{
 	NameXForwardedFor,
@@ -35,6 +35,25 @@ func TestIsValidForwardingType(t *testing.T) {
 
 }
 
+func TestIsHopByHop(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"connection", true},
+		{NameKeepAlive, true},
+		{NameProxyAuthorization, true},
+		{"Proxy-Anything", true},
+		{NameAuthorization, false},
+		{"X-Request-Id", false},
+	}
+	for _, test := range tests {
+		if v := IsHopByHop(test.name); v != test.expected {
+			t.Errorf("%s: expected %t got %t", test.name, test.expected, v)
+		}
+	}
+}
+
 var testHops1 = &Hop{
 	RemoteAddr: "1.2.3.4",
 	Scheme:     "https",
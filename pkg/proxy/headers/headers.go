@@ -32,6 +32,8 @@ const (
 
 	// ValueApplicationJSON represents the HTTP Header Value of "application/json"
 	ValueApplicationJSON = "application/json"
+	// ValueApplicationNDJSON represents the HTTP Header Value of "application/x-ndjson"
+	ValueApplicationNDJSON = "application/x-ndjson"
 	// ValueMaxAge represents the HTTP Header Value of "max-age"
 	ValueMaxAge = "max-age"
 	// ValueMultipartFormData represents the HTTP Header Value of "multipart/form-data"
@@ -80,10 +82,60 @@ const (
 	NameContentRange = "Content-Range"
 	// NameTricksterResult represents the HTTP Header Name of "X-Trickster-Result"
 	NameTricksterResult = "X-Trickster-Result"
+	// NameTricksterBootstrap represents the HTTP Header Name of "X-Trickster-Bootstrap", which marks
+	// a cache fill request made to a bootstrap peer, so that it is never satisfied by another peer fill
+	NameTricksterBootstrap = "X-Trickster-Bootstrap"
+	// NameTricksterRevalidate represents the HTTP Header Name of "X-Trickster-Revalidate", which, when
+	// permitted by the origin config, bypasses reads from the Negative Cache for the request
+	NameTricksterRevalidate = "X-Trickster-Revalidate"
+	// NameTricksterStepReuse represents the HTTP Header Name of "X-Trickster-Step-Reuse", which
+	// reports when a timeseries response was sampled from a cached timeseries at a finer step
+	NameTricksterStepReuse = "X-Trickster-Step-Reuse"
+	// NameTricksterQuerySplit represents the HTTP Header Name of "X-Trickster-Query-Split", which
+	// reports whether a timeseries query was split into independently cached sub-queries
+	NameTricksterQuerySplit = "X-Trickster-Query-Split"
+	// NameTricksterLookback represents the HTTP Header Name of "X-Trickster-Lookback", which reports
+	// when the start of a timeseries query was clamped to its max lookback
+	NameTricksterLookback = "X-Trickster-Lookback"
+	// NameTricksterEstimatedPoints represents the HTTP Header Name of "X-Trickster-Estimated-Points",
+	// which reports the estimated size of the results of a timeseries query that exceeded its path's
+	// max estimated points, on the request routed to the path's designated origin
+	NameTricksterEstimatedPoints = "X-Trickster-Estimated-Points"
+	// NameTricksterEstimateBypass represents the HTTP Header Name of "X-Trickster-Estimate-Bypass",
+	// which holds the token that exempts a timeseries query from its path's max estimated points
+	NameTricksterEstimateBypass = "X-Trickster-Estimate-Bypass"
+	// NameTricksterPartial represents the HTTP Header Name of "X-Trickster-Partial", which reports
+	// the ranges missing from a best-effort timeseries response whose upstream range fetches failed
+	NameTricksterPartial = "X-Trickster-Partial"
+	// NameTricksterChain represents the HTTP Header Name of "X-Trickster-Chain", which lists the
+	// Trickster instances that a request passed through, and that handled its response
+	NameTricksterChain = "X-Trickster-Chain"
+	// NameTricksterVersion represents the HTTP Header Name of "X-Trickster-Version", which reports
+	// the version of Trickster that answered a request locally on behalf of the origin
+	NameTricksterVersion = "X-Trickster-Version"
+	// NameTricksterSignature represents the HTTP Header Name of "X-Trickster-Signature", which holds
+	// the HMAC signature of a request to an admin handler requiring authentication
+	NameTricksterSignature = "X-Trickster-Signature"
+	// NameTricksterTimestamp represents the HTTP Header Name of "X-Trickster-Timestamp", which holds
+	// the epoch time at which a signed request to an admin handler was signed
+	NameTricksterTimestamp = "X-Trickster-Timestamp"
+	// NameRequestID represents the HTTP Header Name of "X-Request-ID", which identifies a request
+	// in the log events of each Trickster that handles it
+	NameRequestID = "X-Request-ID"
+	// NameIdempotencyKey represents the HTTP Header Name of "Idempotency-Key", which identifies
+	// retries of a request that must only be applied once
+	NameIdempotencyKey = "Idempotency-Key"
+	// NameIdempotentReplayed represents the HTTP Header Name of "Idempotent-Replayed", which reports
+	// that a response was replayed for a retry of a request having the same Idempotency-Key
+	NameIdempotentReplayed = "Idempotent-Replayed"
+	// NameAccept represents the HTTP Header Name of "Accept"
+	NameAccept = "Accept"
 	// NameAcceptEncoding represents the HTTP Header Name of "Accept-Encoding"
 	NameAcceptEncoding = "Accept-Encoding"
 	// NameSetCookie represents the HTTP Header Name of "Set-Cookie"
 	NameSetCookie = "Set-Cookie"
+	// NameCookie represents the HTTP Header Name of "Cookie"
+	NameCookie = "Cookie"
 	// NameRange represents the HTTP Header Name of "Range"
 	NameRange = "Range"
 	// NameTransferEncoding represents the HTTP Header Name of "Transfer-Encoding"
@@ -122,6 +174,12 @@ const (
 	NameTrailer = "Trailer"
 	// NameUpgrade represents the HTTP Header Name of "Upgrade"
 	NameUpgrade = "Upgrade"
+	// NameVary represents the HTTP Header Name of "Vary"
+	NameVary = "Vary"
+	// NameRetryAfter represents the HTTP Header Name of "Retry-After"
+	NameRetryAfter = "Retry-After"
+	// NameWarning represents the HTTP Header Name of "Warning"
+	NameWarning = "Warning"
 )
 
 // Merge merges the source http.Header map into destination map.
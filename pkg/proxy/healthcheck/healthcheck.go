@@ -0,0 +1,340 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package healthcheck provides upstream health checking for Origins,
+// both on-demand and via a background prober
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	ho "github.com/tricksterproxy/trickster/pkg/proxy/healthcheck/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/upstream"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	"github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// MaxBodyBytes is the maximum number of health check response body bytes evaluated by the prober
+const MaxBodyBytes = 1024 * 1024
+
+// Checker is implemented by Origin Clients that provide upstream health checks
+type Checker interface {
+	// DefaultHealthCheckConfig returns the provider-specific default Health Check Options
+	DefaultHealthCheckConfig() *ho.Options
+}
+
+// Target is a fully-resolved upstream health check for an Origin
+type Target struct {
+	originName string
+	originType string
+	options    *ho.Options
+	url        *url.URL
+	header     http.Header
+
+	registry *Registry
+	stopCh   chan bool
+	stopOnce sync.Once
+	// unhealthy is set to 1 while the most recent background probe has failed
+	unhealthy int32
+
+	mtx       sync.Mutex
+	lastProbe time.Time
+	lastError string
+}
+
+// Status describes the state of the background prober of an Origin
+type Status struct {
+	Healthy   bool       `json:"healthy"`
+	URL       string     `json:"url"`
+	Interval  string     `json:"interval"`
+	Timeout   string     `json:"timeout"`
+	LastProbe *time.Time `json:"lastProbe,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// Registry holds the running background probers of a router's origins
+type Registry struct {
+	metrics *metrics.Metrics
+	running map[*Target]bool
+	mtx     sync.Mutex
+}
+
+// NewRegistry returns a new Registry whose probers report to the Metrics
+func NewRegistry(m *metrics.Metrics) *Registry {
+	return &Registry{metrics: m, running: make(map[*Target]bool)}
+}
+
+// NewTarget returns a new Target for the Origin, using the base URL and provider-specific defaults.
+// A nil Target is returned if the health check has been disabled by setting an empty verb
+func NewTarget(oc *oo.Options, base *url.URL, defaults *ho.Options) (*Target, error) {
+
+	o, err := ho.New(oc, defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Verb == "" {
+		return nil, nil
+	}
+
+	t := &Target{
+		originName: oc.Name,
+		originType: oc.OriginType,
+		options:    o,
+		header:     http.Header{},
+	}
+
+	if base != nil {
+		t.url = urls.Clone(base)
+	} else {
+		t.url = &url.URL{}
+	}
+	t.url.Path += o.Path
+
+	if o.Headers != nil {
+		headers.UpdateHeaders(t.header, o.Headers)
+	}
+
+	if o.QueryAsBody && o.Query != "" && o.Body == "" && methods.HasBody(o.Verb) {
+		o.Body = o.Query
+		if t.header.Get(headers.NameContentType) == "" {
+			t.header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+		}
+	} else {
+		t.url.RawQuery = o.Query
+	}
+
+	return t, nil
+}
+
+// NewOriginTarget returns a new Target for the Origin, using its upstream base URL and the
+// Checker's provider-specific defaults
+func NewOriginTarget(oc *oo.Options, c Checker) (*Target, error) {
+	return NewTarget(oc, urls.FromParts(oc.Scheme, oc.Host, oc.PathPrefix, "", ""),
+		c.DefaultHealthCheckConfig())
+}
+
+// OriginName returns the name of the Origin whose upstream the Target checks
+func (t *Target) OriginName() string {
+	return t.originName
+}
+
+// Options returns the resolved Health Check Options for the Target
+func (t *Target) Options() *ho.Options {
+	return t.options
+}
+
+// URL returns the full upstream URL for the health check
+func (t *Target) URL() *url.URL {
+	return urls.Clone(t.url)
+}
+
+// NewRequest returns a new upstream health check request derived from the provided context,
+// bounded by the health check timeout. The returned CancelFunc must be called when the request is complete
+func (t *Target) NewRequest(ctx context.Context) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, t.options.Timeout)
+	var body io.Reader
+	if t.options.Body != "" {
+		body = strings.NewReader(t.options.Body)
+	}
+	req, _ := http.NewRequest(t.options.Verb, t.url.String(), body)
+	req = req.WithContext(ctx)
+	req.Header = t.header.Clone()
+	return req, cancel
+}
+
+// Check evaluates the upstream response status code and body against the Target's expectations,
+// and returns an error describing the first failed expectation, or nil if the upstream is healthy
+func (t *Target) Check(code int, body []byte) error {
+	if !t.options.IsExpectedCode(code) {
+		return fmt.Errorf("unexpected health check response code: %d", code)
+	}
+	if t.options.ExpectedBody != "" && !bytes.Contains(body, []byte(t.options.ExpectedBody)) {
+		return fmt.Errorf("health check response body does not contain: %s", t.options.ExpectedBody)
+	}
+	if re := t.options.ExpectedBodyRE(); re != nil && !re.Match(body) {
+		return fmt.Errorf("health check response body does not match: %s", t.options.ExpectedBodyRegex)
+	}
+	return nil
+}
+
+// Probe makes a single upstream health check request using the provided client and evaluates the result
+func (t *Target) Probe(client *http.Client) error {
+	return t.ProbeContext(context.Background(), client)
+}
+
+// ProbeContext is Probe, with the request derived from the provided context, such that it is bounded by
+// the earlier of the context's deadline and the health check timeout
+func (t *Target) ProbeContext(ctx context.Context, client *http.Client) error {
+	req, cancel := t.NewRequest(ctx)
+	defer cancel()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxBodyBytes))
+	if err != nil {
+		return err
+	}
+	return t.Check(resp.StatusCode, body)
+}
+
+// Start begins probing the upstream in the background at the configured interval, and holds
+// the running prober in the Registry until it is stopped. Start is a no-op if the interval is not set
+func (t *Target) Start(r *Registry, client *http.Client, logger *log.Logger) {
+	if t.options.Interval <= 0 || client == nil {
+		return
+	}
+	t.registry = r
+	t.stopCh = make(chan bool)
+	r.mtx.Lock()
+	r.running[t] = true
+	r.mtx.Unlock()
+	go t.probe(client, logger)
+}
+
+func (t *Target) probe(client *http.Client, logger *log.Logger) {
+	ticker := time.NewTicker(t.options.Interval)
+	defer ticker.Stop()
+	status := metrics.OrDefault(t.registry.metrics).ProxyOriginHealthStatus.
+		WithLabelValues(t.originName, t.originType)
+	healthy := true
+	for {
+		err := t.Probe(client)
+		if err != nil {
+			status.Set(0)
+			if healthy {
+				logger.Warn("upstream health check failed",
+					log.Pairs{"originName": t.originName, "url": t.url.String(), "detail": err.Error()})
+			}
+		} else {
+			status.Set(1)
+			if !healthy {
+				logger.Info("upstream health check recovered",
+					log.Pairs{"originName": t.originName, "url": t.url.String()})
+			}
+		}
+		healthy = err == nil
+		t.mtx.Lock()
+		t.lastProbe = time.Now()
+		if healthy {
+			t.lastError = ""
+		} else {
+			t.lastError = upstream.Sanitize(err)
+		}
+		t.mtx.Unlock()
+		if healthy {
+			atomic.StoreInt32(&t.unhealthy, 0)
+		} else {
+			atomic.StoreInt32(&t.unhealthy, 1)
+		}
+		select {
+		case <-t.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop ends background probing of the upstream
+func (t *Target) Stop() {
+	if t.stopCh == nil {
+		return
+	}
+	t.stopOnce.Do(func() {
+		close(t.stopCh)
+		t.registry.mtx.Lock()
+		delete(t.registry.running, t)
+		t.registry.mtx.Unlock()
+	})
+}
+
+// Healthy returns false if the most recent background probe of the upstream has failed
+func (t *Target) Healthy() bool {
+	return atomic.LoadInt32(&t.unhealthy) == 0
+}
+
+// IsHealthy returns false if the named Origin's running background prober reports it as unhealthy.
+// Origins without a running prober are presumed healthy
+func (r *Registry) IsHealthy(originName string) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for t := range r.running {
+		if t.originName == originName && !t.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// Status returns the Status of the Target's background prober. The URL and error omit the query
+// string of the health check, which may hold credentials
+func (t *Target) Status() Status {
+	u := urls.Clone(t.url)
+	u.RawQuery = ""
+	u.User = nil
+	s := Status{Healthy: t.Healthy(), URL: u.String(), Interval: t.options.Interval.String(),
+		Timeout: t.options.Timeout.String()}
+	t.mtx.Lock()
+	if !t.lastProbe.IsZero() {
+		lp := t.lastProbe
+		s.LastProbe = &lp
+	}
+	s.LastError = t.lastError
+	t.mtx.Unlock()
+	return s
+}
+
+// OriginStatus returns the Status of the named Origin's running background prober, and false
+// if it has none
+func (r *Registry) OriginStatus(originName string) (Status, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for t := range r.running {
+		if t.originName == originName {
+			return t.Status(), true
+		}
+	}
+	return Status{}, false
+}
+
+// StopAll ends background probing for all of the Registry's running Targets
+func (r *Registry) StopAll() {
+	r.mtx.Lock()
+	targets := make([]*Target, 0, len(r.running))
+	for t := range r.running {
+		targets = append(targets, t)
+	}
+	r.mtx.Unlock()
+	for _, t := range targets {
+		t.Stop()
+	}
+}
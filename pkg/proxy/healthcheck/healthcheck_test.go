@@ -0,0 +1,346 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	ho "github.com/tricksterproxy/trickster/pkg/proxy/healthcheck/options"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestNewTarget(t *testing.T) {
+
+	oc := oo.NewOptions()
+	oc.Name = "test"
+	oc.HealthCheckHeaders = map[string]string{"X-Test": "test"}
+
+	base, _ := url.Parse("http://127.0.0.1/prefix")
+	defaults := &ho.Options{Verb: http.MethodPost, Path: "/", Query: "a=b", Body: "SELECT 1"}
+
+	tgt, err := NewTarget(oc, base, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := tgt.URL().String(); v != "http://127.0.0.1/prefix/?a=b" {
+		t.Errorf("expected %s got %s", "http://127.0.0.1/prefix/?a=b", v)
+	}
+
+	req, cancel := tgt.NewRequest(context.Background())
+	defer cancel()
+	if req.Method != http.MethodPost {
+		t.Errorf("expected %s got %s", http.MethodPost, req.Method)
+	}
+	if req.Header.Get("X-Test") != "test" {
+		t.Errorf("expected %s got %s", "test", req.Header.Get("X-Test"))
+	}
+	b, _ := ioutil.ReadAll(req.Body)
+	if string(b) != "SELECT 1" {
+		t.Errorf("expected %s got %s", "SELECT 1", string(b))
+	}
+	if _, ok := req.Context().Deadline(); !ok {
+		t.Error("expected request deadline")
+	}
+
+	// an empty verb disables the health check
+	oc.HealthCheckVerb = ""
+	tgt, err = NewTarget(oc, base, defaults)
+	if err != nil {
+		t.Error(err)
+	}
+	if tgt != nil {
+		t.Error("expected nil target")
+	}
+
+	oc.HealthCheckVerb = http.MethodGet
+	oc.HealthCheckBody = "test"
+	_, err = NewTarget(oc, base, defaults)
+	if err != ho.ErrBodyNotAllowed {
+		t.Errorf("expected %v got %v", ho.ErrBodyNotAllowed, err)
+	}
+}
+
+func TestNewTargetQueryAsBody(t *testing.T) {
+
+	oc := oo.NewOptions()
+	oc.Name = "test"
+	oc.HealthCheckQuery = "q=SHOW+DATABASES"
+
+	base, _ := url.Parse("http://127.0.0.1")
+	defaults := &ho.Options{Verb: http.MethodGet, Path: "/query", QueryAsBody: true}
+
+	// the query remains the URL query string for verbs without a body
+	tgt, err := NewTarget(oc, base, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := tgt.URL().String(); v != "http://127.0.0.1/query?q=SHOW+DATABASES" {
+		t.Errorf("expected %s got %s", "http://127.0.0.1/query?q=SHOW+DATABASES", v)
+	}
+
+	// and is the form body for verbs with one
+	oc.HealthCheckVerb = http.MethodPost
+	tgt, err = NewTarget(oc, base, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := tgt.URL().String(); v != "http://127.0.0.1/query" {
+		t.Errorf("expected %s got %s", "http://127.0.0.1/query", v)
+	}
+	req, cancel := tgt.NewRequest(context.Background())
+	defer cancel()
+	if v := req.Header.Get(headers.NameContentType); v != headers.ValueXFormURLEncoded {
+		t.Errorf("expected %s got %s", headers.ValueXFormURLEncoded, v)
+	}
+	b, _ := ioutil.ReadAll(req.Body)
+	if string(b) != "q=SHOW+DATABASES" {
+		t.Errorf("expected %s got %s", "q=SHOW+DATABASES", string(b))
+	}
+
+	// unless a body is configured
+	oc.HealthCheckBody = "test"
+	tgt, err = NewTarget(oc, base, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := tgt.URL().String(); v != "http://127.0.0.1/query?q=SHOW+DATABASES" {
+		t.Errorf("expected %s got %s", "http://127.0.0.1/query?q=SHOW+DATABASES", v)
+	}
+}
+
+func TestCheck(t *testing.T) {
+
+	oc := oo.NewOptions()
+	oc.HealthCheckExpectedCodes = []int{200}
+	oc.HealthCheckExpectedBody = "ready"
+	oc.HealthCheckExpectedBodyRegex = `"status":\s*"ok"`
+
+	tgt, err := NewTarget(oc, nil, &ho.Options{Verb: http.MethodGet})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tgt.Check(200, []byte(`{"ready":true,"status": "ok"}`)); err != nil {
+		t.Error(err)
+	}
+	if err := tgt.Check(204, []byte(`{"ready":true,"status": "ok"}`)); err == nil {
+		t.Error("expected error for unexpected code")
+	}
+	if err := tgt.Check(200, []byte(`{"status": "ok"}`)); err == nil {
+		t.Error("expected error for missing body substring")
+	}
+	if err := tgt.Check(200, []byte(`{"ready":true,"status": "down"}`)); err == nil {
+		t.Error("expected error for body regex mismatch")
+	}
+}
+
+func TestProbe(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer ts.Close()
+
+	base, _ := url.Parse(ts.URL)
+	oc := oo.NewOptions()
+	oc.HealthCheckExpectedBody = "1"
+
+	tgt, err := NewTarget(oc, base, &ho.Options{Verb: http.MethodPost, Path: "/", Body: "SELECT 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tgt.Probe(ts.Client()); err != nil {
+		t.Error(err)
+	}
+
+	oc.HealthCheckVerb = http.MethodPut
+	tgt, _ = NewTarget(oc, base, &ho.Options{Verb: http.MethodPost, Path: "/", Body: "SELECT 1"})
+	if err := tgt.Probe(ts.Client()); err == nil {
+		t.Error("expected error for unexpected code")
+	}
+
+	ts.Close()
+	if err := tgt.Probe(ts.Client()); err == nil {
+		t.Error("expected error for closed server")
+	}
+}
+
+func TestProbeContext(t *testing.T) {
+
+	release := make(chan bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	oc := oo.NewOptions()
+	oc.Scheme = "http"
+	oc.Host = strings.TrimPrefix(ts.URL, "http://")
+	tgt, err := NewOriginTarget(oc, testChecker{&ho.Options{Verb: http.MethodGet, Path: "/health",
+		Timeout: time.Minute}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tgt.URL().String() != ts.URL+"/health" {
+		t.Errorf("expected %s got %s", ts.URL+"/health", tgt.URL().String())
+	}
+
+	// the context's deadline bounds the probe when it is earlier than the health check timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := tgt.ProbeContext(ctx, ts.Client()); err == nil {
+		t.Error("expected error for exceeded deadline")
+	}
+}
+
+type testChecker struct {
+	defaults *ho.Options
+}
+
+func (c testChecker) DefaultHealthCheckConfig() *ho.Options {
+	return c.defaults
+}
+
+func TestStartStop(t *testing.T) {
+
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer ts.Close()
+
+	base, _ := url.Parse(ts.URL)
+	oc := oo.NewOptions()
+	oc.Name = "test"
+	logger := tl.ConsoleLogger("error")
+	r := NewRegistry(nil)
+
+	// no interval means no background prober
+	tgt, _ := NewTarget(oc, base, &ho.Options{Verb: http.MethodGet})
+	tgt.Start(r, ts.Client(), logger)
+	tgt.Stop()
+
+	oc.HealthCheckIntervalMS = 10
+	tgt, _ = NewTarget(oc, base, &ho.Options{Verb: http.MethodGet})
+	tgt.Start(r, ts.Client(), logger)
+
+	time.Sleep(50 * time.Millisecond)
+	r.StopAll()
+	// Stop is safe to call multiple times
+	tgt.Stop()
+
+	n := atomic.LoadInt32(&hits)
+	if n == 0 {
+		t.Error("expected background probes")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if m := atomic.LoadInt32(&hits); m > n+1 {
+		t.Errorf("expected probes to stop, got %d after %d", m, n)
+	}
+}
+
+func TestIsHealthy(t *testing.T) {
+
+	code := int32(http.StatusOK)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&code)))
+	}))
+	defer ts.Close()
+
+	base, _ := url.Parse(ts.URL)
+	oc := oo.NewOptions()
+	oc.Name = "test"
+	oc.HealthCheckIntervalMS = 10
+	r := NewRegistry(nil)
+
+	if !r.IsHealthy("test") {
+		t.Error("expected origin without a prober to be healthy")
+	}
+
+	tgt, _ := NewTarget(oc, base, &ho.Options{Verb: http.MethodGet})
+	tgt.Start(r, ts.Client(), tl.ConsoleLogger("error"))
+	defer tgt.Stop()
+
+	time.Sleep(30 * time.Millisecond)
+	if !r.IsHealthy("test") {
+		t.Error("expected healthy origin")
+	}
+	atomic.StoreInt32(&code, http.StatusInternalServerError)
+	time.Sleep(50 * time.Millisecond)
+	if r.IsHealthy("test") {
+		t.Error("expected unhealthy origin")
+	}
+	if !r.IsHealthy("other") {
+		t.Error("expected other origin to be healthy")
+	}
+}
+
+func TestOriginStatus(t *testing.T) {
+
+	code := int32(http.StatusOK)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&code)))
+	}))
+	defer ts.Close()
+
+	base, _ := url.Parse(ts.URL)
+	oc := oo.NewOptions()
+	oc.Name = "status"
+	oc.HealthCheckIntervalMS = 10
+	r := NewRegistry(nil)
+
+	if _, ok := r.OriginStatus("status"); ok {
+		t.Error("expected no status for an origin without a prober")
+	}
+
+	tgt, _ := NewTarget(oc, base, &ho.Options{Verb: http.MethodGet, Query: "token=secret"})
+	tgt.Start(r, ts.Client(), tl.ConsoleLogger("error"))
+	defer tgt.Stop()
+
+	time.Sleep(30 * time.Millisecond)
+	s, ok := r.OriginStatus("status")
+	if !ok || !s.Healthy || s.LastProbe == nil || s.LastError != "" {
+		t.Errorf("unexpected status %+v", s)
+	}
+	if strings.Contains(s.URL, "secret") {
+		t.Errorf("expected the query to be omitted from %s", s.URL)
+	}
+
+	atomic.StoreInt32(&code, http.StatusInternalServerError)
+	time.Sleep(50 * time.Millisecond)
+	s, _ = r.OriginStatus("status")
+	if s.Healthy || s.LastError != "unexpected health check response code: 500" {
+		t.Errorf("unexpected status %+v", s)
+	}
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the upstream Health Check Options for Origins
+package options
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+)
+
+// Options defines how Trickster checks the health of an upstream Origin
+type Options struct {
+	// Verb is the HTTP Method used for the health check request
+	Verb string
+	// Path is the URL Path appended to the Origin's base URL for the health check request
+	Path string
+	// Query is the raw URL Query String for the health check request
+	Query string
+	// Headers are the HTTP Headers to include in the health check request
+	Headers map[string]string
+	// Body is the HTTP Request Body for the health check request
+	Body string
+	// ExpectedCodes is the list of HTTP status codes that indicate a healthy upstream.
+	// When empty, any 2xx response code is considered healthy
+	ExpectedCodes []int
+	// ExpectedBody is a substring that must be present in a healthy response body
+	ExpectedBody string
+	// ExpectedBodyRegex is a regular expression that a healthy response body must match
+	ExpectedBodyRegex string
+	// Timeout is how long to wait for a health check response
+	Timeout time.Duration
+	// Interval is how often the background prober checks the upstream (0 = disabled)
+	Interval time.Duration
+	// AllowGetBody permits a Request Body with GET and HEAD health checks
+	AllowGetBody bool
+	// QueryAsBody sends the Query as a form-encoded Request Body, rather than as the URL Query
+	// String, when the Verb has a body and no Body is set
+	QueryAsBody bool
+
+	expectedBodyRE *regexp.Regexp
+}
+
+// ErrBodyNotAllowed is returned when a health check defines a request body with a verb that does not permit one
+var ErrBodyNotAllowed = errors.New("health check body is not permitted with GET or HEAD unless health_check_allow_get_body is true")
+
+// New returns a new Health Check Options based on the provided Origin Options,
+// using the provided provider-specific defaults for any values not set on the Origin
+func New(oc *oo.Options, defaults *Options) (*Options, error) {
+
+	o := &Options{}
+	if defaults != nil {
+		o = defaults.Clone()
+	}
+
+	if oc.HealthCheckVerb != d.DefaultHealthCheckVerb {
+		o.Verb = oc.HealthCheckVerb
+	}
+	if oc.HealthCheckUpstreamPath != d.DefaultHealthCheckPath {
+		o.Path = oc.HealthCheckUpstreamPath
+	}
+	if oc.HealthCheckQuery != d.DefaultHealthCheckQuery {
+		o.Query = oc.HealthCheckQuery
+	}
+	if oc.HealthCheckBody != d.DefaultHealthCheckBody {
+		o.Body = oc.HealthCheckBody
+	}
+	if len(oc.HealthCheckHeaders) > 0 {
+		if o.Headers == nil {
+			o.Headers = make(map[string]string)
+		}
+		for k, v := range oc.HealthCheckHeaders {
+			o.Headers[k] = v
+		}
+	}
+	if len(oc.HealthCheckExpectedCodes) > 0 {
+		o.ExpectedCodes = make([]int, len(oc.HealthCheckExpectedCodes))
+		copy(o.ExpectedCodes, oc.HealthCheckExpectedCodes)
+	}
+	if oc.HealthCheckExpectedBody != "" {
+		o.ExpectedBody = oc.HealthCheckExpectedBody
+	}
+	if oc.HealthCheckExpectedBodyRegex != "" {
+		o.ExpectedBodyRegex = oc.HealthCheckExpectedBodyRegex
+	}
+	if oc.HealthCheckTimeoutMS > 0 {
+		o.Timeout = time.Duration(oc.HealthCheckTimeoutMS) * time.Millisecond
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = time.Duration(d.DefaultHealthCheckTimeoutMS) * time.Millisecond
+	}
+	if oc.HealthCheckIntervalMS > 0 {
+		o.Interval = time.Duration(oc.HealthCheckIntervalMS) * time.Millisecond
+	}
+	o.AllowGetBody = o.AllowGetBody || oc.HealthCheckAllowGetBody
+
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Validate checks the Options for errors and compiles the Expected Body Regex
+func (o *Options) Validate() error {
+	if o.Body != "" && !o.AllowGetBody &&
+		(o.Verb == http.MethodGet || o.Verb == http.MethodHead) {
+		return ErrBodyNotAllowed
+	}
+	for _, c := range o.ExpectedCodes {
+		if c < 100 || c > 599 {
+			return fmt.Errorf("invalid health check expected code: %d", c)
+		}
+	}
+	o.expectedBodyRE = nil
+	if o.ExpectedBodyRegex != "" {
+		re, err := regexp.Compile(o.ExpectedBodyRegex)
+		if err != nil {
+			return fmt.Errorf("invalid health check expected body regex: %s", err.Error())
+		}
+		o.expectedBodyRE = re
+	}
+	return nil
+}
+
+// IsExpectedCode returns true if the provided status code indicates a healthy upstream
+func (o *Options) IsExpectedCode(code int) bool {
+	if len(o.ExpectedCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, c := range o.ExpectedCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpectedBodyRE returns the compiled Expected Body Regex, or nil if none is set
+func (o *Options) ExpectedBodyRE() *regexp.Regexp {
+	return o.expectedBodyRE
+}
+
+// Clone returns an exact copy of the Options
+func (o *Options) Clone() *Options {
+	c := &Options{
+		Verb:              o.Verb,
+		Path:              o.Path,
+		Query:             o.Query,
+		Body:              o.Body,
+		ExpectedBody:      o.ExpectedBody,
+		ExpectedBodyRegex: o.ExpectedBodyRegex,
+		Timeout:           o.Timeout,
+		Interval:          o.Interval,
+		AllowGetBody:      o.AllowGetBody,
+		QueryAsBody:       o.QueryAsBody,
+		expectedBodyRE:    o.expectedBodyRE,
+	}
+	if o.Headers != nil {
+		c.Headers = make(map[string]string)
+		for k, v := range o.Headers {
+			c.Headers[k] = v
+		}
+	}
+	if o.ExpectedCodes != nil {
+		c.ExpectedCodes = make([]int, len(o.ExpectedCodes))
+		copy(c.ExpectedCodes, o.ExpectedCodes)
+	}
+	return c
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+)
+
+func TestNew(t *testing.T) {
+
+	defaults := &Options{
+		Verb:    http.MethodPost,
+		Path:    "/",
+		Body:    "SELECT 1",
+		Headers: map[string]string{"X-Test": "default"},
+	}
+
+	oc := oo.NewOptions()
+	o, err := New(oc, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Verb != http.MethodPost || o.Path != "/" || o.Body != "SELECT 1" {
+		t.Errorf("expected provider defaults, got %s %s %s", o.Verb, o.Path, o.Body)
+	}
+	if o.Timeout != time.Duration(d.DefaultHealthCheckTimeoutMS)*time.Millisecond {
+		t.Errorf("expected default timeout got %s", o.Timeout)
+	}
+	if o.Interval != 0 {
+		t.Errorf("expected disabled interval got %s", o.Interval)
+	}
+
+	oc.HealthCheckVerb = http.MethodPut
+	oc.HealthCheckUpstreamPath = "/health"
+	oc.HealthCheckQuery = "a=b"
+	oc.HealthCheckBody = ""
+	oc.HealthCheckHeaders = map[string]string{"X-Other": "origin"}
+	oc.HealthCheckExpectedCodes = []int{204}
+	oc.HealthCheckExpectedBody = "ok"
+	oc.HealthCheckExpectedBodyRegex = "^o"
+	oc.HealthCheckTimeoutMS = 500
+	oc.HealthCheckIntervalMS = 1000
+
+	o, err = New(oc, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Verb != http.MethodPut || o.Path != "/health" || o.Query != "a=b" || o.Body != "" {
+		t.Errorf("expected origin values, got %s %s %s %s", o.Verb, o.Path, o.Query, o.Body)
+	}
+	if len(o.Headers) != 2 {
+		t.Errorf("expected %d got %d", 2, len(o.Headers))
+	}
+	if len(o.ExpectedCodes) != 1 || o.ExpectedCodes[0] != 204 {
+		t.Errorf("unexpected codes %v", o.ExpectedCodes)
+	}
+	if o.Timeout != 500*time.Millisecond {
+		t.Errorf("expected %s got %s", 500*time.Millisecond, o.Timeout)
+	}
+	if o.Interval != time.Second {
+		t.Errorf("expected %s got %s", time.Second, o.Interval)
+	}
+	if o.ExpectedBodyRE() == nil {
+		t.Error("expected compiled regex")
+	}
+
+	// the defaults should not be modified by the origin overlay
+	if len(defaults.Headers) != 1 {
+		t.Errorf("expected %d got %d", 1, len(defaults.Headers))
+	}
+}
+
+func TestNewGetBody(t *testing.T) {
+
+	oc := oo.NewOptions()
+	oc.HealthCheckVerb = http.MethodGet
+	oc.HealthCheckBody = "test"
+
+	_, err := New(oc, nil)
+	if err != ErrBodyNotAllowed {
+		t.Errorf("expected %v got %v", ErrBodyNotAllowed, err)
+	}
+
+	// a provider's default body is not inherited silently when overriding to GET
+	oc.HealthCheckBody = d.DefaultHealthCheckBody
+	_, err = New(oc, &Options{Verb: http.MethodPost, Body: "SELECT 1"})
+	if err != ErrBodyNotAllowed {
+		t.Errorf("expected %v got %v", ErrBodyNotAllowed, err)
+	}
+
+	oc.HealthCheckAllowGetBody = true
+	_, err = New(oc, &Options{Verb: http.MethodPost, Body: "SELECT 1"})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	o := &Options{ExpectedCodes: []int{42}}
+	if err := o.Validate(); err == nil {
+		t.Error("expected error for invalid code")
+	}
+
+	o = &Options{ExpectedBodyRegex: "["}
+	if err := o.Validate(); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+}
+
+func TestIsExpectedCode(t *testing.T) {
+
+	o := &Options{}
+	if !o.IsExpectedCode(204) {
+		t.Error("expected 2xx to be healthy")
+	}
+	if o.IsExpectedCode(500) {
+		t.Error("expected 500 to be unhealthy")
+	}
+
+	o.ExpectedCodes = []int{401}
+	if !o.IsExpectedCode(401) {
+		t.Error("expected 401 to be healthy")
+	}
+	if o.IsExpectedCode(200) {
+		t.Error("expected 200 to be unhealthy")
+	}
+}
+
+func TestClone(t *testing.T) {
+
+	o := &Options{
+		Verb:          http.MethodGet,
+		Headers:       map[string]string{"test": "test"},
+		ExpectedCodes: []int{200},
+		QueryAsBody:   true,
+	}
+
+	o2 := o.Clone()
+	o2.Headers["test"] = "changed"
+	o2.ExpectedCodes[0] = 204
+
+	if o.Headers["test"] != "test" || o.ExpectedCodes[0] != 200 {
+		t.Error("expected clone to not modify the original")
+	}
+	if o2.Verb != http.MethodGet {
+		t.Errorf("expected %s got %s", http.MethodGet, o2.Verb)
+	}
+	if !o2.QueryAsBody {
+		t.Error("expected QueryAsBody to be cloned")
+	}
+}
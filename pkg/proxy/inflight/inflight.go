@@ -0,0 +1,329 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package inflight accounts for the memory held by in-flight requests while their upstream
+// responses and cached documents are processed, and limits it to a budget in bytes
+package inflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// ErrUnavailable is returned when a request cannot reserve capacity in the budget
+// before its timeout elapses
+var ErrUnavailable = errors.New("timed out waiting for in-flight processing capacity")
+
+const (
+	// defaultRatio is the initial ratio of the bytes held while processing a document to the
+	// document's size, accounting for the unmarshaled document and the marshaled output
+	defaultRatio = 3.0
+	// maxRatio bounds the refined ratio, so that a single unusual request cannot skew it
+	maxRatio = 32.0
+	// defaultTypicalBytes is the initial estimate of the size of a document whose size is unknown
+	defaultTypicalBytes = 1 << 20
+	// smoothing is the weight of each new observation in the refined estimates
+	smoothing = 0.2
+)
+
+// Budget is a weighted semaphore in bytes, from which requests reserve capacity for the
+// estimated memory required to process their documents. Estimates are the size of each
+// document multiplied by a ratio, which is refined as the actual sizes become known
+type Budget struct {
+	capacity int64
+	timeout  time.Duration
+	used     int64
+	peak     int64
+	ratio    float64
+	typical  float64
+	waiters  []*waiter
+	metrics  *metrics.Metrics
+	mtx      sync.Mutex
+}
+
+type waiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// NewBudget returns a new Budget of the provided capacity in bytes, where requests wait up to
+// the timeout for capacity to become available, and which reports to the Metrics. A capacity of
+// 0 does not limit requests, but continues to account for them
+func NewBudget(capacity int64, timeout time.Duration, m *metrics.Metrics) *Budget {
+	return &Budget{capacity: capacity, timeout: timeout, ratio: defaultRatio,
+		typical: defaultTypicalBytes, metrics: m}
+}
+
+// Configure sets the capacity and timeout of the Budget. Reservations that are already
+// held are retained, and waiting requests are granted if the capacity has grown
+func (b *Budget) Configure(capacity int64, timeout time.Duration) {
+	b.mtx.Lock()
+	b.capacity = capacity
+	b.timeout = timeout
+	b.grant()
+	b.mtx.Unlock()
+	metrics.OrDefault(b.metrics).ProxyMaxInflightProcessingBytes.Set(float64(capacity))
+}
+
+// Capacity returns the capacity of the Budget in bytes (0 = unlimited)
+func (b *Budget) Capacity() int64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.capacity
+}
+
+// Used returns the bytes currently reserved from the Budget
+func (b *Budget) Used() int64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.used
+}
+
+// Peak returns the most bytes that have been reserved from the Budget at once
+func (b *Budget) Peak() int64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.peak
+}
+
+// Estimate returns the bytes reserved to process a document of size bytes, or of the typical
+// document size when size is negative, such as for a response without a Content-Length
+func (b *Budget) Estimate(size int64) int64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.estimate(size)
+}
+
+// estimate is Estimate for a caller that holds the lock
+func (b *Budget) estimate(size int64) int64 {
+	if size < 0 {
+		return int64(b.typical * b.ratio)
+	}
+	return int64(float64(size) * b.ratio)
+}
+
+// Acquire reserves capacity for processing a document of size bytes, or of unknown size when
+// size is negative, waiting until the capacity is available. It returns ErrUnavailable if the
+// capacity does not become available within the Budget's timeout, or the context's error if the
+// context is done first. A reservation larger than the capacity waits for the whole capacity
+func (b *Budget) Acquire(ctx context.Context, size int64) (*Reservation, error) {
+	b.mtx.Lock()
+	n := b.estimate(size)
+	r := &Reservation{b: b, held: n}
+	if size < 0 {
+		r.guess = n
+	} else {
+		r.size = size
+		b.observeSize(size)
+	}
+	if b.capacity > 0 && n > b.capacity {
+		n = b.capacity
+	}
+	if b.capacity <= 0 || (len(b.waiters) == 0 && b.used+n <= b.capacity) {
+		b.add(r.held)
+		b.mtx.Unlock()
+		return r, nil
+	}
+	w := &waiter{n: n, ready: make(chan struct{})}
+	b.waiters = append(b.waiters, w)
+	timeout := b.timeout
+	b.mtx.Unlock()
+
+	var tc <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		tc = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		// the waiter was granted its clamped amount, so the remainder is added here
+		b.mtx.Lock()
+		b.add(r.held - w.n)
+		b.mtx.Unlock()
+		return r, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-tc:
+		err = ErrUnavailable
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	select {
+	case <-w.ready:
+		// the waiter was granted concurrently with the timeout, so the grant is returned
+		b.add(-w.n)
+		b.grant()
+	default:
+		b.remove(w)
+	}
+	return nil, err
+}
+
+// add adjusts the bytes in use and reports them. The caller must hold the lock
+func (b *Budget) add(n int64) {
+	b.used += n
+	if b.used < 0 {
+		b.used = 0
+	}
+	if b.used > b.peak {
+		b.peak = b.used
+		metrics.OrDefault(b.metrics).ProxyInflightProcessingPeakBytes.Set(float64(b.peak))
+	}
+	metrics.OrDefault(b.metrics).ProxyInflightProcessingBytes.Set(float64(b.used))
+}
+
+// grant grants waiting reservations, in order, while the capacity allows. The caller must hold the lock
+func (b *Budget) grant() {
+	for len(b.waiters) > 0 {
+		w := b.waiters[0]
+		if b.capacity > 0 && b.used+w.n > b.capacity {
+			return
+		}
+		b.waiters = b.waiters[1:]
+		b.add(w.n)
+		close(w.ready)
+	}
+}
+
+// remove removes a waiter that is no longer waiting, and grants the waiters behind it,
+// which may now fit. The caller must hold the lock
+func (b *Budget) remove(w *waiter) {
+	for i := range b.waiters {
+		if b.waiters[i] == w {
+			b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+			break
+		}
+	}
+	b.grant()
+}
+
+// observeSize refines the typical document size. The caller must hold the lock
+func (b *Budget) observeSize(size int64) {
+	b.typical += (float64(size) - b.typical) * smoothing
+}
+
+// observeRatio refines the ratio of the bytes held while processing documents to their
+// sizes. The caller must hold the lock
+func (b *Budget) observeRatio(size, actual int64) {
+	if size <= 0 || actual <= 0 {
+		return
+	}
+	r := float64(actual) / float64(size)
+	if r < 1 {
+		r = 1
+	} else if r > maxRatio {
+		r = maxRatio
+	}
+	b.ratio += (r - b.ratio) * smoothing
+}
+
+// Reservation is the capacity reserved from a Budget by a single request
+type Reservation struct {
+	b *Budget
+	// held is the number of bytes reserved
+	held int64
+	// guess is the portion of held that was estimated for a document of unknown size
+	guess int64
+	// size is the total size of the documents processed by the request
+	size int64
+	// actual is the total bytes observed to be held by the request
+	actual   int64
+	released bool
+}
+
+// Add reserves capacity for an additional document of size bytes processed by the request.
+// It does not wait, since the request already holds capacity, and waiting for more could
+// deadlock with other requests doing the same. The first known size replaces an estimate
+// that was made for a document of unknown size
+func (r *Reservation) Add(size int64) {
+	if r == nil || size < 0 {
+		return
+	}
+	b := r.b
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if r.released {
+		return
+	}
+	n := b.estimate(size) - r.guess
+	r.guess = 0
+	r.held += n
+	r.size += size
+	b.observeSize(size)
+	b.add(n)
+	if n < 0 {
+		b.grant()
+	}
+}
+
+// Observe records bytes that the request is known to hold, such as a buffered upstream body, an
+// unmarshaled document, or the marshaled response, from which the Budget's estimates are refined.
+// When the observed bytes exceed the reservation, the reservation is grown to match them
+func (r *Reservation) Observe(n int64) {
+	if r == nil || n <= 0 {
+		return
+	}
+	b := r.b
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if r.released {
+		return
+	}
+	r.actual += n
+	if r.actual > r.held {
+		b.add(r.actual - r.held)
+		r.held = r.actual
+	}
+}
+
+// Release returns the reservation's capacity to the Budget, once the request's buffers are
+// freed, and refines the Budget's estimates from the sizes that were observed
+func (r *Reservation) Release() {
+	if r == nil {
+		return
+	}
+	b := r.b
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if r.released {
+		return
+	}
+	r.released = true
+	b.observeRatio(r.size, r.actual)
+	b.add(-r.held)
+	b.grant()
+}
+
+// Held returns the number of bytes currently reserved
+func (r *Reservation) Held() int64 {
+	if r == nil {
+		return 0
+	}
+	r.b.mtx.Lock()
+	defer r.b.mtx.Unlock()
+	if r.released {
+		return 0
+	}
+	return r.held
+}
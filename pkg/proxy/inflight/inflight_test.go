@@ -0,0 +1,176 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package inflight
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+
+	b := NewBudget(1000, 20*time.Millisecond, nil)
+
+	r1, err := b.Acquire(context.Background(), 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r1.Held() != 600 || b.Used() != 600 {
+		t.Errorf("expected %d got %d/%d", 600, r1.Held(), b.Used())
+	}
+
+	// the second reservation does not fit, so it times out
+	if _, err = b.Acquire(context.Background(), 200); err != ErrUnavailable {
+		t.Errorf("expected %v got %v", ErrUnavailable, err)
+	}
+	if b.Used() != 600 {
+		t.Errorf("expected %d got %d", 600, b.Used())
+	}
+
+	// a waiting reservation is granted once capacity is released
+	ch := make(chan *Reservation)
+	go func() {
+		r, _ := b.Acquire(context.Background(), 200)
+		ch <- r
+	}()
+	time.Sleep(5 * time.Millisecond)
+	r1.Release()
+	r2 := <-ch
+	if r2 == nil {
+		t.Fatal("expected a reservation")
+	}
+	if b.Peak() != 600 {
+		t.Errorf("expected %d got %d", 600, b.Peak())
+	}
+
+	// releasing is idempotent
+	r1.Release()
+	r2.Release()
+	r2.Release()
+	if b.Used() != 0 {
+		t.Errorf("expected %d got %d", 0, b.Used())
+	}
+
+	// a reservation larger than the capacity waits for the whole capacity
+	r3, err := b.Acquire(context.Background(), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Used() != 3000 || b.Peak() != 3000 {
+		t.Errorf("expected %d got %d/%d", 3000, b.Used(), b.Peak())
+	}
+	r3.Release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r4, _ := b.Acquire(context.Background(), 400)
+	if _, err = b.Acquire(ctx, 1); err != context.Canceled {
+		t.Errorf("expected %v got %v", context.Canceled, err)
+	}
+	r4.Release()
+}
+
+func TestAcquireUnlimited(t *testing.T) {
+	b := NewBudget(0, 0, nil)
+	for i := 0; i < 10; i++ {
+		if _, err := b.Acquire(context.Background(), 1<<30); err != nil {
+			t.Error(err)
+		}
+	}
+	if b.Used() != 10*3<<30 {
+		t.Errorf("expected %d got %d", 10*3<<30, b.Used())
+	}
+}
+
+func TestReservationRefinement(t *testing.T) {
+
+	b := NewBudget(0, 0, nil)
+
+	// a document of unknown size is estimated from the typical size
+	r, _ := b.Acquire(context.Background(), -1)
+	if r.Held() != 3*defaultTypicalBytes {
+		t.Errorf("expected %d got %d", 3*defaultTypicalBytes, r.Held())
+	}
+
+	// the first known size replaces the estimate, and later sizes are added to it
+	r.Add(1000)
+	if r.Held() != 3000 {
+		t.Errorf("expected %d got %d", 3000, r.Held())
+	}
+	r.Add(-1)
+	r.Add(1000)
+	if r.Held() != 6000 || b.Used() != 6000 {
+		t.Errorf("expected %d got %d/%d", 6000, r.Held(), b.Used())
+	}
+
+	// observed bytes beyond the reservation grow it
+	r.Observe(10000)
+	if r.Held() != 10000 {
+		t.Errorf("expected %d got %d", 10000, r.Held())
+	}
+
+	// the ratio is refined toward the observed 5x on release
+	r.Release()
+	if r.Held() != 0 || b.Used() != 0 {
+		t.Errorf("expected %d got %d/%d", 0, r.Held(), b.Used())
+	}
+	if e := b.Estimate(1000); e != 3400 {
+		t.Errorf("expected %d got %d", 3400, e)
+	}
+
+	// the typical size is refined from the observed sizes
+	if e := b.Estimate(-1); e >= 3*defaultTypicalBytes {
+		t.Errorf("expected less than %d got %d", 3*defaultTypicalBytes, e)
+	}
+
+	// a nil reservation is a no-op
+	var nr *Reservation
+	nr.Add(1)
+	nr.Observe(1)
+	nr.Release()
+	if nr.Held() != 0 {
+		t.Errorf("expected %d got %d", 0, nr.Held())
+	}
+}
+
+func TestConfigure(t *testing.T) {
+
+	b := NewBudget(0, 0, nil)
+	b.Configure(100, time.Second)
+
+	if b.Capacity() != 100 {
+		t.Errorf("expected %d got %d", 100, b.Capacity())
+	}
+
+	r1, _ := b.Acquire(context.Background(), 30)
+	ch := make(chan *Reservation)
+	go func() {
+		r, _ := b.Acquire(context.Background(), 30)
+		ch <- r
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	// growing the capacity grants the waiting reservation
+	b.Configure(200, time.Second)
+	r2 := <-ch
+	if r2 == nil {
+		t.Fatal("expected a reservation")
+	}
+	r1.Release()
+	r2.Release()
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package instance holds the runtime state of a Trickster router that is not part of its
+// configuration, such as its metrics and the state of its origins, so that the routers of
+// applications that embed Trickster do not share their state with one another
+package instance
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/events"
+	"github.com/tricksterproxy/trickster/pkg/config"
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/proxy/clockskew"
+	"github.com/tricksterproxy/trickster/pkg/proxy/fingerprint"
+	"github.com/tricksterproxy/trickster/pkg/proxy/healthcheck"
+	"github.com/tricksterproxy/trickster/pkg/proxy/inflight"
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight"
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
+	"github.com/tricksterproxy/trickster/pkg/proxy/ratelimit"
+	"github.com/tricksterproxy/trickster/pkg/proxy/refresh"
+	"github.com/tricksterproxy/trickster/pkg/proxy/sampling"
+	"github.com/tricksterproxy/trickster/pkg/proxy/upstream"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// Instance is the runtime state of a router, which is shared by each config that the router
+// serves over its lifetime, and is passed to its request handlers in the request Resources
+type Instance struct {
+	// Metrics are the router's metrics
+	Metrics *metrics.Metrics
+	// Health holds the running background health check probers of the router's origins
+	Health *healthcheck.Registry
+	// Limiters holds the upstream concurrency limiters of the router's origins
+	Limiters *priority.Registry
+	// RateLimits holds the upstream rate limit estimates of the router's origins
+	RateLimits *ratelimit.Registry
+	// Upstreams holds the upstream request state of the router's origins
+	Upstreams *upstream.Registry
+	// ClockSkews holds the clock skew estimates of the router's origins
+	ClockSkews *clockskew.Registry
+	// TopQueries tracks the most requested query fingerprints of the router's origins
+	TopQueries *fingerprint.Tracker
+	// Inflight is the budget of the bytes held by the router's requests while their
+	// responses are processed
+	Inflight *inflight.Budget
+	// Sampler samples the router's frontend requests
+	Sampler *sampling.Sampler
+	// Events publishes the events of the router's caches
+	Events *events.Publisher
+	// Readiness holds the results of the pre-flight checks of the router's configs
+	Readiness *preflight.Readiness
+
+	scheduler *refresh.Scheduler
+	mtx       sync.Mutex
+}
+
+// New returns a new Instance, with new, unregistered Metrics
+func New() *Instance {
+	m := metrics.New()
+	return newInstance(m, events.NewPublisher(m))
+}
+
+func newInstance(m *metrics.Metrics, p *events.Publisher) *Instance {
+	return &Instance{
+		Metrics:    m,
+		Health:     healthcheck.NewRegistry(m),
+		Limiters:   priority.NewRegistry(m),
+		RateLimits: ratelimit.NewRegistry(m),
+		Upstreams:  upstream.NewRegistry(),
+		ClockSkews: clockskew.NewRegistry(m),
+		TopQueries: fingerprint.NewTracker(d.DefaultTopQueriesSize),
+		Inflight:   inflight.NewBudget(0, 0, m),
+		Sampler:    sampling.NewSampler(),
+		Events:     p,
+		Readiness:  preflight.NewReadiness(),
+	}
+}
+
+// Default is the Instance of the router of the Trickster binary, whose Metrics and cache event
+// Publisher are the Default Metrics and Publisher
+var Default = newInstance(metrics.Default, events.Default)
+
+// OrDefault returns the Instance, or the Default Instance when it is nil
+func OrDefault(i *Instance) *Instance {
+	if i == nil {
+		return Default
+	}
+	return i
+}
+
+// Attach sets the Metrics and cache event Publisher of the config's caches to those of the
+// Instance. It is called before the caches are created
+func (i *Instance) Attach(conf *config.Config) {
+	for _, cc := range conf.Caches {
+		cc.Metrics = i.Metrics
+		cc.Events = i.Events
+	}
+}
+
+// Configure applies the settings of the config to the Instance: the top queries tracker, the
+// in-flight processing limit, request sampling and cache events. It is called once the config's
+// routes are registered and are ready to serve
+func (i *Instance) Configure(conf *config.Config, log *tl.Logger) {
+	i.TopQueries.Resize(conf.Main.TopQueriesSize)
+	i.Inflight.Configure(conf.Main.MaxInflightProcessingBytes,
+		time.Duration(conf.Main.InflightProcessingTimeoutMS)*time.Millisecond)
+	i.Sampler.Configure(conf.Main.RequestSamplingEnabled, conf.Main.RequestSamplingSize)
+	err := i.Events.Configure(conf.Main.CacheEventsEnabled, &events.Options{
+		Size:          conf.Main.CacheEventsSize,
+		Sink:          conf.Main.CacheEventSink,
+		LogFile:       conf.Main.CacheEventLog,
+		LogMaxSizeMB:  conf.Logging.LogMaxSizeMB,
+		LogMaxBackups: conf.Logging.LogMaxBackups,
+		LogMaxAgeDays: conf.Logging.LogMaxAgeDays,
+		LogCompress:   conf.Logging.LogCompress,
+	})
+	if err != nil {
+		log.Warn("unable to configure cache events", tl.Pairs{"detail": err.Error()})
+	}
+}
+
+// SetScheduler sets the Scheduler of the refresh jobs of the running config, whose Jobs are
+// served by the Refresh Jobs API, such as when the config is (re)loaded. It does not start or
+// stop any Jobs
+func (i *Instance) SetScheduler(s *refresh.Scheduler) {
+	i.mtx.Lock()
+	i.scheduler = s
+	i.mtx.Unlock()
+}
+
+// Scheduler returns the Scheduler of the running config, or nil if there is none
+func (i *Instance) Scheduler() *refresh.Scheduler {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	return i.scheduler
+}
+
+// Close releases the resources of the Instance that outlive its router's configs, which is
+// the stream of its cache event Publisher
+func (i *Instance) Close() {
+	i.Events.Close()
+}
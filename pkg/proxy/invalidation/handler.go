@@ -0,0 +1,180 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package invalidation provides a handler that invalidates time ranges of cached timeseries
+// documents on request, so that data that changed at the origin, such as by a backfill, is
+// fetched again before the documents expire
+package invalidation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/handlers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+// maxRequestBodyBytes is the maximum size of the body of a request to the Invalidate Handler
+const maxRequestBodyBytes = 1 << 16
+
+// UnknownSource is the source of requests that do not name one
+const UnknownSource = "unknown"
+
+// responses are retained by their idempotency keys across config reloads, and are guarded by mtx
+var (
+	mtx   sync.Mutex
+	store = newIdempotencyStore()
+)
+
+// Request is the body of a request to the Invalidate Handler
+type Request struct {
+	// Origin is the name of the origin whose cached documents are invalidated
+	Origin string `json:"origin"`
+	// Metric is a metric name, a glob pattern of metric names, or a series selector, limiting the
+	// invalidation to the documents of queries that may reference it. When empty, all of the
+	// origin's cached timeseries documents are invalidated
+	Metric string `json:"metric,omitempty"`
+	// Start is the epoch time in seconds of the start of the invalidated time range
+	Start int64 `json:"start"`
+	// End is the epoch time in seconds of the end of the invalidated time range
+	End int64 `json:"end"`
+	// Source identifies the system that requested the invalidation, such as an ingestion pipeline
+	Source string `json:"source,omitempty"`
+}
+
+// Response is the body of the response to a request to the Invalidate Handler
+type Response struct {
+	Origin string `json:"origin"`
+	Metric string `json:"metric,omitempty"`
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Source string `json:"source"`
+	*engines.InvalidationResult
+}
+
+// HandleFunc returns a handler that invalidates the time range of the cached timeseries documents
+// described by a POSTed Request, and responds with the counts of the affected documents. Requests
+// must be signed with the reload config's hmac_secret, and retries of a request having the same
+// Idempotency-Key header are answered with the response to the original request. The documents
+// are those cached by the Instance, or by the Default Instance when it is nil
+func HandleFunc(conf *config.Config, inst *instance.Instance) func(http.ResponseWriter, *http.Request) {
+	inst = instance.OrDefault(inst)
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost {
+			handlers.HandleMethodNotAllowedResponse(w, r)
+			return
+		}
+
+		badRequest := func(msg string) {
+			txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest, msg).Respond(w, r)
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+		if err != nil {
+			badRequest("invalid request body: " + err.Error())
+			return
+		}
+
+		now := time.Now()
+		if err = handlers.VerifySignature(conf.ReloadConfig.HMACSecret, r, body, now); err != nil {
+			txe.NewResponseError(http.StatusUnauthorized, txe.CodeUnauthorized, err.Error()).Respond(w, r)
+			return
+		}
+
+		// requests are applied one at a time, so that concurrent retries are applied only once
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		key := r.Header.Get(headers.NameIdempotencyKey)
+		bodyHash := md5.Checksum(string(body))
+		if key != "" {
+			if ir, ok := store.get(key, now); ok {
+				if ir.bodyHash != bodyHash {
+					txe.NewResponseError(http.StatusConflict, txe.CodeIdempotencyKeyReused,
+						"idempotency key was used for a different request").Respond(w, r)
+					return
+				}
+				w.Header().Set(headers.NameIdempotentReplayed, "true")
+				respond(w, ir.body)
+				return
+			}
+		}
+
+		req := &Request{}
+		if err = json.Unmarshal(body, req); err != nil {
+			badRequest("invalid request body: " + err.Error())
+			return
+		}
+		oc, ok := conf.Origins[req.Origin]
+		if !ok {
+			badRequest("unknown origin: " + req.Origin)
+			return
+		}
+		if req.Start <= 0 || req.End < req.Start {
+			badRequest("invalid time range: " + strconv.FormatInt(req.Start, 10) + "-" +
+				strconv.FormatInt(req.End, 10))
+			return
+		}
+		match, err := NewMatcher(req.Metric)
+		if err != nil {
+			badRequest("invalid metric " + req.Metric + ": " + err.Error())
+			return
+		}
+		if req.Source == "" {
+			req.Source = UnknownSource
+		}
+
+		result, err := engines.InvalidateTimeseries(inst, req.Origin, match,
+			timeseries.Extent{Start: time.Unix(req.Start, 0), End: time.Unix(req.End, 0)})
+		if result != nil {
+			inst.Metrics.ProxyCacheInvalidations.WithLabelValues(oc.Name, oc.OriginType, req.Source,
+				"removed").Add(float64(result.Removed))
+			inst.Metrics.ProxyCacheInvalidations.WithLabelValues(oc.Name, oc.OriginType, req.Source,
+				"truncated").Add(float64(result.Truncated))
+		}
+		if err != nil {
+			// the request is not retained by its key, so that it can be retried
+			txe.NewResponseError(http.StatusInternalServerError, txe.CodeInternal,
+				"invalidation failed: "+err.Error()).Respond(w, r)
+			return
+		}
+
+		b, _ := json.Marshal(&Response{Origin: req.Origin, Metric: req.Metric, Start: req.Start,
+			End: req.End, Source: req.Source, InvalidationResult: result})
+		if key != "" {
+			store.set(key, bodyHash, b, now)
+		}
+		respond(w, b)
+	}
+}
+
+func respond(w http.ResponseWriter, body []byte) {
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
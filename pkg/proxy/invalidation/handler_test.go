@@ -0,0 +1,158 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invalidation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/handlers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+const testConfig = `
+[reloading]
+hmac_secret = 'secret'
+
+[origins]
+  [origins.prom1]
+  origin_type = 'prometheus'
+  origin_url = 'http://127.0.0.1:9090'
+`
+
+func testRequest(secret, key string, req *Request) *http.Request {
+	b, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/trickster/invalidate", strings.NewReader(string(b)))
+	if secret != "" {
+		now := time.Now().Unix()
+		r.Header.Set(headers.NameTricksterTimestamp, strconv.FormatInt(now, 10))
+		r.Header.Set(headers.NameTricksterSignature, handlers.Sign(secret, now, b))
+	}
+	if key != "" {
+		r.Header.Set(headers.NameIdempotencyKey, key)
+	}
+	return r
+}
+
+func TestHandleFunc(t *testing.T) {
+
+	conf, err := config.LoadDocument(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := HandleFunc(conf, nil)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/trickster/invalidate", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	valid := &Request{Origin: "prom1", Metric: "up", Start: 1600000000, End: 1600003600,
+		Source: "backfill"}
+
+	tests := []struct {
+		secret string
+		req    *Request
+		code   int
+	}{
+		{"secret", valid, http.StatusOK},
+		{"", valid, http.StatusUnauthorized},
+		{"other", valid, http.StatusUnauthorized},
+		{"secret", &Request{Origin: "prom2", Start: 1600000000, End: 1600003600}, http.StatusBadRequest},
+		{"secret", &Request{Origin: "prom1", Start: 1600003600, End: 1600000000}, http.StatusBadRequest},
+		{"secret", &Request{Origin: "prom1", Metric: `{job="api"}`, Start: 1600000000,
+			End: 1600003600}, http.StatusBadRequest},
+	}
+
+	for i, test := range tests {
+		w = httptest.NewRecorder()
+		h(w, testRequest(test.secret, "", test.req))
+		if w.Code != test.code {
+			t.Errorf("(%d) expected %d got %d: %s", i, test.code, w.Code, w.Body.String())
+		}
+	}
+
+	w = httptest.NewRecorder()
+	h(w, testRequest("secret", "", valid))
+	resp := &Response{}
+	if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Origin != "prom1" || resp.Source != "backfill" || resp.InvalidationResult == nil {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+
+	// a retry with the same idempotency key is answered with the original response
+	w = httptest.NewRecorder()
+	h(w, testRequest("secret", "test-key", valid))
+	if w.Code != http.StatusOK || w.Header().Get(headers.NameIdempotentReplayed) != "" {
+		t.Errorf("unexpected response %d %v", w.Code, w.Header())
+	}
+	original := w.Body.String()
+	w = httptest.NewRecorder()
+	h(w, testRequest("secret", "test-key", valid))
+	if w.Code != http.StatusOK || w.Header().Get(headers.NameIdempotentReplayed) != "true" ||
+		w.Body.String() != original {
+		t.Errorf("unexpected response %d %v %s", w.Code, w.Header(), w.Body.String())
+	}
+
+	// the key cannot be reused for another request
+	w = httptest.NewRecorder()
+	h(w, testRequest("secret", "test-key", &Request{Origin: "prom1", Start: 1600000000,
+		End: 1600007200}))
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected %d got %d", http.StatusConflict, w.Code)
+	}
+
+	// requests are rejected when no secret is configured
+	conf.ReloadConfig.HMACSecret = ""
+	w = httptest.NewRecorder()
+	h(w, testRequest("secret", "", valid))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestIdempotencyStore(t *testing.T) {
+
+	s := newIdempotencyStore()
+	now := time.Unix(1600000000, 0)
+	s.set("a", "hash", []byte("body"), now)
+	if ir, ok := s.get("a", now.Add(IdempotencyTTL-time.Second)); !ok || string(ir.body) != "body" {
+		t.Error("expected retained response")
+	}
+	if _, ok := s.get("a", now.Add(IdempotencyTTL)); ok {
+		t.Error("expected expired response")
+	}
+
+	for i := 0; i <= maxIdempotencyKeys; i++ {
+		s.set(strconv.Itoa(i), "hash", nil, now)
+	}
+	if _, ok := s.get("0", now); ok {
+		t.Error("expected the oldest key to be forgotten")
+	}
+	if len(s.entries) != maxIdempotencyKeys || s.order.Len() != maxIdempotencyKeys {
+		t.Errorf("expected %d got %d", maxIdempotencyKeys, len(s.entries))
+	}
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invalidation
+
+import (
+	"container/list"
+	"time"
+)
+
+// IdempotencyTTL is the time for which the response to a request is retained by its idempotency
+// key, during which retries of the request are answered with it rather than applied again
+const IdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeys is the maximum number of idempotency keys retained, beyond which the oldest
+// key is forgotten before its IdempotencyTTL elapses
+const maxIdempotencyKeys = 10000
+
+// idempotentResponse is the response to a request, retained by its idempotency key
+type idempotentResponse struct {
+	key      string
+	bodyHash string
+	body     []byte
+	expires  time.Time
+}
+
+// idempotencyStore retains the responses to requests by their idempotency keys, in the order in
+// which they were stored. It is not safe for concurrent use
+type idempotencyStore struct {
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get returns the unexpired response retained for the key, if any
+func (s *idempotencyStore) get(key string, now time.Time) (*idempotentResponse, bool) {
+	s.expire(now)
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*idempotentResponse), true
+}
+
+// set retains the response to the request having the body hash, by its key
+func (s *idempotencyStore) set(key, bodyHash string, body []byte, now time.Time) {
+	if e, ok := s.entries[key]; ok {
+		s.order.Remove(e)
+	}
+	s.entries[key] = s.order.PushBack(&idempotentResponse{key: key, bodyHash: bodyHash,
+		body: body, expires: now.Add(IdempotencyTTL)})
+	for s.order.Len() > maxIdempotencyKeys {
+		s.remove(s.order.Front())
+	}
+}
+
+// expire forgets the responses whose IdempotencyTTL has elapsed
+func (s *idempotencyStore) expire(now time.Time) {
+	for e := s.order.Front(); e != nil && !now.Before(e.Value.(*idempotentResponse).expires); e = s.order.Front() {
+		s.remove(e)
+	}
+}
+
+func (s *idempotencyStore) remove(e *list.Element) {
+	s.order.Remove(e)
+	delete(s.entries, e.Value.(*idempotentResponse).key)
+}
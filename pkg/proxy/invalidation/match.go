@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invalidation
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/fingerprint"
+)
+
+// ErrNoMetricName is returned when the metric name of a series selector cannot be determined
+var ErrNoMetricName = errors.New("unable to determine the metric name of the selector")
+
+// querySyntax tokenizes the queries of all origin types, by recognizing each of their quotes
+var querySyntax = fingerprint.Syntax{Quotes: "'\"`"}
+
+// nameLabel matches the __name__ label matcher of a series selector
+var nameLabel = regexp.MustCompile(`__name__\s*=\s*["'` + "`" + `]([^"'` + "`" + `]+)["'` + "`" + `]`)
+
+// metricName returns the metric name, or metric name pattern, of the metric, which may be a
+// series selector of the form name{label="value"} or {__name__="name"}
+func metricName(metric string) (string, error) {
+	metric = strings.TrimSpace(metric)
+	i := strings.IndexByte(metric, '{')
+	if i < 0 {
+		return metric, nil
+	}
+	if name := strings.TrimSpace(metric[:i]); name != "" {
+		return name, nil
+	}
+	if m := nameLabel.FindStringSubmatch(metric[i:]); m != nil {
+		return m[1], nil
+	}
+	return "", ErrNoMetricName
+}
+
+// NewMatcher returns a function that conservatively matches the queries that may reference the
+// metric, which is a metric name, a glob pattern of metric names, or a series selector. The
+// selector's label matchers are disregarded, so every query for the selector's metric matches.
+// A metric name matches any query containing it, and a pattern matches any query having an
+// identifier or quoted literal that it matches. An empty metric matches every query, and is
+// represented by a nil function
+func NewMatcher(metric string) (func(query string) bool, error) {
+	if strings.TrimSpace(metric) == "" {
+		return nil, nil
+	}
+	name, err := metricName(metric)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = path.Match(name, ""); err != nil {
+		return nil, err
+	}
+	if !strings.ContainsAny(name, `*?[\`) {
+		return func(query string) bool { return strings.Contains(query, name) }, nil
+	}
+	return func(query string) bool {
+		for _, t := range fingerprint.Tokenize(query, querySyntax) {
+			v := t.Value
+			switch t.Type {
+			case fingerprint.TokenWord:
+			case fingerprint.TokenString:
+				v = strings.TrimSuffix(v[1:], v[:1])
+			default:
+				continue
+			}
+			if ok, _ := path.Match(name, v); ok {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
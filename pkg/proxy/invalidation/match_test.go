@@ -0,0 +1,57 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invalidation
+
+import "testing"
+
+func TestNewMatcher(t *testing.T) {
+
+	tests := []struct {
+		metric  string
+		query   string
+		matches bool
+	}{
+		{"", "up", true},
+		{"http_requests_total", "sum(rate(http_requests_total[5m]))", true},
+		// a metric name matches conservatively, including as part of another name
+		{"http_requests", "sum(rate(http_requests_total[5m]))", true},
+		{"node_load1", "sum(rate(http_requests_total[5m]))", false},
+		{`http_requests_total{job="api"}`, `rate(http_requests_total{job="web"}[5m])`, true},
+		{`{__name__="http_requests_total"}`, `rate(http_requests_total[5m])`, true},
+		{"http_*_total", "sum(rate(http_requests_total[5m]))", true},
+		{"http_*_total", "sum(rate(http_requests_count[5m]))", false},
+		{"cpu*", `SELECT mean("usage_idle") FROM "telegraf"."autogen"."cpu" WHERE time > now() - 1h`, true},
+		{"mem*", `SELECT mean("usage_idle") FROM "telegraf"."autogen"."cpu" WHERE time > now() - 1h`, false},
+	}
+
+	for i, test := range tests {
+		match, err := NewMatcher(test.metric)
+		if err != nil {
+			t.Errorf("(%d) unexpected error %v", i, err)
+			continue
+		}
+		if matches := match == nil || match(test.query); matches != test.matches {
+			t.Errorf("(%d) expected %t got %t", i, test.matches, matches)
+		}
+	}
+
+	for _, metric := range []string{`{job="api"}`, "http_[requests"} {
+		if _, err := NewMatcher(metric); err == nil {
+			t.Errorf("expected error for %s", metric)
+		}
+	}
+}
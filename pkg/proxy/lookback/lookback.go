@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package lookback enumerates how a timeseries request that queries further back than its
+// permitted lookback is handled, and identifies the tenant whose lookback applies to a request
+package lookback
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// Action enumerates the ways that a timeseries request querying further back than its permitted
+// lookback is handled
+type Action int
+
+const (
+	// ActionClamp indicates that the start of the request's time range is moved forward to the oldest
+	// permitted time, and the response is annotated with the clamped range
+	ActionClamp = Action(iota)
+	// ActionReject indicates that the request is rejected with a 403 Forbidden
+	ActionReject
+)
+
+// Names is a map of Actions keyed by string name
+var Names = map[string]Action{
+	"clamp":  ActionClamp,
+	"reject": ActionReject,
+}
+
+// Values is a map of Actions valued by string name
+var Values = make(map[Action]string)
+
+func init() {
+	for k, v := range Names {
+		Values[v] = k
+	}
+}
+
+func (a Action) String() string {
+	if v, ok := Values[a]; ok {
+		return v
+	}
+	return strconv.Itoa(int(a))
+}
+
+// Tenant returns the tenant of the request, from the named request header when header is not empty,
+// and otherwise from the named claim of the request's bearer JSON Web Token. The token's signature is
+// not verified, so its claims must be verified before the request reaches Trickster. An empty string is
+// returned when the request does not identify a tenant
+func Tenant(r *http.Request, header, claim string) string {
+	if header != "" {
+		return r.Header.Get(header)
+	}
+	if claim == "" {
+		return ""
+	}
+	auth := r.Header.Get(headers.NameAuthorization)
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return ""
+	}
+	parts := strings.Split(strings.TrimSpace(auth[7:]), ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return ""
+	}
+	switch v := claims[claim].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lookback
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+)
+
+func TestActionString(t *testing.T) {
+	if ActionClamp.String() != "clamp" {
+		t.Errorf("expected %s got %s", "clamp", ActionClamp.String())
+	}
+	if ActionReject.String() != "reject" {
+		t.Errorf("expected %s got %s", "reject", ActionReject.String())
+	}
+	if Action(5).String() != "5" {
+		t.Errorf("expected %s got %s", "5", Action(5).String())
+	}
+}
+
+func testToken(payload string) string {
+	return "Bearer e30." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".sig"
+}
+
+func TestTenant(t *testing.T) {
+
+	tests := []struct {
+		auth, tenantHeader, header, claim, expected string
+	}{
+		{"", "tenant-a", "X-Tenant", "", "tenant-a"},
+		// the header takes precedence over the claim
+		{testToken(`{"org":"tenant-b"}`), "tenant-a", "X-Tenant", "org", "tenant-a"},
+		{testToken(`{"org":"tenant-b"}`), "", "", "org", "tenant-b"},
+		{testToken(`{"org":42}`), "", "", "org", "42"},
+		{testToken(`{"org":true}`), "", "", "org", "true"},
+		{testToken(`{"org":["a"]}`), "", "", "org", ""},
+		{testToken(`{"sub":"x"}`), "", "", "org", ""},
+		{testToken(`not json`), "", "", "org", ""},
+		{"Bearer e30.!!!.sig", "", "", "org", ""},
+		{"Bearer abc", "", "", "org", ""},
+		{"Basic dXNlcjpwYXNz", "", "", "org", ""},
+		{testToken(`{"org":"tenant-b"}`), "", "", "", ""},
+	}
+
+	for i, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+		if test.auth != "" {
+			r.Header.Set("Authorization", test.auth)
+		}
+		if test.tenantHeader != "" {
+			r.Header.Set("X-Tenant", test.tenantHeader)
+		}
+		if v := Tenant(r, test.header, test.claim); v != test.expected {
+			t.Errorf("(%d) expected %s got %s", i, test.expected, v)
+		}
+	}
+}
@@ -18,18 +18,20 @@
 package clickhouse
 
 import (
-	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"net/http"
 	"net/url"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/proxy"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 )
 
 var _ origins.Client = (*Client)(nil)
@@ -43,9 +45,6 @@ type Client struct {
 	handlers           map[string]http.Handler
 	handlersRegistered bool
 	baseUpstreamURL    *url.URL
-	healthURL          *url.URL
-	healthMethod       string
-	healthHeaders      http.Header
 	router             http.Handler
 }
 
@@ -60,6 +59,16 @@ func NewClient(name string, oc *oo.Options, router http.Handler,
 		baseUpstreamURL: bur, webClient: c}, err
 }
 
+func init() {
+	origins.MustRegisterProvider("clickhouse", newProviderClient)
+}
+
+// newProviderClient is the origins.ProviderFunc for the clickhouse origin type
+func newProviderClient(name string, oc *oo.Options, router http.Handler, c cache.Cache,
+	_ origins.Origins, _ *tl.Logger) (origins.Client, error) {
+	return NewClient(name, oc, router, c)
+}
+
 // Configuration returns the upstream Configuration for this Client
 func (c *Client) Configuration() *oo.Options {
 	return c.config
@@ -90,6 +99,19 @@ func (c *Client) Router() http.Handler {
 	return c.router
 }
 
+// ImportantParams returns the query parameters that are semantically important to ClickHouse.
+// ClickHouse reads parameters from a Poco HTMLForm, whose get() honors the first occurrence
+func (c *Client) ImportantParams() ([]string, bool) {
+	return []string{upQuery, "database"}, false
+}
+
+// DefaultAuthCachePolicy returns the policy for caching responses to authenticated requests when a
+// path does not configure one. ClickHouse row policies and grants can give each user different
+// rows for the same query, so results are cached per identity
+func (c *Client) DefaultAuthCachePolicy() authcache.Policy {
+	return authcache.PolicyPerIdentity
+}
+
 // ParseTimeRangeQuery parses the key parts of a TimeRangeQuery from the inbound HTTP Request
 func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
 	qi := r.URL.Query()
@@ -101,11 +123,13 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 	}
 
 	var bf time.Duration
+	var loc *time.Location
 	res := request.GetResources(r)
 	if res == nil {
 		bf = 60 * time.Second
 	} else {
 		bf = res.OriginConfig.BackfillTolerance
+		loc = res.OriginConfig.TimeZone
 	}
 
 	// Force gzip compression since Brotli is broken on CH 20.3
@@ -113,11 +137,18 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 	// Clients that don't understand gzip are going to break, but oh well
 	r.Header.Set("Accept-Encoding", "gzip")
 
-	trq := &timeseries.TimeRangeQuery{Extent: timeseries.Extent{}, BackfillTolerance: bf}
+	// time grouping functions align to the origin's time zone unless the query specifies one
+	trq := &timeseries.TimeRangeQuery{Extent: timeseries.Extent{}, BackfillTolerance: bf, Location: loc}
 	if err := parseRawQuery(rawQuery, trq); err != nil {
 		return nil, err
 	}
 
+	// results are fetched in the canonical JSON format and rendered in the requested format, so
+	// when the path caches the canonical format, queries differing only by format share a document
+	if res != nil && res.PathConfig != nil && res.PathConfig.CanonicalFormat {
+		trq.Statement = withFormat(trq.Statement, formatJSON)
+	}
+
 	trq.TemplateURL = urls.Clone(r.URL)
 	// Swap in the Tokenized Query in the Url Params
 	qi.Set(upQuery, trq.Statement)
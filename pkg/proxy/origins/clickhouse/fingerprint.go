@@ -0,0 +1,49 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import "github.com/tricksterproxy/trickster/pkg/proxy/fingerprint"
+
+// sqlSyntax describes the lexical features of ClickHouse SQL, whose single-quoted tokens
+// are string literals and double-quoted and backquoted tokens are identifiers
+var sqlSyntax = fingerprint.Syntax{Quotes: "'\"`", LineComment: "--", BlockComments: true}
+
+// sqlKeywords are the case-insensitive SQL keywords that are uppercased in normalized queries
+var sqlKeywords = map[string]bool{"select": true, "from": true, "where": true, "prewhere": true,
+	"and": true, "or": true, "not": true, "in": true, "between": true, "like": true, "is": true,
+	"null": true, "as": true, "group": true, "by": true, "order": true, "asc": true, "desc": true,
+	"limit": true, "offset": true, "having": true, "with": true, "join": true, "on": true,
+	"using": true, "union": true, "all": true, "distinct": true, "format": true, "final": true}
+
+// NormalizeQuery returns the SQL query with its whitespace, comments and keywords canonicalized,
+// and its numeric constants and literal time values replaced with a placeholder. When stripValues
+// is true, all string literals are also replaced
+func (c *Client) NormalizeQuery(query string, stripValues bool) string {
+	tokens := fingerprint.Tokenize(query, sqlSyntax)
+	for i, t := range tokens {
+		switch t.Type {
+		case fingerprint.TokenNumber:
+			tokens[i].Value = fingerprint.Placeholder
+		case fingerprint.TokenString:
+			if t.Value[0] == '\'' && (stripValues || fingerprint.IsTimeLiteral(t.Value)) {
+				tokens[i].Value = "'" + fingerprint.Placeholder + "'"
+			}
+		}
+	}
+	fingerprint.UpperKeywords(tokens, sqlKeywords)
+	return fingerprint.Join(tokens)
+}
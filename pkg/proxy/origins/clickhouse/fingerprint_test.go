@@ -0,0 +1,44 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import "testing"
+
+func TestNormalizeQuery(t *testing.T) {
+
+	tests := []struct {
+		query       string
+		stripValues bool
+		expected    string
+	}{
+		{"select intDiv(toUInt32(t), 60) * 60 as ts, count() from `db`.`events`\n" +
+			"where t >= toDateTime(1577836800) and host = 'web01' group by ts order by ts format JSON", false,
+			"SELECT intDiv(toUInt32(t),?)*? AS ts,count() FROM `db`.`events` " +
+				"WHERE t>=toDateTime(?) AND host='web01' GROUP BY ts ORDER BY ts FORMAT JSON"},
+		{"SELECT count() FROM events WHERE t >= '2020-01-01 00:00:00' AND host IN ('a', 'b')", false,
+			"SELECT count() FROM events WHERE t>='?' AND host IN('a','b')"},
+		{"SELECT count() FROM events /* panel 4 */ WHERE t >= 1577836800 AND host IN ('a', 'b')", true,
+			"SELECT count() FROM events WHERE t>=? AND host IN('?','?')"},
+	}
+
+	c := &Client{}
+	for i, test := range tests {
+		if v := c.NormalizeQuery(test.query, test.stripValues); v != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, v)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// This file renders the canonical JSON form of ClickHouse query results, in which they are
+// fetched from the origin and cached, in the other output formats that a query may request
+
+// The output formats that a time series query may request
+const (
+	formatJSON                  = "JSON"
+	formatCSV                   = "CSV"
+	formatCSVWithNames          = "CSVWithNames"
+	formatTabSeparated          = "TabSeparated"
+	formatTabSeparatedWithNames = "TabSeparatedWithNames"
+)
+
+// upCSVDelimiter is the URL parameter of the setting for the delimiter of CSV formats
+const upCSVDelimiter = "format_csv_delimiter"
+
+// outputFormat describes an output format in which query results are rendered
+type outputFormat struct {
+	name        string
+	contentType string
+	withNames   bool
+	csv         bool
+}
+
+// outputFormats are the supported output formats, by their upper-cased names and aliases,
+// which ClickHouse matches case-insensitively
+var outputFormats = map[string]*outputFormat{
+	"JSON": {name: formatJSON, contentType: "application/json; charset=UTF-8"},
+	"CSV":  {name: formatCSV, contentType: "text/csv; charset=UTF-8; header=absent", csv: true},
+	"CSVWITHNAMES": {name: formatCSVWithNames, contentType: "text/csv; charset=UTF-8; header=present",
+		csv: true, withNames: true},
+	"TABSEPARATED": {name: formatTabSeparated, contentType: "text/tab-separated-values; charset=UTF-8"},
+	"TSV":          {name: formatTabSeparated, contentType: "text/tab-separated-values; charset=UTF-8"},
+	"TABSEPARATEDWITHNAMES": {name: formatTabSeparatedWithNames,
+		contentType: "text/tab-separated-values; charset=UTF-8", withNames: true},
+	"TSVWITHNAMES": {name: formatTabSeparatedWithNames,
+		contentType: "text/tab-separated-values; charset=UTF-8", withNames: true},
+}
+
+// splitFormat splits the trailing FORMAT clause from a query, returning the query without the
+// clause and the requested output format. ok is false when the query does not end with a FORMAT
+// clause naming a supported output format
+func splitFormat(query string) (string, *outputFormat, bool) {
+	q := strings.TrimSpace(query)
+	i := strings.LastIndexAny(q, " \t\r\n")
+	if i < 0 {
+		return query, nil, false
+	}
+	f, ok := outputFormats[sup(q[i+1:])]
+	if !ok {
+		return query, nil, false
+	}
+	q = strings.TrimSpace(q[:i])
+	i = strings.LastIndexAny(q, " \t\r\n")
+	if i < 0 || sup(q[i+1:]) != "FORMAT" {
+		return query, nil, false
+	}
+	return strings.TrimSpace(q[:i]), f, true
+}
+
+// withFormat returns the query with its FORMAT clause replaced by one naming the output format
+func withFormat(query, format string) string {
+	q, _, ok := splitFormat(query)
+	if !ok {
+		return query
+	}
+	return q + " FORMAT " + format
+}
+
+// TimeseriesFormat returns the name of the output format requested by the query, or an empty
+// string when the query requests the canonical JSON format
+func (c *Client) TimeseriesFormat(r *http.Request) string {
+	if r == nil || r.URL == nil {
+		return ""
+	}
+	_, f, ok := splitFormat(r.URL.Query().Get(upQuery))
+	if !ok || f.name == formatJSON {
+		return ""
+	}
+	return f.name
+}
+
+// RenderTimeseries returns the timeseries rendered in the output format requested by the query,
+// honoring the request's format_csv_delimiter setting, and its Content-Type
+func (c *Client) RenderTimeseries(ts timeseries.Timeseries, r *http.Request) ([]byte, string, error) {
+	re, ok := ts.(*ResultsEnvelope)
+	if !ok {
+		return nil, "", fmt.Errorf("unexpected timeseries type %T", ts)
+	}
+	qp := r.URL.Query()
+	_, f, ok := splitFormat(qp.Get(upQuery))
+	if !ok || f.name == formatJSON {
+		b, err := c.MarshalTimeseries(re)
+		return b, outputFormats["JSON"].contentType, err
+	}
+	rsp, err := re.response()
+	if err != nil {
+		return nil, "", err
+	}
+	delimiter := byte('\t')
+	if f.csv {
+		delimiter = ','
+		if d := qp.Get(upCSVDelimiter); len(d) == 1 {
+			delimiter = d[0]
+		}
+	}
+	return f.render(rsp, delimiter), f.contentType, nil
+}
+
+// render returns the rows of the response in the output format, separating fields by the delimiter
+func (f *outputFormat) render(rsp *Response, delimiter byte) []byte {
+	buf := &bytes.Buffer{}
+	if f.withNames {
+		for i, m := range rsp.Meta {
+			if i > 0 {
+				buf.WriteByte(delimiter)
+			}
+			f.writeString(buf, m.Name)
+		}
+		buf.WriteByte('\n')
+	}
+	for _, row := range rsp.RawData {
+		for i, m := range rsp.Meta {
+			if i > 0 {
+				buf.WriteByte(delimiter)
+			}
+			f.writeValue(buf, row[m.Name], m.Type)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// writeValue writes a value of the column type. Values are decoded from the JSON format, in which
+// 64-bit integers and decimals are quoted, so whether a value is quoted in the CSV formats is
+// determined by the column type rather than the decoded value
+func (f *outputFormat) writeValue(buf *bytes.Buffer, v interface{}, columnType string) {
+	var s string
+	switch tv := v.(type) {
+	case nil:
+		buf.WriteString(`\N`)
+		return
+	case string:
+		s = tv
+	case float64:
+		s = strconv.FormatFloat(tv, 'f', -1, 64)
+	case int64:
+		s = strconv.FormatInt(tv, 10)
+	case bool:
+		s = strconv.FormatBool(tv)
+	default:
+		// composite values, such as arrays and tuples, retain their JSON representation
+		b, _ := json.Marshal(tv)
+		s = string(b)
+	}
+	if f.csv && !isStringType(columnType) {
+		buf.WriteString(s)
+		return
+	}
+	f.writeString(buf, s)
+}
+
+// writeString writes a string value, quoted for the CSV formats, or escaped for the
+// TabSeparated formats
+func (f *outputFormat) writeString(buf *bytes.Buffer, s string) {
+	if f.csv {
+		buf.WriteByte('"')
+		buf.WriteString(strings.Replace(s, `"`, `""`, -1))
+		buf.WriteByte('"')
+		return
+	}
+	tsvEscaper.WriteString(buf, s)
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`,
+	"\x00", `\0`, "'", `\'`)
+
+// isStringType returns true if values of the column type are quoted in the CSV formats
+func isStringType(columnType string) bool {
+	for unwrapped := false; !unwrapped; {
+		unwrapped = true
+		for _, wrapper := range []string{"Nullable(", "LowCardinality("} {
+			if strings.HasPrefix(columnType, wrapper) {
+				columnType = strings.TrimSuffix(strings.TrimPrefix(columnType, wrapper), ")")
+				unwrapped = false
+			}
+		}
+	}
+	for _, prefix := range []string{"String", "FixedString", "Date", "UUID", "Enum", "IPv",
+		"Array", "Tuple", "Map"} {
+		if strings.HasPrefix(columnType, prefix) {
+			return true
+		}
+	}
+	return false
+}
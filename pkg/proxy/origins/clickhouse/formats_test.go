@@ -0,0 +1,211 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+var testFormatsJSON = `{"meta":[{"name":"t","type":"UInt64"},{"name":"cnt","type":"UInt64"},` +
+	`{"name":"field1","type":"UInt16"},{"name":"field2","type":"String"}],` +
+	`"data":[{"cnt":"12","field1":200,"field2":"some_value","t":"1516665600000"},` +
+	`{"cnt":"10","field1":200,"field2":"some \"value\"","t":"1516665660000"}],"rows":2}`
+
+const testFormatsCSV = "1516665600000,12,200,\"some_value\"\n" +
+	"1516665660000,10,200,\"some \"\"value\"\"\"\n"
+
+// testFormatQuery returns a query for the hour ending an hour ago, so that it is cacheable,
+// and the JSON response and CSV rendering of its data
+func testFormatQuery(format string) (string, string, string) {
+	end := time.Now().Truncate(time.Minute).Add(-time.Hour).Unix()
+	start := end - 3600
+	query := fmt.Sprintf(`SELECT (intDiv(toUInt32(time_column), 60) * 60) * 1000 AS t, `+
+		`countMerge(some_count) AS cnt, field1, field2 FROM testdb.test_table `+
+		`WHERE time_column BETWEEN toDateTime(%d) AND toDateTime(%d) `+
+		`AND field1 > 0 GROUP BY t, field1, field2 ORDER BY t, field1 FORMAT %s`, start, end, format)
+	json := strings.Replace(strings.Replace(testFormatsJSON, "1516665600", strconv.FormatInt(start, 10), 1),
+		"1516665660", strconv.FormatInt(start+60, 10), 1)
+	csv := strings.Replace(strings.Replace(testFormatsCSV, "1516665600", strconv.FormatInt(start, 10), 1),
+		"1516665660", strconv.FormatInt(start+60, 10), 1)
+	return url.Values{"query": {query}}.Encode(), json, csv
+}
+
+func TestSplitFormat(t *testing.T) {
+
+	tests := []struct {
+		query, expected, format string
+		ok                      bool
+	}{
+		{"SELECT 1 FORMAT JSON", "SELECT 1", formatJSON, true},
+		{"SELECT 1\nFORMAT   csvwithnames\n", "SELECT 1", formatCSVWithNames, true},
+		{"SELECT 1 FORMAT TSV", "SELECT 1", formatTabSeparated, true},
+		{"SELECT 1 FORMAT Pretty", "SELECT 1 FORMAT Pretty", "", false},
+		{"SELECT 1 JSON", "SELECT 1 JSON", "", false},
+		{"JSON", "JSON", "", false},
+	}
+
+	for i, test := range tests {
+		q, f, ok := splitFormat(test.query)
+		if ok != test.ok {
+			t.Errorf("(%d) expected %t got %t", i, test.ok, ok)
+			continue
+		}
+		if q != test.expected {
+			t.Errorf("(%d) expected %s got %s", i, test.expected, q)
+		}
+		if ok && f.name != test.format {
+			t.Errorf("(%d) expected %s got %s", i, test.format, f.name)
+		}
+	}
+
+	if q := withFormat("SELECT 1 FORMAT csv", formatJSON); q != "SELECT 1 FORMAT JSON" {
+		t.Errorf("expected %s got %s", "SELECT 1 FORMAT JSON", q)
+	}
+}
+
+func TestRenderTimeseries(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, err := client.UnmarshalTimeseries([]byte(testFormatsJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format, delimiter, expected, contentType string
+	}{
+		{formatCSV, "", testFormatsCSV, outputFormats["CSV"].contentType},
+		{formatCSVWithNames, ";", "\"t\";\"cnt\";\"field1\";\"field2\"\n" +
+			strings.Replace(testFormatsCSV, ",", ";", -1), outputFormats["CSVWITHNAMES"].contentType},
+		{formatTabSeparated, ",", "1516665600000\t12\t200\tsome_value\n" +
+			"1516665660000\t10\t200\tsome \"value\"\n", outputFormats["TSV"].contentType},
+	}
+
+	for i, test := range tests {
+		v := url.Values{"query": {"SELECT 1 FORMAT " + test.format}}
+		if test.delimiter != "" {
+			v.Set(upCSVDelimiter, test.delimiter)
+		}
+		r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/?"+v.Encode(), nil)
+		if f := client.TimeseriesFormat(r); f != test.format {
+			t.Errorf("(%d) expected %s got %s", i, test.format, f)
+		}
+		b, ct, err := client.RenderTimeseries(ts, r)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(b) != test.expected {
+			t.Errorf("(%d) expected %q got %q", i, test.expected, string(b))
+		}
+		if ct != test.contentType {
+			t.Errorf("(%d) expected %s got %s", i, test.contentType, ct)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/?"+testRawQuery(), nil)
+	if f := client.TimeseriesFormat(r); f != "" {
+		t.Errorf("expected empty format got %s", f)
+	}
+
+	if _, _, err := client.RenderTimeseries(nil, r); err == nil {
+		t.Error("expected error for unexpected timeseries type")
+	}
+}
+
+func TestIsStringType(t *testing.T) {
+	tests := map[string]bool{
+		"String":                           true,
+		"Nullable(String)":                 true,
+		"LowCardinality(Nullable(String))": true,
+		"DateTime('Etc/UTC')":              true,
+		"UInt64":                           false,
+		"Nullable(Float64)":                false,
+	}
+	for columnType, expected := range tests {
+		if isStringType(columnType) != expected {
+			t.Errorf("%s: expected %t", columnType, expected)
+		}
+	}
+}
+
+func TestCanonicalFormatRoundTrip(t *testing.T) {
+
+	jsonQuery, body, expected := testFormatQuery(formatJSON)
+	csvQuery, _, _ := testFormatQuery(formatCSV)
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs,
+		200, body, nil, "clickhouse", "/?"+jsonQuery, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	rsc.PathConfig.CanonicalFormat = true
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+	ctx := r.Context()
+
+	// the JSON request populates the cache
+	client.QueryHandler(w, r)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if s := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(s, "status=kmiss") {
+		t.Errorf("expected kmiss got %s", s)
+	}
+
+	// the CSV request for the same query is rendered from the cached JSON
+	r, _ = http.NewRequest(http.MethodGet, ts.URL+"/?"+csvQuery, nil)
+	r = r.WithContext(ctx)
+	w = httptest.NewRecorder()
+	client.QueryHandler(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if s := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(s, "status=hit") {
+		t.Errorf("expected hit got %s", s)
+	}
+	if ct := resp.Header.Get(headers.NameContentType); ct != outputFormats["CSV"].contentType {
+		t.Errorf("expected %s got %s", outputFormats["CSV"].contentType, ct)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expected {
+		t.Errorf("expected %q got %q", expected, string(b))
+	}
+}
@@ -18,6 +18,7 @@ package clickhouse
 
 import (
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
@@ -59,7 +60,7 @@ func TestHealthHandler(t *testing.T) {
 		t.Errorf("expected '{}' got %s.", bodyBytes)
 	}
 
-	client.healthMethod = "-"
+	client.config.HealthCheckVerb = ""
 
 	w = httptest.NewRecorder()
 	client.HealthHandler(w, r)
@@ -106,3 +107,18 @@ func TestHealthHandlerCustomPath(t *testing.T) {
 	}
 
 }
+
+func TestDefaultHealthCheckConfig(t *testing.T) {
+
+	c := &Client{name: "test"}
+	o := c.DefaultHealthCheckConfig()
+	if o.Verb != http.MethodPost {
+		t.Errorf("expected %s got %s", http.MethodPost, o.Verb)
+	}
+	if o.Body != healthQuery {
+		t.Errorf("expected %s got %s", healthQuery, o.Body)
+	}
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+}
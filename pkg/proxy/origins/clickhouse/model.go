@@ -118,6 +118,38 @@ var toDateString toTimeFunc = func(t time.Time) interface{} {
 	return t.In(utcLoc).Format(chLayout)
 }
 
+// dateTimeLocation returns the time zone of a DateTime column type, such as DateTime('Europe/Moscow'),
+// in which its values are formatted, or UTC when the type does not specify one
+func dateTimeLocation(tsType string) *time.Location {
+	i := strings.Index(tsType, "('")
+	if i < 0 || !strings.HasSuffix(tsType, "')") {
+		return utcLoc
+	}
+	loc, err := time.LoadLocation(tsType[i+2 : len(tsType)-2])
+	if err != nil {
+		return utcLoc
+	}
+	return loc
+}
+
+func fromDateStringIn(loc *time.Location) fromTimeFunc {
+	if loc == utcLoc {
+		return fromDateString
+	}
+	return func(v interface{}) (time.Time, error) {
+		return time.ParseInLocation(chLayout, v.(string), loc)
+	}
+}
+
+func toDateStringIn(loc *time.Location) toTimeFunc {
+	if loc == utcLoc {
+		return toDateString
+	}
+	return func(t time.Time) interface{} {
+		return t.In(loc).Format(chLayout)
+	}
+}
+
 // Converts a Timeseries into a JSON blob
 func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
 	return json.Marshal(ts.(*ResultsEnvelope))
@@ -131,6 +163,16 @@ func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error)
 }
 
 func (re ResultsEnvelope) MarshalJSON() ([]byte, error) {
+	rsp, err := re.response()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rsp)
+}
+
+// response returns the ResultsEnvelope as a ClickHouse query result, with a row for each of the
+// values of each point, in timestamp order
+func (re ResultsEnvelope) response() (*Response, error) {
 	if len(re.Meta) == 0 {
 		return nil, fmt.Errorf("no metadata in ResultsEnvelope")
 	}
@@ -138,7 +180,7 @@ func (re ResultsEnvelope) MarshalJSON() ([]byte, error) {
 	tsType := re.Meta[0].Type
 	var ttf toTimeFunc
 	if strings.HasPrefix(tsType, "DateTime") {
-		ttf = toDateString
+		ttf = toDateStringIn(dateTimeLocation(tsType))
 	} else if strings.HasSuffix(tsType, "t64") {
 		ttf = toMsString
 	} else {
@@ -160,7 +202,7 @@ func (re ResultsEnvelope) MarshalJSON() ([]byte, error) {
 		}
 	}
 	rsp.Rows = rows
-	return json.Marshal(rsp)
+	return rsp, nil
 }
 
 func (re ResultsEnvelope) SeriesCount() int {
@@ -186,7 +228,7 @@ func (re *ResultsEnvelope) UnmarshalJSON(b []byte) error {
 
 	var ftf fromTimeFunc
 	if strings.HasPrefix(tsType, "DateTime") {
-		ftf = fromDateString
+		ftf = fromDateStringIn(dateTimeLocation(tsType))
 	} else if strings.HasSuffix(tsType, "t64") {
 		ftf = fromMsString
 	} else if strings.HasSuffix(tsType, "t32") {
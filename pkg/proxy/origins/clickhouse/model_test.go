@@ -20,6 +20,7 @@ import (
 	tt "github.com/tricksterproxy/trickster/pkg/proxy/timeconv"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -311,3 +312,35 @@ func TestUnmarshallBadJSON(t *testing.T) {
 	test("invalid timestamp field", testInvalidTimestampJSON, "timestamp field does not parse to date")
 	test("missing timestamp field", testMissingTimestampJSON, "missing timestamp field in response data")
 }
+
+func TestDateTimeLocation(t *testing.T) {
+
+	const tzJSON = `{"meta":[{"name":"t","type":"DateTime('America/New_York')"},{"name":"cnt","type":"UInt64"}],` +
+		`"data":[{"cnt":"12","t":"2020-03-09 00:00:00"}],"rows":1}`
+
+	re := ResultsEnvelope{}
+	err := re.UnmarshalJSON([]byte(tzJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bucket is 00:00 EDT, not 00:00 UTC
+	if len(re.Data) != 1 || re.Data[0].Timestamp.Unix() != 1583726400 {
+		t.Errorf("expected %d got %v", 1583726400, re.Data)
+	}
+
+	b, err := re.MarshalJSON()
+	if err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(string(b), `"t":"2020-03-09 00:00:00"`) {
+		t.Errorf("expected local timestamp in %s", string(b))
+	}
+
+	if loc := dateTimeLocation("DateTime"); loc != utcLoc {
+		t.Errorf("expected %s got %s", utcLoc, loc)
+	}
+	if loc := dateTimeLocation("DateTime('Mars/Olympus')"); loc != utcLoc {
+		t.Errorf("expected %s got %s", utcLoc, loc)
+	}
+}
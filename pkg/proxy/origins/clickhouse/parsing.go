@@ -60,9 +60,17 @@ var timeFuncMap = map[string]string{
 	"toStartOfTenMinutes":     "10m",
 	"toStartOfFifteenMinutes": "15m",
 	"toStartOfHour":           "1h",
+	"toStartOfDay":            "1d",
 	"toDate":                  "1d",
+	"toStartOfWeek":           "7d",
 }
 
+// Offsets of the first day of the week from the epoch, which fell on a Thursday
+const (
+	sundayOffset = 3 * 24 * time.Hour
+	mondayOffset = 4 * 24 * time.Hour
+)
+
 var parsingNowProvider = func() int {
 	return int(time.Now().Unix())
 }
@@ -75,8 +83,8 @@ var srm = func(str, old string) string {
 
 var sup = strings.ToUpper
 
-func interpolateTimeQuery(template string, extent *timeseries.Extent, step time.Duration) string {
-	endTime := int(extent.End.Unix()) + int(step.Seconds()) // Add step to normalized end time
+func interpolateTimeQuery(template string, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) string {
+	endTime := int(trq.NextStep(extent.End).Unix()) // Add step to normalized end time
 	return strings.Replace(strings.Replace(template, tkTimestamp1,
 		strconv.Itoa(int(extent.Start.Unix())), -1), tkTimestamp2, strconv.Itoa(endTime), -1)
 }
@@ -91,8 +99,11 @@ func parseRawQuery(query string, trq *timeseries.TimeRangeQuery) error {
 	if size < 4 {
 		return fmt.Errorf("unrecognized query format")
 	}
-	if sup(parts[size-2]+" "+parts[size-1]) != "FORMAT JSON" {
-		return fmt.Errorf("non JSON formats not supported")
+	if sup(parts[size-2]) != "FORMAT" {
+		return fmt.Errorf("no FORMAT clause found")
+	}
+	if _, ok := outputFormats[sup(parts[size-1])]; !ok {
+		return fmt.Errorf("unsupported format %s", parts[size-1])
 	}
 
 	var tsColumn, tsAlias string
@@ -118,6 +129,8 @@ func parseRawQuery(query string, trq *timeseries.TimeRangeQuery) error {
 			if tf, ok := m["timeField"]; ok {
 				tsColumn, tsAlias = tf, testAlias
 				duration = m["step"] + "s"
+				// integer division of the epoch time is not aligned to a time zone
+				trq.Location = nil
 			} else {
 				// Otherwise check for the use of built-in ClickHouse time grouping functions
 				for k, v := range timeFuncMap {
@@ -127,8 +140,12 @@ func parseRawQuery(query string, trq *timeseries.TimeRangeQuery) error {
 						if cp == -1 {
 							return fmt.Errorf("invalid time function syntax")
 						}
-						tsColumn, tsAlias = testCol[fi+len(k)+1:fi+cp+1], testAlias
+						args := strings.Split(testCol[fi+len(k)+1:fi+cp+1], ",")
+						tsColumn, tsAlias = args[0], testAlias
 						duration = v
+						if err := parseTimeFuncArgs(k, args[1:], trq); err != nil {
+							return err
+						}
 						break
 					}
 				}
@@ -165,19 +182,17 @@ func parseRawQuery(query string, trq *timeseries.TimeRangeQuery) error {
 		endTime = now
 	}
 
-	step := int(trq.Step.Seconds())
-
-	norm := now / step * step
+	norm, next := alignUnix(trq, now)
 	if endTime > norm {
 		// Pad out endTime if we are in the "now" bucket so the last extent is not truncated
-		endTime = norm + step
+		endTime = next
 		bft := time.Duration(now-norm) * time.Second
 		if bft > bf {
 			bf = bft
 		}
 	} else {
 		// Reduce backfill tolerance to nothing if we're well outside the window
-		etNorm := endTime / step * step
+		etNorm, _ := alignUnix(trq, endTime)
 		diff := time.Duration(now-etNorm) * time.Second
 		nbf := bf - diff
 		if nbf < bf {
@@ -190,6 +205,50 @@ func parseRawQuery(query string, trq *timeseries.TimeRangeQuery) error {
 	return nil
 }
 
+// parseTimeFuncArgs sets the query's step alignment from the optional arguments of a time grouping
+// function: a time zone, which otherwise defaults to that of the origin, and the mode of toStartOfWeek,
+// which determines whether weeks begin on Sunday (even modes) or Monday (odd modes)
+func parseTimeFuncArgs(fn string, args []string, trq *timeseries.TimeRangeQuery) error {
+	var mode int
+	for _, a := range args {
+		if strings.HasPrefix(a, "'") {
+			loc, err := time.LoadLocation(strings.Trim(a, "'"))
+			if err != nil {
+				return fmt.Errorf("invalid time zone %s", a)
+			}
+			trq.Location = loc
+			continue
+		}
+		if fn != "toStartOfWeek" {
+			return fmt.Errorf("unrecognized %s argument %s", fn, a)
+		}
+		m, err := strconv.Atoi(a)
+		if err != nil {
+			return fmt.Errorf("invalid week mode %s", a)
+		}
+		mode = m
+	}
+	if fn == "toStartOfWeek" {
+		trq.StepOffset = sundayOffset
+		if mode%2 == 1 {
+			trq.StepOffset = mondayOffset
+		}
+	}
+	return nil
+}
+
+// alignUnix returns the boundary of the query's step that contains the epoch time ts, and the
+// boundary of the following step
+func alignUnix(trq *timeseries.TimeRangeQuery, ts int) (int, int) {
+	if !trq.IsLocal() {
+		step := int(trq.Step.Seconds())
+		norm := ts / step * step
+		return norm, norm + step
+	}
+	t := trq.AlignTime(time.Unix(int64(ts), 0))
+	return int(t.Unix()), int(trq.NextStep(t).Unix())
+}
+
 func parseTime(ts string) (int, error) {
 	if strings.HasPrefix(ts, "now(") {
 		now := parsingNowProvider()
@@ -18,6 +18,7 @@ package clickhouse
 
 import (
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	"strings"
 	"testing"
 	"time"
 )
@@ -94,6 +95,67 @@ func TestGoodQueries(t *testing.T) {
 
 }
 
+func TestTimeZoneQueries(t *testing.T) {
+	parsingNowProvider = testNow
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := `SELECT toStartOfDay(datetime, 'America/New_York') AS t, count() as cnt FROM test_db.test_table ` +
+		`WHERE datetime >= 1583038800 AND datetime < 1583985600 GROUP BY t FORMAT JSON`
+	trq := &timeseries.TimeRangeQuery{}
+	err = parseRawQuery(query, trq)
+	if err != nil {
+		t.Error(err)
+	}
+	if trq.Step != 24*time.Hour {
+		t.Errorf("expected %s got %s", 24*time.Hour, trq.Step)
+	}
+	if trq.Location == nil || trq.Location.String() != "America/New_York" {
+		t.Errorf("expected %s got %v", "America/New_York", trq.Location)
+	}
+
+	// the upstream request ends at the following local midnight, which is 23 hours later
+	// on the day that daylight saving time begins
+	e := timeseries.Extent{Start: time.Date(2020, 3, 1, 0, 0, 0, 0, ny), End: time.Date(2020, 3, 8, 0, 0, 0, 0, ny)}
+	q := interpolateTimeQuery(trq.Statement, trq, &e)
+	if !strings.Contains(q, "datetime < 1583726400") {
+		t.Errorf("expected end of %d in %s", 1583726400, q)
+	}
+
+	// weeks begin on Monday in odd modes, and align to the origin's time zone by default
+	query = `SELECT toStartOfWeek(datetime, 1) AS t, count() as cnt FROM test_db.test_table ` +
+		`WHERE datetime >= 1583038800 AND datetime < 1583985600 GROUP BY t FORMAT JSON`
+	trq = &timeseries.TimeRangeQuery{Location: ny}
+	err = parseRawQuery(query, trq)
+	if err != nil {
+		t.Error(err)
+	}
+	if trq.Step != 7*24*time.Hour {
+		t.Errorf("expected %s got %s", 7*24*time.Hour, trq.Step)
+	}
+	if trq.StepOffset != mondayOffset {
+		t.Errorf("expected %s got %s", mondayOffset, trq.StepOffset)
+	}
+	if trq.Location != ny {
+		t.Errorf("expected %s got %v", ny, trq.Location)
+	}
+
+	// integer division of the epoch time is not aligned to the origin's time zone
+	query = `SELECT (intDiv(toUInt32(datetime), 300) * 300) * 1000 AS t, count() as cnt FROM test_db.test_table ` +
+		`WHERE datetime >= 1583038800 AND datetime < 1583985600 GROUP BY t FORMAT JSON`
+	trq = &timeseries.TimeRangeQuery{Location: ny}
+	err = parseRawQuery(query, trq)
+	if err != nil {
+		t.Error(err)
+	}
+	if trq.Location != nil {
+		t.Errorf("expected nil location got %s", trq.Location)
+	}
+}
+
 func TestBadQueries(t *testing.T) {
 	test := func(run string, query string, es string) {
 		t.Run(run, func(t *testing.T) {
@@ -108,8 +170,10 @@ func TestBadQueries(t *testing.T) {
 	}
 
 	test("Query too short", "SELECT too short", "unrecognized query format")
-	test("Query not JSON format", "SELECT toStartOfMinute(datetime), cnt FROM test_table FORMAT TSV",
-		"non JSON formats not supported")
+	test("Query unsupported format", "SELECT toStartOfMinute(datetime), cnt FROM test_table FORMAT Pretty",
+		"unsupported format Pretty")
+	test("Query without format", "SELECT toStartOfMinute(datetime), cnt FROM test_table WHERE x = 1",
+		"no FORMAT clause found")
 	test("Bad time function", "WITH 300 as t SELECT toStartOfTenMinutes(datetime, cnt FROM "+
 		"test_table FORMAT JSON", "invalid time function syntax")
 	test("Not valid time series", "SELECT a, b FROM test_table FORMAT JSON", "no matching time value column found")
@@ -125,6 +189,10 @@ func TestBadQueries(t *testing.T) {
 		`parsing time "November" as "2006-01-02 15:04:05": cannot parse "November" as "2006"`)
 	test("Invalid end time", "SELECT toDate(datetime), cnt FROM test_table WHERE datetime > '2020-10-15 00:22:00' AND datetime <'December' FORMAT JSON",
 		`parsing time "December" as "2006-01-02 15:04:05": cannot parse "December" as "2006"`)
+	test("Invalid time zone", "SELECT toStartOfDay(datetime, 'Mars/Olympus') AS t, cnt FROM test_table "+
+		"WHERE datetime >= 1583038800 FORMAT JSON", `invalid time zone 'Mars/Olympus'`)
+	test("Invalid week mode", "SELECT toStartOfWeek(datetime, x) AS t, cnt FROM test_table "+
+		"WHERE datetime >= 1583038800 FORMAT JSON", `invalid week mode x`)
 	test("Weird now expression", "SELECT toDate(datetime), cnt FROM test_table WHERE datetime > '2020-10-15 00:22:00' AND datetime <now()-2tt FORMAT JSON",
 		`strconv.Atoi: parsing "2tt": invalid syntax`)
 }
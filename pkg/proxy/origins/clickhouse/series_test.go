@@ -296,3 +296,73 @@ func TestSize(t *testing.T) {
 		t.Errorf("expected %d got %d", expected, i)
 	}
 }
+
+func TestMergeDST(t *testing.T) {
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                 string
+		start, cached, end   time.Time
+		expectedFetchedStart time.Time
+	}{
+		{"spring forward", time.Date(2020, 3, 4, 0, 0, 0, 0, ny), time.Date(2020, 3, 8, 0, 0, 0, 0, ny),
+			time.Date(2020, 3, 11, 0, 0, 0, 0, ny), time.Date(2020, 3, 9, 0, 0, 0, 0, ny)},
+		{"fall back", time.Date(2020, 10, 28, 0, 0, 0, 0, ny), time.Date(2020, 11, 1, 0, 0, 0, 0, ny),
+			time.Date(2020, 11, 4, 0, 0, 0, 0, ny), time.Date(2020, 11, 2, 0, 0, 0, 0, ny)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trq := &timeseries.TimeRangeQuery{Step: 24 * time.Hour, Location: ny,
+				Extent: timeseries.Extent{Start: test.start, End: test.end}}
+
+			// the cached buckets end on the day of the transition
+			cached := testRe().setStep("1d")
+			for ts := test.start; !ts.After(test.cached); ts = trq.NextStep(ts) {
+				cached.addPoint(int(ts.Unix()), 1.5)
+			}
+			cached.ExtentList = timeseries.ExtentList{{Start: test.start, End: test.cached}}
+
+			misses := trq.CalculateDeltas(cached.Extents())
+			if len(misses) != 1 || !misses[0].Start.Equal(test.expectedFetchedStart) ||
+				!misses[0].End.Equal(test.end) {
+				t.Fatalf("expected %s-%s got %v", test.expectedFetchedStart, test.end, misses)
+			}
+
+			fetched := testRe().setStep("1d")
+			for ts := misses[0].Start; !ts.After(misses[0].End); ts = trq.NextStep(ts) {
+				fetched.addPoint(int(ts.Unix()), 1.5)
+			}
+			fetched.ExtentList = timeseries.ExtentList{misses[0]}
+
+			cached.Merge(true, fetched)
+			cached.SetExtents(trq.CompressExtents(cached.Extents()))
+
+			el := cached.Extents()
+			if len(el) != 1 || !el[0].Start.Equal(test.start) || !el[0].End.Equal(test.end) {
+				t.Errorf("expected %s-%s got %v", test.start, test.end, el)
+			}
+			if len(trq.CalculateDeltas(el)) != 0 {
+				t.Errorf("expected no deltas got %v", trq.CalculateDeltas(el))
+			}
+
+			// every bucket begins at local midnight, with none missing or duplicated
+			if len(cached.Data) != 8 {
+				t.Errorf("expected %d got %d", 8, len(cached.Data))
+			}
+			for i, p := range cached.Data {
+				if lt := p.Timestamp.In(ny); lt.Hour() != 0 || lt.Minute() != 0 {
+					t.Errorf("expected bucket %d at local midnight got %s", i, lt)
+				}
+				if i > 0 && !p.Timestamp.Equal(trq.NextStep(cached.Data[i-1].Timestamp)) {
+					t.Errorf("expected bucket %d to follow %s got %s", i,
+						cached.Data[i-1].Timestamp, p.Timestamp)
+				}
+			}
+		})
+	}
+}
@@ -42,7 +42,8 @@ func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, exte
 	r.Header.Set("Accept-Encoding", "gzip")
 
 	if q != "" {
-		p.Set(upQuery, interpolateTimeQuery(q, extent, trq.Step))
+		// the origin always responds in the canonical format, which is rendered in the requested format
+		p.Set(upQuery, withFormat(interpolateTimeQuery(q, trq, extent), formatJSON))
 	}
 
 	r.URL.RawQuery = p.Encode()
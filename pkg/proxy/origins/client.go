@@ -20,6 +20,7 @@ import (
 	"net/http"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
 )
@@ -43,3 +44,47 @@ type Client interface {
 	// Cache returns a handle to the Cache instance used by the Client
 	Cache() cache.Cache
 }
+
+// ResultLimiter is an optional interface for Clients whose APIs accept a client-requested
+// limit on the number of results in a response. The limit is applied to cached responses
+// as they are rendered to the client, so cached documents are not altered
+type ResultLimiter interface {
+	// ResultLimit returns the result limit requested by the client, or 0 if there is none
+	ResultLimit(*http.Request) int
+	// LimitResults returns the response body trimmed to no more than limit results,
+	// and true if any results were removed
+	LimitResults(body []byte, limit int) ([]byte, bool)
+}
+
+// TimeoutParamer is an optional interface for Clients whose APIs accept a client-requested
+// query timeout as a query parameter. The parameter is read as the client's timeout hint, and
+// the remaining timeout budget is forwarded in it on upstream requests
+type TimeoutParamer interface {
+	TimeoutParam() string
+}
+
+// ParamsPolicy is an optional interface for Clients that reports the query parameters that are
+// semantically important to the origin's API, and whether the origin honors the last occurrence of
+// a duplicated parameter (otherwise, the first). Clients that do not implement ParamsPolicy use
+// params.DefaultImportantParams, with the first occurrence honored
+type ParamsPolicy interface {
+	ImportantParams() (names []string, useLast bool)
+}
+
+// AuthCachePolicyDefaulter is an optional interface for Clients that reports the policy for
+// caching responses to authenticated requests for paths that do not configure one. Clients
+// that do not implement AuthCachePolicyDefaulter use authcache.PolicyNever
+type AuthCachePolicyDefaulter interface {
+	DefaultAuthCachePolicy() authcache.Policy
+}
+
+// DefaultAuthCachePolicy returns the default policy for caching responses to authenticated
+// requests for the provided Client, or authcache.PolicyNever if it does not report one
+func DefaultAuthCachePolicy(c Client) authcache.Policy {
+	if d, ok := c.(AuthCachePolicyDefaulter); ok {
+		if p := d.DefaultAuthCachePolicy(); p != authcache.PolicyDefault {
+			return p
+		}
+	}
+	return authcache.PolicyNever
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import "github.com/tricksterproxy/trickster/pkg/proxy/fingerprint"
+
+// influxqlSyntax describes the lexical features of InfluxQL, whose single-quoted tokens
+// are string literals and double-quoted tokens are identifiers
+var influxqlSyntax = fingerprint.Syntax{Quotes: `'"`, LineComment: "--", BlockComments: true,
+	RegexOperators: []string{"=~", "!~"}}
+
+// influxqlKeywords are the case-insensitive InfluxQL keywords that are uppercased in normalized queries
+var influxqlKeywords = map[string]bool{"select": true, "from": true, "where": true, "and": true,
+	"or": true, "group": true, "by": true, "order": true, "asc": true, "desc": true, "limit": true,
+	"offset": true, "slimit": true, "soffset": true, "fill": true, "as": true, "into": true,
+	"tz": true, "now": true, "show": true, "on": true, "with": true}
+
+// NormalizeQuery returns the InfluxQL query with its whitespace, comments and keywords canonicalized,
+// and its numeric constants, durations and literal time values replaced with a placeholder. When
+// stripValues is true, the string and regular expression values of tag comparisons are also replaced
+func (c *Client) NormalizeQuery(query string, stripValues bool) string {
+	tokens := fingerprint.Tokenize(query, influxqlSyntax)
+	for i, t := range tokens {
+		switch t.Type {
+		case fingerprint.TokenNumber:
+			tokens[i].Value = fingerprint.Placeholder
+		case fingerprint.TokenString:
+			if t.Value[0] == '\'' && (stripValues || fingerprint.IsTimeLiteral(t.Value)) {
+				tokens[i].Value = "'" + fingerprint.Placeholder + "'"
+			}
+		case fingerprint.TokenRegex:
+			if stripValues {
+				tokens[i].Value = "/" + fingerprint.Placeholder + "/"
+			}
+		}
+	}
+	fingerprint.UpperKeywords(tokens, influxqlKeywords)
+	return fingerprint.Join(tokens)
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import "testing"
+
+func TestNormalizeQuery(t *testing.T) {
+
+	tests := []struct {
+		query       string
+		stripValues bool
+		expected    string
+	}{
+		{`select mean("value") from "cpu" where "host" = 'server01' and time >= now() - 6h group by time(1m)`,
+			false, `SELECT mean("value") FROM "cpu" WHERE "host"='server01' AND time>=NOW()-? GROUP BY time(?)`},
+		{`SELECT mean("value") FROM "cpu" WHERE "host" = 'server01' AND time >= now() - 1h GROUP BY time(10s)`,
+			true, `SELECT mean("value") FROM "cpu" WHERE "host"='?' AND time>=NOW()-? GROUP BY time(?)`},
+		// literal time values are always stripped
+		{`SELECT max(v) FROM m WHERE time > '2020-01-01T00:00:00Z' AND time < 1577840400000ms`, false,
+			`SELECT max(v) FROM m WHERE time>'?' AND time<?`},
+		{`SELECT v FROM m WHERE host =~ /^web-[0-9]+$/ AND v / 2 > 1`, false,
+			`SELECT v FROM m WHERE host=~/^web-[0-9]+$/ AND v/?>?`},
+		{`SELECT v FROM m WHERE host !~ /^web-[0-9]+$/ -- comment`, true,
+			`SELECT v FROM m WHERE host!~/?/`},
+		{`SELECT v FROM m WHERE time > now() - 5µs`, false, `SELECT v FROM m WHERE time>NOW()-?`},
+	}
+
+	c := &Client{}
+	for i, test := range tests {
+		if v := c.NormalizeQuery(test.query, test.stripValues); v != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, v)
+		}
+	}
+}
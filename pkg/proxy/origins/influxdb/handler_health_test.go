@@ -18,18 +18,19 @@ package influxdb
 
 import (
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"sync"
 	"testing"
 
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
 )
 
 func TestHealthHandler(t *testing.T) {
 
-	client := &Client{name: "test", healthHeaderLock: &sync.Mutex{}}
+	client := &Client{name: "test"}
 	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil, "influxdb", "/health", "debug")
 
 	rsc := request.GetResources(r)
@@ -60,7 +61,7 @@ func TestHealthHandler(t *testing.T) {
 		t.Errorf("expected '{}' got %s.", bodyBytes)
 	}
 
-	client.healthMethod = "-"
+	client.config.HealthCheckVerb = ""
 
 	w = httptest.NewRecorder()
 	client.HealthHandler(w, r)
@@ -73,7 +74,7 @@ func TestHealthHandler(t *testing.T) {
 
 func TestHealthHandlerCustomPath(t *testing.T) {
 
-	client := &Client{name: "test", healthHeaderLock: &sync.Mutex{}}
+	client := &Client{name: "test"}
 	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "", nil, "influxdb", "/health", "debug")
 	if err != nil {
 		t.Error(err)
@@ -112,7 +113,7 @@ func TestHealthHandlerCustomPath(t *testing.T) {
 
 func TestHealthHandlerPost(t *testing.T) {
 
-	client := &Client{name: "test", healthHeaderLock: &sync.Mutex{}}
+	client := &Client{name: "test"}
 	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "", nil, "influxdb", "/health", "debug")
 	if err != nil {
 		t.Error(err)
@@ -126,7 +127,17 @@ func TestHealthHandlerPost(t *testing.T) {
 	client.config.HealthCheckUpstreamPath = "-"
 	client.config.HealthCheckVerb = "POST"
 	client.config.HealthCheckQuery = "testParam1=testValue1"
-	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+
+	// the health check query is sent as the form body of the POST
+	var body, contentType, rawQuery string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		contentType = r.Header.Get(headers.NameContentType)
+		rawQuery = r.URL.RawQuery
+	}))
+	defer upstream.Close()
+	client.baseUpstreamURL, _ = url.Parse(upstream.URL)
 	client.webClient = hc
 	client.config.HTTPClient = hc
 
@@ -147,4 +158,14 @@ func TestHealthHandlerPost(t *testing.T) {
 		t.Errorf("expected '' got %s.", bodyBytes)
 	}
 
+	if body != "testParam1=testValue1" {
+		t.Errorf("expected %s got %s", "testParam1=testValue1", body)
+	}
+	if contentType != headers.ValueXFormURLEncoded {
+		t.Errorf("expected %s got %s", headers.ValueXFormURLEncoded, contentType)
+	}
+	if rawQuery != "" {
+		t.Errorf("expected '' got %s", rawQuery)
+	}
+
 }
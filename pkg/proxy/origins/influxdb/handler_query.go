@@ -20,6 +20,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
@@ -67,6 +68,14 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 		return nil, errors.ErrStepParse
 	}
 	trq.Step = stepDuration
+	// a tz() clause aligns the group by time() intervals to the time zone's local time
+	if tz, found := matching.GetNamedMatch("tz", reTimeZone, trq.Statement); found {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, err
+		}
+		trq.Location = loc
+	}
 	trq.Statement, trq.Extent = getQueryParts(trq.Statement)
 	trq.TemplateURL = urls.Clone(r.URL)
 
@@ -188,3 +188,40 @@ func TestParseTimeRangeQueryBadDuration(t *testing.T) {
 	}
 
 }
+
+func TestParseTimeRangeQueryTimeZone(t *testing.T) {
+
+	q := func(tz string) *http.Request {
+		return &http.Request{
+			Method: http.MethodGet,
+			URL: &url.URL{
+				Scheme: "https",
+				Host:   "blah.com",
+				Path:   "/",
+				RawQuery: url.Values(map[string][]string{
+					"q": {`SELECT mean("value") FROM "monthly"."rollup.1min" WHERE time >= 1583038800s ` +
+						`AND time <= 1583985600s GROUP BY time(1d) fill(null) tz('` + tz + `')`},
+					"epoch": {"ms"},
+				}).Encode(),
+			}}
+	}
+
+	client := &Client{}
+	res, err := client.ParseTimeRangeQuery(q("America/New_York"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Location == nil || res.Location.String() != "America/New_York" {
+		t.Errorf("expected %s got %v", "America/New_York", res.Location)
+	}
+
+	// the extent is aligned to local midnight across the daylight saving time transition
+	res.NormalizeExtent()
+	assert.Equal(t, res.Extent.Start.Unix(), int64(1583038800))
+	assert.Equal(t, res.Extent.End.Unix(), int64(1583985600))
+
+	_, err = client.ParseTimeRangeQuery(q("Mars/Olympus"))
+	if err == nil {
+		t.Error("expected error for invalid time zone")
+	}
+}
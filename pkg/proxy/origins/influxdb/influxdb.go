@@ -18,16 +18,16 @@
 package influxdb
 
 import (
-	"io"
 	"net/http"
 	"net/url"
-	"sync"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/proxy"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 )
 
 var _ origins.Client = (*Client)(nil)
@@ -42,12 +42,6 @@ type Client struct {
 	handlersRegistered bool
 	baseUpstreamURL    *url.URL
 	router             http.Handler
-
-	healthURL        *url.URL
-	healthHeaders    http.Header
-	healthMethod     string
-	healthBody       io.Reader
-	healthHeaderLock *sync.Mutex
 }
 
 // NewClient returns a new Client Instance
@@ -58,7 +52,17 @@ func NewClient(name string, oc *oo.Options, router http.Handler,
 	// explicitly disable Fast Forward for this client
 	oc.FastForwardDisable = true
 	return &Client{name: name, config: oc, router: router, cache: cache,
-		webClient: c, baseUpstreamURL: bur, healthHeaderLock: &sync.Mutex{}}, err
+		webClient: c, baseUpstreamURL: bur}, err
+}
+
+func init() {
+	origins.MustRegisterProvider("influxdb", newProviderClient)
+}
+
+// newProviderClient is the origins.ProviderFunc for the influxdb origin type
+func newProviderClient(name string, oc *oo.Options, router http.Handler, c cache.Cache,
+	_ origins.Origins, _ *tl.Logger) (origins.Client, error) {
+	return NewClient(name, oc, router, c)
 }
 
 // Configuration returns the upstream Configuration for this Client
@@ -90,3 +94,17 @@ func (c *Client) SetCache(cc cache.Cache) {
 func (c *Client) Router() http.Handler {
 	return c.router
 }
+
+// ImportantParams returns the query parameters that are semantically important to InfluxDB.
+// InfluxDB reads parameters with http.Request.FormValue, which honors the first occurrence
+func (c *Client) ImportantParams() ([]string, bool) {
+	return []string{upQuery, upDB, "epoch", "rp"}, false
+}
+
+// DefaultAuthCachePolicy returns the policy for caching responses to authenticated requests when a
+// path does not configure one. InfluxDB grants its users read access per database, so a shared
+// response could be served to a user that is not permitted to read the database, and results
+// are instead cached per identity
+func (c *Client) DefaultAuthCachePolicy() authcache.Policy {
+	return authcache.PolicyPerIdentity
+}
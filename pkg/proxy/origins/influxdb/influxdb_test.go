@@ -17,10 +17,12 @@
 package influxdb
 
 import (
+	"reflect"
 	"testing"
 
 	cr "github.com/tricksterproxy/trickster/pkg/cache/registration"
 	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
@@ -118,6 +120,26 @@ func TestName(t *testing.T) {
 
 }
 
+func TestImportantParams(t *testing.T) {
+	// InfluxDB reads parameters with FormValue, which honors the first occurrence
+	client := &Client{}
+	names, useLast := client.ImportantParams()
+	expected := []string{"q", "db", "epoch", "rp"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v got %v", expected, names)
+	}
+	if useLast {
+		t.Error("expected first occurrence to be honored")
+	}
+}
+
+func TestDefaultAuthCachePolicy(t *testing.T) {
+	client := &Client{}
+	if p := client.DefaultAuthCachePolicy(); p != authcache.PolicyPerIdentity {
+		t.Errorf("expected %s got %s", authcache.PolicyPerIdentity, p)
+	}
+}
+
 func TestRouter(t *testing.T) {
 	client := Client{name: "TEST"}
 	r := client.Router()
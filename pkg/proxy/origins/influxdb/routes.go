@@ -42,23 +42,9 @@ func (c *Client) Handlers() map[string]http.Handler {
 	return c.handlers
 }
 
-func populateHeathCheckRequestValues(oc *oo.Options) {
-	if oc.HealthCheckUpstreamPath == "-" {
-		oc.HealthCheckUpstreamPath = "/ping"
-	}
-	if oc.HealthCheckVerb == "-" {
-		oc.HealthCheckVerb = http.MethodGet
-	}
-	if oc.HealthCheckQuery == "-" {
-		oc.HealthCheckQuery = ""
-	}
-}
-
 // DefaultPathConfigs returns the default PathConfigs for the given OriginType
 func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 
-	populateHeathCheckRequestValues(oc)
-
 	paths := map[string]*po.Options{
 		"/" + mnQuery: {
 			Path:            "/" + mnQuery,
@@ -35,13 +35,16 @@ const (
 	tkTime = "<$TIME_TOKEN$>"
 )
 
-var reTime1, reTime2, reStep *regexp.Regexp
+var reTime1, reTime2, reStep, reTimeZone *regexp.Regexp
 
 func init() {
 
 	// Regexp for extracting the step from an InfluxDB Timeseries Query. searches for something like: group by time(1d)
 	reStep = regexp.MustCompile(`(?i)\s+group\s+by\s+.*time\((?P<step>[0-9]+(ns|µ|u|ms|s|m|h|d|w|y))\).*;??`)
 
+	// Regexp for extracting the time zone from an InfluxDB Timeseries Query. searches for something like: tz('Europe/Paris')
+	reTimeZone = regexp.MustCompile(`(?i)\btz\(\s*'(?P<tz>[^']+)'\s*\)`)
+
 	// Regexp for extracting the time elements from an InfluxDB Timeseries Query with equality operators: >=, >, =
 	// If it's a relative time range (e.g.,  where time >= now() - 24h  ), this expression is all that is required
 	reTime1 = regexp.MustCompile(`(?i)(?P<preOp1>where|and)\s+(?P<timeExpr1>time\s+(?P<relationalOp1>>=|>|=)\s+` +
@@ -19,18 +19,15 @@ package irondb
 import (
 	"bytes"
 	"encoding/json"
-	"io"
 	"io/ioutil"
 	"net/http"
-	"net/url"
-	"strings"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/key"
 	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
-	"github.com/tricksterproxy/trickster/pkg/util/md5"
 )
 
 // FetchHandler handles requests for numeric timeseries data with specified
@@ -122,28 +119,20 @@ func (c *Client) fetchHandlerParseTimeRangeQuery(
 	return trq, nil
 }
 
-// fetchHandlerDeriveCacheKey calculates a query-specific keyname based on the
-// user request.
-func (c Client) fetchHandlerDeriveCacheKey(path string, params url.Values,
-	headers http.Header, body io.ReadCloser, extra string) (string, io.ReadCloser) {
-	var sb strings.Builder
-	sb.WriteString(path)
-	newBody := &bytes.Buffer{}
-	if b, err := ioutil.ReadAll(body); err == nil {
-		body = ioutil.NopCloser(bytes.NewReader(b))
-		fetchReq := map[string]interface{}{}
-		err := json.NewDecoder(bytes.NewReader(b)).Decode(&fetchReq)
+// fetchHandlerKeyInputs provides the cache key inputs of the user request,
+// which are its body, without its time range.
+func (c Client) fetchHandlerKeyInputs(path string, body []byte,
+	ki *key.Inputs) {
+	fetchReq := map[string]interface{}{}
+	err := json.NewDecoder(bytes.NewReader(body)).Decode(&fetchReq)
+	if err == nil {
+		delete(fetchReq, "start")
+		delete(fetchReq, "end")
+		delete(fetchReq, "count")
+		newBody := &bytes.Buffer{}
+		err = json.NewEncoder(newBody).Encode(&fetchReq)
 		if err == nil {
-			delete(fetchReq, "start")
-			delete(fetchReq, "end")
-			delete(fetchReq, "count")
-			err = json.NewEncoder(newBody).Encode(&fetchReq)
-			if err == nil {
-				sb.Write(newBody.Bytes())
-			}
+			ki.Extras = append(ki.Extras, newBody.String())
 		}
 	}
-
-	sb.WriteString(extra)
-	return md5.Checksum(sb.String()), body
 }
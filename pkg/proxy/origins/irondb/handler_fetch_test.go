@@ -24,6 +24,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/key"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
@@ -66,7 +67,7 @@ func TestFetchHandler(t *testing.T) {
 	}
 }
 
-func TestFetchHandlerDeriveCacheKey(t *testing.T) {
+func TestFetchHandlerKeyInputs(t *testing.T) {
 
 	client := &Client{name: "test"}
 	path := "/fetch/0/900/00112233-4455-6677-8899-aabbccddeeff/metric"
@@ -76,11 +77,12 @@ func TestFetchHandlerDeriveCacheKey(t *testing.T) {
 	}
 
 	r.Body = ioutil.NopCloser(bytes.NewReader([]byte("{}")))
+	b, _ := ioutil.ReadAll(r.Body)
 
 	const expected = "a34bbb372c505e9eea0e0589e16c0914"
-	var result string
-	result, r.Body = client.fetchHandlerDeriveCacheKey(path, r.URL.Query(), r.Header, r.Body, "extra")
-	if result != expected {
+	ki := &key.Inputs{URLPath: path, Extra: "extra"}
+	client.fetchHandlerKeyInputs(path, b, ki)
+	if result := ki.Derive(); result != expected {
 		t.Errorf("expected %s got %s", expected, result)
 	}
 
@@ -17,58 +17,21 @@
 package irondb
 
 import (
-	"context"
 	"net/http"
 
-	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
-	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
-	"github.com/tricksterproxy/trickster/pkg/proxy/request"
-	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	ho "github.com/tricksterproxy/trickster/pkg/proxy/healthcheck/options"
 )
 
 // HealthHandler checks the health of the Configured Upstream Origin
 func (c *Client) HealthHandler(w http.ResponseWriter, r *http.Request) {
-	if c.healthURL == nil {
-		c.populateHeathCheckRequestValues()
-	}
-
-	if c.healthMethod == "-" {
-		w.WriteHeader(400)
-		w.Write([]byte("Health Check URL not Configured for origin: " + c.config.Name))
-		return
-	}
-
-	req, _ := http.NewRequest(c.healthMethod, c.healthURL.String(), nil)
-	rsc := request.GetResources(r)
-	req = req.WithContext(tctx.WithHealthCheckFlag(tctx.WithResources(context.Background(), rsc), true))
-
-	req.Header = c.healthHeaders
-	engines.DoProxy(w, req, true)
-
+	engines.HealthCheck(w, r, c.config, c.baseUpstreamURL, c.DefaultHealthCheckConfig())
 }
 
-func (c *Client) populateHeathCheckRequestValues() {
-
-	oc := c.config
-
-	if oc.HealthCheckUpstreamPath == "-" {
-		oc.HealthCheckUpstreamPath = "/" + mnState
-	}
-	if oc.HealthCheckVerb == "-" {
-		oc.HealthCheckVerb = http.MethodGet
-	}
-	if oc.HealthCheckQuery == "-" {
-		oc.HealthCheckQuery = ""
-	}
-
-	c.healthURL = urls.Clone(c.baseUpstreamURL)
-	c.healthURL.Path += oc.HealthCheckUpstreamPath
-	c.healthURL.RawQuery = oc.HealthCheckQuery
-	c.healthMethod = oc.HealthCheckVerb
-
-	if oc.HealthCheckHeaders != nil {
-		c.healthHeaders = http.Header{}
-		headers.UpdateHeaders(c.healthHeaders, oc.HealthCheckHeaders)
+// DefaultHealthCheckConfig returns the default Health Check Options for the Origin Type
+func (c *Client) DefaultHealthCheckConfig() *ho.Options {
+	return &ho.Options{
+		Verb: http.MethodGet,
+		Path: "/" + mnState,
 	}
 }
@@ -59,7 +59,7 @@ func TestHealthHandler(t *testing.T) {
 		t.Errorf("expected '{}' got %s.", bodyBytes)
 	}
 
-	client.healthMethod = "-"
+	client.config.HealthCheckVerb = ""
 
 	w = httptest.NewRecorder()
 	client.HealthHandler(w, r)
@@ -18,19 +18,17 @@ package irondb
 
 import (
 	"context"
-	"io"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/key"
 	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
-	"github.com/tricksterproxy/trickster/pkg/util/md5"
 )
 
 // HistogramHandler handles requests for historgam timeseries data and processes
@@ -112,12 +110,10 @@ func (c *Client) histogramHandlerParseTimeRangeQuery(
 	return trq, nil
 }
 
-// histogramHandlerDeriveCacheKey calculates a query-specific keyname based on
-// the user request.
-func (c Client) histogramHandlerDeriveCacheKey(path string, params url.Values,
-	headers http.Header, body io.ReadCloser, extra string) (string, io.ReadCloser) {
-	var sb strings.Builder
-	sb.WriteString(path)
+// histogramHandlerKeyInputs provides the cache key inputs of the user request,
+// which are its metric, without its time range.
+func (c Client) histogramHandlerKeyInputs(path string, body []byte,
+	ki *key.Inputs) {
 	var ps []string
 	if strings.HasPrefix(path, "/irondb") {
 		ps = strings.SplitN(strings.TrimPrefix(path, "/"), "/", 7)
@@ -129,11 +125,8 @@ func (c Client) histogramHandlerDeriveCacheKey(path string, params url.Values,
 	}
 
 	if len(ps) >= 6 || ps[0] == "histogram" {
-		sb.WriteString("/histogram/" + strings.Join(ps[3:], "/"))
+		ki.Extras = append(ki.Extras, "/histogram/"+strings.Join(ps[3:], "/"))
 	}
-
-	sb.WriteString(extra)
-	return md5.Checksum(sb.String()), body
 }
 
 // histogramHandlerFastForwardURL returns the url to fetch the Fast Forward value
@@ -24,6 +24,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/key"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
@@ -92,25 +93,23 @@ func TestHistogramHandler(t *testing.T) {
 	}
 }
 
-func TestHistogramHandlerDeriveCacheKey(t *testing.T) {
+func TestHistogramHandlerKeyInputs(t *testing.T) {
 
 	client := &Client{name: "test"}
 	path := "/histogram/0/900/00112233-4455-6677-8899-aabbccddeeff/metric"
-	_, _, r, _, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil, "irondb", path, "debug")
-	if err != nil {
-		t.Error(err)
-	}
 
 	expected := "11cc1b20a869f6ff0559b08b014c3ca6"
-	result, _ := client.histogramHandlerDeriveCacheKey(path, r.URL.Query(), r.Header, r.Body, "extra")
-	if result != expected {
+	ki := &key.Inputs{URLPath: path, Extra: "extra"}
+	client.histogramHandlerKeyInputs(path, nil, ki)
+	if result := ki.Derive(); result != expected {
 		t.Errorf("expected %s got %s", expected, result)
 	}
 
 	expected = "c70681051e3af3de12f37686b6a4224f"
 	path = "/irondb/0/900/00112233-4455-6677-8899-aabbccddeeff/metric"
-	result, _ = client.histogramHandlerDeriveCacheKey(path, r.URL.Query(), r.Header, r.Body, "extra")
-	if result != expected {
+	ki = &key.Inputs{URLPath: path, Extra: "extra"}
+	client.histogramHandlerKeyInputs(path, nil, ki)
+	if result := ki.Derive(); result != expected {
 		t.Errorf("expected %s got %s", expected, result)
 	}
 
@@ -19,16 +19,21 @@ package irondb
 import (
 	"context"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	"github.com/tricksterproxy/trickster/pkg/proxy/params"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
 )
 
+var _ origins.QuerySplitter = (*Client)(nil)
+
 // RollupHandler handles requests for numeric timeseries data with specified
 // spans and processes them through the delta proxy cache.
 func (c *Client) RollupHandler(w http.ResponseWriter, r *http.Request) {
@@ -129,3 +134,94 @@ func (c *Client) rollupHandlerFastForwardRequest(
 	params.SetRequestValues(nr, v)
 	return nr, nil
 }
+
+// rollupBatchPaths returns the path of a single-metric rollup request for each
+// metric of a batched rollup request, in the order they are requested. A
+// batched rollup request names each metric by its UUID and name, as in
+// /rollup/<uuid1>/<metric1>/<uuid2>/<metric2>. ok is false when the path is not
+// of a rollup request for more than one metric.
+func rollupBatchPaths(u *url.URL) ([]string, bool) {
+	p := u.EscapedPath()
+	i := strings.Index(p, "/"+mnRollup+"/")
+	if i < 0 {
+		return nil, false
+	}
+
+	prefix := p[:i+len(mnRollup)+2]
+	parts := strings.Split(strings.Trim(p[len(prefix):], "/"), "/")
+	if len(parts) < 4 || len(parts)%2 != 0 {
+		return nil, false
+	}
+
+	paths := make([]string, 0, len(parts)/2)
+	for j := 0; j < len(parts); j += 2 {
+		if !isUUID(parts[j]) || parts[j+1] == "" {
+			return nil, false
+		}
+
+		paths = append(paths, prefix+parts[j]+"/"+parts[j+1])
+	}
+
+	return paths, true
+}
+
+// isUUID returns true if the string is a UUID in its canonical textual form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// SplitQuery splits a batched rollup request into a request for each of its
+// metrics, so that the data of each metric is cached independently, and
+// combines their results into the batched response. Requests to other handlers
+// are not split.
+func (c *Client) SplitQuery(r *http.Request,
+	trq *timeseries.TimeRangeQuery) ([]*http.Request, origins.TimeseriesCombiner, bool) {
+	rsc := request.GetResources(r)
+	if rsc == nil || rsc.PathConfig == nil || rsc.PathConfig.HandlerName != "RollupHandler" {
+		return nil, nil, false
+	}
+
+	paths, ok := rollupBatchPaths(r.URL)
+	if !ok {
+		return nil, nil, false
+	}
+
+	reqs := make([]*http.Request, len(paths))
+	for i, p := range paths {
+		sr := r.Clone(r.Context())
+		sr.URL.RawPath = p
+		sr.URL.Path, _ = url.PathUnescape(p)
+		reqs[i] = sr
+	}
+
+	return reqs, func(results []timeseries.Timeseries) (timeseries.Timeseries, error) {
+		rb := &RollupBatch{Series: make([]*SeriesEnvelope, len(results))}
+		for i, ts := range results {
+			se, ok := ts.(*SeriesEnvelope)
+			if !ok {
+				return nil, errors.ErrUnexpectedUpstreamResponse
+			}
+
+			rb.Series[i] = se
+		}
+
+		return rb, nil
+	}, true
+}
@@ -17,14 +17,21 @@
 package irondb
 
 import (
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
@@ -188,3 +195,158 @@ func TestRollupHandlerFastForwardRequestError(t *testing.T) {
 	}
 
 }
+
+const (
+	testUUID1 = "00112233-4455-6677-8899-aabbccddeeff"
+	testUUID2 = "00112233-4455-6677-8899-aabbccddee00"
+	testUUID3 = "00112233-4455-6677-8899-aabbccddee11"
+)
+
+func TestRollupBatchPaths(t *testing.T) {
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"/rollup/" + testUUID1 + "/metric", nil},
+		{"/api/rollup/" + testUUID1 + "/metric1/" + testUUID2 + "/metric%2F2",
+			[]string{"/api/rollup/" + testUUID1 + "/metric1", "/api/rollup/" + testUUID2 + "/metric%2F2"}},
+		{"/rollup/" + testUUID1 + "/metric1/" + testUUID2, nil},
+		{"/rollup/" + testUUID1 + "/metric1/not-a-uuid/metric2", nil},
+		{"/raw/" + testUUID1 + "/metric1/" + testUUID2 + "/metric2", nil},
+	}
+
+	for i, test := range tests {
+		u, _ := url.Parse("http://0" + test.path)
+		paths, ok := rollupBatchPaths(u)
+		if ok != (test.expected != nil) {
+			t.Errorf("(%d) expected %t got %t", i, test.expected != nil, ok)
+		}
+		if !reflect.DeepEqual(paths, test.expected) {
+			t.Errorf("(%d) expected %v got %v", i, test.expected, paths)
+		}
+	}
+}
+
+// testRollupServer serves rollups of each metric whose values are the metric's number, and
+// records the paths requested from it
+type testRollupServer struct {
+	mtx      sync.Mutex
+	requests map[string]int
+}
+
+func (s *testRollupServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	s.requests[r.URL.Path]++
+	s.mtx.Unlock()
+	qp := r.URL.Query()
+	start, _ := strconv.ParseFloat(qp.Get(upStart), 64)
+	end, _ := strconv.ParseFloat(qp.Get(upEnd), 64)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")[1:]
+	batch := make([]string, 0, len(parts)/2)
+	for i := 1; i < len(parts); i += 2 {
+		points := make([]string, 0)
+		for ts := int64(start); ts < int64(end); ts += 300 {
+			points = append(points, fmt.Sprintf("[%d,%s]", ts, strings.TrimPrefix(parts[i], "metric")))
+		}
+		batch = append(batch, "["+strings.Join(points, ",")+"]")
+	}
+	if len(batch) == 1 {
+		w.Write([]byte(batch[0]))
+		return
+	}
+	w.Write([]byte("[" + strings.Join(batch, ",") + "]"))
+}
+
+func TestRollupHandlerBatch(t *testing.T) {
+
+	client := &Client{name: "test"}
+	client.makeTrqParsers()
+	client.makeExtentSetters()
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200,
+		"{}", nil, "irondb", "/rollup/", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rs := &testRollupServer{requests: make(map[string]int)}
+	us := httptest.NewServer(rs)
+	defer us.Close()
+
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(us.URL)
+	ctx := r.Context()
+
+	end := time.Now().Add(-time.Hour).Truncate(300 * time.Second)
+	start := end.Add(-time.Hour)
+	query := fmt.Sprintf("?start_ts=%d&end_ts=%d&rollup_span=300s&type=average", start.Unix(), end.Unix())
+
+	rollup := func(metrics ...int) (*http.Response, [][][]float64) {
+		path := "/rollup"
+		for _, m := range metrics {
+			path += fmt.Sprintf("/%s/metric%d", []string{testUUID1, testUUID2, testUUID3}[m-1], m)
+		}
+		r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1"+path+query, nil)
+		r = r.WithContext(ctx)
+		w := httptest.NewRecorder()
+		client.RollupHandler(w, r)
+		resp := w.Result()
+		var data [][][]float64
+		b, _ := ioutil.ReadAll(resp.Body)
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Errorf("unexpected response %s: %v", string(b), err)
+		}
+		return resp, data
+	}
+
+	check := func(data [][][]float64, metrics ...int) {
+		if len(data) != len(metrics) {
+			t.Fatalf("expected %d metrics got %d", len(metrics), len(data))
+		}
+		for i, m := range metrics {
+			if len(data[i]) == 0 {
+				t.Errorf("expected data for metric%d", m)
+			}
+			for _, dp := range data[i] {
+				if dp[1] != float64(m) {
+					t.Errorf("expected value %d got %f", m, dp[1])
+				}
+			}
+		}
+	}
+
+	resp, data := rollup(1, 2)
+	if v := resp.Header.Get(headers.NameTricksterQuerySplit); v != "split; subqueries=2" {
+		t.Errorf("unexpected query split header %s", v)
+	}
+	check(data, 1, 2)
+
+	// adding a metric to the batch fetches only the added metric, and the order is preserved
+	resp, data = rollup(3, 1, 2)
+	if v := resp.Header.Get(headers.NameTricksterQuerySplit); v != "split; subqueries=3" {
+		t.Errorf("unexpected query split header %s", v)
+	}
+	check(data, 3, 1, 2)
+	for _, m := range []string{testUUID1 + "/metric1", testUUID2 + "/metric2", testUUID3 + "/metric3"} {
+		if n := rs.requests["/rollup/"+m]; n != 1 {
+			t.Errorf("expected %d upstream requests for %s got %d", 1, m, n)
+		}
+	}
+
+	// batches beyond the limit are cached whole
+	rsc.PathConfig.SplitQueriesLimit = 2
+	resp, data = rollup(1, 2, 3)
+	if v := resp.Header.Get(headers.NameTricksterQuerySplit); v != "whole" {
+		t.Errorf("unexpected query split header %s", v)
+	}
+	check(data, 1, 2, 3)
+	resp, data = rollup(1, 2, 3)
+	if v := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(v, "status=hit") {
+		t.Errorf("expected hit got %s", v)
+	}
+	check(data, 1, 2, 3)
+}
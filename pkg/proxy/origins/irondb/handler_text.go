@@ -17,17 +17,15 @@
 package irondb
 
 import (
-	"io"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/key"
 	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
-	"github.com/tricksterproxy/trickster/pkg/util/md5"
 )
 
 // TextHandler handles requests for text timeseries data and processes them
@@ -83,17 +81,12 @@ func (c *Client) textHandlerParseTimeRangeQuery(
 	return trq, nil
 }
 
-// textHandlerDeriveCacheKey calculates a query-specific keyname based on the
-// user request.
-func (c Client) textHandlerDeriveCacheKey(path string, params url.Values,
-	headers http.Header, body io.ReadCloser, extra string) (string, io.ReadCloser) {
-	var sb strings.Builder
-	sb.WriteString(path)
+// textHandlerKeyInputs provides the cache key inputs of the user request,
+// which are its metric, without its time range.
+func (c Client) textHandlerKeyInputs(path string, body []byte,
+	ki *key.Inputs) {
 	ps := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 5)
 	if len(ps) >= 5 || ps[0] == "read" {
-		sb.WriteString("/read/" + strings.Join(ps[3:], "/"))
+		ki.Extras = append(ki.Extras, "/read/"+strings.Join(ps[3:], "/"))
 	}
-
-	sb.WriteString(extra)
-	return md5.Checksum(sb.String()), body
 }
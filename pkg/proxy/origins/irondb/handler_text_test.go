@@ -23,6 +23,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/key"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
@@ -64,18 +65,15 @@ func TestTextHandler(t *testing.T) {
 	}
 }
 
-func TestTextHandlerDeriveCacheKey(t *testing.T) {
+func TestTextHandlerKeyInputs(t *testing.T) {
 
 	client := &Client{name: "test"}
 	path := "/read/0/900/00112233-4455-6677-8899-aabbccddeeff/metric"
-	_, _, r, _, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil, "irondb", path, "debug")
-	if err != nil {
-		t.Error(err)
-	}
 
 	const expected = "a506d1700414b1d0ac15340bd619fdab"
-	result, _ := client.textHandlerDeriveCacheKey(path, r.URL.Query(), r.Header, r.Body, "extra")
-	if result != expected {
+	ki := &key.Inputs{URLPath: path, Extra: "extra"}
+	client.textHandlerKeyInputs(path, nil, ki)
+	if result := ki.Derive(); result != expected {
 		t.Errorf("expected %s got %s", expected, result)
 	}
 
@@ -23,10 +23,12 @@ import (
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/proxy"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 )
 
 var _ origins.Client = (*Client)(nil)
@@ -78,9 +80,6 @@ type Client struct {
 	handlers           map[string]http.Handler
 	handlersRegistered bool
 	baseUpstreamURL    *url.URL
-	healthURL          *url.URL
-	healthHeaders      http.Header
-	healthMethod       string
 	trqParsers         map[string]trqParser
 	extentSetters      map[string]extentSetter
 	router             http.Handler
@@ -98,6 +97,16 @@ func NewClient(name string, oc *oo.Options, router http.Handler,
 	return client, err
 }
 
+func init() {
+	origins.MustRegisterProvider("irondb", newProviderClient)
+}
+
+// newProviderClient is the origins.ProviderFunc for the irondb origin type
+func newProviderClient(name string, oc *oo.Options, router http.Handler, c cache.Cache,
+	_ origins.Origins, _ *tl.Logger) (origins.Client, error) {
+	return NewClient(name, oc, router, c)
+}
+
 func (c *Client) makeTrqParsers() {
 	c.trqParsers = map[string]trqParser{
 		"RawHandler":       c.rawHandlerParseTimeRangeQuery,
@@ -149,3 +158,19 @@ func (c *Client) SetCache(cc cache.Cache) {
 func (c *Client) Router() http.Handler {
 	return c.router
 }
+
+// ImportantParams returns the query parameters that are semantically important to IRONdb.
+// IRONdb does not document its handling of duplicated parameters, so the first occurrence is
+// used, consistent with the other origin types
+func (c *Client) ImportantParams() ([]string, bool) {
+	return []string{upQuery, upStart, upEnd, upSpan, upType, upCAQLQuery,
+		upCAQLStart, upCAQLEnd, upCAQLPeriod}, false
+}
+
+// DefaultAuthCachePolicy returns the policy for caching responses to authenticated requests when a
+// path does not configure one. IRONdb is authenticated by the API in front of it, whose tokens
+// are scoped to accounts that Trickster cannot see, so results are cached per identity rather
+// than served to requestors whose tokens the API may not accept
+func (c *Client) DefaultAuthCachePolicy() authcache.Policy {
+	return authcache.PolicyPerIdentity
+}
@@ -17,10 +17,12 @@
 package irondb
 
 import (
+	"reflect"
 	"testing"
 
 	cr "github.com/tricksterproxy/trickster/pkg/cache/registration"
 	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
@@ -114,6 +116,26 @@ func TestName(t *testing.T) {
 	}
 }
 
+func TestImportantParams(t *testing.T) {
+	// IRONdb does not document its convention, so the first occurrence is used
+	client := &Client{}
+	names, useLast := client.ImportantParams()
+	expected := []string{"query", "start_ts", "end_ts", "rollup_span", "type", "q", "start", "end", "period"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v got %v", expected, names)
+	}
+	if useLast {
+		t.Error("expected first occurrence to be honored")
+	}
+}
+
+func TestDefaultAuthCachePolicy(t *testing.T) {
+	client := &Client{}
+	if p := client.DefaultAuthCachePolicy(); p != authcache.PolicyPerIdentity {
+		t.Errorf("expected %s got %s", authcache.PolicyPerIdentity, p)
+	}
+}
+
 func TestRouter(t *testing.T) {
 	client := Client{name: "TEST"}
 	r := client.Router()
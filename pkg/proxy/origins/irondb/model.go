@@ -18,6 +18,7 @@ package irondb
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"strconv"
@@ -25,6 +26,8 @@ import (
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	terr "github.com/tricksterproxy/trickster/pkg/util/errors"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 )
 
 // SeriesEnvelope values represent a time series data response from the
@@ -231,7 +234,7 @@ func (se *SeriesEnvelope) Clone() timeseries.Timeseries {
 	b := &SeriesEnvelope{
 		Data:         make([]DataPoint, len(se.Data)),
 		StepDuration: se.StepDuration,
-		ExtentList:   make(timeseries.ExtentList, 0, len(se.ExtentList)),
+		ExtentList:   make(timeseries.ExtentList, len(se.ExtentList)),
 	}
 
 	copy(b.ExtentList, se.ExtentList)
@@ -342,12 +345,35 @@ func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries,
 		`"version":"DF4"`) {
 		se := &DF4SeriesEnvelope{}
 		err := json.Unmarshal(data, &se)
-		return se, err
+		return se, unmarshalError(err, "df4", data)
+	}
+
+	if isRollupBatch(data) {
+		rb := &RollupBatch{}
+		err := json.Unmarshal(data, &rb)
+		return rb, unmarshalError(err, "rollup", data)
 	}
 
 	se := &SeriesEnvelope{}
 	err := json.Unmarshal(data, &se)
-	return se, err
+	return se, unmarshalError(err, "series", data)
+}
+
+// unmarshalError wraps an error unmarshaling a timeseries of the format with the detail of its
+// log event, which includes the byte offset at which a JSON syntax or type error occurred
+func unmarshalError(err error, format string, data []byte) error {
+	if err == nil {
+		return nil
+	}
+	pairs := tl.Pairs{"irondbFormat": format, "bodyLength": len(data)}
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &se) {
+		pairs["byteOffset"] = se.Offset
+	} else if errors.As(err, &te) {
+		pairs["byteOffset"] = te.Offset
+	}
+	return terr.Wrap(err, "irondb timeseries unmarshaling failed", pairs)
 }
 
 // UnmarshalInstantaneous is not used for IRONdb origins and is here to conform
@@ -0,0 +1,277 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package irondb
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// RollupBatch values represent the response to a batched rollup request, which
+// is an array holding the rollup data of each requested metric, in the order
+// the metrics were requested.
+type RollupBatch struct {
+	Series       []*SeriesEnvelope
+	ExtentList   timeseries.ExtentList
+	StepDuration time.Duration
+}
+
+// rollupBatchCache is the form in which a RollupBatch is cached.
+type rollupBatchCache struct {
+	Batch        []DataPoints          `json:"batch"`
+	ExtentList   timeseries.ExtentList `json:"extents,omitempty"`
+	StepDuration string                `json:"step,omitempty"`
+}
+
+// isRollupBatch returns true if the JSON byte slice is a RollupBatch, either
+// in its cached form or as an array of rollup data arrays.
+func isRollupBatch(b []byte) bool {
+	b = bytes.Join(bytes.Fields(b), nil)
+	return bytes.HasPrefix(b, []byte(`{"batch":`)) ||
+		bytes.HasPrefix(b, []byte("[[[")) || bytes.HasPrefix(b, []byte("[[]"))
+}
+
+// MarshalJSON encodes a rollup batch value into a JSON byte slice.
+func (rb *RollupBatch) MarshalJSON() ([]byte, error) {
+	data := make([]DataPoints, len(rb.Series))
+	for i, se := range rb.Series {
+		data[i] = se.Data
+		if data[i] == nil {
+			data[i] = DataPoints{}
+		}
+	}
+
+	if rb.StepDuration == 0 && len(rb.ExtentList) == 0 {
+		// Special case for when returning data to the caller.
+		return json.Marshal(data)
+	}
+
+	rbc := rollupBatchCache{Batch: data, ExtentList: rb.ExtentList}
+	if rb.StepDuration != 0 {
+		rbc.StepDuration = rb.StepDuration.String()
+	}
+
+	return json.Marshal(rbc)
+}
+
+// UnmarshalJSON decodes a JSON byte slice into this rollup batch value.
+func (rb *RollupBatch) UnmarshalJSON(b []byte) error {
+	var data []DataPoints
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		var rbc rollupBatchCache
+		if err := json.Unmarshal(b, &rbc); err != nil {
+			return err
+		}
+
+		data = rbc.Batch
+		rb.ExtentList = rbc.ExtentList
+		if rbc.StepDuration != "" {
+			d, err := time.ParseDuration(rbc.StepDuration)
+			if err != nil {
+				return err
+			}
+
+			rb.StepDuration = d
+		}
+	} else if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+
+	rb.Series = make([]*SeriesEnvelope, len(data))
+	for i, dps := range data {
+		rb.Series[i] = &SeriesEnvelope{Data: dps, StepDuration: rb.StepDuration,
+			ExtentList: rb.ExtentList.Clone()}
+	}
+
+	return nil
+}
+
+// Step returns the step for the Timeseries.
+func (rb *RollupBatch) Step() time.Duration {
+	return rb.StepDuration
+}
+
+// SetStep sets the step for the Timeseries.
+func (rb *RollupBatch) SetStep(step time.Duration) {
+	rb.StepDuration = step
+	for _, se := range rb.Series {
+		se.SetStep(step)
+	}
+}
+
+// Extents returns the Timeseries's extent list.
+func (rb *RollupBatch) Extents() timeseries.ExtentList {
+	return rb.ExtentList
+}
+
+// SetExtents overwrites a Timeseries's known extents with the provided extent
+// list.
+func (rb *RollupBatch) SetExtents(extents timeseries.ExtentList) {
+	rb.ExtentList = extents
+	for _, se := range rb.Series {
+		se.SetExtents(extents.Clone())
+	}
+}
+
+// SeriesCount returns the number of individual series in the Timeseries value.
+func (rb *RollupBatch) SeriesCount() int {
+	return len(rb.Series)
+}
+
+// ValueCount returns the count of all data values across all Series in the
+// Timeseries value.
+func (rb *RollupBatch) ValueCount() int {
+	n := 0
+	for _, se := range rb.Series {
+		n += se.ValueCount()
+	}
+
+	return n
+}
+
+// timestamps returns the unique timestamps across the timeseries.
+func (rb *RollupBatch) timestamps() map[int64]struct{} {
+	ts := map[int64]struct{}{}
+	for _, se := range rb.Series {
+		for _, dp := range se.Data {
+			ts[dp.Time.Unix()] = struct{}{}
+		}
+	}
+
+	return ts
+}
+
+// TimestampCount returns the number of unique timestamps across the timeseries.
+func (rb *RollupBatch) TimestampCount() int {
+	return len(rb.timestamps())
+}
+
+// Merge merges the provided Timeseries list into the base Timeseries (in the
+// order provided) and optionally sorts the merged Timeseries. Only batches of
+// the same metrics are merged.
+func (rb *RollupBatch) Merge(sort bool,
+	collection ...timeseries.Timeseries) {
+	for _, ts := range collection {
+		if ts != nil {
+			if rb2, ok := ts.(*RollupBatch); ok && len(rb2.Series) == len(rb.Series) {
+				for i, se := range rb.Series {
+					se.Merge(false, rb2.Series[i])
+				}
+
+				rb.ExtentList = append(rb.ExtentList, rb2.ExtentList...)
+			}
+		}
+	}
+
+	rb.ExtentList = rb.ExtentList.Compress(rb.StepDuration)
+	for _, se := range rb.Series {
+		se.ExtentList = rb.ExtentList.Clone()
+	}
+
+	if sort {
+		rb.Sort()
+	}
+}
+
+// Clone returns a perfect copy of the base Timeseries.
+func (rb *RollupBatch) Clone() timeseries.Timeseries {
+	b := &RollupBatch{
+		Series:       make([]*SeriesEnvelope, len(rb.Series)),
+		StepDuration: rb.StepDuration,
+		ExtentList:   rb.ExtentList.Clone(),
+	}
+
+	for i, se := range rb.Series {
+		b.Series[i] = se.Clone().(*SeriesEnvelope)
+	}
+
+	return b
+}
+
+// CropToRange crops down a Timeseries value to the provided Extent.
+// Crop assumes the base Timeseries is already sorted, and will corrupt an
+// unsorted Timeseries.
+func (rb *RollupBatch) CropToRange(e timeseries.Extent) {
+	for _, se := range rb.Series {
+		se.CropToRange(e)
+	}
+
+	rb.ExtentList = rb.ExtentList.Crop(e)
+}
+
+// CropToSize reduces the number of unique timestamps in the Timeseries to the
+// provided count, by evicting the oldest timestamps of every metric. Any
+// timestamps newer than the provided time are removed before sizing, in order
+// to support backfill tolerance.
+func (rb *RollupBatch) CropToSize(sz int, t time.Time,
+	lur timeseries.Extent) {
+	// The Series has no extents, so no need to do anything.
+	if len(rb.ExtentList) < 1 {
+		for _, se := range rb.Series {
+			se.Data = DataPoints{}
+		}
+
+		rb.SetExtents(timeseries.ExtentList{})
+		return
+	}
+
+	// Crop to the Backfill Tolerance Value if needed.
+	if rb.ExtentList[len(rb.ExtentList)-1].End.After(t) {
+		rb.CropToRange(timeseries.Extent{Start: rb.ExtentList[0].Start, End: t})
+	}
+
+	ts := rb.timestamps()
+	if len(ts) == 0 || len(ts) <= sz {
+		return
+	}
+
+	tsl := make([]int64, 0, len(ts))
+	for k := range ts {
+		tsl = append(tsl, k)
+	}
+
+	sort.Slice(tsl, func(i, j int) bool { return tsl[i] < tsl[j] })
+	tsl = tsl[len(tsl)-sz:]
+	e := timeseries.Extent{Start: time.Unix(tsl[0], 0), End: time.Unix(tsl[len(tsl)-1], 0)}
+	for _, se := range rb.Series {
+		se.CropToRange(e)
+	}
+
+	rb.SetExtents(timeseries.ExtentList{e})
+}
+
+// Sort sorts all data in the Timeseries chronologically by their timestamp.
+func (rb *RollupBatch) Sort() {
+	for _, se := range rb.Series {
+		se.Sort()
+	}
+}
+
+// Size returns the approximate memory utilization in bytes of the timeseries
+func (rb *RollupBatch) Size() int {
+	c := (len(rb.ExtentList) * 72) + // time.Time (24) * 3
+		24 // .StepDuration
+	for _, se := range rb.Series {
+		c += se.Size()
+	}
+
+	return c
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package irondb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestRollupBatch(t *testing.T) {
+
+	client := &Client{}
+	ts, err := client.UnmarshalTimeseries([]byte(`[[[300,1],[600,1]], [], [[300,3]]]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb, ok := ts.(*RollupBatch)
+	if !ok {
+		t.Fatalf("expected *RollupBatch got %T", ts)
+	}
+	if rb.SeriesCount() != 3 || rb.ValueCount() != 3 || rb.TimestampCount() != 2 {
+		t.Errorf("unexpected counts %d %d %d", rb.SeriesCount(), rb.ValueCount(), rb.TimestampCount())
+	}
+
+	step := 300 * time.Second
+	rb.SetStep(step)
+	rb.SetExtents(timeseries.ExtentList{{Start: time.Unix(300, 0), End: time.Unix(600, 0)}})
+
+	// the cached form retains the step and extents
+	b, err := client.MarshalTimeseries(rb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err = client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb2, ok := ts.(*RollupBatch)
+	if !ok {
+		t.Fatalf("expected *RollupBatch got %T", ts)
+	}
+	if rb2.Step() != step || len(rb2.Extents()) != 1 || rb2.ValueCount() != 3 {
+		t.Errorf("unexpected batch %s", string(b))
+	}
+
+	// merging appends the data of each metric
+	rb3 := &RollupBatch{Series: []*SeriesEnvelope{
+		{Data: DataPoints{{Time: time.Unix(900, 0), Value: 1}}}, {}, {}}}
+	rb3.SetStep(step)
+	rb3.SetExtents(timeseries.ExtentList{{Start: time.Unix(900, 0), End: time.Unix(900, 0)}})
+	rb2.Merge(true, rb3)
+	if rb2.ValueCount() != 4 || len(rb2.Extents()) != 1 || !rb2.Extents()[0].End.Equal(time.Unix(900, 0)) {
+		t.Errorf("unexpected merge %v %d", rb2.Extents(), rb2.ValueCount())
+	}
+
+	// batches of other metrics are not merged
+	rb2.Merge(true, &RollupBatch{Series: []*SeriesEnvelope{{}}})
+	if rb2.ValueCount() != 4 {
+		t.Errorf("expected %d got %d", 4, rb2.ValueCount())
+	}
+
+	rb2.CropToSize(2, time.Unix(900, 0), timeseries.Extent{})
+	if rb2.TimestampCount() != 2 || !rb2.Extents()[0].Start.Equal(time.Unix(600, 0)) {
+		t.Errorf("unexpected crop %v %d", rb2.Extents(), rb2.TimestampCount())
+	}
+
+	c := rb2.Clone().(*RollupBatch)
+	c.CropToRange(timeseries.Extent{Start: time.Unix(900, 0), End: time.Unix(900, 0)})
+	if c.ValueCount() != 1 || rb2.ValueCount() != 2 {
+		t.Errorf("unexpected crop %d %d", c.ValueCount(), rb2.ValueCount())
+	}
+
+	// the response to the client is the array of each metric's data
+	c.SetExtents(nil)
+	c.SetStep(0)
+	b, _ = client.MarshalTimeseries(c)
+	if string(b) != `[[[900,1]],[],[]]` {
+		t.Errorf("unexpected response %s", string(b))
+	}
+}
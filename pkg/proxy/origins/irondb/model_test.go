@@ -17,10 +17,13 @@
 package irondb
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	terr "github.com/tricksterproxy/trickster/pkg/util/errors"
 )
 
 const testResponse = `[
@@ -139,6 +142,19 @@ func TestSeriesEnvelopeExtents(t *testing.T) {
 	}
 }
 
+func TestUnmarshalTimeseriesError(t *testing.T) {
+	client := &Client{}
+	_, err := client.UnmarshalTimeseries([]byte(`[[600.000,1.75],[0,1}`))
+	var de *terr.DetailedError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected DetailedError got %v", err)
+	}
+	if de.Pairs["irondbFormat"] != "series" || de.Pairs["bodyLength"] != 21 ||
+		de.Pairs["byteOffset"] != int64(21) {
+		t.Errorf("unexpected pairs %v", de.Pairs)
+	}
+}
+
 func TestSeriesEnvelopeSeriesCount(t *testing.T) {
 	client := &Client{}
 	ts, err := client.UnmarshalTimeseries([]byte(testResponse))
@@ -260,6 +276,12 @@ func TestSeriesEnvelopeClone(t *testing.T) {
 	if string(s1) != string(s2) {
 		t.Errorf("Expected %s = %s", string(s1), string(s2))
 	}
+
+	// the extents are cloned
+	se.ExtentList = timeseries.ExtentList{{Start: time.Unix(0, 0), End: time.Unix(300, 0)}}
+	if el := se.Clone().Extents(); !reflect.DeepEqual(el, se.ExtentList) {
+		t.Errorf("expected %s got %s", se.ExtentList, el)
+	}
 }
 
 func TestSeriesEnvelopeCropToRange(t *testing.T) {
@@ -40,6 +40,18 @@ func (c *Client) registerHandlers() {
 	c.handlers[mnState] = http.HandlerFunc(c.StateHandler)
 	c.handlers[mnCAQL] = http.HandlerFunc(c.CAQLHandler)
 	c.handlers["proxy"] = http.HandlerFunc(c.ProxyHandler)
+	// The default paths reference their handlers by these names, which also
+	// select the time range query parser and extent setter for the path
+	c.handlers["RawHandler"] = c.handlers[mnRaw]
+	c.handlers["RollupHandler"] = c.handlers[mnRollup]
+	c.handlers["FetchHandler"] = c.handlers[mnFetch]
+	c.handlers["TextHandler"] = c.handlers[mnRead]
+	c.handlers["HistogramHandler"] = c.handlers[mnHistogram]
+	c.handlers["FindHandler"] = c.handlers[mnFind]
+	c.handlers["StateHandler"] = c.handlers[mnState]
+	c.handlers["CAQLHandler"] = c.handlers[mnCAQL]
+	c.handlers["CAQLPubHandler"] = c.handlers[mnCAQL]
+	c.handlers["ProxyHandler"] = c.handlers["proxy"]
 }
 
 // Handlers returns a map of the HTTP Handlers the client has registered
@@ -50,23 +62,9 @@ func (c *Client) Handlers() map[string]http.Handler {
 	return c.handlers
 }
 
-func populateHeathCheckRequestValues(oc *oo.Options) {
-	if oc.HealthCheckUpstreamPath == "-" {
-		oc.HealthCheckUpstreamPath = "/" + mnState
-	}
-	if oc.HealthCheckVerb == "-" {
-		oc.HealthCheckVerb = http.MethodGet
-	}
-	if oc.HealthCheckQuery == "-" {
-		oc.HealthCheckQuery = ""
-	}
-}
-
 // DefaultPathConfigs returns the default PathConfigs for the given OriginType
 func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 
-	populateHeathCheckRequestValues(oc)
-
 	paths := map[string]*po.Options{
 
 		"/" + mnRaw + "/": {
@@ -87,12 +85,14 @@ func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 			CacheKeyHeaders: []string{},
 			MatchType:       matching.PathMatchTypePrefix,
 			MatchTypeName:   "prefix",
+			// batched rollup requests are split into per-metric requests
+			SplitQueries: true,
 		},
 
 		"/" + mnFetch: {
 			Path:            "/" + mnFetch,
 			HandlerName:     "FetchHandler",
-			KeyHasher:       []key.HasherFunc{c.fetchHandlerDeriveCacheKey},
+			KeyProvider:     []key.ProviderFunc{c.fetchHandlerKeyInputs},
 			Methods:         []string{http.MethodPost},
 			CacheKeyParams:  []string{},
 			CacheKeyHeaders: []string{},
@@ -103,7 +103,7 @@ func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 		"/" + mnRead + "/": {
 			Path:            "/" + mnRead + "/",
 			HandlerName:     "TextHandler",
-			KeyHasher:       []key.HasherFunc{c.textHandlerDeriveCacheKey},
+			KeyProvider:     []key.ProviderFunc{c.textHandlerKeyInputs},
 			Methods:         []string{http.MethodGet},
 			CacheKeyParams:  []string{"*"},
 			CacheKeyHeaders: []string{},
@@ -115,7 +115,7 @@ func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 			Path:            "/" + mnHistogram + "/",
 			HandlerName:     "HistogramHandler",
 			Methods:         []string{http.MethodGet},
-			KeyHasher:       []key.HasherFunc{c.histogramHandlerDeriveCacheKey},
+			KeyProvider:     []key.ProviderFunc{c.histogramHandlerKeyInputs},
 			CacheKeyParams:  []string{},
 			CacheKeyHeaders: []string{},
 			MatchType:       matching.PathMatchTypePrefix,
@@ -19,12 +19,15 @@ package options
 import (
 	"errors"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache/evictionmethods"
 	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
 	rule "github.com/tricksterproxy/trickster/pkg/proxy/origins/rule/options"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight/policy"
+	"github.com/tricksterproxy/trickster/pkg/proxy/ratelimit"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
 	to "github.com/tricksterproxy/trickster/pkg/proxy/tls/options"
 
@@ -47,6 +50,14 @@ type Options struct {
 	OriginURL string `toml:"origin_url"`
 	// TimeoutSecs defines how long the HTTP request will wait for a response before timing out
 	TimeoutSecs int64 `toml:"timeout_secs"`
+	// TimeoutHeader provides the name of a request header in which clients may provide a timeout hint,
+	// which shortens the deadline of upstream requests when it is less than the origin's timeout
+	TimeoutHeader string `toml:"timeout_header"`
+	// ClientTimeoutMS defines the timeout hint that is assumed for requests that provide none (0 = none)
+	ClientTimeoutMS int `toml:"client_timeout_ms"`
+	// TimeoutMarginMS defines how much the remaining timeout budget is reduced, to account for
+	// Trickster's own overhead, when it is forwarded to origins that accept a timeout parameter
+	TimeoutMarginMS int `toml:"timeout_margin_ms"`
 	// KeepAliveTimeoutSecs defines how long an open keep-alive HTTP connection remains idle before closing
 	KeepAliveTimeoutSecs int64 `toml:"keep_alive_timeout_secs"`
 	// MaxIdleConns defines maximum number of open keep-alive connections to maintain
@@ -63,6 +74,32 @@ type Options struct {
 	HealthCheckQuery string `toml:"health_check_query"`
 	// HealthCheckHeaders provides the HTTP Headers to apply when making an upstream health check
 	HealthCheckHeaders map[string]string `toml:"health_check_headers"`
+	// HealthCheckBody provides the HTTP request body to send when making an upstream health check
+	HealthCheckBody string `toml:"health_check_body"`
+	// HealthCheckExpectedCodes provides the list of HTTP status codes that indicate a healthy upstream
+	HealthCheckExpectedCodes []int `toml:"health_check_expected_codes"`
+	// HealthCheckExpectedBody provides a substring that must be present in a healthy response body
+	HealthCheckExpectedBody string `toml:"health_check_expected_body"`
+	// HealthCheckExpectedBodyRegex provides a regular expression that a healthy response body must match
+	HealthCheckExpectedBodyRegex string `toml:"health_check_expected_body_regex"`
+	// HealthCheckTimeoutMS defines how long an upstream health check will wait for a response
+	HealthCheckTimeoutMS int `toml:"health_check_timeout_ms"`
+	// HealthCheckIntervalMS defines how often the background prober checks the upstream (0 = disabled)
+	HealthCheckIntervalMS int `toml:"health_check_interval_ms"`
+	// HealthCheckAllowGetBody, when true, permits a health check request body with the GET or HEAD verbs
+	HealthCheckAllowGetBody bool `toml:"health_check_allow_get_body"`
+	// PreflightPolicyName specifies how a failed startup probe of the upstream is handled ("required", "warn", "ignore")
+	PreflightPolicyName string `toml:"preflight_policy"`
+	// BootstrapPeerURL provides the base URL of a warm peer Trickster for this origin. When set, cache
+	// misses are filled from the peer instead of the origin for BootstrapWindowSecs after startup
+	BootstrapPeerURL string `toml:"bootstrap_peer_url"`
+	// BootstrapWindowSecs defines how long after startup cache misses are filled from the bootstrap peer
+	BootstrapWindowSecs int `toml:"bootstrap_window_secs"`
+	// ClockSkewWarnSecs defines the estimated origin clock skew, in either direction, above which a warning is logged
+	ClockSkewWarnSecs int `toml:"clock_skew_warn_secs"`
+	// ClockSkewCorrection, when true, applies the estimated origin clock skew when determining which
+	// leading-edge timeseries data is too new to cache
+	ClockSkewCorrection bool `toml:"clock_skew_correction"`
 	// Object Proxy Cache and Delta Proxy Cache Configurations
 	// TimeseriesRetentionFactor limits the maximum the number of chronological
 	// timestamps worth of data to store in cache for each query
@@ -78,6 +115,14 @@ type Options struct {
 	Paths map[string]*po.Options `toml:"paths"`
 	// NegativeCacheName provides the name of the Negative Cache Config to be used by this Origin
 	NegativeCacheName string `toml:"negative_cache_name"`
+	// NegativeCacheAllowRevalidate, when true, permits clients to bypass reads from the Negative Cache
+	// by including the X-Trickster-Revalidate request header. The fresh response still updates the cache.
+	NegativeCacheAllowRevalidate bool `toml:"negative_cache_allow_revalidate"`
+	// NegativeCacheBackoffMultiplier, when greater than 1, extends the TTL of a Negative Cache entry by
+	// this factor each time its refresh is answered by another negative response
+	NegativeCacheBackoffMultiplier float64 `toml:"negative_cache_backoff_multiplier"`
+	// NegativeCacheBackoffMaxTTLSecs caps the TTL of Negative Cache entries extended by the backoff
+	NegativeCacheBackoffMaxTTLSecs int `toml:"negative_cache_backoff_max_ttl_secs"`
 	// TimeseriesTTLSecs specifies the cache TTL of timeseries objects
 	TimeseriesTTLSecs int `toml:"timeseries_ttl_secs"`
 	// TimeseriesTTLSecs specifies the cache TTL of fast forward data
@@ -111,6 +156,12 @@ type Options struct {
 	IsDefault bool `toml:"is_default"`
 	// FastForwardDisable indicates whether the FastForward feature should be disabled for this origin
 	FastForwardDisable bool `toml:"fast_forward_disable"`
+	// ReuseCoarserStep, when true, permits the Delta Proxy Cache to fulfill a request at a given step
+	// from a cached timeseries at a finer step that evenly divides it, by sampling the cached timeseries
+	ReuseCoarserStep bool `toml:"reuse_coarser_step"`
+	// LocalBuildInfo, when true, instructs Trickster to answer Prometheus build information requests locally,
+	// with the origin's build information as last fetched through the cache
+	LocalBuildInfo bool `toml:"local_buildinfo"`
 	// PathRoutingDisabled, when true, will bypass /originName/path route registrations
 	PathRoutingDisabled bool `toml:"path_routing_disabled"`
 	// RequireTLS, when true, indicates this Origin Config's paths must only be registered with the TLS Router
@@ -123,6 +174,54 @@ type Options struct {
 	// expects a multipart response	// this optimizes Trickster to request as few bytes as possible when
 	// fronting origins that only support single range requests
 	DearticulateUpstreamRanges bool `toml:"dearticulate_upstream_ranges"`
+	// RejectDuplicateParams, when true, indicates that requests with more than one occurrence of a
+	// semantically important query parameter (e.g., start) are rejected with a 400 Bad Request, rather
+	// than collapsed to the single occurrence that the origin would honor
+	RejectDuplicateParams bool `toml:"reject_duplicate_params"`
+	// FingerprintStripValues, when true, indicates that the values of label or tag matchers are removed
+	// from queries when they are normalized for fingerprinting, retaining only the matched label names
+	FingerprintStripValues bool `toml:"fingerprint_strip_values"`
+	// SlowQueryThresholdMS is the duration of a timeseries request above which it is logged as a
+	// slow query, with its fingerprint (0 = disabled)
+	SlowQueryThresholdMS int `toml:"slow_query_threshold_ms"`
+	// LookbackDeltaMS is the origin's lookback delta for instant vector selectors, by which upstream
+	// requests for the Delta Proxy Cache are extended so that the points at their edges are complete
+	LookbackDeltaMS int `toml:"lookback_delta_ms"`
+	// RateLimitRemainingHeader is the name of the origin response header reporting the number of requests
+	// remaining in the origin's rate limit window. Upstream requests are paced only when it is set
+	RateLimitRemainingHeader string `toml:"rate_limit_remaining_header"`
+	// RateLimitResetHeader is the name of the origin response header reporting when the origin's
+	// rate limit window resets
+	RateLimitResetHeader string `toml:"rate_limit_reset_header"`
+	// RateLimitMinRemaining is the estimate of the remaining rate limit capacity at or below which
+	// upstream requests are paced until the window resets
+	RateLimitMinRemaining int `toml:"rate_limit_min_remaining"`
+	// RateLimitModeName specifies how upstream requests are paced ("queue", "reject")
+	RateLimitModeName string `toml:"rate_limit_mode"`
+	// RateLimitMaxWaitMS is the longest an upstream request is queued for the rate limit window to reset,
+	// beyond which it is rejected
+	RateLimitMaxWaitMS int `toml:"rate_limit_max_wait_ms"`
+	// RateLimitServeStale, when true, indicates that expired cache objects are served without
+	// revalidation while upstream requests are paced
+	RateLimitServeStale bool `toml:"rate_limit_serve_stale"`
+	// MaxConcurrentRequests is the maximum number of concurrent upstream requests to the origin, beyond
+	// which upstream requests wait to be admitted in order of their priority class (0 = unlimited)
+	MaxConcurrentRequests int `toml:"max_concurrent_requests"`
+	// ConcurrencyReservedShare is the share of MaxConcurrentRequests, between 0 and 1, that is reserved
+	// for upstream requests of the high priority class
+	ConcurrencyReservedShare float64 `toml:"concurrency_reserved_share"`
+	// ConcurrencyMaxWaitMS is the longest an upstream request waits to be admitted under
+	// MaxConcurrentRequests, beyond which it is rejected
+	ConcurrencyMaxWaitMS int `toml:"concurrency_max_wait_ms"`
+	// TimeZoneName is the IANA name of the time zone in which the origin aligns calendar groupings,
+	// such as toStartOfDay(), for queries that do not specify one (e.g., "America/New_York")
+	TimeZoneName string `toml:"timezone"`
+	// RecordDir, when set, is a directory to which each upstream request and response is written as
+	// a fixture file, with credentials removed. For debugging and test authoring only
+	RecordDir string `toml:"record_dir"`
+	// ReplayDir, when set, is a directory of fixture files from which upstream requests are served
+	// instead of the origin. Requests without a matching fixture fail. For debugging and testing only
+	ReplayDir string `toml:"replay_dir"`
 
 	// Synthesized Configurations
 	// These configurations are parsed versions of those defined above, and are what Trickster uses internally
@@ -133,6 +232,24 @@ type Options struct {
 	Router *mux.Router `toml:"-"`
 	// Timeout is the time.Duration representation of TimeoutSecs
 	Timeout time.Duration `toml:"-"`
+	// ClientTimeout is the parsed value of ClientTimeoutMS
+	ClientTimeout time.Duration `toml:"-"`
+	// TimeoutMargin is the parsed value of TimeoutMarginMS
+	TimeoutMargin time.Duration `toml:"-"`
+	// SlowQueryThreshold is the parsed value of SlowQueryThresholdMS
+	SlowQueryThreshold time.Duration `toml:"-"`
+	// LookbackDelta is the parsed value of LookbackDeltaMS
+	LookbackDelta time.Duration `toml:"-"`
+	// RateLimitMode is the parsed value of RateLimitModeName
+	RateLimitMode ratelimit.Mode `toml:"-"`
+	// PreflightPolicy is the parsed value of PreflightPolicyName
+	PreflightPolicy policy.Policy `toml:"-"`
+	// RateLimitMaxWait is the parsed value of RateLimitMaxWaitMS
+	RateLimitMaxWait time.Duration `toml:"-"`
+	// ConcurrencyMaxWait is the parsed value of ConcurrencyMaxWaitMS
+	ConcurrencyMaxWait time.Duration `toml:"-"`
+	// TimeZone is the parsed value of TimeZoneName, and is nil when it is not set
+	TimeZone *time.Location `toml:"-"`
 	// BackfillTolerance is the time.Duration representation of BackfillToleranceSecs
 	BackfillTolerance time.Duration `toml:"-"`
 	// ValueRetention is the time.Duration representation of ValueRetentionSecs
@@ -158,6 +275,14 @@ type Options struct {
 	FastForwardTTL time.Duration `toml:"-"`
 	// FastForwardPath is the paths.Options to use for upstream Fast Forward Requests
 	FastForwardPath *po.Options `toml:"-"`
+	// BootstrapPeer is the parsed value of BootstrapPeerURL
+	BootstrapPeer *url.URL `toml:"-"`
+	// BootstrapWindow is the parsed value of BootstrapWindowSecs
+	BootstrapWindow time.Duration `toml:"-"`
+	// ClockSkewWarnThreshold is the parsed value of ClockSkewWarnSecs
+	ClockSkewWarnThreshold time.Duration `toml:"-"`
+	// NegativeCacheBackoffMaxTTL is the parsed value of NegativeCacheBackoffMaxTTLSecs
+	NegativeCacheBackoffMaxTTL time.Duration `toml:"-"`
 	// MaxTTL is the parsed value of MaxTTLSecs
 	MaxTTL time.Duration `toml:"-"`
 	// HTTPClient is the Client used by trickster to communicate with this origin
@@ -173,37 +298,60 @@ type Options struct {
 // NewOptions will return a pointer to an OriginConfig with the default configuration settings
 func NewOptions() *Options {
 	return &Options{
-		BackfillTolerance:            d.DefaultBackfillToleranceSecs,
-		BackfillToleranceSecs:        d.DefaultBackfillToleranceSecs,
-		CacheKeyPrefix:               "",
-		CacheName:                    d.DefaultOriginCacheName,
-		CompressableTypeList:         d.DefaultCompressableTypes(),
-		FastForwardTTL:               d.DefaultFastForwardTTLSecs * time.Second,
-		FastForwardTTLSecs:           d.DefaultFastForwardTTLSecs,
-		ForwardedHeaders:             d.DefaultForwardedHeaders,
-		HealthCheckHeaders:           make(map[string]string),
-		HealthCheckQuery:             d.DefaultHealthCheckQuery,
-		HealthCheckUpstreamPath:      d.DefaultHealthCheckPath,
-		HealthCheckVerb:              d.DefaultHealthCheckVerb,
-		KeepAliveTimeoutSecs:         d.DefaultKeepAliveTimeoutSecs,
-		MaxIdleConns:                 d.DefaultMaxIdleConns,
-		MaxObjectSizeBytes:           d.DefaultMaxObjectSizeBytes,
-		MaxTTL:                       d.DefaultMaxTTLSecs * time.Second,
-		MaxTTLSecs:                   d.DefaultMaxTTLSecs,
-		NegativeCache:                make(map[int]time.Duration),
-		NegativeCacheName:            d.DefaultOriginNegativeCacheName,
-		Paths:                        make(map[string]*po.Options),
-		RevalidationFactor:           d.DefaultRevalidationFactor,
-		TLS:                          &to.Options{},
-		Timeout:                      time.Second * d.DefaultOriginTimeoutSecs,
-		TimeoutSecs:                  d.DefaultOriginTimeoutSecs,
-		TimeseriesEvictionMethod:     d.DefaultOriginTEM,
-		TimeseriesEvictionMethodName: d.DefaultOriginTEMName,
-		TimeseriesRetention:          d.DefaultOriginTRF,
-		TimeseriesRetentionFactor:    d.DefaultOriginTRF,
-		TimeseriesTTL:                d.DefaultTimeseriesTTLSecs * time.Second,
-		TimeseriesTTLSecs:            d.DefaultTimeseriesTTLSecs,
-		TracingConfigName:            d.DefaultTracingConfigName,
+		BackfillTolerance:              d.DefaultBackfillToleranceSecs,
+		BackfillToleranceSecs:          d.DefaultBackfillToleranceSecs,
+		BootstrapWindowSecs:            d.DefaultBootstrapWindowSecs,
+		BootstrapWindow:                d.DefaultBootstrapWindowSecs * time.Second,
+		CacheKeyPrefix:                 "",
+		CacheName:                      d.DefaultOriginCacheName,
+		ClockSkewWarnSecs:              d.DefaultClockSkewWarnSecs,
+		ClockSkewWarnThreshold:         d.DefaultClockSkewWarnSecs * time.Second,
+		ConcurrencyMaxWait:             d.DefaultConcurrencyMaxWaitMS * time.Millisecond,
+		ConcurrencyMaxWaitMS:           d.DefaultConcurrencyMaxWaitMS,
+		ConcurrencyReservedShare:       d.DefaultConcurrencyReservedShare,
+		CompressableTypeList:           d.DefaultCompressableTypes(),
+		FastForwardTTL:                 d.DefaultFastForwardTTLSecs * time.Second,
+		FastForwardTTLSecs:             d.DefaultFastForwardTTLSecs,
+		ForwardedHeaders:               d.DefaultForwardedHeaders,
+		HealthCheckBody:                d.DefaultHealthCheckBody,
+		HealthCheckHeaders:             make(map[string]string),
+		HealthCheckQuery:               d.DefaultHealthCheckQuery,
+		HealthCheckUpstreamPath:        d.DefaultHealthCheckPath,
+		HealthCheckVerb:                d.DefaultHealthCheckVerb,
+		KeepAliveTimeoutSecs:           d.DefaultKeepAliveTimeoutSecs,
+		LookbackDelta:                  d.DefaultLookbackDeltaMS * time.Millisecond,
+		LookbackDeltaMS:                d.DefaultLookbackDeltaMS,
+		MaxIdleConns:                   d.DefaultMaxIdleConns,
+		MaxObjectSizeBytes:             d.DefaultMaxObjectSizeBytes,
+		MaxTTL:                         d.DefaultMaxTTLSecs * time.Second,
+		MaxTTLSecs:                     d.DefaultMaxTTLSecs,
+		NegativeCache:                  make(map[int]time.Duration),
+		NegativeCacheName:              d.DefaultOriginNegativeCacheName,
+		NegativeCacheBackoffMaxTTL:     d.DefaultNegativeCacheBackoffMaxTTLSecs * time.Second,
+		NegativeCacheBackoffMaxTTLSecs: d.DefaultNegativeCacheBackoffMaxTTLSecs,
+		Paths:                          make(map[string]*po.Options),
+		PreflightPolicy:                d.DefaultPreflightPolicy,
+		PreflightPolicyName:            d.DefaultPreflightPolicyName,
+		RateLimitMaxWait:               d.DefaultRateLimitMaxWaitMS * time.Millisecond,
+		RateLimitMaxWaitMS:             d.DefaultRateLimitMaxWaitMS,
+		RateLimitMinRemaining:          d.DefaultRateLimitMinRemaining,
+		RateLimitMode:                  d.DefaultRateLimitMode,
+		RateLimitModeName:              d.DefaultRateLimitModeName,
+		RateLimitServeStale:            true,
+		RevalidationFactor:             d.DefaultRevalidationFactor,
+		TLS:                            &to.Options{},
+		Timeout:                        time.Second * d.DefaultOriginTimeoutSecs,
+		TimeoutSecs:                    d.DefaultOriginTimeoutSecs,
+		TimeoutHeader:                  d.DefaultTimeoutHeader,
+		TimeoutMargin:                  d.DefaultTimeoutMarginMS * time.Millisecond,
+		TimeoutMarginMS:                d.DefaultTimeoutMarginMS,
+		TimeseriesEvictionMethod:       d.DefaultOriginTEM,
+		TimeseriesEvictionMethodName:   d.DefaultOriginTEMName,
+		TimeseriesRetention:            d.DefaultOriginTRF,
+		TimeseriesRetentionFactor:      d.DefaultOriginTRF,
+		TimeseriesTTL:                  d.DefaultTimeseriesTTLSecs * time.Second,
+		TimeseriesTTLSecs:              d.DefaultTimeseriesTTLSecs,
+		TracingConfigName:              d.DefaultTracingConfigName,
 	}
 }
 
@@ -214,15 +362,35 @@ func (oc *Options) Clone() *Options {
 	o.DearticulateUpstreamRanges = oc.DearticulateUpstreamRanges
 	o.BackfillTolerance = oc.BackfillTolerance
 	o.BackfillToleranceSecs = oc.BackfillToleranceSecs
+	o.BootstrapPeerURL = oc.BootstrapPeerURL
+	o.BootstrapWindowSecs = oc.BootstrapWindowSecs
+	o.BootstrapWindow = oc.BootstrapWindow
+	if oc.BootstrapPeer != nil {
+		u := *oc.BootstrapPeer
+		o.BootstrapPeer = &u
+	}
 	o.CacheName = oc.CacheName
 	o.CacheKeyPrefix = oc.CacheKeyPrefix
+	o.ClockSkewCorrection = oc.ClockSkewCorrection
+	o.ClockSkewWarnSecs = oc.ClockSkewWarnSecs
+	o.ClockSkewWarnThreshold = oc.ClockSkewWarnThreshold
 	o.FastForwardDisable = oc.FastForwardDisable
 	o.FastForwardTTL = oc.FastForwardTTL
 	o.FastForwardTTLSecs = oc.FastForwardTTLSecs
+	o.ReuseCoarserStep = oc.ReuseCoarserStep
+	o.LocalBuildInfo = oc.LocalBuildInfo
 	o.ForwardedHeaders = oc.ForwardedHeaders
 	o.HealthCheckUpstreamPath = oc.HealthCheckUpstreamPath
 	o.HealthCheckVerb = oc.HealthCheckVerb
 	o.HealthCheckQuery = oc.HealthCheckQuery
+	o.HealthCheckBody = oc.HealthCheckBody
+	o.HealthCheckExpectedBody = oc.HealthCheckExpectedBody
+	o.HealthCheckExpectedBodyRegex = oc.HealthCheckExpectedBodyRegex
+	o.HealthCheckTimeoutMS = oc.HealthCheckTimeoutMS
+	o.HealthCheckIntervalMS = oc.HealthCheckIntervalMS
+	o.HealthCheckAllowGetBody = oc.HealthCheckAllowGetBody
+	o.PreflightPolicyName = oc.PreflightPolicyName
+	o.PreflightPolicy = oc.PreflightPolicy
 	o.Host = oc.Host
 	o.Name = oc.Name
 	o.IsDefault = oc.IsDefault
@@ -232,15 +400,43 @@ func (oc *Options) Clone() *Options {
 	o.MaxTTL = oc.MaxTTL
 	o.MaxObjectSizeBytes = oc.MaxObjectSizeBytes
 	o.MultipartRangesDisabled = oc.MultipartRangesDisabled
+	o.RejectDuplicateParams = oc.RejectDuplicateParams
+	o.FingerprintStripValues = oc.FingerprintStripValues
+	o.SlowQueryThresholdMS = oc.SlowQueryThresholdMS
+	o.SlowQueryThreshold = oc.SlowQueryThreshold
+	o.LookbackDeltaMS = oc.LookbackDeltaMS
+	o.LookbackDelta = oc.LookbackDelta
+	o.RateLimitRemainingHeader = oc.RateLimitRemainingHeader
+	o.RateLimitResetHeader = oc.RateLimitResetHeader
+	o.RateLimitMinRemaining = oc.RateLimitMinRemaining
+	o.RateLimitModeName = oc.RateLimitModeName
+	o.RateLimitMode = oc.RateLimitMode
+	o.RateLimitMaxWaitMS = oc.RateLimitMaxWaitMS
+	o.RateLimitMaxWait = oc.RateLimitMaxWait
+	o.RateLimitServeStale = oc.RateLimitServeStale
+	o.MaxConcurrentRequests = oc.MaxConcurrentRequests
+	o.ConcurrencyReservedShare = oc.ConcurrencyReservedShare
+	o.ConcurrencyMaxWaitMS = oc.ConcurrencyMaxWaitMS
+	o.ConcurrencyMaxWait = oc.ConcurrencyMaxWait
+	o.TimeZoneName = oc.TimeZoneName
+	o.TimeZone = oc.TimeZone
+	o.RecordDir = oc.RecordDir
+	o.ReplayDir = oc.ReplayDir
 	o.OriginType = oc.OriginType
 	o.OriginURL = oc.OriginURL
 	o.PathPrefix = oc.PathPrefix
 	o.ReqRewriterName = oc.ReqRewriterName
+	o.ReqRewriter = oc.ReqRewriter
 	o.RevalidationFactor = oc.RevalidationFactor
 	o.RuleName = oc.RuleName
 	o.Scheme = oc.Scheme
 	o.Timeout = oc.Timeout
 	o.TimeoutSecs = oc.TimeoutSecs
+	o.TimeoutHeader = oc.TimeoutHeader
+	o.ClientTimeoutMS = oc.ClientTimeoutMS
+	o.ClientTimeout = oc.ClientTimeout
+	o.TimeoutMarginMS = oc.TimeoutMarginMS
+	o.TimeoutMargin = oc.TimeoutMargin
 	o.TimeseriesRetention = oc.TimeseriesRetention
 	o.TimeseriesRetentionFactor = oc.TimeseriesRetentionFactor
 	o.TimeseriesEvictionMethodName = oc.TimeseriesEvictionMethodName
@@ -273,6 +469,11 @@ func (oc *Options) Clone() *Options {
 		}
 	}
 
+	if oc.HealthCheckExpectedCodes != nil {
+		o.HealthCheckExpectedCodes = make([]int, len(oc.HealthCheckExpectedCodes))
+		copy(o.HealthCheckExpectedCodes, oc.HealthCheckExpectedCodes)
+	}
+
 	o.HealthCheckHeaders = make(map[string]string)
 	for k, v := range oc.HealthCheckHeaders {
 		o.HealthCheckHeaders[k] = v
@@ -284,6 +485,10 @@ func (oc *Options) Clone() *Options {
 	}
 
 	o.NegativeCacheName = oc.NegativeCacheName
+	o.NegativeCacheAllowRevalidate = oc.NegativeCacheAllowRevalidate
+	o.NegativeCacheBackoffMultiplier = oc.NegativeCacheBackoffMultiplier
+	o.NegativeCacheBackoffMaxTTLSecs = oc.NegativeCacheBackoffMaxTTLSecs
+	o.NegativeCacheBackoffMaxTTL = oc.NegativeCacheBackoffMaxTTL
 	if oc.NegativeCache != nil {
 		m := make(map[int]time.Duration)
 		for c, t := range oc.NegativeCache {
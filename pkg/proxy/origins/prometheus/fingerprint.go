@@ -0,0 +1,51 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import "github.com/tricksterproxy/trickster/pkg/proxy/fingerprint"
+
+// promqlSyntax describes the lexical features of PromQL
+var promqlSyntax = fingerprint.Syntax{Quotes: "\"'`", LineComment: "#"}
+
+// NormalizeQuery returns the PromQL query with its whitespace and comments canonicalized, and its
+// numeric constants, durations and timestamps replaced with a placeholder. When stripValues is
+// true, the values of label matchers are also replaced, so that only the label names are retained
+func (c *Client) NormalizeQuery(query string, stripValues bool) string {
+	tokens := fingerprint.Tokenize(query, promqlSyntax)
+	// depth tracks the nesting of label matcher braces
+	var depth int
+	for i, t := range tokens {
+		switch t.Type {
+		case fingerprint.TokenNumber:
+			tokens[i].Value = fingerprint.Placeholder
+		case fingerprint.TokenString:
+			if stripValues && depth > 0 {
+				tokens[i].Value = `"` + fingerprint.Placeholder + `"`
+			}
+		case fingerprint.TokenSymbol:
+			switch t.Value {
+			case "{":
+				depth++
+			case "}":
+				if depth > 0 {
+					depth--
+				}
+			}
+		}
+	}
+	return fingerprint.Join(tokens)
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import "testing"
+
+func TestNormalizeQuery(t *testing.T) {
+
+	tests := []struct {
+		query       string
+		stripValues bool
+		expected    string
+	}{
+		{`sum by (job) (rate(http_requests_total{job="api", code=~"5.."}[5m]))`, false,
+			`sum by(job)(rate(http_requests_total{job="api",code=~"5.."}[?]))`},
+		{`sum by (job) (rate(http_requests_total{job="api", code=~"5.."}[5m]))`, true,
+			`sum by(job)(rate(http_requests_total{job="?",code=~"?"}[?]))`},
+		{"  sum  by(job)(rate(http_requests_total{job='web',code=~`4..`}[1h]))  ", true,
+			`sum by(job)(rate(http_requests_total{job="?",code=~"?"}[?]))`},
+		// strings outside of label matchers are not matcher values
+		{`label_replace(up{instance="a:9090"}, "host", "$1", "instance", "(.*):.*")`, true,
+			`label_replace(up{instance="?"},"host","$1","instance","(.*):.*")`},
+		{"node:cpu:rate5m > 0.95 # alert\n", false, "node:cpu:rate5m>?"},
+		{`up offset 1h @ 1609746000`, false, `up offset ?@?`},
+		{`max_over_time(up[1h:30s])`, false, `max_over_time(up[?:?])`},
+	}
+
+	c := &Client{}
+	for i, test := range tests {
+		if v := c.NormalizeQuery(test.query, test.stripValues); v != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, v)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"flag"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/fixtures"
+)
+
+// recordFixtures, when set, records the handler tests' upstream fixtures from their test servers
+// rather than replaying them. To re-record after changing a test's upstream requests, run:
+//
+//	go test ./pkg/proxy/origins/prometheus/ -run Handler -args -record-fixtures
+var recordFixtures = flag.Bool("record-fixtures", false,
+	"record the handler tests' upstream fixtures from their test servers")
+
+// fixtureClient returns a copy of the test instance's HTTP client, whose upstream requests are
+// served from the test's recorded fixtures, or are recorded to them when -record-fixtures is set.
+// Each test has its own fixture directory, since tests may expect different responses to the
+// same upstream request
+func fixtureClient(t *testing.T, hc *http.Client) *http.Client {
+	dir := filepath.Join("testdata", "fixtures", t.Name())
+	c := *hc
+	if *recordFixtures {
+		c.Transport = fixtures.NewRecorder(dir, hc.Transport)
+	} else {
+		c.Transport = fixtures.NewReplayer(dir)
+	}
+	return &c
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+
+	"github.com/prometheus/common/model"
+)
+
+// goldenOrigin is a deterministic fake Prometheus, which evaluates range queries at start,
+// start+step, ... while the timestamp is not after end, as Prometheus does. Each timestamp's
+// value is a function of the timestamp alone, so that responses are independent of the query
+// and of the range in which a sample was fetched. The second series is absent from every
+// seventh timestamp, to exercise series that appear only in some fetched ranges
+func goldenOrigin() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, err1 := parseTime(r.FormValue(upStart))
+		end, err2 := parseTime(r.FormValue(upEnd))
+		step, err3 := goldenStep(r.FormValue(upStep))
+		if err1 != nil || err2 != nil || err3 != nil || step <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		a := &model.SampleStream{Metric: model.Metric{"__name__": "golden", "series": "a"}}
+		b := &model.SampleStream{Metric: model.Metric{"__name__": "golden", "series": "b"}}
+		for t := start; !t.After(end); t = t.Add(step) {
+			ts := model.TimeFromUnixNano(t.UnixNano())
+			a.Values = append(a.Values, model.SamplePair{Timestamp: ts,
+				Value: model.SampleValue(int64(ts) % 9973)})
+			if (int64(ts)/1000)%7 != 0 {
+				b.Values = append(b.Values, model.SamplePair{Timestamp: ts,
+					Value: model.SampleValue(int64(ts) % 7919)})
+			}
+		}
+		result := model.Matrix{}
+		for _, s := range []*model.SampleStream{a, b} {
+			if len(s.Values) > 0 {
+				result = append(result, s)
+			}
+		}
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		json.NewEncoder(w).Encode(&MatrixEnvelope{Status: "success",
+			Data: MatrixData{ResultType: "matrix", Result: result}})
+	}))
+}
+
+// goldenStep parses a step as Prometheus does, as either a number of seconds or a duration
+func goldenStep(s string) (time.Duration, error) {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(v * float64(time.Second)), nil
+	}
+	d, err := model.ParseDuration(s)
+	return time.Duration(d), err
+}
+
+// goldenHarness proxies range queries through a Client and a memory cache to a goldenOrigin
+type goldenHarness struct {
+	t      *testing.T
+	origin *httptest.Server
+	ts     *httptest.Server
+	client *Client
+	rsc    *request.Resources
+}
+
+func newGoldenHarness(t *testing.T) *goldenHarness {
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil,
+		"prometheus", "/api/v1/query_range", "error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := goldenOrigin()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	rsc.OriginConfig.FastForwardDisable = true
+	rsc.OriginConfig.HTTPClient = hc
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.baseUpstreamURL, _ = url.Parse(origin.URL)
+	return &goldenHarness{t: t, origin: origin, ts: ts, client: client, rsc: rsc}
+}
+
+func (h *goldenHarness) Close() {
+	h.origin.Close()
+	h.ts.Close()
+}
+
+func goldenQuery(query string, start, end time.Time, step string) string {
+	v := url.Values{}
+	v.Set(upQuery, query)
+	v.Set(upStart, formatTime(start))
+	v.Set(upEnd, formatTime(end))
+	v.Set(upStep, step)
+	return v.Encode()
+}
+
+// proxy returns the body of the range query's response from Trickster, and its cache status
+func (h *goldenHarness) proxy(query string, start, end time.Time, step string) ([]byte, string) {
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/api/v1/query_range?"+
+		goldenQuery(query, start, end, step), nil)
+	r = request.SetResources(r, h.rsc)
+	w := httptest.NewRecorder()
+	h.client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		h.t.Fatalf("expected %d got %d: %s", http.StatusOK, resp.StatusCode, string(b))
+	}
+	return b, resp.Header.Get(headers.NameTricksterResult)
+}
+
+// direct returns the samples of the range query's response directly from the origin
+func (h *goldenHarness) direct(query string, start, end time.Time, step string) string {
+	resp, err := http.Get(h.origin.URL + "/api/v1/query_range?" + goldenQuery(query, start, end, step))
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return samples(h.t, b)
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano()/int64(time.Millisecond))/1000, 'f', -1, 64)
+}
+
+// samples returns the samples of a range query's response body, formatted for comparison
+func samples(t *testing.T, body []byte) string {
+	me := &MatrixEnvelope{}
+	if err := json.Unmarshal(body, me); err != nil {
+		t.Fatal(err)
+	}
+	sort.Sort(me.Data.Result)
+	return me.Data.Result.String()
+}
+
+func TestGoldenRangeQueries(t *testing.T) {
+
+	h := newGoldenHarness(t)
+	defer h.Close()
+
+	steps := []string{"15", "60s", "5m", "1h", "1.5"}
+	now := time.Now()
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 200; i++ {
+
+		step := steps[rnd.Intn(len(steps))]
+		sd, _ := goldenStep(step)
+
+		// the start is aligned to the step, since Trickster aligns the ranges of queries to their
+		// step, and the end is either aligned, so that its sample is at the inclusive boundary, or
+		// is between steps. queries end in the past, within the origin's retention
+		points := 1 + rnd.Intn(300)
+		start := now.Truncate(sd).Add(-time.Duration(points+rnd.Intn(600)) * sd)
+		end := start.Add(time.Duration(points-1) * sd)
+		if rnd.Intn(2) == 0 {
+			end = end.Add(time.Duration(rnd.Int63n(int64(sd/time.Millisecond))) * time.Millisecond)
+		}
+
+		// range vector selectors fetch each range from earlier by their lookback, and crop it
+		query := fmt.Sprintf("golden{case='%d'}", i)
+		if rnd.Intn(2) == 0 {
+			query = fmt.Sprintf("rate(golden{case='%d'}[%dm])", i, 1+rnd.Intn(10))
+		}
+
+		desc := fmt.Sprintf("(%d) %s start=%s end=%s step=%s", i, query,
+			formatTime(start), formatTime(end), step)
+		expected := h.direct(query, start, end, step)
+
+		// cold: the query has not been cached
+		cold, status := h.proxy(query, start, end, step)
+		if got := samples(t, cold); got != expected {
+			t.Errorf("%s cold (%s): expected\n%s\ngot\n%s", desc, status, expected, got)
+			continue
+		}
+
+		// warm: the query has been cached in its entirety
+		warm, status := h.proxy(query, start, end, step)
+		if string(warm) != string(cold) {
+			t.Errorf("%s warm (%s): expected\n%s\ngot\n%s", desc, status, cold, warm)
+		}
+
+		// partially warm: a range that overlaps the query, or is inside it, has been cached
+		q := query + " # partial"
+		ws := start.Add(time.Duration(rnd.Intn(points+20)-10) * sd)
+		we := ws.Add(time.Duration(rnd.Intn(points)) * sd)
+		h.proxy(q, ws, we, step)
+		partial, status := h.proxy(q, start, end, step)
+		if string(partial) != string(cold) {
+			t.Errorf("%s partially warm from %s-%s (%s): expected\n%s\ngot\n%s", desc,
+				formatTime(ws), formatTime(we), status, cold, partial)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	"github.com/tricksterproxy/trickster/pkg/runtime"
+)
+
+// buildInfoTTLSecs is the number of seconds that the origin's build information and flags are cached
+const buildInfoTTLSecs = 3600
+
+// buildInfo is the origin's build information, as last fetched through the cache
+type buildInfo struct {
+	body    []byte
+	header  http.Header
+	expires time.Time
+}
+
+// BuildInfoHandler answers calls to /status/buildinfo locally, with the origin's build information
+// as last fetched through the cache, so that datasource health checks do not generate origin load.
+// When the build information has expired and cannot be refetched, the expired copy is used
+func (c *Client) BuildInfoHandler(w http.ResponseWriter, r *http.Request) {
+
+	// requests are serialized so that only one is made to the origin when the build information expires
+	c.buildInfoLock.Lock()
+	defer c.buildInfoLock.Unlock()
+
+	bi := c.buildInfo
+	if bi == nil || time.Now().After(bi.expires) {
+		r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+		body, resp, _ := engines.FetchViaObjectProxyCache(r)
+		if resp != nil && resp.StatusCode == http.StatusOK {
+			h := resp.Header.Clone()
+			h.Del(headers.NameTricksterResult)
+			bi = &buildInfo{body: body, header: h, expires: time.Now().Add(buildInfoTTLSecs * time.Second)}
+			c.buildInfo = bi
+		} else if bi == nil {
+			if resp == nil {
+				errors.NewResponseError(http.StatusBadGateway, errors.CodeOriginUnreachable,
+					"unable to get the build information from the origin").Respond(w, r)
+				return
+			}
+			engines.Respond(w, resp.StatusCode, resp.Header, body)
+			return
+		}
+	}
+
+	h := bi.header.Clone()
+	h.Set(headers.NameTricksterVersion, runtime.ApplicationVersion)
+	engines.Respond(w, http.StatusOK, h, bi.body)
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+const testBuildInfo = `{"status":"success","data":{"version":"2.19.0","revision":"trickster"}}`
+
+func TestBuildInfoHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs,
+		200, testBuildInfo, nil, "prometheus", APIPath+mnBuildInfo, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+
+	testBuildInfoResponse(t, client, w, r, http.StatusOK, testBuildInfo)
+
+	// subsequent requests are answered locally, even when the origin is unavailable
+	ts.Close()
+	testBuildInfoResponse(t, client, httptest.NewRecorder(), r.Clone(r.Context()),
+		http.StatusOK, testBuildInfo)
+
+	// an expired copy is used when the build information cannot be refetched
+	client.buildInfo.expires = time.Now().Add(-time.Second)
+	r2 := r.Clone(r.Context())
+	r2.Header.Set(headers.NameCacheControl, headers.ValueNoCache)
+	testBuildInfoResponse(t, client, httptest.NewRecorder(), r2, http.StatusOK, testBuildInfo)
+}
+
+func TestBuildInfoHandlerOriginError(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs,
+		http.StatusInternalServerError, "", nil, "prometheus", APIPath+mnBuildInfo, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+
+	client.BuildInfoHandler(w, r)
+	if w.Result().StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected %d got %d", http.StatusInternalServerError, w.Result().StatusCode)
+	}
+	if client.buildInfo != nil {
+		t.Error("expected nil build info")
+	}
+}
+
+func testBuildInfoResponse(t *testing.T, client *Client, w *httptest.ResponseRecorder,
+	r *http.Request, code int, body string) {
+	t.Helper()
+	client.BuildInfoHandler(w, r)
+	resp := w.Result()
+	if resp.StatusCode != code {
+		t.Errorf("expected %d got %d", code, resp.StatusCode)
+	}
+	if _, ok := resp.Header[headers.NameTricksterVersion]; !ok {
+		t.Errorf("expected %s header", headers.NameTricksterVersion)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	if string(b) != body {
+		t.Errorf("expected %s got %s", body, string(b))
+	}
+}
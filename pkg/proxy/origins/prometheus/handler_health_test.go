@@ -32,6 +32,7 @@ func TestHealthHandler(t *testing.T) {
 	ts, w, r, hc, err := tu.NewTestInstance("",
 		client.DefaultPathConfigs, 200, "{}", nil, "prometheus", "/health", "debug")
 
+	hc = fixtureClient(t, hc)
 	rsc := request.GetResources(r)
 	client.config = rsc.OriginConfig
 	client.webClient = hc
@@ -59,7 +60,7 @@ func TestHealthHandler(t *testing.T) {
 		t.Errorf("expected '{}' got %s.", bodyBytes)
 	}
 
-	client.healthMethod = "-"
+	client.config.HealthCheckVerb = ""
 
 	w = httptest.NewRecorder()
 	client.HealthHandler(w, r)
@@ -81,6 +82,7 @@ func TestHealthHandlerCustomPath(t *testing.T) {
 		defer ts.Close()
 	}
 
+	hc = fixtureClient(t, hc)
 	rsc := request.GetResources(r)
 	client.config = rsc.OriginConfig
 	client.config.HealthCheckUpstreamPath = "-"
@@ -30,6 +30,7 @@ func TestObjectProxyCacheHandler(t *testing.T) {
 	client := &Client{name: "test"}
 	ts, w, r, hc, err := tu.NewTestInstance("",
 		client.DefaultPathConfigs, 200, "{}", nil, "prometheus", "/health", "debug")
+	hc = fixtureClient(t, hc)
 	rsc := request.GetResources(r)
 	rsc.OriginClient = client
 	client.baseUpstreamURL, _ = url.Parse(ts.URL)
@@ -31,6 +31,7 @@ func TestQueryRangeHandler(t *testing.T) {
 	ts, w, r, hc, err := tu.NewTestInstance("",
 		client.DefaultPathConfigs, 200, "{}", nil, "prometheus",
 		"/query_range?q=up&start=0&end=900&step=15", "debug")
+	hc = fixtureClient(t, hc)
 	rsc := request.GetResources(r)
 	rsc.OriginClient = client
 	client.config = rsc.OriginConfig
@@ -30,6 +30,7 @@ func TestQueryHandler(t *testing.T) {
 	client := &Client{name: "test"}
 	ts, w, r, hc, err := tu.NewTestInstance("",
 		client.DefaultPathConfigs, 200, "{}", nil, "prometheus", "/query?q=up&time=0", "debug")
+	hc = fixtureClient(t, hc)
 	rsc := request.GetResources(r)
 	rsc.OriginClient = client
 	client.config = rsc.OriginConfig
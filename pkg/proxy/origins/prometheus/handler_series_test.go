@@ -32,6 +32,7 @@ func TestSeriesHandler(t *testing.T) {
 		client.DefaultPathConfigs, 200, "{}", nil, "prometheus",
 		`/default/api/v1/series?match[]=up&match[]=process_start_time_seconds{job="prometheus"}&start=100&end=100`,
 		"debug")
+	hc = fixtureClient(t, hc)
 	rsc := request.GetResources(r)
 	rsc.OriginClient = client
 	client.config = rsc.OriginConfig
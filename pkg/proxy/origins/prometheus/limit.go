@@ -0,0 +1,78 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+)
+
+var _ origins.ResultLimiter = (*Client)(nil)
+
+const upLimit = "limit"
+
+// isLimitable returns true if the path is a Prometheus API endpoint that accepts a limit parameter
+func isLimitable(path string) bool {
+	if strings.HasSuffix(path, APIPath+mnSeries) || strings.HasSuffix(path, APIPath+mnLabels) {
+		return true
+	}
+	return strings.Contains(path, APIPath+mnLabel+"/") && strings.HasSuffix(path, "/values")
+}
+
+// ResultLimit returns the limit parameter provided by the client for the series,
+// labels and label values endpoints, or 0 if there is none
+func (c *Client) ResultLimit(r *http.Request) int {
+	if r == nil || r.URL == nil || !isLimitable(r.URL.Path) {
+		return 0
+	}
+	qp, _, _ := params.GetRequestValues(r)
+	l, err := strconv.Atoi(qp.Get(upLimit))
+	if err != nil || l < 0 {
+		return 0
+	}
+	return l
+}
+
+// LimitResults trims the data array of a Prometheus API response to no more than limit entries
+func (c *Client) LimitResults(body []byte, limit int) ([]byte, bool) {
+	if limit <= 0 {
+		return body, false
+	}
+	var env map[string]json.RawMessage
+	if err := json.Unmarshal(body, &env); err != nil {
+		return body, false
+	}
+	var data []json.RawMessage
+	if err := json.Unmarshal(env["data"], &data); err != nil || len(data) <= limit {
+		return body, false
+	}
+	b, err := json.Marshal(data[:limit])
+	if err != nil {
+		return body, false
+	}
+	env["data"] = b
+	b, err = json.Marshal(env)
+	if err != nil {
+		return body, false
+	}
+	return b, true
+}
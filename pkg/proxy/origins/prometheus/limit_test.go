@@ -0,0 +1,200 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+const testSeriesBody = `{"status":"success","data":[{"__name__":"a"},{"__name__":"b"},{"__name__":"c"}]}`
+
+func TestResultLimit(t *testing.T) {
+
+	client := &Client{}
+
+	tests := []struct {
+		url      string
+		expected int
+	}{
+		{"http://0/api/v1/series?match[]=up&limit=2", 2},
+		{"http://0/api/v1/labels?limit=5", 5},
+		{"http://0/api/v1/label/job/values?limit=3", 3},
+		{"http://0/api/v1/series?match[]=up", 0},
+		{"http://0/api/v1/series?match[]=up&limit=x", 0},
+		{"http://0/api/v1/query?query=up&limit=2", 0},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.url, nil)
+		if l := client.ResultLimit(r); l != test.expected {
+			t.Errorf("%s: expected %d got %d", test.url, test.expected, l)
+		}
+	}
+
+	if l := client.ResultLimit(nil); l != 0 {
+		t.Errorf("expected %d got %d", 0, l)
+	}
+}
+
+func TestLimitResults(t *testing.T) {
+
+	client := &Client{}
+
+	b, ok := client.LimitResults([]byte(testSeriesBody), 2)
+	if !ok {
+		t.Error("expected results to be limited")
+	}
+	expected := `{"data":[{"__name__":"a"},{"__name__":"b"}],"status":"success"}`
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+
+	for _, l := range []int{0, 3, 10} {
+		b, ok = client.LimitResults([]byte(testSeriesBody), l)
+		if ok || string(b) != testSeriesBody {
+			t.Errorf("expected unmodified body for limit %d, got %s", l, string(b))
+		}
+	}
+
+	b, ok = client.LimitResults([]byte("not json"), 1)
+	if ok || string(b) != "not json" {
+		t.Errorf("expected unmodified body, got %s", string(b))
+	}
+}
+
+func TestSeriesHandlerLimit(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("",
+		client.DefaultPathConfigs, 200, testSeriesBody,
+		map[string]string{headers.NameCacheControl: "max-age=60"}, "prometheus",
+		"/default/api/v1/series?match[]=up&start=100&end=100&limit=2", "debug")
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+
+	tests := []struct {
+		limit    string
+		status   string
+		expected int
+	}{
+		{"2", "kmiss", 2},
+		{"1", "hit", 1},
+		// the cached document was produced under limit=2, so it can't serve a larger limit
+		{"3", "kmiss", 3},
+		{"", "kmiss", 3},
+		{"2", "hit", 2},
+	}
+
+	for i, test := range tests {
+		u := "http://0/default/api/v1/series?match[]=up&start=100&end=100"
+		if test.limit != "" {
+			u += "&limit=" + test.limit
+		}
+		r2 := httptest.NewRequest("GET", u, nil).WithContext(r.Context())
+		w := httptest.NewRecorder()
+		client.SeriesHandler(w, r2)
+		resp := w.Result()
+		b, _ := ioutil.ReadAll(resp.Body)
+		if n := strings.Count(string(b), "__name__"); n != test.expected {
+			t.Errorf("test %d: expected %d results got %d: %s", i, test.expected, n, string(b))
+		}
+		if rh := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(rh, "status="+test.status) {
+			t.Errorf("test %d: expected status %s got %s", i, test.status, rh)
+		}
+	}
+}
+
+func TestSeriesHandlerLimitETag(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("",
+		client.DefaultPathConfigs, 200, testSeriesBody,
+		map[string]string{headers.NameCacheControl: "max-age=60", headers.NameETag: `"abc"`},
+		"prometheus", "/default/api/v1/series?match[]=up&start=100&end=100", "debug")
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+
+	const u = "http://0/default/api/v1/series?match[]=up&start=100&end=100"
+
+	get := func(limit, inm string) *http.Response {
+		s := u
+		if limit != "" {
+			s += "&limit=" + limit
+		}
+		r2 := httptest.NewRequest("GET", s, nil).WithContext(r.Context())
+		if inm != "" {
+			r2.Header.Set(headers.NameIfNoneMatch, inm)
+		}
+		w := httptest.NewRecorder()
+		client.SeriesHandler(w, r2)
+		return w.Result()
+	}
+
+	if v := get("", "").Header.Get(headers.NameETag); v != `"abc"` {
+		t.Errorf("expected %s got %s", `"abc"`, v)
+	}
+
+	// trimmed responses have validators specific to their limit
+	etag1 := get("1", "").Header.Get(headers.NameETag)
+	etag2 := get("2", "").Header.Get(headers.NameETag)
+	if etag1 == "" || etag1 == `"abc"` || etag1 == etag2 {
+		t.Errorf("expected distinct validators got %s and %s", etag1, etag2)
+	}
+
+	resp := get("1", etag1)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("expected %d got %d", http.StatusNotModified, resp.StatusCode)
+	}
+	if v := resp.Header.Get(headers.NameETag); v != etag1 {
+		t.Errorf("expected %s got %s", etag1, v)
+	}
+
+	// the validator of a response limited differently doesn't satisfy the condition
+	resp = get("1", etag2)
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if n := strings.Count(string(b), "__name__"); n != 1 {
+		t.Errorf("expected %d results got %d: %s", 1, n, string(b))
+	}
+}
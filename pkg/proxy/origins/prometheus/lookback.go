@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	tt "github.com/tricksterproxy/trickster/pkg/proxy/timeconv"
+)
+
+// queryLookback returns the widest window of raw samples that precedes any point of the PromQL
+// query's results. This is the longest range of its range vector selectors, where the ranges
+// of any enclosing subqueries are added to the ranges of the selectors they enclose
+func queryLookback(query string) time.Duration {
+	// frames holds the widest window found in each level of parenthesized expressions
+	frames := []time.Duration{0}
+	// closed is the widest window of the most recently closed parenthesized expression, to
+	// which the range of a subquery that immediately follows it is added
+	var closed time.Duration
+	var prev byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch c {
+		case '"', '\'', '`':
+			i = skipQuoted(query, i)
+		case '#':
+			if j := strings.IndexByte(query[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(query)
+			}
+			continue
+		case '(':
+			frames = append(frames, 0)
+		case ')':
+			if len(frames) > 1 {
+				closed = frames[len(frames)-1]
+				frames = frames[:len(frames)-1]
+				frames[len(frames)-1] = maxDuration(frames[len(frames)-1], closed)
+			}
+		case '[':
+			j := strings.IndexByte(query[i:], ']')
+			if j < 0 {
+				return frames[0]
+			}
+			sel := query[i+1 : i+j]
+			rng, isSubquery := sel, false
+			if k := strings.IndexByte(sel, ':'); k >= 0 {
+				rng, isSubquery = sel[:k], true
+			}
+			if d, err := parseRange(strings.TrimSpace(rng)); err == nil {
+				if isSubquery && prev == ')' {
+					d += closed
+				}
+				frames[len(frames)-1] = maxDuration(frames[len(frames)-1], d)
+			}
+			i += j
+			c = ']'
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			prev = c
+		}
+	}
+	return frames[0]
+}
+
+// skipQuoted returns the index of the quote that closes the string opened at index i
+func skipQuoted(query string, i int) int {
+	q := query[i]
+	for i++; i < len(query); i++ {
+		if query[i] == '\\' && q != '`' {
+			i++
+			continue
+		}
+		if query[i] == q {
+			return i
+		}
+	}
+	return len(query)
+}
+
+// parseRange parses a PromQL duration, which is either a number of seconds or a sequence of
+// integers with units (e.g., 1h30m)
+func parseRange(s string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	var d time.Duration
+	for len(s) > 0 {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		j := i
+		for j < len(s) && (s[j] < '0' || s[j] > '9') {
+			j++
+		}
+		v, err := strconv.ParseInt(s[:i], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		p, err := tt.ParseDurationParts(v, s[i:j])
+		if err != nil {
+			return 0, err
+		}
+		d += p
+		s = s[j:]
+	}
+	return d, nil
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+func TestQueryLookback(t *testing.T) {
+
+	tests := []struct {
+		query    string
+		expected time.Duration
+	}{
+		{`up`, 0},
+		{`rate(http_requests_total[5m])`, 5 * time.Minute},
+		{`sum(rate(a[1m])) / sum(rate(b{job="x"}[1h30m]))`, 90 * time.Minute},
+		{`rate(a[300])`, 5 * time.Minute},
+		{`rate(a[5m] offset 1h)`, 5 * time.Minute},
+		// the ranges of subqueries are added to the ranges of the selectors they enclose
+		{`max_over_time(rate(a[5m])[1h:1m])`, 65 * time.Minute},
+		{`max_over_time(max_over_time(rate(a[5m])[1h:])[1d:5m])`, 25*time.Hour + 5*time.Minute},
+		{`max_over_time(up{job="a"}[1h:1m])`, time.Hour},
+		{`max_over_time((a)[10m:])`, 10 * time.Minute},
+		// brackets within strings and comments are ignored
+		{`count(a{job="[1d]"}) # [1w]` + "\n" + `+ rate(b[2m])`, 2 * time.Minute},
+		{`rate(a[`, 0},
+		{`rate(a[x])`, 0},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if v := queryLookback(test.query); v != test.expected {
+				t.Errorf("expected %s got %s", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestParseTimeRangeQueryLookback(t *testing.T) {
+	client := &Client{}
+	r := httptest.NewRequest("GET",
+		"http://0/api/v1/query_range?query=rate(a[5m])&start=0&end=600&step=15", nil)
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.Lookback != 5*time.Minute {
+		t.Errorf("expected %s got %s", 5*time.Minute, trq.Lookback)
+	}
+}
+
+// simulatedOrigin evaluates rate() queries of a single counter like Prometheus would,
+// from raw samples that are recorded every 15s, and records the requested start times
+type simulatedOrigin struct {
+	mtx    sync.Mutex
+	starts []time.Time
+}
+
+// sample returns the value of the fixture counter at t, which increases irregularly
+func (so *simulatedOrigin) sample(t time.Time) float64 {
+	n := t.Unix() / 15
+	return float64(n*3 + (n*n)%17)
+}
+
+// evaluate returns the rate of the fixture counter over the window ending at t
+func (so *simulatedOrigin) evaluate(t time.Time, window time.Duration) (float64, bool) {
+	first := t.Add(-window).Truncate(15 * time.Second).Add(15 * time.Second)
+	last := t.Truncate(15 * time.Second)
+	if !last.After(first) {
+		return 0, false
+	}
+	return (so.sample(last) - so.sample(first)) / last.Sub(first).Seconds(), true
+}
+
+func (so *simulatedOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	qp := r.URL.Query()
+	start, _ := parseTime(qp.Get(upStart))
+	end, _ := parseTime(qp.Get(upEnd))
+	step, _ := parseDuration(qp.Get(upStep))
+	q := qp.Get(upQuery)
+	window, _ := parseRange(q[strings.Index(q, "[")+1 : strings.Index(q, "]")])
+
+	so.mtx.Lock()
+	so.starts = append(so.starts, start)
+	so.mtx.Unlock()
+
+	values := make([]string, 0)
+	for ts := start; !ts.After(end); ts = ts.Add(step) {
+		if v, ok := so.evaluate(ts, window); ok {
+			values = append(values, fmt.Sprintf(`[%d,"%s"]`, ts.Unix(),
+				strconv.FormatFloat(v, 'f', -1, 64)))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[`+
+		`{"metric":{"__name__":"http_requests_total"},"values":[%s]}]}}`, strings.Join(values, ","))
+}
+
+func TestQueryRangeLookbackSeams(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("",
+		client.DefaultPathConfigs, 200, "{}", nil, "prometheus", "/api/v1/query_range", "error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	so := &simulatedOrigin{}
+	sts := httptest.NewServer(so)
+	defer sts.Close()
+
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.config.FastForwardDisable = true
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(sts.URL)
+
+	step := time.Minute
+	const query = "rate(http_requests_total[5m])"
+	base := time.Now().Truncate(time.Hour).Add(-6 * time.Hour)
+
+	queryRange := func(start, end time.Time) [][]interface{} {
+		req := httptest.NewRequest("GET", fmt.Sprintf("%s/api/v1/query_range?query=%s&start=%d&end=%d&step=%d",
+			ts.URL, url.QueryEscape(query), start.Unix(), end.Unix(), int(step.Seconds())), nil).
+			WithContext(r.Context())
+		w := httptest.NewRecorder()
+		client.QueryRangeHandler(w, req)
+		body, _ := ioutil.ReadAll(w.Result().Body)
+		var doc struct {
+			Data struct {
+				Result []struct {
+					Values [][]interface{} `json:"values"`
+				} `json:"result"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil || len(doc.Data.Result) != 1 {
+			t.Fatalf("unexpected response: %s", body)
+		}
+		return doc.Data.Result[0].Values
+	}
+
+	// the first request is a cache miss, the second fetches only the extents on either side of it
+	queryRange(base.Add(time.Hour), base.Add(2*time.Hour))
+	values := queryRange(base, base.Add(3*time.Hour))
+
+	// each upstream request begins earlier by the 5m range and the default 5m lookback delta
+	lookback := 10 * time.Minute
+	expectedStarts := map[int64]bool{
+		base.Add(time.Hour - lookback).Unix():          true,
+		base.Add(-lookback).Unix():                     true,
+		base.Add(2*time.Hour + step - lookback).Unix(): true,
+	}
+	if len(so.starts) != len(expectedStarts) {
+		t.Errorf("expected %d upstream requests got %d", len(expectedStarts), len(so.starts))
+	}
+	for _, s := range so.starts {
+		if !expectedStarts[s.Unix()] {
+			t.Errorf("unexpected upstream start %d", s.Unix())
+		}
+	}
+
+	// the merged result is trimmed to the requested window, and is identical to the direct evaluation
+	if len(values) != 181 {
+		t.Fatalf("expected %d values got %d", 181, len(values))
+	}
+	for i, v := range values {
+		ts := base.Add(time.Duration(i) * step)
+		if int64(v[0].(float64)) != ts.Unix() {
+			t.Fatalf("expected timestamp %d got %v", ts.Unix(), v[0])
+		}
+		ev, _ := so.evaluate(ts, 5*time.Minute)
+		if v[1].(string) != strconv.FormatFloat(ev, 'f', -1, 64) {
+			t.Errorf("at %d: expected %f got %s", ts.Unix(), ev, v[1])
+		}
+	}
+}
@@ -121,7 +121,10 @@ func (me *MatrixEnvelope) Clone() timeseries.Timeseries {
 
 	for _, ss := range me.Data.Result {
 		newSS := &model.SampleStream{Metric: ss.Metric}
-		newSS.Values = ss.Values[:]
+		// the values are copied, since a later Merge may append to a cropped clone
+		// and overwrite the original's values beyond the cropped length
+		newSS.Values = make([]model.SamplePair, len(ss.Values))
+		copy(newSS.Values, ss.Values)
 		resMe.Data.Result = append(resMe.Data.Result, newSS)
 	}
 	return resMe
@@ -287,6 +290,26 @@ func (me *MatrixEnvelope) CropToRange(e timeseries.Extent) {
 	me.ExtentList = me.ExtentList.Crop(e)
 }
 
+// Downsample reduces the Timeseries to only those values whose timestamps are aligned to the provided step.
+// The values are copied to new slices, so that Values shared with a Clone's source are not affected
+func (me *MatrixEnvelope) Downsample(step time.Duration) {
+	ms := int64(step / time.Millisecond)
+	if ms <= 0 {
+		return
+	}
+	me.isCounted = false
+	for i, s := range me.Data.Result {
+		vals := make([]model.SamplePair, 0, len(s.Values))
+		for _, v := range s.Values {
+			if int64(v.Timestamp)%ms == 0 {
+				vals = append(vals, v)
+			}
+		}
+		me.Data.Result[i].Values = vals
+	}
+	me.StepDuration = step
+}
+
 // Sort sorts all Values in each Series chronologically by their timestamp
 func (me *MatrixEnvelope) Sort() {
 
@@ -1440,6 +1440,46 @@ func TestClone(t *testing.T) {
 
 }
 
+func TestDownsample(t *testing.T) {
+
+	me := &MatrixEnvelope{
+		Data: MatrixData{
+			ResultType: "matrix",
+			Result: model.Matrix{
+				&model.SampleStream{
+					Metric: model.Metric{"__name__": "a"},
+					Values: []model.SamplePair{
+						{Timestamp: 1644001200000, Value: 1.5},
+						{Timestamp: 1644001215000, Value: 2.5},
+						{Timestamp: 1644001230000, Value: 3.5},
+						{Timestamp: 1644001245000, Value: 4.5},
+						{Timestamp: 1644001260000, Value: 5.5},
+					},
+				},
+			},
+		},
+		StepDuration: time.Duration(15) * time.Second,
+	}
+
+	me2 := me.Clone().(*MatrixEnvelope)
+	me2.Downsample(time.Minute)
+
+	if me2.Step() != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, me2.Step())
+	}
+	expected := []model.SamplePair{
+		{Timestamp: 1644001200000, Value: 1.5},
+		{Timestamp: 1644001260000, Value: 5.5},
+	}
+	if !reflect.DeepEqual(me2.Data.Result[0].Values, expected) {
+		t.Errorf("mismatch\nexpected %v\ngot      %v", expected, me2.Data.Result[0].Values)
+	}
+	// the source of the clone is unchanged
+	if me.ValueCount() != 5 {
+		t.Errorf("expected %d got %d", 5, me.ValueCount())
+	}
+}
+
 func TestSort(t *testing.T) {
 	tests := []struct {
 		before, after *MatrixEnvelope
@@ -18,6 +18,8 @@ package prometheus
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/sort/times"
@@ -44,6 +46,7 @@ type MatrixEnvelope struct {
 	Data         MatrixData            `json:"data"`
 	ExtentList   timeseries.ExtentList `json:"extents,omitempty"`
 	StepDuration time.Duration         `json:"step,omitempty"`
+	Warnings     []string              `json:"warnings,omitempty"`
 
 	timestamps map[time.Time]bool // tracks unique timestamps in the matrix data
 	tslist     times.Times
@@ -63,13 +66,51 @@ func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
 	return json.Marshal(ts)
 }
 
+// matrixFrameHeader is the first frame of a streamed Matrix, which describes the frames that follow
+type matrixFrameHeader struct {
+	Status     string `json:"status"`
+	ResultType string `json:"resultType"`
+}
+
+// StreamTimeseries writes a Timeseries as newline-delimited JSON frames. The first frame reports
+// the response status and result type, and each subsequent frame is one series of the matrix
+func (c *Client) StreamTimeseries(ts timeseries.Timeseries, w io.Writer, flush func()) error {
+	me, ok := ts.(*MatrixEnvelope)
+	if !ok {
+		return fmt.Errorf("cannot stream %T as matrix frames", ts)
+	}
+	// the Encoder terminates each frame with a newline
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(matrixFrameHeader{Status: me.Status, ResultType: me.Data.ResultType}); err != nil {
+		return err
+	}
+	flush()
+	for _, s := range me.Data.Result {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+		flush()
+	}
+	return nil
+}
+
 // UnmarshalTimeseries converts a JSON blob into a Timeseries
 func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
 	me := &MatrixEnvelope{}
 	err := json.Unmarshal(data, &me)
+	// warnings describe the evaluation of a single upstream query, so they are not retained
+	// in timeseries that are merged and cached
+	me.Warnings = nil
 	return me, err
 }
 
+// AddWarning adds a warning to the Matrix, which is included in the warnings array of its response
+func (c *Client) AddWarning(ts timeseries.Timeseries, warning string) {
+	if me, ok := ts.(*MatrixEnvelope); ok {
+		me.Warnings = append(me.Warnings, warning)
+	}
+}
+
 // UnmarshalInstantaneous converts a JSON blob into an Instantaneous Data Point
 func (c *Client) UnmarshalInstantaneous(data []byte) (timeseries.Timeseries, error) {
 	ve := &VectorEnvelope{}
@@ -80,6 +121,18 @@ func (c *Client) UnmarshalInstantaneous(data []byte) (timeseries.Timeseries, err
 	return ve.ToMatrix(), nil
 }
 
+// EvaluationTime returns Prometheus' evaluation time for the provided Fast Forward timeseries,
+// which is the origin's clock at the time of the query
+func (c *Client) EvaluationTime(ts timeseries.Timeseries) (time.Time, bool) {
+	me, ok := ts.(*MatrixEnvelope)
+	if !ok || len(me.Data.Result) == 0 || len(me.ExtentList) == 0 {
+		return time.Time{}, false
+	}
+	// ToMatrix truncates timestamps to the second, so on average
+	// they trail the evaluation time by half a second
+	return me.ExtentList[len(me.ExtentList)-1].End.Add(500 * time.Millisecond), true
+}
+
 // ToMatrix converts a VectorEnvelope to a MatrixEnvelope
 func (ve *VectorEnvelope) ToMatrix() *MatrixEnvelope {
 	me := &MatrixEnvelope{}
@@ -17,7 +17,9 @@
 package prometheus
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/prometheus/common/model"
 )
@@ -64,6 +66,51 @@ func TestMarshalTimeseries(t *testing.T) {
 
 }
 
+func TestStreamTimeseries(t *testing.T) {
+
+	me := &MatrixEnvelope{
+		Status: "success",
+		Data: MatrixData{
+			ResultType: "matrix",
+			Result: model.Matrix{
+				&model.SampleStream{
+					Metric: model.Metric{"__name__": "a"},
+					Values: []model.SamplePair{{Timestamp: 99000, Value: 1.5}},
+				},
+				&model.SampleStream{
+					Metric: model.Metric{"__name__": "b"},
+					Values: []model.SamplePair{{Timestamp: 99000, Value: 2.5}},
+				},
+			},
+		},
+	}
+
+	expected := `{"status":"success","resultType":"matrix"}` + "\n" +
+		`{"metric":{"__name__":"a"},"values":[[99,"1.5"]]}` + "\n" +
+		`{"metric":{"__name__":"b"},"values":[[99,"2.5"]]}` + "\n"
+
+	client := &Client{}
+	buf := &bytes.Buffer{}
+	var flushes int
+	err := client.StreamTimeseries(me, buf, func() { flushes++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != expected {
+		t.Errorf("expected [%s] got [%s]", expected, buf.String())
+	}
+
+	if flushes != 3 {
+		t.Errorf("expected %d got %d", 3, flushes)
+	}
+
+	err = client.StreamTimeseries(nil, buf, func() {})
+	if err == nil {
+		t.Error("expected error for non-matrix timeseries")
+	}
+}
+
 func TestUnmarshalTimeseries(t *testing.T) {
 
 	bytes := []byte(`{"status":"","data":{"resultType":"matrix",` +
@@ -95,6 +142,30 @@ func TestUnmarshalTimeseries(t *testing.T) {
 
 }
 
+func TestAddWarning(t *testing.T) {
+
+	client := &Client{}
+	// warnings from the upstream response are not retained
+	ts, err := client.UnmarshalTimeseries([]byte(`{"status":"success","data":{"resultType":"matrix",` +
+		`"result":[]},"warnings":["upstream"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.AddWarning(ts, "partial")
+	b, err := client.MarshalTimeseries(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"status":"success","data":{"resultType":"matrix","result":[]},"warnings":["partial"]}`
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+
+	// timeseries of other types are unchanged
+	client.AddWarning(nil, "partial")
+}
+
 func TestUnmarshalInstantaneous(t *testing.T) {
 
 	bytes := []byte(`{"status":"success","data":{"resultType":"vector","result":[` +
@@ -126,6 +197,33 @@ func TestUnmarshalInstantaneous(t *testing.T) {
 
 }
 
+func TestEvaluationTime(t *testing.T) {
+
+	bytes := []byte(`{"status":"success","data":{"resultType":"vector","result":[` +
+		`{"metric":{"__name__":"up","instance":"localhost:9090","job":"prometheus"},` +
+		`"value":[1554730772.113,"1"]}]}}`)
+	client := &Client{}
+	ts, err := client.UnmarshalInstantaneous(bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	et, ok := client.EvaluationTime(ts)
+	if !ok {
+		t.Fatal("expected evaluation time")
+	}
+
+	expected := time.Unix(1554730772, 500000000)
+	if !et.Equal(expected) {
+		t.Errorf("expected %s got %s", expected, et)
+	}
+
+	_, ok = client.EvaluationTime(&MatrixEnvelope{})
+	if ok {
+		t.Error("expected no evaluation time for empty result")
+	}
+}
+
 func TestUnmarshalInstantaneousFails(t *testing.T) {
 
 	bytes := []byte(`{"status":"success","data":{"resultType":"vector","result":` +
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+	"github.com/tricksterproxy/trickster/pkg/util/testing/originsim"
+)
+
+func TestQueryRangeHandlerSimulated(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "", nil,
+		"prometheus-sim", APIPath+mnQueryRange, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.config.FastForwardDisable = true
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+
+	step := time.Minute
+	end := time.Now().Add(-time.Hour).Truncate(step)
+
+	queryRange := func(start, end time.Time, expectedStatus string) {
+		v := url.Values{"query": {`sim{seed=42,series_count=3}`}, "step": {"60"},
+			"start": {fmt.Sprint(start.Unix())}, "end": {fmt.Sprint(end.Unix())}}
+
+		w := httptest.NewRecorder()
+		req := r.Clone(r.Context())
+		req.URL.RawQuery = v.Encode()
+		client.QueryRangeHandler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+		}
+		if h := w.Header().Get(headers.NameTricksterResult); !strings.Contains(h, "status="+expectedStatus) {
+			t.Errorf("expected %s got %s", expectedStatus, h)
+		}
+		got, err := originsim.Decode(originsim.FormatPrometheus, w.Body.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the proxied response must equal a single fetch of the range from the simulator
+		expected, err := originsim.Fetch(ts.URL + APIPath + mnQueryRange + "?" + v.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 3 {
+			t.Errorf("expected %d series got %d", 3, len(got))
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("proxied response differs from simulator response for %s to %s", start, end)
+		}
+	}
+
+	queryRange(end.Add(-6*time.Hour), end.Add(-3*time.Hour), "kmiss")
+	queryRange(end.Add(-8*time.Hour), end.Add(-1*time.Hour), "phit")
+	queryRange(end.Add(-8*time.Hour), end.Add(-1*time.Hour), "hit")
+	queryRange(end.Add(-7*time.Hour), end.Add(-2*time.Hour), "hit")
+}
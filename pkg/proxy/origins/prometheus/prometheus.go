@@ -24,10 +24,12 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/proxy"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
@@ -35,6 +37,7 @@ import (
 	tt "github.com/tricksterproxy/trickster/pkg/proxy/timeconv"
 	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 )
 
 var _ origins.Client = (*Client)(nil)
@@ -53,16 +56,19 @@ const (
 	mnAlerts        = "alerts"
 	mnAlertManagers = "alertmanagers"
 	mnStatus        = "status"
+	mnBuildInfo     = "status/buildinfo"
+	mnFlags         = "status/flags"
 )
 
 // Common URL Parameter Names
 const (
-	upQuery = "query"
-	upStart = "start"
-	upEnd   = "end"
-	upStep  = "step"
-	upTime  = "time"
-	upMatch = "match[]"
+	upQuery   = "query"
+	upStart   = "start"
+	upEnd     = "end"
+	upStep    = "step"
+	upTime    = "time"
+	upMatch   = "match[]"
+	upTimeout = "timeout"
 )
 
 // Client Implements Proxy Client Interface
@@ -74,10 +80,9 @@ type Client struct {
 	handlers           map[string]http.Handler
 	handlersRegistered bool
 	baseUpstreamURL    *url.URL
-	healthURL          *url.URL
-	healthHeaders      http.Header
-	healthMethod       string
 	router             http.Handler
+	buildInfo          *buildInfo
+	buildInfoLock      sync.Mutex
 }
 
 // NewClient returns a new Client Instance
@@ -89,6 +94,16 @@ func NewClient(name string, oc *oo.Options, router http.Handler,
 		webClient: c, baseUpstreamURL: bur}, err
 }
 
+func init() {
+	origins.MustRegisterProvider("prometheus", newProviderClient)
+}
+
+// newProviderClient is the origins.ProviderFunc for the prometheus origin type
+func newProviderClient(name string, oc *oo.Options, router http.Handler, c cache.Cache,
+	_ origins.Origins, _ *tl.Logger) (origins.Client, error) {
+	return NewClient(name, oc, router, c)
+}
+
 // SetCache sets the Cache object the client will use for caching origin content
 func (c *Client) SetCache(cc cache.Cache) {
 	c.cache = cc
@@ -119,6 +134,25 @@ func (c *Client) Router() http.Handler {
 	return c.router
 }
 
+// ImportantParams returns the query parameters that are semantically important to Prometheus.
+// Prometheus reads parameters with http.Request.FormValue, which honors the first occurrence
+func (c *Client) ImportantParams() ([]string, bool) {
+	return []string{upQuery, upStart, upEnd, upStep, upTime}, false
+}
+
+// TimeoutParam returns the query parameter in which Prometheus accepts a query timeout
+func (c *Client) TimeoutParam() string {
+	return upTimeout
+}
+
+// DefaultAuthCachePolicy returns the policy for caching responses to authenticated requests when a
+// path does not configure one. Prometheus has no users or permissions of its own, so any
+// authentication is done in front of it and every authorized requestor sees the same results,
+// which are shared
+func (c *Client) DefaultAuthCachePolicy() authcache.Policy {
+	return authcache.PolicyShared
+}
+
 // parseTime converts a query time URL parameter to time.Time.
 // Copied from https://github.com/prometheus/prometheus/blob/master/web/api/v1/api.go
 func parseTime(s string) (time.Time, error) {
@@ -140,8 +174,9 @@ func parseDuration(input string) (time.Duration, error) {
 	if err != nil {
 		return tt.ParseDuration(input)
 	}
-	// assume v is in seconds
-	return time.Duration(int64(v)) * time.Second, nil
+	// assume v is in seconds. fractional steps are retained to the millisecond, as Prometheus
+	// retains them, so that the query's steps are aligned to the timestamps that it evaluates
+	return time.Duration(math.Round(v*1000)) * time.Millisecond, nil
 }
 
 // ParseTimeRangeQuery parses the key parts of a TimeRangeQuery from the inbound HTTP Request
@@ -195,5 +230,11 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 		trq.FastForwardDisable = true
 	}
 
+	// each point is computed from the samples within the query's ranges and lookback delta before it
+	trq.Lookback = queryLookback(trq.Statement)
+	if c.config != nil {
+		trq.Lookback += c.config.LookbackDelta
+	}
+
 	return trq, nil
 }
@@ -21,12 +21,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
 
 	cr "github.com/tricksterproxy/trickster/pkg/cache/registration"
 	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
 	pe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
@@ -115,6 +117,28 @@ func TestParseTimeFails(t *testing.T) {
 	}
 }
 
+func TestParseDuration(t *testing.T) {
+	fixtures := []struct {
+		input  string
+		output time.Duration
+	}{
+		{"15", 15 * time.Second},
+		{"1.5", 1500 * time.Millisecond},
+		{"0.001", time.Millisecond},
+		{"5m", 5 * time.Minute},
+	}
+
+	for _, f := range fixtures {
+		out, err := parseDuration(f.input)
+		if err != nil {
+			t.Error(err)
+		}
+		if out != f.output {
+			t.Errorf("Expected %s, got %s for input %s", f.output, out, f.input)
+		}
+	}
+}
+
 func TestConfiguration(t *testing.T) {
 	oc := &oo.Options{OriginType: "TEST"}
 	client := Client{config: oc}
@@ -169,6 +193,26 @@ func TestName(t *testing.T) {
 
 }
 
+func TestImportantParams(t *testing.T) {
+	// Prometheus reads parameters with FormValue, which honors the first occurrence
+	client := &Client{}
+	names, useLast := client.ImportantParams()
+	expected := []string{"query", "start", "end", "step", "time"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v got %v", expected, names)
+	}
+	if useLast {
+		t.Error("expected first occurrence to be honored")
+	}
+}
+
+func TestDefaultAuthCachePolicy(t *testing.T) {
+	client := &Client{}
+	if p := client.DefaultAuthCachePolicy(); p != authcache.PolicyShared {
+		t.Errorf("expected %s got %s", authcache.PolicyShared, p)
+	}
+}
+
 func TestParseTimeRangeQuery(t *testing.T) {
 
 	qp := url.Values(map[string][]string{
@@ -36,6 +36,7 @@ func (c *Client) registerHandlers() {
 	c.handlers["query"] = http.HandlerFunc(c.QueryHandler)
 	c.handlers["series"] = http.HandlerFunc(c.SeriesHandler)
 	c.handlers["proxycache"] = http.HandlerFunc(c.ObjectProxyCacheHandler)
+	c.handlers["buildinfo"] = http.HandlerFunc(c.BuildInfoHandler)
 	c.handlers["proxy"] = http.HandlerFunc(c.ProxyHandler)
 }
 
@@ -47,23 +48,9 @@ func (c *Client) Handlers() map[string]http.Handler {
 	return c.handlers
 }
 
-func populateHeathCheckRequestValues(oc *oo.Options) {
-	if oc.HealthCheckUpstreamPath == "-" {
-		oc.HealthCheckUpstreamPath = APIPath + mnQuery
-	}
-	if oc.HealthCheckVerb == "-" {
-		oc.HealthCheckVerb = http.MethodGet
-	}
-	if oc.HealthCheckQuery == "-" {
-		oc.HealthCheckQuery = "query=up"
-	}
-}
-
 // DefaultPathConfigs returns the default PathConfigs for the given OriginType
 func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 
-	populateHeathCheckRequestValues(oc)
-
 	var rhts map[string]string
 	if oc != nil {
 		rhts = map[string]string{
@@ -71,14 +58,24 @@ func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 	}
 	rhinst := map[string]string{
 		headers.NameCacheControl: fmt.Sprintf("%s=%d", headers.ValueSharedMaxAge, 30)}
+	// build information and flags only change when the origin restarts, and are
+	// requested by datasource health checks each time a dashboard is opened
+	rhstatus := map[string]string{
+		headers.NameCacheControl: fmt.Sprintf("%s=%d", headers.ValueSharedMaxAge, buildInfoTTLSecs)}
+
+	bih := "proxycache"
+	if oc != nil && oc.LocalBuildInfo {
+		bih = "buildinfo"
+	}
 
 	paths := map[string]*po.Options{
 
 		APIPath + mnQueryRange: {
-			Path:            APIPath + mnQueryRange,
-			HandlerName:     mnQueryRange,
-			Methods:         []string{http.MethodGet, http.MethodPost},
-			CacheKeyParams:  []string{upQuery, upStep},
+			Path:        APIPath + mnQueryRange,
+			HandlerName: mnQueryRange,
+			Methods:     []string{http.MethodGet, http.MethodPost},
+			// the step is always included in the cache key by the Delta Proxy Cache
+			CacheKeyParams:  []string{upQuery},
 			CacheKeyHeaders: []string{},
 			ResponseHeaders: rhts,
 			MatchTypeName:   "exact",
@@ -195,6 +192,28 @@ func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 			ResponseHeaders: rhinst,
 		},
 
+		APIPath + mnBuildInfo: {
+			Path:            APIPath + mnBuildInfo,
+			HandlerName:     bih,
+			Methods:         []string{http.MethodGet},
+			CacheKeyParams:  []string{},
+			CacheKeyHeaders: []string{},
+			ResponseHeaders: rhstatus,
+			MatchTypeName:   "exact",
+			MatchType:       matching.PathMatchTypeExact,
+		},
+
+		APIPath + mnFlags: {
+			Path:            APIPath + mnFlags,
+			HandlerName:     "proxycache",
+			Methods:         []string{http.MethodGet},
+			CacheKeyParams:  []string{},
+			CacheKeyHeaders: []string{},
+			ResponseHeaders: rhstatus,
+			MatchTypeName:   "exact",
+			MatchType:       matching.PathMatchTypeExact,
+		},
+
 		APIPath: {
 			Path:          APIPath,
 			HandlerName:   "proxy",
@@ -59,9 +59,19 @@ func TestDefaultPathConfigs(t *testing.T) {
 		t.Errorf("expected to find path named: %s", "/")
 	}
 
-	const expectedLen = 13
+	const expectedLen = 15
 	if len(dpc) != expectedLen {
 		t.Errorf("expected ordered length to be: %d got %d", expectedLen, len(dpc))
 	}
 
+	if h := dpc[APIPath+mnBuildInfo].HandlerName; h != "proxycache" {
+		t.Errorf("expected %s got %s", "proxycache", h)
+	}
+
+	client.config.LocalBuildInfo = true
+	dpc = client.DefaultPathConfigs(client.config)
+	if h := dpc[APIPath+mnBuildInfo].HandlerName; h != "buildinfo" {
+		t.Errorf("expected %s got %s", "buildinfo", h)
+	}
+
 }
@@ -0,0 +1,435 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+
+	"github.com/prometheus/common/model"
+)
+
+// Query splitting supports the following subset of PromQL. Any other query is cached whole.
+//
+//   query     = operand op operand { op operand }    (every op in the query is the same)
+//   op        = "or" | "and" | "unless" | "+" | "-" | "*" | "/"
+//   operand   = selector | aggregate | call
+//   selector  = metric [ "{" matchers "}" ] | "{" matchers "}"
+//   aggregate = aggregation [ grouping ] "(" args ")" [ grouping ]    (at most one grouping)
+//   call      = function "(" args ")"
+//   grouping  = ( "by" | "without" ) "(" labels ")"
+//
+// where aggregation is one of splitAggregations and function is one of splitFunctions, each of
+// which always evaluates to an instant vector. The contents of matchers, args and labels are not
+// inspected, since each operand is evaluated by the origin. Vector matching modifiers (on, ignoring,
+// group_left, group_right), the bool modifier, offset and @ modifiers, comments, parenthesized
+// operands, number literals and all other operators are outside of the subset.
+//
+// The results of the operands are combined with PromQL's default vector matching, in which the
+// samples of the operands at each timestamp are matched on all of their labels except the metric
+// name. Arithmetic operators require one-to-one matching and drop the metric name, while the set
+// operators retain the labels of the samples they select.
+
+const (
+	splitOpOr     = "or"
+	splitOpAnd    = "and"
+	splitOpUnless = "unless"
+	splitOpAdd    = "+"
+	splitOpSub    = "-"
+	splitOpMul    = "*"
+	splitOpDiv    = "/"
+)
+
+// splitAggregations is the list of aggregation operators that an operand may apply
+var splitAggregations = map[string]bool{
+	"sum": true, "min": true, "max": true, "avg": true, "group": true, "stddev": true,
+	"stdvar": true, "count": true, "count_values": true, "bottomk": true, "topk": true,
+	"quantile": true,
+}
+
+// splitFunctions is the list of functions that an operand may call, each of which evaluates
+// to an instant vector
+var splitFunctions = map[string]bool{
+	"abs": true, "absent": true, "absent_over_time": true, "avg_over_time": true, "ceil": true,
+	"changes": true, "clamp": true, "clamp_max": true, "clamp_min": true, "count_over_time": true,
+	"day_of_month": true, "day_of_week": true, "days_in_month": true, "delta": true, "deriv": true,
+	"exp": true, "floor": true, "histogram_quantile": true, "holt_winters": true, "hour": true,
+	"idelta": true, "increase": true, "irate": true, "label_join": true, "label_replace": true,
+	"last_over_time": true, "ln": true, "log10": true, "log2": true, "max_over_time": true,
+	"min_over_time": true, "minute": true, "month": true, "predict_linear": true,
+	"present_over_time": true, "quantile_over_time": true, "rate": true, "resets": true,
+	"round": true, "sgn": true, "sort": true, "sort_desc": true, "sqrt": true,
+	"stddev_over_time": true, "stdvar_over_time": true, "sum_over_time": true, "timestamp": true,
+	"vector": true, "year": true,
+}
+
+// splitUnsupportedWords is the list of keywords that place a query outside of the subset
+var splitUnsupportedWords = map[string]bool{
+	"on": true, "ignoring": true, "group_left": true, "group_right": true, "bool": true,
+	"offset": true, "atan2": true,
+}
+
+// SplitQuery returns a request for each operand of the request's query, and the combiner of their
+// results, when the query is within the subset of PromQL that can be split
+func (c *Client) SplitQuery(r *http.Request,
+	trq *timeseries.TimeRangeQuery) ([]*http.Request, origins.TimeseriesCombiner, bool) {
+	operands, op, ok := splitQuery(trq.Statement)
+	if !ok {
+		return nil, nil, false
+	}
+	v, _, _ := params.GetRequestValues(r)
+	reqs := make([]*http.Request, len(operands))
+	for i, q := range operands {
+		sv := make(url.Values, len(v))
+		for k, vals := range v {
+			sv[k] = vals
+		}
+		sv.Set(upQuery, q)
+		sr := r.Clone(r.Context())
+		// the parsed form of a POST request is cloned, and must be parsed again from the new body
+		sr.Form, sr.PostForm = nil, nil
+		params.SetRequestValues(sr, sv)
+		reqs[i] = sr
+	}
+	return reqs, func(results []timeseries.Timeseries) (timeseries.Timeseries, error) {
+		return combineResults(op, results)
+	}, true
+}
+
+// splitQuery returns the operands of the query and the operator that combines them, when the
+// query is within the subset of PromQL that can be split
+func splitQuery(query string) ([]string, string, bool) {
+	var operands []string
+	var op string
+	var start int
+	// next records an operator found at query[i:j], and the operand that precedes it
+	next := func(o string, i, j int) bool {
+		if op != "" && op != o {
+			return false
+		}
+		operand := strings.TrimSpace(query[start:i])
+		if operand == "" {
+			return false
+		}
+		op = o
+		operands = append(operands, operand)
+		start = j
+		return true
+	}
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			i = skipQuoted(query, i) + 1
+		case c == '(' || c == '{' || c == '[':
+			j := closingBracket(query, i)
+			if j < 0 {
+				return nil, "", false
+			}
+			i = j + 1
+		case isWordChar(c):
+			j := wordEnd(query, i)
+			w := query[i:j]
+			switch {
+			case w == splitOpOr || w == splitOpAnd || w == splitOpUnless:
+				if !next(w, i, j) {
+					return nil, "", false
+				}
+			case splitUnsupportedWords[w]:
+				return nil, "", false
+			}
+			i = j
+		case c == '+' || c == '-' || c == '*' || c == '/':
+			if !next(string(c), i, i+1) {
+				return nil, "", false
+			}
+			i++
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		default:
+			return nil, "", false
+		}
+	}
+	if op == "" {
+		return nil, "", false
+	}
+	operand := strings.TrimSpace(query[start:])
+	if operand == "" {
+		return nil, "", false
+	}
+	operands = append(operands, operand)
+	for _, o := range operands {
+		if !isSplitOperand(o) {
+			return nil, "", false
+		}
+	}
+	return operands, op, true
+}
+
+// isSplitOperand returns true if the operand is a selector, aggregate or call in the subset
+func isSplitOperand(s string) bool {
+	j := wordEnd(s, 0)
+	if j == 0 {
+		return s[0] == '{' && closingBracket(s, 0) == len(s)-1
+	}
+	name, rest := s[:j], strings.TrimSpace(s[j:])
+	if name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	switch {
+	case splitAggregations[name]:
+		rest, grouped := trimGrouping(rest)
+		if rest == "" || rest[0] != '(' {
+			return false
+		}
+		k := closingBracket(rest, 0)
+		if k < 0 {
+			return false
+		}
+		rest = strings.TrimSpace(rest[k+1:])
+		if rest == "" {
+			return true
+		}
+		rest, ok := trimGrouping(rest)
+		return !grouped && ok && rest == ""
+	case splitFunctions[name]:
+		return rest != "" && rest[0] == '(' && closingBracket(rest, 0) == len(rest)-1
+	case splitUnsupportedWords[name]:
+		return false
+	}
+	return rest == "" || (rest[0] == '{' && closingBracket(rest, 0) == len(rest)-1)
+}
+
+// trimGrouping returns s without a leading by or without clause, and true if it had one
+func trimGrouping(s string) (string, bool) {
+	j := wordEnd(s, 0)
+	if w := s[:j]; w != "by" && w != "without" {
+		return s, false
+	}
+	rest := strings.TrimSpace(s[j:])
+	if rest == "" || rest[0] != '(' {
+		return s, false
+	}
+	k := closingBracket(rest, 0)
+	if k < 0 {
+		return s, false
+	}
+	return strings.TrimSpace(rest[k+1:]), true
+}
+
+// closingBracket returns the index of the bracket that closes the bracket at index i, or -1
+func closingBracket(s string, i int) int {
+	var depth int
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'', '`':
+			i = skipQuoted(s, i)
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') || c == '.'
+}
+
+// wordEnd returns the index following the word that begins at index i
+func wordEnd(s string, i int) int {
+	for i < len(s) && isWordChar(s[i]) {
+		i++
+	}
+	return i
+}
+
+// splitSample is a sample of an instant vector that is combined by a split query's operator
+type splitSample struct {
+	metric model.Metric
+	value  model.SampleValue
+}
+
+// combineResults folds the Matrix results of a split query's operands with the operator
+func combineResults(op string, results []timeseries.Timeseries) (timeseries.Timeseries, error) {
+	var combined *MatrixEnvelope
+	for _, ts := range results {
+		me, ok := ts.(*MatrixEnvelope)
+		if !ok || me.Data.ResultType != "matrix" {
+			return nil, fmt.Errorf("cannot combine %T as a matrix", ts)
+		}
+		if combined == nil {
+			combined = &MatrixEnvelope{Status: me.Status, StepDuration: me.StepDuration,
+				Data: MatrixData{ResultType: me.Data.ResultType, Result: me.Data.Result}}
+			continue
+		}
+		m, err := combineMatrices(op, combined.Data.Result, me.Data.Result)
+		if err != nil {
+			return nil, err
+		}
+		combined.Data.Result = m
+	}
+	if combined == nil {
+		return nil, fmt.Errorf("no results to combine")
+	}
+	return combined, nil
+}
+
+// combineMatrices applies the operator to the instant vectors of the matrices at each timestamp
+func combineMatrices(op string, lhs, rhs model.Matrix) (model.Matrix, error) {
+	lv, rv := vectorsByTime(lhs), vectorsByTime(rhs)
+	times := make([]model.Time, 0, len(lv)+len(rv))
+	for t := range lv {
+		times = append(times, t)
+	}
+	for t := range rv {
+		if _, ok := lv[t]; !ok {
+			times = append(times, t)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	streams := make(map[model.Fingerprint]*model.SampleStream)
+	for _, t := range times {
+		v, err := combineVectors(op, lv[t], rv[t])
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range v {
+			fp := s.metric.Fingerprint()
+			ss, ok := streams[fp]
+			if !ok {
+				ss = &model.SampleStream{Metric: s.metric}
+				streams[fp] = ss
+			}
+			ss.Values = append(ss.Values, model.SamplePair{Timestamp: t, Value: s.value})
+		}
+	}
+
+	m := make(model.Matrix, 0, len(streams))
+	for _, ss := range streams {
+		m = append(m, ss)
+	}
+	sort.Slice(m, func(i, j int) bool { return m[i].Metric.Before(m[j].Metric) })
+	return m, nil
+}
+
+// vectorsByTime returns the instant vectors of the matrix, by timestamp
+func vectorsByTime(m model.Matrix) map[model.Time][]splitSample {
+	v := make(map[model.Time][]splitSample)
+	for _, ss := range m {
+		for _, p := range ss.Values {
+			v[p.Timestamp] = append(v[p.Timestamp], splitSample{metric: ss.Metric, value: p.Value})
+		}
+	}
+	return v
+}
+
+// combineVectors applies the operator to a pair of instant vectors
+func combineVectors(op string, lhs, rhs []splitSample) ([]splitSample, error) {
+	var out []splitSample
+	switch op {
+	case splitOpOr, splitOpAnd, splitOpUnless:
+		sigs := make(map[model.Fingerprint]bool, len(rhs))
+		if op == splitOpOr {
+			out = append(out, lhs...)
+			for _, s := range lhs {
+				sigs[matchSignature(s.metric)] = true
+			}
+			for _, s := range rhs {
+				if !sigs[matchSignature(s.metric)] {
+					out = append(out, s)
+				}
+			}
+			return out, nil
+		}
+		for _, s := range rhs {
+			sigs[matchSignature(s.metric)] = true
+		}
+		for _, s := range lhs {
+			if sigs[matchSignature(s.metric)] == (op == splitOpAnd) {
+				out = append(out, s)
+			}
+		}
+		return out, nil
+	}
+
+	rs := make(map[model.Fingerprint]splitSample, len(rhs))
+	for _, s := range rhs {
+		sig := matchSignature(s.metric)
+		if _, ok := rs[sig]; ok {
+			return nil, fmt.Errorf("found duplicate series for the match group on the right hand-side")
+		}
+		rs[sig] = s
+	}
+	seen := make(map[model.Fingerprint]bool, len(lhs))
+	for _, s := range lhs {
+		sig := matchSignature(s.metric)
+		if seen[sig] {
+			return nil, fmt.Errorf("found duplicate series for the match group on the left hand-side")
+		}
+		seen[sig] = true
+		r, ok := rs[sig]
+		if !ok {
+			continue
+		}
+		var v model.SampleValue
+		switch op {
+		case splitOpAdd:
+			v = s.value + r.value
+		case splitOpSub:
+			v = s.value - r.value
+		case splitOpMul:
+			v = s.value * r.value
+		case splitOpDiv:
+			v = s.value / r.value
+		default:
+			return nil, fmt.Errorf("unsupported operator %s", op)
+		}
+		out = append(out, splitSample{metric: withoutName(s.metric), value: v})
+	}
+	return out, nil
+}
+
+// matchSignature returns the fingerprint of the metric's labels other than its name, on which
+// the samples of the operands are matched
+func matchSignature(m model.Metric) model.Fingerprint {
+	return withoutName(m).Fingerprint()
+}
+
+// withoutName returns a copy of the metric without its name
+func withoutName(m model.Metric) model.Metric {
+	c := make(model.Metric, len(m))
+	for k, v := range m {
+		if k != model.MetricNameLabel {
+			c[k] = v
+		}
+	}
+	return c
+}
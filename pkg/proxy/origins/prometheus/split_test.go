@@ -0,0 +1,287 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestSplitQuery(t *testing.T) {
+
+	tests := []struct {
+		query    string
+		operands []string
+		op       string
+	}{
+		// supported operators
+		{`a or b`, []string{`a`, `b`}, splitOpOr},
+		{`a and b`, []string{`a`, `b`}, splitOpAnd},
+		{`a unless b`, []string{`a`, `b`}, splitOpUnless},
+		{`a + b`, []string{`a`, `b`}, splitOpAdd},
+		{`a - b`, []string{`a`, `b`}, splitOpSub},
+		{`a * b`, []string{`a`, `b`}, splitOpMul},
+		{`a / b`, []string{`a`, `b`}, splitOpDiv},
+		{`a or b or c`, []string{`a`, `b`, `c`}, splitOpOr},
+		{`a/b/c`, []string{`a`, `b`, `c`}, splitOpDiv},
+		// supported operands
+		{`up{job="a"} or up{job="b"}`, []string{`up{job="a"}`, `up{job="b"}`}, splitOpOr},
+		{`{__name__="a"} or b`, []string{`{__name__="a"}`, `b`}, splitOpOr},
+		{`job:requests:rate5m or b`, []string{`job:requests:rate5m`, `b`}, splitOpOr},
+		{`sum(rate(a[5m])) or sum(rate(b[5m]))`,
+			[]string{`sum(rate(a[5m]))`, `sum(rate(b[5m]))`}, splitOpOr},
+		{`sum by (job) (rate(a[5m])) / sum(rate(b[5m])) without (instance)`,
+			[]string{`sum by (job) (rate(a[5m]))`, `sum(rate(b[5m])) without (instance)`}, splitOpDiv},
+		{`topk(5, a) or vector(0)`, []string{`topk(5, a)`, `vector(0)`}, splitOpOr},
+		{`histogram_quantile(0.9, sum by (le) (rate(a_bucket[5m]))) or b`,
+			[]string{`histogram_quantile(0.9, sum by (le) (rate(a_bucket[5m])))`, `b`}, splitOpOr},
+		// operators within brackets, strings and label names are not split
+		{`sum(a + b) or c`, []string{`sum(a + b)`, `c`}, splitOpOr},
+		{`a{x="1 or 2"} or b{y='/'}`, []string{`a{x="1 or 2"}`, `b{y='/'}`}, splitOpOr},
+		{`a{or="x"} or b`, []string{`a{or="x"}`, `b`}, splitOpOr},
+		{"a\n  or\n  b", []string{`a`, `b`}, splitOpOr},
+		{`ordinal or b`, []string{`ordinal`, `b`}, splitOpOr},
+
+		// unsupported queries
+		{`a`, nil, ""},
+		{`sum(rate(a[5m]))`, nil, ""},
+		{``, nil, ""},
+		{`a or b and c`, nil, ""},
+		{`a + b * c`, nil, ""},
+		{`a + on(job) b`, nil, ""},
+		{`a or ignoring(job) b`, nil, ""},
+		{`a * on(job) group_left b`, nil, ""},
+		{`a > bool b`, nil, ""},
+		{`a > b`, nil, ""},
+		{`a == b`, nil, ""},
+		{`a != b`, nil, ""},
+		{`a % b`, nil, ""},
+		{`a ^ b`, nil, ""},
+		{`a atan2 b`, nil, ""},
+		{`a offset 5m or b`, nil, ""},
+		{`a @ 1609746000 or b`, nil, ""},
+		{`a or b # comment`, nil, ""},
+		{`(a) or b`, nil, ""},
+		{`a or 1`, nil, ""},
+		{`2 * a`, nil, ""},
+		{`a * 1e-3`, nil, ""},
+		{`-a + b`, nil, ""},
+		{`a + -b`, nil, ""},
+		{`a or`, nil, ""},
+		{`or a`, nil, ""},
+		{`a[5m] or b`, nil, ""},
+		{`scalar(a) * b`, nil, ""},
+		{`time() - a`, nil, ""},
+		{`sum by (job) (a) by (instance) or b`, nil, ""},
+		{`sum by job (a) or b`, nil, ""},
+		{`rate(a[5m]) (b) or c`, nil, ""},
+		{`a{job="x" or b`, nil, ""},
+		{`a) or b`, nil, ""},
+		{`sum(a or b`, nil, ""},
+		{`a, b`, nil, ""},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			operands, op, ok := splitQuery(test.query)
+			if ok != (test.operands != nil) {
+				t.Fatalf("%s: expected %t got %t", test.query, test.operands != nil, ok)
+			}
+			if !reflect.DeepEqual(operands, test.operands) {
+				t.Errorf("%s: expected %v got %v", test.query, test.operands, operands)
+			}
+			if op != test.op {
+				t.Errorf("%s: expected %s got %s", test.query, test.op, op)
+			}
+		})
+	}
+}
+
+func TestClientSplitQuery(t *testing.T) {
+
+	client := &Client{}
+	for _, method := range []string{"GET", "POST"} {
+		const u = "http://0/api/v1/query_range"
+		const q = "query=a+or+b&start=0&end=60&step=15"
+		r := httptest.NewRequest(method, u+"?"+q, nil)
+		if method == "POST" {
+			r = httptest.NewRequest(method, u, strings.NewReader(q))
+			r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+		}
+		trq, err := client.ParseTimeRangeQuery(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs, combine, ok := client.SplitQuery(r, trq)
+		if !ok || combine == nil || len(reqs) != 2 {
+			t.Fatalf("%s: expected %d requests got %d", method, 2, len(reqs))
+		}
+		for i, expected := range []string{"a", "b"} {
+			strq, err := client.ParseTimeRangeQuery(reqs[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strq.Statement != expected || !strq.Extent.End.Equal(trq.Extent.End) || strq.Step != trq.Step {
+				t.Errorf("%s: unexpected sub-query %s", method, strq.String())
+			}
+		}
+		// the request's own query is unchanged
+		if method == "POST" {
+			b, _ := ioutil.ReadAll(r.Body)
+			if !strings.Contains(string(b), "query=a+or+b") {
+				t.Errorf("unexpected request body %s", string(b))
+			}
+		} else if r.URL.Query().Get(upQuery) != "a or b" {
+			t.Errorf("unexpected request query %s", r.URL.RawQuery)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "http://0/api/v1/query_range?query=a&start=0&end=60&step=15", nil)
+	trq, _ := client.ParseTimeRangeQuery(r)
+	if _, _, ok := client.SplitQuery(r, trq); ok {
+		t.Error("expected query not to be split")
+	}
+}
+
+// testMatrix returns a matrix of the series, each of which is a metric's labels
+// followed by its values at timestamps 0, 15 and 30. NaN values are omitted
+func testMatrix(series ...[]interface{}) model.Matrix {
+	m := model.Matrix{}
+	for _, s := range series {
+		ss := &model.SampleStream{Metric: s[0].(model.Metric)}
+		for i, v := range s[1:] {
+			if f := v.(float64); !math.IsNaN(f) {
+				ss.Values = append(ss.Values,
+					model.SamplePair{Timestamp: model.Time(i * 15000), Value: model.SampleValue(f)})
+			}
+		}
+		m = append(m, ss)
+	}
+	return m
+}
+
+func TestCombineMatrices(t *testing.T) {
+
+	nan := math.NaN()
+	a1 := model.Metric{"__name__": "a", "job": "1"}
+	a2 := model.Metric{"__name__": "a", "job": "2"}
+	b1 := model.Metric{"__name__": "b", "job": "1"}
+	b3 := model.Metric{"__name__": "b", "job": "3"}
+	j1 := model.Metric{"job": "1"}
+
+	lhs := testMatrix([]interface{}{a1, 1.0, 2.0, nan}, []interface{}{a2, 3.0, nan, 4.0})
+	rhs := testMatrix([]interface{}{b1, 10.0, nan, 20.0}, []interface{}{b3, 5.0, 6.0, 7.0})
+
+	tests := []struct {
+		op       string
+		expected model.Matrix
+	}{
+		// b1 is included only where a1 has no sample
+		{splitOpOr, testMatrix([]interface{}{a1, 1.0, 2.0, nan}, []interface{}{a2, 3.0, nan, 4.0},
+			[]interface{}{b1, nan, nan, 20.0}, []interface{}{b3, 5.0, 6.0, 7.0})},
+		{splitOpAnd, testMatrix([]interface{}{a1, 1.0, nan, nan})},
+		{splitOpUnless, testMatrix([]interface{}{a1, nan, 2.0, nan}, []interface{}{a2, 3.0, nan, 4.0})},
+		// arithmetic drops the metric name, and requires samples on both sides
+		{splitOpAdd, testMatrix([]interface{}{j1, 11.0, nan, nan})},
+		{splitOpSub, testMatrix([]interface{}{j1, -9.0, nan, nan})},
+		{splitOpMul, testMatrix([]interface{}{j1, 10.0, nan, nan})},
+		{splitOpDiv, testMatrix([]interface{}{j1, 0.1, nan, nan})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.op, func(t *testing.T) {
+			m, err := combineMatrices(test.op, lhs, rhs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(m, test.expected) {
+				t.Errorf("expected %s got %s", test.expected.String(), m.String())
+			}
+		})
+	}
+
+	// division by zero follows floating point semantics
+	m, err := combineMatrices(splitOpDiv, testMatrix([]interface{}{a1, 1.0, nan, nan}),
+		testMatrix([]interface{}{b1, 0.0, nan, nan}))
+	if err != nil || len(m) != 1 || !math.IsInf(float64(m[0].Values[0].Value), 1) {
+		t.Errorf("expected +Inf got %v", m)
+	}
+
+	// one-to-one matching fails when either side has more than one sample in a match group
+	dup := testMatrix([]interface{}{a1, 1.0, nan, nan}, []interface{}{b1, 2.0, nan, nan})
+	if _, err := combineMatrices(splitOpAdd, lhs, dup); err == nil {
+		t.Error("expected error for duplicate right hand-side series")
+	}
+	if _, err := combineMatrices(splitOpAdd, dup, rhs); err == nil {
+		t.Error("expected error for duplicate left hand-side series")
+	}
+	if _, err := combineMatrices("%", lhs, rhs); err == nil {
+		t.Error("expected error for unsupported operator")
+	}
+	// set operators permit duplicate match groups
+	if _, err := combineMatrices(splitOpOr, dup, dup); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCombineResults(t *testing.T) {
+
+	a := testMatrix([]interface{}{model.Metric{"__name__": "a"}, 1.0, 2.0, 3.0})
+	b := testMatrix([]interface{}{model.Metric{"__name__": "b"}, 4.0, 5.0, 6.0})
+	c := testMatrix([]interface{}{model.Metric{"__name__": "c"}, 2.0, 2.0, 2.0})
+	envelope := func(m model.Matrix) *MatrixEnvelope {
+		return &MatrixEnvelope{Status: "success", Data: MatrixData{ResultType: "matrix", Result: m}}
+	}
+
+	// operators are applied from left to right
+	ts, err := combineResults(splitOpSub, []timeseries.Timeseries{envelope(b), envelope(a), envelope(c)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testMatrix([]interface{}{model.Metric{}, 1.0, 1.0, 1.0})
+	if me := ts.(*MatrixEnvelope); me.Status != "success" || me.Data.ResultType != "matrix" ||
+		!reflect.DeepEqual(me.Data.Result, expected) {
+		t.Errorf("expected %s got %s", expected.String(), me.Data.Result.String())
+	}
+
+	if _, err := combineResults(splitOpOr, nil); err == nil {
+		t.Error("expected error for no results")
+	}
+	if _, err := combineResults(splitOpOr, []timeseries.Timeseries{envelope(a),
+		&MatrixEnvelope{Data: MatrixData{ResultType: "vector"}}}); err == nil {
+		t.Error("expected error for non-matrix result")
+	}
+	if _, err := combineResults(splitOpAdd, []timeseries.Timeseries{envelope(a),
+		envelope(testMatrix([]interface{}{model.Metric{"x": "1"}, 1.0, nan(), nan()},
+			[]interface{}{model.Metric{"x": "1", "__name__": "y"}, 1.0, nan(), nan()}))}); err == nil {
+		t.Error("expected error for duplicate series")
+	}
+}
+
+func nan() float64 {
+	return math.NaN()
+}
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/proxy/params"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
@@ -29,11 +30,21 @@ import (
 // SetExtent will change the upstream request query to use the provided Extent
 func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
 	v, _, _ := params.GetRequestValues(r)
-	v.Set(upStart, strconv.FormatInt(extent.Start.Unix(), 10))
-	v.Set(upEnd, strconv.FormatInt(extent.End.Unix(), 10))
+	v.Set(upStart, formatTimestamp(extent.Start))
+	v.Set(upEnd, formatTimestamp(extent.End))
 	params.SetRequestValues(r, v)
 }
 
+// formatTimestamp returns the time as a number of seconds, including milliseconds when the time
+// is between seconds, such as at the boundary of a fractional step
+func formatTimestamp(t time.Time) string {
+	ms := t.UnixNano() / int64(time.Millisecond)
+	if ms%1000 == 0 {
+		return strconv.FormatInt(ms/1000, 10)
+	}
+	return strconv.FormatFloat(float64(ms)/1000, 'f', 3, 64)
+}
+
 // FastForwardRequest returns an *http.Request crafted to collect Fast Forward
 // data from the Origin, based on the provided HTTP Request
 func (c *Client) FastForwardRequest(r *http.Request) (*http.Request, error) {
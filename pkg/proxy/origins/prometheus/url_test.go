@@ -31,8 +31,8 @@ import (
 
 func TestSetExtent(t *testing.T) {
 
-	start := time.Now().Add(time.Duration(-6) * time.Hour)
-	end := time.Now()
+	end := time.Now().Truncate(time.Second)
+	start := end.Add(time.Duration(-6) * time.Hour)
 
 	startSecs := fmt.Sprintf("%d", start.Unix())
 	endSecs := fmt.Sprintf("%d", end.Unix())
@@ -69,6 +69,14 @@ func TestSetExtent(t *testing.T) {
 		t.Errorf("expected 31 got %d", r.ContentLength)
 	}
 
+	// extents between seconds, such as those of fractional steps, retain their milliseconds
+	e = &timeseries.Extent{Start: time.Unix(1600000000, 500000000), End: time.Unix(1600000003, 0)}
+	r, _ = http.NewRequest(http.MethodGet, u.String(), nil)
+	client.SetExtent(r, nil, e)
+	expected = "end=1600000003&q=up&start=1600000000.500"
+	if expected != r.URL.RawQuery {
+		t.Errorf("\nexpected [%s]\ngot [%s]", expected, r.URL.RawQuery)
+	}
 }
 
 func TestFastForwardURL(t *testing.T) {
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package origins
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// ProviderFunc returns a new Client for the named origin, which handles requests with the
+// provided router and caches responses in the provided cache. clients contains the Clients
+// that are already registered for the running configuration, for use by providers that route
+// requests to other origins
+type ProviderFunc func(name string, oc *oo.Options, router http.Handler, c cache.Cache,
+	clients Origins, logger *tl.Logger) (Client, error)
+
+// ErrInvalidProvider is returned when a provider is registered without a name or function
+var ErrInvalidProvider = errors.New("origin provider registrations require a name and function")
+
+var providers = make(map[string]ProviderFunc)
+var providersLock sync.RWMutex
+
+// RegisterProvider registers the ProviderFunc as the provider of Clients for origins having
+// the provided origin type. Providers, including those maintained outside of Trickster, must
+// be registered before the configuration is validated, such as in the init() function of the
+// provider's package. Origin type names are not case-sensitive
+func RegisterProvider(originType string, f ProviderFunc) error {
+	originType = strings.ToLower(originType)
+	if originType == "" || f == nil {
+		return ErrInvalidProvider
+	}
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	if _, ok := providers[originType]; ok {
+		return fmt.Errorf("origin provider already registered for origin type %s", originType)
+	}
+	providers[originType] = f
+	return nil
+}
+
+// MustRegisterProvider registers the ProviderFunc as the provider of Clients for the origin
+// type, and panics if the registration fails
+func MustRegisterProvider(originType string, f ProviderFunc) {
+	if err := RegisterProvider(originType, f); err != nil {
+		panic(err)
+	}
+}
+
+// LookupProvider returns the provider registered for the origin type
+func LookupProvider(originType string) (ProviderFunc, bool) {
+	providersLock.RLock()
+	f, ok := providers[strings.ToLower(originType)]
+	providersLock.RUnlock()
+	return f, ok
+}
+
+// ProviderNames returns the sorted list of origin types having registered providers
+func ProviderNames() []string {
+	providersLock.RLock()
+	names := make([]string, 0, len(providers))
+	for k := range providers {
+		names = append(names, k)
+	}
+	providersLock.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// NewClient returns a new Client for the named origin from the provider registered for the
+// origin's type
+func NewClient(name string, oc *oo.Options, router http.Handler, c cache.Cache,
+	clients Origins, logger *tl.Logger) (Client, error) {
+	f, ok := LookupProvider(oc.OriginType)
+	if !ok {
+		return nil, UnknownProviderError(name, oc.OriginType)
+	}
+	return f(name, oc, router, c, clients, logger)
+}
+
+// UnknownProviderError returns an error for an origin configured with an origin type that
+// has no registered provider, which lists the known origin types
+func UnknownProviderError(name, originType string) error {
+	return fmt.Errorf("unknown origin type in origin config. originName: %s, originType: %s, knownTypes: %s",
+		name, originType, strings.Join(ProviderNames(), ", "))
+}
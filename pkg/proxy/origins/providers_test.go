@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package origins
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestRegisterProvider(t *testing.T) {
+
+	f := func(name string, oc *oo.Options, router http.Handler, c cache.Cache,
+		clients Origins, logger *tl.Logger) (Client, error) {
+		return &TestClient{}, nil
+	}
+
+	if err := RegisterProvider("", f); err != ErrInvalidProvider {
+		t.Errorf("expected %v got %v", ErrInvalidProvider, err)
+	}
+	if err := RegisterProvider("test-provider", nil); err != ErrInvalidProvider {
+		t.Errorf("expected %v got %v", ErrInvalidProvider, err)
+	}
+	if err := RegisterProvider("Test-Provider", f); err != nil {
+		t.Error(err)
+	}
+	if err := RegisterProvider("test-provider", f); err == nil {
+		t.Error("expected error for duplicate registration")
+	}
+
+	if _, ok := LookupProvider("TEST-PROVIDER"); !ok {
+		t.Error("expected provider lookups to be case-insensitive")
+	}
+	found := false
+	for _, n := range ProviderNames() {
+		if n == "test-provider" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected test-provider in %v", ProviderNames())
+	}
+
+	c, err := NewClient("test", &oo.Options{OriginType: "test-provider"}, nil, nil, nil, nil)
+	if err != nil {
+		t.Error(err)
+	} else if c.Name() != "test" {
+		t.Errorf("expected %s got %s", "test", c.Name())
+	}
+
+	_, err = NewClient("test", &oo.Options{OriginType: "foo"}, nil, nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "originType: foo, knownTypes: ") ||
+		!strings.Contains(err.Error(), "test-provider") {
+		t.Errorf("expected unknown provider error got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for duplicate registration")
+			}
+		}()
+		MustRegisterProvider("test-provider", f)
+	}()
+}
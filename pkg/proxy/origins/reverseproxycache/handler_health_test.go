@@ -43,15 +43,14 @@ func TestHealthHandler(t *testing.T) {
 		defer ts.Close()
 	}
 
-	client.healthURL = &url.URL{}
-	client.healthMethod = "-"
+	client.config.HealthCheckVerb = ""
 	client.HealthHandler(w, r)
 	resp := w.Result()
 	if resp.StatusCode != 400 {
 		t.Errorf("Expected status: 400 got %d.", resp.StatusCode)
 	}
 
-	client.healthURL = nil
+	client.config.HealthCheckVerb = "-"
 	client.HealthHandler(w, r)
 	w = httptest.NewRecorder()
 	resp = w.Result()
@@ -26,6 +26,7 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 )
 
 var _ origins.Client = (*Client)(nil)
@@ -39,9 +40,6 @@ type Client struct {
 	handlers           map[string]http.Handler
 	handlersRegistered bool
 	baseUpstreamURL    *url.URL
-	healthURL          *url.URL
-	healthMethod       string
-	healthHeaders      http.Header
 	router             http.Handler
 }
 
@@ -54,6 +52,17 @@ func NewClient(name string, oc *oo.Options, router http.Handler,
 		webClient: c, baseUpstreamURL: bur}, err
 }
 
+func init() {
+	origins.MustRegisterProvider("rpc", newProviderClient)
+	origins.MustRegisterProvider("reverseproxycache", newProviderClient)
+}
+
+// newProviderClient is the origins.ProviderFunc for the rpc and reverseproxycache origin types
+func newProviderClient(name string, oc *oo.Options, router http.Handler, c cache.Cache,
+	_ origins.Origins, _ *tl.Logger) (origins.Client, error) {
+	return NewClient(name, oc, router, c)
+}
+
 // Configuration returns the upstream Configuration for this Client
 func (c *Client) Configuration() *oo.Options {
 	return c.config
@@ -83,3 +92,9 @@ func (c *Client) SetCache(cc cache.Cache) {
 func (c *Client) Router() http.Handler {
 	return c.router
 }
+
+// ImportantParams returns no query parameters, since the semantics of parameters for a generic
+// HTTP origin are unknown, and duplicated parameters may be intentional (e.g., multi-value lists)
+func (c *Client) ImportantParams() ([]string, bool) {
+	return nil, false
+}
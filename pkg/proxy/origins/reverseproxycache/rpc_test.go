@@ -77,6 +77,15 @@ func TestClientName(t *testing.T) {
 	}
 }
 
+func TestImportantParams(t *testing.T) {
+	// generic HTTP origins may use duplicated parameters intentionally, so none are sanitized
+	c := &Client{}
+	names, useLast := c.ImportantParams()
+	if names != nil || useLast {
+		t.Errorf("expected nil, false got %v, %t", names, useLast)
+	}
+}
+
 func TestSetCache(t *testing.T) {
 	c, err := NewClient("test", oo.NewOptions(), nil, nil)
 	if err != nil {
@@ -28,6 +28,7 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 )
 
 // Client Implements the Proxy Client Interface
@@ -57,6 +58,20 @@ func NewClient(name string, options *oo.Options, router http.Handler,
 	}, nil
 }
 
+func init() {
+	origins.MustRegisterProvider("rule", newProviderClient)
+}
+
+// newProviderClient is the origins.ProviderFunc for the rule origin type
+func newProviderClient(name string, oc *oo.Options, router http.Handler, _ cache.Cache,
+	clients origins.Origins, _ *tl.Logger) (origins.Client, error) {
+	c, err := NewClient(name, oc, router, clients)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 // Clients is a list of *rule.Client
 type Clients []*Client
 
@@ -97,6 +97,12 @@ type CaseOptions struct {
 	// RedirectURL provides a URL to redirect the request in this case, rather than
 	// handing off to the NextRoute
 	RedirectURL string `toml:"redirect_url"`
+	// MaxLookbackSecs, when greater than 0, assigns a max lookback in seconds to requests in this case,
+	// which overrides that of the path that serves the request
+	MaxLookbackSecs int `toml:"max_lookback_secs"`
+	// Priority, when set, assigns a priority class to requests in this case ("high", "normal", "low"),
+	// which overrides that of the path that serves the request
+	Priority string `toml:"priority"`
 }
 
 // Clone returns a perfect copy of the subject *Options
@@ -20,10 +20,12 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/config/defaults"
 	"github.com/tricksterproxy/trickster/pkg/proxy/handlers"
 	ro "github.com/tricksterproxy/trickster/pkg/proxy/origins/rule/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
 )
 
@@ -166,6 +168,15 @@ func (c *Client) parseOptions(ro *ro.Options, rwi map[string]rewriter.RewriteIns
 				return fmt.Errorf("missing matches in rule %s case %s", ro.Name, k)
 			}
 
+			var pc *priority.Class
+			if v.Priority != "" {
+				c, ok := priority.Names[strings.ToLower(v.Priority)]
+				if !ok {
+					return fmt.Errorf("invalid priority %s in rule %s case %s", v.Priority, ro.Name, k)
+				}
+				pc = &c
+			}
+
 			rc := 0
 			if v.RedirectURL != "" {
 				rc = 302
@@ -186,6 +197,8 @@ func (c *Client) parseOptions(ro *ro.Options, rwi map[string]rewriter.RewriteIns
 					redirectURL:  v.RedirectURL,
 					redirectCode: rc,
 					rewriter:     ri,
+					maxLookback:  time.Duration(v.MaxLookbackSecs) * time.Second,
+					priority:     pc,
 				}
 				r.caseList = append(r.caseList, rc)
 				r.cases[m] = rc
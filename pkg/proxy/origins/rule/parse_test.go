@@ -221,6 +221,14 @@ func TestParseOptions(t *testing.T) {
 		t.Errorf("expected error for %s", expected)
 	}
 
+	expected = "invalid priority"
+	ropts.CaseOptions["1"].Priority = "urgent"
+	err = c.parseOptions(ropts, rwi)
+	ropts.CaseOptions["1"].Priority = ""
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected error for %s", expected)
+	}
+
 	expected = "unknown next_route"
 	temp = ropts.CaseOptions["1"].NextRoute
 	ropts.CaseOptions["1"].NextRoute = "invalid"
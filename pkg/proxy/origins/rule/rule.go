@@ -18,9 +18,11 @@ package rule
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/proxy/context"
 	"github.com/tricksterproxy/trickster/pkg/proxy/handlers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
 )
 
@@ -53,6 +55,8 @@ type ruleCase struct {
 	redirectURL  string
 	redirectCode int
 	rewriter     rewriter.RewriteInstructions
+	maxLookback  time.Duration
+	priority     *priority.Class
 }
 
 type caseMap map[string]*ruleCase
@@ -97,6 +101,16 @@ func (r *rule) EvaluateOpArg(hr *http.Request) (http.Handler, *http.Request, err
 			hr = hr.WithContext(handlers.WithRedirects(hr.Context(),
 				c.redirectCode, c.redirectURL))
 		}
+
+		// if this case assigns a max lookback, set the appropriate context
+		if c.maxLookback > 0 {
+			hr = hr.WithContext(context.WithMaxLookback(hr.Context(), c.maxLookback))
+		}
+
+		// if this case assigns a priority class, set the appropriate context
+		if c.priority != nil {
+			hr = hr.WithContext(context.WithPriority(hr.Context(), *c.priority))
+		}
 	}
 
 	if !nonDefault && r.defaultRewriter != nil {
@@ -158,6 +172,16 @@ func (r *rule) EvaluateCaseArg(hr *http.Request) (http.Handler, *http.Request, e
 				hr = hr.WithContext(handlers.WithRedirects(hr.Context(),
 					c.redirectCode, c.redirectURL))
 			}
+
+			// if this case assigns a max lookback, set the appropriate context
+			if c.maxLookback > 0 {
+				hr = hr.WithContext(context.WithMaxLookback(hr.Context(), c.maxLookback))
+			}
+
+			// if this case assigns a priority class, set the appropriate context
+			if c.priority != nil {
+				hr = hr.WithContext(context.WithPriority(hr.Context(), *c.priority))
+			}
 		}
 	}
 
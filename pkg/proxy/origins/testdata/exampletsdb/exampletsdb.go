@@ -0,0 +1,253 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package exampletsdb is an example of an origin provider maintained outside of Trickster's
+// source tree. It accelerates a fictional TSDB whose range query API is served at /api/range,
+// with the query in the q parameter, the time range in the from and to parameters (in epoch
+// seconds), and the step in the interval parameter (in seconds), and whose responses use
+// the Prometheus matrix format.
+//
+// A provider registers itself with the origins package from its init() function, so importing
+// the provider's package into the application that runs Trickster makes its origin type
+// available to the configuration:
+//
+//	import _ "example.com/trickster/exampletsdb"
+//
+// and an origin can then be configured with origin_type = 'exampletsdb'
+package exampletsdb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/proxy"
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	te "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	ho "github.com/tricksterproxy/trickster/pkg/proxy/healthcheck/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// OriginType is the origin type name under which the provider is registered
+const OriginType = "exampletsdb"
+
+const (
+	rangePath     = "/api/range"
+	upQuery       = "q"
+	upFrom        = "from"
+	upTo          = "to"
+	upInterval    = "interval"
+	healthPath    = "/api/health"
+	handlerRange  = "range"
+	handlerProxy  = "proxy"
+	handlerHealth = "health"
+)
+
+// ErrNoInstantaneousQueries is returned by UnmarshalInstantaneous, since the example TSDB
+// does not support instantaneous queries, and thus Fast Forward
+var ErrNoInstantaneousQueries = errors.New("exampletsdb does not support instantaneous queries")
+
+// Clients must implement origins.Client. Clients accelerated by the Delta Proxy Cache also
+// implement origins.TimeseriesClient, and Clients with upstream health checks implement
+// healthcheck.Checker
+var _ origins.TimeseriesClient = (*Client)(nil)
+
+// Client is the example TSDB's origins.TimeseriesClient
+type Client struct {
+	name            string
+	config          *oo.Options
+	cache           cache.Cache
+	webClient       *http.Client
+	handlers        map[string]http.Handler
+	baseUpstreamURL *url.URL
+	router          http.Handler
+}
+
+func init() {
+	origins.MustRegisterProvider(OriginType, NewClient)
+}
+
+// NewClient is the origins.ProviderFunc for the example TSDB
+func NewClient(name string, oc *oo.Options, router http.Handler, c cache.Cache,
+	_ origins.Origins, _ *tl.Logger) (origins.Client, error) {
+	wc, err := proxy.NewHTTPClient(oc)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{name: name, config: oc, cache: c, webClient: wc, router: router,
+		baseUpstreamURL: urls.FromParts(oc.Scheme, oc.Host, oc.PathPrefix, "", "")}
+	// the handler names are referenced by the handler setting of path configs
+	client.handlers = map[string]http.Handler{
+		handlerRange:  http.HandlerFunc(client.RangeHandler),
+		handlerProxy:  http.HandlerFunc(client.ProxyHandler),
+		handlerHealth: http.HandlerFunc(client.HealthHandler),
+	}
+	return client, nil
+}
+
+// Handlers returns a map of the HTTP Handlers the client has registered
+func (c *Client) Handlers() map[string]http.Handler {
+	return c.handlers
+}
+
+// DefaultPathConfigs returns the default PathConfigs for the example TSDB, which route range
+// queries through the Delta Proxy Cache, and proxy all other GET and HEAD requests without caching
+func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
+	return map[string]*po.Options{
+		rangePath: {
+			Path:        rangePath,
+			HandlerName: handlerRange,
+			Methods:     []string{http.MethodGet, http.MethodPost},
+			// the step is always included in the cache key by the Delta Proxy Cache
+			CacheKeyParams:  []string{upQuery},
+			CacheKeyHeaders: []string{},
+			MatchTypeName:   "exact",
+			MatchType:       matching.PathMatchTypeExact,
+		},
+		"/": {
+			Path:          "/",
+			HandlerName:   handlerProxy,
+			Methods:       methods.CacheableHTTPMethods(),
+			MatchTypeName: "prefix",
+			MatchType:     matching.PathMatchTypePrefix,
+		},
+	}
+}
+
+// RangeHandler handles range queries through the Delta Proxy Cache
+func (c *Client) RangeHandler(w http.ResponseWriter, r *http.Request) {
+	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+	engines.DeltaProxyCacheRequest(w, r)
+}
+
+// ProxyHandler proxies requests to the origin without caching
+func (c *Client) ProxyHandler(w http.ResponseWriter, r *http.Request) {
+	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+	engines.DoProxy(w, r, true)
+}
+
+// HealthHandler checks the health of the origin
+func (c *Client) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	engines.HealthCheck(w, r, c.config, c.baseUpstreamURL, c.DefaultHealthCheckConfig())
+}
+
+// DefaultHealthCheckConfig returns the default upstream health check, which users can
+// override with the origin's healthcheck config
+func (c *Client) DefaultHealthCheckConfig() *ho.Options {
+	return &ho.Options{Verb: http.MethodGet, Path: healthPath}
+}
+
+// ParseTimeRangeQuery returns the time range query represented by the request, which
+// the Delta Proxy Cache uses to determine which parts of the range are already cached
+func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+	qp, _, _ := params.GetRequestValues(r)
+	trq := &timeseries.TimeRangeQuery{Statement: qp.Get(upQuery), FastForwardDisable: true}
+	if trq.Statement == "" {
+		return nil, te.MissingURLParam(upQuery)
+	}
+	for _, p := range []struct {
+		name string
+		v    *time.Time
+	}{{upFrom, &trq.Extent.Start}, {upTo, &trq.Extent.End}} {
+		i, err := strconv.ParseInt(qp.Get(p.name), 10, 64)
+		if err != nil {
+			return nil, te.MissingURLParam(p.name)
+		}
+		*p.v = time.Unix(i, 0)
+	}
+	i, err := strconv.ParseInt(qp.Get(upInterval), 10, 64)
+	if err != nil || i <= 0 {
+		return nil, te.MissingURLParam(upInterval)
+	}
+	trq.Step = time.Duration(i) * time.Second
+	return trq, nil
+}
+
+// SetExtent updates the upstream request's time range, so that the Delta Proxy
+// Cache can request only the parts of the range that are not cached
+func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, e *timeseries.Extent) {
+	v, _, _ := params.GetRequestValues(r)
+	v.Set(upFrom, strconv.FormatInt(e.Start.Unix(), 10))
+	v.Set(upTo, strconv.FormatInt(e.End.Unix(), 10))
+	params.SetRequestValues(r, v)
+}
+
+// FastForwardRequest is not used, since ParseTimeRangeQuery disables Fast Forward
+func (c *Client) FastForwardRequest(r *http.Request) (*http.Request, error) {
+	return nil, ErrNoInstantaneousQueries
+}
+
+// UnmarshalTimeseries decodes an origin response, or a cached document, into a Timeseries.
+// The example TSDB's responses are in Prometheus's matrix format, so the Prometheus
+// provider's Timeseries implementation is reused
+func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	me := &prometheus.MatrixEnvelope{}
+	err := json.Unmarshal(data, me)
+	return me, err
+}
+
+// MarshalTimeseries encodes a Timeseries for caching, or for the client response
+func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	return json.Marshal(ts)
+}
+
+// UnmarshalInstantaneous is not used, since ParseTimeRangeQuery disables Fast Forward
+func (c *Client) UnmarshalInstantaneous(data []byte) (timeseries.Timeseries, error) {
+	return nil, ErrNoInstantaneousQueries
+}
+
+// Configuration returns the origin's configuration
+func (c *Client) Configuration() *oo.Options {
+	return c.config
+}
+
+// Name returns the origin's name
+func (c *Client) Name() string {
+	return c.name
+}
+
+// HTTPClient returns the HTTP Client used for upstream requests
+func (c *Client) HTTPClient() *http.Client {
+	return c.webClient
+}
+
+// SetCache sets the Cache used by the client
+func (c *Client) SetCache(cc cache.Cache) {
+	c.cache = cc
+}
+
+// Cache returns the Cache used by the client
+func (c *Client) Cache() cache.Cache {
+	return c.cache
+}
+
+// Router returns the router that handles the client's requests
+func (c *Client) Router() http.Handler {
+	return c.router
+}
@@ -17,7 +17,9 @@
 package origins
 
 import (
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
@@ -61,3 +63,61 @@ type TimeseriesClient interface {
 	// Router returns a Router that handles HTTP Requests for this client
 	Router() http.Handler
 }
+
+// EvaluationTimeReporter is an optional interface for TimeseriesClients whose uncached Fast Forward
+// results are timestamped with the origin's evaluation time, which can be used to estimate clock skew
+type EvaluationTimeReporter interface {
+	// EvaluationTime returns the origin's evaluation time for the provided Fast Forward timeseries
+	EvaluationTime(timeseries.Timeseries) (time.Time, bool)
+}
+
+// FrameStreamer is an optional interface for TimeseriesClients that can render a timeseries as
+// newline-delimited JSON frames, for downstream clients that negotiate a streaming response
+type FrameStreamer interface {
+	// StreamTimeseries writes the timeseries to w as newline-delimited JSON frames, calling
+	// flush after each frame so that the client can begin rendering before the last one arrives
+	StreamTimeseries(ts timeseries.Timeseries, w io.Writer, flush func()) error
+}
+
+// QueryNormalizer is an optional interface for TimeseriesClients that can normalize the query
+// language of their origin, so that queries differing only in their literal values share a
+// fingerprint. Clients without a QueryNormalizer only have their queries' whitespace collapsed
+type QueryNormalizer interface {
+	// NormalizeQuery returns the query with its whitespace and comments canonicalized, and its literal
+	// time values and numeric constants replaced with a placeholder. When stripValues is true, the
+	// values of label or tag matchers are also replaced, so that only the names of the matched labels
+	// are retained
+	NormalizeQuery(query string, stripValues bool) string
+}
+
+// TimeseriesCombiner combines the results of a query's sub-queries, in the order they were split,
+// into the result of the query
+type TimeseriesCombiner func([]timeseries.Timeseries) (timeseries.Timeseries, error)
+
+// QuerySplitter is an optional interface for TimeseriesClients that can split a query combining
+// several independent selectors into sub-queries, which are cached independently
+type QuerySplitter interface {
+	// SplitQuery returns a request for each sub-query of the request's query, and the combiner of
+	// their results. ok is false when the query is outside of the subset the client can split
+	SplitQuery(r *http.Request, trq *timeseries.TimeRangeQuery) (subRequests []*http.Request,
+		combine TimeseriesCombiner, ok bool)
+}
+
+// TimeseriesWarner is an optional interface for TimeseriesClients whose response format can convey
+// warnings to the downstream client, such as the warnings array of the Prometheus HTTP API
+type TimeseriesWarner interface {
+	// AddWarning adds the warning to the timeseries, to be included when it is marshaled
+	AddWarning(ts timeseries.Timeseries, warning string)
+}
+
+// TimeseriesRenderer is an optional interface for TimeseriesClients whose origin can respond in
+// several formats. Timeseries are cached in the canonical form returned by MarshalTimeseries,
+// and rendered in the format requested by the downstream client when responding
+type TimeseriesRenderer interface {
+	// TimeseriesFormat returns the name of the format requested by the request, or an empty string
+	// when the request asks for the canonical form
+	TimeseriesFormat(r *http.Request) string
+	// RenderTimeseries returns the timeseries rendered in the format requested by the request,
+	// including any of the request's format-specific settings, and its Content-Type
+	RenderTimeseries(ts timeseries.Timeseries, r *http.Request) ([]byte, string, error)
+}
@@ -16,57 +16,35 @@
 
 package types
 
-import "strconv"
-
-// OriginType enumerates the supported origin types
-type OriginType int
+import "strings"
 
+// The names of the origin types built into Trickster. The valid origin types are those having a
+// provider registered with origins.RegisterProvider, which includes providers maintained
+// outside of Trickster, so these are not a complete list
 const (
-	// OriginTypeRPC represents thee Reverse Proxy Cache origin type
-	OriginTypeRPC = OriginType(iota)
+	// OriginTypeRPC represents the Reverse Proxy Cache origin type
+	OriginTypeRPC = "rpc"
+	// OriginTypeReverseProxyCache is the long name of the Reverse Proxy Cache origin type
+	OriginTypeReverseProxyCache = "reverseproxycache"
 	// OriginTypeRule represents the Ruler origin type
-	OriginTypeRule
+	OriginTypeRule = "rule"
 	// OriginTypePrometheus represents the Prometheus origin type
-	OriginTypePrometheus
+	OriginTypePrometheus = "prometheus"
 	// OriginTypeInfluxDB represents the InfluxDB origin type
-	OriginTypeInfluxDB
+	OriginTypeInfluxDB = "influxdb"
 	// OriginTypeIronDB represents the IRONdb origin type
-	OriginTypeIronDB
+	OriginTypeIronDB = "irondb"
 	// OriginTypeClickHouse represents the ClickHouse origin type
-	OriginTypeClickHouse
+	OriginTypeClickHouse = "clickhouse"
 )
 
-// Names is a map of OriginTypes keyed by string name
-var Names = map[string]OriginType{
-	"rule":              OriginTypeRule,
-	"reverseproxycache": OriginTypeRPC,
-	"rpc":               OriginTypeRPC,
-	"prometheus":        OriginTypePrometheus,
-	"influxdb":          OriginTypeInfluxDB,
-	"irondb":            OriginTypeIronDB,
-	"clickhouse":        OriginTypeClickHouse,
-}
-
-// Values is a map of OriginTypes valued by string name
-var Values = make(map[OriginType]string)
-
-func init() {
-	for k, v := range Names {
-		Values[v] = k
+// IsTimeseries returns true if the named origin type is a timeseries origin type. Only the
+// built-in Reverse Proxy Cache and Rule origin types are not, so the origin types of any other
+// registered provider are timeseries origin types. Origin type names are not case-sensitive
+func IsTimeseries(originType string) bool {
+	switch strings.ToLower(originType) {
+	case OriginTypeRPC, OriginTypeReverseProxyCache, OriginTypeRule:
+		return false
 	}
-	// ensure consistent reverse mapping for reverseproxycache as rpc
-	Values[OriginTypeRPC] = "rpc"
-}
-
-func (t OriginType) String() string {
-	if v, ok := Values[t]; ok {
-		return v
-	}
-	return strconv.Itoa(int(t))
-}
-
-// IsValidOriginType returns true if the provided OriginType is valid for use with Trickster
-func IsValidOriginType(t string) bool {
-	_, ok := Names[t]
-	return ok
+	return true
 }
@@ -16,48 +16,27 @@
 
 package types
 
-import (
-	"strconv"
-	"testing"
-)
+import "testing"
 
-func TestOriginTypeString(t *testing.T) {
-
-	t1 := OriginTypeRPC
-	t2 := OriginTypePrometheus
-	var t3 OriginType = 13
-
-	if t1.String() != "rpc" {
-		t.Errorf("expected %s got %s", "rpc", t1.String())
-	}
-
-	if t2.String() != "prometheus" {
-		t.Errorf("expected %s got %s", "prometheus", t2.String())
-	}
-
-	if t3.String() != "13" {
-		t.Errorf("expected %s got %s", "13", t3.String())
-	}
-
-}
-
-func TestIsValidOriginType(t *testing.T) {
+func TestIsTimeseries(t *testing.T) {
 
 	tests := []struct {
 		o        string
 		expected bool
 	}{
-		{"rpc", true},
+		{"rpc", false},
+		{"reverseproxycache", false},
+		{"Rule", false},
 		{"prometheus", true},
-		{"", false},
-		{"invalid", false},
 		{"influxdb", true},
 		{"irondb", true},
+		{"clickhouse", true},
+		{"exampletsdb", true},
 	}
 
-	for i, test := range tests {
-		t.Run(strconv.Itoa(i), func(t *testing.T) {
-			res := IsValidOriginType(test.o)
+	for _, test := range tests {
+		t.Run(test.o, func(t *testing.T) {
+			res := IsTimeseries(test.o)
 			if test.expected != res {
 				t.Errorf("expected %t got %t", test.expected, res)
 			}
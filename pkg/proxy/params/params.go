@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
@@ -89,3 +90,50 @@ func SetRequestValues(r *http.Request, v url.Values) {
 		r.Body = ioutil.NopCloser(bytes.NewReader([]byte(s)))
 	}
 }
+
+// CacheKeyValue returns the value of the named parameter for inclusion in a cache key, or an empty
+// string if it is not present. A repeated parameter's values are escaped and joined in the order
+// provided, since the order may be significant to the origin
+func CacheKeyValue(v url.Values, name string) string {
+	vals := v[name]
+	switch len(vals) {
+	case 0:
+		return ""
+	case 1:
+		return vals[0]
+	}
+	escaped := make([]string, len(vals))
+	for i, s := range vals {
+		escaped[i] = url.QueryEscape(s)
+	}
+	return strings.Join(escaped, "&")
+}
+
+// DefaultImportantParams is the list of query parameters that are semantically important to most
+// timeseries origins, for which a duplicated occurrence would be ambiguous
+var DefaultImportantParams = []string{"start", "end", "step", "query", "q", "db"}
+
+// DuplicatedParams returns the names from the provided list that occur more than once in the values
+func DuplicatedParams(v url.Values, names []string) []string {
+	var dups []string
+	for _, n := range names {
+		if len(v[n]) > 1 {
+			dups = append(dups, n)
+		}
+	}
+	return dups
+}
+
+// CollapseParams reduces each of the named parameters that occur more than once in the values to a
+// single value: the last occurrence when useLast is true, or otherwise the first occurrence
+func CollapseParams(v url.Values, names []string, useLast bool) {
+	for _, n := range names {
+		if vals := v[n]; len(vals) > 1 {
+			if useLast {
+				v[n] = vals[len(vals)-1:]
+			} else {
+				v[n] = vals[:1]
+			}
+		}
+	}
+}
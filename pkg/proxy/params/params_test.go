@@ -108,3 +108,47 @@ func TestGetSetRequestValues(t *testing.T) {
 	}
 
 }
+
+func TestDuplicatedParams(t *testing.T) {
+	v, _ := url.ParseQuery("query=up&query=down&start=1&step=15&step=15&match[]=a&match[]=b")
+	dups := DuplicatedParams(v, DefaultImportantParams)
+	expected := []string{"step", "query"}
+	if !reflect.DeepEqual(dups, expected) {
+		t.Errorf("expected %v got %v", expected, dups)
+	}
+	if dups = DuplicatedParams(v, nil); dups != nil {
+		t.Errorf("expected nil got %v", dups)
+	}
+}
+
+func TestCollapseParams(t *testing.T) {
+	v, _ := url.ParseQuery("query=up&query=down&start=1&match[]=a&match[]=b")
+	CollapseParams(v, DefaultImportantParams, false)
+	if v.Get("query") != "up" || len(v["query"]) != 1 {
+		t.Errorf("expected %s got %v", "up", v["query"])
+	}
+	if len(v["match[]"]) != 2 {
+		t.Errorf("expected %d got %d", 2, len(v["match[]"]))
+	}
+	v, _ = url.ParseQuery("query=up&query=down")
+	CollapseParams(v, DefaultImportantParams, true)
+	if v.Get("query") != "down" || len(v["query"]) != 1 {
+		t.Errorf("expected %s got %v", "down", v["query"])
+	}
+}
+
+func TestCacheKeyValue(t *testing.T) {
+	v, _ := url.ParseQuery("query=up+time&match[]=a&match[]=b%26c")
+	tests := []struct {
+		name, expected string
+	}{
+		{"query", "up time"},
+		{"match[]", "a&b%26c"},
+		{"missing", ""},
+	}
+	for _, test := range tests {
+		if s := CacheKeyValue(v, test.name); s != test.expected {
+			t.Errorf("expected %s got %s", test.expected, s)
+		}
+	}
+}
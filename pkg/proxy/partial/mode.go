@@ -0,0 +1,55 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package partial enumerates how a timeseries request is answered when some of the upstream
+// requests that fetch its uncached ranges fail
+package partial
+
+import "strconv"
+
+// Mode enumerates the ways that a timeseries request is answered when some of its upstream
+// range fetches fail
+type Mode int
+
+const (
+	// ModeFail indicates that the request fails with the failed upstream response
+	ModeFail = Mode(iota)
+	// ModeBestEffort indicates that the request is answered with the data that was obtained,
+	// and annotated with the ranges that are missing
+	ModeBestEffort
+)
+
+// Names is a map of Modes keyed by string name
+var Names = map[string]Mode{
+	"fail":        ModeFail,
+	"best_effort": ModeBestEffort,
+}
+
+// Values is a map of Modes valued by string name
+var Values = make(map[Mode]string)
+
+func init() {
+	for k, v := range Names {
+		Values[v] = k
+	}
+}
+
+func (m Mode) String() string {
+	if v, ok := Values[m]; ok {
+		return v
+	}
+	return strconv.Itoa(int(m))
+}
@@ -18,12 +18,20 @@ package options
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache/key"
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
+	"github.com/tricksterproxy/trickster/pkg/proxy/estimate"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
+	"github.com/tricksterproxy/trickster/pkg/proxy/lookback"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	"github.com/tricksterproxy/trickster/pkg/proxy/partial"
 	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
+	mwopts "github.com/tricksterproxy/trickster/pkg/util/middleware/options"
 	"github.com/tricksterproxy/trickster/pkg/util/strings"
 	ts "github.com/tricksterproxy/trickster/pkg/util/strings"
 )
@@ -46,6 +54,10 @@ type Options struct {
 	// CacheKeyFormFields provides the list of http request body fields to be included
 	// in the hash for each request's cache key
 	CacheKeyFormFields []string `toml:"cache_key_form_fields"`
+	// CacheKeyExcludeParams provides the list of http request query parameters that are never included
+	// in the hash for each request's cache key, such as cache-busting parameters. When nil, the default
+	// list is used, which excludes Grafana's '_' parameter
+	CacheKeyExcludeParams []string `toml:"cache_key_exclude_params"`
 	// RequestHeaders is a map of headers that will be added to requests to the upstream Origin for this path
 	RequestHeaders map[string]string `toml:"request_headers"`
 	// RequestParams is a map of headers that will be added to requests to the upstream Origin for this path
@@ -61,6 +73,98 @@ type Options struct {
 	// ReqRewriterName is the name of a configured Rewriter that will modify the request prior to
 	// processing by the origin client
 	ReqRewriterName string `toml:"req_rewriter_name"`
+	// NegativeCacheName provides the name of the Negative Cache Config to be used by this Path,
+	// overriding that of the Origin. When empty, the Origin's Negative Cache is used.
+	NegativeCacheName string `toml:"negative_cache_name"`
+	// CachePostRequests, when true, permits the Object Proxy Cache to cache responses to POST requests for
+	// this Path, by including a hash of the request body in the cache key
+	CachePostRequests bool `toml:"cache_post_requests"`
+	// CachePostMaxBodyBytes is the largest request body that a cacheable POST request can have.
+	// POST requests with larger bodies are proxied without caching
+	CachePostMaxBodyBytes int `toml:"cache_post_max_body_bytes"`
+	// CachePostAllowAuthorization, when true, permits caching responses to POST requests that
+	// include an Authorization header
+	CachePostAllowAuthorization bool `toml:"cache_post_allow_authorization"`
+	// HeadUpgradeToGet, when true, fetches the object with a GET request when a HEAD request for it
+	// misses the Object Proxy Cache, so that it is cached for subsequent requests
+	HeadUpgradeToGet bool `toml:"head_upgrade_to_get"`
+	// AssumeSingleUse, when true, indicates that the responses for this Path are rarely requested
+	// more than once, so that a response is only cached when its cache key is requested again
+	// within AssumeSingleUseWindowSecs of a request that was not cached
+	AssumeSingleUse bool `toml:"assume_single_use"`
+	// AssumeSingleUseWindowSecs is how long after an uncached request for a Path that assumes
+	// single use that another request for the same cache key is cached
+	AssumeSingleUseWindowSecs int `toml:"assume_single_use_window_secs"`
+	// CacheAllowHeaders provides the list of upstream response headers that are stored with cached objects
+	// for this Path. When empty, all headers are stored except those that are never cacheable (e.g., Set-Cookie)
+	CacheAllowHeaders []string `toml:"cache_allow_headers"`
+	// CacheDenyHeaders provides the list of upstream response headers that are not stored with cached objects
+	// for this Path, in addition to those that are never cacheable
+	CacheDenyHeaders []string `toml:"cache_deny_headers"`
+	// AuthCachePolicyName indicates how responses to authenticated requests are cached for this Path:
+	// 'never', 'shared' or 'per-identity'. When empty, the Origin Type's default policy is used
+	AuthCachePolicyName string `toml:"cache_authenticated_requests"`
+	// CacheIdentityHeader provides the name of a request header identifying the requestor, whose value is
+	// hashed into the cache key under the 'per-identity' policy, instead of the request's credentials
+	CacheIdentityHeader string `toml:"cache_identity_header"`
+	// MaxRequestURLBytes is the maximum length of a request URL for this Path, overriding that of the
+	// Frontend. When 0, the Frontend's limit is used
+	MaxRequestURLBytes int `toml:"max_request_url_bytes"`
+	// MaxRequestBodyBytes is the maximum size of a request body for this Path, overriding that of the
+	// Frontend. When 0, the Frontend's limit is used
+	MaxRequestBodyBytes int `toml:"max_request_body_bytes"`
+	// Middleware is the ordered list of names of configured Middleware instances that handle requests
+	// for this Path before its handler, with the first name being the outermost
+	Middleware []string `toml:"middleware"`
+	// SplitQueries, when true, permits timeseries queries for this Path that combine several independent
+	// selectors to be split into sub-queries that are cached independently, and whose results are
+	// combined locally. This is experimental, and only supported by some Origin Types
+	SplitQueries bool `toml:"split_queries"`
+	// SplitQueriesLimit is the maximum number of sub-queries into which a query for this Path is split.
+	// Queries that would be split into more sub-queries are cached whole. When 0, the default is used
+	SplitQueriesLimit int `toml:"split_queries_limit"`
+	// CanonicalFormat, when true, caches timeseries results for this Path in a canonical form that is
+	// shared by all of the response formats that a query may request, rendering the requested format
+	// when responding. This is only supported by some Origin Types
+	CanonicalFormat bool `toml:"canonical_format"`
+	// PartialResponseName indicates how a timeseries request for this Path is answered when some of the
+	// upstream requests for its uncached ranges fail: 'fail' (the default) or 'best_effort'
+	PartialResponseName string `toml:"partial_response"`
+	// MaxLookbackSecs is how far back from now, in seconds, a timeseries request for this Path may query.
+	// When 0, the lookback is unlimited
+	MaxLookbackSecs int `toml:"max_lookback_secs"`
+	// MaxLookbackActionName indicates how a timeseries request for this Path that queries further back than
+	// its max lookback is handled: 'clamp' (the default) or 'reject'
+	MaxLookbackActionName string `toml:"max_lookback_action"`
+	// MaxLookbackTenantHeader provides the name of a request header identifying the tenant whose max lookback
+	// in MaxLookbackTenants applies to the request
+	MaxLookbackTenantHeader string `toml:"max_lookback_tenant_header"`
+	// MaxLookbackTenantClaim provides the name of a claim in the request's bearer JSON Web Token identifying
+	// the tenant whose max lookback in MaxLookbackTenants applies to the request, when no header is configured
+	MaxLookbackTenantClaim string `toml:"max_lookback_tenant_claim"`
+	// MaxLookbackTenants maps tenants to their max lookback in seconds, overriding MaxLookbackSecs
+	MaxLookbackTenants map[string]int `toml:"max_lookback_tenants"`
+	// MaxEstimatedPoints is the maximum number of points that the results of a timeseries request for this
+	// Path are estimated to have, from the series counts of previous requests for the same query, before the
+	// request is handled by MaxEstimatedPointsActionName. When 0, results are not estimated
+	MaxEstimatedPoints int64 `toml:"max_estimated_points"`
+	// MaxEstimatedPointsActionName indicates how a timeseries request for this Path whose results are estimated
+	// to exceed MaxEstimatedPoints is handled: 'reject' (the default) or 'route'
+	MaxEstimatedPointsActionName string `toml:"max_estimated_points_action"`
+	// MaxEstimatedPointsOrigin provides the name of the origin to which the 'route' action routes requests
+	MaxEstimatedPointsOrigin string `toml:"max_estimated_points_origin"`
+	// MaxEstimatedPointsBypassToken provides the token that, when provided in a request's
+	// X-Trickster-Estimate-Bypass header, exempts the request from MaxEstimatedPoints
+	MaxEstimatedPointsBypassToken string `toml:"max_estimated_points_bypass_token"`
+	// MaxEstimatedPointsTTLSecs is the age, in seconds, after which the series count of a query is no longer
+	// used to estimate its results, and is replaced rather than averaged with the next one recorded
+	MaxEstimatedPointsTTLSecs int `toml:"max_estimated_points_ttl_secs"`
+	// PriorityName is the priority class by which upstream requests for this Path are admitted to the
+	// origin when its concurrency limit is saturated: 'high', 'normal' (the default) or 'low'
+	PriorityName string `toml:"priority"`
+	// PriorityHeader provides the name of a request header whose value, when it names a priority class,
+	// overrides PriorityName for the request
+	PriorityHeader string `toml:"priority_header"`
 
 	// Handler is the HTTP Handler represented by the Path's HandlerName
 	Handler http.Handler `toml:"-"`
@@ -70,14 +174,32 @@ type Options struct {
 	MatchType matching.PathMatchType `toml:"-"`
 	// CollapsedForwardingType is the typed representation of CollapsedForwardingName
 	CollapsedForwardingType forwarding.CollapsedForwardingType `toml:"-"`
-	// KeyHasher points to an optional function that hashes the cacheKey with a custom algorithm
+	// AuthCachePolicy is the typed representation of AuthCachePolicyName
+	AuthCachePolicy authcache.Policy `toml:"-"`
+	// PartialResponse is the typed representation of PartialResponseName
+	PartialResponse partial.Mode `toml:"-"`
+	// MaxLookbackAction is the typed representation of MaxLookbackActionName
+	MaxLookbackAction lookback.Action `toml:"-"`
+	// Priority is the typed representation of PriorityName
+	Priority priority.Class `toml:"-"`
+	// MaxEstimatedPointsAction is the typed representation of MaxEstimatedPointsActionName
+	MaxEstimatedPointsAction estimate.Action `toml:"-"`
+	// MaxEstimatedPointsRouter is the Request Router of MaxEstimatedPointsOrigin
+	MaxEstimatedPointsRouter http.Handler `toml:"-"`
+	// KeyProvider points to an optional function that provides the origin-specific Extras of
+	// the cache key inputs, which replace the params, headers and body of the request in its key
 	// NOTE: This is used by some origins like IronDB, but is not configurable by end users.
 	// Due to a bug in the vendored toml package, this must be a slice to avoid panic
-	KeyHasher []key.HasherFunc `toml:"-"`
+	KeyProvider []key.ProviderFunc `toml:"-"`
 	// Custom is a compiled list of any custom settings for this path from the config file
 	Custom []string `toml:"-"`
+	// NegativeCache provides a map for the Path's negative cache, with TTLs converted to time.Durations.
+	// When nil, the Origin's Negative Cache is used
+	NegativeCache map[int]time.Duration `toml:"-"`
 	// ReqRewriter is the rewriter handler as indicated by RuleName
 	ReqRewriter rewriter.RewriteInstructions
+	// MiddlewareStack is the list of Middleware instances indicated by Middleware
+	MiddlewareStack []*mwopts.Options `toml:"-"`
 
 	// NoMetrics, when set to true, disables metrics decoration for the path
 	NoMetrics bool `toml:"no_metrics"`
@@ -89,21 +211,33 @@ type Options struct {
 // NewOptions returns a newly-instantiated *Options
 func NewOptions() *Options {
 	return &Options{
-		Path:                    "/",
-		Methods:                 methods.CacheableHTTPMethods(),
-		HandlerName:             "proxy",
-		MatchTypeName:           "exact",
-		MatchType:               matching.PathMatchTypeExact,
-		CollapsedForwardingName: "basic",
-		CollapsedForwardingType: forwarding.CFTypeBasic,
-		CacheKeyParams:          make([]string, 0),
-		CacheKeyHeaders:         make([]string, 0),
-		CacheKeyFormFields:      make([]string, 0),
-		Custom:                  make([]string, 0),
-		RequestHeaders:          make(map[string]string),
-		RequestParams:           make(map[string]string),
-		ResponseHeaders:         make(map[string]string),
-		KeyHasher:               nil,
+		Path:                         "/",
+		Methods:                      methods.CacheableHTTPMethods(),
+		HandlerName:                  "proxy",
+		MatchTypeName:                "exact",
+		MatchType:                    matching.PathMatchTypeExact,
+		CollapsedForwardingName:      "basic",
+		CollapsedForwardingType:      forwarding.CFTypeBasic,
+		PartialResponseName:          "fail",
+		PartialResponse:              partial.ModeFail,
+		MaxLookbackActionName:        "clamp",
+		MaxLookbackAction:            lookback.ActionClamp,
+		PriorityName:                 "normal",
+		Priority:                     priority.ClassNormal,
+		MaxEstimatedPointsActionName: "reject",
+		MaxEstimatedPointsAction:     estimate.ActionReject,
+		MaxEstimatedPointsTTLSecs:    d.DefaultMaxEstimatedPointsTTLSecs,
+		CacheKeyParams:               make([]string, 0),
+		CacheKeyHeaders:              make([]string, 0),
+		CacheKeyFormFields:           make([]string, 0),
+		CacheAllowHeaders:            make([]string, 0),
+		CacheDenyHeaders:             make([]string, 0),
+		Custom:                       make([]string, 0),
+		RequestHeaders:               make(map[string]string),
+		RequestParams:                make(map[string]string),
+		ResponseHeaders:              make(map[string]string),
+		KeyProvider:                  nil,
+		CachePostMaxBodyBytes:        d.DefaultCachePostMaxBodyBytes,
 	}
 }
 
@@ -112,33 +246,93 @@ func (o *Options) Clone() *Options {
 	c := &Options{
 		Path: o.Path,
 		//		OriginConfig:            o.OriginConfig,
-		MatchTypeName:           o.MatchTypeName,
-		MatchType:               o.MatchType,
-		HandlerName:             o.HandlerName,
-		Handler:                 o.Handler,
-		RequestHeaders:          ts.CloneMap(o.RequestHeaders),
-		RequestParams:           ts.CloneMap(o.RequestParams),
-		ReqRewriter:             o.ReqRewriter,
-		ReqRewriterName:         o.ReqRewriterName,
-		ResponseHeaders:         ts.CloneMap(o.ResponseHeaders),
-		ResponseBody:            o.ResponseBody,
-		ResponseBodyBytes:       o.ResponseBodyBytes,
-		CollapsedForwardingName: o.CollapsedForwardingName,
-		CollapsedForwardingType: o.CollapsedForwardingType,
-		NoMetrics:               o.NoMetrics,
-		HasCustomResponseBody:   o.HasCustomResponseBody,
-		Methods:                 make([]string, len(o.Methods)),
-		CacheKeyParams:          make([]string, len(o.CacheKeyParams)),
-		CacheKeyHeaders:         make([]string, len(o.CacheKeyHeaders)),
-		CacheKeyFormFields:      make([]string, len(o.CacheKeyFormFields)),
-		Custom:                  make([]string, len(o.Custom)),
-		KeyHasher:               o.KeyHasher,
+		MatchTypeName:                 o.MatchTypeName,
+		MatchType:                     o.MatchType,
+		HandlerName:                   o.HandlerName,
+		Handler:                       o.Handler,
+		RequestHeaders:                ts.CloneMap(o.RequestHeaders),
+		RequestParams:                 ts.CloneMap(o.RequestParams),
+		ReqRewriter:                   o.ReqRewriter,
+		ReqRewriterName:               o.ReqRewriterName,
+		NegativeCacheName:             o.NegativeCacheName,
+		CachePostRequests:             o.CachePostRequests,
+		CachePostMaxBodyBytes:         o.CachePostMaxBodyBytes,
+		CachePostAllowAuthorization:   o.CachePostAllowAuthorization,
+		HeadUpgradeToGet:              o.HeadUpgradeToGet,
+		AssumeSingleUse:               o.AssumeSingleUse,
+		AssumeSingleUseWindowSecs:     o.AssumeSingleUseWindowSecs,
+		AuthCachePolicyName:           o.AuthCachePolicyName,
+		AuthCachePolicy:               o.AuthCachePolicy,
+		CacheIdentityHeader:           o.CacheIdentityHeader,
+		MaxRequestURLBytes:            o.MaxRequestURLBytes,
+		MaxRequestBodyBytes:           o.MaxRequestBodyBytes,
+		SplitQueries:                  o.SplitQueries,
+		SplitQueriesLimit:             o.SplitQueriesLimit,
+		CanonicalFormat:               o.CanonicalFormat,
+		PartialResponseName:           o.PartialResponseName,
+		PartialResponse:               o.PartialResponse,
+		MaxLookbackSecs:               o.MaxLookbackSecs,
+		MaxLookbackActionName:         o.MaxLookbackActionName,
+		MaxLookbackAction:             o.MaxLookbackAction,
+		MaxLookbackTenantHeader:       o.MaxLookbackTenantHeader,
+		MaxLookbackTenantClaim:        o.MaxLookbackTenantClaim,
+		MaxEstimatedPoints:            o.MaxEstimatedPoints,
+		MaxEstimatedPointsActionName:  o.MaxEstimatedPointsActionName,
+		MaxEstimatedPointsAction:      o.MaxEstimatedPointsAction,
+		MaxEstimatedPointsOrigin:      o.MaxEstimatedPointsOrigin,
+		MaxEstimatedPointsBypassToken: o.MaxEstimatedPointsBypassToken,
+		MaxEstimatedPointsTTLSecs:     o.MaxEstimatedPointsTTLSecs,
+		MaxEstimatedPointsRouter:      o.MaxEstimatedPointsRouter,
+		PriorityName:                  o.PriorityName,
+		Priority:                      o.Priority,
+		PriorityHeader:                o.PriorityHeader,
+		ResponseHeaders:               ts.CloneMap(o.ResponseHeaders),
+		ResponseBody:                  o.ResponseBody,
+		ResponseBodyBytes:             o.ResponseBodyBytes,
+		CollapsedForwardingName:       o.CollapsedForwardingName,
+		CollapsedForwardingType:       o.CollapsedForwardingType,
+		NoMetrics:                     o.NoMetrics,
+		HasCustomResponseBody:         o.HasCustomResponseBody,
+		Methods:                       make([]string, len(o.Methods)),
+		CacheKeyParams:                make([]string, len(o.CacheKeyParams)),
+		CacheKeyHeaders:               make([]string, len(o.CacheKeyHeaders)),
+		CacheKeyFormFields:            make([]string, len(o.CacheKeyFormFields)),
+		CacheAllowHeaders:             make([]string, len(o.CacheAllowHeaders)),
+		CacheDenyHeaders:              make([]string, len(o.CacheDenyHeaders)),
+		Custom:                        make([]string, len(o.Custom)),
+		KeyProvider:                   o.KeyProvider,
+	}
+	if o.CacheKeyExcludeParams != nil {
+		c.CacheKeyExcludeParams = make([]string, len(o.CacheKeyExcludeParams))
+		copy(c.CacheKeyExcludeParams, o.CacheKeyExcludeParams)
 	}
 	copy(c.Methods, o.Methods)
 	copy(c.CacheKeyParams, o.CacheKeyParams)
 	copy(c.CacheKeyHeaders, o.CacheKeyHeaders)
 	copy(c.CacheKeyFormFields, o.CacheKeyFormFields)
+	copy(c.CacheAllowHeaders, o.CacheAllowHeaders)
+	copy(c.CacheDenyHeaders, o.CacheDenyHeaders)
 	copy(c.Custom, o.Custom)
+	if o.Middleware != nil {
+		c.Middleware = make([]string, len(o.Middleware))
+		copy(c.Middleware, o.Middleware)
+	}
+	if o.MiddlewareStack != nil {
+		c.MiddlewareStack = make([]*mwopts.Options, len(o.MiddlewareStack))
+		copy(c.MiddlewareStack, o.MiddlewareStack)
+	}
+	if o.MaxLookbackTenants != nil {
+		c.MaxLookbackTenants = make(map[string]int, len(o.MaxLookbackTenants))
+		for k, v := range o.MaxLookbackTenants {
+			c.MaxLookbackTenants[k] = v
+		}
+	}
+	if o.NegativeCache != nil {
+		c.NegativeCache = make(map[int]time.Duration, len(o.NegativeCache))
+		for k, v := range o.NegativeCache {
+			c.NegativeCache[k] = v
+		}
+	}
 	return c
 }
 
@@ -166,6 +360,8 @@ func (o *Options) Merge(o2 *Options) {
 			o.CacheKeyHeaders = o2.CacheKeyHeaders
 		case "cache_key_form_fields":
 			o.CacheKeyFormFields = o2.CacheKeyFormFields
+		case "cache_key_exclude_params":
+			o.CacheKeyExcludeParams = o2.CacheKeyExcludeParams
 		case "request_headers":
 			o.RequestHeaders = o2.RequestHeaders
 		case "request_params":
@@ -186,6 +382,73 @@ func (o *Options) Merge(o2 *Options) {
 		case "req_rewriter_name":
 			o.ReqRewriterName = o2.ReqRewriterName
 			o.ReqRewriter = o2.ReqRewriter
+		case "negative_cache_name":
+			o.NegativeCacheName = o2.NegativeCacheName
+			o.NegativeCache = o2.NegativeCache
+		case "cache_post_requests":
+			o.CachePostRequests = o2.CachePostRequests
+		case "cache_post_max_body_bytes":
+			o.CachePostMaxBodyBytes = o2.CachePostMaxBodyBytes
+		case "cache_post_allow_authorization":
+			o.CachePostAllowAuthorization = o2.CachePostAllowAuthorization
+		case "head_upgrade_to_get":
+			o.HeadUpgradeToGet = o2.HeadUpgradeToGet
+		case "assume_single_use":
+			o.AssumeSingleUse = o2.AssumeSingleUse
+		case "assume_single_use_window_secs":
+			o.AssumeSingleUseWindowSecs = o2.AssumeSingleUseWindowSecs
+		case "cache_allow_headers":
+			o.CacheAllowHeaders = o2.CacheAllowHeaders
+		case "cache_deny_headers":
+			o.CacheDenyHeaders = o2.CacheDenyHeaders
+		case "cache_authenticated_requests":
+			o.AuthCachePolicyName = o2.AuthCachePolicyName
+			o.AuthCachePolicy = o2.AuthCachePolicy
+		case "cache_identity_header":
+			o.CacheIdentityHeader = o2.CacheIdentityHeader
+		case "max_request_url_bytes":
+			o.MaxRequestURLBytes = o2.MaxRequestURLBytes
+		case "max_request_body_bytes":
+			o.MaxRequestBodyBytes = o2.MaxRequestBodyBytes
+		case "middleware":
+			o.Middleware = o2.Middleware
+			o.MiddlewareStack = o2.MiddlewareStack
+		case "split_queries":
+			o.SplitQueries = o2.SplitQueries
+		case "split_queries_limit":
+			o.SplitQueriesLimit = o2.SplitQueriesLimit
+		case "canonical_format":
+			o.CanonicalFormat = o2.CanonicalFormat
+		case "partial_response":
+			o.PartialResponseName = o2.PartialResponseName
+			o.PartialResponse = o2.PartialResponse
+		case "max_lookback_secs":
+			o.MaxLookbackSecs = o2.MaxLookbackSecs
+		case "max_lookback_action":
+			o.MaxLookbackActionName = o2.MaxLookbackActionName
+			o.MaxLookbackAction = o2.MaxLookbackAction
+		case "max_lookback_tenant_header":
+			o.MaxLookbackTenantHeader = o2.MaxLookbackTenantHeader
+		case "max_lookback_tenant_claim":
+			o.MaxLookbackTenantClaim = o2.MaxLookbackTenantClaim
+		case "max_lookback_tenants":
+			o.MaxLookbackTenants = o2.MaxLookbackTenants
+		case "max_estimated_points":
+			o.MaxEstimatedPoints = o2.MaxEstimatedPoints
+		case "max_estimated_points_action":
+			o.MaxEstimatedPointsActionName = o2.MaxEstimatedPointsActionName
+			o.MaxEstimatedPointsAction = o2.MaxEstimatedPointsAction
+		case "max_estimated_points_origin":
+			o.MaxEstimatedPointsOrigin = o2.MaxEstimatedPointsOrigin
+		case "max_estimated_points_bypass_token":
+			o.MaxEstimatedPointsBypassToken = o2.MaxEstimatedPointsBypassToken
+		case "max_estimated_points_ttl_secs":
+			o.MaxEstimatedPointsTTLSecs = o2.MaxEstimatedPointsTTLSecs
+		case "priority":
+			o.PriorityName = o2.PriorityName
+			o.Priority = o2.Priority
+		case "priority_header":
+			o.PriorityHeader = o2.PriorityHeader
 		}
 	}
 	o.Custom = strings.Unique(o.Custom)
@@ -20,8 +20,11 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/tricksterproxy/trickster/pkg/proxy/estimate"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
+	"github.com/tricksterproxy/trickster/pkg/proxy/lookback"
 	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
+	"github.com/tricksterproxy/trickster/pkg/proxy/priority"
 )
 
 func TestNewOptions(t *testing.T) {
@@ -61,7 +64,12 @@ func TestPathMerge(t *testing.T) {
 	pc2.Custom = []string{"path", "match_type", "handler", "methods",
 		"cache_key_params", "cache_key_headers", "cache_key_form_fields",
 		"request_headers", "request_params", "response_headers",
-		"response_code", "response_body", "no_metrics", "collapsed_forwarding"}
+		"response_code", "response_body", "no_metrics", "collapsed_forwarding", "split_queries",
+		"split_queries_limit", "canonical_format", "max_lookback_secs", "max_lookback_action",
+		"max_lookback_tenant_header", "max_lookback_tenant_claim", "max_lookback_tenants",
+		"priority", "priority_header", "head_upgrade_to_get", "assume_single_use",
+		"assume_single_use_window_secs", "max_estimated_points", "max_estimated_points_action",
+		"max_estimated_points_origin", "max_estimated_points_bypass_token", "max_estimated_points_ttl_secs"}
 
 	expectedPath := "testPath"
 	expectedHandlerName := "testHandler"
@@ -81,9 +89,66 @@ func TestPathMerge(t *testing.T) {
 	pc2.NoMetrics = true
 	pc2.CollapsedForwardingName = "progressive"
 	pc2.CollapsedForwardingType = forwarding.CFTypeProgressive
+	pc2.SplitQueries = true
+	pc2.SplitQueriesLimit = 10
+	pc2.CanonicalFormat = true
+	pc2.MaxLookbackSecs = 86400
+	pc2.MaxLookbackActionName = "reject"
+	pc2.MaxLookbackAction = lookback.ActionReject
+	pc2.MaxLookbackTenantHeader = "X-Tenant"
+	pc2.MaxLookbackTenantClaim = "tenant"
+	pc2.MaxLookbackTenants = map[string]int{"admin": 0}
+	pc2.PriorityName = "low"
+	pc2.Priority = priority.ClassLow
+	pc2.PriorityHeader = "X-Priority"
+	pc2.HeadUpgradeToGet = true
+	pc2.AssumeSingleUse = true
+	pc2.AssumeSingleUseWindowSecs = 60
+	pc2.MaxEstimatedPoints = 1000000
+	pc2.MaxEstimatedPointsActionName = "route"
+	pc2.MaxEstimatedPointsAction = estimate.ActionRoute
+	pc2.MaxEstimatedPointsOrigin = "heavy"
+	pc2.MaxEstimatedPointsBypassToken = "secret"
+	pc2.MaxEstimatedPointsTTLSecs = 60
 
 	pc.Merge(pc2)
 
+	if pc.MaxEstimatedPoints != 1000000 || pc.MaxEstimatedPointsAction != estimate.ActionRoute ||
+		pc.MaxEstimatedPointsActionName != "route" || pc.MaxEstimatedPointsOrigin != "heavy" ||
+		pc.MaxEstimatedPointsBypassToken != "secret" || pc.MaxEstimatedPointsTTLSecs != 60 {
+		t.Errorf("unexpected max estimated points options %+v", pc)
+	}
+
+	if !pc.HeadUpgradeToGet {
+		t.Errorf("expected %t got %t", true, pc.HeadUpgradeToGet)
+	}
+
+	if !pc.AssumeSingleUse || pc.AssumeSingleUseWindowSecs != 60 {
+		t.Errorf("unexpected single use options %t %d", pc.AssumeSingleUse, pc.AssumeSingleUseWindowSecs)
+	}
+
+	if pc.MaxLookbackSecs != 86400 || pc.MaxLookbackAction != lookback.ActionReject ||
+		pc.MaxLookbackActionName != "reject" || pc.MaxLookbackTenantHeader != "X-Tenant" ||
+		pc.MaxLookbackTenantClaim != "tenant" || len(pc.MaxLookbackTenants) != 1 {
+		t.Errorf("unexpected max lookback options %+v", pc)
+	}
+
+	if pc.Priority != priority.ClassLow || pc.PriorityName != "low" || pc.PriorityHeader != "X-Priority" {
+		t.Errorf("unexpected priority options %+v", pc)
+	}
+
+	if !pc.SplitQueries {
+		t.Errorf("expected %t got %t", true, pc.SplitQueries)
+	}
+
+	if pc.SplitQueriesLimit != 10 {
+		t.Errorf("expected %d got %d", 10, pc.SplitQueriesLimit)
+	}
+
+	if !pc.CanonicalFormat {
+		t.Errorf("expected %t got %t", true, pc.CanonicalFormat)
+	}
+
 	if pc.Path != expectedPath {
 		t.Errorf("expected %s got %s", expectedPath, pc.Path)
 	}
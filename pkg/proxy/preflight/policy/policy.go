@@ -0,0 +1,55 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package policy enumerates the ways that the failed pre-flight check of a component is handled
+package policy
+
+import "strconv"
+
+// Policy enumerates the ways that the failed pre-flight check of a component is handled
+type Policy int
+
+const (
+	// Warn indicates that a failed pre-flight check is logged as a warning, and startup continues
+	Warn = Policy(iota)
+	// Required indicates that a failed pre-flight check aborts startup
+	Required
+	// Ignore indicates that the result of a pre-flight check is disregarded
+	Ignore
+)
+
+// Names is a map of Policies keyed by string name
+var Names = map[string]Policy{
+	"warn":     Warn,
+	"required": Required,
+	"ignore":   Ignore,
+}
+
+// Values is a map of Policies valued by string name
+var Values = make(map[Policy]string)
+
+func init() {
+	for k, v := range Names {
+		Values[v] = k
+	}
+}
+
+func (p Policy) String() string {
+	if v, ok := Values[p]; ok {
+		return v
+	}
+	return strconv.Itoa(int(p))
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package policy
+
+import "testing"
+
+func TestPolicyString(t *testing.T) {
+
+	tests := map[Policy]string{
+		Warn:     "warn",
+		Required: "required",
+		Ignore:   "ignore",
+		3:        "3",
+	}
+
+	for p, expected := range tests {
+		if p.String() != expected {
+			t.Errorf("expected %s got %s", expected, p.String())
+		}
+	}
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package preflight runs the startup pre-flight checks of Trickster's caches and origins,
+// and reports their results to readiness checks
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/readonly"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight/policy"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// Check is the pre-flight check of a component
+type Check struct {
+	// Component names the checked component, such as "cache:default" or "origin:prom1"
+	Component string
+	// Policy is the handling of a failed check
+	Policy policy.Policy
+	// Run checks the component, returning an error if it is unavailable. A Run that does not
+	// return by the context's deadline is reported as failed, and left to finish in the background
+	Run func(ctx context.Context) error
+}
+
+// Result is the result of a component's pre-flight check
+type Result struct {
+	// Component names the checked component
+	Component string `json:"component"`
+	// Policy is the name of the handling of a failed check
+	Policy string `json:"policy"`
+	// OK is true if the check succeeded
+	OK bool `json:"ok"`
+	// Error describes why the check failed
+	Error string `json:"error,omitempty"`
+
+	policy policy.Policy
+	err    error
+}
+
+// Report is the results of a set of pre-flight checks, in the order the checks were provided
+type Report struct {
+	Results []*Result `json:"results"`
+}
+
+// Error is the error of a failed pre-flight check of a required component
+type Error struct {
+	Component string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("pre-flight check failed for required component [%s]: %s",
+		e.Component, e.Err.Error())
+}
+
+// Run runs the checks concurrently, each bounded by the timeout, and returns their results. Failed
+// checks of components with the Warn policy are logged as warnings; those of required components
+// are left to the caller, by way of the Report's Err
+func Run(checks []*Check, timeout time.Duration, logger *tl.Logger) *Report {
+	report := &Report{Results: make([]*Result, len(checks))}
+	wg := &sync.WaitGroup{}
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *Check) {
+			defer wg.Done()
+			err := run(c, timeout)
+			res := &Result{Component: c.Component, Policy: c.Policy.String(), OK: err == nil,
+				policy: c.Policy, err: err}
+			if err == nil {
+				logger.Debug("pre-flight check succeeded", tl.Pairs{"component": c.Component})
+			} else {
+				res.Error = err.Error()
+				switch c.Policy {
+				case policy.Warn:
+					logger.Warn("pre-flight check failed",
+						tl.Pairs{"component": c.Component, "detail": res.Error})
+				case policy.Ignore:
+					logger.Debug("pre-flight check failed",
+						tl.Pairs{"component": c.Component, "detail": res.Error})
+				}
+			}
+			report.Results[i] = res
+		}(i, c)
+	}
+	wg.Wait()
+	return report
+}
+
+func run(c *Check, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ch := make(chan error, 1)
+	go func() { ch <- c.Run(ctx) }()
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+// Err returns an Error for the first failed check of a required component,
+// or nil if all required components passed their checks
+func (r *Report) Err() error {
+	for _, res := range r.Results {
+		if !res.OK && res.policy == policy.Required {
+			return &Error{Component: res.Component, Err: res.err}
+		}
+	}
+	return nil
+}
+
+// Readiness holds the Reports of the pre-flight checks of a router's configs, for its
+// Readiness Handler
+type Readiness struct {
+	report    *Report
+	succeeded map[string]bool
+	mtx       sync.Mutex
+}
+
+// NewReadiness returns a new Readiness, which is not ready until a Report is recorded
+func NewReadiness() *Readiness {
+	return &Readiness{succeeded: make(map[string]bool)}
+}
+
+// Record records the Report of the running config's pre-flight checks
+func (rd *Readiness) Record(r *Report) {
+	rd.mtx.Lock()
+	defer rd.mtx.Unlock()
+	rd.report = r
+	for _, res := range r.Results {
+		if res.OK {
+			rd.succeeded[res.Component] = true
+		}
+	}
+}
+
+// Ready returns true once a Report has been recorded, and every required component
+// in the most recently recorded Report has succeeded its check at least once
+func (rd *Readiness) Ready() bool {
+	rd.mtx.Lock()
+	defer rd.mtx.Unlock()
+	return rd.ready()
+}
+
+func (rd *Readiness) ready() bool {
+	if rd.report == nil {
+		return false
+	}
+	for _, res := range rd.report.Results {
+		if res.policy == policy.Required && !rd.succeeded[res.Component] {
+			return false
+		}
+	}
+	return true
+}
+
+// ComponentReady returns true once a Report has been recorded, and the named component has either
+// succeeded its check at least once, or was not checked in the most recently recorded Report
+func (rd *Readiness) ComponentReady(component string) bool {
+	rd.mtx.Lock()
+	defer rd.mtx.Unlock()
+	if rd.report == nil {
+		return false
+	}
+	for _, res := range rd.report.Results {
+		if res.Component == component {
+			return rd.succeeded[component]
+		}
+	}
+	return true
+}
+
+// ReadyHandleFunc responds to an HTTP Request with the readiness status and the results of the
+// most recently recorded pre-flight checks: 200 OK when ready, and 503 Service Unavailable otherwise.
+// Caches in read-only mode are listed, but do not affect readiness, since they continue to serve
+func (rd *Readiness) ReadyHandleFunc(w http.ResponseWriter, r *http.Request) {
+	rd.mtx.Lock()
+	status := struct {
+		Ready          bool      `json:"ready"`
+		Results        []*Result `json:"results"`
+		ReadOnlyCaches []string  `json:"read_only_caches,omitempty"`
+	}{Ready: rd.ready(), Results: []*Result{}}
+	if rd.report != nil {
+		status.Results = rd.report.Results
+	}
+	for _, s := range readonly.Statuses() {
+		if s.ReadOnly {
+			status.ReadOnlyCaches = append(status.ReadOnlyCaches, s.Cache)
+		}
+	}
+	b, _ := json.Marshal(status)
+	rd.mtx.Unlock()
+
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+	if status.Ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(b)
+}
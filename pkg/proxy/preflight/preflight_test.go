@@ -0,0 +1,161 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight/policy"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func testCheck(component string, p policy.Policy, err error) *Check {
+	return &Check{Component: component, Policy: p,
+		Run: func(context.Context) error { return err }}
+}
+
+func TestRun(t *testing.T) {
+
+	errDown := errors.New("connection refused")
+	checks := []*Check{
+		testCheck("cache:default", policy.Required, nil),
+		testCheck("origin:prom1", policy.Warn, errDown),
+		testCheck("origin:prom2", policy.Ignore, errDown),
+		// a check that does not observe its context is abandoned at the timeout
+		{Component: "cache:redis", Policy: policy.Warn,
+			Run: func(context.Context) error { time.Sleep(time.Second); return nil }},
+	}
+
+	report := Run(checks, 50*time.Millisecond, tl.ConsoleLogger("error"))
+	if len(report.Results) != len(checks) {
+		t.Fatalf("expected %d got %d", len(checks), len(report.Results))
+	}
+	for i, res := range report.Results {
+		if res.Component != checks[i].Component || res.Policy != checks[i].Policy.String() {
+			t.Errorf("unexpected result %+v", res)
+		}
+	}
+	if !report.Results[0].OK || report.Results[1].OK || report.Results[1].Error != errDown.Error() {
+		t.Errorf("unexpected results %+v %+v", report.Results[0], report.Results[1])
+	}
+	if report.Results[3].OK || report.Results[3].Error != "timed out after 50ms" {
+		t.Errorf("unexpected result %+v", report.Results[3])
+	}
+	if err := report.Err(); err != nil {
+		t.Error(err)
+	}
+
+	checks = append(checks, testCheck("origin:prom3", policy.Required, errDown))
+	report = Run(checks, 100*time.Millisecond, tl.ConsoleLogger("error"))
+	err := report.Err()
+	pe, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected *Error got %v", err)
+	}
+	if pe.Component != "origin:prom3" || pe.Err != errDown {
+		t.Errorf("unexpected error %+v", pe)
+	}
+	const expected = "pre-flight check failed for required component [origin:prom3]: connection refused"
+	if err.Error() != expected {
+		t.Errorf("expected %s got %s", expected, err.Error())
+	}
+}
+
+func TestReadyHandleFunc(t *testing.T) {
+
+	rd := NewReadiness()
+
+	type status struct {
+		Ready   bool      `json:"ready"`
+		Results []*Result `json:"results"`
+	}
+
+	get := func(code int) *status {
+		w := httptest.NewRecorder()
+		rd.ReadyHandleFunc(w, httptest.NewRequest(http.MethodGet, "/trickster/readyz", nil))
+		if w.Code != code {
+			t.Errorf("expected %d got %d", code, w.Code)
+		}
+		s := &status{}
+		if err := json.Unmarshal(w.Body.Bytes(), s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	// nothing is ready until the pre-flight checks have been recorded
+	if s := get(http.StatusServiceUnavailable); s.Ready || len(s.Results) != 0 {
+		t.Errorf("unexpected status %+v", s)
+	}
+
+	logger := tl.ConsoleLogger("error")
+	errDown := errors.New("connection refused")
+	rd.Record(Run([]*Check{
+		testCheck("cache:default", policy.Required, nil),
+		testCheck("origin:prom1", policy.Warn, errDown),
+	}, time.Second, logger))
+	if s := get(http.StatusOK); !s.Ready || len(s.Results) != 2 || s.Results[1].Error != errDown.Error() {
+		t.Errorf("unexpected status %+v", s)
+	}
+
+	// required components that have never succeeded are not ready
+	rd.Record(Run([]*Check{
+		testCheck("cache:default", policy.Required, errDown),
+		testCheck("origin:prom1", policy.Required, errDown),
+	}, time.Second, logger))
+	if rd.Ready() {
+		t.Error("expected not ready")
+	}
+	get(http.StatusServiceUnavailable)
+
+	// once a required component has succeeded, it remains ready
+	rd.Record(Run([]*Check{
+		testCheck("cache:default", policy.Required, errDown),
+		testCheck("origin:prom1", policy.Required, nil),
+	}, time.Second, logger))
+	if !rd.Ready() {
+		t.Error("expected ready")
+	}
+}
+
+func TestComponentReady(t *testing.T) {
+
+	rd := NewReadiness()
+
+	if rd.ComponentReady("origin:prom1") {
+		t.Error("expected not ready")
+	}
+
+	errDown := errors.New("connection refused")
+	rd.Record(Run([]*Check{
+		testCheck("origin:prom1", policy.Warn, errDown),
+		testCheck("origin:prom2", policy.Ignore, nil),
+	}, time.Second, tl.ConsoleLogger("error")))
+
+	tests := map[string]bool{"origin:prom1": false, "origin:prom2": true, "origin:prom3": true}
+	for component, expected := range tests {
+		if rd.ComponentReady(component) != expected {
+			t.Errorf("%s: expected %t", component, expected)
+		}
+	}
+}
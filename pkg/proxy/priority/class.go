@@ -0,0 +1,61 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package priority
+
+import "strconv"
+
+// Class enumerates the priorities by which upstream requests are admitted to an origin
+// when its concurrency limit is saturated
+type Class int
+
+const (
+	// ClassNormal is admitted after ClassHigh, and is the class of unclassified requests
+	ClassNormal = Class(iota)
+	// ClassHigh is admitted before all other classes, and may use the origin's reserved capacity
+	ClassHigh
+	// ClassLow is admitted only when no other class is waiting
+	ClassLow
+)
+
+// numClasses is the number of Classes
+const numClasses = 3
+
+// admissionOrder lists the Classes in the order that their waiting requests are admitted
+var admissionOrder = [numClasses]Class{ClassHigh, ClassNormal, ClassLow}
+
+// Names is a map of Classes keyed by string name
+var Names = map[string]Class{
+	"high":   ClassHigh,
+	"normal": ClassNormal,
+	"low":    ClassLow,
+}
+
+// Values is a map of Classes valued by string name
+var Values = make(map[Class]string)
+
+func init() {
+	for k, v := range Names {
+		Values[v] = k
+	}
+}
+
+func (c Class) String() string {
+	if v, ok := Values[c]; ok {
+		return v
+	}
+	return strconv.Itoa(int(c))
+}
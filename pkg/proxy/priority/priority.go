@@ -0,0 +1,269 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package priority limits the concurrency of upstream requests to each of Trickster's origins,
+// admitting waiting requests in order of their priority class, so that interactive requests
+// are not starved of origin capacity by bulk requests
+package priority
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// ErrUnavailable is returned when a request cannot be admitted under the origin's
+// concurrency limit before its timeout elapses
+var ErrUnavailable = errors.New("timed out waiting for origin concurrency capacity")
+
+// Limiter is a semaphore of an origin's concurrent upstream requests. Waiting requests are
+// admitted in order of their Class, and in order of arrival within a Class. A share of the
+// capacity is reserved for ClassHigh, which other classes are not admitted into
+type Limiter struct {
+	originName string
+	originType string
+	metrics    *metrics.Metrics
+	capacity   int
+	reserved   int
+	timeout    time.Duration
+	active     int
+	waiters    [numClasses][]*waiter
+	mtx        sync.Mutex
+}
+
+type waiter struct {
+	ready chan struct{}
+}
+
+// Registry holds the Limiters of a router's origins
+type Registry struct {
+	metrics  *metrics.Metrics
+	limiters sync.Map
+}
+
+// NewRegistry returns a new Registry whose Limiters report to the Metrics
+func NewRegistry(m *metrics.Metrics) *Registry {
+	return &Registry{metrics: m}
+}
+
+// Get returns the Limiter for the named origin, creating it if necessary. A new Limiter
+// does not limit requests until it is configured
+func (r *Registry) Get(originName, originType string) *Limiter {
+	if l, ok := r.limiters.Load(originName); ok {
+		return l.(*Limiter)
+	}
+	l, _ := r.limiters.LoadOrStore(originName, &Limiter{originName: originName,
+		originType: originType, metrics: r.metrics})
+	return l.(*Limiter)
+}
+
+// Configure sets the capacity of the Limiter (0 = unlimited), the share of the capacity reserved
+// for ClassHigh, and the longest that requests wait to be admitted (0 = until their context is done).
+// Requests that are already admitted are retained, and waiting requests are admitted if the
+// capacity has grown. At least one unit of capacity is left unreserved, so that every class can
+// eventually be admitted
+func (l *Limiter) Configure(capacity int, reservedShare float64, timeout time.Duration) {
+	reserved := int(math.Ceil(float64(capacity) * reservedShare))
+	if reserved >= capacity {
+		reserved = capacity - 1
+	}
+	if reserved < 0 {
+		reserved = 0
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.capacity == capacity && l.reserved == reserved && l.timeout == timeout {
+		return
+	}
+	l.capacity = capacity
+	l.reserved = reserved
+	l.timeout = timeout
+	l.grant()
+}
+
+// Active returns the number of requests currently admitted by the Limiter
+func (l *Limiter) Active() int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.active
+}
+
+// Waiting returns the number of requests of the Class waiting to be admitted by the Limiter
+func (l *Limiter) Waiting(c Class) int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return len(l.waiters[c.index()])
+}
+
+// Acquire admits a request of the Class under the Limiter, waiting until it can be admitted ahead
+// of the waiting requests of lower classes, and behind those of its own and higher classes. It
+// returns ErrUnavailable if the request is not admitted within the Limiter's timeout, or the
+// context's error if the context is done first. The returned Slot must be released once the
+// request's upstream response is complete
+func (l *Limiter) Acquire(ctx context.Context, c Class) (*Slot, error) {
+	i := c.index()
+	start := time.Now()
+	l.mtx.Lock()
+	if !l.queued(i) && l.admits(i) {
+		l.add(1)
+		l.mtx.Unlock()
+		l.observeWait(c, 0)
+		return &Slot{l: l}, nil
+	}
+	w := &waiter{ready: make(chan struct{})}
+	l.waiters[i] = append(l.waiters[i], w)
+	timeout := l.timeout
+	l.mtx.Unlock()
+
+	var tc <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		tc = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		l.observeWait(c, time.Since(start))
+		return &Slot{l: l}, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-tc:
+		err = ErrUnavailable
+		metrics.OrDefault(l.metrics).ProxyOriginConcurrencyRejections.WithLabelValues(l.originName,
+			l.originType, c.String()).Inc()
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	select {
+	case <-w.ready:
+		// the waiter was admitted concurrently with the timeout, so its admission is returned
+		l.add(-1)
+		l.grant()
+	default:
+		l.remove(i, w)
+	}
+	return nil, err
+}
+
+// index returns the index of the Class in the Limiter's waiters, treating unknown classes as ClassNormal
+func (c Class) index() int {
+	if c < 0 || c >= numClasses {
+		return int(ClassNormal)
+	}
+	return int(c)
+}
+
+// queued returns true if requests of the class at index i, or of a class admitted before it, are
+// waiting. The caller must hold the lock
+func (l *Limiter) queued(i int) bool {
+	for _, c := range admissionOrder {
+		if len(l.waiters[c]) > 0 {
+			return true
+		}
+		if int(c) == i {
+			break
+		}
+	}
+	return false
+}
+
+// admits returns true if a request of the class at index i fits in the capacity available to
+// its class. The caller must hold the lock
+func (l *Limiter) admits(i int) bool {
+	if l.capacity <= 0 {
+		return true
+	}
+	limit := l.capacity
+	if i != int(ClassHigh) {
+		limit -= l.reserved
+	}
+	return l.active < limit
+}
+
+// add adjusts the number of admitted requests and reports it. The caller must hold the lock
+func (l *Limiter) add(n int) {
+	l.active += n
+	if l.active < 0 {
+		l.active = 0
+	}
+	metrics.OrDefault(l.metrics).ProxyOriginConcurrentRequests.WithLabelValues(l.originName,
+		l.originType).Set(float64(l.active))
+}
+
+// grant admits waiting requests in order of their class while the capacity allows. Since lower
+// classes never have more capacity available than higher ones, granting stops at the first class
+// whose waiters cannot be admitted. The caller must hold the lock
+func (l *Limiter) grant() {
+	for _, c := range admissionOrder {
+		i := int(c)
+		for len(l.waiters[i]) > 0 {
+			if !l.admits(i) {
+				return
+			}
+			w := l.waiters[i][0]
+			l.waiters[i] = l.waiters[i][1:]
+			l.add(1)
+			close(w.ready)
+		}
+	}
+}
+
+// remove removes a waiter that is no longer waiting, and admits the waiters behind it,
+// which may now be first in line. The caller must hold the lock
+func (l *Limiter) remove(i int, w *waiter) {
+	for j := range l.waiters[i] {
+		if l.waiters[i][j] == w {
+			l.waiters[i] = append(l.waiters[i][:j], l.waiters[i][j+1:]...)
+			break
+		}
+	}
+	l.grant()
+}
+
+func (l *Limiter) observeWait(c Class, d time.Duration) {
+	metrics.OrDefault(l.metrics).ProxyOriginConcurrencyQueueWait.WithLabelValues(l.originName,
+		l.originType, c.String()).Observe(d.Seconds())
+}
+
+// Slot is the admission of a single request under a Limiter
+type Slot struct {
+	l        *Limiter
+	released bool
+}
+
+// Release returns the Slot's admission to its Limiter, admitting the next waiting request.
+// It may be called more than once, and on a nil Slot
+func (s *Slot) Release() {
+	if s == nil {
+		return
+	}
+	l := s.l
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if s.released {
+		return
+	}
+	s.released = true
+	l.add(-1)
+	l.grant()
+}
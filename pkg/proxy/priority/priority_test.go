@@ -0,0 +1,202 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package priority
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitFor waits until the Limiter has n waiting requests of the Class
+func waitFor(t *testing.T, l *Limiter, c Class, n int) {
+	for i := 0; i < 100; i++ {
+		if l.Waiting(c) == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d waiting %s requests got %d", n, c, l.Waiting(c))
+}
+
+func TestAcquire(t *testing.T) {
+
+	l := NewRegistry(nil).Get("test-acquire", "test")
+	l.Configure(2, 0, 20*time.Millisecond)
+
+	s1, err := l.Acquire(context.Background(), ClassLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := l.Acquire(context.Background(), ClassNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Active() != 2 {
+		t.Errorf("expected %d got %d", 2, l.Active())
+	}
+
+	// the limiter is saturated, so the request times out
+	if _, err = l.Acquire(context.Background(), ClassHigh); err != ErrUnavailable {
+		t.Errorf("expected %v got %v", ErrUnavailable, err)
+	}
+	if l.Waiting(ClassHigh) != 0 {
+		t.Errorf("expected %d got %d", 0, l.Waiting(ClassHigh))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = l.Acquire(ctx, ClassNormal); err != context.Canceled {
+		t.Errorf("expected %v got %v", context.Canceled, err)
+	}
+
+	// releasing is idempotent
+	s1.Release()
+	s1.Release()
+	s2.Release()
+	var s3 *Slot
+	s3.Release()
+	if l.Active() != 0 {
+		t.Errorf("expected %d got %d", 0, l.Active())
+	}
+}
+
+func TestAcquireOrder(t *testing.T) {
+
+	l := NewRegistry(nil).Get("test-order", "test")
+	l.Configure(1, 0, 0)
+
+	s, err := l.Acquire(context.Background(), ClassNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// requests are queued low, normal, high, and are admitted high, normal, low
+	order := make(chan Class, 3)
+	for _, c := range []Class{ClassLow, ClassNormal, ClassHigh} {
+		go func(c Class) {
+			s, err := l.Acquire(context.Background(), c)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- c
+			time.Sleep(time.Millisecond)
+			s.Release()
+		}(c)
+		waitFor(t, l, c, 1)
+	}
+
+	s.Release()
+	for _, expected := range []Class{ClassHigh, ClassNormal, ClassLow} {
+		if c := <-order; c != expected {
+			t.Errorf("expected %s got %s", expected, c)
+		}
+	}
+}
+
+func TestAcquireReserved(t *testing.T) {
+
+	l := NewRegistry(nil).Get("test-reserved", "test")
+	// a share of 0.25 of 4 reserves 1 for the high class
+	l.Configure(4, 0.25, 10*time.Millisecond)
+
+	slots := make([]*Slot, 0, 4)
+	for i := 0; i < 3; i++ {
+		s, err := l.Acquire(context.Background(), ClassLow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slots = append(slots, s)
+	}
+
+	// bulk requests cannot use the reserved capacity
+	if _, err := l.Acquire(context.Background(), ClassNormal); err != ErrUnavailable {
+		t.Errorf("expected %v got %v", ErrUnavailable, err)
+	}
+	s, err := l.Acquire(context.Background(), ClassHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slots = append(slots, s)
+	if _, err := l.Acquire(context.Background(), ClassHigh); err != ErrUnavailable {
+		t.Errorf("expected %v got %v", ErrUnavailable, err)
+	}
+
+	// growing the capacity admits waiting requests
+	done := make(chan error)
+	go func() {
+		s, err := l.Acquire(context.Background(), ClassNormal)
+		s.Release()
+		done <- err
+	}()
+	waitFor(t, l, ClassNormal, 1)
+	l.Configure(8, 0.25, 10*time.Millisecond)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+
+	for _, s := range slots {
+		s.Release()
+	}
+	if l.Active() != 0 {
+		t.Errorf("expected %d got %d", 0, l.Active())
+	}
+}
+
+func TestConfigureReserved(t *testing.T) {
+
+	l := &Limiter{}
+	tests := []struct {
+		capacity int
+		share    float64
+		reserved int
+	}{
+		{0, 0.5, 0},
+		{1, 0.2, 0},
+		{10, 0.2, 2},
+		{10, 0.25, 3},
+		{10, 1, 9},
+	}
+	for i, test := range tests {
+		l.Configure(test.capacity, test.share, 0)
+		if l.reserved != test.reserved {
+			t.Errorf("(%d) expected %d got %d", i, test.reserved, l.reserved)
+		}
+	}
+}
+
+func TestAcquireUnlimited(t *testing.T) {
+	l := NewRegistry(nil).Get("test-unlimited", "test")
+	for i := 0; i < 10; i++ {
+		if _, err := l.Acquire(context.Background(), ClassLow); err != nil {
+			t.Error(err)
+		}
+	}
+	if l.Active() != 10 {
+		t.Errorf("expected %d got %d", 10, l.Active())
+	}
+}
+
+func TestClassString(t *testing.T) {
+	if ClassHigh.String() != "high" {
+		t.Errorf("expected %s got %s", "high", ClassHigh.String())
+	}
+	if Class(7).String() != "7" {
+		t.Errorf("expected %s got %s", "7", Class(7).String())
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/proxy/fixtures"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 )
 
@@ -37,6 +38,11 @@ func NewHTTPClient(oc *oo.Options) (*http.Client, error) {
 		return nil, nil
 	}
 
+	// an origin that is unchanged by a config reload keeps its client
+	if oc.HTTPClient != nil {
+		return oc.HTTPClient, nil
+	}
+
 	var TLSConfig *tls.Config
 
 	if oc.TLS != nil {
@@ -77,17 +83,27 @@ func NewHTTPClient(oc *oo.Options) (*http.Client, error) {
 		}
 	}
 
+	var transport http.RoundTripper = &http.Transport{
+		Dial:                (&net.Dialer{KeepAlive: time.Duration(oc.KeepAliveTimeoutSecs) * time.Second}).Dial,
+		MaxIdleConns:        oc.MaxIdleConns,
+		MaxIdleConnsPerHost: oc.MaxIdleConns,
+		TLSClientConfig:     TLSConfig,
+	}
+
+	// upstream interactions are recorded or replayed beneath the client, so that everything
+	// above the transport runs as it would against the origin
+	if oc.ReplayDir != "" {
+		transport = fixtures.NewReplayer(oc.ReplayDir)
+	} else if oc.RecordDir != "" {
+		transport = fixtures.NewRecorder(oc.RecordDir, transport)
+	}
+
 	return &http.Client{
 		Timeout: oc.Timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
-		Transport: &http.Transport{
-			Dial:                (&net.Dialer{KeepAlive: time.Duration(oc.KeepAliveTimeoutSecs) * time.Second}).Dial,
-			MaxIdleConns:        oc.MaxIdleConns,
-			MaxIdleConnsPerHost: oc.MaxIdleConns,
-			TLSClientConfig:     TLSConfig,
-		},
+		Transport: transport,
 	}, nil
 
 }
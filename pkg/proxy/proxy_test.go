@@ -19,6 +19,7 @@ package proxy
 import (
 	"testing"
 
+	"github.com/tricksterproxy/trickster/pkg/proxy/fixtures"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	tlstest "github.com/tricksterproxy/trickster/pkg/util/testing/tls"
 )
@@ -47,11 +48,22 @@ func TestNewHTTPClient(t *testing.T) {
 
 	// test good originconfig, no CA
 	oc := oo.NewOptions()
-	_, err = NewHTTPClient(oc)
+	c, err = NewHTTPClient(oc)
 	if err != nil {
 		t.Error(err)
 	}
 
+	// test the client of an origin that is unchanged by a reload is kept
+	oc.HTTPClient = c
+	c2, err := NewHTTPClient(oc)
+	if err != nil {
+		t.Error(err)
+	}
+	if c2 != c {
+		t.Error("expected the origin's existing client")
+	}
+	oc.HTTPClient = nil
+
 	// test good originconfig, 1 good CA
 	oc.TLS.CertificateAuthorityPaths = []string{caFile}
 	_, err = NewHTTPClient(oc)
@@ -98,3 +110,25 @@ func TestNewHTTPClient(t *testing.T) {
 		t.Errorf("failed to find any PEM data in key input for file %s", oc.TLS.ClientKeyPath)
 	}
 }
+
+func TestNewHTTPClientFixtures(t *testing.T) {
+
+	oc := oo.NewOptions()
+	oc.RecordDir = "/tmp/trickster/fixtures"
+	c, err := NewHTTPClient(oc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Transport.(*fixtures.Recorder); !ok {
+		t.Errorf("expected %T got %T", &fixtures.Recorder{}, c.Transport)
+	}
+
+	oc.ReplayDir = "/tmp/trickster/fixtures"
+	c, err = NewHTTPClient(oc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Transport.(*fixtures.Replayer); !ok {
+		t.Errorf("expected %T got %T", &fixtures.Replayer{}, c.Transport)
+	}
+}
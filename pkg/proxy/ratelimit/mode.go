@@ -0,0 +1,52 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import "strconv"
+
+// Mode enumerates the ways that upstream requests are handled while an origin is throttled
+type Mode int
+
+const (
+	// ModeQueue indicates that upstream requests wait for the origin's rate limit to reset,
+	// and are rejected only when the reset is further away than the maximum wait
+	ModeQueue = Mode(iota)
+	// ModeReject indicates that upstream requests are rejected until the origin's rate limit resets
+	ModeReject
+)
+
+// Names is a map of Modes keyed by string name
+var Names = map[string]Mode{
+	"queue":  ModeQueue,
+	"reject": ModeReject,
+}
+
+// Values is a map of Modes valued by string name
+var Values = make(map[Mode]string)
+
+func init() {
+	for k, v := range Names {
+		Values[v] = k
+	}
+}
+
+func (m Mode) String() string {
+	if v, ok := Values[m]; ok {
+		return v
+	}
+	return strconv.Itoa(int(m))
+}
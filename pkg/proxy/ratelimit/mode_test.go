@@ -0,0 +1,35 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import "testing"
+
+func TestModeString(t *testing.T) {
+
+	if ModeQueue.String() != "queue" {
+		t.Errorf("expected %s got %s", "queue", ModeQueue.String())
+	}
+
+	if ModeReject.String() != "reject" {
+		t.Errorf("expected %s got %s", "reject", ModeReject.String())
+	}
+
+	var m Mode = 3
+	if m.String() != "3" {
+		t.Errorf("expected %s got %s", "3", m.String())
+	}
+}
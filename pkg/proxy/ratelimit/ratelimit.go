@@ -0,0 +1,237 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ratelimit maintains estimates of the capacity remaining in the rate limits of
+// Trickster's upstream origins, as reported in origin response headers, so that upstream
+// requests can be paced to stay within them
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// epochThreshold is the reset header value at or above which the value is interpreted as an
+// epoch timestamp, rather than as a number of seconds until the reset (~ September 2001)
+const epochThreshold = 1e9
+
+// windowTolerance is the difference in reset times under which two observations are considered
+// to describe the same rate limit window, since relative reset times are rounded to the second
+const windowTolerance = time.Second
+
+// Tracker maintains an estimate of the requests remaining in a single origin's rate limit
+// window. The estimate is set from origin response headers, and decremented for each upstream
+// request that is reserved against it, until the window resets
+type Tracker struct {
+	originName string
+	originType string
+	metrics    *metrics.Metrics
+	// known is true while the estimate describes a window that has not yet reset
+	known     bool
+	remaining int
+	reset     time.Time
+	throttled bool
+	mtx       sync.Mutex
+}
+
+// Registry holds the Trackers of a router's origins
+type Registry struct {
+	metrics  *metrics.Metrics
+	trackers sync.Map
+}
+
+// NewRegistry returns a new Registry whose Trackers report to the Metrics
+func NewRegistry(m *metrics.Metrics) *Registry {
+	return &Registry{metrics: m}
+}
+
+// Get returns the Tracker for the named origin, creating it if necessary
+func (r *Registry) Get(originName, originType string) *Tracker {
+	if t, ok := r.trackers.Load(originName); ok {
+		return t.(*Tracker)
+	}
+	t, _ := r.trackers.LoadOrStore(originName, &Tracker{originName: originName,
+		originType: originType, metrics: r.metrics})
+	return t.(*Tracker)
+}
+
+// Throttled returns true if upstream requests to the named origin are currently being paced
+func (r *Registry) Throttled(originName string) bool {
+	if t, ok := r.trackers.Load(originName); ok {
+		return t.(*Tracker).Throttled()
+	}
+	return false
+}
+
+// ParseHeaders returns the remaining request count and the reset time reported in the named
+// response headers, and false if either is absent or invalid. The reset header may provide a
+// number of seconds until the reset, an epoch timestamp in seconds, or an HTTP date
+func ParseHeaders(h http.Header, remainingName, resetName string, now time.Time) (int, time.Time, bool) {
+	if h == nil || remainingName == "" || resetName == "" {
+		return 0, time.Time{}, false
+	}
+	rv := strings.TrimSpace(h.Get(remainingName))
+	if rv == "" {
+		return 0, time.Time{}, false
+	}
+	remaining, err := strconv.Atoi(rv)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	reset, ok := parseReset(h.Get(resetName), now)
+	if !ok {
+		return 0, time.Time{}, false
+	}
+	return remaining, reset, true
+}
+
+// ParseRetryAfter returns the time indicated by a Retry-After response header, and false
+// if the header is absent or invalid
+func ParseRetryAfter(h http.Header, now time.Time) (time.Time, bool) {
+	if h == nil {
+		return time.Time{}, false
+	}
+	v := strings.TrimSpace(h.Get(headers.NameRetryAfter))
+	if v == "" {
+		return time.Time{}, false
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil && secs >= 0 {
+		return now.Add(time.Duration(secs) * time.Second), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func parseReset(v string, now time.Time) (time.Time, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, false
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		if f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return time.Time{}, false
+		}
+		if f >= epochThreshold {
+			return time.Unix(0, int64(f*1e9)), true
+		}
+		return now.Add(time.Duration(f * float64(time.Second))), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// Observe updates the estimate from the remaining request count and reset time reported by
+// the origin, and returns true if the observation changed the throttled state. Observations
+// of an earlier window than the current estimate's, such as from a slow response, are ignored,
+// and observations of the same window can only lower the estimate
+func (t *Tracker) Observe(remaining int, reset time.Time, minRemaining int, now time.Time) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	before := t.throttled
+	switch {
+	case !t.known || reset.After(t.reset.Add(windowTolerance)):
+		t.known = true
+		t.remaining = remaining
+		t.reset = reset
+	case reset.Before(t.reset.Add(-windowTolerance)):
+	default:
+		if remaining < t.remaining {
+			t.remaining = remaining
+		}
+		if reset.After(t.reset) {
+			t.reset = reset
+		}
+	}
+	t.update(minRemaining, now)
+	t.report()
+	return t.throttled != before
+}
+
+// Reserve reserves capacity for an upstream request, and returns how long the request must
+// wait for the origin's rate limit to reset, which is 0 if it may proceed immediately, and
+// true if the reservation changed the throttled state. Requests that must wait do not
+// reserve capacity, and should call Reserve again once the wait is over
+func (t *Tracker) Reserve(minRemaining int, now time.Time) (time.Duration, bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	before := t.throttled
+	t.update(minRemaining, now)
+	var wait time.Duration
+	if t.throttled {
+		wait = t.reset.Sub(now)
+	} else if t.known {
+		t.remaining--
+		t.update(minRemaining, now)
+	}
+	t.report()
+	return wait, t.throttled != before
+}
+
+// update expires the estimate once its window has reset, and determines whether the origin
+// is throttled. The caller must hold the lock
+func (t *Tracker) update(minRemaining int, now time.Time) {
+	if t.known && !now.Before(t.reset) {
+		t.known = false
+	}
+	t.throttled = t.known && t.remaining <= minRemaining
+}
+
+// report sets the Tracker's metrics, once a window has been observed. The caller must hold the lock
+func (t *Tracker) report() {
+	if t.reset.IsZero() {
+		return
+	}
+	var throttled float64
+	if t.throttled {
+		throttled = 1
+	}
+	m := metrics.OrDefault(t.metrics)
+	m.ProxyOriginRateLimitRemaining.WithLabelValues(t.originName, t.originType).
+		Set(float64(t.remaining))
+	m.ProxyOriginRateLimitReset.WithLabelValues(t.originName, t.originType).
+		Set(float64(t.reset.Unix()))
+	m.ProxyOriginRateLimitThrottled.WithLabelValues(t.originName, t.originType).Set(throttled)
+}
+
+// Throttled returns true if upstream requests are currently being paced. An origin is
+// throttled while its estimate is at or below its minimum, until the window resets
+func (t *Tracker) Throttled() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.throttled && time.Now().Before(t.reset)
+}
+
+// Remaining returns the current estimate of the remaining request count and the reset time,
+// and false if no window is known
+func (t *Tracker) Remaining() (int, time.Time, bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.remaining, t.reset, t.known && time.Now().Before(t.reset)
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseHeaders(t *testing.T) {
+
+	now := time.Unix(1600000000, 0)
+
+	tests := []struct {
+		remaining, reset string
+		expRemaining     int
+		expReset         time.Time
+		expOK            bool
+	}{
+		{"10", "30", 10, now.Add(30 * time.Second), true},
+		{"10", "0.5", 10, now.Add(500 * time.Millisecond), true},
+		{"0", "1600000060", 0, now.Add(time.Minute), true},
+		{"-1", "30", 0, now.Add(30 * time.Second), true},
+		{"5", now.Add(time.Hour).UTC().Format(http.TimeFormat), 5, now.Add(time.Hour), true},
+		{"", "30", 0, time.Time{}, false},
+		{"10", "", 0, time.Time{}, false},
+		{"ten", "30", 0, time.Time{}, false},
+		{"10", "soon", 0, time.Time{}, false},
+		{"10", "-30", 0, time.Time{}, false},
+	}
+
+	for i, test := range tests {
+		h := http.Header{}
+		if test.remaining != "" {
+			h.Set("X-RateLimit-Remaining", test.remaining)
+		}
+		if test.reset != "" {
+			h.Set("X-RateLimit-Reset", test.reset)
+		}
+		remaining, reset, ok := ParseHeaders(h, "X-RateLimit-Remaining", "X-RateLimit-Reset", now)
+		if ok != test.expOK {
+			t.Errorf("(%d) expected %t got %t", i, test.expOK, ok)
+			continue
+		}
+		if remaining != test.expRemaining {
+			t.Errorf("(%d) expected %d got %d", i, test.expRemaining, remaining)
+		}
+		if !reset.Equal(test.expReset) {
+			t.Errorf("(%d) expected %s got %s", i, test.expReset, reset)
+		}
+	}
+
+	if _, _, ok := ParseHeaders(nil, "X-RateLimit-Remaining", "X-RateLimit-Reset", now); ok {
+		t.Errorf("expected %t got %t", false, ok)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+
+	now := time.Unix(1600000000, 0)
+
+	h := http.Header{"Retry-After": []string{"120"}}
+	reset, ok := ParseRetryAfter(h, now)
+	if !ok || !reset.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("expected %s got %s", now.Add(2*time.Minute), reset)
+	}
+
+	h.Set("Retry-After", now.Add(time.Hour).UTC().Format(http.TimeFormat))
+	reset, ok = ParseRetryAfter(h, now)
+	if !ok || !reset.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected %s got %s", now.Add(time.Hour), reset)
+	}
+
+	h.Set("Retry-After", "later")
+	if _, ok = ParseRetryAfter(h, now); ok {
+		t.Errorf("expected %t got %t", false, ok)
+	}
+
+	if _, ok = ParseRetryAfter(nil, now); ok {
+		t.Errorf("expected %t got %t", false, ok)
+	}
+}
+
+func TestReserve(t *testing.T) {
+
+	now := time.Now()
+	r := NewRegistry(nil)
+	tr := r.Get("test-reserve", "test")
+
+	// no window has been observed, so requests are not paced
+	if wait, changed := tr.Reserve(1, now); wait != 0 || changed {
+		t.Errorf("expected %d/%t got %s/%t", 0, false, wait, changed)
+	}
+	if _, _, ok := tr.Remaining(); ok {
+		t.Errorf("expected %t got %t", false, ok)
+	}
+
+	reset := now.Add(time.Minute)
+	if changed := tr.Observe(3, reset, 1, now); changed {
+		t.Errorf("expected %t got %t", false, changed)
+	}
+
+	// the first reservation is taken from the estimate
+	if wait, changed := tr.Reserve(1, now); wait != 0 || changed {
+		t.Errorf("expected %d/%t got %s/%t", 0, false, wait, changed)
+	}
+
+	// the second reservation reaches the minimum, so the origin is throttled
+	if wait, changed := tr.Reserve(1, now); wait != 0 || !changed {
+		t.Errorf("expected %d/%t got %s/%t", 0, true, wait, changed)
+	}
+	if !r.Throttled("test-reserve") {
+		t.Errorf("expected %t got %t", true, false)
+	}
+
+	// subsequent reservations must wait for the reset
+	if wait, changed := tr.Reserve(1, now); wait != time.Minute || changed {
+		t.Errorf("expected %s/%t got %s/%t", time.Minute, false, wait, changed)
+	}
+	if remaining, _, _ := tr.Remaining(); remaining != 1 {
+		t.Errorf("expected %d got %d", 1, remaining)
+	}
+
+	// once the window resets, requests are no longer paced
+	if wait, changed := tr.Reserve(1, reset); wait != 0 || !changed {
+		t.Errorf("expected %d/%t got %s/%t", 0, true, wait, changed)
+	}
+}
+
+func TestObserve(t *testing.T) {
+
+	now := time.Now()
+	tr := NewRegistry(nil).Get("test-observe", "test")
+	reset := now.Add(time.Minute)
+
+	if changed := tr.Observe(10, reset, 1, now); changed {
+		t.Errorf("expected %t got %t", false, changed)
+	}
+
+	// an observation of the same window can only lower the estimate
+	tr.Observe(20, reset.Add(500*time.Millisecond), 1, now)
+	if remaining, r, _ := tr.Remaining(); remaining != 10 || !r.Equal(reset.Add(500*time.Millisecond)) {
+		t.Errorf("expected %d/%s got %d/%s", 10, reset.Add(500*time.Millisecond), remaining, r)
+	}
+
+	if changed := tr.Observe(0, reset, 1, now); !changed {
+		t.Errorf("expected %t got %t", true, changed)
+	}
+
+	// an observation of an earlier window is ignored
+	if changed := tr.Observe(50, now.Add(time.Second), 1, now); changed {
+		t.Errorf("expected %t got %t", false, changed)
+	}
+	if remaining, _, _ := tr.Remaining(); remaining != 0 {
+		t.Errorf("expected %d got %d", 0, remaining)
+	}
+
+	// an observation of a later window replaces the estimate
+	if changed := tr.Observe(100, reset.Add(time.Minute), 1, now); !changed {
+		t.Errorf("expected %t got %t", true, changed)
+	}
+	if tr.Throttled() {
+		t.Errorf("expected %t got %t", false, true)
+	}
+}
+
+func TestRegistryGet(t *testing.T) {
+	r := NewRegistry(nil)
+	t1 := r.Get("test-get", "test")
+	t2 := r.Get("test-get", "test")
+	if t1 != t2 {
+		t.Error("expected the same tracker")
+	}
+	if r.Throttled("test-get-unknown") {
+		t.Errorf("expected %t got %t", false, true)
+	}
+	if NewRegistry(nil).Get("test-get", "test") == t1 {
+		t.Error("expected registries not to share trackers")
+	}
+}
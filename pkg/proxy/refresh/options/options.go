@@ -0,0 +1,228 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the options for Refresh Jobs, which periodically request
+// designated queries in the background to keep their cache entries fresh
+package options
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Options defines a Refresh Job
+type Options struct {
+	// Name provides the name of the Refresh Job
+	Name string `toml:"-"`
+	// OriginName is the name of the Origin whose handlers serve the Refresh Job's request
+	OriginName string `toml:"origin_name"`
+	// Path is the URL Path of the Refresh Job's request, relative to the Origin's routes
+	Path string `toml:"path"`
+	// Query is the URL Query String template of the Refresh Job's request. Parameter values
+	// of 'now', or of 'now' offset by a duration (e.g., 'now-6h'), are replaced with the
+	// corresponding Unix epoch time whenever the job is executed. See RenderQuery
+	Query string `toml:"query"`
+	// Headers are the HTTP Headers to include in the Refresh Job's request
+	Headers map[string]string `toml:"headers"`
+	// Enabled indicates whether the Refresh Job is scheduled. Disabled jobs may still be run on demand
+	Enabled bool `toml:"enabled"`
+	// IntervalSecs is how often the Refresh Job is executed
+	IntervalSecs int `toml:"interval_secs"`
+	// JitterMS is the maximum random delay added to each execution, so that jobs
+	// sharing an interval do not all hit the Origin at once
+	JitterMS int `toml:"jitter_ms"`
+	// MaxBackoffSecs is the maximum delay between executions of a failing Refresh Job,
+	// whose interval is doubled with each consecutive failure
+	MaxBackoffSecs int `toml:"max_backoff_secs"`
+
+	// Interval is the time.Duration representation of IntervalSecs
+	Interval time.Duration `toml:"-"`
+	// Jitter is the time.Duration representation of JitterMS
+	Jitter time.Duration `toml:"-"`
+	// MaxBackoff is the time.Duration representation of MaxBackoffSecs
+	MaxBackoff time.Duration `toml:"-"`
+}
+
+// ErrNoPath is returned when a Refresh Job does not define a path
+var ErrNoPath = errors.New("refresh job path is required")
+
+// NewOptions returns a new Refresh Job Options with the default values
+func NewOptions() *Options {
+	return &Options{
+		Enabled:        true,
+		IntervalSecs:   d.DefaultRefreshJobIntervalSecs,
+		JitterMS:       d.DefaultRefreshJobJitterMS,
+		MaxBackoffSecs: d.DefaultRefreshJobMaxBackoffSecs,
+		Interval:       time.Duration(d.DefaultRefreshJobIntervalSecs) * time.Second,
+		Jitter:         time.Duration(d.DefaultRefreshJobJitterMS) * time.Millisecond,
+		MaxBackoff:     time.Duration(d.DefaultRefreshJobMaxBackoffSecs) * time.Second,
+	}
+}
+
+// Clone returns an exact copy of the Options
+func (o *Options) Clone() *Options {
+	c := &Options{
+		Name:           o.Name,
+		OriginName:     o.OriginName,
+		Path:           o.Path,
+		Query:          o.Query,
+		Enabled:        o.Enabled,
+		IntervalSecs:   o.IntervalSecs,
+		JitterMS:       o.JitterMS,
+		MaxBackoffSecs: o.MaxBackoffSecs,
+		Interval:       o.Interval,
+		Jitter:         o.Jitter,
+		MaxBackoff:     o.MaxBackoff,
+	}
+	if o.Headers != nil {
+		c.Headers = make(map[string]string, len(o.Headers))
+		for k, v := range o.Headers {
+			c.Headers[k] = v
+		}
+	}
+	return c
+}
+
+// ProcessRefreshJobOptions applies defaults to, and validates, the provided Refresh Job Options
+// collection, ensuring each job refers to one of the provided Origin names
+func ProcessRefreshJobOptions(mo map[string]*Options, metadata *toml.MetaData,
+	originNames map[string]bool) error {
+	for k, v := range mo {
+		v.Name = k
+		if metadata != nil {
+			if !metadata.IsDefined("refresh_jobs", k, "enabled") {
+				v.Enabled = true
+			}
+			if !metadata.IsDefined("refresh_jobs", k, "interval_secs") {
+				v.IntervalSecs = d.DefaultRefreshJobIntervalSecs
+			}
+			if !metadata.IsDefined("refresh_jobs", k, "jitter_ms") {
+				v.JitterMS = d.DefaultRefreshJobJitterMS
+			}
+			if !metadata.IsDefined("refresh_jobs", k, "max_backoff_secs") {
+				v.MaxBackoffSecs = d.DefaultRefreshJobMaxBackoffSecs
+			}
+		}
+		if _, ok := originNames[v.OriginName]; !ok {
+			return fmt.Errorf("invalid origin name [%s] provided in refresh job [%s]", v.OriginName, k)
+		}
+		if v.Path == "" {
+			return fmt.Errorf("%s: [%s]", ErrNoPath.Error(), k)
+		}
+		if !strings.HasPrefix(v.Path, "/") {
+			v.Path = "/" + v.Path
+		}
+		if _, err := RenderQuery(v.Query, time.Now()); err != nil {
+			return fmt.Errorf("invalid query provided in refresh job [%s]: %s", k, err.Error())
+		}
+		if v.IntervalSecs <= 0 {
+			return fmt.Errorf("invalid interval_secs [%d] provided in refresh job [%s]", v.IntervalSecs, k)
+		}
+		if v.JitterMS < 0 {
+			v.JitterMS = 0
+		}
+		if v.MaxBackoffSecs < v.IntervalSecs {
+			v.MaxBackoffSecs = v.IntervalSecs
+		}
+		v.Interval = time.Duration(v.IntervalSecs) * time.Second
+		v.Jitter = time.Duration(v.JitterMS) * time.Millisecond
+		v.MaxBackoff = time.Duration(v.MaxBackoffSecs) * time.Second
+	}
+	return nil
+}
+
+// relativeTimeUnits are the duration units of relative times that time.ParseDuration does not
+// support, by their suffixes
+var relativeTimeUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 24 * 7 * time.Hour,
+	"y": 24 * 365 * time.Hour,
+}
+
+// RenderQuery returns the query string template with each parameter value of 'now', or of
+// 'now' offset by a duration (e.g., 'now-6h'), replaced with the corresponding Unix epoch time.
+// Only values that are exactly 'now', or 'now' followed by a sign, are relative times, so others
+// that begin with 'now', such as 'nowcast_total', are left as they are. It returns an error when
+// the offset of a relative time is not a valid duration
+func RenderQuery(query string, now time.Time) (string, error) {
+	if query == "" {
+		return "", nil
+	}
+	parts := strings.Split(query, "&")
+	for i, p := range parts {
+		eq := strings.Index(p, "=")
+		if eq < 0 {
+			continue
+		}
+		v, err := url.QueryUnescape(p[eq+1:])
+		if err != nil {
+			continue
+		}
+		t, ok, err := relativeTime(v, now)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			parts[i] = p[:eq+1] + strconv.FormatInt(t.Unix(), 10)
+		}
+	}
+	return strings.Join(parts, "&"), nil
+}
+
+// relativeTime returns the time of the value, and true, when it is 'now' or 'now' offset by a
+// signed duration, such as 'now-6h' or 'now+1d'
+func relativeTime(v string, now time.Time) (time.Time, bool, error) {
+	if v == "now" {
+		return now, true, nil
+	}
+	if len(v) < 4 || v[:3] != "now" || (v[3] != '-' && v[3] != '+') {
+		return now, false, nil
+	}
+	d, err := parseOffset(v[4:])
+	if err != nil {
+		return now, false, fmt.Errorf("invalid relative time: %s", v)
+	}
+	if v[3] == '-' {
+		d = -d
+	}
+	return now.Add(d), true, nil
+}
+
+// parseOffset returns the duration of the offset of a relative time, which is a duration string
+// (e.g., '90m' or '1h30m'), or a number of days, weeks or years (e.g., '7d')
+func parseOffset(s string) (time.Duration, error) {
+	for suffix, unit := range relativeTimeUnits {
+		if n := strings.TrimSuffix(s, suffix); n != s {
+			i, err := strconv.ParseInt(n, 10, 64)
+			if err != nil || i < 0 {
+				return 0, fmt.Errorf("invalid duration: %s", s)
+			}
+			return time.Duration(i) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 || s[0] == '+' {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestProcessRefreshJobOptions(t *testing.T) {
+
+	originNames := map[string]bool{"default": true}
+
+	tests := []struct {
+		conf      string
+		expectErr bool
+	}{
+		{"[refresh_jobs.test]\norigin_name = 'default'\npath = 'api/v1/query_range'\n", false},
+		{"[refresh_jobs.test]\norigin_name = 'missing'\npath = '/'\n", true},
+		{"[refresh_jobs.test]\norigin_name = 'default'\n", true},
+		{"[refresh_jobs.test]\norigin_name = 'default'\npath = '/'\ninterval_secs = 0\n", true},
+		{"[refresh_jobs.test]\norigin_name = 'default'\npath = '/'\nquery = 'start=now-6x'\n", true},
+	}
+
+	for i, test := range tests {
+		c := struct {
+			RefreshJobs map[string]*Options `toml:"refresh_jobs"`
+		}{}
+		md, err := toml.Decode(test.conf, &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ProcessRefreshJobOptions(c.RefreshJobs, &md, originNames)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %s", i, err.Error())
+			continue
+		}
+		o := c.RefreshJobs["test"]
+		if o.Name != "test" || o.Path != "/api/v1/query_range" || !o.Enabled {
+			t.Errorf("test %d: unexpected options %+v", i, o)
+		}
+		if o.Interval != 300*time.Second || o.Jitter != 5*time.Second || o.MaxBackoff != time.Hour {
+			t.Errorf("test %d: unexpected durations %+v", i, o)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := NewOptions()
+	o.Name = "test"
+	o.Headers = map[string]string{"X-Test": "1"}
+	c := o.Clone()
+	if c.Name != o.Name || c.Interval != o.Interval || c.Headers["X-Test"] != "1" {
+		t.Errorf("unexpected clone %+v", c)
+	}
+	c.Headers["X-Test"] = "2"
+	if o.Headers["X-Test"] != "1" {
+		t.Error("expected clone headers to be independent")
+	}
+}
+
+func TestRenderQuery(t *testing.T) {
+
+	now := time.Unix(1600000000, 0)
+	tests := []struct {
+		query, expected string
+		expectErr       bool
+	}{
+		{"", "", false},
+		{"query=up&start=now-6h&end=now&step=60",
+			"query=up&start=1599978400&end=1600000000&step=60", false},
+		{"query=sum%28up%29&end=now%2B1d", "query=sum%28up%29&end=1600086400", false},
+		{"start=now-1h30m", "start=1599994600", false},
+		{"query=nowhere&flag", "query=nowhere&flag", false},
+		{"query=nowcast_total&start=now-1w", "query=nowcast_total&start=1599395200", false},
+		{"start=now-6x", "", true},
+		{"start=now%2B", "", true},
+		{"start=now--1h", "", true},
+	}
+
+	for i, test := range tests {
+		q, err := RenderQuery(test.query, now)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %s", i, err.Error())
+		}
+		if q != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, q)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package refresh provides Refresh Jobs, which periodically request designated queries through
+// an Origin's handlers in the background, so that their cache entries are always fresh
+package refresh
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	ro "github.com/tricksterproxy/trickster/pkg/proxy/refresh/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// ErrPaused is returned when a Refresh Job is not executed because its Origin is unhealthy
+var ErrPaused = errors.New("refresh job is paused while its origin is unhealthy")
+
+// maxBackoffShift bounds the exponent of the failure backoff, so that it cannot overflow
+const maxBackoffShift = 16
+
+// Status describes the current state of a Refresh Job
+type Status struct {
+	Name                string     `json:"name"`
+	OriginName          string     `json:"origin"`
+	Path                string     `json:"path"`
+	Enabled             bool       `json:"enabled"`
+	Paused              bool       `json:"paused"`
+	Runs                int        `json:"runs"`
+	Failures            int        `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastRun             *time.Time `json:"lastRun,omitempty"`
+	LastStatusCode      int        `json:"lastStatusCode,omitempty"`
+	LastDurationMS      int64      `json:"lastDurationMs,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	NextRun             *time.Time `json:"nextRun,omitempty"`
+}
+
+// Job is a scheduled background request for a designated query
+type Job struct {
+	options *ro.Options
+	handler http.Handler
+	metrics *metrics.Metrics
+	logger  *tl.Logger
+	// paused reports whether executions of jobs for the named origin should be skipped
+	paused func(originName string) bool
+
+	triggerCh chan struct{}
+	mtx       sync.Mutex
+	status    Status
+}
+
+// NewJob returns a new Job that serves its requests with the provided handler, which is
+// normally the router of the job's Origin, so that requests pass through the full handler chain.
+// The Job is not paused until its Scheduler's health func is set
+func NewJob(o *ro.Options, handler http.Handler, m *metrics.Metrics, logger *tl.Logger) *Job {
+	return &Job{
+		options:   o,
+		handler:   handler,
+		metrics:   metrics.OrDefault(m),
+		logger:    logger,
+		paused:    func(string) bool { return false },
+		triggerCh: make(chan struct{}, 1),
+		status: Status{
+			Name:       o.Name,
+			OriginName: o.OriginName,
+			Path:       o.Path,
+			Enabled:    o.Enabled,
+		},
+	}
+}
+
+// Status returns a copy of the Job's current Status
+func (j *Job) Status() Status {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	s := j.status
+	s.Paused = j.paused(j.options.OriginName)
+	return s
+}
+
+// Trigger requests that the Job be executed as soon as possible, independent of its schedule.
+// It returns false if an on-demand execution is already pending
+func (j *Job) Trigger() bool {
+	select {
+	case j.triggerCh <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Execute makes the Job's request through its handler and records the result
+func (j *Job) Execute() error {
+
+	o := j.options
+	if j.paused(o.OriginName) {
+		j.metrics.ProxyRefreshJobRuns.WithLabelValues(o.Name, o.OriginName, "paused").Inc()
+		j.logger.Debug("refresh job paused", tl.Pairs{"jobName": o.Name, "originName": o.OriginName})
+		return ErrPaused
+	}
+
+	start := time.Now()
+	w := &discardWriter{header: make(http.Header)}
+	r, err := j.newRequest(start)
+	if err == nil {
+		j.handler.ServeHTTP(w, r)
+		if w.code >= http.StatusBadRequest {
+			err = fmt.Errorf("unexpected response code: %d", w.code)
+		}
+	}
+	elapsed := time.Since(start)
+
+	j.mtx.Lock()
+	j.status.Runs++
+	j.status.LastRun = &start
+	j.status.LastStatusCode = w.code
+	j.status.LastDurationMS = elapsed.Milliseconds()
+	wasFailing := j.status.ConsecutiveFailures > 0
+	if err != nil {
+		j.status.Failures++
+		j.status.ConsecutiveFailures++
+		j.status.LastError = err.Error()
+	} else {
+		j.status.ConsecutiveFailures = 0
+		j.status.LastError = ""
+	}
+	j.mtx.Unlock()
+
+	j.metrics.ProxyRefreshJobDuration.WithLabelValues(o.Name, o.OriginName).Observe(elapsed.Seconds())
+	if err != nil {
+		j.metrics.ProxyRefreshJobRuns.WithLabelValues(o.Name, o.OriginName, "failure").Inc()
+		if !wasFailing {
+			j.logger.Warn("refresh job failed", tl.Pairs{"jobName": o.Name,
+				"originName": o.OriginName, "path": o.Path, "detail": err.Error()})
+		}
+		return err
+	}
+	j.metrics.ProxyRefreshJobRuns.WithLabelValues(o.Name, o.OriginName, "success").Inc()
+	if wasFailing {
+		j.logger.Info("refresh job recovered", tl.Pairs{"jobName": o.Name, "originName": o.OriginName})
+	}
+	j.logger.Debug("refresh job completed", tl.Pairs{"jobName": o.Name, "originName": o.OriginName,
+		"statusCode": w.code, "elapsed": elapsed.String()})
+	return nil
+}
+
+// newRequest returns the Job's request, with the relative times in its query resolved against now
+func (j *Job) newRequest(now time.Time) (*http.Request, error) {
+	q, err := ro.RenderQuery(j.options.Query, now)
+	if err != nil {
+		return nil, err
+	}
+	u := &url.URL{Scheme: "http", Host: "localhost", Path: j.options.Path, RawQuery: q}
+	r, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range j.options.Headers {
+		r.Header.Set(k, v)
+	}
+	return r, nil
+}
+
+// nextDelay returns how long to wait before the next scheduled execution, which backs off
+// exponentially while the Job is failing, plus a random jitter
+func (j *Job) nextDelay() time.Duration {
+	o := j.options
+	j.mtx.Lock()
+	f := j.status.ConsecutiveFailures
+	j.mtx.Unlock()
+	d := o.Interval
+	if f > 0 {
+		if f > maxBackoffShift {
+			f = maxBackoffShift
+		}
+		d = o.Interval << uint(f)
+		if d > o.MaxBackoff || d <= 0 {
+			d = o.MaxBackoff
+		}
+	}
+	return d + jitter(o.Jitter)
+}
+
+func (j *Job) run(stopCh chan struct{}) {
+	// the first scheduled execution happens shortly after startup, so that entries that
+	// went stale while Trickster was not running are refreshed before they are requested
+	delay := jitter(j.options.Jitter)
+	for {
+		var timerCh <-chan time.Time
+		var timer *time.Timer
+		if j.options.Enabled {
+			timer = time.NewTimer(delay)
+			timerCh = timer.C
+			next := time.Now().Add(delay)
+			j.mtx.Lock()
+			j.status.NextRun = &next
+			j.mtx.Unlock()
+		}
+		select {
+		case <-stopCh:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-j.triggerCh:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-timerCh:
+		}
+		j.Execute()
+		delay = j.nextDelay()
+	}
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// discardWriter is an http.ResponseWriter that records the response code and discards the body
+type discardWriter struct {
+	header http.Header
+	code   int
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+// Scheduler runs a collection of Refresh Jobs in the background
+type Scheduler struct {
+	jobs     map[string]*Job
+	names    []string
+	logger   *tl.Logger
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewScheduler returns a new Scheduler for the provided Refresh Jobs, whose requests are
+// served by the routers of their respective Origins. Jobs whose Origin has no router are skipped
+func NewScheduler(jobs map[string]*ro.Options, origins map[string]*oo.Options,
+	m *metrics.Metrics, logger *tl.Logger) *Scheduler {
+	s := &Scheduler{
+		jobs:   make(map[string]*Job, len(jobs)),
+		names:  make([]string, 0, len(jobs)),
+		logger: logger,
+		stopCh: make(chan struct{}),
+	}
+	for k, o := range jobs {
+		oc, ok := origins[o.OriginName]
+		if !ok || oc.Router == nil {
+			logger.Warn("refresh job skipped, origin has no routes",
+				tl.Pairs{"jobName": k, "originName": o.OriginName})
+			continue
+		}
+		s.jobs[k] = NewJob(o, oc.Router, m, logger)
+		s.names = append(s.names, k)
+	}
+	sort.Strings(s.names)
+	return s
+}
+
+// SetHealth sets the func that reports whether the named Origin is healthy, while which the
+// Scheduler's Jobs for the Origin are run, such as the Origin's running background prober.
+// It must be called before the Scheduler is started
+func (s *Scheduler) SetHealth(healthy func(originName string) bool) {
+	for _, j := range s.jobs {
+		j.paused = func(originName string) bool { return !healthy(originName) }
+	}
+}
+
+// Job returns the named Job, or nil if there is no such Job
+func (s *Scheduler) Job(name string) *Job {
+	return s.jobs[name]
+}
+
+// Statuses returns the Status of each Job, ordered by name
+func (s *Scheduler) Statuses() []Status {
+	out := make([]Status, 0, len(s.names))
+	for _, n := range s.names {
+		out = append(out, s.jobs[n].Status())
+	}
+	return out
+}
+
+// Start begins running the Scheduler's Jobs in the background
+func (s *Scheduler) Start() {
+	for _, n := range s.names {
+		go s.jobs[n].run(s.stopCh)
+	}
+	if len(s.names) > 0 {
+		s.logger.Info("refresh jobs started", tl.Pairs{"jobCount": len(s.names)})
+	}
+}
+
+// Stop ends the Scheduler's Jobs. Any in-progress executions are allowed to complete
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+}
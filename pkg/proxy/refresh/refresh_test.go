@@ -0,0 +1,199 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package refresh
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	ro "github.com/tricksterproxy/trickster/pkg/proxy/refresh/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"github.com/gorilla/mux"
+)
+
+var testLogger = tl.ConsoleLogger("error")
+
+func testOptions() *ro.Options {
+	o := ro.NewOptions()
+	o.Name = "test"
+	o.OriginName = "default"
+	o.Path = "/api/v1/query_range"
+	o.Query = "query=up&start=now-6h&end=now&step=60"
+	o.Jitter = 0
+	return o
+}
+
+func TestExecute(t *testing.T) {
+
+	code := http.StatusOK
+	var query string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		if r.Header.Get("X-Test") != "1" {
+			t.Error("expected job header")
+		}
+		w.WriteHeader(code)
+	})
+
+	o := testOptions()
+	o.Headers = map[string]string{"X-Test": "1"}
+	j := NewJob(o, h, nil, testLogger)
+
+	if err := j.Execute(); err != nil {
+		t.Error(err)
+	}
+	if query == "" || query == o.Query {
+		t.Errorf("expected rendered query got %s", query)
+	}
+	s := j.Status()
+	if s.Runs != 1 || s.Failures != 0 || s.LastStatusCode != http.StatusOK || s.LastRun == nil {
+		t.Errorf("unexpected status %+v", s)
+	}
+
+	code = http.StatusBadGateway
+	for i := 0; i < 2; i++ {
+		if err := j.Execute(); err == nil {
+			t.Error("expected error")
+		}
+	}
+	s = j.Status()
+	if s.Runs != 3 || s.Failures != 2 || s.ConsecutiveFailures != 2 || s.LastError == "" {
+		t.Errorf("unexpected status %+v", s)
+	}
+
+	code = http.StatusOK
+	j.Execute()
+	if s = j.Status(); s.ConsecutiveFailures != 0 || s.LastError != "" {
+		t.Errorf("unexpected status %+v", s)
+	}
+
+	j.paused = func(string) bool { return true }
+	if err := j.Execute(); err != ErrPaused {
+		t.Errorf("expected %v got %v", ErrPaused, err)
+	}
+	if s = j.Status(); s.Runs != 4 || !s.Paused {
+		t.Errorf("unexpected status %+v", s)
+	}
+}
+
+func TestNextDelay(t *testing.T) {
+
+	o := testOptions()
+	o.Interval = time.Minute
+	o.MaxBackoff = 5 * time.Minute
+	j := NewJob(o, http.NotFoundHandler(), nil, testLogger)
+
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{2, 4 * time.Minute},
+		{3, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+
+	for i, test := range tests {
+		j.status.ConsecutiveFailures = test.failures
+		if d := j.nextDelay(); d != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, d)
+		}
+	}
+
+	o.Jitter = time.Second
+	j.status.ConsecutiveFailures = 0
+	if d := j.nextDelay(); d < time.Minute || d >= time.Minute+time.Second {
+		t.Errorf("expected jittered delay got %s", d)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/query_range", func(w http.ResponseWriter, r *http.Request) {})
+
+	enabled := testOptions()
+	disabled := testOptions()
+	disabled.Name = "disabled"
+	disabled.Enabled = false
+	missing := testOptions()
+	missing.Name = "missing"
+	missing.OriginName = "missing"
+
+	origins := map[string]*oo.Options{"default": {Router: router}}
+	jobs := map[string]*ro.Options{"test": enabled, "disabled": disabled, "missing": missing}
+
+	s := NewScheduler(jobs, origins, nil, testLogger)
+	s.Start()
+	defer s.Stop()
+
+	if s.Job("missing") != nil {
+		t.Error("expected job for missing origin to be skipped")
+	}
+	statuses := s.Statuses()
+	if len(statuses) != 2 || statuses[0].Name != "disabled" || statuses[1].Name != "test" {
+		t.Fatalf("unexpected statuses %+v", statuses)
+	}
+
+	// the enabled job runs at startup, while the disabled job only runs on demand
+	waitFor(t, func() bool { return s.Job("test").Status().Runs == 1 })
+	if s.Job("disabled").Status().Runs != 0 {
+		t.Error("expected disabled job not to run")
+	}
+	if !s.Job("disabled").Trigger() {
+		t.Error("expected trigger to be queued")
+	}
+	waitFor(t, func() bool { return s.Job("disabled").Status().Runs == 1 })
+}
+
+func TestSchedulerSetHealth(t *testing.T) {
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/query_range", func(w http.ResponseWriter, r *http.Request) {})
+
+	s := NewScheduler(map[string]*ro.Options{"test": testOptions()},
+		map[string]*oo.Options{"default": {Router: router}}, nil, testLogger)
+
+	healthy := false
+	s.SetHealth(func(originName string) bool { return healthy })
+	if err := s.Job("test").Execute(); err != ErrPaused {
+		t.Errorf("expected %v got %v", ErrPaused, err)
+	}
+	if !s.Job("test").Status().Paused {
+		t.Error("expected paused job")
+	}
+
+	healthy = true
+	if err := s.Job("test").Execute(); err != nil {
+		t.Error(err)
+	}
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if f() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for condition")
+}
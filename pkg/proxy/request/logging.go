@@ -0,0 +1,45 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package request
+
+import (
+	"context"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+// LogPairs returns the Pairs that correlate the log events of a request: its request ID, and the
+// IDs of its trace and span when it is traced
+func LogPairs(ctx context.Context) tl.Pairs {
+	pairs := make(tl.Pairs, 3)
+	if ctx == nil {
+		return pairs
+	}
+	if id := tctx.RequestID(ctx); id != "" {
+		pairs["request_id"] = id
+	}
+	if sc := trace.SpanFromContext(ctx).SpanContext(); sc.HasTraceID() {
+		pairs["trace_id"] = sc.TraceID.String()
+		if sc.HasSpanID() {
+			pairs["span_id"] = sc.SpanID.String()
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package request
+
+import (
+	"context"
+	"testing"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+
+	"go.opentelemetry.io/otel/api/trace/testtrace"
+)
+
+func TestLogPairs(t *testing.T) {
+
+	if p := LogPairs(nil); len(p) != 0 {
+		t.Errorf("unexpected pairs %v", p)
+	}
+
+	ctx := context.Background()
+	if p := LogPairs(ctx); len(p) != 0 {
+		t.Errorf("unexpected pairs %v", p)
+	}
+
+	ctx = tctx.WithRequestID(ctx, "abc")
+	if p := LogPairs(ctx); len(p) != 1 || p["request_id"] != "abc" {
+		t.Errorf("unexpected pairs %v", p)
+	}
+
+	ctx, span := testtrace.NewTracer().Start(ctx, "request")
+	defer span.End()
+	p := LogPairs(ctx)
+	if len(p) != 3 || p["request_id"] != "abc" ||
+		p["trace_id"] != span.SpanContext().TraceID.String() ||
+		p["span_id"] != span.SpanContext().SpanID.String() {
+		t.Errorf("unexpected pairs %v", p)
+	}
+}
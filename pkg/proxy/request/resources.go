@@ -22,7 +22,9 @@ import (
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/chain"
 	"github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
@@ -44,6 +46,16 @@ type Resources struct {
 	TimeRangeQuery    *timeseries.TimeRangeQuery
 	Tracer            *tracing.Tracer
 	Logger            *tl.Logger
+	// TimeoutDeadline is the time at which the request's timeout budget expires, if it has one
+	TimeoutDeadline time.Time
+	// ChainDepth is the depth of this Trickster in the chain of Tricksters that the request
+	// passed through, where 0 is the Trickster that received the request from the client
+	ChainDepth int
+	// UpstreamChain collects the chain of Tricksters reported by the request's upstream responses
+	UpstreamChain *chain.Upstream
+	// Instance is the runtime state of the router that received the request. When nil, the
+	// Default Instance is used
+	Instance *instance.Instance
 }
 
 // Clone returns an exact copy of the subject Resources collection
@@ -59,6 +71,10 @@ func (r Resources) Clone() *Resources {
 		TimeRangeQuery:    r.TimeRangeQuery,
 		Tracer:            r.Tracer,
 		Logger:            r.Logger,
+		TimeoutDeadline:   r.TimeoutDeadline,
+		ChainDepth:        r.ChainDepth,
+		UpstreamChain:     r.UpstreamChain,
+		Instance:          r.Instance,
 	}
 }
 
@@ -77,6 +93,14 @@ func NewResources(oo *oo.Options, po *po.Options, co *co.Options,
 	}
 }
 
+// State returns the Instance of the Resources, or the Default Instance when it is not set
+func (r *Resources) State() *instance.Instance {
+	if r == nil {
+		return instance.Default
+	}
+	return instance.OrDefault(r.Instance)
+}
+
 // GetResources will return a casted Resource object from the HTTP Request's context
 func GetResources(r *http.Request) *Resources {
 	if r == nil {
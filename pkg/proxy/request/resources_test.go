@@ -23,6 +23,7 @@ import (
 	"time"
 
 	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 )
 
@@ -67,3 +68,18 @@ func TestGetAndSetResources(t *testing.T) {
 	}
 
 }
+
+func TestResourcesState(t *testing.T) {
+	var r *Resources
+	if r.State() != instance.Default {
+		t.Error("expected the default instance")
+	}
+	r = NewResources(nil, nil, nil, nil, nil, nil, tl.ConsoleLogger("error"))
+	if r.State() != instance.Default {
+		t.Error("expected the default instance")
+	}
+	r.Instance = instance.New()
+	if r.Clone().State() != r.Instance {
+		t.Error("expected the resources' instance")
+	}
+}
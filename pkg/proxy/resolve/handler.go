@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package resolve provides a handler that describes how a request would be routed, and the
+// exact inputs from which its cache key would be derived, under the running config
+package resolve
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/handlers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/sampling"
+	"github.com/tricksterproxy/trickster/pkg/routing"
+)
+
+const (
+	// ParamRequest is the parameter of the request line to resolve, of the form "METHOD URL"
+	ParamRequest = "request"
+	// ParamHeader is the parameter of a header of the request to resolve, of the form
+	// "Name: value", which may be repeated
+	ParamHeader = "header"
+)
+
+// HandleFunc returns a handler that resolves the request described by its parameters against
+// the running config, and responds with its routing.Resolution
+func HandleFunc(conf *config.Config) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodGet {
+			handlers.HandleMethodNotAllowedResponse(w, r)
+			return
+		}
+
+		badRequest := func(msg string) {
+			txe.NewResponseError(http.StatusBadRequest, txe.CodeBadRequest, msg).Respond(w, r)
+		}
+
+		qp := r.URL.Query()
+		line := qp.Get(ParamRequest)
+		if line == "" {
+			badRequest("no request provided")
+			return
+		}
+		s, err := sampling.ParseRequestLine(line)
+		if err != nil {
+			badRequest(err.Error() + ": " + line)
+			return
+		}
+		req, err := s.Request()
+		if err != nil {
+			badRequest(err.Error() + ": " + line)
+			return
+		}
+		for _, h := range qp[ParamHeader] {
+			parts := strings.SplitN(h, ":", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+				badRequest("invalid header: " + h)
+				return
+			}
+			req.Header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+
+		// the running config is cloned, since inspecting its routes modifies it
+		in, err := routing.NewInspector(conf.Clone())
+		if err != nil {
+			txe.NewResponseError(http.StatusInternalServerError, txe.CodeInternal,
+				err.Error()).Respond(w, r)
+			return
+		}
+
+		b, _ := json.Marshal(in.Resolve(req))
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/routing"
+)
+
+const testConfig = `
+[origins]
+  [origins.prom1]
+  origin_type = 'prometheus'
+  origin_url = 'http://127.0.0.1:9090'
+  is_default = true
+    [origins.prom1.paths.query]
+    path = '/api/v1/query'
+    methods = ['GET', 'POST']
+    cache_key_headers = ['X-Tenant', 'Connection']
+      [origins.prom1.paths.query.request_headers]
+      'X-Tenant' = 'injected'
+`
+
+func TestHandleFunc(t *testing.T) {
+
+	conf, err := config.LoadDocument(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := HandleFunc(conf)
+
+	resolve := func(method string, qp url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(method, "/trickster/debug/resolve?"+qp.Encode(), nil))
+		return w
+	}
+
+	tests := []struct {
+		method string
+		qp     url.Values
+		code   int
+	}{
+		{http.MethodPost, url.Values{ParamRequest: {"GET /api/v1/query?query=up"}},
+			http.StatusMethodNotAllowed},
+		{http.MethodGet, url.Values{}, http.StatusBadRequest},
+		{http.MethodGet, url.Values{ParamRequest: {"GET"}}, http.StatusBadRequest},
+		{http.MethodGet, url.Values{ParamRequest: {"GET /api/v1/query?query=up"},
+			ParamHeader: {"invalid"}}, http.StatusBadRequest},
+	}
+
+	for i, test := range tests {
+		if w := resolve(test.method, test.qp); w.Code != test.code {
+			t.Errorf("(%d) expected %d got %d", i, test.code, w.Code)
+		}
+	}
+
+	w := resolve(http.MethodGet, url.Values{ParamRequest: {"GET /api/v1/query?query=up&time=0"},
+		ParamHeader: {"Authorization: Basic c2VjcmV0", "X-Tenant: t1", "Connection: close"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if strings.Contains(w.Body.String(), "c2VjcmV0") {
+		t.Errorf("unexpected credentials in %s", w.Body.String())
+	}
+
+	rs := &routing.Resolution{}
+	if err := json.Unmarshal(w.Body.Bytes(), rs); err != nil {
+		t.Fatal(err)
+	}
+	if rs.Origin != "prom1" || rs.Path != "/api/v1/query" || rs.CacheKey == "" ||
+		rs.KeyInputs == nil {
+		t.Fatalf("unexpected resolution %s", w.Body.String())
+	}
+	ki := rs.KeyInputs
+	if ki.Method != http.MethodGet || len(ki.Params) != 2 || len(ki.Headers) != 0 {
+		t.Errorf("unexpected key inputs %s", w.Body.String())
+	}
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sampling retains the metadata of recently-seen frontend requests in a bounded ring
+// buffer, so that they can be replayed against candidate configs without contacting any origin
+package sampling
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+// ErrInvalidRequestLine is returned when a request line is not of the form "METHOD URL"
+var ErrInvalidRequestLine = errors.New(`invalid request line; must be of the form "METHOD URL"`)
+
+// credentialHeaders are the headers whose values are replaced by their checksums when sampled,
+// so that samples do not retain credentials, while requests that are keyed by them remain distinct
+var credentialHeaders = []string{
+	headers.NameAuthorization,
+	headers.NameProxyAuthorization,
+	headers.NameCookie,
+}
+
+// Sample is the metadata of a frontend request. Request bodies are not retained
+type Sample struct {
+	// Time is the time the request was received
+	Time time.Time `json:"time"`
+	// Method is the request's HTTP method
+	Method string `json:"method"`
+	// Host is the request's Host header
+	Host string `json:"host"`
+	// URI is the request's path and query string
+	URI string `json:"uri"`
+	// Header is the request's headers, with credentials replaced by their checksums
+	Header http.Header `json:"-"`
+}
+
+// NewSample returns the Sample of the request
+func NewSample(r *http.Request) *Sample {
+	h := r.Header.Clone()
+	for _, n := range credentialHeaders {
+		if vals, ok := h[n]; ok {
+			for i, v := range vals {
+				vals[i] = md5.Checksum(v)
+			}
+		}
+	}
+	return &Sample{Time: time.Now(), Method: r.Method, Host: r.Host,
+		URI: r.URL.RequestURI(), Header: h}
+}
+
+// ParseRequestLine returns the Sample of a request line of the form "METHOD URL", where the URL
+// is either absolute, in which case its host is the request's Host header, or a path and query
+func ParseRequestLine(line string) (*Sample, error) {
+	parts := strings.Fields(line)
+	if len(parts) != 2 {
+		return nil, ErrInvalidRequestLine
+	}
+	u, err := url.Parse(parts[1])
+	if err != nil || (u.Host == "" && !strings.HasPrefix(u.Path, "/")) {
+		return nil, ErrInvalidRequestLine
+	}
+	return &Sample{Method: strings.ToUpper(parts[0]), Host: u.Host, URI: u.RequestURI(),
+		Header: http.Header{}}, nil
+}
+
+// String returns the Sample as a request line
+func (s *Sample) String() string {
+	if s.Host == "" {
+		return s.Method + " " + s.URI
+	}
+	return s.Method + " http://" + s.Host + s.URI
+}
+
+// Request returns a new request for the Sample, which has no body
+func (s *Sample) Request() (*http.Request, error) {
+	host := s.Host
+	if host == "" {
+		host = "localhost"
+	}
+	r, err := http.NewRequest(s.Method, "http://"+host+s.URI, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Host = s.Host
+	if s.Header != nil {
+		r.Header = s.Header.Clone()
+	}
+	return r, nil
+}
+
+// Ring is a bounded buffer of the most recent Samples
+type Ring struct {
+	samples []*Sample
+	next    int
+	full    bool
+	mtx     sync.Mutex
+}
+
+// NewRing returns a new Ring that retains up to size Samples
+func NewRing(size int) *Ring {
+	if size < 1 {
+		size = 1
+	}
+	return &Ring{samples: make([]*Sample, size)}
+}
+
+// Add adds the Sample to the Ring, replacing the oldest Sample once the Ring is full
+func (r *Ring) Add(s *Sample) {
+	r.mtx.Lock()
+	r.samples[r.next] = s
+	r.next++
+	if r.next == len(r.samples) {
+		r.next = 0
+		r.full = true
+	}
+	r.mtx.Unlock()
+}
+
+// Samples returns the Samples in the Ring, from the oldest to the newest
+func (r *Ring) Samples() []*Sample {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if !r.full {
+		out := make([]*Sample, r.next)
+		copy(out, r.samples[:r.next])
+		return out
+	}
+	out := make([]*Sample, 0, len(r.samples))
+	out = append(out, r.samples[r.next:]...)
+	return append(out, r.samples[:r.next]...)
+}
+
+// Sampler samples the frontend requests of a router, when sampling is enabled by the router's config
+type Sampler struct {
+	ring *Ring
+	mtx  sync.RWMutex
+}
+
+// NewSampler returns a new Sampler, which is disabled until it is configured
+func NewSampler() *Sampler {
+	return &Sampler{}
+}
+
+// Configure enables or disables the Sampler, retaining up to size Samples. Samples that were
+// already retained are kept when the size is unchanged
+func (s *Sampler) Configure(enabled bool, size int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if !enabled {
+		s.ring = nil
+		return
+	}
+	if s.ring == nil || len(s.ring.samples) != size {
+		s.ring = NewRing(size)
+	}
+}
+
+func (s *Sampler) current() *Ring {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.ring
+}
+
+// Enabled returns true if the Sampler is sampling frontend requests
+func (s *Sampler) Enabled() bool {
+	return s.current() != nil
+}
+
+// Record samples the request, if sampling is enabled
+func (s *Sampler) Record(r *http.Request) {
+	if rg := s.current(); rg != nil {
+		rg.Add(NewSample(r))
+	}
+}
+
+// Samples returns the Sampler's Samples, from the oldest to the newest
+func (s *Sampler) Samples() []*Sample {
+	rg := s.current()
+	if rg == nil {
+		return nil
+	}
+	return rg.Samples()
+}
+
+// Middleware samples each request before passing it to next
+func (s *Sampler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Record(r)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -57,6 +57,23 @@ func ParseDurationParts(value int64, units string) (time.Duration, error) {
 	return time.Duration(value) * UnitMap[units], nil
 }
 
+// ParseTimeout returns a duration from a timeout value, which is either a number of
+// seconds (e.g., "30" or "1.5"), or a duration string (e.g., "30s" or "1m30s")
+func ParseTimeout(input string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(input, 64); err == nil {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	if d, err := time.ParseDuration(input); err == nil {
+		return d, nil
+	}
+	return ParseDuration(input)
+}
+
+// FormatTimeout returns the duration as a number of seconds, with millisecond precision
+func FormatTimeout(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
 // UnitMap provides a map of common time unit abbreviations to their respective time.Durations
 var UnitMap = map[string]time.Duration{
 	"ns": time.Nanosecond,
@@ -65,3 +65,32 @@ func TestParseDurationPartsFailed(t *testing.T) {
 		t.Errorf("expected 'unable to parse duration 1x' error")
 	}
 }
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		err      bool
+	}{
+		{"30", 30 * time.Second, false},
+		{"1.5", 1500 * time.Millisecond, false},
+		{"1m30s", 90 * time.Second, false},
+		{"1d", 24 * time.Hour, false},
+		{"x", 0, true},
+	}
+	for _, test := range tests {
+		d, err := ParseTimeout(test.input)
+		if (err != nil) != test.err {
+			t.Errorf("%s: expected error %t got %v", test.input, test.err, err)
+		}
+		if d != test.expected {
+			t.Errorf("%s: expected %d got %d", test.input, test.expected, d)
+		}
+	}
+}
+
+func TestFormatTimeout(t *testing.T) {
+	if s := FormatTimeout(1500 * time.Millisecond); s != "1.500" {
+		t.Errorf("expected %s got %s", "1.500", s)
+	}
+}
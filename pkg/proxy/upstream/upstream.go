@@ -0,0 +1,260 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package upstream maintains the runtime state of the upstream requests made to each of
+// Trickster's origins: the number in flight, the details of the most recent connection, and
+// the most recent failure, so that an origin's live state can be inspected during incidents
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxMessageLength is the maximum length of the message of a recorded Failure
+const maxMessageLength = 256
+
+// The classes of upstream failures
+const (
+	ClassTimeout  = "timeout"
+	ClassCanceled = "canceled"
+	ClassDNS      = "dns"
+	ClassConnect  = "connect"
+	ClassTLS      = "tls"
+	ClassStatus   = "http_status"
+	ClassOther    = "other"
+)
+
+// Connection describes the connection over which the most recent upstream response was received
+type Connection struct {
+	Time     time.Time `json:"time"`
+	Protocol string    `json:"protocol"`
+	// the remaining fields are only set for TLS connections
+	TLSVersion         string     `json:"tlsVersion,omitempty"`
+	CipherSuite        string     `json:"cipherSuite,omitempty"`
+	ServerName         string     `json:"serverName,omitempty"`
+	NegotiatedProtocol string     `json:"negotiatedProtocol,omitempty"`
+	Resumed            bool       `json:"resumed,omitempty"`
+	PeerCertificate    string     `json:"peerCertificate,omitempty"`
+	PeerCertificateExp *time.Time `json:"peerCertificateExpiry,omitempty"`
+}
+
+// Failure describes an upstream request that could not be completed, or whose response
+// indicated a server error
+type Failure struct {
+	Time    time.Time `json:"time"`
+	Class   string    `json:"class"`
+	Message string    `json:"message"`
+}
+
+// Tracker maintains the runtime state of the upstream requests of a single origin
+type Tracker struct {
+	originName     string
+	originType     string
+	inFlight       int64
+	lastConnection *Connection
+	lastFailure    *Failure
+	mtx            sync.Mutex
+}
+
+// Registry holds the Trackers of a router's origins
+type Registry struct {
+	trackers sync.Map
+}
+
+// NewRegistry returns a new Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Get returns the Tracker for the named origin, creating it if necessary
+func (r *Registry) Get(originName, originType string) *Tracker {
+	if t, ok := r.trackers.Load(originName); ok {
+		return t.(*Tracker)
+	}
+	t, _ := r.trackers.LoadOrStore(originName, &Tracker{originName: originName, originType: originType})
+	return t.(*Tracker)
+}
+
+// Lookup returns the Tracker for the named origin, and false if no upstream request has been
+// made to it
+func (r *Registry) Lookup(originName string) (*Tracker, bool) {
+	if t, ok := r.trackers.Load(originName); ok {
+		return t.(*Tracker), true
+	}
+	return nil, false
+}
+
+// Begin records the start of an upstream request, and returns the function that records its end
+func (t *Tracker) Begin() func() {
+	atomic.AddInt64(&t.inFlight, 1)
+	var once sync.Once
+	return func() { once.Do(func() { atomic.AddInt64(&t.inFlight, -1) }) }
+}
+
+// InFlight returns the number of upstream requests currently in flight
+func (t *Tracker) InFlight() int64 {
+	return atomic.LoadInt64(&t.inFlight)
+}
+
+// ObserveResponse records the connection over which the upstream response was received, and
+// records a response having a server error status as a Failure
+func (t *Tracker) ObserveResponse(resp *http.Response, now time.Time) {
+	if resp == nil {
+		return
+	}
+	c := &Connection{Time: now, Protocol: resp.Proto}
+	if cs := resp.TLS; cs != nil {
+		c.TLSVersion = tlsVersion(cs.Version)
+		c.CipherSuite = tls.CipherSuiteName(cs.CipherSuite)
+		c.ServerName = cs.ServerName
+		c.NegotiatedProtocol = cs.NegotiatedProtocol
+		c.Resumed = cs.DidResume
+		if len(cs.PeerCertificates) > 0 {
+			cert := cs.PeerCertificates[0]
+			c.PeerCertificate = cert.Subject.String()
+			exp := cert.NotAfter
+			c.PeerCertificateExp = &exp
+		}
+	}
+	t.mtx.Lock()
+	t.lastConnection = c
+	if resp.StatusCode >= http.StatusInternalServerError {
+		t.lastFailure = &Failure{Time: now, Class: ClassStatus,
+			Message: "unexpected response status: " + strconv.Itoa(resp.StatusCode)}
+	}
+	t.mtx.Unlock()
+}
+
+// ObserveError records the error of an upstream request that could not be completed. Requests
+// canceled by their clients are not failures of the origin, and are not recorded
+func (t *Tracker) ObserveError(err error, now time.Time) {
+	if err == nil {
+		return
+	}
+	class := Classify(err)
+	if class == ClassCanceled {
+		return
+	}
+	f := &Failure{Time: now, Class: class, Message: Sanitize(err)}
+	t.mtx.Lock()
+	t.lastFailure = f
+	t.mtx.Unlock()
+}
+
+// LastConnection returns a copy of the most recent Connection, or nil if none was observed
+func (t *Tracker) LastConnection() *Connection {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.lastConnection == nil {
+		return nil
+	}
+	c := *t.lastConnection
+	return &c
+}
+
+// LastFailure returns a copy of the most recent Failure, or nil if none was observed
+func (t *Tracker) LastFailure() *Failure {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.lastFailure == nil {
+		return nil
+	}
+	f := *t.lastFailure
+	return &f
+}
+
+// Classify returns the class of the upstream request error
+func Classify(err error) string {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var ne net.Error
+	var hostnameErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+	var certErr x509.CertificateInvalidError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ClassTimeout
+	case errors.As(err, &dnsErr):
+		return ClassDNS
+	case errors.As(err, &hostnameErr), errors.As(err, &authorityErr),
+		errors.As(err, &certErr), errors.As(err, &recordErr):
+		return ClassTLS
+	case errors.As(err, &ne) && ne.Timeout():
+		return ClassTimeout
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return ClassConnect
+	}
+	return ClassOther
+}
+
+// Sanitize returns the message of the upstream request error, without the query string and
+// credentials of its URL, which may hold secrets, truncated to a bounded length
+func Sanitize(err error) string {
+	var msg string
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		msg = ue.Op + " " + RedactURL(ue.URL) + ": " + ue.Err.Error()
+	} else {
+		msg = err.Error()
+	}
+	if len(msg) > maxMessageLength {
+		msg = msg[:maxMessageLength] + "..."
+	}
+	return msg
+}
+
+// RedactURL returns the URL without its query string and fragment, and with its password masked
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		}
+	}
+	return u.String()
+}
+
+func tlsVersion(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return "unknown (0x" + strconv.FormatUint(uint64(v), 16) + ")"
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/key"
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"github.com/gorilla/mux"
+)
+
+// Route describes how a request is routed and cached under a config
+type Route struct {
+	// Origin is the name of the origin selected for the request, or empty if no route matched it
+	Origin string `json:"origin"`
+	// Path is the path config that matched the request
+	Path string `json:"path"`
+	// CacheKey is the key under which the response would be cached, or empty if it would not be
+	CacheKey string `json:"cache_key"`
+}
+
+// Resolution describes a request's Route, and the exact inputs from which its cache key is derived
+type Resolution struct {
+	Route
+	// KeyInputs are the inputs of the CacheKey, or nil if the response would not be cached
+	KeyInputs *key.Inputs `json:"key_inputs,omitempty"`
+}
+
+type routeContextKey struct{}
+
+// Inspector routes requests through the routes of a config, including its rules, rewriters and
+// cache key settings, to describe how they would be served, without serving them. Inspected
+// requests do not touch any cache or origin
+type Inspector struct {
+	router *mux.Router
+}
+
+// NewInspector returns an Inspector of the config's routes. The config is modified as it is by
+// RegisterProxyRoutes, and must not be in use
+func NewInspector(conf *config.Config) (*Inspector, error) {
+	router := mux.NewRouter()
+	_, _, err := registerProxyRoutes(conf, nil, router, nil, nil, tl.ConsoleLogger("error"),
+		true, http.HandlerFunc(inspectRoute))
+	if err != nil {
+		return nil, err
+	}
+	return &Inspector{router: router}, nil
+}
+
+// Route returns the Route of the request
+func (in *Inspector) Route(r *http.Request) Route {
+	return in.Resolve(r).Route
+}
+
+// Resolve returns the Resolution of the request
+func (in *Inspector) Resolve(r *http.Request) *Resolution {
+	rs := &Resolution{}
+	r = r.WithContext(context.WithValue(r.Context(), routeContextKey{}, rs))
+	in.router.ServeHTTP(discardWriter{}, r)
+	return rs
+}
+
+// inspectRoute is the core handler of inspected paths, which records the request's Resolution
+func inspectRoute(w http.ResponseWriter, r *http.Request) {
+	rs, ok := r.Context().Value(routeContextKey{}).(*Resolution)
+	rsc := request.GetResources(r)
+	if !ok || rsc == nil || rsc.OriginConfig == nil || rsc.PathConfig == nil {
+		return
+	}
+	oc := rsc.OriginConfig
+	rs.Origin = oc.Name
+	rs.Path = rsc.PathConfig.Path
+	r.URL = urls.BuildUpstreamURL(r, urls.FromParts(oc.Scheme, oc.Host, oc.PathPrefix, "", ""))
+	rs.CacheKey, rs.KeyInputs = engines.InspectKeyInputs(r)
+}
+
+// discardWriter is a ResponseWriter that discards the responses of inspected requests
+type discardWriter struct{}
+
+func (discardWriter) Header() http.Header         { return http.Header{} }
+func (discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (discardWriter) WriteHeader(int)             {}
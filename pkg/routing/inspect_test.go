@@ -0,0 +1,176 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+)
+
+// each origin's keyed path injects upstream credentials, and includes hop-by-hop and injected
+// headers in its cache key headers. web does not inject an Authorization header, since the
+// reverse proxy cache does not cache authenticated requests by default
+const testInspectConfig = `
+[origins]
+  [origins.prom]
+  origin_type = 'prometheus'
+  origin_url = 'http://127.0.0.1:9090'
+  is_default = true
+    [origins.prom.paths.query_range]
+    path = '/api/v1/query_range'
+    methods = ['GET', 'POST']
+    cache_key_headers = ['X-Test-Header', 'X-Api-Key', 'Connection', 'Proxy-Authorization']
+      [origins.prom.paths.query_range.request_headers]
+      'Authorization' = 'Bearer injected-secret'
+      '+X-Api-Key' = 'injected-secret'
+      [origins.prom.paths.query_range.request_params]
+      'api_key' = 'injected-secret'
+
+  [origins.influx]
+  origin_type = 'influxdb'
+  origin_url = 'http://127.0.0.1:8086'
+    [origins.influx.paths.query]
+    path = '/query'
+    methods = ['GET', 'POST']
+    cache_key_params = ['*']
+    cache_key_headers = ['X-Test-Header', 'X-Api-Key', 'Keep-Alive', 'Upgrade']
+      [origins.influx.paths.query.request_headers]
+      'Authorization' = 'Bearer injected-secret'
+      'X-Api-Key' = 'injected-secret'
+      [origins.influx.paths.query.request_params]
+      'u' = 'injected-secret'
+
+  [origins.ch]
+  origin_type = 'clickhouse'
+  origin_url = 'http://127.0.0.1:8123'
+    [origins.ch.paths.root]
+    path = '/'
+    methods = ['GET', 'POST']
+    cache_key_params = ['*']
+    cache_key_headers = ['X-Test-Header', 'X-ClickHouse-Key', 'TE']
+      [origins.ch.paths.root.request_headers]
+      'X-ClickHouse-Key' = 'injected-secret'
+      [origins.ch.paths.root.request_params]
+      'password' = 'injected-secret'
+
+  [origins.iron]
+  origin_type = 'irondb'
+  origin_url = 'http://127.0.0.1:8112'
+    [origins.iron.paths.read]
+    path = '/read/'
+    methods = ['GET']
+    handler = 'read'
+    cache_key_headers = ['X-Test-Header', 'X-Api-Key', 'Connection']
+      [origins.iron.paths.read.request_headers]
+      'Authorization' = 'Bearer injected-secret'
+
+  [origins.web]
+  origin_type = 'reverseproxycache'
+  origin_url = 'http://127.0.0.1:8080'
+    [origins.web.paths.root]
+    path = '/'
+    match_type = 'prefix'
+    handler = 'proxycache'
+    cache_key_params = ['*']
+    cache_key_headers = ['X-Test-Header', 'X-Api-Key', 'Transfer-Encoding']
+      [origins.web.paths.root.request_headers]
+      'X-Api-Key' = 'injected-secret'
+      [origins.web.paths.root.request_params]
+      '+token' = 'injected-secret'
+`
+
+func TestInspectorResolve(t *testing.T) {
+
+	conf, err := config.LoadDocument(testInspectConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := NewInspector(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url    string
+		origin string
+		path   string
+	}{
+		{"/prom/api/v1/query_range?query=up&start=1600000000&end=1600003600&step=15",
+			"prom", "/api/v1/query_range"},
+		{"/influx/query?db=test&q=select+1", "influx", "/query"},
+		{"/ch/?query=select+1", "ch", "/"},
+		{"/iron/read/0/900/00112233-4455-6677-8899-aabbccddeeff/metric", "iron", "/read/"},
+		{"/web/index.html?v=1", "web", "/"},
+	}
+
+	hopByHop := []string{"Connection", "Keep-Alive", "Upgrade", "Te", "Transfer-Encoding",
+		"Proxy-Authorization"}
+
+	for _, test := range tests {
+		t.Run(test.origin, func(t *testing.T) {
+			var pc *po.Options
+			for _, p := range conf.Origins[test.origin].Paths {
+				if p.Path == test.path && p.HandlerName != "proxy" {
+					pc = p
+				}
+			}
+			// requests arrive as they would be proxied, with the path's credentials injected
+			newRequest := func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "http://trickster"+test.url, nil)
+				r.Header.Set("X-Test-Header", "test")
+				for _, h := range hopByHop {
+					r.Header.Set(h, "hop-by-hop")
+				}
+				headers.UpdateHeaders(r.Header, pc.RequestHeaders)
+				qp := r.URL.Query()
+				params.UpdateParams(qp, pc.RequestParams)
+				r.URL.RawQuery = qp.Encode()
+				return r
+			}
+			rs := in.Resolve(newRequest())
+			if rs.Origin != test.origin || rs.Path != test.path {
+				t.Fatalf("unexpected route %+v", rs.Route)
+			}
+			if rs.CacheKey == "" || rs.KeyInputs == nil {
+				t.Fatal("expected a cache key and its inputs")
+			}
+			if rs.KeyInputs.Origin != test.origin || rs.KeyInputs.Path != test.path {
+				t.Errorf("unexpected key inputs %s", rs.KeyInputs.String())
+			}
+			if rt := in.Route(newRequest()); rt != rs.Route {
+				t.Errorf("expected %+v got %+v", rs.Route, rt)
+			}
+			s := rs.KeyInputs.String()
+			if strings.Contains(s, "injected-secret") || strings.Contains(s, "hop-by-hop") ||
+				rs.KeyInputs.Authorization != "" {
+				t.Errorf("unexpected upstream credentials or hop-by-hop headers in %s", s)
+			}
+			for _, h := range rs.KeyInputs.Headers {
+				if h.Name != "X-Test-Header" {
+					t.Errorf("unexpected header %s", h.Name)
+				}
+			}
+		})
+	}
+}
@@ -26,22 +26,31 @@ import (
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/estimate"
+	"github.com/tricksterproxy/trickster/pkg/proxy/handlers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/healthcheck"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
-	"github.com/tricksterproxy/trickster/pkg/proxy/origins/clickhouse"
-	"github.com/tricksterproxy/trickster/pkg/proxy/origins/influxdb"
-	"github.com/tricksterproxy/trickster/pkg/proxy/origins/irondb"
+	// the built-in origin providers register themselves with the origins package
+	_ "github.com/tricksterproxy/trickster/pkg/proxy/origins/clickhouse"
+	_ "github.com/tricksterproxy/trickster/pkg/proxy/origins/influxdb"
+	_ "github.com/tricksterproxy/trickster/pkg/proxy/origins/irondb"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
-	"github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
+	_ "github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/reverseproxycache"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/rule"
-	"github.com/tricksterproxy/trickster/pkg/proxy/origins/types"
 	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/refresh"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
 	"github.com/tricksterproxy/trickster/pkg/tracing"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
 	"github.com/tricksterproxy/trickster/pkg/util/middleware"
+	mwopts "github.com/tricksterproxy/trickster/pkg/util/middleware/options"
 
 	"github.com/gorilla/mux"
 )
@@ -56,11 +65,30 @@ func RegisterPprofRoutes(routerName string, h *http.ServeMux, log *tl.Logger) {
 	h.HandleFunc("/debug/pprof/trace", pprof.Trace)
 }
 
-// RegisterProxyRoutes iterates the Trickster Configuration and
-// registers the routes for the configured origins
-func RegisterProxyRoutes(conf *config.Config, router *mux.Router,
+// RegisterProxyRoutes iterates the Trickster Configuration and registers the routes for the
+// configured origins. It returns the origins' Services, which are not started, so that they are
+// only started by the caller once the config is ready to replace any running config. No Services
+// are returned for a dry run. The origins' requests are served with the metrics and state of the
+// Instance, or of the Default Instance when it is nil
+func RegisterProxyRoutes(conf *config.Config, inst *instance.Instance, router *mux.Router,
 	caches map[string]cache.Cache, tracers tracing.Tracers,
-	log *tl.Logger, dryRun bool) (origins.Origins, error) {
+	log *tl.Logger, dryRun bool) (origins.Origins, *Services, error) {
+	return registerProxyRoutes(conf, inst, router, caches, tracers, log, dryRun, nil)
+}
+
+// registerProxyRoutes registers the routes for the configured origins. When inspect is not nil,
+// the routes are registered for inspection rather than for serving requests
+func registerProxyRoutes(conf *config.Config, inst *instance.Instance, router *mux.Router,
+	caches map[string]cache.Cache, tracers tracing.Tracers,
+	log *tl.Logger, dryRun bool, inspect http.Handler) (origins.Origins, *Services, error) {
+
+	inst = instance.OrDefault(inst)
+	var svc *Services
+	if !dryRun {
+		svc = &Services{health: inst.Health}
+	}
+
+	setErrorHandlers(router)
 
 	// a fake "top-level" origin representing the main frontend, so rules can route
 	// to it via the clients map
@@ -77,16 +105,14 @@ func RegisterProxyRoutes(conf *config.Config, router *mux.Router,
 	// This iteration will ensure default origins are handled properly
 	for k, o := range conf.Origins {
 
-		if !types.IsValidOriginType(o.OriginType) {
-			return nil,
-				fmt.Errorf(`unknown origin type in origin config. originName: %s, originType: %s`,
-					k, o.OriginType)
+		if _, ok := origins.LookupProvider(o.OriginType); !ok {
+			return nil, nil, origins.UnknownProviderError(k, o.OriginType)
 		}
 
 		// Ensure only one default origin exists
 		if o.IsDefault {
 			if cdo != nil {
-				return nil,
+				return nil, nil,
 					fmt.Errorf("only one origin can be marked as default. Found both %s and %s",
 						defaultOrigin, k)
 			}
@@ -103,9 +129,9 @@ func RegisterProxyRoutes(conf *config.Config, router *mux.Router,
 			continue
 		}
 
-		_, err = registerOriginRoutes(router, conf, k, o, clients, caches, tracers, log, dryRun)
+		_, err = registerOriginRoutes(router, conf, inst, k, o, clients, caches, tracers, log, dryRun, inspect, svc)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
@@ -115,26 +141,35 @@ func RegisterProxyRoutes(conf *config.Config, router *mux.Router,
 			cdo = ndo
 			defaultOrigin = "default"
 		} else {
-			_, err = registerOriginRoutes(router, conf, "default", ndo, clients, caches, tracers, log, dryRun)
+			_, err = registerOriginRoutes(router, conf, inst, "default", ndo, clients, caches, tracers,
+				log, dryRun, inspect, svc)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		}
 	}
 
 	if cdo != nil {
-		clients, err = registerOriginRoutes(router, conf, defaultOrigin, cdo, clients, caches, tracers, log, dryRun)
+		clients, err = registerOriginRoutes(router, conf, inst, defaultOrigin, cdo, clients, caches,
+			tracers, log, dryRun, inspect, svc)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	err = validateRuleClients(clients, conf.CompiledRewriters)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return clients, nil
+	// refresh jobs are served by the origins' routers, so they are scheduled once all routes are
+	// registered, and are paused by the health of the config's own origins
+	if svc != nil {
+		svc.scheduler = refresh.NewScheduler(conf.RefreshJobs, conf.Origins, inst.Metrics, log)
+		svc.scheduler.SetHealth(svc.healthy)
+	}
+
+	return clients, svc, nil
 }
 
 // This ensures that rule clients are fully loaded, which can't be done
@@ -157,60 +192,98 @@ func validateRuleClients(clients origins.Origins,
 	return nil
 }
 
-func registerOriginRoutes(router *mux.Router, conf *config.Config, k string,
+func registerOriginRoutes(router *mux.Router, conf *config.Config, inst *instance.Instance, k string,
 	o *oo.Options, clients origins.Origins, caches map[string]cache.Cache,
-	tracers tracing.Tracers, log *tl.Logger, dryRun bool) (origins.Origins, error) {
+	tracers tracing.Tracers, log *tl.Logger, dryRun bool,
+	inspect http.Handler, svc *Services) (origins.Origins, error) {
 
 	var client origins.Client
 	var c cache.Cache
 	var ok bool
 	var err error
 
-	c, ok = caches[o.CacheName]
-	if !ok {
-		return nil, fmt.Errorf("could not find cache named [%s]", o.CacheName)
+	// inspected routes never reach the cache, so none is provided to their clients
+	if inspect == nil {
+		c, ok = caches[o.CacheName]
+		if !ok {
+			return nil, fmt.Errorf("could not find cache named [%s]", o.CacheName)
+		}
 	}
 
 	if !dryRun {
 		log.Info("registering route paths", tl.Pairs{"originName": k,
 			"originType": o.OriginType, "upstreamHost": o.Host})
+		if o.ReplayDir != "" {
+			log.Warn("origin is serving upstream requests from recorded fixtures, and will not contact the origin",
+				tl.Pairs{"originName": k, "replayDir": o.ReplayDir})
+		} else if o.RecordDir != "" {
+			log.Warn("origin is recording upstream requests and responses to fixture files",
+				tl.Pairs{"originName": k, "recordDir": o.RecordDir})
+		}
 	}
 
-	switch strings.ToLower(o.OriginType) {
-	case "prometheus", "":
-		client, err = prometheus.NewClient(k, o, mux.NewRouter(), c)
-	case "influxdb":
-		client, err = influxdb.NewClient(k, o, mux.NewRouter(), c)
-	case "irondb":
-		client, err = irondb.NewClient(k, o, mux.NewRouter(), c)
-	case "clickhouse":
-		client, err = clickhouse.NewClient(k, o, mux.NewRouter(), c)
-	case "rpc", "reverseproxycache":
-		client, err = reverseproxycache.NewClient(k, o, mux.NewRouter(), c)
-	case "rule":
-		client, err = rule.NewClient(k, o, mux.NewRouter(), clients)
-	}
+	cr := mux.NewRouter()
+	setErrorHandlers(cr)
+	client, err = origins.NewClient(k, o, cr, c, clients, log)
 	if err != nil {
 		return nil, err
 	}
 
-	if client != nil && !dryRun {
+	// resolve the upstream health check against the provider defaults,
+	// so that invalid health check options are caught during dry runs
+	var hct *healthcheck.Target
+	if hc, ok := client.(healthcheck.Checker); ok && inspect == nil {
+		hct, err = healthcheck.NewOriginTarget(o, hc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid health check config for origin [%s]: %s", k, err.Error())
+		}
+	}
+
+	if client != nil && (!dryRun || inspect != nil) {
 		o.HTTPClient = client.HTTPClient()
 		clients[k] = client
 		defaultPaths := client.DefaultPathConfigs(o)
-		registerPathRoutes(router, client.Handlers(), client, o, c, defaultPaths,
-			tracers, conf.Main.HealthHandlerPath, log)
+		registerPathRoutes(router, inst, client.Handlers(), client, o, c, defaultPaths,
+			tracers, conf.Frontend, conf.Main.HealthHandlerPath, hct, log, inspect, clients)
+		if inspect != nil {
+			return clients, nil
+		}
+		if hct != nil && svc != nil {
+			svc.probers = append(svc.probers, prober{target: hct, client: o.HTTPClient, logger: log})
+		}
+		log.Info("origin ready", tl.Pairs{"originName": k, "originType": o.OriginType,
+			"cacheName": o.CacheName, "pathCount": len(o.Paths)})
+		if o.BootstrapPeer != nil {
+			log.Info("bootstrap peer fill enabled", tl.Pairs{"originName": k,
+				"peerURL": o.BootstrapPeer.String(), "bootstrapWindow": o.BootstrapWindow.String()})
+		}
 	}
 	return clients, nil
 }
 
+// originRouter returns a handler that dispatches requests to the Request Router of the named origin.
+// The router is looked up for each request, since the origin may be registered after the handler
+// is created
+func originRouter(clients origins.Origins, originName string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := clients.GetRouter(originName)
+		if h == nil {
+			txe.NewResponseError(http.StatusBadGateway, txe.CodeOriginUnreachable,
+				"no origin named "+originName).Respond(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
 // registerPathRoutes will take the provided default paths map,
 // merge it with any path data in the provided originconfig, and then register
 // the path routes to the appropriate handler from the provided handlers map
-func registerPathRoutes(router *mux.Router, handlers map[string]http.Handler,
+func registerPathRoutes(router *mux.Router, inst *instance.Instance, handlers map[string]http.Handler,
 	client origins.Client, oo *oo.Options, c cache.Cache,
-	defaultPaths map[string]*po.Options, tracers tracing.Tracers,
-	healthHandlerPath string, log *tl.Logger) {
+	defaultPaths map[string]*po.Options, tracers tracing.Tracers, fc *config.FrontendConfig,
+	healthHandlerPath string, hct *healthcheck.Target, log *tl.Logger, inspect http.Handler,
+	clients origins.Origins) {
 
 	if oo == nil {
 		return
@@ -225,38 +298,57 @@ func registerPathRoutes(router *mux.Router, handlers map[string]http.Handler,
 	}
 
 	decorate := func(po *po.Options) http.Handler {
-		// default base route is the path handler
+		// the core of the chain is the path handler, which is preceded by the handlers
+		// that prepare the request for it, regardless of the path's middleware stack
 		h := po.Handler
+		// inspected paths end with the inspection handler instead, except for those of rules,
+		// which dispatch requests to the inspected paths of their destination origins
+		if inspect != nil && oo.OriginType != "rule" {
+			h = inspect
+		}
+		// bound the upstream deadline by the client's timeout hint
+		h = middleware.TimeoutBudget(client, oo, h)
+		// ensure important query parameters are not duplicated
+		h = middleware.SanitizeParams(client, oo, h)
 		// attach distributed tracer
 		if tr != nil {
 			h = middleware.Trace(tr, h)
 		}
 		// add Origin, Cache, and Path Configs to the HTTP Request's context
-		h = middleware.WithResourcesContext(client, oo, c, po, tr, log, h)
-		// attach any request rewriters
+		h = middleware.WithResourcesContext(inst, client, oo, c, po, tr, log, h)
+		// the origin's request rewriter always runs immediately before the core handlers
 		if len(oo.ReqRewriter) > 0 {
 			h = rewriter.Rewrite(oo.ReqRewriter, h)
 		}
-		if len(po.ReqRewriter) > 0 {
-			h = rewriter.Rewrite(po.ReqRewriter, h)
+		// wrap the core with the path's middleware stack, from the innermost outward
+		stack := middlewareStack(po, fc)
+		for i := len(stack) - 1; i >= 0; i-- {
+			// only rewriters change how inspected requests are routed and keyed
+			if inspect != nil && stack[i].Type != mwopts.TypeRewriter {
+				continue
+			}
+			h = applyMiddleware(stack[i], inst.Metrics, oo, po, fc, log, h)
 		}
-		// decorate frontend prometheus metrics
-		if !po.NoMetrics {
-			h = middleware.Decorate(oo.Name, oo.OriginType, po.Path, h)
+		// a panic in any handler of the path is logged and answered with a 500, rather than
+		// bypassing the logger with the default dump to stderr
+		h = middleware.Recover(inst.Metrics, oo.Name, po.Path, log, h)
+		// the access log records the client's request and the response it received, so it is
+		// the outermost handler. inspected requests are not logged
+		if inspect == nil {
+			h = middleware.AccessLog(oo.Name, h)
 		}
 		return h
 	}
 
 	// now we'll go ahead and register the health handler
-	if h, ok := handlers["health"]; ok &&
-		oo.HealthCheckUpstreamPath != "" && oo.HealthCheckVerb != "" && healthHandlerPath != "" {
+	if h, ok := handlers["health"]; ok && hct != nil && healthHandlerPath != "" {
 		hp := strings.Replace(healthHandlerPath+"/"+oo.Name, "//", "/", -1)
 		log.Debug("registering health handler path",
 			tl.Pairs{"path": hp, "originName": oo.Name,
-				"upstreamPath": oo.HealthCheckUpstreamPath,
-				"upstreamVerb": oo.HealthCheckVerb})
+				"upstreamPath": hct.URL().Path,
+				"upstreamVerb": hct.Options().Verb})
 		router.PathPrefix(hp).
-			Handler(middleware.WithResourcesContext(client, oo, nil, nil, tr, log, h)).
+			Handler(middleware.WithResourcesContext(inst, client, oo, nil, nil, tr, log, h)).
 			Methods(methods.CacheableHTTPMethods()...)
 	}
 
@@ -291,6 +383,15 @@ func registerPathRoutes(router *mux.Router, handlers map[string]http.Handler,
 		if h, ok := handlers[p.HandlerName]; ok && h != nil {
 			p.Handler = h
 			plist = append(plist, k)
+			if p.MaxEstimatedPoints > 0 && p.MaxEstimatedPointsAction == estimate.ActionRoute {
+				p.MaxEstimatedPointsRouter = originRouter(clients, p.MaxEstimatedPointsOrigin)
+			}
+			if p.AuthCachePolicy == authcache.PolicyDefault {
+				p.AuthCachePolicy = origins.DefaultAuthCachePolicy(client)
+				p.AuthCachePolicyName = p.AuthCachePolicy.String()
+				log.Info("using default cache policy for authenticated requests",
+					tl.Pairs{"originName": oo.Name, "path": k, "policy": p.AuthCachePolicyName})
+			}
 		} else {
 			log.Info("invalid handler name for path",
 				tl.Pairs{"path": p.Path, "handlerName": p.HandlerName})
@@ -318,7 +419,8 @@ func registerPathRoutes(router *mux.Router, handlers map[string]http.Handler,
 		log.Debug("registering origin handler path",
 			tl.Pairs{"originName": oo.Name, "path": v, "handlerName": p.HandlerName,
 				"originHost": oo.Host, "handledPath": handledPath, "matchType": p.MatchType,
-				"frontendHosts": strings.Join(oo.Hosts, ",")})
+				"frontendHosts": strings.Join(oo.Hosts, ","),
+				"middleware":    middlewareNames(middlewareStack(p, fc))})
 		if p.Handler != nil && len(p.Methods) > 0 {
 
 			if p.Methods[0] == "*" {
@@ -390,3 +492,79 @@ func (a ByLen) Less(i, j int) bool {
 func (a ByLen) Swap(i, j int) {
 	a[i], a[j] = a[j], a[i]
 }
+
+// setErrorHandlers configures the router to respond to requests that match no route with
+// Trickster's JSON error documents, unless the router already has handlers for them
+func setErrorHandlers(router *mux.Router) {
+	if router.NotFoundHandler == nil {
+		router.NotFoundHandler = http.HandlerFunc(handlers.HandleNotFoundResponse)
+	}
+	if router.MethodNotAllowedHandler == nil {
+		router.MethodNotAllowedHandler = http.HandlerFunc(handlers.HandleMethodNotAllowedResponse)
+	}
+}
+
+// middlewareStack returns the ordered list of Middleware instances that precede the core handlers
+// of the path, with the first being the outermost. The path's metrics, size limit and rewriter
+// settings are translated into implicit instances, unless the path's middleware list includes an
+// instance of the same type. The implicit metrics and limits instances precede the listed
+// instances, so that all requests are measured and oversized requests are rejected before any
+// other middleware reads them, and the implicit rewriter follows them
+func middlewareStack(p *po.Options, fc *config.FrontendConfig) []*mwopts.Options {
+	listed := make(map[string]bool, len(p.MiddlewareStack))
+	for _, m := range p.MiddlewareStack {
+		listed[m.Type] = true
+	}
+	stack := make([]*mwopts.Options, 0, len(p.MiddlewareStack)+3)
+	if !p.NoMetrics && !listed[mwopts.TypeMetrics] {
+		stack = append(stack, &mwopts.Options{Name: "implicit-metrics",
+			Type: mwopts.TypeMetrics, Implicit: true})
+	}
+	if fc != nil && !listed[mwopts.TypeLimits] {
+		stack = append(stack, &mwopts.Options{Name: "implicit-limits", Type: mwopts.TypeLimits,
+			MaxRequestURLBytes: p.MaxRequestURLBytes, MaxRequestBodyBytes: p.MaxRequestBodyBytes,
+			Implicit: true})
+	}
+	stack = append(stack, p.MiddlewareStack...)
+	if len(p.ReqRewriter) > 0 && !listed[mwopts.TypeRewriter] {
+		stack = append(stack, &mwopts.Options{Name: "implicit-rewriter", Type: mwopts.TypeRewriter,
+			ReqRewriterName: p.ReqRewriterName, ReqRewriter: p.ReqRewriter, Implicit: true})
+	}
+	return stack
+}
+
+// applyMiddleware wraps next with the handler for the Middleware instance, which reports to the
+// Metrics
+func applyMiddleware(m *mwopts.Options, mt *metrics.Metrics, o *oo.Options, p *po.Options,
+	fc *config.FrontendConfig, log *tl.Logger, next http.Handler) http.Handler {
+	switch m.Type {
+	case mwopts.TypeMetrics:
+		return middleware.Decorate(mt, o.Name, o.OriginType, p.Path, next)
+	case mwopts.TypeLimits:
+		// limits that are not set by the instance fall back to those of the frontend
+		maxURL, maxBody := m.MaxRequestURLBytes, m.MaxRequestBodyBytes
+		if fc != nil {
+			if maxURL == 0 {
+				maxURL = fc.MaxRequestURLBytes
+			}
+			if maxBody == 0 {
+				maxBody = fc.MaxRequestBodyBytes
+			}
+		}
+		return middleware.LimitRequestSize(mt, o, p, maxURL, maxBody, log, next)
+	case mwopts.TypeRewriter:
+		if len(m.ReqRewriter) > 0 {
+			return rewriter.Rewrite(m.ReqRewriter, next)
+		}
+	}
+	return next
+}
+
+// middlewareNames returns the names of the Middleware instances in the stack
+func middlewareNames(stack []*mwopts.Options) string {
+	names := make([]string, len(stack))
+	for i, m := range stack {
+		names[i] = m.Name
+	}
+	return strings.Join(names, ",")
+}
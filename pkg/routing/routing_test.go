@@ -19,20 +19,33 @@ package routing
 import (
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache/registration"
 	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authcache"
+	"github.com/tricksterproxy/trickster/pkg/proxy/background"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/reverseproxycache"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/rule"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/testdata/exampletsdb"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
 	"github.com/tricksterproxy/trickster/pkg/tracing"
 	"github.com/tricksterproxy/trickster/pkg/tracing/exporters/zipkin"
 	to "github.com/tricksterproxy/trickster/pkg/tracing/options"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/middleware"
+	mwopts "github.com/tricksterproxy/trickster/pkg/util/middleware/options"
 	tlstest "github.com/tricksterproxy/trickster/pkg/util/testing/tls"
 
 	"github.com/gorilla/mux"
@@ -61,7 +74,7 @@ func TestRegisterProxyRoutes(t *testing.T) {
 	}
 	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
 	defer registration.CloseCaches(caches)
-	proxyClients, err = RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, log, false)
+	proxyClients, _, err = RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil, log, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -76,7 +89,7 @@ func TestRegisterProxyRoutes(t *testing.T) {
 	oc.Hosts = []string{"test", "test2"}
 
 	registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
-	RegisterProxyRoutes(conf, mux.NewRouter(), caches, tr, log, false)
+	RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, tr, log, false)
 
 	if len(proxyClients) == 0 {
 		t.Errorf("expected %d got %d", 1, 0)
@@ -97,33 +110,33 @@ func TestRegisterProxyRoutes(t *testing.T) {
 	conf.Origins["2"] = o2
 
 	router := mux.NewRouter()
-	_, err = RegisterProxyRoutes(conf, router, caches, tr, log, false)
+	_, _, err = RegisterProxyRoutes(conf, nil, router, caches, tr, log, false)
 	if err == nil {
 		t.Error("Expected error for too many default origins.")
 	}
 
 	o1.IsDefault = false
 	o1.CacheName = "invalid"
-	_, err = RegisterProxyRoutes(conf, router, caches, tr, log, false)
+	_, _, err = RegisterProxyRoutes(conf, nil, router, caches, tr, log, false)
 	if err == nil {
 		t.Errorf("Expected error for invalid cache name")
 	}
 
 	o1.CacheName = o2.CacheName
-	_, err = RegisterProxyRoutes(conf, router, caches, tr, log, false)
+	_, _, err = RegisterProxyRoutes(conf, nil, router, caches, tr, log, false)
 	if err != nil {
 		t.Error(err)
 	}
 
 	o2.IsDefault = false
 	o2.CacheName = "invalid"
-	_, err = RegisterProxyRoutes(conf, router, caches, tr, log, false)
+	_, _, err = RegisterProxyRoutes(conf, nil, router, caches, tr, log, false)
 	if err == nil {
 		t.Errorf("Expected error for invalid cache name")
 	}
 
 	o2.CacheName = "default"
-	_, err = RegisterProxyRoutes(conf, router, caches, tr, log, false)
+	_, _, err = RegisterProxyRoutes(conf, nil, router, caches, tr, log, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -138,7 +151,7 @@ func TestRegisterProxyRoutes(t *testing.T) {
 
 	o1.Paths["/-GET-HEAD"].Methods = nil
 
-	_, err = RegisterProxyRoutes(conf, router, caches, tr, log, false)
+	_, _, err = RegisterProxyRoutes(conf, nil, router, caches, tr, log, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -154,7 +167,7 @@ func TestRegisterProxyRoutesInflux(t *testing.T) {
 
 	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
 	defer registration.CloseCaches(caches)
-	proxyClients, err := RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	proxyClients, _, err := RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -175,7 +188,7 @@ func TestRegisterProxyRoutesClickHouse(t *testing.T) {
 
 	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
 	defer registration.CloseCaches(caches)
-	proxyClients, err := RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	proxyClients, _, err := RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -196,7 +209,7 @@ func TestRegisterProxyRoutesIRONdb(t *testing.T) {
 
 	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
 	defer registration.CloseCaches(caches)
-	proxyClients, err := RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	proxyClients, _, err := RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -220,7 +233,7 @@ func TestRegisterProxyRoutesWithReqRewriters(t *testing.T) {
 
 	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
 	defer registration.CloseCaches(caches)
-	proxyClients, err := RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	proxyClients, _, err := RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -241,7 +254,7 @@ func TestRegisterProxyRoutesMultipleDefaults(t *testing.T) {
 	}
 	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
 	defer registration.CloseCaches(caches)
-	_, err = RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	_, _, err = RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
 	if err == nil {
 		t.Errorf("expected error `%s` got nothing", expected1)
 	} else if err.Error() != expected1 && err.Error() != expected2 {
@@ -275,7 +288,7 @@ func TestRegisterProxyRoutesInvalidCert(t *testing.T) {
 	}
 	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
 	defer registration.CloseCaches(caches)
-	_, err = RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	_, _, err = RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
 	if err == nil {
 		t.Errorf("expected error: %s", expected)
 	}
@@ -296,7 +309,8 @@ func TestRegisterProxyRoutesBadCacheName(t *testing.T) {
 }
 
 func TestRegisterProxyRoutesBadOriginType(t *testing.T) {
-	expected := "unknown origin type in origin config. originName: test, originType: foo"
+	expected := "unknown origin type in origin config. originName: test, originType: foo, knownTypes: " +
+		strings.Join(origins.ProviderNames(), ", ")
 	a := []string{"-config", "../../testdata/test.unknown_origin_type.conf"}
 	conf, _, err := config.Load("trickster", "test", a)
 	if err != nil {
@@ -304,7 +318,7 @@ func TestRegisterProxyRoutesBadOriginType(t *testing.T) {
 	}
 	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
 	defer registration.CloseCaches(caches)
-	_, err = RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	_, _, err = RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
 	if err == nil {
 		t.Errorf("expected error `%s` got nothing", expected)
 	} else if err.Error() != expected {
@@ -320,7 +334,7 @@ func TestRegisterMultipleOrigins(t *testing.T) {
 	}
 	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
 	defer registration.CloseCaches(caches)
-	_, err = RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	_, _, err = RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -334,7 +348,7 @@ func TestRegisterMultipleOriginsPlusDefault(t *testing.T) {
 	}
 	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
 	defer registration.CloseCaches(caches)
-	_, err = RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	_, _, err = RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -345,7 +359,7 @@ func TestRegisterMultipleOriginsPlusDefault(t *testing.T) {
 
 func TestRegisterPathRoutes(t *testing.T) {
 	p := map[string]*po.Options{"test": {}}
-	registerPathRoutes(nil, nil, nil, nil, nil, p, nil, "", nil)
+	registerPathRoutes(nil, nil, nil, nil, nil, nil, p, nil, nil, "", nil, nil, nil, nil)
 
 	conf, _, err := config.Load("trickster", "test",
 		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "rpc"})
@@ -357,10 +371,58 @@ func TestRegisterPathRoutes(t *testing.T) {
 	rpc, _ := reverseproxycache.NewClient("test", oo, mux.NewRouter(), nil)
 	dpc := rpc.DefaultPathConfigs(oo)
 	dpc["/-GET-HEAD"].Methods = nil
-	registerPathRoutes(nil, nil, rpc, oo, nil, dpc, nil, "", tl.ConsoleLogger("INFO"))
+	registerPathRoutes(mux.NewRouter(), instance.Default, rpc.Handlers(), rpc, oo, nil, dpc, nil, conf.Frontend, "",
+		nil, tl.ConsoleLogger("INFO"), nil, nil)
+
+	// the reverse proxy cache does not share cached responses to authenticated requests by default
+	if p := dpc["/-GET-HEAD"].AuthCachePolicy; p != authcache.PolicyNever {
+		t.Errorf("expected %s got %s", authcache.PolicyNever, p)
+	}
 
 }
 
+func TestRegisterPathRoutesRecover(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", "http://1", "-origin-type", "rpc"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	oo := conf.Origins["default"]
+	rpc, _ := reverseproxycache.NewClient("default", oo, mux.NewRouter(), nil)
+	panicHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	})
+	handlers := map[string]http.Handler{"proxy": panicHandler, "proxycache": panicHandler}
+	log, rec := tl.NewTestLogger("info")
+	router := mux.NewRouter()
+	registerPathRoutes(router, instance.Default, handlers, rpc, oo, nil, rpc.DefaultPathConfigs(oo), nil,
+		conf.Frontend, "", nil, log, nil, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://0/default/api?q=1", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	entries := rec.Find("error", "recovered from panic while handling request")
+	if len(entries) != 1 {
+		t.Fatalf("expected %d got %d", 1, len(entries))
+	}
+	p := entries[0].Pairs
+	if p["panic"] != "test panic" || p["url"] != "http://0/api?q=1" || p["originName"] != "default" ||
+		p["path"] != "/" {
+		t.Errorf("unexpected pairs %v", p)
+	}
+	if id := w.Header().Get(headers.NameRequestID); id == "" || p["request_id"] != id {
+		t.Errorf("expected request_id %s got %v", id, p["request_id"])
+	}
+	if s, _ := p["stack"].(string); !strings.Contains(s, "TestRegisterPathRoutesRecover") {
+		t.Errorf("unexpected stack %s", s)
+	}
+}
+
 func TestValidateRuleClients(t *testing.T) {
 
 	var cl = origins.Origins{"test": &rule.Client{}}
@@ -379,9 +441,455 @@ func TestValidateRuleClients(t *testing.T) {
 	oc := conf.Origins["default"]
 	oc.OriginType = "rule"
 
-	_, err = RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	_, _, err = RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
 	if err == nil {
 		t.Error("expected error")
 	}
 
 }
+
+func TestOriginRouter(t *testing.T) {
+
+	router := mux.NewRouter()
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	heavy, _ := reverseproxycache.NewClient("heavy", &oo.Options{}, router, nil)
+
+	// the origin is looked up when the request is routed, so it may be registered later
+	clients := origins.Origins{}
+	h := originRouter(clients, "heavy")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://0/", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected %d got %d", http.StatusBadGateway, w.Code)
+	}
+
+	clients["heavy"] = heavy
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://0/", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected %d got %d", http.StatusNoContent, w.Code)
+	}
+}
+
+func TestRegisterProxyRoutesRequestLimits(t *testing.T) {
+
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer es.Close()
+
+	log := tl.ConsoleLogger("error")
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", es.URL, "-origin-type", "rpc"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Frontend.MaxRequestURLBytes = 32
+	conf.Frontend.MaxRequestBodyBytes = 16
+	// the body limit is raised for the path handling POST requests
+	um := methods.UncacheableHTTPMethods()
+	conf.Origins["default"].Paths["/-"+strings.Join(um, "-")] = &po.Options{Path: "/",
+		MaxRequestBodyBytes: 64, Custom: []string{"max_request_body_bytes"}}
+
+	caches := registration.LoadCachesFromConfig(conf, log)
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	_, _, err = RegisterProxyRoutes(conf, nil, router, caches, nil, log, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path, body string
+		chunked            bool
+		code               int
+	}{
+		{http.MethodGet, "/?q=1", "", false, http.StatusOK},
+		{http.MethodGet, "/?q=" + strings.Repeat("x", 32), "", false, http.StatusRequestURITooLong},
+		{http.MethodGet, "/", strings.Repeat("x", 16), false, http.StatusOK},
+		{http.MethodGet, "/", strings.Repeat("x", 17), false, http.StatusRequestEntityTooLarge},
+		{http.MethodGet, "/", strings.Repeat("x", 17), true, http.StatusRequestEntityTooLarge},
+		{http.MethodGet, "/", strings.Repeat("x", 8), true, http.StatusOK},
+		{http.MethodPost, "/", strings.Repeat("x", 64), false, http.StatusOK},
+		{http.MethodPost, "/", strings.Repeat("x", 65), false, http.StatusRequestEntityTooLarge},
+		{http.MethodPost, "/", strings.Repeat("x", 65), true, http.StatusRequestEntityTooLarge},
+	}
+
+	for i, test := range tests {
+		var r *http.Request
+		if test.chunked {
+			// wrapping the reader hides the length, as with a chunked request
+			r = httptest.NewRequest(test.method, test.path, ioutil.NopCloser(strings.NewReader(test.body)))
+			r.ContentLength = -1
+		} else {
+			r = httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("test %d: expected %d got %d", i, test.code, w.Code)
+		}
+		if test.code == http.StatusOK && w.Body.String() != test.body {
+			t.Errorf("test %d: expected %s got %s", i, test.body, w.Body.String())
+		}
+		if test.code != http.StatusOK &&
+			(!strings.Contains(w.Body.String(), `"code":"request_`) ||
+				!strings.Contains(w.Body.String(), `"message":"request exceeds the max_request_`)) {
+			t.Errorf("test %d: expected limit in error body, got %s", i, w.Body.String())
+		}
+	}
+}
+
+func TestMiddlewareStack(t *testing.T) {
+
+	rw := make(rewriter.RewriteInstructions, 1)
+	limits := &mwopts.Options{Name: "limits-strict", Type: mwopts.TypeLimits}
+	dashboards := &mwopts.Options{Name: "rewrite-dashboards", Type: mwopts.TypeRewriter, ReqRewriter: rw}
+	fc := &config.FrontendConfig{}
+
+	tests := []struct {
+		p        *po.Options
+		fc       *config.FrontendConfig
+		expected string
+	}{
+		{&po.Options{}, fc, "implicit-metrics,implicit-limits"},
+		{&po.Options{}, nil, "implicit-metrics"},
+		{&po.Options{NoMetrics: true, ReqRewriter: rw}, fc, "implicit-limits,implicit-rewriter"},
+		{&po.Options{MiddlewareStack: []*mwopts.Options{dashboards, limits}, ReqRewriter: rw}, fc,
+			"implicit-metrics,rewrite-dashboards,limits-strict"},
+		{&po.Options{MiddlewareStack: []*mwopts.Options{limits}, ReqRewriter: rw}, fc,
+			"implicit-metrics,limits-strict,implicit-rewriter"},
+	}
+
+	for i, test := range tests {
+		if names := middlewareNames(middlewareStack(test.p, test.fc)); names != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, names)
+		}
+	}
+}
+
+func TestRegisterProxyRoutesMiddleware(t *testing.T) {
+
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer es.Close()
+
+	log := tl.ConsoleLogger("error")
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", es.URL, "-origin-type", "rpc"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Frontend.MaxRequestURLBytes = 32
+	conf.Frontend.MaxRequestBodyBytes = 16
+	// the listed limits instance replaces the path's own limits, and falls back
+	// to the frontend's url limit since it only sets a body limit
+	um := methods.UncacheableHTTPMethods()
+	conf.Origins["default"].Paths["/-"+strings.Join(um, "-")] = &po.Options{Path: "/",
+		MaxRequestBodyBytes: 64, Middleware: []string{"limits-strict"},
+		MiddlewareStack: []*mwopts.Options{{Name: "limits-strict", Type: mwopts.TypeLimits,
+			MaxRequestBodyBytes: 8}},
+		Custom: []string{"max_request_body_bytes", "middleware"}}
+
+	caches := registration.LoadCachesFromConfig(conf, log)
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	_, _, err = RegisterProxyRoutes(conf, nil, router, caches, nil, log, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodGet, "/", strings.Repeat("x", 16), http.StatusOK},
+		{http.MethodPost, "/", strings.Repeat("x", 8), http.StatusOK},
+		{http.MethodPost, "/", strings.Repeat("x", 9), http.StatusRequestEntityTooLarge},
+		{http.MethodPost, "/?q=" + strings.Repeat("x", 32), "", http.StatusRequestURITooLong},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		if w.Code != test.code {
+			t.Errorf("test %d: expected %d got %d", i, test.code, w.Code)
+		}
+	}
+}
+
+func TestRegisterProxyRoutesErrorBodies(t *testing.T) {
+
+	// a closed server's address refuses connections
+	es := httptest.NewServer(http.NotFoundHandler())
+	es.Close()
+
+	log := tl.ConsoleLogger("error")
+	tests := []struct {
+		originType, path, expected string
+	}{
+		{"rpc", "/", `{"status":502,"code":"origin_unreachable",` +
+			`"message":"unable to get a response from the origin","origin":"default","path":"/"}`},
+		{"prometheus", "/api/v1/query?query=up", `{"status":"error","errorType":"origin_unreachable",` +
+			`"error":"unable to get a response from the origin"}`},
+	}
+
+	for i, test := range tests {
+		conf, _, err := config.Load("trickster", "test",
+			[]string{"-log-level", "debug", "-origin-url", es.URL, "-origin-type", test.originType})
+		if err != nil {
+			t.Fatalf("Could not load configuration: %s", err.Error())
+		}
+		caches := registration.LoadCachesFromConfig(conf, log)
+		router := mux.NewRouter()
+		_, _, err = RegisterProxyRoutes(conf, nil, router, caches, nil, log, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		registration.CloseCaches(caches)
+		if w.Code != http.StatusBadGateway {
+			t.Errorf("test %d: expected %d got %d", i, http.StatusBadGateway, w.Code)
+		}
+		if w.Body.String() != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, w.Body.String())
+		}
+	}
+}
+
+func TestRegisterProxyRoutesExternalProvider(t *testing.T) {
+
+	// the example provider generates one value per interval in the requested range
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, _ := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		to, _ := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+		interval, _ := strconv.ParseInt(r.URL.Query().Get("interval"), 10, 64)
+		values := make([]string, 0, (to-from)/interval+1)
+		for i := from; i <= to; i += interval {
+			values = append(values, "["+strconv.FormatInt(i, 10)+`,"1"]`)
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[` +
+			`{"metric":{"__name__":"a"},"values":[` + strings.Join(values, ",") + `]}]}}`))
+	}))
+	defer ts.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", ts.URL, "-origin-type", exampletsdb.OriginType, "-log-level", "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	clients, _, err := RegisterProxyRoutes(conf, nil, router, caches, nil, tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := clients["default"].(*exampletsdb.Client); !ok {
+		t.Errorf("expected exampletsdb client got %T", clients["default"])
+	}
+
+	end := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	u := "http://0/api/range?q=a&interval=60&from=" + strconv.FormatInt(end.Add(-time.Hour).Unix(), 10) +
+		"&to=" + strconv.FormatInt(end.Unix(), 10)
+
+	for _, expected := range []string{"kmiss", "hit"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, u, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+		}
+		if v := w.Header().Get("X-Trickster-Result"); !strings.Contains(v, "status="+expected) {
+			t.Errorf("expected status %s got %s", expected, v)
+		}
+		if n := strings.Count(w.Body.String(), `,"1"]`); n != 61 {
+			t.Errorf("expected %d values got %d", 61, n)
+		}
+		// the cache is written in a separate goroutine from the response
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRegisterProxyRoutesTimeoutBudget(t *testing.T) {
+
+	timeouts := make(chan string, 1)
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeouts <- r.URL.Query().Get("timeout")
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer es.Close()
+
+	log := tl.ConsoleLogger("error")
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", es.URL, "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	caches := registration.LoadCachesFromConfig(conf, log)
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	_, _, err = RegisterProxyRoutes(conf, nil, router, caches, nil, log, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path, header string
+	}{
+		{"/api/v1/query?query=up&timeout=0.5", ""},
+		{"/api/v1/query?query=up", "500ms"},
+	}
+
+	for i, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.header != "" {
+			r.Header.Set("X-Request-Timeout", test.header)
+		}
+		w := httptest.NewRecorder()
+		start := time.Now()
+		router.ServeHTTP(w, r)
+		elapsed := time.Since(start)
+
+		if elapsed > 3*time.Second {
+			t.Errorf("test %d: expected request to be abandoned at the hint, took %s", i, elapsed)
+		}
+		if w.Code != http.StatusBadGateway {
+			t.Errorf("test %d: expected %d got %d", i, http.StatusBadGateway, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "origin_timeout") {
+			t.Errorf("test %d: expected origin_timeout error got %s", i, w.Body.String())
+		}
+		ts := <-timeouts
+		d, err := time.ParseDuration(ts + "s")
+		if err != nil {
+			t.Fatalf("test %d: %s", i, err.Error())
+		}
+		if d <= 0 || d > 400*time.Millisecond {
+			t.Errorf("test %d: expected forwarded timeout of at most 400ms got %s", i, ts)
+		}
+	}
+}
+
+func TestRegisterProxyRoutesAccessLog(t *testing.T) {
+
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test"))
+	}))
+	defer es.Close()
+
+	dir, err := ioutil.TempDir("/tmp", "trickster-access-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	log := tl.ConsoleLogger("error")
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", es.URL, "-origin-type", "rpc"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Logging.AccessLogFile = dir + "/access.log"
+	al := tl.NewAccessLogger(conf)
+	middleware.SetAccessLogger(al)
+	defer middleware.SetAccessLogger(nil)
+
+	caches := registration.LoadCachesFromConfig(conf, log)
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	_, _, err = RegisterProxyRoutes(conf, nil, router, caches, nil, log, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/default/test.js?v=1", nil)
+	r.RemoteAddr = "192.0.2.1:54321"
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	al.Close()
+
+	b, err := ioutil.ReadFile(conf.Logging.AccessLogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{" method=GET path=/default/test.js query=\"v=1\" status=200 bytes=4 ",
+		" client_ip=192.0.2.1 origin=default cache_status=kmiss\n"} {
+		if !strings.Contains(string(b), part) {
+			t.Errorf("expected %s in %s", part, string(b))
+		}
+	}
+}
+
+func TestRegisterProxyRoutesRequestID(t *testing.T) {
+
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the request ID is forwarded upstream
+		if v := r.Header.Get(headers.NameRequestID); v != "abc" {
+			t.Errorf("expected %s got %s", "abc", v)
+		}
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		start, end := r.URL.Query().Get("start"), r.URL.Query().Get("end")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[` +
+			`{"metric":{"__name__":"up"},"values":[[` + start + `,"1"],[` + end + `,"1"]]}]}}`))
+	}))
+	defer es.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "trace", "-origin-url", es.URL, "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	log, rec := tl.NewTestLogger(conf.Logging.LogLevel)
+
+	caches := registration.LoadCachesFromConfig(conf, log)
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	_, _, err = RegisterProxyRoutes(conf, nil, router, caches, nil, log, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	end := time.Now().Add(-time.Hour).Truncate(time.Minute).Unix()
+	r := httptest.NewRequest(http.MethodGet, "/default/api/v1/query_range?query=up&start="+
+		strconv.FormatInt(end-60, 10)+"&end="+strconv.FormatInt(end, 10)+"&step=60", nil)
+	r.Header.Set(headers.NameRequestID, "abc")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if v := w.Header().Get(headers.NameRequestID); v != "abc" {
+		t.Errorf("expected %s got %s", "abc", v)
+	}
+	// the cache is written in the background
+	background.Wait(time.Second)
+
+	// the request's events, across the delta proxy cache, the upstream request and the cache
+	// write, are correlated by its request ID
+	for _, event := range []string{"delta proxy cache lookup", "upstream request complete",
+		"writing object to cache"} {
+		entries := rec.Find("", event)
+		if len(entries) == 0 {
+			t.Errorf("expected %s event", event)
+		}
+		for _, e := range entries {
+			if e.Pairs["request_id"] != "abc" {
+				t.Errorf("expected request_id in %s event %v", event, e.Pairs)
+			}
+		}
+	}
+
+	// requests having no valid request ID are assigned one
+	r.Header.Set(headers.NameRequestID, "a b")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if v := w.Header().Get(headers.NameRequestID); len(v) != 32 {
+		t.Errorf("expected a new request ID got %s", v)
+	}
+}
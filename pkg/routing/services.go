@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routing
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/healthcheck"
+	"github.com/tricksterproxy/trickster/pkg/proxy/refresh"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// Services are the background health check probers and refresh jobs of the origins registered
+// by RegisterProxyRoutes. They are not started as the routes are registered, so that a config
+// can be checked before it replaces the running config, and the Services of each config are
+// started and stopped independently of those of any other config in the process
+type Services struct {
+	health    *healthcheck.Registry
+	probers   []prober
+	scheduler *refresh.Scheduler
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// prober is an origin's health check Target, and the client with which it is probed
+type prober struct {
+	target *healthcheck.Target
+	client *http.Client
+	logger *tl.Logger
+}
+
+// Scheduler returns the Scheduler of the refresh jobs, or nil if there is none
+func (s *Services) Scheduler() *refresh.Scheduler {
+	if s == nil {
+		return nil
+	}
+	return s.scheduler
+}
+
+// Start begins the background health check probers and refresh jobs
+func (s *Services) Start() {
+	if s == nil {
+		return
+	}
+	s.startOnce.Do(func() {
+		for _, p := range s.probers {
+			p.target.Start(s.health, p.client, p.logger)
+		}
+		if s.scheduler != nil {
+			s.scheduler.Start()
+		}
+	})
+}
+
+// Stop ends the background health check probers and refresh jobs
+func (s *Services) Stop() {
+	if s == nil {
+		return
+	}
+	s.stopOnce.Do(func() {
+		if s.scheduler != nil {
+			s.scheduler.Stop()
+		}
+		for _, p := range s.probers {
+			p.target.Stop()
+		}
+	})
+}
+
+// healthy returns false if the background prober of the named origin reports it as unhealthy.
+// Origins without a prober are presumed healthy
+func (s *Services) healthy(originName string) bool {
+	for _, p := range s.probers {
+		if p.target.OriginName() == originName && !p.target.Healthy() {
+			return false
+		}
+	}
+	return true
+}
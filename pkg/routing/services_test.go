@@ -0,0 +1,111 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/registration"
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/proxy/refresh"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServices(t *testing.T) {
+
+	var unhealthy int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&unhealthy) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer origin.Close()
+
+	conf, err := config.LoadDocument(fmt.Sprintf(`
+[origins]
+  [origins.services]
+  origin_type = 'reverseproxycache'
+  origin_url = '%s'
+  health_check_interval_ms = 60000
+
+[refresh_jobs]
+  [refresh_jobs.test]
+  origin_name = 'services'
+  path = '/'
+  enabled = false
+`, origin.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+
+	// a dry run has no Services
+	_, svc, err := RegisterProxyRoutes(conf, nil, mux.NewRouter(), caches, nil,
+		tl.ConsoleLogger("error"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc != nil {
+		t.Error("expected no services for a dry run")
+	}
+	svc.Start()
+	svc.Stop()
+	if svc.Scheduler() != nil {
+		t.Error("expected no scheduler")
+	}
+
+	// the Services are not started as the routes are registered
+	inst := instance.New()
+	_, svc, err = RegisterProxyRoutes(conf, inst, mux.NewRouter(), caches, nil,
+		tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := inst.Health.OriginStatus("services"); ok {
+		t.Error("expected the prober not to be started")
+	}
+	if svc.Scheduler() == nil || svc.Scheduler().Job("test") == nil {
+		t.Fatal("expected scheduled refresh job")
+	}
+
+	// the refresh jobs are paused by the health of the Services' own origins
+	atomic.StoreInt32(&unhealthy, 1)
+	svc.Start()
+	if _, ok := inst.Health.OriginStatus("services"); !ok {
+		t.Error("expected the prober to be started")
+	}
+	for i := 0; i < 100 && svc.healthy("services"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := svc.Scheduler().Job("test").Execute(); err != refresh.ErrPaused {
+		t.Errorf("expected %v got %v", refresh.ErrPaused, err)
+	}
+
+	svc.Stop()
+	if _, ok := inst.Health.OriginStatus("services"); ok {
+		t.Error("expected the prober to be stopped")
+	}
+}
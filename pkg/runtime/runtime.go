@@ -17,7 +17,10 @@
 // Package runtime holds application runtime information
 package runtime
 
-import "os"
+import (
+	"os"
+	"time"
+)
 
 // ApplicationName is the name of the Application
 var ApplicationName string
@@ -25,6 +28,15 @@ var ApplicationName string
 // ApplicationVersion holds the version of the Application
 var ApplicationVersion string
 
+// ApplicationCommitID holds the git commit ID from which the Application was built
+var ApplicationCommitID string
+
+// ApplicationBuildTime holds the time at which the Application was built
+var ApplicationBuildTime string
+
+// StartTime is the time at which the Application process started
+var StartTime = time.Now()
+
 // Server is the name, hostname or ip of the server as advertised in HTTP Headers
 // By default uses the hostname reported by the kernel
 var Server, _ = os.Hostname()
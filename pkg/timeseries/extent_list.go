@@ -19,6 +19,7 @@ package timeseries
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -39,6 +40,32 @@ func (el ExtentList) String() string {
 	return strings.Join(lines, ";")
 }
 
+// ParseExtentList returns the ExtentList represented by a string in the format
+// startEpochSec1-endEpochSec1;startEpochSec2-endEpochSec2, as produced by String
+func ParseExtentList(s string) (ExtentList, error) {
+	if s == "" {
+		return ExtentList{}, nil
+	}
+	parts := strings.Split(s, ";")
+	el := make(ExtentList, len(parts))
+	for i, p := range parts {
+		se := strings.SplitN(strings.TrimSpace(p), "-", 2)
+		if len(se) != 2 {
+			return nil, fmt.Errorf("invalid extent [%s]", p)
+		}
+		start, err := strconv.ParseInt(se[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extent [%s]", p)
+		}
+		end, err := strconv.ParseInt(se[1], 10, 64)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("invalid extent [%s]", p)
+		}
+		el[i] = Extent{Start: time.Unix(start, 0), End: time.Unix(end, 0)}
+	}
+	return el, nil
+}
+
 // InsideOf returns true if the provided extent is contained
 // completely within boundaries of the subject ExtentList
 func (el ExtentList) InsideOf(e Extent) bool {
@@ -104,9 +131,41 @@ func (el ExtentList) Crop(e Extent) ExtentList {
 	return make(ExtentList, 0)
 }
 
+// Subtract returns the portions of the provided extent that are not covered by the
+// ExtentList, aligned to the step, so that a returned extent does not include the
+// timestamps at the boundaries of the subtracted extents
+func (el ExtentList) Subtract(e Extent, step time.Duration) ExtentList {
+	sorted := el.Clone()
+	sort.Sort(sorted)
+	out := make(ExtentList, 0, len(sorted)+1)
+	start := e.Start
+	for _, x := range sorted {
+		if x.End.Before(start) {
+			continue
+		}
+		if x.Start.After(e.End) {
+			break
+		}
+		if end := x.Start.Add(-step); !end.Before(start) {
+			out = append(out, Extent{Start: start, End: end})
+		}
+		start = x.End.Add(step)
+	}
+	if !start.After(e.End) {
+		out = append(out, Extent{Start: start, End: e.End})
+	}
+	return out
+}
+
 // Compress sorts an ExtentList and merges time-adjacent Extents so that the total extent of
 // data is accurately represented in as few Extents as possible
 func (el ExtentList) Compress(step time.Duration) ExtentList {
+	return el.compress(func(t time.Time) time.Time { return t.Add(step) })
+}
+
+// compress merges extents that overlap, or where one extent begins at the step boundary
+// that the provided func returns as following the other's end
+func (el ExtentList) compress(next func(time.Time) time.Time) ExtentList {
 	exc := el.Clone()
 	if len(el) == 0 {
 		return exc
@@ -127,7 +186,7 @@ func (el ExtentList) Compress(step time.Duration) ExtentList {
 		if exc[i].End.Before(extr.End) {
 			continue
 		}
-		if i+1 < l && ((exc[i].End.Add(step).Equal(exc[i+1].Start) ||
+		if i+1 < l && ((next(exc[i].End).Equal(exc[i+1].Start) ||
 			exc[i].End.Equal(exc[i+1].Start)) && exc[i].LastUsed.Equal(exc[i+1].LastUsed) ||
 			exc[i].End.Equal(exc[i+1].End) && exc[i].Start.Equal(exc[i+1].Start)) {
 			continue
@@ -240,6 +240,53 @@ func TestString(t *testing.T) {
 
 }
 
+func TestParseExtentList(t *testing.T) {
+
+	el, err := ParseExtentList("100-200;600-900")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := ExtentList{Extent{Start: t100, End: t200}, Extent{Start: t600, End: t900}}
+	if !reflect.DeepEqual(el, expected) {
+		t.Errorf("expected %s got %s", expected, el)
+	}
+
+	el, err = ParseExtentList("")
+	if err != nil || len(el) != 0 {
+		t.Errorf("expected empty list got %s %v", el, err)
+	}
+
+	for _, s := range []string{"100", "a-200", "100-b", "200-100"} {
+		if _, err = ParseExtentList(s); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
+
+func TestSubtract(t *testing.T) {
+
+	tests := []struct {
+		el       ExtentList
+		e        Extent
+		expected string
+	}{
+		{ExtentList{}, Extent{Start: t100, End: t900}, "100-900"},
+		{ExtentList{Extent{Start: t600, End: t900}, Extent{Start: t100, End: t200}},
+			Extent{Start: t100, End: t900}, "300-500"},
+		{ExtentList{Extent{Start: t300, End: t600}}, Extent{Start: t100, End: t900}, "100-200;700-900"},
+		{ExtentList{Extent{Start: t1000, End: t1300}}, Extent{Start: t100, End: t900}, "100-900"},
+		{ExtentList{Extent{Start: t100, End: t900}}, Extent{Start: t100, End: t900}, ""},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if s := test.el.Subtract(test.e, 100*time.Second).String(); s != test.expected {
+				t.Errorf("got %s expected %s", s, test.expected)
+			}
+		})
+	}
+}
+
 func TestCrop(t *testing.T) {
 
 	el := ExtentList{
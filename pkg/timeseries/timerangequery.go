@@ -44,8 +44,19 @@ type TimeRangeQuery struct {
 	IsOffset bool
 	// BackfillTolerance can be updated to override the overall backfill tolerance per query
 	BackfillTolerance time.Duration
+	// Lookback is the duration of raw data preceding each datapoint from which the datapoint is computed
+	Lookback time.Duration
+	// Location is the time zone to whose local time the query's step boundaries are aligned, such as
+	// by a tz() clause. When nil, step boundaries are aligned to UTC
+	Location *time.Location
+	// StepOffset is the offset of the query's step boundaries from the epoch, such as 3 days for
+	// weeks beginning on Sunday, since the epoch fell on a Thursday
+	StepOffset time.Duration
 }
 
+// day is the length of a calendar day outside of daylight saving time transitions
+const day = 24 * time.Hour
+
 // Clone returns an exact copy of a TimeRangeQuery
 func (trq *TimeRangeQuery) Clone() *TimeRangeQuery {
 	t := &TimeRangeQuery{
@@ -55,6 +66,9 @@ func (trq *TimeRangeQuery) Clone() *TimeRangeQuery {
 		IsOffset:           trq.IsOffset,
 		TimestampFieldName: trq.TimestampFieldName,
 		FastForwardDisable: trq.FastForwardDisable,
+		Lookback:           trq.Lookback,
+		Location:           trq.Location,
+		StepOffset:         trq.StepOffset,
 	}
 
 	if trq.TemplateURL != nil {
@@ -70,9 +84,71 @@ func (trq *TimeRangeQuery) NormalizeExtent() {
 		if !trq.IsOffset && trq.Extent.End.After(time.Now()) {
 			trq.Extent.End = time.Now()
 		}
-		trq.Extent.Start = trq.Extent.Start.Truncate(trq.Step)
-		trq.Extent.End = trq.Extent.End.Truncate(trq.Step)
+		trq.Extent.Start = trq.AlignTime(trq.Extent.Start)
+		trq.Extent.End = trq.AlignTime(trq.Extent.End)
+	}
+}
+
+// IsLocal returns true if the query's step boundaries are aligned to a Location or a StepOffset,
+// rather than to multiples of the Step
+func (trq *TimeRangeQuery) IsLocal() bool {
+	return trq.Location != nil || trq.StepOffset != 0
+}
+
+// AlignTime returns the boundary of the step containing t. When the query is local, steps of whole
+// days begin at local midnight, so they are 23 or 25 hours long across daylight saving time
+// transitions, and shorter steps are aligned to the Location's offset from UTC at t
+func (trq *TimeRangeQuery) AlignTime(t time.Time) time.Time {
+	if trq.Step <= 0 {
+		return t
+	}
+	if !trq.IsLocal() {
+		return t.Truncate(trq.Step)
+	}
+	loc := trq.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	lt := t.In(loc)
+	if trq.Step%day != 0 {
+		_, zo := lt.Zone()
+		shift := time.Duration(zo)*time.Second - trq.StepOffset
+		return t.Add(shift).Truncate(trq.Step).Add(-shift)
+	}
+	days := int64(trq.Step / day)
+	offset := int64(trq.StepOffset / day)
+	dn := time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, time.UTC).Unix()/86400 - offset
+	dn -= ((dn % days) + days) % days
+	y, m, d := time.Unix((dn+offset)*86400, 0).UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// NextStep returns the boundary of the step following the step that begins at t
+func (trq *TimeRangeQuery) NextStep(t time.Time) time.Time {
+	if trq.Location == nil || trq.Step%day != 0 {
+		return t.Add(trq.Step)
+	}
+	lt := t.In(trq.Location)
+	return time.Date(lt.Year(), lt.Month(), lt.Day()+int(trq.Step/day), 0, 0, 0, 0, trq.Location)
+}
+
+// CompressExtents returns the ExtentList, sorted and with adjacent extents merged, where extents
+// are adjacent when one begins at the query's step boundary following the other's end
+func (trq *TimeRangeQuery) CompressExtents(el ExtentList) ExtentList {
+	return el.compress(trq.NextStep)
+}
+
+// LookbackExtent returns the extent with its Start moved earlier by the query's Lookback. The Lookback
+// is rounded up to a multiple of the Step, so the returned extent remains aligned to step boundaries
+func (trq *TimeRangeQuery) LookbackExtent(e Extent) Extent {
+	if trq.Lookback <= 0 {
+		return e
+	}
+	lb := trq.Lookback
+	if trq.Step > 0 {
+		lb = ((lb + trq.Step - 1) / trq.Step) * trq.Step
 	}
+	return Extent{Start: e.Start.Add(-lb), End: e.End, LastUsed: e.LastUsed}
 }
 
 // CalculateDeltas provides a list of extents that are not in a cached timeseries,
@@ -86,7 +162,7 @@ func (trq *TimeRangeQuery) CalculateDeltas(have ExtentList) ExtentList {
 		misCap = 0
 	}
 	misses := make([]time.Time, 0, misCap)
-	for i := trq.Extent.Start; !trq.Extent.End.Before(i); i = i.Add(trq.Step) {
+	for i := trq.Extent.Start; !trq.Extent.End.Before(i); i = trq.NextStep(i) {
 		found := false
 		for j := range have {
 			if j == 0 && i.Before(have[j].Start) {
@@ -110,7 +186,7 @@ func (trq *TimeRangeQuery) CalculateDeltas(have ExtentList) ExtentList {
 		if inStart.IsZero() {
 			inStart = misses[i]
 		}
-		if i+1 == l || !misses[i+1].Equal(misses[i].Add(trq.Step)) {
+		if i+1 == l || !misses[i+1].Equal(trq.NextStep(misses[i])) {
 			ins = append(ins, Extent{Start: inStart, End: misses[i]})
 			inStart = time.Time{}
 		}
@@ -135,13 +135,39 @@ func TestNormalizeExtent(t *testing.T) {
 func TestClone(t *testing.T) {
 	u, _ := url.Parse("http://127.0.0.1/")
 	trq := &TimeRangeQuery{Statement: "1234", Extent: Extent{Start: time.Unix(5, 0),
-		End: time.Unix(10, 0)}, Step: time.Duration(5) * time.Second, TemplateURL: u}
+		End: time.Unix(10, 0)}, Step: time.Duration(5) * time.Second, TemplateURL: u,
+		Lookback: time.Duration(5) * time.Minute}
 	c := trq.Clone()
 	if !reflect.DeepEqual(trq, c) {
 		t.Errorf("expected %s got %s", trq.String(), c.String())
 	}
 }
 
+func TestLookbackExtent(t *testing.T) {
+
+	e := Extent{Start: time.Unix(600, 0), End: time.Unix(900, 0)}
+
+	tests := []struct {
+		lookback, step time.Duration
+		expected       Extent
+	}{
+		{0, time.Minute, e},
+		{time.Minute * 5, time.Minute, Extent{Start: time.Unix(300, 0), End: e.End}},
+		// the lookback is rounded up to the step
+		{time.Second * 330, time.Minute, Extent{Start: time.Unix(240, 0), End: e.End}},
+		{time.Second * 330, 0, Extent{Start: time.Unix(270, 0), End: e.End}},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			trq := &TimeRangeQuery{Extent: e, Step: test.step, Lookback: test.lookback}
+			if v := trq.LookbackExtent(e); v != test.expected {
+				t.Errorf("expected %s got %s", test.expected.String(), v.String())
+			}
+		})
+	}
+}
+
 func TestStringTRQ(t *testing.T) {
 
 	const expected = `{ "statement": "1234", "step": "5s", "extent": "5-10" }`
@@ -173,3 +199,155 @@ func TestGetBackfillTolerance(t *testing.T) {
 	}
 
 }
+
+func TestAlignTime(t *testing.T) {
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		loc      *time.Location
+		step     time.Duration
+		offset   time.Duration
+		t        time.Time
+		expected time.Time
+	}{
+		{nil, time.Hour, 0, time.Unix(5400, 0), time.Unix(3600, 0)},
+		{ny, day, 0, time.Date(2020, 3, 8, 12, 0, 0, 0, ny), time.Date(2020, 3, 8, 0, 0, 0, 0, ny)},
+		{ny, day, 0, time.Date(2020, 3, 9, 0, 30, 0, 0, ny), time.Date(2020, 3, 9, 0, 0, 0, 0, ny)},
+		{ny, day, 0, time.Date(2020, 11, 1, 23, 30, 0, 0, ny), time.Date(2020, 11, 1, 0, 0, 0, 0, ny)},
+		{ny, 7 * day, 3 * day, time.Date(2020, 3, 11, 12, 0, 0, 0, ny), time.Date(2020, 3, 8, 0, 0, 0, 0, ny)},
+		{ny, 7 * day, 4 * day, time.Date(2020, 3, 11, 12, 0, 0, 0, ny), time.Date(2020, 3, 9, 0, 0, 0, 0, ny)},
+		{nil, 7 * day, 3 * day, time.Date(2020, 3, 11, 12, 0, 0, 0, time.UTC),
+			time.Date(2020, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{kolkata, time.Hour, 0, time.Date(2020, 3, 8, 10, 45, 0, 0, kolkata),
+			time.Date(2020, 3, 8, 10, 0, 0, 0, kolkata)},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			trq := TimeRangeQuery{Step: test.step, Location: test.loc, StepOffset: test.offset}
+			if v := trq.AlignTime(test.t); !v.Equal(test.expected) {
+				t.Errorf("expected %s got %s", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestNextStep(t *testing.T) {
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trq := TimeRangeQuery{Step: day, Location: ny}
+
+	// spring forward
+	st := time.Date(2020, 3, 8, 0, 0, 0, 0, ny)
+	if v := trq.NextStep(st); v.Sub(st) != 23*time.Hour {
+		t.Errorf("expected %s got %s", 23*time.Hour, v.Sub(st))
+	}
+
+	// fall back
+	st = time.Date(2020, 11, 1, 0, 0, 0, 0, ny)
+	if v := trq.NextStep(st); v.Sub(st) != 25*time.Hour {
+		t.Errorf("expected %s got %s", 25*time.Hour, v.Sub(st))
+	}
+
+	trq.Location = nil
+	if v := trq.NextStep(st); v.Sub(st) != day {
+		t.Errorf("expected %s got %s", day, v.Sub(st))
+	}
+}
+
+func TestCalculateDeltasDST(t *testing.T) {
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2020, month, day, hour, 0, 0, 0, ny)
+	}
+
+	tests := []struct {
+		start, end time.Time
+		have       ExtentList
+		expected   ExtentList
+	}{
+		{ // spring forward
+			d(3, 1, 12), d(3, 12, 12),
+			ExtentList{{Start: d(3, 1, 0), End: d(3, 7, 0)}},
+			ExtentList{{Start: d(3, 8, 0), End: d(3, 12, 0)}},
+		},
+		{ // fall back
+			d(10, 25, 12), d(11, 5, 12),
+			ExtentList{{Start: d(10, 25, 0), End: d(11, 1, 0)}},
+			ExtentList{{Start: d(11, 2, 0), End: d(11, 5, 0)}},
+		},
+		{ // cached on both sides of the transition
+			d(3, 1, 12), d(3, 12, 12),
+			ExtentList{{Start: d(3, 1, 0), End: d(3, 8, 0)}, {Start: d(3, 9, 0), End: d(3, 12, 0)}},
+			ExtentList{},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			trq := TimeRangeQuery{Extent: Extent{Start: test.start, End: test.end}, Step: day, Location: ny}
+			trq.NormalizeExtent()
+			if !trq.Extent.Start.Equal(d(test.start.Month(), test.start.Day(), 0)) ||
+				!trq.Extent.End.Equal(d(test.end.Month(), test.end.Day(), 0)) {
+				t.Errorf("expected extent aligned to local midnight got %s", trq.Extent.String())
+			}
+			deltas := trq.CalculateDeltas(test.have)
+			if !extentsEqual(deltas, test.expected) {
+				t.Errorf("expected %v got %v", test.expected, deltas)
+			}
+		})
+	}
+}
+
+func TestCompressExtentsDST(t *testing.T) {
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	el := ExtentList{
+		{Start: time.Date(2020, 3, 1, 0, 0, 0, 0, ny), End: time.Date(2020, 3, 8, 0, 0, 0, 0, ny)},
+		{Start: time.Date(2020, 3, 9, 0, 0, 0, 0, ny), End: time.Date(2020, 3, 12, 0, 0, 0, 0, ny)},
+	}
+
+	// the 23-hour day is not joined by adding the step
+	if c := el.Compress(day); len(c) != 2 {
+		t.Errorf("expected %d got %d", 2, len(c))
+	}
+
+	trq := TimeRangeQuery{Step: day, Location: ny}
+	expected := ExtentList{{Start: el[0].Start, End: el[1].End}}
+	if c := trq.CompressExtents(el); !extentsEqual(c, expected) {
+		t.Errorf("expected %v got %v", expected, c)
+	}
+}
+
+// extentsEqual compares extent lists by instant, regardless of the location of their times
+func extentsEqual(a, b ExtentList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Start.Equal(b[i].Start) || !a[i].End.Equal(b[i].End) {
+			return false
+		}
+	}
+	return true
+}
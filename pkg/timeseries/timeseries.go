@@ -55,3 +55,11 @@ type Timeseries interface {
 	// Size returns the approximate memory byte size of the timeseries object
 	Size() int
 }
+
+// Downsampler is an optional interface for Timeseries that can reduce their resolution
+// to a coarser step, so that they can be used to fulfill requests at that step
+type Downsampler interface {
+	// Downsample should reduce the Timeseries to only those timestamps that are aligned to
+	// the provided step, and set the Timeseries step accordingly
+	Downsample(time.Duration)
+}
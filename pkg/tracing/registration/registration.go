@@ -31,11 +31,52 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/tracing/types"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 	"github.com/tricksterproxy/trickster/pkg/util/strings"
+
+	"go.opentelemetry.io/otel/api/trace"
 )
 
 // RegisterAll registers all Tracers in the provided configuration, and returns
 // their Flushers
 func RegisterAll(cfg *config.Config, log *tl.Logger, isDryRun bool) (tracing.Tracers, error) {
+	mapped, err := mappedConfigs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tracers := make(tracing.Tracers)
+	for k, tc := range mapped {
+		if _, ok := types.Names[tc.TracerType]; !ok {
+			return nil, fmt.Errorf("invalid tracer type [%s] for tracing config [%s]",
+				tc.TracerType, k)
+		}
+		tracer, err := GetTracer(tc, log, isDryRun)
+		if err != nil {
+			return nil, err
+		}
+		tracers[k] = tracer
+	}
+	return tracers, nil
+}
+
+// RegisterAllWithProvider returns a Tracer from the provided Provider for each tracing config
+// in the provided configuration, in place of the Tracer of the config's exporter, such as for
+// an application that embeds Trickster and exports the spans of its own Provider. The
+// Provider is flushed by its owner, so the Tracers have no Flushers
+func RegisterAllWithProvider(cfg *config.Config, tp trace.Provider) (tracing.Tracers, error) {
+	mapped, err := mappedConfigs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tracers := make(tracing.Tracers)
+	for k, tc := range mapped {
+		tracers[k] = &tracing.Tracer{Name: k, Tracer: tp.Tracer(k), Options: tc}
+	}
+	return tracers, nil
+}
+
+// mappedConfigs returns the tracing configs that are used by an origin, keyed by name. Tracing
+// configs that are not used by any origin are not returned, since there is no need to use
+// resources to instantiate them
+func mappedConfigs(cfg *config.Config) (map[string]*options.Options, error) {
 	if cfg == nil {
 		return nil, errors.New("no config provided")
 	}
@@ -46,40 +87,19 @@ func RegisterAll(cfg *config.Config, log *tl.Logger, isDryRun bool) (tracing.Tra
 		return nil, errors.New("no tracers provided")
 	}
 
-	// remove any tracers that are configured but not used by an origin, we don't want
-	// to use resources to instantiate them
-	mappedTracers := make(map[string]bool)
-
+	mapped := make(map[string]*options.Options)
 	for k, v := range cfg.Origins {
 		if v != nil && v.TracingConfigName != "" {
-			if _, ok := cfg.TracingConfigs[v.TracingConfigName]; !ok {
+			tc, ok := cfg.TracingConfigs[v.TracingConfigName]
+			if !ok {
 				return nil, fmt.Errorf("origin %s provided invalid tracing config name %s",
 					k, v.TracingConfigName)
 			}
-			mappedTracers[v.TracingConfigName] = true
+			tc.Name = v.TracingConfigName
+			mapped[v.TracingConfigName] = tc
 		}
 	}
-
-	tracers := make(tracing.Tracers)
-	for k, tc := range cfg.TracingConfigs {
-		if _, ok := mappedTracers[k]; !ok {
-			// tracer is configured, but not mapped by any origin,
-			// so we won't instantiate it
-			continue
-		}
-
-		tc.Name = k
-		if _, ok := types.Names[tc.TracerType]; !ok {
-			return nil, fmt.Errorf("invalid tracer type [%s] for tracing config [%s]",
-				tc.TracerType, k)
-		}
-		tracer, err := GetTracer(tc, log, isDryRun)
-		if err != nil {
-			return nil, err
-		}
-		tracers[k] = tracer
-	}
-	return tracers, nil
+	return mapped, nil
 }
 
 // GetTracer returns a *Tracer based on the provided options
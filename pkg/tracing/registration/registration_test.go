@@ -22,6 +22,8 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/config"
 	"github.com/tricksterproxy/trickster/pkg/tracing/options"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"go.opentelemetry.io/otel/api/trace"
 )
 
 func TestRegisterAll(t *testing.T) {
@@ -117,6 +119,40 @@ func TestRegisterAll(t *testing.T) {
 
 }
 
+func TestRegisterAllWithProvider(t *testing.T) {
+
+	if _, err := RegisterAllWithProvider(nil, trace.NoopProvider{}); err == nil {
+		t.Error("expected error for no config provided")
+	}
+
+	cfg := config.NewConfig()
+	tc := options.NewOptions()
+	// the tracer type is not used, since the tracer is from the provider
+	tc.TracerType = "foo"
+	cfg.TracingConfigs = map[string]*options.Options{"test": tc, "unused": options.NewOptions()}
+	cfg.Origins["default"].TracingConfigName = "test"
+
+	f, err := RegisterAllWithProvider(cfg, trace.NoopProvider{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f) != 1 {
+		t.Fatalf("expected %d got %d", 1, len(f))
+	}
+	tr, ok := f["test"]
+	if !ok || tr.Tracer == nil {
+		t.Fatal("expected tracer from provider")
+	}
+	if tr.Name != "test" || tr.Options != tc || tr.Flusher != nil {
+		t.Errorf("unexpected tracer %+v", tr)
+	}
+
+	cfg.Origins["default"].TracingConfigName = "missing"
+	if _, err = RegisterAllWithProvider(cfg, trace.NoopProvider{}); err == nil {
+		t.Error("expected error for invalid tracing config name")
+	}
+}
+
 func TestGetTracer(t *testing.T) {
 	tr, _ := GetTracer(nil, tl.ConsoleLogger("error"), true)
 	if tr != nil {
@@ -25,6 +25,7 @@ import (
 
 	"go.opentelemetry.io/otel/api/correlation"
 	"go.opentelemetry.io/otel/api/kv"
+	"go.opentelemetry.io/otel/api/propagation"
 	"go.opentelemetry.io/otel/api/trace"
 	"go.opentelemetry.io/otel/plugin/httptrace"
 )
@@ -43,7 +44,14 @@ func PrepareRequest(r *http.Request, tr *tracing.Tracer) (*http.Request, trace.S
 		return r, nil
 	}
 
-	attrs, entries, spanCtx := httptrace.Extract(r.Context(), r)
+	ctx := propagation.ExtractHTTP(r.Context(), tracing.Propagators, r.Header)
+	attrs := []kv.KeyValue{httptrace.URLKey.String(r.URL.String())}
+	var entries []kv.KeyValue
+	correlation.MapFromContext(ctx).Foreach(func(e kv.KeyValue) bool {
+		entries = append(entries, e)
+		return true
+	})
+	spanCtx := trace.RemoteSpanContextFromContext(ctx)
 
 	attrs = filterAttributes(tr, attrs)
 
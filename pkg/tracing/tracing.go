@@ -22,11 +22,21 @@ import (
 
 	"github.com/tricksterproxy/trickster/pkg/tracing/options"
 
+	"go.opentelemetry.io/otel/api/correlation"
 	"go.opentelemetry.io/otel/api/kv"
+	"go.opentelemetry.io/otel/api/propagation"
 	"go.opentelemetry.io/otel/api/trace"
 	"google.golang.org/grpc/codes"
 )
 
+// Propagators extract the W3C Trace Context and Correlation Context of client requests, and inject
+// them into upstream requests. They are used in place of the OpenTelemetry global propagators, so
+// that tracing does not depend on the global state of the process
+var Propagators = propagation.New(
+	propagation.WithInjectors(trace.DefaultHTTPPropagator(), correlation.DefaultHTTPPropagator()),
+	propagation.WithExtractors(trace.DefaultHTTPPropagator(), correlation.DefaultHTTPPropagator()),
+)
+
 // FlusherFunc defines a function used to Flush a Tracer
 type FlusherFunc func()
 
@@ -0,0 +1,296 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package trickster provides Trickster's proxy and caching engine as an http.Handler,
+// so that it can be embedded within other Go applications
+package trickster
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/registration"
+	"github.com/tricksterproxy/trickster/pkg/config"
+	th "github.com/tricksterproxy/trickster/pkg/proxy/handlers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/healthcheck"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight"
+	"github.com/tricksterproxy/trickster/pkg/routing"
+	"github.com/tricksterproxy/trickster/pkg/tracing"
+	tr "github.com/tricksterproxy/trickster/pkg/tracing/registration"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+// ErrNoConfig is returned when a Handler is requested without a configuration
+var ErrNoConfig = errors.New("no config provided")
+
+// Options are the optional dependencies of a Handler, which are provided by the embedding
+// application
+type Options struct {
+	// Logger is the logger of the Handler. When nil, a console logger at the configured log
+	// level is used
+	Logger *tl.Logger
+	// Registerer is the Prometheus registry with which the Handler's metrics are registered. Each
+	// Handler has its own metrics, so Handlers in the same process need separate registries. When
+	// nil, the metrics are not registered with any registry
+	Registerer prometheus.Registerer
+	// TracerProvider provides the tracers of the origins that are configured with a tracing
+	// config. When nil, the tracers are those of the exporters of the tracing configs, which are
+	// not installed as the OpenTelemetry global provider
+	TracerProvider trace.Provider
+}
+
+// NewHandler returns an http.Handler that serves Trickster's frontend routes for the provided
+// configuration, along with an io.Closer that releases the caches, tracers, health check
+// probers and refresh jobs used by the Handler. It does not bind any listeners or install any
+// signal handlers, which are left to the embedding application. The Handler's metrics and runtime
+// state, such as the health and rate limits of its origins, are its own, and are not shared with
+// any other Handler in the process. The options may be nil
+func NewHandler(conf *config.Config, opts *Options) (http.Handler, io.Closer, error) {
+	if conf == nil {
+		return nil, nil, ErrNoConfig
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = tl.ConsoleLogger(conf.Logging.LogLevel)
+	}
+	inst := instance.New()
+	if opts.Registerer != nil {
+		if err := inst.Metrics.Register(opts.Registerer); err != nil {
+			return nil, nil, err
+		}
+	}
+	inst.Attach(conf)
+	caches := make(map[string]cache.Cache)
+	for k, v := range conf.Caches {
+		caches[k] = registration.New(k, v, logger)
+	}
+	router, tracers, svc, err := newCheckedRouter(conf, inst, caches, caches,
+		opts.TracerProvider, logger)
+	if err != nil {
+		closeCaches(caches)
+		return nil, nil, err
+	}
+	inst.Configure(conf, logger)
+	svc.Start()
+	return router, &closer{caches: caches, tracers: tracers, services: svc, instance: inst}, nil
+}
+
+// NewCaches returns the caches described by the configuration, connected and ready for use. The
+// caches report to the metrics and cache event stream of the Instance, or of the Default Instance
+// when it is nil, which should be that of the router that uses them
+func NewCaches(conf *config.Config, inst *instance.Instance, logger *tl.Logger) map[string]cache.Cache {
+	instance.OrDefault(inst).Attach(conf)
+	caches := make(map[string]cache.Cache)
+	for k, v := range conf.Caches {
+		caches[k] = registration.NewCache(k, v, logger)
+	}
+	return caches
+}
+
+// NewRouter returns a new router that serves Trickster's frontend routes for the provided
+// configuration and caches, along with the tracers used by the routes and the Services of its
+// origins, which the caller starts once the router is in use and stops once it is not. The
+// router's metrics and runtime state are those of the Instance, or of the Default Instance when
+// it is nil
+func NewRouter(conf *config.Config, inst *instance.Instance, caches map[string]cache.Cache,
+	logger *tl.Logger) (*mux.Router, tracing.Tracers, *routing.Services, error) {
+	router, tracers, svc, _, err := newRouter(conf, instance.OrDefault(inst), caches, nil, logger)
+	return router, tracers, svc, err
+}
+
+// NewCheckedRouter is NewRouter for caches that may not yet be connected. Once the routes are
+// registered, it runs the startup pre-flight checks, which connect the pending caches and probe the
+// upstream health check of each origin. If a required component fails its check, the check's
+// *preflight.Error is returned; otherwise, the results are recorded for the Instance's Readiness
+// Handler
+func NewCheckedRouter(conf *config.Config, inst *instance.Instance,
+	caches, pending map[string]cache.Cache,
+	logger *tl.Logger) (*mux.Router, tracing.Tracers, *routing.Services, error) {
+	return newCheckedRouter(conf, instance.OrDefault(inst), caches, pending, nil, logger)
+}
+
+func newCheckedRouter(conf *config.Config, inst *instance.Instance,
+	caches, pending map[string]cache.Cache, tp trace.Provider,
+	logger *tl.Logger) (*mux.Router, tracing.Tracers, *routing.Services, error) {
+
+	router, tracers, svc, clients, err := newRouter(conf, inst, caches, tp, logger)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	checks := append(cacheChecks(pending, logger), originChecks(clients)...)
+	report := preflight.Run(checks,
+		time.Duration(conf.Main.PreflightTimeoutMS)*time.Millisecond, logger)
+	if err = report.Err(); err != nil {
+		for _, t := range tracers {
+			if t != nil && t.Flusher != nil {
+				t.Flusher()
+			}
+		}
+		return nil, nil, nil, err
+	}
+	inst.Readiness.Record(report)
+
+	return router, tracers, svc, nil
+}
+
+func newRouter(conf *config.Config, inst *instance.Instance, caches map[string]cache.Cache,
+	tp trace.Provider, logger *tl.Logger) (*mux.Router, tracing.Tracers, *routing.Services, origins.Origins, error) {
+
+	var tracers tracing.Tracers
+	var err error
+	if tp != nil {
+		tracers, err = tr.RegisterAllWithProvider(conf, tp)
+	} else {
+		tracers, err = tr.RegisterAll(conf, logger, false)
+	}
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc(conf.Main.PingHandlerPath, th.PingHandleFunc(conf)).Methods(http.MethodGet)
+	if conf.Main.VersionHandlerPath != "" {
+		router.HandleFunc(conf.Main.VersionHandlerPath,
+			th.VersionHandleFunc(conf)).Methods(http.MethodGet)
+	}
+	if conf.Main.ReadyHandlerPath != "" {
+		router.HandleFunc(conf.Main.ReadyHandlerPath,
+			inst.Readiness.ReadyHandleFunc).Methods(http.MethodGet)
+	}
+
+	clients, svc, err := routing.RegisterProxyRoutes(conf, inst, router, caches, tracers, logger, false)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// requests are sampled whether or not they match a route, since a candidate config may route
+	// requests that the current config does not
+	router.Use(inst.Sampler.Middleware)
+	router.NotFoundHandler = inst.Sampler.Middleware(router.NotFoundHandler)
+	router.MethodNotAllowedHandler = inst.Sampler.Middleware(router.MethodNotAllowedHandler)
+
+	return router, tracers, svc, clients, nil
+}
+
+// cacheChecks returns the pre-flight checks that connect the pending caches
+func cacheChecks(pending map[string]cache.Cache, logger *tl.Logger) []*preflight.Check {
+	names := make([]string, 0, len(pending))
+	for k := range pending {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	checks := make([]*preflight.Check, 0, len(names))
+	for _, k := range names {
+		c := pending[k]
+		checks = append(checks, &preflight.Check{
+			Component: "cache:" + k,
+			Policy:    c.Configuration().PreflightPolicy,
+			Run:       func(context.Context) error { return registration.Connect(c, logger) },
+		})
+	}
+	return checks
+}
+
+// originChecks returns the pre-flight checks that probe the upstream health check of each origin,
+// using the same Target as the background prober
+func originChecks(clients origins.Origins) []*preflight.Check {
+	names := make([]string, 0, len(clients))
+	for k := range clients {
+		if k != "frontend" {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	checks := make([]*preflight.Check, 0, len(names))
+	for _, k := range names {
+		client := clients[k]
+		hc, ok := client.(healthcheck.Checker)
+		if !ok || client.HTTPClient() == nil {
+			continue
+		}
+		o := client.Configuration()
+		t, err := healthcheck.NewOriginTarget(o, hc)
+		if err != nil || t == nil {
+			// invalid health check configs are rejected while registering routes,
+			// and origins with disabled health checks are not probed
+			continue
+		}
+		checks = append(checks, &preflight.Check{
+			Component: "origin:" + k,
+			Policy:    o.PreflightPolicy,
+			Run: func(ctx context.Context) error {
+				return t.ProbeContext(ctx, client.HTTPClient())
+			},
+		})
+	}
+	return checks
+}
+
+// closer releases the resources used by a Handler
+type closer struct {
+	caches   map[string]cache.Cache
+	tracers  tracing.Tracers
+	services *routing.Services
+	instance *instance.Instance
+	once     sync.Once
+}
+
+// Close stops the Handler's health check probers and refresh jobs, flushes its tracers, closes
+// its caches and its cache event stream. The probers and refresh jobs of any other Handler in the
+// process are unaffected
+func (c *closer) Close() error {
+	var err error
+	c.once.Do(func() {
+		c.services.Stop()
+		for _, t := range c.tracers {
+			if t != nil && t.Flusher != nil {
+				t.Flusher()
+			}
+		}
+		err = closeCaches(c.caches)
+		c.instance.Close()
+	})
+	return err
+}
+
+func closeCaches(caches map[string]cache.Cache) error {
+	var err error
+	for _, c := range caches {
+		if c == nil {
+			continue
+		}
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,312 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package trickster_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/preflight"
+	"github.com/tricksterproxy/trickster/pkg/trickster"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+func ExampleNewHandler() {
+
+	// an upstream origin server to be accelerated by Trickster
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello from the origin"))
+	}))
+	defer origin.Close()
+
+	conf, _, err := config.Load("trickster", "example",
+		[]string{"-origin-url", origin.URL, "-origin-type", "reverseproxycache"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	h, closer, err := trickster.NewHandler(conf, &trickster.Options{Logger: tl.ConsoleLogger("error")})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer closer.Close()
+
+	// mount the Trickster handler in the application's own server
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(ts.URL + "/hello")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		fmt.Println(string(b))
+	}
+
+	// Output:
+	// hello from the origin
+	// hello from the origin
+}
+
+func TestNewHandlerNoConfig(t *testing.T) {
+	if _, _, err := trickster.NewHandler(nil, nil); err != trickster.ErrNoConfig {
+		t.Errorf("expected %v got %v", trickster.ErrNoConfig, err)
+	}
+}
+
+func TestNewHandlerPreflight(t *testing.T) {
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer origin.Close()
+
+	// the unreachable origin's failed pre-flight check is only logged under the warn policy
+	conf, err := config.LoadDocument(fmt.Sprintf(`
+[origins]
+  [origins.prom1]
+  origin_type = 'prometheus'
+  origin_url = '%s'
+  preflight_policy = 'required'
+
+  [origins.prom2]
+  origin_type = 'prometheus'
+  origin_url = 'http://127.0.0.1:1'
+`, origin.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, closer, err := trickster.NewHandler(conf, &trickster.Options{Logger: tl.ConsoleLogger("error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/trickster/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	closer.Close()
+
+	// but aborts startup under the required policy
+	conf, _ = config.LoadDocument(`
+[origins]
+  [origins.prom2]
+  origin_type = 'prometheus'
+  origin_url = 'http://127.0.0.1:1'
+  preflight_policy = 'required'
+`)
+	_, _, err = trickster.NewHandler(conf, &trickster.Options{Logger: tl.ConsoleLogger("error")})
+	pe, ok := err.(*preflight.Error)
+	if !ok {
+		t.Fatalf("expected *preflight.Error got %v", err)
+	}
+	if pe.Component != "origin:prom2" {
+		t.Errorf("expected %s got %s", "origin:prom2", pe.Component)
+	}
+}
+
+func TestNewHandlerOptions(t *testing.T) {
+
+	conf, err := config.LoadDocument(`
+[origins]
+  [origins.test]
+  origin_type = 'reverseproxycache'
+  origin_url = 'http://127.0.0.1:1'
+  tracing_name = 'test'
+
+[tracing]
+  [tracing.test]
+  tracer_type = 'jaeger'
+  collector_url = 'http://127.0.0.1:1'
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the metrics are registered with the provided registry, and the origin's tracer is from the
+	// provided tracer provider rather than the configured jaeger exporter
+	reg := prometheus.NewRegistry()
+	_, closer, err := trickster.NewHandler(conf, &trickster.Options{
+		Logger:         tl.ConsoleLogger("error"),
+		Registerer:     reg,
+		TracerProvider: trace.NoopProvider{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	found := false
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() == "trickster_proxy_max_inflight_processing_bytes" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected trickster metrics in the provided registry")
+	}
+
+	// and are not registered with the default registry
+	mfs, _ = prometheus.DefaultGatherer.Gather()
+	for _, mf := range mfs {
+		if mf.GetName() == "trickster_proxy_max_inflight_processing_bytes" {
+			t.Error("unexpected trickster metrics in the default registry")
+		}
+	}
+}
+
+func TestNewHandlerClose(t *testing.T) {
+
+	// each handler's origin is probed at its own upstream, under the same origin name
+	newOrigin := func() (*httptest.Server, *int32) {
+		var probes int32
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&probes, 1)
+		})), &probes
+	}
+	origin1, probes1 := newOrigin()
+	defer origin1.Close()
+	origin2, probes2 := newOrigin()
+	defer origin2.Close()
+
+	newHandler := func(originURL string) io.Closer {
+		conf, err := config.LoadDocument(fmt.Sprintf(`
+[origins]
+  [origins.test]
+  origin_type = 'reverseproxycache'
+  origin_url = '%s'
+  health_check_interval_ms = 10
+`, originURL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, closer, err := trickster.NewHandler(conf,
+			&trickster.Options{Logger: tl.ConsoleLogger("error")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return closer
+	}
+
+	c1 := newHandler(origin1.URL)
+	c2 := newHandler(origin2.URL)
+	defer c2.Close()
+
+	// closing a handler stops only its own health check probers
+	c1.Close()
+	time.Sleep(50 * time.Millisecond)
+	n1, n2 := atomic.LoadInt32(probes1), atomic.LoadInt32(probes2)
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(probes1); n != n1 {
+		t.Errorf("expected the closed handler's prober to be stopped, got %d more probes", n-n1)
+	}
+	if n := atomic.LoadInt32(probes2); n == n2 {
+		t.Error("expected the other handler's prober to be running")
+	}
+}
+
+func TestNewHandlerIsolation(t *testing.T) {
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer origin.Close()
+
+	newHandler := func(reg prometheus.Registerer) (http.Handler, io.Closer, error) {
+		conf, err := config.LoadDocument(fmt.Sprintf(`
+[origins]
+  [origins.test]
+  origin_type = 'reverseproxycache'
+  origin_url = '%s'
+  is_default = true
+`, origin.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return trickster.NewHandler(conf,
+			&trickster.Options{Logger: tl.ConsoleLogger("error"), Registerer: reg})
+	}
+
+	// handlers with the same origin names have their own metrics, in their own registries
+	reg1, reg2 := prometheus.NewRegistry(), prometheus.NewRegistry()
+	h1, c1, err := newHandler(reg1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	_, c2, err := newHandler(reg2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+
+	// the metrics of two handlers can't be registered with the same registry
+	if _, c3, err := newHandler(reg1); err == nil {
+		c3.Close()
+		t.Error("expected an error registering a second handler's metrics with the registry")
+	}
+
+	ts := httptest.NewServer(h1)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	requests := func(reg *prometheus.Registry) float64 {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n float64
+		for _, mf := range mfs {
+			if mf.GetName() == "trickster_proxy_requests_total" {
+				for _, m := range mf.GetMetric() {
+					n += m.GetCounter().GetValue()
+				}
+			}
+		}
+		return n
+	}
+	if n := requests(reg1); n != 1 {
+		t.Errorf("expected %d requests got %v", 1, n)
+	}
+	if n := requests(reg2); n != 0 {
+		t.Errorf("expected %d requests got %v", 0, n)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package errors provides an error type that carries the context with which it is logged, so
+// that the context is not lost as the error is returned up the stack
+package errors
+
+import (
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// DetailedError wraps an error with the event and Pairs of the log event that describes it,
+// which are logged by Logger.LogError, merged with those of any DetailedErrors it wraps
+type DetailedError struct {
+	Event string
+	Pairs tl.Pairs
+	Err   error
+}
+
+// Wrap returns a DetailedError that wraps the error with the event and Pairs, or nil when the
+// error is nil
+func Wrap(err error, event string, pairs tl.Pairs) error {
+	if err == nil {
+		return nil
+	}
+	return &DetailedError{Event: event, Pairs: pairs, Err: err}
+}
+
+// Error returns the event, followed by the message of the wrapped error
+func (e *DetailedError) Error() string {
+	if e.Err == nil {
+		return e.Event
+	}
+	return e.Event + ": " + e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *DetailedError) Unwrap() error {
+	return e.Err
+}
+
+// LogDetail returns the event and Pairs with which the error is logged
+func (e *DetailedError) LogDetail() (string, tl.Pairs) {
+	return e.Event, e.Pairs
+}
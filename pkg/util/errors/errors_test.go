@@ -0,0 +1,54 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package errors
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestWrap(t *testing.T) {
+
+	if Wrap(nil, "test event", nil) != nil {
+		t.Error("expected nil error")
+	}
+
+	inner := Wrap(io.ErrUnexpectedEOF, "test inner event", tl.Pairs{"byteOffset": 12, "a": 1})
+	err := Wrap(inner, "test event", tl.Pairs{"cacheKey": "key", "a": 2})
+	if s := err.Error(); s != "test event: test inner event: unexpected EOF" {
+		t.Errorf("unexpected message %s", s)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("expected the wrapped error to be unwrapped")
+	}
+
+	// the event and merged Pairs of the chain are logged as a single event
+	l, rec := tl.NewTestLogger("info")
+	l.LogError(err)
+	entries := rec.Find("error", "test event")
+	if len(entries) != 1 {
+		t.Fatalf("expected %d got %d", 1, len(entries))
+	}
+	p := entries[0].Pairs
+	if p["cacheKey"] != "key" || p["byteOffset"] != 12 || p["a"] != 2 ||
+		p["detail"] != "unexpected EOF" || len(p) != 4 {
+		t.Errorf("unexpected pairs %v", p)
+	}
+}
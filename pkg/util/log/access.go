@@ -0,0 +1,259 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// accessLogBufferSize is the size of the buffer in which access log entries are accumulated
+// between writes to the access log file
+const accessLogBufferSize = 64 * 1024
+
+// combinedTimeFormat is the format of the timestamp of the Apache combined log format
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessEntry describes a proxied request, and is written to the access log
+type AccessEntry struct {
+	Time        time.Time
+	Method      string
+	Path        string
+	Query       string
+	Proto       string
+	Status      int
+	Bytes       int64
+	Duration    time.Duration
+	ClientIP    string
+	Referer     string
+	UserAgent   string
+	OriginName  string
+	CacheStatus string
+}
+
+// AccessLogger writes an entry for each proxied request to the access log file. Entries are
+// buffered, and the buffer is written to the file when it is full, periodically, and on Close
+type AccessLogger struct {
+	mtx      sync.Mutex
+	w        *bufio.Writer
+	closer   io.Closer
+	combined bool
+	redactor *redactor
+	line     []byte
+	stop     chan bool
+	closed   bool
+	// fields are the logfmt-encoded instance fields appended to each logfmt entry
+	fields []byte
+}
+
+// NewAccessLogger returns an AccessLogger for the access log of the config, or nil when the
+// config has no access log. The instance fields of the config are appended to logfmt entries,
+// since the combined format can't be extended
+func NewAccessLogger(conf *config.Config) *AccessLogger {
+	if conf == nil || conf.Logging == nil || conf.Logging.AccessLogFile == "" {
+		return nil
+	}
+	lc := conf.Logging
+	fw, _ := newFileWriter(lc.AccessLogFile, lc)
+	al := newAccessLogger(fw, lc.AccessLogFormat == "combined",
+		time.Duration(lc.AccessLogFlushIntervalMS)*time.Millisecond)
+	if lc.RedactKeys != nil {
+		al.redactor = newRedactor(lc.RedactKeys)
+	}
+	fields := instanceFields(conf)
+	for i := 0; i+1 < len(fields); i += 2 {
+		al.fields = append(al.fields, ' ')
+		al.fields = append(al.fields, fmt.Sprint(fields[i])...)
+		al.fields = appendLogfmtString(append(al.fields, '='), fmt.Sprint(fields[i+1]))
+	}
+	return al
+}
+
+func newAccessLogger(wc io.WriteCloser, combined bool, flushInterval time.Duration) *AccessLogger {
+	al := &AccessLogger{
+		w:        bufio.NewWriterSize(wc, accessLogBufferSize),
+		closer:   wc,
+		combined: combined,
+		line:     make([]byte, 0, 512),
+		redactor: newRedactor(d.DefaultLogRedactKeys()),
+		stop:     make(chan bool),
+	}
+	if flushInterval > 0 {
+		go al.flushEvery(flushInterval)
+	}
+	return al
+}
+
+// flushEvery writes the buffered entries to the access log file at the interval until stopped
+func (al *AccessLogger) flushEvery(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-al.stop:
+			return
+		case <-t.C:
+			al.Flush()
+		}
+	}
+}
+
+// Log writes the entry to the access log. It is safe to call on a nil AccessLogger, which
+// discards the entry
+func (al *AccessLogger) Log(e *AccessEntry) {
+	if al == nil || e == nil {
+		return
+	}
+	al.mtx.Lock()
+	defer al.mtx.Unlock()
+	if al.closed {
+		return
+	}
+	// the entry is copied, so that the caller's entry is not modified by redaction
+	ec := *e
+	ec.Query, _ = al.redactor.redactQuery(ec.Query)
+	if ec.Referer != "" {
+		ec.Referer, _ = al.redactor.redactString(ec.Referer)
+	}
+	if al.combined {
+		al.line = appendCombined(al.line[:0], &ec)
+	} else {
+		al.line = appendAccessLogfmt(al.line[:0], &ec, al.fields)
+	}
+	al.w.Write(al.line)
+}
+
+// Flush writes the buffered entries to the access log file
+func (al *AccessLogger) Flush() error {
+	if al == nil {
+		return nil
+	}
+	al.mtx.Lock()
+	defer al.mtx.Unlock()
+	return al.w.Flush()
+}
+
+// Close writes the buffered entries to the access log file and closes it. Entries logged
+// after Close are discarded
+func (al *AccessLogger) Close() error {
+	if al == nil {
+		return nil
+	}
+	al.mtx.Lock()
+	defer al.mtx.Unlock()
+	if al.closed {
+		return nil
+	}
+	al.closed = true
+	close(al.stop)
+	al.w.Flush()
+	return al.closer.Close()
+}
+
+// appendAccessLogfmt appends the entry in the logfmt format to the buffer, followed by the
+// encoded fields
+func appendAccessLogfmt(b []byte, e *AccessEntry, fields []byte) []byte {
+	b = append(b, "time="...)
+	b = e.Time.UTC().AppendFormat(b, time.RFC3339Nano)
+	b = appendLogfmtString(append(b, " method="...), e.Method)
+	b = appendLogfmtString(append(b, " path="...), e.Path)
+	b = appendLogfmtString(append(b, " query="...), e.Query)
+	b = strconv.AppendInt(append(b, " status="...), int64(e.Status), 10)
+	b = strconv.AppendInt(append(b, " bytes="...), e.Bytes, 10)
+	b = strconv.AppendFloat(append(b, " duration_ms="...),
+		float64(e.Duration)/float64(time.Millisecond), 'f', 3, 64)
+	b = appendLogfmtString(append(b, " client_ip="...), e.ClientIP)
+	b = appendLogfmtString(append(b, " origin="...), e.OriginName)
+	b = appendLogfmtString(append(b, " cache_status="...), e.CacheStatus)
+	b = append(b, fields...)
+	return append(b, '\n')
+}
+
+// appendLogfmtString appends the value to the buffer, quoted if it is empty or includes
+// characters that must be quoted in logfmt
+func appendLogfmtString(b []byte, s string) []byte {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r >= 0x7f
+	}) >= 0 {
+		return strconv.AppendQuote(b, s)
+	}
+	return append(b, s...)
+}
+
+// appendCombined appends the entry in the Apache combined log format to the buffer
+func appendCombined(b []byte, e *AccessEntry) []byte {
+	b = appendCombinedField(b, e.ClientIP)
+	b = append(b, " - - ["...)
+	b = e.Time.AppendFormat(b, combinedTimeFormat)
+	b = append(b, "] \""...)
+	b = appendCombinedString(b, e.Method)
+	b = append(b, ' ')
+	b = appendCombinedString(b, e.Path)
+	if e.Query != "" {
+		b = append(b, '?')
+		b = appendCombinedString(b, e.Query)
+	}
+	b = append(b, ' ')
+	b = appendCombinedString(b, e.Proto)
+	b = strconv.AppendInt(append(b, "\" "...), int64(e.Status), 10)
+	b = append(b, ' ')
+	if e.Bytes > 0 {
+		b = strconv.AppendInt(b, e.Bytes, 10)
+	} else {
+		b = append(b, '-')
+	}
+	b = append(b, " \""...)
+	b = appendCombinedField(b, e.Referer)
+	b = append(b, "\" \""...)
+	b = appendCombinedField(b, e.UserAgent)
+	return append(b, "\"\n"...)
+}
+
+// appendCombinedField appends the value to the buffer, or - when it is empty
+func appendCombinedField(b []byte, s string) []byte {
+	if s == "" {
+		return append(b, '-')
+	}
+	return appendCombinedString(b, s)
+}
+
+// appendCombinedString appends the value to the buffer, escaping quotes, backslashes and
+// control characters as Apache does
+func appendCombinedString(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c < ' ' || c == 0x7f:
+			b = append(b, '\\', 'x', hexDigits[c>>4], hexDigits[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+const hexDigits = "0123456789abcdef"
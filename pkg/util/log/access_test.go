@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+)
+
+type testWriteCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (wc *testWriteCloser) Close() error {
+	wc.closed = true
+	return nil
+}
+
+var testAccessEntry = &AccessEntry{
+	Time:        time.Date(2020, 10, 10, 13, 55, 36, 0, time.UTC),
+	Method:      "GET",
+	Path:        "/api/v1/query_range",
+	Query:       "query=up&step=15",
+	Proto:       "HTTP/1.1",
+	Status:      200,
+	Bytes:       2326,
+	Duration:    1500 * time.Microsecond,
+	ClientIP:    "127.0.0.1",
+	UserAgent:   `Grafana "7"`,
+	OriginName:  "prom1",
+	CacheStatus: "phit",
+}
+
+func TestAccessLoggerLogfmt(t *testing.T) {
+
+	wc := &testWriteCloser{}
+	al := newAccessLogger(wc, false, 0)
+	al.Log(testAccessEntry)
+	if wc.Len() != 0 {
+		t.Error("expected the entry to be buffered")
+	}
+	al.Flush()
+	expected := `time=2020-10-10T13:55:36Z method=GET path=/api/v1/query_range ` +
+		`query="query=up&step=15" status=200 bytes=2326 duration_ms=1.500 client_ip=127.0.0.1 ` +
+		`origin=prom1 cache_status=phit` + "\n"
+	if s := wc.String(); s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+
+	// empty values are quoted
+	wc.Reset()
+	al.Log(&AccessEntry{Method: "GET", Path: "/", Status: 404})
+	al.Flush()
+	if s := wc.String(); !strings.Contains(s, ` query="" status=404 `) ||
+		!strings.HasSuffix(s, ` cache_status=""`+"\n") {
+		t.Errorf("unexpected entry %s", s)
+	}
+
+	// entries logged after Close are discarded
+	wc.Reset()
+	al.Log(testAccessEntry)
+	al.Close()
+	al.Log(testAccessEntry)
+	al.Close()
+	if !wc.closed || strings.Count(wc.String(), "\n") != 1 {
+		t.Errorf("unexpected entries %s", wc.String())
+	}
+}
+
+func TestAccessLoggerCombined(t *testing.T) {
+
+	wc := &testWriteCloser{}
+	al := newAccessLogger(wc, true, 0)
+	al.Log(testAccessEntry)
+	al.Log(&AccessEntry{Time: testAccessEntry.Time, Method: "GET", Path: "/", Proto: "HTTP/1.1",
+		Status: 304})
+	al.Close()
+	expected := `127.0.0.1 - - [10/Oct/2020:13:55:36 +0000] "GET /api/v1/query_range?query=up&step=15 ` +
+		`HTTP/1.1" 200 2326 "-" "Grafana \"7\""` + "\n" +
+		`- - - [10/Oct/2020:13:55:36 +0000] "GET / HTTP/1.1" 304 - "-" "-"` + "\n"
+	if s := wc.String(); s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+}
+
+func TestNewAccessLogger(t *testing.T) {
+
+	conf := config.NewConfig()
+	if al := NewAccessLogger(conf); al != nil {
+		t.Error("expected the access log to be disabled by default")
+	}
+	// a nil AccessLogger discards entries
+	var al *AccessLogger
+	al.Log(testAccessEntry)
+	al.Flush()
+	al.Close()
+
+	dir, err := ioutil.TempDir("/tmp", "trickster-access-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf.Logging.AccessLogFile = filepath.Join(dir, "access.log")
+	conf.Logging.AccessLogFlushIntervalMS = 10
+	conf.Main.InstanceID = 2
+	al = NewAccessLogger(conf)
+	defer al.Close()
+	al.Log(testAccessEntry)
+
+	// the entry is written at the flush interval, with the instance fields
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if b, _ := ioutil.ReadFile(conf.Logging.AccessLogFile); len(b) > 0 {
+			if !strings.HasPrefix(string(b), "time=2020-10-10T13:55:36Z method=GET ") ||
+				!strings.HasSuffix(string(b), " instance_id=2\n") {
+				t.Errorf("unexpected entry %s", string(b))
+			}
+			return
+		}
+	}
+	t.Error("expected the entry to be written")
+}
+
+func BenchmarkAccessLogger(b *testing.B) {
+	al := newAccessLogger(&testWriteCloser{}, false, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		al.Log(testAccessEntry)
+		if i%1000 == 0 {
+			al.Flush()
+		}
+	}
+}
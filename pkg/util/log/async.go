@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// asyncWriter buffers encoded events in a ring of a bounded size, and writes them to its writer
+// from a dedicated goroutine, so that logging does not block on the writer. When the ring is
+// full, the oldest buffered event is dropped and counted, so that a write never blocks
+type asyncWriter struct {
+	w io.Writer
+	// dropped is the count of dropped events, which is that of the Logger
+	dropped *uint64
+
+	mtx  sync.Mutex
+	cond *sync.Cond
+	// ring holds the buffered events, n of which are buffered from head
+	ring [][]byte
+	head int
+	n    int
+	// writing is true while the goroutine writes events taken from the ring
+	writing bool
+	closed  bool
+	done    chan struct{}
+}
+
+// newAsyncWriter returns an asyncWriter that buffers up to size events for the writer, and
+// counts the events it drops in dropped
+func newAsyncWriter(w io.Writer, size int, dropped *uint64) *asyncWriter {
+	aw := &asyncWriter{w: w, dropped: dropped, ring: make([][]byte, size),
+		done: make(chan struct{})}
+	aw.cond = sync.NewCond(&aw.mtx)
+	go aw.run()
+	return aw
+}
+
+// Write buffers a copy of the encoded event. Once the asyncWriter is closed, events are written
+// directly to its writer
+func (aw *asyncWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	aw.mtx.Lock()
+	if aw.closed {
+		aw.mtx.Unlock()
+		return aw.w.Write(p)
+	}
+	if aw.n == len(aw.ring) {
+		// the oldest event is dropped to make room
+		aw.ring[aw.head] = nil
+		aw.head = (aw.head + 1) % len(aw.ring)
+		aw.n--
+		atomic.AddUint64(aw.dropped, 1)
+	}
+	aw.ring[(aw.head+aw.n)%len(aw.ring)] = b
+	aw.n++
+	aw.cond.Broadcast()
+	aw.mtx.Unlock()
+	return len(p), nil
+}
+
+// run writes the buffered events to the writer until the asyncWriter is closed and drained
+func (aw *asyncWriter) run() {
+	defer close(aw.done)
+	batch := make([][]byte, 0, len(aw.ring))
+	for {
+		aw.mtx.Lock()
+		for aw.n == 0 && !aw.closed {
+			aw.cond.Wait()
+		}
+		if aw.n == 0 {
+			aw.mtx.Unlock()
+			return
+		}
+		batch = batch[:0]
+		for ; aw.n > 0; aw.n-- {
+			batch = append(batch, aw.ring[aw.head])
+			aw.ring[aw.head] = nil
+			aw.head = (aw.head + 1) % len(aw.ring)
+		}
+		aw.writing = true
+		aw.mtx.Unlock()
+		for _, b := range batch {
+			aw.w.Write(b)
+		}
+		aw.mtx.Lock()
+		aw.writing = false
+		aw.cond.Broadcast()
+		aw.mtx.Unlock()
+	}
+}
+
+// Flush blocks until the events buffered before it was called are written
+func (aw *asyncWriter) Flush() {
+	aw.mtx.Lock()
+	defer aw.mtx.Unlock()
+	for (aw.n > 0 || aw.writing) && !aw.closed {
+		aw.cond.Wait()
+	}
+}
+
+// Close writes the buffered events, and stops the goroutine that writes them. The writer is not
+// closed, since it is owned by the Logger
+func (aw *asyncWriter) Close() error {
+	aw.mtx.Lock()
+	if aw.closed {
+		aw.mtx.Unlock()
+		return nil
+	}
+	aw.closed = true
+	aw.cond.Broadcast()
+	aw.mtx.Unlock()
+	<-aw.done
+	return nil
+}
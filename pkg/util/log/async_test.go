@@ -0,0 +1,166 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+)
+
+// blockingWriter records its writes, blocking on each until it is released
+type blockingWriter struct {
+	mtx     sync.Mutex
+	buf     bytes.Buffer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	select {
+	case w.started <- struct{}{}:
+	default:
+	}
+	<-w.release
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.buf.Write(p)
+}
+
+func TestAsyncWriter(t *testing.T) {
+
+	w := &blockingWriter{started: make(chan struct{}, 1), release: make(chan struct{})}
+	var dropped uint64
+	aw := newAsyncWriter(w, 2, &dropped)
+
+	// the first event is taken from the ring, and blocks its write
+	aw.Write([]byte("a\n"))
+	<-w.started
+	// the ring holds 2 events, so the oldest of the 3 buffered is dropped
+	for _, s := range []string{"b\n", "c\n", "d\n"} {
+		aw.Write([]byte(s))
+	}
+	if dropped != 1 {
+		t.Errorf("expected %d got %d", 1, dropped)
+	}
+
+	close(w.release)
+	aw.Flush()
+	if s := w.buf.String(); s != "a\nc\nd\n" {
+		t.Errorf("unexpected output %q", s)
+	}
+
+	// once closed, events are written directly
+	aw.Close()
+	aw.Write([]byte("e\n"))
+	if s := w.buf.String(); s != "a\nc\nd\ne\n" {
+		t.Errorf("unexpected output %q", s)
+	}
+	aw.Close()
+}
+
+func TestNewLogger_Async(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "trickster-async")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "out.log")
+	conf := config.NewConfig()
+	conf.Logging.LogFile = fileName
+	conf.Logging.LogAsyncBufferSize = 1000
+	l := New(conf)
+	for i := 0; i < 100; i++ {
+		l.Info("test entry", Pairs{"i": i})
+	}
+	l.With(Pairs{"originName": "default"}).Warn("test child entry", nil)
+	// the buffered events are written when the Logger is closed
+	l.Close()
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	if n := strings.Count(s, `event="test entry"`); n != 100 {
+		t.Errorf("expected %d got %d", 100, n)
+	}
+	if !strings.Contains(s, "i=99") || !strings.Contains(s, `event="test child entry"`) {
+		t.Errorf("unexpected output %s", s)
+	}
+	if n := l.Counts()[CountDropped]; n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+
+	// Console is buffered when there is no log file
+	buf := &bytes.Buffer{}
+	stdout = buf
+	defer func() { stdout = os.Stdout }()
+	conf = config.NewConfig()
+	conf.Logging.LogAsyncBufferSize = 10
+	l = New(conf)
+	l.Info("test entry", nil)
+	l.Close()
+	if !strings.Contains(buf.String(), `event="test entry"`) {
+		t.Errorf("unexpected output %s", buf.String())
+	}
+}
+
+func benchmarkLogging(b *testing.B, size int) {
+	dir, err := ioutil.TempDir("", "trickster-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := config.NewConfig()
+	conf.Logging.LogFile = filepath.Join(dir, "out.log")
+	conf.Logging.LogLevel = "debug"
+	conf.Logging.LogAsyncBufferSize = size
+	l := New(conf)
+	defer l.Close()
+
+	// RunParallel starts parallelism*GOMAXPROCS goroutines, which log concurrently
+	parallelism := 16 / runtime.GOMAXPROCS(0)
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	b.SetParallelism(parallelism)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Debug("test entry", Pairs{"testKey": "testVal", "code": 200})
+		}
+	})
+}
+
+func BenchmarkSyncLogging(b *testing.B) {
+	benchmarkLogging(b, 0)
+}
+
+func BenchmarkAsyncLogging(b *testing.B) {
+	benchmarkLogging(b, 8192)
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// logWrapped1 is a wrapper of the Logger, whose events report the caller of the wrapper
+func logWrapped1(l *Logger, event string) {
+	l.WithCallerSkip(1).Warn(event, nil)
+}
+
+// logWrapped2 is a wrapper of logWrapped2Inner, whose events report the caller of logWrapped2
+func logWrapped2(l *Logger, event string) {
+	logWrapped2Inner(l, event)
+}
+
+func logWrapped2Inner(l *Logger, event string) {
+	l.WithCallerSkip(2).Warn(event, nil)
+}
+
+// nextLine returns the caller of the test at the line following that of the call to nextLine
+func nextLine() string {
+	_, _, line, _ := runtime.Caller(1)
+	return "util/log/caller_test.go:" + strconv.Itoa(line+1)
+}
+
+// callerTest is an event and the caller it is expected to report
+type callerTest struct {
+	event  string
+	caller string
+}
+
+func TestCaller(t *testing.T) {
+
+	l, rec := NewTestLogger("info")
+
+	var tests []callerTest
+
+	caller := nextLine()
+	l.Warn("test direct", nil)
+	tests = append(tests, callerTest{"test direct", caller})
+
+	caller = nextLine()
+	l.WarnOnce("test-key", "test once", nil)
+	tests = append(tests, callerTest{"test once", caller})
+
+	caller = nextLine()
+	l.With(Pairs{"a": 1}).Warn("test child", nil)
+	tests = append(tests, callerTest{"test child", caller})
+
+	caller = nextLine()
+	logWrapped1(l, "test wrapped 1")
+	tests = append(tests, callerTest{"test wrapped 1", caller})
+
+	caller = nextLine()
+	logWrapped2(l, "test wrapped 2")
+	tests = append(tests, callerTest{"test wrapped 2", caller})
+
+	// the skip of a child is kept by its children, and added to by WithCallerSkip
+	wl := l.WithCallerSkip(1).With(Pairs{"a": 1})
+	caller = nextLine()
+	func() { wl.Warn("test wrapped child", nil) }()
+	tests = append(tests, callerTest{"test wrapped child", caller})
+
+	caller = nextLine()
+	func() { func() { wl.WithCallerSkip(1).Warn("test wrapped grandchild", nil) }() }()
+	tests = append(tests, callerTest{"test wrapped grandchild", caller})
+
+	for _, test := range tests {
+		t.Run(test.event, func(t *testing.T) {
+			entries := rec.Find("warn", test.event)
+			if len(entries) != 1 {
+				t.Fatalf("expected %d got %d", 1, len(entries))
+			}
+			if entries[0].Caller != test.caller {
+				t.Errorf("expected %s got %s", test.caller, entries[0].Caller)
+			}
+		})
+	}
+
+	// a skipped child is filtered by the level of its root
+	logWrapped1(l.WithCallerSkip(0), "test filtered")
+	l.WithCallerSkip(1).Debug("test filtered", nil)
+	if entries := rec.Find("", "test filtered"); len(entries) != 1 {
+		t.Errorf("expected %d got %d", 1, len(entries))
+	}
+}
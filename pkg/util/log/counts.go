@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import "sync/atomic"
+
+// CountOnceSuppressed is the key of the count of suppressed Once invocations in the map returned
+// by Counts
+const CountOnceSuppressed = "once_suppressed"
+
+// CountDedupSuppressed is the key of the count of repeated events suppressed within their
+// deduplication window in the map returned by Counts
+const CountDedupSuppressed = "dedup_suppressed"
+
+// CountDropped is the key of the count of events dropped because the buffer of asynchronous
+// logging, or of the network log target, was full in the map returned by Counts
+const CountDropped = "dropped"
+
+// countLevels are the levels by which events are counted, indexed by their rank
+var countLevels = [...]string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+// eventCounts are the counts of the events sent by a Logger, which are shared by its children
+type eventCounts struct {
+	events          [len(countLevels)]uint64
+	onceSuppressed  uint64
+	dedupSuppressed uint64
+	dropped         uint64
+}
+
+// count increments the count of events of the rank
+func (tl *Logger) count(rank int) {
+	atomic.AddUint64(&tl.shared().counts.events[rank], 1)
+}
+
+// countOnceSuppressed increments the count of Once invocations that were suppressed because an
+// event was already sent for their key
+func (tl *Logger) countOnceSuppressed() {
+	atomic.AddUint64(&tl.shared().counts.onceSuppressed, 1)
+}
+
+// countDedupSuppressed increments the count of repeated events that were suppressed within their
+// deduplication window
+func (tl *Logger) countDedupSuppressed() {
+	atomic.AddUint64(&tl.shared().counts.dedupSuppressed, 1)
+}
+
+// Counts returns the number of events sent to the Logger by level, including those of its child
+// Loggers, the number of suppressed Once invocations by the CountOnceSuppressed key, and the
+// number of suppressed repeated events by the CountDedupSuppressed key, and the number of events
+// dropped from a full buffer by the CountDropped key. Events that do not pass the level filter,
+// or that are suppressed as repeats, are not counted by level
+func (tl *Logger) Counts() map[string]uint64 {
+	c := &tl.shared().counts
+	m := make(map[string]uint64, len(countLevels)+3)
+	for i, lvl := range countLevels {
+		m[lvl] = atomic.LoadUint64(&c.events[i])
+	}
+	m[CountOnceSuppressed] = atomic.LoadUint64(&c.onceSuppressed)
+	m[CountDedupSuppressed] = atomic.LoadUint64(&c.dedupSuppressed)
+	m[CountDropped] = atomic.LoadUint64(&c.dropped)
+	return m
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+)
+
+func TestCounts(t *testing.T) {
+
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(ioutil.Discard, "logfmt")
+	l.SetLogLevel("debug")
+
+	l.Trace("test entry", nil)
+	l.Debug("test entry", nil)
+	l.Info("test entry", nil)
+	l.Warn("test entry", nil)
+	l.Error("test entry", nil)
+	l.Fatal(-1, "test entry", nil)
+
+	// events of child Loggers are counted by their root
+	c := l.With(Pairs{"testKey": "testVal"})
+	c.Info("test entry", nil)
+
+	// only the first of the Once invocations is sent, and traces are not sent at all
+	for i := 0; i < 3; i++ {
+		c.WarnOnce("test-key", "test entry", nil)
+		l.TraceOnce("test-key", "test entry", nil)
+	}
+
+	expected := map[string]uint64{"trace": 0, "debug": 1, "info": 2, "warn": 2, "error": 1,
+		"fatal": 1, CountOnceSuppressed: 2, CountDedupSuppressed: 0,
+		CountDropped: 0}
+	counts := c.Counts()
+	if len(counts) != len(expected) {
+		t.Errorf("expected %d got %d", len(expected), len(counts))
+	}
+	for k, v := range expected {
+		if counts[k] != v {
+			t.Errorf("expected %d %s events got %d", v, k, counts[k])
+		}
+	}
+}
+
+func TestCountsConcurrency(t *testing.T) {
+
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(ioutil.Discard, "logfmt")
+	l.SetLogLevel("info")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Error("test entry", nil)
+			l.ErrorOnce("test-key", "test entry", nil)
+		}()
+	}
+	wg.Wait()
+
+	if counts := l.Counts(); counts["error"] != 101 || counts[CountOnceSuppressed] != 99 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+}
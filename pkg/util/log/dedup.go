@@ -0,0 +1,161 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// dedupEntry is the deduplication window of an event that was sent to the Logger
+type dedupEntry struct {
+	// closes is the time at which the window closes, after which the event is sent again
+	closes time.Time
+	// repeated is the number of repeats of the event that were suppressed in the window
+	repeated int
+	// rank and keyvals are those of the event, which are kept once it is repeated, so that the
+	// summary of its repeats can be sent when the window closes
+	rank    int
+	keyvals []interface{}
+	// timer sends the summary of the repeats when the window closes, and is nil until the
+	// event is repeated
+	timer *time.Timer
+}
+
+// rankLoggers return the leveled loggers of the ranks, which add the level of their events
+var rankLoggers = [...]func(log.Logger) log.Logger{
+	func(l log.Logger) log.Logger { return withLevel(l, "trace") },
+	level.Debug, level.Info, level.Warn, level.Error,
+}
+
+// SetDedupWindow sets the window for which the repeats of an identical event, with the same
+// level, event and Pairs, are suppressed after it is sent. When the window closes, the event is
+// sent again with the number of suppressed repeats attached as a "repeated" Pair. A window of 0
+// or less disables deduplication, which is the default. Fatal events are never suppressed
+func (tl *Logger) SetDedupWindow(window time.Duration) {
+	tl = tl.shared()
+	tl.dedupMtx.Lock()
+	defer tl.dedupMtx.Unlock()
+	tl.dedupWindow = window
+	if window <= 0 {
+		// the windows of repeated events still close and send their summaries
+		tl.dedupEntries = nil
+	}
+}
+
+// deduplicate returns true if the event is a repeat of an identical event whose window is open,
+// in which case it is suppressed. Otherwise, a window is opened for the event. Windows of events
+// that are not repeated are forgotten once they close, and those of repeated events once their
+// summaries are sent
+func (tl *Logger) deduplicate(rank int, keyvals []interface{}) bool {
+	tl = tl.shared()
+	tl.dedupMtx.Lock()
+	defer tl.dedupMtx.Unlock()
+	if tl.dedupWindow <= 0 {
+		return false
+	}
+	now := time.Now()
+	if tl.dedupEntries == nil {
+		tl.dedupEntries = make(map[uint64]*dedupEntry)
+	}
+	if now.Sub(tl.dedupSwept) >= tl.dedupWindow {
+		for h, e := range tl.dedupEntries {
+			if e.timer == nil && !now.Before(e.closes) {
+				delete(tl.dedupEntries, h)
+			}
+		}
+		tl.dedupSwept = now
+	}
+	h := hashEvent(rank, keyvals)
+	if e, ok := tl.dedupEntries[h]; ok && now.Before(e.closes) {
+		e.repeated++
+		if e.timer == nil {
+			e.rank = rank
+			e.keyvals = keyvals
+			e.timer = time.AfterFunc(e.closes.Sub(now), func() { tl.closeDedupWindow(h, e) })
+		}
+		tl.countDedupSuppressed()
+		return true
+	}
+	tl.dedupEntries[h] = &dedupEntry{closes: now.Add(tl.dedupWindow)}
+	return false
+}
+
+// closeDedupWindow forgets the window of a repeated event, and sends the summary of its repeats
+func (tl *Logger) closeDedupWindow(h uint64, e *dedupEntry) {
+	tl.dedupMtx.Lock()
+	if tl.dedupEntries[h] == e {
+		delete(tl.dedupEntries, h)
+	}
+	tl.dedupMtx.Unlock()
+	tl.sendRepeated(e)
+}
+
+// flushDedup closes the open windows of repeated events, and sends the summaries of their repeats
+func (tl *Logger) flushDedup() {
+	tl.dedupMtx.Lock()
+	var flushed []*dedupEntry
+	for h, e := range tl.dedupEntries {
+		// a timer that can't be stopped has fired, and sends its summary itself
+		if e.timer != nil && e.timer.Stop() {
+			flushed = append(flushed, e)
+			delete(tl.dedupEntries, h)
+		}
+	}
+	tl.dedupMtx.Unlock()
+	for _, e := range flushed {
+		tl.sendRepeated(e)
+	}
+}
+
+// sendRepeated sends the event of a closed window with the number of its suppressed repeats
+// attached as a "repeated" Pair. The event already passed the level filter when it was sent
+func (tl *Logger) sendRepeated(e *dedupEntry) {
+	var event interface{}
+	detail := make(Pairs, len(e.keyvals)/2)
+	for i := 0; i+1 < len(e.keyvals); i += 2 {
+		k, _ := e.keyvals[i].(string)
+		if k == "event" {
+			event = e.keyvals[i+1]
+			continue
+		}
+		detail[k] = e.keyvals[i+1]
+	}
+	detail["repeated"] = e.repeated
+	tl.levelMtx.RLock()
+	logger := tl.unfiltered
+	tl.levelMtx.RUnlock()
+	if logger == nil {
+		return
+	}
+	tl.count(e.rank)
+	rankLoggers[e.rank](logger).Log(mapToArray(fmt.Sprint(event), detail)...)
+}
+
+// hashEvent returns the hash of the rank and keyvals of an event, which identifies its repeats
+func hashEvent(rank int, keyvals []interface{}) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d", rank)
+	for _, v := range keyvals {
+		fmt.Fprintf(h, "\x00%v", v)
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer that is safe to write from the goroutines of closing windows
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.String()
+}
+
+func TestDeduplicate(t *testing.T) {
+
+	buf := &syncBuffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "logfmt")
+	l.SetLogLevel("debug")
+	l.SetDedupWindow(100 * time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		l.Error("cache unavailable", Pairs{"cacheName": "default"})
+	}
+	// events that differ in their level or Pairs are not repeats
+	l.Warn("cache unavailable", Pairs{"cacheName": "default"})
+	l.With(Pairs{"cacheName": "other"}).Error("cache unavailable", nil)
+
+	s := buf.String()
+	if n := strings.Count(s, "\n"); n != 3 {
+		t.Errorf("expected %d got %d: %s", 3, n, s)
+	}
+	if c := l.Counts(); c["error"] != 2 || c[CountDedupSuppressed] != 9 {
+		t.Errorf("unexpected counts %v", c)
+	}
+
+	// the summary is sent when the window closes
+	time.Sleep(250 * time.Millisecond)
+	s = buf.String()
+	if n := strings.Count(s, "\n"); n != 4 ||
+		!strings.Contains(s, `level=error event="cache unavailable" cacheName=default repeated=9`) {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	// the next repeat opens a new window
+	l.Error("cache unavailable", Pairs{"cacheName": "default"})
+	if n := strings.Count(buf.String(), "\n"); n != 5 {
+		t.Errorf("expected %d got %d", 5, n)
+	}
+
+	// windows of events that are not repeated are forgotten once they close
+	l.dedupMtx.Lock()
+	l.dedupSwept = time.Time{}
+	l.dedupMtx.Unlock()
+	l.Debug("test entry", nil)
+	l.dedupMtx.Lock()
+	if n := len(l.dedupEntries); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	l.dedupMtx.Unlock()
+}
+
+func TestDeduplicateClose(t *testing.T) {
+
+	buf := &syncBuffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "logfmt")
+	l.SetLogLevel("trace")
+	l.SetDedupWindow(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		l.Trace("test entry", Pairs{"level": "ignored"})
+	}
+	// Fatal is never suppressed
+	for i := 0; i < 2; i++ {
+		l.Fatal(-1, "test fatal", nil)
+	}
+
+	// the open windows are closed when the Logger is closed
+	l.Close()
+	s := buf.String()
+	if n := strings.Count(s, "\n"); n != 4 ||
+		!strings.Contains(s, `level=trace event="test entry" repeated=2`) ||
+		strings.Count(s, "test fatal") != 2 {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	// a disabled window suppresses nothing
+	l.SetDedupWindow(0)
+	for i := 0; i < 2; i++ {
+		l.Trace("test entry", nil)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 6 {
+		t.Errorf("expected %d got %d", 6, n)
+	}
+}
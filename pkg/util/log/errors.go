@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"errors"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// detailer is an error that carries the event and Pairs with which it is logged, such as the
+// DetailedError of the util/errors package, which can't be referenced here without an import cycle
+type detailer interface {
+	error
+	LogDetail() (string, Pairs)
+	Unwrap() error
+}
+
+// LogError sends an "ERROR" event for the error to the Logger. When the error, or an error it
+// wraps, carries the detail of an event, the event is that of the outermost error, and the Pairs
+// of each error in the chain are merged, with those of outer errors taking precedence. The
+// "detail" is then the error wrapped by the innermost detailed error. Otherwise, the event is
+// the error's message
+func (tl *Logger) LogError(err error) {
+	if err == nil {
+		return
+	}
+	logger, ok := tl.filter(rankError)
+	if !ok {
+		return
+	}
+	event, detail := errorEvent(err)
+	keyvals := tl.array(event, detail)
+	if tl.deduplicate(rankError, keyvals) {
+		return
+	}
+	tl.count(rankError)
+	level.Error(logger).Log(keyvals...)
+}
+
+// errorEvent returns the event and Pairs of the error's chain of detailed errors
+func errorEvent(err error) (string, Pairs) {
+	var event string
+	var detail Pairs
+	var cause error
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		d, ok := e.(detailer)
+		if !ok {
+			continue
+		}
+		ev, pairs := d.LogDetail()
+		if detail == nil {
+			event = ev
+			detail = make(Pairs, len(pairs)+1)
+		}
+		for k, v := range pairs {
+			if _, ok := detail[k]; !ok {
+				detail[k] = v
+			}
+		}
+		cause = d.Unwrap()
+	}
+	if detail == nil {
+		return err.Error(), nil
+	}
+	if _, ok := detail["detail"]; !ok && cause != nil {
+		detail["detail"] = cause.Error()
+	}
+	return event, detail
+}
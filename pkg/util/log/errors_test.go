@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testDetailedError is a detailer like that of the util/errors package, which can't be
+// imported by these tests
+type testDetailedError struct {
+	event string
+	pairs Pairs
+	err   error
+}
+
+func (e *testDetailedError) Error() string              { return e.event + ": " + e.err.Error() }
+func (e *testDetailedError) Unwrap() error              { return e.err }
+func (e *testDetailedError) LogDetail() (string, Pairs) { return e.event, e.pairs }
+
+func TestLogError(t *testing.T) {
+
+	l, rec := NewTestLogger("info")
+
+	// an error without detail is logged as its message
+	l.LogError(errors.New("test error"))
+	l.LogError(nil)
+	if entries := rec.Entries(); len(entries) != 1 || entries[0].Event != "test error" {
+		t.Errorf("unexpected entries %v", entries)
+	}
+
+	// the detail of a chain of errors is merged, including through errors wrapped by fmt
+	rec.Reset()
+	inner := &testDetailedError{"test inner event", Pairs{"byteOffset": 12, "originName": "a"},
+		errors.New("test cause")}
+	outer := &testDetailedError{"test event", Pairs{"cacheKey": "key", "originName": "b"},
+		fmt.Errorf("wrapped: %w", inner)}
+	l.LogError(outer)
+	entries := rec.Find("error", "test event")
+	if len(entries) != 1 {
+		t.Fatalf("expected %d got %d", 1, len(entries))
+	}
+	p := entries[0].Pairs
+	if p["cacheKey"] != "key" || p["byteOffset"] != 12 || p["originName"] != "b" ||
+		p["detail"] != "test cause" || len(p) != 4 {
+		t.Errorf("unexpected pairs %v", p)
+	}
+	if !strings.HasPrefix(entries[0].Caller, "util/log/errors_test.go:") {
+		t.Errorf("unexpected caller %s", entries[0].Caller)
+	}
+
+	// errors are not logged below the level
+	rec.Reset()
+	l.SetLogLevel("none")
+	l.LogError(outer)
+	if len(rec.Entries()) != 0 {
+		t.Error("expected no entries")
+	}
+}
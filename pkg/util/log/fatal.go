@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// fatalHookTimeout bounds the time that Fatal waits for the fatal hooks to complete before exiting
+var fatalHookTimeout = 2 * time.Second
+
+// fatalHooks are the functions run by Fatal before it exits, to release resources that must be
+// cleaned up, such as flushing tracers and closing caches
+var fatalHooks struct {
+	sync.Mutex
+	hooks []func()
+	once  *sync.Once
+}
+
+// RegisterFatalHook registers a function that Fatal runs before it exits. The hooks run only once,
+// concurrently, and Fatal exits once they have completed, or after 2 seconds, whichever is first,
+// so that a hook that hangs does not prevent the exit. A hook that panics does not affect the
+// others, nor prevent the exit
+func RegisterFatalHook(f func()) {
+	if f == nil {
+		return
+	}
+	fatalHooks.Lock()
+	fatalHooks.hooks = append(fatalHooks.hooks, f)
+	fatalHooks.Unlock()
+}
+
+// runFatalHooks runs the fatal hooks, if they have not already run. A concurrent caller waits
+// for the hooks run by the first, so that neither exits until they have completed
+func runFatalHooks() {
+	fatalHooks.Lock()
+	if fatalHooks.once == nil {
+		fatalHooks.once = &sync.Once{}
+	}
+	once := fatalHooks.once
+	hooks := make([]func(), len(fatalHooks.hooks))
+	copy(hooks, fatalHooks.hooks)
+	fatalHooks.Unlock()
+
+	once.Do(func() {
+		wg := &sync.WaitGroup{}
+		for _, f := range hooks {
+			wg.Add(1)
+			go func(f func()) {
+				defer wg.Done()
+				defer func() { recover() }()
+				f()
+			}(f)
+		}
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(fatalHookTimeout):
+		}
+	})
+}
+
+// resetFatalHooks removes the fatal hooks, and permits them to run again
+func resetFatalHooks() {
+	fatalHooks.Lock()
+	fatalHooks.hooks = nil
+	fatalHooks.once = nil
+	fatalHooks.Unlock()
+}
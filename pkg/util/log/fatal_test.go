@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFatalHooks(t *testing.T) {
+
+	resetFatalHooks()
+	defer resetFatalHooks()
+	fatalHookTimeout = 100 * time.Millisecond
+	defer func() { fatalHookTimeout = 2 * time.Second }()
+
+	var calls int32
+	RegisterFatalHook(func() { atomic.AddInt32(&calls, 1) })
+	// a hook that panics does not affect the others
+	RegisterFatalHook(func() { panic("test panic") })
+	// a hook that hangs is bounded by the timeout
+	hang := make(chan struct{})
+	defer close(hang)
+	RegisterFatalHook(func() { <-hang })
+	RegisterFatalHook(nil)
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "logfmt")
+	l.SetLogLevel("info")
+	var closed int32
+	l.closer = testCloser(func() { atomic.AddInt32(&closed, 1) })
+
+	// concurrent Fatal calls run the hooks once, and both wait for them
+	start := time.Now()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.With(Pairs{"a": 1}).Fatal(-1, "test entry", nil)
+			if atomic.LoadInt32(&calls) != 1 {
+				t.Error("expected the hooks to have run before Fatal returned")
+			}
+		}()
+	}
+	wg.Wait()
+	if d := time.Since(start); d < fatalHookTimeout || d > time.Second {
+		t.Errorf("unexpected duration %s", d)
+	}
+
+	l.Fatal(-1, "test entry", nil)
+	if calls != 1 {
+		t.Errorf("expected %d got %d", 1, calls)
+	}
+	// the Logger is closed by each Fatal call, including those of its children
+	if closed != 3 {
+		t.Errorf("expected %d got %d", 3, closed)
+	}
+	if n := strings.Count(buf.String(), "level=fatal"); n != 3 {
+		t.Errorf("expected %d got %d", 3, n)
+	}
+}
@@ -0,0 +1,262 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// gelfChunkSize is the maximum size of a UDP datagram of a GELF message, beyond which the
+	// message is chunked, and which fits in the MTU of most networks
+	gelfChunkSize = 1420
+	// gelfChunkHeaderSize is the size of the header of each chunk of a GELF message
+	gelfChunkHeaderSize = 12
+	// gelfMaxChunks is the maximum number of chunks of a GELF message
+	gelfMaxChunks = 128
+	// gelfDialTimeout bounds the time taken to connect to the GELF endpoint
+	gelfDialTimeout = 5 * time.Second
+	// gelfRedialInterval is the minimum interval at which the GELF endpoint is reconnected
+	gelfRedialInterval = time.Second
+	// gelfErrorInterval is the minimum interval at which errors sending to the GELF endpoint are
+	// written to Console
+	gelfErrorInterval = time.Minute
+)
+
+// gelfChunkMagic are the magic bytes that begin each chunk of a GELF message
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// gelfLevels are the syslog severities of the GELF messages of events, by level
+var gelfLevels = map[string]int{"trace": 7, "debug": 7, "info": 6, "warn": 4, "error": 3,
+	"fatal": 2}
+
+// gelfFieldName matches the characters that are not permitted in GELF additional field names
+var gelfFieldName = regexp.MustCompile(`[^\w\.\-]`)
+
+// errGelfTooLarge is returned for a GELF message that exceeds the maximum number of chunks
+var errGelfTooLarge = errors.New("gelf message exceeds the maximum number of chunks")
+
+// errGelfNotConnected is returned while the GELF endpoint is waiting to be reconnected
+var errGelfNotConnected = errors.New("not connected to gelf endpoint")
+
+// dialGelf connects to the GELF endpoint, and may be replaced by tests
+var dialGelf = func(network, address string) (net.Conn, error) {
+	return net.DialTimeout(network, address, gelfDialTimeout)
+}
+
+// gelfLogger sends events to a GELF endpoint as GELF 1.1 messages. Events that can't be sent
+// are written to the fallback logger instead, along with a rate-limited error
+type gelfLogger struct {
+	mtx      sync.Mutex
+	network  string
+	address  string
+	host     string
+	conn     net.Conn
+	fallback log.Logger
+	// redial is the time from which the endpoint may be reconnected after a failure
+	redial time.Time
+	// nextError is the time from which an error may next be written to the fallback logger
+	nextError time.Time
+	// suppressed is the number of errors suppressed since the last written error
+	suppressed int
+}
+
+// newGelfLogger returns a gelfLogger that is connected to the GELF endpoint of the logging config
+func newGelfLogger(lc *config.LoggingConfig, fallback log.Logger) (*gelfLogger, error) {
+	conn, err := dialGelf(lc.GelfNetwork, lc.GelfAddress)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	return &gelfLogger{network: lc.GelfNetwork, address: lc.GelfAddress, host: host, conn: conn,
+		fallback: fallback}, nil
+}
+
+// Log sends the event to the GELF endpoint
+func (l *gelfLogger) Log(keyvals ...interface{}) error {
+	msg, err := encodeGelf(l.host, time.Now(), keyvals)
+	if err != nil {
+		return err
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if err = l.send(msg); err == nil {
+		return nil
+	}
+	// events are written to Console rather than lost
+	l.fallback.Log(keyvals...)
+	now := time.Now()
+	if now.Before(l.nextError) {
+		l.suppressed++
+		return nil
+	}
+	kvs := []interface{}{"time", eventValue(keyvals, "time"), "app", "trickster",
+		level.Key(), level.ErrorValue(), "event", "unable to send event to gelf endpoint, logging to stdout",
+		"detail", err.Error(), "gelfAddress", l.address, "gelfNetwork", l.network}
+	if l.suppressed > 0 {
+		kvs = append(kvs, "suppressed", l.suppressed)
+	}
+	l.fallback.Log(kvs...)
+	l.nextError = now.Add(gelfErrorInterval)
+	l.suppressed = 0
+	return nil
+}
+
+// send writes the message to the GELF endpoint, reconnecting to it when a previous write failed.
+// Messages sent over UDP are chunked when they exceed gelfChunkSize, and those sent over TCP are
+// terminated by a null byte. The caller must hold the mutex
+func (l *gelfLogger) send(msg []byte) error {
+	if l.conn == nil {
+		if time.Now().Before(l.redial) {
+			return errGelfNotConnected
+		}
+		conn, err := dialGelf(l.network, l.address)
+		if err != nil {
+			l.redial = time.Now().Add(gelfRedialInterval)
+			return err
+		}
+		l.conn = conn
+	}
+	var err error
+	if l.network == "tcp" {
+		_, err = l.conn.Write(append(msg, 0))
+	} else {
+		err = writeGelfChunks(l.conn, msg)
+	}
+	if err != nil && err != errGelfTooLarge {
+		l.conn.Close()
+		l.conn = nil
+		l.redial = time.Now().Add(gelfRedialInterval)
+	}
+	return err
+}
+
+// writeGelfChunks writes the message as a single datagram when it fits in gelfChunkSize, and
+// otherwise as a sequence of chunks that share a random message ID
+func writeGelfChunks(w net.Conn, msg []byte) error {
+	if len(msg) <= gelfChunkSize {
+		_, err := w.Write(msg)
+		return err
+	}
+	const size = gelfChunkSize - gelfChunkHeaderSize
+	count := (len(msg) + size - 1) / size
+	if count > gelfMaxChunks {
+		return errGelfTooLarge
+	}
+	chunk := make([]byte, gelfChunkHeaderSize, gelfChunkSize)
+	copy(chunk, gelfChunkMagic)
+	if _, err := rand.Read(chunk[2:10]); err != nil {
+		return err
+	}
+	chunk[11] = byte(count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk[10] = byte(i)
+		if _, err := w.Write(append(chunk[:gelfChunkHeaderSize], msg[i*size:end]...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection to the GELF endpoint
+func (l *gelfLogger) Close() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	err := l.conn.Close()
+	l.conn = nil
+	// a closed logger is not reconnected
+	l.redial = time.Now().Add(100 * 365 * 24 * time.Hour)
+	return err
+}
+
+// encodeGelf returns the GELF 1.1 message of the event with the keyvals. The event is the short
+// message, the level is mapped to its syslog severity, and the remaining keyvals, other than the
+// time, are additional fields, which are prefixed with an underscore
+func encodeGelf(host string, ts time.Time, keyvals []interface{}) ([]byte, error) {
+	m := make(map[string]interface{}, len(keyvals)/2+4)
+	m["version"] = "1.1"
+	m["host"] = host
+	m["timestamp"] = float64(ts.UnixNano()/int64(time.Millisecond)) / 1000
+	m["level"] = gelfLevels["info"]
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		k := fmt.Sprint(keyvals[i])
+		v := keyvals[i+1]
+		switch k {
+		case "time":
+			continue
+		case "event":
+			m["short_message"] = fmt.Sprint(v)
+			continue
+		case "level":
+			if sev, ok := gelfLevels[fmt.Sprint(v)]; ok {
+				m["level"] = sev
+			}
+			continue
+		case "id":
+			// _id is reserved by GELF
+			k = "id_"
+		}
+		m["_"+gelfFieldName.ReplaceAllString(k, "_")] = gelfValue(v)
+	}
+	if _, ok := m["short_message"]; !ok {
+		m["short_message"] = "-"
+	}
+	return json.Marshal(m)
+}
+
+// gelfValue returns the value of a GELF additional field, which is either a number or a string
+func gelfValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return t
+	case time.Duration:
+		return t.String()
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// eventValue returns the value of the key in the keyvals of an event, or nil when it is absent
+func eventValue(keyvals []interface{}, key string) interface{} {
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if fmt.Sprint(keyvals[i]) == key {
+			return keyvals[i+1]
+		}
+	}
+	return nil
+}
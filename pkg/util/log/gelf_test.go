@@ -0,0 +1,218 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+)
+
+// testGelfServer receives GELF messages over UDP, reassembling chunked messages, or over TCP,
+// and decodes them
+type testGelfServer struct {
+	addr     string
+	messages chan map[string]interface{}
+	close    func()
+}
+
+func newTestGelfServer(t *testing.T, network string) *testGelfServer {
+	s := &testGelfServer{messages: make(chan map[string]interface{}, 100)}
+	decode := func(b []byte) {
+		m := make(map[string]interface{})
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Errorf("unable to decode gelf message: %v", err)
+			return
+		}
+		s.messages <- m
+	}
+	if network == "tcp" {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.addr = ln.Addr().String()
+		s.close = func() { ln.Close() }
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					r := bufio.NewReader(conn)
+					for {
+						b, err := r.ReadBytes(0)
+						if err != nil {
+							return
+						}
+						decode(b[:len(b)-1])
+					}
+				}()
+			}
+		}()
+		return s
+	}
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.addr = pc.LocalAddr().String()
+	s.close = func() { pc.Close() }
+	go func() {
+		chunks := make(map[string][][]byte)
+		buf := make([]byte, 65536)
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			b := append([]byte(nil), buf[:n]...)
+			if !bytes.HasPrefix(b, gelfChunkMagic) {
+				decode(b)
+				continue
+			}
+			if n > gelfChunkSize {
+				t.Errorf("chunk of %d bytes exceeds %d", n, gelfChunkSize)
+			}
+			id, seq, count := string(b[2:10]), int(b[10]), int(b[11])
+			if chunks[id] == nil {
+				chunks[id] = make([][]byte, count)
+			}
+			chunks[id][seq] = b[gelfChunkHeaderSize:]
+			msg := []byte{}
+			for _, c := range chunks[id] {
+				if c == nil {
+					msg = nil
+					break
+				}
+				msg = append(msg, c...)
+			}
+			if msg != nil {
+				delete(chunks, id)
+				decode(msg)
+			}
+		}
+	}()
+	return s
+}
+
+func (s *testGelfServer) receive(t *testing.T) map[string]interface{} {
+	select {
+	case m := <-s.messages:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for gelf message")
+	}
+	return nil
+}
+
+func TestGelfLogger(t *testing.T) {
+
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			s := newTestGelfServer(t, network)
+			defer s.close()
+
+			conf := config.NewConfig()
+			conf.Logging.LogFormat = "gelf"
+			conf.Logging.GelfNetwork = network
+			conf.Logging.GelfAddress = s.addr
+			conf.Logging.LogLevel = "debug"
+			l := New(conf)
+			defer l.Close()
+
+			l.Warn("test entry", Pairs{"testKey": "testVal", "code": 200, "id": "abc",
+				"bad key": "x"})
+			m := s.receive(t)
+			host, _ := os.Hostname()
+			if m["version"] != "1.1" || m["host"] != host || m["short_message"] != "test entry" ||
+				m["level"] != float64(4) || m["_testKey"] != "testVal" || m["_code"] != float64(200) ||
+				m["_id_"] != "abc" || m["_bad_key"] != "x" || m["_app"] != "trickster" {
+				t.Errorf("unexpected message %v", m)
+			}
+			if _, ok := m["timestamp"].(float64); !ok {
+				t.Errorf("unexpected timestamp %v", m["timestamp"])
+			}
+			if _, ok := m["_time"]; ok {
+				t.Errorf("unexpected time %v", m["_time"])
+			}
+
+			// messages that exceed the chunk size are chunked over UDP
+			large := strings.Repeat("x", 5*gelfChunkSize)
+			l.Debug("test large entry", Pairs{"large": large})
+			m = s.receive(t)
+			if m["short_message"] != "test large entry" || m["level"] != float64(7) ||
+				m["_large"] != large {
+				t.Errorf("unexpected message %v", m["short_message"])
+			}
+		})
+	}
+}
+
+func TestGelfLoggerFallback(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	stdout = buf
+	defer func() { stdout = os.Stdout }()
+
+	// the GELF endpoint is unreachable at startup
+	dial := dialGelf
+	defer func() { dialGelf = dial }()
+	dialGelf = func(network, address string) (net.Conn, error) {
+		return nil, errors.New("test dial error")
+	}
+	conf := config.NewConfig()
+	conf.Logging.LogFormat = "gelf"
+	l := New(conf)
+	l.Info("test entry", nil)
+	if s := buf.String(); !strings.Contains(s, "unable to connect to gelf endpoint, logging to stdout") ||
+		!strings.Contains(s, `event="test entry"`) {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	// events that can't be sent are written to Console, with a rate-limited error
+	buf.Reset()
+	client, server := net.Pipe()
+	server.Close()
+	gl := &gelfLogger{network: "tcp", address: "127.0.0.1:12201", conn: client,
+		fallback: newConsoleEncoder("logfmt", false)}
+	for i := 0; i < 3; i++ {
+		gl.Log("time", "now", "level", "info", "event", "test entry")
+	}
+	s := buf.String()
+	if strings.Count(s, `event="test entry"`) != 3 ||
+		strings.Count(s, "unable to send event to gelf endpoint, logging to stdout") != 1 {
+		t.Errorf("unexpected output %s", s)
+	}
+	if gl.suppressed != 2 {
+		t.Errorf("expected %d got %d", 2, gl.suppressed)
+	}
+
+	// messages exceeding the maximum chunks are not sent
+	if err := writeGelfChunks(nil, make([]byte, gelfChunkSize*gelfMaxChunks)); err != errGelfTooLarge {
+		t.Errorf("expected %v got %v", errGelfTooLarge, err)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-kit/kit/log"
+)
+
+// localTimeFormat is the format of the local timestamp field of log events
+const localTimeFormat = "2006-01-02 15:04:05.000 MST"
+
+// translationsCheckInterval is the minimum interval at which the translations file is checked
+// for changes
+const translationsCheckInterval = time.Second
+
+// localeLogger wraps a logger to add human-readable fields to each event: a timestamp in the
+// configured time zone, and display fields holding the translations of the values of fields
+// listed in the translations. The event's own fields are unchanged
+type localeLogger struct {
+	log.Logger
+	location     *time.Location
+	timeField    string
+	suffix       string
+	translations *translations
+}
+
+// newLocaleLogger returns the logger wrapped to add the human-readable fields of the locale
+// config, or the logger itself when the config adds no fields. It also returns any error
+// loading the translations file, in which case the values are not translated until the file
+// is successfully reloaded
+func newLocaleLogger(l log.Logger, lc *config.LogLocaleConfig) (log.Logger, error) {
+	if lc == nil || (lc.Timezone == "" && lc.TranslationsFile == "") {
+		return l, nil
+	}
+	ll := &localeLogger{Logger: l, timeField: lc.TimeField, suffix: lc.DisplaySuffix}
+	if lc.Timezone != "" {
+		loc, err := time.LoadLocation(lc.Timezone)
+		if err != nil {
+			return l, err
+		}
+		ll.location = loc
+	}
+	var err error
+	if lc.TranslationsFile != "" {
+		ll.translations = &translations{path: lc.TranslationsFile}
+		err = ll.translations.load()
+	}
+	return ll, err
+}
+
+// Log adds the human-readable fields to the keyvals, and logs them
+func (l *localeLogger) Log(keyvals ...interface{}) error {
+	var table map[string]map[string]string
+	if l.translations != nil {
+		table = l.translations.get()
+	}
+	kvs := make([]interface{}, len(keyvals), len(keyvals)+2+len(table)*2)
+	copy(kvs, keyvals)
+	if l.location != nil {
+		kvs = append(kvs, l.timeField, time.Now().In(l.location).Format(localTimeFormat))
+	}
+	if len(table) > 0 {
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			k, ok := keyvals[i].(string)
+			if !ok {
+				continue
+			}
+			values, ok := table[k]
+			if !ok {
+				continue
+			}
+			// values without a translation are displayed as they are
+			v := fmt.Sprint(keyvals[i+1])
+			if t, ok := values[v]; ok {
+				v = t
+			}
+			kvs = append(kvs, k+l.suffix, v)
+		}
+	}
+	return l.Logger.Log(kvs...)
+}
+
+// translations are the value translations of a translations file, which are reloaded when the
+// file is modified
+type translations struct {
+	path    string
+	table   map[string]map[string]string
+	modTime time.Time
+	checked time.Time
+	mtx     sync.Mutex
+}
+
+// get returns the current value translations, reloading the file when it has been modified
+// since it was last loaded
+func (t *translations) get() map[string]map[string]string {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if time.Since(t.checked) >= translationsCheckInterval {
+		t.checked = time.Now()
+		if fi, err := os.Stat(t.path); err == nil && !fi.ModTime().Equal(t.modTime) {
+			// a file that fails to load leaves the previous translations in place
+			t.reload()
+		}
+	}
+	return t.table
+}
+
+// load loads the translations file
+func (t *translations) load() error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.checked = time.Now()
+	return t.reload()
+}
+
+func (t *translations) reload() error {
+	fi, err := os.Stat(t.path)
+	if err != nil {
+		return err
+	}
+	table, err := loadTranslations(t.path)
+	if err != nil {
+		return err
+	}
+	t.table = table
+	t.modTime = fi.ModTime()
+	return nil
+}
+
+// loadTranslations loads a translations file, which is decoded as JSON when its name has the
+// .json extension, and otherwise as TOML. The file maps field names to tables of the display
+// values of the field's values
+func loadTranslations(path string) (map[string]map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	table := make(map[string]map[string]string)
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		err = json.Unmarshal(b, &table)
+	} else {
+		_, err = toml.Decode(string(b), &table)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
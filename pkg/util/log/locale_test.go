@@ -0,0 +1,216 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+
+	"github.com/go-kit/kit/log"
+)
+
+func testLogger(enc log.Logger) *Logger {
+	l := NoopLogger()
+	ts, _ := newTimestamp("", false)
+	l.baseLogger = withContext(enc, ts, 0)
+	l.SetLogLevel("info")
+	return l
+}
+
+func testEvent(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("%s: %s", err, buf.String())
+	}
+	buf.Reset()
+	return m
+}
+
+// testModify writes the file with a modification time later than its previous one, and expires
+// the check interval of the translations so that the change is seen by the next event
+func testModify(t *testing.T, tr *translations, data string, mt time.Time) {
+	if err := ioutil.WriteFile(tr.path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(tr.path, mt, mt)
+	tr.mtx.Lock()
+	tr.checked = time.Time{}
+	tr.mtx.Unlock()
+}
+
+func TestLocaleLogger(t *testing.T) {
+
+	dir, err := ioutil.TempDir("/tmp", "trickster-log-locale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "translations.toml")
+	err = ioutil.WriteFile(path, []byte("[cacheStatus]\nhit = '命中'\nkmiss = '键未命中'\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lc := config.NewConfig().Logging.LogLocale
+	lc.Timezone = "Asia/Shanghai"
+	lc.TranslationsFile = path
+
+	buf := &bytes.Buffer{}
+	enc, err := newLocaleLogger(newEncoder(buf, "json"), &lc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := enc.(*localeLogger).translations
+	l := testLogger(enc)
+
+	l.Info("test entry", Pairs{"cacheStatus": "hit", "originName": "prom1"})
+	m := testEvent(t, buf)
+	// the canonical fields are unchanged
+	if m["cacheStatus"] != "hit" || m["originName"] != "prom1" || m["event"] != "test entry" {
+		t.Errorf("unexpected event %v", m)
+	}
+	if m["cacheStatus_display"] != "命中" {
+		t.Errorf("expected %s got %v", "命中", m["cacheStatus_display"])
+	}
+	if _, ok := m["originName_display"]; ok {
+		t.Errorf("unexpected display field in %v", m)
+	}
+	if v, ok := m["time_local"].(string); !ok || !strings.HasSuffix(v, " CST") {
+		t.Errorf("unexpected local time in %v", m)
+	}
+	if c, ok := m["caller"].(string); !ok || !strings.HasPrefix(c, "util/log/locale_test.go:") {
+		t.Errorf("expected caller in %v", m)
+	}
+
+	// values without a translation are displayed as they are
+	l.Info("test entry", Pairs{"cacheStatus": "phit"})
+	if m = testEvent(t, buf); m["cacheStatus_display"] != "phit" {
+		t.Errorf("expected %s got %v", "phit", m["cacheStatus_display"])
+	}
+
+	// the translations file is reloaded when it changes
+	mt := time.Now().Add(time.Minute)
+	testModify(t, tr, "[cacheStatus]\nhit = 'HIT'\n", mt)
+	l.Info("test entry", Pairs{"cacheStatus": "hit"})
+	if m = testEvent(t, buf); m["cacheStatus_display"] != "HIT" {
+		t.Errorf("expected %s got %v", "HIT", m["cacheStatus_display"])
+	}
+
+	// and a file that fails to load leaves the previous translations in place
+	testModify(t, tr, "[cacheStatus", mt.Add(time.Minute))
+	l.Info("test entry", Pairs{"cacheStatus": "hit"})
+	if m = testEvent(t, buf); m["cacheStatus_display"] != "HIT" {
+		t.Errorf("expected %s got %v", "HIT", m["cacheStatus_display"])
+	}
+}
+
+func TestLocaleLoggerJSONTranslations(t *testing.T) {
+
+	dir, err := ioutil.TempDir("/tmp", "trickster-log-locale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "translations.json")
+	err = ioutil.WriteFile(path, []byte(`{"errorClass":{"timeout":"超时"}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lc := config.NewConfig().Logging.LogLocale
+	lc.TranslationsFile = path
+	lc.DisplaySuffix = "_zh"
+
+	buf := &bytes.Buffer{}
+	enc, err := newLocaleLogger(newEncoder(buf, "json"), &lc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLogger(enc).Warn("test entry", Pairs{"errorClass": "timeout"})
+	m := testEvent(t, buf)
+	if m["errorClass"] != "timeout" || m["errorClass_zh"] != "超时" {
+		t.Errorf("unexpected event %v", m)
+	}
+	// no local time is added without a timezone
+	if _, ok := m["time_local"]; ok {
+		t.Errorf("unexpected local time in %v", m)
+	}
+}
+
+func TestNewLocaleLogger(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	enc := newEncoder(buf, "logfmt")
+
+	// the logger is not wrapped when the locale adds no fields
+	if l, err := newLocaleLogger(enc, &config.LogLocaleConfig{}); err != nil || l != enc {
+		t.Error("expected the logger to be unwrapped")
+	}
+	if l, err := newLocaleLogger(enc, nil); err != nil || l != enc {
+		t.Error("expected the logger to be unwrapped")
+	}
+
+	if _, err := newLocaleLogger(enc, &config.LogLocaleConfig{Timezone: "Nowhere/Special"}); err == nil {
+		t.Error("expected error for invalid timezone")
+	}
+
+	// a translations file that fails to load is reported, and values are displayed as they are
+	lc := &config.LogLocaleConfig{TranslationsFile: "/nonexistent/translations.toml",
+		DisplaySuffix: "_display"}
+	l, err := newLocaleLogger(enc, lc)
+	if err == nil {
+		t.Error("expected error for missing translations file")
+	}
+	l.Log("cacheStatus", "hit")
+	if s := buf.String(); s != "cacheStatus=hit\n" {
+		t.Errorf("unexpected output %s", s)
+	}
+}
+
+func TestLoggerLocale(t *testing.T) {
+
+	fileName := "out.locale.log"
+	conf := config.NewConfig()
+	conf.Logging.LogFile = fileName
+	conf.Logging.LogFormat = "json"
+	conf.Logging.LogLocale.TranslationsFile = "/nonexistent/translations.toml"
+	l := New(conf)
+	defer os.Remove(fileName)
+	l.Info("test entry", Pairs{"cacheStatus": "hit"})
+	l.Close()
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the failure to load the translations file is logged, and the event has no display fields
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "translations file") ||
+		strings.Contains(lines[1], "_display") {
+		t.Errorf("unexpected log %s", string(b))
+	}
+}
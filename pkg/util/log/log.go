@@ -18,14 +18,20 @@
 package log
 
 import (
+	"container/list"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/config"
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -35,15 +41,75 @@ import (
 
 // Logger is a container for the underlying log provider
 type Logger struct {
+	// counts are the counts of events sent to the Logger, which are first in the struct so that
+	// they are 64-bit aligned on 32-bit platforms
+	counts eventCounts
+
 	baseLogger log.Logger // the logger prior to leveling, used to relevel in config reload
 	logger     log.Logger // the logger after leveling, which is used by importing packages
 	closer     io.Closer
 	level      string
-
-	onceMutex      *sync.Mutex
-	onceRanEntries map[string]bool
+	// rank is the minimum rank of the events that pass the filter of the level, which all level
+	// methods consult, since the leveled logger can't filter trace and fatal events
+	rank int
+	// async are the writers that buffer events for the closer's writers, which are closed first
+	async []*asyncWriter
+	// unfiltered is the logger after leveling that allows all levels, which is used for events
+	// whose level is filtered by a level override
+	unfiltered log.Logger
+	// overrides are the levels of subsystems that override the level, and are nil when none are set
+	overrides *levelOverrides
+
+	// levelMtx guards the level, the leveled loggers and the level overrides, which are replaced
+	// while events are logged
+	levelMtx sync.RWMutex
+
+	// onceMutex guards the once entries, which are ordered by the time they ran, oldest last, so
+	// that they are evicted oldest first when a bound is configured
+	onceMutex      sync.Mutex
+	onceRanEntries map[string]*list.Element
+	onceOrder      *list.List
+	onceMaxEntries int
+	onceTTL        time.Duration
+
+	// rateLimitMtx guards the rate-limited keys, which expire once they are idle
+	rateLimitMtx     sync.Mutex
+	rateLimitEntries map[string]*rateLimitEntry
+	rateLimitSwept   time.Time
+
+	// dedupMtx guards the deduplication windows of events, which are forgotten once they close
+	dedupMtx     sync.Mutex
+	dedupWindow  time.Duration
+	dedupEntries map[uint64]*dedupEntry
+	dedupSwept   time.Time
+
+	// redactor replaces the values of sensitive keys, headers and URL query parameters
+	redactor *redactor
+
+	// root is the Logger whose writer, level and once entries are shared by this child Logger,
+	// and is nil when this Logger is not a child
+	root *Logger
+	// defaults are merged into the detail of every event sent to this child Logger
+	defaults Pairs
+	// subsystem is the subsystem of the events sent to this child Logger, from its defaults
+	subsystem string
+	// callerSkip is the number of frames of wrappers above the log package that are skipped to
+	// find the caller of the events sent to this child Logger
+	callerSkip int
+	// skipped is the logger that allows all levels, whose caller skips the frames of callerSkip,
+	// and is nil when callerSkip is 0
+	skipped log.Logger
+	// context returns the root's logger prior to leveling, with its caller skipping the frames,
+	// so that children can skip the frames of wrappers. It is nil when the root has no context
+	context func(skip int) log.Logger
 }
 
+// stdout is the Console writer, which tests may replace
+var stdout io.Writer = os.Stdout
+
+// nopLogger discards all log events
+var nopLogger = log.NewNopLogger()
+
 func mapToArray(event string, detail Pairs) []interface{} {
 	a := make([]interface{}, (len(detail)*2)+2)
 	var i int
@@ -52,7 +118,6 @@ func mapToArray(event string, detail Pairs) []interface{} {
 	if level, ok := detail["level"]; ok {
 		a[0] = "level"
 		a[1] = level
-		delete(detail, "level")
 		i += 2
 	}
 
@@ -61,46 +126,362 @@ func mapToArray(event string, detail Pairs) []interface{} {
 	a[i+1] = event
 	i += 2
 
-	for k, v := range detail {
+	// the remaining Pairs are sorted by key, so that the same event always renders identically.
+	// the caller's detail is not modified, since it may be reused across log calls
+	keys := make([]string, 0, len(detail))
+	for k := range detail {
+		if k != "level" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
 		a[i] = k
-		a[i+1] = v
+		a[i+1] = detail[k]
 		i += 2
 	}
 	return a
 }
 
+// withLevel returns a go-kit logger that prefixes its events with the log level, for the levels
+// that go-kit/log/level does not support. Like those of go-kit/log/level, it adds a context
+// logger to the chain, so that the caller valuer points at the same depth for every level
+func withLevel(logger log.Logger, lvl string) log.Logger {
+	return log.WithPrefix(logger, level.Key(), lvl)
+}
+
+// withoutLevel returns the detail without a "level" key, which would otherwise duplicate the
+// level of an event whose level is set by withLevel
+func withoutLevel(detail Pairs) Pairs {
+	if _, ok := detail["level"]; !ok {
+		return detail
+	}
+	c := make(Pairs, len(detail))
+	for k, v := range detail {
+		if k != "level" {
+			c[k] = v
+		}
+	}
+	return c
+}
+
 // DefaultLogger returns the default logger, which is the console logger at level "info"
 func DefaultLogger() *Logger {
 	return ConsoleLogger("info")
 }
 
-func noopLogger() *Logger {
+// NoopLogger returns a Logger that discards all log events. The zero value of Logger also discards
+// all log events, until its log level is set
+func NoopLogger() *Logger {
 	return &Logger{
-		onceRanEntries: make(map[string]bool),
-		onceMutex:      &sync.Mutex{},
+		baseLogger:     nopLogger,
+		logger:         nopLogger,
+		onceRanEntries: make(map[string]*list.Element),
+		onceOrder:      list.New(),
+		redactor:       newRedactor(d.DefaultLogRedactKeys()),
 	}
 }
 
 // ConsoleLogger returns a Logger object that prints log events to the Console
 func ConsoleLogger(logLevel string) *Logger {
 
-	l := noopLogger()
-	wr := os.Stdout
-	l.baseLogger = log.NewLogfmtLogger(log.NewSyncWriter(wr))
-	l.baseLogger = log.With(l.baseLogger,
-		"time", log.DefaultTimestampUTC,
-		"app", "trickster",
-		"caller", log.Valuer(func() interface{} {
-			return pkgCaller{stack.Caller(6)}
-		}),
-	)
+	l := NoopLogger()
+	ts, _ := newTimestamp("", false)
+	l.setContext(newEncoder(os.Stdout, "logfmt"), ts)
 	l.SetLogLevel(logLevel)
 	return l
 }
 
-// SetLogLevel sets the log level, defaulting to "Info" if the provided level is unknown
-func (tl *Logger) SetLogLevel(logLevel string) {
+// newBaseLogger returns a logger that encodes events to the writer in the provided format,
+// with the time, app and caller of each event attached. The format defaults to logfmt, and the
+// time is in the default timestamp format
+func newBaseLogger(wr io.Writer, format string) log.Logger {
+	ts, _ := newTimestamp("", false)
+	return withContext(newEncoder(wr, format), ts, 0)
+}
+
+// newEncoder returns a logger that encodes events to the writer in the provided format,
+// which defaults to logfmt
+func newEncoder(wr io.Writer, format string) log.Logger {
+	switch strings.ToLower(format) {
+	case "json":
+		return jsonLogger{log.NewJSONLogger(log.NewSyncWriter(wr))}
+	case "pretty":
+		return newPrettyLogger(wr)
+	}
+	return log.NewLogfmtLogger(log.NewSyncWriter(wr))
+}
+
+// withContext returns the logger with the time of each event, as valued by ts, its app, the
+// fields, such as those of instanceFields, and its caller attached. The caller is the first
+// frame outside of the log package, after skipping the frames of skip wrappers
+func withContext(l log.Logger, ts log.Valuer, skip int, fields ...interface{}) log.Logger {
+	keyvals := make([]interface{}, 0, len(fields)+6)
+	keyvals = append(keyvals, "time", ts, "app", "trickster")
+	keyvals = append(keyvals, fields...)
+	keyvals = append(keyvals, "caller", log.Valuer(func() interface{} {
+		return callerOf(skip)
+	}))
+	return log.With(l, keyvals...)
+}
+
+// setContext sets the logger prior to leveling to the encoder, with the context of withContext,
+// and remembers the context so that children can derive loggers that skip the frames of wrappers
+func (tl *Logger) setContext(enc log.Logger, ts log.Valuer, fields ...interface{}) {
+	tl.context = func(skip int) log.Logger {
+		return withContext(enc, ts, skip, fields...)
+	}
+	tl.baseLogger = tl.context(0)
+}
+
+const (
+	// goKitLogPrefix is the prefix of the function names of the go-kit loggers, whose frames are
+	// skipped to find the caller of an event
+	goKitLogPrefix = "github.com/go-kit/kit/log"
+	// logPkgPrefix is the prefix of the function names of the log package, whose frames are
+	// skipped to find the caller of an event, other than those of its tests
+	logPkgPrefix = pkgPrefix + "util/log."
+	// maxCallerDepth is the maximum number of frames that are walked to find the caller
+	maxCallerDepth = 32
+)
+
+// callerOf returns the caller of an event, which is the first frame outside of the log package
+// and the go-kit loggers, whichever of the Logger's methods was called, after skipping the
+// frames of skip wrappers
+func callerOf(skip int) pkgCaller {
+	var pcs [maxCallerDepth]uintptr
+	// skip runtime.Callers and this func
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for i := 0; ; i++ {
+		f, more := frames.Next()
+		if !isLogFrame(f) {
+			// stack.Caller(0) is the caller of this func, which is frame 0
+			return pkgCaller{stack.Caller(i + 1 + skip)}
+		}
+		if !more {
+			return pkgCaller{stack.Caller(i + 1)}
+		}
+	}
+}
+
+// isLogFrame returns true if the frame is that of the log package, other than its tests, or of
+// the go-kit loggers
+func isLogFrame(f runtime.Frame) bool {
+	if strings.HasPrefix(f.Function, goKitLogPrefix) {
+		return true
+	}
+	return strings.HasPrefix(f.Function, logPkgPrefix) && !strings.HasSuffix(f.File, "_test.go")
+}
+
+// instanceFields returns the fields that identify the instance of Trickster in its events once
+// they are aggregated centrally: its instance_id, when one is configured, and its hostname,
+// when LogIncludeHostname is true
+func instanceFields(conf *config.Config) []interface{} {
+	var fields []interface{}
+	if conf.Main != nil && conf.Main.InstanceID > 0 {
+		fields = append(fields, "instance_id", conf.Main.InstanceID)
+	}
+	if conf.Logging != nil && conf.Logging.LogIncludeHostname {
+		if host, err := os.Hostname(); err == nil {
+			fields = append(fields, "hostname", host)
+		}
+	}
+	return fields
+}
+
+// jsonLogger wraps a JSON logger to log events that have values which can't be
+// encoded as JSON, with those values replaced by their encoding errors, as the
+// logfmt logger does
+type jsonLogger struct {
+	log.Logger
+}
+
+// Log encodes the keyvals as a JSON object
+func (l jsonLogger) Log(keyvals ...interface{}) error {
+	if err := l.Logger.Log(keyvals...); err == nil {
+		return nil
+	}
+	kvs := make([]interface{}, len(keyvals))
+	copy(kvs, keyvals)
+	for i := 1; i < len(kvs); i += 2 {
+		switch kvs[i].(type) {
+		case error, fmt.Stringer:
+			// these are encoded as strings
+			continue
+		}
+		if _, err := json.Marshal(kvs[i]); err != nil {
+			kvs[i] = err.Error()
+		}
+	}
+	return l.Logger.Log(kvs...)
+}
+
+// With returns a child Logger that merges the default Pairs into the detail of every event it
+// sends, with the event's own Pairs taking precedence over the defaults. The child shares the
+// writer, log level and once entries of its parent, so a change to the level of either applies
+// to both. The child of a child Logger also includes the defaults of its parent
+func (tl *Logger) With(defaults Pairs) *Logger {
+	d := make(Pairs, len(tl.defaults)+len(defaults))
+	for k, v := range tl.defaults {
+		d[k] = v
+	}
+	for k, v := range defaults {
+		d[k] = v
+	}
+	s, _ := d[SubsystemKey].(string)
+	return &Logger{root: tl.shared(), defaults: d, subsystem: s, callerSkip: tl.callerSkip,
+		skipped: tl.skipped}
+}
+
+// WithCallerSkip returns a child Logger whose events report the caller n frames above the call
+// to its methods, for use by functions that wrap the Logger, so that the caller is that of the
+// wrapper rather than the wrapper itself. The frames of the log package are always skipped. The
+// child shares the state of its parent, as do those of With, and its skip is added to that of
+// its parent
+func (tl *Logger) WithCallerSkip(n int) *Logger {
+	c := &Logger{root: tl.shared(), defaults: tl.defaults, subsystem: tl.subsystem,
+		callerSkip: tl.callerSkip + n}
+	if c.callerSkip < 0 {
+		c.callerSkip = 0
+	}
+	if c.callerSkip > 0 && c.root.context != nil {
+		c.skipped = level.NewFilter(c.root.context(c.callerSkip), level.AllowAll())
+	}
+	return c
+}
+
+// shared returns the Logger whose state is shared by this Logger, which is its root when it is
+// a child Logger, and otherwise itself
+func (tl *Logger) shared() *Logger {
+	if tl.root != nil {
+		return tl.root
+	}
+	return tl
+}
+
+// array returns the event and its detail, merged with the Logger's default Pairs, as an array
+// of alternating keys and values
+func (tl *Logger) array(event string, detail Pairs) []interface{} {
+	if len(tl.defaults) > 0 {
+		merged := make(Pairs, len(tl.defaults)+len(detail))
+		for k, v := range tl.defaults {
+			merged[k] = v
+		}
+		for k, v := range detail {
+			merged[k] = v
+		}
+		detail = merged
+	}
+	return mapToArray(event, tl.shared().redactor.redact(evaluate(detail)))
+}
+
+// evaluate returns the detail with its lazy values, of type func() interface{}, replaced by
+// their results. The detail is copied when it has any lazy values, so that the caller's detail
+// is not modified. Lazy values are only evaluated for events that pass the level filter, so that
+// values that are expensive to compute cost nothing when their events are filtered
+func evaluate(detail Pairs) Pairs {
+	var out Pairs
+	for k, v := range detail {
+		f, ok := v.(func() interface{})
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make(Pairs, len(detail))
+			for k2, v2 := range detail {
+				out[k2] = v2
+			}
+		}
+		out[k] = f()
+	}
+	if out == nil {
+		return detail
+	}
+	return out
+}
+
+// levels are the supported log levels
+var levels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true,
+	"trace": true, "none": true}
+
+// IsLevel returns true if the log level is supported
+func IsLevel(logLevel string) bool {
+	return levels[strings.ToLower(logLevel)]
+}
+
+// The ranks of the leveled events, from the least to the most severe
+const (
+	rankTrace = iota
+	rankDebug
+	rankInfo
+	rankWarn
+	rankError
+	rankFatal
+)
+
+// levelRanks are the ranks of the leveled events by level
+var levelRanks = map[string]int{"trace": rankTrace, "debug": rankDebug, "info": rankInfo,
+	"warn": rankWarn, "error": rankError}
+
+// Enabled returns true if events of the level pass the Logger's level filter, so that the
+// caller can skip the preparation of events that would be filtered. The level override of the
+// caller's subsystem applies. Fatal events are always enabled once the log level is set. A nil
+// Logger is disabled
+func (tl *Logger) Enabled(logLevel string) bool {
+	logLevel = strings.ToLower(logLevel)
+	if logLevel == "fatal" {
+		return tl != nil && tl.leveled() != nopLogger
+	}
+	rank, ok := levelRanks[logLevel]
+	if !ok {
+		return false
+	}
+	_, ok = tl.filter(rank)
+	return ok
+}
+
+// filter returns the leveled logger to which an event of the rank is sent, and true when the
+// event passes the level filter, which is that of the level override of the event's subsystem,
+// if any, and otherwise that of the log level
+func (tl *Logger) filter(rank int) (log.Logger, bool) {
+	if tl == nil {
+		return nil, false
+	}
+	subsystem, skipped := tl.subsystem, tl.skipped
+	tl = tl.shared()
+	tl.levelMtx.RLock()
+	defer tl.levelMtx.RUnlock()
+	if tl.logger == nil {
+		return nil, false
+	}
+	logger, min := tl.logger, tl.rank
+	if tl.overrides != nil {
+		if r, ok := tl.overrides.rank(subsystem); ok {
+			logger, min = tl.unfiltered, r
+		}
+	}
+	// the events of a child that skips the frames of wrappers are filtered by rank alone
+	if skipped != nil {
+		logger = skipped
+	}
+	return logger, rank >= min
+}
+
+// SetLogLevel sets the log level, defaulting to "Info" if the provided level is unknown, and
+// returns the previous log level. The trace level also enables debug events. It is safe to call
+// while events are being logged
+func (tl *Logger) SetLogLevel(logLevel string) string {
+	tl = tl.shared()
+	tl.levelMtx.Lock()
+	defer tl.levelMtx.Unlock()
+	if tl.baseLogger == nil {
+		tl.baseLogger = nopLogger
+	}
+	previous := tl.level
 	tl.level = strings.ToLower(logLevel)
+	tl.rank = minRank(tl.level)
+	tl.unfiltered = level.NewFilter(tl.baseLogger, level.AllowAll())
 	// wrap logger depending on log level
 	switch tl.level {
 	case "debug":
@@ -118,6 +499,7 @@ func (tl *Logger) SetLogLevel(logLevel string) {
 	default:
 		tl.logger = level.NewFilter(tl.baseLogger, level.AllowInfo())
 	}
+	return previous
 }
 
 // New returns a Logger for the provided logging configuration. The
@@ -125,132 +507,473 @@ func (tl *Logger) SetLogLevel(logLevel string) {
 // instance string.
 func New(conf *config.Config) *Logger {
 
-	l := noopLogger()
+	l := NoopLogger()
 	var wr io.Writer
-
-	if conf.Logging.LogFile == "" {
-		wr = os.Stdout
+	var enc log.Logger
+	var defaulted Pairs
+	var syslogErr, fileErr, gelfErr error
+
+	if conf.Logging.LogFormat == "gelf" {
+		// when the GELF endpoint is unreachable, events are written to Console rather than lost
+		console := newConsoleEncoder(d.DefaultLogFormat, conf.Logging.SplitStreams)
+		gl, err := newGelfLogger(conf.Logging, console)
+		if err == nil {
+			l.closer = gl
+			enc = gl
+		} else {
+			gelfErr = err
+			enc = console
+		}
+	} else if conf.Logging.LogTarget == "syslog" {
+		// when syslog is unreachable, events are written to Console rather than lost
+		sw, err := dialSyslog(conf.Logging)
+		if err == nil {
+			l.closer = sw
+			enc = newSyslogLogger(sw, conf.Logging.LogFormat)
+		} else {
+			syslogErr = err
+			enc = newConsoleEncoder(conf.Logging.LogFormat, conf.Logging.SplitStreams)
+		}
+	} else if conf.Logging.LogTarget == "network" {
+		// the endpoint is connected in the background, so events are buffered rather than
+		// written to Console while it is unreachable
+		nw := newNetworkWriter(conf.Logging.LogTargetNetwork, conf.Logging.LogTargetAddress,
+			conf.Logging.LogTargetBufferSize, &l.counts.dropped)
+		l.closer = nw
+		wr = nw
+	} else if conf.Logging.LogFile == "" {
+		enc = newBufferedConsoleEncoder(conf.Logging.LogFormat, conf.Logging.SplitStreams,
+			l.buffered(conf.Logging.LogAsyncBufferSize))
+		if c, ok := stdout.(io.Closer); ok && c != nil {
+			l.closer = c
+		}
+	} else if sw, ok := consoleStream(conf.Logging.LogFile); ok {
+		// a log file that names a Console stream is written directly, rather than opened as a
+		// file, and is not closed
+		wr = sw
+		if buffered := l.buffered(conf.Logging.LogAsyncBufferSize); buffered != nil {
+			wr = buffered(wr)
+		}
 	} else {
 		logFile := conf.Logging.LogFile
 		if conf.Main.InstanceID > 0 {
 			logFile = strings.Replace(logFile, ".log", "."+strconv.Itoa(conf.Main.InstanceID)+".log", 1)
 		}
-
-		wr = &lumberjack.Logger{
-			Filename:   logFile,
-			MaxSize:    256,  // megabytes
-			MaxBackups: 80,   // 256 megs @ 80 backups is 20GB of Logs
-			MaxAge:     7,    // days
-			Compress:   true, // Compress Rolled Backups
+		var fw io.WriteCloser
+		fw, defaulted, fileErr = openFileWriter(logFile, conf.Logging)
+		if fileErr != nil {
+			// when the log file can't be opened, events are written to Console rather than lost
+			enc = newConsoleEncoder(conf.Logging.LogFormat, conf.Logging.SplitStreams)
+		} else {
+			buffered := l.buffered(conf.Logging.LogAsyncBufferSize)
+			// only the file is closed when both are written, since Console remains open
+			l.closer = fw
+			if conf.Logging.LogAlsoStdout && conf.Logging.SplitStreams {
+				// each event is encoded once for the file, and once for its Console stream
+				var bw io.Writer = fw
+				if buffered != nil {
+					bw = buffered(fw)
+				}
+				enc = teeLogger{newEncoder(bw, conf.Logging.LogFormat),
+					newBufferedConsoleEncoder(conf.Logging.LogFormat, true, buffered)}
+			} else {
+				wr = fw
+				if conf.Logging.LogAlsoStdout {
+					wr = io.MultiWriter(fw, stdout)
+				}
+				if buffered != nil {
+					wr = buffered(wr)
+				}
+			}
 		}
 	}
 
-	l.baseLogger = log.NewLogfmtLogger(log.NewSyncWriter(wr))
-	l.baseLogger = log.With(l.baseLogger,
-		"time", log.DefaultTimestampUTC,
-		"app", "trickster",
-		"caller", log.Valuer(func() interface{} {
-			return pkgCaller{stack.Caller(6)}
-		}),
-	)
+	// the encoder's sync writer wraps the combined writer, so that events written concurrently
+	// are not interleaved in either destination
+	if enc == nil {
+		enc = newEncoder(wr, conf.Logging.LogFormat)
+	}
+	enc, localeErr := newLocaleLogger(enc, &conf.Logging.LogLocale)
+	ts, tsOK := newTimestamp(conf.Logging.LogTimestampFormat, conf.Logging.LogTimestampLocal)
+	l.setContext(enc, ts, instanceFields(conf)...)
 
+	if conf.Logging.RedactKeys != nil {
+		l.redactor = newRedactor(conf.Logging.RedactKeys)
+	}
 	l.SetLogLevel(conf.Logging.LogLevel)
+	l.SetLevelOverrides(conf.Logging.LogLevelOverrides)
+	l.SetOnceLimits(conf.Logging.LogOnceMaxEntries,
+		time.Duration(conf.Logging.LogOnceTTLSecs)*time.Second)
+	l.SetDedupWindow(time.Duration(conf.Logging.LogDedupWindowSecs) * time.Second)
+
+	if localeErr != nil {
+		l.Warn("unable to load log translations file, values are not translated until it is reloaded",
+			Pairs{"translationsFile": conf.Logging.LogLocale.TranslationsFile, "detail": localeErr.Error()})
+	}
 
-	if c, ok := wr.(io.Closer); ok && c != nil {
-		l.closer = c
+	if !tsOK {
+		l.WarnOnce("logging.log_timestamp_format", "invalid log timestamp format, using default",
+			Pairs{"logTimestampFormat": conf.Logging.LogTimestampFormat, "default": timestampRFC3339Nano})
+	}
+
+	if syslogErr != nil {
+		l.ErrorOnce("logging.syslog", "unable to connect to syslog, logging to stdout",
+			Pairs{"syslogNetwork": conf.Logging.SyslogNetwork,
+				"syslogAddress": conf.Logging.SyslogAddress, "detail": syslogErr.Error()})
+	}
+
+	if gelfErr != nil {
+		l.ErrorOnce("logging.gelf", "unable to connect to gelf endpoint, logging to stdout",
+			Pairs{"gelfNetwork": conf.Logging.GelfNetwork,
+				"gelfAddress": conf.Logging.GelfAddress, "detail": gelfErr.Error()})
+	}
+
+	if fileErr != nil {
+		l.ErrorOnce("logging.log_file", "unable to open log file, logging to stdout",
+			Pairs{"logFile": conf.Logging.LogFile, "detail": fileErr.Error()})
+	}
+
+	for k, v := range defaulted {
+		l.WarnOnce("logging."+k, "invalid log rotation option, using default",
+			Pairs{"option": k, "default": v})
 	}
 
 	return l
 }
 
-// Pairs represents a key=value pair that helps to describe a log event
+// buffered returns a func that wraps a writer with an asyncWriter that buffers up to size events,
+// which is closed when the Logger is closed, or nil when size is not positive
+func (tl *Logger) buffered(size int) func(io.Writer) io.Writer {
+	if size <= 0 {
+		return nil
+	}
+	return func(w io.Writer) io.Writer {
+		aw := newAsyncWriter(w, size, &tl.counts.dropped)
+		tl.async = append(tl.async, aw)
+		return aw
+	}
+}
+
+// openFileWriter returns a writer to the log file. A log file that is rotated externally is
+// opened directly, so that it can be reopened once it is rotated, and is otherwise rotated by
+// the writer of newFileWriter, whose defaulted options are returned
+func openFileWriter(logFile string, lc *config.LoggingConfig) (io.WriteCloser, Pairs, error) {
+	if lc.LogRotation == "external" {
+		w, err := newReopenWriter(logFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return w, nil, nil
+	}
+	w, defaulted := newFileWriter(logFile, lc)
+	return w, defaulted, nil
+}
+
+// newFileWriter returns a writer to the log file that rotates it per the logging
+// configuration. Rotation options that are not positive are replaced by their
+// defaults, which are returned by option name
+func newFileWriter(logFile string, lc *config.LoggingConfig) (*lumberjack.Logger, Pairs) {
+	defaulted := make(Pairs)
+	positive := func(option string, v, def int) int {
+		if v > 0 {
+			return v
+		}
+		defaulted[option] = def
+		return def
+	}
+	return &lumberjack.Logger{
+		Filename:   logFile,
+		MaxSize:    positive("log_max_size_mb", lc.LogMaxSizeMB, d.DefaultLogMaxSizeMB),
+		MaxBackups: positive("log_max_backups", lc.LogMaxBackups, d.DefaultLogMaxBackups),
+		MaxAge:     positive("log_max_age_days", lc.LogMaxAgeDays, d.DefaultLogMaxAgeDays),
+		Compress:   lc.LogCompress,
+	}, defaulted
+}
+
+// Pairs represents a key=value pair that helps to describe a log event. A value of type
+// func() interface{} is lazy, and is only called when the event passes the level filter
 type Pairs map[string]interface{}
 
 // Info sends an "INFO" event to the Logger
 func (tl *Logger) Info(event string, detail Pairs) {
-	level.Info(tl.logger).Log(mapToArray(event, detail)...)
+	logger, ok := tl.filter(rankInfo)
+	if !ok {
+		return
+	}
+	keyvals := tl.array(event, detail)
+	if tl.deduplicate(rankInfo, keyvals) {
+		return
+	}
+	tl.count(rankInfo)
+	level.Info(logger).Log(keyvals...)
 }
 
 // InfoOnce sends a "INFO" event to the Logger only once per key.
 // Returns true if this invocation was the first, and thus sent to the Logger
 func (tl *Logger) InfoOnce(key string, event string, detail Pairs) bool {
-	tl.onceMutex.Lock()
-	defer tl.onceMutex.Unlock()
-	key = "info." + key
-	if _, ok := tl.onceRanEntries[key]; !ok {
-		tl.onceRanEntries[key] = true
+	if tl.once("info." + key) {
 		tl.Info(event, detail)
 		return true
 	}
+	tl.countOnceSuppressed()
 	return false
 }
 
 // Warn sends an "WARN" event to the Logger
 func (tl *Logger) Warn(event string, detail Pairs) {
-	level.Warn(tl.logger).Log(mapToArray(event, detail)...)
+	logger, ok := tl.filter(rankWarn)
+	if !ok {
+		return
+	}
+	keyvals := tl.array(event, detail)
+	if tl.deduplicate(rankWarn, keyvals) {
+		return
+	}
+	tl.count(rankWarn)
+	level.Warn(logger).Log(keyvals...)
 }
 
 // WarnOnce sends a "WARN" event to the Logger only once per key.
 // Returns true if this invocation was the first, and thus sent to the Logger
 func (tl *Logger) WarnOnce(key string, event string, detail Pairs) bool {
-	tl.onceMutex.Lock()
-	defer tl.onceMutex.Unlock()
-	key = "warn." + key
-	if _, ok := tl.onceRanEntries[key]; !ok {
-		tl.onceRanEntries[key] = true
+	if tl.once("warn." + key) {
 		tl.Warn(event, detail)
 		return true
 	}
+	tl.countOnceSuppressed()
 	return false
 }
 
+// onceLevels are the levels of the Once functions, whose keys are prefixed with the level
+var onceLevels = []string{"trace", "debug", "info", "warn", "error"}
+
+// onceEntry is a key of an event that was sent once, and the time it was sent
+type onceEntry struct {
+	key string
+	ran time.Time
+}
+
+// once returns true if it is the first time it is called for the key, or the first time since
+// the key was evicted or expired
+func (tl *Logger) once(key string) bool {
+	tl = tl.shared()
+	tl.onceMutex.Lock()
+	defer tl.onceMutex.Unlock()
+	if tl.onceRanEntries == nil {
+		tl.onceRanEntries = make(map[string]*list.Element)
+		tl.onceOrder = list.New()
+	}
+	now := time.Now()
+	tl.expireOnce(now)
+	if _, ok := tl.onceRanEntries[key]; ok {
+		return false
+	}
+	tl.onceRanEntries[key] = tl.onceOrder.PushFront(&onceEntry{key: key, ran: now})
+	if tl.onceMaxEntries > 0 {
+		for tl.onceOrder.Len() > tl.onceMaxEntries {
+			tl.removeOnce(tl.onceOrder.Back())
+		}
+	}
+	return true
+}
+
+// expireOnce removes the once entries that ran longer than the once TTL ago. The caller must
+// hold the once mutex
+func (tl *Logger) expireOnce(now time.Time) {
+	if tl.onceTTL <= 0 || tl.onceOrder == nil {
+		return
+	}
+	for e := tl.onceOrder.Back(); e != nil; e = tl.onceOrder.Back() {
+		if now.Sub(e.Value.(*onceEntry).ran) < tl.onceTTL {
+			return
+		}
+		tl.removeOnce(e)
+	}
+}
+
+// removeOnce removes a once entry. The caller must hold the once mutex
+func (tl *Logger) removeOnce(e *list.Element) {
+	delete(tl.onceRanEntries, e.Value.(*onceEntry).key)
+	tl.onceOrder.Remove(e)
+}
+
+// SetOnceLimits bounds the keys remembered by the Once functions to the most recent maxEntries,
+// and forgets each key once the ttl has elapsed since its event was sent, after which an event
+// for the key is sent again. A maxEntries or ttl of 0 or less is unbounded, which is the default
+func (tl *Logger) SetOnceLimits(maxEntries int, ttl time.Duration) {
+	tl = tl.shared()
+	tl.onceMutex.Lock()
+	defer tl.onceMutex.Unlock()
+	tl.onceMaxEntries = maxEntries
+	tl.onceTTL = ttl
+	if tl.onceOrder == nil {
+		return
+	}
+	tl.expireOnce(time.Now())
+	if maxEntries > 0 {
+		for tl.onceOrder.Len() > maxEntries {
+			tl.removeOnce(tl.onceOrder.Back())
+		}
+	}
+}
+
+// ResetOnce forgets the key at every level, so that the next Once event for the key is sent
+func (tl *Logger) ResetOnce(key string) {
+	tl = tl.shared()
+	tl.onceMutex.Lock()
+	defer tl.onceMutex.Unlock()
+	for _, lvl := range onceLevels {
+		if e, ok := tl.onceRanEntries[lvl+"."+key]; ok {
+			tl.removeOnce(e)
+		}
+	}
+}
+
+// ResetAllOnce forgets every key, so that the next Once event for each key is sent
+func (tl *Logger) ResetAllOnce() {
+	tl = tl.shared()
+	tl.onceMutex.Lock()
+	defer tl.onceMutex.Unlock()
+	tl.onceRanEntries = make(map[string]*list.Element)
+	tl.onceOrder = list.New()
+}
+
+// OnceEntries returns the number of keys remembered by the Once functions
+func (tl *Logger) OnceEntries() int {
+	tl = tl.shared()
+	tl.onceMutex.Lock()
+	defer tl.onceMutex.Unlock()
+	if tl.onceOrder == nil {
+		return 0
+	}
+	tl.expireOnce(time.Now())
+	return tl.onceOrder.Len()
+}
+
+// leveled returns the logger after leveling, or a logger that discards all log events when the
+// log level has not been set
+func (tl *Logger) leveled() log.Logger {
+	skipped := tl.skipped
+	tl = tl.shared()
+	tl.levelMtx.RLock()
+	defer tl.levelMtx.RUnlock()
+	if tl.logger == nil {
+		return nopLogger
+	}
+	if skipped != nil {
+		return skipped
+	}
+	return tl.logger
+}
+
 // HasWarnedOnce returns true if a warning for the key has already been sent to the Logger
 func (tl *Logger) HasWarnedOnce(key string) bool {
+	return tl.HasLoggedOnce("warn", key)
+}
+
+// HasLoggedOnce returns true if an event for the key has already been sent to the Logger by
+// the Once function of the level, which is one of trace, debug, info, warn or error
+func (tl *Logger) HasLoggedOnce(lvl string, key string) bool {
+	tl = tl.shared()
 	tl.onceMutex.Lock()
 	defer tl.onceMutex.Unlock()
-	key = "warn." + key
-	_, ok := tl.onceRanEntries[key]
+	tl.expireOnce(time.Now())
+	_, ok := tl.onceRanEntries[strings.ToLower(lvl)+"."+key]
 	return ok
 }
 
 // Error sends an "ERROR" event to the Logger
 func (tl *Logger) Error(event string, detail Pairs) {
-	level.Error(tl.logger).Log(mapToArray(event, detail)...)
+	logger, ok := tl.filter(rankError)
+	if !ok {
+		return
+	}
+	keyvals := tl.array(event, detail)
+	if tl.deduplicate(rankError, keyvals) {
+		return
+	}
+	tl.count(rankError)
+	level.Error(logger).Log(keyvals...)
 }
 
 // ErrorOnce sends an "ERROR" event to the Logger only once per key
 // Returns true if this invocation was the first, and thus sent to the Logger
 func (tl *Logger) ErrorOnce(key string, event string, detail Pairs) bool {
-	tl.onceMutex.Lock()
-	defer tl.onceMutex.Unlock()
-	key = "error." + key
-	if _, ok := tl.onceRanEntries[key]; !ok {
-		tl.onceRanEntries[key] = true
+	if tl.once("error." + key) {
 		tl.Error(event, detail)
 		return true
 	}
+	tl.countOnceSuppressed()
 	return false
 }
 
 // Debug sends an "DEBUG" event to the Logger
 func (tl *Logger) Debug(event string, detail Pairs) {
-	level.Debug(tl.logger).Log(mapToArray(event, detail)...)
+	logger, ok := tl.filter(rankDebug)
+	if !ok {
+		return
+	}
+	keyvals := tl.array(event, detail)
+	if tl.deduplicate(rankDebug, keyvals) {
+		return
+	}
+	tl.count(rankDebug)
+	level.Debug(logger).Log(keyvals...)
+}
+
+// DebugOnce sends a "DEBUG" event to the Logger only once per key.
+// Returns true if this invocation was the first, and thus sent to the Logger
+func (tl *Logger) DebugOnce(key string, event string, detail Pairs) bool {
+	if tl.once("debug." + key) {
+		tl.Debug(event, detail)
+		return true
+	}
+	tl.countOnceSuppressed()
+	return false
 }
 
 // Trace sends a "TRACE" event to the Logger
 func (tl *Logger) Trace(event string, detail Pairs) {
-	// go-kit/log/level does not support Trace, so implemented separately here
-	if tl.level == "trace" {
-		detail["level"] = "trace"
-		tl.logger.Log(mapToArray(event, detail)...)
+	// go-kit/log/level does not support Trace, so its level is filtered by rank, like the
+	// others, and only its level value is set separately here
+	logger, ok := tl.filter(rankTrace)
+	if !ok {
+		return
+	}
+	keyvals := tl.array(event, withoutLevel(detail))
+	if tl.deduplicate(rankTrace, keyvals) {
+		return
 	}
+	tl.count(rankTrace)
+	withLevel(logger, "trace").Log(keyvals...)
 }
 
-// Fatal sends a "FATAL" event to the Logger and exits the program with the provided exit code
+// TraceOnce sends a "TRACE" event to the Logger only once per key. The key is not remembered
+// unless the log level is trace, so that the event is sent once the level is changed to trace.
+// Returns true if this invocation was the first, and thus sent to the Logger
+func (tl *Logger) TraceOnce(key string, event string, detail Pairs) bool {
+	if !tl.Enabled("trace") {
+		return false
+	}
+	if tl.once("trace." + key) {
+		tl.Trace(event, detail)
+		return true
+	}
+	tl.countOnceSuppressed()
+	return false
+}
+
+// Fatal sends a "FATAL" event to the Logger, runs the fatal hooks, closes the Logger and exits the
+// program with the provided exit code. A negative code does not exit
 func (tl *Logger) Fatal(code int, event string, detail Pairs) {
 	// go-kit/log/level does not support Fatal, so implemented separately here
-	detail["level"] = "fatal"
-	tl.logger.Log(mapToArray(event, detail)...)
+	if tl.Enabled("fatal") {
+		tl.count(rankFatal)
+	}
+	withLevel(tl.leveled(), "fatal").Log(tl.array(event, withoutLevel(detail))...)
+	runFatalHooks()
+	// a child Logger does not own its writer, so that of its root is closed
+	tl.shared().Close()
 	if code >= 0 {
 		os.Exit(code)
 	}
@@ -258,16 +981,34 @@ func (tl *Logger) Fatal(code int, event string, detail Pairs) {
 
 // Level returns the configured Log Level
 func (tl *Logger) Level() string {
+	tl = tl.shared()
+	tl.levelMtx.RLock()
+	defer tl.levelMtx.RUnlock()
 	return tl.level
 }
 
-// Close closes any opened file handles that were used for logging.
+// Close sends the summaries of the repeated events whose deduplication windows are open, writes
+// any buffered events, and closes any opened file handles that were used for logging. A child
+// Logger does not own the file handles of its parent, so closing it has no effect
 func (tl *Logger) Close() {
+	if tl.root == nil {
+		tl.flushDedup()
+	}
+	// the buffered events are written before their writers are closed
+	tl.Flush()
 	if tl.closer != nil {
 		tl.closer.Close()
 	}
 }
 
+// Flush writes any events that are buffered by asynchronous logging, after which events are
+// written synchronously. Unlike Close, the writers are left open
+func (tl *Logger) Flush() {
+	for _, aw := range tl.async {
+		aw.Close()
+	}
+}
+
 // pkgCaller wraps a stack.Call to make the default string output include the
 // package path.
 type pkgCaller struct {
@@ -17,10 +17,19 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/config"
+
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
 )
 
 func TestConsoleLogger(t *testing.T) {
@@ -56,6 +65,245 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNoopLogger(t *testing.T) {
+
+	for name, l := range map[string]*Logger{"noop": NoopLogger(), "zero": {}} {
+		t.Run(name, func(t *testing.T) {
+			l.Info("test entry", Pairs{"testKey": "testVal"})
+			l.Warn("test entry", Pairs{"testKey": "testVal"})
+			l.Error("test entry", Pairs{"testKey": "testVal"})
+			l.Debug("test entry", nil)
+			l.Trace("test entry", nil)
+			l.Fatal(-1, "test entry", nil)
+			for i, f := range []func(string, string, Pairs) bool{l.DebugOnce, l.InfoOnce,
+				l.WarnOnce, l.ErrorOnce} {
+				if !f("test-key", "test entry", nil) {
+					t.Errorf("(%d) expected true", i)
+				}
+				if f("test-key", "test entry", nil) {
+					t.Errorf("(%d) expected false", i)
+				}
+			}
+			if !l.HasWarnedOnce("test-key") {
+				t.Error("expected true")
+			}
+			if l.Level() != "" {
+				t.Errorf("expected empty level got %s", l.Level())
+			}
+
+			// setting the level does not make the logger log
+			l.SetLogLevel("trace")
+			l.Trace("test entry", nil)
+			l.Fatal(-1, "test entry", Pairs{"testKey": "testVal"})
+			l.Close()
+		})
+	}
+}
+
+func TestNewLogger_JSON(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "json")
+	l.SetLogLevel("info")
+	l.Info("test entry", Pairs{"testKey": "testVal", "testSlice": []string{"a", "b"},
+		"testFunc": func() {}})
+
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range map[string]string{"level": "info", "event": "test entry",
+		"app": "trickster", "testKey": "testVal"} {
+		if m[k] != v {
+			t.Errorf("expected %s got %v for %s", v, m[k], k)
+		}
+	}
+	if _, ok := m["time"].(string); !ok {
+		t.Errorf("expected time in %s", buf.String())
+	}
+	if c, ok := m["caller"].(string); !ok || !strings.HasPrefix(c, "util/log/log_test.go:") {
+		t.Errorf("expected caller in %s", buf.String())
+	}
+	if a, ok := m["testSlice"].([]interface{}); !ok || len(a) != 2 || a[0] != "a" || a[1] != "b" {
+		t.Errorf("expected [a b] got %v", m["testSlice"])
+	}
+	if _, ok := m["testFunc"].(string); !ok {
+		t.Errorf("expected encoding error got %v", m["testFunc"])
+	}
+}
+
+func TestNewLogger_Logfmt(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "logfmt")
+	l.SetLogLevel("info")
+	l.Info("test entry", Pairs{"testKey": "testVal"})
+
+	s := buf.String()
+	if !strings.HasPrefix(s, "time=") {
+		t.Errorf("expected time first in %s", s)
+	}
+	i := strings.Index(s, " level=info event=\"test entry\" testKey=testVal")
+	if i < 0 || i < strings.Index(s, " caller=util/log/log_test.go:") {
+		t.Errorf("unexpected output %s", s)
+	}
+}
+
+func TestPairsOrder(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "logfmt")
+	l.SetLogLevel("info")
+
+	detail := Pairs{"originName": "prom1", "cacheKey": "abc", "statusCode": 200,
+		"zone": "east", "bytes": 1024, "attempt": 2}
+	var expected string
+	for i := 0; i < 50; i++ {
+		l.Info("test entry", detail)
+		s := buf.String()
+		buf.Reset()
+		// the timestamp is excluded from the comparison
+		s = s[strings.Index(s, " "):]
+		if i == 0 {
+			expected = s
+			continue
+		}
+		if s != expected {
+			t.Fatalf("expected %s got %s", expected, s)
+		}
+	}
+	if !strings.Contains(expected, " level=info event=\"test entry\" attempt=2 bytes=1024 "+
+		"cacheKey=abc originName=prom1 statusCode=200 zone=east") {
+		t.Errorf("unexpected output %s", expected)
+	}
+}
+
+func TestPairsNotModified(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "logfmt")
+	l.SetLogLevel("trace")
+
+	detail := Pairs{"chunk": 1, "level": "custom"}
+	for _, lvl := range []string{"fatal", "trace"} {
+		for i := 0; i < 2; i++ {
+			if lvl == "fatal" {
+				l.Fatal(-1, "test entry", detail)
+			} else {
+				l.Trace("test entry", detail)
+			}
+			s := buf.String()
+			buf.Reset()
+			if !strings.Contains(s, " level="+lvl+" event=\"test entry\" chunk=1") {
+				t.Errorf("unexpected output %s", s)
+			}
+			if len(detail) != 2 || detail["level"] != "custom" || detail["chunk"] != 1 {
+				t.Errorf("unexpected detail %v", detail)
+			}
+		}
+	}
+
+	// a level provided by the caller is not removed from its detail
+	l.Info("test entry", detail)
+	if s := buf.String(); !strings.Contains(s, " level=custom event=\"test entry\" chunk=1") ||
+		len(detail) != 2 {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	// nil detail is permitted
+	l.Trace("test entry", nil)
+	l.Fatal(-1, "test entry", nil)
+}
+
+func TestWith(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "json")
+	l.SetLogLevel("info")
+
+	child := l.With(Pairs{"originName": "prom1", "originType": "prometheus"})
+	grandchild := child.With(Pairs{"originType": "rpc", "cacheName": "default"})
+
+	event := func() map[string]interface{} {
+		m := make(map[string]interface{})
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatalf("%s: %s", err, buf.String())
+		}
+		buf.Reset()
+		return m
+	}
+
+	// the call site's pairs take precedence over the defaults
+	child.Info("test entry", Pairs{"originName": "prom2", "testKey": "testVal"})
+	m := event()
+	if m["originName"] != "prom2" || m["originType"] != "prometheus" || m["testKey"] != "testVal" {
+		t.Errorf("unexpected event %v", m)
+	}
+	if c, ok := m["caller"].(string); !ok || !strings.HasPrefix(c, "util/log/log_test.go:") {
+		t.Errorf("expected caller in %v", m)
+	}
+
+	// the defaults of a child's child are merged with its parent's
+	grandchild.Warn("test entry", nil)
+	m = event()
+	if m["originName"] != "prom1" || m["originType"] != "rpc" || m["cacheName"] != "default" {
+		t.Errorf("unexpected event %v", m)
+	}
+
+	// the parent does not include the child's defaults
+	l.Info("test entry", nil)
+	if m = event(); m["originName"] != nil {
+		t.Errorf("unexpected event %v", m)
+	}
+
+	// the level of the parent applies to the children created before it changed
+	l.SetLogLevel("error")
+	if child.Level() != "error" || grandchild.Level() != "error" {
+		t.Errorf("expected %s got %s and %s", "error", child.Level(), grandchild.Level())
+	}
+	grandchild.Info("test entry", nil)
+	if buf.Len() > 0 {
+		t.Errorf("unexpected output %s", buf.String())
+	}
+	l.SetLogLevel("trace")
+	grandchild.Trace("test entry", nil)
+	if m = event(); m["level"] != "trace" || m["cacheName"] != "default" {
+		t.Errorf("unexpected event %v", m)
+	}
+
+	// once entries are shared by the parent and its children
+	if !child.WarnOnce("test-key", "test entry", nil) {
+		t.Error("expected first warning to be sent")
+	}
+	if l.WarnOnce("test-key", "test entry", nil) || grandchild.WarnOnce("test-key", "test entry", nil) {
+		t.Error("expected subsequent warnings not to be sent")
+	}
+	if !l.HasWarnedOnce("test-key") || !grandchild.HasWarnedOnce("test-key") {
+		t.Error("expected the warning to be shared")
+	}
+
+	// closing a child does not close its parent's writer
+	l.closer = testCloser(func() { t.Error("unexpected close") })
+	child.Close()
+
+	// children of the zero and noop Loggers discard their events
+	(&Logger{}).With(Pairs{"a": 1}).Info("test entry", nil)
+	NoopLogger().With(Pairs{"a": 1}).Error("test entry", nil)
+}
+
+type testCloser func()
+
+func (f testCloser) Close() error {
+	f()
+	return nil
+}
+
 func TestNewLogger_LogFile(t *testing.T) {
 	fileName := "out.log"
 	instanceFileName := "out.1.log"
@@ -73,6 +321,128 @@ func TestNewLogger_LogFile(t *testing.T) {
 	os.Remove(instanceFileName)
 }
 
+// testConsole is a Console writer that records whether it was closed
+type testConsole struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *testConsole) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestNewLogger_LogAlsoStdout(t *testing.T) {
+	fileName := "out.stdout.log"
+	console := &testConsole{}
+	stdout = console
+	defer func() { stdout = os.Stdout }()
+
+	conf := config.NewConfig()
+	conf.Logging.LogFile = fileName
+	conf.Logging.LogFormat = "json"
+	conf.Logging.LogAlsoStdout = true
+	l := New(conf)
+	defer os.Remove(fileName)
+
+	// events logged concurrently are written whole to both destinations
+	done := make(chan bool)
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			for j := 0; j < 10; j++ {
+				l.Info("test entry", Pairs{"goroutine": i, "entry": j})
+			}
+			done <- true
+		}(i)
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+	l.Close()
+	if console.closed {
+		t.Error("expected console to remain open")
+	}
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, console.Bytes()) {
+		t.Errorf("expected identical events, got:\n%s\nand:\n%s", string(b), console.String())
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 100 {
+		t.Errorf("expected %d got %d", 100, len(lines))
+	}
+	for _, line := range lines {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("invalid event %s", line)
+		}
+	}
+
+	// without the option, events are only written to the file
+	console.Reset()
+	conf.Logging.LogAlsoStdout = false
+	l = New(conf)
+	l.Info("test entry", nil)
+	l.Close()
+	if console.Len() != 0 {
+		t.Errorf("unexpected console output %s", console.String())
+	}
+}
+
+func TestNewLogger_LogFileRotation(t *testing.T) {
+	fileName := "out.rotation.log"
+	conf := config.NewConfig()
+	conf.Logging.LogFile = fileName
+	conf.Logging.LogMaxSizeMB = 10
+	conf.Logging.LogMaxBackups = 3
+	conf.Logging.LogMaxAgeDays = 2
+	conf.Logging.LogCompress = false
+
+	w, defaulted := newFileWriter(fileName, conf.Logging)
+	if w.Filename != fileName || w.MaxSize != 10 || w.MaxBackups != 3 ||
+		w.MaxAge != 2 || w.Compress {
+		t.Errorf("unexpected writer %+v", w)
+	}
+	if len(defaulted) != 0 {
+		t.Errorf("expected %d got %d", 0, len(defaulted))
+	}
+
+	// the defaults are used when unset
+	w, defaulted = newFileWriter(fileName, config.NewConfig().Logging)
+	if w.MaxSize != 256 || w.MaxBackups != 80 || w.MaxAge != 7 || !w.Compress {
+		t.Errorf("unexpected writer %+v", w)
+	}
+	if len(defaulted) != 0 {
+		t.Errorf("expected %d got %d", 0, len(defaulted))
+	}
+
+	// and values that are not positive fall back to the defaults, with a warning
+	conf.Logging.LogMaxSizeMB = 0
+	conf.Logging.LogMaxBackups = -1
+	w, defaulted = newFileWriter(fileName, conf.Logging)
+	if w.MaxSize != 256 || w.MaxBackups != 80 || w.MaxAge != 2 {
+		t.Errorf("unexpected writer %+v", w)
+	}
+	if len(defaulted) != 2 || defaulted["log_max_size_mb"] != 256 ||
+		defaulted["log_max_backups"] != 80 {
+		t.Errorf("unexpected defaulted options %v", defaulted)
+	}
+
+	log := New(conf)
+	defer os.Remove(fileName)
+	defer log.Close()
+	if !log.HasWarnedOnce("logging.log_max_size_mb") ||
+		!log.HasWarnedOnce("logging.log_max_backups") ||
+		log.HasWarnedOnce("logging.log_max_age_days") {
+		t.Error("expected warnings for the defaulted options only")
+	}
+	if w, ok := log.closer.(*lumberjack.Logger); !ok || w.MaxSize != 256 || w.MaxAge != 2 {
+		t.Errorf("unexpected writer %+v", log.closer)
+	}
+}
+
 func TestNewLoggerDebug_LogFile(t *testing.T) {
 	fileName := "out.debug.log"
 	// it should create a logger that outputs to a log file ("out.test.log")
@@ -274,3 +644,357 @@ func TestSetLogLevel(t *testing.T) {
 	}
 
 }
+
+func TestInstanceFields(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	stdout = buf
+	defer func() { stdout = os.Stdout }()
+
+	conf := config.NewConfig()
+	conf.Main.InstanceID = 2
+	l := New(conf)
+	l.Info("test entry", nil)
+	l.With(Pairs{"originName": "default"}).Warn("test child entry", nil)
+	l.Error("test error entry", Pairs{"testKey": "testVal"})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected %d got %d", 3, len(lines))
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, " app=trickster instance_id=2 caller=") ||
+			strings.Contains(line, "hostname=") {
+			t.Errorf("unexpected output %s", line)
+		}
+	}
+
+	// the hostname is included when configured, and no instance_id when it is not set
+	buf.Reset()
+	host, _ := os.Hostname()
+	conf.Main.InstanceID = 0
+	conf.Logging.LogIncludeHostname = true
+	l = New(conf)
+	l.Info("test entry", nil)
+	if s := buf.String(); !strings.Contains(s, "hostname="+host) ||
+		strings.Contains(s, "instance_id=") {
+		t.Errorf("unexpected output %s", s)
+	}
+}
+
+func TestTraceLevel(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "logfmt")
+
+	// trace events are dropped at the debug level, which they are more verbose than
+	l.SetLogLevel("debug")
+	l.Trace("test trace", nil)
+	l.Debug("test debug", nil)
+	if s := buf.String(); strings.Contains(s, "test trace") || !strings.Contains(s, "test debug") {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	// the trace level also enables debug events, and a child Logger is filtered by its root
+	buf.Reset()
+	l.SetLogLevel("trace")
+	l.With(Pairs{"originName": "default"}).Trace("test trace", Pairs{"level": "ignored"})
+	l.Debug("test debug", nil)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(lines))
+	}
+	// the level of a trace event is rendered like that of the other levels, before its event
+	for i, lvl := range []string{"trace", "debug"} {
+		li := strings.Index(lines[i], "level="+lvl+" ")
+		if li < 0 || strings.Count(lines[i], "level=") != 1 ||
+			li > strings.Index(lines[i], "event=") {
+			t.Errorf("unexpected output %s", lines[i])
+		}
+	}
+	if !l.Enabled("trace") {
+		t.Error("expected trace to be enabled")
+	}
+}
+
+func TestSetLogLevelConcurrent(t *testing.T) {
+	l := New(config.NewConfig())
+	l.baseLogger = newBaseLogger(ioutil.Discard, "logfmt")
+
+	// the level is changed while events are logged
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			l.Debug("test entry", nil)
+			l.Trace("test entry", nil)
+		}
+		done <- true
+	}()
+	for _, lvl := range []string{"debug", "trace", "warn", "info"} {
+		l.SetLogLevel(lvl)
+	}
+	<-done
+
+	if previous := l.SetLogLevel("error"); previous != "info" {
+		t.Errorf("expected %s got %s", "info", previous)
+	}
+	if !IsLevel("WARN") || IsLevel("verbose") {
+		t.Error("unexpected level validation")
+	}
+}
+
+func TestOnceLimits(t *testing.T) {
+
+	log := NoopLogger()
+	for _, key := range []string{"key1", "key2", "key3"} {
+		log.WarnOnce(key, "test entry", nil)
+	}
+	if n := log.OnceEntries(); n != 3 {
+		t.Errorf("expected %d got %d", 3, n)
+	}
+
+	// the oldest keys are evicted, and their events are sent again
+	log.SetOnceLimits(2, 0)
+	if n := log.OnceEntries(); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	if log.HasWarnedOnce("key1") || !log.HasWarnedOnce("key3") {
+		t.Error("expected key1 to be evicted")
+	}
+	if !log.WarnOnce("key1", "test entry", nil) {
+		t.Error("expected evicted key to be sent")
+	}
+	if log.HasWarnedOnce("key2") {
+		t.Error("expected key2 to be evicted")
+	}
+
+	// keys expire after the ttl
+	log.SetOnceLimits(0, time.Millisecond*10)
+	if log.WarnOnce("key3", "test entry", nil) {
+		t.Error("expected key3 not to be sent")
+	}
+	time.Sleep(time.Millisecond * 20)
+	if n := log.OnceEntries(); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+	if !log.WarnOnce("key3", "test entry", nil) {
+		t.Error("expected expired key to be sent")
+	}
+
+	// keys are reset at every level
+	log.SetOnceLimits(0, 0)
+	log.InfoOnce("key4", "test entry", nil)
+	log.ErrorOnce("key4", "test entry", nil)
+	log.ResetOnce("key4")
+	if n := log.OnceEntries(); n != 1 {
+		t.Errorf("expected %d got %d", 1, n)
+	}
+	if !log.InfoOnce("key4", "test entry", nil) || !log.ErrorOnce("key4", "test entry", nil) {
+		t.Error("expected reset key to be sent")
+	}
+
+	// the entries of a child Logger are those of its parent
+	child := log.With(Pairs{"childKey": "childVal"})
+	child.ResetAllOnce()
+	if n := log.OnceEntries(); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+	if !log.WarnOnce("key3", "test entry", nil) {
+		t.Error("expected reset key to be sent")
+	}
+
+	// the zero value Logger remembers keys
+	zl := &Logger{}
+	if !zl.WarnOnce("key1", "test entry", nil) || zl.WarnOnce("key1", "test entry", nil) ||
+		zl.OnceEntries() != 1 {
+		t.Error("expected the zero value Logger to send the event once")
+	}
+}
+
+func TestDebugTraceOnce(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "logfmt")
+	l.SetLogLevel("debug")
+
+	if !l.DebugOnce("test-key", "test entry", nil) || l.DebugOnce("test-key", "test entry", nil) {
+		t.Error("expected the debug event to be sent once")
+	}
+	if s := buf.String(); strings.Count(s, "level=debug") != 1 {
+		t.Errorf("unexpected output %s", s)
+	}
+	if !l.HasLoggedOnce("debug", "test-key") || !l.HasLoggedOnce("DEBUG", "test-key") ||
+		l.HasLoggedOnce("info", "test-key") || l.HasLoggedOnce("invalid", "test-key") {
+		t.Error("expected the key to be remembered at the debug level only")
+	}
+
+	// the trace event is not sent, nor its key remembered, until the level is trace
+	buf.Reset()
+	if l.TraceOnce("test-key", "test entry", nil) || l.HasLoggedOnce("trace", "test-key") {
+		t.Error("expected the trace event not to be sent")
+	}
+	l.SetLogLevel("trace")
+	if !l.TraceOnce("test-key", "test entry", nil) || l.TraceOnce("test-key", "test entry", nil) {
+		t.Error("expected the trace event to be sent once")
+	}
+	if s := buf.String(); strings.Count(s, "level=trace") != 1 {
+		t.Errorf("unexpected output %s", s)
+	}
+	if !l.HasLoggedOnce("trace", "test-key") {
+		t.Error("expected the key to be remembered")
+	}
+
+	// keys are reset at every level
+	l.ResetOnce("test-key")
+	if l.HasLoggedOnce("trace", "test-key") || l.HasLoggedOnce("debug", "test-key") {
+		t.Error("expected the key to be reset")
+	}
+}
+
+func TestOnceConcurrency(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "logfmt")
+	l.SetLogLevel("trace")
+
+	funcs := map[string]func(string, string, Pairs) bool{
+		"trace": l.TraceOnce,
+		"debug": l.DebugOnce,
+		"info":  l.InfoOnce,
+		"warn":  l.WarnOnce,
+		"error": l.ErrorOnce,
+	}
+
+	for lvl, f := range funcs {
+		buf.Reset()
+		var sent int32
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if f("test-key", "test entry", nil) {
+					atomic.AddInt32(&sent, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		if sent != 1 {
+			t.Errorf("(%s) expected %d got %d", lvl, 1, sent)
+		}
+		if n := strings.Count(buf.String(), "level="+lvl+" "); n != 1 {
+			t.Errorf("(%s) expected %d events got %d", lvl, 1, n)
+		}
+		if !l.HasLoggedOnce(lvl, "test-key") {
+			t.Errorf("(%s) expected the key to be remembered", lvl)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+
+	zl := &Logger{}
+	if zl.Enabled("error") || zl.Enabled("fatal") {
+		t.Error("expected the zero value Logger to be disabled")
+	}
+	var nl *Logger
+	if nl.Enabled("error") {
+		t.Error("expected a nil Logger to be disabled")
+	}
+	nl.Trace("test entry", nil)
+
+	l := NoopLogger()
+
+	tests := []struct {
+		level    string
+		enabled  []string
+		disabled []string
+	}{
+		{"trace", []string{"trace", "debug", "info", "warn", "error", "fatal"}, nil},
+		{"debug", []string{"debug", "info", "error", "fatal"}, []string{"trace"}},
+		{"info", []string{"info", "warn", "error"}, []string{"trace", "debug"}},
+		{"WARN", []string{"warn", "ERROR"}, []string{"info", "invalid"}},
+		{"error", []string{"error", "fatal"}, []string{"warn"}},
+		{"none", []string{"fatal"}, []string{"error"}},
+		{"invalid", []string{"info"}, []string{"debug"}},
+	}
+
+	for _, test := range tests {
+		child := l.With(Pairs{"a": 1})
+		l.SetLogLevel(test.level)
+		for _, lvl := range test.enabled {
+			if !child.Enabled(lvl) {
+				t.Errorf("(%s) expected %s to be enabled", test.level, lvl)
+			}
+		}
+		for _, lvl := range test.disabled {
+			if child.Enabled(lvl) {
+				t.Errorf("(%s) expected %s to be disabled", test.level, lvl)
+			}
+		}
+	}
+}
+
+func TestLazyPairs(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "logfmt")
+	l.SetLogLevel("info")
+
+	var calls int
+	lazy := func() interface{} {
+		calls++
+		return "http://127.0.0.1/?api_key=secret"
+	}
+	detail := Pairs{"url": lazy, "code": 200}
+
+	// the lazy value of a filtered event is not evaluated
+	l.Debug("test entry", detail)
+	l.Trace("test entry", detail)
+	if calls != 0 || buf.Len() != 0 {
+		t.Errorf("expected no evaluation got %d: %s", calls, buf.String())
+	}
+
+	// the lazy value of a sent event is evaluated, then redacted
+	l.With(Pairs{"lazyDefault": lazy}).Info("test entry", detail)
+	if calls != 2 {
+		t.Errorf("expected %d got %d", 2, calls)
+	}
+	if s := buf.String(); !strings.Contains(s, "code=200") ||
+		!strings.Contains(s, "lazyDefault=\"http://127.0.0.1/?api_key=[REDACTED]\"") ||
+		!strings.Contains(s, "url=\"http://127.0.0.1/?api_key=[REDACTED]\"") {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	// the caller's detail is not modified
+	if _, ok := detail["url"].(func() interface{}); !ok {
+		t.Errorf("unexpected detail %v", detail)
+	}
+}
+
+func BenchmarkFilteredDebugLazy(b *testing.B) {
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(ioutil.Discard, "logfmt")
+	l.SetLogLevel("info")
+	detail := Pairs{"timeseries": func() interface{} { return strings.Repeat("x", 1024) }}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Debug("test entry", detail)
+	}
+}
+
+func BenchmarkDebugLazy(b *testing.B) {
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(ioutil.Discard, "logfmt")
+	l.SetLogLevel("debug")
+	detail := Pairs{"timeseries": func() interface{} { return strings.Repeat("x", 1024) }}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Debug("test entry", detail)
+	}
+}
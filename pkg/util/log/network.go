@@ -0,0 +1,160 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// networkWriteTimeout bounds the time taken to write an event to the network endpoint, after
+// which the endpoint is reconnected
+const networkWriteTimeout = 5 * time.Second
+
+// The bounds of the exponential backoff between attempts to connect to the network endpoint,
+// which tests may replace
+var (
+	networkBackoffMin = 100 * time.Millisecond
+	networkBackoffMax = 30 * time.Second
+)
+
+// dialNetwork connects to the network endpoint, and may be replaced by tests
+var dialNetwork = func(network, address string) (net.Conn, error) {
+	return net.DialTimeout(network, address, networkWriteTimeout)
+}
+
+// networkWriter writes newline-delimited events to a network endpoint, such as a Logstash TCP
+// input, from a dedicated goroutine. The events written while the endpoint is being connected
+// are buffered, and those beyond the buffer are dropped and counted
+type networkWriter struct {
+	network string
+	address string
+	events  chan []byte
+	// dropped is the count of dropped events, which is that of the Logger
+	dropped *uint64
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// newNetworkWriter returns a networkWriter that buffers up to size events for the endpoint,
+// and counts the events it drops in dropped. The endpoint is connected in the background
+func newNetworkWriter(network, address string, size int, dropped *uint64) *networkWriter {
+	nw := &networkWriter{network: network, address: address, events: make(chan []byte, size),
+		dropped: dropped, stop: make(chan struct{}), done: make(chan struct{})}
+	go nw.run()
+	return nw
+}
+
+// Write buffers a copy of the encoded event, which is dropped when the buffer is full
+func (nw *networkWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	select {
+	case nw.events <- b:
+	default:
+		atomic.AddUint64(nw.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// run writes the buffered events to the endpoint, reconnecting to it with exponential backoff
+// when it can't be connected or written, until the networkWriter is closed. An event whose
+// write failed is written again once the endpoint is reconnected
+func (nw *networkWriter) run() {
+	defer close(nw.done)
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	backoff := networkBackoffMin
+	var event []byte
+	for {
+		if event == nil {
+			select {
+			case event = <-nw.events:
+			case <-nw.stop:
+				nw.drain(conn)
+				return
+			}
+		}
+		if conn == nil {
+			c, err := dialNetwork(nw.network, nw.address)
+			if err != nil {
+				select {
+				case <-time.After(backoff):
+				case <-nw.stop:
+					return
+				}
+				if backoff *= 2; backoff > networkBackoffMax {
+					backoff = networkBackoffMax
+				}
+				continue
+			}
+			conn = c
+			backoff = networkBackoffMin
+		}
+		if err := writeEvent(conn, event); err != nil {
+			conn.Close()
+			conn = nil
+			continue
+		}
+		event = nil
+	}
+}
+
+// drain writes the events remaining in the buffer when the networkWriter is closed, without
+// reconnecting the endpoint
+func (nw *networkWriter) drain(conn net.Conn) {
+	if conn == nil {
+		return
+	}
+	for {
+		select {
+		case event := <-nw.events:
+			if writeEvent(conn, event) != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// writeEvent writes the event to the connection, terminating it with a newline when the encoder
+// has not
+func writeEvent(conn net.Conn, event []byte) error {
+	if len(event) == 0 || event[len(event)-1] != '\n' {
+		event = append(event, '\n')
+	}
+	conn.SetWriteDeadline(time.Now().Add(networkWriteTimeout))
+	_, err := conn.Write(event)
+	return err
+}
+
+// Close writes the buffered events while the endpoint is connected, and stops the goroutine
+// that writes them
+func (nw *networkWriter) Close() error {
+	nw.closeOnce.Do(func() { close(nw.stop) })
+	<-nw.done
+	return nil
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+)
+
+// testNetworkServer receives newline-delimited events over TCP from the network log target
+type testNetworkServer struct {
+	ln    net.Listener
+	conns chan net.Conn
+	lines chan string
+}
+
+func newTestNetworkServer(t *testing.T, address string) *testNetworkServer {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testNetworkServer{ln: ln, conns: make(chan net.Conn, 10), lines: make(chan string, 100)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.conns <- conn
+			go func() {
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					s.lines <- line
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+// close closes the listener and its accepted connections
+func (s *testNetworkServer) close() {
+	s.ln.Close()
+	for {
+		select {
+		case conn := <-s.conns:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// receive returns the next line that contains the substring
+func (s *testNetworkServer) receive(t *testing.T, substr string) string {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line := <-s.lines:
+			if strings.Contains(line, substr) {
+				return line
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", substr)
+			return ""
+		}
+	}
+}
+
+func TestNetworkTarget(t *testing.T) {
+
+	min, max := networkBackoffMin, networkBackoffMax
+	networkBackoffMin, networkBackoffMax = 10*time.Millisecond, 50*time.Millisecond
+	defer func() { networkBackoffMin, networkBackoffMax = min, max }()
+
+	s := newTestNetworkServer(t, "127.0.0.1:0")
+	address := s.ln.Addr().String()
+
+	conf := config.NewConfig()
+	conf.Logging.LogTarget = "network"
+	conf.Logging.LogTargetNetwork = "tcp"
+	conf.Logging.LogTargetAddress = address
+	conf.Logging.LogTargetBufferSize = 100
+	conf.Logging.LogFormat = "json"
+	l := New(conf)
+
+	l.Info("test entry before", Pairs{"testKey": "testVal"})
+	if line := s.receive(t, "test entry before"); !strings.Contains(line, `"testKey":"testVal"`) ||
+		!strings.HasSuffix(line, "}\n") {
+		t.Errorf("unexpected line %s", line)
+	}
+
+	// the endpoint is restarted mid-stream. the events logged while it is down are buffered,
+	// other than those written to the connection before its drop was detected
+	s.close()
+	for i := 0; i < 10; i++ {
+		l.Info("test entry during", Pairs{"i": i})
+		time.Sleep(10 * time.Millisecond)
+	}
+	s = newTestNetworkServer(t, address)
+	defer s.close()
+	s.receive(t, `"i":9`)
+	l.Info("test entry after", nil)
+	s.receive(t, "test entry after")
+
+	// Close stops the goroutine once the buffered events are written
+	l.Info("test entry closing", nil)
+	l.Close()
+	s.receive(t, "test entry closing")
+	if n := l.Counts()[CountDropped]; n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+}
+
+func TestNetworkTargetDropped(t *testing.T) {
+
+	dial := dialNetwork
+	defer func() { dialNetwork = dial }()
+	dials := make(chan bool, 100)
+	dialNetwork = func(network, address string) (net.Conn, error) {
+		dials <- true
+		return nil, errors.New("test dial error")
+	}
+
+	var dropped uint64
+	nw := newNetworkWriter("tcp", "127.0.0.1:1", 2, &dropped)
+	// the first event is taken from the buffer to be written once the endpoint is connected
+	nw.Write([]byte("test entry 0\n"))
+	<-dials
+	for i := 1; i < 6; i++ {
+		nw.Write([]byte("test entry " + strconv.Itoa(i) + "\n"))
+	}
+	if dropped != 3 {
+		t.Errorf("expected %d got %d", 3, dropped)
+	}
+
+	// Close stops the goroutine while the endpoint is unreachable
+	closed := make(chan bool)
+	go func() {
+		nw.Close()
+		closed <- true
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for close")
+	}
+	nw.Close()
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// SubsystemKey is the key of the default Pair of a child Logger that names the subsystem of its
+// events, whose level override is applied in place of that of their call site
+const SubsystemKey = "subsystem"
+
+const (
+	// pkgPrefix is the prefix of the import paths of Trickster's packages, which is trimmed from
+	// the package path of a call site to name its subsystem
+	pkgPrefix = "github.com/tricksterproxy/trickster/pkg/"
+	// loggerMethodPrefix is the prefix of the function names of the Logger's methods, whose frames
+	// are skipped to find the call site of an event
+	loggerMethodPrefix = pkgPrefix + "util/log.(*Logger)."
+)
+
+const (
+	// siteInternal marks a pc of the Logger's methods, which is skipped to find the call site
+	siteInternal = -2
+	// siteNoOverride marks a call site whose subsystem has no level override
+	siteNoOverride = -1
+)
+
+const (
+	// callerBatch is the number of frames that are walked at a time to find the call site of an
+	// event, which is small since the cost of a walk grows with its frames, and most call sites
+	// are within the first batch
+	callerBatch = 3
+	// maxCallerFrames is the maximum number of frames that are walked to find the call site
+	maxCallerFrames = 12
+)
+
+// levelOverrides are the ranks of the subsystems whose levels override that of the Logger. The
+// override of each call site is resolved once, and remembered by its pc, so that the lookup of
+// an event's override costs a short walk of the stack and a map read
+type levelOverrides struct {
+	ranks map[string]int
+
+	sitesMtx sync.RWMutex
+	sites    map[uintptr]int
+}
+
+// newLevelOverrides returns the level overrides for the levels by subsystem, or nil when there
+// are none. Subsystems are named by their package paths relative to pkg/, with their elements
+// separated by dots, such as proxy.engines
+func newLevelOverrides(overrides map[string]string) *levelOverrides {
+	if len(overrides) == 0 {
+		return nil
+	}
+	lo := &levelOverrides{ranks: make(map[string]int, len(overrides)),
+		sites: make(map[uintptr]int)}
+	for k, v := range overrides {
+		lo.ranks[strings.Trim(strings.Replace(strings.ToLower(k), "/", ".", -1), ".")] = minRank(v)
+	}
+	return lo
+}
+
+// minRank returns the rank of the least severe events that pass the level filter of the level
+func minRank(lvl string) int {
+	switch lvl = strings.ToLower(lvl); lvl {
+	case "none":
+		return rankFatal
+	case "trace", "debug", "warn", "error":
+		return levelRanks[lvl]
+	default:
+		return rankInfo
+	}
+}
+
+// lookup returns the rank of the most specific override of the subsystem, which includes the
+// overrides of its parent subsystems, and false when it has none
+func (lo *levelOverrides) lookup(subsystem string) (int, bool) {
+	for subsystem != "" {
+		if r, ok := lo.ranks[subsystem]; ok {
+			return r, true
+		}
+		i := strings.LastIndexByte(subsystem, '.')
+		if i < 0 {
+			break
+		}
+		subsystem = subsystem[:i]
+	}
+	return 0, false
+}
+
+// rank returns the rank of the override of an event, and false when it has none. The subsystem
+// is that of a child Logger, and when empty, the subsystem of the event's call site is used,
+// which is the first caller outside of the Logger's methods
+func (lo *levelOverrides) rank(subsystem string) (int, bool) {
+	if subsystem != "" {
+		return lo.lookup(subsystem)
+	}
+	var pcs [callerBatch]uintptr
+	// skip runtime.Callers and this method
+	for skip := 2; skip < maxCallerFrames; skip += callerBatch {
+		n := runtime.Callers(skip, pcs[:])
+		for _, pc := range pcs[:n] {
+			switch r := lo.site(pc); r {
+			case siteInternal:
+				continue
+			case siteNoOverride:
+				return 0, false
+			default:
+				return r, true
+			}
+		}
+		if n < callerBatch {
+			break
+		}
+	}
+	return 0, false
+}
+
+// site returns the rank of the override of the call site at the pc, or siteNoOverride when it has
+// none, or siteInternal when the pc is within the Logger's methods
+func (lo *levelOverrides) site(pc uintptr) int {
+	lo.sitesMtx.RLock()
+	r, ok := lo.sites[pc]
+	lo.sitesMtx.RUnlock()
+	if ok {
+		return r
+	}
+	r = siteInternal
+	// the frames of the pc include those of the functions that are inlined at it
+	frames := runtime.CallersFrames([]uintptr{pc})
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, loggerMethodPrefix) {
+			r = siteNoOverride
+			if rank, ok := lo.lookup(subsystemOf(f.Function)); ok {
+				r = rank
+			}
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	lo.sitesMtx.Lock()
+	lo.sites[pc] = r
+	lo.sitesMtx.Unlock()
+	return r
+}
+
+// subsystemOf returns the subsystem of the function, which is its package path relative to pkg/,
+// with its elements separated by dots
+func subsystemOf(function string) string {
+	// the package path ends at the first dot after its last slash
+	i := strings.LastIndexByte(function, '/')
+	if j := strings.IndexByte(function[i+1:], '.'); j >= 0 {
+		function = function[:i+1+j]
+	}
+	return strings.Replace(strings.TrimPrefix(function, pkgPrefix), "/", ".", -1)
+}
+
+// SetLevelOverrides sets the levels of the subsystems whose events are filtered by them rather
+// than by the Logger's level, replacing any previous overrides. Subsystems are named by their
+// package paths relative to pkg/, with their elements separated by dots, and include their
+// subpackages. The subsystem of an event is that of its call site, unless it is sent to a child
+// Logger with a SubsystemKey default. Nil or empty overrides remove them
+func (tl *Logger) SetLevelOverrides(overrides map[string]string) {
+	lo := newLevelOverrides(overrides)
+	tl = tl.shared()
+	tl.levelMtx.Lock()
+	defer tl.levelMtx.Unlock()
+	tl.overrides = lo
+}
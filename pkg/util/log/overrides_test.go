@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+)
+
+func TestLevelOverrides(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "logfmt")
+	l.SetLogLevel("info")
+
+	// the events of this package's tests are sent from the util.log subsystem
+	l.SetLevelOverrides(map[string]string{"util.log": "debug", "cache.redis": "warn"})
+	l.Debug("test debug", nil)
+	if s := buf.String(); !strings.Contains(s, `level=debug event="test debug"`) ||
+		!strings.Contains(s, "caller=util/log/overrides_test.go") {
+		t.Errorf("unexpected output %s", s)
+	}
+	if !l.Enabled("debug") || l.Enabled("trace") {
+		t.Error("expected debug to be enabled and trace to be disabled")
+	}
+
+	// the Once functions are attributed to their callers
+	buf.Reset()
+	l.DebugOnce("test", "test debug once", nil)
+	if s := buf.String(); !strings.Contains(s, `event="test debug once"`) {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	// the subsystem of a child Logger overrides that of its call site
+	buf.Reset()
+	redis := l.With(Pairs{SubsystemKey: "cache.redis"})
+	redis.Info("test info", nil)
+	redis.Debug("test debug", nil)
+	if buf.Len() != 0 {
+		t.Errorf("unexpected output %s", buf.String())
+	}
+	redis.Warn("test warn", nil)
+	if s := buf.String(); !strings.Contains(s, `level=warn event="test warn"`) ||
+		!strings.Contains(s, "subsystem=cache.redis") {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	// subsystems without overrides are filtered by the log level
+	buf.Reset()
+	l.With(Pairs{SubsystemKey: "proxy.engines"}).Debug("test debug", nil)
+	if buf.Len() != 0 {
+		t.Errorf("unexpected output %s", buf.String())
+	}
+
+	// the overrides of a parent subsystem apply to its subpackages, and the most specific applies
+	l.SetLevelOverrides(map[string]string{"util": "trace", "proxy": "error", "proxy.engines": "none"})
+	buf.Reset()
+	l.Trace("test trace", nil)
+	l.With(Pairs{SubsystemKey: "proxy.origins.prometheus"}).Warn("test warn", nil)
+	l.With(Pairs{SubsystemKey: "proxy.engines"}).Error("test error", nil)
+	if s := buf.String(); strings.Count(s, "\n") != 1 || !strings.Contains(s, `level=trace event="test trace"`) {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	// removing the overrides restores the log level
+	l.SetLevelOverrides(nil)
+	buf.Reset()
+	l.Debug("test debug", nil)
+	if buf.Len() != 0 || l.Enabled("debug") {
+		t.Errorf("unexpected output %s", buf.String())
+	}
+}
+
+func TestNewLevelOverrides(t *testing.T) {
+
+	conf := config.NewConfig()
+	conf.Logging.LogLevelOverrides = map[string]string{"util/log": "WARN"}
+	l := New(conf)
+	if l.Enabled("info") || !l.Enabled("warn") {
+		t.Error("expected info to be disabled and warn to be enabled")
+	}
+
+	if newLevelOverrides(nil) != nil {
+		t.Error("expected nil overrides")
+	}
+}
+
+func TestSubsystemOf(t *testing.T) {
+	tests := []struct {
+		function, expected string
+	}{
+		{pkgPrefix + "proxy/engines.(*HTTPDocument).Clone", "proxy.engines"},
+		{pkgPrefix + "proxy/engines.DeltaProxyCacheRequest.func1", "proxy.engines"},
+		{pkgPrefix + "cache/redis.New", "cache.redis"},
+		{"main.runConfig", "main"},
+	}
+	for _, test := range tests {
+		if s := subsystemOf(test.function); s != test.expected {
+			t.Errorf("expected %s got %s", test.expected, s)
+		}
+	}
+}
+
+func BenchmarkFilteredDebug(b *testing.B) {
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(ioutil.Discard, "logfmt")
+	l.SetLogLevel("info")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Debug("test entry", nil)
+	}
+}
+
+func BenchmarkFilteredDebugOverrides(b *testing.B) {
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(ioutil.Discard, "logfmt")
+	l.SetLogLevel("info")
+	l.SetLevelOverrides(map[string]string{"proxy.engines": "debug", "cache.redis": "warn"})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Debug("test entry", nil)
+	}
+}
+
+func BenchmarkFilteredDebugOverridden(b *testing.B) {
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(ioutil.Discard, "logfmt")
+	l.SetLogLevel("debug")
+	l.SetLevelOverrides(map[string]string{"util.log": "info"})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Debug("test entry", nil)
+	}
+}
+
+func BenchmarkFilteredDebugSubsystem(b *testing.B) {
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(ioutil.Discard, "logfmt")
+	l.SetLogLevel("debug")
+	l.SetLevelOverrides(map[string]string{"cache": "info"})
+	child := l.With(Pairs{SubsystemKey: "cache.redis"})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		child.Debug("test entry", nil)
+	}
+}
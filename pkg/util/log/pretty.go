@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+)
+
+// prettyTimeFormat is the format of the local time of the events of the pretty format
+const prettyTimeFormat = "15:04:05.000"
+
+const (
+	colorReset = "\x1b[0m"
+	colorDim   = "\x1b[2m"
+)
+
+// prettyColors are the ANSI colors of the level tags of the pretty format, by level
+var prettyColors = map[string]string{
+	"trace": "\x1b[90m",   // gray
+	"debug": "\x1b[36m",   // cyan
+	"info":  "\x1b[32m",   // green
+	"warn":  "\x1b[33m",   // yellow
+	"error": "\x1b[31m",   // red
+	"fatal": "\x1b[1;31m", // bold red
+}
+
+// prettyLogger encodes events for reading on Console, as a level tag, the short local time, the
+// event, and then its remaining Pairs in logfmt. The level tag is colored, and the Pairs dimmed,
+// when color is enabled
+type prettyLogger struct {
+	mtx   sync.Mutex
+	w     io.Writer
+	color bool
+	buf   bytes.Buffer
+}
+
+// newPrettyLogger returns a prettyLogger that writes to the writer, with color enabled when the
+// writer is a terminal and the NO_COLOR environment variable is not set
+func newPrettyLogger(w io.Writer) *prettyLogger {
+	return &prettyLogger{w: w, color: isTerminal(w) && os.Getenv("NO_COLOR") == ""}
+}
+
+// isTerminal returns true if the writer is a file that is a terminal, including when its writes
+// are buffered by an asyncWriter
+func isTerminal(w io.Writer) bool {
+	if aw, ok := w.(*asyncWriter); ok {
+		w = aw.w
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Log encodes the keyvals and writes them to the writer. The time of the event is replaced by the
+// local time at which it is written, and its app is omitted
+func (l *prettyLogger) Log(keyvals ...interface{}) error {
+	var lvl, event string
+	pairs := make([]interface{}, 0, len(keyvals))
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		switch fmt.Sprint(keyvals[i]) {
+		case "time", "app":
+		case "level":
+			lvl = strings.ToLower(fmt.Sprint(keyvals[i+1]))
+		case "event":
+			event = fmt.Sprint(keyvals[i+1])
+		default:
+			pairs = append(pairs, keyvals[i], keyvals[i+1])
+		}
+	}
+	detail, err := logfmt.MarshalKeyvals(pairs...)
+	if err != nil {
+		return err
+	}
+	tag := fmt.Sprintf("%-5s", strings.ToUpper(lvl))
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.buf.Reset()
+	if l.color {
+		fmt.Fprintf(&l.buf, "%s%s%s %s%s%s %s", prettyColors[lvl], tag, colorReset,
+			colorDim, time.Now().Format(prettyTimeFormat), colorReset, event)
+		if len(detail) > 0 {
+			fmt.Fprintf(&l.buf, "  %s%s%s", colorDim, detail, colorReset)
+		}
+	} else {
+		fmt.Fprintf(&l.buf, "%s %s %s", tag, time.Now().Format(prettyTimeFormat), event)
+		if len(detail) > 0 {
+			fmt.Fprintf(&l.buf, "  %s", detail)
+		}
+	}
+	l.buf.WriteByte('\n')
+	_, err = l.w.Write(l.buf.Bytes())
+	return err
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+)
+
+func TestPrettyLogger(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	stdout = buf
+	defer func() { stdout = os.Stdout }()
+
+	conf := config.NewConfig()
+	conf.Logging.LogFormat = "pretty"
+	l := New(conf)
+	l.Debug("test debug", nil)
+	l.Warn("test entry", Pairs{"testKey": "test val"})
+	l.WarnOnce("test-key", "test once", nil)
+	l.WarnOnce("test-key", "test once", nil)
+
+	// a buffer is not a terminal, so the output is not colored
+	re := regexp.MustCompile(`^WARN  \d{2}:\d{2}:\d{2}\.\d{3} test entry  caller=util/log/pretty_test.go:\d+ testKey="test val"$`)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 || !re.MatchString(lines[0]) || !strings.Contains(lines[1], " test once  caller=") {
+		t.Errorf("unexpected output %s", buf.String())
+	}
+
+	buf.Reset()
+	pl := newPrettyLogger(buf)
+	pl.color = true
+	pl.Log("time", "now", "app", "trickster", "level", "error", "event", "test entry")
+	if s := buf.String(); !strings.HasPrefix(s, "\x1b[31mERROR\x1b[0m \x1b[2m") ||
+		!strings.HasSuffix(s, "\x1b[0m test entry\n") {
+		t.Errorf("unexpected output %q", s)
+	}
+}
+
+func TestPrettyLoggerColor(t *testing.T) {
+
+	if newPrettyLogger(&bytes.Buffer{}).color {
+		t.Error("expected color to be disabled for a buffer")
+	}
+
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !isTerminal(f) {
+		t.Skip("the null device is not a character device")
+	}
+	os.Setenv("NO_COLOR", "1")
+	defer os.Unsetenv("NO_COLOR")
+	if newPrettyLogger(f).color {
+		t.Error("expected color to be disabled by NO_COLOR")
+	}
+}
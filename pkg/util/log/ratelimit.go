@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import "time"
+
+// rateLimitSweepInterval is the minimum interval at which idle rate-limited keys are expired
+const rateLimitSweepInterval = time.Minute
+
+// rateLimitEntry is the state of a rate-limited key
+type rateLimitEntry struct {
+	// window is the key's most recent window
+	window time.Duration
+	// next is the time from which the key may next emit an event
+	next time.Time
+	// suppressed is the number of events suppressed since the key's last emitted event
+	suppressed int
+}
+
+// InfoRateLimited sends an "INFO" event to the Logger at most once per window for each key. The
+// number of events suppressed since the key's last event is attached as a "suppressed" Pair.
+// Returns true if the event was sent to the Logger
+func (tl *Logger) InfoRateLimited(key string, window time.Duration, event string,
+	detail Pairs) bool {
+	if suppressed, ok := tl.rateLimit("info."+key, window); ok {
+		tl.Info(event, withSuppressed(detail, suppressed))
+		return true
+	}
+	return false
+}
+
+// WarnRateLimited sends a "WARN" event to the Logger at most once per window for each key. The
+// number of events suppressed since the key's last event is attached as a "suppressed" Pair.
+// Returns true if the event was sent to the Logger
+func (tl *Logger) WarnRateLimited(key string, window time.Duration, event string,
+	detail Pairs) bool {
+	if suppressed, ok := tl.rateLimit("warn."+key, window); ok {
+		tl.Warn(event, withSuppressed(detail, suppressed))
+		return true
+	}
+	return false
+}
+
+// ErrorRateLimited sends an "ERROR" event to the Logger at most once per window for each key. The
+// number of events suppressed since the key's last event is attached as a "suppressed" Pair.
+// Returns true if the event was sent to the Logger
+func (tl *Logger) ErrorRateLimited(key string, window time.Duration, event string,
+	detail Pairs) bool {
+	if suppressed, ok := tl.rateLimit("error."+key, window); ok {
+		tl.Error(event, withSuppressed(detail, suppressed))
+		return true
+	}
+	return false
+}
+
+// rateLimit returns true if an event for the key may be emitted in its window, along with the
+// number of events that were suppressed since the key's last emitted event. Keys that have been
+// idle for a full window after they could next emit are forgotten, along with their suppressed
+// counts, so that the keys of transient conditions do not accumulate
+func (tl *Logger) rateLimit(key string, window time.Duration) (int, bool) {
+	tl = tl.shared()
+	now := time.Now()
+	tl.rateLimitMtx.Lock()
+	defer tl.rateLimitMtx.Unlock()
+	if tl.rateLimitEntries == nil {
+		tl.rateLimitEntries = make(map[string]*rateLimitEntry)
+	}
+	if now.Sub(tl.rateLimitSwept) >= rateLimitSweepInterval {
+		for k, e := range tl.rateLimitEntries {
+			if now.After(e.next.Add(e.window)) {
+				delete(tl.rateLimitEntries, k)
+			}
+		}
+		tl.rateLimitSwept = now
+	}
+	e, ok := tl.rateLimitEntries[key]
+	if !ok {
+		e = &rateLimitEntry{}
+		tl.rateLimitEntries[key] = e
+	}
+	e.window = window
+	if now.Before(e.next) {
+		e.suppressed++
+		return 0, false
+	}
+	suppressed := e.suppressed
+	e.suppressed = 0
+	e.next = now.Add(window)
+	return suppressed, true
+}
+
+// withSuppressed returns a copy of the detail with the suppressed count attached, or the detail
+// when no events were suppressed
+func withSuppressed(detail Pairs, suppressed int) Pairs {
+	if suppressed == 0 {
+		return detail
+	}
+	d := make(Pairs, len(detail)+1)
+	for k, v := range detail {
+		d[k] = v
+	}
+	d["suppressed"] = suppressed
+	return d
+}
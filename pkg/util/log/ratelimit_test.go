@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRateLimited(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := NoopLogger()
+	l.baseLogger = newBaseLogger(buf, "json")
+	l.SetLogLevel("info")
+
+	event := func() map[string]interface{} {
+		m := make(map[string]interface{})
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatalf("%s: %s", err, buf.String())
+		}
+		buf.Reset()
+		return m
+	}
+
+	funcs := map[string]func(string, time.Duration, string, Pairs) bool{
+		"info":  l.InfoRateLimited,
+		"warn":  l.WarnRateLimited,
+		"error": l.ErrorRateLimited,
+	}
+
+	for lvl, f := range funcs {
+		if !f("test-key", time.Hour, "test entry", Pairs{"testKey": "testVal"}) {
+			t.Errorf("(%s) expected event to be sent", lvl)
+		}
+		m := event()
+		if m["level"] != lvl || m["testKey"] != "testVal" {
+			t.Errorf("(%s) unexpected event %v", lvl, m)
+		}
+		if _, ok := m["suppressed"]; ok {
+			t.Errorf("(%s) unexpected suppressed count %v", lvl, m)
+		}
+		for i := 0; i < 3; i++ {
+			if f("test-key", time.Hour, "test entry", nil) {
+				t.Errorf("(%s) expected event to be suppressed", lvl)
+			}
+		}
+		if buf.Len() != 0 {
+			t.Errorf("(%s) unexpected output %s", lvl, buf.String())
+		}
+	}
+
+	// a child logger shares the rate-limited keys of its root
+	if l.With(Pairs{"originName": "prom1"}).WarnRateLimited("test-key", time.Hour, "test entry", nil) {
+		t.Error("expected event to be suppressed")
+	}
+
+	// once the window elapses, the suppressed count is attached to the next event
+	l.rateLimitMtx.Lock()
+	l.rateLimitEntries["warn.test-key"].next = time.Now()
+	l.rateLimitMtx.Unlock()
+	detail := Pairs{"testKey": "testVal"}
+	if !l.WarnRateLimited("test-key", time.Hour, "test entry", detail) {
+		t.Error("expected event to be sent")
+	}
+	m := event()
+	if m["suppressed"] != float64(4) {
+		t.Errorf("expected %d got %v", 4, m["suppressed"])
+	}
+	if _, ok := detail["suppressed"]; ok {
+		t.Error("expected the caller's detail to be unmodified")
+	}
+}
+
+func TestRateLimitExpiry(t *testing.T) {
+
+	l := NoopLogger()
+	l.rateLimit("idle", time.Millisecond)
+	l.rateLimit("active", time.Hour)
+	l.rateLimit("active", time.Hour)
+
+	// idle keys are expired by the next sweep
+	time.Sleep(5 * time.Millisecond)
+	l.rateLimitSwept = time.Time{}
+	l.rateLimit("other", time.Hour)
+
+	if _, ok := l.rateLimitEntries["idle"]; ok {
+		t.Error("expected idle key to be expired")
+	}
+	if e, ok := l.rateLimitEntries["active"]; !ok || e.suppressed != 1 {
+		t.Errorf("expected active key to be retained, got %v", e)
+	}
+
+	// a non-positive window does not limit events
+	for i := 0; i < 3; i++ {
+		if _, ok := l.rateLimit("unlimited", 0); !ok {
+			t.Error("expected event to be sent")
+		}
+	}
+}
@@ -0,0 +1,267 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redactedValue replaces the values of sensitive keys, headers and URL query parameters
+const redactedValue = "[REDACTED]"
+
+// redactor replaces the values of sensitive keys, headers and URL query parameters in log
+// events. A name is sensitive when it is one of the keys, or ends with one of the keys preceded
+// by a - or _, case-insensitively, so that the key token matches the X-Api-Token header and the
+// access_token query parameter
+type redactor struct {
+	keys []string
+}
+
+// newRedactor returns a redactor of the keys, or nil when there are no keys
+func newRedactor(keys []string) *redactor {
+	rd := &redactor{keys: make([]string, 0, len(keys))}
+	for _, k := range keys {
+		if k = strings.TrimSpace(k); k != "" {
+			rd.keys = append(rd.keys, strings.ToLower(k))
+		}
+	}
+	if len(rd.keys) == 0 {
+		return nil
+	}
+	return rd
+}
+
+// matches returns true if the name is sensitive
+func (rd *redactor) matches(name string) bool {
+	if rd == nil {
+		return false
+	}
+	for _, k := range rd.keys {
+		if len(name) < len(k) || !strings.EqualFold(name[len(name)-len(k):], k) {
+			continue
+		}
+		if len(name) == len(k) {
+			return true
+		}
+		if c := name[len(name)-len(k)-1]; c == '-' || c == '_' {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns the detail with the values of its sensitive keys, headers and URL query
+// parameters replaced. The detail is copied when any value is replaced, so that the caller's
+// detail is not modified
+func (rd *redactor) redact(detail Pairs) Pairs {
+	if rd == nil {
+		return detail
+	}
+	var out Pairs
+	for k, v := range detail {
+		rv, ok := rd.redactValue(k, v)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make(Pairs, len(detail))
+			for k2, v2 := range detail {
+				out[k2] = v2
+			}
+		}
+		out[k] = rv
+	}
+	if out == nil {
+		return detail
+	}
+	return out
+}
+
+// redactValue returns the value of the key with its sensitive parts replaced, and true when it
+// had any
+func (rd *redactor) redactValue(k string, v interface{}) (interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	if rd.matches(k) {
+		return redactedValue, true
+	}
+	switch tv := v.(type) {
+	case string:
+		return rd.redactString(tv)
+	case http.Header:
+		return rd.redactHeader(tv)
+	case url.Values:
+		return rd.redactValues(tv)
+	case *url.URL:
+		if tv == nil {
+			return v, false
+		}
+		return rd.redactURL(tv.String())
+	case url.URL:
+		return rd.redactURL(tv.String())
+	}
+	return v, false
+}
+
+// redactHeader returns a copy of the header with the values of its sensitive names replaced
+func (rd *redactor) redactHeader(h http.Header) (http.Header, bool) {
+	var out http.Header
+	for k := range h {
+		if !rd.matches(k) {
+			continue
+		}
+		if out == nil {
+			out = h.Clone()
+		}
+		out[k] = []string{redactedValue}
+	}
+	if out == nil {
+		return h, false
+	}
+	return out, true
+}
+
+// redactValues returns a copy of the values with the values of its sensitive names replaced
+func (rd *redactor) redactValues(v url.Values) (url.Values, bool) {
+	var out url.Values
+	for k := range v {
+		if !rd.matches(k) {
+			continue
+		}
+		if out == nil {
+			out = make(url.Values, len(v))
+			for k2, v2 := range v {
+				out[k2] = v2
+			}
+		}
+		out[k] = []string{redactedValue}
+	}
+	if out == nil {
+		return v, false
+	}
+	return out, true
+}
+
+// redactString returns the string with its sensitive parts replaced, when it is a URL or the
+// compact representation of a header from headers.LogString
+func (rd *redactor) redactString(s string) (string, bool) {
+	if rd == nil {
+		return s, false
+	}
+	if strings.HasPrefix(s, "{[") && strings.HasSuffix(s, "]}") {
+		return rd.redactLogString(s)
+	}
+	return rd.redactURL(s)
+}
+
+// redactLogString returns the compact representation of a header, of the form
+// {[Name1:value1],[Name2:value2]}, with the values of its sensitive names replaced
+func (rd *redactor) redactLogString(s string) (string, bool) {
+	entries := strings.Split(s[2:len(s)-2], "],[")
+	var changed bool
+	for i, e := range entries {
+		if j := strings.IndexByte(e, ':'); j > 0 && rd.matches(e[:j]) {
+			entries[i] = e[:j+1] + redactedValue
+			changed = true
+		}
+	}
+	if !changed {
+		return s, false
+	}
+	return "{[" + strings.Join(entries, "],[") + "]}", true
+}
+
+// redactURL returns the URL with the password of its userinfo, and the values of its sensitive
+// query parameters, replaced
+func (rd *redactor) redactURL(s string) (string, bool) {
+	var changed bool
+	if i := strings.Index(s, "://"); i > 0 {
+		authority := s[i+3:]
+		if j := strings.IndexAny(authority, "/?#"); j >= 0 {
+			authority = authority[:j]
+		}
+		if at := strings.LastIndexByte(authority, '@'); at >= 0 {
+			if c := strings.IndexByte(authority[:at], ':'); c >= 0 {
+				s = s[:i+3+c+1] + redactedValue + s[i+3+at:]
+				changed = true
+			}
+		}
+	}
+	q := strings.IndexByte(s, '?')
+	if q < 0 {
+		return s, changed
+	}
+	query, fragment := s[q+1:], ""
+	if f := strings.IndexByte(query, '#'); f >= 0 {
+		query, fragment = query[:f], query[f:]
+	}
+	if rq, ok := rd.redactQuery(query); ok {
+		return s[:q+1] + rq + fragment, true
+	}
+	return s, changed
+}
+
+// redactQuery returns the encoded query with the values of its sensitive parameters replaced.
+// The query is not copied unless a value is replaced
+func (rd *redactor) redactQuery(query string) (string, bool) {
+	if rd == nil || query == "" {
+		return query, false
+	}
+	var sb *strings.Builder
+	for i, rest := 0, query; ; {
+		param := rest
+		end := strings.IndexByte(rest, '&')
+		if end >= 0 {
+			param = rest[:end]
+		}
+		name := param
+		eq := strings.IndexByte(param, '=')
+		if eq >= 0 {
+			name = param[:eq]
+		}
+		if strings.ContainsAny(name, "%+") {
+			if n, err := url.QueryUnescape(name); err == nil {
+				name = n
+			}
+		}
+		if eq >= 0 && rd.matches(name) {
+			if sb == nil {
+				sb = &strings.Builder{}
+				sb.WriteString(query[:i])
+			}
+			sb.WriteString(param[:eq+1])
+			sb.WriteString(redactedValue)
+		} else if sb != nil {
+			sb.WriteString(param)
+		}
+		if end < 0 {
+			break
+		}
+		if sb != nil {
+			sb.WriteByte('&')
+		}
+		i += end + 1
+		rest = rest[end+1:]
+	}
+	if sb == nil {
+		return query, false
+	}
+	return sb.String(), true
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"os"
+	"sync"
+)
+
+// reopener is a writer that can reopen its file, such as after the file is rotated externally
+type reopener interface {
+	Reopen() error
+}
+
+// reopenWriter writes to a log file that is rotated externally, such as by logrotate, and
+// reopens the file when Reopen is called once the file has been rotated
+type reopenWriter struct {
+	mtx  sync.Mutex
+	path string
+	f    *os.File
+}
+
+// openLogFile opens the log file for appending, creating it if it does not exist
+func openLogFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+// newReopenWriter returns a reopenWriter to the log file
+func newReopenWriter(path string) (*reopenWriter, error) {
+	f, err := openLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &reopenWriter{path: path, f: f}, nil
+}
+
+// Write writes to the open log file. Writes are serialized with Reopen, so that no write is
+// dropped or interleaved while the file is swapped
+func (w *reopenWriter) Write(p []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+	return w.f.Write(p)
+}
+
+// Reopen opens the log file again at its path, and then closes the previously open file. The
+// new file is opened before the lock is taken, so that writes wait only for the swap. When the
+// file can't be opened, the previously open file remains open, and the error is returned. A
+// closed writer is not reopened
+func (w *reopenWriter) Reopen() error {
+	f, err := openLogFile(w.path)
+	if err != nil {
+		return err
+	}
+	w.mtx.Lock()
+	old := w.f
+	if old == nil {
+		// the writer was closed while the file was reopened
+		w.mtx.Unlock()
+		f.Close()
+		return os.ErrClosed
+	}
+	w.f = f
+	w.mtx.Unlock()
+	return old.Close()
+}
+
+// Close closes the open log file
+func (w *reopenWriter) Close() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// Reopen reopens the Logger's log file, when it is rotated externally, such as after it is
+// rotated by logrotate. It does nothing for other Loggers
+func (tl *Logger) Reopen() error {
+	if r, ok := tl.shared().closer.(reopener); ok {
+		return r.Reopen()
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+)
+
+func TestReopen(t *testing.T) {
+
+	dir, err := ioutil.TempDir("/tmp", "trickster-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := config.NewConfig()
+	conf.Logging.LogFile = filepath.Join(dir, "trickster.log")
+	conf.Logging.LogRotation = "external"
+	l := New(conf)
+	if _, ok := l.closer.(*reopenWriter); !ok {
+		t.Fatalf("unexpected closer %T", l.closer)
+	}
+
+	// the file is rotated externally, and reopened at its path
+	l.Info("before rotation", nil)
+	if err := os.Rename(conf.Logging.LogFile, conf.Logging.LogFile+".1"); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("after rename", nil)
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	l.With(Pairs{"testKey": "testVal"}).Info("after reopen", nil)
+
+	b, _ := ioutil.ReadFile(conf.Logging.LogFile + ".1")
+	if s := string(b); strings.Count(s, "\n") != 2 || !strings.Contains(s, "before rotation") ||
+		!strings.Contains(s, "after rename") {
+		t.Errorf("unexpected rotated file %s", s)
+	}
+	b, _ = ioutil.ReadFile(conf.Logging.LogFile)
+	if s := string(b); strings.Count(s, "\n") != 1 || !strings.Contains(s, "after reopen") {
+		t.Errorf("unexpected log file %s", s)
+	}
+
+	// the reopened file is the one closed, and a closed writer is not reopened
+	l.Close()
+	if _, err := l.closer.(*reopenWriter).Write([]byte("test\n")); err != os.ErrClosed {
+		t.Errorf("expected %v got %v", os.ErrClosed, err)
+	}
+	if err := l.Reopen(); err != os.ErrClosed {
+		t.Errorf("expected %v got %v", os.ErrClosed, err)
+	}
+
+	// Loggers that are not rotated externally are not reopened
+	if err := NoopLogger().Reopen(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReopenConcurrency(t *testing.T) {
+
+	dir, err := ioutil.TempDir("/tmp", "trickster-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := config.NewConfig()
+	conf.Logging.LogFile = filepath.Join(dir, "trickster.log")
+	conf.Logging.LogRotation = "external"
+	l := New(conf)
+	defer l.Close()
+
+	const writers, events, rotations = 10, 100, 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < events; j++ {
+				l.Info("test entry", Pairs{"testKey": "testVal"})
+			}
+		}()
+	}
+	for i := 0; i < rotations; i++ {
+		os.Rename(conf.Logging.LogFile, conf.Logging.LogFile+"."+string(rune('a'+i)))
+		if err := l.Reopen(); err != nil {
+			t.Error(err)
+		}
+	}
+	wg.Wait()
+
+	// every event is written whole to one of the files
+	files, _ := filepath.Glob(conf.Logging.LogFile + "*")
+	var n int
+	for _, f := range files {
+		b, _ := ioutil.ReadFile(f)
+		for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			if !strings.HasPrefix(line, "time=") ||
+				!strings.HasSuffix(line, `event="test entry" testKey=testVal`) {
+				t.Errorf("unexpected line %s", line)
+			}
+			n++
+		}
+	}
+	if n != writers*events {
+		t.Errorf("expected %d got %d", writers*events, n)
+	}
+}
+
+func TestReopenOpenFailed(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	stdout = buf
+	defer func() { stdout = os.Stdout }()
+
+	conf := config.NewConfig()
+	conf.Logging.LogFile = "/nonexistent/trickster.log"
+	conf.Logging.LogRotation = "external"
+	l := New(conf)
+	l.Info("test entry", nil)
+	if s := buf.String(); !strings.Contains(s, "unable to open log file, logging to stdout") ||
+		!strings.Contains(s, `event="test entry"`) {
+		t.Errorf("unexpected output %s", s)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"io"
+	"os"
+
+	"github.com/go-kit/kit/log"
+)
+
+// stderr is the Console error writer, which tests may replace
+var stderr io.Writer = os.Stderr
+
+// consoleStream returns the Console stream named by a log_file value of "stdout", "stderr",
+// "/dev/stdout" or "/dev/stderr", and false for a log_file value that names a file
+func consoleStream(logFile string) (io.Writer, bool) {
+	switch logFile {
+	case "stdout", "/dev/stdout":
+		return stdout, true
+	case "stderr", "/dev/stderr":
+		return stderr, true
+	}
+	return nil, false
+}
+
+// splitLogger writes warn, error and fatal events to one logger, and events of other levels to
+// another, such as to split the events written to Console between STDERR and STDOUT. Each logger
+// has its own sync writer, so that the events written to each stream are not interleaved
+type splitLogger struct {
+	out log.Logger
+	err log.Logger
+}
+
+// Log writes the event to the logger for its level
+func (l splitLogger) Log(keyvals ...interface{}) error {
+	switch eventLevel(keyvals) {
+	case "warn", "error", "fatal":
+		return l.err.Log(keyvals...)
+	}
+	return l.out.Log(keyvals...)
+}
+
+// newConsoleEncoder returns a logger that encodes events to Console in the provided format,
+// splitting them between STDERR and STDOUT by level when split is true
+func newConsoleEncoder(format string, split bool) log.Logger {
+	return newBufferedConsoleEncoder(format, split, nil)
+}
+
+// newBufferedConsoleEncoder returns a logger like that of newConsoleEncoder, whose Console
+// streams are each wrapped by buffered, when it is not nil
+func newBufferedConsoleEncoder(format string, split bool,
+	buffered func(io.Writer) io.Writer) log.Logger {
+	out, errw := stdout, stderr
+	if buffered != nil {
+		out = buffered(out)
+		if split {
+			errw = buffered(errw)
+		}
+	}
+	if !split {
+		return newEncoder(out, format)
+	}
+	return splitLogger{out: newEncoder(out, format), err: newEncoder(errw, format)}
+}
+
+// teeLogger writes each event to each of its loggers
+type teeLogger []log.Logger
+
+// Log writes the event to each logger, returning the first error
+func (l teeLogger) Log(keyvals ...interface{}) error {
+	var err error
+	for _, lg := range l {
+		if lerr := lg.Log(keyvals...); lerr != nil && err == nil {
+			err = lerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+)
+
+func TestSplitStreams(t *testing.T) {
+
+	out, errs := &bytes.Buffer{}, &bytes.Buffer{}
+	stdout, stderr = out, errs
+	defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+
+	logAll := func(l *Logger) {
+		l.Trace("trace entry", nil)
+		l.Debug("debug entry", nil)
+		l.Info("info entry", nil)
+		l.Warn("warn entry", nil)
+		l.Error("error entry", nil)
+		l.Fatal(-1, "fatal entry", nil)
+	}
+
+	// by default, all events are written to STDOUT
+	conf := config.NewConfig()
+	conf.Logging.LogLevel = "trace"
+	logAll(New(conf))
+	if n := strings.Count(out.String(), "\n"); n != 6 || errs.Len() != 0 {
+		t.Errorf("unexpected output %s %s", out.String(), errs.String())
+	}
+
+	out.Reset()
+	conf.Logging.SplitStreams = true
+	logAll(New(conf))
+	for _, lvl := range []string{"trace", "debug", "info"} {
+		if !strings.Contains(out.String(), "level="+lvl+" event=\""+lvl+" entry\"") ||
+			strings.Contains(errs.String(), "level="+lvl) {
+			t.Errorf("expected %s in stdout: %s", lvl, out.String())
+		}
+	}
+	for _, lvl := range []string{"warn", "error", "fatal"} {
+		if !strings.Contains(errs.String(), "level="+lvl+" event=\""+lvl+" entry\"") ||
+			strings.Contains(out.String(), "level="+lvl) {
+			t.Errorf("expected %s in stderr: %s", lvl, errs.String())
+		}
+	}
+	// the caller is the call site of each event, in logAll
+	callers := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()+errs.String()), "\n") {
+		i := strings.Index(line, " caller=util/log/streams_test.go:")
+		if i < 0 {
+			t.Errorf("unexpected caller in %s", line)
+			continue
+		}
+		callers[strings.Fields(line[i:])[0]] = true
+	}
+	if len(callers) != 6 {
+		t.Errorf("expected %d got %d", 6, len(callers))
+	}
+}
+
+func TestSplitStreamsLogAlsoStdout(t *testing.T) {
+
+	out, errs := &bytes.Buffer{}, &bytes.Buffer{}
+	stdout, stderr = out, errs
+	defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+
+	dir, err := ioutil.TempDir("/tmp", "trickster-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := config.NewConfig()
+	conf.Logging.LogFile = dir + "/trickster.log"
+	conf.Logging.LogAlsoStdout = true
+	conf.Logging.SplitStreams = true
+	conf.Logging.LogLevel = "info"
+	l := New(conf)
+	l.Info("info entry", nil)
+	l.Error("error entry", nil)
+	l.Close()
+
+	b, err := ioutil.ReadFile(conf.Logging.LogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the file receives every event, and Console receives each in its stream
+	if !strings.Contains(string(b), "info entry") || !strings.Contains(string(b), "error entry") {
+		t.Errorf("unexpected file output %s", string(b))
+	}
+	if !strings.Contains(out.String(), "info entry") || strings.Contains(out.String(), "error entry") {
+		t.Errorf("unexpected stdout output %s", out.String())
+	}
+	if !strings.Contains(errs.String(), "error entry") || strings.Contains(errs.String(), "info entry") {
+		t.Errorf("unexpected stderr output %s", errs.String())
+	}
+}
+
+func TestLogFileStreams(t *testing.T) {
+
+	out, errw := &bytes.Buffer{}, &bytes.Buffer{}
+	stdout, stderr = out, errw
+	defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+
+	for _, test := range []struct {
+		logFile  string
+		expected *bytes.Buffer
+	}{
+		{"stdout", out},
+		{"/dev/stdout", out},
+		{"stderr", errw},
+		{"/dev/stderr", errw},
+	} {
+		out.Reset()
+		errw.Reset()
+		conf := config.NewConfig()
+		conf.Main.InstanceID = 1
+		conf.Logging.LogFile = test.logFile
+		l := New(conf)
+		l.Info("test entry", nil)
+		l.Close()
+		if !strings.Contains(test.expected.String(), `event="test entry"`) ||
+			out.Len()+errw.Len() != test.expected.Len() {
+			t.Errorf("unexpected output for %s: %q %q", test.logFile, out.String(), errw.String())
+		}
+		// no file is created for the stream
+		for _, name := range []string{"stdout", "stderr", "stdout.1", "stderr.1"} {
+			if _, err := os.Stat(name); err == nil {
+				os.Remove(name)
+				t.Errorf("unexpected file %s for %s", name, test.logFile)
+			}
+		}
+	}
+
+	// a file whose path contains the name of a stream is a file
+	dir, err := ioutil.TempDir("", "trickster-streams")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	errw.Reset()
+	conf := config.NewConfig()
+	conf.Main.InstanceID = 1
+	conf.Logging.LogFile = filepath.Join(dir, "stderr.log")
+	l := New(conf)
+	l.Info("test entry", nil)
+	l.Close()
+	b, err := ioutil.ReadFile(filepath.Join(dir, "stderr.1.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `event="test entry"`) || errw.Len() != 0 {
+		t.Errorf("unexpected output %s", string(b))
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+
+	"github.com/go-kit/kit/log"
+)
+
+// syslogWriter writes messages to syslog at the severity of the method called
+type syslogWriter interface {
+	Debug(m string) error
+	Info(m string) error
+	Warning(m string) error
+	Err(m string) error
+	Crit(m string) error
+	Close() error
+}
+
+// dialSyslog connects to the syslog server of the logging config, and may be replaced by tests
+var dialSyslog = func(lc *config.LoggingConfig) (syslogWriter, error) {
+	return dialSyslogWriter(lc.SyslogNetwork, lc.SyslogAddress,
+		config.SyslogFacilities[lc.SyslogFacility], lc.SyslogTag)
+}
+
+// syslogLogger encodes events in the log format, and writes them to syslog at the severity
+// that corresponds to their level
+type syslogLogger struct {
+	mtx sync.Mutex
+	w   syslogWriter
+	buf *bytes.Buffer
+	enc log.Logger
+}
+
+func newSyslogLogger(w syslogWriter, format string) *syslogLogger {
+	buf := &bytes.Buffer{}
+	return &syslogLogger{w: w, buf: buf, enc: newEncoder(buf, format)}
+}
+
+// Log encodes the keyvals and writes them to syslog
+func (l *syslogLogger) Log(keyvals ...interface{}) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.buf.Reset()
+	if err := l.enc.Log(keyvals...); err != nil {
+		return err
+	}
+	m := strings.TrimSuffix(l.buf.String(), "\n")
+	switch eventLevel(keyvals) {
+	case "debug", "trace":
+		return l.w.Debug(m)
+	case "warn":
+		return l.w.Warning(m)
+	case "error":
+		return l.w.Err(m)
+	case "fatal":
+		return l.w.Crit(m)
+	}
+	return l.w.Info(m)
+}
+
+// eventLevel returns the level of the event with the keyvals
+func eventLevel(keyvals []interface{}) string {
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if fmt.Sprint(keyvals[i]) == "level" {
+			return fmt.Sprint(keyvals[i+1])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+)
+
+// testSyslogWriter records the messages written at each severity
+type testSyslogWriter struct {
+	messages map[string][]string
+	closed   bool
+}
+
+func (w *testSyslogWriter) write(severity, m string) error {
+	w.messages[severity] = append(w.messages[severity], m)
+	return nil
+}
+
+func (w *testSyslogWriter) Debug(m string) error   { return w.write("debug", m) }
+func (w *testSyslogWriter) Info(m string) error    { return w.write("info", m) }
+func (w *testSyslogWriter) Warning(m string) error { return w.write("warning", m) }
+func (w *testSyslogWriter) Err(m string) error     { return w.write("err", m) }
+func (w *testSyslogWriter) Crit(m string) error    { return w.write("crit", m) }
+
+func (w *testSyslogWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestSyslogLogger(t *testing.T) {
+
+	w := &testSyslogWriter{messages: make(map[string][]string)}
+	var dialed *config.LoggingConfig
+	dial := dialSyslog
+	defer func() { dialSyslog = dial }()
+	dialSyslog = func(lc *config.LoggingConfig) (syslogWriter, error) {
+		dialed = lc
+		return w, nil
+	}
+
+	conf := config.NewConfig()
+	conf.Logging.LogTarget = "syslog"
+	conf.Logging.SyslogNetwork = "udp"
+	conf.Logging.SyslogAddress = "127.0.0.1:514"
+	conf.Logging.LogLevel = "trace"
+	l := New(conf)
+	if dialed != conf.Logging {
+		t.Error("expected the syslog server of the logging config to be dialed")
+	}
+
+	l.Trace("test entry", nil)
+	l.Debug("test entry", nil)
+	l.Info("test entry", Pairs{"testKey": "testVal"})
+	l.Warn("test entry", nil)
+	l.Error("test entry", nil)
+	l.Fatal(-1, "test entry", nil)
+
+	for severity, n := range map[string]int{"debug": 2, "info": 1, "warning": 1, "err": 1, "crit": 1} {
+		if len(w.messages[severity]) != n {
+			t.Errorf("expected %d %s messages got %d", n, severity, len(w.messages[severity]))
+		}
+	}
+	m := w.messages["info"][0]
+	if !strings.Contains(m, ` level=info event="test entry" testKey=testVal`) ||
+		strings.HasSuffix(m, "\n") {
+		t.Errorf("unexpected message %s", m)
+	}
+
+	l.Close()
+	if !w.closed {
+		t.Error("expected the syslog connection to be closed")
+	}
+}
+
+func TestSyslogLoggerFallback(t *testing.T) {
+
+	dial := dialSyslog
+	defer func() { dialSyslog = dial }()
+	dialSyslog = func(lc *config.LoggingConfig) (syslogWriter, error) {
+		return nil, errors.New("connection refused")
+	}
+	buf := &bytes.Buffer{}
+	stdout = buf
+	defer func() { stdout = os.Stdout }()
+
+	conf := config.NewConfig()
+	conf.Logging.LogTarget = "syslog"
+	l := New(conf)
+	if s := buf.String(); !strings.Contains(s, `level=error event="unable to connect to syslog`) ||
+		!strings.Contains(s, `detail="connection refused"`) {
+		t.Errorf("unexpected output %s", s)
+	}
+	buf.Reset()
+	if l.ErrorOnce("logging.syslog", "test entry", nil) {
+		t.Error("expected the error to be logged once")
+	}
+	l.Info("test entry", nil)
+	if !strings.Contains(buf.String(), `event="test entry"`) {
+		t.Errorf("unexpected output %s", buf.String())
+	}
+}
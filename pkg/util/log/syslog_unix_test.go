@@ -0,0 +1,55 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDialSyslogWriter(t *testing.T) {
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	w, err := dialSyslogWriter("udp", pc.LocalAddr().String(), 16, "trickster-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Err("test entry")
+
+	b := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local0.err is priority 16*8+3
+	if s := string(b[:n]); !strings.HasPrefix(s, "<131>") ||
+		!strings.Contains(s, "trickster-test") || !strings.Contains(s, "test entry") {
+		t.Errorf("unexpected message %s", s)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Entry is an event recorded by a Recorder
+type Entry struct {
+	// Level is the lowercase level of the event
+	Level string
+	// Event is the description of the event
+	Event string
+	// Caller is the call site of the event, relative to the root of the project
+	Caller string
+	// Pairs are the details of the event, which exclude its time, app, caller, level and event
+	Pairs Pairs
+	// Keys are the keys of the event in the order in which they are encoded
+	Keys []string
+}
+
+// Recorder records the events sent to a Logger returned by NewTestLogger, so that tests can
+// assert the events that were logged. It is safe for concurrent use
+type Recorder struct {
+	mtx     sync.Mutex
+	entries []Entry
+}
+
+// NewTestLogger returns a Logger at the log level whose events are recorded by the returned
+// Recorder, rather than written. Events pass through the same leveling, ordering and redaction
+// as those of any other Logger
+func NewTestLogger(logLevel string) (*Logger, *Recorder) {
+	rec := &Recorder{}
+	l := NoopLogger()
+	ts, _ := newTimestamp("", false)
+	l.setContext(rec, ts)
+	l.SetLogLevel(logLevel)
+	return l, rec
+}
+
+// Log records the keyvals as an Entry
+func (r *Recorder) Log(keyvals ...interface{}) error {
+	e := Entry{Pairs: make(Pairs, len(keyvals)/2), Keys: make([]string, 0, len(keyvals)/2)}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		k := fmt.Sprint(keyvals[i])
+		v := keyvals[i+1]
+		e.Keys = append(e.Keys, k)
+		switch k {
+		case "level":
+			e.Level = strings.ToLower(fmt.Sprint(v))
+		case "event":
+			e.Event = fmt.Sprint(v)
+		case "caller":
+			e.Caller = fmt.Sprint(v)
+		case "time", "app":
+		default:
+			e.Pairs[k] = v
+		}
+	}
+	r.mtx.Lock()
+	r.entries = append(r.entries, e)
+	r.mtx.Unlock()
+	return nil
+}
+
+// Entries returns the recorded events in the order in which they were logged
+func (r *Recorder) Entries() []Entry {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	entries := make([]Entry, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+// Find returns the recorded events having the level and event. An empty level or event
+// matches any
+func (r *Recorder) Find(logLevel, event string) []Entry {
+	logLevel = strings.ToLower(logLevel)
+	var entries []Entry
+	for _, e := range r.Entries() {
+		if (logLevel == "" || e.Level == logLevel) && (event == "" || e.Event == event) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Reset forgets the recorded events
+func (r *Recorder) Reset() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.entries = nil
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewTestLogger(t *testing.T) {
+
+	l, rec := NewTestLogger("info")
+
+	l.Debug("test debug", nil)
+	l.With(Pairs{"originName": "default"}).WarnOnce("test-key", "test warn",
+		Pairs{"token": "secret", "b": 2, "a": 1})
+	l.WarnOnce("test-key", "test warn", nil)
+	l.Trace("test trace", nil)
+	l.Fatal(-1, "test fatal", Pairs{"level": "ignored"})
+
+	entries := rec.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(entries))
+	}
+
+	warns := rec.Find("WARN", "test warn")
+	if len(warns) != 1 {
+		t.Fatalf("expected %d got %d", 1, len(warns))
+	}
+	w := warns[0]
+	if w.Pairs["originName"] != "default" || w.Pairs["token"] != "[REDACTED]" ||
+		w.Pairs["a"] != 1 || len(w.Pairs) != 4 {
+		t.Errorf("unexpected pairs %v", w.Pairs)
+	}
+	if s := strings.Join(w.Keys, ","); s != "time,app,caller,level,event,a,b,originName,token" {
+		t.Errorf("unexpected keys %s", s)
+	}
+
+	if f := rec.Find("fatal", ""); len(f) != 1 || f[0].Event != "test fatal" ||
+		!strings.HasPrefix(f[0].Caller, "util/log/testlogger_test.go:") {
+		t.Errorf("unexpected entries %v", f)
+	}
+
+	rec.Reset()
+	if len(rec.Entries()) != 0 {
+		t.Error("expected no entries")
+	}
+}
+
+func TestRecorderConcurrency(t *testing.T) {
+
+	l, rec := NewTestLogger("info")
+	const writers, events = 10, 100
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < events; j++ {
+				l.Info("test entry", nil)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(rec.Find("info", "test entry")); n != writers*events {
+		t.Errorf("expected %d got %d", writers*events, n)
+	}
+}
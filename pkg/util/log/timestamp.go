@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// The keywords of the log timestamp formats that are not Go time layouts
+const (
+	timestampRFC3339     = "rfc3339"
+	timestampRFC3339Nano = "rfc3339nano"
+	timestampEpoch       = "epoch"
+	timestampEpochMS     = "epoch_ms"
+)
+
+// timestampLayouts are the Go time layouts of the log timestamp format keywords
+var timestampLayouts = map[string]string{
+	timestampRFC3339:     time.RFC3339,
+	timestampRFC3339Nano: time.RFC3339Nano,
+}
+
+// newTimestamp returns a valuer of the time of each log event in the provided format, which is
+// either a Go time layout or a format keyword, in the local time zone or in UTC. An empty format
+// is rfc3339nano. ok is false when the format is not a valid layout, in which case the valuer
+// renders the time in the default format
+func newTimestamp(format string, local bool) (log.Valuer, bool) {
+	now := func() time.Time { return time.Now().UTC() }
+	if local {
+		now = time.Now
+	}
+	ok := true
+	switch k := strings.ToLower(format); k {
+	case timestampEpoch:
+		return func() interface{} { return now().Unix() }, true
+	case timestampEpochMS:
+		return func() interface{} { return now().UnixNano() / int64(time.Millisecond) }, true
+	case "":
+		format = time.RFC3339Nano
+	default:
+		if layout, found := timestampLayouts[k]; found {
+			format = layout
+		} else if !validLayout(format) {
+			format, ok = time.RFC3339Nano, false
+		}
+	}
+	return func() interface{} { return now().Format(format) }, ok
+}
+
+// validLayout returns true if the Go time layout includes at least one element of the time,
+// since time.Format accepts any string as a layout, and renders those without elements verbatim
+func validLayout(layout string) bool {
+	t1 := time.Date(2006, 1, 2, 15, 4, 5, 123456789, time.UTC)
+	t2 := time.Date(2017, 11, 23, 8, 37, 48, 987654321, time.FixedZone("", 3600))
+	return t1.Format(layout) != t2.Format(layout)
+}
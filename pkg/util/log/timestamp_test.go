@@ -0,0 +1,108 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+)
+
+func TestNewTimestamp(t *testing.T) {
+
+	local := time.Now().Format("-07:00")
+
+	tests := []struct {
+		format  string
+		local   bool
+		pattern string
+		ok      bool
+	}{
+		{"", false, `^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?Z$`, true},
+		{"rfc3339", false, `^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\dZ$`, true},
+		{"RFC3339Nano", false, `^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?Z$`, true},
+		{"2006-01-02T15:04:05.000Z07:00", true,
+			`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}` + regexp.QuoteMeta(strings.Replace(local, "+00:00", "Z", 1)) + `$`, true},
+		{"2006-01-02 15:04:05.000", false, `^\d{4}-\d\d-\d\d \d\d:\d\d:\d\d\.\d{3}$`, true},
+		{"not a layout", false, `^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?Z$`, false},
+	}
+
+	for i, test := range tests {
+		ts, ok := newTimestamp(test.format, test.local)
+		if ok != test.ok {
+			t.Errorf("(%d) expected %t got %t", i, test.ok, ok)
+		}
+		s, _ := ts().(string)
+		if !regexp.MustCompile(test.pattern).MatchString(s) {
+			t.Errorf("(%d) unexpected timestamp %s for format %s", i, s, test.format)
+		}
+	}
+
+	for _, format := range []string{"epoch", "epoch_ms"} {
+		ts, ok := newTimestamp(format, false)
+		if !ok {
+			t.Errorf("expected %t got %t", true, ok)
+		}
+		v, isInt := ts().(int64)
+		if !isInt {
+			t.Fatalf("unexpected timestamp %v for format %s", ts(), format)
+		}
+		expected := time.Now().Unix()
+		if format == "epoch_ms" {
+			expected = time.Now().UnixNano() / int64(time.Millisecond)
+			v /= 1000
+			expected /= 1000
+		}
+		if d := expected - v; d < 0 || d > 1 {
+			t.Errorf("expected %d got %d", expected, v)
+		}
+	}
+}
+
+func TestNewTimestampFormat(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	stdout = buf
+	defer func() { stdout = os.Stdout }()
+
+	conf := config.NewConfig()
+	conf.Logging.LogTimestampFormat = "epoch_ms"
+	New(conf).Info("test entry", nil)
+	m := regexp.MustCompile(`^time=(\d+) `).FindStringSubmatch(buf.String())
+	if m == nil {
+		t.Fatalf("unexpected output %s", buf.String())
+	}
+	if ms, _ := strconv.ParseInt(m[1], 10, 64); ms < time.Now().Add(-time.Minute).UnixNano()/1e6 {
+		t.Errorf("unexpected timestamp %d", ms)
+	}
+
+	// an invalid layout warns once, and falls back to the default
+	buf.Reset()
+	conf.Logging.LogTimestampFormat = "not a layout"
+	New(conf).Info("test entry", nil)
+	s := buf.String()
+	if strings.Count(s, "invalid log timestamp format") != 1 ||
+		!regexp.MustCompile(`(?m)^time=\d{4}-\d\d-\d\dT\S+Z .* event="test entry"`).MatchString(s) {
+		t.Errorf("unexpected output %s", s)
+	}
+}
@@ -19,6 +19,7 @@ package metrics
 
 import (
 	"net/http"
+	"sync/atomic"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -31,11 +32,16 @@ const (
 	configSubsystem   = "config"
 	buildSubsystem    = "build"
 	frontendSubsystem = "frontend"
+	logSubsystem      = "log"
 )
 
 // Default histogram buckets used by trickster
 var (
 	defaultBuckets = []float64{0.05, 0.1, 0.5, 1, 5, 10, 20}
+	// lock waits are usually sub-millisecond, so their buckets start much lower
+	lockWaitBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+	// queue waits are usually short, but may last up to an origin's max wait
+	queueWaitBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30}
 )
 
 // BuildInfo is a Gauge representing the Trickster binary build information of the running server instance
@@ -47,44 +53,88 @@ var LastReloadSuccessful prometheus.Gauge
 // LastReloadSuccessfulTimestamp gauge is the epoch time of the most recent successful config load
 var LastReloadSuccessfulTimestamp prometheus.Gauge
 
-// FrontendRequestStatus is a Counter of front end requests that have been processed with their status
-var FrontendRequestStatus *prometheus.CounterVec
+// LogOnceEntries is a Gauge of the keys of once-per-key events remembered by the logger
+var LogOnceEntries prometheus.GaugeFunc
 
-// FrontendRequestDuration is a histogram that tracks the time it takes to process a request
-var FrontendRequestDuration *prometheus.HistogramVec
+// logOnceEntries holds the func() int that reports LogOnceEntries
+var logOnceEntries atomic.Value
 
-// FrontendRequestWrittenBytes is a Counter of bytes written for front end requests
-var FrontendRequestWrittenBytes *prometheus.CounterVec
+// SetLogOnceEntriesFunc sets the function that reports LogOnceEntries, which is that of the
+// logger of the running config
+func SetLogOnceEntriesFunc(f func() int) {
+	logOnceEntries.Store(f)
+}
+
+// LogEvents is a Counter of the events sent by the logger, by level
+var LogEvents prometheus.Collector
+
+// LogOnceSuppressed is a Counter of the once-per-key log events that were suppressed, because an
+// event was already sent for their key
+var LogOnceSuppressed prometheus.CounterFunc
 
-// ProxyRequestStatus is a Counter of downstream client requests handled by Trickster
-var ProxyRequestStatus *prometheus.CounterVec
+// LogDedupSuppressed is a Counter of the repeated log events that were suppressed within their
+// deduplication window
+var LogDedupSuppressed prometheus.CounterFunc
 
-// ProxyRequestElements is a Counter of data points in the timeseries returned to the requesting client
-var ProxyRequestElements *prometheus.CounterVec
+// LogDropped is a Counter of the log events that were dropped because the buffer of asynchronous
+// logging, or of the network log target, was full
+var LogDropped prometheus.CounterFunc
 
-// ProxyRequestDuration is a Histogram of time required in seconds to proxy a given Prometheus query
-var ProxyRequestDuration *prometheus.HistogramVec
+// logCounts holds the func() map[string]uint64 that reports LogEvents, LogOnceSuppressed,
+// LogDedupSuppressed and LogDropped
+var logCounts atomic.Value
 
-// CacheObjectOperations is a Counter of operations (in # of objects) performed on a Trickster cache
-var CacheObjectOperations *prometheus.CounterVec
+// logOnceSuppressedCount is the key of the count of LogOnceSuppressed in the map reported by the
+// logCounts func, which is that of log.CountOnceSuppressed
+const logOnceSuppressedCount = "once_suppressed"
 
-// CacheByteOperations is a Counter of operations (in # of bytes) performed on a Trickster cache
-var CacheByteOperations *prometheus.CounterVec
+// logDedupSuppressedCount is the key of the count of LogDedupSuppressed in the map reported by the
+// logCounts func, which is that of log.CountDedupSuppressed
+const logDedupSuppressedCount = "dedup_suppressed"
+
+// logDroppedCount is the key of the count of LogDropped in the map reported by the logCounts
+// func, which is that of log.CountDropped
+const logDroppedCount = "dropped"
+
+// SetLogCountsFunc sets the function that reports LogEvents, LogOnceSuppressed,
+// LogDedupSuppressed and LogDropped, which is the
+// Counts method of the logger of the running config. The counts of the log package are read at
+// collection time, rather than incremented here, so that logging does not depend on this package
+func SetLogCountsFunc(f func() map[string]uint64) {
+	logCounts.Store(f)
+}
 
-// CacheEvents is a Counter of events performed on a Trickster cache
-var CacheEvents *prometheus.CounterVec
+// loadLogCounts returns the counts reported by the logCounts func, or nil when it is not set
+func loadLogCounts() map[string]uint64 {
+	if f, ok := logCounts.Load().(func() map[string]uint64); ok {
+		return f()
+	}
+	return nil
+}
 
-// CacheObjects is a Gauge representing the number of objects in a Trickster cache
-var CacheObjects *prometheus.GaugeVec
+// logEventsCollector collects LogEvents from the logCounts func
+type logEventsCollector struct {
+	desc *prometheus.Desc
+}
 
-// CacheBytes is a Gauge representing the number of bytes in a Trickster cache
-var CacheBytes *prometheus.GaugeVec
+// Describe implements prometheus.Collector
+func (c *logEventsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
 
-// CacheMaxObjects is a Gauge for the Trickster cache's Max Object Threshold for triggering an eviction exercise
-var CacheMaxObjects *prometheus.GaugeVec
+// Collect implements prometheus.Collector
+func (c *logEventsCollector) Collect(ch chan<- prometheus.Metric) {
+	for k, v := range loadLogCounts() {
+		if k == logOnceSuppressedCount || k == logDedupSuppressedCount || k == logDroppedCount {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(v), k)
+	}
+}
 
-// CacheMaxBytes is a Gauge for the Trickster cache's Max Object Threshold for triggering an eviction exercise
-var CacheMaxBytes *prometheus.GaugeVec
+// ProxyBackgroundTasks is a Gauge of the goroutines running on behalf of proxied requests, such as
+// fast forward fetches and cache writes, by their kind
+var ProxyBackgroundTasks *prometheus.GaugeVec
 
 // ProxyMaxConnections is a Gauge representing the max number of active concurrent connections in the server
 var ProxyMaxConnections prometheus.Gauge
@@ -135,65 +185,63 @@ func init() {
 		},
 	)
 
-	FrontendRequestStatus = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
+	LogOnceEntries = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
 			Namespace: metricNamespace,
-			Subsystem: frontendSubsystem,
-			Name:      "requests_total",
-			Help:      "Count of front end requests handled by Trickster",
+			Subsystem: logSubsystem,
+			Name:      "once_entries",
+			Help:      "Number of keys of once-per-key log events remembered by the logger.",
 		},
-		[]string{"origin_name", "origin_type", "method", "path", "http_status"},
-	)
-
-	FrontendRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Namespace: metricNamespace,
-			Subsystem: frontendSubsystem,
-			Name:      "requests_duration_seconds",
-			Help:      "Histogram of front end request durations handled by Trickster",
-			Buckets:   defaultBuckets,
+		func() float64 {
+			if f, ok := logOnceEntries.Load().(func() int); ok {
+				return float64(f())
+			}
+			return 0
 		},
-		[]string{"origin_name", "origin_type", "method", "path", "http_status"},
 	)
 
-	FrontendRequestWrittenBytes = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Subsystem: frontendSubsystem,
-			Name:      "written_bytes_total",
-			Help:      "Count of bytes written in front end requests handled by Trickster",
-		},
-		[]string{"origin_name", "origin_type", "method", "path", "http_status"})
+	LogEvents = &logEventsCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricNamespace, logSubsystem, "events_total"),
+			"Count of events sent by the logger, by level.",
+			[]string{"level"}, nil,
+		),
+	}
 
-	ProxyRequestStatus = prometheus.NewCounterVec(
+	LogOnceSuppressed = prometheus.NewCounterFunc(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
-			Subsystem: proxySubsystem,
-			Name:      "requests_total",
-			Help:      "Count of downstream client requests handled by Trickster",
+			Subsystem: logSubsystem,
+			Name:      "once_suppressed_total",
+			Help:      "Count of once-per-key log events suppressed because an event was already sent for their key.",
+		},
+		func() float64 {
+			return float64(loadLogCounts()[logOnceSuppressedCount])
 		},
-		[]string{"origin_name", "origin_type", "method", "cache_status", "http_status", "path"},
 	)
 
-	ProxyRequestElements = prometheus.NewCounterVec(
+	LogDedupSuppressed = prometheus.NewCounterFunc(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
-			Subsystem: proxySubsystem,
-			Name:      "points_total",
-			Help:      "Count of data points in the timeseries returned to the requesting client.",
+			Subsystem: logSubsystem,
+			Name:      "dedup_suppressed_total",
+			Help:      "Count of repeated log events suppressed within their deduplication window.",
+		},
+		func() float64 {
+			return float64(loadLogCounts()[logDedupSuppressedCount])
 		},
-		[]string{"origin_name", "origin_type", "cache_status", "path"},
 	)
 
-	ProxyRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
+	LogDropped = prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
 			Namespace: metricNamespace,
-			Subsystem: proxySubsystem,
-			Name:      "request_duration_seconds",
-			Help:      "Time required in seconds to proxy a given Prometheus query.",
-			Buckets:   defaultBuckets,
+			Subsystem: logSubsystem,
+			Name:      "dropped_total",
+			Help:      "Count of log events dropped because the buffer of asynchronous logging, or of the network log target, was full.",
+		},
+		func() float64 {
+			return float64(loadLogCounts()[logDroppedCount])
 		},
-		[]string{"origin_name", "origin_type", "method", "status", "http_status", "path"},
 	)
 
 	ProxyMaxConnections = prometheus.NewGauge(
@@ -249,7 +297,247 @@ func init() {
 		},
 	)
 
-	CacheObjectOperations = prometheus.NewCounterVec(
+	ProxyBackgroundTasks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "background_tasks",
+			Help:      "Number of goroutines running on behalf of proxied requests.",
+		},
+		[]string{"kind"},
+	)
+}
+
+// Metrics are the metrics of the requests, caches and origins of a router, which are
+// registered with the Prometheus registry of the application that serves it
+type Metrics struct {
+	// FrontendRequestStatus is a Counter of front end requests that have been processed with their status
+	FrontendRequestStatus *prometheus.CounterVec
+
+	// FrontendRequestDuration is a histogram that tracks the time it takes to process a request
+	FrontendRequestDuration *prometheus.HistogramVec
+
+	// FrontendRequestWrittenBytes is a Counter of bytes written for front end requests
+	FrontendRequestWrittenBytes *prometheus.CounterVec
+
+	// FrontendRequestsRejected is a Counter of front end requests rejected for exceeding a size limit
+	FrontendRequestsRejected *prometheus.CounterVec
+
+	// Panics is a Counter of panics recovered while handling front end requests
+	Panics *prometheus.CounterVec
+
+	// ProxyRequestStatus is a Counter of downstream client requests handled by Trickster
+	ProxyRequestStatus *prometheus.CounterVec
+
+	// ProxyRequestElements is a Counter of data points in the timeseries returned to the requesting client
+	ProxyRequestElements *prometheus.CounterVec
+
+	// ProxyRequestDuration is a Histogram of time required in seconds to proxy a given Prometheus query
+	ProxyRequestDuration *prometheus.HistogramVec
+
+	// CacheObjectOperations is a Counter of operations (in # of objects) performed on a Trickster cache
+	CacheObjectOperations *prometheus.CounterVec
+
+	// CacheByteOperations is a Counter of operations (in # of bytes) performed on a Trickster cache
+	CacheByteOperations *prometheus.CounterVec
+
+	// CacheEvents is a Counter of events performed on a Trickster cache
+	CacheEvents *prometheus.CounterVec
+
+	// CacheCorruptions is a Counter of cache objects that failed integrity verification
+	CacheCorruptions *prometheus.CounterVec
+
+	// CacheDecodeFailures is a Counter of cache objects that could not be retrieved or decoded, by failure class
+	CacheDecodeFailures *prometheus.CounterVec
+
+	// CacheObjects is a Gauge representing the number of objects in a Trickster cache
+	CacheObjects *prometheus.GaugeVec
+
+	// CacheBytes is a Gauge representing the number of bytes in a Trickster cache
+	CacheBytes *prometheus.GaugeVec
+
+	// CacheMaxObjects is a Gauge for the Trickster cache's Max Object Threshold for triggering an eviction exercise
+	CacheMaxObjects *prometheus.GaugeVec
+
+	// CacheMaxBytes is a Gauge for the Trickster cache's Max Object Threshold for triggering an eviction exercise
+	CacheMaxBytes *prometheus.GaugeVec
+
+	// CacheLockWaitDuration is a Histogram of time in seconds spent waiting to acquire a cache key lock
+	CacheLockWaitDuration *prometheus.HistogramVec
+
+	// CachePendingDeletions is a Gauge of objects removed from a cache index that are awaiting physical deletion
+	CachePendingDeletions *prometheus.GaugeVec
+
+	// CacheDeferredDeletions is a Counter of objects physically deleted by a cache's background deletion worker
+	CacheDeferredDeletions *prometheus.CounterVec
+
+	// CacheUnusedEvictions is a Counter of objects evicted from a cache because they were not read
+	// within the unused eviction window after they were written
+	CacheUnusedEvictions *prometheus.CounterVec
+
+	// CacheUnusedEvictionRewrites is a Counter of objects that were written to a cache again after
+	// they were evicted as unused, and before they would otherwise have expired
+	CacheUnusedEvictionRewrites *prometheus.CounterVec
+
+	// CacheReadOnly is a Gauge of whether a cache is in read-only mode (1 = read-only, 0 = writable)
+	CacheReadOnly *prometheus.GaugeVec
+
+	// CacheReadOnlySkippedStores is a Counter of objects that were not stored because their cache was
+	// in read-only mode
+	CacheReadOnlySkippedStores *prometheus.CounterVec
+
+	// CacheEventsDropped is a Counter of cache mutation events that were dropped because the cache
+	// event stream could not keep up with them
+	CacheEventsDropped prometheus.Counter
+
+	// ProxyCacheFills is a Counter of cache miss fills, by the source (origin or bootstrap peer) that satisfied them
+	ProxyCacheFills *prometheus.CounterVec
+
+	// ProxyPartialResponses is a Counter of best-effort timeseries responses that are missing the ranges
+	// of failed upstream fetches
+	ProxyPartialResponses *prometheus.CounterVec
+
+	// ProxyChainRequests is a Counter of proxied requests by the depth of this Trickster in the chain of
+	// Tricksters that each request passed through, and its cache status
+	ProxyChainRequests *prometheus.CounterVec
+
+	// ProxyNegativeCacheTTL is a Gauge of the TTL most recently applied to the Negative Cache entries of a path
+	// for a response status code, which grows while the backoff extends the entries of a failing origin
+	ProxyNegativeCacheTTL *prometheus.GaugeVec
+
+	// ProxyOriginClockSkew is a Gauge of the smoothed clock skew estimate between Trickster and an origin
+	ProxyOriginClockSkew *prometheus.GaugeVec
+
+	// ProxyOriginHealthStatus is a Gauge of the background health check status of an origin (1 = healthy, 0 = unhealthy)
+	ProxyOriginHealthStatus *prometheus.GaugeVec
+
+	// ProxyOriginRateLimitRemaining is a Gauge of the estimated number of requests remaining in an origin's rate limit
+	ProxyOriginRateLimitRemaining *prometheus.GaugeVec
+
+	// ProxyOriginRateLimitReset is a Gauge of the epoch timestamp at which an origin's rate limit resets
+	ProxyOriginRateLimitReset *prometheus.GaugeVec
+
+	// ProxyOriginRateLimitThrottled is a Gauge indicating whether upstream requests to an origin are
+	// being paced to its rate limit (1 = throttled, 0 = not throttled)
+	ProxyOriginRateLimitThrottled *prometheus.GaugeVec
+
+	// ProxyOriginConcurrentRequests is a Gauge of the upstream requests admitted under an origin's concurrency limit
+	ProxyOriginConcurrentRequests *prometheus.GaugeVec
+
+	// ProxyOriginConcurrencyQueueWait is a Histogram of time in seconds that upstream requests wait to be
+	// admitted under an origin's concurrency limit, by priority class
+	ProxyOriginConcurrencyQueueWait *prometheus.HistogramVec
+
+	// ProxyOriginConcurrencyRejections is a Counter of upstream requests rejected because they could not be
+	// admitted under an origin's concurrency limit, by priority class
+	ProxyOriginConcurrencyRejections *prometheus.CounterVec
+
+	// ProxyInflightProcessingBytes is a Gauge of the estimated bytes held by in-flight requests while
+	// their upstream responses and cached documents are processed
+	ProxyInflightProcessingBytes prometheus.Gauge
+
+	// ProxyInflightProcessingPeakBytes is a Gauge of the highest value of ProxyInflightProcessingBytes
+	// since Trickster started
+	ProxyInflightProcessingPeakBytes prometheus.Gauge
+
+	// ProxyMaxInflightProcessingBytes is a Gauge of the limit of the bytes held by in-flight requests (0 = unlimited)
+	ProxyMaxInflightProcessingBytes prometheus.Gauge
+
+	// ProxyRefreshJobRuns is a Counter of Refresh Job executions, by their result
+	ProxyRefreshJobRuns *prometheus.CounterVec
+
+	// ProxyCacheInvalidations is a Counter of cached timeseries documents invalidated by requests to the
+	// Invalidate Handler, by the source of the request and the action taken
+	ProxyCacheInvalidations *prometheus.CounterVec
+
+	// ProxyRefreshJobDuration is a Histogram of the time in seconds required to execute a Refresh Job
+	ProxyRefreshJobDuration *prometheus.HistogramVec
+}
+
+// New returns new, unregistered Metrics
+func New() *Metrics {
+
+	m := &Metrics{}
+
+	m.FrontendRequestStatus = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: frontendSubsystem,
+			Name:      "requests_total",
+			Help:      "Count of front end requests handled by Trickster",
+		},
+		[]string{"origin_name", "origin_type", "method", "path", "http_status"},
+	)
+
+	m.FrontendRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Subsystem: frontendSubsystem,
+			Name:      "requests_duration_seconds",
+			Help:      "Histogram of front end request durations handled by Trickster",
+			Buckets:   defaultBuckets,
+		},
+		[]string{"origin_name", "origin_type", "method", "path", "http_status"},
+	)
+
+	m.FrontendRequestWrittenBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: frontendSubsystem,
+			Name:      "written_bytes_total",
+			Help:      "Count of bytes written in front end requests handled by Trickster",
+		},
+		[]string{"origin_name", "origin_type", "method", "path", "http_status"})
+
+	m.FrontendRequestsRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: frontendSubsystem,
+			Name:      "requests_rejected_total",
+			Help:      "Count of front end requests rejected by Trickster for exceeding a size limit",
+		},
+		[]string{"origin_name", "origin_type", "path", "limit"})
+
+	m.Panics = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "panics_total",
+			Help:      "Count of panics recovered while handling front end requests",
+		},
+		[]string{"origin_name", "path"})
+
+	m.ProxyRequestStatus = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "requests_total",
+			Help:      "Count of downstream client requests handled by Trickster",
+		},
+		[]string{"origin_name", "origin_type", "method", "cache_status", "http_status", "path"},
+	)
+
+	m.ProxyRequestElements = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "points_total",
+			Help:      "Count of data points in the timeseries returned to the requesting client.",
+		},
+		[]string{"origin_name", "origin_type", "cache_status", "path"},
+	)
+
+	m.ProxyRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Time required in seconds to proxy a given Prometheus query.",
+			Buckets:   defaultBuckets,
+		},
+		[]string{"origin_name", "origin_type", "method", "status", "http_status", "path"},
+	)
+
+	m.CacheObjectOperations = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
 			Subsystem: cacheSubsystem,
@@ -259,7 +547,7 @@ func init() {
 		[]string{"cache_name", "cache_type", "operation", "status"},
 	)
 
-	CacheByteOperations = prometheus.NewCounterVec(
+	m.CacheByteOperations = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
 			Subsystem: cacheSubsystem,
@@ -269,7 +557,7 @@ func init() {
 		[]string{"cache_name", "cache_type", "operation", "status"},
 	)
 
-	CacheEvents = prometheus.NewCounterVec(
+	m.CacheEvents = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
 			Subsystem: cacheSubsystem,
@@ -279,7 +567,27 @@ func init() {
 		[]string{"cache_name", "cache_type", "event", "reason"},
 	)
 
-	CacheObjects = prometheus.NewGaugeVec(
+	m.CacheCorruptions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "corruptions_total",
+			Help:      "Count of objects that failed checksum verification when retrieved from a Trickster cache.",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	m.CacheDecodeFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "decode_failures_total",
+			Help:      "Count of objects that could not be retrieved or decoded from a Trickster cache, by failure class.",
+		},
+		[]string{"cache_name", "cache_type", "class"},
+	)
+
+	m.CacheObjects = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: metricNamespace,
 			Subsystem: cacheSubsystem,
@@ -289,7 +597,7 @@ func init() {
 		[]string{"cache_name", "cache_type"},
 	)
 
-	CacheBytes = prometheus.NewGaugeVec(
+	m.CacheBytes = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: metricNamespace,
 			Subsystem: cacheSubsystem,
@@ -299,7 +607,7 @@ func init() {
 		[]string{"cache_name", "cache_type"},
 	)
 
-	CacheMaxObjects = prometheus.NewGaugeVec(
+	m.CacheMaxObjects = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: metricNamespace,
 			Subsystem: cacheSubsystem,
@@ -309,7 +617,7 @@ func init() {
 		[]string{"cache_name", "cache_type"},
 	)
 
-	CacheMaxBytes = prometheus.NewGaugeVec(
+	m.CacheMaxBytes = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: metricNamespace,
 			Subsystem: cacheSubsystem,
@@ -319,29 +627,375 @@ func init() {
 		[]string{"cache_name", "cache_type"},
 	)
 
-	// Register Metrics
-	prometheus.MustRegister(FrontendRequestStatus)
-	prometheus.MustRegister(FrontendRequestDuration)
-	prometheus.MustRegister(FrontendRequestWrittenBytes)
-	prometheus.MustRegister(ProxyRequestStatus)
-	prometheus.MustRegister(ProxyRequestElements)
-	prometheus.MustRegister(ProxyRequestDuration)
-	prometheus.MustRegister(ProxyMaxConnections)
-	prometheus.MustRegister(ProxyActiveConnections)
-	prometheus.MustRegister(ProxyConnectionRequested)
-	prometheus.MustRegister(ProxyConnectionAccepted)
-	prometheus.MustRegister(ProxyConnectionClosed)
-	prometheus.MustRegister(ProxyConnectionFailed)
-	prometheus.MustRegister(CacheObjectOperations)
-	prometheus.MustRegister(CacheByteOperations)
-	prometheus.MustRegister(CacheEvents)
-	prometheus.MustRegister(CacheObjects)
-	prometheus.MustRegister(CacheBytes)
-	prometheus.MustRegister(CacheMaxObjects)
-	prometheus.MustRegister(CacheMaxBytes)
-	prometheus.MustRegister(BuildInfo)
-	prometheus.MustRegister(LastReloadSuccessful)
-	prometheus.MustRegister(LastReloadSuccessfulTimestamp)
+	m.CacheLockWaitDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "lock_wait_seconds",
+			Help:      "Time in seconds spent waiting to acquire a Trickster cache key lock, by lock mode.",
+			Buckets:   lockWaitBuckets,
+		},
+		[]string{"cache_name", "cache_type", "mode"},
+	)
+
+	m.CachePendingDeletions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "pending_deletions",
+			Help:      "Number of objects removed from a Trickster cache index that are awaiting physical deletion.",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	m.CacheDeferredDeletions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "deferred_deletions_total",
+			Help:      "Count of objects physically deleted from a Trickster cache by its background deletion worker.",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	m.CacheUnusedEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "unused_evictions_total",
+			Help:      "Count of objects evicted from a Trickster cache because they were not read within the unused eviction window.",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	m.CacheUnusedEvictionRewrites = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "unused_eviction_rewrites_total",
+			Help:      "Count of objects written to a Trickster cache again after they were evicted as unused, before their TTL.",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	m.CacheReadOnly = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "read_only",
+			Help:      "Whether a Trickster cache is in read-only mode (1 = read-only, 0 = writable).",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	m.CacheReadOnlySkippedStores = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "read_only_skipped_stores_total",
+			Help:      "Count of objects not stored because their Trickster cache was in read-only mode.",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	m.CacheEventsDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "events_dropped_total",
+			Help:      "Count of cache mutation events dropped because the cache event stream could not keep up.",
+		},
+	)
+
+	m.ProxyCacheFills = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "cache_fills_total",
+			Help:      "Count of upstream cache miss fills by the source that satisfied them.",
+		},
+		[]string{"origin_name", "origin_type", "source"},
+	)
+
+	m.ProxyPartialResponses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "partial_responses_total",
+			Help:      "Count of best-effort timeseries responses missing the ranges of failed upstream fetches.",
+		},
+		[]string{"origin_name", "origin_type", "path"},
+	)
+
+	m.ProxyChainRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "chain_requests_total",
+			Help:      "Count of proxied requests by the depth of this Trickster in the chain of Tricksters and cache status.",
+		},
+		[]string{"origin_name", "origin_type", "depth", "cache_status"},
+	)
+
+	m.ProxyNegativeCacheTTL = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "negative_cache_ttl_seconds",
+			Help:      "Effective TTL in seconds of the Negative Cache entries of a path, by response status code.",
+		},
+		[]string{"origin_name", "origin_type", "path", "http_status"},
+	)
+
+	m.ProxyOriginClockSkew = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_clock_skew_seconds",
+			Help:      "Smoothed estimate of the origin's clock skew relative to Trickster (positive = origin ahead).",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	m.ProxyOriginHealthStatus = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_health_status",
+			Help:      "Trickster background health check status of an origin (1 = healthy, 0 = unhealthy).",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	m.ProxyOriginRateLimitRemaining = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_rate_limit_remaining",
+			Help:      "Estimated number of requests remaining in the origin's rate limit.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	m.ProxyOriginRateLimitReset = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_rate_limit_reset_timestamp_seconds",
+			Help:      "Epoch timestamp at which the origin's rate limit resets.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	m.ProxyOriginRateLimitThrottled = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_rate_limit_throttled",
+			Help:      "Whether upstream requests to the origin are paced to its rate limit (1 = throttled, 0 = not throttled).",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	m.ProxyOriginConcurrentRequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_concurrent_requests",
+			Help:      "Number of upstream requests admitted under the origin's concurrency limit.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	m.ProxyOriginConcurrencyQueueWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_concurrency_queue_wait_seconds",
+			Help:      "Time in seconds upstream requests waited for admission under the origin's concurrency limit, by priority class.",
+			Buckets:   queueWaitBuckets,
+		},
+		[]string{"origin_name", "origin_type", "class"},
+	)
+
+	m.ProxyOriginConcurrencyRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_concurrency_rejections_total",
+			Help:      "Count of upstream requests rejected for want of admission under the origin's concurrency limit, by priority class.",
+		},
+		[]string{"origin_name", "origin_type", "class"},
+	)
+
+	m.ProxyInflightProcessingBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "inflight_processing_bytes",
+			Help:      "Estimated bytes held by in-flight requests while their responses are processed.",
+		},
+	)
+
+	m.ProxyInflightProcessingPeakBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "inflight_processing_peak_bytes",
+			Help:      "Highest estimated bytes held by in-flight requests since Trickster started.",
+		},
+	)
+
+	m.ProxyMaxInflightProcessingBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "max_inflight_processing_bytes",
+			Help:      "Limit of the bytes held by in-flight requests while their responses are processed (0 = unlimited).",
+		},
+	)
+
+	m.ProxyRefreshJobRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "refresh_job_runs_total",
+			Help:      "Count of background refresh job executions by result (success, failure, paused).",
+		},
+		[]string{"job_name", "origin_name", "result"},
+	)
+
+	m.ProxyCacheInvalidations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "cache_invalidations_total",
+			Help:      "Count of cached timeseries documents invalidated on request, by source and action (removed, truncated).",
+		},
+		[]string{"origin_name", "origin_type", "source", "action"},
+	)
+
+	m.ProxyRefreshJobDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "refresh_job_duration_seconds",
+			Help:      "Time required in seconds to execute a background refresh job.",
+			Buckets:   defaultBuckets,
+		},
+		[]string{"job_name", "origin_name"},
+	)
+
+	return m
+}
+
+// Default are the Metrics of the routers of the Trickster binary
+var Default = New()
+
+// OrDefault returns the Metrics, or the Default Metrics when they are nil
+func OrDefault(m *Metrics) *Metrics {
+	if m == nil {
+		return Default
+	}
+	return m
+}
+
+// collectors returns Trickster's process metrics, which are shared by every router in the process
+func collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		ProxyMaxConnections,
+		ProxyActiveConnections,
+		ProxyConnectionRequested,
+		ProxyConnectionAccepted,
+		ProxyConnectionClosed,
+		ProxyConnectionFailed,
+		ProxyBackgroundTasks,
+		BuildInfo,
+		LastReloadSuccessful,
+		LastReloadSuccessfulTimestamp,
+		LogOnceEntries,
+		LogEvents,
+		LogOnceSuppressed,
+		LogDedupSuppressed,
+		LogDropped,
+	}
+}
+
+// collectors returns the Metrics' collectors
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.FrontendRequestStatus,
+		m.FrontendRequestDuration,
+		m.FrontendRequestWrittenBytes,
+		m.FrontendRequestsRejected,
+		m.Panics,
+		m.ProxyRequestStatus,
+		m.ProxyRequestElements,
+		m.ProxyRequestDuration,
+		m.ProxyCacheFills,
+		m.ProxyPartialResponses,
+		m.ProxyChainRequests,
+		m.ProxyNegativeCacheTTL,
+		m.ProxyOriginClockSkew,
+		m.ProxyOriginHealthStatus,
+		m.ProxyOriginRateLimitRemaining,
+		m.ProxyOriginRateLimitReset,
+		m.ProxyOriginRateLimitThrottled,
+		m.ProxyOriginConcurrentRequests,
+		m.ProxyOriginConcurrencyQueueWait,
+		m.ProxyOriginConcurrencyRejections,
+		m.ProxyInflightProcessingBytes,
+		m.ProxyInflightProcessingPeakBytes,
+		m.ProxyMaxInflightProcessingBytes,
+		m.ProxyRefreshJobRuns,
+		m.ProxyRefreshJobDuration,
+		m.ProxyCacheInvalidations,
+		m.CacheObjectOperations,
+		m.CacheByteOperations,
+		m.CacheEvents,
+		m.CacheCorruptions,
+		m.CacheDecodeFailures,
+		m.CacheObjects,
+		m.CacheBytes,
+		m.CacheMaxObjects,
+		m.CacheMaxBytes,
+		m.CacheLockWaitDuration,
+		m.CachePendingDeletions,
+		m.CacheDeferredDeletions,
+		m.CacheUnusedEvictions,
+		m.CacheUnusedEvictionRewrites,
+		m.CacheReadOnly,
+		m.CacheReadOnlySkippedStores,
+		m.CacheEventsDropped,
+	}
+}
+
+// Register registers Trickster's process metrics and the Default Metrics with the provided
+// registry, such as the default registry of the Prometheus client for the Trickster binary.
+// Metrics that are already registered with the registry are skipped
+func Register(r prometheus.Registerer) error {
+	if err := register(r, collectors()); err != nil {
+		return err
+	}
+	return Default.Register(r)
+}
+
+// Register registers the Metrics with the provided registry, such as a registry of an
+// application that embeds Trickster. Metrics that are already registered with the registry
+// are skipped, but the Metrics of two routers cannot be registered with the same registry
+func (m *Metrics) Register(r prometheus.Registerer) error {
+	return register(r, m.collectors())
+}
+
+func register(r prometheus.Registerer, cs []prometheus.Collector) error {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			if are, ok := err.(prometheus.AlreadyRegisteredError); !ok || are.ExistingCollector != c {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // Handler returns the http handler for the listener
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// accessLogger holds the *tl.AccessLogger of the running config, which is nil when the
+// access log is disabled
+var accessLogger atomic.Value
+
+// SetAccessLogger sets the AccessLogger to which proxied requests are logged, and returns the
+// previous AccessLogger. A nil AccessLogger disables the access log
+func SetAccessLogger(al *tl.AccessLogger) *tl.AccessLogger {
+	prev, _ := accessLogger.Load().(*tl.AccessLogger)
+	accessLogger.Store(al)
+	return prev
+}
+
+// AccessLog writes an entry to the access log for each request to the origin, when the access
+// log is enabled
+func AccessLog(originName string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		al, _ := accessLogger.Load().(*tl.AccessLogger)
+		if al == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		aw := &accessLogWriter{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(aw, r)
+
+		// the request URI is that requested by the client, before any path prefix is stripped
+		// or the request is rewritten
+		e := tl.AccessEntry{
+			Time:       start,
+			Method:     r.Method,
+			Path:       r.RequestURI,
+			Proto:      r.Proto,
+			Status:     aw.status,
+			Bytes:      aw.bytes,
+			Duration:   time.Since(start),
+			ClientIP:   r.RemoteAddr,
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+			OriginName: originName,
+		}
+		if i := strings.IndexByte(e.Path, '?'); i >= 0 {
+			e.Path, e.Query = e.Path[:i], e.Path[i+1:]
+		}
+		if host, _, err := net.SplitHostPort(e.ClientIP); err == nil {
+			e.ClientIP = host
+		}
+		if e.Status == 0 {
+			e.Status = http.StatusOK
+		}
+		e.CacheStatus = cacheStatus(aw.Header().Get(headers.NameTricksterResult))
+		al.Log(&e)
+	})
+}
+
+// cacheStatus returns the cache lookup status reported by an X-Trickster-Result header value,
+// which follows its engine
+func cacheStatus(result string) string {
+	i := strings.Index(result, "; status=")
+	if i < 0 {
+		return ""
+	}
+	s := result[i+len("; status="):]
+	if j := strings.IndexByte(s, ';'); j >= 0 {
+		s = s[:j]
+	}
+	return s
+}
+
+// accessLogWriter records the status and size of a response
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessLogWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush sends any buffered response data to the client, so that streamed responses are
+// delivered as they are written when passing through the writer
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
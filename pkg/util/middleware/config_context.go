@@ -20,7 +20,10 @@ import (
 	"net/http"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/proxy/chain"
 	"github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/instance"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
@@ -30,7 +33,7 @@ import (
 )
 
 // WithResourcesContext ...
-func WithResourcesContext(client origins.Client, oc *oo.Options,
+func WithResourcesContext(inst *instance.Instance, client origins.Client, oc *oo.Options,
 	c cache.Cache, p *po.Options, t *tracing.Tracer,
 	l *tl.Logger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -40,6 +43,18 @@ func WithResourcesContext(client origins.Client, oc *oo.Options,
 		} else {
 			resources = request.NewResources(oc, p, c.Configuration(), c, client, t, l)
 		}
-		next.ServeHTTP(w, r.WithContext(context.WithResources(r.Context(), resources)))
+		resources.Instance = inst
+		resources.ChainDepth = chain.Depth(r.Header)
+		resources.UpstreamChain = &chain.Upstream{}
+		// the request ID is returned to the client, and forwarded upstream with the request, so
+		// that the request's log events are correlated across each Trickster that handles it
+		id := requestID(r)
+		r.Header.Set(headers.NameRequestID, id)
+		w.Header().Set(headers.NameRequestID, id)
+		ctx := context.WithRequestID(r.Context(), id)
+		if l != nil {
+			resources.Logger = l.With(request.LogPairs(ctx))
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithResources(ctx, resources)))
 	})
 }
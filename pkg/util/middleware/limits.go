@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// maxPreviewBytes is the length to which rejected URLs and bodies are truncated when logged
+const maxPreviewBytes = 256
+
+// LimitRequestSize rejects requests whose URL is longer than maxURLBytes with a 414 URI Too Long,
+// and requests whose body is larger than maxBodyBytes with a 413 Payload Too Large, before any
+// other handler reads the request. Bodies are read through an http.MaxBytesReader, so no more
+// than maxBodyBytes of an oversized body are ever read. A limit of 0 or less is not enforced.
+// Rejections are counted in m, or in the Default Metrics when m is nil
+func LimitRequestSize(m *metrics.Metrics, oc *oo.Options, pc *po.Options, maxURLBytes, maxBodyBytes int,
+	logger *tl.Logger, next http.Handler) http.Handler {
+
+	m = metrics.OrDefault(m)
+	reject := func(w http.ResponseWriter, r *http.Request, code int, errCode, limit string,
+		limitBytes int, requestBytes string, preview []byte) {
+		m.FrontendRequestsRejected.WithLabelValues(oc.Name, oc.OriginType, pc.Path, limit).Inc()
+		if logger != nil {
+			if len(preview) > maxPreviewBytes {
+				preview = preview[:maxPreviewBytes]
+			}
+			logger.Warn("request exceeds size limit", tl.Pairs{"originName": oc.Name,
+				"path": pc.Path, "limit": limit, "limitBytes": limitBytes,
+				"requestBytes": requestBytes, "preview": string(preview)})
+		}
+		responseError(oc, pc, code, errCode,
+			fmt.Sprintf("request exceeds the %s limit of %d bytes", limit, limitBytes)).Respond(w, r)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if maxURLBytes > 0 {
+			u := r.RequestURI
+			if u == "" {
+				u = r.URL.RequestURI()
+			}
+			if len(u) > maxURLBytes {
+				reject(w, r, http.StatusRequestURITooLong, txe.CodeRequestURITooLong,
+					"max_request_url_bytes", maxURLBytes, strconv.Itoa(len(u)), []byte(u))
+				return
+			}
+		}
+
+		if maxBodyBytes > 0 && r.Body != nil && r.Body != http.NoBody {
+			if r.ContentLength > int64(maxBodyBytes) {
+				preview, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxPreviewBytes))
+				reject(w, r, http.StatusRequestEntityTooLarge, txe.CodeRequestTooLarge,
+					"max_request_body_bytes", maxBodyBytes, strconv.FormatInt(r.ContentLength, 10), preview)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, int64(maxBodyBytes))
+			// when the length is unknown (e.g., chunked), the body is read now, so that
+			// an oversized body is rejected before any handler or rewriter reads it
+			if r.ContentLength < 0 {
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil && len(b) >= maxBodyBytes {
+					reject(w, r, http.StatusRequestEntityTooLarge, txe.CodeRequestTooLarge,
+						"max_request_body_bytes", maxBodyBytes, "unknown", b)
+					return
+				}
+				if err != nil {
+					responseError(oc, pc, http.StatusBadRequest, txe.CodeBadRequest,
+						"unable to read the request body").Respond(w, r)
+					return
+				}
+				r.Body = ioutil.NopCloser(bytes.NewReader(b))
+				r.ContentLength = int64(len(b))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// responseError returns a ResponseError for a request rejected by middleware, which may run
+// before the request's resources are attached to its context
+func responseError(oc *oo.Options, pc *po.Options, code int, errCode, message string) *txe.ResponseError {
+	e := txe.NewResponseError(code, errCode, message)
+	if oc != nil {
+		e.Origin, e.OriginType = oc.Name, oc.OriginType
+	}
+	if pc != nil {
+		e.Path = pc.Path
+	}
+	return e
+}
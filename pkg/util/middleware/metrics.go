@@ -25,8 +25,9 @@ import (
 
 // Decorate decorates a function in such a way that it captures both the
 // returned status and the time used to execute a request from the front end
-// perspective
-func Decorate(originName, originType, path string, next http.Handler) http.Handler {
+// perspective, in m, or in the Default Metrics when m is nil
+func Decorate(m *metrics.Metrics, originName, originType, path string, next http.Handler) http.Handler {
+	m = metrics.OrDefault(m)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		observer := &responseObserver{
 			w,
@@ -37,11 +38,11 @@ func Decorate(originName, originType, path string, next http.Handler) http.Handl
 		n := time.Now()
 		next.ServeHTTP(observer, r)
 
-		metrics.FrontendRequestDuration.WithLabelValues(originName, originType,
+		m.FrontendRequestDuration.WithLabelValues(originName, originType,
 			r.Method, path, observer.status).Observe(time.Since(n).Seconds())
-		metrics.FrontendRequestStatus.WithLabelValues(originName, originType,
+		m.FrontendRequestStatus.WithLabelValues(originName, originType,
 			r.Method, path, observer.status).Inc()
-		metrics.FrontendRequestWrittenBytes.WithLabelValues(originName, originType,
+		m.FrontendRequestWrittenBytes.WithLabelValues(originName, originType,
 			r.Method, path, observer.status).Add(observer.bytesWritten)
 	})
 }
@@ -76,3 +77,11 @@ func (w *responseObserver) Write(b []byte) (int, error) {
 
 	return bytesWritten, err
 }
+
+// Flush sends any buffered response data to the client, so that streamed responses are
+// delivered as they are written when passing through the observer
+func (w *responseObserver) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the options for named Middleware instances, which Paths
+// reference to compose the chain of handlers that precede the Path's handler
+package options
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
+)
+
+const (
+	// TypeMetrics is the Middleware Type that records the frontend request metrics
+	TypeMetrics = "metrics"
+	// TypeLimits is the Middleware Type that rejects requests exceeding size limits
+	TypeLimits = "limits"
+	// TypeRewriter is the Middleware Type that passes requests through a Request Rewriter
+	TypeRewriter = "rewriter"
+)
+
+// types is the set of available Middleware Types
+var types = map[string]bool{
+	TypeMetrics:  true,
+	TypeLimits:   true,
+	TypeRewriter: true,
+}
+
+// Types returns the sorted list of available Middleware Types
+func Types() []string {
+	l := make([]string, 0, len(types))
+	for k := range types {
+		l = append(l, k)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// Options defines a named instance of a Middleware Type and its settings
+type Options struct {
+	// Name provides the name of the Middleware instance
+	Name string `toml:"-"`
+	// Type is the Middleware Type of the instance ('metrics', 'limits' or 'rewriter')
+	Type string `toml:"type"`
+	// MaxRequestURLBytes is the maximum length of a request URL for the 'limits' type.
+	// When 0, the Frontend's limit is used
+	MaxRequestURLBytes int `toml:"max_request_url_bytes"`
+	// MaxRequestBodyBytes is the maximum size of a request body for the 'limits' type.
+	// When 0, the Frontend's limit is used
+	MaxRequestBodyBytes int `toml:"max_request_body_bytes"`
+	// ReqRewriterName is the name of the configured Request Rewriter used by the 'rewriter' type
+	ReqRewriterName string `toml:"req_rewriter_name"`
+
+	// ReqRewriter is the compiled Request Rewriter indicated by ReqRewriterName
+	ReqRewriter rewriter.RewriteInstructions `toml:"-"`
+	// Implicit is true when the instance was translated from a Path's settings,
+	// rather than configured in the middlewares section
+	Implicit bool `toml:"-"`
+}
+
+// Clone returns an exact copy of the Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		Name:                o.Name,
+		Type:                o.Type,
+		MaxRequestURLBytes:  o.MaxRequestURLBytes,
+		MaxRequestBodyBytes: o.MaxRequestBodyBytes,
+		ReqRewriterName:     o.ReqRewriterName,
+		ReqRewriter:         o.ReqRewriter,
+		Implicit:            o.Implicit,
+	}
+}
+
+// ProcessMiddlewareOptions validates the provided Middleware Options collection, and resolves
+// the Request Rewriters referenced by its 'rewriter' instances from the provided compiled rewriters
+func ProcessMiddlewareOptions(mo map[string]*Options,
+	rewriters map[string]rewriter.RewriteInstructions) error {
+	for k, v := range mo {
+		v.Name = k
+		v.Type = strings.ToLower(v.Type)
+		if _, ok := types[v.Type]; !ok {
+			return fmt.Errorf("invalid type [%s] provided in middleware [%s]. knownTypes: %s",
+				v.Type, k, strings.Join(Types(), ", "))
+		}
+		switch v.Type {
+		case TypeLimits:
+			if v.MaxRequestURLBytes < 0 || v.MaxRequestBodyBytes < 0 {
+				return fmt.Errorf("invalid negative size limit provided in middleware [%s]", k)
+			}
+		case TypeRewriter:
+			ri, ok := rewriters[v.ReqRewriterName]
+			if !ok {
+				return fmt.Errorf("invalid rewriter name [%s] provided in middleware [%s]",
+					v.ReqRewriterName, k)
+			}
+			v.ReqRewriter = ri
+		}
+	}
+	return nil
+}
+
+// ResolveStack returns the Middleware instances for the provided ordered list of names,
+// and fails if any name is not in the collection or is listed more than once
+func ResolveStack(mo map[string]*Options, names []string) ([]*Options, error) {
+	stack := make([]*Options, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, n := range names {
+		if seen[n] {
+			return nil, fmt.Errorf("middleware [%s] is listed more than once", n)
+		}
+		seen[n] = true
+		m, ok := mo[n]
+		if !ok {
+			return nil, fmt.Errorf("unknown middleware name [%s]", n)
+		}
+		stack = append(stack, m)
+	}
+	return stack, nil
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
+)
+
+func TestProcessMiddlewareOptions(t *testing.T) {
+
+	rewriters := map[string]rewriter.RewriteInstructions{
+		"example": make(rewriter.RewriteInstructions, 1),
+	}
+
+	tests := []struct {
+		o         *Options
+		expectErr bool
+	}{
+		{&Options{Type: "LIMITS", MaxRequestURLBytes: 1024}, false},
+		{&Options{Type: TypeLimits, MaxRequestBodyBytes: -1}, true},
+		{&Options{Type: TypeMetrics}, false},
+		{&Options{Type: TypeRewriter, ReqRewriterName: "example"}, false},
+		{&Options{Type: TypeRewriter, ReqRewriterName: "missing"}, true},
+		{&Options{Type: "cors"}, true},
+	}
+
+	for i, test := range tests {
+		err := ProcessMiddlewareOptions(map[string]*Options{"test": test.o}, rewriters)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %s", i, err.Error())
+		}
+		if test.o.Name != "test" {
+			t.Errorf("test %d: expected name %s got %s", i, "test", test.o.Name)
+		}
+		if test.o.Type == TypeRewriter && len(test.o.ReqRewriter) != 1 {
+			t.Errorf("test %d: expected resolved rewriter", i)
+		}
+	}
+}
+
+func TestResolveStack(t *testing.T) {
+
+	mo := map[string]*Options{
+		"metrics": {Name: "metrics", Type: TypeMetrics},
+		"limits":  {Name: "limits", Type: TypeLimits},
+	}
+
+	stack, err := ResolveStack(mo, []string{"limits", "metrics"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stack) != 2 || stack[0] != mo["limits"] || stack[1] != mo["metrics"] {
+		t.Error("expected stack in listed order")
+	}
+
+	if _, err = ResolveStack(mo, []string{"limits", "auth-internal"}); err == nil {
+		t.Error("expected error for unknown middleware name")
+	}
+
+	if _, err = ResolveStack(mo, []string{"limits", "limits"}); err == nil {
+		t.Error("expected error for duplicate middleware name")
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := &Options{Name: "test", Type: TypeLimits, MaxRequestURLBytes: 1, MaxRequestBodyBytes: 2}
+	c := o.Clone()
+	if c.Name != o.Name || c.Type != o.Type || c.MaxRequestURLBytes != o.MaxRequestURLBytes ||
+		c.MaxRequestBodyBytes != o.MaxRequestBodyBytes {
+		t.Error("expected identical clone")
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// SanitizeParams ensures that semantically important query parameters occur no more than once in
+// incoming requests, so that the cache key and upstream request never disagree about their values.
+// Duplicates are either collapsed to the occurrence the origin would honor, or rejected with a
+// 400 Bad Request, as configured for the origin
+func SanitizeParams(client origins.Client, oc *oo.Options, next http.Handler) http.Handler {
+
+	names, useLast := params.DefaultImportantParams, false
+	if pp, ok := client.(origins.ParamsPolicy); ok {
+		names, useLast = pp.ImportantParams()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var dups []string
+		if r.URL != nil && r.URL.RawQuery != "" {
+			qp := r.URL.Query()
+			if d := params.DuplicatedParams(qp, names); len(d) > 0 {
+				dups = d
+				params.CollapseParams(qp, names, useLast)
+				r.URL.RawQuery = qp.Encode()
+			}
+		}
+
+		if methods.HasBody(r.Method) &&
+			r.Header.Get(headers.NameContentType) == headers.ValueXFormURLEncoded {
+			qp, _, _ := params.GetRequestValues(r)
+			if d := params.DuplicatedParams(qp, names); len(d) > 0 {
+				dups = append(dups, d...)
+				params.CollapseParams(qp, names, useLast)
+				params.SetRequestValues(r, qp)
+				// the next ParseForm will rebuild Form from the collapsed PostForm
+				r.PostForm = qp
+				r.Form = nil
+			}
+		}
+
+		if len(dups) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if rsc := request.GetResources(r); rsc != nil && rsc.Logger != nil {
+			rsc.Logger.Debug("request has duplicate query parameters",
+				tl.Pairs{"originName": oc.Name, "params": strings.Join(dups, ","),
+					"rejected": oc.RejectDuplicateParams})
+		}
+
+		if oc.RejectDuplicateParams {
+			responseError(oc, nil, http.StatusBadRequest, txe.CodeDuplicateParams,
+				"duplicate query parameters: "+strings.Join(dups, ",")).Respond(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
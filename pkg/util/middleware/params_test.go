@@ -0,0 +1,188 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+)
+
+// paramsClient is an origins.Client that reports its important params as an origins.ParamsPolicy
+type paramsClient struct {
+	origins.Client
+	names   []string
+	useLast bool
+}
+
+func (c *paramsClient) ImportantParams() ([]string, bool) {
+	return c.names, c.useLast
+}
+
+// echoParams is a handler that writes the query string and form body it receives
+func echoParams(query, form *string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*query = r.URL.RawQuery
+		if r.Body != nil {
+			b, _ := ioutil.ReadAll(r.Body)
+			*form = string(b)
+		}
+		r.ParseForm()
+		w.Write([]byte(r.Form.Get("query")))
+	})
+}
+
+func TestSanitizeParamsQuery(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		client   origins.Client
+		rawQuery string
+		expected string
+	}{
+		{
+			// clients without a ParamsPolicy use the default params and the first occurrence
+			name:     "default",
+			rawQuery: "start=1000&start=9999&end=2000",
+			expected: "end=2000&start=1000",
+		},
+		{
+			name:     "first",
+			client:   &paramsClient{names: []string{"query", "time"}},
+			rawQuery: "query=up&query=down&time=1&time=2",
+			expected: "query=up&time=1",
+		},
+		{
+			name:     "last",
+			client:   &paramsClient{names: []string{"query"}, useLast: true},
+			rawQuery: "query=up&query=down",
+			expected: "query=down",
+		},
+		{
+			// params that are not important, like match[], may be legitimately repeated
+			name:     "unimportant",
+			client:   &paramsClient{names: []string{"query"}},
+			rawQuery: "match%5B%5D=a&match%5B%5D=b",
+			expected: "match%5B%5D=a&match%5B%5D=b",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var query, form string
+			h := SanitizeParams(test.client, &oo.Options{Name: "test"}, echoParams(&query, &form))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://127.0.0.1/?"+test.rawQuery, nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+			}
+			if query != test.expected {
+				t.Errorf("expected %s got %s", test.expected, query)
+			}
+		})
+	}
+}
+
+func TestSanitizeParamsForm(t *testing.T) {
+
+	var query, form string
+	client := &paramsClient{names: []string{"query", "db"}}
+	h := SanitizeParams(client, &oo.Options{Name: "test"}, echoParams(&query, &form))
+
+	r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/query",
+		strings.NewReader("query=up&query=down&db=a&db=b"))
+	r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if form != "db=a&query=up" {
+		t.Errorf("expected %s got %s", "db=a&query=up", form)
+	}
+	// a ParseForm by the next handler sees only the collapsed values
+	if v := w.Body.String(); v != "up" {
+		t.Errorf("expected %s got %s", "up", v)
+	}
+
+	// bodies that are not form-encoded are not altered
+	r = httptest.NewRequest(http.MethodPost, "http://127.0.0.1/query",
+		strings.NewReader("query=up&query=down"))
+	r.Header.Set(headers.NameContentType, "text/plain")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if form != "query=up&query=down" {
+		t.Errorf("expected %s got %s", "query=up&query=down", form)
+	}
+}
+
+func TestSanitizeParamsReject(t *testing.T) {
+
+	var query, form string
+	client := &paramsClient{names: []string{"query", "db"}}
+	oc := &oo.Options{Name: "test", OriginType: "influxdb", RejectDuplicateParams: true}
+	h := SanitizeParams(client, oc, echoParams(&query, &form))
+
+	// requests without duplicates are passed through
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://127.0.0.1/?query=up&db=a", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if query != "query=up&db=a" {
+		t.Errorf("expected %s got %s", "query=up&db=a", query)
+	}
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "http://127.0.0.1/?query=up&query=down", nil),
+		func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/",
+				strings.NewReader(url.Values{"db": {"a", "b"}}.Encode()))
+			r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+			return r
+		}(),
+	} {
+		query, form = "", ""
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+		}
+		if query != "" || form != "" {
+			t.Error("expected the rejected request not to be served")
+		}
+		e := &txe.ResponseError{}
+		if err := json.Unmarshal(w.Body.Bytes(), e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Code != txe.CodeDuplicateParams {
+			t.Errorf("expected %s got %s", txe.CodeDuplicateParams, e.Code)
+		}
+		if e.Origin != "test" {
+			t.Errorf("expected %s got %s", "test", e.Origin)
+		}
+	}
+}
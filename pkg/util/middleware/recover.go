@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	txe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// Recover recovers from a panic while handling a request to the path of the origin, logging it
+// as an error event with its stack trace, so that it reaches the log pipeline rather than only
+// the default dump to stderr. The client receives a 500 response, unless the response was
+// already started. http.ErrAbortHandler is not recovered, since it aborts the response on purpose.
+// Panics are counted in m, or in the Default Metrics when m is nil
+func Recover(m *metrics.Metrics, originName, path string, log *tl.Logger, next http.Handler) http.Handler {
+	m = metrics.OrDefault(m)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoverWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			m.Panics.WithLabelValues(originName, path).Inc()
+			// the request ID is that set on the response when the request was routed, or a new
+			// one when the panic occurred before then
+			id := w.Header().Get(headers.NameRequestID)
+			if id == "" {
+				id = requestID(r)
+				w.Header().Set(headers.NameRequestID, id)
+			}
+			if log != nil {
+				log.Error("recovered from panic while handling request", tl.Pairs{
+					"panic":      fmt.Sprint(v),
+					"stack":      string(debug.Stack()),
+					"url":        r.URL.String(),
+					"originName": originName,
+					"path":       path,
+					"request_id": id,
+				})
+			}
+			if !rw.wroteHeader {
+				txe.NewResponseError(http.StatusInternalServerError, txe.CodeInternal,
+					"internal error").Respond(w, r)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// recoverWriter records whether the response was started, after which its status can't be changed
+type recoverWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recoverWriter) WriteHeader(statusCode int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recoverWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered response data to the client, so that streamed responses are
+// delivered as they are written when passing through the recoverWriter
+func (w *recoverWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
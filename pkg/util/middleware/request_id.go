@@ -0,0 +1,66 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// maxRequestIDLength is the maximum length of a request ID accepted from a client
+const maxRequestIDLength = 128
+
+// requestID returns the ID of the request, which is that assigned when it was routed through a
+// rule, or that provided by the client when it is valid, or otherwise a new ID
+func requestID(r *http.Request) string {
+	if id := context.RequestID(r.Context()); id != "" {
+		return id
+	}
+	if id := r.Header.Get(headers.NameRequestID); validRequestID(id) {
+		return id
+	}
+	return newRequestID()
+}
+
+// validRequestID returns true if the request ID is not empty, is not too long, and only has
+// printable ASCII characters other than space, so that it cannot corrupt the log events it is in
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a new random request ID of 32 hex characters
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/proxy/timeconv"
+	tspan "github.com/tricksterproxy/trickster/pkg/tracing/span"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"go.opentelemetry.io/otel/api/kv"
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+// TimeoutBudget derives the request's timeout budget from the client's timeout hint, provided as
+// the origin's timeout parameter, the origin's timeout header, or the configured client timeout,
+// in that order. The budget is the lesser of the hint and the origin's timeout, and its deadline is
+// applied to all upstream requests made on behalf of the request, so they are abandoned with the client
+func TimeoutBudget(client origins.Client, oc *oo.Options, next http.Handler) http.Handler {
+
+	var param string
+	if tp, ok := client.(origins.TimeoutParamer); ok {
+		param = tp.TimeoutParam()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		hint, source := timeoutHint(r, param, oc)
+		if hint <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		budget := hint
+		if oc.Timeout > 0 && oc.Timeout < budget {
+			budget = oc.Timeout
+		}
+
+		rsc := request.GetResources(r)
+		if rsc == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rsc.TimeoutDeadline = time.Now().Add(budget)
+		tspan.SetAttributes(rsc.Tracer, trace.SpanFromContext(r.Context()),
+			kv.Int64("timeout.budget_ms", budget.Milliseconds()),
+			kv.String("timeout.hint_source", source),
+		)
+		if rsc.Logger != nil {
+			rsc.Logger.Debug("applying timeout budget",
+				tl.Pairs{"originName": oc.Name, "budget": budget.String(),
+					"hint": hint.String(), "source": source})
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// timeoutHint returns the client's timeout hint and the name of its source, or 0 if it has none
+func timeoutHint(r *http.Request, param string, oc *oo.Options) (time.Duration, string) {
+	if param != "" {
+		qp, _, _ := params.GetRequestValues(r)
+		if v := qp.Get(param); v != "" {
+			if d, err := timeconv.ParseTimeout(v); err == nil && d > 0 {
+				return d, "param"
+			}
+		}
+	}
+	if oc.TimeoutHeader != "" {
+		if v := r.Header.Get(oc.TimeoutHeader); v != "" {
+			if d, err := timeconv.ParseTimeout(v); err == nil && d > 0 {
+				return d, "header"
+			}
+		}
+	}
+	if oc.ClientTimeout > 0 {
+		return oc.ClientTimeout, "config"
+	}
+	return 0, ""
+}
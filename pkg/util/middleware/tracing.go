@@ -35,6 +35,10 @@ func Trace(tr *tracing.Tracer, next http.Handler) http.Handler {
 			defer span.End()
 
 			rsc := request.GetResources(r)
+			// the request's log events are correlated with its trace
+			if rsc != nil && rsc.Logger != nil {
+				rsc.Logger = rsc.Logger.With(request.LogPairs(r.Context()))
+			}
 			if rsc != nil &&
 				rsc.OriginConfig != nil &&
 				rsc.PathConfig != nil &&
@@ -0,0 +1,189 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conformance
+
+import (
+	"bytes"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// checkColdFetch verifies that a query that misses the cache is answered as the origin answers it
+func checkColdFetch(h *harness) {
+	w := h.expect(h.request(1, h.end.Add(-6*time.Hour), h.end.Add(-3*time.Hour)), "kmiss")
+	if v := h.decode(w.Body.Bytes()); len(v) != h.seriesCount() {
+		h.Errorf("expected %d series got %d", h.seriesCount(), len(v))
+	}
+}
+
+// checkWarmHit verifies that a query served from the cache is answered exactly as it was when it
+// was fetched from the origin
+func checkWarmHit(h *harness) {
+	r := h.request(2, h.end.Add(-6*time.Hour), h.end.Add(-3*time.Hour))
+	cold := h.expect(r, "kmiss")
+	warm := h.expect(r, "hit")
+	if !bytes.Equal(cold.Body.Bytes(), warm.Body.Bytes()) {
+		h.Errorf("cached response differs from uncached response:\nexpected %s\ngot      %s",
+			cold.Body.String(), warm.Body.String())
+	}
+}
+
+// checkPartialHit verifies that cached and newly fetched ranges of a query are merged exactly
+func checkPartialHit(h *harness) {
+	h.expect(h.request(3, h.end.Add(-6*time.Hour), h.end.Add(-3*time.Hour)), "kmiss")
+	// the range is extended on both sides of the cached range
+	h.expect(h.request(3, h.end.Add(-8*time.Hour), h.end.Add(-1*time.Hour)), "phit")
+	h.expect(h.request(3, h.end.Add(-8*time.Hour), h.end.Add(-1*time.Hour)), "hit")
+	h.expect(h.request(3, h.end.Add(-7*time.Hour), h.end.Add(-2*time.Hour)), "hit")
+}
+
+// checkStepBoundaries verifies that the time ranges of queries are aligned to their step, so that
+// a query whose range is not aligned is answered as the origin answers the aligned range, and
+// that ranges of a single step are answered
+func checkStepBoundaries(h *harness) {
+	half := Step / 2
+	start, end := h.end.Add(-6*time.Hour), h.end.Add(-3*time.Hour)
+	expected := h.request(4, start, end)
+	for i, r := range []struct{ start, end time.Time }{
+		{start.Add(half), end.Add(half)},
+		{start, end},
+		{start.Add(1 * time.Second), end.Add(Step - time.Second)},
+	} {
+		w := h.proxy(h.request(4, r.start, r.end))
+		code, b := h.direct(expected)
+		if w.Code != code {
+			h.Fatalf("(%d) expected status %d got %d: %s", i, code, w.Code, w.Body.String())
+		}
+		if d := Diff(h.decode(b), h.decode(w.Body.Bytes())); d != "" {
+			h.Errorf("(%d) response for %s to %s differs from origin response for %s to %s:\n%s",
+				i, r.start.Format(time.RFC3339), r.end.Format(time.RFC3339),
+				start.Format(time.RFC3339), end.Format(time.RFC3339), d)
+		}
+	}
+
+	// a range of a single data point, both uncached and cached
+	h.expect(h.request(4, h.end.Add(-2*time.Hour), h.end.Add(-2*time.Hour)), "")
+	h.expect(h.request(4, h.end.Add(-4*time.Hour), h.end.Add(-4*time.Hour)), "hit")
+}
+
+// checkExtentBoundaries verifies that adjacent and overlapping cached ranges are merged without
+// dropping or duplicating the data points at their boundaries
+func checkExtentBoundaries(h *harness) {
+	// the cache is cropped at the end of each request's range, so the ranges are requested from
+	// oldest to newest to retain all of them
+	h.expect(h.request(5, h.end.Add(-7*time.Hour), h.end.Add(-6*time.Hour).Add(-Step)), "kmiss")
+	// the range begins one step after the end of the cached range
+	h.expect(h.request(5, h.end.Add(-6*time.Hour), h.end.Add(-5*time.Hour)), "")
+	// the range begins at the end of the cached range
+	h.expect(h.request(5, h.end.Add(-5*time.Hour), h.end.Add(-4*time.Hour)), "phit")
+	h.expect(h.request(5, h.end.Add(-4*time.Hour).Add(Step), h.end.Add(-3*time.Hour)), "")
+	h.expect(h.request(5, h.end.Add(-7*time.Hour), h.end.Add(-3*time.Hour)), "hit")
+}
+
+// checkEmptyResults verifies that queries with no results are answered as the origin answers them,
+// whether or not they are cached
+func checkEmptyResults(h *harness) {
+	r := h.request(6, h.end.Add(-6*time.Hour), h.end.Add(-3*time.Hour), "series_count=0")
+	for i := 0; i < 2; i++ {
+		n := 0
+		for _, points := range h.decode(h.expect(r, "").Body.Bytes()) {
+			n += len(points)
+		}
+		if n != 0 {
+			h.Errorf("(%d) expected %d data points got %d", i, 0, n)
+		}
+	}
+}
+
+// checkErrorPassthrough verifies that the origin's error responses are passed to the client,
+// with their status code and body, and are not cached
+func checkErrorPassthrough(h *harness) {
+	r := h.request(7, h.end.Add(-6*time.Hour), h.end.Add(-3*time.Hour), "error_rate=1", "status_code=400")
+	for i := 0; i < 2; i++ {
+		w := h.proxy(r)
+		code, b := h.direct(r)
+		if w.Code != code {
+			h.Fatalf("(%d) expected status %d got %d", i, code, w.Code)
+		}
+		if !bytes.Equal(w.Body.Bytes(), b) {
+			h.Errorf("(%d) expected body %s got %s", i, string(b), w.Body.String())
+		}
+	}
+}
+
+// checkCacheKeyStability verifies that a query's cache key does not depend on the order of its
+// parameters, or on how they are encoded
+func checkCacheKeyStability(h *harness) {
+	r := h.request(8, h.end.Add(-6*time.Hour), h.end.Add(-3*time.Hour))
+	h.expect(r, "kmiss")
+
+	// the parameters are reversed, and spaces are percent-encoded rather than encoded as '+'
+	qp := r.URL.Query()
+	names := make([]string, 0, len(qp))
+	for k := range qp {
+		names = append(names, k)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	parts := make([]string, 0, len(names))
+	for _, k := range names {
+		for _, v := range qp[k] {
+			parts = append(parts, url.QueryEscape(k)+"="+strings.Replace(url.QueryEscape(v), "+", "%20", -1))
+		}
+	}
+	r2 := r.Clone(r.Context())
+	r2.URL.RawQuery = strings.Join(parts, "&")
+	h.expect(r2, "hit")
+}
+
+// checkTimeRangeQuery verifies that the client parses the time range and step of a query
+func checkTimeRangeQuery(h *harness) {
+	c := h.timeseriesClient()
+	start, end := h.end.Add(-6*time.Hour), h.end.Add(-3*time.Hour)
+	trq, err := c.ParseTimeRangeQuery(withPathConfig(c, h.request(9, start, end)))
+	if err != nil {
+		h.Fatal(err)
+	}
+	if trq.Step != Step {
+		h.Errorf("expected step %s got %s", Step, trq.Step)
+	}
+	if !trq.Extent.Start.Equal(start) || !trq.Extent.End.Equal(end) {
+		h.Errorf("expected extent %d-%d got %d-%d", start.Unix(), end.Unix(),
+			trq.Extent.Start.Unix(), trq.Extent.End.Unix())
+	}
+}
+
+// checkMarshalRoundTrip verifies that the client's timeseries codec preserves the data points of
+// the origin's responses
+func checkMarshalRoundTrip(h *harness) {
+	c := h.timeseriesClient()
+	for _, seriesCount := range []string{"series_count=0", "series_count=3"} {
+		_, b := h.direct(h.request(10, h.end.Add(-6*time.Hour), h.end.Add(-3*time.Hour), seriesCount))
+		ts, err := c.UnmarshalTimeseries(b)
+		if err != nil {
+			h.Fatal(err)
+		}
+		b2, err := c.MarshalTimeseries(ts)
+		if err != nil {
+			h.Fatal(err)
+		}
+		if d := Diff(h.decode(b), h.decode(b2)); d != "" {
+			h.Errorf("%s: marshaled timeseries differs from origin response:\n%s", seriesCount, d)
+		}
+	}
+}
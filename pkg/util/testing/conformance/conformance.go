@@ -0,0 +1,338 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package conformance is a suite of tests that verifies that Trickster serves the timeseries
+// queries of an origin provider, including one maintained outside of Trickster's source tree, as
+// the provider's origin would. Given a Fixture, which describes the provider and generates its
+// queries, Run proxies the queries through Trickster to a simulated origin, and compares each
+// response with the origin's response to the same query.
+package conformance
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/trickster"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/testing/originsim"
+
+	"github.com/gorilla/mux"
+)
+
+// Step is the step of the suite's queries
+const Step = time.Minute
+
+// DefaultSeriesCount is the number of series requested by the suite's queries
+const DefaultSeriesCount = 3
+
+// maxDiffs is the maximum number of differing data points reported for a response
+const maxDiffs = 10
+
+// Fixture describes an origin provider under test, and generates the requests for its queries
+type Fixture struct {
+	// OriginType is the origin type under which the provider is registered
+	OriginType string
+	// Origin serves the provider's origin API. When nil, an originsim.Handler serves it
+	Origin http.Handler
+	// Format is the originsim wire format of the origin's responses, in which they are decoded
+	// when Decode is nil
+	Format string
+	// Decode returns the data points of a response body, for comparing responses irrespective
+	// of the order of their series and data points
+	Decode func([]byte) (originsim.Values, error)
+	// Request returns a request for the series described by spec over the time range, at the
+	// step. spec is a series spec in the format of originsim.ParseSpec, such as
+	// {seed=42,series_count=3}, which the request embeds in its query
+	Request func(spec string, start, end time.Time, step time.Duration) *http.Request
+	// SeriesCount is the number of series in the responses to the suite's queries, when the
+	// origin returns fewer than the DefaultSeriesCount that they request
+	SeriesCount int
+}
+
+// check is a test of the conformance suite
+type check struct {
+	name string
+	run  func(*harness)
+}
+
+var checks = []check{
+	{"ColdFetch", checkColdFetch},
+	{"WarmHit", checkWarmHit},
+	{"PartialHit", checkPartialHit},
+	{"StepBoundaries", checkStepBoundaries},
+	{"ExtentBoundaries", checkExtentBoundaries},
+	{"EmptyResults", checkEmptyResults},
+	{"ErrorPassthrough", checkErrorPassthrough},
+	{"CacheKeyStability", checkCacheKeyStability},
+	{"TimeRangeQuery", checkTimeRangeQuery},
+	{"MarshalRoundTrip", checkMarshalRoundTrip},
+}
+
+// Run runs the conformance suite against the provider described by the Fixture, with each
+// check as a subtest of t. Each check proxies its queries through its own Trickster instance
+// and memory cache, so that the checks are independent of each other
+func Run(t *testing.T, f *Fixture) {
+	if f == nil || f.OriginType == "" || f.Request == nil {
+		t.Fatal("the conformance suite requires a fixture with an origin type and request generator")
+	}
+	if f.Decode == nil {
+		if f.Format == "" {
+			t.Fatal("the conformance suite requires a fixture with a format or decoder")
+		}
+		format := f.Format
+		f.Decode = func(b []byte) (originsim.Values, error) { return originsim.Decode(format, b) }
+	}
+	for _, c := range checks {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			h := newHarness(t, f)
+			defer h.Close()
+			c.run(h)
+		})
+	}
+}
+
+// harness proxies a check's queries through Trickster to the fixture's origin
+type harness struct {
+	*testing.T
+	f       *Fixture
+	origin  *httptest.Server
+	handler http.Handler
+	closer  func() error
+	// end is the end of the check's time ranges, which is aligned to the step, and far enough
+	// in the past that the ranges are not subject to backfill tolerance
+	end time.Time
+}
+
+func newHarness(t *testing.T, f *Fixture) *harness {
+	oh := f.Origin
+	if oh == nil {
+		oh = originsim.NewHandler()
+	}
+	origin := httptest.NewServer(oh)
+	conf, err := config.LoadDocument(fmt.Sprintf(`
+[origins]
+  [origins.default]
+  origin_type = '%s'
+  origin_url = '%s'
+  fast_forward_disable = true
+  health_check_interval_ms = 0
+  preflight_policy = 'ignore'
+`, f.OriginType, origin.URL))
+	if err != nil {
+		origin.Close()
+		t.Fatal(err)
+	}
+	handler, closer, err := trickster.NewHandler(conf, &trickster.Options{Logger: tl.ConsoleLogger("error")})
+	if err != nil {
+		origin.Close()
+		t.Fatal(err)
+	}
+	return &harness{T: t, f: f, origin: origin, handler: handler, closer: closer.Close,
+		end: time.Now().Add(-time.Hour).Truncate(Step)}
+}
+
+// Close releases the harness's Trickster instance and origin
+func (h *harness) Close() {
+	h.closer()
+	h.origin.Close()
+}
+
+// spec returns the series spec of a query for the seed's series, with any additional spec keys
+func (h *harness) spec(seed int, extra ...string) string {
+	return "{" + strings.Join(append([]string{fmt.Sprintf("seed=%d", seed),
+		fmt.Sprintf("series_count=%d", DefaultSeriesCount)}, extra...), ",") + "}"
+}
+
+// request returns a request for the seed's series over the time range
+func (h *harness) request(seed int, start, end time.Time, extra ...string) *http.Request {
+	return h.f.Request(h.spec(seed, extra...), start, end, Step)
+}
+
+// proxy returns Trickster's response to the request
+func (h *harness) proxy(r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.handler.ServeHTTP(w, r.Clone(r.Context()))
+	return w
+}
+
+// direct returns the origin's response to the request
+func (h *harness) direct(r *http.Request) (int, []byte) {
+	u := h.origin.URL + r.URL.RequestURI()
+	resp, err := http.Get(u)
+	if err != nil {
+		h.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		h.Fatal(err)
+	}
+	return resp.StatusCode, b
+}
+
+// decode returns the data points of the response body
+func (h *harness) decode(b []byte) originsim.Values {
+	v, err := h.f.Decode(b)
+	if err != nil {
+		h.Fatalf("could not decode response: %s: %s", err.Error(), string(b))
+	}
+	return v
+}
+
+// expect proxies the request, and fails the check unless the response has the cache status, when
+// provided, and the same data points as the origin's response. It returns the proxied response
+func (h *harness) expect(r *http.Request, cacheStatus string) *httptest.ResponseRecorder {
+	h.Helper()
+	w := h.proxy(r)
+	code, b := h.direct(r)
+	if w.Code != code {
+		h.Fatalf("%s: expected status %d got %d: %s", r.URL.RequestURI(), code, w.Code, w.Body.String())
+	}
+	if cacheStatus != "" {
+		if s := w.Header().Get(headers.NameTricksterResult); !strings.Contains(s, "status="+cacheStatus+";") &&
+			!strings.HasSuffix(s, "status="+cacheStatus) {
+			h.Errorf("%s: expected cache status %s got %s", r.URL.RequestURI(), cacheStatus, s)
+		}
+	}
+	if d := Diff(h.decode(b), h.decode(w.Body.Bytes())); d != "" {
+		h.Errorf("%s: proxied response differs from origin response:\n%s", r.URL.RequestURI(), d)
+	}
+	return w
+}
+
+// seriesCount returns the number of series in the responses to the suite's queries
+func (h *harness) seriesCount() int {
+	if h.f.SeriesCount > 0 {
+		return h.f.SeriesCount
+	}
+	return DefaultSeriesCount
+}
+
+// Diff returns a description of the differences between the expected and actual data points,
+// listing up to 10 of the differing data points, or an empty string when they are the same
+func Diff(expected, got originsim.Values) string {
+	ids := make(map[string]bool)
+	for id := range expected {
+		ids[id] = true
+	}
+	for id := range got {
+		ids[id] = true
+	}
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	var lines []string
+	var n int
+	for _, id := range sorted {
+		e, g := expected[id], got[id]
+		if e == nil {
+			lines = append(lines, fmt.Sprintf("series %s: unexpected series with %d points", id, len(g)))
+			continue
+		}
+		if g == nil {
+			lines = append(lines, fmt.Sprintf("series %s: missing series with %d points", id, len(e)))
+			continue
+		}
+		times := make([]int64, 0, len(e)+len(g))
+		for ms := range e {
+			times = append(times, ms)
+		}
+		for ms := range g {
+			if _, ok := e[ms]; !ok {
+				times = append(times, ms)
+			}
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+		for _, ms := range times {
+			ev, eok := e[ms]
+			gv, gok := g[ms]
+			if eok && gok && ev == gv {
+				continue
+			}
+			n++
+			if n > maxDiffs {
+				continue
+			}
+			t := time.Unix(0, ms*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+			switch {
+			case !gok:
+				lines = append(lines, fmt.Sprintf("series %s at %s: expected %v got none", id, t, ev))
+			case !eok:
+				lines = append(lines, fmt.Sprintf("series %s at %s: expected none got %v", id, t, gv))
+			default:
+				lines = append(lines, fmt.Sprintf("series %s at %s: expected %v got %v", id, t, ev, gv))
+			}
+		}
+	}
+	if n > maxDiffs {
+		lines = append(lines, fmt.Sprintf("and %d more differing data points", n-maxDiffs))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// timeseriesClient returns a standalone Client from the fixture's provider, when it is an
+// origins.TimeseriesClient
+func (h *harness) timeseriesClient() origins.TimeseriesClient {
+	conf, err := config.LoadDocument(fmt.Sprintf(`
+[origins]
+  [origins.default]
+  origin_type = '%s'
+  origin_url = '%s'
+`, h.f.OriginType, h.origin.URL))
+	if err != nil {
+		h.Fatal(err)
+	}
+	c, err := origins.NewClient("default", conf.Origins["default"], mux.NewRouter(), nil, nil,
+		tl.ConsoleLogger("error"))
+	if err != nil {
+		h.Fatal(err)
+	}
+	tc, ok := c.(origins.TimeseriesClient)
+	if !ok {
+		h.Skipf("%T is not an origins.TimeseriesClient", c)
+	}
+	return tc
+}
+
+// withPathConfig returns the request with the resources of the client's default path that
+// routes it, since some clients parse a query according to the handler of its path
+func withPathConfig(c origins.Client, r *http.Request) *http.Request {
+	var pc *po.Options
+	for _, p := range c.DefaultPathConfigs(c.Configuration()) {
+		if (p.Path == r.URL.Path || p.MatchType == matching.PathMatchTypePrefix &&
+			strings.HasPrefix(r.URL.Path, p.Path)) && (pc == nil || len(p.Path) > len(pc.Path)) {
+			pc = p
+		}
+	}
+	return request.SetResources(r, request.NewResources(c.Configuration(), pc, nil, nil, c, nil,
+		tl.ConsoleLogger("error")))
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/testdata/exampletsdb"
+	"github.com/tricksterproxy/trickster/pkg/util/testing/originsim"
+)
+
+func newRequest(path string, v url.Values) *http.Request {
+	return httptest.NewRequest(http.MethodGet, path+"?"+v.Encode(), nil)
+}
+
+func TestPrometheus(t *testing.T) {
+	Run(t, &Fixture{
+		OriginType: "prometheus",
+		Format:     originsim.FormatPrometheus,
+		Request: func(spec string, start, end time.Time, step time.Duration) *http.Request {
+			return newRequest("/api/v1/query_range", url.Values{"query": {"sim" + spec},
+				"start": {strconv.FormatInt(start.Unix(), 10)}, "end": {strconv.FormatInt(end.Unix(), 10)},
+				"step": {strconv.Itoa(int(step.Seconds()))}})
+		},
+	})
+}
+
+func TestInfluxDB(t *testing.T) {
+	Run(t, &Fixture{
+		OriginType: "influxdb",
+		Format:     originsim.FormatInfluxDB,
+		Request: func(spec string, start, end time.Time, step time.Duration) *http.Request {
+			return newRequest("/query", url.Values{"epoch": {"ms"}, "q": {fmt.Sprintf(
+				`SELECT mean("value") FROM "sim" WHERE "spec" = '%s' AND time >= %dms AND time <= %dms `+
+					`GROUP BY time(%ds)`, spec, start.Unix()*1000, end.Unix()*1000, int(step.Seconds()))}})
+		},
+	})
+}
+
+func TestClickHouse(t *testing.T) {
+	Run(t, &Fixture{
+		OriginType: "clickhouse",
+		Format:     originsim.FormatClickHouse,
+		Request: func(spec string, start, end time.Time, step time.Duration) *http.Request {
+			s := int(step.Seconds())
+			return newRequest("/", url.Values{"query": {fmt.Sprintf(
+				`SELECT (intDiv(toUInt32(ts), %d) * %d) * 1000 AS t, series_id, avg(value) AS value `+
+					`FROM sim WHERE spec = '%s' AND ts BETWEEN toDateTime(%d) AND toDateTime(%d) `+
+					`GROUP BY t, series_id ORDER BY t FORMAT JSON`, s, s, spec, start.Unix(), end.Unix())}})
+		},
+	})
+}
+
+func TestIRONdb(t *testing.T) {
+	Run(t, &Fixture{
+		OriginType: "irondb",
+		Format:     originsim.FormatIRONdb,
+		// a rollup is of a single metric
+		SeriesCount: 1,
+		Request: func(spec string, start, end time.Time, step time.Duration) *http.Request {
+			r := newRequest("/rollup/00112233-4455-6677-8899-aabbccddeeff/sim", url.Values{
+				"start_ts": {strconv.FormatInt(start.Unix(), 10)}, "end_ts": {strconv.FormatInt(end.Unix(), 10)},
+				"rollup_span": {fmt.Sprintf("%ds", int(step.Seconds()))}, "type": {"average"}})
+			r.URL.Path += spec
+			return r
+		},
+	})
+}
+
+// exampleTSDBOrigin serves the example TSDB's range query API from the origin simulator
+func exampleTSDBOrigin() http.Handler {
+	sim := originsim.NewHandler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		qp := r.URL.Query()
+		r = r.Clone(r.Context())
+		r.URL.RawQuery = url.Values{"query": {qp.Get("q")}, "start": {qp.Get("from")},
+			"end": {qp.Get("to")}, "step": {qp.Get("interval")},
+			originsim.ParamFormat: {originsim.FormatPrometheus}}.Encode()
+		sim.ServeHTTP(w, r)
+	})
+}
+
+func TestExampleTSDB(t *testing.T) {
+	Run(t, &Fixture{
+		OriginType: exampletsdb.OriginType,
+		Origin:     exampleTSDBOrigin(),
+		Format:     originsim.FormatPrometheus,
+		Request: func(spec string, start, end time.Time, step time.Duration) *http.Request {
+			return newRequest("/api/range", url.Values{"q": {"sim" + spec},
+				"from": {strconv.FormatInt(start.Unix(), 10)}, "to": {strconv.FormatInt(end.Unix(), 10)},
+				"interval": {strconv.Itoa(int(step.Seconds()))}})
+		},
+	})
+}
+
+func TestDiff(t *testing.T) {
+
+	expected := originsim.Values{"0": {1000: 1, 2000: 2}, "1": {1000: 3}}
+	if d := Diff(expected, expected); d != "" {
+		t.Errorf("expected no differences got %s", d)
+	}
+
+	got := originsim.Values{"0": {1000: 1, 2000: 4, 3000: 5}, "2": {1000: 3}}
+	d := Diff(expected, got)
+	for _, s := range []string{
+		"series 0 at 1970-01-01T00:00:02Z: expected 2 got 4",
+		"series 0 at 1970-01-01T00:00:03Z: expected none got 5",
+		"series 1: missing series with 1 points",
+		"series 2: unexpected series with 1 points",
+	} {
+		if !strings.Contains(d, s) {
+			t.Errorf("expected %s in %s", s, d)
+		}
+	}
+}
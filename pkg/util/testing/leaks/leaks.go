@@ -0,0 +1,115 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package leaks detects goroutines that are leaked by the code under test, by comparing the
+// goroutines running after the test with those that were running before it
+package leaks
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// TB is the subset of testing.TB used to report leaks
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Snapshot is the set of goroutines, by their IDs, that were running when it was taken
+type Snapshot map[string]bool
+
+// checkInterval is the interval at which Check looks for leaked goroutines
+const checkInterval = 10 * time.Millisecond
+
+// Take returns a Snapshot of the running goroutines
+func Take() Snapshot {
+	s := make(Snapshot)
+	for id := range goroutines() {
+		s[id] = true
+	}
+	return s
+}
+
+// Check reports an error to t for each goroutine that was started after the Snapshot was taken
+// and is still running when the timeout elapses. Goroutines whose stacks contain any of the
+// ignored strings are not reported
+func (s Snapshot) Check(t TB, timeout time.Duration, ignore ...string) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		leaked := s.leaked(ignore)
+		if len(leaked) == 0 {
+			return
+		}
+		if !time.Now().Before(deadline) {
+			for _, stack := range leaked {
+				t.Errorf("leaked goroutine: %s", stack)
+			}
+			return
+		}
+		time.Sleep(checkInterval)
+	}
+}
+
+func (s Snapshot) leaked(ignore []string) []string {
+	var leaked []string
+	for id, stack := range goroutines() {
+		if s[id] || ignored(stack, ignore) {
+			continue
+		}
+		leaked = append(leaked, stack)
+	}
+	return leaked
+}
+
+func ignored(stack string, ignore []string) bool {
+	for _, v := range ignore {
+		if strings.Contains(stack, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// goroutines returns the stacks of the running goroutines, other than the calling goroutine,
+// by their IDs
+func goroutines() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+	stacks := bytes.Split(buf, []byte("\n\n"))
+	m := make(map[string]string, len(stacks))
+	// the first stack is that of the calling goroutine
+	for _, b := range stacks[1:] {
+		stack := string(b)
+		// the stack begins with a header of the form "goroutine 1 [running]:"
+		f := strings.Fields(stack)
+		if len(f) < 2 || f[0] != "goroutine" {
+			continue
+		}
+		m[f[1]] = stack
+	}
+	return m
+}
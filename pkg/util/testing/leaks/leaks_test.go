@@ -0,0 +1,64 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package leaks
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type testTB struct {
+	errors []string
+}
+
+func (t *testTB) Helper() {}
+
+func (t *testTB) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func leak(release chan struct{}) {
+	<-release
+}
+
+func TestCheck(t *testing.T) {
+
+	s := Take()
+	release := make(chan struct{})
+	go leak(release)
+
+	tb := &testTB{}
+	s.Check(tb, 20*time.Millisecond)
+	if len(tb.errors) != 1 {
+		t.Fatalf("expected %d got %d", 1, len(tb.errors))
+	}
+
+	tb = &testTB{}
+	s.Check(tb, 20*time.Millisecond, "leaks.leak")
+	if len(tb.errors) != 0 {
+		t.Errorf("expected %d got %d", 0, len(tb.errors))
+	}
+
+	// goroutines that exit before the timeout are not leaked
+	time.AfterFunc(10*time.Millisecond, func() { close(release) })
+	tb = &testTB{}
+	s.Check(tb, time.Second)
+	if len(tb.errors) != 0 {
+		t.Errorf("expected %d got %d: %v", 0, len(tb.errors), tb.errors)
+	}
+}
@@ -0,0 +1,481 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package originsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// The wire formats in which the simulated series are rendered
+const (
+	FormatPrometheus = "prometheus"
+	FormatInfluxDB   = "influxdb"
+	FormatClickHouse = "clickhouse"
+	FormatIRONdb     = "irondb"
+)
+
+// ParamFormat is the name of the query parameter that selects the wire format of a response
+const ParamFormat = "format"
+
+// labelSeriesID is the label, tag or column that identifies each simulated series
+const labelSeriesID = "series_id"
+
+var (
+	reInfluxStep      = regexp.MustCompile(`(?i)group\s+by\s+.*time\(\s*([0-9]+)(ms|s|m|h|d|w)\s*\)`)
+	reInfluxStart     = regexp.MustCompile(`(?i)\btime\s*>=?\s*([0-9]+)(ns|u|µ|ms|s)?\b`)
+	reInfluxEnd       = regexp.MustCompile(`(?i)\btime\s*<=?\s*([0-9]+)(ns|u|µ|ms|s)?\b`)
+	reClickHouseStep  = regexp.MustCompile(`intDiv\(\s*toU?Int32\([^)]+\)\s*,\s*([0-9]+)\s*\)`)
+	reClickHouseRange = regexp.MustCompile(`(?i)between\s+toDateTime\(\s*([0-9]+)\s*\)\s+and\s+` +
+		`toDateTime\(\s*([0-9]+)\s*\)`)
+	reClickHouseBound = regexp.MustCompile(`(>=|>|<=|<)\s*(?:toDateTime\(\s*)?([0-9]+)`)
+)
+
+// RequestFormat returns the wire format of the request's response, from its format query
+// parameter when provided, and otherwise inferred from its path
+func RequestFormat(r *http.Request) string {
+	if f := r.URL.Query().Get(ParamFormat); f != "" {
+		return strings.ToLower(f)
+	}
+	switch {
+	case strings.Contains(r.URL.Path, "/api/v1/"):
+		return FormatPrometheus
+	case strings.HasSuffix(r.URL.Path, "/query"):
+		return FormatInfluxDB
+	case strings.Contains(r.URL.Path, "/rollup/"):
+		return FormatIRONdb
+	}
+	return FormatClickHouse
+}
+
+// ParseRequest returns the Query of the request, which is parsed as a query of the format's
+// origin type
+func ParseRequest(r *http.Request, format string) (*Query, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	q := &Query{Format: format}
+	var statement string
+	var err error
+	switch format {
+	case FormatPrometheus:
+		statement = r.Form.Get("query")
+		if q.Start, err = parsePrometheusTime(r.Form.Get("start")); err != nil {
+			return nil, err
+		}
+		if q.End, err = parsePrometheusTime(r.Form.Get("end")); err != nil {
+			return nil, err
+		}
+		if q.Step, err = parsePrometheusStep(r.Form.Get("step")); err != nil {
+			return nil, err
+		}
+	case FormatInfluxDB:
+		statement = r.Form.Get("q")
+		m := reInfluxStep.FindStringSubmatch(statement)
+		if m == nil {
+			return nil, fmt.Errorf("no group by time() in query: %s", statement)
+		}
+		if q.Step, err = parseDuration(m[1], m[2]); err != nil {
+			return nil, err
+		}
+		if q.Start, err = parseInfluxTime(reInfluxStart, statement); err != nil {
+			return nil, err
+		}
+		if q.End, err = parseInfluxTime(reInfluxEnd, statement); err != nil {
+			return nil, err
+		}
+	case FormatClickHouse:
+		statement = r.Form.Get("query")
+		m := reClickHouseStep.FindStringSubmatch(statement)
+		if m == nil {
+			return nil, fmt.Errorf("no intDiv() step in query: %s", statement)
+		}
+		if q.Step, err = parseDuration(m[1], "s"); err != nil {
+			return nil, err
+		}
+		if q.Start, q.End, err = parseClickHouseRange(statement); err != nil {
+			return nil, err
+		}
+	case FormatIRONdb:
+		i := strings.LastIndex(r.URL.Path, "/")
+		statement = r.URL.Path[i+1:]
+		if q.Start, err = parsePrometheusTime(r.Form.Get("start_ts")); err != nil {
+			return nil, err
+		}
+		if q.End, err = parsePrometheusTime(r.Form.Get("end_ts")); err != nil {
+			return nil, err
+		}
+		if q.Step, err = parseDuration(strings.TrimSuffix(r.Form.Get("rollup_span"), "s"), "s"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+	if q.Spec, err = ParseSpec(statement); err != nil {
+		return nil, err
+	}
+	if format == FormatIRONdb && q.Spec.SeriesCount > 1 {
+		// an IRONdb rollup is of a single metric, which has no data points when the spec has no
+		// series
+		q.Spec.SeriesCount = 1
+	}
+	return q, nil
+}
+
+// parsePrometheusTime parses a time provided as epoch seconds, with optional fractional seconds,
+// or in RFC3339 format
+func parsePrometheusTime(s string) (time.Time, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(0, int64(f*float64(time.Second))).Truncate(time.Millisecond), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", s)
+	}
+	return t, nil
+}
+
+// parsePrometheusStep parses a step provided as seconds, or as a duration
+func parsePrometheusStep(s string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil && f > 0 {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid step: %s", s)
+	}
+	return d, nil
+}
+
+// parseDuration parses a duration provided as a count of the unit
+func parseDuration(count, unit string) (time.Duration, error) {
+	n, err := strconv.ParseInt(count, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid step: %s%s", count, unit)
+	}
+	var d time.Duration
+	switch unit {
+	case "ms":
+		d = time.Millisecond
+	case "s":
+		d = time.Second
+	case "m":
+		d = time.Minute
+	case "h":
+		d = time.Hour
+	case "d":
+		d = 24 * time.Hour
+	case "w":
+		d = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("invalid step: %s%s", count, unit)
+	}
+	return time.Duration(n) * d, nil
+}
+
+// parseInfluxTime parses the absolute time of the InfluxQL time condition matched by re, whose
+// unit defaults to nanoseconds
+func parseInfluxTime(re *regexp.Regexp, statement string) (time.Time, error) {
+	m := re.FindStringSubmatch(statement)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("no absolute time range in query: %s", statement)
+	}
+	n, _ := strconv.ParseInt(m[1], 10, 64)
+	switch m[2] {
+	case "s":
+		return time.Unix(n, 0), nil
+	case "ms":
+		return time.Unix(0, n*int64(time.Millisecond)), nil
+	case "u", "µ":
+		return time.Unix(0, n*int64(time.Microsecond)), nil
+	}
+	return time.Unix(0, n), nil
+}
+
+// parseClickHouseRange parses the time range of a ClickHouse query, which is provided as
+// BETWEEN toDateTime(start) AND toDateTime(end), or as comparisons with epoch seconds
+func parseClickHouseRange(statement string) (time.Time, time.Time, error) {
+	if m := reClickHouseRange.FindStringSubmatch(statement); m != nil {
+		start, _ := strconv.ParseInt(m[1], 10, 64)
+		end, _ := strconv.ParseInt(m[2], 10, 64)
+		return time.Unix(start, 0), time.Unix(end, 0), nil
+	}
+	var start, end time.Time
+	for _, m := range reClickHouseBound.FindAllStringSubmatch(statement, -1) {
+		n, _ := strconv.ParseInt(m[2], 10, 64)
+		t := time.Unix(n, 0)
+		switch m[1] {
+		case ">=":
+			start = t
+		case ">":
+			start = t.Add(time.Nanosecond)
+		case "<=":
+			end = t
+		case "<":
+			end = t.Add(-time.Nanosecond)
+		}
+	}
+	if start.IsZero() || end.IsZero() {
+		return time.Time{}, time.Time{}, fmt.Errorf("no time range in query: %s", statement)
+	}
+	return start, end, nil
+}
+
+// Render returns the simulated series of the query, rendered in the query's wire format
+func Render(q *Query) ([]byte, error) {
+	times := q.Timestamps()
+	switch q.Format {
+	case FormatPrometheus:
+		type series struct {
+			Metric map[string]string `json:"metric"`
+			Values [][]interface{}   `json:"values"`
+		}
+		result := make([]series, q.Spec.SeriesCount)
+		for i := range result {
+			result[i].Metric = map[string]string{"__name__": "sim", labelSeriesID: strconv.Itoa(i)}
+			result[i].Values = make([][]interface{}, len(times))
+			for j, t := range times {
+				result[i].Values[j] = []interface{}{t.Unix(),
+					strconv.FormatInt(q.Spec.Value(i, t), 10)}
+			}
+		}
+		return json.Marshal(map[string]interface{}{"status": "success",
+			"data": map[string]interface{}{"resultType": "matrix", "result": result}})
+	case FormatInfluxDB:
+		type series struct {
+			Name    string            `json:"name"`
+			Tags    map[string]string `json:"tags"`
+			Columns []string          `json:"columns"`
+			Values  [][]interface{}   `json:"values"`
+		}
+		rows := make([]series, q.Spec.SeriesCount)
+		for i := range rows {
+			rows[i] = series{Name: "sim", Tags: map[string]string{labelSeriesID: strconv.Itoa(i)},
+				Columns: []string{"time", "value"}, Values: make([][]interface{}, len(times))}
+			for j, t := range times {
+				rows[i].Values[j] = []interface{}{t.UnixNano() / int64(time.Millisecond), q.Spec.Value(i, t)}
+			}
+		}
+		return json.Marshal(map[string]interface{}{"results": []interface{}{
+			map[string]interface{}{"statement_id": 0, "series": rows}}})
+	case FormatClickHouse:
+		data := make([]map[string]interface{}, 0, len(times)*q.Spec.SeriesCount)
+		for _, t := range times {
+			for i := 0; i < q.Spec.SeriesCount; i++ {
+				data = append(data, map[string]interface{}{
+					"t":           strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10),
+					labelSeriesID: strconv.Itoa(i),
+					"value":       q.Spec.Value(i, t),
+				})
+			}
+		}
+		return json.Marshal(map[string]interface{}{
+			"meta": []map[string]string{{"name": "t", "type": "UInt64"},
+				{"name": labelSeriesID, "type": "String"}, {"name": "value", "type": "Float64"}},
+			"data": data, "rows": len(data)})
+	case FormatIRONdb:
+		points := make([][]interface{}, 0, len(times))
+		for _, t := range times {
+			if q.Spec.SeriesCount > 0 {
+				points = append(points, []interface{}{t.Unix(), q.Spec.Value(0, t)})
+			}
+		}
+		return json.Marshal(points)
+	}
+	return nil, fmt.Errorf("unknown format: %s", q.Format)
+}
+
+// writeError writes an error response in the wire format
+func writeError(w http.ResponseWriter, format string, code int, msg string) {
+	var b []byte
+	if format == FormatPrometheus {
+		b, _ = json.Marshal(map[string]string{"status": "error", "errorType": "simulated", "error": msg})
+	} else {
+		b, _ = json.Marshal(map[string]string{"error": msg})
+	}
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// Values are the data points of a response, by series ID, and then by epoch milliseconds
+type Values map[string]map[int64]float64
+
+func (v Values) set(series string, ms int64, value interface{}) error {
+	var f float64
+	switch tv := value.(type) {
+	case float64:
+		f = tv
+	case string:
+		var err error
+		if f, err = strconv.ParseFloat(tv, 64); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unexpected value %v", value)
+	}
+	if _, ok := v[series]; !ok {
+		v[series] = make(map[int64]float64)
+	}
+	v[series][ms] = f
+	return nil
+}
+
+// toMillis returns the epoch milliseconds of a timestamp decoded from JSON, which is provided in
+// the unit when numeric, or as epoch milliseconds or in RFC3339 format when a string
+func toMillis(ts interface{}, unit time.Duration) (int64, error) {
+	switch tv := ts.(type) {
+	case float64:
+		return int64(tv * float64(unit) / float64(time.Millisecond)), nil
+	case string:
+		if n, err := strconv.ParseInt(tv, 10, 64); err == nil {
+			return n, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, tv)
+		if err != nil {
+			return 0, err
+		}
+		return t.UnixNano() / int64(time.Millisecond), nil
+	}
+	return 0, fmt.Errorf("unexpected timestamp %v", ts)
+}
+
+// Decode returns the Values of a JSON response body in the wire format, whether it was provided by
+// the simulator or by a proxy of the simulator, for comparing responses irrespective of the order
+// of their series and data points
+func Decode(format string, body []byte) (Values, error) {
+	v := make(Values)
+	switch format {
+	case FormatPrometheus:
+		doc := struct {
+			Data struct {
+				Result []struct {
+					Metric map[string]string `json:"metric"`
+					Values [][]interface{}   `json:"values"`
+				} `json:"result"`
+			} `json:"data"`
+		}{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, err
+		}
+		for _, s := range doc.Data.Result {
+			for _, p := range s.Values {
+				if len(p) != 2 {
+					return nil, fmt.Errorf("unexpected data point %v", p)
+				}
+				ms, err := toMillis(p[0], time.Second)
+				if err != nil {
+					return nil, err
+				}
+				if err := v.set(s.Metric[labelSeriesID], ms, p[1]); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case FormatInfluxDB:
+		doc := struct {
+			Results []struct {
+				Series []struct {
+					Tags   map[string]string `json:"tags"`
+					Values [][]interface{}   `json:"values"`
+				} `json:"series"`
+			} `json:"results"`
+		}{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, err
+		}
+		for _, r := range doc.Results {
+			for _, s := range r.Series {
+				for _, p := range s.Values {
+					if len(p) != 2 {
+						return nil, fmt.Errorf("unexpected data point %v", p)
+					}
+					ms, err := toMillis(p[0], time.Millisecond)
+					if err != nil {
+						return nil, err
+					}
+					if err := v.set(s.Tags[labelSeriesID], ms, p[1]); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	case FormatClickHouse:
+		doc := struct {
+			Data []map[string]interface{} `json:"data"`
+		}{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, err
+		}
+		for _, row := range doc.Data {
+			ms, err := toMillis(row["t"], time.Millisecond)
+			if err != nil {
+				return nil, err
+			}
+			series, _ := row[labelSeriesID].(string)
+			if err := v.set(series, ms, row["value"]); err != nil {
+				return nil, err
+			}
+		}
+	case FormatIRONdb:
+		var points [][]interface{}
+		if err := json.Unmarshal(body, &points); err != nil {
+			return nil, err
+		}
+		for _, p := range points {
+			if len(p) != 2 {
+				return nil, fmt.Errorf("unexpected data point %v", p)
+			}
+			ms, err := toMillis(p[0], time.Second)
+			if err != nil {
+				return nil, err
+			}
+			if err := v.set("0", ms, p[1]); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+	return v, nil
+}
+
+// Fetch returns the Values of the simulator's response to a GET request for the URL, in the
+// format of the request, for comparison with the response of a proxy of the simulator
+func Fetch(u string) (Values, error) {
+	resp, err := http.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(b))
+	}
+	return Decode(RequestFormat(resp.Request), b)
+}
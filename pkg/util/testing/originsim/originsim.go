@@ -0,0 +1,229 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package originsim is a deterministic, seedable simulator of timeseries origins, for use in
+// tests that would otherwise require a running instance of each origin type. Given a seed, a step
+// and a series spec embedded in the query, it generates values that depend only on the seed, the
+// series and the timestamp, so that a range assembled from several partial fetches is identical
+// to the same range fetched at once. The values are rendered in the wire format of the Prometheus,
+// InfluxDB, ClickHouse or IRONdb origin types, which is selected by the request's format query
+// parameter, or otherwise inferred from the request path.
+package originsim
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// Spec keys, which are provided in the query as {key=value,...}. Other keys are ignored, so that the
+// spec can be embedded in a query as, for example, a set of label matchers
+const (
+	specSeed        = "seed"
+	specSeriesCount = "series_count"
+	specMinValue    = "min_value"
+	specMaxValue    = "max_value"
+	specLatency     = "latency_ms"
+	specErrorRate   = "error_rate"
+	specStatusCode  = "status_code"
+)
+
+// Spec describes the series generated for a query, and the simulated behavior of the origin
+type Spec struct {
+	// Seed seeds the values of the series
+	Seed int64
+	// SeriesCount is the number of series generated
+	SeriesCount int
+	// MinValue is the minimum value of any data point
+	MinValue int64
+	// MaxValue is the maximum value of any data point
+	MaxValue int64
+	// Latency delays the response to each request
+	Latency time.Duration
+	// ErrorRate is the fraction of requests, from 0 to 1, that fail with StatusCode
+	ErrorRate float64
+	// StatusCode is the status code of failed requests
+	StatusCode int
+}
+
+// NewSpec returns a Spec with the default values
+func NewSpec() *Spec {
+	return &Spec{
+		SeriesCount: 1,
+		MaxValue:    100,
+		StatusCode:  http.StatusInternalServerError,
+	}
+}
+
+// ParseSpec returns the Spec embedded in the query, as the first set of comma-separated key=value
+// pairs enclosed in braces. Defaults are used for any keys that are not provided
+func ParseSpec(query string) (*Spec, error) {
+	s := NewSpec()
+	i := strings.Index(query, "{")
+	if i < 0 {
+		return s, nil
+	}
+	j := strings.Index(query[i:], "}")
+	if j < 0 {
+		return nil, fmt.Errorf("unterminated series spec in query: %s", query)
+	}
+	for _, pair := range strings.Split(query[i+1:i+j], ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		k := strings.TrimSpace(parts[0])
+		v := strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+		var err error
+		switch k {
+		case specSeed:
+			s.Seed, err = strconv.ParseInt(v, 10, 64)
+		case specSeriesCount:
+			s.SeriesCount, err = strconv.Atoi(v)
+		case specMinValue:
+			s.MinValue, err = strconv.ParseInt(v, 10, 64)
+		case specMaxValue:
+			s.MaxValue, err = strconv.ParseInt(v, 10, 64)
+		case specLatency:
+			var ms int
+			ms, err = strconv.Atoi(v)
+			s.Latency = time.Duration(ms) * time.Millisecond
+		case specErrorRate:
+			s.ErrorRate, err = strconv.ParseFloat(v, 64)
+		case specStatusCode:
+			s.StatusCode, err = strconv.Atoi(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in series spec: %s", k, v)
+		}
+	}
+	if s.SeriesCount < 0 || s.MaxValue < s.MinValue || s.ErrorRate < 0 || s.ErrorRate > 1 {
+		return nil, fmt.Errorf("invalid series spec in query: %s", query)
+	}
+	return s, nil
+}
+
+// hash returns a hash of the seed and the provided values
+func (s *Spec) hash(vals ...int64) uint64 {
+	h := fnv.New64a()
+	b := make([]byte, 8)
+	for _, v := range append([]int64{s.Seed}, vals...) {
+		binary.LittleEndian.PutUint64(b, uint64(v))
+		h.Write(b)
+	}
+	return h.Sum64()
+}
+
+// Value returns the value of the series at the provided time, which is the same for every request
+// having the same seed
+func (s *Spec) Value(series int, t time.Time) int64 {
+	return s.MinValue + int64(s.hash(int64(series), t.Unix())%uint64(s.MaxValue-s.MinValue+1))
+}
+
+// fails returns true if the nth request to the origin should fail
+func (s *Spec) fails(n uint64) bool {
+	if s.ErrorRate <= 0 {
+		return false
+	}
+	return float64(s.hash(-1, int64(n)))/math.MaxUint64 < s.ErrorRate
+}
+
+// Query is a simulated timeseries query
+type Query struct {
+	// Format is the wire format of the query's response
+	Format string
+	// Spec describes the query's series
+	Spec *Spec
+	// Start is the start of the query's time range, inclusive
+	Start time.Time
+	// End is the end of the query's time range, inclusive
+	End time.Time
+	// Step is the interval between the data points of each series
+	Step time.Duration
+}
+
+// Timestamps returns the times of the query's data points, which are the multiples of the step,
+// from the epoch, that fall within the time range
+func (q *Query) Timestamps() []time.Time {
+	if q.Step <= 0 || q.End.Before(q.Start) {
+		return nil
+	}
+	t := q.Start.Truncate(q.Step)
+	if t.Before(q.Start) {
+		t = t.Add(q.Step)
+	}
+	times := make([]time.Time, 0, int(q.End.Sub(t)/q.Step)+1)
+	for ; !t.After(q.End); t = t.Add(q.Step) {
+		times = append(times, t)
+	}
+	return times
+}
+
+// Handler is an http.Handler that simulates a timeseries origin
+type Handler struct {
+	requests uint64
+}
+
+// NewHandler returns a new Handler
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// NewServer returns a new httptest.Server that simulates a timeseries origin
+func NewServer() *httptest.Server {
+	return httptest.NewServer(NewHandler())
+}
+
+// Requests returns the number of requests the Handler has served
+func (h *Handler) Requests() int {
+	return int(atomic.LoadUint64(&h.requests))
+}
+
+// ServeHTTP responds to the request with the simulated series of its query, in the wire format
+// of the request, after any latency of its spec. Requests selected by the spec's error rate fail
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := atomic.AddUint64(&h.requests, 1)
+	format := RequestFormat(r)
+	q, err := ParseRequest(r, format)
+	if err != nil {
+		writeError(w, format, http.StatusBadRequest, err.Error())
+		return
+	}
+	if q.Spec.Latency > 0 {
+		time.Sleep(q.Spec.Latency)
+	}
+	if q.Spec.fails(n) {
+		writeError(w, format, q.Spec.StatusCode, "simulated error")
+		return
+	}
+	b, err := Render(q)
+	if err != nil {
+		writeError(w, format, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
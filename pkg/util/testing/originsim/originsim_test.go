@@ -0,0 +1,285 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package originsim
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSpec(t *testing.T) {
+
+	s, err := ParseSpec(`sim{job="a",seed=7,series_count=3,min_value=10,max_value=20,` +
+		`latency_ms=5,error_rate=0.5,status_code=502}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Spec{Seed: 7, SeriesCount: 3, MinValue: 10, MaxValue: 20,
+		Latency: 5 * time.Millisecond, ErrorRate: 0.5, StatusCode: 502}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %+v got %+v", expected, s)
+	}
+
+	s, err = ParseSpec("up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, NewSpec()) {
+		t.Errorf("expected %+v got %+v", NewSpec(), s)
+	}
+
+	for _, q := range []string{"sim{seed=7", "sim{seed=x}", "sim{min_value=5,max_value=1}",
+		"sim{error_rate=2}", "sim{series_count=-1}"} {
+		if _, err := ParseSpec(q); err == nil {
+			t.Errorf("expected error for %s", q)
+		}
+	}
+}
+
+func TestSpecValue(t *testing.T) {
+
+	s := &Spec{Seed: 1, SeriesCount: 2, MinValue: 5, MaxValue: 9}
+	ts := time.Unix(1600000000, 0)
+	v := s.Value(0, ts)
+	if v < 5 || v > 9 {
+		t.Errorf("value %d out of range", v)
+	}
+	if s.Value(0, ts) != v {
+		t.Error("expected repeatable value")
+	}
+
+	// values vary with the seed
+	s2 := &Spec{Seed: 2, SeriesCount: 2, MaxValue: 1 << 40}
+	s.MaxValue = 1 << 40
+	if s.Value(0, ts) == s2.Value(0, ts) {
+		t.Error("expected values to vary with the seed")
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	q := &Query{Start: time.Unix(100, 0), End: time.Unix(300, 0), Step: time.Minute}
+	times := q.Timestamps()
+	if len(times) != 4 || times[0].Unix() != 120 || times[3].Unix() != 300 {
+		t.Errorf("unexpected timestamps %v", times)
+	}
+	q.End = time.Unix(50, 0)
+	if len(q.Timestamps()) != 0 {
+		t.Error("expected no timestamps")
+	}
+}
+
+func TestRequestFormat(t *testing.T) {
+	tests := []struct {
+		url, expected string
+	}{
+		{"/api/v1/query_range", FormatPrometheus},
+		{"/query", FormatInfluxDB},
+		{"/rollup/uuid/metric", FormatIRONdb},
+		{"/", FormatClickHouse},
+		{"/api/v1/query_range?format=IRONdb", FormatIRONdb},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, test.url, nil)
+		if f := RequestFormat(r); f != test.expected {
+			t.Errorf("expected %s got %s", test.expected, f)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+
+	h := NewHandler()
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	const spec = `{seed=3,series_count=2}`
+	start, end := int64(1599998400), int64(1600002000)
+
+	tests := []struct {
+		format, path string
+		v            url.Values
+		series       int
+	}{
+		{FormatPrometheus, "/api/v1/query_range", url.Values{"query": {"sim" + spec},
+			"start": {"1599998400"}, "end": {"1600002000"}, "step": {"60"}}, 2},
+		{FormatInfluxDB, "/query", url.Values{"epoch": {"ms"}, "q": {`SELECT mean(value) FROM sim ` +
+			`WHERE spec = '` + spec + `' AND time >= 1599998400000ms AND time <= 1600002000000ms ` +
+			`GROUP BY time(1m)`}}, 2},
+		{FormatClickHouse, "/", url.Values{"query": {`SELECT intDiv(toUInt32(ts), 60) * 60 * 1000 AS t, ` +
+			`series_id, avg(value) AS value FROM sim WHERE spec = '` + spec + `' AND ts BETWEEN ` +
+			`toDateTime(1599998400) AND toDateTime(1600002000) GROUP BY t, series_id FORMAT JSON`}}, 2},
+		{FormatIRONdb, "/rollup/00112233-4455-6677-8899-aabbccddeeff/sim" + spec, url.Values{
+			"start_ts": {"1599998400.000"}, "end_ts": {"1600002000.000"}, "rollup_span": {"60s"}}, 1},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(ts.URL + test.path + "?" + test.v.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("(%s) expected %d got %d: %s", test.format, http.StatusOK, resp.StatusCode, b)
+		}
+		v, err := Decode(test.format, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(v) != test.series {
+			t.Errorf("(%s) expected %d series got %d", test.format, test.series, len(v))
+		}
+		s := &Spec{Seed: 3, MaxValue: 100}
+		for id, points := range v {
+			if len(points) != 61 {
+				t.Errorf("(%s) expected %d points got %d", test.format, 61, len(points))
+			}
+			i := 0
+			if id == "1" {
+				i = 1
+			}
+			for ms, val := range points {
+				if ms < start*1000 || ms > end*1000 {
+					t.Errorf("(%s) point %d out of range", test.format, ms)
+				}
+				if expected := float64(s.Value(i, time.Unix(ms/1000, 0))); val != expected {
+					t.Errorf("(%s) expected %f got %f", test.format, expected, val)
+				}
+			}
+		}
+	}
+
+	if h.Requests() != len(tests) {
+		t.Errorf("expected %d got %d", len(tests), h.Requests())
+	}
+
+	// invalid queries are bad requests
+	resp, err := http.Get(ts.URL + "/api/v1/query_range?query=up&start=x&end=1&step=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestHandlerErrorRate(t *testing.T) {
+
+	ts := NewServer()
+	defer ts.Close()
+
+	u := ts.URL + "/api/v1/query_range?" + url.Values{"query": {"sim{error_rate=0.5,status_code=503}"},
+		"start": {"0"}, "end": {"60"}, "step": {"15"}}.Encode()
+
+	var failed int
+	for i := 0; i < 100; i++ {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusServiceUnavailable:
+			failed++
+		case http.StatusOK:
+		default:
+			t.Errorf("unexpected status %d", resp.StatusCode)
+		}
+	}
+	if failed < 25 || failed > 75 {
+		t.Errorf("expected about half of requests to fail, got %d", failed)
+	}
+
+	// failures are repeatable
+	ts2 := NewServer()
+	defer ts2.Close()
+	u2 := ts2.URL + u[len(ts.URL):]
+	var failed2 int
+	for i := 0; i < 100; i++ {
+		resp, _ := http.Get(u2)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			failed2++
+		}
+	}
+	if failed != failed2 {
+		t.Errorf("expected %d got %d", failed, failed2)
+	}
+}
+
+func TestHandlerLatency(t *testing.T) {
+	ts := NewServer()
+	defer ts.Close()
+	st := time.Now()
+	resp, err := http.Get(ts.URL + "/api/v1/query_range?" + url.Values{"query": {"sim{latency_ms=20}"},
+		"start": {"0"}, "end": {"60"}, "step": {"15"}}.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if time.Since(st) < 20*time.Millisecond {
+		t.Error("expected latency")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, f := range []string{FormatPrometheus, FormatInfluxDB, FormatClickHouse, FormatIRONdb} {
+		if _, err := Decode(f, []byte("{")); err == nil {
+			t.Errorf("(%s) expected error", f)
+		}
+	}
+	if _, err := Decode("unknown", []byte("{}")); err == nil {
+		t.Error("expected error")
+	}
+	v, err := Decode(FormatInfluxDB,
+		[]byte(`{"results":[{"series":[{"tags":{"series_id":"0"},"values":[["2020-09-13T12:26:40Z",1]]}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v["0"][1600000000000] != 1 {
+		t.Errorf("unexpected values %v", v)
+	}
+}
+
+func TestParseClickHouseRange(t *testing.T) {
+	tests := []struct {
+		statement  string
+		start, end time.Time
+	}{
+		{"WHERE ts BETWEEN toDateTime(100) AND toDateTime(200)", time.Unix(100, 0), time.Unix(200, 0)},
+		{"WHERE (ts >= 100 AND ts < 200)", time.Unix(100, 0), time.Unix(200, 0).Add(-time.Nanosecond)},
+		{"WHERE ts > toDateTime(100) AND ts <= toDateTime(200)", time.Unix(100, 1), time.Unix(200, 0)},
+	}
+	for _, test := range tests {
+		start, end, err := parseClickHouseRange(test.statement)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !start.Equal(test.start) || !end.Equal(test.end) {
+			t.Errorf("expected %s to %s got %s to %s", test.start, test.end, start, end)
+		}
+	}
+	if _, _, err := parseClickHouseRange("WHERE ts >= 100"); err == nil {
+		t.Error("expected error")
+	}
+}
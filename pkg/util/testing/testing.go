@@ -37,6 +37,7 @@ import (
 	to "github.com/tricksterproxy/trickster/pkg/tracing/options"
 	tr "github.com/tricksterproxy/trickster/pkg/tracing/registration"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/testing/originsim"
 
 	"github.com/tricksterproxy/mockster/pkg/testutil"
 )
@@ -90,6 +91,10 @@ func NewTestInstance(
 	} else if originType == "rangesim" {
 		ts = testutil.NewTestServer()
 		originType = "rpc"
+	} else if strings.HasSuffix(originType, "-sim") {
+		// e.g., influxdb-sim is an influxdb origin served by the timeseries origin simulator
+		ts = originsim.NewServer()
+		originType = strings.TrimSuffix(originType, "-sim")
 	} else {
 		isBasicTestServer = true
 		ts = NewTestServer(respCode, respBody, respHeaders)
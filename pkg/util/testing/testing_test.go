@@ -93,6 +93,16 @@ func TestNewTestInstance(t *testing.T) {
 		t.Error(err)
 	}
 
+	// cover the origin simulator conditional
+
+	s, _, _, _, err = NewTestInstance("", f, 200, "", nil, "influxdb-sim", "test", "debug")
+	if s == nil {
+		t.Error("Expected server pointer, got nil")
+	}
+	if err != nil {
+		t.Error(err)
+	}
+
 	// cover config file provided
 
 	_, _, _, _, err = NewTestInstance("../../../testdata/test.full.conf", f, 200, "", nil, "promsim", "test", "debug")